@@ -63,6 +63,7 @@ const (
 	NotPermError          FsError = FsError(int(unix.EPERM))        // Operation not permitted
 	NotFoundError         FsError = FsError(int(unix.ENOENT))       // No such file or directory
 	IOError               FsError = FsError(int(unix.EIO))          // I/O error
+	NoSuchAddressError    FsError = FsError(int(unix.ENXIO))        // No such device or address
 	TooBigError           FsError = FsError(int(unix.E2BIG))        // Argument list too long
 	TooManyArgsError      FsError = FsError(int(unix.E2BIG))        // Arg list too long
 	BadFileError          FsError = FsError(int(unix.EBADF))        // Bad file number
@@ -92,6 +93,9 @@ const (
 	NotSupportedError     FsError = FsError(int(unix.ENOTSUP))      // Operation not supported
 	NoDataError           FsError = FsError(int(unix.ENODATA))      // No data available
 	TimedOut              FsError = FsError(int(unix.ETIMEDOUT))    // Connection Timed Out
+	StaleHandleError      FsError = FsError(int(unix.ESTALE))       // Stale file handle
+	CanceledError         FsError = FsError(int(unix.ECANCELED))    // Operation Canceled
+	DeadlockError         FsError = FsError(int(unix.EDEADLK))      // Resource deadlock would occur
 )
 
 // Errors that map to constants already defined above
@@ -123,6 +127,7 @@ const (
 	StreamNotFound        FsError = NoDataError
 	AccountNotModifiable  FsError = NotPermError
 	OldMetaDataDifferent  FsError = TryAgainError
+	RangeNotSatisfiable   FsError = NoSuchAddressError
 )
 
 // Success error (sounds odd, no? - perhaps this could be renamed "NotAnError"?)
@@ -134,6 +139,7 @@ const ( // reset iota to 0
 	PackError
 	CorruptInodeError
 	NotAnObjectError
+	PreconditionFailed
 )
 
 // Default errno values for success and failure
@@ -49,8 +49,10 @@ func my_function() {
 package dlm
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 type NotifyReason uint32
@@ -75,6 +77,7 @@ type Notify interface {
 }
 
 type RWLockStruct struct {
+	Domain       string // lock domain (see domain.go) in which LockID is unique
 	LockID       string
 	Notify       Notify
 	LockCallerID CallerID
@@ -101,9 +104,15 @@ func GenerateCallerID() (callerID CallerID) {
 	return callerID
 }
 
-// IsLockHeld() returns
+// IsLockHeld() returns whether the lock in the DefaultDomain is held by callerID
 func IsLockHeld(lockID string, callerID CallerID, lockHeldType LockHeldType) (held bool) {
-	held = isLockHeld(lockID, callerID, lockHeldType)
+	held = isLockHeld(DefaultDomain, lockID, callerID, lockHeldType)
+	return held
+}
+
+// IsLockHeldInDomain() returns whether the lock in the named domain is held by callerID
+func IsLockHeldInDomain(domainName string, lockID string, callerID CallerID, lockHeldType LockHeldType) (held bool) {
+	held = isLockHeld(domainName, lockID, callerID, lockHeldType)
 	return held
 }
 
@@ -119,39 +128,85 @@ func (l *RWLockStruct) GetCallerID() CallerID {
 
 // Returns whether the lock is held for reading
 func (l *RWLockStruct) IsReadHeld() bool {
-	held := isLockHeld(l.LockID, l.LockCallerID, READLOCK)
+	held := isLockHeld(l.Domain, l.LockID, l.LockCallerID, READLOCK)
 	return held
 }
 
 // Returns whether the lock is held for writing
 func (l *RWLockStruct) IsWriteHeld() bool {
-	held := isLockHeld(l.LockID, l.LockCallerID, WRITELOCK)
+	held := isLockHeld(l.Domain, l.LockID, l.LockCallerID, WRITELOCK)
 	return held
 }
 
 // WriteLock() blocks until the lock for the inode can be held exclusively.
 func (l *RWLockStruct) WriteLock() (err error) {
 	// TODO - what errors are possible here?
-	err = l.commonLock(exclusive, false)
+	err = l.commonLock(nil, exclusive, false)
 	return err
 }
 
 // ReadLock() blocks until the lock for the inode can be held shared.
 func (l *RWLockStruct) ReadLock() (err error) {
 	// TODO - what errors are possible here?
-	err = l.commonLock(shared, false)
+	err = l.commonLock(nil, shared, false)
+	return err
+}
+
+// WriteLockWithTimeout() is WriteLock() giving up, failing with TimedOut, should the lock not be
+// granted within timeout.
+func (l *RWLockStruct) WriteLockWithTimeout(timeout time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = l.commonLock(ctx, exclusive, false)
+	return err
+}
+
+// ReadLockWithTimeout() is ReadLock() giving up, failing with TimedOut, should the lock not be
+// granted within timeout.
+func (l *RWLockStruct) ReadLockWithTimeout(timeout time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = l.commonLock(ctx, shared, false)
+	return err
+}
+
+// WriteLockWithContext() is WriteLock() giving up should ctx be done before the lock is granted,
+// failing with TimedOut if its deadline passed or CanceledError if it was canceled.
+func (l *RWLockStruct) WriteLockWithContext(ctx context.Context) (err error) {
+	err = l.commonLock(ctx, exclusive, false)
+	return err
+}
+
+// ReadLockWithContext() is ReadLock() giving up should ctx be done before the lock is granted,
+// failing with TimedOut if its deadline passed or CanceledError if it was canceled.
+func (l *RWLockStruct) ReadLockWithContext(ctx context.Context) (err error) {
+	err = l.commonLock(ctx, shared, false)
 	return err
 }
 
 // TryWriteLock() attempts to grab the lock if is is free.  Otherwise, it returns EAGAIN.
 func (l *RWLockStruct) TryWriteLock() (err error) {
-	err = l.commonLock(exclusive, true)
+	err = l.commonLock(nil, exclusive, true)
 	return err
 }
 
 // TryReadLock() attempts to grab the lock if is is free or shared.  Otherwise, it returns EAGAIN.
 func (l *RWLockStruct) TryReadLock() (err error) {
-	err = l.commonLock(shared, true)
+	err = l.commonLock(nil, shared, true)
+	return err
+}
+
+// Upgrade() converts the lock held shared by the caller to exclusive without releasing it, blocking until
+// any other holders release it. It fails with DeadlockError (leaving the lock held shared) should another
+// holder already be upgrading it (see upgrade.go).
+func (l *RWLockStruct) Upgrade() (err error) {
+	err = l.upgrade()
+	return err
+}
+
+// Downgrade() converts the lock held exclusively by the caller to shared without releasing it.
+func (l *RWLockStruct) Downgrade() (err error) {
+	err = l.downgrade()
 	return err
 }
 
@@ -0,0 +1,167 @@
+package dlm
+
+// Lock backends
+//
+// The node-local lock manager (see llm.go) arbitrates between the threads of a single proxyfsd. A Backend
+// additionally arbitrates each lock between proxyfsd nodes. Once the local lock manager has granted a
+// lock, it acquires it from the Backend (in the same mode, on behalf of the same caller) before returning;
+// upon Unlock(), it releases the lock to the Backend before releasing it locally. Should the Backend fail
+// to grant the lock, the local grant is undone and the error returned.
+//
+// Note that arbitrating locks between nodes does not by itself allow them to serve the same volume: each
+// volume is served by its single [Volume:<VolumeName>]PrimaryPeer, whose inode caches and checkpoints are
+// not kept coherent with any other node. Nor is any Backend provided replicated (e.g. by etcd or a raft
+// group): the lockserver Backend relies upon a single lock server, without which no node may acquire a lock.
+//
+// The Backend is selected by [DLM]Backend:
+//
+//   local       (the default) locks are only arbitrated between the threads of this node
+//   lockserver  locks are also arbitrated by the lock server (see lock_server.go) run by [DLM]LockServerPeer
+//               and listening on its [Peer:<LockServerPeer>]PrivateIPAddr at [DLM]LockServerPort, its
+//               connections authenticated by [DLM]LockServerSecret and fenced after [DLM]LockServerFenceTimeout
+//
+// Alternatively, a Backend may be supplied (e.g. one backed by an external coordination service) via
+// SetBackend().
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// Backend arbitrates locks between nodes.
+//
+// Callers identify a lock by domainName and lockID (see domain.go) and its holder by callerID (unique
+// amongst the holders on this node). Acquire() blocks until the lock is granted unless try is true,
+// in which case it instead fails with TryAgainError should the lock be unavailable. If ctx is non-nil,
+// Acquire() instead fails with TimedOut or CanceledError (see lockWaitError()) should ctx be done before
+// the lock is granted (leaving the lock not held). A caller may hold
+// the same lock shared more than once, releasing it via a matching number of Release()s. Upgrade() and
+// Downgrade() convert a lock held by the caller between shared and exclusive (see upgrade.go), Upgrade()
+// blocking until any other holders release it (or failing with DeadlockError).
+type Backend interface {
+	Acquire(ctx context.Context, domainName string, lockID string, callerID string, exclusive bool, try bool) (err error)
+	Release(domainName string, lockID string, callerID string) (err error)
+	Upgrade(domainName string, lockID string, callerID string) (err error)
+	Downgrade(domainName string, lockID string, callerID string) (err error)
+	Close() (err error)
+}
+
+// localBackendStruct is the Backend arbitrating locks between the threads of this node alone.
+type localBackendStruct struct{}
+
+func (backend *localBackendStruct) Acquire(ctx context.Context, domainName string, lockID string, callerID string, exclusive bool, try bool) (err error) {
+	return
+}
+
+func (backend *localBackendStruct) Release(domainName string, lockID string, callerID string) (err error) {
+	return
+}
+
+func (backend *localBackendStruct) Upgrade(domainName string, lockID string, callerID string) (err error) {
+	return
+}
+
+func (backend *localBackendStruct) Downgrade(domainName string, lockID string, callerID string) (err error) {
+	return
+}
+
+func (backend *localBackendStruct) Close() (err error) {
+	return
+}
+
+// SetBackend replaces the Backend, returning the one replaced. It must only be called while no lock is
+// held (e.g. just after Up()).
+func SetBackend(backend Backend) (previousBackend Backend) {
+	globals.Lock()
+	previousBackend = globals.backend
+	globals.backend = backend
+	globals.Unlock()
+	return
+}
+
+func fetchBackend() (backend Backend) {
+	globals.Lock()
+	backend = globals.backend
+	globals.Unlock()
+	return
+}
+
+// callerIDString returns the string by which callerID is known to the Backend.
+func callerIDString(callerID CallerID) string {
+	if nil == callerID {
+		return ""
+	}
+	return *callerID
+}
+
+// makeBackend returns the Backend selected by [DLM]Backend (starting the lock server should this node run it).
+func makeBackend(confMap conf.ConfMap) (backend Backend, err error) {
+	backendName, fetchErr := confMap.FetchOptionValueString("DLM", "Backend")
+	if nil != fetchErr {
+		backendName = "local"
+	}
+
+	switch backendName {
+	case "local":
+		backend = &localBackendStruct{}
+	case "lockserver":
+		lockServerPeer, fetchErr := confMap.FetchOptionValueString("DLM", "LockServerPeer")
+		if nil != fetchErr {
+			err = fetchErr
+			return
+		}
+		lockServerPort, fetchErr := confMap.FetchOptionValueUint16("DLM", "LockServerPort")
+		if nil != fetchErr {
+			err = fetchErr
+			return
+		}
+		lockServerIPAddr, fetchErr := confMap.FetchOptionValueString(utils.PeerNameConfSection(lockServerPeer), "PrivateIPAddr")
+		if nil != fetchErr {
+			err = fetchErr
+			return
+		}
+		lockServerSecret, fetchErr := confMap.FetchOptionValueString("DLM", "LockServerSecret")
+		if nil != fetchErr {
+			err = fetchErr
+			return
+		}
+		if "" == lockServerSecret {
+			err = fmt.Errorf("[DLM]LockServerSecret must not be empty")
+			return
+		}
+		lockServerFenceTimeout, fetchErr := confMap.FetchOptionValueDuration("DLM", "LockServerFenceTimeout")
+		if nil != fetchErr {
+			lockServerFenceTimeout = 10 * time.Second
+		}
+		if 0 == lockServerFenceTimeout {
+			err = fmt.Errorf("[DLM]LockServerFenceTimeout must be non-zero")
+			return
+		}
+		whoAmI, fetchErr := confMap.FetchOptionValueString("Cluster", "WhoAmI")
+		if nil != fetchErr {
+			err = fetchErr
+			return
+		}
+
+		lockServerAddr := net.JoinHostPort(lockServerIPAddr, strconv.Itoa(int(lockServerPort)))
+
+		if whoAmI == lockServerPeer {
+			globals.lockServer, err = startLockServer(lockServerAddr, lockServerSecret, lockServerFenceTimeout)
+			if nil != err {
+				return
+			}
+		}
+
+		backend = newLockServerBackend(lockServerAddr, lockServerSecret, lockServerFenceTimeout)
+	default:
+		err = fmt.Errorf("[DLM]Backend must be either \"local\" or \"lockserver\" (not \"%s\")", backendName)
+	}
+
+	return
+}
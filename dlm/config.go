@@ -11,22 +11,34 @@ import (
 type globalsStruct struct {
 	sync.Mutex
 
-	// Map used to store locks owned locally
+	// Map of lock domains (see domain.go)
 	// NOTE: This map is protected by the Mutex
-	localLockMap map[string]*localLockTrack
+	domainMap map[string]*lockDomainStruct
 
-	// TODO - channels for STOP and from DLM lock master?
-	// is the channel lock one per lock or a global one from DLM?
-	// how could it be... probably just one receive thread the locks
-	// map, checks bit and releases lock if no one using, otherwise
-	// blocks until it is free...
+	// Map of fairness policies of lock domains not using FairnessFIFO (see fairness.go)
+	// NOTE: This map is protected by the Mutex
+	fairnessPolicyMap map[string]FairnessPolicy
+
+	backend    Backend           // see backend.go (protected by the Mutex)
+	lockServer *lockServerStruct // non-nil if this node runs the lock server (see lock_server.go)
+
+	deadlockDetector *deadlockDetectorStruct // nil if deadlock detection is disabled (see deadlock.go)
+	lastWaitSeq      uint64                  // seq of the request last to begin waiting (updated atomically)
 }
 
 var globals globalsStruct
 
 func Up(confMap conf.ConfMap) (err error) {
-	// Create map used to store locks
-	globals.localLockMap = make(map[string]*localLockTrack)
+	// Create map used to store lock domains
+	globals.domainMap = make(map[string]*lockDomainStruct)
+	globals.fairnessPolicyMap = make(map[string]FairnessPolicy)
+
+	globals.deadlockDetector, err = startDeadlockDetector(confMap)
+	if nil != err {
+		return
+	}
+
+	globals.backend, err = makeBackend(confMap)
 	return
 }
 
@@ -43,5 +55,17 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 }
 
 func Down() (err error) {
+	if nil != globals.deadlockDetector {
+		globals.deadlockDetector.stop()
+		globals.deadlockDetector = nil
+	}
+	if nil != globals.backend {
+		err = globals.backend.Close()
+		globals.backend = nil
+	}
+	if nil != globals.lockServer {
+		globals.lockServer.stop()
+		globals.lockServer = nil
+	}
 	return
 }
@@ -0,0 +1,274 @@
+package dlm
+
+// Deadlock detection
+//
+// Paths holding more than one lock at a time (e.g. fs's Rename(), MiddlewareCoalesce(), and
+// MiddlewarePutComplete()) deadlock should each of two (or more) of them wait for a lock another holds.
+// Every [DLM]DeadlockDetectionInterval, the wait-for graph of the callers awaiting locks is built from the
+// localLockTrack's of every domain: a caller waits for each owner of the lock it awaits, as well as for the
+// caller of each other request for the lock that its domain's fairness policy (see fairness.go) would grant
+// ahead of its own (e.g., for FairnessFIFO, those queued ahead of it unless both are shared) or that upgrades
+// it (see upgrade.go). A caller upgrading a lock waits for its other owners.
+// For each cycle found, the youngest of the requests making it up (i.e. the one that began waiting last) is
+// failed with DeadlockError (EDEADLK) and the cycle logged. Its caller is then expected to release the
+// locks it holds (as it would upon any other error), allowing the others to proceed.
+//
+// Only the locks of this node are considered. As the lock server (see lock_server.go) keeps the locks it
+// arbitrates in its node's lock manager, that node also detects deadlocks spanning nodes (the failed
+// request's Backend Acquire() failing with DeadlockError). A caller is never considered to wait for itself
+// (as concurrent threads may share a CallerID).
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+type deadlockDetectorStruct struct {
+	interval time.Duration
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// waitForEdgeStruct records that waiter, a request for track's lock, waits for holder: as an owner of the
+// lock if blocker is nil, else as the caller of blocker (another request to be granted first).
+type waitForEdgeStruct struct {
+	domain  *lockDomainStruct
+	track   *localLockTrack
+	waiter  *localLockRequest
+	holder  CallerID
+	blocker *localLockRequest
+}
+
+// startDeadlockDetector starts detecting deadlocks every [DLM]DeadlockDetectionInterval (returning nil
+// should that be zero).
+func startDeadlockDetector(confMap conf.ConfMap) (deadlockDetector *deadlockDetectorStruct, err error) {
+	interval, err := confMap.FetchOptionValueDuration("DLM", "DeadlockDetectionInterval")
+	if nil != err {
+		interval = time.Second
+		err = nil
+	}
+	if 0 == interval {
+		return
+	}
+
+	deadlockDetector = &deadlockDetectorStruct{
+		interval: interval,
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+
+	go deadlockDetector.run()
+
+	return
+}
+
+func (deadlockDetector *deadlockDetectorStruct) run() {
+	defer close(deadlockDetector.doneChan)
+
+	ticker := time.NewTicker(deadlockDetector.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deadlockDetector.stopChan:
+			return
+		case <-ticker.C:
+			_ = detectDeadlocks()
+		}
+	}
+}
+
+func (deadlockDetector *deadlockDetectorStruct) stop() {
+	close(deadlockDetector.stopChan)
+	<-deadlockDetector.doneChan
+}
+
+// detectDeadlocks fails a request of each deadlock found, returning the number of them.
+func detectDeadlocks() (deadlocks int) {
+	for _, cycle := range findWaitForCycles(buildWaitForGraph()) {
+		if breakDeadlock(cycle) {
+			deadlocks++
+		}
+	}
+	return
+}
+
+// buildWaitForGraph returns, for each caller awaiting a lock, the callers it waits for.
+func buildWaitForGraph() (graph map[CallerID][]*waitForEdgeStruct) {
+	globals.Lock()
+	domains := make([]*lockDomainStruct, 0, len(globals.domainMap))
+	for _, domain := range globals.domainMap {
+		domains = append(domains, domain)
+	}
+	globals.Unlock()
+
+	graph = make(map[CallerID][]*waitForEdgeStruct)
+
+	for _, domain := range domains {
+		domain.Lock()
+		fairnessPolicy := domain.fairnessPolicy
+		for _, track := range domain.localLockMap {
+			track.Mutex.Lock()
+			upgrader := track.upgrader
+			if (nil != upgrader) && ((nil == upgrader.LockCallerID) || (nil != upgrader.failure)) {
+				upgrader = nil
+			}
+			if nil != upgrader {
+				for _, owner := range track.listOfOwners {
+					if (nil != owner) && (owner != upgrader.LockCallerID) {
+						graph[upgrader.LockCallerID] = append(graph[upgrader.LockCallerID],
+							&waitForEdgeStruct{domain: domain, track: track, waiter: upgrader, holder: owner})
+					}
+				}
+			}
+			for elem := track.waitReqQ.Front(); nil != elem; elem = elem.Next() {
+				waiter := elem.Value.(*localLockRequest)
+				if (nil == waiter.LockCallerID) || (nil != waiter.failure) {
+					continue
+				}
+				if (nil != upgrader) && (upgrader.LockCallerID != waiter.LockCallerID) {
+					graph[waiter.LockCallerID] = append(graph[waiter.LockCallerID],
+						&waitForEdgeStruct{domain: domain, track: track, waiter: waiter, holder: upgrader.LockCallerID, blocker: upgrader})
+				}
+				for _, owner := range track.listOfOwners {
+					if (nil != owner) && (owner != waiter.LockCallerID) {
+						graph[waiter.LockCallerID] = append(graph[waiter.LockCallerID],
+							&waitForEdgeStruct{domain: domain, track: track, waiter: waiter, holder: owner})
+					}
+				}
+				ahead := true
+				for other := track.waitReqQ.Front(); nil != other; other = other.Next() {
+					if other == elem {
+						ahead = false
+						continue
+					}
+					blocker := other.Value.(*localLockRequest)
+					if (nil == blocker.LockCallerID) || (blocker.LockCallerID == waiter.LockCallerID) || (nil != blocker.failure) {
+						continue
+					}
+					if queuedRequestBlocks(fairnessPolicy, waiter, blocker, ahead) {
+						graph[waiter.LockCallerID] = append(graph[waiter.LockCallerID],
+							&waitForEdgeStruct{domain: domain, track: track, waiter: waiter, holder: blocker.LockCallerID, blocker: blocker})
+					}
+				}
+			}
+			track.Mutex.Unlock()
+		}
+		domain.Unlock()
+	}
+
+	return
+}
+
+// findWaitForCycles returns the cycles (each as the edges making it up) found in graph.
+//
+// As graph was built a lock at a time, a cycle need not be a deadlock (see breakDeadlock()).
+func findWaitForCycles(graph map[CallerID][]*waitForEdgeStruct) (cycles [][]*waitForEdgeStruct) {
+	const (
+		unvisited = iota
+		onPath
+		visited
+	)
+
+	state := make(map[CallerID]int)
+	path := make([]*waitForEdgeStruct, 0)
+
+	var visit func(caller CallerID)
+	visit = func(caller CallerID) {
+		state[caller] = onPath
+		for _, edge := range graph[caller] {
+			switch state[edge.holder] {
+			case unvisited:
+				path = append(path, edge)
+				visit(edge.holder)
+				path = path[:len(path)-1]
+			case onPath:
+				start := len(path)
+				for path[start-1].waiter.LockCallerID != edge.holder {
+					start--
+				}
+				cycle := append(append(make([]*waitForEdgeStruct, 0, len(path)-start+2), path[start-1:]...), edge)
+				cycles = append(cycles, cycle)
+			}
+		}
+		state[caller] = visited
+	}
+
+	for caller := range graph {
+		if unvisited == state[caller] {
+			visit(caller)
+		}
+	}
+
+	return
+}
+
+// stillWaits reports whether edge yet holds. Caller must hold edge.track's Mutex.
+func (edge *waitForEdgeStruct) stillWaits() bool {
+	if edge.waiter.wakeUp || (nil != edge.waiter.failure) {
+		return false
+	}
+	if nil == edge.blocker {
+		return callerInListOfOwners(edge.track.listOfOwners, edge.holder)
+	}
+	// Neither having left waitReqQ (nor their relative order having changed), blocker still blocks waiter
+	return !edge.blocker.wakeUp && (nil == edge.blocker.failure)
+}
+
+// breakDeadlock fails the youngest request of cycle should (with every lock involved held at once) it
+// prove to be a deadlock, returning whether it was.
+func breakDeadlock(cycle []*waitForEdgeStruct) (deadlock bool) {
+	lockedTracks := make(map[*localLockTrack]bool)
+	for _, edge := range cycle {
+		if !lockedTracks[edge.track] {
+			edge.track.Mutex.Lock()
+			lockedTracks[edge.track] = true
+		}
+	}
+	defer func() {
+		for track := range lockedTracks {
+			track.Mutex.Unlock()
+		}
+	}()
+
+	victim := cycle[0]
+	for _, edge := range cycle {
+		if !edge.stillWaits() {
+			return false
+		}
+		if edge.waiter.seq > victim.waiter.seq {
+			victim = edge
+		}
+	}
+
+	edgeDescriptions := make([]string, len(cycle))
+	for i, edge := range cycle {
+		requestedState := "shared"
+		if exclusive == edge.waiter.requestedState {
+			requestedState = "exclusive"
+		}
+		waitReason := "held by"
+		if nil != edge.blocker {
+			waitReason = "queued behind"
+		}
+		edgeDescriptions[i] = fmt.Sprintf("caller %s awaits %s lock %s:%s %s caller %s",
+			callerIDString(edge.waiter.LockCallerID), requestedState, edge.domain.name, edge.track.lockId, waitReason, callerIDString(edge.holder))
+	}
+	cycleDescription := strings.Join(edgeDescriptions, ", ")
+
+	logger.Errorf("dlm deadlock detected (%s)... failing request of caller %s for lock %s:%s",
+		cycleDescription, callerIDString(victim.waiter.LockCallerID), victim.domain.name, victim.track.lockId)
+
+	victim.waiter.failure = blunder.NewError(blunder.DeadlockError, "Deadlock detected (%s)", cycleDescription)
+	victim.waiter.Cond.Broadcast()
+	atomic.AddUint64(&victim.domain.deadlocks, 1)
+
+	deadlock = true
+	return
+}
@@ -0,0 +1,146 @@
+package dlm
+
+// Lock domains
+//
+// Each lock lives in the domain named by its RWLockStruct's Domain (e.g. the name of the volume
+// containing the inode it protects). LockIDs need only be unique within a domain. Each domain has its
+// own map of locks (and mutex protecting it), so that lock traffic on one volume does not contend with
+// that of another, as well as its own contention statistics. Once a domain is no longer in use (e.g.
+// once its volume is unmounted), it may be dropped in its entirety via DropDomain().
+//
+// A domain is created on first use. The DefaultDomain ("") serves callers not specifying one.
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+const DefaultDomain = ""
+
+// DomainStatsStruct is returned by FetchDomainStats().
+type DomainStatsStruct struct {
+	TrackedLocks          uint64 // locks currently held or waited for
+	Acquisitions          uint64 // locks granted
+	ContendedAcquisitions uint64 // locks granted only after waiting for another holder
+	TryFailures           uint64 // TryReadLock()'s & TryWriteLock()'s failing with EAGAIN
+	AbandonedAcquisitions uint64 // lock requests given up (timed out or canceled) while waiting
+	Deadlocks             uint64 // lock requests failed with DeadlockError (see deadlock.go)
+}
+
+type lockDomainStruct struct {
+	sync.Mutex
+	name                  string
+	dropped               bool                       // if true, domain has been removed from globals.domainMap
+	localLockMap          map[string]*localLockTrack // protected by Mutex
+	acquisitions          uint64                     // updated atomically
+	contendedAcquisitions uint64                     // updated atomically
+	tryFailures           uint64                     // updated atomically
+	abandonedAcquisitions uint64                     // updated atomically
+	deadlocks             uint64                     // updated atomically
+	fairnessPolicy        FairnessPolicy             // protected by Mutex (see fairness.go)
+}
+
+// lockDomain returns the named domain, creating it if necessary, with its Mutex held.
+func lockDomain(domainName string) (domain *lockDomainStruct) {
+	var ok bool
+
+	for {
+		globals.Lock()
+		domain, ok = globals.domainMap[domainName]
+		if !ok {
+			domain = &lockDomainStruct{name: domainName, localLockMap: make(map[string]*localLockTrack), fairnessPolicy: globals.fairnessPolicyMap[domainName]}
+			globals.domainMap[domainName] = domain
+		}
+		globals.Unlock()
+
+		domain.Lock()
+		if !domain.dropped {
+			return domain
+		}
+
+		// Raced a DropDomain()... so retry (creating a new instance of the domain)
+
+		domain.Unlock()
+	}
+}
+
+// lockExistingDomain is lockDomain() that returns nil (rather than creating it) if the named domain
+// does not exist.
+func lockExistingDomain(domainName string) (domain *lockDomainStruct) {
+	globals.Lock()
+	domain, ok := globals.domainMap[domainName]
+	globals.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	domain.Lock()
+	if domain.dropped {
+		domain.Unlock()
+		return nil
+	}
+
+	return domain
+}
+
+// FetchDomainNames returns the names of all lock domains (in sorted order).
+func FetchDomainNames() (domainNames []string) {
+	globals.Lock()
+	domainNames = make([]string, 0, len(globals.domainMap))
+	for domainName := range globals.domainMap {
+		domainNames = append(domainNames, domainName)
+	}
+	globals.Unlock()
+
+	sort.Strings(domainNames)
+	return
+}
+
+// FetchDomainStats returns the statistics of the named lock domain. If the domain does not exist,
+// ok is false.
+func FetchDomainStats(domainName string) (domainStats DomainStatsStruct, ok bool) {
+	domain := lockExistingDomain(domainName)
+	if nil == domain {
+		return
+	}
+
+	domainStats.TrackedLocks = uint64(len(domain.localLockMap))
+	domain.Unlock()
+
+	domainStats.Acquisitions = atomic.LoadUint64(&domain.acquisitions)
+	domainStats.ContendedAcquisitions = atomic.LoadUint64(&domain.contendedAcquisitions)
+	domainStats.TryFailures = atomic.LoadUint64(&domain.tryFailures)
+	domainStats.AbandonedAcquisitions = atomic.LoadUint64(&domain.abandonedAcquisitions)
+	domainStats.Deadlocks = atomic.LoadUint64(&domain.deadlocks)
+
+	ok = true
+	return
+}
+
+// DropDomain removes the named lock domain (and its statistics). It fails with DevBusyError should
+// any lock in the domain be held or waited for.
+func DropDomain(domainName string) (err error) {
+	domain := lockExistingDomain(domainName)
+	if nil == domain {
+		return
+	}
+
+	if 0 != len(domain.localLockMap) {
+		err = blunder.NewError(blunder.DevBusyError, "lock domain \"%s\" still tracks %d locks", domainName, len(domain.localLockMap))
+		domain.Unlock()
+		return
+	}
+
+	globals.Lock()
+	delete(globals.domainMap, domainName)
+	globals.Unlock()
+
+	domain.dropped = true
+	domain.Unlock()
+
+	return
+}
@@ -0,0 +1,140 @@
+package dlm
+
+// Fairness policies
+//
+// The order in which the requests waiting for a lock are granted (as it is released, or as requests are
+// made or withdrawn) is set by the fairness policy of its domain:
+//
+//   FairnessFIFO              (the default) requests are granted in the order they were made: each exclusive
+//                             request waits for every request ahead of it, while a shared request waits only
+//                             for exclusive requests ahead of it. No request is starved.
+//   FairnessWriterPreference  while any exclusive request waits, no shared request is granted. Readers may be
+//                             starved by a steady stream of writers.
+//   FairnessReaderPreference  shared requests are granted whenever the lock is not held exclusively, even ahead
+//                             of exclusive requests made earlier. Writers may be starved by a steady stream of
+//                             (overlapping) readers.
+//
+// TryReadLock() and TryWriteLock() succeed only if the policy would grant their request immediately.
+
+import (
+	"container/list"
+)
+
+type FairnessPolicy int
+
+const (
+	FairnessFIFO FairnessPolicy = iota
+	FairnessWriterPreference
+	FairnessReaderPreference
+)
+
+// SetDomainFairnessPolicy sets the fairness policy of the named lock domain (retained should it be dropped
+// and later recreated).
+func SetDomainFairnessPolicy(domainName string, fairnessPolicy FairnessPolicy) {
+	globals.Lock()
+	if FairnessFIFO == fairnessPolicy {
+		delete(globals.fairnessPolicyMap, domainName)
+	} else {
+		globals.fairnessPolicyMap[domainName] = fairnessPolicy
+	}
+	globals.Unlock()
+
+	domain := lockExistingDomain(domainName)
+	if nil == domain {
+		return
+	}
+
+	domain.fairnessPolicy = fairnessPolicy
+
+	// Requests already waiting may be granted under the new policy
+	for _, track := range domain.localLockMap {
+		track.Mutex.Lock()
+		processLocalQ(track, fairnessPolicy)
+		track.Mutex.Unlock()
+	}
+
+	domain.Unlock()
+}
+
+// FetchDomainFairnessPolicy returns the fairness policy of the named lock domain.
+func FetchDomainFairnessPolicy(domainName string) (fairnessPolicy FairnessPolicy) {
+	globals.Lock()
+	fairnessPolicy = globals.fairnessPolicyMap[domainName]
+	globals.Unlock()
+	return
+}
+
+// queuedRequestBlocks reports whether, under fairnessPolicy, waiter cannot be granted before blocker (also
+// waiting, and queued ahead of waiter if ahead).
+func queuedRequestBlocks(fairnessPolicy FairnessPolicy, waiter *localLockRequest, blocker *localLockRequest, ahead bool) bool {
+	switch fairnessPolicy {
+	case FairnessWriterPreference:
+		if waiter.requestedState == shared {
+			return blocker.requestedState == exclusive
+		}
+		return ahead && (blocker.requestedState == exclusive)
+	case FairnessReaderPreference:
+		if waiter.requestedState == shared {
+			return false
+		}
+		return ahead || (blocker.requestedState == shared)
+	default:
+		return ahead && ((waiter.requestedState == exclusive) || (blocker.requestedState == exclusive))
+	}
+}
+
+// grantQueuedShared grants every shared request in waitReqQ.
+//
+// This function assumes that the tracking mutex is held (and that the lock is not held exclusively).
+func grantQueuedShared(track *localLockTrack) {
+	var nextElem *list.Element
+
+	for elem := track.waitReqQ.Front(); nil != elem; elem = nextElem {
+		nextElem = elem.Next()
+		localQRequest := elem.Value.(*localLockRequest)
+		if localQRequest.requestedState == shared {
+			track.waitReqQ.Remove(elem)
+			grantAndSignal(track, localQRequest)
+		}
+	}
+}
+
+// processLocalQWriterPreference is processLocalQ() for FairnessWriterPreference.
+//
+// This function assumes that the tracking mutex is held.
+func processLocalQWriterPreference(track *localLockTrack) {
+	if track.state == exclusive {
+		return
+	}
+
+	// Grant the first exclusive request (once the lock is free) ahead of any shared one
+	for elem := track.waitReqQ.Front(); nil != elem; elem = elem.Next() {
+		localQRequest := elem.Value.(*localLockRequest)
+		if localQRequest.requestedState == exclusive {
+			if track.state == stale {
+				track.waitReqQ.Remove(elem)
+				grantAndSignal(track, localQRequest)
+			}
+			return
+		}
+	}
+
+	// No exclusive request waits, so grant every shared one
+	grantQueuedShared(track)
+}
+
+// processLocalQReaderPreference is processLocalQ() for FairnessReaderPreference.
+//
+// This function assumes that the tracking mutex is held.
+func processLocalQReaderPreference(track *localLockTrack) {
+	if track.state == exclusive {
+		return
+	}
+
+	grantQueuedShared(track)
+
+	// Only exclusive requests remain queued... grant the first should the lock be free
+	if (track.state == stale) && (track.waitReqQ.Len() > 0) {
+		grantAndSignal(track, track.waitReqQ.Remove(track.waitReqQ.Front()).(*localLockRequest))
+	}
+}
@@ -2,9 +2,11 @@ package dlm
 
 import (
 	"container/list"
+	"context"
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/swiftstack/ProxyFS/blunder"
@@ -18,13 +20,16 @@ type localLockTrack struct {
 	waiters      uint64 // Count of threads which want to own the lock (either shared or exclusive)
 	state        lockState
 	listOfOwners []CallerID
-	waitReqQ     *list.List // List of requests waiting for lock
+	waitReqQ     *list.List        // List of requests waiting for lock
+	upgrader     *localLockRequest // Pending upgrade of a shared owner (see upgrade.go), else nil
 }
 
 type localLockRequest struct {
 	requestedState lockState
 	*sync.Cond
 	wakeUp       bool
+	failure      error  // if non-nil, the request was failed while waiting (see abandonOnDone() & deadlock.go)
+	seq          uint64 // order in which requests began waiting (see deadlock.go)
 	LockCallerID CallerID
 }
 
@@ -39,10 +44,10 @@ const (
 
 // NOTE: This is a test-only interface used for unit tests.
 //
-// This function assumes that globals.Lock() is held.
+// This function assumes that the DefaultDomain is locked.
 // TODO - can this be used in more cases without creating entry it if does not exist?
-func getTrack(lockId string) (track *localLockTrack, ok bool) {
-	track, ok = globals.localLockMap[lockId]
+func getTrack(domain *lockDomainStruct, lockId string) (track *localLockTrack, ok bool) {
+	track, ok = domain.localLockMap[lockId]
 	if !ok {
 		return track, ok
 	}
@@ -52,20 +57,20 @@ func getTrack(lockId string) (track *localLockTrack, ok bool) {
 // NOTE: This is a test-only interface used for unit tests.
 func waitCountWaiters(lockId string, count uint64) {
 	for {
-		globals.Lock()
-		track, ok := getTrack(lockId)
+		domain := lockDomain(DefaultDomain)
+		track, ok := getTrack(domain, lockId)
 
 		// If the tracking object has not been created yet, sleep and retry.
 		if !ok {
 			// Sleep 5 milliseconds and test again
-			globals.Unlock()
+			domain.Unlock()
 			time.Sleep(5 * time.Millisecond)
 			break
 		}
 
 		track.Mutex.Lock()
 
-		globals.Unlock()
+		domain.Unlock()
 
 		waiters := track.waiters
 		track.Mutex.Unlock()
@@ -82,20 +87,20 @@ func waitCountWaiters(lockId string, count uint64) {
 // NOTE: This is a test-only interface used for unit tests.
 func waitCountOwners(lockId string, count uint64) {
 	for {
-		globals.Lock()
-		track, ok := getTrack(lockId)
+		domain := lockDomain(DefaultDomain)
+		track, ok := getTrack(domain, lockId)
 
 		// If the tracking object has not been created yet, sleep and retry.
 		if !ok {
 			// Sleep 5 milliseconds and test again
-			globals.Unlock()
+			domain.Unlock()
 			time.Sleep(5 * time.Millisecond)
 			break
 		}
 
 		track.Mutex.Lock()
 
-		globals.Unlock()
+		domain.Unlock()
 
 		owners := track.owners
 		track.Mutex.Unlock()
@@ -109,13 +114,14 @@ func waitCountOwners(lockId string, count uint64) {
 	}
 }
 
+// removeFromListOfOwners returns listOfOwners less (one instance of) callerID.
+//
 // This function assumes the mutex is held on the tracker structure
-func removeFromListOfOwners(listOfOwners []CallerID, callerID CallerID) {
+func removeFromListOfOwners(listOfOwners []CallerID, callerID CallerID) []CallerID {
 	// Find Position
 	for i, id := range listOfOwners {
 		if id == callerID {
-			listOfOwners = append(listOfOwners[:i], listOfOwners[i+1:]...)
-			return
+			return append(listOfOwners[:i], listOfOwners[i+1:]...)
 		}
 	}
 
@@ -134,21 +140,26 @@ func callerInListOfOwners(listOfOwners []CallerID, callerID CallerID) (amOwner b
 	return false
 }
 
-func isLockHeld(lockID string, callerID CallerID, lockHeldType LockHeldType) (held bool) {
-	globals.Lock()
-	// NOTE: Not doing a defer globals.Unlock() here since grabbing another lock below.
+func isLockHeld(domainName string, lockID string, callerID CallerID, lockHeldType LockHeldType) (held bool) {
+	domain := lockExistingDomain(domainName)
+	if nil == domain {
+
+		// Domain (and therefore lock) does not exist
+		return false
+	}
+	// NOTE: Not doing a defer domain.Unlock() here since grabbing another lock below.
 
-	track, ok := globals.localLockMap[lockID]
+	track, ok := domain.localLockMap[lockID]
 	if !ok {
 
 		// Lock does not exist in map
-		globals.Unlock()
+		domain.Unlock()
 		return false
 	}
 
 	track.Mutex.Lock()
 
-	globals.Unlock()
+	domain.Unlock()
 
 	defer track.Mutex.Unlock()
 
@@ -171,10 +182,24 @@ func grantAndSignal(track *localLockTrack, localQRequest *localLockRequest) {
 	localQRequest.Cond.Broadcast()
 }
 
-// Process the waitReqQ and see if any locks can be granted.
+// Process the waitReqQ and see if any locks can be granted (in the order fairnessPolicy dictates).
 //
 // This function assumes that the tracking mutex is held.
-func processLocalQ(track *localLockTrack) {
+func processLocalQ(track *localLockTrack, fairnessPolicy FairnessPolicy) {
+	// A pending upgrade takes precedence over (and holds back) every queued request
+	if nil != track.upgrader {
+		grantUpgrade(track)
+		return
+	}
+
+	switch fairnessPolicy {
+	case FairnessWriterPreference:
+		processLocalQWriterPreference(track)
+		return
+	case FairnessReaderPreference:
+		processLocalQReaderPreference(track)
+		return
+	}
 
 	// If nothing on queue then return
 	if track.waitReqQ.Len() == 0 {
@@ -214,38 +239,63 @@ func processLocalQ(track *localLockTrack) {
 	}
 }
 
-func (l *RWLockStruct) commonLock(requestedState lockState, try bool) (err error) {
+// lockWaitError returns the error with which a wait for a lock ends once ctx is done.
+func lockWaitError(ctx context.Context) (err error) {
+	if context.DeadlineExceeded == ctx.Err() {
+		err = blunder.NewError(blunder.TimedOut, "Timed out waiting for lock")
+	} else {
+		err = blunder.NewError(blunder.CanceledError, "Canceled waiting for lock")
+	}
+	return
+}
 
-	globals.Lock()
-	track, ok := globals.localLockMap[l.LockID]
-	if !ok {
-		// TODO - handle blocking waiting for lock from DLM
+// commonLock obtains the lock locally and then from the Backend (see backend.go). If ctx is non-nil, either
+// wait is given up should ctx be done before the lock is granted.
+func (l *RWLockStruct) commonLock(ctx context.Context, requestedState lockState, try bool) (err error) {
+	err = l.localLock(ctx, requestedState, try)
+	if nil != err {
+		return
+	}
+
+	err = fetchBackend().Acquire(ctx, l.Domain, l.LockID, callerIDString(l.LockCallerID), (exclusive == requestedState), try)
+	if nil != err {
+		_ = l.localUnlock()
+	}
+	return
+}
+
+// unlock releases the lock to the Backend (see backend.go) and then locally.
+func (l *RWLockStruct) unlock() (err error) {
+	err = fetchBackend().Release(l.Domain, l.LockID, callerIDString(l.LockCallerID))
+
+	localErr := l.localUnlock()
+	if nil == err {
+		err = localErr
+	}
+	return
+}
+
+// localLock obtains the lock from the node-local lock manager. If ctx is non-nil, the request is withdrawn
+// from waitReqQ should ctx be done before the lock is granted.
+func (l *RWLockStruct) localLock(ctx context.Context, requestedState lockState, try bool) (err error) {
 
+	domain := lockDomain(l.Domain)
+	track, ok := domain.localLockMap[l.LockID]
+	if !ok {
 		// Lock does not exist in map, create one
 		track = &localLockTrack{lockId: l.LockID, state: stale}
 		track.waitReqQ = list.New()
-		globals.localLockMap[l.LockID] = track
+		domain.localLockMap[l.LockID] = track
 
 	}
 
 	track.Mutex.Lock()
 	defer track.Mutex.Unlock()
 
-	globals.Unlock()
+	fairnessPolicy := domain.fairnessPolicy
+
+	domain.Unlock()
 
-	// If we are doing a TryWriteLock or TryReadLock, see if we could
-	// grab the lock before putting on queue.
-	if try {
-		if (requestedState == exclusive) && (track.state != stale) {
-			err = errors.New("Lock is busy - try again!")
-			return blunder.AddError(err, blunder.TryAgainError)
-		} else {
-			if track.state == exclusive {
-				err = errors.New("Lock is busy - try again!")
-				return blunder.AddError(err, blunder.TryAgainError)
-			}
-		}
-	}
 	localRequest := localLockRequest{requestedState: requestedState, LockCallerID: l.LockCallerID, wakeUp: false}
 	localRequest.Cond = sync.NewCond(&track.Mutex)
 	track.waitReqQ.PushBack(&localRequest)
@@ -253,13 +303,46 @@ func (l *RWLockStruct) commonLock(requestedState lockState, try bool) (err error
 	track.waiters++
 
 	// See if any locks can be granted
-	processLocalQ(track)
+	processLocalQ(track, fairnessPolicy)
 
 	// wakeUp will already be true if processLocalQ() signaled this thread to wakeup.
-	for localRequest.wakeUp == false {
+	if localRequest.wakeUp == false {
+		// If we are doing a TryWriteLock or TryReadLock, fail rather than wait (as the
+		// fairnessPolicy would have us do) for the lock.
+		if try {
+			withdrawRequest(track, &localRequest)
+			track.waiters--
+			atomic.AddUint64(&domain.tryFailures, 1)
+			err = errors.New("Lock is busy - try again!")
+			return blunder.AddError(err, blunder.TryAgainError)
+		}
+
+		atomic.AddUint64(&domain.contendedAcquisitions, 1)
+
+		localRequest.seq = atomic.AddUint64(&globals.lastWaitSeq, 1)
+
+		if nil != ctx {
+			waitDone := make(chan struct{})
+			defer close(waitDone)
+			go abandonOnDone(ctx, waitDone, domain, track, &localRequest)
+		}
+	}
+	for (localRequest.wakeUp == false) && (nil == localRequest.failure) {
 		localRequest.Cond.Wait()
 	}
 
+	// Note that, should the lock have been granted after the request was failed (but before this
+	// thread awoke), the lock is simply returned as granted.
+	if localRequest.wakeUp == false {
+		withdrawRequest(track, &localRequest)
+		track.waiters--
+
+		// Withdrawing an exclusive request may allow shared requests queued behind it to be granted
+		processLocalQ(track, fairnessPolicy)
+
+		return localRequest.failure
+	}
+
 	// At this point, we got the lock either by the call to processLocalQ() above
 	// or as a result of processLocalQ() being called from the unlock() path.
 
@@ -267,15 +350,48 @@ func (l *RWLockStruct) commonLock(requestedState lockState, try bool) (err error
 	// assume there are no waiters between the time the Cond is signaled and we wakeup this thread.
 	track.waiters--
 
+	atomic.AddUint64(&domain.acquisitions, 1)
+
 	return nil
 }
 
-// unlock() releases the lock and signals any waiters that the lock is free.
-func (l *RWLockStruct) unlock() (err error) {
+// abandonOnDone fails localRequest, waking its waiter, should ctx be done before either the lock is
+// granted or waitDone is closed.
+func abandonOnDone(ctx context.Context, waitDone chan struct{}, domain *lockDomainStruct, track *localLockTrack, localRequest *localLockRequest) {
+	select {
+	case <-ctx.Done():
+		track.Mutex.Lock()
+		if (localRequest.wakeUp == false) && (nil == localRequest.failure) {
+			localRequest.failure = lockWaitError(ctx)
+			localRequest.Cond.Broadcast()
+			atomic.AddUint64(&domain.abandonedAcquisitions, 1)
+		}
+		track.Mutex.Unlock()
+	case <-waitDone:
+	}
+}
+
+// withdrawRequest removes localRequest from waitReqQ.
+//
+// This function assumes that the tracking mutex is held.
+func withdrawRequest(track *localLockTrack, localRequest *localLockRequest) {
+	for elem := track.waitReqQ.Front(); nil != elem; elem = elem.Next() {
+		if elem.Value == localRequest {
+			track.waitReqQ.Remove(elem)
+			return
+		}
+	}
+}
+
+// localUnlock() releases the lock and signals any waiters that the lock is free.
+func (l *RWLockStruct) localUnlock() (err error) {
 
 	// TODO - assert not stale and if shared that count != 0
-	globals.Lock()
-	track, ok := globals.localLockMap[l.LockID]
+	domain := lockExistingDomain(l.Domain)
+	if nil == domain {
+		panic(fmt.Sprintf("Trying to Unlock() inode: %v and lock domain %q not found!", l.LockID, l.Domain))
+	}
+	track, ok := domain.localLockMap[l.LockID]
 	if !ok {
 		panic(fmt.Sprintf("Trying to Unlock() inode: %v and lock not found in localLockMap()!", l.LockID))
 	}
@@ -288,15 +404,16 @@ func (l *RWLockStruct) unlock() (err error) {
 	// lock from map if we are the last holder of the lock.
 	// TODO - does this handle revoke case and any others?
 	if (track.owners == 1) && (track.waiters == 0) {
-		delete(globals.localLockMap, l.LockID)
+		delete(domain.localLockMap, l.LockID)
 	}
 
-	globals.Unlock()
+	fairnessPolicy := domain.fairnessPolicy
+
+	domain.Unlock()
 
-	// TODO - handle release of lock back to DLM and delete from localLockMap
 	// Set stale and signal any waiters
 	track.owners--
-	removeFromListOfOwners(track.listOfOwners, l.LockCallerID)
+	track.listOfOwners = removeFromListOfOwners(track.listOfOwners, l.LockCallerID)
 	if track.owners == 0 {
 		track.state = stale
 	} else {
@@ -305,8 +422,14 @@ func (l *RWLockStruct) unlock() (err error) {
 		}
 	}
 
+	// A pending upgrade of a lock its caller no longer holds can never be granted
+	if (nil != track.upgrader) && !callerInListOfOwners(track.listOfOwners, track.upgrader.LockCallerID) && (nil == track.upgrader.failure) {
+		track.upgrader.failure = blunder.NewError(blunder.InvalidArgError, "Lock %s:%s released while its Upgrade() was pending", l.Domain, l.LockID)
+		track.upgrader.Cond.Broadcast()
+	}
+
 	// See if any locks can be granted
-	processLocalQ(track)
+	processLocalQ(track, fairnessPolicy)
 
 	track.Mutex.Unlock()
 
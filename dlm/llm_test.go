@@ -1,6 +1,7 @@
 package dlm
 
 import (
+	"context"
 	"flag"
 	"io/ioutil"
 	"os"
@@ -627,3 +628,451 @@ func testTryFailsIfHeldExclusive(t *testing.T) {
 	// Stop worker threads
 	stopThreads(t)
 }
+
+// Test that lock domains are independent and may be dropped once unused
+func TestLockDomains(t *testing.T) {
+	assert := assert.New(t)
+
+	lockA := &RWLockStruct{Domain: "domainA", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	lockB := &RWLockStruct{Domain: "domainB", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+
+	// The same LockID in different domains names different locks
+	err := lockA.WriteLock()
+	assert.Nil(err, "WriteLock() of domainA lock should have worked")
+	err = lockB.TryWriteLock()
+	assert.Nil(err, "TryWriteLock() of domainB lock should have worked")
+
+	assert.True(IsLockHeldInDomain("domainA", s1, lockA.LockCallerID, WRITELOCK))
+	assert.False(IsLockHeldInDomain("domainA", s1, lockB.LockCallerID, WRITELOCK))
+	assert.True(IsLockHeldInDomain("domainB", s1, lockB.LockCallerID, WRITELOCK))
+	assert.False(IsLockHeld(s1, lockA.LockCallerID, ANYLOCK))
+
+	// A held lock prevents its domain from being dropped
+	err = DropDomain("domainA")
+	assert.True(blunder.Is(err, blunder.DevBusyError), "DropDomain() of busy domain should fail with DevBusyError")
+
+	// Contention is accounted to the domain in which it occurs
+	otherLockA := &RWLockStruct{Domain: "domainA", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	err = otherLockA.TryReadLock()
+	assert.True(blunder.Is(err, blunder.TryAgainError), "TryReadLock() of held lock should fail with TryAgainError")
+
+	readLockDone := make(chan error)
+	go func() {
+		readLockDone <- otherLockA.ReadLock()
+	}()
+	for {
+		domainStats, ok := FetchDomainStats("domainA")
+		assert.True(ok)
+		globals.Lock()
+		domain := globals.domainMap["domainA"]
+		globals.Unlock()
+		domain.Lock()
+		waiters := domain.localLockMap[s1].waiters
+		domain.Unlock()
+		if (1 == waiters) && (1 == domainStats.TrackedLocks) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	err = lockA.Unlock()
+	assert.Nil(err)
+	err = <-readLockDone
+	assert.Nil(err)
+	err = otherLockA.Unlock()
+	assert.Nil(err)
+
+	domainStats, ok := FetchDomainStats("domainA")
+	assert.True(ok)
+	assert.Equal(DomainStatsStruct{TrackedLocks: 0, Acquisitions: 2, ContendedAcquisitions: 1, TryFailures: 1}, domainStats)
+	domainStats, ok = FetchDomainStats("domainB")
+	assert.True(ok)
+	assert.Equal(DomainStatsStruct{TrackedLocks: 1, Acquisitions: 1, ContendedAcquisitions: 0, TryFailures: 0}, domainStats)
+
+	// An unused domain may be dropped (taking its stats with it)
+	err = DropDomain("domainA")
+	assert.Nil(err)
+	_, ok = FetchDomainStats("domainA")
+	assert.False(ok)
+	assert.NotContains(FetchDomainNames(), "domainA")
+	assert.Contains(FetchDomainNames(), "domainB")
+
+	// ...and is recreated on next use
+	err = lockA.ReadLock()
+	assert.Nil(err)
+	assert.True(IsLockHeldInDomain("domainA", s1, lockA.LockCallerID, READLOCK))
+	err = lockA.Unlock()
+	assert.Nil(err)
+
+	err = lockB.Unlock()
+	assert.Nil(err)
+	err = DropDomain("domainA")
+	assert.Nil(err)
+	err = DropDomain("domainB")
+	assert.Nil(err)
+}
+
+func TestLockTimeouts(t *testing.T) {
+	assert := assert.New(t)
+
+	holder := &RWLockStruct{Domain: "domainT", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	writer := &RWLockStruct{Domain: "domainT", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	reader := &RWLockStruct{Domain: "domainT", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+
+	waitForWaiters := func(expectedWaiters uint64) {
+		for {
+			globals.Lock()
+			domain := globals.domainMap["domainT"]
+			globals.Unlock()
+			domain.Lock()
+			track := domain.localLockMap[s1]
+			track.Mutex.Lock()
+			domain.Unlock()
+			waiters := track.waiters
+			track.Mutex.Unlock()
+			if expectedWaiters == waiters {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	err := holder.ReadLock()
+	assert.Nil(err)
+
+	// A wait for a held lock times out, leaving it held only by its holder
+	err = writer.WriteLockWithTimeout(50 * time.Millisecond)
+	assert.True(blunder.Is(err, blunder.TimedOut), "WriteLockWithTimeout() of held lock should fail with TimedOut")
+	assert.False(writer.IsWriteHeld())
+	waitForWaiters(0)
+
+	// A lock free (or compatible) when requested is granted regardless of the timeout
+	err = reader.ReadLockWithTimeout(time.Nanosecond)
+	assert.Nil(err)
+	err = reader.Unlock()
+	assert.Nil(err)
+
+	// Canceling an exclusive request queued ahead of a shared one lets the shared one be granted
+	ctx, cancel := context.WithCancel(context.Background())
+	writeLockDone := make(chan error)
+	go func() {
+		writeLockDone <- writer.WriteLockWithContext(ctx)
+	}()
+	waitForWaiters(1)
+	readLockDone := make(chan error)
+	go func() {
+		readLockDone <- reader.ReadLock()
+	}()
+	waitForWaiters(2)
+	cancel()
+	err = <-writeLockDone
+	assert.True(blunder.Is(err, blunder.CanceledError), "canceled WriteLockWithContext() should fail with CanceledError")
+	err = <-readLockDone
+	assert.Nil(err)
+	assert.True(reader.IsReadHeld())
+	assert.False(writer.IsWriteHeld())
+
+	// A context already done still has a free lock granted
+	err = reader.Unlock()
+	assert.Nil(err)
+	err = holder.Unlock()
+	assert.Nil(err)
+	err = writer.WriteLockWithContext(ctx)
+	assert.Nil(err)
+	err = writer.Unlock()
+	assert.Nil(err)
+
+	domainStats, ok := FetchDomainStats("domainT")
+	assert.True(ok)
+	assert.Equal(DomainStatsStruct{TrackedLocks: 0, Acquisitions: 4, ContendedAcquisitions: 3, TryFailures: 0, AbandonedAcquisitions: 2}, domainStats)
+
+	err = DropDomain("domainT")
+	assert.Nil(err)
+}
+
+func TestLockDeadlockDetection(t *testing.T) {
+	assert := assert.New(t)
+
+	lockAOn1 := &RWLockStruct{Domain: "domainD", LockID: "1", Notify: nil, LockCallerID: GenerateCallerID()}
+	lockAOn2 := &RWLockStruct{Domain: "domainD", LockID: "2", Notify: nil, LockCallerID: lockAOn1.LockCallerID}
+	lockBOn2 := &RWLockStruct{Domain: "domainD", LockID: "2", Notify: nil, LockCallerID: GenerateCallerID()}
+	lockBOn1 := &RWLockStruct{Domain: "domainD", LockID: "1", Notify: nil, LockCallerID: lockBOn2.LockCallerID}
+
+	waitForWaiters := func(lockID string, expectedWaiters uint64) {
+		for {
+			globals.Lock()
+			domain := globals.domainMap["domainD"]
+			globals.Unlock()
+			domain.Lock()
+			track := domain.localLockMap[lockID]
+			track.Mutex.Lock()
+			domain.Unlock()
+			waiters := track.waiters
+			track.Mutex.Unlock()
+			if expectedWaiters == waiters {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	err := lockAOn1.WriteLock()
+	assert.Nil(err)
+	err = lockBOn2.ReadLock()
+	assert.Nil(err)
+
+	// Waits that are not (yet) deadlocked are left alone
+	lockADone := make(chan error)
+	go func() {
+		lockADone <- lockAOn2.WriteLock()
+	}()
+	waitForWaiters("2", 1)
+	assert.Equal(0, detectDeadlocks())
+
+	// Once A & B each await the lock the other holds, B (the youngest request) fails with DeadlockError
+	lockBDone := make(chan error)
+	go func() {
+		lockBDone <- lockBOn1.ReadLock()
+	}()
+	waitForWaiters("1", 1)
+	_ = detectDeadlocks() // [DLM]DeadlockDetectionInterval's may already have done so
+	err = <-lockBDone
+	assert.True(blunder.Is(err, blunder.DeadlockError), "ReadLock() completing a deadlock should fail with DeadlockError")
+	assert.False(lockBOn1.IsReadHeld())
+	waitForWaiters("1", 0)
+	assert.Equal(0, detectDeadlocks())
+
+	// Once B releases its lock, A proceeds
+	err = lockBOn2.Unlock()
+	assert.Nil(err)
+	err = <-lockADone
+	assert.Nil(err)
+	err = lockAOn2.Unlock()
+	assert.Nil(err)
+	err = lockAOn1.Unlock()
+	assert.Nil(err)
+
+	domainStats, ok := FetchDomainStats("domainD")
+	assert.True(ok)
+	assert.Equal(DomainStatsStruct{TrackedLocks: 0, Acquisitions: 3, ContendedAcquisitions: 2, Deadlocks: 1}, domainStats)
+
+	err = DropDomain("domainD")
+	assert.Nil(err)
+}
+
+func TestLockFairnessPolicies(t *testing.T) {
+	assert := assert.New(t)
+
+	holder := &RWLockStruct{Domain: "domainF", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	writer := &RWLockStruct{Domain: "domainF", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	reader := &RWLockStruct{Domain: "domainF", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	trier := &RWLockStruct{Domain: "domainF", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+
+	waitForWaiters := func(expectedWaiters uint64) {
+		for {
+			globals.Lock()
+			domain := globals.domainMap["domainF"]
+			globals.Unlock()
+			domain.Lock()
+			track := domain.localLockMap[s1]
+			track.Mutex.Lock()
+			domain.Unlock()
+			waiters := track.waiters
+			track.Mutex.Unlock()
+			if expectedWaiters == waiters {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	writeLockAsync := func() (done chan error) {
+		done = make(chan error, 1)
+		go func() {
+			done <- writer.WriteLock()
+		}()
+		return
+	}
+	readLockAsync := func() (done chan error) {
+		done = make(chan error, 1)
+		go func() {
+			done <- reader.ReadLock()
+		}()
+		return
+	}
+
+	// FIFO (the default): a reader arriving behind a waiting writer waits for it
+	assert.Equal(FairnessFIFO, FetchDomainFairnessPolicy("domainF"))
+	err := holder.ReadLock()
+	assert.Nil(err)
+	writerDone := writeLockAsync()
+	waitForWaiters(1)
+	err = trier.TryReadLock()
+	assert.True(blunder.Is(err, blunder.TryAgainError), "TryReadLock() behind waiting writer should fail with TryAgainError")
+	readerDone := readLockAsync()
+	waitForWaiters(2)
+	assert.False(reader.IsReadHeld())
+	err = holder.Unlock()
+	assert.Nil(err)
+	assert.Nil(<-writerDone)
+	assert.False(reader.IsReadHeld())
+	err = writer.Unlock()
+	assert.Nil(err)
+	assert.Nil(<-readerDone)
+	err = reader.Unlock()
+	assert.Nil(err)
+
+	// Writer preference: a writer waiting behind a reader is granted first
+	SetDomainFairnessPolicy("domainF", FairnessWriterPreference)
+	assert.Equal(FairnessWriterPreference, FetchDomainFairnessPolicy("domainF"))
+	err = holder.WriteLock()
+	assert.Nil(err)
+	readerDone = readLockAsync()
+	waitForWaiters(1)
+	writerDone = writeLockAsync()
+	waitForWaiters(2)
+	err = holder.Unlock()
+	assert.Nil(err)
+	assert.Nil(<-writerDone)
+	assert.False(reader.IsReadHeld())
+	err = writer.Unlock()
+	assert.Nil(err)
+	assert.Nil(<-readerDone)
+	err = reader.Unlock()
+	assert.Nil(err)
+
+	// Reader preference: readers are granted ahead of a waiting writer
+	SetDomainFairnessPolicy("domainF", FairnessReaderPreference)
+	err = holder.ReadLock()
+	assert.Nil(err)
+	writerDone = writeLockAsync()
+	waitForWaiters(1)
+	err = trier.TryReadLock()
+	assert.Nil(err)
+	err = reader.ReadLock()
+	assert.Nil(err)
+	for _, lock := range []*RWLockStruct{trier, reader, holder} {
+		err = lock.Unlock()
+		assert.Nil(err)
+	}
+	assert.Nil(<-writerDone)
+	err = writer.Unlock()
+	assert.Nil(err)
+
+	// Changing the policy grants any request it no longer has wait
+	SetDomainFairnessPolicy("domainF", FairnessFIFO)
+	err = holder.ReadLock()
+	assert.Nil(err)
+	writerDone = writeLockAsync()
+	waitForWaiters(1)
+	readerDone = readLockAsync()
+	waitForWaiters(2)
+	SetDomainFairnessPolicy("domainF", FairnessReaderPreference)
+	assert.Nil(<-readerDone)
+	for _, lock := range []*RWLockStruct{reader, holder} {
+		err = lock.Unlock()
+		assert.Nil(err)
+	}
+	assert.Nil(<-writerDone)
+	err = writer.Unlock()
+	assert.Nil(err)
+
+	SetDomainFairnessPolicy("domainF", FairnessFIFO)
+	assert.Equal(FairnessFIFO, FetchDomainFairnessPolicy("domainF"))
+	err = DropDomain("domainF")
+	assert.Nil(err)
+}
+
+func TestLockUpgrade(t *testing.T) {
+	assert := assert.New(t)
+
+	lockA := &RWLockStruct{Domain: "domainU", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	lockB := &RWLockStruct{Domain: "domainU", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+	lockC := &RWLockStruct{Domain: "domainU", LockID: s1, Notify: nil, LockCallerID: GenerateCallerID()}
+
+	fetchTrack := func() (track *localLockTrack) {
+		globals.Lock()
+		domain := globals.domainMap["domainU"]
+		globals.Unlock()
+		domain.Lock()
+		track = domain.localLockMap[s1]
+		domain.Unlock()
+		return
+	}
+
+	// Conversions of a lock not held as required fail
+	err := lockA.Upgrade()
+	assert.True(blunder.Is(err, blunder.InvalidArgError), "Upgrade() of lock not held should fail with InvalidArgError")
+	err = lockA.ReadLock()
+	assert.Nil(err)
+	err = lockA.Downgrade()
+	assert.True(blunder.Is(err, blunder.InvalidArgError), "Downgrade() of lock held shared should fail with InvalidArgError")
+
+	// The sole holder upgrades (and downgrades) immediately
+	err = lockA.Upgrade()
+	assert.Nil(err)
+	assert.True(lockA.IsWriteHeld())
+	err = lockA.Downgrade()
+	assert.Nil(err)
+	assert.True(lockA.IsReadHeld())
+
+	// An upgrade waits for the other holders, holding back new requests
+	err = lockB.ReadLock()
+	assert.Nil(err)
+	upgradeDone := make(chan error)
+	go func() {
+		upgradeDone <- lockA.Upgrade()
+	}()
+	for {
+		track := fetchTrack()
+		track.Mutex.Lock()
+		upgrading := (nil != track.upgrader)
+		track.Mutex.Unlock()
+		if upgrading {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	err = lockC.TryReadLock()
+	assert.True(blunder.Is(err, blunder.TryAgainError), "TryReadLock() of lock being upgraded should fail with TryAgainError")
+	readLockDone := make(chan error)
+	go func() {
+		readLockDone <- lockC.ReadLock()
+	}()
+	for {
+		track := fetchTrack()
+		track.Mutex.Lock()
+		waiters := track.waiters
+		track.Mutex.Unlock()
+		if 1 == waiters {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A second upgrade would deadlock, so fails (leaving the lock held shared)
+	err = lockB.Upgrade()
+	assert.True(blunder.Is(err, blunder.DeadlockError), "concurrent Upgrade() should fail with DeadlockError")
+	assert.True(lockB.IsReadHeld())
+	err = lockB.Unlock()
+	assert.Nil(err)
+	assert.Nil(<-upgradeDone)
+	assert.True(lockA.IsWriteHeld())
+	assert.False(lockC.IsReadHeld())
+
+	// Downgrading lets the waiting reader in
+	err = lockA.Downgrade()
+	assert.Nil(err)
+	assert.Nil(<-readLockDone)
+	assert.True(lockA.IsReadHeld())
+	assert.True(lockC.IsReadHeld())
+	err = lockC.Unlock()
+	assert.Nil(err)
+	err = lockA.Unlock()
+	assert.Nil(err)
+
+	domainStats, ok := FetchDomainStats("domainU")
+	assert.True(ok)
+	assert.Equal(DomainStatsStruct{TrackedLocks: 0, Acquisitions: 3, ContendedAcquisitions: 1, TryFailures: 1, Deadlocks: 1}, domainStats)
+
+	err = DropDomain("domainU")
+	assert.Nil(err)
+}
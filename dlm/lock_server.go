@@ -0,0 +1,744 @@
+package dlm
+
+// Lock server
+//
+// The lockserver Backend (see backend.go) has every node acquire each lock its threads are granted from
+// a single lock server, run by one of the nodes, via JSON-RPC over TCP. The lock server keeps the locks of
+// its clients in the local lock manager of the node running it, in a domain distinct from any of that
+// node's own (lockServerDomainPrefix followed by the client's domain), on behalf of a caller unique to
+// the client connection and the client's callerID.
+//
+// Connections are authenticated by [DLM]LockServerSecret, shared by every node: upon accepting a connection,
+// the lock server sends a random challenge, serving no RPC until the client replies with the HMAC-SHA256 of
+// the challenge keyed by the secret.
+//
+// Each client holds a single connection to the lock server, over which it calls LockServer.Ping every
+// quarter of [DLM]LockServerFenceTimeout. Should the connection fail (or a Ping go unanswered for a quarter
+// of the fence timeout) while any lock acquired over it is held, the client is fenced: it fail-stops (see
+// failStop()), as the lock server may since have granted those locks to other nodes. The lock server, for
+// its part, closes any connection over which it has received no request for the fence timeout. Once a
+// connection closes (e.g. should the client node fail), every lock acquired over it is released so that
+// the surviving nodes may proceed (as is any it awaited, once granted) - but not before the fence timeout
+// has passed since the last request received over it, by which time the client has fail-stopped. A client
+// whose connection fails while it holds no lock acquired over it merely fails its subsequent lock requests
+// until it is able to reconnect.
+//
+// Note that the lock server is not itself replicated: should the node running it fail, no lock may be
+// acquired (by any node) until it is restarted (and any node then holding a lock fail-stops).
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+const (
+	lockServerDomainPrefix  = "dlm.lockserver:"
+	lockServerChallengeSize = 32                // bytes of randomness in each challenge
+	lockServerAuthenticated = "authenticated\n" // sent once the client's response to the challenge is verified
+)
+
+// LockServerAcquireRequest is the request of the LockServer.Acquire RPC.
+type LockServerAcquireRequest struct {
+	DomainName string
+	LockID     string
+	CallerID   string
+	Exclusive  bool
+	Try        bool
+}
+
+// LockServerAcquireReply is the reply of the LockServer.Acquire RPC.
+type LockServerAcquireReply struct {
+	Busy       bool // if true, the (try) request failed as the lock was unavailable
+	Deadlocked bool // if true, the request was failed to break a deadlock (see deadlock.go)
+}
+
+// LockServerReleaseRequest is the request of the LockServer.Release RPC.
+type LockServerReleaseRequest struct {
+	DomainName string
+	LockID     string
+	CallerID   string
+}
+
+// LockServerReleaseReply is the reply of the LockServer.Release RPC.
+type LockServerReleaseReply struct{}
+
+// LockServerConvertRequest is the request of the LockServer.Upgrade & LockServer.Downgrade RPCs.
+type LockServerConvertRequest struct {
+	DomainName string
+	LockID     string
+	CallerID   string
+}
+
+// LockServerConvertReply is the reply of the LockServer.Upgrade & LockServer.Downgrade RPCs.
+type LockServerConvertReply struct {
+	Deadlocked bool // if true, the Upgrade failed to avoid (or break) a deadlock (see upgrade.go)
+}
+
+// LockServerPingRequest is the request of the LockServer.Ping RPC.
+type LockServerPingRequest struct{}
+
+// LockServerPingReply is the reply of the LockServer.Ping RPC.
+type LockServerPingReply struct{}
+
+type lockServerStruct struct {
+	sync.Mutex
+	secret       []byte
+	fenceTimeout time.Duration
+	listener     net.Listener
+	connections  map[*lockServerConnectionStruct]net.Conn
+	stopChan     chan struct{} // closed by stop()
+	wg           sync.WaitGroup
+}
+
+type lockServerHeldKey struct {
+	domainName string
+	lockID     string
+	callerID   string
+}
+
+// lockServerCallerStruct is the caller (see GenerateCallerID()) on whose behalf the lock server holds
+// (or awaits) locks for a client's callerID.
+type lockServerCallerStruct struct {
+	callerID CallerID
+	refs     uint64 // locks held or awaited
+}
+
+// lockServerConnectionStruct is the LockServer RPC service of a single client connection.
+type lockServerConnectionStruct struct {
+	sync.Mutex
+	closed  bool
+	callers map[string]*lockServerCallerStruct // key == client's callerID
+	held    map[lockServerHeldKey]uint64       // value == number of times held
+}
+
+func startLockServer(lockServerAddr string, secret string, fenceTimeout time.Duration) (lockServer *lockServerStruct, err error) {
+	listener, err := net.Listen("tcp", lockServerAddr)
+	if nil != err {
+		err = fmt.Errorf("dlm lock server net.Listen(\"tcp\", \"%s\") failed: %v", lockServerAddr, err)
+		return
+	}
+
+	lockServer = &lockServerStruct{
+		secret:       []byte(secret),
+		fenceTimeout: fenceTimeout,
+		listener:     listener,
+		connections:  make(map[*lockServerConnectionStruct]net.Conn),
+		stopChan:     make(chan struct{}),
+	}
+
+	lockServer.wg.Add(1)
+	go lockServer.serve()
+
+	return
+}
+
+func (lockServer *lockServerStruct) serve() {
+	defer lockServer.wg.Done()
+
+	for {
+		conn, err := lockServer.listener.Accept()
+		if nil != err {
+			return // listener closed by stop()
+		}
+
+		lockServer.wg.Add(1)
+		go lockServer.serveConn(conn)
+	}
+}
+
+func (lockServer *lockServerStruct) serveConn(conn net.Conn) {
+	defer lockServer.wg.Done()
+
+	err := lockServer.challenge(conn)
+	if nil != err {
+		logger.WarnfWithError(err, "dlm lock server rejected connection from %s", conn.RemoteAddr())
+		_ = conn.Close()
+		return
+	}
+
+	connection := &lockServerConnectionStruct{
+		callers: make(map[string]*lockServerCallerStruct),
+		held:    make(map[lockServerHeldKey]uint64),
+	}
+
+	server := rpc.NewServer()
+	err = server.RegisterName("LockServer", connection)
+	if nil != err {
+		logger.ErrorfWithError(err, "dlm lock server failed to register RPC handler")
+		_ = conn.Close()
+		return
+	}
+
+	lockServer.Lock()
+	lockServer.connections[connection] = conn
+	lockServer.Unlock()
+
+	server.ServeCodec(&lockServerCodec{
+		ServerCodec:     jsonrpc.NewServerCodec(conn),
+		lockServer:      lockServer,
+		conn:            conn,
+		connection:      connection,
+		lastRequestTime: time.Now(),
+	})
+
+	lockServer.Lock()
+	delete(lockServer.connections, connection)
+	lockServer.Unlock()
+}
+
+func (lockServer *lockServerStruct) stop() {
+	_ = lockServer.listener.Close()
+	close(lockServer.stopChan)
+
+	lockServer.Lock()
+	for _, conn := range lockServer.connections {
+		_ = conn.Close()
+	}
+	lockServer.Unlock()
+
+	lockServer.wg.Wait()
+}
+
+// challenge verifies that the client of conn knows the secret, failing should it not reply to a random
+// challenge with its HMAC keyed by the secret (see lockServerResponse()) within the fence timeout.
+func (lockServer *lockServerStruct) challenge(conn net.Conn) (err error) {
+	err = conn.SetDeadline(time.Now().Add(lockServer.fenceTimeout))
+	if nil != err {
+		return
+	}
+
+	challengeBuf := make([]byte, lockServerChallengeSize)
+	_, err = rand.Read(challengeBuf)
+	if nil != err {
+		return
+	}
+	challenge := hex.EncodeToString(challengeBuf)
+
+	_, err = io.WriteString(conn, challenge+"\n")
+	if nil != err {
+		return
+	}
+	response, err := bufio.NewReader(conn).ReadString('\n') // client sends nothing more until authenticated
+	if nil != err {
+		return
+	}
+	if !hmac.Equal([]byte(strings.TrimSuffix(response, "\n")), []byte(lockServerResponse(lockServer.secret, challenge))) {
+		err = fmt.Errorf("wrong response to challenge (is [DLM]LockServerSecret the same on every peer?)")
+		return
+	}
+	_, err = io.WriteString(conn, lockServerAuthenticated)
+	if nil != err {
+		return
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	return
+}
+
+// lockServerResponse returns the response to challenge of a client knowing secret.
+func lockServerResponse(secret []byte, challenge string) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(challenge))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// awaitFence waits until the fence timeout has passed since lastRequestTime (by which time the client of a
+// connection over which no request has since been received has fail-stopped should it hold any lock
+// acquired over it) or the lock server is stopped.
+func (lockServer *lockServerStruct) awaitFence(lastRequestTime time.Time) {
+	timer := time.NewTimer(lastRequestTime.Add(lockServer.fenceTimeout).Sub(time.Now()))
+	select {
+	case <-timer.C:
+	case <-lockServer.stopChan:
+		timer.Stop()
+	}
+}
+
+// lockServerCodec closes its connection should no request be received over it for the fence timeout and
+// releases the locks of its connection as soon as the client is fenced (see awaitFence()), rather than once
+// any calls still awaiting locks (perhaps held by the connection itself) complete.
+type lockServerCodec struct {
+	rpc.ServerCodec
+	lockServer      *lockServerStruct
+	conn            net.Conn
+	connection      *lockServerConnectionStruct
+	lastRequestTime time.Time
+}
+
+func (codec *lockServerCodec) ReadRequestHeader(request *rpc.Request) (err error) {
+	err = codec.conn.SetReadDeadline(codec.lastRequestTime.Add(codec.lockServer.fenceTimeout))
+	if nil == err {
+		err = codec.ServerCodec.ReadRequestHeader(request)
+	}
+	if nil != err {
+		codec.lockServer.awaitFence(codec.lastRequestTime)
+		codec.connection.releaseAll()
+		return
+	}
+	codec.lastRequestTime = time.Now()
+	return
+}
+
+// lock returns the lock identified by the client as domainName & lockID on behalf of its callerID, taking a
+// reference on the caller. Caller must hold connection's Mutex.
+func (connection *lockServerConnectionStruct) lock(domainName string, lockID string, callerID string) (lock *RWLockStruct) {
+	caller, ok := connection.callers[callerID]
+	if !ok {
+		caller = &lockServerCallerStruct{callerID: GenerateCallerID()}
+		connection.callers[callerID] = caller
+	}
+	caller.refs++
+
+	lock = &RWLockStruct{Domain: lockServerDomainPrefix + domainName, LockID: lockID, LockCallerID: caller.callerID}
+	return
+}
+
+// unref drops a reference on the client's callerID taken by lock(). Caller must hold connection's Mutex.
+func (connection *lockServerConnectionStruct) unref(callerID string) {
+	caller := connection.callers[callerID]
+	caller.refs--
+	if 0 == caller.refs {
+		delete(connection.callers, callerID)
+	}
+}
+
+// Acquire is the LockServer.Acquire RPC.
+func (connection *lockServerConnectionStruct) Acquire(request *LockServerAcquireRequest, reply *LockServerAcquireReply) (err error) {
+	connection.Lock()
+	lock := connection.lock(request.DomainName, request.LockID, request.CallerID)
+	connection.Unlock()
+
+	lockState := shared
+	if request.Exclusive {
+		lockState = exclusive
+	}
+
+	err = lock.localLock(nil, lockState, request.Try)
+	if nil != err {
+		if blunder.Is(err, blunder.TryAgainError) {
+			reply.Busy = true
+			err = nil
+		} else if blunder.Is(err, blunder.DeadlockError) {
+			reply.Deadlocked = true
+			err = nil
+		}
+		connection.Lock()
+		connection.unref(request.CallerID)
+		connection.Unlock()
+		return
+	}
+
+	connection.Lock()
+	if connection.closed {
+		// Connection closed (and its locks released) while we waited for this one
+		_ = lock.localUnlock()
+		connection.unref(request.CallerID)
+		connection.Unlock()
+		err = fmt.Errorf("dlm lock server connection closed")
+		return
+	}
+	connection.held[lockServerHeldKey{request.DomainName, request.LockID, request.CallerID}]++
+	connection.Unlock()
+
+	return
+}
+
+// Release is the LockServer.Release RPC.
+func (connection *lockServerConnectionStruct) Release(request *LockServerReleaseRequest, reply *LockServerReleaseReply) (err error) {
+	heldKey := lockServerHeldKey{request.DomainName, request.LockID, request.CallerID}
+
+	connection.Lock()
+	heldCount := connection.held[heldKey]
+	if 0 == heldCount {
+		connection.Unlock()
+		err = fmt.Errorf("dlm lock server: lock %s:%s not held by caller %s", request.DomainName, request.LockID, request.CallerID)
+		return
+	}
+	if 1 == heldCount {
+		delete(connection.held, heldKey)
+	} else {
+		connection.held[heldKey] = heldCount - 1
+	}
+	lock := connection.lock(request.DomainName, request.LockID, request.CallerID)
+	err = lock.localUnlock()
+	connection.unref(request.CallerID) // dropping the reference taken just above...
+	connection.unref(request.CallerID) // ...as well as that taken by Acquire()
+	connection.Unlock()
+
+	return
+}
+
+// heldLock returns the lock identified by the client as domainName & lockID on behalf of its callerID (as
+// does lock()), failing should it not be held over the connection.
+func (connection *lockServerConnectionStruct) heldLock(domainName string, lockID string, callerID string) (lock *RWLockStruct, err error) {
+	connection.Lock()
+	defer connection.Unlock()
+
+	if 0 == connection.held[lockServerHeldKey{domainName, lockID, callerID}] {
+		err = fmt.Errorf("dlm lock server: lock %s:%s not held by caller %s", domainName, lockID, callerID)
+		return
+	}
+
+	lock = connection.lock(domainName, lockID, callerID)
+	return
+}
+
+// Upgrade is the LockServer.Upgrade RPC.
+func (connection *lockServerConnectionStruct) Upgrade(request *LockServerConvertRequest, reply *LockServerConvertReply) (err error) {
+	lock, err := connection.heldLock(request.DomainName, request.LockID, request.CallerID)
+	if nil != err {
+		return
+	}
+
+	err = lock.localUpgrade()
+	if blunder.Is(err, blunder.DeadlockError) {
+		reply.Deadlocked = true
+		err = nil
+	}
+
+	connection.Lock()
+	connection.unref(request.CallerID)
+	connection.Unlock()
+
+	return
+}
+
+// Downgrade is the LockServer.Downgrade RPC.
+func (connection *lockServerConnectionStruct) Downgrade(request *LockServerConvertRequest, reply *LockServerConvertReply) (err error) {
+	lock, err := connection.heldLock(request.DomainName, request.LockID, request.CallerID)
+	if nil != err {
+		return
+	}
+
+	err = lock.localDowngrade()
+
+	connection.Lock()
+	connection.unref(request.CallerID)
+	connection.Unlock()
+
+	return
+}
+
+// Ping is the LockServer.Ping RPC, by which the client keeps its connection alive.
+func (connection *lockServerConnectionStruct) Ping(request *LockServerPingRequest, reply *LockServerPingReply) (err error) {
+	return
+}
+
+// releaseAll releases every lock acquired over the (now failed) connection.
+func (connection *lockServerConnectionStruct) releaseAll() {
+	connection.Lock()
+	connection.closed = true
+	for heldKey, heldCount := range connection.held {
+		lock := connection.lock(heldKey.domainName, heldKey.lockID, heldKey.callerID)
+		for ; heldCount > 0; heldCount-- {
+			_ = lock.localUnlock()
+			connection.unref(heldKey.callerID)
+		}
+		connection.unref(heldKey.callerID)
+	}
+	connection.held = make(map[lockServerHeldKey]uint64)
+	connection.Unlock()
+}
+
+// lockServerBackendStruct is the Backend acquiring locks from the lock server.
+type lockServerBackendStruct struct {
+	sync.Mutex
+	lockServerAddr string
+	secret         []byte
+	fenceTimeout   time.Duration
+	fence          func(err error) // called should client fail while held > 0 (failStop() unless replaced by tests)
+	client         *rpc.Client     // nil if not (or no longer) connected
+	held           uint64          // locks acquired over client (and not since released)
+}
+
+func newLockServerBackend(lockServerAddr string, secret string, fenceTimeout time.Duration) (backend *lockServerBackendStruct) {
+	backend = &lockServerBackendStruct{
+		lockServerAddr: lockServerAddr,
+		secret:         []byte(secret),
+		fenceTimeout:   fenceTimeout,
+		fence:          failStop,
+	}
+	return
+}
+
+// failStop fences this node by exiting: as the locks its threads hold may have been granted to other nodes,
+// it may not continue to act upon them.
+func failStop(err error) {
+	logger.FatalfWithError(err, "dlm lock server connection failed while locks were held via it - fail-stopping")
+}
+
+// fetchClient returns the connection to the lock server, (re)connecting if necessary.
+func (backend *lockServerBackendStruct) fetchClient() (client *rpc.Client, err error) {
+	backend.Lock()
+	defer backend.Unlock()
+
+	if nil == backend.client {
+		backend.client, err = backend.dial()
+		if nil != err {
+			err = blunder.NewError(blunder.IOError, "dlm lock server %s unreachable: %v", backend.lockServerAddr, err)
+			return
+		}
+		backend.held = 0
+		go backend.heartbeat(backend.client)
+	}
+
+	client = backend.client
+	return
+}
+
+// dial connects to the lock server, replying to its challenge (see challenge()).
+func (backend *lockServerBackendStruct) dial() (client *rpc.Client, err error) {
+	conn, err := net.DialTimeout("tcp", backend.lockServerAddr, backend.fenceTimeout)
+	if nil != err {
+		return
+	}
+
+	err = conn.SetDeadline(time.Now().Add(backend.fenceTimeout))
+	if nil != err {
+		_ = conn.Close()
+		return
+	}
+	reader := bufio.NewReader(conn) // lock server sends nothing more until we are authenticated
+	challenge, err := reader.ReadString('\n')
+	if nil != err {
+		_ = conn.Close()
+		return
+	}
+	_, err = io.WriteString(conn, lockServerResponse(backend.secret, strings.TrimSuffix(challenge, "\n"))+"\n")
+	if nil != err {
+		_ = conn.Close()
+		return
+	}
+	authenticated, err := reader.ReadString('\n')
+	if (nil != err) || (lockServerAuthenticated != authenticated) {
+		_ = conn.Close()
+		err = fmt.Errorf("response to challenge rejected (is [DLM]LockServerSecret the same on every peer?): %v", err)
+		return
+	}
+	err = conn.SetDeadline(time.Time{})
+	if nil != err {
+		_ = conn.Close()
+		return
+	}
+
+	client = jsonrpc.NewClient(conn)
+	return
+}
+
+// heartbeat calls LockServer.Ping over client every quarter of the fence timeout until client is dropped,
+// dropping it should a Ping fail or go unanswered for a quarter of the fence timeout.
+func (backend *lockServerBackendStruct) heartbeat(client *rpc.Client) {
+	interval := backend.fenceTimeout / 4
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		backend.Lock()
+		dropped := (client != backend.client)
+		backend.Unlock()
+		if dropped {
+			return
+		}
+
+		var err error
+
+		call := client.Go("LockServer.Ping", &LockServerPingRequest{}, &LockServerPingReply{}, make(chan *rpc.Call, 1))
+		timer := time.NewTimer(interval)
+		select {
+		case <-call.Done:
+			timer.Stop()
+			err = call.Error
+		case <-timer.C:
+			err = fmt.Errorf("LockServer.Ping unanswered for %v", interval)
+		}
+		if nil != err {
+			backend.dropClient(client, err)
+			return
+		}
+	}
+}
+
+// dropClient forgets client should its connection have failed, fencing this node should any lock acquired
+// over it still be held.
+func (backend *lockServerBackendStruct) dropClient(client *rpc.Client, err error) {
+	if _, ok := err.(rpc.ServerError); ok {
+		return // connection intact
+	}
+
+	backend.Lock()
+	if client != backend.client {
+		backend.Unlock()
+		return // already dropped
+	}
+	_ = client.Close()
+	backend.client = nil
+	held := backend.held
+	backend.held = 0
+	backend.Unlock()
+
+	if 0 == held {
+		logger.WarnfWithError(err, "dlm lock server %s connection failed (no locks were held via it)", backend.lockServerAddr)
+		return
+	}
+
+	backend.fence(fmt.Errorf("dlm lock server %s connection failed with %d lock(s) held via it: %v", backend.lockServerAddr, held, err))
+}
+
+// granted notes the grant of a lock acquired over client, failing (leaving the lock server to release it)
+// should client have since been dropped.
+func (backend *lockServerBackendStruct) granted(client *rpc.Client) (err error) {
+	backend.Lock()
+	if client == backend.client {
+		backend.held++
+	} else {
+		err = fmt.Errorf("connection failed")
+	}
+	backend.Unlock()
+	return
+}
+
+// released notes the release of a lock acquired over client.
+func (backend *lockServerBackendStruct) released(client *rpc.Client) {
+	backend.Lock()
+	if (client == backend.client) && (0 < backend.held) {
+		backend.held--
+	}
+	backend.Unlock()
+}
+
+func (backend *lockServerBackendStruct) Acquire(ctx context.Context, domainName string, lockID string, callerID string, exclusive bool, try bool) (err error) {
+	client, err := backend.fetchClient()
+	if nil != err {
+		return
+	}
+
+	request := &LockServerAcquireRequest{
+		DomainName: domainName,
+		LockID:     lockID,
+		CallerID:   callerID,
+		Exclusive:  exclusive,
+		Try:        try,
+	}
+	reply := &LockServerAcquireReply{}
+
+	var ctxDone <-chan struct{} // nil (i.e. never done) if ctx is nil
+	if nil != ctx {
+		ctxDone = ctx.Done()
+	}
+
+	call := client.Go("LockServer.Acquire", request, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		err = call.Error
+	case <-ctxDone:
+		// The lock server continues to await the lock on our behalf, so release it should it yet be granted
+		go func() {
+			<-call.Done
+			if (nil == call.Error) && !reply.Busy && !reply.Deadlocked && (nil == backend.granted(client)) {
+				_ = backend.Release(domainName, lockID, callerID)
+			}
+		}()
+		err = lockWaitError(ctx)
+		return
+	}
+	if nil != err {
+		backend.dropClient(client, err)
+		err = blunder.NewError(blunder.IOError, "dlm lock server Acquire of %s:%s failed: %v", domainName, lockID, err)
+		return
+	}
+	if reply.Busy {
+		err = blunder.NewError(blunder.TryAgainError, "Lock is busy - try again!")
+	} else if reply.Deadlocked {
+		err = blunder.NewError(blunder.DeadlockError, "Deadlock detected by dlm lock server %s", backend.lockServerAddr)
+	} else {
+		err = backend.granted(client)
+		if nil != err {
+			err = blunder.NewError(blunder.IOError, "dlm lock server Acquire of %s:%s failed: %v", domainName, lockID, err)
+		}
+	}
+	return
+}
+
+func (backend *lockServerBackendStruct) Release(domainName string, lockID string, callerID string) (err error) {
+	client, err := backend.fetchClient()
+	if nil != err {
+		return
+	}
+
+	request := &LockServerReleaseRequest{
+		DomainName: domainName,
+		LockID:     lockID,
+		CallerID:   callerID,
+	}
+	reply := &LockServerReleaseReply{}
+
+	err = client.Call("LockServer.Release", request, reply)
+	if nil != err {
+		backend.dropClient(client, err)
+		err = blunder.NewError(blunder.IOError, "dlm lock server Release of %s:%s failed: %v", domainName, lockID, err)
+		return
+	}
+	backend.released(client)
+	return
+}
+
+func (backend *lockServerBackendStruct) Upgrade(domainName string, lockID string, callerID string) (err error) {
+	return backend.convert("Upgrade", domainName, lockID, callerID)
+}
+
+func (backend *lockServerBackendStruct) Downgrade(domainName string, lockID string, callerID string) (err error) {
+	return backend.convert("Downgrade", domainName, lockID, callerID)
+}
+
+// convert issues the LockServer.Upgrade or LockServer.Downgrade (as named by method) RPC.
+func (backend *lockServerBackendStruct) convert(method string, domainName string, lockID string, callerID string) (err error) {
+	client, err := backend.fetchClient()
+	if nil != err {
+		return
+	}
+
+	request := &LockServerConvertRequest{
+		DomainName: domainName,
+		LockID:     lockID,
+		CallerID:   callerID,
+	}
+	reply := &LockServerConvertReply{}
+
+	err = client.Call("LockServer."+method, request, reply)
+	if nil != err {
+		backend.dropClient(client, err)
+		err = blunder.NewError(blunder.IOError, "dlm lock server %s of %s:%s failed: %v", method, domainName, lockID, err)
+		return
+	}
+	if reply.Deadlocked {
+		err = blunder.NewError(blunder.DeadlockError, "Deadlock detected by dlm lock server %s", backend.lockServerAddr)
+	}
+	return
+}
+
+func (backend *lockServerBackendStruct) Close() (err error) {
+	backend.Lock()
+	if nil != backend.client {
+		err = backend.client.Close()
+		backend.client = nil
+	}
+	backend.Unlock()
+	return
+}
@@ -0,0 +1,198 @@
+package dlm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+const (
+	testLockServerSecret       = "secret"
+	testLockServerFenceTimeout = 400 * time.Millisecond
+)
+
+func TestLockServer(t *testing.T) {
+	assert := assert.New(t)
+
+	lockServer, err := startLockServer("127.0.0.1:0", testLockServerSecret, testLockServerFenceTimeout)
+	if !assert.Nil(err) {
+		return
+	}
+	defer lockServer.stop()
+
+	lockServerAddr := lockServer.listener.Addr().String()
+
+	// Each backend stands in for a separate node
+	nodeA := newLockServerBackend(lockServerAddr, testLockServerSecret, testLockServerFenceTimeout)
+	nodeB := newLockServerBackend(lockServerAddr, testLockServerSecret, testLockServerFenceTimeout)
+	defer nodeB.Close()
+
+	fenced := make(chan error, 1)
+	nodeA.fence = func(err error) { fenced <- err }
+	nodeB.fence = func(err error) { t.Errorf("node holding no locks fenced: %v", err) }
+
+	// A node not knowing the secret may not connect
+	nodeX := newLockServerBackend(lockServerAddr, "wrong"+testLockServerSecret, testLockServerFenceTimeout)
+	err = nodeX.Acquire(nil, "domain", "lock1", "1", true, true)
+	assert.True(blunder.Is(err, blunder.IOError), "Acquire() by node with wrong secret should fail with IOError")
+
+	// An exclusive lock held by one node excludes the other
+	err = nodeA.Acquire(nil, "domain", "lock1", "1", true, false)
+	assert.Nil(err)
+	err = nodeB.Acquire(nil, "domain", "lock1", "1", false, true)
+	assert.True(blunder.Is(err, blunder.TryAgainError), "try of lock held exclusively by another node should fail with TryAgainError")
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- nodeB.Acquire(nil, "domain", "lock1", "1", false, false)
+	}()
+	select {
+	case err = <-acquired:
+		t.Fatalf("Acquire() of lock held exclusively by another node returned early (err: %v)", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	err = nodeA.Release("domain", "lock1", "1")
+	assert.Nil(err)
+	assert.Nil(<-acquired)
+
+	// A shared lock is shared between nodes (and the same caller may hold it more than once)
+	err = nodeA.Acquire(nil, "domain", "lock1", "1", false, true)
+	assert.Nil(err)
+	err = nodeA.Acquire(nil, "domain", "lock1", "1", false, true)
+	assert.Nil(err)
+	err = nodeA.Acquire(nil, "domain", "lock1", "2", true, true)
+	assert.True(blunder.Is(err, blunder.TryAgainError), "try of exclusive lock held shared should fail with TryAgainError")
+	for i := 0; i < 2; i++ {
+		err = nodeA.Release("domain", "lock1", "1")
+		assert.Nil(err)
+	}
+	err = nodeB.Release("domain", "lock1", "1")
+	assert.Nil(err)
+	err = nodeB.Release("domain", "lock1", "1")
+	assert.NotNil(err, "Release() of lock not held should fail")
+
+	// Locks held via RWLockStruct are arbitrated by the Backend
+	previousBackend := SetBackend(nodeA)
+	lock := &RWLockStruct{Domain: "domain", LockID: "lock2", LockCallerID: GenerateCallerID()}
+	err = lock.WriteLock()
+	assert.Nil(err)
+	assert.True(lock.IsWriteHeld())
+	err = nodeB.Acquire(nil, "domain", "lock2", "1", false, true)
+	assert.True(blunder.Is(err, blunder.TryAgainError), "try of lock write locked by another node should fail with TryAgainError")
+	err = lock.Unlock()
+	assert.Nil(err)
+	err = nodeB.Acquire(nil, "domain", "lock2", "1", true, true)
+	assert.Nil(err)
+	err = lock.TryReadLock()
+	assert.True(blunder.Is(err, blunder.TryAgainError), "TryReadLock() of lock held exclusively by another node should fail with TryAgainError")
+	assert.False(lock.IsReadHeld(), "failed TryReadLock() should not leave the lock held locally")
+	err = nodeB.Release("domain", "lock2", "1")
+	assert.Nil(err)
+	SetBackend(previousBackend)
+
+	// A wait for a lock held by another node may be given up (the lock being released should it yet be granted)
+	err = nodeA.Acquire(nil, "domain", "lock4", "1", true, false)
+	assert.Nil(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	err = nodeB.Acquire(ctx, "domain", "lock4", "1", true, false)
+	cancel()
+	assert.True(blunder.Is(err, blunder.TimedOut), "Acquire() of lock held by another node should fail with TimedOut once ctx is done")
+	err = nodeA.Release("domain", "lock4", "1")
+	assert.Nil(err)
+	go func() {
+		acquired <- nodeA.Acquire(nil, "domain", "lock4", "1", true, false)
+	}()
+	select {
+	case err = <-acquired:
+		assert.Nil(err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Acquire() of lock abandoned by another node never returned")
+	}
+	err = nodeA.Release("domain", "lock4", "1")
+	assert.Nil(err)
+
+	// A lock held shared by a single node may be upgraded and downgraded
+	err = nodeA.Acquire(nil, "domain", "lock5", "1", false, false)
+	assert.Nil(err)
+	err = nodeB.Acquire(nil, "domain", "lock5", "1", false, false)
+	assert.Nil(err)
+	go func() {
+		acquired <- nodeA.Upgrade("domain", "lock5", "1")
+	}()
+	select {
+	case err = <-acquired:
+		t.Fatalf("Upgrade() of lock also held by another node returned early (err: %v)", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	err = nodeB.Upgrade("domain", "lock5", "1")
+	assert.True(blunder.Is(err, blunder.DeadlockError), "concurrent Upgrade() should fail with DeadlockError")
+	err = nodeB.Release("domain", "lock5", "1")
+	assert.Nil(err)
+	assert.Nil(<-acquired)
+	err = nodeB.Acquire(nil, "domain", "lock5", "1", false, true)
+	assert.True(blunder.Is(err, blunder.TryAgainError), "try of upgraded lock should fail with TryAgainError")
+	err = nodeA.Downgrade("domain", "lock5", "1")
+	assert.Nil(err)
+	err = nodeB.Acquire(nil, "domain", "lock5", "1", false, true)
+	assert.Nil(err)
+	err = nodeB.Downgrade("domain", "lock5", "1")
+	assert.NotNil(err, "Downgrade() of lock held shared should fail")
+	for _, node := range []*lockServerBackendStruct{nodeA, nodeB} {
+		err = node.Release("domain", "lock5", "1")
+		assert.Nil(err)
+	}
+
+	// The locks of a node whose connection fails are released
+	err = nodeA.Acquire(nil, "domain", "lock3", "1", true, false)
+	assert.Nil(err)
+	err = nodeA.Close()
+	assert.Nil(err)
+	go func() {
+		acquired <- nodeB.Acquire(nil, "domain", "lock3", "1", true, false)
+	}()
+	select {
+	case err = <-acquired:
+		assert.Nil(err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Acquire() of lock held by failed node never returned")
+	}
+	err = nodeB.Release("domain", "lock3", "1")
+	assert.Nil(err)
+
+	// A node whose connection fails while holding locks is fenced (its locks released only after the fence timeout)
+	nodeA = newLockServerBackend(lockServerAddr, testLockServerSecret, testLockServerFenceTimeout)
+	defer nodeA.Close()
+	nodeA.fence = func(err error) { fenced <- err }
+	err = nodeA.Acquire(nil, "domain", "lock6", "1", true, false)
+	assert.Nil(err)
+	err = nodeB.Acquire(nil, "domain", "lock7", "1", true, false)
+	assert.Nil(err)
+	err = nodeB.Release("domain", "lock7", "1")
+	assert.Nil(err)
+	failedTime := time.Now()
+	lockServer.Lock()
+	for _, conn := range lockServer.connections {
+		_ = conn.Close()
+	}
+	lockServer.Unlock()
+	select {
+	case err = <-fenced:
+		assert.NotNil(err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("node whose connection failed while holding locks never fenced")
+	}
+	assert.True(time.Since(failedTime) < testLockServerFenceTimeout, "node should be fenced before the fence timeout passes")
+	err = nodeB.Acquire(nil, "domain", "lock6", "1", true, false)
+	if blunder.Is(err, blunder.IOError) {
+		// nodeB's connection also failed, so it must first reconnect
+		err = nodeB.Acquire(nil, "domain", "lock6", "1", true, false)
+	}
+	assert.Nil(err)
+	assert.True(time.Since(failedTime) >= testLockServerFenceTimeout/2, "locks of failed connection released before the fence timeout passed")
+	err = nodeB.Release("domain", "lock6", "1")
+	assert.Nil(err)
+}
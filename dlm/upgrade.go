@@ -0,0 +1,159 @@
+package dlm
+
+// Lock upgrade & downgrade
+//
+// A caller holding a lock shared may Upgrade() it to exclusive without releasing it (and so without another
+// caller changing what it protects in between). The upgrade waits for any other holders to release the lock,
+// taking precedence over every queued request (each of which would otherwise wait for the upgrading caller,
+// even as it waited for them). While it is pending, no other request is granted. Should two holders of a lock
+// both attempt to upgrade it, each would wait for the other to release it... so the second instead fails
+// immediately with DeadlockError (EDEADLK) with the lock remaining held shared (its caller then typically
+// releases the lock, reacquiring it exclusively). A pending upgrade is otherwise subject to deadlock detection
+// (see deadlock.go) like any waiting request.
+//
+// A caller holding a lock exclusively may Downgrade() it to shared without releasing it, whereupon any shared
+// requests its domain's fairness policy (see fairness.go) allows are granted.
+//
+// Both are applied locally and then to the Backend (see backend.go), Upgrade() being undone locally should
+// the Backend fail it.
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+// upgrade upgrades the lock locally and then with the Backend.
+func (l *RWLockStruct) upgrade() (err error) {
+	err = l.localUpgrade()
+	if nil != err {
+		return
+	}
+
+	err = fetchBackend().Upgrade(l.Domain, l.LockID, callerIDString(l.LockCallerID))
+	if nil != err {
+		_ = l.localDowngrade()
+	}
+	return
+}
+
+// downgrade downgrades the lock locally and then with the Backend.
+func (l *RWLockStruct) downgrade() (err error) {
+	err = l.localDowngrade()
+	if nil != err {
+		return
+	}
+
+	err = fetchBackend().Downgrade(l.Domain, l.LockID, callerIDString(l.LockCallerID))
+	return
+}
+
+// lockHeldTrack returns the tracking structure of the lock with its mutex held (as well as its domain, and
+// the domain's fairness policy), failing should the caller not hold it in heldState.
+func (l *RWLockStruct) lockHeldTrack(heldState lockState) (domain *lockDomainStruct, track *localLockTrack, fairnessPolicy FairnessPolicy, err error) {
+	domain = lockExistingDomain(l.Domain)
+	if nil != domain {
+		track = domain.localLockMap[l.LockID]
+		if nil != track {
+			track.Mutex.Lock()
+		}
+		fairnessPolicy = domain.fairnessPolicy
+		domain.Unlock()
+	}
+
+	if (nil == track) || (track.state != heldState) || !callerInListOfOwners(track.listOfOwners, l.LockCallerID) {
+		if nil != track {
+			track.Mutex.Unlock()
+			track = nil
+		}
+		heldStateString := "shared"
+		if exclusive == heldState {
+			heldStateString = "exclusively"
+		}
+		err = blunder.NewError(blunder.InvalidArgError, "Lock %s:%s not held %s by caller %s", l.Domain, l.LockID, heldStateString, callerIDString(l.LockCallerID))
+	}
+
+	return
+}
+
+// localUpgrade upgrades the lock held shared by the caller to exclusive with the node-local lock manager.
+func (l *RWLockStruct) localUpgrade() (err error) {
+	domain, track, fairnessPolicy, err := l.lockHeldTrack(shared)
+	if nil != err {
+		return
+	}
+	defer track.Mutex.Unlock()
+
+	if nil != track.upgrader {
+		atomic.AddUint64(&domain.deadlocks, 1)
+		err = blunder.NewError(blunder.DeadlockError, "Upgrade() of lock %s:%s by caller %s would deadlock with that of caller %s",
+			l.Domain, l.LockID, callerIDString(l.LockCallerID), callerIDString(track.upgrader.LockCallerID))
+		return
+	}
+
+	upgradeRequest := localLockRequest{requestedState: exclusive, LockCallerID: l.LockCallerID, wakeUp: false}
+	upgradeRequest.Cond = sync.NewCond(&track.Mutex)
+	track.upgrader = &upgradeRequest
+
+	processLocalQ(track, fairnessPolicy)
+
+	if upgradeRequest.wakeUp == false {
+		upgradeRequest.seq = atomic.AddUint64(&globals.lastWaitSeq, 1)
+	}
+	for (upgradeRequest.wakeUp == false) && (nil == upgradeRequest.failure) {
+		upgradeRequest.Cond.Wait()
+	}
+
+	if upgradeRequest.wakeUp == false {
+		if track.upgrader == &upgradeRequest {
+			track.upgrader = nil
+		}
+
+		// Requests held back by the upgrade may now be granted
+		processLocalQ(track, fairnessPolicy)
+
+		return upgradeRequest.failure
+	}
+
+	return nil
+}
+
+// localDowngrade downgrades the lock held exclusively by the caller to shared with the node-local lock manager.
+func (l *RWLockStruct) localDowngrade() (err error) {
+	_, track, fairnessPolicy, err := l.lockHeldTrack(exclusive)
+	if nil != err {
+		return
+	}
+
+	track.state = shared
+
+	// See if any (shared) locks can be granted
+	processLocalQ(track, fairnessPolicy)
+
+	track.Mutex.Unlock()
+
+	return nil
+}
+
+// grantUpgrade grants the pending upgrade once its caller is the lock's only holder.
+//
+// This function assumes that the tracking mutex is held.
+func grantUpgrade(track *localLockTrack) {
+	upgradeRequest := track.upgrader
+
+	if (nil != upgradeRequest.failure) || !callerInListOfOwners(track.listOfOwners, upgradeRequest.LockCallerID) {
+		return // upgrade to be withdrawn by its waiter
+	}
+
+	for _, owner := range track.listOfOwners {
+		if owner != upgradeRequest.LockCallerID {
+			return
+		}
+	}
+
+	track.state = exclusive
+	track.upgrader = nil
+	upgradeRequest.wakeUp = true
+	upgradeRequest.Cond.Broadcast()
+}
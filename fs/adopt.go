@@ -0,0 +1,164 @@
+package fs
+
+// Adoption of middleware-written objects
+//
+// A file reified by MiddlewarePutComplete() references the objects PUT via the Swift middleware as its
+// LogSegments. Their layout (e.g. a single multi-GiB LogSegment) suits whole-object GETs but not later
+// random writes via the filesystem path. If [<volume-section>]AdoptMiddlewareObjects is true, each such
+// file records the LogSegments so attached in its reserved AdoptStream. The first Write() to the file
+// then schedules a background "adopt" job that rewrites the extents residing in those LogSegments to
+// new LogSegments exactly as Write() would have written them (see inode.Adopt()), after which the
+// original objects are deleted and the AdoptStream removed. The file's contents are unaffected.
+//
+// As the AdoptStream is persisted with the inode, a file not yet adopted when its volume goes offline
+// is adopted upon its first Write() once back online.
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// AdoptStream is the reserved stream on a file inode listing the middleware-written LogSegments to adopt.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const AdoptStream = "proxyfs.adopt"
+
+type adoptStruct struct {
+	sync.Mutex
+	enabled bool                           // [<volume-section>]AdoptMiddlewareObjects
+	stopped bool                           // if true, no further adopt jobs are launched (see shutdown.go)
+	pending map[inode.InodeNumber]struct{} // files with an adopt job scheduled or running
+	jobs    sync.WaitGroup                 // signaled as each adopt job exits
+}
+
+func (vS *volumeStruct) initAdopt() {
+	vS.adopt.pending = make(map[inode.InodeNumber]struct{})
+}
+
+func (vS *volumeStruct) configureAdopt(enabled bool) {
+	vS.adopt.Lock()
+	vS.adopt.enabled = enabled
+	vS.adopt.Unlock()
+}
+
+// recordAdoptable records in fileInodeNumber's AdoptStream the LogSegments of pObjectPaths.
+func (vS *volumeStruct) recordAdoptable(fileInodeNumber inode.InodeNumber, pObjectPaths []string) (err error) {
+	vS.adopt.Lock()
+	enabled := vS.adopt.enabled
+	vS.adopt.Unlock()
+
+	if !enabled || (0 == len(pObjectPaths)) {
+		return
+	}
+
+	logSegmentNumbers := make([]uint64, 0, len(pObjectPaths))
+	for _, pObjectPath := range pObjectPaths {
+		_, _, logSegmentNumeral, pathErr := utils.PathToAcctContObj(pObjectPath)
+		if nil != pathErr {
+			err = pathErr
+			return
+		}
+		logSegmentNumber, parseErr := strconv.ParseUint(logSegmentNumeral, 16, 64)
+		if nil != parseErr {
+			err = blunder.NewError(blunder.InvalidArgError, "physical object path %s does not name a LogSegment", pObjectPath)
+			return
+		}
+		logSegmentNumbers = append(logSegmentNumbers, logSegmentNumber)
+	}
+
+	buf, err := json.Marshal(logSegmentNumbers)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(fileInodeNumber, AdoptStream, buf)
+	return
+}
+
+// scheduleAdopt launches an adopt job for fileInodeNumber if it has an AdoptStream.
+//
+// Caller holds fileInodeNumber's write lock, so the job only proceeds once the caller is done.
+func (vS *volumeStruct) scheduleAdopt(fileInodeNumber inode.InodeNumber) {
+	vS.adopt.Lock()
+	if !vS.adopt.enabled || vS.adopt.stopped {
+		vS.adopt.Unlock()
+		return
+	}
+	_, ok := vS.adopt.pending[fileInodeNumber]
+	if ok {
+		vS.adopt.Unlock()
+		return
+	}
+	_, err := vS.VolumeHandle.GetStream(fileInodeNumber, AdoptStream)
+	if nil != err {
+		vS.adopt.Unlock()
+		return
+	}
+	vS.adopt.pending[fileInodeNumber] = struct{}{}
+	vS.adopt.jobs.Add(1)
+	vS.adopt.Unlock()
+
+	go vS.adoptFile(fileInodeNumber)
+}
+
+// stopAdopt prevents further adopt jobs from being launched, waiting until deadline for those running to
+// finish. As the AdoptStream of a file is removed only once it has been adopted, an unfinished job is
+// simply redone following the file's next Write().
+func (vS *volumeStruct) stopAdopt(deadline time.Time) (finished bool) {
+	vS.adopt.Lock()
+	vS.adopt.stopped = true
+	vS.adopt.Unlock()
+
+	finished = waitUntil(&vS.adopt.jobs, deadline)
+	return
+}
+
+// adoptFile is the adopt job for fileInodeNumber.
+func (vS *volumeStruct) adoptFile(fileInodeNumber inode.InodeNumber) {
+	defer func() {
+		vS.adopt.Lock()
+		delete(vS.adopt.pending, fileInodeNumber)
+		vS.adopt.Unlock()
+		vS.adopt.jobs.Done()
+	}()
+
+	inodeLock, err := vS.getWriteLock(fileInodeNumber, nil)
+	if nil != err {
+		logger.ErrorfWithError(err, "fs: adopt of inode %v in volume '%s' unable to lock it", fileInodeNumber, vS.volumeName)
+		return
+	}
+	defer inodeLock.Unlock()
+
+	buf, err := vS.VolumeHandle.GetStream(fileInodeNumber, AdoptStream)
+	if nil != err {
+		return // removed (or already adopted) in the meantime
+	}
+
+	var logSegmentNumbers []uint64
+
+	err = json.Unmarshal(buf, &logSegmentNumbers)
+	if nil == err {
+		err = vS.VolumeHandle.Adopt(fileInodeNumber, logSegmentNumbers)
+	}
+	if nil != err {
+		logger.ErrorfWithError(err, "fs: adopt of inode %v in volume '%s' failed", fileInodeNumber, vS.volumeName)
+		return
+	}
+
+	err = vS.VolumeHandle.DeleteStream(fileInodeNumber, AdoptStream)
+	if nil != err {
+		logger.ErrorfWithError(err, "fs: adopt of inode %v in volume '%s' unable to remove %s", fileInodeNumber, vS.volumeName, AdoptStream)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsAdoptOps)
+	logger.Infof("fs: adopted %v middleware-written LogSegments of inode %v in volume '%s'", len(logSegmentNumbers), fileInodeNumber, vS.volumeName)
+}
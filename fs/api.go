@@ -5,6 +5,8 @@ package fs
 import "C"
 
 import (
+	"time"
+
 	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/stats"
 	"github.com/swiftstack/ProxyFS/utils"
@@ -38,6 +40,13 @@ type ContainerEntry struct {
 	NumWrites        uint64
 	InodeNumber      uint64
 	Metadata         []byte
+	IsSubdir         bool // a pseudo-directory ("subdir") of a delimited listing; Basename ends with the delimiter
+}
+
+// Returned by MiddlewareGetContainerShards
+type ContainerShard struct {
+	Marker    string // list entries after this ("" == from the start of the container)
+	EndMarker string // list entries up to and including this ("" == through the end of the container)
 }
 
 type HeadResponse struct {
@@ -47,6 +56,51 @@ type HeadResponse struct {
 	IsDir            bool
 	InodeNumber      inode.InodeNumber
 	NumWrites        uint64
+	ETag             string // hash of a file's contents (see etag.go); empty if a directory or not computed
+	ContentType      string // detected Content-Type of a file (see content_type.go); empty if a directory or none
+}
+
+// PutPreconditions make MiddlewarePutCompleteConditional() fail with PreconditionFailed unless what
+// currently occupies the object's path is as the caller expects. They are checked with the path locked, so
+// no other change can intervene before the object is replaced. The zero value imposes no preconditions.
+//
+// Setting any of the IfMatch fields requires that a file occupy the path (i.e. If-Match: *). An IfMatchETag
+// is compared to the file's content hash (see etag.go), so never matches a file too large to be hashed.
+type PutPreconditions struct {
+	IfNoneMatch        bool              // the path must be unoccupied (i.e. If-None-Match: *)
+	IfMatch            bool              // a file must occupy the path...
+	IfMatchETag        string            // ...having this ETag (if non-empty)...
+	IfMatchInodeNumber inode.InodeNumber // ...and being this inode (if non-zero)...
+	IfMatchNumWrites   uint64            // ...with this NumWrites
+}
+
+// CoalesceElementSpec names a file (by its path relative to the root directory) to be consumed by
+// MiddlewareCoalesceElements() and the range of its contents to include in the combined file.
+type CoalesceElementSpec struct {
+	Path   string
+	Offset uint64
+	Length uint64 // 0 == through the end of the file
+}
+
+// DeleteMultiEntry names an object (or empty directory) to be deleted by MiddlewareDeleteMulti()
+type DeleteMultiEntry struct {
+	Container  string
+	ObjectPath string // path within Container; "" == Container itself (which must be empty)
+}
+
+// ContainerACL lists the callers admitted to read and to write a container (see container_acl.go). Each
+// entry is "*" (any caller), a caller's Principal, or one of a caller's Groups.
+type ContainerACL struct {
+	Read  []string
+	Write []string
+}
+
+// MiddlewareCallerStruct identifies the HTTP client on whose behalf a Middleware...AsCaller() operation
+// is performed, as authenticated by the Swift proxy
+type MiddlewareCallerStruct struct {
+	Principal string   // e.g. "AUTH_test:tester"
+	Groups    []string // e.g. roles or tempauth groups
+	Admin     bool     // if true, no ContainerACL applies (e.g. the account's owner)
 }
 
 // The following constants are used to ensure that the length of file fullpath and basenames are POSIX-compliant
@@ -55,12 +109,16 @@ const (
 	FileNameMax = C.NAME_MAX
 )
 
-// The maximum number of symlinks we will follow
+// The maximum number of symlinks we will follow unless [<volume-section>]MaxSymlinks says otherwise
 const MaxSymlinks = 8 // same as Linux; see include/linux/namei.h in Linux's Git repository
 
-// Constant defining the name of the alternate data stream used by Swift Middleware
+// Constant defining the name of the alternate data stream used by Swift Middleware (reserved; see xattr.go)
 const MiddlewareStream = "middleware"
 
+// Constant defining the name of the alternate data stream of the root directory holding the metadata of the
+// Swift account (reserved; see xattr.go)
+const AccountMetadataStream = "proxyfs.accountmetadata"
+
 // Byte prefix constants
 const (
 	KiloByte = 1024
@@ -75,7 +133,7 @@ const (
 	FsOptimalTransferSize = 64 * KiloByte
 )
 
-// XXX TBD: For now we are cooking up these numbers...
+// Capacities reported by StatVfs when no quota is configured for (nor set on the Swift account of) a volume
 const (
 	VolFakeTotalBlocks = TeraByte / FsBlockSize
 	VolFakeFreeBlocks  = TeraByte / FsBlockSize
@@ -86,6 +144,156 @@ const (
 	VolFakeAvailInodes = TeraByte
 )
 
+// LimitsStruct collects the limits a volume imposes (see GetLimits() and SetLimits())
+//
+// Only the fields marked adjustable may be changed by SetLimits(). Each is initialized from the
+// like-named [<volume-section>] option. The rest reflect constants of this package save MaxSymlinks,
+// taken from [<volume-section>]MaxSymlinks (see symlink_policy.go).
+type LimitsStruct struct {
+	FileNameMax            uint64 // longest basename
+	FilePathMax            uint64 // longest fullpath
+	MaxSymlinks            uint64 // most symlinks followed resolving a fullpath
+	FsBlockSize            uint64 // block size reported by StatVfs()
+	OptimalTransferSize    uint64 // optimal transfer size reported by StatVfs()
+	XAttrNameMax           uint64 // longest XAttr (stream) name accepted by SetXAttr()     [adjustable]
+	XAttrValueMax          uint64 // largest XAttr (stream) value accepted by SetXAttr()    [adjustable]
+	MaxEntriesPerOperation uint64 // most entries a single listing returns (see limits.go) [adjustable]
+	MaxBytesPerOperation   uint64 // most bytes a single listing or read returns          [adjustable]
+}
+
+// ReclaimCandidate is a file found by AnalyzeReclaimable() to hold space that Reclaim() could recover
+type ReclaimCandidate struct {
+	InodeNumber  inode.InodeNumber
+	Fragments    uint64 // extents composing the file
+	TrappedBytes uint64 // unreferenced bytes in the LogSegments the file references
+}
+
+// ReclaimReport is returned by AnalyzeReclaimable()
+type ReclaimReport struct {
+	FilesScanned uint64
+	TrappedBytes uint64             // sum of Candidates[].TrappedBytes
+	Candidates   []ReclaimCandidate // in decreasing order of TrappedBytes
+	OrphanInodes uint64             // unnamed inodes (see CreateUnlinked()) reclaimed once the volume is next brought up
+	OrphanBytes  uint64             // total Size of OrphanInodes
+	Stopped      bool               // if true, the report is incomplete
+}
+
+// ReclaimResult is returned by Reclaim()
+type ReclaimResult struct {
+	FilesCompacted uint64
+	ReclaimedBytes uint64
+	Stopped        bool // if true, stopChan or maxDuration ended Reclaim() early
+}
+
+// DirEntryTypesUpgradeResult is returned by UpgradeDirEntryTypes()
+type DirEntryTypesUpgradeResult struct {
+	DirsScanned     uint64
+	EntriesRecorded uint64 // entries that previously lacked their InodeType
+	Stopped         bool   // if true, stopChan ended UpgradeDirEntryTypes() early
+}
+
+// InodeHistoryEntry records an operation upon an inode (see FetchInodeHistory())
+type InodeHistoryEntry struct {
+	Time    time.Time // when the operation completed
+	MountID MountID   // the mount the operation arrived via
+	Op      string    // e.g. "Write", "Unlink foo", or "Create foo (inode 123)"
+	Result  string    // "" if the operation succeeded, else its error
+}
+
+// VerifyObject identifies a Swift Object found by VerifyVolume() to be in discrepancy
+type VerifyObject struct {
+	ContainerName string
+	ObjectName    string
+	InodeNumber   inode.InodeNumber `json:",omitempty"` // for MissingObjects, the file referencing it
+}
+
+// VerifyReport is returned by VerifyVolume()
+type VerifyReport struct {
+	FilesScanned        uint64
+	LogSegmentsScanned  uint64         // LogSegments referenced by the files scanned
+	ContainersListed    uint64         // PhysicalContainers listed
+	ObjectsListed       uint64         // Objects in the PhysicalContainers listed
+	UnreferencedObjects []VerifyObject // Objects not referenced by any file
+	MissingObjects      []VerifyObject // LogSegments referenced by a file but not found
+	Stopped             bool           // if true, the report is incomplete
+}
+
+// OpStats reports the operations performed via a mount or upon a volume (see op_stats.go)
+type OpStats struct {
+	Operations   uint64 // operations begun
+	Errors       uint64 // operations that returned an error
+	ReadBytes    uint64
+	WrittenBytes uint64
+}
+
+// VolumeOpStats is returned by FetchVolumeOpStats()
+type VolumeOpStats struct {
+	Volume OpStats
+	Mounts map[MountID]OpStats // current mounts of the volume
+}
+
+// Snapshot describes a read-only snapshot of a volume (see snapshot.go)
+//
+// Its contents may be browsed at /.snapshot/<Name>.
+type Snapshot struct {
+	ID         uint64
+	Name       string
+	CreateTime time.Time
+}
+
+// TrashEntry describes an inode in a volume's trash (see trash.go)
+type TrashEntry struct {
+	InodeNumber    inode.InodeNumber
+	InodeType      inode.InodeType
+	DirInodeNumber inode.InodeNumber // the directory from which it was removed
+	Basename       string            // the name by which it was removed
+	OriginalPath   string            // path (from the root directory) RestoreTrash() would return it to ("" if undeterminable)
+	DeleteTime     time.Time
+}
+
+// FileVersion describes a version of a file preserved as the file was truncated or overwritten (see version.go)
+type FileVersion struct {
+	VersionInodeNumber inode.InodeNumber // may be opened via OpenVersion()
+	VersionTime        time.Time         // when the version was preserved
+	ModificationTime   time.Time         // of the file as preserved
+	Size               uint64
+}
+
+// RetentionPolicy is the retention applied to files placed in a container (see retention.go)
+type RetentionPolicy struct {
+	MinimumRetention time.Duration // how long each file is protected once committed
+}
+
+// RetentionStatus describes the protection of a file from alteration (see retention.go)
+type RetentionStatus struct {
+	MinimumRetention time.Duration // non-zero if retention is pending (i.e. not yet committed)
+	RetainUntil      time.Time     // zero if not yet committed
+	LegalHold        bool
+	Protected        bool // true if under legal hold or before RetainUntil
+}
+
+// UsageSample is a point of the usage trend returned by FetchUsageTrend()
+type UsageSample struct {
+	Time       time.Time
+	BytesUsed  uint64
+	InodesUsed uint64
+	Ops        uint64  // operations begun since the prior sample
+	OpsPerSec  float64 // Ops averaged over the time since the prior sample (0 for the first sample)
+}
+
+// UsageAlert is logged, POSTed to any UsageAlertWebhook, and handed to each UsageAlertHandler (see trend.go)
+type UsageAlert struct {
+	VolumeName string
+	Resource   string // "bytes" or "inodes"
+	Used       uint64
+	Total      uint64
+	Percent    float64       // of Total that is Used
+	TimeToFull time.Duration // at the recent rate of growth (0 == not growing)
+}
+
+// UsageAlertHandler is invoked upon each UsageAlert of any volume
+type UsageAlertHandler func(alert UsageAlert)
+
 type FlockStruct struct {
 	Type   int32
 	Whence int32
@@ -94,10 +302,112 @@ type FlockStruct struct {
 	Pid    uint64
 }
 
+// NotifyEventType identifies the kind of change reported by a NotifyEvent
+type NotifyEventType uint32
+
+const (
+	NotifyCreate     NotifyEventType = iota + 1 // Basename was created (or linked) in ParentInodeNumber
+	NotifyUnlink                                // Basename was removed from ParentInodeNumber
+	NotifyRenameFrom                            // Basename was renamed out of ParentInodeNumber (followed by a NotifyRenameTo)
+	NotifyRenameTo                              // Basename was renamed into ParentInodeNumber
+	NotifyWrite                                 // InodeNumber's data (or size) was modified
+	NotifySetAttr                               // InodeNumber's attributes (including XAttrs) were modified
+	NotifyOverflow                              // Events were discarded because the watch's queue overflowed
+)
+
+// NotifyEvent describes a change delivered to a watch registered via AddWatch()
+//
+// ParentInodeNumber and Basename are zero/empty for a NotifyWrite or NotifySetAttr whose name is not known.
+type NotifyEvent struct {
+	Type              NotifyEventType
+	InodeNumber       inode.InodeNumber
+	ParentInodeNumber inode.InodeNumber
+	Basename          string
+}
+
+type WatchID uint64
+
+// NotifyHandler is invoked (serially for a given watch) for each NotifyEvent matching the watch
+type NotifyHandler func(watchID WatchID, event NotifyEvent)
+
+// WatchStats is returned by FetchWatchStats()
+//
+// Version is a rollup counter advanced by every change matching the watch (anywhere in the subtree of a
+// subtree watch); a caller need only rescan when it has moved (e.g. upon receiving a NotifyOverflow).
+type WatchStats struct {
+	Version   uint64 // changes matched (including those discarded in a NotifyOverflow)
+	Delivered uint64 // events handed to the watch's NotifyHandler
+	Queued    uint64 // events awaiting delivery
+	Overflows uint64 // times queued events were collapsed into a NotifyOverflow
+}
+
+// LeaseType is the caching permitted the holder of a lease granted via AcquireLease()
+type LeaseType uint32
+
+const (
+	LeaseNone  LeaseType = iota // no caching (i.e. the lease has been released)
+	LeaseRead                   // holder may cache reads (and attributes)
+	LeaseWrite                  // holder may also cache writes... excludes leases held by other mounts
+)
+
+type LeaseID uint64
+
+// FreezeID identifies a container freeze made via MiddlewareFreezeContainer()
+type FreezeID uint64
+
+// LeaseBreakHandler is invoked when another mount requests a lease that conflicts with leaseID
+//
+// The holder should write back anything it has cached and then call DowngradeLease() to breakTo (either
+// LeaseRead or LeaseNone, the latter being equivalent to ReleaseLease()).
+type LeaseBreakHandler func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType)
+
+// OpenFlags are the access requested of Open()
+type OpenFlags uint32
+
+const (
+	OpenRead   OpenFlags = 1 << iota // handle may be used to read
+	OpenWrite                        // handle may be used to write
+	OpenDelete                       // handle's holder intends to unlink or rename the file (matters only to ShareMode)
+	OpenAppend                       // handle may be used to write... always at the end of the file
+	OpenDirect                       // hint: writes via handle are flushed before returning (i.e. bypass any write-back)
+)
+
+// ShareMode is the access other concurrent opens of a file are permitted to request (see Open())
+type ShareMode uint32
+
+const (
+	ShareRead ShareMode = 1 << iota
+	ShareWrite
+	ShareDelete
+)
+
+// FileHandle is returned by Open() to identify the open in subsequent ReadByHandle(), WriteByHandle(), FlockByHandle(), and Close() calls
+type FileHandle uint64
+
+// DurableHandleStruct is returned by OpenDurable() to identify the inode a path resolved to in subsequent ...ByDurableHandle() calls (see durable.go)
+type DurableHandleStruct struct {
+	InodeNumber inode.InodeNumber
+	Generation  uint64
+}
+
 type MountOptions uint64
 
 const (
-	MountReadOnly MountOptions = 1 << iota
+	MountReadOnly    MountOptions = 1 << iota
+	MountNoATime                  // reads never update atime (the default if no atime policy is specified)
+	MountRelATime                 // reads update atime only if it is not newer than mtime/ctime or is over a day old
+	MountStrictATime              // reads always update atime
+	MountNoExec                   // files may not be executed (see mount_options.go)
+	MountNoSuid                   // setuid & setgid bits are neither settable nor retained (see mount_options.go)
+	MountNoDev                    // device files may not be used (see mount_options.go)
+)
+
+// RenameFlags may be bitwise or'd together in the flags passed to Rename() (values match Linux renameat2())
+type RenameFlags uint32
+
+const (
+	RenameNoReplace RenameFlags = 1 << iota // fail with FileExistsError (EEXIST) if dstBasename already exists
+	RenameExchange                          // atomically swap srcBasename & dstBasename (both must exist)
 )
 
 type StatKey uint64
@@ -115,6 +425,9 @@ const (
 	StatUserID                       // file userid
 	StatGroupID                      // file groupid
 	StatNumWrites                    // number of writes to inode
+	StatBlocks                       // number of 512-byte blocks allocated (blocks in posix stat)
+	StatBlkSize                      // preferred I/O size in bytes         (blksize in posix stat)
+	StatChangeCount                  // incremented with each change to inode data or attributes (e.g. NFS change attribute)
 )
 
 // XXX TODO: StatMode, StatUserID, and StatGroupID are really
@@ -144,56 +457,205 @@ type StatVFS map[StatVFSKey]uint64 // key is one of StatVFSKey consts
 
 // Mount handle interface
 
+// IDMapStruct describes how caller identities presented to a MountHandle are mapped (see idmap.go)
+type IDMapStruct struct {
+	RootSquash  bool                                      // root (after applying UserIDMap & GroupIDMap) becomes AnonUserID & AnonGroupID
+	AnonUserID  inode.InodeUserID                         // used only if RootSquash is true
+	AnonGroupID inode.InodeGroupID                        // used only if RootSquash is true
+	UserIDMap   map[inode.InodeUserID]inode.InodeUserID   // key == client userID; value == userID used in volume
+	GroupIDMap  map[inode.InodeGroupID]inode.InodeGroupID // key == client groupID; value == groupID used in volume
+}
+
+// MountAuthMethod selects the credentials presented to MountAuthenticated() (see auth.go)
+type MountAuthMethod uint32
+
+const (
+	MountAuthNone        MountAuthMethod = iota // no credentials
+	MountAuthSecret                             // the volume's shared secret
+	MountAuthToken                              // a token (e.g. from Keystone) verified by the registered MountAuthenticator
+	MountAuthCertificate                        // a client certificate verified by the registered MountAuthenticator
+	MountAuthLocal                              // in-process caller (i.e. via Mount() or MountWithIDMap())
+)
+
+// MountCredentialsStruct is presented to MountAuthenticated() on behalf of a remote client
+type MountCredentialsStruct struct {
+	Method      MountAuthMethod
+	ClientAddr  string // IP address of the client as seen by the transport (if known)
+	Secret      string // used only if Method is MountAuthSecret
+	Token       string // used only if Method is MountAuthToken
+	Certificate []byte // used only if Method is MountAuthCertificate (DER-encoded)
+}
+
+// MountIdentityStruct is the identity granted to a mount (see Identity())
+type MountIdentityStruct struct {
+	Method    MountAuthMethod
+	Principal string // as returned by the MountAuthenticator (empty unless Method is MountAuthToken or MountAuthCertificate)
+	UserID    inode.InodeUserID
+	GroupID   inode.InodeGroupID
+}
+
+// MountAuthenticator verifies credentials presented to mount volumeName, returning the Principal,
+// UserID, and GroupID to grant (see RegisterMountAuthenticator())
+type MountAuthenticator func(volumeName string, credentials *MountCredentialsStruct) (identity MountIdentityStruct, err error)
+
 func Mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandle, err error) {
-	mountHandle, err = mount(volumeName, mountOptions)
+	mountHandle, err = mount(volumeName, mountOptions, nil, nil)
+	return
+}
+
+// MountWithIDMap is Mount() where the caller identities presented to mountHandle are mapped per idMap
+func MountWithIDMap(volumeName string, mountOptions MountOptions, idMap *IDMapStruct) (mountHandle MountHandle, err error) {
+	mountHandle, err = mount(volumeName, mountOptions, idMap, nil)
+	return
+}
+
+// MountAuthenticated is MountWithIDMap() (idMap may be nil) on behalf of a remote client presenting
+// credentials. It fails with PermDeniedError (EACCES) unless the volume's export policy admits them.
+func MountAuthenticated(volumeName string, mountOptions MountOptions, idMap *IDMapStruct, credentials *MountCredentialsStruct) (mountHandle MountHandle, err error) {
+	mountHandle, err = mountAuthenticated(volumeName, mountOptions, idMap, credentials)
+	return
+}
+
+// Unmount releases mountHandle once its in-flight operations have drained (see unmount.go). It fails
+// with DevBusyError (EBUSY) if any FileHandle opened via mountHandle remains open.
+func Unmount(mountHandle MountHandle) (err error) {
+	err = unmount(mountHandle)
+	return
+}
+
+// Shutdown takes every volume offline in an orderly fashion ahead of process exit (see shutdown.go): leases
+// are recalled, in-flight operations drained, and everything yet to be persisted made durable. Once called,
+// Mount() and operations via existing mounts fail with TryAgainError (EAGAIN).
+func Shutdown() (err error) {
+	err = shutdown()
 	return
 }
 
 type MountHandle interface {
 	Access(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, accessMode inode.InodeMode) (accessReturn bool)
+	AcquireLease(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, leaseType LeaseType, handler LeaseBreakHandler) (leaseID LeaseID, err error)
+	AddWatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, subtree bool, handler NotifyHandler) (watchID WatchID, err error)
+	Close(fileHandle FileHandle) (err error)
 	CallInodeToProvisionObject() (pPath string, err error)
 	Create(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error)
+	CreateUnlinked(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error)
+	DowngradeLease(leaseID LeaseID, leaseType LeaseType) (err error)
+	FetchOpStats() (opStats OpStats)
+	FetchWatchStats(watchID WatchID) (watchStats WatchStats, err error)
 	Flush(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error)
+	FlushDir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error)
 	Flock(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, lockCmd int32, inFlockStruct *FlockStruct) (outFlockStruct *FlockStruct, err error)
+	FlockByHandle(fileHandle FileHandle, lockCmd int32, inFlockStruct *FlockStruct) (outFlockStruct *FlockStruct, err error)
 	Getstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (stat Stat, err error)
+	GetstatByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct) (stat Stat, err error)
+	GetInodeFlags(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (flags inode.InodeFlags, err error)
+	GetLimits() (limits LimitsStruct)
+	GetNFS4ACL(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (acl inode.NFS4ACL, err error)
+	GetRetention(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (status RetentionStatus, err error)
+	GetStreamSize(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (size uint64, err error)
+	Identity() (identity MountIdentityStruct)
 	GetType(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeType inode.InodeType, err error)
 	GetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (value []byte, err error)
+	GetXAttrByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct, streamName string) (value []byte, err error)
 	IsDir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsDir bool, err error)
 	IsFile(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsFile bool, err error)
 	IsSymlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsSymlink bool, err error)
 	Link(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error)
+	LinkByInode(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error)
 	ListXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (streamNames []string, err error)
+	ListXAttrByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct) (streamNames []string, err error)
+	ListVersions(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (versions []FileVersion, err error)
 	Lookup(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string) (inodeNumber inode.InodeNumber, err error)
+	LookupMulti(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basenames []string) (inodeNumbers []inode.InodeNumber, errs []error, err error)
 	LookupPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (inodeNumber inode.InodeNumber, err error)
 	MiddlewareCoalesce(destPath string, elementPaths []string) (ino uint64, numWrites uint64, modificationTime uint64, err error)
+	MiddlewareCoalesceElements(destPath string, elements []CoalesceElementSpec, emitManifest bool) (ino uint64, numWrites uint64, modificationTime uint64, err error)
 	MiddlewareDelete(parentDir string, baseName string) (err error)
+	MiddlewareDeleteAsCaller(caller *MiddlewareCallerStruct, parentDir string, baseName string) (err error)
+	MiddlewareDeleteMulti(entries []DeleteMultiEntry) (errs []error)
+	MiddlewareDeleteMultiAsCaller(caller *MiddlewareCallerStruct, entries []DeleteMultiEntry) (errs []error)
+	MiddlewareFreezeContainer(vContainerName string, ttl time.Duration) (freezeID FreezeID, expiry time.Time, err error)
 	MiddlewareGetAccount(maxEntries uint64, marker string) (accountEnts []AccountEntry, err error)
+	MiddlewareGetAccountListing(maxEntries uint64, marker string, endMarker string, reverse bool) (accountEnts []AccountEntry, err error)
 	MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, prefix string) (containerEnts []ContainerEntry, err error)
-	MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, err error)
+	MiddlewareGetContainerDelimited(vContainerName string, maxEntries uint64, marker string, prefix string, delimiter string) (containerEnts []ContainerEntry, err error)
+	MiddlewareGetContainerRange(vContainerName string, maxEntries uint64, marker string, endMarker string, prefix string, delimiter string) (containerEnts []ContainerEntry, err error)
+	MiddlewareGetContainerListing(vContainerName string, maxEntries uint64, marker string, endMarker string, prefix string, delimiter string, reverse bool) (containerEnts []ContainerEntry, err error)
+	MiddlewareGetContainerCached(vContainerName string, maxEntries uint64, marker string, endMarker string, prefix string, delimiter string, reverse bool, maxStaleness time.Duration) (containerEnts []ContainerEntry, err error)
+	MiddlewareGetContainerShards(vContainerName string, maxShards uint64) (shards []ContainerShard, err error)
+	MiddlewareGetContainerACL(vContainerName string) (acl *ContainerACL, err error)
+	MiddlewareGetContainerRetention(vContainerName string) (policy *RetentionPolicy, err error)
+	MiddlewareGetContainerByToken(vContainerName string, maxEntries uint64, marker string, continuationToken string, prefix string) (containerEnts []ContainerEntry, nextContinuationToken string, err error)
+	MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, etag string, contentType string, err error)
+	MiddlewareGetObjectAsCaller(caller *MiddlewareCallerStruct, volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, etag string, contentType string, err error)
+	MiddlewareHeadAccount() (response HeadResponse, err error)
 	MiddlewareHeadResponse(entityPath string) (response HeadResponse, err error)
+	MiddlewareHeadMultiple(entityPaths []string) (responses []HeadResponse, errs []error)
 	MiddlewarePost(parentDir string, baseName string, newMetaData []byte, oldMetaData []byte) (err error)
+	MiddlewarePostAccount(newMetaData []byte, oldMetaData []byte) (err error)
 	MiddlewareMkdir(vContainerName string, vObjectPath string, metadata []byte) (mtime uint64, inodeNumber inode.InodeNumber, numWrites uint64, err error)
 	MiddlewarePutComplete(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error)
+	MiddlewarePutCompleteAsCaller(caller *MiddlewareCallerStruct, vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte, preconditions PutPreconditions) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error)
+	MiddlewarePutCompleteConditional(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte, preconditions PutPreconditions) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error)
 	MiddlewarePutContainer(containerName string, oldMetadata []byte, newMetadata []byte) (err error)
+	MiddlewareSetContainerACL(vContainerName string, acl *ContainerACL) (err error)
+	MiddlewareSetContainerRetention(vContainerName string, policy *RetentionPolicy) (err error)
+	MiddlewareThawContainer(vContainerName string, freezeID FreezeID) (err error)
 	Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (newDirInodeNumber inode.InodeNumber, err error)
+	Mknod(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, mode inode.InodeMode) (inodeNumber inode.InodeNumber, err error)
+	Open(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flags OpenFlags, shareMode ShareMode) (fileHandle FileHandle, err error)
+	OpenAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string, flags OpenFlags, shareMode ShareMode) (fileHandle FileHandle, err error)
+	OpenByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct, flags OpenFlags, shareMode ShareMode) (fileHandle FileHandle, err error)
+	OpenDurable(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (durableHandle DurableHandleStruct, err error)
+	OpenVersion(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, versionInodeNumber inode.InodeNumber) (fileHandle FileHandle, err error)
+	PinPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (pinnedBytes uint64, err error)
+	ReclaimLease(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, leaseID LeaseID, handler LeaseBreakHandler) (inodeNumber inode.InodeNumber, leaseType LeaseType, err error)
+	ReleaseLease(leaseID LeaseID) (err error)
+	ReleaseUnlinked(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error)
+	RemoveWatch(watchID WatchID) (err error)
 	RemoveXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (err error)
-	Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (err error)
+	Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags RenameFlags) (err error)
+	RenameAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcRelativePath string, dstDirInodeNumber inode.InodeNumber, dstRelativePath string, flags RenameFlags) (err error)
 	Read(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error)
+	ReadByHandle(fileHandle FileHandle, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error)
+	ReadInto(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, dst []byte, profiler *utils.Profiler) (size uint64, err error)
+	ReadIntoWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, dst []byte, profiler *utils.Profiler) (size uint64, err error)
+	ReadStream(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, offset uint64, length uint64) (buf []byte, err error)
+	ReadWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error)
 	Readdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevBasenameReturned string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, numEntries uint64, areMoreEntries bool, err error)
+	ReaddirByToken(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, continuationToken string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, nextContinuationToken string, areMoreEntries bool, err error)
 	ReaddirOne(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation) (entries []inode.DirEntry, err error)
 	ReaddirPlus(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevBasenameReturned string, maxEntries uint64, maxBufSize uint64) (dirEntries []inode.DirEntry, statEntries []Stat, numEntries uint64, areMoreEntries bool, err error)
 	ReaddirOnePlus(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation) (dirEntries []inode.DirEntry, statEntries []Stat, err error)
+	ReaddirMatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation, pattern string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, areMoreEntries bool, err error)
+	ReaddirPlusMatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation, pattern string, maxEntries uint64, maxBufSize uint64) (dirEntries []inode.DirEntry, statEntries []Stat, areMoreEntries bool, err error)
 	Readsymlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (target string, err error)
+	ResolvePathAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string) (inodeNumber inode.InodeNumber, err error)
 	Resize(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, newSize uint64) (err error)
+	ResizeStream(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, newSize uint64) (err error)
 	Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error)
+	SetInodeFlags(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flags inode.InodeFlags) (err error)
+	SetLegalHold(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, hold bool) (err error)
+	SetNFS4ACL(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, acl inode.NFS4ACL) (err error)
+	SetUmask(umask inode.InodeMode) (previousUmask inode.InodeMode)
 	Setstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, stat Stat) (err error)
+	SetstatAtomic(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, stat Stat) (rejected []StatKey, err error)
 	SetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, value []byte, flags int) (err error)
+	SetXAttrIfMatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, oldValue []byte, newValue []byte) (err error)
 	StatVfs() (statVFS StatVFS, err error)
+	StatVfsAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (statVFS StatVFS, err error)
 	Symlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, target string) (symlinkInodeNumber inode.InodeNumber, err error)
 	Unlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error)
+	UnlinkAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string, removeDir bool) (err error)
+	UnpinPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (err error)
 	Validate(inodeNumber inode.InodeNumber) (err error)
 	VolumeName() (volumeName string)
 	Write(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error)
+	WriteByHandle(fileHandle FileHandle, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error)
+	WriteStream(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, offset uint64, buf []byte) (err error)
+	WriteWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error)
+	Writev(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, segments []inode.WriteSegment, profiler *utils.Profiler) (size uint64, err error)
+	WritevWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, segments []inode.WriteSegment, profiler *utils.Profiler) (size uint64, err error)
 }
 
 // Utility functions
@@ -213,6 +675,107 @@ func ValidateVolume(volumeName string, stopChan chan bool, errChan chan error) {
 	errChan <- validateVolume(volumeName, stopChan)
 }
 
+// SetLimits adjusts the adjustable fields of the LimitsStruct (see GetLimits()) imposed by volumeName
+//
+// The remaining fields must match those currently reported. The adjustments last until the volume is
+// next brought up (or its configuration changed), at which point the [<volume-section>] options apply.
+func SetLimits(volumeName string, limits LimitsStruct) (err error) {
+	err = setLimits(volumeName, limits)
+	return
+}
+
+// AnalyzeReclaimable reports the space reclaimable in volumeName (see reclaim.go)
+//
+// The walk of the volume's namespace ends early should stopChan be signaled.
+func AnalyzeReclaimable(volumeName string, stopChan chan bool) (report ReclaimReport, err error) {
+	report, err = analyzeReclaimable(volumeName, stopChan)
+	return
+}
+
+// Reclaim compacts each of inodeNumbers (typically selected from ReclaimReport.Candidates)
+//
+// Reclaim returns early should stopChan be signaled or maxDuration (if non-zero) elapse.
+func Reclaim(volumeName string, inodeNumbers []inode.InodeNumber, maxDuration time.Duration, stopChan chan bool) (result ReclaimResult, err error) {
+	result, err = reclaim(volumeName, inodeNumbers, maxDuration, stopChan)
+	return
+}
+
+// UpgradeDirEntryTypes records the InodeType in each directory entry of volumeName lacking one (see dirent_type.go)
+//
+// The walk of the volume's namespace ends early should stopChan be signaled.
+func UpgradeDirEntryTypes(volumeName string, stopChan chan bool) (result DirEntryTypesUpgradeResult, err error) {
+	result, err = upgradeDirEntryTypes(volumeName, stopChan)
+	return
+}
+
+// FetchInodeHistory returns the recent operations upon inodeNumber in volumeName, oldest first (see history.go)
+func FetchInodeHistory(volumeName string, inodeNumber inode.InodeNumber) (entries []InodeHistoryEntry, err error) {
+	entries, err = fetchInodeHistory(volumeName, inodeNumber)
+	return
+}
+
+// FetchUsageTrend returns the recent usage samples of volumeName, oldest first (see trend.go)
+func FetchUsageTrend(volumeName string) (samples []UsageSample, err error) {
+	samples, err = fetchUsageTrend(volumeName)
+	return
+}
+
+// FetchVolumeOpStats returns the OpStats of volumeName and of each of its current mounts (see op_stats.go)
+func FetchVolumeOpStats(volumeName string) (volumeOpStats VolumeOpStats, err error) {
+	volumeOpStats, err = fetchVolumeOpStats(volumeName)
+	return
+}
+
+// RegisterUsageAlertHandler adds handler to those invoked upon each UsageAlert (see trend.go)
+func RegisterUsageAlertHandler(handler UsageAlertHandler) {
+	registerUsageAlertHandler(handler)
+}
+
+// VerifyVolume compares volumeName's files against listings of its PhysicalContainers (see verify.go)
+//
+// Neither the volume nor its PhysicalContainers are modified. The walk of the volume's namespace ends
+// early should stopChan be signaled.
+func VerifyVolume(volumeName string, stopChan chan bool) (report VerifyReport, err error) {
+	report, err = verifyVolume(volumeName, stopChan)
+	return
+}
+
+// CreateSnapshot records a snapshot of volumeName's current contents (see snapshot.go)
+func CreateSnapshot(volumeName string, name string) (snapshot Snapshot, err error) {
+	snapshot, err = createSnapshot(volumeName, name)
+	return
+}
+
+// DeleteSnapshot deletes the snapshot of volumeName identified by id (see snapshot.go)
+func DeleteSnapshot(volumeName string, id uint64) (err error) {
+	err = deleteSnapshot(volumeName, id)
+	return
+}
+
+// ListSnapshots returns the snapshots of volumeName in ID order (see snapshot.go)
+func ListSnapshots(volumeName string) (snapshots []Snapshot, err error) {
+	snapshots, err = listSnapshots(volumeName)
+	return
+}
+
+// ListTrash returns the inodes in volumeName's trash in InodeNumber order (see trash.go)
+func ListTrash(volumeName string) (entries []TrashEntry, err error) {
+	entries, err = listTrash(volumeName)
+	return
+}
+
+// RestoreTrash returns inodeNumber from volumeName's trash to the name from which it was removed (see trash.go)
+func RestoreTrash(volumeName string, inodeNumber inode.InodeNumber) (err error) {
+	err = restoreTrash(volumeName, inodeNumber)
+	return
+}
+
+// PurgeTrash destroys inodeNumber in volumeName's trash (see trash.go)
+func PurgeTrash(volumeName string, inodeNumber inode.InodeNumber) (err error) {
+	err = purgeTrash(volumeName, inodeNumber)
+	return
+}
+
 func AccountNameToVolumeName(accountName string) (volumeName string, ok bool) {
 	volumeName, ok = inode.AccountNameToVolumeName(accountName)
 	stats.IncrementOperations(&stats.FsAcctToVolumeOps)
@@ -8,6 +8,7 @@ import (
 	"math"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -32,6 +33,8 @@ type symlinkFollowState struct {
 type dirAndFileName struct {
 	dirName  string
 	fileName string
+	offset   uint64 // of the range of a Coalesce() element to include
+	length   uint64 // of the range of a Coalesce() element to include (0 == through its end)
 }
 
 // this has to be a named type to be a method receiver
@@ -212,19 +215,52 @@ func chunkedPutConnectionPoolStarvationCallback() {
 	inFlightFileInodeData.volStruct.inFlightFileInodeDataFlusher(inFlightFileInodeData.InodeNumber)
 }
 
-func mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandle, err error) {
+// mount is called with nil credentials on behalf of (trusted) in-process callers (see auth.go).
+func mount(volumeName string, mountOptions MountOptions, idMap *IDMapStruct, credentials *MountCredentialsStruct) (mountHandle MountHandle, err error) {
 	var (
+		identity  MountIdentityStruct
 		mS        *mountStruct
 		ok        bool
 		volStruct *volumeStruct
 	)
 
-	globals.Lock()
+	err = validateATimeMountOptions(mountOptions)
+	if nil != err {
+		return
+	}
+
+	err = validateIDMap(idMap)
+	if nil != err {
+		return
+	}
 
+	globals.Lock()
 	volStruct, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
 	if !ok {
 		err = fmt.Errorf("Unknown volumeName passed to mount(): \"%s\"", volumeName)
 		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	// Authenticate without holding globals.Lock() as a MountAuthenticator may take a while
+
+	identity, err = volStruct.authenticateMount(credentials)
+	if nil != err {
+		return
+	}
+
+	globals.Lock()
+
+	if globals.shuttingDown {
+		err = blunder.NewError(blunder.TryAgainError, "mount() of \"%s\" refused as shutting down", volumeName)
+		globals.Unlock()
+		return
+	}
+
+	if volStruct != globals.volumeMap[volumeName] {
+		err = fmt.Errorf("volumeName passed to mount() went offline: \"%s\"", volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
 		globals.Unlock()
 		return
 	}
@@ -234,8 +270,12 @@ func mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandl
 	mS = &mountStruct{
 		id:        globals.lastMountID,
 		options:   mountOptions,
+		idMap:     idMap,
 		volStruct: volStruct,
+		identity:  identity,
 	}
+	mS.initGate()
+	mS.initOpStats()
 
 	globals.mountMap[mS.id] = mS
 
@@ -251,33 +291,98 @@ func mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandl
 	return
 }
 
+// checkWritable returns ReadOnlyError (EROFS) if the mount is read-only.
+func (mS *mountStruct) checkWritable() (err error) {
+	if 0 != mS.options&MountReadOnly {
+		stats.IncrementOperations(&stats.FsReadOnlyDeniedOps)
+		err = blunder.NewError(blunder.ReadOnlyError, "EROFS")
+	}
+	return
+}
+
 func (mS *mountStruct) Access(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, accessMode inode.InodeMode) (accessReturn bool) {
+	if nil != mS.enterOp() {
+		return false
+	}
+	defer mS.exitOp(nil)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if (0 != accessMode&inode.W_OK) && (0 != mS.options&MountReadOnly) {
+		accessReturn = false
+		return
+	}
+	if isSnapshotInodeNumber(inodeNumber) {
+		accessReturn = mS.snapshotAccess(userID, groupID, otherGroupIDs, inodeNumber, accessMode)
+		return
+	}
+	if mS.noExecDenies(inodeNumber, accessMode) {
+		accessReturn = false
+		return
+	}
 	accessReturn = mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, accessMode)
 	return
 }
 
 func (mS *mountStruct) CallInodeToProvisionObject() (pPath string, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
 	pPath, err = mS.volStruct.VolumeHandle.ProvisionObject()
 	stats.IncrementOperations(&stats.FsProvisionObjOps)
 	return
 }
 
 func (mS *mountStruct) Create(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error) {
-	err = validateBaseName(basename)
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	fileInodeNumber, err = mS.create(userID, groupID, otherGroupIDs, dirInodeNumber, basename, filePerm)
+	return
+}
+
+// create is Create() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) create(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() { mS.noteNameHistory(dirInodeNumber, historyOpCreate, basename, fileInodeNumber, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterEntries(freezeEntryStruct{dirInodeNumber, basename}) // see freeze.go
+	defer exitInodes()
+
+	err = mS.volStruct.validateName(basename)
 	if err != nil {
 		return 0, err
 	}
 
-	// Lock the directory inode before doing the link
-	dirInodeLock, err := mS.volStruct.initInodeLock(dirInodeNumber, nil)
+	// The RetentionPolicy (if any) of the directory's container must be found before locking it (see retention.go)
+	retentionPolicy, err := mS.volStruct.fetchRetentionPolicyOf(dirInodeNumber)
 	if err != nil {
 		return 0, err
 	}
-	err = dirInodeLock.WriteLock()
+
+	// Lock the directory inode (or just basename's shard of it) before doing the link
+	dirEntryLock, err := mS.volStruct.getDirEntryLock(dirInodeNumber, basename, nil)
 	if err != nil {
 		return 0, err
 	}
-	defer dirInodeLock.Unlock()
+	defer dirEntryLock.Unlock()
 
 	if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
 		return 0, blunder.NewError(blunder.NotFoundError, "ENOENT")
@@ -287,8 +392,27 @@ func (mS *mountStruct) Create(userID inode.InodeUserID, groupID inode.InodeGroup
 	}
 
 	// create the file and add it to the directory
-	fileInodeNumber, err = mS.volStruct.VolumeHandle.CreateFile(filePerm, userID, groupID)
+	fileGroupID, fileMode := mS.inheritFromDir(userID, groupID, otherGroupIDs, dirInodeNumber, inode.FileType, mS.createMode(filePerm))
+	fileInodeNumber, err = mS.volStruct.VolumeHandle.CreateFile(fileMode, userID, fileGroupID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = mS.volStruct.stampRetention(fileInodeNumber, retentionPolicy, false)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(fileInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed stampRetention() in fs.Create", fileInodeNumber)
+		}
+		return 0, err
+	}
+
+	err = mS.volStruct.detectContentTypeByExtension(fileInodeNumber, basename) // see content_type.go
 	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(fileInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed detectContentTypeByExtension() in fs.Create", fileInodeNumber)
+		}
 		return 0, err
 	}
 
@@ -301,11 +425,33 @@ func (mS *mountStruct) Create(userID inode.InodeUserID, groupID inode.InodeGroup
 		return 0, err
 	}
 
+	mS.volStruct.notifyName(NotifyCreate, dirInodeNumber, basename, fileInodeNumber)
+
 	stats.IncrementOperations(&stats.FsCreateOps)
 	return fileInodeNumber, nil
 }
 
 func (mS *mountStruct) Flush(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.flush(userID, groupID, otherGroupIDs, inodeNumber)
+	return
+}
+
+// flush is Flush() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) flush(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		return // nothing within a snapshot is ever dirty
+	}
+
+	defer func() { mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpFlush}, err) }()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -326,10 +472,58 @@ func (mS *mountStruct) Flush(userID inode.InodeUserID, groupID inode.InodeGroupI
 	err = mS.volStruct.VolumeHandle.Flush(inodeNumber, false)
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
 
+	if nil == err {
+		sniffErr := mS.volStruct.sniffContentType(inodeNumber) // see content_type.go
+		if nil != sniffErr {
+			logger.WarnfWithError(sniffErr, "couldn't sniff Content-Type of inode %v in fs.Flush", inodeNumber)
+		}
+	}
+
 	stats.IncrementOperations(&stats.FsFlushOps)
 	return
 }
 
+// FlushDir returns once all previously completed namespace operations (e.g. Create(), Rename(), Unlink())
+// in inodeNumber are durable.
+//
+// File data is not included (see Flush()).
+func (mS *mountStruct) FlushDir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		return // nothing within a snapshot is ever dirty
+	}
+
+	inodeLock, err := mS.volStruct.getReadLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		inodeLock.Unlock()
+		return blunder.NewError(blunder.NotFoundError, "ENOENT")
+	}
+
+	// The lock is not held across the (potentially lengthy) checkpoint as operations that complete
+	// concurrently with FlushDir() need not be made durable by it
+
+	inodeLock.Unlock()
+
+	err = mS.volStruct.VolumeHandle.FlushDir(inodeNumber)
+	if err != nil {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsFlushDirOps)
+	return
+}
+
 func (mS *mountStruct) getFileLockList(inodeNumber inode.InodeNumber) (flockList *list.List) {
 	mS.volStruct.Lock()
 	defer mS.volStruct.Unlock()
@@ -564,6 +758,24 @@ func (mS *mountStruct) fileUnlock(inodeNumber inode.InodeNumber, inFlock *FlockS
 // Implements file locking conforming to fcntl(2) locking description. F_SETLKW is not implemented. Supports F_SETLW and F_GETLW.
 // whence: FS supports only SEEK_SET - starting from 0, since it does not manage file handles, caller is expected to supply the start and length relative to offset ZERO.
 func (mS *mountStruct) Flock(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, lockCmd int32, inFlock *FlockStruct) (outFlock *FlockStruct, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	outFlock, err = mS.flock(userID, groupID, otherGroupIDs, inodeNumber, lockCmd, inFlock)
+	return
+}
+
+// flock is Flock() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) flock(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, lockCmd int32, inFlock *FlockStruct) (outFlock *FlockStruct, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpFlock, Flags: uint64(lockCmd)}, err)
+	}()
+
 	outFlock = inFlock
 
 	if lockCmd == syscall.F_SETLKW {
@@ -612,11 +824,20 @@ func (mS *mountStruct) Flock(userID inode.InodeUserID, groupID inode.InodeGroupI
 			err = mS.fileUnlock(inodeNumber, inFlock)
 		} else if inFlock.Type == syscall.F_WRLCK || inFlock.Type == syscall.F_RDLCK {
 			err = mS.fileLockInsert(inodeNumber, inFlock)
+			if nil == err {
+				mS.noteFlockOwner(inodeNumber, inFlock.Pid)
+			}
 		} else {
 			err = blunder.NewError(blunder.InvalidArgError, "EINVAL")
 			return
 		}
 
+		if nil == err {
+			mS.volStruct.reclaimFlocks(inodeNumber, inFlock.Pid) // see volume_state.go
+			mS.volStruct.scheduleVolumeStateExport()
+			mS.volStruct.noteFlockChange()
+		}
+
 		break
 
 	default:
@@ -633,19 +854,24 @@ func (mS *mountStruct) getstatHelper(inodeNumber inode.InodeNumber, callerID dlm
 	if err != nil {
 		return
 	}
-	if !dlm.IsLockHeld(lockID, callerID, dlm.ANYLOCK) {
+	if !dlm.IsLockHeldInDomain(mS.volStruct.volumeName, lockID, callerID, dlm.ANYLOCK) {
 		err = fmt.Errorf("%s: inode %v lock must be held before calling", utils.GetFnName(), inodeNumber)
 		return nil, blunder.AddError(err, blunder.NotFoundError)
 	}
 
-	stat = make(map[StatKey]uint64)
-
 	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
 
 	if err != nil {
 		return nil, err
 	}
 
+	return statFromMetadata(inodeNumber, metadata), nil
+}
+
+// statFromMetadata converts the metadata of inode inodeNumber into a Stat.
+func statFromMetadata(inodeNumber inode.InodeNumber, metadata *inode.MetadataStruct) (stat Stat) {
+	stat = make(map[StatKey]uint64)
+
 	stat[StatCRTime] = uint64(metadata.CreationTime.UnixNano())
 	stat[StatMTime] = uint64(metadata.ModificationTime.UnixNano())
 	stat[StatCTime] = uint64(metadata.AttrChangeTime.UnixNano())
@@ -658,11 +884,33 @@ func (mS *mountStruct) getstatHelper(inodeNumber inode.InodeNumber, callerID dlm
 	stat[StatUserID] = uint64(metadata.UserID)
 	stat[StatGroupID] = uint64(metadata.GroupID)
 	stat[StatNumWrites] = metadata.NumWrites
+	stat[StatBlocks] = (metadata.AllocatedBytes + 511) / 512
+	stat[StatBlkSize] = FsOptimalTransferSize
+	stat[StatChangeCount] = metadata.ChangeCount
 
-	return stat, nil
+	return
 }
 
 func (mS *mountStruct) Getstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (stat Stat, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	stat, err = mS.getstat(userID, groupID, otherGroupIDs, inodeNumber)
+	return
+}
+
+// getstat is Getstat() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) getstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (stat Stat, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		stats.IncrementOperations(&stats.FsGetstatOps)
+		return mS.snapshotGetstat(inodeNumber)
+	}
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -684,7 +932,7 @@ func (mS *mountStruct) getTypeHelper(inodeNumber inode.InodeNumber, callerID dlm
 	if err != nil {
 		return
 	}
-	if !dlm.IsLockHeld(lockID, callerID, dlm.ANYLOCK) {
+	if !dlm.IsLockHeldInDomain(mS.volStruct.volumeName, lockID, callerID, dlm.ANYLOCK) {
 		err = fmt.Errorf("%s: inode %v lock must be held before calling.", utils.GetFnName(), inodeNumber)
 		err = blunder.AddError(err, blunder.NotFoundError)
 		return
@@ -699,6 +947,19 @@ func (mS *mountStruct) getTypeHelper(inodeNumber inode.InodeNumber, callerID dlm
 }
 
 func (mS *mountStruct) GetType(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeType inode.InodeType, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		stats.IncrementOperations(&stats.FsGetTypeOps)
+		return mS.snapshotGetType(inodeNumber)
+	}
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -714,6 +975,20 @@ func (mS *mountStruct) GetType(userID inode.InodeUserID, groupID inode.InodeGrou
 }
 
 func (mS *mountStruct) GetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (value []byte, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	value, err = mS.getXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName)
+	return
+}
+
+// getXAttr is GetXAttr() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) getXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (value []byte, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -733,6 +1008,16 @@ func (mS *mountStruct) GetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 		return
 	}
 
+	if isReservedStream(inodeNumber, streamName) {
+		err = blunder.NewError(blunder.StreamNotFound, "ENODATA")
+		return
+	}
+
+	err = mS.checkXAttrNamespace(userID, inodeNumber, streamName, false)
+	if nil != err {
+		return
+	}
+
 	value, err = mS.volStruct.VolumeHandle.GetStream(inodeNumber, streamName)
 	if err != nil {
 		// Did not find the requested stream. However this isn't really an error since
@@ -745,6 +1030,20 @@ func (mS *mountStruct) GetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 }
 
 func (mS *mountStruct) IsDir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsDir bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	inodeIsDir, err = mS.isDir(userID, groupID, otherGroupIDs, inodeNumber)
+	return
+}
+
+// isDir is IsDir() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) isDir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsDir bool, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -761,7 +1060,7 @@ func (mS *mountStruct) IsDir(userID inode.InodeUserID, groupID inode.InodeGroupI
 	if err != nil {
 		return
 	}
-	if !dlm.IsLockHeld(lockID, inodeLock.GetCallerID(), dlm.ANYLOCK) {
+	if !dlm.IsLockHeldInDomain(mS.volStruct.volumeName, lockID, inodeLock.GetCallerID(), dlm.ANYLOCK) {
 		err = fmt.Errorf("%s: inode %v lock must be held before calling", utils.GetFnName(), inodeNumber)
 		return false, blunder.AddError(err, blunder.NotFoundError)
 	}
@@ -774,6 +1073,14 @@ func (mS *mountStruct) IsDir(userID inode.InodeUserID, groupID inode.InodeGroupI
 }
 
 func (mS *mountStruct) IsFile(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsFile bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -793,6 +1100,14 @@ func (mS *mountStruct) IsFile(userID inode.InodeUserID, groupID inode.InodeGroup
 }
 
 func (mS *mountStruct) IsSymlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsSymlink bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -812,11 +1127,35 @@ func (mS *mountStruct) IsSymlink(userID inode.InodeUserID, groupID inode.InodeGr
 }
 
 func (mS *mountStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.link(userID, groupID, otherGroupIDs, dirInodeNumber, basename, targetInodeNumber)
+	return
+}
+
+// link is Link() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) link(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() { mS.noteNameHistory(dirInodeNumber, historyOpLink, basename, targetInodeNumber, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterEntries(freezeEntryStruct{dirInodeNumber, basename}, freezeEntryStruct{inodeNumber: targetInodeNumber}) // see freeze.go
+	defer exitInodes()
+
 	var (
 		inodeType inode.InodeType
 	)
 
-	err = validateBaseName(basename)
+	err = mS.volStruct.validateName(basename)
 	if err != nil {
 		return
 	}
@@ -893,11 +1232,29 @@ func (mS *mountStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupID
 		mS.volStruct.untrackInFlightFileInodeData(targetInodeNumber, false)
 	}
 
+	if nil == err {
+		mS.volStruct.notifyName(NotifyCreate, dirInodeNumber, basename, targetInodeNumber)
+	}
+
 	stats.IncrementOperations(&stats.FsLinkOps)
 	return err
 }
 
 func (mS *mountStruct) ListXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (streamNames []string, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	streamNames, err = mS.listXAttr(userID, groupID, otherGroupIDs, inodeNumber)
+	return
+}
+
+// listXAttr is ListXAttr() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) listXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (streamNames []string, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -925,13 +1282,30 @@ func (mS *mountStruct) ListXAttr(userID inode.InodeUserID, groupID inode.InodeGr
 		return
 	}
 
-	streamNames = make([]string, len(metadata.InodeStreamNameSlice))
-	copy(streamNames, metadata.InodeStreamNameSlice)
+	streamNames = make([]string, 0, len(metadata.InodeStreamNameSlice))
+	for _, streamName := range metadata.InodeStreamNameSlice {
+		if !isReservedStream(inodeNumber, streamName) && xattrListable(userID, streamName) {
+			streamNames = append(streamNames, streamName)
+		}
+	}
 	stats.IncrementOperations(&stats.FsListXattrOps)
 	return
 }
 
 func (mS *mountStruct) Lookup(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string) (inodeNumber inode.InodeNumber, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(dirInodeNumber) {
+		stats.IncrementOperations(&stats.FsLookupOps)
+		return mS.snapshotLookup(userID, groupID, otherGroupIDs, dirInodeNumber, basename)
+	}
+
 	dirInodeLock, err := mS.volStruct.initInodeLock(dirInodeNumber, nil)
 	if err != nil {
 		return
@@ -949,69 +1323,140 @@ func (mS *mountStruct) Lookup(userID inode.InodeUserID, groupID inode.InodeGroup
 	}
 
 	inodeNumber, err = mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if (nil == err) && ("." != basename) && (".." != basename) {
+		mS.volStruct.noteName(inodeNumber, dirInodeNumber, basename)
+	}
+	if (nil != err) && (inode.RootDirInodeNumber == dirInodeNumber) && (SnapshotDirName == basename) && blunder.Is(err, blunder.NotFoundError) {
+		inodeNumber, err = SnapshotDirInodeNumber, nil
+	}
 	stats.IncrementOperations(&stats.FsLookupOps)
 	return inodeNumber, err
 }
 
-func (mS *mountStruct) LookupPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (inodeNumber inode.InodeNumber, err error) {
-	stats.IncrementOperations(&stats.FsPathLookupOps)
-
-	// In the special case of a fullpath starting with "/", the path segment splitting above
-	// results in a first segment that still begins with "/". Because this is not recognized
-	// as a real path segment, by the underlying code, we have trouble looking it up.
-	//
-	// This is a hack to work around this case until I figure out a better way.
-	newfullpath := strings.TrimPrefix(fullpath, "/")
-	if strings.Compare(fullpath, newfullpath) != 0 {
-		fullpath = newfullpath
+// LookupMulti resolves each of basenames within dirInodeNumber as Lookup() would, returning the outcome of
+// each in inodeNumbers & errs (nil == found), but taking the directory's read lock (and checking access to
+// it) only once. Only a failure to access the directory itself is returned in err.
+func (mS *mountStruct) LookupMulti(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basenames []string) (inodeNumbers []inode.InodeNumber, errs []error, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
 	}
+	defer mS.exitOp(&err)
 
-	pathSegments := strings.Split(path.Clean(fullpath), "/")
-
-	cursorInodeNumber := inode.RootDirInodeNumber
-	for _, segment := range pathSegments {
-		cursorInodeLock, err1 := mS.volStruct.initInodeLock(cursorInodeNumber, nil)
-		if err = err1; err != nil {
-			return
-		}
-		err = cursorInodeLock.ReadLock()
-		if err != nil {
-			return
-		}
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
 
-		if !mS.volStruct.VolumeHandle.Access(cursorInodeNumber, userID, groupID, otherGroupIDs, inode.X_OK) {
-			cursorInodeLock.Unlock()
-			err = blunder.NewError(blunder.PermDeniedError, "EACCES")
-			return
-		}
+	stats.IncrementOperations(&stats.FsLookupMultiOps)
 
-		cursorInodeNumber, err = mS.volStruct.VolumeHandle.Lookup(cursorInodeNumber, segment)
-		cursorInodeLock.Unlock()
+	inodeNumbers = make([]inode.InodeNumber, len(basenames))
+	errs = make([]error, len(basenames))
 
-		if err != nil {
-			return cursorInodeNumber, err
+	if isSnapshotInodeNumber(dirInodeNumber) {
+		for i, basename := range basenames {
+			inodeNumbers[i], errs[i] = mS.snapshotLookup(userID, groupID, otherGroupIDs, dirInodeNumber, basename)
 		}
+		return
 	}
 
-	return cursorInodeNumber, nil
-}
+	dirInodeLock, err := mS.volStruct.initInodeLock(dirInodeNumber, nil)
+	if err != nil {
+		return
+	}
+	dirInodeLock.ReadLock()
+	defer dirInodeLock.Unlock()
 
-func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string) (ino uint64, numWrites uint64, modificationTime uint64, err error) {
-	// it'll hold a dir lock and a file lock for each element path, plus a lock on the destination dir and the root dir
-	heldLocks := make([]*dlm.RWLockStruct, 0, 2*len(elementPaths)+2)
-	defer func() {
-		for _, lock := range heldLocks {
-			if lock != nil {
-				lock.Unlock()
-			}
+	if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.X_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	for i, basename := range basenames {
+		inodeNumbers[i], errs[i] = mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, basename)
+		if (nil == errs[i]) && ("." != basename) && (".." != basename) {
+			mS.volStruct.noteName(inodeNumbers[i], dirInodeNumber, basename)
 		}
-	}()
+		if (nil != errs[i]) && (inode.RootDirInodeNumber == dirInodeNumber) && (SnapshotDirName == basename) && blunder.Is(errs[i], blunder.NotFoundError) {
+			inodeNumbers[i], errs[i] = SnapshotDirInodeNumber, nil
+		}
+	}
+
+	return
+}
+
+// LookupPath resolves fullpath (relative to the root directory) as does resolvePathForRead(), following
+// symlinks (per the volume's policy; see symlink_policy.go), but also requiring search (X_OK) permission on each directory traversed.
+func (mS *mountStruct) LookupPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (inodeNumber inode.InodeNumber, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	inodeNumber, err = mS.lookupPath(userID, groupID, otherGroupIDs, fullpath)
+	return
+}
+
+// lookupPath is LookupPath() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) lookupPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (inodeNumber inode.InodeNumber, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
 
-	elementDirAndFileNames := make(dirAndFileNameSlice, 0, len(elementPaths))
-	coalesceElements := make([]inode.CoalesceElement, 0, len(elementPaths))
+	stats.IncrementOperations(&stats.FsPathLookupOps)
 
-	for _, path := range elementPaths {
-		dirName, fileName := filepath.Split(path)
+	inodeNumber, err = mS.lookupPathAt(userID, groupID, otherGroupIDs, inode.RootDirInodeNumber, fullpath)
+	return
+}
+
+func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string) (ino uint64, numWrites uint64, modificationTime uint64, err error) {
+	elements := make([]CoalesceElementSpec, len(elementPaths))
+	for i, path := range elementPaths {
+		elements[i].Path = path
+	}
+
+	ino, numWrites, modificationTime, err = mS.MiddlewareCoalesceElements(destPath, elements, false)
+	return
+}
+
+// MiddlewareCoalesceElements is MiddlewareCoalesce() including just the specified range of each element. Should
+// emitManifest be true, an SLO-style manifest of the elements is left in the combined file's CoalesceManifestStream
+// (see coalesce_manifest.go).
+func (mS *mountStruct) MiddlewareCoalesceElements(destPath string, elements []CoalesceElementSpec, emitManifest bool) (ino uint64, numWrites uint64, modificationTime uint64, err error) {
+	vContainerNames := make([]string, 0, 1+len(elements))
+	vContainerNames = append(vContainerNames, containerOfPath(destPath, ""))
+	for _, element := range elements {
+		vContainerNames = append(vContainerNames, containerOfPath(element.Path, ""))
+	}
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(vContainerNames...) // see freeze.go
+	defer exitContainers()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.validatePathNames(destPath)
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.admitHeavyOp()
+	if nil != err {
+		return
+	}
+	defer mS.volStruct.releaseHeavyOp()
+
+	elementDirAndFileNames := make(dirAndFileNameSlice, 0, len(elements))
+
+	for _, element := range elements {
+		dirName, fileName := filepath.Split(element.Path)
 		if dirName == "" {
 			err = fmt.Errorf("Files to coalesce must not be in the root directory")
 			return
@@ -1025,6 +1470,8 @@ func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string
 		elementDirAndFileNames = append(elementDirAndFileNames, dirAndFileName{
 			dirName:  dirName,
 			fileName: fileName,
+			offset:   element.Offset,
+			length:   element.Length,
 		})
 	}
 
@@ -1037,61 +1484,215 @@ func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string
 	}
 	destDirName = destDirName[0 : len(destDirName)-1] // chop off trailing slash
 
-	// We lock things in whatever order the caller provides them. To protect against deadlocks with other concurrent
-	// calls to this function, we first obtain a write lock on the root inode.
-	//
-	// One might think there's some way to sort these paths and do something smarter, but then one would remember that
-	// symlinks exist and so one cannot do anything useful with paths. For example, given two directory paths, which is
-	// more deeply nested, a/b/c or d/e/f/g/h? It might be the first one, if b is a symlink to b1/b2/b3/b4/b5, or it
-	// might not.
-	//
-	// This function is called infrequently enough that we can probably get away with the big heavy lock without causing
-	// too much trouble.
+	// Both the elements consumed and any file replaced are about to change (see coherence.go)
+	for _, element := range elements {
+		mS.breakLeasesOfPath(element.Path, true)
+	}
+	mS.breakLeasesOfPath(destPath, true)
+
+	// The coalesced file is committed at once to any RetentionPolicy of its container (see retention.go)
+	retentionPolicy, err := mS.fetchContainerRetentionByName(strings.SplitN(destDirName, "/", 2)[0])
+	if nil != err {
+		return
+	}
+
+	// We need write locks on the destination directory plus each element's directory and file. Paths tell us nothing
+	// about the order in which to lock these (symlinks may make a/b/c more deeply nested than d/e/f/g/h), so we first
+	// resolve every path, holding no locks once done, and then lock the resulting inodes in inode number order. Only
+	// the first lock is waited for; should any other be unavailable, or should any path no longer resolve as it did
+	// once all the locks are held, we drop them all and start over (see retry.go).
 	callerID := dlm.GenerateCallerID()
 
-	rootDirInodeLock, err := mS.volStruct.getWriteLock(inode.RootDirInodeNumber, callerID)
-	if err != nil {
+	var (
+		coalesceElements   []inode.CoalesceElement
+		destDirInodeNumber inode.InodeNumber
+		heldLocks          []*dlm.RWLockStruct
+	)
+
+	releaseLocks := func() {
+		for _, lock := range heldLocks {
+			lock.Unlock()
+		}
+		heldLocks = nil
+	}
+
+	err = mS.volStruct.retryLockConflicts(func() (retriable bool, err error) {
+		destDirInodeNumber, err = mS.makeCoalesceDestDir(destDirName, callerID)
+		if nil != err {
+			return
+		}
+
+		coalesceElements, err = mS.resolveCoalesceElements(elementDirAndFileNames, callerID)
+		if nil != err {
+			return
+		}
+
+		inodeNumbersToLock := make([]inode.InodeNumber, 0, 1+2*len(coalesceElements))
+		inodeNumbersToLock = append(inodeNumbersToLock, destDirInodeNumber)
+		for _, coalesceElement := range coalesceElements {
+			inodeNumbersToLock = append(inodeNumbersToLock, coalesceElement.ContainingDirectoryInodeNumber, coalesceElement.ElementInodeNumber)
+		}
+		sort.Slice(inodeNumbersToLock, func(i int, j int) bool { return inodeNumbersToLock[i] < inodeNumbersToLock[j] })
+
+		heldLocks = make([]*dlm.RWLockStruct, 0, len(inodeNumbersToLock))
+
+		for i, inodeNumber := range inodeNumbersToLock {
+			if (0 < i) && (inodeNumber == inodeNumbersToLock[i-1]) {
+				continue
+			}
+
+			lock, lockErr := mS.volStruct.initInodeLock(inodeNumber, callerID)
+			if nil != lockErr {
+				releaseLocks()
+				err = lockErr
+				return
+			}
+			if 0 == len(heldLocks) {
+				lockErr = lock.WriteLock()
+			} else {
+				lockErr = lock.TryWriteLock()
+			}
+			if nil != lockErr {
+				releaseLocks()
+				err = lockErr
+				retriable = blunder.Is(err, blunder.TryAgainError)
+				return
+			}
+			heldLocks = append(heldLocks, lock)
+		}
+
+		// Now that we hold every lock, make sure the paths still lead where they did
+
+		err = mS.revalidateCoalescePaths(destDirName, destDirInodeNumber, elementDirAndFileNames, coalesceElements, callerID)
+		if nil != err {
+			releaseLocks()
+			retriable = blunder.Is(err, blunder.TryAgainError)
+			return
+		}
+
+		return
+	})
+	if nil != err {
 		return
 	}
-	heldLocks = append(heldLocks, rootDirInodeLock)
+	defer releaseLocks()
 
-	// Walk the path one directory at a time, stopping when we either encounter a missing path component or when we've
-	// gone through the whole path.
-	destDirPathComponents := strings.Split(destDirName, "/")
+	// Neither the elements consumed nor any file replaced may be protected (see retention.go & inode_flags.go)
+	for _, coalesceElement := range coalesceElements {
+		err = mS.volStruct.checkRetention(coalesceElement.ElementInodeNumber)
+		if nil == err {
+			err = mS.volStruct.checkInodeFlags(coalesceElement.ElementInodeNumber) // see inode_flags.go
+		}
+		if nil != err {
+			return
+		}
+	}
+	err = mS.volStruct.checkRetentionOfName(destDirInodeNumber, destFileName)
+	if nil == err {
+		err = mS.volStruct.checkInodeFlagsOfName(destDirInodeNumber, destFileName)
+	}
+	if nil != err {
+		return
+	}
 
-	cursorInodeNumber := inode.RootDirInodeNumber
-	var cursorInodeLock *dlm.RWLockStruct // deliberately starts as nil; we have a lock on the root dir already
+	// Nor may any be open denying their deletion (see share_mode.go)
+	for _, coalesceElement := range coalesceElements {
+		err = mS.volStruct.checkShareModes(coalesceElement.ElementInodeNumber, ShareDelete, 0)
+		if nil != err {
+			return
+		}
+	}
+	err = mS.volStruct.checkShareModesOfName(destDirInodeNumber, destFileName, ShareDelete)
+	if nil != err {
+		return
+	}
 
-	defer func() {
-		if cursorInodeLock != nil {
-			cursorInodeLock.Unlock()
+	// The manifest must be made before the elements (and so their sizes) are consumed
+	var manifest []byte
+	if emitManifest {
+		manifest, err = mS.volStruct.makeCoalesceManifest(elements, coalesceElements)
+		if nil != err {
+			return
 		}
-	}()
+	}
+
+	// We've now jumped through all the requisite hoops to get the required locks, so now we can call inode.Coalesce and
+	// do something useful
+	destInodeNumber, mtime, numWrites, err := mS.volStruct.VolumeHandle.Coalesce(destDirInodeNumber, destFileName, coalesceElements)
+	if (nil == err) && emitManifest {
+		err = mS.volStruct.VolumeHandle.PutStream(destInodeNumber, CoalesceManifestStream, manifest)
+	}
+	if nil == err {
+		err = mS.volStruct.stampRetention(destInodeNumber, retentionPolicy, true)
+	}
+	if nil == err {
+		for _, coalesceElement := range coalesceElements {
+			mS.volStruct.notifyName(NotifyUnlink, coalesceElement.ContainingDirectoryInodeNumber, coalesceElement.ElementName, coalesceElement.ElementInodeNumber)
+			mS.volStruct.forgetInodeIfDestroyed(coalesceElement.ElementInodeNumber) // see coherence.go
+		}
+		mS.volStruct.notifyName(NotifyCreate, destDirInodeNumber, destFileName, destInodeNumber)
+	}
+	ino = uint64(destInodeNumber)
+	modificationTime = uint64(mtime.UnixNano())
+	return
+}
 
-	// Resolve as much of the path as exists
-	for len(destDirPathComponents) > 0 {
-		pathComponent := destDirPathComponents[0]
+// makeCoalesceDestDir resolves destDirName, creating any missing directories along the way, returning the
+// directory's inode number. No locks are held upon return.
+//
+// Each directory is created while holding only its parent's lock, so this needs no particular lock ordering.
+func (mS *mountStruct) makeCoalesceDestDir(destDirName string, callerID dlm.CallerID) (destDirInodeNumber inode.InodeNumber, err error) {
+	cursorInodeNumber := inode.RootDirInodeNumber
+
+	for _, pathComponent := range strings.Split(destDirName, "/") {
 		// We have to look up the dirent ourselves instead of letting resolvePath do it so we can distinguish between
 		// the following cases:
 		//
-		// (A) dirent doesn't exist, in which case we break out of this loop and start creating directories
+		// (A) dirent doesn't exist, in which case we make the directory
 		//
 		// (B) dirent does exist, but references a broken symlink, so resolvePath returns NotFoundError, in which case
 		// we return an error
-		_, err1 := mS.volStruct.VolumeHandle.Lookup(cursorInodeNumber, pathComponent)
+		//
+		// Only should the dirent be missing do we need the cursor's write lock, after which we must look again.
+		getLock := mS.volStruct.ensureReadLock
+		cursorInodeLock, err1 := mS.volStruct.getReadLock(cursorInodeNumber, callerID)
 		if err1 != nil {
-			if blunder.Is(err1, blunder.NotFoundError) {
-				// We found a dir entry that doesn't exist; now we start making directories.
-				break
-			} else {
-				// Mystery error; bail out
+			err = err1
+			return
+		}
+		_, err1 = mS.volStruct.VolumeHandle.Lookup(cursorInodeNumber, pathComponent)
+		if blunder.Is(err1, blunder.NotFoundError) {
+			cursorInodeLock.Unlock()
+			getLock = mS.volStruct.ensureWriteLock
+			cursorInodeLock, err1 = mS.volStruct.getWriteLock(cursorInodeNumber, callerID)
+			if err1 != nil {
 				err = err1
 				return
 			}
+			_, err1 = mS.volStruct.VolumeHandle.Lookup(cursorInodeNumber, pathComponent)
+			if blunder.Is(err1, blunder.NotFoundError) {
+				// can't use Mkdir since it wants to take its own lock, so we make and link the dir ourselves
+				cursorInodeNumber, err = mS.makeCoalesceDir(cursorInodeNumber, pathComponent)
+				cursorInodeLock.Unlock()
+				if err != nil {
+					return
+				}
+				continue
+			}
+		}
+		if err1 != nil {
+			// Mystery error; bail out
+			cursorInodeLock.Unlock()
+			err = err1
+			return
 		}
 
 		// Resolve one path component and advance the cursor
-		nextCursorInodeNumber, nextCursorInodeType, nextCursorInodeLock, err1 := mS.resolvePath(pathComponent, callerID, cursorInodeNumber, mS.volStruct.ensureWriteLock)
+		nextCursorInodeNumber, nextCursorInodeType, nextCursorInodeLock, err1 := mS.resolvePath(pathComponent, callerID, cursorInodeNumber, getLock, nil, true)
+		if nextCursorInodeLock != nil {
+			nextCursorInodeLock.Unlock()
+		}
+		cursorInodeLock.Unlock()
 		if err1 != nil {
 			err = err1
 			return
@@ -1099,114 +1700,154 @@ func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string
 		if nextCursorInodeType != inode.DirType {
 			// Every path component must resolve to a directory. There may be symlinks along the way, but resolvePath
 			// takes care of following those.
-			if nextCursorInodeLock != nil {
-				nextCursorInodeLock.Unlock()
-			}
 			err = blunder.NewError(blunder.NotDirError, "%v is not a directory", pathComponent)
 			return
 		}
 
-		if cursorInodeLock != nil {
-			cursorInodeLock.Unlock()
-		}
 		cursorInodeNumber = nextCursorInodeNumber
-		cursorInodeLock = nextCursorInodeLock
-		destDirPathComponents = destDirPathComponents[1:]
 	}
 
-	// Make any missing directory entires
-	for len(destDirPathComponents) > 0 {
-		pathComponent := destDirPathComponents[0]
-		// can't use Mkdir since it wants to take its own lock, so we make and link the dir ourselves
-
-		newDirInodeNumber, err1 := mS.volStruct.VolumeHandle.CreateDir(inode.InodeMode(0755), inode.InodeRootUserID, inode.InodeRootGroupID)
-		if err1 != nil {
-			logger.ErrorWithError(err1)
-			err = err1
-			return
-		}
+	destDirInodeNumber = cursorInodeNumber
+	return
+}
 
-		err = mS.volStruct.VolumeHandle.Link(cursorInodeNumber, pathComponent, newDirInodeNumber)
-		if err != nil {
-			destroyErr := mS.volStruct.VolumeHandle.Destroy(newDirInodeNumber)
-			if destroyErr != nil {
-				logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed Link() in fs.MiddlewareCoalesce", newDirInodeNumber)
-			}
-			return
-		}
+// makeCoalesceDir makes directory basename in dirInodeNumber, whose write lock the caller holds.
+func (mS *mountStruct) makeCoalesceDir(dirInodeNumber inode.InodeNumber, basename string) (newDirInodeNumber inode.InodeNumber, err error) {
+	newDirInodeNumber, err = mS.volStruct.VolumeHandle.CreateDir(inode.InodeMode(0755), inode.InodeRootUserID, inode.InodeRootGroupID)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return
+	}
 
-		if cursorInodeLock != nil {
-			cursorInodeLock.Unlock()
-		}
-		destDirPathComponents = destDirPathComponents[1:]
-		cursorInodeNumber = newDirInodeNumber
-		cursorInodeLock, err1 = mS.volStruct.ensureWriteLock(newDirInodeNumber, callerID)
-		if err1 != nil {
-			err = err1
-			return
+	err = mS.volStruct.VolumeHandle.Link(dirInodeNumber, basename, newDirInodeNumber)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(newDirInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed Link() in fs.MiddlewareCoalesce", newDirInodeNumber)
 		}
+		return
 	}
+	mS.volStruct.notifyName(NotifyCreate, dirInodeNumber, basename, newDirInodeNumber)
+	return
+}
+
+// resolveCoalesceElements resolves each element's directory and file. No locks are held upon return.
+func (mS *mountStruct) resolveCoalesceElements(elementDirAndFileNames dirAndFileNameSlice, callerID dlm.CallerID) (coalesceElements []inode.CoalesceElement, err error) {
+	coalesceElements = make([]inode.CoalesceElement, 0, len(elementDirAndFileNames))
 
 	for _, entry := range elementDirAndFileNames {
-		dirInodeNumber, dirInodeType, dirInodeLock, err1 := mS.resolvePathForWrite(entry.dirName, callerID)
+		dirInodeNumber, dirInodeType, dirInodeLock, err1 := mS.resolvePathForRead(entry.dirName, callerID)
 		if err1 != nil {
 			err = err1
 			return
 		}
-		if dirInodeLock != nil {
-			heldLocks = append(heldLocks, dirInodeLock)
-		}
 
-		if dirInodeType != inode.DirType {
-			err = blunder.NewError(blunder.NotDirError, "%s is not a directory", entry.dirName)
-			return
+		fileInodeNumber, err1 := mS.checkCoalesceElement(entry, dirInodeNumber, dirInodeType)
+		if dirInodeLock != nil {
+			dirInodeLock.Unlock()
 		}
-
-		fileInodeNumber, err1 := mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, entry.fileName)
 		if err1 != nil {
 			err = err1
 			return
 		}
 
-		fileInodeLock, err1 := mS.volStruct.initInodeLock(fileInodeNumber, callerID)
-		if err1 != nil {
-			err = err1
-			return
-		}
-		if !fileInodeLock.IsWriteHeld() {
-			err = fileInodeLock.WriteLock()
-			if err != nil {
-				return
-			}
-			heldLocks = append(heldLocks, fileInodeLock)
+		coalesceElements = append(coalesceElements, inode.CoalesceElement{
+			ContainingDirectoryInodeNumber: dirInodeNumber,
+			ElementInodeNumber:             fileInodeNumber,
+			ElementName:                    entry.fileName,
+			Offset:                         entry.offset,
+			Length:                         entry.length,
+		})
+	}
+	return
+}
+
+// checkCoalesceElement returns the inode number of entry's file in dirInodeNumber (of dirInodeType), which must
+// be an ordinary file. Caller holds a lock on dirInodeNumber.
+func (mS *mountStruct) checkCoalesceElement(entry dirAndFileName, dirInodeNumber inode.InodeNumber, dirInodeType inode.InodeType) (fileInodeNumber inode.InodeNumber, err error) {
+	if dirInodeType != inode.DirType {
+		err = blunder.NewError(blunder.NotDirError, "%s is not a directory", entry.dirName)
+		return
+	}
+
+	fileInodeNumber, err = mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, entry.fileName)
+	if err != nil {
+		return
+	}
+
+	fileInodeType, err := mS.volStruct.VolumeHandle.GetType(fileInodeNumber)
+	if err != nil {
+		return
+	}
+	if fileInodeType != inode.FileType {
+		err = blunder.NewError(blunder.NotFileError, "%s/%s is not an ordinary file", entry.dirName, entry.fileName)
+	}
+	return
+}
+
+// revalidateCoalescePaths returns TryAgainError unless destDirName and each element's path still resolve to
+// destDirInodeNumber and coalesceElements. Caller holds the write lock on each of those inodes, so any other
+// lock needed to resolve the paths is only tried for.
+func (mS *mountStruct) revalidateCoalescePaths(destDirName string, destDirInodeNumber inode.InodeNumber, elementDirAndFileNames dirAndFileNameSlice, coalesceElements []inode.CoalesceElement, callerID dlm.CallerID) (err error) {
+	changedErr := blunder.NewError(blunder.TryAgainError, "paths to coalesce changed while being locked")
+
+	resolvedInodeNumber, resolvedInodeType, resolvedInodeLock, err := mS.resolvePath(destDirName, callerID, inode.RootDirInodeNumber, mS.volStruct.tryEnsureReadLock, nil, true)
+	if resolvedInodeLock != nil {
+		resolvedInodeLock.Unlock()
+	}
+	if err != nil {
+		if blunder.IsNot(err, blunder.TryAgainError) {
+			err = changedErr
 		}
+		return
+	}
+	if (resolvedInodeNumber != destDirInodeNumber) || (resolvedInodeType != inode.DirType) {
+		err = changedErr
+		return
+	}
 
-		fileMetadata, err1 := mS.volStruct.VolumeHandle.GetMetadata(fileInodeNumber)
-		if err1 != nil {
-			err = err1
+	for i, entry := range elementDirAndFileNames {
+		resolvedInodeNumber, resolvedInodeType, resolvedInodeLock, err = mS.resolvePath(entry.dirName, callerID, inode.RootDirInodeNumber, mS.volStruct.tryEnsureReadLock, nil, true)
+		if resolvedInodeLock != nil {
+			resolvedInodeLock.Unlock()
+		}
+		if err != nil {
+			if blunder.IsNot(err, blunder.TryAgainError) {
+				err = changedErr
+			}
 			return
 		}
-		if fileMetadata.InodeType != inode.FileType {
-			err = blunder.NewError(blunder.NotFileError, "%s/%s is not an ordinary file", entry.dirName, entry.fileName)
+		if resolvedInodeNumber != coalesceElements[i].ContainingDirectoryInodeNumber {
+			err = changedErr
 			return
 		}
 
-		coalesceElements = append(coalesceElements, inode.CoalesceElement{
-			ContainingDirectoryInodeNumber: dirInodeNumber,
-			ElementInodeNumber:             fileInodeNumber,
-			ElementName:                    entry.fileName,
-		})
+		fileInodeNumber, checkErr := mS.checkCoalesceElement(entry, resolvedInodeNumber, resolvedInodeType)
+		if (checkErr != nil) || (fileInodeNumber != coalesceElements[i].ElementInodeNumber) {
+			err = changedErr
+			return
+		}
 	}
-
-	// We've now jumped through all the requisite hoops to get the required locks, so now we can call inode.Coalesce and
-	// do something useful
-	destInodeNumber, mtime, numWrites, err := mS.volStruct.VolumeHandle.Coalesce(cursorInodeNumber, destFileName, coalesceElements)
-	ino = uint64(destInodeNumber)
-	modificationTime = uint64(mtime.UnixNano())
 	return
 }
 
 func (mS *mountStruct) MiddlewareDelete(parentDir string, baseName string) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(containerOfPath(parentDir, baseName)) // see freeze.go
+	defer exitContainers()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeasesOfPath(parentDir+"/"+baseName, true) // see coherence.go
+
 	// Get the inode, type, and lock for the parent directory
 	parentInodeNumber, parentInodeType, parentDirLock, err := mS.resolvePathForWrite(parentDir, nil)
 	if err != nil {
@@ -1217,17 +1858,44 @@ func (mS *mountStruct) MiddlewareDelete(parentDir string, baseName string) (err
 		err = blunder.NewError(blunder.NotDirError, "%s is a file", parentDir)
 	}
 
+	err = mS.middlewareDeleteHelper(parentInodeNumber, baseName, parentDirLock.GetCallerID())
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsMwDeleteOps)
+	return
+}
+
+// middlewareDeleteHelper unlinks baseName from parentInodeNumber (destroying it if that was its last link)
+// provided it is not a non-empty directory.
+//
+// The caller must hold a write lock on parentInodeNumber (as callerID).
+func (mS *mountStruct) middlewareDeleteHelper(parentInodeNumber inode.InodeNumber, baseName string, callerID dlm.CallerID) (err error) {
 	// We will need both parentDir lock to Unlink() and baseInode lock.
 	baseNameInodeNumber, err := mS.volStruct.VolumeHandle.Lookup(parentInodeNumber, baseName)
 	if err != nil {
-		return err
+		return
 	}
-	baseInodeLock, err := mS.volStruct.getWriteLock(baseNameInodeNumber, parentDirLock.GetCallerID())
+	baseInodeLock, err := mS.volStruct.getWriteLock(baseNameInodeNumber, callerID)
 	if nil != err {
 		return
 	}
 	defer baseInodeLock.Unlock()
 
+	err = mS.volStruct.checkRetention(baseNameInodeNumber) // see retention.go
+	if nil != err {
+		return
+	}
+	err = mS.volStruct.checkInodeFlags(baseNameInodeNumber) // see inode_flags.go
+	if nil != err {
+		return
+	}
+	err = mS.volStruct.checkShareModes(baseNameInodeNumber, ShareDelete, 0) // see share_mode.go
+	if nil != err {
+		return
+	}
+
 	inodeType, err := mS.volStruct.VolumeHandle.GetType(baseNameInodeNumber)
 	if nil != err {
 		return
@@ -1268,28 +1936,207 @@ func (mS *mountStruct) MiddlewareDelete(parentDir string, baseName string) (err
 		return
 	}
 
+	mS.volStruct.notifyName(NotifyUnlink, parentInodeNumber, baseName, baseNameInodeNumber)
+
 	if doDestroy {
 		err = mS.volStruct.VolumeHandle.Destroy(baseNameInodeNumber)
 		if nil != err {
-			return err
+			return
 		}
 		mS.volStruct.untrackInFlightFileInodeData(baseNameInodeNumber, false)
+		mS.volStruct.destroyVersions(baseNameInodeNumber)
+		mS.volStruct.forgetInodeIfDestroyed(baseNameInodeNumber) // see coherence.go
 	}
 
-	stats.IncrementOperations(&stats.FsMwDeleteOps)
 	return
 }
 
+// middlewareDeleteMultiBatchSize caps how many entries MiddlewareDeleteMulti() deletes under one lock of their
+// parent directory (so as not to starve other users of a directory it is emptying)
+const middlewareDeleteMultiBatchSize = 256
+
+// MiddlewareDeleteMulti deletes each of entries as MiddlewareDelete() would, returning the outcome of each
+// (nil == deleted) in errs. Entries sharing a parent directory are deleted in batches under a single lock
+// of that directory. Containers are deleted after any objects so that a container emptied by the request
+// may itself be deleted by it.
+func (mS *mountStruct) MiddlewareDeleteMulti(entries []DeleteMultiEntry) (errs []error) {
+	errs = mS.middlewareDeleteMulti(nil, entries)
+	return
+}
+
+// middlewareDeleteMulti implements MiddlewareDeleteMulti() and, for a non-nil caller,
+// MiddlewareDeleteMultiAsCaller().
+func (mS *mountStruct) middlewareDeleteMulti(caller *MiddlewareCallerStruct, entries []DeleteMultiEntry) (errs []error) {
+	type deleteGroupStruct struct {
+		parentPath string
+		basenames  []string
+		entryIndex []int // index into entries (and errs) of each of basenames
+	}
+
+	var (
+		containerGroup  *deleteGroupStruct // deletes of containers themselves; done last
+		deleteGroup     *deleteGroupStruct
+		deleteGroupList []*deleteGroupStruct
+		deleteGroupMap  = make(map[string]*deleteGroupStruct) // key == deleteGroupStruct.parentPath
+		ok              bool
+		authErrMap      = make(map[string]error) // key == container name
+		vContainerNames []string
+		vContainerSet   = make(map[string]struct{})
+	)
+
+	for _, entry := range entries {
+		_, ok = vContainerSet[entry.Container]
+		if !ok {
+			vContainerSet[entry.Container] = struct{}{}
+			vContainerNames = append(vContainerNames, entry.Container)
+		}
+	}
+
+	errs = make([]error, len(entries))
+
+	err := mS.enterOp()
+	if nil == err {
+		defer mS.exitOp(&err)
+		err = mS.checkWritable()
+	}
+	if nil != err {
+		for i := range errs {
+			errs[i] = err
+		}
+		return
+	}
+
+	exitContainers := mS.enterContainers(vContainerNames...) // see freeze.go
+	defer exitContainers()
+
+	for i, entry := range entries {
+		if ("" == entry.Container) || strings.Contains(entry.Container, "/") {
+			errs[i] = blunder.NewError(blunder.InvalidArgError, "\"%s\" is not a container", entry.Container)
+			continue
+		}
+
+		var parentPath, basename string
+
+		if "" == strings.Trim(entry.ObjectPath, "/") {
+			parentPath = "/"
+			basename = entry.Container
+		} else {
+			containerPath := "/" + entry.Container
+			cleanPath := path.Clean(containerPath + "/" + entry.ObjectPath)
+			if !strings.HasPrefix(cleanPath, containerPath+"/") {
+				errs[i] = blunder.NewError(blunder.InvalidArgError, "\"%s\" is not an object within container \"%s\"", entry.ObjectPath, entry.Container)
+				continue
+			}
+			parentPath = path.Dir(cleanPath)
+			basename = path.Base(cleanPath)
+		}
+
+		if nil != caller {
+			errs[i], ok = authErrMap[entry.Container]
+			if !ok {
+				errs[i] = mS.authorizeCaller(caller, entry.Container, true)
+				authErrMap[entry.Container] = errs[i]
+			}
+			if nil != errs[i] {
+				continue
+			}
+		}
+
+		if "/" == parentPath {
+			if nil == containerGroup {
+				containerGroup = &deleteGroupStruct{parentPath: parentPath}
+			}
+			deleteGroup = containerGroup
+		} else {
+			deleteGroup, ok = deleteGroupMap[parentPath]
+			if !ok {
+				deleteGroup = &deleteGroupStruct{parentPath: parentPath}
+				deleteGroupMap[parentPath] = deleteGroup
+				deleteGroupList = append(deleteGroupList, deleteGroup)
+			}
+		}
+		deleteGroup.basenames = append(deleteGroup.basenames, basename)
+		deleteGroup.entryIndex = append(deleteGroup.entryIndex, i)
+	}
+
+	if nil != containerGroup {
+		deleteGroupList = append(deleteGroupList, containerGroup)
+	}
+
+	// Each batch holds its parent directory's lock only while deleting at most middlewareDeleteMultiBatchSize entries
+
+	for _, deleteGroup = range deleteGroupList {
+		for batchStart := 0; batchStart < len(deleteGroup.basenames); batchStart += middlewareDeleteMultiBatchSize {
+			batchEnd := batchStart + middlewareDeleteMultiBatchSize
+			if batchEnd > len(deleteGroup.basenames) {
+				batchEnd = len(deleteGroup.basenames)
+			}
+			mS.middlewareDeleteBatch(deleteGroup.parentPath, deleteGroup.basenames[batchStart:batchEnd], deleteGroup.entryIndex[batchStart:batchEnd], errs)
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsMwDeleteMultiOps)
+	return
+}
+
+// middlewareDeleteBatch fills in errs for the delete of each of basenames from the directory at parentPath.
+func (mS *mountStruct) middlewareDeleteBatch(parentPath string, basenames []string, entryIndex []int, errs []error) {
+	callerID := dlm.GenerateCallerID()
+
+	parentInodeNumber, parentInodeType, parentDirLock, err := mS.resolvePathForWrite(parentPath, callerID)
+	if nil == err {
+		defer parentDirLock.Unlock()
+		if inode.DirType != parentInodeType {
+			err = blunder.NewError(blunder.NotDirError, "%s is a file, not a directory", parentPath)
+		}
+	}
+	if nil != err {
+		for _, i := range entryIndex {
+			errs[i] = err
+		}
+		return
+	}
+
+	for j, basename := range basenames {
+		errs[entryIndex[j]] = mS.middlewareDeleteHelper(parentInodeNumber, basename, callerID)
+	}
+}
+
 func (mS *mountStruct) MiddlewareGetAccount(maxEntries uint64, marker string) (accountEnts []AccountEntry, err error) {
+	accountEnts, err = mS.MiddlewareGetAccountListing(maxEntries, marker, "", false)
+	return
+}
+
+// MiddlewareGetAccountListing is MiddlewareGetAccount() also honoring Swift's end_marker and reverse query
+// parameters: only containers after marker and before endMarker ("" == no end_marker) are listed or, if
+// reverse, only those before marker and after endMarker are listed (in reverse order).
+func (mS *mountStruct) MiddlewareGetAccountListing(maxEntries uint64, marker string, endMarker string, reverse bool) (accountEnts []AccountEntry, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
 	// List the root directory, starting at the marker, and keep only
 	// the directories. The Swift API doesn't let you have objects in
 	// an account, so files or symlinks don't belong in an account
 	// listing.
+	if 0 < maxEntries {
+		maxEntries = mS.volStruct.capEntries(maxEntries)
+	}
+	if reverse {
+		accountEnts, err = mS.middlewareGetAccountReverse(maxEntries, marker, endMarker)
+		if nil != err {
+			return
+		}
+		stats.IncrementOperations(&stats.FsMwGetAccountOps)
+		return
+	}
 	areMoreEntries := true
 	lastBasename := marker
 	for areMoreEntries && uint64(len(accountEnts)) < maxEntries {
 		var dirEnts []inode.DirEntry
-		dirEnts, _, areMoreEntries, err = mS.Readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, lastBasename, maxEntries-uint64(len(accountEnts)), 0)
+		dirEnts, _, areMoreEntries, err = mS.readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, lastBasename, maxEntries-uint64(len(accountEnts)), 0)
 		if err != nil {
 			if blunder.Is(err, blunder.NotFoundError) {
 				// Readdir gives you a NotFoundError if you ask for a
@@ -1314,29 +2161,90 @@ func (mS *mountStruct) MiddlewareGetAccount(maxEntries uint64, marker string) (a
 			if dirEnt.Basename == "." || dirEnt.Basename == ".." {
 				continue
 			}
+			if ("" != endMarker) && (dirEnt.Basename >= endMarker) {
+				areMoreEntries = false
+				break
+			}
 
 			var isItADir bool
-			isItADir, err = mS.IsDir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirEnt.InodeNumber)
+			isItADir, err = mS.isDir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirEnt.InodeNumber)
 			if err != nil {
 				logger.ErrorfWithError(err, "MiddlewareGetAccount: error in IsDir(%v)", dirEnt.InodeNumber)
 				return
 			}
 
-			if isItADir {
-				accountEnts = append(accountEnts, AccountEntry{Basename: dirEnt.Basename})
-			}
-		}
-		if len(dirEnts) == 0 {
-			break
-		} else {
-			lastBasename = dirEnts[len(dirEnts)-1].Basename
-		}
+			if isItADir {
+				accountEnts = append(accountEnts, AccountEntry{Basename: dirEnt.Basename})
+			}
+		}
+		if len(dirEnts) == 0 {
+			break
+		} else {
+			lastBasename = dirEnts[len(dirEnts)-1].Basename
+		}
+	}
+	stats.IncrementOperations(&stats.FsMwGetAccountOps)
+	return
+}
+
+func (mS *mountStruct) MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, prefix string) (containerEnts []ContainerEntry, err error) {
+	containerEnts, err = mS.MiddlewareGetContainerDelimited(vContainerName, maxEntries, marker, prefix, "")
+	return
+}
+
+// MiddlewareGetContainerDelimited is MiddlewareGetContainer() honoring a Swift delimiter query parameter.
+//
+// With delimiter "/", a directory beneath prefix is listed (as usual) but not descended. Rather, should
+// it have any entries, it is followed in the listing by a pseudo-directory entry (IsSubdir) for its path
+// plus "/" standing in for them all. Only "/" (or "", meaning no delimiter) is supported as delimiter: as
+// any other character may appear anywhere in a basename, it would require walking the entire tree anyway.
+func (mS *mountStruct) MiddlewareGetContainerDelimited(vContainerName string, maxEntries uint64, marker string, prefix string, delimiter string) (containerEnts []ContainerEntry, err error) {
+	containerEnts, err = mS.MiddlewareGetContainerRange(vContainerName, maxEntries, marker, "", prefix, delimiter)
+	return
+}
+
+// MiddlewareGetContainerRange is MiddlewareGetContainerDelimited() also honoring a Swift end_marker query
+// parameter: only entries after marker and up to (and including) endMarker ("" == no end_marker) are listed.
+// Note that, unlike Swift, the listing includes an entry named endMarker (so that the ContainerShards of
+// MiddlewareGetContainerShards() may be listed without overlap or gap).
+func (mS *mountStruct) MiddlewareGetContainerRange(vContainerName string, maxEntries uint64, marker string, endMarker string, prefix string, delimiter string) (containerEnts []ContainerEntry, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	containerEnts, err = mS.middlewareGetContainer(vContainerName, maxEntries, marker, endMarker, true, prefix, delimiter, false)
+	return
+}
+
+// MiddlewareGetContainerListing is MiddlewareGetContainerDelimited() also honoring Swift's end_marker and
+// reverse query parameters: only entries after marker and before endMarker ("" == no end_marker) are listed
+// or, if reverse, only those before marker and after endMarker are listed (in reverse order).
+func (mS *mountStruct) MiddlewareGetContainerListing(vContainerName string, maxEntries uint64, marker string, endMarker string, prefix string, delimiter string, reverse bool) (containerEnts []ContainerEntry, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
 	}
-	stats.IncrementOperations(&stats.FsMwGetAccountOps)
+	defer mS.exitOp(&err)
+
+	containerEnts, err = mS.middlewareGetContainer(vContainerName, maxEntries, marker, endMarker, false, prefix, delimiter, reverse)
 	return
 }
 
-func (mS *mountStruct) MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, prefix string) (containerEnts []ContainerEntry, err error) {
+// middlewareGetContainer lists vContainerName for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) middlewareGetContainer(vContainerName string, maxEntries uint64, marker string, endMarker string, endMarkerInclusive bool, prefix string, delimiter string, reverse bool) (containerEnts []ContainerEntry, err error) {
+	if ("" != delimiter) && ("/" != delimiter) {
+		err = blunder.NewError(blunder.InvalidArgError, "delimiter %q not supported (only \"/\")", delimiter)
+		return
+	}
+
+	err = mS.volStruct.admitHeavyOp()
+	if nil != err {
+		return
+	}
+	defer mS.volStruct.releaseHeavyOp()
+
 	ino, _, inoLock, err := mS.resolvePathForRead(vContainerName, nil)
 	if err != nil {
 		return
@@ -1352,185 +2260,392 @@ func (mS *mountStruct) MiddlewareGetContainer(vContainerName string, maxEntries
 	// than what a Swift client would normally have to put up with.
 	inoLock.Unlock()
 
-	containerEnts = make([]ContainerEntry, 0)
-	var recursiveReaddirPlus func(dirName string, dirInode inode.InodeNumber) error
-	recursiveReaddirPlus = func(dirName string, dirInode inode.InodeNumber) error {
-		var dirEnts []inode.DirEntry
-		var recursiveDescents []dirToDescend
-		areMoreEntries := true
-		lastBasename := ""
-
-		// Note that we're taking advantage of the fact that
-		// Readdir() returns things in lexicographic order, which
-		// is the same as our desired order. This lets us avoid
-		// reading the whole directory only to sort it.
-		for (areMoreEntries || len(dirEnts) > 0 || len(recursiveDescents) > 0) && uint64(len(containerEnts)) < maxEntries {
-			// If we've run out of real directory entries, load some more.
-			if areMoreEntries && len(dirEnts) == 0 {
-				dirEnts, _, areMoreEntries, err = mS.Readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInode, lastBasename, maxEntries-uint64(len(containerEnts)), 0)
-				if err != nil {
-					logger.ErrorfWithError(err, "MiddlewareGetContainer: error reading directory %s (inode %v)", dirName, dirInode)
-					return err
-				}
-				if len(dirEnts) > 0 {
-					// If there's no dirEnts here, then areMoreEntries
-					// is false, so we'll never call Readdir again,
-					// and thus it doesn't matter what the value of
-					// lastBasename is.
-					lastBasename = dirEnts[len(dirEnts)-1].Basename
-				}
-			}
+	if 0 < maxEntries {
+		maxEntries = mS.volStruct.capEntries(maxEntries)
+	}
 
-			// Ignore these early so we can stop thinking about them
-			if len(dirEnts) > 0 && (dirEnts[0].Basename == "." || dirEnts[0].Basename == "..") {
-				dirEnts = dirEnts[1:]
-				continue
+	listing := &containerListingStruct{
+		mS:                 mS,
+		maxEntries:         maxEntries,
+		marker:             marker,
+		endMarker:          endMarker,
+		endMarkerInclusive: endMarkerInclusive,
+		prefix:             prefix,
+		delimited:          ("" != delimiter),
+		reverse:            reverse,
+		containerEnts:      make([]ContainerEntry, 0),
+	}
+
+	if listing.reverse {
+		err = mS.volStruct.descendTree(listing.newReverseLevel("", ino))
+	} else {
+		err = mS.volStruct.descendTree(listing.newLevel("", ino))
+	}
+	if err != nil {
+		// already logged (unless a descent limit was exceeded)
+		return
+	}
+	containerEnts = listing.containerEnts
+	stats.IncrementOperations(&stats.FsMwGetContainerOps)
+	if listing.delimited {
+		stats.IncrementOperations(&stats.FsMwGetContainerDelimitedOps)
+	}
+	if listing.reverse {
+		stats.IncrementOperations(&stats.FsMwGetContainerReverseOps)
+	}
+	return
+}
+
+// containerListingStruct is the state of a MiddlewareGetContainer() shared by each level of its descent.
+type containerListingStruct struct {
+	mS                 *mountStruct
+	maxEntries         uint64
+	marker             string
+	endMarker          string // "" == no end_marker
+	endMarkerInclusive bool   // if true, an entry named endMarker is listed
+	prefix             string
+	delimited          bool // delimiter "/" (so directories beneath prefix are listed as subdirs, not descended)
+	reverse            bool // list in reverse order (see reverse_listing.go)
+	pastEndMarker      bool // if true, the listing is complete
+	containerEnts      []ContainerEntry
+}
+
+// newLevel returns the listing level for directory dirInode (named dirName, with a trailing "/"
+// unless the container itself).
+//
+// Rather than reading the directory from its first entry, the level begins its Readdir() just before
+// the first entry that could be listed (or lead to an entry to be listed) given marker & prefix.
+func (listing *containerListingStruct) newLevel(dirName string, dirInode inode.InodeNumber) (level *containerListingLevelStruct) {
+	level = &containerListingLevelStruct{listing: listing, dirName: dirName, dirInode: dirInode, areMoreEntries: true}
+
+	if strings.HasPrefix(listing.marker, dirName) && (len(listing.marker) > len(dirName)) {
+		// An entry sorting before the marker's basename in this directory lists only names before the marker
+		// unless it is a proper prefix of that basename followed there by a byte sorting before '/' (e.g.
+		// "a" for marker "a.txt", as "a/b" > "a.txt")... so begin at the first such (possible) prefix
+		markerBasename := strings.SplitN(listing.marker[len(dirName):], "/", 2)[0]
+		for i := 0; i < len(markerBasename); i++ {
+			if markerBasename[i] < '/' {
+				markerBasename = markerBasename[:i]
+				break
 			}
+		}
+		level.lastBasename = basenameBefore(markerBasename)
+	}
 
-			// If we've got pending recursive descents that should go before the next dirEnt, handle them
-			for len(recursiveDescents) > 0 && (len(dirEnts) == 0 || (recursiveDescents[0].name < dirEnts[0].Basename)) {
-				err = recursiveReaddirPlus(recursiveDescents[0].path, recursiveDescents[0].ino)
-				if err != nil {
-					// already logged
-					return err
-				}
-				if uint64(len(containerEnts)) >= maxEntries {
-					// we're finished here
-					return nil
-				}
-				recursiveDescents = recursiveDescents[1:]
+	if strings.HasPrefix(listing.prefix, dirName) && (len(listing.prefix) > len(dirName)) {
+		// No entry sorting before the prefix's (possibly partial) basename in this directory can match
+		prefixBasename := strings.SplitN(listing.prefix[len(dirName):], "/", 2)[0]
+		prefixStart := basenameBefore(prefixBasename)
+		if prefixStart > level.lastBasename {
+			level.lastBasename = prefixStart
+		}
+	}
+
+	return
+}
+
+// basenameBefore returns a string sorting before basename but after any shorter basename sorting before it
+// (suitable as the prevBasenameReturned of a Readdir() that must return basename if it exists).
+func basenameBefore(basename string) (before string) {
+	if "" == basename {
+		return
+	}
+	lastByte := basename[len(basename)-1]
+	if 1 == lastByte {
+		before = basename[:len(basename)-1]
+	} else {
+		before = basename[:len(basename)-1] + string([]byte{lastByte - 1})
+	}
+	return
+}
+
+// pastEnd returns whether name (and hence, as entries are listed in order, all that would follow it) is
+// beyond the listing's endMarker (or, unless endMarkerInclusive, is endMarker), noting if so that the listing is
+// complete.
+func (listing *containerListingStruct) pastEnd(name string) bool {
+	if ("" != listing.endMarker) && ((name > listing.endMarker) || (!listing.endMarkerInclusive && (name == listing.endMarker))) {
+		listing.pastEndMarker = true
+	}
+	return listing.pastEndMarker
+}
+
+// containerListingLevelStruct is the state of a MiddlewareGetContainer() listing one directory.
+type containerListingLevelStruct struct {
+	listing           *containerListingStruct
+	dirName           string
+	dirInode          inode.InodeNumber
+	dirEnts           []inode.DirEntry
+	recursiveDescents []dirToDescend
+	areMoreEntries    bool
+	lastBasename      string
+}
+
+func (level *containerListingLevelStruct) next(tD *treeDescentStruct) (subLevel treeDescentLevel, err error) {
+	subLevel, err = level.listDir(tD)
+	if (nil == subLevel) && (nil == err) {
+		// Done with this directory... so forget any descents we won't be making
+		err = tD.addPending(-len(level.recursiveDescents))
+		level.recursiveDescents = nil
+	}
+	return
+}
+
+// listDir appends the entries of the directory to the listing until it either needs to descend into a
+// subdirectory (returned as subLevel) or is done (returning a nil subLevel).
+func (level *containerListingLevelStruct) listDir(tD *treeDescentStruct) (subLevel treeDescentLevel, err error) {
+	mS := level.listing.mS
+	maxEntries := level.listing.maxEntries
+	marker := level.listing.marker
+	prefix := level.listing.prefix
+	dirName := level.dirName
+	dirInode := level.dirInode
+
+	// Note that we're taking advantage of the fact that
+	// Readdir() returns things in lexicographic order, which
+	// is the same as our desired order. This lets us avoid
+	// reading the whole directory only to sort it.
+	for (level.areMoreEntries || len(level.dirEnts) > 0 || len(level.recursiveDescents) > 0) && uint64(len(level.listing.containerEnts)) < maxEntries && !level.listing.pastEndMarker {
+		// If we've run out of real directory entries, load some more.
+		if level.areMoreEntries && len(level.dirEnts) == 0 {
+			level.dirEnts, _, level.areMoreEntries, err = mS.Readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInode, level.lastBasename, maxEntries-uint64(len(level.listing.containerEnts)), 0)
+			if ("" != level.lastBasename) && blunder.Is(err, blunder.NotFoundError) {
+				// As with MiddlewareGetAccount(), a lastBasename beyond the last entry (e.g. as positioned
+				// by newLevel()) simply means there are no more entries
+				level.dirEnts, level.areMoreEntries, err = nil, false, nil
 			}
+			if err != nil {
+				logger.ErrorfWithError(err, "MiddlewareGetContainer: error reading directory %s (inode %v)", dirName, dirInode)
+				return
+			}
+			if len(level.dirEnts) > 0 {
+				// If there's no dirEnts here, then areMoreEntries
+				// is false, so we'll never call Readdir again,
+				// and thus it doesn't matter what the value of
+				// lastBasename is.
+				level.lastBasename = level.dirEnts[len(level.dirEnts)-1].Basename
+			}
+		}
+
+		// Ignore these early so we can stop thinking about them
+		if len(level.dirEnts) > 0 && (level.dirEnts[0].Basename == "." || level.dirEnts[0].Basename == "..") {
+			level.dirEnts = level.dirEnts[1:]
+			continue
+		}
 
-			// Handle just one dirEnt per loop iteration. That lets us
-			// avoid having to refill dirEnts at more than one
-			// location in the code.
-			if !(len(dirEnts) > 0) {
+		// If we've got pending recursive descents that should go before the next dirEnt, descend into the first
+		if len(level.recursiveDescents) > 0 && (len(level.dirEnts) == 0 || (level.recursiveDescents[0].name < level.dirEnts[0].Basename)) {
+			descent := level.recursiveDescents[0]
+			level.recursiveDescents = level.recursiveDescents[1:]
+			err = tD.addPending(-1)
+			if nil != err {
+				return
+			}
+			if level.listing.pastEnd(descent.path) {
+				return
+			}
+			if descent.subdir {
+				err = level.listing.listSubdir(descent)
+				if nil != err {
+					return
+				}
 				continue
 			}
+			subLevel = level.listing.newLevel(descent.path, descent.ino)
+			return
+		}
 
-			dirEnt := dirEnts[0]
-			dirEnts = dirEnts[1:]
+		// Handle just one dirEnt per loop iteration. That lets us
+		// avoid having to refill dirEnts at more than one
+		// location in the code.
+		if !(len(level.dirEnts) > 0) {
+			continue
+		}
 
-			fileName := dirEnt.Basename
-			if len(dirName) > 0 {
-				fileName = dirName + dirEnt.Basename
-			}
+		dirEnt := level.dirEnts[0]
+		level.dirEnts = level.dirEnts[1:]
 
-			if fileName > prefix && !strings.HasPrefix(fileName, prefix) {
-				// Remember that we're going over these in order, so the first time we see something that's greater that
-				// the prefix but doesn't start with it, we can skip the entire rest of the directory entries since they
-				// are *also* greater than the prefix but don't start with it.
-				return nil
-			}
+		fileName := dirEnt.Basename
+		if len(dirName) > 0 {
+			fileName = dirName + dirEnt.Basename
+		}
 
-			// Swift container listings are paginated; you
-			// retrieve the first page with a simple GET
-			// <container>, then you retrieve each subsequent page
-			// with a GET <container>?marker=<last-obj-returned>.
-			//
-			// If we were given a marker, then we can prune the
-			// directory tree that we're walking.
-			//
-			// For a regular file, if its container-relative path
-			// is lexicographically less than or equal to the
-			// marker, we skip it.
-			//
-			// For a directory, if its container-relative path is
-			// lexicographically less than or equal to the marker
-			// and the marker does not begin with the directory's
-			// path, we skip it.
-			//
-			// Since no regular file's container-relative path
-			// starts with another regular file's
-			// container-relative path, we can make the following
-			// test prior to any Getstat() calls, avoiding
-			// unneeded IO.
-			if fileName <= marker && strings.Index(marker, fileName) != 0 {
+		if level.listing.pastEnd(fileName) {
+			return
+		}
+
+		if fileName > prefix && !strings.HasPrefix(fileName, prefix) {
+			// Remember that we're going over these in order, so the first time we see something that's greater that
+			// the prefix but doesn't start with it, we can skip the entire rest of the directory entries since they
+			// are *also* greater than the prefix but don't start with it.
+			return
+		}
+
+		// Swift container listings are paginated; you
+		// retrieve the first page with a simple GET
+		// <container>, then you retrieve each subsequent page
+		// with a GET <container>?marker=<last-obj-returned>.
+		//
+		// If we were given a marker, then we can prune the
+		// directory tree that we're walking.
+		//
+		// For a regular file, if its container-relative path
+		// is lexicographically less than or equal to the
+		// marker, we skip it.
+		//
+		// For a directory, if its container-relative path is
+		// lexicographically less than or equal to the marker
+		// and the marker does not begin with the directory's
+		// path, we skip it.
+		//
+		// Since no regular file's container-relative path
+		// starts with another regular file's
+		// container-relative path, we can make the following
+		// test prior to any Getstat() calls, avoiding
+		// unneeded IO.
+		if fileName <= marker && strings.Index(marker, fileName) != 0 {
+			continue
+		}
+
+		statResult, getstatErr := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirEnt.InodeNumber) // TODO: fix this
+		if getstatErr != nil {
+			logger.ErrorfWithError(getstatErr, "MiddlewareGetContainer: error in Getstat of %s", fileName)
+			err = getstatErr
+			return
+		}
+
+		fileType := inode.InodeType(statResult[StatFType])
+
+		if fileType == inode.FileType || fileType == inode.SymlinkType {
+			if fileName <= marker {
 				continue
 			}
-
-			statResult, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirEnt.InodeNumber) // TODO: fix this
-			if err != nil {
-				logger.ErrorfWithError(err, "MiddlewareGetContainer: error in Getstat of %s", fileName)
-				return err
+			if !strings.HasPrefix(fileName, prefix) {
+				continue
 			}
 
-			fileType := inode.InodeType(statResult[StatFType])
+			err = level.listing.appendFile(fileName, dirEnt.InodeNumber, statResult)
+			if nil != err {
+				return
+			}
+		} else {
+			if !strings.HasPrefix(fileName, prefix) && !strings.HasPrefix(prefix, fileName) {
+				continue
+			}
 
-			if fileType == inode.FileType || fileType == inode.SymlinkType {
-				if fileName <= marker {
-					continue
-				}
-				if !strings.HasPrefix(fileName, prefix) {
+			// Directories are handled specially. For a directory
+			// "some-dir", we put an entry for "some-dir" in the
+			// container listing, then put "some-dir/" into
+			// recursiveDescents (note the trailing slash). This
+			// lets us put off the descent until we have handled
+			// all dirEnts coming before "some-dir/".
+			//
+			// For example, consider a filesystem with a dir "d",
+			// a file "d/f", and a file "d-README".
+			// Lexicographically, these would be ordered "d",
+			// "d-README", "d/f" ("-" is ASCII 45, "/" is ASCII
+			// 47). If we recursed into d immediately upon
+			// encountering it, we would have "d/f" before
+			// "d-README", which is not what the Swift API
+			// demands.
+			if fileName > marker && strings.HasPrefix(fileName, prefix) {
+				level.listing.appendDir(fileName, statResult)
+			}
+			descent := dirToDescend{path: fileName + "/", name: dirEnt.Basename + "/", ino: dirEnt.InodeNumber}
+			if level.listing.delimited && !strings.HasPrefix(prefix, descent.path) {
+				// The delimiter follows prefix in each path beneath this directory, so (as a subdir entry, in
+				// the same order the descent would have been made) they are all listed as just descent.path
+				if (descent.path <= marker) || !strings.HasPrefix(descent.path, prefix) {
 					continue
 				}
+				descent.subdir = true
+			}
+			level.recursiveDescents = append(level.recursiveDescents, descent)
+			err = tD.addPending(1)
+			if nil != err {
+				return
+			}
+		}
+	}
+	return
+}
 
-				// Alternate data streams live in the inode, so this is almost certainly still cached from the Getstat()
-				// call, and hence is very cheap to retrieve.
-				serializedMetadata, err := mS.volStruct.VolumeHandle.GetStream(dirEnt.InodeNumber, MiddlewareStream)
+// appendFile appends to the listing the entry of a file (or symlink) named fileName.
+func (listing *containerListingStruct) appendFile(fileName string, inodeNumber inode.InodeNumber, statResult Stat) (err error) {
+	// Alternate data streams live in the inode, so this is almost certainly still cached from the Getstat()
+	// call, and hence is very cheap to retrieve.
+	serializedMetadata, getStreamErr := listing.mS.volStruct.VolumeHandle.GetStream(inodeNumber, MiddlewareStream)
 
-				// It's okay if there's no such stream; we just treat it as empty metadata. The middleware handles it.
-				if err != nil && blunder.IsNot(err, blunder.StreamNotFound) {
-					return err
-				}
+	// It's okay if there's no such stream; we just treat it as empty metadata. The middleware handles it.
+	if getStreamErr != nil && blunder.IsNot(getStreamErr, blunder.StreamNotFound) {
+		err = getStreamErr
+		return
+	}
 
-				containerEnt := ContainerEntry{
-					Basename:         fileName,
-					FileSize:         statResult[StatSize],
-					ModificationTime: statResult[StatMTime],
-					NumWrites:        statResult[StatNumWrites],
-					InodeNumber:      statResult[StatINum],
-					IsDir:            false,
-					Metadata:         serializedMetadata,
-				}
-				containerEnts = append(containerEnts, containerEnt)
-			} else {
-				if !strings.HasPrefix(fileName, prefix) && !strings.HasPrefix(prefix, fileName) {
-					continue
-				}
+	containerEnt := ContainerEntry{
+		Basename:         fileName,
+		FileSize:         statResult[StatSize],
+		ModificationTime: statResult[StatMTime],
+		NumWrites:        statResult[StatNumWrites],
+		InodeNumber:      statResult[StatINum],
+		IsDir:            false,
+		Metadata:         serializedMetadata,
+	}
+	listing.containerEnts = append(listing.containerEnts, containerEnt)
+	return
+}
 
-				// Directories are handled specially. For a directory
-				// "some-dir", we put an entry for "some-dir" in the
-				// container listing, then put "some-dir/" into
-				// recursiveDescents (note the trailing slash). This
-				// lets us put off the descent until we have handled
-				// all dirEnts coming before "some-dir/".
-				//
-				// For example, consider a filesystem with a dir "d",
-				// a file "d/f", and a file "d-README".
-				// Lexicographically, these would be ordered "d",
-				// "d-README", "d/f" ("-" is ASCII 45, "/" is ASCII
-				// 47). If we recursed into d immediately upon
-				// encountering it, we would have "d/f" before
-				// "d-README", which is not what the Swift API
-				// demands.
-				if fileName > marker && strings.HasPrefix(fileName, prefix) {
-					containerEnt := ContainerEntry{
-						Basename:         fileName,
-						FileSize:         0,
-						ModificationTime: statResult[StatMTime],
-						NumWrites:        statResult[StatNumWrites],
-						InodeNumber:      statResult[StatINum],
-						IsDir:            true,
-					}
-					containerEnts = append(containerEnts, containerEnt)
-				}
-				recursiveDescents = append(recursiveDescents, dirToDescend{path: fileName + "/", name: dirEnt.Basename + "/", ino: dirEnt.InodeNumber})
-			}
+// appendDir appends to the listing the entry of a directory named fileName.
+func (listing *containerListingStruct) appendDir(fileName string, statResult Stat) {
+	containerEnt := ContainerEntry{
+		Basename:         fileName,
+		FileSize:         0,
+		ModificationTime: statResult[StatMTime],
+		NumWrites:        statResult[StatNumWrites],
+		InodeNumber:      statResult[StatINum],
+		IsDir:            true,
+	}
+	listing.containerEnts = append(listing.containerEnts, containerEnt)
+}
+
+// listSubdir appends to the listing the subdir entry of descent (a directory not to be descended) if the
+// directory has any entries.
+func (listing *containerListingStruct) listSubdir(descent dirToDescend) (err error) {
+	mS := listing.mS
+
+	dirEnts, _, _, err := mS.Readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, descent.ino, "", 3, 0)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil // removed since listed
+		} else {
+			logger.ErrorfWithError(err, "MiddlewareGetContainer: error reading directory %s (inode %v)", descent.path, descent.ino)
 		}
-		return nil
+		return
 	}
-
-	err = recursiveReaddirPlus("", ino)
-	if err != nil {
-		// already logged
+	for _, dirEnt := range dirEnts {
+		if ("." == dirEnt.Basename) || (".." == dirEnt.Basename) {
+			continue
+		}
+		containerEnt := ContainerEntry{
+			Basename:    descent.path,
+			IsDir:       true,
+			InodeNumber: uint64(descent.ino),
+			IsSubdir:    true,
+		}
+		listing.containerEnts = append(listing.containerEnts, containerEnt)
 		return
 	}
-	stats.IncrementOperations(&stats.FsMwGetContainerOps)
 	return
 }
 
-func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, err error) {
+func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, etag string, contentType string, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	mS.breakLeasesOfPath(containerObjectPath, false) // see coherence.go
+
 	inodeNumber, inodeType, inodeLock, err := mS.resolvePathForRead(containerObjectPath, nil)
 	ino = uint64(inodeNumber)
 	if err != nil {
@@ -1547,6 +2662,11 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 		return
 	}
 
+	err = mS.volStruct.checkShareModes(inodeNumber, ShareRead, 0) // see share_mode.go
+	if nil != err {
+		return
+	}
+
 	// Find file size
 	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
 	if err != nil {
@@ -1556,8 +2676,15 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 	lastModified = uint64(metadata.ModificationTime.UnixNano())
 	numWrites = metadata.NumWrites
 
-	// If no ranges are given then get range of whole file.  Otherwise, get ranges.
-	if len(readRangeIn) == 0 {
+	readRanges, err := normalizeReadRanges(readRangeIn, fileSize) // see read_range.go
+	if nil != err {
+		return
+	}
+
+	readPlanStart := len(*readRangeOut)
+
+	// If no (satisfiable) ranges are given then get range of whole file.  Otherwise, get ranges.
+	if len(readRanges) == 0 {
 		// Get ReadPlan for file
 		volumeHandle, err1 := inode.FetchVolumeHandle(volumeName)
 		if err1 != nil {
@@ -1581,9 +2708,8 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 		}
 
 		// Get ReadPlan for each range and append physical path ranges to result
-		for i := range readRangeIn {
-			// TODO - verify that range request is within file size
-			tmpReadEnt, err1 := volumeHandle.GetReadPlan(inodeNumber, readRangeIn[i].Offset, readRangeIn[i].Len)
+		for i := range readRanges {
+			tmpReadEnt, err1 := volumeHandle.GetReadPlan(inodeNumber, &readRanges[i].offset, &readRanges[i].length)
 			if err1 != nil {
 				err = err1
 				return
@@ -1592,6 +2718,11 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 		}
 	}
 
+	err = mS.volStruct.checkReadPlanSegments((*readRangeOut)[readPlanStart:])
+	if nil != err {
+		return
+	}
+
 	serializedMetadata, err = mS.volStruct.VolumeHandle.GetStream(inodeNumber, MiddlewareStream)
 	// If someone makes a directory or file via SMB/FUSE and then
 	// accesses it via HTTP, we'll see StreamNotFound. We treat it as
@@ -1602,18 +2733,154 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 	} else {
 		err = nil
 	}
+
+	etag, err = mS.volStruct.fetchETag(inodeNumber, fileSize, numWrites)
+	if nil != err {
+		return
+	}
+
+	contentType, err = mS.volStruct.fetchContentType(inodeNumber) // see content_type.go
+	if nil != err {
+		return
+	}
+
 	stats.IncrementOperations(&stats.FsMwGetObjOps)
 	return
 }
 
 func (mS *mountStruct) MiddlewareHeadResponse(entityPath string) (response HeadResponse, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
 	ino, inoType, inoLock, err := mS.resolvePathForRead(entityPath, nil)
 	if err != nil {
 		return
 	}
 	defer inoLock.Unlock()
 
-	statResult, err := mS.getstatHelper(ino, inoLock.GetCallerID())
+	response, err = mS.middlewareHeadHelper(ino, inoType, inoLock.GetCallerID())
+	if err != nil {
+		return
+	}
+	stats.IncrementOperations(&stats.FsMwHeadResponseOps)
+	return
+}
+
+// MiddlewareHeadMultiple returns the HeadResponse (or error) for each of entityPaths (in order).
+//
+// Rather than traversing to (and locking) each entity's parent directory once per entity, entities
+// sharing a parent directory are all HEAD'd under a single traversal to (and read lock of) it. A
+// symlink is followed just as MiddlewareHeadResponse() would (after the parent's lock is released).
+func (mS *mountStruct) MiddlewareHeadMultiple(entityPaths []string) (responses []HeadResponse, errs []error) {
+	type headGroupStruct struct {
+		parentPath  string
+		basenames   []string
+		entityIndex []int // index into entityPaths (and responses & errs) of each of basenames
+	}
+
+	var (
+		headGroup     *headGroupStruct
+		headGroupList []*headGroupStruct
+		headGroupMap  = make(map[string]*headGroupStruct) // key == headGroupStruct.parentPath
+		ok            bool
+		singleList    []int // index into entityPaths of each entity to be individually HEAD'd
+	)
+
+	responses = make([]HeadResponse, len(entityPaths))
+	errs = make([]error, len(entityPaths))
+
+	for i, entityPath := range entityPaths {
+		cleanPath := path.Clean("/" + entityPath)
+		if "/" == cleanPath {
+			singleList = append(singleList, i)
+			continue
+		}
+		parentPath := path.Dir(cleanPath)
+		headGroup, ok = headGroupMap[parentPath]
+		if !ok {
+			headGroup = &headGroupStruct{parentPath: parentPath}
+			headGroupMap[parentPath] = headGroup
+			headGroupList = append(headGroupList, headGroup)
+		}
+		headGroup.basenames = append(headGroup.basenames, path.Base(cleanPath))
+		headGroup.entityIndex = append(headGroup.entityIndex, i)
+	}
+
+	for _, headGroup = range headGroupList {
+		symlinkList := mS.middlewareHeadGroup(headGroup.parentPath, headGroup.basenames, headGroup.entityIndex, responses, errs)
+		singleList = append(singleList, symlinkList...)
+	}
+
+	for _, i := range singleList {
+		responses[i], errs[i] = mS.MiddlewareHeadResponse(entityPaths[i])
+	}
+
+	stats.IncrementOperations(&stats.FsMwHeadMultipleOps)
+	return
+}
+
+// middlewareHeadGroup fills in responses & errs for each of basenames in the directory at parentPath.
+//
+// Entities that turn out to be symlinks are skipped; their entityIndex values are returned instead.
+func (mS *mountStruct) middlewareHeadGroup(parentPath string, basenames []string, entityIndex []int, responses []HeadResponse, errs []error) (symlinkList []int) {
+	callerID := dlm.GenerateCallerID()
+
+	dirInodeNumber, dirInodeType, dirInodeLock, err := mS.resolvePathForRead(parentPath, callerID)
+	if nil != err {
+		for _, i := range entityIndex {
+			errs[i] = err
+		}
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	if inode.DirType != dirInodeType {
+		for _, i := range entityIndex {
+			errs[i] = blunder.NewError(blunder.NotDirError, "%s is a file, not a directory", parentPath)
+		}
+		return
+	}
+
+	for j, basename := range basenames {
+		i := entityIndex[j]
+
+		ino, lookupErr := mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, basename)
+		if nil != lookupErr {
+			errs[i] = lookupErr
+			continue
+		}
+
+		inoLock, lockErr := mS.volStruct.ensureReadLock(ino, callerID)
+		if nil != lockErr {
+			errs[i] = lockErr
+			continue
+		}
+
+		inoType, typeErr := mS.volStruct.VolumeHandle.GetType(ino)
+		if nil != typeErr {
+			errs[i] = typeErr
+		} else if inode.SymlinkType == inoType {
+			symlinkList = append(symlinkList, i)
+		} else {
+			responses[i], errs[i] = mS.middlewareHeadHelper(ino, inoType, callerID)
+		}
+
+		if nil != inoLock {
+			inoLock.Unlock()
+		}
+	}
+
+	return
+}
+
+// middlewareHeadHelper builds the HeadResponse for ino.
+//
+// The caller must hold (at least) a read lock on ino.
+func (mS *mountStruct) middlewareHeadHelper(ino inode.InodeNumber, inoType inode.InodeType, callerID dlm.CallerID) (response HeadResponse, err error) {
+	statResult, err := mS.getstatHelper(ino, callerID)
 	if err != nil {
 		return
 	}
@@ -1630,24 +2897,55 @@ func (mS *mountStruct) MiddlewareHeadResponse(entityPath string) (response HeadR
 		// HEADs it via HTTP, we'll see this error. We treat it as
 		// though there is no metadata. The middleware is equipped to
 		// handle this case.
-		if blunder.Is(err, blunder.StreamNotFound) {
-			err = nil
+		if !blunder.Is(err, blunder.StreamNotFound) {
+			return
 		}
-		return
+		err = nil
+	}
+
+	if inode.FileType == inoType {
+		response.ETag, err = mS.volStruct.fetchETag(ino, response.FileSize, response.NumWrites)
+		if nil != err {
+			return
+		}
+		response.ContentType, err = mS.volStruct.fetchContentType(ino) // see content_type.go
 	}
-	stats.IncrementOperations(&stats.FsMwHeadResponseOps)
 	return
 }
 
 func (mS *mountStruct) MiddlewarePost(parentDir string, baseName string, newMetaData []byte, oldMetaData []byte) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(containerOfPath(parentDir, baseName)) // see freeze.go
+	defer exitContainers()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
 	// Find inode for container or object
 	fullPathName := parentDir + "/" + baseName
+	mS.breakLeasesOfPath(fullPathName, true) // see coherence.go
 	baseNameInodeNumber, _, baseInodeLock, err := mS.resolvePathForWrite(fullPathName, nil)
 	if err != nil {
 		return err
 	}
 	defer baseInodeLock.Unlock()
 
+	err = mS.volStruct.checkRetention(baseNameInodeNumber) // see retention.go
+	if nil != err {
+		return err
+	}
+	err = mS.volStruct.checkInodeFlags(baseNameInodeNumber) // see inode_flags.go
+	if nil != err {
+		return err
+	}
+
 	// Compare oldMetaData to existing existingStreamData to make sure that the HTTP metadata has not changed.
 	// If it has changed, then return an error since middleware has to handle it.
 	existingStreamData, err := mS.volStruct.VolumeHandle.GetStream(baseNameInodeNumber, MiddlewareStream)
@@ -1665,12 +2963,109 @@ func (mS *mountStruct) MiddlewarePost(parentDir string, baseName string, newMeta
 	// Change looks okay so make it.
 	err = mS.volStruct.VolumeHandle.PutStream(baseNameInodeNumber, MiddlewareStream, newMetaData)
 	mS.volStruct.untrackInFlightFileInodeData(baseNameInodeNumber, false)
+	if nil == err {
+		mS.volStruct.notifyInode(NotifySetAttr, baseNameInodeNumber)
+	}
 
 	stats.IncrementOperations(&stats.FsMwPostOps)
 	return err
 }
 
-func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string, makeInodeFunc func() (inode.InodeNumber, error)) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+// MiddlewareHeadAccount returns the HeadResponse of the account (i.e. the root directory) with, as its
+// Metadata, that of the account (kept in the root directory's AccountMetadataStream).
+func (mS *mountStruct) MiddlewareHeadAccount() (response HeadResponse, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	rootInodeLock, err := mS.volStruct.initInodeLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = rootInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	statResult, err := mS.getstatHelper(inode.RootDirInodeNumber, rootInodeLock.GetCallerID())
+	if nil != err {
+		return
+	}
+	response.ModificationTime = statResult[StatMTime]
+	response.IsDir = true
+	response.InodeNumber = inode.RootDirInodeNumber
+	response.NumWrites = statResult[StatNumWrites]
+
+	response.Metadata, err = mS.volStruct.VolumeHandle.GetStream(inode.RootDirInodeNumber, AccountMetadataStream)
+	if nil != err {
+		if blunder.IsNot(err, blunder.StreamNotFound) {
+			return
+		}
+		// No account metadata has been POSTed yet
+		response.Metadata = []byte{}
+		err = nil
+	}
+
+	stats.IncrementOperations(&stats.FsMwHeadAccountOps)
+	return
+}
+
+// MiddlewarePostAccount is MiddlewarePost() for the account, replacing its metadata (if still oldMetaData)
+// with newMetaData.
+func (mS *mountStruct) MiddlewarePostAccount(newMetaData []byte, oldMetaData []byte) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	rootInodeLock, err := mS.volStruct.initInodeLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = rootInodeLock.WriteLock()
+	if nil != err {
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	// As with MiddlewarePost(), make sure the metadata hasn't changed since the caller fetched it
+	existingStreamData, err := mS.volStruct.VolumeHandle.GetStream(inode.RootDirInodeNumber, AccountMetadataStream)
+	if err != nil && blunder.IsNot(err, blunder.StreamNotFound) {
+		return err
+	}
+	if err == nil && !bytes.Equal(existingStreamData, oldMetaData) {
+		return blunder.NewError(blunder.TryAgainError, "%s: MetaData different - existingStreamData: %v OldMetaData: %v.", utils.GetFnName(), existingStreamData, oldMetaData)
+	}
+
+	err = mS.volStruct.VolumeHandle.PutStream(inode.RootDirInodeNumber, AccountMetadataStream, newMetaData)
+	if nil == err {
+		mS.volStruct.notifyInode(NotifySetAttr, inode.RootDirInodeNumber)
+	}
+
+	stats.IncrementOperations(&stats.FsMwPostAccountOps)
+	return err
+}
+
+// putObjectHelper links the inode returned by makeInodeFunc() in at vContainerName/vObjectPath, creating any
+// missing intermediate directories and replacing whatever (file or empty directory) already occupies the
+// path. If non-nil, obstacleFunc() is first handed (locked) any such occupant and may instead claim it (e.g.
+// reusing an existing directory) or refuse the PUT; should it claim it, nothing is created and the occupant's
+// mtime/inode/numWrites are returned.
+func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string, makeInodeFunc func() (inode.InodeNumber, error), obstacleFunc func(obstacleInodeNumber inode.InodeNumber) (claimed bool, err error)) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+
+	err = mS.volStruct.validatePathNames(vObjectPath)
+	if err != nil {
+		return
+	}
 
 	// Find the inode of the directory corresponding to the container
 	dirInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, vContainerName)
@@ -1693,6 +3088,7 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 	// us.
 	var dirEntInodeLock *dlm.RWLockStruct
 	callerID := dlm.GenerateCallerID()
+	symlinkFollower := mS.volStruct.newSymlinkFollower(true)
 	dirInodeLock, err := mS.volStruct.getWriteLock(dirInodeNumber, callerID)
 	if err != nil {
 		return
@@ -1754,7 +3150,13 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 					return
 				}
 
-				if strings.HasPrefix(target, "/") {
+				target, fromRoot, err1 := symlinkFollower.follow(vContainerName+"/"+vObjectPath, target)
+				if err1 != nil {
+					err = err1
+					return
+				}
+
+				if fromRoot {
 					// Absolute symlink: restart traversal from the
 					// root directory.
 					dirInodeLock.Unlock()
@@ -1779,47 +3181,14 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 		}
 	}
 
-	// Now, dirInodeNumber is the inode of the lowest existing directory. Anything else is created by us and isn't part
-	// of the filesystem tree until we Link() it in, so we only need to hold this one lock. Call the inode-creator
-	// function and start linking stuff together.
-	fileInodeNumber, err = makeInodeFunc()
-	if err != nil {
-		return
-	}
-
-	highestUnlinkedInodeNumber := fileInodeNumber
-	highestUnlinkedName := vObjectBaseName
-	for i := 0; i < len(dirs); i++ {
-		newDirInodeNumber, err1 := mS.volStruct.VolumeHandle.CreateDir(inode.PosixModePerm, 0, 0)
-		if err1 != nil {
-			logger.DebugfIDWithError(internalDebug, err1, "mount.CreateDir(): %v failed!")
-			err = err1
-			return
-		}
-
-		err = mS.volStruct.VolumeHandle.Link(newDirInodeNumber, highestUnlinkedName, highestUnlinkedInodeNumber)
-		if err != nil {
-			logger.DebugfIDWithError(internalDebug, err, "mount.Link(%v, %v, %v) failed",
-				newDirInodeNumber, highestUnlinkedName, highestUnlinkedInodeNumber)
-			return
-		}
-		mS.volStruct.untrackInFlightFileInodeData(highestUnlinkedInodeNumber, false)
-
-		highestUnlinkedInodeNumber = newDirInodeNumber
-		highestUnlinkedName = dirs[i]
-	}
-
-	// Now we've got a pre-existing directory inode in dirInodeNumber,
-	// and highestUnlinked(Name,InodeNumber) indicate a thing we need
-	// to link into place. The last step is to make sure there's no
-	// obstacle to us doing that. Note that this is only required when
-	// all the necessary directories already exist; if we had to
-	// create any directories, then the bottom directory is empty
-	// because we just created it.
+	// Now, dirInodeNumber is the inode of the lowest existing directory. If all the necessary directories already
+	// exist, something may already occupy the path; lock it and see if obstacleFunc() wants it. Note that if we
+	// have to create any directories, then the bottom directory is empty because we just created it.
 	haveObstacle := false
 	var obstacleInodeNumber inode.InodeNumber
 	if 0 == len(dirs) {
-		obstacleInodeNumber, err1 := mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, vObjectBaseName)
+		var err1 error
+		obstacleInodeNumber, err1 = mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, vObjectBaseName)
 		if err1 != nil && blunder.Errno(err1) == int(blunder.NotFoundError) {
 			// File not found? Good!
 		} else if err1 != nil {
@@ -1836,15 +3205,107 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 			}
 			defer obstacleInodeLock.Unlock()
 
-			err = mS.removeObstacleToObjectPut(callerID, dirInodeNumber, vObjectBaseName, obstacleInodeNumber)
-			if err != nil {
+			// A protected file may not be replaced (see retention.go & inode_flags.go)
+			err = mS.volStruct.checkRetention(obstacleInodeNumber)
+			if nil == err {
+				err = mS.volStruct.checkInodeFlags(obstacleInodeNumber)
+			}
+			if nil == err {
+				err = mS.volStruct.checkShareModes(obstacleInodeNumber, ShareDelete, 0) // see share_mode.go
+			}
+			if nil != err {
 				return
 			}
-			// We're now responsible for destroying obstacleInode, but
-			// we're not going to do it yet. We'll wait to actually
-			// destroy the data until after we've linked in its
-			// replacement.
+
+			if nil != obstacleFunc {
+				claimed, err1 := obstacleFunc(obstacleInodeNumber)
+				if err1 != nil {
+					err = err1
+					return
+				}
+				if claimed {
+					fileInodeNumber = obstacleInodeNumber
+					metadata, err1 := mS.volStruct.VolumeHandle.GetMetadata(fileInodeNumber)
+					if err1 != nil {
+						err = err1
+						return
+					}
+					mtime = uint64(metadata.ModificationTime.UnixNano())
+					numWrites = metadata.NumWrites
+					return
+				}
+			}
+		}
+	}
+
+	// Anything else is created by us and isn't part of the filesystem tree until we Link() it in, so we only need
+	// to hold the locks we already have. Call the inode-creator function and create any missing directories.
+	fileInodeNumber, err = makeInodeFunc()
+	if err != nil {
+		return
+	}
+
+	// Track everything we create in intent so that, should we fail (or crash) before the final Link(), the
+	// chain is rolled back (or completed by recoverIntents()) rather than left unreachable.
+	intent := &putIntentStruct{
+		ParentInodeNumber: dirInodeNumber,
+		ChainInodeNumbers: []inode.InodeNumber{fileInodeNumber},
+		Basenames:         []string{vObjectBaseName},
+	}
+	for i := 0; i < len(dirs); i++ {
+		newDirInodeNumber, err1 := mS.volStruct.VolumeHandle.CreateDir(mS.volStruct.middlewareMode(), 0, 0)
+		if err1 != nil {
+			logger.DebugfIDWithError(internalDebug, err1, "mount.CreateDir(): %v failed!")
+			err = err1
+			_ = mS.volStruct.resolvePutIntent(intent, false)
+			return
+		}
+		intent.ChainInodeNumbers = append(intent.ChainInodeNumbers, newDirInodeNumber)
+		intent.Basenames = append(intent.Basenames, dirs[i])
+	}
+
+	// Now we've got a pre-existing directory inode in dirInodeNumber
+	// and a chain of new inodes we need to link into place (replacing
+	// any obstacle found above).
+	if haveObstacle {
+		intent.ObstacleInodeNumber = obstacleInodeNumber
+	}
+
+	// Journal the chain before linking any of it (or removing the obstacle)
+	intentID, err := mS.volStruct.recordPutIntent(intent)
+	if err != nil {
+		_ = mS.volStruct.resolvePutIntent(intent, false)
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = mS.volStruct.resolvePutIntent(intent, false)
+		}
+		mS.volStruct.clearPutIntent(intentID)
+	}()
+
+	for i := 0; i < len(dirs); i++ {
+		err = mS.volStruct.VolumeHandle.Link(intent.ChainInodeNumbers[i+1], intent.Basenames[i], intent.ChainInodeNumbers[i])
+		if err != nil {
+			logger.DebugfIDWithError(internalDebug, err, "mount.Link(%v, %v, %v) failed",
+				intent.ChainInodeNumbers[i+1], intent.Basenames[i], intent.ChainInodeNumbers[i])
+			return
+		}
+		mS.volStruct.untrackInFlightFileInodeData(intent.ChainInodeNumbers[i], false)
+	}
+
+	highestUnlinkedInodeNumber := intent.ChainInodeNumbers[len(dirs)]
+	highestUnlinkedName := intent.Basenames[len(dirs)]
+
+	if haveObstacle {
+		err = mS.removeObstacleToObjectPut(callerID, dirInodeNumber, vObjectBaseName, obstacleInodeNumber)
+		if err != nil {
+			return
 		}
+		// We're now responsible for destroying obstacleInode, but
+		// we're not going to do it yet. We'll wait to actually
+		// destroy the data until after we've linked in its
+		// replacement.
 	}
 
 	// If we got here, then there's no obstacle (any more). Link the thing into place.
@@ -1869,6 +3330,11 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 		return
 	}
 
+	if haveObstacle {
+		mS.volStruct.notifyName(NotifyUnlink, dirInodeNumber, vObjectBaseName, obstacleInodeNumber)
+	}
+	mS.volStruct.notifyName(NotifyCreate, dirInodeNumber, highestUnlinkedName, highestUnlinkedInodeNumber)
+
 	// Log errors from inode destruction, but don't let them cause the
 	// RPC call to fail. As far as this function's caller is
 	// concerned, everything worked as intended.
@@ -1877,6 +3343,7 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 		if destroyErr != nil {
 			logger.ErrorfWithError(destroyErr, "MiddlewarePutComplete: error destroying inode %v", obstacleInodeNumber)
 		}
+		mS.volStruct.forgetInodeIfDestroyed(obstacleInodeNumber) // see coherence.go
 	}
 
 	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(fileInodeNumber) // not getstat() since we're already holding a lock on this inode
@@ -1893,22 +3360,85 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 }
 
 func (mS *mountStruct) MiddlewarePutComplete(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	mtime, fileInodeNumber, numWrites, err = mS.MiddlewarePutCompleteConditional(vContainerName, vObjectPath, pObjectPaths, pObjectLengths, pObjectMetadata, PutPreconditions{})
+	return
+}
+
+func (mS *mountStruct) MiddlewarePutCompleteConditional(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte, preconditions PutPreconditions) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(vContainerName) // see freeze.go
+	defer exitContainers()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	if len(pObjectPaths) != len(pObjectLengths) {
+		err = blunder.NewError(blunder.InvalidArgError, "MiddlewarePutComplete() given %d pObjectPaths but %d pObjectLengths", len(pObjectPaths), len(pObjectLengths))
+		return
+	}
+
+	if mS.volStruct.isHeavyPutComplete(len(pObjectPaths)) {
+		err = mS.volStruct.admitHeavyOp()
+		if nil != err {
+			return
+		}
+		defer mS.volStruct.releaseHeavyOp()
+	}
+
+	mS.breakLeasesOfPath(vContainerName+"/"+vObjectPath, true) // see coherence.go
+
+	// Preconditions are checked against whatever occupies the path once it's locked... or, should nothing
+	// occupy it, just before the file is reified
+	var checkTheObstacle func(obstacleInodeNumber inode.InodeNumber) (claimed bool, err error)
+	sawObstacle := false
+	if (PutPreconditions{}) != preconditions {
+		checkTheObstacle = func(obstacleInodeNumber inode.InodeNumber) (claimed bool, err error) {
+			sawObstacle = true
+			err = mS.volStruct.checkPutPreconditions(preconditions, obstacleInodeNumber)
+			return
+		}
+	}
+
+	// Objects PUT into a container with a RetentionPolicy are committed to it at once (see retention.go)
+	retentionPolicy, err := mS.fetchContainerRetentionByName(vContainerName)
+	if err != nil {
+		return
+	}
 
 	reifyTheFile := func() (fileInodeNumber inode.InodeNumber, err error) {
+		if !sawObstacle {
+			err = mS.volStruct.checkPutPreconditions(preconditions, 0)
+			if err != nil {
+				return
+			}
+		}
+
 		// Reify the Swift object into a ProxyFS file by making a new,
 		// empty inode and then associating it with the log segment
 		// written by the middleware.
-		fileInodeNumber, err = mS.volStruct.VolumeHandle.CreateFile(inode.PosixModePerm, 0, 0)
+		fileInodeNumber, err = mS.volStruct.VolumeHandle.CreateFile(mS.volStruct.middlewareMode(), 0, 0)
 		if err != nil {
 			logger.DebugfIDWithError(internalDebug, err, "fs.CreateFile(): vContainerName: %v failed!", vContainerName)
 			return
 		}
 
-		// Associate fileInodeNumber with log segments written by Swift
+		// Associate fileInodeNumber with log segments written by Swift. Empty log segments are skipped
+		// as recording them would leave zero-length extents, so an empty object (i.e. one with no
+		// log segments or only empty ones) leaves the file at zero bytes without calling Wrote().
 		fileOffset := uint64(0) // Swift only writes whole files
 		pObjectOffset := uint64(0)
 		for i := 0; i < len(pObjectPaths); i++ {
-			err = mS.volStruct.VolumeHandle.Wrote(fileInodeNumber, fileOffset, pObjectPaths[i], pObjectOffset, pObjectLengths[i], i > 0)
+			if 0 == pObjectLengths[i] {
+				continue
+			}
+			err = mS.volStruct.VolumeHandle.Wrote(fileInodeNumber, fileOffset, pObjectPaths[i], pObjectOffset, pObjectLengths[i], 0 < fileOffset)
 			if err != nil {
 				logger.DebugfIDWithError(internalDebug, err, "mount.Wrote() fileInodeNumber: %v fileOffset: %v pOjectPaths: %v pObjectOffset: %v pObjectLengths: %v i: %v failed!",
 					fileInodeNumber, fileOffset, pObjectPaths, pObjectOffset, pObjectLengths, i)
@@ -1924,16 +3454,112 @@ func (mS *mountStruct) MiddlewarePutComplete(vContainerName string, vObjectPath
 				fileInodeNumber, pObjectMetadata)
 			return
 		}
+
+		err = mS.volStruct.stampRetention(fileInodeNumber, retentionPolicy, true)
+		if err != nil {
+			return
+		}
+
+		// Note the log segments to adopt upon the file's first Write() (see adopt.go)
+		adoptablePaths := make([]string, 0, len(pObjectPaths))
+		for i := 0; i < len(pObjectPaths); i++ {
+			if 0 != pObjectLengths[i] {
+				adoptablePaths = append(adoptablePaths, pObjectPaths[i])
+			}
+		}
+		err = mS.volStruct.recordAdoptable(fileInodeNumber, adoptablePaths)
+		if err != nil {
+			logger.DebugfIDWithError(internalDebug, err, "mount.recordAdoptable fileInodeNumber: %v failed", fileInodeNumber)
+			return
+		}
+		return
+	}
+
+	// Fence any file being replaced so that concurrent writers don't interleave with us
+	replacedInodeNumber, lookupErr := mS.lookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, vContainerName+"/"+vObjectPath)
+	if nil == lookupErr {
+		mS.volStruct.raiseReplaceFence(replacedInodeNumber)
+		defer mS.volStruct.lowerReplaceFence(replacedInodeNumber)
+	}
+
+	return putObjectHelper(mS, vContainerName, vObjectPath, reifyTheFile, checkTheObstacle)
+}
+
+// checkPutPreconditions fails with PreconditionFailed unless preconditions are met by obstacleInodeNumber,
+// the (locked) inode occupying the path being PUT (0 if unoccupied).
+func (vS *volumeStruct) checkPutPreconditions(preconditions PutPreconditions, obstacleInodeNumber inode.InodeNumber) (err error) {
+	ifMatch := preconditions.IfMatch || ("" != preconditions.IfMatchETag) || (0 != preconditions.IfMatchInodeNumber)
+
+	if 0 == obstacleInodeNumber {
+		if ifMatch {
+			stats.IncrementOperations(&stats.FsMwPutPreconditionFailedOps)
+			err = blunder.NewError(blunder.PreconditionFailed, "PUT is conditional upon an existing object, but there is none")
+		}
+		return
+	}
+
+	if preconditions.IfNoneMatch {
+		stats.IncrementOperations(&stats.FsMwPutPreconditionFailedOps)
+		err = blunder.NewError(blunder.PreconditionFailed, "PUT is conditional upon there being no existing object, but inode %v exists", obstacleInodeNumber)
+		return
+	}
+
+	if !ifMatch {
+		return
+	}
+
+	metadata, err := vS.VolumeHandle.GetMetadata(obstacleInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if inode.FileType != metadata.InodeType {
+		stats.IncrementOperations(&stats.FsMwPutPreconditionFailedOps)
+		err = blunder.NewError(blunder.PreconditionFailed, "PUT is conditional upon an existing object, but inode %v is not a file", obstacleInodeNumber)
+		return
+	}
+
+	if (0 != preconditions.IfMatchInodeNumber) && ((preconditions.IfMatchInodeNumber != obstacleInodeNumber) || (preconditions.IfMatchNumWrites != metadata.NumWrites)) {
+		stats.IncrementOperations(&stats.FsMwPutPreconditionFailedOps)
+		err = blunder.NewError(blunder.PreconditionFailed, "PUT is conditional upon inode %v with NumWrites %v, but found inode %v with NumWrites %v",
+			preconditions.IfMatchInodeNumber, preconditions.IfMatchNumWrites, obstacleInodeNumber, metadata.NumWrites)
 		return
 	}
 
-	return putObjectHelper(mS, vContainerName, vObjectPath, reifyTheFile)
+	if "" != preconditions.IfMatchETag {
+		etag, etagErr := vS.fetchETag(obstacleInodeNumber, metadata.Size, metadata.NumWrites)
+		if nil != etagErr {
+			err = etagErr
+			return
+		}
+		if preconditions.IfMatchETag != etag {
+			stats.IncrementOperations(&stats.FsMwPutPreconditionFailedOps)
+			err = blunder.NewError(blunder.PreconditionFailed, "PUT is conditional upon ETag %s, but inode %v has ETag \"%s\"", preconditions.IfMatchETag, obstacleInodeNumber, etag)
+			return
+		}
+	}
+
+	return
 }
 
 func (mS *mountStruct) MiddlewareMkdir(vContainerName string, vObjectPath string, metadata []byte) (mtime uint64, inodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(vContainerName) // see freeze.go
+	defer exitContainers()
+
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
 
 	createTheDirectory := func() (dirInodeNumber inode.InodeNumber, err error) {
-		dirInodeNumber, err = mS.volStruct.VolumeHandle.CreateDir(inode.PosixModePerm, 0, 0)
+		dirInodeNumber, err = mS.volStruct.VolumeHandle.CreateDir(mS.volStruct.middlewareMode(), 0, 0)
 		if err != nil {
 			logger.ErrorWithError(err)
 			return
@@ -1950,10 +3576,54 @@ func (mS *mountStruct) MiddlewareMkdir(vContainerName string, vObjectPath string
 		return
 	}
 
-	return putObjectHelper(mS, vContainerName, vObjectPath, createTheDirectory)
+	// A directory already at vObjectPath is kept (along with its contents) and merely takes on the new
+	// metadata, just as a repeated Mkdir of a Swift directory marker would; anything else is a conflict
+	// rather than something to silently replace.
+	claimTheDirectory := func(obstacleInodeNumber inode.InodeNumber) (claimed bool, err error) {
+		obstacleInodeType, err := mS.volStruct.VolumeHandle.GetType(obstacleInodeNumber)
+		if err != nil {
+			return
+		}
+		if obstacleInodeType != inode.DirType {
+			err = blunder.NewError(blunder.FileExistsError, "%s/%s exists and is not a directory", vContainerName, vObjectPath)
+			return
+		}
+
+		if len(metadata) > 0 {
+			err = mS.volStruct.VolumeHandle.PutStream(obstacleInodeNumber, MiddlewareStream, metadata)
+		} else {
+			err = mS.volStruct.VolumeHandle.DeleteStream(obstacleInodeNumber, MiddlewareStream)
+			if err != nil && blunder.Is(err, blunder.StreamNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return
+		}
+		mS.volStruct.notifyInode(NotifySetAttr, obstacleInodeNumber)
+
+		claimed = true
+		return
+	}
+
+	return putObjectHelper(mS, vContainerName, vObjectPath, createTheDirectory, claimTheDirectory)
 }
 
 func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata []byte, newMetadata []byte) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(containerName) // see freeze.go
+	defer exitContainers()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
 	var (
 		containerInodeLock   *dlm.RWLockStruct
 		containerInodeNumber inode.InodeNumber
@@ -1977,7 +3647,12 @@ func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata
 	} else if err != nil {
 		// No such container, so we create it
 
-		newDirInodeNumber, err = mS.volStruct.VolumeHandle.CreateDir(inode.PosixModePerm, 0, 0)
+		err = mS.volStruct.validateName(containerName)
+		if nil != err {
+			return
+		}
+
+		newDirInodeNumber, err = mS.volStruct.VolumeHandle.CreateDir(mS.volStruct.middlewareMode(), 0, 0)
 		if err != nil {
 			logger.ErrorWithError(err)
 			return
@@ -1993,6 +3668,9 @@ func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata
 		}
 
 		err = mS.volStruct.VolumeHandle.Link(inode.RootDirInodeNumber, containerName, newDirInodeNumber)
+		if nil == err {
+			mS.volStruct.notifyName(NotifyCreate, inode.RootDirInodeNumber, containerName, newDirInodeNumber)
+		}
 
 		return
 	}
@@ -2019,19 +3697,41 @@ func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata
 		return
 	}
 	err = mS.volStruct.VolumeHandle.PutStream(containerInodeNumber, MiddlewareStream, newMetadata)
+	if nil == err {
+		mS.volStruct.notifyName(NotifySetAttr, inode.RootDirInodeNumber, containerName, containerInodeNumber)
+	}
 
 	stats.IncrementOperations(&stats.FsMwPutContainerOps)
 	return
 }
 
 func (mS *mountStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (newDirInodeNumber inode.InodeNumber, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() { mS.noteNameHistory(inodeNumber, historyOpMkdir, basename, newDirInodeNumber, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterEntries(freezeEntryStruct{inodeNumber, basename}) // see freeze.go
+	defer exitInodes()
+
 	// Make sure the file basename is not too long
-	err = validateBaseName(basename)
+	err = mS.volStruct.validateName(basename)
 	if err != nil {
 		return 0, err
 	}
 
-	newDirInodeNumber, err = mS.volStruct.VolumeHandle.CreateDir(filePerm, userID, groupID)
+	dirGroupID, dirMode := mS.inheritFromDir(userID, groupID, otherGroupIDs, inodeNumber, inode.DirType, mS.createMode(filePerm))
+	newDirInodeNumber, err = mS.volStruct.VolumeHandle.CreateDir(dirMode, userID, dirGroupID)
 	if err != nil {
 		logger.ErrorWithError(err)
 		return 0, err
@@ -2072,11 +3772,301 @@ func (mS *mountStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 		}
 		return 0, err
 	}
+	mS.volStruct.notifyName(NotifyCreate, inodeNumber, basename, newDirInodeNumber)
+
 	stats.IncrementOperations(&stats.FsMkdirOps)
 	return newDirInodeNumber, nil
 }
 
+// Mknod creates a regular file, FIFO, or Unix socket as selected by the file type bits of mode.
+//
+// A mode with no file type bits creates a regular file (as for Create()). Character and block devices
+// are not supported.
+func (mS *mountStruct) Mknod(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, mode inode.InodeMode) (inodeNumber inode.InodeNumber, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	var inodeType inode.InodeType
+
+	switch mode & inode.PosixModeType {
+	case 0, inode.PosixModeFile:
+		return mS.create(userID, groupID, otherGroupIDs, dirInodeNumber, basename, mode&inode.PosixModeBits)
+	case inode.PosixModeFIFO:
+		inodeType = inode.FIFOType
+	case inode.PosixModeSocket:
+		inodeType = inode.SocketType
+	default:
+		err = blunder.NewError(blunder.NotPermError, "mknod of mode 0%o not supported", mode)
+		return
+	}
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() { mS.noteNameHistory(dirInodeNumber, historyOpMknod, basename, inodeNumber, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterEntries(freezeEntryStruct{dirInodeNumber, basename}) // see freeze.go
+	defer exitInodes()
+
+	err = mS.volStruct.validateName(basename)
+	if err != nil {
+		return
+	}
+
+	// Lock the directory inode (or just basename's shard of it) before doing the link
+	dirEntryLock, err := mS.volStruct.getDirEntryLock(dirInodeNumber, basename, nil)
+	if err != nil {
+		return
+	}
+	defer dirEntryLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.W_OK|inode.X_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	specialGroupID, specialMode := mS.inheritFromDir(userID, groupID, otherGroupIDs, dirInodeNumber, inodeType, mS.createMode(mode&inode.PosixModePerm))
+	inodeNumber, err = mS.volStruct.VolumeHandle.CreateSpecial(inodeType, specialMode, userID, specialGroupID)
+	if err != nil {
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.Link(dirInodeNumber, basename, inodeNumber)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(inodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed Link() in fs.Mknod", inodeNumber)
+		}
+		inodeNumber = 0
+		return
+	}
+
+	mS.volStruct.notifyName(NotifyCreate, dirInodeNumber, basename, inodeNumber)
+
+	stats.IncrementOperations(&stats.FsMknodOps)
+	return
+}
+
+// PinPath pins the file or directory at fullpath (and, for a directory, its entire subtree)
+// into the inode and Read Caches so that it is exempt from eviction. Pinned data counts
+// against the Read Cache's memory budget; pinnedBytes reports how much was newly pinned.
+//
+// The pin covers the data present at the time of the call. Files subsequently written
+// or added to a pinned subtree are not pinned until PinPath() is called again.
+//
+// As pinned data is exempt from eviction at the expense of every other user of the volume,
+// only root may pin (or unpin); others fail with NotPermError (EPERM).
+func (mS *mountStruct) PinPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (pinnedBytes uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if inode.InodeRootUserID != userID {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+
+	inodeNumber, err := mS.lookupPath(userID, groupID, otherGroupIDs, fullpath)
+	if nil != err {
+		return
+	}
+
+	pinnedBytes, err = mS.pinTree(userID, groupID, otherGroupIDs, inodeNumber, true)
+
+	stats.IncrementOperations(&stats.FsPinOps)
+	return
+}
+
+// UnpinPath reverses a prior PinPath() of fullpath.
+func (mS *mountStruct) UnpinPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if inode.InodeRootUserID != userID {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+
+	inodeNumber, err := mS.lookupPath(userID, groupID, otherGroupIDs, fullpath)
+	if nil != err {
+		return
+	}
+
+	_, err = mS.pinTree(userID, groupID, otherGroupIDs, inodeNumber, false)
+
+	stats.IncrementOperations(&stats.FsUnpinOps)
+	return
+}
+
+// pinTree pins (or unpins) inodeNumber and, if it is a directory, all of its descendants (see descend.go).
+func (mS *mountStruct) pinTree(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, pin bool) (pinnedBytes uint64, err error) {
+	level := &pinTreeLevelStruct{mS: mS, userID: userID, groupID: groupID, otherGroupIDs: otherGroupIDs, pin: pin, pinnedBytes: &pinnedBytes}
+
+	level.childInodeNumbers, _, err = level.pinInode(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.descendTree(level)
+	return
+}
+
+// pinTreeLevelStruct is the state of a pinTree() visiting one directory.
+type pinTreeLevelStruct struct {
+	mS                *mountStruct
+	userID            inode.InodeUserID
+	groupID           inode.InodeGroupID
+	otherGroupIDs     []inode.InodeGroupID
+	pin               bool
+	pinnedBytes       *uint64
+	childInodeNumbers []inode.InodeNumber // yet to be pinned (or unpinned)
+	pendingAdded      bool                // if true, childInodeNumbers have been counted by tD.addPending()
+}
+
+// pinInode pins (or unpins) inodeNumber returning, if it is a directory, its children.
+func (level *pinTreeLevelStruct) pinInode(inodeNumber inode.InodeNumber) (childInodeNumbers []inode.InodeNumber, isDir bool, err error) {
+	var (
+		dirEntrySlice []inode.DirEntry
+		inodeType     inode.InodeType
+		pinnedBytes   uint64
+	)
+
+	mS := level.mS
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if err != nil {
+		return
+	}
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, level.userID, level.groupID, level.otherGroupIDs, inode.F_OK) {
+		inodeLock.Unlock()
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, level.userID, level.groupID, level.otherGroupIDs, inode.R_OK) {
+		inodeLock.Unlock()
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	if level.pin {
+		pinnedBytes, err = mS.volStruct.VolumeHandle.Pin(inodeNumber)
+	} else {
+		err = mS.volStruct.VolumeHandle.Unpin(inodeNumber)
+	}
+	if nil != err {
+		inodeLock.Unlock()
+		return
+	}
+	*level.pinnedBytes += pinnedBytes
+
+	inodeType, err = mS.volStruct.VolumeHandle.GetType(inodeNumber)
+	isDir = (nil == err) && (inode.DirType == inodeType)
+	if isDir {
+		dirEntrySlice, _, err = mS.volStruct.VolumeHandle.ReadDir(inodeNumber, 0, 0)
+	}
+
+	inodeLock.Unlock()
+
+	if nil != err {
+		return
+	}
+
+	childInodeNumbers = make([]inode.InodeNumber, 0, len(dirEntrySlice))
+	for _, dirEntry := range dirEntrySlice {
+		if ("." != dirEntry.Basename) && (".." != dirEntry.Basename) {
+			childInodeNumbers = append(childInodeNumbers, dirEntry.InodeNumber)
+		}
+	}
+
+	return
+}
+
+func (level *pinTreeLevelStruct) next(tD *treeDescentStruct) (subLevel treeDescentLevel, err error) {
+	if !level.pendingAdded {
+		level.pendingAdded = true
+		err = tD.addPending(len(level.childInodeNumbers))
+		if nil != err {
+			return
+		}
+	}
+
+	for 0 < len(level.childInodeNumbers) {
+		childInodeNumber := level.childInodeNumbers[0]
+		level.childInodeNumbers = level.childInodeNumbers[1:]
+		err = tD.addPending(-1)
+		if nil != err {
+			return
+		}
+
+		childLevel := &pinTreeLevelStruct{mS: level.mS, userID: level.userID, groupID: level.groupID, otherGroupIDs: level.otherGroupIDs, pin: level.pin, pinnedBytes: level.pinnedBytes}
+
+		var childIsDir bool
+
+		childLevel.childInodeNumbers, childIsDir, err = childLevel.pinInode(childInodeNumber)
+		if nil != err {
+			if blunder.Is(err, blunder.NotFoundError) {
+				// Removed since we read the directory... simply skip it
+				err = nil
+				continue
+			}
+			return
+		}
+
+		if childIsDir {
+			subLevel = childLevel
+			return
+		}
+	}
+
+	return
+}
+
 func (mS *mountStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpRemoveXAttr, Name: streamName}, err)
+	}()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2096,9 +4086,21 @@ func (mS *mountStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.Inode
 		return
 	}
 
+	if isReservedStream(inodeNumber, streamName) {
+		err = blunder.NewError(blunder.StreamNotFound, "ENODATA")
+		return
+	}
+
+	err = mS.checkXAttrNamespace(userID, inodeNumber, streamName, true)
+	if nil != err {
+		return
+	}
+
 	err = mS.volStruct.VolumeHandle.DeleteStream(inodeNumber, streamName)
 	if err != nil {
 		logger.ErrorfWithError(err, "Failed to delete XAttr %v of inode %v", streamName, inodeNumber)
+	} else {
+		mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
 	}
 
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
@@ -2107,88 +4109,221 @@ func (mS *mountStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.Inode
 	return
 }
 
-func (mS *mountStruct) Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (err error) {
+// tryLockExchangedInodes write-locks the inodes referenced by srcBasename & dstBasename as a RenameExchange
+// modifies them both (e.g. a directory's ".." entry). Since the caller holds the directory locks, only a
+// TryWriteLock() is attempted; a TryAgainError means the caller must drop all of its locks and retry.
+func (mS *mountStruct) tryLockExchangedInodes(callerID dlm.CallerID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (inodeLockList []*dlm.RWLockStruct, err error) {
+	type entryStruct struct {
+		dirInodeNumber inode.InodeNumber
+		basename       string
+	}
+
+	for _, entry := range []entryStruct{{srcDirInodeNumber, srcBasename}, {dstDirInodeNumber, dstBasename}} {
+		inodeNumber, lookupErr := mS.volStruct.VolumeHandle.Lookup(entry.dirInodeNumber, entry.basename)
+		if nil != lookupErr {
+			continue // Move() will report this
+		}
+		if (srcDirInodeNumber == inodeNumber) || (dstDirInodeNumber == inodeNumber) {
+			continue // already locked
+		}
+
+		inodeLock, lockErr := mS.volStruct.initInodeLock(inodeNumber, callerID)
+		if nil != lockErr {
+			err = lockErr
+			break
+		}
+		if inodeLock.IsWriteHeld() {
+			continue // both entries reference the same inode
+		}
+		err = inodeLock.TryWriteLock()
+		if nil != err {
+			break
+		}
+		inodeLockList = append(inodeLockList, inodeLock)
+	}
+
+	if nil != err {
+		for _, inodeLock := range inodeLockList {
+			inodeLock.Unlock()
+		}
+		inodeLockList = nil
+	}
+
+	return
+}
+
+func (mS *mountStruct) Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags RenameFlags) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.rename(userID, groupID, otherGroupIDs, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags)
+	return
+}
+
+// rename is Rename() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags RenameFlags) (err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(srcDirInodeNumber, historyRecordStruct{Op: historyOpRenameFrom, Name: srcBasename, OtherName: dstBasename, InodeNumber: dstDirInodeNumber}, err)
+		if dstDirInodeNumber != srcDirInodeNumber {
+			mS.noteHistory(dstDirInodeNumber, historyRecordStruct{Op: historyOpRenameTo, Name: srcBasename, OtherName: dstBasename, InodeNumber: srcDirInodeNumber}, err)
+		}
+	}()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	// A Rename() between directories or of a container holds renameLock exclusively (see rename.go)
+	renaming := (srcDirInodeNumber != dstDirInodeNumber) || (inode.RootDirInodeNumber == srcDirInodeNumber)
+	exitInodes := mS.admitMutation(renaming, []freezeEntryStruct{{srcDirInodeNumber, srcBasename}, {dstDirInodeNumber, dstBasename}}) // see freeze.go
+	defer exitInodes()
+
 	err = validateBaseName(srcBasename)
 	if err != nil {
 		return
 	}
 
-	err = validateBaseName(dstBasename)
+	err = mS.volStruct.validateName(dstBasename)
 	if err != nil {
 		return
 	}
 
+	var moveFlags inode.MoveFlags
+	if 0 != flags&^(RenameNoReplace|RenameExchange) {
+		err = blunder.NewError(blunder.InvalidArgError, "EINVAL")
+		return
+	}
+	if 0 != flags&RenameNoReplace {
+		moveFlags |= inode.MoveNoReplace
+	}
+	if 0 != flags&RenameExchange {
+		moveFlags |= inode.MoveExchange
+	}
+
+	mS.volStruct.breakLeasesOfName(mS.id, srcDirInodeNumber, srcBasename, true) // see coherence.go
+	mS.volStruct.breakLeasesOfName(mS.id, dstDirInodeNumber, dstBasename, true)
+
 	// Flag to tell us if there's only one directory to be locked
 	srcAndDestDirsAreSame := srcDirInodeNumber == dstDirInodeNumber
 
 	// Generate our calling context ID, so that the locks will have the same callerID
 	callerID := dlm.GenerateCallerID()
 
-	// Allocate the source dir lock
-	srcDirLock, err := mS.volStruct.initInodeLock(srcDirInodeNumber, callerID)
+	// Allocate the directory locks in the order they must be obtained
+	dirLocks, err := mS.volStruct.renameDirLocks(callerID, srcDirInodeNumber, dstDirInodeNumber)
 	if err != nil {
 		return
 	}
 
-	// Allocate the dest dir lock
-	dstDirLock, err := mS.volStruct.initInodeLock(dstDirInodeNumber, callerID)
-	if err != nil {
-		return err
+	unlockDirs := func() {
+		for i := len(dirLocks) - 1; i >= 0; i-- {
+			dirLocks[i].Unlock()
+		}
 	}
 
-retryLock:
-	// Get the source directory's lock
-	err = srcDirLock.WriteLock()
-	if err != nil {
-		return
-	}
+	// Should an exchanged inode's lock be unavailable, drop all of the locks and try again
+	err = mS.volStruct.retryLockConflicts(func() (retriable bool, err error) {
+		// Get the directories' locks
+		for i, dirLock := range dirLocks {
+			err = dirLock.WriteLock()
+			if err != nil {
+				for j := i - 1; j >= 0; j-- {
+					dirLocks[j].Unlock()
+				}
+				return
+			}
+		}
 
-	if !mS.volStruct.VolumeHandle.Access(srcDirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
-		srcDirLock.Unlock()
-		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
-		return
-	}
-	if !mS.volStruct.VolumeHandle.Access(srcDirInodeNumber, userID, groupID, otherGroupIDs, inode.W_OK|inode.X_OK) {
-		srcDirLock.Unlock()
-		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
-		return
-	}
+		for _, dirInodeNumber := range []inode.InodeNumber{srcDirInodeNumber, dstDirInodeNumber} {
+			if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+				unlockDirs()
+				err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+				return
+			}
+			if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.W_OK|inode.X_OK) {
+				unlockDirs()
+				err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+				return
+			}
+		}
 
-	// Try to get the destination directory's lock. If we can't get it, drop the
-	// source dir lock and try the whole thing again.
-	if !srcAndDestDirsAreSame {
-		err = dstDirLock.TryWriteLock()
-		if blunder.Is(err, blunder.TryAgainError) {
-			srcDirLock.Unlock()
-			goto retryLock
-		} else if blunder.IsNotSuccess(err) {
-			// This shouldn't happen...
-			srcDirLock.Unlock()
-			return err
+		// A Rename() between directories mustn't move a directory beneath itself (see rename.go)
+		if !srcAndDestDirsAreSame {
+			err = mS.volStruct.checkRenameCycle(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, 0 != flags&RenameExchange)
+			if nil != err {
+				unlockDirs()
+				return
+			}
 		}
 
-		if !mS.volStruct.VolumeHandle.Access(dstDirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
-			srcDirLock.Unlock()
-			dstDirLock.Unlock()
-			err = blunder.NewError(blunder.NotFoundError, "ENOENT")
-			return
+		// The sticky bit may forbid removing either name from its directory
+		err = mS.checkRestrictedDeletionOfName(userID, srcDirInodeNumber, srcBasename)
+		if nil == err {
+			err = mS.checkRestrictedDeletionOfName(userID, dstDirInodeNumber, dstBasename)
 		}
-		if !mS.volStruct.VolumeHandle.Access(dstDirInodeNumber, userID, groupID, otherGroupIDs, inode.W_OK|inode.X_OK) {
-			srcDirLock.Unlock()
-			dstDirLock.Unlock()
-			err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+
+		// As may retention (see retention.go)
+		if nil == err {
+			err = mS.volStruct.checkRetentionOfName(srcDirInodeNumber, srcBasename)
+		}
+		if nil == err {
+			err = mS.volStruct.checkRetentionOfName(dstDirInodeNumber, dstBasename)
+		}
+
+		// As may immutable & append-only flags (see inode_flags.go)
+		if nil == err {
+			err = mS.volStruct.checkInodeFlagsOfName(srcDirInodeNumber, srcBasename)
+		}
+		if nil == err {
+			err = mS.volStruct.checkInodeFlagsOfName(dstDirInodeNumber, dstBasename)
+		}
+
+		// As may opens denying deletion (see share_mode.go)
+		if nil == err {
+			err = mS.volStruct.checkShareModesOfName(srcDirInodeNumber, srcBasename, ShareDelete)
+		}
+		if nil == err {
+			err = mS.volStruct.checkShareModesOfName(dstDirInodeNumber, dstBasename, ShareDelete)
+		}
+		if nil != err {
+			unlockDirs()
 			return
 		}
-	}
 
-	// Now we have the locks for both directories; we can do the move
-	err = mS.volStruct.VolumeHandle.Move(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename)
+		// An exchange also needs the locks for both of the inodes being exchanged
+		var exchangeLockList []*dlm.RWLockStruct
+		if 0 != flags&RenameExchange {
+			exchangeLockList, err = mS.tryLockExchangedInodes(callerID, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename)
+			if nil != err {
+				unlockDirs()
+				retriable = blunder.Is(err, blunder.TryAgainError)
+				return
+			}
+		}
+
+		// Now we have the locks for both directories; we can do the move
+		err = mS.volStruct.VolumeHandle.Move(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, moveFlags)
+		if nil == err {
+			mS.volStruct.notifyRename(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename)
+			if 0 != flags&RenameExchange {
+				mS.volStruct.notifyRename(dstDirInodeNumber, dstBasename, srcDirInodeNumber, srcBasename)
+			}
+		}
 
-	// Release our locks and return
-	if !srcAndDestDirsAreSame {
-		dstDirLock.Unlock()
-	}
-	srcDirLock.Unlock()
+		// Release our locks and return
+		for _, exchangeLock := range exchangeLockList {
+			exchangeLock.Unlock()
+		}
+		unlockDirs()
+
+		return
+	})
 
 	// TODO: Where is the lock on the potentially removed fileInode overwritten by the Move() call?
 
@@ -2197,6 +4332,84 @@ retryLock:
 }
 
 func (mS *mountStruct) Read(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error) {
+	return mS.ReadWithFlockPid(userID, groupID, otherGroupIDs, inodeNumber, 0, offset, length, profiler)
+}
+
+func (mS *mountStruct) ReadWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	buf, err = mS.readWithFlockPid(userID, groupID, otherGroupIDs, inodeNumber, flockPid, offset, length, profiler)
+	return
+}
+
+// readWithFlockPid is ReadWithFlockPid() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) readWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error) {
+	buf, err = mS.read(userID, groupID, otherGroupIDs, inodeNumber, flockPid, offset, length, nil, profiler)
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsReadOps)
+	return
+}
+
+func (mS *mountStruct) ReadInto(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, dst []byte, profiler *utils.Profiler) (size uint64, err error) {
+	return mS.ReadIntoWithFlockPid(userID, groupID, otherGroupIDs, inodeNumber, 0, offset, dst, profiler)
+}
+
+func (mS *mountStruct) ReadIntoWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, dst []byte, profiler *utils.Profiler) (size uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	if nil == dst {
+		dst = []byte{} // distinguishes ReadInto() from Read() in mS.read()
+	}
+
+	buf, err := mS.read(userID, groupID, otherGroupIDs, inodeNumber, flockPid, offset, uint64(len(dst)), dst, profiler)
+	if nil != err {
+		return
+	}
+
+	size = uint64(len(buf))
+
+	stats.IncrementOperations(&stats.FsReadIntoOps)
+	return
+}
+
+// read implements ReadWithFlockPid() and, if dst is non-nil, ReadIntoWithFlockPid() (in which case buf
+// is a prefix of dst).
+func (mS *mountStruct) read(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, length uint64, dst []byte, profiler *utils.Profiler) (buf []byte, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		return mS.snapshotRead(userID, groupID, otherGroupIDs, inodeNumber, offset, length, dst, profiler)
+	}
+
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
+	if 0 < length {
+		length = mS.volStruct.capBytes(length) // a short read is permitted
+	}
+
+	mS.volStruct.breakLeasesForAccess(mS.id, inodeNumber, false) // see coherence.go
+
+	err = mS.volStruct.awaitMandatoryLock(inodeNumber, flockPid, syscall.F_RDLCK, offset, length)
+	if nil != err {
+		return
+	}
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2216,6 +4429,11 @@ func (mS *mountStruct) Read(userID inode.InodeUserID, groupID inode.InodeGroupID
 		return
 	}
 
+	err = mS.volStruct.checkShareModes(inodeNumber, ShareRead, flockPid) // see share_mode.go
+	if nil != err {
+		return
+	}
+
 	inodeType, err := mS.volStruct.VolumeHandle.GetType(inodeNumber)
 	if err != nil {
 		logger.ErrorfWithError(err, "couldn't get type for inode %v", inodeNumber)
@@ -2228,20 +4446,57 @@ func (mS *mountStruct) Read(userID inode.InodeUserID, groupID inode.InodeGroupID
 		return buf, blunder.AddError(err, blunder.NotFileError)
 	}
 
-	profiler.AddEventNow("before inode.Read()")
-	buf, err = mS.volStruct.VolumeHandle.Read(inodeNumber, offset, length, profiler)
-	profiler.AddEventNow("after inode.Read()")
+	if nil == dst {
+		profiler.AddEventNow("before inode.Read()")
+		buf, err = mS.volStruct.VolumeHandle.Read(inodeNumber, offset, length, profiler)
+		profiler.AddEventNow("after inode.Read()")
+	} else {
+		var n uint64
+		profiler.AddEventNow("before inode.ReadInto()")
+		n, err = mS.volStruct.VolumeHandle.ReadInto(inodeNumber, offset, dst[:length], profiler)
+		profiler.AddEventNow("after inode.ReadInto()")
+		buf = dst[:n]
+	}
 	if uint64(len(buf)) > length {
 		err = fmt.Errorf("%s: Buf length %v is greater than supplied length %v", utils.GetFnName(), uint64(len(buf)), length)
 		logger.ErrorWithError(err)
 		return buf, blunder.AddError(err, blunder.IOError)
 	}
+	if nil == err {
+		mS.noteRead(uint64(len(buf)))
+	}
 
-	stats.IncrementOperations(&stats.FsReadOps)
 	return buf, err
 }
 
 func (mS *mountStruct) Readdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevBasenameReturned string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, numEntries uint64, areMoreEntries bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	entries, numEntries, areMoreEntries, err = mS.readdir(userID, groupID, otherGroupIDs, inodeNumber, prevBasenameReturned, maxEntries, maxBufSize)
+	return
+}
+
+// readdir is Readdir() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) readdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevBasenameReturned string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, numEntries uint64, areMoreEntries bool, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		entries, areMoreEntries, err = mS.snapshotReaddir(userID, groupID, otherGroupIDs, inodeNumber, prevBasenameReturned, maxEntries, maxBufSize)
+		numEntries = uint64(len(entries))
+		stats.IncrementOperations(&stats.FsReaddirOps)
+		return
+	}
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2264,10 +4519,30 @@ func (mS *mountStruct) Readdir(userID inode.InodeUserID, groupID inode.InodeGrou
 	stats.IncrementOperations(&stats.FsReaddirOps)
 
 	// Call readdir helper function to do the work
-	return mS.readdirHelper(inodeNumber, prevBasenameReturned, maxEntries, maxBufSize, inodeLock.GetCallerID())
+	return mS.readdirHelper(inodeNumber, prevBasenameReturned, "", maxEntries, maxBufSize, inodeLock.GetCallerID())
 }
 
 func (mS *mountStruct) ReaddirOne(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation) (entries []inode.DirEntry, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		entries, _, err = mS.snapshotReaddir(userID, groupID, otherGroupIDs, inodeNumber, prevDirLocation, 1, 0)
+		stats.IncrementOperations(&stats.FsReaddirOneOps)
+		return
+	}
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return entries, err
@@ -2301,6 +4576,31 @@ func (mS *mountStruct) ReaddirOne(userID inode.InodeUserID, groupID inode.InodeG
 }
 
 func (mS *mountStruct) ReaddirPlus(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevBasenameReturned string, maxEntries uint64, maxBufSize uint64) (dirEntries []inode.DirEntry, statEntries []Stat, numEntries uint64, areMoreEntries bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		dirEntries, areMoreEntries, err = mS.snapshotReaddir(userID, groupID, otherGroupIDs, inodeNumber, prevBasenameReturned, maxEntries, maxBufSize)
+		if nil != err {
+			return
+		}
+		numEntries = uint64(len(dirEntries))
+		statEntries, err = mS.snapshotReaddirPlus(dirEntries)
+		stats.IncrementOperations(&stats.FsReaddirPlusOps)
+		return
+	}
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2322,7 +4622,7 @@ func (mS *mountStruct) ReaddirPlus(userID inode.InodeUserID, groupID inode.Inode
 	}
 
 	// Get dir entries; Call readdir helper function to do the work
-	dirEntries, numEntries, areMoreEntries, err = mS.readdirHelper(inodeNumber, prevBasenameReturned, maxEntries, maxBufSize, inodeLock.GetCallerID())
+	dirEntries, numEntries, areMoreEntries, err = mS.readdirHelper(inodeNumber, prevBasenameReturned, "", maxEntries, maxBufSize, inodeLock.GetCallerID())
 	inodeLock.Unlock()
 
 	if err != nil {
@@ -2330,26 +4630,11 @@ func (mS *mountStruct) ReaddirPlus(userID inode.InodeUserID, groupID inode.Inode
 		return dirEntries, statEntries, numEntries, areMoreEntries, err
 	}
 
-	// Get stats
-	statEntries = make([]Stat, numEntries)
-	for i := range dirEntries {
-		entryInodeLock, err1 := mS.volStruct.initInodeLock(dirEntries[i].InodeNumber, nil)
-		if err = err1; err != nil {
-			return
-		}
-		err = entryInodeLock.ReadLock()
-		if err != nil {
-			return
-		}
-
-		// Fill in stats, calling getstat helper function to do the work
-		statEntries[i], err = mS.getstatHelper(dirEntries[i].InodeNumber, entryInodeLock.GetCallerID())
-		entryInodeLock.Unlock()
-
-		if err != nil {
-			logger.ErrorWithError(err)
-			return dirEntries, statEntries, numEntries, areMoreEntries, err
-		}
+	// Get stats (see readdir_plus.go)
+	statEntries, err = mS.readdirPlusStats(dirEntries)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return dirEntries, statEntries, numEntries, areMoreEntries, err
 	}
 
 	stats.IncrementOperations(&stats.FsReaddirPlusOps)
@@ -2357,6 +4642,30 @@ func (mS *mountStruct) ReaddirPlus(userID inode.InodeUserID, groupID inode.Inode
 }
 
 func (mS *mountStruct) ReaddirOnePlus(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation) (dirEntries []inode.DirEntry, statEntries []Stat, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		dirEntries, _, err = mS.snapshotReaddir(userID, groupID, otherGroupIDs, inodeNumber, prevDirLocation, 1, 0)
+		if nil != err {
+			return
+		}
+		statEntries, err = mS.snapshotReaddirPlus(dirEntries)
+		stats.IncrementOperations(&stats.FsReaddirOnePlusOps)
+		return
+	}
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2420,6 +4729,24 @@ func (mS *mountStruct) ReaddirOnePlus(userID inode.InodeUserID, groupID inode.In
 }
 
 func (mS *mountStruct) Readsymlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (target string, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if isSnapshotInodeNumber(inodeNumber) {
+		return mS.snapshotReadsymlink(userID, groupID, otherGroupIDs, inodeNumber)
+	}
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2445,6 +4772,31 @@ func (mS *mountStruct) Readsymlink(userID inode.InodeUserID, groupID inode.Inode
 }
 
 func (mS *mountStruct) Resize(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, newSize uint64) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() { mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpResize, Length: newSize}, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
+	err = mS.volStruct.awaitReplaceFence(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.breakLeasesForAccess(mS.id, inodeNumber, true) // see coherence.go
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2464,13 +4816,64 @@ func (mS *mountStruct) Resize(userID inode.InodeUserID, groupID inode.InodeGroup
 		return
 	}
 
+	err = mS.volStruct.checkRetention(inodeNumber) // see retention.go
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.checkInodeFlags(inodeNumber) // see inode_flags.go
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.checkShareModes(inodeNumber, ShareWrite, 0) // see share_mode.go
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.preserveVersion(inodeNumber, newSize) // see version.go
+	if nil != err {
+		return
+	}
+
 	err = mS.volStruct.VolumeHandle.SetSize(inodeNumber, newSize)
+	if nil == err {
+		err = mS.clearSetID(inodeNumber)
+	}
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
+	if nil == err {
+		mS.volStruct.notifyInode(NotifyWrite, inodeNumber)
+	}
 	stats.IncrementOperations(&stats.FsSetsizeOps)
 	return err
 }
 
 func (mS *mountStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.rmdir(userID, groupID, otherGroupIDs, inodeNumber, basename)
+	return
+}
+
+// rmdir is Rmdir() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) rmdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	var basenameInodeNumber inode.InodeNumber
+	defer func() { mS.noteNameHistory(inodeNumber, historyOpRmdir, basename, basenameInodeNumber, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterEntries(freezeEntryStruct{inodeNumber, basename}) // see freeze.go
+	defer exitInodes()
+
 	callerID := dlm.GenerateCallerID()
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, callerID)
 	if err != nil {
@@ -2491,7 +4894,7 @@ func (mS *mountStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return
 	}
 
-	basenameInodeNumber, err := mS.volStruct.VolumeHandle.Lookup(inodeNumber, basename)
+	basenameInodeNumber, err = mS.volStruct.VolumeHandle.Lookup(inodeNumber, basename)
 	if nil != err {
 		return
 	}
@@ -2511,6 +4914,16 @@ func (mS *mountStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return
 	}
 
+	err = mS.checkRestrictedDeletion(userID, inodeNumber, basenameInodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.checkInodeFlags(basenameInodeNumber) // see inode_flags.go
+	if nil != err {
+		return
+	}
+
 	basenameInodeType, err := mS.volStruct.VolumeHandle.GetType(basenameInodeNumber)
 	if nil != err {
 		return
@@ -2533,21 +4946,59 @@ func (mS *mountStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return
 	}
 
-	err = mS.volStruct.VolumeHandle.Unlink(inodeNumber, basename)
+	if trashDirInodeNumber, enabled := mS.volStruct.fetchTrashDir(); enabled {
+		err = mS.volStruct.trashInode(callerID, trashDirInodeNumber, inodeNumber, basename, basenameInodeNumber)
+		if nil != err {
+			return
+		}
+	} else {
+		err = mS.volStruct.VolumeHandle.Unlink(inodeNumber, basename)
+		if nil != err {
+			return
+		}
+
+		err = mS.volStruct.VolumeHandle.Destroy(basenameInodeNumber)
+		if nil != err {
+			return
+		}
+	}
+
+	mS.volStruct.notifyName(NotifyUnlink, inodeNumber, basename, basenameInodeNumber)
+
+	stats.IncrementOperations(&stats.FsRmdirOps)
+	return
+}
+
+func (mS *mountStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, stat Stat) (err error) {
+	_, err = mS.SetstatAtomic(userID, groupID, otherGroupIDs, inodeNumber, stat)
+	return
+}
+
+// SetstatAtomic applies all of the attributes in stat or, should any be rejected, none of them. The
+// attributes individually rejected (e.g. StatSize of a directory) are returned in rejected (in StatKey
+// order) along with the error of the first of them. Refusals applying to the inode as a whole (e.g.
+// missing permission or a retained file) return only err.
+func (mS *mountStruct) SetstatAtomic(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, stat Stat) (rejected []StatKey, err error) {
+	err = mS.enterOp()
 	if nil != err {
 		return
 	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
 
-	err = mS.volStruct.VolumeHandle.Destroy(basenameInodeNumber)
+	defer func() { mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpSetstat}, err) }()
+
+	err = mS.checkWritable()
 	if nil != err {
 		return
 	}
 
-	stats.IncrementOperations(&stats.FsRmdirOps)
-	return
-}
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
+	mS.volStruct.breakLeasesForAccess(mS.id, inodeNumber, true) // see coherence.go
 
-func (mS *mountStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, stat Stat) (err error) {
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2562,45 +5013,31 @@ func (mS *mountStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGrou
 		err = blunder.NewError(blunder.NotPermError, "EPERM")
 		return
 	}
-	_, ok := stat[StatSize]
-	if ok {
-		if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK) {
-			err = blunder.NewError(blunder.NotPermError, "EPERM")
-			return
-		}
+
+	err = mS.volStruct.checkRetention(inodeNumber) // see retention.go
+	if nil != err {
+		return
 	}
 
-	// Set crtime, if present in the map
-	crtime, ok := stat[StatCRTime]
-	if ok {
-		newCreationTime := time.Unix(0, int64(crtime))
-		err = mS.volStruct.VolumeHandle.SetCreationTime(inodeNumber, newCreationTime)
-		if err != nil {
-			logger.ErrorWithError(err)
-			return err
-		}
+	err = mS.volStruct.checkInodeFlags(inodeNumber) // see inode_flags.go
+	if nil != err {
+		return
 	}
 
-	// Set mtime, if present in the map
-	mtime, ok := stat[StatMTime]
-	if ok {
-		newModificationTime := time.Unix(0, int64(mtime))
-		err = mS.volStruct.VolumeHandle.SetModificationTime(inodeNumber, newModificationTime)
-		if err != nil {
-			logger.ErrorWithError(err)
-			return err
-		}
+	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
 	}
 
-	// Set atime, if present in the map
-	atime, ok := stat[StatATime]
-	if ok {
-		newAccessTime := time.Unix(0, int64(atime))
-		err = mS.volStruct.VolumeHandle.SetAccessTime(inodeNumber, newAccessTime)
-		if err != nil {
-			logger.ErrorWithError(err)
-			return err
+	// Validate each attribute (in StatKey order) before applying any of them
+
+	attrs := &inode.SetAttrsStruct{}
+
+	reject := func(statKey StatKey, rejectErr error) {
+		if nil == rejected {
+			err = rejectErr
 		}
+		rejected = append(rejected, statKey)
 	}
 
 	// ctime is used to reliably determine whether the contents of a file
@@ -2608,78 +5045,133 @@ func (mS *mountStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGrou
 	// software depends on this)
 	ctime, ok := stat[StatCTime]
 	if ok {
-		newAccessTime := time.Unix(0, int64(ctime))
+		newAttrChangeTime := time.Unix(0, int64(ctime))
 		logger.Info("%s: ignoring attempt to change ctime to %v on volume '%s' inode %v",
-			utils.GetFnName(), newAccessTime, mS.volStruct.volumeName, inodeNumber)
+			utils.GetFnName(), newAttrChangeTime, mS.volStruct.volumeName, inodeNumber)
+	}
+
+	crtime, ok := stat[StatCRTime]
+	if ok {
+		attrs.SetCreationTime = true
+		attrs.CreationTime = time.Unix(0, int64(crtime))
+	}
+
+	mtime, ok := stat[StatMTime]
+	if ok {
+		attrs.SetModificationTime = true
+		attrs.ModificationTime = time.Unix(0, int64(mtime))
+	}
+
+	atime, ok := stat[StatATime]
+	if ok {
+		attrs.SetAccessTime = true
+		attrs.AccessTime = time.Unix(0, int64(atime))
 	}
 
-	// Set size, if present in the map
 	size, ok := stat[StatSize]
 	if ok {
-		err = mS.volStruct.VolumeHandle.SetSize(inodeNumber, size)
-		if err != nil {
-			logger.ErrorWithError(err)
-			return err
+		if inode.FileType != metadata.InodeType {
+			reject(StatSize, blunder.NewError(blunder.NotFileError, "%s: cannot set size of inode %d type %v",
+				utils.GetFnName(), inodeNumber, metadata.InodeType))
+		} else if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK) {
+			reject(StatSize, blunder.NewError(blunder.NotPermError, "EPERM"))
+		} else if shareErr := mS.volStruct.checkShareModes(inodeNumber, ShareWrite, 0); nil != shareErr { // see share_mode.go
+			reject(StatSize, shareErr)
+		} else {
+			attrs.SetSize = true
+			attrs.Size = size
+		}
+	}
+
+	// Set mode, if present in the map
+	filePerm, ok := stat[StatMode]
+	if ok {
+		// Since we are using a uint64 to convey a uint32 value, make sure we didn't get something too big
+		if filePerm > math.MaxUint32 {
+			reject(StatMode, blunder.NewError(blunder.InvalidFileModeError, "%s: filePerm is too large - value is %d, max is %d.",
+				utils.GetFnName(), filePerm, uint64(math.MaxUint32)))
+		} else {
+			attrs.SetPermMode = true
+			attrs.PermMode = mS.allowedMode(inode.InodeMode(filePerm))
 		}
 	}
 
-	// Set userID, if present in the map
-	//
 	// TODO: only root can do this (unless the userid is not changing, in which case its OK) --craig
-	newUserID, settingUserID := stat[StatUserID]
-	if settingUserID {
+	newUserID, ok := stat[StatUserID]
+	if ok {
 		// Since we are using a uint64 to convey a uint32 value, make sure we didn't get something too big
 		if newUserID > math.MaxUint32 {
-			err = fmt.Errorf("%s: userID is too large - value is %d, max is %d.", utils.GetFnName(), newUserID, math.MaxUint32)
-			return blunder.AddError(err, blunder.InvalidUserIDError)
+			reject(StatUserID, blunder.NewError(blunder.InvalidUserIDError, "%s: userID is too large - value is %d, max is %d.",
+				utils.GetFnName(), newUserID, uint64(math.MaxUint32)))
+		} else {
+			attrs.SetUserID = true
+			attrs.UserID = inode.InodeUserID(newUserID)
+			if nil != mS.idMap {
+				attrs.UserID = mS.idMap.mapUserID(attrs.UserID)
+			}
 		}
 	}
 
-	// Set groupID, if present in the map
-	//
 	// TODO: any user can change a file to a different group in their group list,
 	// but only root can change to a group not in the group list. --craig
-	newGroupID, settingGroupID := stat[StatGroupID]
-	if settingGroupID {
+	newGroupID, ok := stat[StatGroupID]
+	if ok {
 		// Since we are using a uint64 to convey a uint32 value, make sure we didn't get something too big
 		if newGroupID > math.MaxUint32 {
-			err = fmt.Errorf("%s: groupID is too large - value is %d, max is %d.", utils.GetFnName(), newGroupID, math.MaxUint32)
-			return blunder.AddError(err, blunder.InvalidGroupIDError)
+			reject(StatGroupID, blunder.NewError(blunder.InvalidGroupIDError, "%s: groupID is too large - value is %d, max is %d.",
+				utils.GetFnName(), newGroupID, uint64(math.MaxUint32)))
+		} else {
+			attrs.SetGroupID = true
+			attrs.GroupID = inode.InodeGroupID(newGroupID)
+			if nil != mS.idMap {
+				attrs.GroupID = mS.idMap.mapGroupID(attrs.GroupID)
+			}
 		}
 	}
 
-	if settingUserID || settingGroupID {
-		if settingUserID {
-			if settingGroupID {
-				err = mS.volStruct.VolumeHandle.SetOwnerUserIDGroupID(inodeNumber, inode.InodeUserID(newUserID), inode.InodeGroupID(newGroupID))
-			} else { // only settingUserID is true
-				err = mS.volStruct.VolumeHandle.SetOwnerUserID(inodeNumber, inode.InodeUserID(newUserID))
-			}
-		} else { // only settingGroupID is true
-			err = mS.volStruct.VolumeHandle.SetOwnerGroupID(inodeNumber, inode.InodeGroupID(newGroupID))
-		}
+	if nil != rejected {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	// Truncating or chown'ing may clear setuid & setgid bits (see mount_options.go)
+	clearingSetID := false
+	if (attrs.SetSize || attrs.SetUserID || attrs.SetGroupID) && !attrs.SetPermMode &&
+		(0 != mS.options&MountNoSuid) && (0 != metadata.Mode&inode.PosixModeSetID) {
+		clearingSetID = true
+		attrs.SetPermMode = true
+		attrs.PermMode = metadata.Mode & inode.PosixModeBits &^ inode.PosixModeSetID
+	}
+
+	if attrs.SetSize {
+		err = mS.volStruct.preserveVersion(inodeNumber, attrs.Size) // see version.go
 		if nil != err {
 			logger.ErrorWithError(err)
-			return err
+			return
 		}
 	}
 
-	// Set mode, if present in the map
-	filePerm, ok := stat[StatMode]
-	if ok {
-		// Since we are using a uint64 to convey a uint32 value, make sure we didn't get something too big
-		if filePerm > math.MaxUint32 {
-			err = fmt.Errorf("%s: filePerm is too large - value is %d, max is %d.", utils.GetFnName(), filePerm, math.MaxUint32)
-			return blunder.AddError(err, blunder.InvalidFileModeError)
-		}
+	err = mS.volStruct.VolumeHandle.SetAttrs(inodeNumber, attrs)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
 
-		err = mS.volStruct.VolumeHandle.SetPermMode(inodeNumber, inode.InodeMode(filePerm))
-		if err != nil {
+	if clearingSetID {
+		stats.IncrementOperations(&stats.FsNoSuidSetIDClearedOps)
+	}
+
+	// Removing all write permission commits a file to its pending retention (see retention.go)
+	if attrs.SetPermMode && !clearingSetID && (0 == (attrs.PermMode & posixModeWriteBits)) {
+		err = mS.volStruct.commitRetention(inodeNumber)
+		if nil != err {
 			logger.ErrorWithError(err)
-			return err
+			return
 		}
 	}
 
+	mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
+
 	stats.IncrementOperations(&stats.FsSetstatOps)
 	return
 }
@@ -2691,6 +5183,24 @@ const (
 )
 
 func (mS *mountStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, value []byte, flags int) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() { mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpSetXAttr, Name: streamName}, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2701,12 +5211,8 @@ func (mS *mountStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 	}
 	defer inodeLock.Unlock()
 
-	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
-		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
-		return
-	}
-	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK) {
-		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+	err = mS.checkSetXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName, value)
+	if nil != err {
 		return
 	}
 
@@ -2714,12 +5220,12 @@ func (mS *mountStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 	case 0:
 		break
 	case xattr_create:
-		_, err = mS.GetXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName)
+		_, err = mS.getXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName)
 		if err == nil {
 			return blunder.AddError(err, blunder.FileExistsError)
 		}
 	case xattr_replace:
-		_, err = mS.GetXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName)
+		_, err = mS.getXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName)
 		if err != nil {
 			return blunder.AddError(err, blunder.StreamNotFound)
 		}
@@ -2727,38 +5233,199 @@ func (mS *mountStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 		return blunder.AddError(err, blunder.InvalidArgError)
 	}
 
+	err = mS.putXAttr(inodeNumber, streamName, value)
+
+	stats.IncrementOperations(&stats.FsSetXattrOps)
+	return
+}
+
+// SetXAttrIfMatch is SetXAttr() that only sets streamName to newValue if its current value is oldValue
+// (or, if oldValue is nil, if it is not currently set), failing with TryAgainError otherwise. As the
+// comparison and update are made under the inode's write lock, concurrent read-modify-write cycles
+// of an XAttr cannot lose each others' updates.
+func (mS *mountStruct) SetXAttrIfMatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, oldValue []byte, newValue []byte) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpSetXAttrIfMatch, Name: streamName}, err)
+	}()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.WriteLock()
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	err = mS.checkSetXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName, newValue)
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsSetXattrIfMatchOps)
+
+	existingValue, err := mS.volStruct.VolumeHandle.GetStream(inodeNumber, streamName)
+	if nil == err {
+		if (nil == oldValue) || !bytes.Equal(existingValue, oldValue) {
+			stats.IncrementOperations(&stats.FsSetXattrIfMatchMismatchOps)
+			err = blunder.NewError(blunder.TryAgainError, "XAttr %s of inode %v does not have the expected value", streamName, inodeNumber)
+			return
+		}
+	} else if blunder.Is(err, blunder.StreamNotFound) {
+		if nil != oldValue {
+			stats.IncrementOperations(&stats.FsSetXattrIfMatchMismatchOps)
+			err = blunder.NewError(blunder.TryAgainError, "XAttr %s of inode %v is not set", streamName, inodeNumber)
+			return
+		}
+	} else {
+		return
+	}
+
+	err = mS.putXAttr(inodeNumber, streamName, newValue)
+	return
+}
+
+// checkSetXAttr applies the access, reservation, limit, and namespace checks of setting streamName of
+// inodeNumber to value. Caller must hold the inode's write lock.
+func (mS *mountStruct) checkSetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, value []byte) (err error) {
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	if isReservedStream(inodeNumber, streamName) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	err = mS.volStruct.checkXAttrLimits(streamName, value)
+	if nil != err {
+		return
+	}
+
+	err = mS.checkXAttrNamespace(userID, inodeNumber, streamName, true)
+	return
+}
+
+// putXAttr sets streamName of inodeNumber to value. Caller must hold the inode's write lock.
+func (mS *mountStruct) putXAttr(inodeNumber inode.InodeNumber, streamName string, value []byte) (err error) {
 	err = mS.volStruct.VolumeHandle.PutStream(inodeNumber, streamName, value)
 	if err != nil {
 		logger.ErrorfWithError(err, "Failed to set XAttr %v to inode %v", streamName, inodeNumber)
+	} else {
+		mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
 	}
 
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
+	return
+}
+
+func (mS *mountStruct) StatVfs() (statVFS StatVFS, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	statVFS, err = mS.statVfs()
+	return
+}
+
+// statVfs is StatVfs() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) statVfs() (statVFS StatVFS, err error) {
+	statVFS = make(map[StatVFSKey]uint64)
+
+	totalBlocks, freeBlocks, availBlocks, totalInodes, freeInodes, availInodes := mS.volStruct.fetchCapacity()
+
+	statVFS[StatVFSFilesystemID] = mS.volStruct.VolumeHandle.GetFSID()
+	statVFS[StatVFSBlockSize] = FsBlockSize
+	statVFS[StatVFSFragmentSize] = FsOptimalTransferSize
+	statVFS[StatVFSTotalBlocks] = totalBlocks
+	statVFS[StatVFSFreeBlocks] = freeBlocks
+	statVFS[StatVFSAvailBlocks] = availBlocks
+	statVFS[StatVFSTotalInodes] = totalInodes
+	statVFS[StatVFSFreeInodes] = freeInodes
+	statVFS[StatVFSAvailInodes] = availInodes
+	statVFS[StatVFSMountFlags] = 0
+	statVFS[StatVFSMaxFilenameLen] = FileNameMax
+
+	stats.IncrementOperations(&stats.FsStatvfsOps)
+	return statVFS, nil
+}
+
+// StatVfsAt is StatVfs() reporting the capacity available to the subtree containing inodeNumber
+// (see usage.go).
+func (mS *mountStruct) StatVfsAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (statVFS StatVFS, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsStatvfsAtOps)
+
+	statVFS, err = mS.statVfs()
+	return
+}
+
+func (mS *mountStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, target string) (symlinkInodeNumber inode.InodeNumber, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
 
-	stats.IncrementOperations(&stats.FsSetXattrOps)
-	return
-}
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
 
-func (mS *mountStruct) StatVfs() (statVFS StatVFS, err error) {
-	statVFS = make(map[StatVFSKey]uint64)
+	defer func() { mS.noteNameHistory(inodeNumber, historyOpSymlink, basename, symlinkInodeNumber, err) }()
 
-	statVFS[StatVFSFilesystemID] = mS.volStruct.VolumeHandle.GetFSID()
-	statVFS[StatVFSBlockSize] = FsBlockSize
-	statVFS[StatVFSFragmentSize] = FsOptimalTransferSize
-	statVFS[StatVFSTotalBlocks] = VolFakeTotalBlocks
-	statVFS[StatVFSFreeBlocks] = VolFakeFreeBlocks
-	statVFS[StatVFSAvailBlocks] = VolFakeAvailBlocks
-	statVFS[StatVFSTotalInodes] = VolFakeTotalInodes
-	statVFS[StatVFSFreeInodes] = VolFakeAvailInodes
-	statVFS[StatVFSAvailInodes] = VolFakeAvailInodes
-	statVFS[StatVFSMountFlags] = 0
-	statVFS[StatVFSMaxFilenameLen] = FileNameMax
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
 
-	stats.IncrementOperations(&stats.FsStatvfsOps)
-	return statVFS, nil
-}
+	exitInodes := mS.enterEntries(freezeEntryStruct{inodeNumber, basename}) // see freeze.go
+	defer exitInodes()
 
-func (mS *mountStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, target string) (symlinkInodeNumber inode.InodeNumber, err error) {
-	err = validateBaseName(basename)
+	err = mS.volStruct.validateName(basename)
 	if err != nil {
 		return
 	}
@@ -2769,7 +5436,8 @@ func (mS *mountStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGrou
 	}
 
 	// Mode for symlinks defaults to rwxrwxrwx, i.e. inode.PosixModePerm
-	symlinkInodeNumber, err = mS.volStruct.VolumeHandle.CreateSymlink(target, inode.PosixModePerm, userID, groupID)
+	symlinkGroupID, _ := mS.inheritFromDir(userID, groupID, otherGroupIDs, inodeNumber, inode.SymlinkType, inode.PosixModePerm)
+	symlinkInodeNumber, err = mS.volStruct.VolumeHandle.CreateSymlink(target, inode.PosixModePerm, userID, symlinkGroupID)
 	if err != nil {
 		return
 	}
@@ -2810,21 +5478,46 @@ func (mS *mountStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGrou
 		return
 	}
 
+	mS.volStruct.notifyName(NotifyCreate, inodeNumber, basename, symlinkInodeNumber)
+
 	stats.IncrementOperations(&stats.FsSymlinkOps)
 	return
 }
 
 func (mS *mountStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error) {
-	callerID := dlm.GenerateCallerID()
-	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, callerID)
-	if err != nil {
+	err = mS.enterOp()
+	if nil != err {
 		return
 	}
-	err = inodeLock.WriteLock()
+	defer mS.exitOp(&err)
+
+	err = mS.unlink(userID, groupID, otherGroupIDs, inodeNumber, basename)
+	return
+}
+
+// unlink is Unlink() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) unlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	var basenameInodeNumber inode.InodeNumber
+	defer func() { mS.noteNameHistory(inodeNumber, historyOpUnlink, basename, basenameInodeNumber, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterEntries(freezeEntryStruct{inodeNumber, basename}) // see freeze.go
+	defer exitInodes()
+
+	mS.volStruct.breakLeasesOfName(mS.id, inodeNumber, basename, true) // see coherence.go
+
+	callerID := dlm.GenerateCallerID()
+	dirEntryLock, err := mS.volStruct.getUnlinkLock(inodeNumber, basename, callerID)
 	if err != nil {
 		return
 	}
-	defer inodeLock.Unlock()
+	defer dirEntryLock.Unlock()
 
 	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
 		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
@@ -2835,7 +5528,7 @@ func (mS *mountStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGroup
 		return
 	}
 
-	basenameInodeNumber, err := mS.volStruct.VolumeHandle.Lookup(inodeNumber, basename)
+	basenameInodeNumber, err = mS.volStruct.VolumeHandle.Lookup(inodeNumber, basename)
 	if nil != err {
 		return
 	}
@@ -2850,6 +5543,24 @@ func (mS *mountStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGroup
 	}
 	defer basenameInodeLock.Unlock()
 
+	err = mS.checkRestrictedDeletion(userID, inodeNumber, basenameInodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.checkRetention(basenameInodeNumber) // see retention.go
+	if nil != err {
+		return
+	}
+	err = mS.volStruct.checkInodeFlags(basenameInodeNumber) // see inode_flags.go
+	if nil != err {
+		return
+	}
+	err = mS.volStruct.checkShareModes(basenameInodeNumber, ShareDelete, 0) // see share_mode.go
+	if nil != err {
+		return
+	}
+
 	basenameInodeType, err := mS.volStruct.VolumeHandle.GetType(basenameInodeNumber)
 	if nil != err {
 		return
@@ -2861,11 +5572,45 @@ func (mS *mountStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGroup
 		return
 	}
 
-	err = mS.volStruct.VolumeHandle.Unlink(inodeNumber, basename)
+	// Removing the last name of a file in a volume with a trash moves it there instead (see trash.go)
+
+	if trashDirInodeNumber, enabled := mS.volStruct.fetchTrashDir(); enabled {
+		basenameLinkCount, nonShadowingErr := mS.volStruct.VolumeHandle.GetLinkCount(basenameInodeNumber)
+		if nil != nonShadowingErr {
+			err = nonShadowingErr
+			return
+		}
+
+		if 1 == basenameLinkCount {
+			err = mS.volStruct.trashInode(callerID, trashDirInodeNumber, inodeNumber, basename, basenameInodeNumber)
+			if nil != err {
+				return
+			}
+
+			mS.volStruct.notifyName(NotifyUnlink, inodeNumber, basename, basenameInodeNumber)
+
+			stats.IncrementOperations(&stats.FsUnlinkOps)
+			return
+		}
+	}
+
+	// Unlink() and Destroy() may fail ambiguously, so each is retried (once) guarded by the generation
+	// of basename's inode lest the retry undo someone else's work (see inode/generation.go)
+
+	basenameGeneration, err := mS.volStruct.VolumeHandle.GetGeneration(basenameInodeNumber)
 	if nil != err {
 		return
 	}
 
+	_, err = mS.volStruct.VolumeHandle.UnlinkGeneration(inodeNumber, basename, basenameInodeNumber, basenameGeneration)
+	if nil != err {
+		stats.IncrementOperations(&stats.FsUnlinkRetryOps)
+		_, err = mS.volStruct.VolumeHandle.UnlinkGeneration(inodeNumber, basename, basenameInodeNumber, basenameGeneration)
+		if nil != err {
+			return
+		}
+	}
+
 	basenameLinkCount, err := mS.volStruct.VolumeHandle.GetLinkCount(basenameInodeNumber)
 	if nil != err {
 		return
@@ -2873,17 +5618,31 @@ func (mS *mountStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGroup
 
 	if 0 == basenameLinkCount {
 		mS.volStruct.untrackInFlightFileInodeData(basenameInodeNumber, false)
-		err = mS.volStruct.VolumeHandle.Destroy(basenameInodeNumber)
+		_, err = mS.volStruct.VolumeHandle.DestroyGeneration(basenameInodeNumber, basenameGeneration)
 		if nil != err {
-			return
+			stats.IncrementOperations(&stats.FsUnlinkRetryOps)
+			_, err = mS.volStruct.VolumeHandle.DestroyGeneration(basenameInodeNumber, basenameGeneration)
+			if nil != err {
+				return
+			}
 		}
+		mS.volStruct.destroyVersions(basenameInodeNumber)
+		mS.volStruct.forgetInodeIfDestroyed(basenameInodeNumber) // see coherence.go
 	}
 
+	mS.volStruct.notifyName(NotifyUnlink, inodeNumber, basename, basenameInodeNumber)
+
 	stats.IncrementOperations(&stats.FsUnlinkOps)
 	return
 }
 
 func (mS *mountStruct) Validate(inodeNumber inode.InodeNumber) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
 	err = mS.Validate(inodeNumber)
 	if err != nil {
 		return err
@@ -2899,10 +5658,60 @@ func (mS *mountStruct) VolumeName() (volumeName string) {
 }
 
 func (mS *mountStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error) {
+	return mS.WriteWithFlockPid(userID, groupID, otherGroupIDs, inodeNumber, 0, offset, buf, profiler)
+}
+
+func (mS *mountStruct) WriteWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	size, err = mS.writeHelper(userID, groupID, otherGroupIDs, inodeNumber, flockPid, offset, false, buf, profiler)
+	return
+}
+
+// writeHelper performs Write() on behalf of flockPid. If appendMode, offset is ignored and buf is
+// instead written at the file's Size as of obtaining the inode's write lock. Caller has already mapped IDs.
+func (mS *mountStruct) writeHelper(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, offset uint64, appendMode bool, buf []byte, profiler *utils.Profiler) (size uint64, err error) {
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpWrite, Offset: offset, Length: uint64(len(buf))}, err)
+	}()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
 
 	logger.Tracef("fs.Write(): starting volume '%s' inode %d offset %d len %d",
 		mS.volStruct.volumeName, inodeNumber, offset, len(buf))
 
+	err = mS.volStruct.awaitReplaceFence(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if appendMode {
+		// The mandatory lock check can only consider where the file ends now
+		metadata, metadataErr := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+		if nil == metadataErr {
+			offset = metadata.Size
+		}
+	}
+
+	mS.volStruct.breakLeasesForAccess(mS.id, inodeNumber, true) // see coherence.go
+
+	err = mS.volStruct.awaitMandatoryLock(inodeNumber, flockPid, syscall.F_WRLCK, offset, uint64(len(buf)))
+	if nil != err {
+		return
+	}
+
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -2922,6 +5731,35 @@ func (mS *mountStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return
 	}
 
+	err = mS.volStruct.checkRetention(inodeNumber) // see retention.go
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.checkInodeFlagsForWrite(inodeNumber, offset, appendMode) // see inode_flags.go
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.checkShareModes(inodeNumber, ShareWrite, flockPid) // see share_mode.go
+	if nil != err {
+		return
+	}
+
+	if appendMode {
+		metadata, metadataErr := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+		if nil != metadataErr {
+			err = metadataErr
+			return
+		}
+		offset = metadata.Size
+	} else {
+		err = mS.volStruct.preserveVersion(inodeNumber, offset) // see version.go
+		if nil != err {
+			return
+		}
+	}
+
 	profiler.AddEventNow("before inode.Write()")
 	err = mS.volStruct.VolumeHandle.Write(inodeNumber, offset, buf, profiler)
 	profiler.AddEventNow("after inode.Write()")
@@ -2930,22 +5768,136 @@ func (mS *mountStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return 0, err
 	}
 
+	err = mS.clearSetID(inodeNumber)
+	if nil != err {
+		return 0, err
+	}
+
 	logger.Tracef("fs.Write(): tracking write volume '%s' inode %d", mS.volStruct.volumeName, inodeNumber)
 	mS.volStruct.trackInFlightFileInodeData(inodeNumber)
+	mS.volStruct.scheduleAdopt(inodeNumber)
+	mS.volStruct.notifyInode(NotifyWrite, inodeNumber)
 	size = uint64(len(buf))
+	mS.noteWritten(size)
 	stats.IncrementOperations(&stats.FsWriteOps)
 	return
 }
 
-func validateBaseName(baseName string) (err error) {
-	// Make sure the file baseName is not too long
-	baseLen := len(baseName)
-	if baseLen > FileNameMax {
-		err = fmt.Errorf("%s: basename is too long. Length %v, max %v", utils.GetFnName(), baseLen, FileNameMax)
-		logger.ErrorWithError(err)
-		return blunder.AddError(err, blunder.NameTooLongError)
+// Writev writes each of segments in turn (later segments overwriting any earlier ones they overlap) under a
+// single acquisition of the inode's write lock. The file's NumWrites is incremented just once, and runs of
+// contiguous segments are coalesced into single extents (see inode.Writev()). On success, size is the total
+// length of segments.
+func (mS *mountStruct) Writev(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, segments []inode.WriteSegment, profiler *utils.Profiler) (size uint64, err error) {
+	size, err = mS.WritevWithFlockPid(userID, groupID, otherGroupIDs, inodeNumber, 0, segments, profiler)
+	return
+}
+
+func (mS *mountStruct) WritevWithFlockPid(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flockPid uint64, segments []inode.WriteSegment, profiler *utils.Profiler) (size uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpWritev, Length: uint64(len(segments))}, err)
+	}()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
+	err = mS.volStruct.awaitReplaceFence(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.breakLeasesForAccess(mS.id, inodeNumber, true) // see coherence.go
+
+	for _, segment := range segments {
+		err = mS.volStruct.awaitMandatoryLock(inodeNumber, flockPid, syscall.F_WRLCK, segment.Offset, uint64(len(segment.Buf)))
+		if nil != err {
+			return
+		}
+	}
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.WriteLock()
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	err = mS.volStruct.checkRetention(inodeNumber) // see retention.go
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.checkShareModes(inodeNumber, ShareWrite, 0) // see share_mode.go
+	if nil != err {
+		return
+	}
+
+	if 0 < len(segments) {
+		overwriteOffset := segments[0].Offset
+		for _, segment := range segments[1:] {
+			if segment.Offset < overwriteOffset {
+				overwriteOffset = segment.Offset
+			}
+		}
+		err = mS.volStruct.checkInodeFlagsForWrite(inodeNumber, overwriteOffset, false) // see inode_flags.go
+		if nil != err {
+			return
+		}
+		err = mS.volStruct.preserveVersion(inodeNumber, overwriteOffset) // see version.go
+		if nil != err {
+			return
+		}
+	}
+
+	profiler.AddEventNow("before inode.Writev()")
+	err = mS.volStruct.VolumeHandle.Writev(inodeNumber, segments, profiler)
+	profiler.AddEventNow("after inode.Writev()")
+	mS.volStruct.trackInFlightFileInodeData(inodeNumber) // even if only some segments were written
+	if err != nil {
+		return 0, err
+	}
+
+	err = mS.clearSetID(inodeNumber)
+	if nil != err {
+		return 0, err
+	}
+
+	mS.volStruct.scheduleAdopt(inodeNumber)
+	mS.volStruct.notifyInode(NotifyWrite, inodeNumber)
+	for _, segment := range segments {
+		size += uint64(len(segment.Buf))
 	}
-	stats.IncrementOperations(&stats.FsBasenameValidateOps)
+	mS.noteWritten(size)
+	stats.IncrementOperations(&stats.FsWritevOps)
+	return
+}
+
+func validateBaseName(baseName string) (err error) {
+	err = posixNameRules.validate(baseName)
 	return
 }
 
@@ -2986,18 +5938,21 @@ func revSplitPath(fullpath string) []string {
 //
 // If the referenced entity is a symlink, then it will be followed.
 // Subsequent symlinks will also be followed until a terminal
-// non-symlink is reached, up to a limit of MaxSymlinks. A terminal
-// non-symlink may be a directory, a file, or something that does not
-// exist.
+// non-symlink is reached, as the volume's symlink policy permits (see
+// symlink_policy.go). A terminal non-symlink may be a directory, a
+// file, or something that does not exist.
 func (mS *mountStruct) resolvePathForRead(fullpath string, callerID dlm.CallerID) (inodeNumber inode.InodeNumber, inodeType inode.InodeType, inodeLock *dlm.RWLockStruct, err error) {
-	return mS.resolvePath(fullpath, callerID, inode.RootDirInodeNumber, mS.volStruct.ensureReadLock)
+	return mS.resolvePath(fullpath, callerID, inode.RootDirInodeNumber, mS.volStruct.ensureReadLock, nil, true)
 }
 
 func (mS *mountStruct) resolvePathForWrite(fullpath string, callerID dlm.CallerID) (inodeNumber inode.InodeNumber, inodeType inode.InodeType, inodeLock *dlm.RWLockStruct, err error) {
-	return mS.resolvePath(fullpath, callerID, inode.RootDirInodeNumber, mS.volStruct.ensureWriteLock)
+	return mS.resolvePath(fullpath, callerID, inode.RootDirInodeNumber, mS.volStruct.ensureWriteLock, nil, true)
 }
 
-func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, startingInode inode.InodeNumber, getLock func(inode.InodeNumber, dlm.CallerID) (*dlm.RWLockStruct, error)) (inodeNumber inode.InodeNumber, inodeType inode.InodeType, inodeLock *dlm.RWLockStruct, err error) {
+// If checkAccess is non-nil, it is consulted before each directory is searched; should it return false,
+// resolution fails with PermDeniedError. Symlinks are followed per the volume's policy for Middleware...()
+// APIs (if forMiddleware) or any other (see symlink_policy.go).
+func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, startingInode inode.InodeNumber, getLock func(inode.InodeNumber, dlm.CallerID) (*dlm.RWLockStruct, error), checkAccess func(inode.InodeNumber) bool, forMiddleware bool) (inodeNumber inode.InodeNumber, inodeType inode.InodeType, inodeLock *dlm.RWLockStruct, err error) {
 	// pathSegments is the reversed split path. For example, if
 	// fullpath is "/etc/thing/default.conf", then pathSegments is
 	// ["default.conf", "thing", "etc"].
@@ -3008,13 +5963,28 @@ func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, start
 
 	// Our protection against symlink loops is a limit on the number
 	// of symlinks that we will follow.
-	followsRemaining := MaxSymlinks
+	symlinkFollower := mS.volStruct.newSymlinkFollower(forMiddleware)
+
+	// dirSegments is the path (with any symlinks followed) of the
+	// directory in which segment is looked up... both reported
+	// (see path_failure.go) should resolution fail.
+	var dirSegments []string
+	var segment string
+
+	defer func() {
+		if err != nil {
+			err = addPathFailure(err, dirSegments, segment, len(pathSegments))
+		}
+	}()
 
 	var cursorInodeNumber inode.InodeNumber
 	var cursorInodeType inode.InodeType
 	var cursorInodeLock *dlm.RWLockStruct
 	dirInodeNumber := startingInode
 	dirInodeLock, err := getLock(dirInodeNumber, callerID)
+	if err != nil {
+		return
+	}
 
 	// Use defer for cleanup so that we don't have to think as hard
 	// about every if-error-return block.
@@ -3030,13 +6000,18 @@ func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, start
 	}()
 
 	for len(pathSegments) > 0 {
-		segment := pathSegments[len(pathSegments)-1]
+		segment = pathSegments[len(pathSegments)-1]
 		pathSegments = pathSegments[:len(pathSegments)-1]
 
 		if segment == "." {
 			continue
 		}
 
+		if (nil != checkAccess) && !checkAccess(dirInodeNumber) {
+			err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+			return
+		}
+
 		// Look up the entry in the directory.
 		//
 		// If we find a relative symlink (does not start with "/"),
@@ -3059,13 +6034,6 @@ func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, start
 		if cursorInodeType == inode.SymlinkType {
 			// Dereference the symlink and continue path traversal
 			// from the appropriate location.
-			if followsRemaining == 0 {
-				err = blunder.NewError(blunder.TooManySymlinksError, "Too many symlinks while resolving %s", fullpath)
-				return
-			} else {
-				followsRemaining -= 1
-			}
-
 			target, err1 := mS.volStruct.VolumeHandle.GetSymlink(cursorInodeNumber)
 			if cursorInodeLock != nil {
 				cursorInodeLock.Unlock() // done with this symlink, error or not
@@ -3076,7 +6044,13 @@ func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, start
 				return
 			}
 
-			if strings.HasPrefix(target, "/") {
+			target, fromRoot, err1 := symlinkFollower.follow(fullpath, target)
+			if err1 != nil {
+				err = err1
+				return
+			}
+
+			if fromRoot {
 				// Absolute symlink; we don't keep track of the
 				// current directory any more, but restart traversal
 				// from the root directory.
@@ -3085,6 +6059,7 @@ func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, start
 					dirInodeLock = nil
 				}
 				dirInodeNumber = inode.RootDirInodeNumber
+				dirSegments = nil
 				dirInodeLock, err = getLock(inode.RootDirInodeNumber, callerID)
 				if err != nil {
 					return
@@ -3117,6 +6092,11 @@ func (mS *mountStruct) resolvePath(fullpath string, callerID dlm.CallerID, start
 			dirInodeNumber = cursorInodeNumber
 			dirInodeLock = cursorInodeLock
 			cursorInodeLock = nil
+			if segment != ".." {
+				dirSegments = append(dirSegments, segment)
+			} else if len(dirSegments) > 0 {
+				dirSegments = dirSegments[:len(dirSegments)-1]
+			}
 		}
 	}
 
@@ -3147,8 +6127,8 @@ func (mS *mountStruct) removeObstacleToObjectPut(callerID dlm.CallerID, dirInode
 	}
 
 	fileType := inode.InodeType(statResult[StatFType])
-	if fileType == inode.FileType || fileType == inode.SymlinkType {
-		// Files and symlinks can always, barring errors, be unlinked
+	if fileType == inode.FileType || fileType == inode.SymlinkType || fileType == inode.FIFOType || fileType == inode.SocketType {
+		// Files, symlinks, FIFOs, and sockets can always, barring errors, be unlinked
 		err = mS.volStruct.VolumeHandle.Unlink(dirInodeNumber, obstacleName)
 		if err != nil {
 			return err
@@ -3199,8 +6179,8 @@ func removeObstacleToObjectPut(mount *mountStruct, callerID dlm.CallerID, dirIno
 	}
 
 	fileType := inode.InodeType(statResult[StatFType])
-	if fileType == inode.FileType || fileType == inode.SymlinkType {
-		// Files and symlinks can always, barring errors, be unlinked
+	if fileType == inode.FileType || fileType == inode.SymlinkType || fileType == inode.FIFOType || fileType == inode.SocketType {
+		// Files, symlinks, FIFOs, and sockets can always, barring errors, be unlinked
 		err = mount.volStruct.VolumeHandle.Unlink(dirInodeNumber, obstacleName)
 		if err != nil {
 			return err
@@ -3246,45 +6226,38 @@ func appendReadPlanEntries(readPlan []inode.ReadPlanStep, readRangeOut *[]inode.
 }
 
 type dirToDescend struct {
-	name string
-	path string
-	ino  inode.InodeNumber
+	name   string
+	path   string
+	ino    inode.InodeNumber
+	subdir bool // list as a subdir entry (of a delimited listing) rather than descend
 }
 
 // readdir is a helper function to do the work of Readdir once we hold the lock.
-func (mS *mountStruct) readdirHelper(inodeNumber inode.InodeNumber, prevBasenameReturned string, maxEntries uint64, maxBufSize uint64, callerID dlm.CallerID) (entries []inode.DirEntry, numEntries uint64, areMoreEntries bool, err error) {
+// readdirHelper accepts, as prevReturned, either a string (basename) or inode.InodeDirLocation.
+// If pattern is non-empty, only entries whose basenames match it are returned (see match.go).
+func (mS *mountStruct) readdirHelper(inodeNumber inode.InodeNumber, prevReturned interface{}, pattern string, maxEntries uint64, maxBufSize uint64, callerID dlm.CallerID) (entries []inode.DirEntry, numEntries uint64, areMoreEntries bool, err error) {
 	lockID, err := mS.volStruct.makeLockID(inodeNumber)
 	if err != nil {
 		return
 	}
-	if !dlm.IsLockHeld(lockID, callerID, dlm.ANYLOCK) {
+	if !dlm.IsLockHeldInDomain(mS.volStruct.volumeName, lockID, callerID, dlm.ANYLOCK) {
 		err = fmt.Errorf("%s: inode %v lock must be held before calling.", utils.GetFnName(), inodeNumber)
 		return nil, 0, false, blunder.AddError(err, blunder.NotFoundError)
 	}
 
-	entries, areMoreEntries, err = mS.volStruct.VolumeHandle.ReadDir(inodeNumber, maxEntries, maxBufSize, prevBasenameReturned)
+	maxEntries = mS.volStruct.capEntries(maxEntries)
+	maxBufSize = mS.volStruct.capBytes(maxBufSize)
+
+	if "" == pattern {
+		entries, areMoreEntries, err = mS.volStruct.VolumeHandle.ReadDir(inodeNumber, maxEntries, maxBufSize, prevReturned)
+	} else {
+		entries, areMoreEntries, err = mS.readdirMatch(inodeNumber, prevReturned, pattern, maxEntries, maxBufSize)
+	}
 	if err != nil {
 		return entries, numEntries, areMoreEntries, err
 	}
 	numEntries = uint64(len(entries))
 
-	// Tracker: 129872175: Directory entry must have the type, we should not be getting from inode, due to potential lock order issues.
-	for i := range entries {
-		if inodeNumber == entries[i].InodeNumber {
-			entries[i].Type, _ = mS.getTypeHelper(entries[i].InodeNumber, callerID) // in case of "."
-		} else {
-			entryInodeLock, err1 := mS.volStruct.initInodeLock(entries[i].InodeNumber, callerID)
-			if err = err1; err != nil {
-				return
-			}
-			err = entryInodeLock.ReadLock()
-			if err != nil {
-				return
-			}
-			entries[i].Type, _ = mS.getTypeHelper(entries[i].InodeNumber, entryInodeLock.GetCallerID())
-			entryInodeLock.Unlock()
-		}
-	}
 	return entries, numEntries, areMoreEntries, err
 }
 
@@ -3294,7 +6267,7 @@ func (mS *mountStruct) readdirOneHelper(inodeNumber inode.InodeNumber, prevDirLo
 	if err != nil {
 		return
 	}
-	if !dlm.IsLockHeld(lockID, callerID, dlm.ANYLOCK) {
+	if !dlm.IsLockHeldInDomain(mS.volStruct.volumeName, lockID, callerID, dlm.ANYLOCK) {
 		err = fmt.Errorf("%s: inode %v lock must be held before calling.", utils.GetFnName(), inodeNumber)
 		err = blunder.AddError(err, blunder.NotFoundError)
 		return
@@ -3307,23 +6280,5 @@ func (mS *mountStruct) readdirOneHelper(inodeNumber inode.InodeNumber, prevDirLo
 		return entries, err
 	}
 
-	// Tracker: 129872175: Directory entry must have the type, we should not be getting from inode, due to potential lock order issues.
-	for i := range entries {
-		if inodeNumber == entries[i].InodeNumber {
-			entries[i].Type, _ = mS.getTypeHelper(entries[i].InodeNumber, callerID) // in case of "."
-		} else {
-			entryInodeLock, err1 := mS.volStruct.initInodeLock(entries[i].InodeNumber, callerID)
-			if err = err1; err != nil {
-				return
-			}
-			err = entryInodeLock.ReadLock()
-			if err != nil {
-				return
-			}
-			entries[i].Type, _ = mS.getTypeHelper(entries[i].InodeNumber, callerID)
-			entryInodeLock.Unlock()
-		}
-	}
-
 	return entries, err
 }
@@ -2,13 +2,24 @@ package fs
 
 import (
 	"bytes"
+	"container/list"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -25,6 +36,7 @@ import (
 	"github.com/swiftstack/ProxyFS/ramswift"
 	"github.com/swiftstack/ProxyFS/stats"
 	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
 )
 
 // our global mountStruct to be used in tests
@@ -642,7 +654,7 @@ func TestBadRename(t *testing.T) {
 	}
 
 	// Try to rename a valid file to a name that is too long
-	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, testDirInode, validFile, testDirInode, nameTooLong)
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, testDirInode, validFile, testDirInode, nameTooLong, 0)
 	if nil != err {
 		if blunder.IsNot(err, blunder.NameTooLongError) {
 			t.Fatalf("Link() returned error %v, expected %v(%d).", blunder.Errno(err), blunder.NameTooLongError, blunder.NameTooLongError.Value())
@@ -655,7 +667,7 @@ func TestBadRename(t *testing.T) {
 	expectDirectory(t, inode.InodeRootUserID, inode.InodeRootGroupID, testDirInode, entriesExpected)
 
 	// Try to rename a nonexistent file with a name that is too long
-	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, testDirInode, nameTooLong, testDirInode, "AlsoAGoodFilename")
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, testDirInode, nameTooLong, testDirInode, "AlsoAGoodFilename", 0)
 	if nil != err {
 		if blunder.IsNot(err, blunder.NameTooLongError) {
 			t.Fatalf("Link() returned error %v, expected %v(%d).", blunder.Errno(err), blunder.NameTooLongError, blunder.NameTooLongError.Value())
@@ -1183,7 +1195,7 @@ func TestStaleInodes(t *testing.T) {
 
 	// Rename -- two cases, one with stale src directory and one with stale dest
 	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil,
-		testDirInodeNumber, "fubar", staleDirInodeNumber, "barfu")
+		testDirInodeNumber, "fubar", staleDirInodeNumber, "barfu", 0)
 	if nil == err {
 		t.Fatalf("Rename(1) should not have returned success")
 	}
@@ -1192,7 +1204,7 @@ func TestStaleInodes(t *testing.T) {
 	}
 
 	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil,
-		staleDirInodeNumber, "fubar", testDirInodeNumber, "barfu")
+		staleDirInodeNumber, "fubar", testDirInodeNumber, "barfu", 0)
 	if nil == err {
 		t.Fatalf("Rename(2) should not have returned success")
 	}
@@ -1212,3 +1224,8386 @@ func TestStaleInodes(t *testing.T) {
 		t.Fatalf("Rmdir() of '%s' returned error: %v", testDirname, err)
 	}
 }
+
+func TestVolumeStateFlock(t *testing.T) {
+	var err error
+
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	mS.volStruct.checkpointByteRangeLocks = true
+	defer func() { mS.volStruct.checkpointByteRangeLocks = false }()
+
+	basename := "TestVolumeStateLockFile"
+	lockFileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, basename, inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() %v returned error: %v", basename, err)
+	}
+
+	var lock FlockStruct
+	lock.Type = syscall.F_WRLCK
+	lock.Start = 0
+	lock.Len = 100
+	lock.Pid = 1
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, lockFileInodeNumber, syscall.F_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Write lock on file failed: %v", err)
+	}
+
+	var lock3 FlockStruct
+	lock3.Type = syscall.F_WRLCK
+	lock3.Start = 200
+	lock3.Len = 100
+	lock3.Pid = 3
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, lockFileInodeNumber, syscall.F_SETLK, &lock3)
+	if err != nil {
+		t.Fatalf("Write lock on file failed: %v", err)
+	}
+
+	err = mS.volStruct.exportVolumeState()
+	if err != nil {
+		t.Fatalf("exportVolumeState() failed: %v", err)
+	}
+
+	// Simulate a restart by forgetting all in-memory lock state
+	mS.volStruct.FLockMap = make(map[inode.InodeNumber]*list.List)
+
+	mS.volStruct.Lock()
+	leaseBreakTimeout := mS.volStruct.leaseBreakTimeout
+	mS.volStruct.leaseBreakTimeout = time.Second
+	mS.volStruct.Unlock()
+
+	err = mS.volStruct.importVolumeState()
+	if err != nil {
+		t.Fatalf("importVolumeState() failed: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.leaseBreakTimeout = leaseBreakTimeout
+	mS.volStruct.Unlock()
+
+	var lock1 FlockStruct
+	lock1 = lock
+	lock1.Pid = 2
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, lockFileInodeNumber, syscall.F_SETLK, &lock1)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Write lock conflicting with restored lock should have failed with TryAgainError, instead got: %v", err)
+	}
+
+	// Restored locks not reclaimed within LeaseBreakTimeout are released
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, lockFileInodeNumber, syscall.F_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Write lock reclaiming restored lock failed: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		lock1 = lock3
+		lock1.Pid = 2
+		_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, lockFileInodeNumber, syscall.F_GETLK, &lock1)
+		if nil == err {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Unreclaimed restored lock not released (F_GETLK returned %v)", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lock1 = lock
+	lock1.Pid = 2
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, lockFileInodeNumber, syscall.F_SETLK, &lock1)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Write lock conflicting with reclaimed lock should have failed with TryAgainError, instead got: %v", err)
+	}
+
+	// The reserved stream must not be visible nor modifiable via the XAttr APIs
+	streamNames, err := mS.ListXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber)
+	if err != nil {
+		t.Fatalf("ListXAttr() failed: %v", err)
+	}
+	for _, streamName := range streamNames {
+		if VolumeStateStream == streamName {
+			t.Fatalf("ListXAttr() should not have returned %v", VolumeStateStream)
+		}
+	}
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, VolumeStateStream)
+	if blunder.IsNot(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr() of %v should have failed with StreamNotFound, instead got: %v", VolumeStateStream, err)
+	}
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, VolumeStateStream, []byte{}, 0)
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("SetXAttr() of %v should have failed with PermDeniedError, instead got: %v", VolumeStateStream, err)
+	}
+
+	lock.Type = syscall.F_UNLCK
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, lockFileInodeNumber, syscall.F_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Unlock on file failed: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, basename)
+	if err != nil {
+		t.Fatalf("Unlink() %v returned error: %v", basename, err)
+	}
+}
+
+func TestVolumeStateLeases(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	basename := "TestVolumeStateLeaseFile"
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, basename, inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() %v returned error: %v", basename, err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	breakChan := make(chan LeaseType, 8)
+	handler := func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {
+		breakChan <- breakTo
+	}
+	otherHandler := func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {}
+
+	leaseID, err := otherMountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseWrite, otherHandler)
+	if err != nil {
+		t.Fatalf("AcquireLease(LeaseWrite) returned error: %v", err)
+	}
+
+	err = mS.volStruct.exportVolumeState()
+	if err != nil {
+		t.Fatalf("exportVolumeState() failed: %v", err)
+	}
+
+	// Simulate a restart by forgetting all in-memory lease state
+
+	mS.volStruct.releaseAllLeases()
+
+	err = mS.volStruct.importVolumeState()
+	if err != nil {
+		t.Fatalf("importVolumeState() failed: %v", err)
+	}
+
+	_, _, err = mS.ReclaimLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, leaseID, nil)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("ReclaimLease() without a LeaseBreakHandler should have failed with InvalidArgError: %v", err)
+	}
+	reclaimedInodeNumber, leaseType, err := mS.ReclaimLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, leaseID, handler)
+	if err != nil {
+		t.Fatalf("ReclaimLease() returned error: %v", err)
+	}
+	if (fileInodeNumber != reclaimedInodeNumber) || (LeaseWrite != leaseType) {
+		t.Fatalf("ReclaimLease() returned inode %v %v (expected inode %v %v)", reclaimedInodeNumber, leaseType, fileInodeNumber, LeaseWrite)
+	}
+	_, _, err = otherMountHandle.ReclaimLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, leaseID, otherHandler)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("ReclaimLease() of an already reclaimed lease should have failed with NotFoundError: %v", err)
+	}
+
+	// The reclaimed lease is broken via its new holder's LeaseBreakHandler
+
+	acquiredChan := make(chan LeaseID, 1)
+	go func() {
+		otherLeaseID, acquireErr := otherMountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseRead, otherHandler)
+		if acquireErr != nil {
+			t.Errorf("AcquireLease(LeaseRead) returned error: %v", acquireErr)
+		}
+		acquiredChan <- otherLeaseID
+	}()
+	select {
+	case breakTo := <-breakChan:
+		if LeaseRead != breakTo {
+			t.Fatalf("LeaseBreakHandler invoked to %v (expected %v)", breakTo, LeaseRead)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("LeaseBreakHandler not invoked for reclaimed lease %v", leaseID)
+	}
+	err = mS.DowngradeLease(leaseID, LeaseRead)
+	if err != nil {
+		t.Fatalf("DowngradeLease() of reclaimed lease returned error: %v", err)
+	}
+	otherLeaseID := <-acquiredChan
+
+	// Restored leases not reclaimed within LeaseBreakTimeout are released
+
+	err = mS.volStruct.exportVolumeState()
+	if err != nil {
+		t.Fatalf("exportVolumeState() failed: %v", err)
+	}
+
+	mS.volStruct.releaseAllLeases()
+
+	mS.volStruct.Lock()
+	leaseBreakTimeout := mS.volStruct.leaseBreakTimeout
+	mS.volStruct.leaseBreakTimeout = 100 * time.Millisecond
+	mS.volStruct.Unlock()
+
+	err = mS.volStruct.importVolumeState()
+	if err != nil {
+		t.Fatalf("importVolumeState() failed: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.leaseBreakTimeout = leaseBreakTimeout
+	mS.volStruct.Unlock()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mS.volStruct.leases.Lock()
+		leaseCount := len(mS.volStruct.leases.leaseMap)
+		mS.volStruct.leases.Unlock()
+		if 0 == leaseCount {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Restored leases not released (%d remain)", leaseCount)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, unreclaimedLeaseID := range []LeaseID{leaseID, otherLeaseID} {
+		_, _, err = mS.ReclaimLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, unreclaimedLeaseID, handler)
+		if blunder.IsNot(err, blunder.NotFoundError) {
+			t.Fatalf("ReclaimLease() of released lease should have failed with NotFoundError: %v", err)
+		}
+	}
+
+	// The retention clock resumes from where it was exported (not from the wall clock)
+
+	mS.volStruct.startRetentionClock(time.Now().Add(-time.Hour))
+
+	err = mS.volStruct.exportVolumeState()
+	if err != nil {
+		t.Fatalf("exportVolumeState() failed: %v", err)
+	}
+
+	mS.volStruct.startRetentionClock(time.Time{})
+
+	err = mS.volStruct.importVolumeState()
+	if err != nil {
+		t.Fatalf("importVolumeState() failed: %v", err)
+	}
+
+	if mS.volStruct.retentionNow().After(time.Now().Add(-59 * time.Minute)) {
+		t.Fatalf("Retention clock did not resume from its exported time")
+	}
+
+	// ...and, while the volume is online, is exported periodically
+
+	mS.volStruct.Lock()
+	retentionExportTimer := mS.volStruct.retentionExportTimer
+	mS.volStruct.Unlock()
+	if nil == retentionExportTimer {
+		t.Fatalf("importVolumeState() did not start periodic export of the retention clock")
+	}
+
+	mS.volStruct.startRetentionClock(time.Time{})
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, basename)
+	if err != nil {
+		t.Fatalf("Unlink() %v returned error: %v", basename, err)
+	}
+}
+
+func TestPinPath(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPinDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestPinFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("pinned"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+
+	pinnedBytes, err := mS.PinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestPinDir")
+	if err != nil {
+		t.Fatalf("PinPath() returned error: %v", err)
+	}
+	if 0 == pinnedBytes {
+		t.Fatalf("PinPath() of a subtree containing data should have pinned some bytes")
+	}
+	pinnedInodes, _ := mS.volStruct.VolumeHandle.GetPinnedStats()
+	if 2 != pinnedInodes {
+		t.Fatalf("PinPath() should have pinned 2 inodes, instead pinned %v", pinnedInodes)
+	}
+
+	_, err = mS.PinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestPinDir/NoSuchFile")
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("PinPath() of missing path should have failed with NotFoundError, instead got: %v", err)
+	}
+
+	_, err = mS.PinPath(inode.InodeUserID(1000), inode.InodeGroupID(1000), nil, "/TestPinDir")
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("PinPath() by non-root should have failed with NotPermError, instead got: %v", err)
+	}
+	err = mS.UnpinPath(inode.InodeUserID(1000), inode.InodeGroupID(1000), nil, "/TestPinDir")
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("UnpinPath() by non-root should have failed with NotPermError, instead got: %v", err)
+	}
+
+	err = mS.UnpinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestPinDir")
+	if err != nil {
+		t.Fatalf("UnpinPath() returned error: %v", err)
+	}
+	pinnedInodes, pinnedBytes = mS.volStruct.VolumeHandle.GetPinnedStats()
+	if (0 != pinnedInodes) || (0 != pinnedBytes) {
+		t.Fatalf("UnpinPath() left (%v, %v) pinned", pinnedInodes, pinnedBytes)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestPinFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPinDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestStatVfs(t *testing.T) {
+	defer func() {
+		mS.volStruct.quotaBytes = 0
+		mS.volStruct.quotaInodes = 0
+		mS.volStruct.usageCache = nil
+	}()
+
+	// With no quota configured, the VolFake* capacities are reported
+	mS.volStruct.usageCache = nil
+	statVFS, err := mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() failed: %v", err)
+	}
+	if VolFakeTotalBlocks != statVFS[StatVFSTotalBlocks] {
+		t.Fatalf("StatVfs() returned TotalBlocks == %v (expected %v)", statVFS[StatVFSTotalBlocks], VolFakeTotalBlocks)
+	}
+	if (0 == statVFS[StatVFSFreeInodes]) || (VolFakeTotalInodes <= statVFS[StatVFSFreeInodes]) {
+		t.Fatalf("StatVfs() returned FreeInodes == %v (expected some inodes in use)", statVFS[StatVFSFreeInodes])
+	}
+
+	// A Swift account quota is honored
+	err = swiftclient.AccountPost("CommonAccount", map[string][]string{"X-Account-Meta-Quota-Bytes": []string{strconv.Itoa(10 * FsBlockSize)}})
+	if nil != err {
+		t.Fatalf("AccountPost() failed: %v", err)
+	}
+	mS.volStruct.usageCache = nil
+	statVFS, err = mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() failed: %v", err)
+	}
+	if 10 != statVFS[StatVFSTotalBlocks] {
+		t.Fatalf("StatVfs() returned TotalBlocks == %v (expected 10)", statVFS[StatVFSTotalBlocks])
+	}
+
+	// A configured volume quota takes precedence
+	mS.volStruct.quotaBytes = 20 * FsBlockSize
+	mS.volStruct.quotaInodes = 1000
+	statVFS, err = mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() failed: %v", err)
+	}
+	if 20 != statVFS[StatVFSTotalBlocks] {
+		t.Fatalf("StatVfs() returned TotalBlocks == %v (expected 20)", statVFS[StatVFSTotalBlocks])
+	}
+	if statVFS[StatVFSFreeBlocks] > statVFS[StatVFSTotalBlocks] {
+		t.Fatalf("StatVfs() returned FreeBlocks (%v) > TotalBlocks (%v)", statVFS[StatVFSFreeBlocks], statVFS[StatVFSTotalBlocks])
+	}
+	if (1000 != statVFS[StatVFSTotalInodes]) || (1000 <= statVFS[StatVFSFreeInodes]) {
+		t.Fatalf("StatVfs() returned TotalInodes == %v & FreeInodes == %v", statVFS[StatVFSTotalInodes], statVFS[StatVFSFreeInodes])
+	}
+
+	err = swiftclient.AccountPost("CommonAccount", map[string][]string{"X-Account-Meta-Quota-Bytes": []string{""}})
+	if nil != err {
+		t.Fatalf("AccountPost() failed: %v", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestNotifyDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	dirEvents := make(chan NotifyEvent, 16)
+	subtreeEvents := make(chan NotifyEvent, 16)
+
+	dirWatchID, err := mS.AddWatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, false, func(watchID WatchID, event NotifyEvent) { dirEvents <- event })
+	if err != nil {
+		t.Fatalf("AddWatch() of dir returned error: %v", err)
+	}
+	subtreeWatchID, err := mS.AddWatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, true, func(watchID WatchID, event NotifyEvent) { subtreeEvents <- event })
+	if err != nil {
+		t.Fatalf("AddWatch() of subtree returned error: %v", err)
+	}
+
+	expectEvent := func(events chan NotifyEvent, eventType NotifyEventType, parentInodeNumber inode.InodeNumber, basename string) {
+		select {
+		case event := <-events:
+			if (eventType != event.Type) || (parentInodeNumber != event.ParentInodeNumber) || (basename != event.Basename) {
+				t.Fatalf("expected event {%v %v %v}, got %+v", eventType, parentInodeNumber, basename, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected event {%v %v %v}, got none", eventType, parentInodeNumber, basename)
+		}
+	}
+	expectNoEvent := func(events chan NotifyEvent) {
+		select {
+		case event := <-events:
+			t.Fatalf("expected no event, got %+v", event)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	expectEvent(dirEvents, NotifyCreate, dirInodeNumber, "File")
+	expectEvent(subtreeEvents, NotifyCreate, dirInodeNumber, "File")
+
+	// Write() only knows the InodeNumber... the name comes from the hint recorded by Create()
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("notify"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	expectEvent(dirEvents, NotifyWrite, dirInodeNumber, "File")
+	expectEvent(subtreeEvents, NotifyWrite, dirInodeNumber, "File")
+
+	// Only the subtree watch sees changes within SubDir
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", subDirInodeNumber, "Renamed", 0)
+	if err != nil {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+	expectEvent(dirEvents, NotifyRenameFrom, dirInodeNumber, "File")
+	expectEvent(subtreeEvents, NotifyRenameFrom, dirInodeNumber, "File")
+	expectEvent(subtreeEvents, NotifyRenameTo, subDirInodeNumber, "Renamed")
+
+	stat := make(Stat)
+	stat[StatMode] = uint64(0600)
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, stat)
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	expectEvent(subtreeEvents, NotifySetAttr, subDirInodeNumber, "Renamed")
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "Renamed")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	expectEvent(subtreeEvents, NotifyUnlink, subDirInodeNumber, "Renamed")
+	expectNoEvent(dirEvents)
+
+	err = mS.RemoveWatch(dirWatchID)
+	if err != nil {
+		t.Fatalf("RemoveWatch() returned error: %v", err)
+	}
+	err = mS.RemoveWatch(dirWatchID)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("RemoveWatch() of removed watch should have failed with NotFoundError, instead got: %v", err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	expectEvent(subtreeEvents, NotifyUnlink, dirInodeNumber, "SubDir")
+	expectNoEvent(dirEvents)
+
+	err = mS.RemoveWatch(subtreeWatchID)
+	if err != nil {
+		t.Fatalf("RemoveWatch() returned error: %v", err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestNotifyDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestReplaceFence(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReplaceFenceFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	savedReplaceFenceMode := mS.volStruct.replaceFenceMode
+	defer func() { mS.volStruct.replaceFenceMode = savedReplaceFenceMode }()
+
+	// In "fail" mode, writes to a fenced inode return EAGAIN
+
+	mS.volStruct.replaceFenceMode = replaceFenceModeFail
+	mS.volStruct.raiseReplaceFence(fileInodeNumber)
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x00}, nil)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Write() to fenced inode should have returned TryAgainError, got: %v", err)
+	}
+	err = mS.Resize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Resize() of fenced inode should have returned TryAgainError, got: %v", err)
+	}
+
+	mS.volStruct.lowerReplaceFence(fileInodeNumber)
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x00}, nil)
+	if err != nil {
+		t.Fatalf("Write() to unfenced inode returned error: %v", err)
+	}
+
+	// In "block" mode, writes to a fenced inode wait for the fence to be lowered
+
+	mS.volStruct.replaceFenceMode = replaceFenceModeBlock
+	mS.volStruct.raiseReplaceFence(fileInodeNumber)
+
+	writeDone := make(chan error, 1)
+	go func() {
+		_, writeErr := mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 1, []byte{0x01}, nil)
+		writeDone <- writeErr
+	}()
+
+	select {
+	case err = <-writeDone:
+		t.Fatalf("Write() to fenced inode should have blocked, returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mS.volStruct.lowerReplaceFence(fileInodeNumber)
+
+	select {
+	case err = <-writeDone:
+		if err != nil {
+			t.Fatalf("Write() after fence lowered returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Write() remained blocked after fence lowered")
+	}
+
+	// In "none" mode, no fence is raised at all
+
+	mS.volStruct.replaceFenceMode = replaceFenceModeNone
+	mS.volStruct.raiseReplaceFence(fileInodeNumber)
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 2, []byte{0x02}, nil)
+	if err != nil {
+		t.Fatalf("Write() with ReplaceFenceMode none returned error: %v", err)
+	}
+
+	mS.volStruct.lowerReplaceFence(fileInodeNumber)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReplaceFenceFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestATimePolicy(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	_, err := Mount("TestVolume", MountRelATime|MountStrictATime)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("Mount() with conflicting atime policies should have returned InvalidArgError, got: %v", err)
+	}
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestATimePolicyFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x00}, nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	readAndFetchATime := func(mountOptions MountOptions) uint64 {
+		mountHandle, err := Mount("TestVolume", mountOptions)
+		if err != nil {
+			t.Fatalf("Mount() returned error: %v", err)
+		}
+		_, err = mountHandle.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 1, nil)
+		if err != nil {
+			t.Fatalf("Read() returned error: %v", err)
+		}
+		stat, err := mountHandle.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+		if err != nil {
+			t.Fatalf("Getstat() returned error: %v", err)
+		}
+		return stat[StatATime]
+	}
+
+	// Default and noatime mounts leave atime alone
+
+	stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	originalATime := stat[StatATime]
+
+	if readAndFetchATime(MountOptions(0)) != originalATime {
+		t.Fatalf("Read() via default mount should not have updated atime")
+	}
+	if readAndFetchATime(MountNoATime) != originalATime {
+		t.Fatalf("Read() via MountNoATime mount should not have updated atime")
+	}
+
+	// relatime updates atime once following a modification...
+
+	relATime := readAndFetchATime(MountRelATime)
+	if relATime <= originalATime {
+		t.Fatalf("Read() via MountRelATime mount should have updated atime preceding mtime")
+	}
+	if readAndFetchATime(MountRelATime) != relATime {
+		t.Fatalf("Read() via MountRelATime mount should not have updated a recent atime")
+	}
+
+	// ...while strictatime updates it always
+
+	time.Sleep(time.Millisecond)
+
+	if readAndFetchATime(MountStrictATime) <= relATime {
+		t.Fatalf("Read() via MountStrictATime mount should have updated atime")
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestATimePolicyFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestGetObjectSegmentCheck(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSegmentCheckContainer", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "Object", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("TestSegmentCheck"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+
+	mS.volStruct.segmentCheck = true
+	defer func() { mS.volStruct.segmentCheck = false }()
+
+	getObject := func() (readPlan []inode.ReadPlanStep, err error) {
+		readPlan = make([]inode.ReadPlanStep, 0)
+		_, _, _, _, _, _, _, err = mS.MiddlewareGetObject("TestVolume", "TestSegmentCheckContainer/Object", []ReadRangeIn{}, &readPlan)
+		return
+	}
+
+	readPlan, err := getObject()
+	if err != nil {
+		t.Fatalf("MiddlewareGetObject() returned error: %v", err)
+	}
+	if 1 != len(readPlan) {
+		t.Fatalf("MiddlewareGetObject() returned %v read plan steps (expected 1)", len(readPlan))
+	}
+	mS.volStruct.Lock()
+	_, ok := mS.volStruct.segmentCheckCache[readPlan[0].ObjectPath]
+	mS.volStruct.Unlock()
+	if !ok {
+		t.Fatalf("LogSegment existence should have been cached after the first HEAD")
+	}
+
+	// Remove the LogSegment out from under the file
+
+	accountName, containerName, objectName, err := utils.PathToAcctContObj(readPlan[0].ObjectPath)
+	if err != nil {
+		t.Fatalf("PathToAcctContObj() returned error: %v", err)
+	}
+	err = swiftclient.ObjectDeleteSync(accountName, containerName, objectName)
+	if err != nil {
+		t.Fatalf("ObjectDeleteSync() returned error: %v", err)
+	}
+
+	_, err = getObject()
+	if err != nil {
+		t.Fatalf("MiddlewareGetObject() should have trusted cached LogSegment existence, got: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.segmentCheckCache = make(map[string]time.Time)
+	mS.volStruct.Unlock()
+
+	_, err = getObject()
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("MiddlewareGetObject() of a stale read plan should have returned TryAgainError, got: %v", err)
+	}
+}
+
+func TestReadOnlyMount(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x00}, nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	mountHandle, err := Mount("TestVolume", MountReadOnly)
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	_, err = mountHandle.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 1, nil)
+	if err != nil {
+		t.Fatalf("Read() via read-only mount returned error: %v", err)
+	}
+	if !mountHandle.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, inode.R_OK) {
+		t.Fatalf("Access(R_OK) via read-only mount should have succeeded")
+	}
+	if mountHandle.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, inode.W_OK) {
+		t.Fatalf("Access(W_OK) via read-only mount should have failed")
+	}
+
+	expectReadOnlyError := func(op string, err error) {
+		if blunder.IsNot(err, blunder.ReadOnlyError) {
+			t.Fatalf("%s via read-only mount should have returned ReadOnlyError, got: %v", op, err)
+		}
+	}
+
+	_, err = mountHandle.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountFile2", inode.PosixModePerm)
+	expectReadOnlyError("Create()", err)
+	_, err = mountHandle.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountDir", inode.PosixModePerm)
+	expectReadOnlyError("Mkdir()", err)
+	_, err = mountHandle.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountSymlink", "TestReadOnlyMountFile")
+	expectReadOnlyError("Symlink()", err)
+	err = mountHandle.Link(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountLink", fileInodeNumber)
+	expectReadOnlyError("Link()", err)
+	_, err = mountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x01}, nil)
+	expectReadOnlyError("Write()", err)
+	err = mountHandle.Resize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0)
+	expectReadOnlyError("Resize()", err)
+	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: uint64(0600)})
+	expectReadOnlyError("Setstat()", err)
+	err = mountHandle.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.test", []byte{0x00}, 0)
+	expectReadOnlyError("SetXAttr()", err)
+	err = mountHandle.RemoveXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.test")
+	expectReadOnlyError("RemoveXAttr()", err)
+	err = mountHandle.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountFile", rootDirInodeNumber, "TestReadOnlyMountFile3", 0)
+	expectReadOnlyError("Rename()", err)
+	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountFile")
+	expectReadOnlyError("Unlink()", err)
+	err = mountHandle.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountFile")
+	expectReadOnlyError("Rmdir()", err)
+	_, err = mountHandle.CallInodeToProvisionObject()
+	expectReadOnlyError("CallInodeToProvisionObject()", err)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadOnlyMountFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestDirLockShards(t *testing.T) {
+	const numFiles = 32
+
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestDirLockShardsDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.dirLockShards = 8
+	mS.volStruct.Unlock()
+	defer func() {
+		mS.volStruct.Lock()
+		mS.volStruct.dirLockShards = 0
+		mS.volStruct.Unlock()
+	}()
+
+	// Names in different shards may be locked concurrently...
+
+	basenameA := "A"
+	basenameB := "B"
+	for dirEntryShard(basenameA, 8) == dirEntryShard(basenameB, 8) {
+		basenameB += "B"
+	}
+
+	dirEntryLockA, err := mS.volStruct.getDirEntryLock(dirInodeNumber, basenameA, nil)
+	if err != nil {
+		t.Fatalf("getDirEntryLock() returned error: %v", err)
+	}
+
+	lockedB := make(chan *dirEntryLockStruct, 1)
+	go func() {
+		dirEntryLockB, _ := mS.volStruct.getDirEntryLock(dirInodeNumber, basenameB, nil)
+		lockedB <- dirEntryLockB
+	}()
+	select {
+	case dirEntryLockB := <-lockedB:
+		dirEntryLockB.Unlock()
+	case <-time.After(time.Second):
+		t.Fatalf("getDirEntryLock() of a name in a different shard should not have blocked")
+	}
+
+	// ...while a directory-wide write lock must wait for them
+
+	dirLocked := make(chan struct{})
+	go func() {
+		dirInodeLock, _ := mS.volStruct.getWriteLock(dirInodeNumber, nil)
+		dirInodeLock.Unlock()
+		close(dirLocked)
+	}()
+	select {
+	case <-dirLocked:
+		t.Fatalf("getWriteLock() of directory should have blocked while an entry is locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	dirEntryLockA.Unlock()
+	select {
+	case <-dirLocked:
+	case <-time.After(time.Second):
+		t.Fatalf("getWriteLock() of directory should have succeeded once the entry was unlocked")
+	}
+
+	// Concurrent Create()s & Unlink()s in the same directory
+
+	errChan := make(chan error, numFiles)
+	for i := 0; i < numFiles; i++ {
+		go func(i int) {
+			_, createErr := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fmt.Sprintf("File%02d", i), inode.PosixModePerm)
+			errChan <- createErr
+		}(i)
+	}
+	for i := 0; i < numFiles; i++ {
+		err = <-errChan
+		if err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	numEntries, err := mS.volStruct.VolumeHandle.NumDirEntries(dirInodeNumber)
+	if err != nil {
+		t.Fatalf("NumDirEntries() returned error: %v", err)
+	}
+	if (numFiles + 2) != numEntries {
+		t.Fatalf("NumDirEntries() returned %v (expected %v)", numEntries, numFiles+2)
+	}
+
+	for i := 0; i < numFiles; i++ {
+		go func(i int) {
+			errChan <- mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fmt.Sprintf("File%02d", i))
+		}(i)
+	}
+	for i := 0; i < numFiles; i++ {
+		err = <-errChan
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestDirLockShardsDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestIDMap(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	_, err := MountWithIDMap("TestVolume", MountOptions(0), &IDMapStruct{
+		UserIDMap: map[inode.InodeUserID]inode.InodeUserID{1000: 2000, 2000: 3000},
+	})
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("MountWithIDMap() of chained UserIDMap should have returned InvalidArgError, got: %v", err)
+	}
+
+	mountHandle, err := MountWithIDMap("TestVolume", MountOptions(0), &IDMapStruct{
+		RootSquash:  true,
+		AnonUserID:  65534,
+		AnonGroupID: 65534,
+		UserIDMap:   map[inode.InodeUserID]inode.InodeUserID{1000: 2000},
+		GroupIDMap:  map[inode.InodeGroupID]inode.InodeGroupID{1000: 2000},
+	})
+	if err != nil {
+		t.Fatalf("MountWithIDMap() returned error: %v", err)
+	}
+
+	// A directory only root may modify
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestIDMapDir", inode.InodeMode(0755))
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	if mountHandle.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, inode.W_OK) {
+		t.Fatalf("Access(W_OK) by squashed root should have failed")
+	}
+	_, err = mountHandle.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestIDMapFile", inode.PosixModePerm)
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("Create() by squashed root should have returned PermDeniedError, got: %v", err)
+	}
+
+	// Ownership of created inodes reflects the mapped identity
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, Stat{StatMode: uint64(inode.PosixModePerm)})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+
+	squashedFileInodeNumber, err := mountHandle.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestIDMapSquashedFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() by squashed root returned error: %v", err)
+	}
+	stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, squashedFileInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if (65534 != stat[StatUserID]) || (65534 != stat[StatGroupID]) {
+		t.Fatalf("Create() by squashed root resulted in owner %v:%v (expected 65534:65534)", stat[StatUserID], stat[StatGroupID])
+	}
+
+	mappedFileInodeNumber, err := mountHandle.Create(1000, 1000, nil, dirInodeNumber, "TestIDMapMappedFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() by mapped user returned error: %v", err)
+	}
+	stat, err = mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, mappedFileInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if (2000 != stat[StatUserID]) || (2000 != stat[StatGroupID]) {
+		t.Fatalf("Create() by mapped user resulted in owner %v:%v (expected 2000:2000)", stat[StatUserID], stat[StatGroupID])
+	}
+
+	// Squashed root may no longer chown... but an unsquashed caller's chown target is mapped
+
+	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, mappedFileInodeNumber, Stat{StatUserID: 1000})
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("Setstat() by squashed root of another user's file should have returned NotPermError, got: %v", err)
+	}
+	err = mountHandle.Setstat(1000, 1000, nil, mappedFileInodeNumber, Stat{StatUserID: 1000, StatGroupID: 1000})
+	if err != nil {
+		t.Fatalf("Setstat() by mapped user returned error: %v", err)
+	}
+	stat, err = mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, mappedFileInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if (2000 != stat[StatUserID]) || (2000 != stat[StatGroupID]) {
+		t.Fatalf("Setstat() of owner 1000:1000 via mapped mount resulted in owner %v:%v (expected 2000:2000)", stat[StatUserID], stat[StatGroupID])
+	}
+
+	for _, basename := range []string{"TestIDMapSquashedFile", "TestIDMapMappedFile"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestIDMapDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestRenameFlags(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsFile", dirInodeNumber, "SubDir", RenameNoReplace)
+	if blunder.IsNot(err, blunder.FileExistsError) {
+		t.Fatalf("Rename(RenameNoReplace) onto existing entry should have returned FileExistsError, got: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsFile", dirInodeNumber, "SubDir", RenameExchange|0x80)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("Rename() with unknown flags should have returned InvalidArgError, got: %v", err)
+	}
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsFile", dirInodeNumber, "SubDir", RenameExchange)
+	if err != nil {
+		t.Fatalf("Rename(RenameExchange) returned error: %v", err)
+	}
+
+	lookupInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsFile")
+	if (err != nil) || (subDirInodeNumber != lookupInodeNumber) {
+		t.Fatalf("Lookup() after Rename(RenameExchange) returned %v, %v (expected %v)", lookupInodeNumber, err, subDirInodeNumber)
+	}
+	lookupInodeNumber, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir")
+	if (err != nil) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("Lookup() after Rename(RenameExchange) returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsFile")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameFlagsDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestReaddirByToken(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReaddirByTokenDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	fileNames := []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9"}
+	for _, fileName := range fileNames {
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fileName, inode.PosixModePerm)
+		if err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	_, _, _, err = mS.ReaddirByToken(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "not-a-token", 3, 0)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("ReaddirByToken() with malformed token should have returned InvalidArgError, got: %v", err)
+	}
+
+	// Page through the directory, inserting entries both before and after the resumption point mid-listing
+
+	seen := make(map[string]int)
+	continuationToken := ""
+	pages := 0
+	for {
+		entries, nextContinuationToken, areMoreEntries, readdirErr := mS.ReaddirByToken(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, continuationToken, 3, 0)
+		if readdirErr != nil {
+			t.Fatalf("ReaddirByToken() returned error: %v", readdirErr)
+		}
+		for _, entry := range entries {
+			seen[entry.Basename]++
+		}
+		pages++
+		if 1 == pages {
+			for _, fileName := range []string{"a-before", "z-after"} {
+				_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fileName, inode.PosixModePerm)
+				if err != nil {
+					t.Fatalf("Create() returned error: %v", err)
+				}
+			}
+		}
+		if !areMoreEntries {
+			break
+		}
+		if "" == nextContinuationToken {
+			t.Fatalf("ReaddirByToken() returned areMoreEntries without a nextContinuationToken")
+		}
+		continuationToken = nextContinuationToken
+	}
+
+	for _, fileName := range append(fileNames, ".", "..", "z-after") {
+		if 1 != seen[fileName] {
+			t.Fatalf("ReaddirByToken() returned \"%s\" %v times (expected once)", fileName, seen[fileName])
+		}
+	}
+	if 0 != seen["a-before"] {
+		t.Fatalf("ReaddirByToken() returned \"a-before\" inserted before the resumption point")
+	}
+
+	// Removing the last entry returned (which shifts every later entry down a slot) must not skip any entry
+
+	entries, continuationToken, _, err := mS.ReaddirByToken(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "", 5, 0)
+	if err != nil {
+		t.Fatalf("ReaddirByToken() returned error: %v", err)
+	}
+	lastBasename := entries[len(entries)-1].Basename
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, lastBasename)
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	entries, _, _, err = mS.ReaddirByToken(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, continuationToken, 1, 0)
+	if err != nil {
+		t.Fatalf("ReaddirByToken() returned error: %v", err)
+	}
+	if ("f1" != lastBasename) || (1 != len(entries)) || ("f2" != entries[0].Basename) {
+		t.Fatalf("ReaddirByToken() after Unlink() of \"%s\" resumed at %v (expected \"f2\")", lastBasename, entries)
+	}
+
+	for _, fileName := range append(fileNames, "a-before", "z-after") {
+		if fileName == lastBasename {
+			continue
+		}
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fileName)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReaddirByTokenDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestCreateUnlinked(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	// CreateUnlinked() followed by LinkByInode()
+
+	fileInodeNumber, err := mS.CreateUnlinked(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("CreateUnlinked() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("unnamed"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if 0 != stat[StatNLink] {
+		t.Fatalf("Getstat() of unnamed inode returned StatNLink == %v (expected 0)", stat[StatNLink])
+	}
+
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, OrphanStream)
+	if blunder.IsNot(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr() of %s should have returned StreamNotFound, got: %v", OrphanStream, err)
+	}
+
+	err = mS.LinkByInode(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCreateUnlinkedFile", fileInodeNumber)
+	if err != nil {
+		t.Fatalf("LinkByInode() returned error: %v", err)
+	}
+	lookupInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCreateUnlinkedFile")
+	if (err != nil) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("Lookup() after LinkByInode() returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 7, nil)
+	if (err != nil) || ("unnamed" != string(buf)) {
+		t.Fatalf("Read() after LinkByInode() returned \"%s\", %v", string(buf), err)
+	}
+
+	err = mS.LinkByInode(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCreateUnlinkedFile2", fileInodeNumber)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("LinkByInode() of a named inode should have returned InvalidArgError, got: %v", err)
+	}
+
+	// CreateUnlinked() followed by ReleaseUnlinked()
+
+	releasedInodeNumber, err := mS.CreateUnlinked(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("CreateUnlinked() returned error: %v", err)
+	}
+	err = mS.ReleaseUnlinked(inode.InodeRootUserID, inode.InodeRootGroupID, nil, releasedInodeNumber)
+	if err != nil {
+		t.Fatalf("ReleaseUnlinked() returned error: %v", err)
+	}
+	_, err = mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, releasedInodeNumber)
+	if err == nil {
+		t.Fatalf("Getstat() of released inode should have failed")
+	}
+
+	// Simulate a crash leaving one unnamed inode behind (and another since linked)
+
+	orphanInodeNumber, err := mS.CreateUnlinked(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("CreateUnlinked() returned error: %v", err)
+	}
+	linkedInodeNumber, err := mS.CreateUnlinked(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("CreateUnlinked() returned error: %v", err)
+	}
+	err = mS.Link(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCreateUnlinkedLinked", linkedInodeNumber)
+	if err != nil {
+		t.Fatalf("Link() returned error: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.orphanMap = make(map[inode.InodeNumber]struct{})
+	mS.volStruct.Unlock()
+
+	err = mS.volStruct.reapOrphans()
+	if err != nil {
+		t.Fatalf("reapOrphans() returned error: %v", err)
+	}
+
+	_, err = mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, orphanInodeNumber)
+	if err == nil {
+		t.Fatalf("Getstat() of reaped orphan inode should have failed")
+	}
+	stat, err = mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, linkedInodeNumber)
+	if (err != nil) || (1 != stat[StatNLink]) {
+		t.Fatalf("Getstat() of linked inode after reapOrphans() returned %v, %v", stat, err)
+	}
+	_, err = mS.volStruct.VolumeHandle.GetStream(rootDirInodeNumber, OrphanStream)
+	if blunder.IsNot(err, blunder.StreamNotFound) {
+		t.Fatalf("reapOrphans() should have removed %s, got: %v", OrphanStream, err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCreateUnlinkedLinked")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCreateUnlinkedFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestMknod(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	for _, special := range []struct {
+		basename  string
+		mode      inode.InodeMode
+		inodeType inode.InodeType
+	}{
+		{"TestMknodFIFO", inode.PosixModeFIFO | 0644, inode.FIFOType},
+		{"TestMknodSocket", inode.PosixModeSocket | 0755, inode.SocketType},
+		{"TestMknodFile", 0600, inode.FileType},
+	} {
+		inodeNumber, err := mS.Mknod(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, special.basename, special.mode)
+		if err != nil {
+			t.Fatalf("Mknod() of \"%s\" returned error: %v", special.basename, err)
+		}
+
+		lookupInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, special.basename)
+		if (err != nil) || (inodeNumber != lookupInodeNumber) {
+			t.Fatalf("Lookup() of \"%s\" returned %v, %v (expected %v)", special.basename, lookupInodeNumber, err, inodeNumber)
+		}
+		stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inodeNumber)
+		if err != nil {
+			t.Fatalf("Getstat() of \"%s\" returned error: %v", special.basename, err)
+		}
+		if uint64(special.inodeType) != stat[StatFType] {
+			t.Fatalf("Getstat() of \"%s\" returned StatFType == %v (expected %v)", special.basename, stat[StatFType], special.inodeType)
+		}
+		if uint64(special.mode&inode.PosixModePerm) != (stat[StatMode] & uint64(inode.PosixModePerm)) {
+			t.Fatalf("Getstat() of \"%s\" returned StatMode == 0%o (expected permissions 0%o)", special.basename, stat[StatMode], special.mode&inode.PosixModePerm)
+		}
+
+		_, err = mS.Mknod(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, special.basename, special.mode)
+		if blunder.IsNot(err, blunder.FileExistsError) {
+			t.Fatalf("Mknod() of existing \"%s\" should have failed with FileExistsError: %v", special.basename, err)
+		}
+
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, special.basename)
+		if err != nil {
+			t.Fatalf("Unlink() of \"%s\" returned error: %v", special.basename, err)
+		}
+	}
+
+	// Character (0x2000) and block (0x6000) devices are not supported
+
+	for _, mode := range []inode.InodeMode{0x2000 | 0644, 0x6000 | 0644} {
+		_, err := mS.Mknod(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMknodDevice", mode)
+		if blunder.IsNot(err, blunder.NotPermError) {
+			t.Fatalf("Mknod() of mode 0%o should have failed with NotPermError: %v", mode, err)
+		}
+	}
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMknodDir", 0755)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Mknod(inode.InodeUserID(1), inode.InodeGroupID(1), nil, dirInodeNumber, "TestMknodNoAccess", inode.PosixModeFIFO|0644)
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("Mknod() without write access to the directory should have failed with PermDeniedError: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMknodDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestFlushDir(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestFlushDirDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", dirInodeNumber, "RenamedFile", 0)
+	if err != nil {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+
+	err = mS.FlushDir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber)
+	if err != nil {
+		t.Fatalf("FlushDir() returned error: %v", err)
+	}
+
+	err = mS.FlushDir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if blunder.IsNot(err, blunder.NotDirError) {
+		t.Fatalf("FlushDir() of a file should have failed with NotDirError: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "RenamedFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestFlushDirDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+
+	err = mS.FlushDir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("FlushDir() of a removed directory should have failed with NotFoundError: %v", err)
+	}
+}
+
+func TestLeases(t *testing.T) {
+	type leaseBreakStruct struct {
+		leaseID LeaseID
+		breakTo LeaseType
+	}
+
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestLeasesFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	breakChan := make(chan leaseBreakStruct, 8)
+	otherBreakChan := make(chan leaseBreakStruct, 8)
+	handler := func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {
+		breakChan <- leaseBreakStruct{leaseID, breakTo}
+	}
+	otherHandler := func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {
+		otherBreakChan <- leaseBreakStruct{leaseID, breakTo}
+	}
+	expectBreak := func(breakChan chan leaseBreakStruct, leaseID LeaseID, breakTo LeaseType) {
+		select {
+		case leaseBreak := <-breakChan:
+			if (leaseID != leaseBreak.leaseID) || (breakTo != leaseBreak.breakTo) {
+				t.Fatalf("LeaseBreakHandler invoked for lease %v to %v (expected lease %v to %v)", leaseBreak.leaseID, leaseBreak.breakTo, leaseID, breakTo)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("LeaseBreakHandler not invoked for lease %v", leaseID)
+		}
+	}
+	acquireLeaseAsync := func(mountHandle MountHandle, leaseType LeaseType, handler LeaseBreakHandler) (acquiredChan chan LeaseID) {
+		acquiredChan = make(chan LeaseID, 1)
+		go func() {
+			leaseID, acquireErr := mountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, leaseType, handler)
+			if acquireErr != nil {
+				t.Errorf("AcquireLease() returned error: %v", acquireErr)
+			}
+			acquiredChan <- leaseID
+		}()
+		return
+	}
+
+	_, err = mS.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseNone, handler)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("AcquireLease(LeaseNone) should have failed with InvalidArgError: %v", err)
+	}
+
+	// Read leases held via different mounts do not conflict
+
+	readLeaseID, err := mS.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseRead, handler)
+	if err != nil {
+		t.Fatalf("AcquireLease(LeaseRead) returned error: %v", err)
+	}
+	otherReadLeaseID, err := otherMountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseRead, otherHandler)
+	if err != nil {
+		t.Fatalf("AcquireLease(LeaseRead) via other mount returned error: %v", err)
+	}
+
+	err = mS.DowngradeLease(readLeaseID, LeaseWrite)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("DowngradeLease() to LeaseWrite should have failed with InvalidArgError: %v", err)
+	}
+	err = otherMountHandle.ReleaseLease(readLeaseID)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("ReleaseLease() via a mount not holding the lease should have failed with NotFoundError: %v", err)
+	}
+
+	// A write lease breaks the other mount's read lease (but not those of its own mount)
+
+	acquiredChan := acquireLeaseAsync(mS, LeaseWrite, handler)
+	expectBreak(otherBreakChan, otherReadLeaseID, LeaseNone)
+	err = otherMountHandle.ReleaseLease(otherReadLeaseID)
+	if err != nil {
+		t.Fatalf("ReleaseLease() returned error: %v", err)
+	}
+	writeLeaseID := <-acquiredChan
+
+	// A read lease breaks the other mount's write lease to a read lease
+
+	acquiredChan = acquireLeaseAsync(otherMountHandle, LeaseRead, otherHandler)
+	expectBreak(breakChan, writeLeaseID, LeaseRead)
+	err = mS.DowngradeLease(writeLeaseID, LeaseRead)
+	if err != nil {
+		t.Fatalf("DowngradeLease() returned error: %v", err)
+	}
+	otherReadLeaseID = <-acquiredChan
+
+	// A holder failing to respond to a break within LeaseBreakTimeout is forcibly downgraded
+
+	mS.volStruct.Lock()
+	leaseBreakTimeout := mS.volStruct.leaseBreakTimeout
+	mS.volStruct.leaseBreakTimeout = 100 * time.Millisecond
+	mS.volStruct.Unlock()
+
+	otherWriteLeaseID, err := otherMountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseWrite, otherHandler)
+	if err != nil {
+		t.Fatalf("AcquireLease(LeaseWrite) via other mount returned error: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.leaseBreakTimeout = leaseBreakTimeout
+	mS.volStruct.Unlock()
+
+	// Both of this mount's leases are broken, in no particular order
+
+	brokenLeaseIDs := make(map[LeaseID]struct{})
+	for len(brokenLeaseIDs) < 2 {
+		select {
+		case leaseBreak := <-breakChan:
+			if ((readLeaseID != leaseBreak.leaseID) && (writeLeaseID != leaseBreak.leaseID)) || (LeaseNone != leaseBreak.breakTo) {
+				t.Fatalf("LeaseBreakHandler invoked for lease %v to %v (expected lease %v or %v to %v)", leaseBreak.leaseID, leaseBreak.breakTo, readLeaseID, writeLeaseID, LeaseNone)
+			}
+			brokenLeaseIDs[leaseBreak.leaseID] = struct{}{}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("LeaseBreakHandler not invoked for both leases %v and %v", readLeaseID, writeLeaseID)
+		}
+	}
+
+	for _, leaseID := range []LeaseID{readLeaseID, writeLeaseID} {
+		err = mS.ReleaseLease(leaseID)
+		if blunder.IsNot(err, blunder.NotFoundError) {
+			t.Fatalf("ReleaseLease() of forcibly broken lease should have failed with NotFoundError: %v", err)
+		}
+	}
+
+	for _, leaseID := range []LeaseID{otherReadLeaseID, otherWriteLeaseID} {
+		err = otherMountHandle.ReleaseLease(leaseID)
+		if err != nil {
+			t.Fatalf("ReleaseLease() returned error: %v", err)
+		}
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestLeasesFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestMiddlewareFreezeContainer(t *testing.T) {
+	err := mS.MiddlewarePutContainer("TestFreezeContainer", []byte(""), []byte(""))
+	if err != nil {
+		t.Fatalf("MiddlewarePutContainer() returned error: %v", err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	_, _, err = mS.MiddlewareFreezeContainer("TestFreezeContainerMissing", 0)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareFreezeContainer() of missing container returned %v (expected NotFoundError)", err)
+	}
+
+	freezeID, expiry, err := mS.MiddlewareFreezeContainer("TestFreezeContainer", time.Minute)
+	if err != nil {
+		t.Fatalf("MiddlewareFreezeContainer() returned error: %v", err)
+	}
+	if time.Until(expiry) > time.Minute {
+		t.Fatalf("MiddlewareFreezeContainer() returned expiry %v beyond requested TTL", expiry)
+	}
+
+	_, _, err = otherMountHandle.MiddlewareFreezeContainer("TestFreezeContainer", 0)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("MiddlewareFreezeContainer() of frozen container returned %v (expected DevBusyError)", err)
+	}
+
+	// Mutations via the freezing mount proceed
+
+	_, _, _, err = mS.MiddlewareMkdir("TestFreezeContainer", "a", nil)
+	if err != nil {
+		t.Fatalf("MiddlewareMkdir() via freezing mount returned error: %v", err)
+	}
+
+	// Mutations via another mount wait until thawed
+
+	mkdirDoneChan := make(chan error, 1)
+	go func() {
+		_, _, _, mkdirErr := otherMountHandle.MiddlewareMkdir("TestFreezeContainer", "b", nil)
+		mkdirDoneChan <- mkdirErr
+	}()
+
+	select {
+	case err = <-mkdirDoneChan:
+		t.Fatalf("MiddlewareMkdir() via other mount completed (with %v) while frozen", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	err = mS.MiddlewareThawContainer("TestFreezeContainer", freezeID+1)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareThawContainer() with wrong FreezeID returned %v (expected NotFoundError)", err)
+	}
+
+	err = mS.MiddlewareThawContainer("TestFreezeContainer", freezeID)
+	if err != nil {
+		t.Fatalf("MiddlewareThawContainer() returned error: %v", err)
+	}
+
+	select {
+	case err = <-mkdirDoneChan:
+		if err != nil {
+			t.Fatalf("MiddlewareMkdir() via other mount returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("MiddlewareMkdir() via other mount not released by thaw")
+	}
+
+	// Freezes expire after their TTL
+
+	_, _, err = mS.MiddlewareFreezeContainer("TestFreezeContainer", 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("MiddlewareFreezeContainer() returned error: %v", err)
+	}
+
+	_, _, _, err = otherMountHandle.MiddlewareMkdir("TestFreezeContainer", "c", nil)
+	if err != nil {
+		t.Fatalf("MiddlewareMkdir() via other mount after expiry returned error: %v", err)
+	}
+
+	// Freezes are thawed as their mount is unmounted
+
+	freezeMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	_, _, err = freezeMountHandle.MiddlewareFreezeContainer("TestFreezeContainer", 0)
+	if err != nil {
+		t.Fatalf("MiddlewareFreezeContainer() returned error: %v", err)
+	}
+
+	err = Unmount(freezeMountHandle)
+	if err != nil {
+		t.Fatalf("Unmount() returned error: %v", err)
+	}
+
+	err = otherMountHandle.MiddlewareDelete("TestFreezeContainer", "c")
+	if err != nil {
+		t.Fatalf("MiddlewareDelete() after Unmount() returned error: %v", err)
+	}
+
+	err = Unmount(otherMountHandle)
+	if err != nil {
+		t.Fatalf("Unmount() returned error: %v", err)
+	}
+}
+
+func TestFreezeContainerInodeMutations(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestFreezeInodeContainer", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "dir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "file", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	otherContainerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestFreezeInodeOtherContainer", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	otherFileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, otherContainerInodeNumber, "file", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	freezeID, _, err := mS.MiddlewareFreezeContainer("TestFreezeInodeContainer", time.Minute)
+	if err != nil {
+		t.Fatalf("MiddlewareFreezeContainer() returned error: %v", err)
+	}
+
+	// Mutations via the freezing mount (and of other containers) proceed
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("a"), nil)
+	if err != nil {
+		t.Fatalf("Write() via freezing mount returned error: %v", err)
+	}
+	_, err = otherMountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, otherFileInodeNumber, 0, []byte("a"), nil)
+	if err != nil {
+		t.Fatalf("Write() via other mount to other container returned error: %v", err)
+	}
+
+	// Mutations beneath the frozen container via another mount wait until thawed
+
+	writeDoneChan := make(chan error, 1)
+	go func() {
+		_, writeErr := otherMountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("b"), nil)
+		writeDoneChan <- writeErr
+	}()
+	createDoneChan := make(chan error, 1)
+	go func() {
+		_, createErr := otherMountHandle.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "other", inode.PosixModePerm)
+		createDoneChan <- createErr
+	}()
+	rmdirDoneChan := make(chan error, 1)
+	go func() {
+		rmdirDoneChan <- otherMountHandle.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestFreezeInodeContainer")
+	}()
+
+	select {
+	case err = <-writeDoneChan:
+		t.Fatalf("Write() via other mount completed (with %v) while frozen", err)
+	case err = <-createDoneChan:
+		t.Fatalf("Create() via other mount completed (with %v) while frozen", err)
+	case err = <-rmdirDoneChan:
+		t.Fatalf("Rmdir() of frozen container via other mount completed (with %v) while frozen", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	err = mS.MiddlewareThawContainer("TestFreezeInodeContainer", freezeID)
+	if err != nil {
+		t.Fatalf("MiddlewareThawContainer() returned error: %v", err)
+	}
+
+	for _, doneChan := range []chan error{writeDoneChan, createDoneChan} {
+		select {
+		case err = <-doneChan:
+			if err != nil {
+				t.Fatalf("Mutation via other mount returned error: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Mutation via other mount not released by thaw")
+		}
+	}
+	select {
+	case err = <-rmdirDoneChan:
+		if blunder.IsNot(err, blunder.NotEmptyError) {
+			t.Fatalf("Rmdir() of non-empty container returned %v (expected NotEmptyError)", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Rmdir() via other mount not released by thaw")
+	}
+
+	// A mutation that found its (unfrozen) container keeps a Rename() from moving what it alters elsewhere until it completes
+
+	freezeID, _, err = mS.MiddlewareFreezeContainer("TestFreezeInodeOtherContainer", time.Minute)
+	if err != nil {
+		t.Fatalf("MiddlewareFreezeContainer() returned error: %v", err)
+	}
+
+	exitInodes := otherMountHandle.(*mountStruct).enterInodes(dirInodeNumber)
+
+	renameDoneChan := make(chan error, 1)
+	go func() {
+		renameDoneChan <- mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "dir", otherContainerInodeNumber, "dir", 0)
+	}()
+
+	select {
+	case err = <-renameDoneChan:
+		t.Fatalf("Rename() completed (with %v) while a mutation of the renamed directory remained in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	exitInodes()
+
+	select {
+	case err = <-renameDoneChan:
+		if err != nil {
+			t.Fatalf("Rename() returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Rename() not released by completion of mutation in flight")
+	}
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, otherContainerInodeNumber, "dir", containerInodeNumber, "dir", 0)
+	if err != nil {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+
+	err = mS.MiddlewareThawContainer("TestFreezeInodeOtherContainer", freezeID)
+	if err != nil {
+		t.Fatalf("MiddlewareThawContainer() returned error: %v", err)
+	}
+
+	err = Unmount(otherMountHandle)
+	if err != nil {
+		t.Fatalf("Unmount() returned error: %v", err)
+	}
+
+	for _, basename := range []string{"file", "other"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, otherContainerInodeNumber, "file")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "dir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	for _, basename := range []string{"TestFreezeInodeContainer", "TestFreezeInodeOtherContainer"} {
+		err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Rmdir() returned error: %v", err)
+		}
+	}
+}
+
+func TestPutIntents(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+	vS := mS.volStruct
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPutIntentsContainer", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	// A successful MiddlewareMkdir() of a chain leaves no intent behind
+
+	_, mkdirInodeNumber, _, err := mS.MiddlewareMkdir("TestPutIntentsContainer", "a/b/c", nil)
+	if err != nil {
+		t.Fatalf("MiddlewareMkdir() returned error: %v", err)
+	}
+	lookupInodeNumber, err := mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestPutIntentsContainer/a/b/c")
+	if (err != nil) || (mkdirInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() after MiddlewareMkdir() returned %v, %v (expected %v)", lookupInodeNumber, err, mkdirInodeNumber)
+	}
+	vS.intents.Lock()
+	pendingIntents := len(vS.intents.intentMap)
+	vS.intents.Unlock()
+	if 0 != pendingIntents {
+		t.Fatalf("MiddlewareMkdir() left %v intents behind", pendingIntents)
+	}
+
+	// Simulate crashes before the final Link()... one whose name is still free (and whose obstacle was
+	// already removed) plus one whose name has since been taken
+
+	makeChain := func(basename string, obstacleInodeNumber inode.InodeNumber) (intent *putIntentStruct) {
+		fileInodeNumber, err := vS.VolumeHandle.CreateFile(inode.PosixModePerm, 0, 0)
+		if err != nil {
+			t.Fatalf("CreateFile() returned error: %v", err)
+		}
+		dirInodeNumber, err := vS.VolumeHandle.CreateDir(inode.PosixModePerm, 0, 0)
+		if err != nil {
+			t.Fatalf("CreateDir() returned error: %v", err)
+		}
+		err = vS.VolumeHandle.Link(dirInodeNumber, "File", fileInodeNumber)
+		if err != nil {
+			t.Fatalf("Link() returned error: %v", err)
+		}
+		intent = &putIntentStruct{
+			ParentInodeNumber:   containerInodeNumber,
+			ChainInodeNumbers:   []inode.InodeNumber{fileInodeNumber, dirInodeNumber},
+			Basenames:           []string{"File", basename},
+			ObstacleInodeNumber: obstacleInodeNumber,
+		}
+		_, err = vS.recordPutIntent(intent)
+		if err != nil {
+			t.Fatalf("recordPutIntent() returned error: %v", err)
+		}
+		return
+	}
+
+	obstacleInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "Completed", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	err = vS.VolumeHandle.Unlink(containerInodeNumber, "Completed")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	completedIntent := makeChain("Completed", obstacleInodeNumber)
+
+	rolledBackIntent := makeChain("RolledBack", 0)
+	takenInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "RolledBack", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	err = vS.recoverIntents()
+	if err != nil {
+		t.Fatalf("recoverIntents() returned error: %v", err)
+	}
+
+	lookupInodeNumber, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestPutIntentsContainer/Completed/File")
+	if (err != nil) || (completedIntent.ChainInodeNumbers[0] != lookupInodeNumber) {
+		t.Fatalf("LookupPath() of completed chain returned %v, %v (expected %v)", lookupInodeNumber, err, completedIntent.ChainInodeNumbers[0])
+	}
+	_, err = vS.VolumeHandle.GetType(obstacleInodeNumber)
+	if err == nil {
+		t.Fatalf("GetType() of replaced inode should have failed")
+	}
+
+	lookupInodeNumber, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "RolledBack")
+	if (err != nil) || (takenInodeNumber != lookupInodeNumber) {
+		t.Fatalf("Lookup() of taken name returned %v, %v (expected %v)", lookupInodeNumber, err, takenInodeNumber)
+	}
+	for _, chainInodeNumber := range rolledBackIntent.ChainInodeNumbers {
+		_, err = vS.VolumeHandle.GetType(chainInodeNumber)
+		if err == nil {
+			t.Fatalf("GetType() of rolled back inode %v should have failed", chainInodeNumber)
+		}
+	}
+
+	vS.intents.Lock()
+	pendingIntents = len(vS.intents.intentMap)
+	vS.intents.Unlock()
+	if 0 != pendingIntents {
+		t.Fatalf("recoverIntents() left %v intents behind", pendingIntents)
+	}
+
+	// The intent journal is not visible via the XAttr APIs
+
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, IntentJournalStream)
+	if blunder.IsNot(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr() of %s should have returned StreamNotFound, got: %v", IntentJournalStream, err)
+	}
+
+	for _, path := range []string{"Completed/File", "Completed", "RolledBack", "a/b/c", "a/b", "a"} {
+		dirPath, basename := "TestPutIntentsContainer", path
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			dirPath, basename = dirPath+"/"+path[:i], path[i+1:]
+		}
+		dirInodeNumber, err := mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirPath)
+		if err != nil {
+			t.Fatalf("LookupPath(%s) returned error: %v", dirPath, err)
+		}
+		if ("Completed/File" == path) || ("RolledBack" == path) {
+			err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		} else {
+			err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		}
+		if err != nil {
+			t.Fatalf("removing %s returned error: %v", path, err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPutIntentsContainer")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestMandatoryByteRangeLocks(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMandatoryByteRangeLocksFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123456789ABCDEFGHIJ"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	lock := &FlockStruct{Type: syscall.F_WRLCK, Whence: 0, Start: 0, Len: 10, Pid: 1}
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, syscall.F_SETLK, lock)
+	if err != nil {
+		t.Fatalf("Flock() returned error: %v", err)
+	}
+
+	// Locks are advisory by default
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 10, nil)
+	if err != nil {
+		t.Fatalf("Read() of advisory locked range returned error: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.mandatoryLockMode = mandatoryLockModeFail
+	mS.volStruct.Unlock()
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 5, 10, nil)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Read() of locked range should have failed with TryAgainError: %v", err)
+	}
+	_, err = mS.WriteWithFlockPid(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 2, 0, []byte("x"), nil)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("WriteWithFlockPid() of range locked by another owner should have failed with TryAgainError: %v", err)
+	}
+	buf, err := mS.ReadWithFlockPid(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 1, 0, 10, nil)
+	if (err != nil) || ("0123456789" != string(buf)) {
+		t.Fatalf("ReadWithFlockPid() by lock owner returned \"%s\", %v", string(buf), err)
+	}
+	_, err = mS.WriteWithFlockPid(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 1, 0, []byte("a"), nil)
+	if err != nil {
+		t.Fatalf("WriteWithFlockPid() by lock owner returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 10, []byte("k"), nil)
+	if err != nil {
+		t.Fatalf("Write() outside locked range returned error: %v", err)
+	}
+
+	// In "block" mode, a conflicting Write() waits for the lock to be released
+
+	mS.volStruct.Lock()
+	mS.volStruct.mandatoryLockMode = mandatoryLockModeBlock
+	mS.volStruct.Unlock()
+
+	writeDoneChan := make(chan error, 1)
+	go func() {
+		_, writeErr := mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 9, []byte("j"), nil)
+		writeDoneChan <- writeErr
+	}()
+
+	select {
+	case err = <-writeDoneChan:
+		t.Fatalf("Write() of locked range should have blocked (returned %v)", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock := &FlockStruct{Type: syscall.F_UNLCK, Whence: 0, Start: 0, Len: 10, Pid: 1}
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, syscall.F_SETLK, unlock)
+	if err != nil {
+		t.Fatalf("Flock() returned error: %v", err)
+	}
+
+	select {
+	case err = <-writeDoneChan:
+		if err != nil {
+			t.Fatalf("Write() after lock released returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Write() remained blocked after lock released")
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.mandatoryLockMode = mandatoryLockModeNone
+	mS.volStruct.Unlock()
+
+	buf, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 11, nil)
+	if (err != nil) || ("a12345678jk" != string(buf)) {
+		t.Fatalf("Read() returned \"%s\", %v", string(buf), err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMandatoryByteRangeLocksFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestOperationCaps(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestOperationCapsDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123456789"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	savedMaxEntriesPerOperation := mS.volStruct.limits.MaxEntriesPerOperation
+	savedMaxBytesPerOperation := mS.volStruct.limits.MaxBytesPerOperation
+	mS.volStruct.limits.MaxEntriesPerOperation = 2
+	mS.volStruct.Unlock()
+
+	defer func() {
+		mS.volStruct.Lock()
+		mS.volStruct.limits.MaxEntriesPerOperation = savedMaxEntriesPerOperation
+		mS.volStruct.limits.MaxBytesPerOperation = savedMaxBytesPerOperation
+		mS.volStruct.Unlock()
+	}()
+
+	// ".", "..", & "File" exceed the cap of 2 entries
+
+	entries, numEntries, areMoreEntries, err := mS.Readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "", uint64(1)<<32, 0)
+	if err != nil {
+		t.Fatalf("Readdir() returned error: %v", err)
+	}
+	if (2 != numEntries) || (2 != len(entries)) || !areMoreEntries {
+		t.Fatalf("Readdir() with huge maxEntries returned %v entries (areMoreEntries == %v), expected 2 (true)", numEntries, areMoreEntries)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.limits.MaxBytesPerOperation = 4
+	mS.volStruct.Unlock()
+
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, uint64(1)<<40, nil)
+	if (err != nil) || ("0123" != string(buf)) {
+		t.Fatalf("Read() with huge length returned \"%s\", %v", string(buf), err)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.limits.MaxBytesPerOperation = savedMaxBytesPerOperation
+	mS.volStruct.Unlock()
+
+	buf, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, uint64(1)<<40, nil)
+	if (err != nil) || ("0123456789" != string(buf)) {
+		t.Fatalf("Read() with huge length returned \"%s\", %v", string(buf), err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestOperationCapsDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestOpenHandles(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestOpenHandlesFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	// Share modes
+
+	writeHandle, err := mS.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenWrite|OpenAppend, ShareRead)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	_, err = otherMountHandle.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenWrite, ShareRead|ShareWrite)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Open() for write of file not shared for write should have failed with DevBusyError, got: %v", err)
+	}
+	_, err = otherMountHandle.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenRead, ShareRead)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Open() not sharing write of file open for write should have failed with DevBusyError, got: %v", err)
+	}
+	readHandle, err := otherMountHandle.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenRead, ShareRead|ShareWrite)
+	if err != nil {
+		t.Fatalf("Open() of compatible share mode returned error: %v", err)
+	}
+
+	// Access is limited to that requested and to the opening mount
+
+	_, err = mS.ReadByHandle(writeHandle, 0, 1, nil)
+	if blunder.IsNot(err, blunder.BadFileError) {
+		t.Fatalf("ReadByHandle() via handle not open for reading should have failed with BadFileError, got: %v", err)
+	}
+	_, err = mS.ReadByHandle(readHandle, 0, 1, nil)
+	if blunder.IsNot(err, blunder.BadFileError) {
+		t.Fatalf("ReadByHandle() via another mount's handle should have failed with BadFileError, got: %v", err)
+	}
+
+	// Appends ignore offset
+
+	_, err = mS.WriteByHandle(writeHandle, 0, []byte("0123"), nil)
+	if err != nil {
+		t.Fatalf("WriteByHandle() returned error: %v", err)
+	}
+	_, err = mS.WriteByHandle(writeHandle, 0, []byte("4567"), nil)
+	if err != nil {
+		t.Fatalf("WriteByHandle() returned error: %v", err)
+	}
+	buf, err := otherMountHandle.ReadByHandle(readHandle, 0, 8, nil)
+	if err != nil {
+		t.Fatalf("ReadByHandle() returned error: %v", err)
+	}
+	if "01234567" != string(buf) {
+		t.Fatalf("ReadByHandle() returned \"%s\" (expected \"01234567\")", string(buf))
+	}
+
+	// Each handle owns its locks... and Close() releases them
+
+	lock := &FlockStruct{Type: syscall.F_WRLCK, Whence: 0, Start: 0, Len: 4}
+	_, err = mS.FlockByHandle(writeHandle, syscall.F_SETLK, lock)
+	if err != nil {
+		t.Fatalf("FlockByHandle() returned error: %v", err)
+	}
+	lock = &FlockStruct{Type: syscall.F_RDLCK, Whence: 0, Start: 0, Len: 4}
+	_, err = otherMountHandle.FlockByHandle(readHandle, syscall.F_SETLK, lock)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("FlockByHandle() of range locked by another handle should have failed with TryAgainError, got: %v", err)
+	}
+
+	err = mS.Close(writeHandle)
+	if err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+	err = mS.Close(writeHandle)
+	if blunder.IsNot(err, blunder.BadFileError) {
+		t.Fatalf("Close() of closed handle should have failed with BadFileError, got: %v", err)
+	}
+
+	lock = &FlockStruct{Type: syscall.F_RDLCK, Whence: 0, Start: 0, Len: 4}
+	_, err = otherMountHandle.FlockByHandle(readHandle, syscall.F_SETLK, lock)
+	if err != nil {
+		t.Fatalf("FlockByHandle() of range unlocked by Close() returned error: %v", err)
+	}
+
+	err = otherMountHandle.Close(readHandle)
+	if err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestOpenHandlesFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	limits := mS.GetLimits()
+	if (FileNameMax != limits.FileNameMax) || (FilePathMax != limits.FilePathMax) || (MaxSymlinks != limits.MaxSymlinks) || (FsBlockSize != limits.FsBlockSize) {
+		t.Fatalf("GetLimits() returned unexpected fixed limits: %+v", limits)
+	}
+	if (defaultXAttrNameMax != limits.XAttrNameMax) || (defaultXAttrValueMax != limits.XAttrValueMax) {
+		t.Fatalf("GetLimits() returned unexpected XAttr limits: %+v", limits)
+	}
+
+	savedLimits := limits
+	defer func() {
+		err := SetLimits("TestVolume", savedLimits)
+		if err != nil {
+			t.Fatalf("SetLimits() returned error: %v", err)
+		}
+	}()
+
+	badLimits := limits
+	badLimits.FileNameMax++
+	err := SetLimits("TestVolume", badLimits)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("SetLimits() adjusting FileNameMax should have failed with InvalidArgError, got: %v", err)
+	}
+
+	limits.XAttrNameMax = 6
+	limits.XAttrValueMax = 4
+	err = SetLimits("TestVolume", limits)
+	if err != nil {
+		t.Fatalf("SetLimits() returned error: %v", err)
+	}
+	if limits != mS.GetLimits() {
+		t.Fatalf("GetLimits() returned %+v after SetLimits(%+v)", mS.GetLimits(), limits)
+	}
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestLimitsFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.a", []byte("1234"), 0)
+	if err != nil {
+		t.Fatalf("SetXAttr() within limits returned error: %v", err)
+	}
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.ab", []byte("1"), 0)
+	if blunder.IsNot(err, blunder.OutOfRangeError) {
+		t.Fatalf("SetXAttr() of long name should have failed with OutOfRangeError, got: %v", err)
+	}
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.a", []byte("12345"), 0)
+	if blunder.IsNot(err, blunder.TooBigError) {
+		t.Fatalf("SetXAttr() of large value should have failed with TooBigError, got: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestLimitsFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReclaimDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123456789"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("abcd"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	report, err := AnalyzeReclaimable("TestVolume", nil)
+	if err != nil {
+		t.Fatalf("AnalyzeReclaimable() returned error: %v", err)
+	}
+	found := false
+	for _, candidate := range report.Candidates {
+		if fileInodeNumber == candidate.InodeNumber {
+			if 4 != candidate.TrappedBytes {
+				t.Fatalf("AnalyzeReclaimable() reported %v TrappedBytes (expected 4)", candidate.TrappedBytes)
+			}
+			found = true
+		}
+	}
+	if !found || report.Stopped {
+		t.Fatalf("AnalyzeReclaimable() did not report overwritten file: %+v", report)
+	}
+
+	result, err := Reclaim("TestVolume", []inode.InodeNumber{fileInodeNumber}, 0, nil)
+	if err != nil {
+		t.Fatalf("Reclaim() returned error: %v", err)
+	}
+	if (1 != result.FilesCompacted) || (4 != result.ReclaimedBytes) || result.Stopped {
+		t.Fatalf("Reclaim() returned %+v", result)
+	}
+
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 10, nil)
+	if (err != nil) || ("abcd456789" != string(buf)) {
+		t.Fatalf("Read() after Reclaim() returned \"%s\", %v", string(buf), err)
+	}
+
+	_, err = AnalyzeReclaimable("NoSuchVolume", nil)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("AnalyzeReclaimable() of unknown volume should have failed with NotFoundError, got: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReclaimDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestLookupPathSymlinks(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestLookupPathDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "RelativeLink", "SubDir")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "AbsoluteLink", "/TestLookupPathDir/SubDir/File")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "LoopLink", "LoopLink")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+
+	lookupInodeNumber, err := mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestLookupPathDir/RelativeLink/File")
+	if (err != nil) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() through relative symlink returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+	lookupInodeNumber, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestLookupPathDir/AbsoluteLink")
+	if (err != nil) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() of absolute symlink returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+	lookupInodeNumber, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/")
+	if (err != nil) || (rootDirInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() of \"/\" returned %v, %v", lookupInodeNumber, err)
+	}
+
+	_, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestLookupPathDir/LoopLink")
+	if blunder.IsNot(err, blunder.TooManySymlinksError) {
+		t.Fatalf("LookupPath() of symlink loop should have failed with TooManySymlinksError, instead got: %v", err)
+	}
+	_, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestLookupPathDir/AbsoluteLink/File")
+	if blunder.IsNot(err, blunder.NotDirError) {
+		t.Fatalf("LookupPath() beneath a file should have failed with NotDirError, instead got: %v", err)
+	}
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, Stat{StatMode: 0600})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	_, err = mS.LookupPath(inode.InodeUserID(1), inode.InodeGroupID(1), nil, "/TestLookupPathDir/RelativeLink/File")
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("LookupPath() without search permission should have failed with PermDeniedError, instead got: %v", err)
+	}
+
+	for _, basename := range []string{"RelativeLink", "AbsoluteLink", "LoopLink"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestLookupPathDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestVerifyVolume(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+	accountName := mS.volStruct.VolumeHandle.GetAccountName()
+
+	putObject := func(containerName string, objectName string, buf []byte) {
+		chunkedPutContext, err := swiftclient.ObjectFetchChunkedPutContext(accountName, containerName, objectName)
+		if err != nil {
+			t.Fatalf("ObjectFetchChunkedPutContext() returned error: %v", err)
+		}
+		err = chunkedPutContext.SendChunk(buf)
+		if err != nil {
+			t.Fatalf("SendChunk() returned error: %v", err)
+		}
+		err = chunkedPutContext.Close()
+		if err != nil {
+			t.Fatalf("Close() returned error: %v", err)
+		}
+	}
+	containsObject := func(verifyObjects []VerifyObject, verifyObject VerifyObject) bool {
+		for _, candidate := range verifyObjects {
+			if candidate == verifyObject {
+				return true
+			}
+		}
+		return false
+	}
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestVerifyVolumeDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("verify"), nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+
+	locations, err := mS.volStruct.VolumeHandle.FetchLogSegmentLocations(fileInodeNumber)
+	if (err != nil) || (1 != len(locations)) || ("" == locations[0].ContainerName) {
+		t.Fatalf("FetchLogSegmentLocations() returned %+v, %v", locations, err)
+	}
+	missingObject := VerifyObject{
+		ContainerName: locations[0].ContainerName,
+		ObjectName:    locations[0].ObjectName,
+		InodeNumber:   fileInodeNumber,
+	}
+	strayObject := VerifyObject{
+		ContainerName: locations[0].ContainerName,
+		ObjectName:    "TestVerifyVolumeStray",
+	}
+
+	report, err := VerifyVolume("TestVolume", nil)
+	if err != nil {
+		t.Fatalf("VerifyVolume() returned error: %v", err)
+	}
+	if (0 == report.FilesScanned) || (0 == report.ContainersListed) || containsObject(report.MissingObjects, missingObject) || report.Stopped {
+		t.Fatalf("VerifyVolume() of consistent file returned %+v", report)
+	}
+
+	segmentBuf, err := swiftclient.ObjectLoad(accountName, missingObject.ContainerName, missingObject.ObjectName)
+	if err != nil {
+		t.Fatalf("ObjectLoad() returned error: %v", err)
+	}
+	err = swiftclient.ObjectDeleteSync(accountName, missingObject.ContainerName, missingObject.ObjectName)
+	if err != nil {
+		t.Fatalf("ObjectDeleteSync() returned error: %v", err)
+	}
+	putObject(strayObject.ContainerName, strayObject.ObjectName, []byte("stray"))
+
+	report, err = VerifyVolume("TestVolume", nil)
+	if err != nil {
+		t.Fatalf("VerifyVolume() returned error: %v", err)
+	}
+	if !containsObject(report.MissingObjects, missingObject) {
+		t.Fatalf("VerifyVolume() did not report missing LogSegment %+v: %+v", missingObject, report)
+	}
+	if !containsObject(report.UnreferencedObjects, strayObject) {
+		t.Fatalf("VerifyVolume() did not report unreferenced Object %+v: %+v", strayObject, report)
+	}
+
+	putObject(missingObject.ContainerName, missingObject.ObjectName, segmentBuf)
+	err = swiftclient.ObjectDeleteSync(accountName, strayObject.ContainerName, strayObject.ObjectName)
+	if err != nil {
+		t.Fatalf("ObjectDeleteSync() returned error: %v", err)
+	}
+
+	_, err = VerifyVolume("NoSuchVolume", nil)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("VerifyVolume() of unknown volume should have failed with NotFoundError, got: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestVerifyVolumeDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestPathAt(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPathAtDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Link", "SubDir/File")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+
+	resolvedInodeNumber, err := mS.ResolvePathAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir/File")
+	if (err != nil) || (fileInodeNumber != resolvedInodeNumber) {
+		t.Fatalf("ResolvePathAt() returned %v, %v (expected %v)", resolvedInodeNumber, err, fileInodeNumber)
+	}
+	resolvedInodeNumber, err = mS.ResolvePathAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "../Link")
+	if (err != nil) || (fileInodeNumber != resolvedInodeNumber) {
+		t.Fatalf("ResolvePathAt() via \"..\" and symlink returned %v, %v (expected %v)", resolvedInodeNumber, err, fileInodeNumber)
+	}
+	resolvedInodeNumber, err = mS.ResolvePathAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "/TestPathAtDir")
+	if (err != nil) || (dirInodeNumber != resolvedInodeNumber) {
+		t.Fatalf("ResolvePathAt() of absolute path returned %v, %v (expected %v)", resolvedInodeNumber, err, dirInodeNumber)
+	}
+	_, err = mS.ResolvePathAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "File")
+	if nil == err {
+		t.Fatalf("ResolvePathAt() from a file should have failed")
+	}
+
+	fileHandle, err := mS.OpenAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Link", OpenWrite, ShareRead|ShareWrite)
+	if err != nil {
+		t.Fatalf("OpenAt() returned error: %v", err)
+	}
+	_, err = mS.WriteByHandle(fileHandle, 0, []byte("at"), nil)
+	if err != nil {
+		t.Fatalf("WriteByHandle() returned error: %v", err)
+	}
+	err = mS.Close(fileHandle)
+	if err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	err = mS.RenameAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File", dirInodeNumber, "SubDir/Renamed", 0)
+	if err != nil {
+		t.Fatalf("RenameAt() returned error: %v", err)
+	}
+	resolvedInodeNumber, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "Renamed")
+	if (err != nil) || (fileInodeNumber != resolvedInodeNumber) {
+		t.Fatalf("Lookup() after RenameAt() returned %v, %v (expected %v)", resolvedInodeNumber, err, fileInodeNumber)
+	}
+
+	// UnlinkAt() removes (rather than follows) a final symlink
+
+	err = mS.UnlinkAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "../Link", false)
+	if err != nil {
+		t.Fatalf("UnlinkAt() of symlink returned error: %v", err)
+	}
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "Renamed")
+	if err != nil {
+		t.Fatalf("UnlinkAt() of symlink should not have removed its target: %v", err)
+	}
+	err = mS.UnlinkAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir/.", true)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("UnlinkAt() of \".\" should have failed with InvalidArgError, instead got: %v", err)
+	}
+	err = mS.UnlinkAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir/Renamed", false)
+	if err != nil {
+		t.Fatalf("UnlinkAt() returned error: %v", err)
+	}
+	err = mS.UnlinkAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPathAtDir/SubDir", true)
+	if err != nil {
+		t.Fatalf("UnlinkAt() of directory returned error: %v", err)
+	}
+	err = mS.UnlinkAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "/TestPathAtDir", true)
+	if err != nil {
+		t.Fatalf("UnlinkAt() of absolute path returned error: %v", err)
+	}
+}
+
+func TestInodeHistory(t *testing.T) {
+	const historyDepth = uint64(16)
+
+	rootDirInodeNumber := inode.RootDirInodeNumber
+	vS := mS.volStruct
+
+	vS.configureHistory(historyDepth)
+	defer vS.configureHistory(defaultInodeHistoryDepth)
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestInodeHistoryDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil == err {
+		t.Fatalf("Create() of existing basename should have failed")
+	}
+
+	entries, err := FetchInodeHistory("TestVolume", dirInodeNumber)
+	if err != nil {
+		t.Fatalf("FetchInodeHistory() returned error: %v", err)
+	}
+	if 3 != len(entries) {
+		t.Fatalf("FetchInodeHistory() of directory returned %d entries (expected 3): %v", len(entries), entries)
+	}
+	if !strings.HasPrefix(entries[0].Op, "Mkdir TestInodeHistoryDir") || ("" != entries[0].Result) {
+		t.Fatalf("FetchInodeHistory() of directory returned unexpected entries[0]: %+v", entries[0])
+	}
+	expectedOp := fmt.Sprintf("Create File (inode %d)", fileInodeNumber)
+	if (expectedOp != entries[1].Op) || ("" != entries[1].Result) || (mS.id != entries[1].MountID) {
+		t.Fatalf("FetchInodeHistory() of directory returned unexpected entries[1]: %+v", entries[1])
+	}
+	if !strings.HasPrefix(entries[2].Op, "Create File") || ("" == entries[2].Result) {
+		t.Fatalf("FetchInodeHistory() of directory returned unexpected entries[2]: %+v", entries[2])
+	}
+
+	for offset := uint64(0); offset < historyDepth+4; offset++ {
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, offset, []byte{0x00}, nil)
+		if err != nil {
+			t.Fatalf("Write() returned error: %v", err)
+		}
+	}
+
+	entries, err = FetchInodeHistory("TestVolume", fileInodeNumber)
+	if err != nil {
+		t.Fatalf("FetchInodeHistory() returned error: %v", err)
+	}
+	if historyDepth != uint64(len(entries)) {
+		t.Fatalf("FetchInodeHistory() of file returned %d entries (expected %d)", len(entries), historyDepth)
+	}
+	for index, entry := range entries {
+		expectedOp = fmt.Sprintf("Write 1 bytes at offset %d", uint64(index)+4)
+		if expectedOp != entry.Op {
+			t.Fatalf("FetchInodeHistory() of file returned entries[%d].Op == %q (expected %q)", index, entry.Op, expectedOp)
+		}
+		if (0 < index) && entry.Time.Before(entries[index-1].Time) {
+			t.Fatalf("FetchInodeHistory() of file returned entries out of order")
+		}
+	}
+
+	// History is kept in the file's reserved stream (so survives a restart)...
+
+	_, err = vS.VolumeHandle.GetStream(fileInodeNumber, InodeHistoryStream)
+	if err != nil {
+		t.Fatalf("GetStream(InodeHistoryStream) of file returned error: %v", err)
+	}
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, InodeHistoryStream)
+	if nil == err {
+		t.Fatalf("GetXAttr(InodeHistoryStream) should have failed")
+	}
+
+	// ...but Reads are not recorded
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 1, nil)
+	if err != nil {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	entries, err = FetchInodeHistory("TestVolume", fileInodeNumber)
+	if err != nil {
+		t.Fatalf("FetchInodeHistory() returned error: %v", err)
+	}
+	expectedOp = fmt.Sprintf("Write 1 bytes at offset %d", historyDepth+3)
+	if expectedOp != entries[len(entries)-1].Op {
+		t.Fatalf("FetchInodeHistory() of read file returned unexpected last entry: %+v", entries[len(entries)-1])
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	entries, err = FetchInodeHistory("TestVolume", dirInodeNumber)
+	if err != nil {
+		t.Fatalf("FetchInodeHistory() returned error: %v", err)
+	}
+	expectedOp = fmt.Sprintf("Unlink File (inode %d)", fileInodeNumber)
+	if expectedOp != entries[len(entries)-1].Op {
+		t.Fatalf("FetchInodeHistory() of directory returned unexpected last entry: %+v", entries[len(entries)-1])
+	}
+	_, err = FetchInodeHistory("TestVolume", fileInodeNumber)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchInodeHistory() of unlinked file returned %v (expected NotFoundError)", err)
+	}
+	_, err = FetchInodeHistory("NoSuchVolume", fileInodeNumber)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchInodeHistory() of unknown volume returned %v (expected NotFoundError)", err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestInodeHistoryDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestReaddirMatch(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReaddirMatchDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	basenames := []string{"IMG_1.jpg", "IMG_2.png", "IMG_3.jpg", "a.jpg", "b.png", "c.jpg"}
+	for _, basename := range basenames {
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	// Walk the matches one at a time as an SMB client would
+	matched := make([]string, 0)
+	prevDirLocation := inode.InodeDirLocation(-1)
+	for {
+		entries, _, err := mS.ReaddirMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, prevDirLocation, "*.jpg", 1, 0)
+		if nil != err {
+			if !blunder.Is(err, blunder.NotFoundError) {
+				t.Fatalf("ReaddirMatch() returned unexpected error: %v", err)
+			}
+			break
+		}
+		if 1 != len(entries) {
+			t.Fatalf("ReaddirMatch() returned %d entries (expected 1)", len(entries))
+		}
+		if (inode.FileType != entries[0].Type) || (0 == entries[0].InodeNumber) {
+			t.Fatalf("ReaddirMatch() returned unexpected entry: %+v", entries[0])
+		}
+		matched = append(matched, entries[0].Basename)
+		prevDirLocation = entries[0].NextDirLocation - 1
+	}
+	if "IMG_1.jpg,IMG_3.jpg,a.jpg,c.jpg" != strings.Join(matched, ",") {
+		t.Fatalf("ReaddirMatch() of \"*.jpg\" returned %v", matched)
+	}
+
+	entries, areMoreEntries, err := mS.ReaddirMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, -1, "IMG_*.jpg", 0, 0)
+	if (err != nil) || (2 != len(entries)) || areMoreEntries || ("IMG_1.jpg" != entries[0].Basename) || ("IMG_3.jpg" != entries[1].Basename) {
+		t.Fatalf("ReaddirMatch() of \"IMG_*.jpg\" returned %v, %v, %v", entries, areMoreEntries, err)
+	}
+	entries, areMoreEntries, err = mS.ReaddirMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, -1, "?.png", 0, 0)
+	if (err != nil) || (1 != len(entries)) || areMoreEntries || ("b.png" != entries[0].Basename) {
+		t.Fatalf("ReaddirMatch() of \"?.png\" returned %v, %v, %v", entries, areMoreEntries, err)
+	}
+	entries, areMoreEntries, err = mS.ReaddirMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, -1, "*.jpg", 2, 0)
+	if (err != nil) || (2 != len(entries)) || !areMoreEntries {
+		t.Fatalf("ReaddirMatch() of \"*.jpg\" limited to 2 entries returned %v, %v, %v", entries, areMoreEntries, err)
+	}
+
+	_, _, err = mS.ReaddirMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, -1, "*.gif", 0, 0)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("ReaddirMatch() without matches returned %v (expected NotFoundError)", err)
+	}
+	_, _, err = mS.ReaddirMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, -1, "[", 0, 0)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("ReaddirMatch() of malformed pattern returned %v (expected InvalidArgError)", err)
+	}
+
+	dirEntries, statEntries, _, err := mS.ReaddirPlusMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, -1, "*.png", 0, 0)
+	if (err != nil) || (2 != len(dirEntries)) || (2 != len(statEntries)) {
+		t.Fatalf("ReaddirPlusMatch() returned %v, %v, %v", dirEntries, statEntries, err)
+	}
+	for i := range dirEntries {
+		if uint64(dirEntries[i].InodeNumber) != statEntries[i][StatINum] {
+			t.Fatalf("ReaddirPlusMatch() returned mismatched Stat for %s", dirEntries[i].Basename)
+		}
+	}
+
+	for _, basename := range basenames {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReaddirMatchDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestRetryLockConflicts(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.Lock()
+	savedLockRetry := vS.lockRetry
+	vS.lockRetry = lockRetryStruct{
+		limit:      3,
+		delay:      time.Millisecond,
+		maxDelay:   2 * time.Millisecond,
+		expBackoff: 2.0,
+	}
+	vS.Unlock()
+
+	defer func() {
+		vS.Lock()
+		vS.lockRetry = savedLockRetry
+		vS.Unlock()
+	}()
+
+	attempts := 0
+	err := vS.retryLockConflicts(func() (retriable bool, err error) {
+		attempts++
+		if 3 > attempts {
+			retriable = true
+			err = blunder.NewError(blunder.TryAgainError, "conflict")
+		}
+		return
+	})
+	if (nil != err) || (3 != attempts) {
+		t.Fatalf("retryLockConflicts() of transient conflict returned %v after %d attempts (expected success after 3)", err, attempts)
+	}
+
+	attempts = 0
+	err = vS.retryLockConflicts(func() (retriable bool, err error) {
+		attempts++
+		err = blunder.NewError(blunder.TryAgainError, "metadata differs")
+		return
+	})
+	if !blunder.Is(err, blunder.TryAgainError) || (1 != attempts) {
+		t.Fatalf("retryLockConflicts() of non-retriable failure returned %v after %d attempts (expected 1)", err, attempts)
+	}
+
+	attempts = 0
+	err = vS.retryLockConflicts(func() (retriable bool, err error) {
+		attempts++
+		retriable = true
+		err = blunder.NewError(blunder.TryAgainError, "conflict")
+		return
+	})
+	if !blunder.Is(err, blunder.TryAgainError) || (4 != attempts) {
+		t.Fatalf("retryLockConflicts() of persistent conflict returned %v after %d attempts (expected 4)", err, attempts)
+	}
+}
+
+func TestReaddirByTokenRename(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReaddirByTokenRenameDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	fileNames := []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9"}
+	for _, fileName := range fileNames {
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fileName, inode.PosixModePerm)
+		if err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	entries, continuationToken, _, err := mS.ReaddirByToken(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "", 5, 0)
+	if (err != nil) || (5 != len(entries)) || ("f2" != entries[4].Basename) {
+		t.Fatalf("ReaddirByToken() returned %v, %v", entries, err)
+	}
+
+	// Move the last entry returned ahead of the resumption point and an unreturned entry past the end
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "f2", dirInodeNumber, "a2", 0)
+	if err != nil {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "f5", dirInodeNumber, "g5", 0)
+	if err != nil {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+
+	seen := make([]string, 0)
+	for {
+		entries, nextContinuationToken, areMoreEntries, readdirErr := mS.ReaddirByToken(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, continuationToken, 2, 0)
+		if readdirErr != nil {
+			t.Fatalf("ReaddirByToken() returned error: %v", readdirErr)
+		}
+		for _, entry := range entries {
+			seen = append(seen, entry.Basename)
+		}
+		if !areMoreEntries {
+			break
+		}
+		continuationToken = nextContinuationToken
+	}
+	if "f3,f4,f6,f7,f8,f9,g5" != strings.Join(seen, ",") {
+		t.Fatalf("ReaddirByToken() resumed after renames returned %v (expected [f3 f4 f6 f7 f8 f9 g5])", seen)
+	}
+
+	// Version 1 tokens (lacking basenames) must still be accepted
+
+	tokenBuf := []byte{1}
+	field := make([]byte, binary.MaxVarintLen64)
+	for _, value := range []uint64{uint64(dirInodeNumber), 0, 4, uint64(dirInodeNumber)} {
+		n := binary.PutUvarint(field, value)
+		tokenBuf = append(tokenBuf, field[:n]...)
+	}
+	levels, err := decodeContinuationToken(base64.RawURLEncoding.EncodeToString(tokenBuf))
+	if (err != nil) || (1 != len(levels)) || (dirInodeNumber != levels[0].dirInodeNumber) || ("" != levels[0].entryBasename) {
+		t.Fatalf("decodeContinuationToken() of version 1 token returned %v, %v", levels, err)
+	}
+	tokenBuf[0] = 2
+	tokenBuf = append(tokenBuf, 9, 'x')
+	_, err = decodeContinuationToken(base64.RawURLEncoding.EncodeToString(tokenBuf))
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("decodeContinuationToken() of truncated basename returned %v (expected InvalidArgError)", err)
+	}
+
+	for _, fileName := range []string{"f0", "f1", "a2", "f3", "f4", "g5", "f6", "f7", "f8", "f9"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fileName)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReaddirByTokenRenameDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestMiddlewareEmptyObjects(t *testing.T) {
+	err := mS.MiddlewarePutContainer("TestEmptyObjectContainer", []byte(""), []byte("container-meta"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() returned error: %v", err)
+	}
+
+	// An empty object may be finalized with no log segments or with only empty ones
+
+	_, _, numWrites, err := mS.MiddlewarePutComplete("TestEmptyObjectContainer", "dir/a", nil, nil, []byte("meta-a"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() of object without log segments returned error: %v", err)
+	}
+	if 0 != numWrites {
+		t.Fatalf("MiddlewarePutComplete() of object without log segments returned numWrites %v (expected 0)", numWrites)
+	}
+
+	_, _, numWrites, err = mS.MiddlewarePutComplete("TestEmptyObjectContainer", "dir/c", []string{"/v1/AUTH_test/c/0000000000000001"}, []uint64{0}, []byte("meta-c"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() of object with empty log segment returned error: %v", err)
+	}
+	if 0 != numWrites {
+		t.Fatalf("MiddlewarePutComplete() of object with empty log segment returned numWrites %v (expected 0)", numWrites)
+	}
+
+	_, _, _, err = mS.MiddlewarePutComplete("TestEmptyObjectContainer", "dir/x", []string{"/v1/AUTH_test/c/0000000000000002"}, nil, []byte(""))
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewarePutComplete() with mismatched pObjectPaths and pObjectLengths should have returned InvalidArgError, got: %v", err)
+	}
+
+	// HEAD
+
+	headResponse, err := mS.MiddlewareHeadResponse("TestEmptyObjectContainer/dir/a")
+	if nil != err {
+		t.Fatalf("MiddlewareHeadResponse() returned error: %v", err)
+	}
+	if (0 != headResponse.FileSize) || headResponse.IsDir || ("meta-a" != string(headResponse.Metadata)) {
+		t.Fatalf("MiddlewareHeadResponse() of empty object returned unexpected %+v", headResponse)
+	}
+
+	// GET of the whole object as well as of ranges beyond its (zero) length
+
+	zero := uint64(0)
+	ten := uint64(10)
+
+	for _, readRangeIn := range [][]ReadRangeIn{nil, {{Offset: &zero, Len: nil}}, {{Offset: nil, Len: &ten}}, {{Offset: &ten, Len: &ten}}} {
+		readRangeOut := make([]inode.ReadPlanStep, 0)
+		fileSize, _, _, _, serializedMetadata, _, _, getErr := mS.MiddlewareGetObject("TestVolume", "TestEmptyObjectContainer/dir/c", readRangeIn, &readRangeOut)
+		if nil != getErr {
+			t.Fatalf("MiddlewareGetObject() of empty object with ranges %+v returned error: %v", readRangeIn, getErr)
+		}
+		if (0 != fileSize) || ("meta-c" != string(serializedMetadata)) {
+			t.Fatalf("MiddlewareGetObject() of empty object returned fileSize %v and metadata \"%s\"", fileSize, string(serializedMetadata))
+		}
+		for _, step := range readRangeOut {
+			if 0 != step.Length {
+				t.Fatalf("MiddlewareGetObject() of empty object with ranges %+v returned non-empty read plan %+v", readRangeIn, readRangeOut)
+			}
+		}
+	}
+
+	// Listing
+
+	containerEnts, err := mS.MiddlewareGetContainer("TestEmptyObjectContainer", 10, "", "dir/")
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainer() returned error: %v", err)
+	}
+	if 2 != len(containerEnts) {
+		t.Fatalf("MiddlewareGetContainer() returned %v entries (expected 2)", len(containerEnts))
+	}
+	for _, containerEnt := range containerEnts {
+		if (0 != containerEnt.FileSize) || containerEnt.IsDir {
+			t.Fatalf("MiddlewareGetContainer() returned unexpected %+v", containerEnt)
+		}
+	}
+
+	// Coalescing empty elements with a non-empty one yields just the non-empty one's content
+
+	containerInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestEmptyObjectContainer")
+	if nil != err {
+		t.Fatalf("Lookup() returned error: %v", err)
+	}
+	dirInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "dir")
+	if nil != err {
+		t.Fatalf("Lookup() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "b", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("hello"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	combinedInodeNumber, _, _, err := mS.MiddlewareCoalesce("TestEmptyObjectContainer/combined", []string{"TestEmptyObjectContainer/dir/a", "TestEmptyObjectContainer/dir/b", "TestEmptyObjectContainer/dir/c"})
+	if nil != err {
+		t.Fatalf("MiddlewareCoalesce() returned error: %v", err)
+	}
+	combinedContents, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(combinedInodeNumber), 0, 100, nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	if "hello" != string(combinedContents) {
+		t.Fatalf("MiddlewareCoalesce() of empty and non-empty elements produced \"%s\" (expected \"hello\")", string(combinedContents))
+	}
+
+	// A suffix range longer than the object covers just the whole object
+
+	readRangeOut := make([]inode.ReadPlanStep, 0)
+	_, _, _, _, _, _, _, err = mS.MiddlewareGetObject("TestVolume", "TestEmptyObjectContainer/combined", []ReadRangeIn{{Offset: nil, Len: &ten}}, &readRangeOut)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObject() returned error: %v", err)
+	}
+	readPlanBytes := uint64(0)
+	for _, step := range readRangeOut {
+		readPlanBytes += step.Length
+	}
+	if 5 != readPlanBytes {
+		t.Fatalf("MiddlewareGetObject() of suffix range longer than object returned %v bytes (expected 5)", readPlanBytes)
+	}
+
+	// Coalescing only empty elements yields an empty object
+
+	for _, basename := range []string{"d", "e"} {
+		_, _, _, err = mS.MiddlewarePutComplete("TestEmptyObjectContainer", "dir/"+basename, nil, nil, []byte(""))
+		if nil != err {
+			t.Fatalf("MiddlewarePutComplete() returned error: %v", err)
+		}
+	}
+
+	_, _, _, err = mS.MiddlewareCoalesce("TestEmptyObjectContainer/empty", []string{"TestEmptyObjectContainer/dir/d", "TestEmptyObjectContainer/dir/e"})
+	if nil != err {
+		t.Fatalf("MiddlewareCoalesce() of empty elements returned error: %v", err)
+	}
+	headResponse, err = mS.MiddlewareHeadResponse("TestEmptyObjectContainer/empty")
+	if nil != err {
+		t.Fatalf("MiddlewareHeadResponse() returned error: %v", err)
+	}
+	if 0 != headResponse.FileSize {
+		t.Fatalf("MiddlewareCoalesce() of empty elements produced FileSize %v (expected 0)", headResponse.FileSize)
+	}
+}
+
+func TestGetstatExtended(t *testing.T) {
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestGetstatExtendedFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	getstat := func() (stat Stat) {
+		stat, getstatErr := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+		if nil != getstatErr {
+			t.Fatalf("Getstat() returned error: %v", getstatErr)
+		}
+		return
+	}
+
+	stat := getstat()
+	if (0 != stat[StatBlocks]) || (FsOptimalTransferSize != stat[StatBlkSize]) {
+		t.Fatalf("Getstat() of empty file returned StatBlocks %v & StatBlkSize %v", stat[StatBlocks], stat[StatBlkSize])
+	}
+	changeCount := stat[StatChangeCount]
+
+	// Blocks reflect only the bytes written, not the hole between them
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("hello"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 1024*1024, make([]byte, 1000), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	stat = getstat()
+	if (1024*1024+1000 != stat[StatSize]) || (2 != stat[StatBlocks]) {
+		t.Fatalf("Getstat() of sparse file returned StatSize %v & StatBlocks %v (expected %v & 2)", stat[StatSize], stat[StatBlocks], 1024*1024+1000)
+	}
+	if stat[StatChangeCount] <= changeCount {
+		t.Fatalf("Write() should have incremented StatChangeCount")
+	}
+	changeCount = stat[StatChangeCount]
+
+	// Reads leave the change count alone while attribute changes increment it
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 5, nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	if getstat()[StatChangeCount] != changeCount {
+		t.Fatalf("Read() should not have changed StatChangeCount")
+	}
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: uint64(0600)})
+	if nil != err {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	if getstat()[StatChangeCount] <= changeCount {
+		t.Fatalf("Setstat() should have incremented StatChangeCount")
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestGetstatExtendedFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestHeavyMiddlewareOps(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.heavyOps.Lock()
+	limit, queueDepth, putCompleteSegments := vS.heavyOps.limit, vS.heavyOps.queueDepth, vS.heavyOps.putCompleteSegments
+	vS.heavyOps.Unlock()
+	defer vS.configureHeavyOps(limit, queueDepth, putCompleteSegments)
+
+	err := mS.MiddlewarePutContainer("TestHeavyOpsContainer", []byte(""), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() returned error: %v", err)
+	}
+
+	vS.configureHeavyOps(1, 1, 2)
+
+	// Occupy the only slot so that the next heavy operation queues...
+
+	err = vS.admitHeavyOp()
+	if nil != err {
+		t.Fatalf("admitHeavyOp() returned error: %v", err)
+	}
+
+	listingErrC := make(chan error, 1)
+	go func() {
+		_, listingErr := mS.MiddlewareGetContainer("TestHeavyOpsContainer", 10, "", "")
+		listingErrC <- listingErr
+	}()
+
+	for {
+		vS.heavyOps.Lock()
+		queued := vS.heavyOps.queued
+		vS.heavyOps.Unlock()
+		if 1 == queued {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// ...and the one after that is rejected
+
+	_, _, _, err = mS.MiddlewareCoalesce("TestHeavyOpsContainer/combined", []string{"TestHeavyOpsContainer/dir/a"})
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("MiddlewareCoalesce() with full queue should have returned DevBusyError, got: %v", err)
+	}
+
+	_, _, _, err = mS.MiddlewarePutComplete("TestHeavyOpsContainer", "heavy", []string{"/v1/AUTH_test/c/0000000000000001", "/v1/AUTH_test/c/0000000000000002"}, []uint64{0, 0}, []byte(""))
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("MiddlewarePutComplete() of HeavyPutCompleteSegments with full queue should have returned DevBusyError, got: %v", err)
+	}
+
+	// A PutComplete of fewer segments is not heavy so proceeds regardless
+
+	_, _, _, err = mS.MiddlewarePutComplete("TestHeavyOpsContainer", "light", []string{"/v1/AUTH_test/c/0000000000000003"}, []uint64{0}, []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() of fewer than HeavyPutCompleteSegments returned error: %v", err)
+	}
+
+	select {
+	case err = <-listingErrC:
+		t.Fatalf("MiddlewareGetContainer() should have waited for a heavy operation slot (returned %v)", err)
+	default:
+	}
+
+	vS.releaseHeavyOp()
+
+	err = <-listingErrC
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainer() returned error: %v", err)
+	}
+
+	vS.heavyOps.Lock()
+	active, queued := vS.heavyOps.active, vS.heavyOps.queued
+	vS.heavyOps.Unlock()
+	if (0 != active) || (0 != queued) {
+		t.Fatalf("heavy operations left active (%v) or queued (%v)", active, queued)
+	}
+}
+
+func TestXAttrNamespaces(t *testing.T) {
+	const (
+		ownerUserID = inode.InodeUserID(1000)
+		otherUserID = inode.InodeUserID(2000)
+		userGroupID = inode.InodeGroupID(1000)
+	)
+
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestXAttrNamespacesFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatUserID: uint64(ownerUserID), StatMode: uint64(0666)})
+	if nil != err {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+
+	expectErr := func(what string, err error, expectedErr blunder.FsError) {
+		if blunder.IsNot(err, expectedErr) {
+			t.Fatalf("%s should have failed with %v, got: %v", what, expectedErr, err)
+		}
+	}
+	expectNoErr := func(what string, err error) {
+		if nil != err {
+			t.Fatalf("%s returned error: %v", what, err)
+		}
+	}
+
+	// Names must be in a namespace
+
+	err = mS.SetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "plain", []byte("v"), 0)
+	expectErr("SetXAttr() of name outside any namespace", err, blunder.NotSupportedError)
+	_, err = mS.GetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "plain")
+	expectErr("GetXAttr() of name outside any namespace", err, blunder.NotSupportedError)
+	err = mS.SetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "user.", []byte("v"), 0)
+	expectErr("SetXAttr() of bare namespace", err, blunder.InvalidArgError)
+
+	// user.* follows the file's permission bits
+
+	err = mS.SetXAttr(otherUserID, userGroupID, nil, fileInodeNumber, "user.a", []byte("v"), 0)
+	expectNoErr("SetXAttr() of user.a", err)
+	value, err := mS.GetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "user.a")
+	expectNoErr("GetXAttr() of user.a", err)
+	if "v" != string(value) {
+		t.Fatalf("GetXAttr() of user.a returned \"%s\" (expected \"v\")", string(value))
+	}
+
+	// system.* may only be modified by the owner (or a privileged caller)
+
+	err = mS.SetXAttr(otherUserID, userGroupID, nil, fileInodeNumber, "system.a", []byte("v"), 0)
+	expectErr("SetXAttr() of system.a by non-owner", err, blunder.NotPermError)
+	err = mS.SetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "system.a", []byte("v"), 0)
+	expectNoErr("SetXAttr() of system.a by owner", err)
+	err = mS.RemoveXAttr(otherUserID, userGroupID, nil, fileInodeNumber, "system.a")
+	expectErr("RemoveXAttr() of system.a by non-owner", err, blunder.NotPermError)
+
+	// security.* may only be modified by a privileged caller but is visible to all
+
+	err = mS.SetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "security.a", []byte("v"), 0)
+	expectErr("SetXAttr() of security.a by unprivileged caller", err, blunder.NotPermError)
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "security.a", []byte("v"), 0)
+	expectNoErr("SetXAttr() of security.a by privileged caller", err)
+	_, err = mS.GetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "security.a")
+	expectNoErr("GetXAttr() of security.a by unprivileged caller", err)
+
+	// trusted.* is only visible to a privileged caller
+
+	err = mS.SetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "trusted.a", []byte("v"), 0)
+	expectErr("SetXAttr() of trusted.a by unprivileged caller", err, blunder.NotPermError)
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "trusted.a", []byte("v"), 0)
+	expectNoErr("SetXAttr() of trusted.a by privileged caller", err)
+	_, err = mS.GetXAttr(ownerUserID, userGroupID, nil, fileInodeNumber, "trusted.a")
+	expectErr("GetXAttr() of trusted.a by unprivileged caller", err, blunder.StreamNotFound)
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "trusted.a")
+	expectNoErr("GetXAttr() of trusted.a by privileged caller", err)
+
+	// The middleware's stream is reserved
+
+	err = mS.volStruct.VolumeHandle.PutStream(fileInodeNumber, MiddlewareStream, []byte("metadata"))
+	expectNoErr("PutStream()", err)
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, MiddlewareStream)
+	expectErr("GetXAttr() of MiddlewareStream", err, blunder.StreamNotFound)
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, MiddlewareStream, []byte("v"), 0)
+	expectErr("SetXAttr() of MiddlewareStream", err, blunder.PermDeniedError)
+	err = mS.RemoveXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, MiddlewareStream)
+	expectErr("RemoveXAttr() of MiddlewareStream", err, blunder.StreamNotFound)
+
+	listXAttr := func(userID inode.InodeUserID, groupID inode.InodeGroupID) (streamNames []string) {
+		streamNames, listErr := mS.ListXAttr(userID, groupID, nil, fileInodeNumber)
+		expectNoErr("ListXAttr()", listErr)
+		sort.Strings(streamNames)
+		return
+	}
+
+	if streamNames := listXAttr(ownerUserID, userGroupID); !reflect.DeepEqual(streamNames, []string{"security.a", "system.a", "user.a"}) {
+		t.Fatalf("ListXAttr() by unprivileged caller returned %v", streamNames)
+	}
+	if streamNames := listXAttr(inode.InodeRootUserID, inode.InodeRootGroupID); !reflect.DeepEqual(streamNames, []string{"security.a", "system.a", "trusted.a", "user.a"}) {
+		t.Fatalf("ListXAttr() by privileged caller returned %v", streamNames)
+	}
+
+	// user.* may not be attached to a symlink
+
+	symlinkInodeNumber, err := mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestXAttrNamespacesSymlink", "TestXAttrNamespacesFile")
+	expectNoErr("Symlink()", err)
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, symlinkInodeNumber, "user.a", []byte("v"), 0)
+	expectErr("SetXAttr() of user.a on symlink", err, blunder.NotPermError)
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, symlinkInodeNumber, "trusted.a", []byte("v"), 0)
+	expectNoErr("SetXAttr() of trusted.a on symlink", err)
+
+	for _, basename := range []string{"TestXAttrNamespacesSymlink", "TestXAttrNamespacesFile"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, basename)
+		expectNoErr("Unlink()", err)
+	}
+}
+
+func TestSetXAttrIfMatch(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetXAttrIfMatchFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	setXAttrIfMatch := func(streamName string, oldValue []byte, newValue []byte) error {
+		return mS.SetXAttrIfMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, streamName, oldValue, newValue)
+	}
+
+	// A nil oldValue requires the XAttr not be set...
+
+	err = setXAttrIfMatch("user.a", nil, []byte("v1"))
+	if nil != err {
+		t.Fatalf("SetXAttrIfMatch() of unset XAttr returned error: %v", err)
+	}
+	err = setXAttrIfMatch("user.a", nil, []byte("v2"))
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("SetXAttrIfMatch() expecting unset XAttr should have failed with TryAgainError, got: %v", err)
+	}
+
+	// ...while a non-nil oldValue (even an empty one) requires the XAttr be set to it
+
+	err = setXAttrIfMatch("user.b", []byte{}, []byte("v1"))
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("SetXAttrIfMatch() of unset XAttr expecting a value should have failed with TryAgainError, got: %v", err)
+	}
+	err = setXAttrIfMatch("user.a", []byte("v0"), []byte("v2"))
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("SetXAttrIfMatch() expecting wrong value should have failed with TryAgainError, got: %v", err)
+	}
+	err = setXAttrIfMatch("user.a", []byte("v1"), []byte("v2"))
+	if nil != err {
+		t.Fatalf("SetXAttrIfMatch() expecting current value returned error: %v", err)
+	}
+
+	value, err := mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.a")
+	if nil != err {
+		t.Fatalf("GetXAttr() returned error: %v", err)
+	}
+	if "v2" != string(value) {
+		t.Fatalf("GetXAttr() returned \"%s\" (expected \"v2\")", string(value))
+	}
+
+	// Concurrent read-modify-write cycles lose no updates
+
+	const (
+		incrementers            = 4
+		incrementsPerIncrementer = 25
+	)
+
+	err = setXAttrIfMatch("user.counter", nil, []byte("0"))
+	if nil != err {
+		t.Fatalf("SetXAttrIfMatch() returned error: %v", err)
+	}
+
+	incrementErrC := make(chan error, incrementers)
+	for i := 0; i < incrementers; i++ {
+		go func() {
+			for n := 0; n < incrementsPerIncrementer; {
+				oldValue, getErr := mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.counter")
+				if nil != getErr {
+					incrementErrC <- getErr
+					return
+				}
+				counter, _ := strconv.Atoi(string(oldValue))
+				setErr := setXAttrIfMatch("user.counter", oldValue, []byte(strconv.Itoa(counter+1)))
+				if nil == setErr {
+					n++
+				} else if blunder.IsNot(setErr, blunder.TryAgainError) {
+					incrementErrC <- setErr
+					return
+				}
+			}
+			incrementErrC <- nil
+		}()
+	}
+	for i := 0; i < incrementers; i++ {
+		err = <-incrementErrC
+		if nil != err {
+			t.Fatalf("incrementer returned error: %v", err)
+		}
+	}
+
+	value, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.counter")
+	if nil != err {
+		t.Fatalf("GetXAttr() returned error: %v", err)
+	}
+	if strconv.Itoa(incrementers*incrementsPerIncrementer) != string(value) {
+		t.Fatalf("concurrent SetXAttrIfMatch() increments produced %s (expected %v)", string(value), incrementers*incrementsPerIncrementer)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetXAttrIfMatchFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestMountOptions(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fetchMode := func(inodeNumber inode.InodeNumber) inode.InodeMode {
+		stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inodeNumber)
+		if err != nil {
+			t.Fatalf("Getstat() returned error: %v", err)
+		}
+		return inode.InodeMode(stat[StatMode]) & inode.PosixModeBits
+	}
+
+	// Without MountNoSuid, setuid & setgid bits are retained
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMountOptionsFile", inode.PosixModeSetUID|inode.PosixModeSetGID|0755)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if fetchMode(fileInodeNumber) != inode.PosixModeSetUID|inode.PosixModeSetGID|0755 {
+		t.Fatalf("Create() via default mount should have retained setuid & setgid bits, got mode 0%o", fetchMode(fileInodeNumber))
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x00}, nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if fetchMode(fileInodeNumber) != inode.PosixModeSetUID|inode.PosixModeSetGID|0755 {
+		t.Fatalf("Write() via default mount should have retained setuid & setgid bits, got mode 0%o", fetchMode(fileInodeNumber))
+	}
+	if !mS.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, inode.X_OK) {
+		t.Fatalf("Access(X_OK) via default mount should have succeeded")
+	}
+
+	// MountNoSuid drops setuid & setgid bits from modes set and clears them upon modification
+
+	noSuidMountHandle, err := Mount("TestVolume", MountNoSuid)
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	noSuidFileInodeNumber, err := noSuidMountHandle.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMountOptionsNoSuidFile", inode.PosixModeSetUID|inode.PosixModeSticky|0755)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if fetchMode(noSuidFileInodeNumber) != inode.PosixModeSticky|0755 {
+		t.Fatalf("Create() via MountNoSuid mount should have dropped setuid bit, got mode 0%o", fetchMode(noSuidFileInodeNumber))
+	}
+	err = noSuidMountHandle.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, noSuidFileInodeNumber, Stat{StatMode: uint64(inode.PosixModeSetGID | 0700)})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	if fetchMode(noSuidFileInodeNumber) != 0700 {
+		t.Fatalf("Setstat() via MountNoSuid mount should have dropped setgid bit, got mode 0%o", fetchMode(noSuidFileInodeNumber))
+	}
+
+	_, err = noSuidMountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x01}, nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if fetchMode(fileInodeNumber) != 0755 {
+		t.Fatalf("Write() via MountNoSuid mount should have cleared setuid & setgid bits, got mode 0%o", fetchMode(fileInodeNumber))
+	}
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: uint64(inode.PosixModeSetUID | 0755)})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	err = noSuidMountHandle.Resize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0)
+	if err != nil {
+		t.Fatalf("Resize() returned error: %v", err)
+	}
+	if fetchMode(fileInodeNumber) != 0755 {
+		t.Fatalf("Resize() via MountNoSuid mount should have cleared setuid bit, got mode 0%o", fetchMode(fileInodeNumber))
+	}
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: uint64(inode.PosixModeSetGID | 0755)})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	err = noSuidMountHandle.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatUserID: 1})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	if fetchMode(fileInodeNumber) != 0755 {
+		t.Fatalf("Setstat(StatUserID) via MountNoSuid mount should have cleared setgid bit, got mode 0%o", fetchMode(fileInodeNumber))
+	}
+
+	// MountNoExec denies X_OK on all but directories
+
+	noExecMountHandle, err := Mount("TestVolume", MountNoExec)
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	if noExecMountHandle.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, inode.X_OK) {
+		t.Fatalf("Access(X_OK) of file via MountNoExec mount should have failed")
+	}
+	if noExecMountHandle.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, inode.R_OK|inode.X_OK) {
+		t.Fatalf("Access(R_OK|X_OK) of file via MountNoExec mount should have failed")
+	}
+	if !noExecMountHandle.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, inode.R_OK) {
+		t.Fatalf("Access(R_OK) of file via MountNoExec mount should have succeeded")
+	}
+	if !noExecMountHandle.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, inode.X_OK) {
+		t.Fatalf("Access(X_OK) of directory via MountNoExec mount should have succeeded")
+	}
+
+	// MountNoDev holds regardless, as device files cannot be created
+
+	noDevMountHandle, err := Mount("TestVolume", MountNoDev)
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+	_, err = noDevMountHandle.Mknod(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestMountOptionsDev", inode.InodeMode(0x2000)|0644)
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("Mknod() of character device should have returned NotPermError, got: %v", err)
+	}
+
+	for _, basename := range []string{"TestMountOptionsFile", "TestMountOptionsNoSuidFile"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+}
+
+func TestStatVfsAt(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	defer func() {
+		mS.volStruct.quotaBytes = 0
+		mS.volStruct.quotaInodes = 0
+		mS.volStruct.usageCache = nil
+	}()
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestStatVfsAtDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	// The volume's quota applies to each of its subtrees
+	mS.volStruct.quotaBytes = 20 * FsBlockSize
+	mS.volStruct.quotaInodes = 1000
+	mS.volStruct.usageCache = nil
+
+	statVFS, err := mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() failed: %v", err)
+	}
+	for _, inodeNumber := range []inode.InodeNumber{rootDirInodeNumber, dirInodeNumber} {
+		statVFSAt, err := mS.StatVfsAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inodeNumber)
+		if nil != err {
+			t.Fatalf("StatVfsAt(%v) failed: %v", inodeNumber, err)
+		}
+		if !reflect.DeepEqual(statVFS, statVFSAt) {
+			t.Fatalf("StatVfsAt(%v) returned %v (expected %v)", inodeNumber, statVFSAt, statVFS)
+		}
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestStatVfsAtDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+
+	_, err = mS.StatVfsAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("StatVfsAt() of removed directory should have returned NotFoundError, got: %v", err)
+	}
+}
+
+func TestTreeDescentLimits(t *testing.T) {
+	const depth = 8
+
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	defer func() {
+		mS.volStruct.Lock()
+		mS.volStruct.treeDescentLimits = treeDescentLimitsStruct{maxDepth: defaultMaxTreeDescentDepth, maxPending: defaultMaxTreeDescentPending}
+		mS.volStruct.Unlock()
+	}()
+	setLimits := func(maxDepth uint64, maxPending uint64) {
+		mS.volStruct.Lock()
+		mS.volStruct.treeDescentLimits = treeDescentLimitsStruct{maxDepth: maxDepth, maxPending: maxPending}
+		mS.volStruct.Unlock()
+	}
+
+	// Build TestTreeDescentContainer/d/d/.../d (depth levels below the container) with a sibling "d-e" of the first "d"
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestTreeDescentContainer", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "d-e", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	dirInodeNumbers := []inode.InodeNumber{containerInodeNumber}
+	for i := 0; i < depth; i++ {
+		dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumbers[len(dirInodeNumbers)-1], "d", inode.PosixModePerm)
+		if err != nil {
+			t.Fatalf("Mkdir() returned error: %v", err)
+		}
+		dirInodeNumbers = append(dirInodeNumbers, dirInodeNumber)
+	}
+
+	// Within limits, the whole (depth+2 directory) tree is listed & pinned
+
+	containerEnts, err := mS.MiddlewareGetContainer("TestTreeDescentContainer", 100, "", "")
+	if err != nil {
+		t.Fatalf("MiddlewareGetContainer() returned error: %v", err)
+	}
+	if depth+1 != len(containerEnts) {
+		t.Fatalf("MiddlewareGetContainer() returned %v entries (expected %v)", len(containerEnts), depth+1)
+	}
+	if "d-e" != containerEnts[1].Basename {
+		t.Fatalf("MiddlewareGetContainer() returned second entry \"%s\" (expected \"d-e\")", containerEnts[1].Basename)
+	}
+	if strings.Repeat("d/", depth-1)+"d" != containerEnts[depth].Basename {
+		t.Fatalf("MiddlewareGetContainer() returned deepest entry \"%s\"", containerEnts[depth].Basename)
+	}
+
+	_, err = mS.PinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestTreeDescentContainer")
+	if err != nil {
+		t.Fatalf("PinPath() returned error: %v", err)
+	}
+	pinnedInodes, _ := mS.volStruct.VolumeHandle.GetPinnedStats()
+	if depth+2 != pinnedInodes {
+		t.Fatalf("PinPath() should have pinned %v inodes, instead pinned %v", depth+2, pinnedInodes)
+	}
+	err = mS.UnpinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestTreeDescentContainer")
+	if err != nil {
+		t.Fatalf("UnpinPath() returned error: %v", err)
+	}
+
+	// Exactly enough levels suffices
+
+	setLimits(depth+1, defaultMaxTreeDescentPending)
+	_, err = mS.MiddlewareGetContainer("TestTreeDescentContainer", 100, "", "")
+	if err != nil {
+		t.Fatalf("MiddlewareGetContainer() with MaxTreeDescentDepth %v returned error: %v", depth+1, err)
+	}
+
+	// One level too few fails with NameTooLongError
+
+	setLimits(depth, defaultMaxTreeDescentPending)
+	_, err = mS.MiddlewareGetContainer("TestTreeDescentContainer", 100, "", "")
+	if blunder.IsNot(err, blunder.NameTooLongError) {
+		t.Fatalf("MiddlewareGetContainer() with MaxTreeDescentDepth %v should have failed with NameTooLongError, got: %v", depth, err)
+	}
+	_, err = mS.PinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestTreeDescentContainer")
+	if blunder.IsNot(err, blunder.NameTooLongError) {
+		t.Fatalf("PinPath() with MaxTreeDescentDepth %v should have failed with NameTooLongError, got: %v", depth, err)
+	}
+
+	// Remembering both "d" & "d-e" (which precedes "d/") of the container exceeds a MaxTreeDescentPending of 1
+
+	setLimits(defaultMaxTreeDescentDepth, 1)
+	_, err = mS.MiddlewareGetContainer("TestTreeDescentContainer", 100, "", "")
+	if blunder.IsNot(err, blunder.OutOfMemoryError) {
+		t.Fatalf("MiddlewareGetContainer() with MaxTreeDescentPending 1 should have failed with OutOfMemoryError, got: %v", err)
+	}
+	_, err = mS.PinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestTreeDescentContainer")
+	if blunder.IsNot(err, blunder.OutOfMemoryError) {
+		t.Fatalf("PinPath() with MaxTreeDescentPending 1 should have failed with OutOfMemoryError, got: %v", err)
+	}
+
+	setLimits(defaultMaxTreeDescentDepth, defaultMaxTreeDescentPending)
+	err = mS.UnpinPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestTreeDescentContainer")
+	if err != nil {
+		t.Fatalf("UnpinPath() returned error: %v", err)
+	}
+
+	for i := depth; i > 0; i-- {
+		err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumbers[i-1], "d")
+		if err != nil {
+			t.Fatalf("Rmdir() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "d-e")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestTreeDescentContainer")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestUnmount(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestUnmountFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	fileHandle, err := otherMountHandle.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenRead, ShareRead|ShareWrite|ShareDelete)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	lock := FlockStruct{Type: syscall.F_WRLCK, Whence: 0, Start: 0, Len: 0, Pid: 4242}
+	_, err = otherMountHandle.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, syscall.F_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Flock() returned error: %v", err)
+	}
+	_, err = otherMountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseWrite, func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {})
+	if err != nil {
+		t.Fatalf("AcquireLease() returned error: %v", err)
+	}
+	_, err = otherMountHandle.AddWatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, false, func(watchID WatchID, event NotifyEvent) {})
+	if err != nil {
+		t.Fatalf("AddWatch() returned error: %v", err)
+	}
+
+	// An open FileHandle keeps the mount busy (and usable)
+
+	err = Unmount(otherMountHandle)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Unmount() with open FileHandle should have returned DevBusyError, got: %v", err)
+	}
+	err = otherMountHandle.Close(fileHandle)
+	if err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	// While an operation remains in flight, the drain rejects new operations (but not the work of the one in flight)
+
+	otherMS := otherMountHandle.(*mountStruct)
+	err = otherMS.enterOp()
+	if err != nil {
+		t.Fatalf("enterOp() returned error: %v", err)
+	}
+	unmounted := make(chan error, 1)
+	go func() {
+		unmounted <- Unmount(otherMountHandle)
+	}()
+	for draining := false; !draining; {
+		otherMS.gate.Lock()
+		draining = (mountStateDraining == otherMS.gate.state)
+		otherMS.gate.Unlock()
+	}
+	_, err = otherMountHandle.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Getstat() via draining mount should have returned TryAgainError, got: %v", err)
+	}
+	_, err = otherMS.getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("getstat() within operation in flight via draining mount returned error: %v", err)
+	}
+	select {
+	case err = <-unmounted:
+		t.Fatalf("Unmount() returned while an operation remained in flight (err: %v)", err)
+	default:
+	}
+	otherMS.exitOp(nil)
+
+	err = <-unmounted
+	if err != nil {
+		t.Fatalf("Unmount() returned error: %v", err)
+	}
+
+	_, err = otherMountHandle.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if blunder.IsNot(err, blunder.BadMountIDError) {
+		t.Fatalf("Getstat() via unmounted mount should have returned BadMountIDError, got: %v", err)
+	}
+	err = Unmount(otherMountHandle)
+	if blunder.IsNot(err, blunder.BadMountIDError) {
+		t.Fatalf("second Unmount() should have returned BadMountIDError, got: %v", err)
+	}
+
+	// The mount's lock, lease, and watch are gone
+
+	lock = FlockStruct{Type: syscall.F_WRLCK, Whence: 0, Start: 0, Len: 0, Pid: 1}
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, syscall.F_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Flock() of lock released by Unmount() returned error: %v", err)
+	}
+	lock.Type = syscall.F_UNLCK
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, syscall.F_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Flock() unlock returned error: %v", err)
+	}
+
+	mS.volStruct.leases.Lock()
+	leaseCount := len(mS.volStruct.leases.leaseMap)
+	mS.volStruct.leases.Unlock()
+	if 0 != leaseCount {
+		t.Fatalf("Unmount() left %v leases", leaseCount)
+	}
+	mS.volStruct.notify.Lock()
+	watchCount := len(mS.volStruct.notify.watchMap)
+	mS.volStruct.notify.Unlock()
+	if 0 != watchCount {
+		t.Fatalf("Unmount() left %v watches", watchCount)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestUnmountFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestMountAuthentication(t *testing.T) {
+	volStruct := mS.volStruct
+
+	volStruct.Lock()
+	savedExportPolicy := volStruct.exportPolicy
+	volStruct.Unlock()
+
+	defer func() {
+		volStruct.Lock()
+		volStruct.exportPolicy = savedExportPolicy
+		volStruct.Unlock()
+		_ = RegisterMountAuthenticator(MountAuthToken, nil)
+	}()
+
+	setExportPolicy := func(confStrings []string) {
+		confMap, err := conf.MakeConfMapFromStrings(append([]string{"Volume:TestVolume.FSID=1"}, confStrings...))
+		if nil != err {
+			t.Fatalf("conf.MakeConfMapFromStrings() failed: %v", err)
+		}
+		exportPolicy, err := fetchExportPolicy(confMap, "Volume:TestVolume")
+		if nil != err {
+			t.Fatalf("fetchExportPolicy(%v) failed: %v", confStrings, err)
+		}
+		volStruct.Lock()
+		volStruct.exportPolicy = exportPolicy
+		volStruct.Unlock()
+	}
+	expectMount := func(credentials *MountCredentialsStruct, expectedIdentity MountIdentityStruct) {
+		mountHandle, err := MountAuthenticated("TestVolume", MountOptions(0), nil, credentials)
+		if nil != err {
+			t.Fatalf("MountAuthenticated(%+v) failed: %v", credentials, err)
+		}
+		identity := mountHandle.Identity()
+		if expectedIdentity != identity {
+			t.Fatalf("MountAuthenticated(%+v) granted %+v (expected %+v)", credentials, identity, expectedIdentity)
+		}
+		err = Unmount(mountHandle)
+		if nil != err {
+			t.Fatalf("Unmount() failed: %v", err)
+		}
+	}
+	expectDenied := func(credentials *MountCredentialsStruct) {
+		_, err := MountAuthenticated("TestVolume", MountOptions(0), nil, credentials)
+		if blunder.IsNot(err, blunder.PermDeniedError) {
+			t.Fatalf("MountAuthenticated(%+v) should have returned PermDeniedError, got: %v", credentials, err)
+		}
+	}
+
+	// In-process mounts are trusted
+
+	if (MountIdentityStruct{Method: MountAuthLocal}) != mS.Identity() {
+		t.Fatalf("Mount() granted %+v", mS.Identity())
+	}
+	_, err := MountAuthenticated("TestVolume", MountOptions(0), nil, nil)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("MountAuthenticated() without credentials should have returned InvalidArgError, got: %v", err)
+	}
+
+	// Export policies are validated
+
+	for _, confStrings := range [][]string{
+		{"Volume:TestVolume.MountAuthMethod=kerberos"},
+		{"Volume:TestVolume.MountAuthMethod=secret"},
+		{"Volume:TestVolume.MountAllowedClients=10.0.0.0/33"},
+		{"Volume:TestVolume.MountAllowedPrincipals=alice"},
+	} {
+		confMap, err := conf.MakeConfMapFromStrings(confStrings)
+		if nil != err {
+			t.Fatalf("conf.MakeConfMapFromStrings() failed: %v", err)
+		}
+		_, err = fetchExportPolicy(confMap, "Volume:TestVolume")
+		if nil == err {
+			t.Fatalf("fetchExportPolicy(%v) should have failed", confStrings)
+		}
+	}
+
+	// "none" & "secret" grant MountUserID & MountGroupID
+
+	setExportPolicy([]string{"Volume:TestVolume.MountUserID=1000", "Volume:TestVolume.MountGroupID=100"})
+	expectMount(&MountCredentialsStruct{Method: MountAuthNone}, MountIdentityStruct{Method: MountAuthNone, UserID: 1000, GroupID: 100})
+
+	setExportPolicy([]string{"Volume:TestVolume.MountAuthMethod=secret", "Volume:TestVolume.MountSecret=sesame"})
+	expectDenied(&MountCredentialsStruct{Method: MountAuthNone})
+	expectDenied(&MountCredentialsStruct{Method: MountAuthSecret, Secret: "simsim"})
+	expectMount(&MountCredentialsStruct{Method: MountAuthSecret, Secret: "sesame"}, MountIdentityStruct{Method: MountAuthSecret})
+
+	// MountAllowedClients
+
+	setExportPolicy([]string{"Volume:TestVolume.MountAllowedClients=10.0.0.0/8, 192.168.1.1"})
+	expectDenied(&MountCredentialsStruct{Method: MountAuthNone})
+	expectDenied(&MountCredentialsStruct{Method: MountAuthNone, ClientAddr: "192.168.1.2"})
+	expectMount(&MountCredentialsStruct{Method: MountAuthNone, ClientAddr: "10.1.2.3"}, MountIdentityStruct{Method: MountAuthNone})
+	expectMount(&MountCredentialsStruct{Method: MountAuthNone, ClientAddr: "192.168.1.1"}, MountIdentityStruct{Method: MountAuthNone})
+
+	// "token" requires a MountAuthenticator (& any MountAllowedPrincipals)
+
+	setExportPolicy([]string{"Volume:TestVolume.MountAuthMethod=token", "Volume:TestVolume.MountAllowedPrincipals=alice"})
+	expectDenied(&MountCredentialsStruct{Method: MountAuthToken, Token: "alice-token"})
+
+	err = RegisterMountAuthenticator(MountAuthToken, func(volumeName string, credentials *MountCredentialsStruct) (identity MountIdentityStruct, err error) {
+		if !strings.HasSuffix(credentials.Token, "-token") {
+			err = fmt.Errorf("invalid token")
+			return
+		}
+		identity = MountIdentityStruct{Principal: strings.TrimSuffix(credentials.Token, "-token"), UserID: 2000, GroupID: 200}
+		return
+	})
+	if nil != err {
+		t.Fatalf("RegisterMountAuthenticator() failed: %v", err)
+	}
+
+	expectDenied(&MountCredentialsStruct{Method: MountAuthSecret, Secret: "alice-token"})
+	expectDenied(&MountCredentialsStruct{Method: MountAuthToken, Token: "alice"})
+	expectDenied(&MountCredentialsStruct{Method: MountAuthToken, Token: "bob-token"})
+	expectMount(&MountCredentialsStruct{Method: MountAuthToken, Token: "alice-token"}, MountIdentityStruct{Method: MountAuthToken, Principal: "alice", UserID: 2000, GroupID: 200})
+}
+
+func TestAdopt(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.adopt.Lock()
+	enabled := vS.adopt.enabled
+	vS.adopt.Unlock()
+	defer vS.configureAdopt(enabled)
+
+	vS.configureAdopt(true)
+
+	err := mS.MiddlewarePutContainer("TestAdoptContainer", []byte(""), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() returned error: %v", err)
+	}
+
+	// PUT the object's contents as the middleware would...
+
+	objectPath, err := vS.VolumeHandle.ProvisionObject()
+	if nil != err {
+		t.Fatalf("ProvisionObject() returned error: %v", err)
+	}
+	accountName, containerName, objectName, err := utils.PathToAcctContObj(objectPath)
+	if nil != err {
+		t.Fatalf("PathToAcctContObj() returned error: %v", err)
+	}
+
+	objectBuf := make([]byte, 3*FsBlockSize)
+	for i := range objectBuf {
+		objectBuf[i] = byte(i)
+	}
+
+	chunkedPutContext, err := swiftclient.ObjectFetchChunkedPutContext(accountName, containerName, objectName)
+	if nil != err {
+		t.Fatalf("ObjectFetchChunkedPutContext() returned error: %v", err)
+	}
+	err = chunkedPutContext.SendChunk(objectBuf)
+	if nil != err {
+		t.Fatalf("SendChunk() returned error: %v", err)
+	}
+	err = chunkedPutContext.Close()
+	if nil != err {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	_, fileInodeNumber, _, err := mS.MiddlewarePutComplete("TestAdoptContainer", "adoptee", []string{objectPath}, []uint64{uint64(len(objectBuf))}, []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() returned error: %v", err)
+	}
+
+	// ...which marks the file for adoption (though not visibly via the XAttr APIs)
+
+	_, err = vS.VolumeHandle.GetStream(fileInodeNumber, AdoptStream)
+	if nil != err {
+		t.Fatalf("GetStream(AdoptStream) of middleware-written file returned error: %v", err)
+	}
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, AdoptStream)
+	if nil == err {
+		t.Fatalf("GetXAttr(AdoptStream) should have failed")
+	}
+
+	// Reading the file leaves it alone...
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, uint64(len(objectBuf)), nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	vS.adopt.Lock()
+	_, pending := vS.adopt.pending[fileInodeNumber]
+	vS.adopt.Unlock()
+	if pending {
+		t.Fatalf("Read() should not have scheduled an adopt job")
+	}
+
+	// ...while the first Write() adopts it
+
+	writeBuf := []byte("adopted")
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, FsBlockSize, writeBuf, nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	copy(objectBuf[FsBlockSize:], writeBuf)
+
+	if !waitUntil(&vS.adopt.jobs, time.Now().Add(10*time.Second)) {
+		t.Fatalf("adopt job did not complete")
+	}
+	_, err = vS.VolumeHandle.GetStream(fileInodeNumber, AdoptStream)
+	if nil == err {
+		t.Fatalf("GetStream(AdoptStream) of adopted file should have failed")
+	}
+
+	readBuf, err := mS.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, uint64(len(objectBuf)), nil)
+	if nil != err {
+		t.Fatalf("Read() of adopted file returned error: %v", err)
+	}
+	if !bytes.Equal(objectBuf, readBuf) {
+		t.Fatalf("Read() of adopted file returned unexpected contents")
+	}
+}
+
+func TestDurableHandles(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestDurableHandlesDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "file", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.durable", []byte("value"), 0)
+	if err != nil {
+		t.Fatalf("SetXAttr() returned error: %v", err)
+	}
+
+	durableHandle, err := mS.OpenDurable(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestDurableHandlesDir/file")
+	if err != nil {
+		t.Fatalf("OpenDurable() returned error: %v", err)
+	}
+	if fileInodeNumber != durableHandle.InodeNumber {
+		t.Fatalf("OpenDurable() returned InodeNumber %v (expected %v)", durableHandle.InodeNumber, fileInodeNumber)
+	}
+	_, err = mS.OpenDurable(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestDurableHandlesDir/missing")
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("OpenDurable() of missing path should have failed with NotFoundError, got: %v", err)
+	}
+
+	// Handles continue to name the inode across a rename...
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "file", dirInodeNumber, "renamed", 0)
+	if err != nil {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+
+	stat, err := mS.GetstatByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle)
+	if err != nil {
+		t.Fatalf("GetstatByDurableHandle() returned error: %v", err)
+	}
+	if uint64(fileInodeNumber) != stat[StatINum] {
+		t.Fatalf("GetstatByDurableHandle() returned StatINum %v (expected %v)", stat[StatINum], fileInodeNumber)
+	}
+	value, err := mS.GetXAttrByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle, "user.durable")
+	if err != nil {
+		t.Fatalf("GetXAttrByDurableHandle() returned error: %v", err)
+	}
+	if "value" != string(value) {
+		t.Fatalf("GetXAttrByDurableHandle() returned \"%s\" (expected \"value\")", string(value))
+	}
+	streamNames, err := mS.ListXAttrByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle)
+	if err != nil {
+		t.Fatalf("ListXAttrByDurableHandle() returned error: %v", err)
+	}
+	if !reflect.DeepEqual([]string{"user.durable"}, streamNames) {
+		t.Fatalf("ListXAttrByDurableHandle() returned %v", streamNames)
+	}
+	fileHandle, err := mS.OpenByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle, OpenRead, ShareRead|ShareWrite|ShareDelete)
+	if err != nil {
+		t.Fatalf("OpenByDurableHandle() returned error: %v", err)
+	}
+	err = mS.Close(fileHandle)
+	if err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	// ...and via another mount, but not once of a different generation...
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+	_, err = otherMountHandle.GetstatByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle)
+	if err != nil {
+		t.Fatalf("GetstatByDurableHandle() via another mount returned error: %v", err)
+	}
+	staleHandle := durableHandle
+	staleHandle.Generation++
+	_, err = otherMountHandle.GetstatByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, staleHandle)
+	if blunder.IsNot(err, blunder.StaleHandleError) {
+		t.Fatalf("GetstatByDurableHandle() of different generation should have failed with StaleHandleError, got: %v", err)
+	}
+	_, err = otherMountHandle.GetXAttrByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, staleHandle, "user.durable")
+	if blunder.IsNot(err, blunder.StaleHandleError) {
+		t.Fatalf("GetXAttrByDurableHandle() of different generation should have failed with StaleHandleError, got: %v", err)
+	}
+	err = Unmount(otherMountHandle)
+	if err != nil {
+		t.Fatalf("Unmount() returned error: %v", err)
+	}
+
+	// ...nor once the inode is gone
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "renamed")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	_, err = mS.GetstatByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle)
+	if blunder.IsNot(err, blunder.StaleHandleError) {
+		t.Fatalf("GetstatByDurableHandle() of removed inode should have failed with StaleHandleError, got: %v", err)
+	}
+	_, err = mS.ListXAttrByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle)
+	if blunder.IsNot(err, blunder.StaleHandleError) {
+		t.Fatalf("ListXAttrByDurableHandle() of removed inode should have failed with StaleHandleError, got: %v", err)
+	}
+	_, err = mS.OpenByDurableHandle(inode.InodeRootUserID, inode.InodeRootGroupID, nil, durableHandle, OpenRead, ShareRead)
+	if blunder.IsNot(err, blunder.StaleHandleError) {
+		t.Fatalf("OpenByDurableHandle() of removed inode should have failed with StaleHandleError, got: %v", err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestDurableHandlesDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestStickyBit(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestStickyBitDir", inode.PosixModeSticky|inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if 0 == inode.InodeMode(stat[StatMode])&inode.PosixModeSticky {
+		t.Fatalf("Mkdir() dropped the sticky bit (mode 0%o)", stat[StatMode])
+	}
+
+	owner := inode.InodeUserID(1000)
+	other := inode.InodeUserID(2000)
+	group := inode.InodeGroupID(1000)
+
+	for _, basename := range []string{"owned", "replaced"} {
+		_, err = mS.Create(owner, group, nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if err != nil {
+			t.Fatalf("Create() of %s returned error: %v", basename, err)
+		}
+	}
+	_, err = mS.Mkdir(owner, group, nil, dirInodeNumber, "ownedDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(other, group, nil, dirInodeNumber, "others", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// Another user may neither remove nor rename the owner's entries...
+
+	err = mS.Unlink(other, group, nil, dirInodeNumber, "owned")
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("Unlink() of another user's file in sticky directory should have failed with NotPermError, got: %v", err)
+	}
+	err = mS.Rmdir(other, group, nil, dirInodeNumber, "ownedDir")
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("Rmdir() of another user's directory in sticky directory should have failed with NotPermError, got: %v", err)
+	}
+	err = mS.Rename(other, group, nil, dirInodeNumber, "owned", dirInodeNumber, "stolen", 0)
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("Rename() of another user's file in sticky directory should have failed with NotPermError, got: %v", err)
+	}
+	err = mS.Rename(other, group, nil, dirInodeNumber, "others", dirInodeNumber, "replaced", 0)
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("Rename() replacing another user's file in sticky directory should have failed with NotPermError, got: %v", err)
+	}
+
+	// ...but may its own...
+
+	err = mS.Rename(other, group, nil, dirInodeNumber, "others", dirInodeNumber, "renamed", 0)
+	if err != nil {
+		t.Fatalf("Rename() of own file in sticky directory returned error: %v", err)
+	}
+	err = mS.Unlink(other, group, nil, dirInodeNumber, "renamed")
+	if err != nil {
+		t.Fatalf("Unlink() of own file in sticky directory returned error: %v", err)
+	}
+
+	// ...as may the owner and root
+
+	err = mS.Unlink(owner, group, nil, dirInodeNumber, "owned")
+	if err != nil {
+		t.Fatalf("Unlink() by owner returned error: %v", err)
+	}
+	err = mS.Rmdir(owner, group, nil, dirInodeNumber, "ownedDir")
+	if err != nil {
+		t.Fatalf("Rmdir() by owner returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "replaced")
+	if err != nil {
+		t.Fatalf("Unlink() by root returned error: %v", err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestStickyBitDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestNameValidation(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+	vS := mS.volStruct
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestNameValidationDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	// POSIX rules
+
+	invalidNames := []struct {
+		basename string
+		errno    blunder.FsError
+	}{
+		{"", blunder.NotFoundError},
+		{".", blunder.InvalidArgError},
+		{"..", blunder.InvalidArgError},
+		{"a/b", blunder.InvalidArgError},
+		{"a\x00b", blunder.InvalidArgError},
+		{strings.Repeat("n", FileNameMax+1), blunder.NameTooLongError},
+	}
+	for _, invalidName := range invalidNames {
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, invalidName.basename, inode.PosixModePerm)
+		if blunder.IsNot(err, invalidName.errno) {
+			t.Fatalf("Create() of %q should have failed with %v, got: %v", invalidName.basename, invalidName.errno, err)
+		}
+		_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, invalidName.basename, inode.PosixModePerm)
+		if blunder.IsNot(err, invalidName.errno) {
+			t.Fatalf("Mkdir() of %q should have failed with %v, got: %v", invalidName.basename, invalidName.errno, err)
+		}
+		_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, invalidName.basename, "target")
+		if blunder.IsNot(err, invalidName.errno) {
+			t.Fatalf("Symlink() of %q should have failed with %v, got: %v", invalidName.basename, invalidName.errno, err)
+		}
+	}
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "legacy:name", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// ForbiddenNameCharacters
+
+	vS.Lock()
+	nameRules := vS.nameRules
+	vS.nameRules = newNameRules(":*é")
+	vS.Unlock()
+	defer func() {
+		vS.Lock()
+		vS.nameRules = nameRules
+		vS.Unlock()
+	}()
+
+	for _, forbiddenName := range []string{"a:b", "a*b", "café"} {
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, forbiddenName, inode.PosixModePerm)
+		if blunder.IsNot(err, blunder.InvalidArgError) {
+			t.Fatalf("Create() of %q should have failed with InvalidArgError, got: %v", forbiddenName, err)
+		}
+	}
+	err = mS.Link(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "link:name", fileInodeNumber)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("Link() of forbidden name should have failed with InvalidArgError, got: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "cafè", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() of permitted non-ASCII name returned error: %v", err)
+	}
+
+	// An existing name may be renamed away from, but not to, a forbidden name
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "cafè", dirInodeNumber, "x*y", 0)
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("Rename() to forbidden name should have failed with InvalidArgError, got: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "legacy:name", dirInodeNumber, "legacy-name", 0)
+	if err != nil {
+		t.Fatalf("Rename() from forbidden name returned error: %v", err)
+	}
+
+	// Paths created via the middleware are checked too
+
+	_, _, _, err = mS.MiddlewarePutComplete("TestNameValidationDir", "sub:dir/object", nil, nil, []byte(""))
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewarePutComplete() of path with forbidden name should have failed with InvalidArgError, got: %v", err)
+	}
+	_, _, _, err = mS.MiddlewarePutComplete("TestNameValidationDir", "sub/../object", nil, nil, []byte(""))
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewarePutComplete() of path with \"..\" should have failed with InvalidArgError, got: %v", err)
+	}
+	_, _, _, err = mS.MiddlewarePutComplete("TestNameValidationDir", "./sub/object", nil, nil, []byte(""))
+	if err != nil {
+		t.Fatalf("MiddlewarePutComplete() returned error: %v", err)
+	}
+
+	for _, basename := range []string{"cafè", "legacy-name"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() of %s returned error: %v", basename, err)
+		}
+	}
+}
+
+func TestSetGIDInheritance(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetGIDInheritanceDir", inode.PosixModeSetGID|inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	team := inode.InodeGroupID(500)
+	user := inode.InodeUserID(1000)
+	group := inode.InodeGroupID(1000)
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, Stat{StatGroupID: uint64(team)})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+
+	fileInodeNumber, err := mS.Create(user, group, nil, dirInodeNumber, "file", inode.PosixModeSetGID|inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	subdirInodeNumber, err := mS.Mkdir(user, group, nil, dirInodeNumber, "subdir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	symlinkInodeNumber, err := mS.Symlink(user, group, nil, dirInodeNumber, "symlink", "file")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+	memberFileInodeNumber, err := mS.Create(user, group, []inode.InodeGroupID{team}, dirInodeNumber, "memberFile", inode.PosixModeSetGID|inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	for _, inodeNumber := range []inode.InodeNumber{fileInodeNumber, subdirInodeNumber, symlinkInodeNumber, memberFileInodeNumber} {
+		stat, err := mS.Getstat(user, group, nil, inodeNumber)
+		if err != nil {
+			t.Fatalf("Getstat() returned error: %v", err)
+		}
+		if (uint64(user) != stat[StatUserID]) || (uint64(team) != stat[StatGroupID]) {
+			t.Fatalf("inode %v created in setgid directory has owner %v:%v (expected %v:%v)", inodeNumber, stat[StatUserID], stat[StatGroupID], user, team)
+		}
+	}
+
+	// Subdirectories inherit the setgid bit; files of a group the creator is not a member of lose it
+
+	expectedSetGID := map[inode.InodeNumber]bool{
+		subdirInodeNumber:     true,
+		fileInodeNumber:       false,
+		memberFileInodeNumber: true,
+	}
+	for inodeNumber, expected := range expectedSetGID {
+		stat, err := mS.Getstat(user, group, nil, inodeNumber)
+		if err != nil {
+			t.Fatalf("Getstat() returned error: %v", err)
+		}
+		if expected != (0 != inode.InodeMode(stat[StatMode])&inode.PosixModeSetGID) {
+			t.Fatalf("inode %v created in setgid directory has mode 0%o (expected setgid == %v)", inodeNumber, stat[StatMode], expected)
+		}
+	}
+
+	// Outside a setgid directory, the creator's group applies
+
+	plainFileInodeNumber, err := mS.Create(user, group, nil, subdirInodeNumber, "plain", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	stat, err := mS.Getstat(user, group, nil, plainFileInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if uint64(team) != stat[StatGroupID] {
+		t.Fatalf("Create() in inherited setgid subdirectory resulted in group %v (expected %v)", stat[StatGroupID], team)
+	}
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subdirInodeNumber, Stat{StatMode: uint64(inode.PosixModePerm)})
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	err = mS.Unlink(user, group, nil, subdirInodeNumber, "plain")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	plainFileInodeNumber, err = mS.Create(user, group, nil, subdirInodeNumber, "plain", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	stat, err = mS.Getstat(user, group, nil, plainFileInodeNumber)
+	if err != nil {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if uint64(group) != stat[StatGroupID] {
+		t.Fatalf("Create() in non-setgid directory resulted in group %v (expected %v)", stat[StatGroupID], group)
+	}
+
+	err = mS.Unlink(user, group, nil, subdirInodeNumber, "plain")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(user, group, nil, dirInodeNumber, "subdir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	for _, basename := range []string{"file", "symlink", "memberFile"} {
+		err = mS.Unlink(user, group, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() of %s returned error: %v", basename, err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetGIDInheritanceDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestUmask(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+	vS := mS.volStruct
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestUmaskDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	expectMode := func(inodeNumber inode.InodeNumber, expectedMode inode.InodeMode) {
+		stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inodeNumber)
+		if err != nil {
+			t.Fatalf("Getstat() returned error: %v", err)
+		}
+		if expectedMode != inode.InodeMode(stat[StatMode])&inode.PosixModeBits {
+			t.Fatalf("inode %v has mode 0%o (expected 0%o)", inodeNumber, inode.InodeMode(stat[StatMode])&inode.PosixModeBits, expectedMode)
+		}
+	}
+
+	// The mount's umask applies to Create() & Mkdir() but not Symlink()
+
+	previousUmask := mS.SetUmask(0022)
+	if 0 != previousUmask {
+		t.Fatalf("SetUmask() returned previous umask 0%o (expected 0)", previousUmask)
+	}
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "file", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	subdirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "subdir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	symlinkInodeNumber, err := mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "symlink", "file")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+
+	previousUmask = mS.SetUmask(0)
+	if 0022 != previousUmask {
+		t.Fatalf("SetUmask() returned previous umask 0%o (expected 0022)", previousUmask)
+	}
+
+	expectMode(fileInodeNumber, 0755)
+	expectMode(subdirInodeNumber, 0755)
+	expectMode(symlinkInodeNumber, inode.PosixModePerm)
+
+	// Middleware-created directories are rwxrwxrwx less MiddlewareUmask
+
+	confMap, err := conf.MakeConfMapFromStrings([]string{"Volume:TestVolume.MiddlewareUmask=027"})
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings() failed: %v", err)
+	}
+	middlewareUmask, err := fetchMiddlewareUmask(confMap, "Volume:TestVolume")
+	if nil != err {
+		t.Fatalf("fetchMiddlewareUmask() failed: %v", err)
+	}
+	for _, invalidMiddlewareUmask := range []string{"0999", "01000", "022x"} {
+		confMap, err = conf.MakeConfMapFromStrings([]string{"Volume:TestVolume.MiddlewareUmask=" + invalidMiddlewareUmask})
+		if nil != err {
+			t.Fatalf("conf.MakeConfMapFromStrings() failed: %v", err)
+		}
+		_, err = fetchMiddlewareUmask(confMap, "Volume:TestVolume")
+		if nil == err {
+			t.Fatalf("fetchMiddlewareUmask() of %s should have failed", invalidMiddlewareUmask)
+		}
+	}
+
+	vS.Lock()
+	savedMiddlewareUmask := vS.middlewareUmask
+	vS.middlewareUmask = middlewareUmask
+	vS.Unlock()
+	defer func() {
+		vS.Lock()
+		vS.middlewareUmask = savedMiddlewareUmask
+		vS.Unlock()
+	}()
+
+	_, middlewareDirInodeNumber, _, err := mS.MiddlewareMkdir("TestUmaskDir", "middleware", nil)
+	if err != nil {
+		t.Fatalf("MiddlewareMkdir() returned error: %v", err)
+	}
+	expectMode(middlewareDirInodeNumber, 0750)
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "middleware")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "subdir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	for _, basename := range []string{"file", "symlink"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() of %s returned error: %v", basename, err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestUmaskDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestPathFailure(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPathFailureDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "sub", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "file", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "loop", "loop")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "abs", "/TestPathFailureDir/sub")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+
+	pathFailures := []struct {
+		path       string
+		expected   PathFailureStruct
+		httpStatus int
+	}{
+		{"TestPathFailureDir/missing", PathFailureStruct{PathFailureNotFound, "TestPathFailureDir", "missing", 0}, http.StatusNotFound},
+		{"TestPathFailureDir/missing/object", PathFailureStruct{PathFailureNotFound, "TestPathFailureDir", "missing", 1}, http.StatusNotFound},
+		{"TestPathFailureDir/file/object", PathFailureStruct{PathFailureNotDir, "TestPathFailureDir", "file", 1}, http.StatusConflict},
+		{"TestPathFailureDir/loop", PathFailureStruct{PathFailureSymlinkLoop, "TestPathFailureDir", "loop", 0}, http.StatusConflict},
+		{"TestPathFailureDir/abs/missing", PathFailureStruct{PathFailureNotFound, "TestPathFailureDir/sub", "missing", 0}, http.StatusNotFound},
+		{"TestPathFailureDir/sub/../file/object", PathFailureStruct{PathFailureNotDir, "TestPathFailureDir", "file", 1}, http.StatusConflict},
+	}
+	for _, pathFailure := range pathFailures {
+		_, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, pathFailure.path)
+		if nil == err {
+			t.Fatalf("LookupPath(%s) should have failed", pathFailure.path)
+		}
+		resolutionFailure := PathFailure(err)
+		if nil == resolutionFailure {
+			t.Fatalf("LookupPath(%s) returned error without PathFailure: %v", pathFailure.path, err)
+		}
+		if pathFailure.expected != *resolutionFailure {
+			t.Fatalf("LookupPath(%s) returned PathFailure %+v (expected %+v)", pathFailure.path, *resolutionFailure, pathFailure.expected)
+		}
+		if pathFailure.httpStatus != blunder.HTTPCode(err) {
+			t.Fatalf("LookupPath(%s) returned HTTP status %v (expected %v)", pathFailure.path, blunder.HTTPCode(err), pathFailure.httpStatus)
+		}
+	}
+
+	// The usual errno is preserved, including via the middleware
+
+	var readPlan []inode.ReadPlanStep
+	_, _, _, _, _, _, _, err = mS.MiddlewareGetObject("TestVolume", "TestPathFailureDir/file/object", []ReadRangeIn{}, &readPlan)
+	if blunder.IsNot(err, blunder.NotDirError) {
+		t.Fatalf("MiddlewareGetObject() of file-in-the-middle should have failed with NotDirError, got: %v", err)
+	}
+	resolutionFailure := PathFailure(err)
+	if (nil == resolutionFailure) || (PathFailureNotDir != resolutionFailure.Reason) || ("file" != resolutionFailure.Segment) {
+		t.Fatalf("MiddlewareGetObject() of file-in-the-middle returned PathFailure %+v", resolutionFailure)
+	}
+	if nil != PathFailure(blunder.NewError(blunder.NotFoundError, "not a path")) {
+		t.Fatalf("PathFailure() of an error not from path resolution should be nil")
+	}
+
+	for _, basename := range []string{"abs", "loop", "file"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() of %s returned error: %v", basename, err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "sub")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestPathFailureDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestCoalesceConcurrency(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+	vS := mS.volStruct
+
+	containerNames := []string{"TestCoalesceConcurrencyA", "TestCoalesceConcurrencyB", "TestCoalesceConcurrencyC"}
+
+	// Each container holds an element for each container's coalesce (plus two for the first coalesce below)
+
+	for _, containerName := range containerNames {
+		containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, containerName, inode.PosixModePerm)
+		if err != nil {
+			t.Fatalf("Mkdir() returned error: %v", err)
+		}
+		for _, elementName := range append([]string{"e1", "e2"}, containerNames...) {
+			fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, elementName, inode.PosixModePerm)
+			if err != nil {
+				t.Fatalf("Create() returned error: %v", err)
+			}
+			_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("element"), nil)
+			if err != nil {
+				t.Fatalf("Write() returned error: %v", err)
+			}
+		}
+	}
+
+	// Coalesces no longer write lock the root directory, so one proceeds while it is read locked (e.g. by a
+	// container listing)...
+
+	rootDirInodeLock, err := vS.getReadLock(rootDirInodeNumber, nil)
+	if err != nil {
+		t.Fatalf("getReadLock() returned error: %v", err)
+	}
+
+	coalesceDone := make(chan error, 1)
+	go func() {
+		_, _, _, coalesceErr := mS.MiddlewareCoalesce(containerNames[0]+"/new/dir/dest", []string{containerNames[0] + "/e1", containerNames[0] + "/e2"})
+		coalesceDone <- coalesceErr
+	}()
+	select {
+	case err = <-coalesceDone:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("MiddlewareCoalesce() blocked on the root directory's read lock")
+	}
+
+	rootDirInodeLock.Unlock()
+
+	if err != nil {
+		t.Fatalf("MiddlewareCoalesce() returned error: %v", err)
+	}
+
+	// ...and concurrent coalesces, each into its own container from the others' (so locking their directories
+	// in differing path orders), all succeed
+
+	var wg sync.WaitGroup
+	coalesceErrs := make([]error, len(containerNames))
+	for i, containerName := range containerNames {
+		elementPaths := make([]string, 0, len(containerNames)-1)
+		for _, otherContainerName := range containerNames {
+			if otherContainerName != containerName {
+				elementPaths = append(elementPaths, otherContainerName+"/"+containerName)
+			}
+		}
+		wg.Add(1)
+		go func(i int, destPath string, elementPaths []string) {
+			defer wg.Done()
+			_, _, _, coalesceErrs[i] = mS.MiddlewareCoalesce(destPath, elementPaths)
+		}(i, containerName+"/combined", elementPaths)
+	}
+	wg.Wait()
+
+	for i, containerName := range containerNames {
+		if coalesceErrs[i] != nil {
+			t.Fatalf("MiddlewareCoalesce() into %s returned error: %v", containerName, coalesceErrs[i])
+		}
+		combinedInodeNumber, err := mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerName+"/combined")
+		if err != nil {
+			t.Fatalf("LookupPath() returned error: %v", err)
+		}
+		stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, combinedInodeNumber)
+		if err != nil {
+			t.Fatalf("Getstat() returned error: %v", err)
+		}
+		if uint64(2*len("element")) != stat[StatSize] {
+			t.Fatalf("MiddlewareCoalesce() into %s resulted in size %v (expected %v)", containerName, stat[StatSize], 2*len("element"))
+		}
+	}
+
+	for i, containerName := range containerNames {
+		remainingPaths := []string{containerName + "/combined", containerName + "/" + containerName}
+		if 0 == i {
+			remainingPaths = append(remainingPaths, containerName+"/new/dir/dest", containerName+"/new/dir", containerName+"/new")
+		} else {
+			remainingPaths = append(remainingPaths, containerName+"/e1", containerName+"/e2")
+		}
+		for _, remainingPath := range append(remainingPaths, containerName) {
+			err = mS.MiddlewareDelete(path.Dir(remainingPath), path.Base(remainingPath))
+			if err != nil {
+				t.Fatalf("MiddlewareDelete() of %s returned error: %v", remainingPath, err)
+			}
+		}
+	}
+}
+
+func TestMiddlewareGetContainerDelimited(t *testing.T) {
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestDelimitedContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fullDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "full", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fullDirInodeNumber, "file", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "empty", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "full-file", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// An empty directory has no subdir entry, and "full/" follows "full-file" as "full/file" would have
+
+	containerEnts, err := mS.MiddlewareGetContainerDelimited("TestDelimitedContainer", 10, "", "", "/")
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainerDelimited() returned error: %v", err)
+	}
+	expected := []string{"empty", "full", "full-file", "full/"}
+	if len(expected) != len(containerEnts) {
+		t.Fatalf("MiddlewareGetContainerDelimited() returned %+v (expected %v)", containerEnts, expected)
+	}
+	for i, containerEnt := range containerEnts {
+		if (expected[i] != containerEnt.Basename) || (strings.HasSuffix(expected[i], "/") != containerEnt.IsSubdir) {
+			t.Fatalf("MiddlewareGetContainerDelimited() returned %+v (expected %v)", containerEnts, expected)
+		}
+	}
+
+	// A prefix reaching into a directory descends it
+
+	containerEnts, err = mS.MiddlewareGetContainerDelimited("TestDelimitedContainer", 10, "", "full/", "/")
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainerDelimited() returned error: %v", err)
+	}
+	if (1 != len(containerEnts)) || ("full/file" != containerEnts[0].Basename) || containerEnts[0].IsSubdir {
+		t.Fatalf("MiddlewareGetContainerDelimited() with prefix returned %+v", containerEnts)
+	}
+
+	_, err = mS.MiddlewareGetContainerDelimited("TestDelimitedContainer", 10, "", "", "-")
+	if blunder.IsNot(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareGetContainerDelimited() with unsupported delimiter should have failed with InvalidArgError (got %v)", err)
+	}
+}
+
+func TestMiddlewareGetContainerRangeAndShards(t *testing.T) {
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestRangeContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "a", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "b", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	for _, basename := range []string{"a-z", "a.txt", "b", "c", "d"} {
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, basename, inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	listRange := func(marker string, endMarker string) (basenames []string) {
+		containerEnts, listErr := mS.MiddlewareGetContainerRange("TestRangeContainer", 100, marker, endMarker, "", "")
+		if nil != listErr {
+			t.Fatalf("MiddlewareGetContainerRange(%q, %q) returned error: %v", marker, endMarker, listErr)
+		}
+		for _, containerEnt := range containerEnts {
+			basenames = append(basenames, containerEnt.Basename)
+		}
+		return
+	}
+
+	expected := []string{"a", "a-z", "a.txt", "a/b", "b", "c", "d"}
+
+	// "a/b" follows a marker of "a.txt" though "a" precedes it
+
+	basenames := listRange("a.txt", "")
+	if strings.Join(basenames, ",") != strings.Join(expected[3:], ",") {
+		t.Fatalf("MiddlewareGetContainerRange() after \"a.txt\" returned %v (expected %v)", basenames, expected[3:])
+	}
+
+	basenames = listRange("", "a.txt")
+	if strings.Join(basenames, ",") != strings.Join(expected[:3], ",") {
+		t.Fatalf("MiddlewareGetContainerRange() through \"a.txt\" returned %v (expected %v)", basenames, expected[:3])
+	}
+
+	basenames = listRange("zzz", "")
+	if 0 != len(basenames) {
+		t.Fatalf("MiddlewareGetContainerRange() after \"zzz\" returned %v", basenames)
+	}
+
+	// The listings of the shards, in order, are the listing of the container
+
+	for _, maxShards := range []uint64{1, 3, 100} {
+		shards, shardsErr := mS.MiddlewareGetContainerShards("TestRangeContainer", maxShards)
+		if nil != shardsErr {
+			t.Fatalf("MiddlewareGetContainerShards() returned error: %v", shardsErr)
+		}
+		if (0 == len(shards)) || (uint64(len(shards)) > maxShards) || ((1 < maxShards) && (1 == len(shards))) {
+			t.Fatalf("MiddlewareGetContainerShards(%v) returned %+v", maxShards, shards)
+		}
+		basenames = nil
+		for _, shard := range shards {
+			basenames = append(basenames, listRange(shard.Marker, shard.EndMarker)...)
+		}
+		if strings.Join(basenames, ",") != strings.Join(expected, ",") {
+			t.Fatalf("MiddlewareGetContainerShards(%v) returned %+v listing %v (expected %v)", maxShards, shards, basenames, expected)
+		}
+	}
+}
+
+func TestMiddlewareGetContainerReverse(t *testing.T) {
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestReverseContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	aInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "a", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	cInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "c", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	ceInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, cInodeNumber, "e", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "empty", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	for dirInodeNumber, basenames := range map[inode.InodeNumber][]string{
+		containerInodeNumber: {"a-z", "a.txt", "b", "c.d.txt", "c.txt", "c0"},
+		aInodeNumber:         {"b", "b.txt"},
+		cInodeNumber:         {"d", "e.txt"},
+		ceInodeNumber:        {"f"},
+	} {
+		for _, basename := range basenames {
+			_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename, inode.PosixModePerm)
+			if nil != err {
+				t.Fatalf("Create() returned error: %v", err)
+			}
+		}
+	}
+
+	list := func(maxEntries uint64, marker string, endMarker string, prefix string, delimiter string, reverse bool) (basenames []string) {
+		containerEnts, listErr := mS.MiddlewareGetContainerListing("TestReverseContainer", maxEntries, marker, endMarker, prefix, delimiter, reverse)
+		if nil != listErr {
+			t.Fatalf("MiddlewareGetContainerListing(%q, %q, %q, %q, %v) returned error: %v", marker, endMarker, prefix, delimiter, reverse, listErr)
+		}
+		for _, containerEnt := range containerEnts {
+			basenames = append(basenames, containerEnt.Basename)
+		}
+		return
+	}
+
+	expected := []string{"a", "a-z", "a.txt", "a/b", "a/b.txt", "b", "c", "c.d.txt", "c.txt", "c/d", "c/e", "c/e.txt", "c/e/f", "c0", "empty"}
+
+	basenames := list(100, "", "", "", "", false)
+	if strings.Join(basenames, ",") != strings.Join(expected, ",") {
+		t.Fatalf("MiddlewareGetContainerListing() returned %v (expected %v)", basenames, expected)
+	}
+
+	// A reverse listing is the forward listing (with the same marker & end_marker swapped) reversed
+
+	reversed := func(basenames []string) (reversedBasenames []string) {
+		for i := len(basenames) - 1; i >= 0; i-- {
+			reversedBasenames = append(reversedBasenames, basenames[i])
+		}
+		return
+	}
+
+	markers := []string{"", "a", "a.txt", "a/", "a/b", "a/c", "b", "c", "c.", "c/e", "c/e/", "c/e/f", "c/z", "c0", "zzz"}
+	for _, prefix := range []string{"", "a", "a/", "c", "c/", "c/e", "c/e/", "z"} {
+		for _, delimiter := range []string{"", "/"} {
+			for _, marker := range markers {
+				for _, endMarker := range markers {
+					forwardBasenames := reversed(list(100, endMarker, marker, prefix, delimiter, false))
+					reverseBasenames := list(100, marker, endMarker, prefix, delimiter, true)
+					if strings.Join(reverseBasenames, ",") != strings.Join(forwardBasenames, ",") {
+						t.Fatalf("MiddlewareGetContainerListing(marker %q, end_marker %q, prefix %q, delimiter %q, reverse) returned %v (expected %v)", marker, endMarker, prefix, delimiter, reverseBasenames, forwardBasenames)
+					}
+					if 2 < len(reverseBasenames) {
+						reverseBasenames = list(2, marker, endMarker, prefix, delimiter, true)
+						if strings.Join(reverseBasenames, ",") != strings.Join(forwardBasenames[:2], ",") {
+							t.Fatalf("MiddlewareGetContainerListing(2, marker %q, end_marker %q, prefix %q, delimiter %q, reverse) returned %v (expected %v)", marker, endMarker, prefix, delimiter, reverseBasenames, forwardBasenames[:2])
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Unlike MiddlewareGetContainerRange(), the end_marker itself is not listed
+
+	basenames = list(100, "a", "c", "", "", false)
+	if strings.Join(basenames, ",") != strings.Join(expected[1:6], ",") {
+		t.Fatalf("MiddlewareGetContainerListing(marker \"a\", end_marker \"c\") returned %v (expected %v)", basenames, expected[1:6])
+	}
+
+	// Account listings honor end_marker & reverse likewise
+
+	listAccount := func(marker string, endMarker string, reverse bool) (basenames []string) {
+		accountEnts, listErr := mS.MiddlewareGetAccountListing(1000, marker, endMarker, reverse)
+		if nil != listErr {
+			t.Fatalf("MiddlewareGetAccountListing(%q, %q, %v) returned error: %v", marker, endMarker, reverse, listErr)
+		}
+		for _, accountEnt := range accountEnts {
+			basenames = append(basenames, accountEnt.Basename)
+		}
+		return
+	}
+
+	for _, marker := range []string{"", "TestReverseContainer", "TestReverseContainez", "zzz"} {
+		for _, endMarker := range []string{"", "TestRangeContainer", "TestReverseContainer"} {
+			forwardBasenames := reversed(listAccount(endMarker, marker, false))
+			reverseBasenames := listAccount(marker, endMarker, true)
+			if strings.Join(reverseBasenames, ",") != strings.Join(forwardBasenames, ",") {
+				t.Fatalf("MiddlewareGetAccountListing(marker %q, end_marker %q, reverse) returned %v (expected %v)", marker, endMarker, reverseBasenames, forwardBasenames)
+			}
+		}
+	}
+}
+
+func TestMiddlewareAccountMetadata(t *testing.T) {
+	response, err := mS.MiddlewareHeadAccount()
+	if nil != err {
+		t.Fatalf("MiddlewareHeadAccount() returned error: %v", err)
+	}
+	if (0 != len(response.Metadata)) || !response.IsDir || (inode.RootDirInodeNumber != response.InodeNumber) {
+		t.Fatalf("MiddlewareHeadAccount() of fresh account returned %+v", response)
+	}
+
+	err = mS.MiddlewarePostAccount([]byte("account metadata"), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePostAccount() returned error: %v", err)
+	}
+	response, err = mS.MiddlewareHeadAccount()
+	if nil != err {
+		t.Fatalf("MiddlewareHeadAccount() returned error: %v", err)
+	}
+	if "account metadata" != string(response.Metadata) {
+		t.Fatalf("MiddlewareHeadAccount() returned Metadata %q (expected \"account metadata\")", string(response.Metadata))
+	}
+
+	// As with MiddlewarePost(), the caller must have seen the current metadata
+
+	err = mS.MiddlewarePostAccount([]byte("other account metadata"), []byte("stale account metadata"))
+	if !blunder.Is(err, blunder.TryAgainError) {
+		t.Fatalf("MiddlewarePostAccount() with stale oldMetaData returned %v (expected TryAgainError)", err)
+	}
+
+	// The account metadata is neither a container's metadata nor visible as an XAttr of the root directory
+
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, AccountMetadataStream)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr() of AccountMetadataStream returned %v (expected StreamNotFound)", err)
+	}
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, AccountMetadataStream, []byte("hijacked"), 0)
+	if nil == err {
+		t.Fatalf("SetXAttr() of AccountMetadataStream should have failed")
+	}
+
+	err = mS.MiddlewarePostAccount([]byte(""), []byte("account metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewarePostAccount() returned error: %v", err)
+	}
+}
+
+func TestMiddlewareGetContainerCached(t *testing.T) {
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestCachedContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "a", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	listCached := func(maxStaleness time.Duration) (basenames string) {
+		containerEnts, listErr := mS.MiddlewareGetContainerCached("TestCachedContainer", 100, "", "", "", "", false, maxStaleness)
+		if nil != listErr {
+			t.Fatalf("MiddlewareGetContainerCached(%v) returned error: %v", maxStaleness, listErr)
+		}
+		for _, containerEnt := range containerEnts {
+			basenames += containerEnt.Basename + ","
+		}
+		return
+	}
+
+	basenames := listCached(time.Minute)
+	if "a," != basenames {
+		t.Fatalf("MiddlewareGetContainerCached() returned %q (expected \"a,\")", basenames)
+	}
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "b", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// A caller accepting a stale listing may get the cached one...
+
+	basenames = listCached(time.Minute)
+	if "a," != basenames {
+		t.Fatalf("MiddlewareGetContainerCached() returned %q (expected cached \"a,\")", basenames)
+	}
+
+	// ...but not if it is staler than the caller accepts (or the caller accepts none at all)
+
+	basenames = listCached(0)
+	if "a,b," != basenames {
+		t.Fatalf("MiddlewareGetContainerCached(0) returned %q (expected \"a,b,\")", basenames)
+	}
+	time.Sleep(10 * time.Millisecond)
+	basenames = listCached(time.Millisecond)
+	if "a,b," != basenames {
+		t.Fatalf("MiddlewareGetContainerCached(1ms) returned %q (expected \"a,b,\")", basenames)
+	}
+
+	// Nor is any listing cached if ListingCacheMaxStaleness is 0
+
+	mS.volStruct.Lock()
+	maxStaleness := mS.volStruct.listingCache.maxStaleness
+	mS.volStruct.listingCache.maxStaleness = 0
+	mS.volStruct.Unlock()
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "c", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	basenames = listCached(time.Minute)
+	if "a,b,c," != basenames {
+		t.Fatalf("MiddlewareGetContainerCached() with no cache returned %q (expected \"a,b,c,\")", basenames)
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.listingCache.maxStaleness = maxStaleness
+	mS.volStruct.Unlock()
+}
+
+func TestMiddlewareMkdirConflicts(t *testing.T) {
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestMkdirContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	_, dirInodeNumber, _, err := mS.MiddlewareMkdir("TestMkdirContainer", "dir", []byte("dir metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "file", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// Repeating MiddlewareMkdir() keeps the (non-empty) directory, replacing only its metadata
+
+	_, reusedInodeNumber, _, err := mS.MiddlewareMkdir("TestMkdirContainer", "dir", []byte("new dir metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMkdir() of existing directory returned error: %v", err)
+	}
+	if dirInodeNumber != reusedInodeNumber {
+		t.Fatalf("MiddlewareMkdir() of existing directory returned %v (expected %v)", reusedInodeNumber, dirInodeNumber)
+	}
+	response, err := mS.MiddlewareHeadResponse("TestMkdirContainer/dir")
+	if nil != err {
+		t.Fatalf("MiddlewareHeadResponse() returned error: %v", err)
+	}
+	if "new dir metadata" != string(response.Metadata) {
+		t.Fatalf("MiddlewareHeadResponse() returned Metadata %q (expected \"new dir metadata\")", string(response.Metadata))
+	}
+	_, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestMkdirContainer/dir/file")
+	if nil != err {
+		t.Fatalf("LookupPath() after MiddlewareMkdir() of existing directory returned error: %v", err)
+	}
+
+	_, _, _, err = mS.MiddlewareMkdir("TestMkdirContainer", "dir", nil)
+	if nil != err {
+		t.Fatalf("MiddlewareMkdir() of existing directory returned error: %v", err)
+	}
+	response, err = mS.MiddlewareHeadResponse("TestMkdirContainer/dir")
+	if nil != err {
+		t.Fatalf("MiddlewareHeadResponse() returned error: %v", err)
+	}
+	if 0 != len(response.Metadata) {
+		t.Fatalf("MiddlewareHeadResponse() returned Metadata %q (expected none)", string(response.Metadata))
+	}
+
+	// A file at (or along) the path is a conflict and is left alone
+
+	_, _, _, err = mS.MiddlewareMkdir("TestMkdirContainer", "dir/file", nil)
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("MiddlewareMkdir() over a file returned %v (expected FileExistsError)", err)
+	}
+	_, _, _, err = mS.MiddlewareMkdir("TestMkdirContainer", "dir/file/subdir", nil)
+	if !blunder.Is(err, blunder.NotDirError) {
+		t.Fatalf("MiddlewareMkdir() beneath a file returned %v (expected NotDirError)", err)
+	}
+	response, err = mS.MiddlewareHeadResponse("TestMkdirContainer/dir/file")
+	if nil != err {
+		t.Fatalf("MiddlewareHeadResponse() returned error: %v", err)
+	}
+	if response.IsDir {
+		t.Fatalf("MiddlewareMkdir() conflict replaced the file")
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "file")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "dir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestMkdirContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestWatchStats(t *testing.T) {
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestWatchStatsDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	subSubDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "SubSubDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	events := make(chan NotifyEvent, 16)
+
+	watchID, err := mS.AddWatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, true, func(watchID WatchID, event NotifyEvent) { events <- event })
+	if nil != err {
+		t.Fatalf("AddWatch() returned error: %v", err)
+	}
+
+	expectEvent := func(eventType NotifyEventType, parentInodeNumber inode.InodeNumber, basename string) {
+		select {
+		case event := <-events:
+			if (eventType != event.Type) || (parentInodeNumber != event.ParentInodeNumber) || (basename != event.Basename) {
+				t.Fatalf("expected event {%v %v %v}, got %+v", eventType, parentInodeNumber, basename, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected event {%v %v %v}, got none", eventType, parentInodeNumber, basename)
+		}
+	}
+
+	// Changes deep in the subtree advance the watch's version, remembering the ancestry walked
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subSubDirInodeNumber, "File1", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	expectEvent(NotifyCreate, subSubDirInodeNumber, "File1")
+
+	expectParent := func(dirInodeNumber inode.InodeNumber, expectedParentInodeNumber inode.InodeNumber, expectedOK bool) {
+		mS.volStruct.notify.Lock()
+		parentInodeNumber, ok := mS.volStruct.notify.parentMap[dirInodeNumber]
+		mS.volStruct.notify.Unlock()
+		if (expectedOK != ok) || (expectedParentInodeNumber != parentInodeNumber) {
+			t.Fatalf("parentMap[%v] == %v, %v (expected %v, %v)", dirInodeNumber, parentInodeNumber, ok, expectedParentInodeNumber, expectedOK)
+		}
+	}
+
+	expectParent(subSubDirInodeNumber, subDirInodeNumber, true)
+	expectParent(subDirInodeNumber, dirInodeNumber, true)
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subSubDirInodeNumber, "File2", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	expectEvent(NotifyCreate, subSubDirInodeNumber, "File2")
+
+	watchStats, err := mS.FetchWatchStats(watchID)
+	if nil != err {
+		t.Fatalf("FetchWatchStats() returned error: %v", err)
+	}
+	if (2 != watchStats.Version) || (2 != watchStats.Delivered) || (0 != watchStats.Queued) || (0 != watchStats.Overflows) {
+		t.Fatalf("FetchWatchStats() returned %+v", watchStats)
+	}
+
+	// Moving SubSubDir out of the subtree must not leave its remembered parent behind
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "SubSubDir", inode.RootDirInodeNumber, "TestWatchStatsMoved", 0)
+	if nil != err {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+	expectEvent(NotifyRenameFrom, subDirInodeNumber, "SubSubDir")
+	expectParent(subSubDirInodeNumber, 0, false)
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subSubDirInodeNumber, "File3", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("expected no event after moving out of the subtree, got %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	watchStats, err = mS.FetchWatchStats(watchID)
+	if nil != err {
+		t.Fatalf("FetchWatchStats() returned error: %v", err)
+	}
+	if 3 != watchStats.Version {
+		t.Fatalf("FetchWatchStats() returned Version %v (expected 3)", watchStats.Version)
+	}
+
+	err = mS.RemoveWatch(watchID)
+	if nil != err {
+		t.Fatalf("RemoveWatch() returned error: %v", err)
+	}
+	_, err = mS.FetchWatchStats(watchID)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("FetchWatchStats() of removed watch should have failed with NotFoundError, instead got: %v", err)
+	}
+
+	for _, basename := range []string{"File1", "File2", "File3"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subSubDirInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestWatchStatsMoved")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "SubDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestWatchStatsDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestUsageTrend(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.usageTrend.Lock()
+	interval := vS.usageTrend.interval
+	sampleCount := uint64(cap(vS.usageTrend.samples))
+	alertPercent := vS.usageTrend.alertPercent
+	alertHorizon := vS.usageTrend.alertHorizon
+	alertWebhook := vS.usageTrend.alertWebhook
+	vS.usageTrend.Unlock()
+
+	vS.Lock()
+	quotaInodes := vS.quotaInodes
+	usageCacheTTL := vS.usageCacheTTL
+	vS.usageCacheTTL = 0
+	vS.Unlock()
+
+	vS.stopUsageTrend()
+
+	defer func() {
+		vS.Lock()
+		vS.quotaInodes = quotaInodes
+		vS.usageCacheTTL = usageCacheTTL
+		vS.Unlock()
+		vS.configureUsageTrend(interval, sampleCount, alertPercent, alertHorizon, alertWebhook)
+		vS.startUsageTrend()
+	}()
+
+	webhookAlerts := make(chan UsageAlert, 16)
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		var alert UsageAlert
+		if nil == json.NewDecoder(request.Body).Decode(&alert) {
+			webhookAlerts <- alert
+		}
+	}))
+	defer webhookServer.Close()
+
+	handlerAlerts := make(chan UsageAlert, 16)
+	RegisterUsageAlertHandler(func(alert UsageAlert) {
+		select {
+		case handlerAlerts <- alert:
+		default:
+		}
+	})
+
+	// Samples are kept, oldest first, in a ring buffer of UsageSampleCount
+
+	vS.configureUsageTrend(0, 3, 90, 0, webhookServer.URL)
+
+	vS.Lock()
+	vS.quotaInodes = 0
+	vS.Unlock()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		vS.sampleUsage(start.Add(time.Duration(i) * time.Second))
+	}
+
+	samples, err := FetchUsageTrend(mS.VolumeName())
+	if nil != err {
+		t.Fatalf("FetchUsageTrend() returned error: %v", err)
+	}
+	if 3 != len(samples) {
+		t.Fatalf("FetchUsageTrend() returned %v samples (expected 3)", len(samples))
+	}
+	for i, sample := range samples {
+		if !sample.Time.Equal(start.Add(time.Duration(i+1) * time.Second)) {
+			t.Fatalf("FetchUsageTrend() returned samples[%v].Time == %v (expected %v)", i, sample.Time, start.Add(time.Duration(i+1)*time.Second))
+		}
+		if 0 == sample.InodesUsed {
+			t.Fatalf("FetchUsageTrend() returned samples[%v].InodesUsed == 0", i)
+		}
+	}
+
+	_, err = FetchUsageTrend("NoSuchVolume")
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("FetchUsageTrend() of unknown volume should have failed with NotFoundError, instead got: %v", err)
+	}
+
+	expectAlert := func(alerts chan UsageAlert, resource string) (alert UsageAlert) {
+		select {
+		case alert = <-alerts:
+			if (mS.VolumeName() != alert.VolumeName) || (resource != alert.Resource) {
+				t.Fatalf("expected %s UsageAlert of volume %s, got %+v", resource, mS.VolumeName(), alert)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s UsageAlert, got none", resource)
+		}
+		return
+	}
+	expectNoAlert := func(alerts chan UsageAlert) {
+		select {
+		case alert := <-alerts:
+			t.Fatalf("expected no UsageAlert, got %+v", alert)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Reaching UsageAlertPercent raises a single alert until re-armed
+
+	inodesUsed := samples[2].InodesUsed
+
+	vS.Lock()
+	vS.quotaInodes = inodesUsed
+	vS.Unlock()
+
+	vS.sampleUsage(start.Add(4 * time.Second))
+	alert := expectAlert(handlerAlerts, "inodes")
+	if (inodesUsed != alert.Used) || (inodesUsed != alert.Total) || (100.0 != alert.Percent) {
+		t.Fatalf("expected UsageAlert of %v used of %v (100%%), got %+v", inodesUsed, inodesUsed, alert)
+	}
+	expectAlert(webhookAlerts, "inodes")
+
+	vS.sampleUsage(start.Add(5 * time.Second))
+	expectNoAlert(handlerAlerts)
+
+	vS.Lock()
+	vS.quotaInodes = 100 * inodesUsed
+	vS.Unlock()
+
+	vS.sampleUsage(start.Add(6 * time.Second))
+	expectNoAlert(handlerAlerts)
+
+	// Growth projected to exhaust capacity within UsageAlertHorizon also raises an alert
+
+	vS.configureUsageTrend(0, 3, 90, 1000*time.Hour, "")
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestUsageTrendFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	vS.sampleUsage(start.Add(7 * time.Second))
+	alert = expectAlert(handlerAlerts, "inodes")
+	if (0 == alert.TimeToFull) || (alert.Percent >= 90.0) {
+		t.Fatalf("expected projected UsageAlert below 90%%, got %+v", alert)
+	}
+	expectNoAlert(webhookAlerts)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestUsageTrendFile")
+	if nil != err {
+		t.Fatalf("Unlink() of inode %v returned error: %v", fileInodeNumber, err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	vS := mS.volStruct
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestShutdownFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if nil != err {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+	otherMS := otherMountHandle.(*mountStruct)
+
+	// Mount() is refused once shutting down
+
+	globals.Lock()
+	globals.shuttingDown = true
+	globals.Unlock()
+
+	_, err = Mount("TestVolume", MountOptions(0))
+
+	globals.Lock()
+	globals.shuttingDown = false
+	globals.Unlock()
+
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Mount() while shutting down should have failed with TryAgainError: %v", err)
+	}
+
+	// Leases are recalled... and released regardless should their holders not respond in time
+
+	releasedLeaseID, err := otherMS.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseRead,
+		func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {
+			_ = otherMS.DowngradeLease(leaseID, breakTo)
+		})
+	if nil != err {
+		t.Fatalf("AcquireLease() returned error: %v", err)
+	}
+	ignoredLeaseID, err := otherMS.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseRead,
+		func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {})
+	if nil != err {
+		t.Fatalf("AcquireLease() returned error: %v", err)
+	}
+
+	vS.recallAllLeases(time.Now().Add(time.Second))
+
+	vS.leases.Lock()
+	_, releasedLeaseFound := vS.leases.leaseMap[releasedLeaseID]
+	_, ignoredLeaseFound := vS.leases.leaseMap[ignoredLeaseID]
+	vS.leases.Unlock()
+	if releasedLeaseFound || ignoredLeaseFound {
+		t.Fatalf("recallAllLeases() left leases held (%v & %v)", releasedLeaseFound, ignoredLeaseFound)
+	}
+
+	// Operations in flight are drained (until the deadline)... new ones are refused
+
+	err = otherMS.enterOp()
+	if nil != err {
+		t.Fatalf("enterOp() returned error: %v", err)
+	}
+
+	if drainMounts([]*mountStruct{otherMS}, time.Now().Add(100*time.Millisecond)) {
+		t.Fatalf("drainMounts() should have timed out")
+	}
+
+	_, err = otherMS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		t.Fatalf("Getstat() via shut down mount should have failed with TryAgainError: %v", err)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { otherMS.exitOp(nil) })
+
+	if !drainMounts([]*mountStruct{otherMS}, time.Now().Add(10*time.Second)) {
+		t.Fatalf("drainMounts() should have drained")
+	}
+
+	// Data written is durable once the volume is shut down
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("shutdown"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	err = vS.shutdownVolume(time.Now().Add(10 * time.Second))
+	if nil != err {
+		t.Fatalf("shutdownVolume() returned error: %v", err)
+	}
+
+	vS.Lock()
+	inFlightFileInodeDataCount := len(vS.inFlightFileInodeDataMap)
+	vS.Unlock()
+	if 0 != inFlightFileInodeDataCount {
+		t.Fatalf("shutdownVolume() left %v inodes with in-flight data", inFlightFileInodeDataCount)
+	}
+
+	vS.adopt.Lock()
+	vS.adopt.stopped = false
+	vS.adopt.Unlock()
+	vS.etag.Lock()
+	vS.etag.stopped = false
+	vS.etag.Unlock()
+	vS.startUsageTrend()
+
+	otherMS.gate.Lock()
+	otherMS.gate.state = mountStateMounted
+	otherMS.gate.Unlock()
+
+	err = Unmount(otherMountHandle)
+	if nil != err {
+		t.Fatalf("Unmount() returned error: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestShutdownFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestETag(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.etag.Lock()
+	algorithm := vS.etag.algorithm
+	maxComputeSize := vS.etag.maxComputeSize
+	vS.etag.Unlock()
+
+	defer vS.configureETag(algorithm, maxComputeSize)
+
+	vS.configureETag("md5", defaultETagMaxComputeSize)
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestETagContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "object", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	expectETag := func(expectedETag string) {
+		response, headErr := mS.MiddlewareHeadResponse("TestETagContainer/object")
+		if nil != headErr {
+			t.Fatalf("MiddlewareHeadResponse() returned error: %v", headErr)
+		}
+		if expectedETag != response.ETag {
+			t.Fatalf("MiddlewareHeadResponse() returned ETag \"%s\" (expected \"%s\")", response.ETag, expectedETag)
+		}
+		var readPlan []inode.ReadPlanStep
+		_, _, _, _, _, etag, _, getErr := mS.MiddlewareGetObject("TestVolume", "TestETagContainer/object", []ReadRangeIn{}, &readPlan)
+		if nil != getErr {
+			t.Fatalf("MiddlewareGetObject() returned error: %v", getErr)
+		}
+		if expectedETag != etag {
+			t.Fatalf("MiddlewareGetObject() returned ETag \"%s\" (expected \"%s\")", etag, expectedETag)
+		}
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("hello"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	expectETag("5d41402abc4b2a76b9719d911017c592") // MD5 of "hello"
+
+	// Once persisted, the ETag is recorded against the file's NumWrites
+
+	vS.etag.jobs.Wait()
+
+	buf, err := vS.VolumeHandle.GetStream(fileInodeNumber, ETagStream)
+	if nil != err {
+		t.Fatalf("GetStream(ETagStream) returned error: %v", err)
+	}
+	var record etagRecordStruct
+	err = json.Unmarshal(buf, &record)
+	if nil != err {
+		t.Fatalf("json.Unmarshal() of ETagStream returned error: %v", err)
+	}
+	metadata, err := vS.VolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() returned error: %v", err)
+	}
+	if (metadata.NumWrites != record.NumWrites) || ("md5" != record.Algorithm) || ("5d41402abc4b2a76b9719d911017c592" != record.ETag) {
+		t.Fatalf("ETagStream holds %+v (expected NumWrites %v)", record, metadata.NumWrites)
+	}
+
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, ETagStream)
+	if nil == err {
+		t.Fatalf("GetXAttr() of ETagStream should have failed")
+	}
+
+	// Modifying the file (e.g. via SMB or FUSE) yields a fresh ETag
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 5, []byte(" world"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	expectETag("5eb63bbbe01eeed093cb22bb8f5acdc3") // MD5 of "hello world"
+
+	vS.configureETag("sha256", defaultETagMaxComputeSize)
+
+	expectETag("b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9") // SHA256 of "hello world"
+
+	// Files too large to hash, or volumes not hashing at all, leave the ETag to the middleware
+
+	vS.configureETag("md5", 16)
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 11, []byte(", goodbye"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	expectETag("")
+
+	vS.configureETag("", defaultETagMaxComputeSize)
+
+	expectETag("")
+
+	vS.etag.jobs.Wait()
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "object")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestETagContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestMiddlewarePutCompletePreconditions(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.etag.Lock()
+	algorithm := vS.etag.algorithm
+	maxComputeSize := vS.etag.maxComputeSize
+	vS.etag.Unlock()
+
+	defer vS.configureETag(algorithm, maxComputeSize)
+
+	vS.configureETag("md5", defaultETagMaxComputeSize)
+
+	err := mS.MiddlewarePutContainer("TestPreconditionsContainer", []byte(""), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() returned error: %v", err)
+	}
+
+	putConditionally := func(objectPath string, preconditions PutPreconditions) (fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+		_, fileInodeNumber, numWrites, err = mS.MiddlewarePutCompleteConditional("TestPreconditionsContainer", objectPath, nil, nil, []byte(""), preconditions)
+		return
+	}
+
+	// Nothing may be required of a path yet to be occupied... save that it is unoccupied
+
+	_, _, err = putConditionally("dir/obj", PutPreconditions{IfMatch: true})
+	if blunder.IsNot(err, blunder.PreconditionFailed) {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatch) of missing object should have failed with PreconditionFailed (got %v)", err)
+	}
+	_, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestPreconditionsContainer/dir")
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewarePutCompleteConditional() failing its preconditions should have left no intermediate directory (got %v)", err)
+	}
+
+	fileInodeNumber, numWrites, err := putConditionally("dir/obj", PutPreconditions{IfNoneMatch: true})
+	if nil != err {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfNoneMatch) of missing object returned error: %v", err)
+	}
+
+	_, _, err = putConditionally("dir/obj", PutPreconditions{IfNoneMatch: true})
+	if blunder.IsNot(err, blunder.PreconditionFailed) {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfNoneMatch) of existing object should have failed with PreconditionFailed (got %v)", err)
+	}
+
+	// An occupied path may be required to hold a particular ETag or a particular inode & NumWrites
+
+	_, _, err = putConditionally("dir/obj", PutPreconditions{IfMatchETag: "0123456789abcdef0123456789abcdef"})
+	if blunder.IsNot(err, blunder.PreconditionFailed) {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatchETag) of mismatched ETag should have failed with PreconditionFailed (got %v)", err)
+	}
+	_, _, err = putConditionally("dir/obj", PutPreconditions{IfMatchInodeNumber: fileInodeNumber, IfMatchNumWrites: numWrites + 1})
+	if blunder.IsNot(err, blunder.PreconditionFailed) {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatchNumWrites) of mismatched NumWrites should have failed with PreconditionFailed (got %v)", err)
+	}
+
+	lookedUpInodeNumber, err := mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestPreconditionsContainer/dir/obj")
+	if nil != err {
+		t.Fatalf("LookupPath() returned error: %v", err)
+	}
+	if fileInodeNumber != lookedUpInodeNumber {
+		t.Fatalf("MiddlewarePutCompleteConditional() failing its preconditions should have left the object as it was")
+	}
+
+	replacementInodeNumber, _, err := putConditionally("dir/obj", PutPreconditions{IfMatchETag: "d41d8cd98f00b204e9800998ecf8427e"}) // MD5 of ""
+	if nil != err {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatchETag) of matching ETag returned error: %v", err)
+	}
+	if fileInodeNumber == replacementInodeNumber {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatchETag) of matching ETag should have replaced the object")
+	}
+
+	_, _, err = putConditionally("dir/obj", PutPreconditions{IfMatchInodeNumber: fileInodeNumber, IfMatchNumWrites: numWrites})
+	if blunder.IsNot(err, blunder.PreconditionFailed) {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatchInodeNumber) of replaced object should have failed with PreconditionFailed (got %v)", err)
+	}
+
+	_, _, err = putConditionally("dir/obj", PutPreconditions{IfMatch: true, IfMatchInodeNumber: replacementInodeNumber})
+	if nil != err {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatchInodeNumber) of matching object returned error: %v", err)
+	}
+
+	// A directory never satisfies IfMatch
+
+	_, _, err = putConditionally("dir", PutPreconditions{IfMatch: true})
+	if blunder.IsNot(err, blunder.PreconditionFailed) {
+		t.Fatalf("MiddlewarePutCompleteConditional(IfMatch) of directory should have failed with PreconditionFailed (got %v)", err)
+	}
+
+	vS.etag.jobs.Wait()
+}
+
+func TestMiddlewareContainerACL(t *testing.T) {
+	err := mS.MiddlewarePutContainer("TestContainerACLContainer", []byte(""), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() returned error: %v", err)
+	}
+
+	_, _, _, err = mS.MiddlewarePutComplete("TestContainerACLContainer", "obj", nil, nil, []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() returned error: %v", err)
+	}
+
+	reader := &MiddlewareCallerStruct{Principal: "AUTH_test:reader"}
+	writer := &MiddlewareCallerStruct{Principal: "AUTH_test:someone", Groups: []string{"writers"}}
+	admin := &MiddlewareCallerStruct{Principal: "AUTH_test:admin", Admin: true}
+
+	getObject := func(caller *MiddlewareCallerStruct) (err error) {
+		var readPlan []inode.ReadPlanStep
+		_, _, _, _, _, _, _, err = mS.MiddlewareGetObjectAsCaller(caller, "TestVolume", "TestContainerACLContainer/obj", []ReadRangeIn{}, &readPlan)
+		return
+	}
+	putObject := func(caller *MiddlewareCallerStruct) (err error) {
+		_, _, _, err = mS.MiddlewarePutCompleteAsCaller(caller, "TestContainerACLContainer", "obj", nil, nil, []byte(""), PutPreconditions{})
+		return
+	}
+
+	// Without an ACL, any caller is admitted
+
+	acl, err := mS.MiddlewareGetContainerACL("TestContainerACLContainer")
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainerACL() returned error: %v", err)
+	}
+	if nil != acl {
+		t.Fatalf("MiddlewareGetContainerACL() of container without an ACL should have returned nil (got %+v)", acl)
+	}
+	err = getObject(reader)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObjectAsCaller() of container without an ACL returned error: %v", err)
+	}
+	err = putObject(reader)
+	if nil != err {
+		t.Fatalf("MiddlewarePutCompleteAsCaller() of container without an ACL returned error: %v", err)
+	}
+
+	err = mS.MiddlewareSetContainerACL("TestContainerACLContainer", &ContainerACL{Read: []string{"AUTH_test:reader", "writers"}, Write: []string{"writers"}})
+	if nil != err {
+		t.Fatalf("MiddlewareSetContainerACL() returned error: %v", err)
+	}
+	acl, err = mS.MiddlewareGetContainerACL("TestContainerACLContainer")
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainerACL() returned error: %v", err)
+	}
+	if (nil == acl) || (2 != len(acl.Read)) || (1 != len(acl.Write)) || ("writers" != acl.Write[0]) {
+		t.Fatalf("MiddlewareGetContainerACL() returned unexpected ACL %+v", acl)
+	}
+
+	// The ACL's stream is reserved
+
+	containerInodeNumber, err := mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestContainerACLContainer")
+	if nil != err {
+		t.Fatalf("LookupPath() returned error: %v", err)
+	}
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, ContainerACLStream)
+	if nil == err {
+		t.Fatalf("GetXAttr(ContainerACLStream) should have failed")
+	}
+
+	// Principals and groups are admitted per the ACL... admins and trusted (nil) callers regardless
+
+	err = getObject(reader)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObjectAsCaller() by listed principal returned error: %v", err)
+	}
+	err = putObject(reader)
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("MiddlewarePutCompleteAsCaller() by unlisted principal should have failed with PermDeniedError (got %v)", err)
+	}
+	err = putObject(writer)
+	if nil != err {
+		t.Fatalf("MiddlewarePutCompleteAsCaller() by listed group returned error: %v", err)
+	}
+	err = getObject(&MiddlewareCallerStruct{})
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("MiddlewareGetObjectAsCaller() by anonymous caller should have failed with PermDeniedError (got %v)", err)
+	}
+	err = putObject(admin)
+	if nil != err {
+		t.Fatalf("MiddlewarePutCompleteAsCaller() by admin returned error: %v", err)
+	}
+	err = putObject(nil)
+	if nil != err {
+		t.Fatalf("MiddlewarePutCompleteAsCaller() by trusted caller returned error: %v", err)
+	}
+
+	err = mS.MiddlewareDeleteAsCaller(reader, "TestContainerACLContainer", "obj")
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("MiddlewareDeleteAsCaller() by unlisted principal should have failed with PermDeniedError (got %v)", err)
+	}
+	err = mS.MiddlewareDeleteAsCaller(writer, "TestContainerACLContainer", "obj")
+	if nil != err {
+		t.Fatalf("MiddlewareDeleteAsCaller() by listed group returned error: %v", err)
+	}
+
+	// "*" admits anyone
+
+	err = mS.MiddlewareSetContainerACL("TestContainerACLContainer", &ContainerACL{Read: []string{"*"}})
+	if nil != err {
+		t.Fatalf("MiddlewareSetContainerACL() returned error: %v", err)
+	}
+	err = mS.MiddlewareDeleteAsCaller(&MiddlewareCallerStruct{}, "/", "TestContainerACLContainer")
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("MiddlewareDeleteAsCaller() of container by unlisted caller should have failed with PermDeniedError (got %v)", err)
+	}
+
+	// Removing the ACL admits all once more
+
+	err = mS.MiddlewareSetContainerACL("TestContainerACLContainer", nil)
+	if nil != err {
+		t.Fatalf("MiddlewareSetContainerACL(nil) returned error: %v", err)
+	}
+	err = mS.MiddlewareDeleteAsCaller(&MiddlewareCallerStruct{}, "/", "TestContainerACLContainer")
+	if nil != err {
+		t.Fatalf("MiddlewareDeleteAsCaller() of container without an ACL returned error: %v", err)
+	}
+}
+
+func TestMiddlewareDeleteMulti(t *testing.T) {
+	err := mS.MiddlewarePutContainer("TestDeleteMultiContainer", []byte(""), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() returned error: %v", err)
+	}
+
+	objectPaths := []string{"a", "dir/b", "dir/c", "dir/sub/d", "e"}
+	for i := 0; i < 2*middlewareDeleteMultiBatchSize+1; i++ {
+		objectPaths = append(objectPaths, fmt.Sprintf("many/%04d", i))
+	}
+	for _, objectPath := range objectPaths {
+		_, _, _, err = mS.MiddlewarePutComplete("TestDeleteMultiContainer", objectPath, nil, nil, []byte(""))
+		if nil != err {
+			t.Fatalf("MiddlewarePutComplete(\"%s\") returned error: %v", objectPath, err)
+		}
+	}
+
+	// The container is listed first, yet deleted last (once emptied)
+
+	entries := []DeleteMultiEntry{{Container: "TestDeleteMultiContainer"}}
+	for _, objectPath := range objectPaths {
+		entries = append(entries, DeleteMultiEntry{Container: "TestDeleteMultiContainer", ObjectPath: objectPath})
+	}
+	entries = append(entries,
+		DeleteMultiEntry{Container: "TestDeleteMultiContainer", ObjectPath: "dir/sub"},
+		DeleteMultiEntry{Container: "TestDeleteMultiContainer", ObjectPath: "dir"},
+		DeleteMultiEntry{Container: "TestDeleteMultiContainer", ObjectPath: "many"},
+		DeleteMultiEntry{Container: "TestDeleteMultiContainer", ObjectPath: "missing"},
+		DeleteMultiEntry{Container: "TestDeleteMultiContainer", ObjectPath: "../escape"},
+		DeleteMultiEntry{Container: "NoSuchContainer", ObjectPath: "x"},
+		DeleteMultiEntry{Container: "a/b", ObjectPath: "x"})
+
+	errs := mS.MiddlewareDeleteMulti(entries)
+	if len(entries) != len(errs) {
+		t.Fatalf("MiddlewareDeleteMulti() returned %d errs (expected %d)", len(errs), len(entries))
+	}
+
+	// Deleting "dir/sub", "dir", and "many" fails (not empty) as their groups precede those of their contents
+
+	for i, entry := range entries {
+		var expectedErr blunder.FsError
+		switch entry.ObjectPath {
+		case "dir/sub", "dir", "many":
+			expectedErr = blunder.NotEmptyError
+		case "missing":
+			expectedErr = blunder.NotFoundError
+		case "../escape":
+			expectedErr = blunder.InvalidArgError
+		case "":
+			expectedErr = blunder.NotEmptyError
+		default:
+			switch entry.Container {
+			case "NoSuchContainer":
+				expectedErr = blunder.NotFoundError
+			case "a/b":
+				expectedErr = blunder.InvalidArgError
+			default:
+				expectedErr = blunder.SuccessError
+			}
+		}
+		if blunder.SuccessError == expectedErr {
+			if nil != errs[i] {
+				t.Fatalf("MiddlewareDeleteMulti() of %+v returned error: %v", entry, errs[i])
+			}
+		} else if blunder.IsNot(errs[i], expectedErr) {
+			t.Fatalf("MiddlewareDeleteMulti() of %+v should have failed with %v (got %v)", entry, expectedErr, errs[i])
+		}
+	}
+
+	// The container may be deleted only once emptied
+
+	errs = mS.MiddlewareDeleteMulti([]DeleteMultiEntry{
+		{Container: "TestDeleteMultiContainer"},
+		{Container: "TestDeleteMultiContainer", ObjectPath: "dir"},
+		{Container: "TestDeleteMultiContainer", ObjectPath: "dir/sub"},
+		{Container: "TestDeleteMultiContainer", ObjectPath: "many/"},
+	})
+	for i, deleteErr := range errs {
+		if (1 == i) && blunder.IsNot(deleteErr, blunder.NotEmptyError) {
+			t.Fatalf("MiddlewareDeleteMulti() of \"dir\" before \"dir/sub\" should have failed with NotEmptyError (got %v)", deleteErr)
+		}
+		if (0 == i) && blunder.IsNot(deleteErr, blunder.NotEmptyError) {
+			t.Fatalf("MiddlewareDeleteMulti() of container still holding \"dir\" should have failed with NotEmptyError (got %v)", deleteErr)
+		}
+		if (1 < i) && (nil != deleteErr) {
+			t.Fatalf("MiddlewareDeleteMulti() of entry %d returned error: %v", i, deleteErr)
+		}
+	}
+
+	errs = mS.MiddlewareDeleteMulti([]DeleteMultiEntry{
+		{Container: "TestDeleteMultiContainer", ObjectPath: "dir"},
+		{Container: "TestDeleteMultiContainer"},
+	})
+	for i, deleteErr := range errs {
+		if nil != deleteErr {
+			t.Fatalf("MiddlewareDeleteMulti() of entry %d returned error: %v", i, deleteErr)
+		}
+	}
+
+	_, err = mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestDeleteMultiContainer")
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareDeleteMulti() should have deleted the container (got %v)", err)
+	}
+}
+
+func TestWritev(t *testing.T) {
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestWritevFile", inode.InodeMode(0600))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	size, err := mS.Writev(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, []inode.WriteSegment{
+		{Offset: 0, Buf: []byte("hello")},
+		{Offset: 5, Buf: []byte(", ")},
+		{Offset: 7, Buf: []byte("world")},
+		{Offset: 0, Buf: []byte("J")},
+	}, nil)
+	if nil != err {
+		t.Fatalf("Writev() returned error: %v", err)
+	}
+	if 13 != size {
+		t.Fatalf("Writev() returned size %v (expected 13)", size)
+	}
+
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 12, nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	if "Jello, world" != string(buf) {
+		t.Fatalf("Read() after Writev() returned \"%s\"", buf)
+	}
+
+	stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if 1 != stat[StatNumWrites] {
+		t.Fatalf("Writev() should have counted as a single write (got NumWrites %v)", stat[StatNumWrites])
+	}
+
+	// Writev() is subject to the same permission checks as Write()
+
+	_, err = mS.Writev(inode.InodeUserID(1234), inode.InodeGroupID(5678), nil, fileInodeNumber, []inode.WriteSegment{{Offset: 0, Buf: []byte("x")}}, nil)
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("Writev() by unprivileged user should have failed with PermDeniedError (got %v)", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestWritevFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestReadInto(t *testing.T) {
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestReadIntoFile", inode.InodeMode(0600))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("hello, world"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	dst := make([]byte, 5)
+	size, err := mS.ReadInto(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 7, dst, nil)
+	if nil != err {
+		t.Fatalf("ReadInto() returned error: %v", err)
+	}
+	if (5 != size) || ("world" != string(dst)) {
+		t.Fatalf("ReadInto() returned size %v & \"%s\"", size, dst)
+	}
+
+	// A ReadInto() extending beyond EOF is short
+
+	dst = make([]byte, 16)
+	size, err = mS.ReadInto(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, dst, nil)
+	if nil != err {
+		t.Fatalf("ReadInto() beyond EOF returned error: %v", err)
+	}
+	if (12 != size) || ("hello, world" != string(dst[:size])) {
+		t.Fatalf("ReadInto() beyond EOF returned size %v & \"%s\"", size, dst[:size])
+	}
+
+	// ReadInto() is subject to the same permission checks as Read()
+
+	_, err = mS.ReadInto(inode.InodeUserID(1234), inode.InodeGroupID(5678), nil, fileInodeNumber, 0, dst, nil)
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("ReadInto() by unprivileged user should have failed with PermDeniedError (got %v)", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestReadIntoFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestOpStats(t *testing.T) {
+	volumeOpStatsBefore, err := FetchVolumeOpStats(mS.VolumeName())
+	if nil != err {
+		t.Fatalf("FetchVolumeOpStats() returned error: %v", err)
+	}
+	mountOpStatsBefore, ok := volumeOpStatsBefore.Mounts[mS.id]
+	if !ok {
+		t.Fatalf("FetchVolumeOpStats() omitted MountID %v", mS.id)
+	}
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestOpStatsFile", inode.InodeMode(0600))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("12345"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 1, 3, nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestOpStatsMissing")
+	if nil == err {
+		t.Fatalf("Lookup() of missing file should have failed")
+	}
+
+	mountOpStatsAfter := mS.FetchOpStats()
+	volumeOpStatsAfter, err := FetchVolumeOpStats(mS.VolumeName())
+	if nil != err {
+		t.Fatalf("FetchVolumeOpStats() returned error: %v", err)
+	}
+
+	for _, opStatsPair := range [][2]OpStats{{mountOpStatsBefore, mountOpStatsAfter}, {volumeOpStatsBefore.Volume, volumeOpStatsAfter.Volume}} {
+		before, after := opStatsPair[0], opStatsPair[1]
+		if (after.Operations < (before.Operations + 4)) || (after.Errors != (before.Errors + 1)) ||
+			(after.ReadBytes != (before.ReadBytes + 3)) || (after.WrittenBytes != (before.WrittenBytes + 5)) {
+			t.Fatalf("OpStats went from %+v to %+v", before, after)
+		}
+	}
+
+	_, err = FetchVolumeOpStats("TestOpStatsNoSuchVolume")
+	if nil == err {
+		t.Fatalf("FetchVolumeOpStats() of unknown volume should have failed")
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestOpStatsFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestSnapshotFile", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("before"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	snapshot, err := CreateSnapshot(mS.VolumeName(), "TestSnapshot")
+	if nil != err {
+		t.Fatalf("CreateSnapshot() returned error: %v", err)
+	}
+	if "TestSnapshot" != snapshot.Name {
+		t.Fatalf("CreateSnapshot() returned %+v", snapshot)
+	}
+
+	_, err = CreateSnapshot(mS.VolumeName(), "TestSnapshot")
+	if blunder.IsNot(err, blunder.FileExistsError) {
+		t.Fatalf("CreateSnapshot() of duplicate name should have failed with FileExistsError (got %v)", err)
+	}
+
+	// Modify the file & then remove it from the live volume
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("AFTER!"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestSnapshotFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+
+	// The file remains, unmodified, beneath .snapshot
+
+	snapshotDirInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, SnapshotDirName)
+	if nil != err {
+		t.Fatalf("Lookup() of %s returned error: %v", SnapshotDirName, err)
+	}
+	if SnapshotDirInodeNumber != snapshotDirInodeNumber {
+		t.Fatalf("Lookup() of %s returned %v", SnapshotDirName, snapshotDirInodeNumber)
+	}
+
+	entries, numEntries, _, err := mS.Readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotDirInodeNumber, "", 0, 0)
+	if nil != err {
+		t.Fatalf("Readdir() of %s returned error: %v", SnapshotDirName, err)
+	}
+	if (3 != numEntries) || ("TestSnapshot" != entries[2].Basename) || (inode.DirType != entries[2].Type) {
+		t.Fatalf("Readdir() of %s returned %+v", SnapshotDirName, entries)
+	}
+
+	snapshotRootInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotDirInodeNumber, "TestSnapshot")
+	if nil != err {
+		t.Fatalf("Lookup() of snapshot returned error: %v", err)
+	}
+	parentInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotRootInodeNumber, "..")
+	if nil != err {
+		t.Fatalf("Lookup() of snapshot's .. returned error: %v", err)
+	}
+	if SnapshotDirInodeNumber != parentInodeNumber {
+		t.Fatalf("Lookup() of snapshot's .. returned %v", parentInodeNumber)
+	}
+
+	snapshotFileInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotRootInodeNumber, "TestSnapshotFile")
+	if nil != err {
+		t.Fatalf("Lookup() of file within snapshot returned error: %v", err)
+	}
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotFileInodeNumber, 0, 64, nil)
+	if nil != err {
+		t.Fatalf("Read() of file within snapshot returned error: %v", err)
+	}
+	if "before" != string(buf) {
+		t.Fatalf("Read() of file within snapshot returned \"%s\"", buf)
+	}
+	stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotFileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() of file within snapshot returned error: %v", err)
+	}
+	if (6 != stat[StatSize]) || (uint64(snapshotFileInodeNumber) != stat[StatINum]) {
+		t.Fatalf("Getstat() of file within snapshot returned %v", stat)
+	}
+
+	// Nothing beneath .snapshot may be modified
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotFileInodeNumber, 0, []byte("x"), nil)
+	if blunder.IsNot(err, blunder.ReadOnlyError) {
+		t.Fatalf("Write() within snapshot should have failed with ReadOnlyError (got %v)", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotRootInodeNumber, "TestSnapshotNewFile", inode.InodeMode(0644))
+	if blunder.IsNot(err, blunder.ReadOnlyError) {
+		t.Fatalf("Create() within snapshot should have failed with ReadOnlyError (got %v)", err)
+	}
+	if mS.Access(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotFileInodeNumber, inode.W_OK) {
+		t.Fatalf("Access(W_OK) within snapshot should have failed")
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotFileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() within snapshot returned error: %v", err)
+	}
+
+	snapshots, err := ListSnapshots(mS.VolumeName())
+	if nil != err {
+		t.Fatalf("ListSnapshots() returned error: %v", err)
+	}
+	if (1 != len(snapshots)) || (snapshot != snapshots[0]) {
+		t.Fatalf("ListSnapshots() returned %+v", snapshots)
+	}
+
+	err = DeleteSnapshot(mS.VolumeName(), snapshot.ID)
+	if nil != err {
+		t.Fatalf("DeleteSnapshot() returned error: %v", err)
+	}
+
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotDirInodeNumber, "TestSnapshot")
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of deleted snapshot should have failed with NotFoundError (got %v)", err)
+	}
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, snapshotFileInodeNumber, 0, 64, nil)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("Read() within deleted snapshot should have failed with NotFoundError (got %v)", err)
+	}
+	err = DeleteSnapshot(mS.VolumeName(), snapshot.ID)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("DeleteSnapshot() of deleted snapshot should have failed with NotFoundError (got %v)", err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.trash.Lock()
+	vS.trash.enabled = true
+	vS.trash.Unlock()
+	defer func() {
+		vS.trash.Lock()
+		vS.trash.enabled = false
+		vS.trash.Unlock()
+	}()
+
+	err := vS.loadTrash()
+	if nil != err {
+		t.Fatalf("loadTrash() returned error: %v", err)
+	}
+	trashDirInodeNumber, enabled := vS.fetchTrashDir()
+	if !enabled {
+		t.Fatalf("fetchTrashDir() after loadTrash() returned %v, %v", trashDirInodeNumber, enabled)
+	}
+
+	findTrashEntry := func(inodeNumber inode.InodeNumber) (entry TrashEntry, ok bool) {
+		entries, listErr := ListTrash(mS.VolumeName())
+		if nil != listErr {
+			t.Fatalf("ListTrash() returned error: %v", listErr)
+		}
+		for _, entry = range entries {
+			if inodeNumber == entry.InodeNumber {
+				ok = true
+				return
+			}
+		}
+		return
+	}
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestTrashDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashFile", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("precious"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	// Unlink() & Rmdir() trash rather than destroy
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashFile")
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of trashed file should have failed with NotFoundError (got %v)", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestTrashDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+
+	entry, ok := findTrashEntry(fileInodeNumber)
+	if !ok || (inode.FileType != entry.InodeType) || (dirInodeNumber != entry.DirInodeNumber) || ("/TestTrashDir/TestTrashFile" != entry.OriginalPath) {
+		t.Fatalf("ListTrash() returned %+v, %v for trashed file", entry, ok)
+	}
+	entry, ok = findTrashEntry(dirInodeNumber)
+	if !ok || (inode.DirType != entry.InodeType) || ("/TestTrashDir" != entry.OriginalPath) {
+		t.Fatalf("ListTrash() returned %+v, %v for trashed directory", entry, ok)
+	}
+
+	// The file may only be restored once its directory has been
+
+	err = RestoreTrash(mS.VolumeName(), fileInodeNumber)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("RestoreTrash() into trashed directory should have failed with NotFoundError (got %v)", err)
+	}
+	err = RestoreTrash(mS.VolumeName(), dirInodeNumber)
+	if nil != err {
+		t.Fatalf("RestoreTrash() of directory returned error: %v", err)
+	}
+	err = RestoreTrash(mS.VolumeName(), fileInodeNumber)
+	if nil != err {
+		t.Fatalf("RestoreTrash() of file returned error: %v", err)
+	}
+
+	restoredInodeNumber, err := mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "TestTrashDir/TestTrashFile")
+	if nil != err {
+		t.Fatalf("LookupPath() of restored file returned error: %v", err)
+	}
+	if fileInodeNumber != restoredInodeNumber {
+		t.Fatalf("LookupPath() of restored file returned %v", restoredInodeNumber)
+	}
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 64, nil)
+	if (nil != err) || ("precious" != string(buf)) {
+		t.Fatalf("Read() of restored file returned \"%s\", %v", buf, err)
+	}
+	_, ok = findTrashEntry(fileInodeNumber)
+	if ok {
+		t.Fatalf("ListTrash() still lists restored file")
+	}
+
+	// A name since reused is not overwritten
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	replacementInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashFile", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	err = RestoreTrash(mS.VolumeName(), fileInodeNumber)
+	if blunder.IsNot(err, blunder.FileExistsError) {
+		t.Fatalf("RestoreTrash() over existing name should have failed with FileExistsError (got %v)", err)
+	}
+
+	err = PurgeTrash(mS.VolumeName(), fileInodeNumber)
+	if nil != err {
+		t.Fatalf("PurgeTrash() returned error: %v", err)
+	}
+	_, ok = findTrashEntry(fileInodeNumber)
+	if ok {
+		t.Fatalf("ListTrash() still lists purged file")
+	}
+	err = RestoreTrash(mS.VolumeName(), fileInodeNumber)
+	if blunder.IsNot(err, blunder.NotFoundError) {
+		t.Fatalf("RestoreTrash() of purged file should have failed with NotFoundError (got %v)", err)
+	}
+
+	// Removing one of several names is not trashed
+
+	err = mS.Link(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashLink", replacementInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashLink")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	_, ok = findTrashEntry(replacementInodeNumber)
+	if ok {
+		t.Fatalf("ListTrash() lists file that still has a name")
+	}
+
+	// Expired trash is purged automatically
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "TestTrashFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestTrashDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+
+	vS.purgeExpiredTrash(time.Now(), nil)
+	_, ok = findTrashEntry(replacementInodeNumber)
+	if !ok {
+		t.Fatalf("purgeExpiredTrash() purged file trashed too recently")
+	}
+
+	vS.purgeExpiredTrash(time.Now().Add(defaultTrashRetention+time.Minute), nil)
+	for _, inodeNumber := range []inode.InodeNumber{replacementInodeNumber, dirInodeNumber} {
+		_, ok = findTrashEntry(inodeNumber)
+		if ok {
+			t.Fatalf("purgeExpiredTrash() didn't purge inode %v", inodeNumber)
+		}
+	}
+}
+
+func TestFileVersions(t *testing.T) {
+	vS := mS.volStruct
+
+	vS.configureVersions(2, 0)
+	defer vS.configureVersions(0, defaultFileVersionInterval)
+
+	err := vS.loadVersions()
+	if nil != err {
+		t.Fatalf("loadVersions() returned error: %v", err)
+	}
+	versionsDirInodeNumber := vS.fetchVersionsDir()
+	if 0 == versionsDirInodeNumber {
+		t.Fatalf("loadVersions() didn't create versions directory")
+	}
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestFileVersionsFile", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	listVersions := func(expectedLen int) (versions []FileVersion) {
+		versions, listErr := mS.ListVersions(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+		if nil != listErr {
+			t.Fatalf("ListVersions() returned error: %v", listErr)
+		}
+		if expectedLen != len(versions) {
+			t.Fatalf("ListVersions() returned %v versions, expected %v", len(versions), expectedLen)
+		}
+		return
+	}
+
+	readVersion := func(version FileVersion) (buf []byte) {
+		fileHandle, openErr := mS.OpenVersion(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, version.VersionInodeNumber)
+		if nil != openErr {
+			t.Fatalf("OpenVersion() returned error: %v", openErr)
+		}
+		buf, readErr := mS.ReadByHandle(fileHandle, 0, version.Size, nil)
+		if nil != readErr {
+			t.Fatalf("ReadByHandle() of version returned error: %v", readErr)
+		}
+		closeErr := mS.Close(fileHandle)
+		if nil != closeErr {
+			t.Fatalf("Close() of version returned error: %v", closeErr)
+		}
+		return
+	}
+
+	// Writing to an empty file (or appending) overwrites nothing so preserves no version
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("version 1"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	_ = listVersions(0)
+
+	// Overwriting & truncating each preserve a version
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("VERSION 2"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	versions := listVersions(1)
+	if "version 1" != string(readVersion(versions[0])) {
+		t.Fatalf("version preserved by overwrite has unexpected contents")
+	}
+
+	err = mS.Resize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 4)
+	if nil != err {
+		t.Fatalf("Resize() returned error: %v", err)
+	}
+	versions = listVersions(2)
+	if "VERSION 2" != string(readVersion(versions[1])) {
+		t.Fatalf("version preserved by truncation has unexpected contents")
+	}
+
+	// Beyond MaxFileVersions, the oldest version is destroyed
+
+	expiredVersionInodeNumber := versions[0].VersionInodeNumber
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("v3"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	versions = listVersions(2)
+	if ("VERSION 2" != string(readVersion(versions[0]))) || ("VERS" != string(readVersion(versions[1]))) {
+		t.Fatalf("versions after expiry have unexpected contents")
+	}
+	_, err = vS.VolumeHandle.GetType(expiredVersionInodeNumber)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("GetType() of expired version should have failed with NotFoundError, got: %v", err)
+	}
+
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	if "v3RS" != string(buf) {
+		t.Fatalf("Read() of versioned file returned \"%s\"", buf)
+	}
+
+	// Only versions of the file may be opened via OpenVersion()
+
+	_, err = mS.OpenVersion(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, fileInodeNumber)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("OpenVersion() of non-version should have failed with NotFoundError, got: %v", err)
+	}
+
+	// Versions are destroyed along with their file
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestFileVersionsFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	for _, version := range versions {
+		_, err = vS.VolumeHandle.GetType(version.VersionInodeNumber)
+		if !blunder.Is(err, blunder.NotFoundError) {
+			t.Fatalf("GetType() of version of unlinked file should have failed with NotFoundError, got: %v", err)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestRetentionContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() of container returned error: %v", err)
+	}
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "SubDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() of subdirectory returned error: %v", err)
+	}
+
+	err = mS.MiddlewareSetContainerRetention("TestRetentionContainer", &RetentionPolicy{MinimumRetention: 0})
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareSetContainerRetention() of zero MinimumRetention should have failed with InvalidArgError, got: %v", err)
+	}
+	err = mS.MiddlewareSetContainerRetention("TestRetentionContainer", &RetentionPolicy{MinimumRetention: time.Hour})
+	if nil != err {
+		t.Fatalf("MiddlewareSetContainerRetention() returned error: %v", err)
+	}
+	policy, err := mS.MiddlewareGetContainerRetention("TestRetentionContainer")
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainerRetention() returned error: %v", err)
+	}
+	if (nil == policy) || (time.Hour != policy.MinimumRetention) {
+		t.Fatalf("MiddlewareGetContainerRetention() returned %+v", policy)
+	}
+
+	expectNotPerm := func(what string, err error) {
+		if !blunder.Is(err, blunder.NotPermError) {
+			t.Fatalf("%s of protected file should have failed with NotPermError, got: %v", what, err)
+		}
+	}
+
+	// A file created beneath the container is pending until made read-only
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	status, err := mS.GetRetention(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetRetention() returned error: %v", err)
+	}
+	if (time.Hour != status.MinimumRetention) || !status.RetainUntil.IsZero() || status.Protected {
+		t.Fatalf("GetRetention() of pending file returned %+v", status)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("record"), nil)
+	if nil != err {
+		t.Fatalf("Write() of pending file returned error: %v", err)
+	}
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: 0444})
+	if nil != err {
+		t.Fatalf("Setstat() committing retention returned error: %v", err)
+	}
+	status, err = mS.GetRetention(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetRetention() returned error: %v", err)
+	}
+	if (0 != status.MinimumRetention) || !status.RetainUntil.After(time.Now().Add(time.Hour-time.Minute)) || !status.Protected {
+		t.Fatalf("GetRetention() of committed file returned %+v", status)
+	}
+
+	// Once committed, the file may be neither altered nor removed... even by root
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("RECORD"), nil)
+	expectNotPerm("Write()", err)
+	err = mS.Resize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0)
+	expectNotPerm("Resize()", err)
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: 0644})
+	expectNotPerm("Setstat()", err)
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File", subDirInodeNumber, "Renamed", 0)
+	expectNotPerm("Rename()", err)
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File")
+	expectNotPerm("Unlink()", err)
+	err = mS.MiddlewareDelete("TestRetentionContainer/SubDir", "File")
+	expectNotPerm("MiddlewareDelete()", err)
+
+	_, err = mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() of protected file returned error: %v", err)
+	}
+
+	// An object PUT into the container is committed at once
+
+	_, objectInodeNumber, _, err := mS.MiddlewarePutComplete("TestRetentionContainer", "Object", nil, nil, []byte("metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() returned error: %v", err)
+	}
+	status, err = mS.GetRetention(inode.InodeRootUserID, inode.InodeRootGroupID, nil, objectInodeNumber)
+	if nil != err {
+		t.Fatalf("GetRetention() returned error: %v", err)
+	}
+	if !status.Protected {
+		t.Fatalf("GetRetention() of PUT object returned %+v", status)
+	}
+	_, _, _, err = mS.MiddlewarePutComplete("TestRetentionContainer", "Object", nil, nil, []byte("metadata"))
+	expectNotPerm("MiddlewarePutComplete() replacing", err)
+	err = mS.MiddlewarePost("TestRetentionContainer", "Object", []byte("new metadata"), []byte("metadata"))
+	expectNotPerm("MiddlewarePost()", err)
+
+	// Legal holds may be placed only by root, and protect even files whose retention has expired
+
+	err = mS.SetLegalHold(inode.InodeUserID(1), inode.InodeGroupID(1), nil, fileInodeNumber, true)
+	expectNotPerm("SetLegalHold() by non-root", err)
+	err = mS.SetLegalHold(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, true)
+	if nil != err {
+		t.Fatalf("SetLegalHold() returned error: %v", err)
+	}
+
+	expireRetention := func(inodeNumber inode.InodeNumber) {
+		retention, expireErr := mS.volStruct.fetchRetention(inodeNumber)
+		if nil != expireErr {
+			t.Fatalf("fetchRetention() returned error: %v", expireErr)
+		}
+		retention.RetainUntil = time.Now().Add(-time.Second)
+		expireErr = mS.volStruct.putRetention(inodeNumber, retention)
+		if nil != expireErr {
+			t.Fatalf("putRetention() returned error: %v", expireErr)
+		}
+	}
+
+	expireRetention(fileInodeNumber)
+	expireRetention(objectInodeNumber)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File")
+	expectNotPerm("Unlink() under legal hold", err)
+
+	err = mS.SetLegalHold(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("SetLegalHold() lifting hold returned error: %v", err)
+	}
+
+	// With retention expired and no legal hold, everything may be cleaned up
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, subDirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() of expired file returned error: %v", err)
+	}
+	err = mS.MiddlewareDelete("TestRetentionContainer", "Object")
+	if nil != err {
+		t.Fatalf("MiddlewareDelete() of expired object returned error: %v", err)
+	}
+
+	err = mS.MiddlewareSetContainerRetention("TestRetentionContainer", nil)
+	if nil != err {
+		t.Fatalf("MiddlewareSetContainerRetention() removing policy returned error: %v", err)
+	}
+	policy, err = mS.MiddlewareGetContainerRetention("TestRetentionContainer")
+	if (nil != err) || (nil != policy) {
+		t.Fatalf("MiddlewareGetContainerRetention() after removal returned %+v, %v", policy, err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "SubDir")
+	if nil != err {
+		t.Fatalf("Rmdir() of subdirectory returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestRetentionContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() of container returned error: %v", err)
+	}
+}
+
+func TestInodeFlags(t *testing.T) {
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestInodeFlagsFile", inode.InodeMode(0666))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	// Only root may fetch or change flags
+
+	_, err = mS.GetInodeFlags(inode.InodeUserID(1), inode.InodeGroupID(1), nil, fileInodeNumber)
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("GetInodeFlags() by non-root should have failed with NotPermError, got: %v", err)
+	}
+	err = mS.SetInodeFlags(inode.InodeUserID(1), inode.InodeGroupID(1), nil, fileInodeNumber, inode.ImmutableFlag)
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("SetInodeFlags() by non-root should have failed with NotPermError, got: %v", err)
+	}
+
+	setFlags := func(flags inode.InodeFlags) {
+		setErr := mS.SetInodeFlags(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, flags)
+		if nil != setErr {
+			t.Fatalf("SetInodeFlags(0x%X) returned error: %v", flags, setErr)
+		}
+		flagsReturned, getErr := mS.GetInodeFlags(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+		if nil != getErr {
+			t.Fatalf("GetInodeFlags() returned error: %v", getErr)
+		}
+		if flags != flagsReturned {
+			t.Fatalf("GetInodeFlags() returned 0x%X, expected 0x%X", flagsReturned, flags)
+		}
+	}
+
+	expectNotPerm := func(what string, err error) {
+		if !blunder.Is(err, blunder.NotPermError) {
+			t.Fatalf("%s should have failed with NotPermError, got: %v", what, err)
+		}
+	}
+
+	expectRefusals := func(flagName string) {
+		err = mS.Resize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0)
+		expectNotPerm("Resize() of "+flagName+" file", err)
+		err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: 0644})
+		expectNotPerm("Setstat() of "+flagName+" file", err)
+		err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestInodeFlagsFile", inode.RootDirInodeNumber, "TestInodeFlagsRenamed", 0)
+		expectNotPerm("Rename() of "+flagName+" file", err)
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestInodeFlagsFile")
+		expectNotPerm("Unlink() of "+flagName+" file", err)
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("ABCD"), nil)
+		expectNotPerm("Write() over "+flagName+" file", err)
+	}
+
+	// An immutable file may not be written at all
+
+	setFlags(inode.ImmutableFlag)
+	expectRefusals("immutable")
+	fileHandle, err := mS.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenAppend, ShareRead|ShareWrite|ShareDelete)
+	if nil != err {
+		t.Fatalf("Open() for append returned error: %v", err)
+	}
+	_, err = mS.WriteByHandle(fileHandle, 0, []byte("4567"), nil)
+	expectNotPerm("WriteByHandle() appending to immutable file", err)
+
+	// An append-only file may only be written at (or beyond) its end
+
+	setFlags(inode.AppendOnlyFlag)
+	expectRefusals("append-only")
+	_, err = mS.WriteByHandle(fileHandle, 0, []byte("45"), nil)
+	if nil != err {
+		t.Fatalf("WriteByHandle() appending to append-only file returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 6, []byte("67"), nil)
+	if nil != err {
+		t.Fatalf("Write() at end of append-only file returned error: %v", err)
+	}
+	err = mS.Close(fileHandle)
+	if nil != err {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 8, nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	if "01234567" != string(buf) {
+		t.Fatalf("Read() returned \"%s\"", buf)
+	}
+
+	setFlags(0)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestInodeFlagsFile")
+	if nil != err {
+		t.Fatalf("Unlink() after clearing flags returned error: %v", err)
+	}
+}
+
+func TestDentryCache(t *testing.T) {
+	vS := mS.volStruct
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestDentryCacheDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	isCached := func(dirInodeNumber inode.InodeNumber, basename string) (cached bool) {
+		vS.dentryCache.Lock()
+		_, cached = vS.dentryCache.dirMap[dirInodeNumber][basename]
+		vS.dentryCache.Unlock()
+		return
+	}
+
+	lookup := func(basename string, expectedInodeNumber inode.InodeNumber) {
+		inodeNumber, lookupErr := mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if nil != lookupErr {
+			t.Fatalf("Lookup(\"%s\") returned error: %v", basename, lookupErr)
+		}
+		if expectedInodeNumber != inodeNumber {
+			t.Fatalf("Lookup(\"%s\") returned %v, expected %v", basename, inodeNumber, expectedInodeNumber)
+		}
+	}
+
+	lookup("File", fileInodeNumber)
+	if !isCached(dirInodeNumber, "File") {
+		t.Fatalf("Lookup() didn't cache its result")
+	}
+	lookup("File", fileInodeNumber)
+
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "..")
+	if nil != err {
+		t.Fatalf("Lookup(\"..\") returned error: %v", err)
+	}
+	if isCached(dirInodeNumber, "..") {
+		t.Fatalf("Lookup(\"..\") should not have been cached")
+	}
+
+	// Changing the directory forgets what was cached for it
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", dirInodeNumber, "Renamed", 0)
+	if nil != err {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+	if isCached(dirInodeNumber, "File") {
+		t.Fatalf("Rename() should have invalidated cached entry")
+	}
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of renamed name should have failed with NotFoundError, got: %v", err)
+	}
+	lookup("Renamed", fileInodeNumber)
+
+	err = mS.Link(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Link", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() returned error: %v", err)
+	}
+	if isCached(dirInodeNumber, "Renamed") {
+		t.Fatalf("Link() should have invalidated cached entries of directory")
+	}
+	lookup("Link", fileInodeNumber)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Link")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Link")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of unlinked name should have failed with NotFoundError, got: %v", err)
+	}
+
+	// A stale generation prevents caching what a Lookup() racing a change found
+
+	_, _, generation := vS.dentryCache.fetch(dirInodeNumber, "Renamed")
+	vS.dentryCache.forgetDirs(dirInodeNumber)
+	vS.dentryCache.insert(dirInodeNumber, "Renamed", fileInodeNumber, generation)
+	if isCached(dirInodeNumber, "Renamed") {
+		t.Fatalf("insert() with stale generation should not have cached entry")
+	}
+
+	// The least recently used entries are evicted beyond DentryCacheMax
+
+	vS.configureDentryCache(1)
+	lookup("Renamed", fileInodeNumber)
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestDentryCacheDir")
+	if nil != err {
+		t.Fatalf("Lookup() of directory returned error: %v", err)
+	}
+	if isCached(dirInodeNumber, "Renamed") || !isCached(inode.RootDirInodeNumber, "TestDentryCacheDir") {
+		t.Fatalf("least recently used entry should have been evicted")
+	}
+	vS.configureDentryCache(defaultDentryCacheMax)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Renamed")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestDentryCacheDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestLookupMulti(t *testing.T) {
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestLookupMultiDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileAInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "FileA", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	fileBInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "FileB", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	inodeNumbers, errs, err := mS.LookupMulti(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, []string{"FileA", "Missing", "FileB", ".."})
+	if nil != err {
+		t.Fatalf("LookupMulti() returned error: %v", err)
+	}
+	if (4 != len(inodeNumbers)) || (4 != len(errs)) {
+		t.Fatalf("LookupMulti() returned %d inodeNumbers & %d errs, expected 4 of each", len(inodeNumbers), len(errs))
+	}
+	if (nil != errs[0]) || (fileAInodeNumber != inodeNumbers[0]) {
+		t.Fatalf("LookupMulti() of \"FileA\" returned %v, %v", inodeNumbers[0], errs[0])
+	}
+	if !blunder.Is(errs[1], blunder.NotFoundError) {
+		t.Fatalf("LookupMulti() of \"Missing\" should have failed with NotFoundError, got: %v", errs[1])
+	}
+	if (nil != errs[2]) || (fileBInodeNumber != inodeNumbers[2]) {
+		t.Fatalf("LookupMulti() of \"FileB\" returned %v, %v", inodeNumbers[2], errs[2])
+	}
+	if (nil != errs[3]) || (inode.RootDirInodeNumber != inodeNumbers[3]) {
+		t.Fatalf("LookupMulti() of \"..\" returned %v, %v", inodeNumbers[3], errs[3])
+	}
+
+	// Only a failure to access the directory itself fails the whole LookupMulti()
+
+	stat := make(Stat)
+	stat[StatMode] = uint64(0700)
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, stat)
+	if nil != err {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	_, _, err = mS.LookupMulti(inode.InodeUserID(1001), inode.InodeGroupID(1001), nil, dirInodeNumber, []string{"FileA"})
+	if !blunder.Is(err, blunder.PermDeniedError) {
+		t.Fatalf("LookupMulti() without search permission should have failed with PermDeniedError, got: %v", err)
+	}
+
+	for _, basename := range []string{"FileA", "FileB"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestLookupMultiDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestShareModeEnforcement(t *testing.T) {
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestShareModeContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	// A deny-write, deny-delete open refuses such access via the inode-based APIs...
+
+	fileHandle, err := mS.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenRead|OpenWrite, ShareRead)
+	if nil != err {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("Read() of file shared for read returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("4567"), nil)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Write() of file not shared for write should have failed with DevBusyError, got: %v", err)
+	}
+	err = mS.Resize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Resize() of file not shared for write should have failed with DevBusyError, got: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Unlink() of file not shared for delete should have failed with DevBusyError, got: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", dirInodeNumber, "Renamed", 0)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Rename() of file not shared for delete should have failed with DevBusyError, got: %v", err)
+	}
+	err = mS.MiddlewareDelete("TestShareModeContainer", "File")
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("MiddlewareDelete() of file not shared for delete should have failed with DevBusyError, got: %v", err)
+	}
+
+	// ...but not via the FileHandle itself
+
+	_, err = mS.WriteByHandle(fileHandle, 0, []byte("4567"), nil)
+	if nil != err {
+		t.Fatalf("WriteByHandle() returned error: %v", err)
+	}
+	buf, err := mS.ReadByHandle(fileHandle, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("ReadByHandle() returned error: %v", err)
+	}
+	if "4567" != string(buf) {
+		t.Fatalf("ReadByHandle() returned \"%s\" (expected \"4567\")", string(buf))
+	}
+
+	err = mS.Close(fileHandle)
+	if nil != err {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	// A deny-read open refuses reads
+
+	fileHandle, err = mS.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenRead, 0)
+	if nil != err {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 4, nil)
+	if blunder.IsNot(err, blunder.DevBusyError) {
+		t.Fatalf("Read() of file not shared for read should have failed with DevBusyError, got: %v", err)
+	}
+	err = mS.Close(fileHandle)
+	if nil != err {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	// An open requesting delete access is presumed to be that of the deleter
+
+	fileHandle, err = mS.Open(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, OpenRead|OpenDelete, ShareRead)
+	if nil != err {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() by holder of delete access returned error: %v", err)
+	}
+	err = mS.Close(fileHandle)
+	if nil != err {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestShareModeContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestRangedStreams(t *testing.T) {
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestRangedStreamsFile", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// A stream may grow beyond XAttrValueMax a piece at a time...
+
+	chunk := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	numChunks := 2 * int(mS.GetLimits().XAttrValueMax) / len(chunk)
+
+	for i := 0; i < numChunks; i++ {
+		err = mS.WriteStream(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.ads", uint64(i*len(chunk)), chunk)
+		if nil != err {
+			t.Fatalf("WriteStream() returned error: %v", err)
+		}
+	}
+
+	size, err := mS.GetStreamSize(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.ads")
+	if nil != err {
+		t.Fatalf("GetStreamSize() returned error: %v", err)
+	}
+	if uint64(numChunks*len(chunk)) != size {
+		t.Fatalf("GetStreamSize() returned %v (expected %v)", size, numChunks*len(chunk))
+	}
+
+	// ...and be read back likewise
+
+	buf, err := mS.ReadStream(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.ads", uint64(len(chunk))+10, 6)
+	if nil != err {
+		t.Fatalf("ReadStream() returned error: %v", err)
+	}
+	if "abcdef" != string(buf) {
+		t.Fatalf("ReadStream() returned \"%s\" (expected \"abcdef\")", string(buf))
+	}
+
+	err = mS.ResizeStream(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.ads", 4)
+	if nil != err {
+		t.Fatalf("ResizeStream() returned error: %v", err)
+	}
+	value, err := mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "user.ads")
+	if nil != err {
+		t.Fatalf("GetXAttr() returned error: %v", err)
+	}
+	if "0123" != string(value) {
+		t.Fatalf("GetXAttr() after ResizeStream() returned \"%s\" (expected \"0123\")", string(value))
+	}
+
+	// Access is checked as for GetXAttr() & SetXAttr()
+
+	_, err = mS.ReadStream(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, MiddlewareStream, 0, 1)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("ReadStream() of reserved stream should have failed with StreamNotFound, got: %v", err)
+	}
+	err = mS.WriteStream(inode.InodeUserID(1001), inode.InodeGroupID(1001), nil, fileInodeNumber, "user.ads", 0, []byte("x"))
+	if !blunder.Is(err, blunder.PermDeniedError) {
+		t.Fatalf("WriteStream() without write permission should have failed with PermDeniedError, got: %v", err)
+	}
+	err = mS.WriteStream(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, "ads", 0, []byte("x"))
+	if !blunder.Is(err, blunder.NotSupportedError) {
+		t.Fatalf("WriteStream() of stream outside any namespace should have failed with NotSupportedError, got: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestRangedStreamsFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestCoherence(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCoherenceFile", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	otherMountHandle, err := Mount("TestVolume", MountOptions(0))
+	if err != nil {
+		t.Fatalf("Mount() returned error: %v", err)
+	}
+
+	breakChan := make(chan LeaseType, 8)
+	handler := func(leaseID LeaseID, inodeNumber inode.InodeNumber, breakTo LeaseType) {
+		breakChan <- breakTo
+		if LeaseNone == breakTo {
+			_ = otherMountHandle.ReleaseLease(leaseID)
+		} else {
+			_ = otherMountHandle.DowngradeLease(leaseID, breakTo)
+		}
+	}
+	expectBreak := func(breakTo LeaseType) {
+		select {
+		case leaseBreak := <-breakChan:
+			if breakTo != leaseBreak {
+				t.Fatalf("LeaseBreakHandler invoked to %v (expected %v)", leaseBreak, breakTo)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("LeaseBreakHandler not invoked")
+		}
+	}
+
+	// A read via another mount breaks a LeaseWrite to LeaseRead, which a subsequent read leaves alone
+
+	_, err = otherMountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, LeaseWrite, handler)
+	if err != nil {
+		t.Fatalf("AcquireLease(LeaseWrite) returned error: %v", err)
+	}
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 1, nil)
+	if err != nil {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	expectBreak(LeaseRead)
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, 1, nil)
+	if err != nil {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	select {
+	case leaseBreak := <-breakChan:
+		t.Fatalf("Read() should not have broken a LeaseRead (broke it to %v)", leaseBreak)
+	default:
+	}
+
+	// A write via another mount breaks every lease
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte{0x01}, nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	expectBreak(LeaseNone)
+
+	// As does an HTTP PUT replacing the file... though the lease holder's own writes do not
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCoherenceContainer", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	objectInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "Object", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	_, err = otherMountHandle.AcquireLease(inode.InodeRootUserID, inode.InodeRootGroupID, nil, objectInodeNumber, LeaseWrite, handler)
+	if err != nil {
+		t.Fatalf("AcquireLease(LeaseWrite) returned error: %v", err)
+	}
+
+	_, err = otherMountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, objectInodeNumber, 0, []byte{0x01}, nil)
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	select {
+	case leaseBreak := <-breakChan:
+		t.Fatalf("Write() via the holder's own mount should not have broken its lease (broke it to %v)", leaseBreak)
+	default:
+	}
+
+	err = mS.MiddlewareDelete("TestCoherenceContainer", "Object")
+	if err != nil {
+		t.Fatalf("MiddlewareDelete() returned error: %v", err)
+	}
+	expectBreak(LeaseNone)
+
+	// Once the file is destroyed, any byte-range locks still held on it are dropped
+
+	var lock FlockStruct
+	lock.Type = syscall.F_WRLCK
+	lock.Pid = 1
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, syscall.F_SETLK, &lock)
+	if err != nil {
+		t.Fatalf("Flock() returned error: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCoherenceFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+
+	mS.volStruct.Lock()
+	_, ok := mS.volStruct.FLockMap[fileInodeNumber]
+	mS.volStruct.Unlock()
+	if ok {
+		t.Fatalf("Unlink() should have dropped the byte-range locks of the destroyed file")
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCoherenceContainer")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+
+	err = Unmount(otherMountHandle)
+	if err != nil {
+		t.Fatalf("Unmount() returned error: %v", err)
+	}
+}
+
+func TestNFS4ACL(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+	ownerUserID := inode.InodeUserID(1001)
+	otherUserID := inode.InodeUserID(1002)
+	otherGroupID := inode.InodeGroupID(1003)
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestNFS4ACLFile", inode.InodeMode(0600))
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	stat := make(Stat)
+	stat[StatUserID] = uint64(ownerUserID)
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, stat)
+	if err != nil {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+
+	acl, err := mS.GetNFS4ACL(otherUserID, otherGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("GetNFS4ACL() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(inode.NFS4ACLFromMode(0600), acl) {
+		t.Fatalf("GetNFS4ACL() of file without ACL returned %v", acl)
+	}
+
+	// Only the owner (or root) may set the ACL
+
+	acl = inode.NFS4ACL{
+		{Type: inode.NFS4ACEAccessAllowed, Flags: inode.NFS4ACESpecialWho, Mask: inode.NFS4MaskReadData | inode.NFS4MaskWriteData | inode.NFS4MaskAppendData, Who: inode.NFS4WhoOwner},
+		{Type: inode.NFS4ACEAccessAllowed, Mask: inode.NFS4MaskReadData, Who: uint32(otherUserID)},
+	}
+
+	err = mS.SetNFS4ACL(otherUserID, otherGroupID, nil, fileInodeNumber, acl)
+	if blunder.IsNot(err, blunder.NotPermError) {
+		t.Fatalf("SetNFS4ACL() by non-owner should have failed with NotPermError: %v", err)
+	}
+	if mS.Access(otherUserID, otherGroupID, nil, fileInodeNumber, inode.R_OK) {
+		t.Fatalf("Access() should have been denied before the ACL was set")
+	}
+
+	err = mS.SetNFS4ACL(ownerUserID, otherGroupID, nil, fileInodeNumber, acl)
+	if err != nil {
+		t.Fatalf("SetNFS4ACL() returned error: %v", err)
+	}
+
+	fetchedACL, err := mS.GetNFS4ACL(otherUserID, otherGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("GetNFS4ACL() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(acl, fetchedACL) {
+		t.Fatalf("GetNFS4ACL() returned %v (expected %v)", fetchedACL, acl)
+	}
+
+	// The ACL now governs access (including that of Read() & Write())
+
+	if !mS.Access(otherUserID, otherGroupID, nil, fileInodeNumber, inode.R_OK) {
+		t.Fatalf("Access(R_OK) should have been granted by the ACL")
+	}
+	_, err = mS.Read(otherUserID, otherGroupID, nil, fileInodeNumber, 0, 1, nil)
+	if err != nil {
+		t.Fatalf("Read() permitted by the ACL returned error: %v", err)
+	}
+	_, err = mS.Write(otherUserID, otherGroupID, nil, fileInodeNumber, 0, []byte{0x01}, nil)
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("Write() not permitted by the ACL should have failed with PermDeniedError: %v", err)
+	}
+
+	// The ACL's stream is reserved
+
+	streamNames, err := mS.ListXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if err != nil {
+		t.Fatalf("ListXAttr() returned error: %v", err)
+	}
+	for _, streamName := range streamNames {
+		if inode.NFS4ACLStream == streamName {
+			t.Fatalf("ListXAttr() should not have listed %v", inode.NFS4ACLStream)
+		}
+	}
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, inode.NFS4ACLStream, []byte{}, 0)
+	if nil == err {
+		t.Fatalf("SetXAttr() of %v should have failed", inode.NFS4ACLStream)
+	}
+
+	// Removing the ACL restores mode-based access
+
+	err = mS.SetNFS4ACL(ownerUserID, otherGroupID, nil, fileInodeNumber, nil)
+	if err != nil {
+		t.Fatalf("SetNFS4ACL(nil) returned error: %v", err)
+	}
+	if mS.Access(otherUserID, otherGroupID, nil, fileInodeNumber, inode.R_OK) {
+		t.Fatalf("Access() should have been denied once the ACL was removed")
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestNFS4ACLFile")
+	if err != nil {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestDirEntryTypes(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestDirEntryTypesDir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Subdir", inode.PosixModePerm)
+	if err != nil {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Symlink", "File")
+	if err != nil {
+		t.Fatalf("Symlink() returned error: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", dirInodeNumber, "RenamedFile", 0)
+	if err != nil {
+		t.Fatalf("Rename() returned error: %v", err)
+	}
+
+	expectedTypes := map[string]inode.InodeType{
+		".":           inode.DirType,
+		"..":          inode.DirType,
+		"RenamedFile": inode.FileType,
+		"Subdir":      inode.DirType,
+		"Symlink":     inode.SymlinkType,
+	}
+
+	entries, numEntries, _, err := mS.Readdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "", 0, 0)
+	if err != nil {
+		t.Fatalf("Readdir() returned error: %v", err)
+	}
+	if uint64(len(expectedTypes)) != numEntries {
+		t.Fatalf("Readdir() returned %v entries (expected %v)", numEntries, len(expectedTypes))
+	}
+	for _, entry := range entries {
+		if expectedTypes[entry.Basename] != entry.Type {
+			t.Fatalf("Readdir() returned Type %v for %v (expected %v)", entry.Type, entry.Basename, expectedTypes[entry.Basename])
+		}
+	}
+
+	// Every entry already recording its type, the upgrade finds nothing to do
+
+	result, err := UpgradeDirEntryTypes("TestVolume", nil)
+	if err != nil {
+		t.Fatalf("UpgradeDirEntryTypes() returned error: %v", err)
+	}
+	if (2 > result.DirsScanned) || (0 != result.EntriesRecorded) || result.Stopped {
+		t.Fatalf("UpgradeDirEntryTypes() returned unexpected result: %+v", result)
+	}
+
+	_, err = UpgradeDirEntryTypes("NoSuchVolume", nil)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("UpgradeDirEntryTypes() of unknown volume should have failed with NotFoundError, got: %v", err)
+	}
+
+	for _, basename := range []string{"RenamedFile", "Symlink"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename)
+		if err != nil {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Subdir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestDirEntryTypesDir")
+	if err != nil {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestAttrCache(t *testing.T) {
+	vS := mS.volStruct
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestAttrCacheDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	isCached := func(inodeNumber inode.InodeNumber) (cached bool) {
+		vS.attrCache.Lock()
+		_, cached = vS.attrCache.inodeMap[inodeNumber]
+		vS.attrCache.Unlock()
+		return
+	}
+
+	getstat := func(inodeNumber inode.InodeNumber, statKey StatKey, expectedValue uint64) {
+		stat, getstatErr := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inodeNumber)
+		if nil != getstatErr {
+			t.Fatalf("Getstat() returned error: %v", getstatErr)
+		}
+		if expectedValue != stat[statKey] {
+			t.Fatalf("Getstat() returned %v == %v, expected %v", statKey, stat[statKey], expectedValue)
+		}
+	}
+
+	getstat(fileInodeNumber, StatSize, 0)
+	if !isCached(fileInodeNumber) {
+		t.Fatalf("Getstat() didn't cache the metadata it fetched")
+	}
+	getstat(fileInodeNumber, StatSize, 0)
+
+	// Changing the inode forgets what was cached for it
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if isCached(fileInodeNumber) {
+		t.Fatalf("Write() should have invalidated cached metadata")
+	}
+	getstat(fileInodeNumber, StatSize, 10)
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{StatMode: uint64(0600)})
+	if nil != err {
+		t.Fatalf("Setstat() returned error: %v", err)
+	}
+	getstat(fileInodeNumber, StatMode, uint64(inode.PosixModeFile|0600))
+
+	// Namespace changes forget both the directory and the inode named
+
+	getstat(dirInodeNumber, StatNLink, 2)
+	err = mS.Link(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Link", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() returned error: %v", err)
+	}
+	if isCached(dirInodeNumber) {
+		t.Fatalf("Link() should have invalidated cached metadata of directory")
+	}
+	getstat(fileInodeNumber, StatNLink, 2)
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "Link")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	getstat(fileInodeNumber, StatNLink, 1)
+
+	vS.attrCache.forgetInodes(fileInodeNumber)
+	_, _, _, _, err = mS.ReaddirPlus(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "", 0, 0)
+	if nil != err {
+		t.Fatalf("ReaddirPlus() returned error: %v", err)
+	}
+	if !isCached(fileInodeNumber) {
+		t.Fatalf("ReaddirPlus() didn't cache the metadata it fetched")
+	}
+
+	// A stale generation prevents caching what a GetMetadata() racing a change found
+
+	metadata, err := vS.VolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() returned error: %v", err)
+	}
+	_, _, generation := vS.attrCache.fetch(fileInodeNumber)
+	vS.attrCache.forgetInodes(fileInodeNumber)
+	vS.attrCache.insert(fileInodeNumber, metadata, generation)
+	if isCached(fileInodeNumber) {
+		t.Fatalf("insert() with stale generation should not have cached metadata")
+	}
+
+	// Cached metadata is copied so that callers can't alter it
+
+	getstat(fileInodeNumber, StatNLink, 1)
+	metadata, err = vS.VolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() returned error: %v", err)
+	}
+	metadata.LinkCount = 99
+	getstat(fileInodeNumber, StatNLink, 1)
+
+	// Destroying the inode forgets it
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	if isCached(fileInodeNumber) {
+		t.Fatalf("Unlink() should have invalidated cached metadata of the inode it destroyed")
+	}
+	_, err = mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil == err {
+		t.Fatalf("Getstat() of destroyed inode should have failed")
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestAttrCacheDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestReaddirPlusParallel(t *testing.T) {
+	vS := mS.volStruct
+
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestReaddirPlusParallelDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	const numFiles = 3*readdirPlusBatchSize + 5
+
+	fileInodeNumbers := make([]inode.InodeNumber, numFiles)
+	for i := range fileInodeNumbers {
+		fileInodeNumbers[i], err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fmt.Sprintf("File%03d", i), inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumbers[i], 0, make([]byte, i+1), nil)
+		if nil != err {
+			t.Fatalf("Write() returned error: %v", err)
+		}
+	}
+
+	vS.Lock()
+	readdirPlusParallelism := vS.readdirPlusParallelism
+	vS.Unlock()
+
+	defer func() {
+		vS.Lock()
+		vS.readdirPlusParallelism = readdirPlusParallelism
+		vS.Unlock()
+	}()
+
+	for _, parallelism := range []uint64{0, 4} {
+		vS.Lock()
+		vS.readdirPlusParallelism = parallelism
+		vS.Unlock()
+
+		// Hold one entry's lock briefly so that its batch must stat it once the lock is released
+
+		heldLock, err := vS.getWriteLock(fileInodeNumbers[readdirPlusBatchSize+1], nil)
+		if nil != err {
+			t.Fatalf("getWriteLock() returned error: %v", err)
+		}
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			heldLock.Unlock()
+		}()
+
+		dirEntries, statEntries, numEntries, _, err := mS.ReaddirPlus(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, "", 0, 0)
+		if nil != err {
+			t.Fatalf("ReaddirPlus() returned error: %v", err)
+		}
+		if (numFiles+2 != numEntries) || (len(dirEntries) != len(statEntries)) {
+			t.Fatalf("ReaddirPlus() returned %v entries & %v stats (expected %v)", numEntries, len(statEntries), numFiles+2)
+		}
+		for i := range dirEntries {
+			if uint64(dirEntries[i].InodeNumber) != statEntries[i][StatINum] {
+				t.Fatalf("ReaddirPlus() returned stat of inode %v for entry %v", statEntries[i][StatINum], dirEntries[i].Basename)
+			}
+		}
+		for i := range fileInodeNumbers {
+			if uint64(i+1) != statEntries[i+2][StatSize] {
+				t.Fatalf("ReaddirPlus() returned Size %v for %v (expected %v)", statEntries[i+2][StatSize], dirEntries[i+2].Basename, i+1)
+			}
+		}
+	}
+
+	for i := range fileInodeNumbers {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, fmt.Sprintf("File%03d", i))
+		if nil != err {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, "TestReaddirPlusParallelDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestRenameOrderingAndCycles(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	aDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameCyclesA", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	bDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, "B", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	cDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "C", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	// A directory can't be moved beneath itself
+
+	for _, dstDirInodeNumber := range []inode.InodeNumber{aDirInodeNumber, bDirInodeNumber, cDirInodeNumber} {
+		err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameCyclesA", dstDirInodeNumber, "A", 0)
+		if !blunder.Is(err, blunder.InvalidArgError) {
+			t.Fatalf("Rename() of directory beneath itself should have failed with InvalidArgError, got: %v", err)
+		}
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "C", rootDirInodeNumber, "TestRenameCyclesA", RenameExchange)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("Rename(RenameExchange) of directory with its descendant should have failed with InvalidArgError, got: %v", err)
+	}
+
+	// Moving a directory up (or sideways) is fine
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "C", aDirInodeNumber, "C", 0)
+	if nil != err {
+		t.Fatalf("Rename() of directory to its grandparent returned error: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, "C", bDirInodeNumber, "C", 0)
+	if nil != err {
+		t.Fatalf("Rename() of directory to its sibling returned error: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "C", aDirInodeNumber, "B", RenameExchange)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("Rename(RenameExchange) of directory with its parent should have failed with InvalidArgError, got: %v", err)
+	}
+
+	// Checking the ancestry needs no ancestor's lock, so a busy ancestor neither fails nor delays a Rename()
+
+	_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "D", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	rootDirLock, err := mS.volStruct.getWriteLock(rootDirInodeNumber, nil)
+	if nil != err {
+		t.Fatalf("getWriteLock() returned error: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "D", cDirInodeNumber, "D", 0)
+	rootDirLock.Unlock()
+	if nil != err {
+		t.Fatalf("Rename() of directory to its sibling with the root directory locked returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, cDirInodeNumber, "D")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+
+	// Renames between the same pair of directories in opposite directions all complete
+
+	const renamesPerDirection = 50
+
+	for _, basename := range []string{"X", "Y"} {
+		dirInodeNumber := bDirInodeNumber
+		if "Y" == basename {
+			dirInodeNumber = cDirInodeNumber
+		}
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	renameBackAndForth := func(basename string, fromDirInodeNumber inode.InodeNumber, toDirInodeNumber inode.InodeNumber, errChan chan error) {
+		for i := 0; i < renamesPerDirection; i++ {
+			renameErr := mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fromDirInodeNumber, basename, toDirInodeNumber, basename, 0)
+			if nil != renameErr {
+				errChan <- renameErr
+				return
+			}
+			fromDirInodeNumber, toDirInodeNumber = toDirInodeNumber, fromDirInodeNumber
+		}
+		errChan <- nil
+	}
+
+	errChan := make(chan error, 2)
+	go renameBackAndForth("X", bDirInodeNumber, cDirInodeNumber, errChan)
+	go renameBackAndForth("Y", cDirInodeNumber, bDirInodeNumber, errChan)
+	for i := 0; i < 2; i++ {
+		err = <-errChan
+		if nil != err {
+			t.Fatalf("concurrent Rename() returned error: %v", err)
+		}
+	}
+
+	// Of concurrent Rename()s moving each of two sibling directories beneath the other, only one succeeds
+
+	pDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, "P", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	qDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, "Q", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	renameBeneath := func(basename string, dstDirInodeNumber inode.InodeNumber, errChan chan error) {
+		errChan <- mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, basename, dstDirInodeNumber, basename, 0)
+	}
+
+	for i := 0; i < renamesPerDirection; i++ {
+		pErrChan := make(chan error, 1)
+		qErrChan := make(chan error, 1)
+		go renameBeneath("P", qDirInodeNumber, pErrChan)
+		go renameBeneath("Q", pDirInodeNumber, qErrChan)
+		pErr := <-pErrChan
+		qErr := <-qErrChan
+
+		switch {
+		case (nil == pErr) && blunder.Is(qErr, blunder.InvalidArgError):
+			err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, qDirInodeNumber, "P", aDirInodeNumber, "P", 0)
+		case (nil == qErr) && blunder.Is(pErr, blunder.InvalidArgError):
+			err = mS.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, pDirInodeNumber, "Q", aDirInodeNumber, "Q", 0)
+		default:
+			t.Fatalf("concurrent Rename()s of directories beneath each other returned %v & %v (expected one to fail with InvalidArgError)", pErr, qErr)
+		}
+		if nil != err {
+			t.Fatalf("Rename() back returned error: %v", err)
+		}
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, "P")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, "Q")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "X")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, cDirInodeNumber, "Y")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, bDirInodeNumber, "C")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, aDirInodeNumber, "B")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestRenameCyclesA")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestSetstatAtomic(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetstatAtomicFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetstatAtomicDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	before, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+
+	// All attributes change together, counting as a single change
+
+	newMTime := uint64(time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).UnixNano())
+	newATime := uint64(time.Date(2002, 3, 4, 5, 6, 7, 0, time.UTC).UnixNano())
+
+	rejected, err := mS.SetstatAtomic(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{
+		StatSize:    4,
+		StatMode:    0640,
+		StatUserID:  1001,
+		StatGroupID: 1002,
+		StatMTime:   newMTime,
+		StatATime:   newATime,
+	})
+	if (nil != err) || (nil != rejected) {
+		t.Fatalf("SetstatAtomic() returned rejected %v, error: %v", rejected, err)
+	}
+
+	after, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if (4 != after[StatSize]) || (0640 != after[StatMode]&uint64(inode.PosixModePerm)) || (1001 != after[StatUserID]) || (1002 != after[StatGroupID]) {
+		t.Fatalf("SetstatAtomic() left size %v mode 0%o uid %v gid %v", after[StatSize], after[StatMode], after[StatUserID], after[StatGroupID])
+	}
+	if (newMTime != after[StatMTime]) || (newATime != after[StatATime]) {
+		t.Fatalf("SetstatAtomic() left mtime %v atime %v (size must not override the requested mtime)", after[StatMTime], after[StatATime])
+	}
+	if before[StatChangeCount]+1 != after[StatChangeCount] {
+		t.Fatalf("SetstatAtomic() changed ChangeCount from %v to %v (expected a single change)", before[StatChangeCount], after[StatChangeCount])
+	}
+
+	// A rejected attribute leaves every attribute unchanged
+
+	rejected, err = mS.SetstatAtomic(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, Stat{
+		StatSize:    0,
+		StatMode:    0600,
+		StatUserID:  math.MaxUint32 + 1,
+		StatGroupID: math.MaxUint32 + 1,
+	})
+	if !blunder.Is(err, blunder.InvalidUserIDError) {
+		t.Fatalf("SetstatAtomic() of oversized userID should have failed with InvalidUserIDError, got: %v", err)
+	}
+	if !reflect.DeepEqual([]StatKey{StatUserID, StatGroupID}, rejected) {
+		t.Fatalf("SetstatAtomic() returned rejected %v", rejected)
+	}
+
+	unchanged, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(after, unchanged) {
+		t.Fatalf("SetstatAtomic() with rejected attributes changed %v to %v", after, unchanged)
+	}
+
+	rejected, err = mS.SetstatAtomic(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, Stat{StatSize: 0, StatMode: 0700})
+	if !blunder.Is(err, blunder.NotFileError) {
+		t.Fatalf("SetstatAtomic() of directory size should have failed with NotFileError, got: %v", err)
+	}
+	if !reflect.DeepEqual([]StatKey{StatSize}, rejected) {
+		t.Fatalf("SetstatAtomic() returned rejected %v", rejected)
+	}
+	dirStat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() returned error: %v", err)
+	}
+	if uint64(inode.PosixModePerm) != dirStat[StatMode]&uint64(inode.PosixModePerm) {
+		t.Fatalf("SetstatAtomic() with rejected size changed directory mode to 0%o", dirStat[StatMode])
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetstatAtomicDir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSetstatAtomicFile")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+}
+
+func TestMiddlewareGetObjectRanges(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadRangeContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "object", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	u64 := func(value uint64) *uint64 { return &value }
+
+	readPlanBytes := func(readRangeIn []ReadRangeIn) (planBytes []uint64, err error) {
+		for i := range readRangeIn {
+			readRangeOut := make([]inode.ReadPlanStep, 0)
+			_, _, _, _, _, _, _, err = mS.MiddlewareGetObject("TestVolume", "TestReadRangeContainer/object", readRangeIn[i:i+1], &readRangeOut)
+			if nil != err {
+				return
+			}
+			var length uint64
+			for _, step := range readRangeOut {
+				length += step.Length
+			}
+			planBytes = append(planBytes, length)
+		}
+		return
+	}
+
+	// Satisfiable ranges are clamped to the end of the file
+
+	planBytes, err := readPlanBytes([]ReadRangeIn{
+		{Offset: u64(2), Len: u64(3)},
+		{Offset: u64(8), Len: u64(100)},
+		{Offset: u64(8), Len: u64(math.MaxUint64)},
+		{Offset: u64(4), Len: nil},
+		{Offset: nil, Len: u64(3)},
+		{Offset: nil, Len: u64(100)},
+	})
+	if nil != err {
+		t.Fatalf("MiddlewareGetObject() returned error: %v", err)
+	}
+	if !reflect.DeepEqual([]uint64{3, 2, 2, 6, 3, 10}, planBytes) {
+		t.Fatalf("MiddlewareGetObject() returned read plans of %v bytes", planBytes)
+	}
+
+	// Unsatisfiable ranges are dropped... unless no range remains
+
+	readRangeOut := make([]inode.ReadPlanStep, 0)
+	_, _, _, _, _, _, _, err = mS.MiddlewareGetObject("TestVolume", "TestReadRangeContainer/object", []ReadRangeIn{{Offset: u64(20), Len: nil}, {Offset: u64(1), Len: u64(1)}}, &readRangeOut)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObject() with one satisfiable range returned error: %v", err)
+	}
+	if (1 != len(readRangeOut)) || (1 != readRangeOut[0].Length) {
+		t.Fatalf("MiddlewareGetObject() with one satisfiable range returned read plan %+v", readRangeOut)
+	}
+
+	for _, readRangeIn := range [][]ReadRangeIn{{{Offset: u64(10), Len: nil}}, {{Offset: u64(20), Len: u64(5)}}, {{Offset: nil, Len: u64(0)}}} {
+		readRangeOut = make([]inode.ReadPlanStep, 0)
+		_, _, _, _, _, _, _, err = mS.MiddlewareGetObject("TestVolume", "TestReadRangeContainer/object", readRangeIn, &readRangeOut)
+		if !blunder.Is(err, blunder.RangeNotSatisfiable) {
+			t.Fatalf("MiddlewareGetObject() of unsatisfiable range should have failed with RangeNotSatisfiable, got: %v", err)
+		}
+	}
+
+	_, _, _, _, _, _, _, err = mS.MiddlewareGetObject("TestVolume", "TestReadRangeContainer/object", []ReadRangeIn{{Offset: nil, Len: nil}}, &readRangeOut)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareGetObject() of range with neither Offset nor Len should have failed with InvalidArgError, got: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "object")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestReadRangeContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestContentTypeDetection(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	mS.volStruct.Lock()
+	mS.volStruct.contentTypeDetection = contentTypeDetectionSniff
+	mS.volStruct.Unlock()
+	defer func() {
+		mS.volStruct.Lock()
+		mS.volStruct.contentTypeDetection = contentTypeDetectionNone
+		mS.volStruct.Unlock()
+	}()
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestContentTypeContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	headContentType := func(objectName string) string {
+		headResponse, headErr := mS.MiddlewareHeadResponse("TestContentTypeContainer/" + objectName)
+		if nil != headErr {
+			t.Fatalf("MiddlewareHeadResponse() returned error: %v", headErr)
+		}
+		return headResponse.ContentType
+	}
+
+	// Detected from the extension upon Create()
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "page.html", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if "text/html; charset=utf-8" != headContentType("page.html") {
+		t.Fatalf("MiddlewareHeadResponse() of page.html returned ContentType \"%s\"", headContentType("page.html"))
+	}
+
+	// ...else sniffed upon the first Flush() of data
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "document", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+	if "" != headContentType("document") {
+		t.Fatalf("MiddlewareHeadResponse() of empty document returned ContentType \"%s\"", headContentType("document"))
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("%PDF-1.4\n"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+	if "application/pdf" != headContentType("document") {
+		t.Fatalf("MiddlewareHeadResponse() of document returned ContentType \"%s\"", headContentType("document"))
+	}
+
+	readRangeOut := make([]inode.ReadPlanStep, 0)
+	_, _, _, _, _, _, contentType, err := mS.MiddlewareGetObject("TestVolume", "TestContentTypeContainer/document", nil, &readRangeOut)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObject() returned error: %v", err)
+	}
+	if "application/pdf" != contentType {
+		t.Fatalf("MiddlewareGetObject() of document returned ContentType \"%s\"", contentType)
+	}
+
+	// Not revised as the file is rewritten
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("GIF89a"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+	if "application/pdf" != headContentType("document") {
+		t.Fatalf("MiddlewareHeadResponse() of rewritten document returned ContentType \"%s\"", headContentType("document"))
+	}
+
+	// ContentTypeStream is reserved
+
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, ContentTypeStream)
+	if nil == err {
+		t.Fatalf("GetXAttr() of ContentTypeStream should have failed")
+	}
+
+	// Nothing is detected with detection disabled
+
+	mS.volStruct.Lock()
+	mS.volStruct.contentTypeDetection = contentTypeDetectionNone
+	mS.volStruct.Unlock()
+
+	fileInodeNumber, err = mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "undetected.html", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte("%PDF-1.4\n"), nil)
+	if nil != err {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+	if "" != headContentType("undetected.html") {
+		t.Fatalf("MiddlewareHeadResponse() with detection disabled returned ContentType \"%s\"", headContentType("undetected.html"))
+	}
+
+	for _, basename := range []string{"page.html", "document", "undetected.html"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestContentTypeContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestMiddlewareCoalesceElements(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCoalesceElementsContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	dirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "dir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	for basename, contents := range map[string]string{"a": "abcdefgh", "b": "ijkl", "c": "mnop"} {
+		fileInodeNumber, createErr := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if nil != createErr {
+			t.Fatalf("Create() returned error: %v", createErr)
+		}
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte(contents), nil)
+		if nil != err {
+			t.Fatalf("Write() returned error: %v", err)
+		}
+	}
+
+	// A range beyond the end of an element is refused
+
+	_, _, _, err = mS.MiddlewareCoalesceElements("TestCoalesceElementsContainer/combined", []CoalesceElementSpec{
+		{Path: "TestCoalesceElementsContainer/dir/b", Offset: 5},
+	}, true)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareCoalesceElements() of range beyond element should have failed with InvalidArgError, got: %v", err)
+	}
+
+	// Just the requested ranges are combined, and a manifest of them left behind
+
+	combinedInodeNumber, _, _, err := mS.MiddlewareCoalesceElements("TestCoalesceElementsContainer/combined", []CoalesceElementSpec{
+		{Path: "TestCoalesceElementsContainer/dir/a", Offset: 2, Length: 3},
+		{Path: "TestCoalesceElementsContainer/dir/b", Offset: 1},
+		{Path: "TestCoalesceElementsContainer/dir/c"},
+	}, true)
+	if nil != err {
+		t.Fatalf("MiddlewareCoalesceElements() returned error: %v", err)
+	}
+	combinedContents, err := mS.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(combinedInodeNumber), 0, 100, nil)
+	if nil != err {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+	if "cdejklmnop" != string(combinedContents) {
+		t.Fatalf("MiddlewareCoalesceElements() produced \"%s\" (expected \"cdejklmnop\")", string(combinedContents))
+	}
+
+	manifestBuf, err := mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(combinedInodeNumber), CoalesceManifestStream)
+	if nil != err {
+		t.Fatalf("GetXAttr() of CoalesceManifestStream returned error: %v", err)
+	}
+	var manifest []coalesceManifestSegmentStruct
+	err = json.Unmarshal(manifestBuf, &manifest)
+	if nil != err {
+		t.Fatalf("json.Unmarshal() of manifest returned error: %v", err)
+	}
+	expectedManifest := []coalesceManifestSegmentStruct{
+		{Name: "/TestCoalesceElementsContainer/dir/a", Bytes: 3, Range: "2-4"},
+		{Name: "/TestCoalesceElementsContainer/dir/b", Bytes: 3, Range: "1-3"},
+		{Name: "/TestCoalesceElementsContainer/dir/c", Bytes: 4},
+	}
+	if !reflect.DeepEqual(expectedManifest, manifest) {
+		t.Fatalf("MiddlewareCoalesceElements() left manifest %+v (expected %+v)", manifest, expectedManifest)
+	}
+
+	// MiddlewareCoalesce() consumes whole elements and leaves no manifest
+
+	for _, basename := range []string{"d", "e"} {
+		fileInodeNumber, createErr := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if nil != createErr {
+			t.Fatalf("Create() returned error: %v", createErr)
+		}
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, fileInodeNumber, 0, []byte(basename), nil)
+		if nil != err {
+			t.Fatalf("Write() returned error: %v", err)
+		}
+	}
+	wholeInodeNumber, _, _, err := mS.MiddlewareCoalesce("TestCoalesceElementsContainer/whole", []string{"TestCoalesceElementsContainer/dir/d", "TestCoalesceElementsContainer/dir/e"})
+	if nil != err {
+		t.Fatalf("MiddlewareCoalesce() returned error: %v", err)
+	}
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(wholeInodeNumber), CoalesceManifestStream)
+	if nil == err {
+		t.Fatalf("GetXAttr() of CoalesceManifestStream after MiddlewareCoalesce() should have failed")
+	}
+
+	for _, basename := range []string{"combined", "whole"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "dir")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestCoalesceElementsContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	rootDirInodeNumber := inode.RootDirInodeNumber
+
+	savedPolicy := mS.volStruct.symlinkPolicy
+	defer func() {
+		mS.volStruct.Lock()
+		mS.volStruct.symlinkPolicy = savedPolicy
+		mS.volStruct.limits.MaxSymlinks = MaxSymlinks
+		mS.volStruct.Unlock()
+	}()
+
+	containerInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSymlinkPolicyContainer", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	symlinks := map[string]string{
+		"Link1":         "File",
+		"Link2":         "Link1",
+		"Link3":         "Link2",
+		"AbsoluteLink":  "/TestSymlinkPolicyContainer/File",
+		"MountedLink":   "/mnt/TestVolume/TestSymlinkPolicyContainer/File",
+		"EscapingLink":  "/etc/passwd",
+		"MountRootLink": "/mnt/TestVolume",
+	}
+	for basename, target := range symlinks {
+		_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, basename, target)
+		if nil != err {
+			t.Fatalf("Symlink() returned error: %v", err)
+		}
+	}
+
+	lookupPath := func(basename string) (inodeNumber inode.InodeNumber, err error) {
+		return mS.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, "/TestSymlinkPolicyContainer/"+basename)
+	}
+
+	// MaxSymlinks caps the symlinks followed (and is reported by GetLimits())
+
+	mS.volStruct.Lock()
+	mS.volStruct.limits.MaxSymlinks = 2
+	mS.volStruct.Unlock()
+
+	if 2 != mS.GetLimits().MaxSymlinks {
+		t.Fatalf("GetLimits() returned MaxSymlinks %v (expected 2)", mS.GetLimits().MaxSymlinks)
+	}
+	lookupInodeNumber, err := lookupPath("Link2")
+	if (nil != err) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() following 2 symlinks returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+	_, err = lookupPath("Link3")
+	if blunder.IsNot(err, blunder.TooManySymlinksError) {
+		t.Fatalf("LookupPath() following 3 symlinks should have failed with TooManySymlinksError, instead got: %v", err)
+	}
+
+	// Absolute symlinks are confined to those beneath the mount point
+
+	mS.volStruct.Lock()
+	mS.volStruct.limits.MaxSymlinks = MaxSymlinks
+	mS.volStruct.symlinkPolicy.confineAbsolute = true
+	mS.volStruct.symlinkPolicy.mountPointPath = "/mnt/TestVolume"
+	mS.volStruct.Unlock()
+
+	lookupInodeNumber, err = lookupPath("MountedLink")
+	if (nil != err) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() of confined symlink beneath the mount point returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+	lookupInodeNumber, err = lookupPath("MountRootLink")
+	if (nil != err) || (rootDirInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() of confined symlink to the mount point returned %v, %v (expected %v)", lookupInodeNumber, err, rootDirInodeNumber)
+	}
+	for _, basename := range []string{"AbsoluteLink", "EscapingLink"} {
+		_, err = lookupPath(basename)
+		if blunder.IsNot(err, blunder.PermDeniedError) {
+			t.Fatalf("LookupPath() of confined symlink %s should have failed with PermDeniedError, instead got: %v", basename, err)
+		}
+	}
+
+	mS.volStruct.Lock()
+	mS.volStruct.symlinkPolicy.confineAbsolute = false
+	mS.volStruct.Unlock()
+
+	lookupInodeNumber, err = lookupPath("AbsoluteLink")
+	if (nil != err) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() of unconfined absolute symlink returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+
+	// Middleware requests may be refused symlinks entirely (without affecting other callers)
+
+	mS.volStruct.Lock()
+	mS.volStruct.symlinkPolicy.middlewareFollow = false
+	mS.volStruct.Unlock()
+
+	_, err = mS.MiddlewareHeadResponse("TestSymlinkPolicyContainer/Link1")
+	if blunder.IsNot(err, blunder.PermDeniedError) {
+		t.Fatalf("MiddlewareHeadResponse() of symlink should have failed with PermDeniedError, instead got: %v", err)
+	}
+	pathFailure := PathFailure(err)
+	if (nil == pathFailure) || (PathFailurePermDenied != pathFailure.Reason) || ("Link1" != pathFailure.Segment) {
+		t.Fatalf("MiddlewareHeadResponse() of symlink returned PathFailure %+v", pathFailure)
+	}
+	_, err = mS.MiddlewareHeadResponse("TestSymlinkPolicyContainer/File")
+	if nil != err {
+		t.Fatalf("MiddlewareHeadResponse() of file returned error: %v", err)
+	}
+	lookupInodeNumber, err = lookupPath("Link1")
+	if (nil != err) || (fileInodeNumber != lookupInodeNumber) {
+		t.Fatalf("LookupPath() of symlink with middleware refusing symlinks returned %v, %v (expected %v)", lookupInodeNumber, err, fileInodeNumber)
+	}
+
+	for basename := range symlinks {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink() returned error: %v", err)
+		}
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() returned error: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, rootDirInodeNumber, "TestSymlinkPolicyContainer")
+	if nil != err {
+		t.Fatalf("Rmdir() returned error: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package fs
+
+// Relative path resolution (*at() semantics)
+//
+// ResolvePathAt(), OpenAt(), UnlinkAt(), and RenameAt() mirror POSIX openat(), unlinkat(), and
+// renameat(): a relative path is resolved starting from the supplied directory inode (rather than the
+// root directory) so that clients holding a directory need not re-walk (and lock) each directory above
+// it. As with their POSIX counterparts, a path beginning with "/" is instead resolved from the root
+// directory. Symlinks are followed (per the volume's policy; see symlink_policy.go) as by LookupPath(), except that UnlinkAt() and
+// RenameAt() act upon (rather than follow) a symlink named by the final path component.
+
+import (
+	"path"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// lookupPathAt resolves relativePath from dirInodeNumber for the (already mapped) caller identity.
+func (mS *mountStruct) lookupPathAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string) (inodeNumber inode.InodeNumber, err error) {
+	if strings.HasPrefix(relativePath, "/") {
+		dirInodeNumber = inode.RootDirInodeNumber
+	}
+
+	checkSearch := func(dirInodeNumber inode.InodeNumber) bool {
+		return mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.X_OK)
+	}
+
+	inodeNumber, _, inodeLock, err := mS.resolvePath(relativePath, nil, dirInodeNumber, mS.volStruct.ensureReadLock, checkSearch, false)
+	if nil != err {
+		return
+	}
+	if nil != inodeLock {
+		inodeLock.Unlock()
+	}
+	return
+}
+
+// lookupParentAt resolves all but the final component of relativePath from dirInodeNumber for the
+// (already mapped) caller identity, returning the resultant directory and the final component.
+func (mS *mountStruct) lookupParentAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string) (parentInodeNumber inode.InodeNumber, basename string, err error) {
+	trimmedPath := strings.TrimRight(relativePath, "/")
+	if "" == trimmedPath {
+		err = blunder.NewError(blunder.InvalidArgError, "path \"%s\" names no directory entry", relativePath)
+		return
+	}
+
+	basename = path.Base(trimmedPath)
+	if ("." == basename) || (".." == basename) {
+		err = blunder.NewError(blunder.InvalidArgError, "path %s must not end in \"%s\"", relativePath, basename)
+		return
+	}
+
+	parentPath := trimmedPath[:len(trimmedPath)-len(basename)]
+
+	if "" == parentPath {
+		parentInodeNumber = dirInodeNumber
+		return
+	}
+
+	parentInodeNumber, err = mS.lookupPathAt(userID, groupID, otherGroupIDs, dirInodeNumber, parentPath)
+	return
+}
+
+func (mS *mountStruct) ResolvePathAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string) (inodeNumber inode.InodeNumber, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	inodeNumber, err = mS.resolvePathAt(userID, groupID, otherGroupIDs, dirInodeNumber, relativePath)
+	return
+}
+
+// resolvePathAt is ResolvePathAt() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) resolvePathAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string) (inodeNumber inode.InodeNumber, err error) {
+	mappedUserID, mappedGroupID, mappedOtherGroupIDs := mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeNumber, err = mS.lookupPathAt(mappedUserID, mappedGroupID, mappedOtherGroupIDs, dirInodeNumber, relativePath)
+
+	stats.IncrementOperations(&stats.FsResolvePathAtOps)
+	return
+}
+
+func (mS *mountStruct) OpenAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string, flags OpenFlags, shareMode ShareMode) (fileHandle FileHandle, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	inodeNumber, err := mS.resolvePathAt(userID, groupID, otherGroupIDs, dirInodeNumber, relativePath)
+	if nil != err {
+		return
+	}
+
+	fileHandle, err = mS.open(userID, groupID, otherGroupIDs, inodeNumber, flags, shareMode)
+	return
+}
+
+// UnlinkAt removes the file (or, if removeDir, the empty directory) named by relativePath.
+func (mS *mountStruct) UnlinkAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, relativePath string, removeDir bool) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	mappedUserID, mappedGroupID, mappedOtherGroupIDs := mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	parentInodeNumber, basename, err := mS.lookupParentAt(mappedUserID, mappedGroupID, mappedOtherGroupIDs, dirInodeNumber, relativePath)
+	if nil != err {
+		return
+	}
+
+	if removeDir {
+		err = mS.rmdir(userID, groupID, otherGroupIDs, parentInodeNumber, basename)
+	} else {
+		err = mS.unlink(userID, groupID, otherGroupIDs, parentInodeNumber, basename)
+	}
+
+	stats.IncrementOperations(&stats.FsUnlinkAtOps)
+	return
+}
+
+func (mS *mountStruct) RenameAt(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcRelativePath string, dstDirInodeNumber inode.InodeNumber, dstRelativePath string, flags RenameFlags) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	mappedUserID, mappedGroupID, mappedOtherGroupIDs := mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	srcParentInodeNumber, srcBasename, err := mS.lookupParentAt(mappedUserID, mappedGroupID, mappedOtherGroupIDs, srcDirInodeNumber, srcRelativePath)
+	if nil != err {
+		return
+	}
+	dstParentInodeNumber, dstBasename, err := mS.lookupParentAt(mappedUserID, mappedGroupID, mappedOtherGroupIDs, dstDirInodeNumber, dstRelativePath)
+	if nil != err {
+		return
+	}
+
+	err = mS.rename(userID, groupID, otherGroupIDs, srcParentInodeNumber, srcBasename, dstParentInodeNumber, dstBasename, flags)
+
+	stats.IncrementOperations(&stats.FsRenameAtOps)
+	return
+}
@@ -0,0 +1,80 @@
+package fs
+
+// Access time maintenance
+//
+// Each mount selects one of MountNoATime, MountRelATime, or MountStrictATime. Read operations
+// (Read(), Readdir*(), and Readsymlink()) note their access once their (read) inode lock has been
+// released. If the mount's policy calls for it (and the mount is not MountReadOnly), the inode is
+// then write-locked and its atime updated. As with the Linux "relatime" mount option, MountRelATime
+// updates atime only when it would otherwise appear to predate the last modification or is more
+// than relATimeInterval old.
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const relATimeInterval = 24 * time.Hour
+
+const aTimeMountOptions = MountNoATime | MountRelATime | MountStrictATime
+
+func validateATimeMountOptions(mountOptions MountOptions) (err error) {
+	switch mountOptions & aTimeMountOptions {
+	case 0, MountNoATime, MountRelATime, MountStrictATime:
+		err = nil
+	default:
+		err = blunder.NewError(blunder.InvalidArgError, "at most one of MountNoATime, MountRelATime, or MountStrictATime may be specified")
+	}
+	return
+}
+
+func aTimeUpdateNeeded(mountOptions MountOptions, metadata *inode.MetadataStruct, now time.Time) bool {
+	switch mountOptions & aTimeMountOptions {
+	case MountStrictATime:
+		return true
+	case MountRelATime:
+		if !metadata.AccessTime.After(metadata.ModificationTime) || !metadata.AccessTime.After(metadata.AttrChangeTime) {
+			return true
+		}
+		return now.Sub(metadata.AccessTime) >= relATimeInterval
+	default:
+		return false
+	}
+}
+
+// noteAccess is called, without holding inodeNumber's lock, following a successful read operation.
+func (mS *mountStruct) noteAccess(inodeNumber inode.InodeNumber) {
+	if (0 == mS.options&(MountRelATime|MountStrictATime)) || (0 != mS.options&MountReadOnly) {
+		return
+	}
+
+	inodeLock, err := mS.volStruct.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		// Most likely the inode was removed since it was read
+		return
+	}
+
+	now := mS.volStruct.VolumeHandle.Now()
+
+	if !aTimeUpdateNeeded(mS.options, metadata, now) {
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.UpdateAccessTime(inodeNumber, now)
+	if nil != err {
+		logger.ErrorfWithError(err, "fs.noteAccess(): UpdateAccessTime() of volume '%s' inode %v failed", mS.volStruct.volumeName, inodeNumber)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsATimeUpdateOps)
+}
@@ -0,0 +1,413 @@
+package fs
+
+// Attribute cache
+//
+// Getstat() and ReaddirPlus() are often issued in storms (e.g. by Finder or Explorer refreshing a window)
+// for the same few inodes, each fetching the inode's full metadata from the inode layer. Instead, the
+// volume's inode.VolumeHandle is wrapped (outside its dentry cache, see dentry_cache.go) by an
+// attrCacheVolumeHandleStruct remembering the MetadataStruct returned by up to [<volume-section>]AttrCacheMax
+// (0 == none) recent GetMetadata()s, keyed by inode number, evicting the least recently used.
+//
+// The cache is write-through: each of the wrapper's methods that may change an inode's metadata forgets the
+// entries of every inode it touches (e.g., for Unlink(), both the directory and the inode the entry named,
+// whose LinkCount drops). An inode being destroyed is forgotten along with its locks and leases (see
+// coherence.go). As with the dentry cache, a GetMetadata() racing such a change only caches what it found if
+// no inode has been changed since it began (see generation).
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+const defaultAttrCacheMax = 16384
+
+type attrCacheStruct struct {
+	sync.Mutex
+	max        uint64                              // [<volume-section>]AttrCacheMax
+	generation uint64                              // advanced as each inode is changed
+	inodeMap   map[inode.InodeNumber]*list.Element // Value.(*attrCacheEntryStruct)
+	lruList    *list.List                          // front == most recently used
+}
+
+type attrCacheEntryStruct struct {
+	inodeNumber inode.InodeNumber
+	metadata    inode.MetadataStruct
+}
+
+// attrCacheVolumeHandleStruct is the inode.VolumeHandle used by fs, consulting & maintaining cache.
+type attrCacheVolumeHandleStruct struct {
+	inode.VolumeHandle
+	cache *attrCacheStruct
+}
+
+// newAttrCacheVolumeHandle wraps volumeHandle (newly fetched for vS) with vS's (emptied) attribute cache.
+func (vS *volumeStruct) newAttrCacheVolumeHandle(volumeHandle inode.VolumeHandle) inode.VolumeHandle {
+	vS.attrCache.Lock()
+	vS.attrCache.inodeMap = make(map[inode.InodeNumber]*list.Element)
+	vS.attrCache.lruList = list.New()
+	vS.attrCache.generation++
+	vS.attrCache.Unlock()
+
+	return &attrCacheVolumeHandleStruct{VolumeHandle: volumeHandle, cache: &vS.attrCache}
+}
+
+func (vS *volumeStruct) configureAttrCache(max uint64) {
+	vS.attrCache.Lock()
+	vS.attrCache.max = max
+	vS.attrCache.evictWhileLocked()
+	vS.attrCache.Unlock()
+}
+
+// copyMetadata returns a copy of metadata not sharing its InodeStreamNameSlice.
+func copyMetadata(metadata *inode.MetadataStruct) (metadataCopy *inode.MetadataStruct) {
+	metadataCopy = &inode.MetadataStruct{}
+	*metadataCopy = *metadata
+	metadataCopy.InodeStreamNameSlice = append(make([]string, 0, len(metadata.InodeStreamNameSlice)), metadata.InodeStreamNameSlice...)
+	return
+}
+
+// evictWhileLocked evicts the least recently used entries beyond max. Caller must hold cache.Mutex.
+func (cache *attrCacheStruct) evictWhileLocked() {
+	if nil == cache.lruList {
+		return
+	}
+
+	for uint64(cache.lruList.Len()) > cache.max {
+		entry := cache.lruList.Remove(cache.lruList.Back()).(*attrCacheEntryStruct)
+		delete(cache.inodeMap, entry.inodeNumber)
+	}
+}
+
+// fetch returns (a copy of) the cached metadata of inodeNumber (if ok) along with the generation to pass
+// to any subsequent insert() of what an uncached GetMetadata() finds.
+func (cache *attrCacheStruct) fetch(inodeNumber inode.InodeNumber) (metadata *inode.MetadataStruct, ok bool, generation uint64) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	generation = cache.generation
+
+	element, ok := cache.inodeMap[inodeNumber]
+	if ok {
+		cache.lruList.MoveToFront(element)
+		metadata = copyMetadata(&element.Value.(*attrCacheEntryStruct).metadata)
+	}
+	return
+}
+
+// insert caches (a copy of) metadata as that of inodeNumber unless some inode has since changed (i.e.
+// generation is no longer current).
+func (cache *attrCacheStruct) insert(inodeNumber inode.InodeNumber, metadata *inode.MetadataStruct, generation uint64) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if (0 == cache.max) || (generation != cache.generation) || (nil == cache.inodeMap) {
+		return
+	}
+
+	element, ok := cache.inodeMap[inodeNumber]
+	if ok {
+		element.Value.(*attrCacheEntryStruct).metadata = *copyMetadata(metadata)
+		cache.lruList.MoveToFront(element)
+		return
+	}
+
+	cache.inodeMap[inodeNumber] = cache.lruList.PushFront(&attrCacheEntryStruct{
+		inodeNumber: inodeNumber,
+		metadata:    *copyMetadata(metadata),
+	})
+
+	cache.evictWhileLocked()
+}
+
+// forgetInodes forgets the cached metadata of each of inodeNumbers.
+func (cache *attrCacheStruct) forgetInodes(inodeNumbers ...inode.InodeNumber) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.generation++
+
+	for _, inodeNumber := range inodeNumbers {
+		element, ok := cache.inodeMap[inodeNumber]
+		if ok {
+			cache.lruList.Remove(element)
+			delete(cache.inodeMap, inodeNumber)
+		}
+	}
+}
+
+func (aH *attrCacheVolumeHandleStruct) GetMetadata(inodeNumber inode.InodeNumber) (metadata *inode.MetadataStruct, err error) {
+	metadata, ok, generation := aH.cache.fetch(inodeNumber)
+	if ok {
+		stats.IncrementOperations(&stats.FsAttrCacheHitOps)
+		return
+	}
+
+	metadata, err = aH.VolumeHandle.GetMetadata(inodeNumber)
+	if nil == err {
+		aH.cache.insert(inodeNumber, metadata, generation)
+	}
+
+	stats.IncrementOperations(&stats.FsAttrCacheMissOps)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) GetMetadataMulti(inodeNumbers []inode.InodeNumber) (metadataSlice []*inode.MetadataStruct, errs []error) {
+	metadataSlice = make([]*inode.MetadataStruct, len(inodeNumbers))
+	errs = make([]error, len(inodeNumbers))
+
+	generations := make([]uint64, len(inodeNumbers))
+	missIndices := make([]int, 0, len(inodeNumbers))
+	missInodeNumbers := make([]inode.InodeNumber, 0, len(inodeNumbers))
+
+	for i, inodeNumber := range inodeNumbers {
+		metadata, ok, generation := aH.cache.fetch(inodeNumber)
+		if ok {
+			metadataSlice[i] = metadata
+			stats.IncrementOperations(&stats.FsAttrCacheHitOps)
+			continue
+		}
+		generations[i] = generation
+		missIndices = append(missIndices, i)
+		missInodeNumbers = append(missInodeNumbers, inodeNumber)
+	}
+
+	if 0 == len(missIndices) {
+		return
+	}
+
+	missMetadataSlice, missErrs := aH.VolumeHandle.GetMetadataMulti(missInodeNumbers)
+
+	for j, i := range missIndices {
+		metadataSlice[i], errs[i] = missMetadataSlice[j], missErrs[j]
+		if nil == errs[i] {
+			aH.cache.insert(inodeNumbers[i], metadataSlice[i], generations[i])
+		}
+		stats.IncrementOperations(&stats.FsAttrCacheMissOps)
+	}
+
+	return
+}
+
+// lookupQuietly returns the inode dirInodeNumber's entry basename names (if any) so that it may be
+// forgotten once the entry is changed.
+func (aH *attrCacheVolumeHandleStruct) lookupQuietly(dirInodeNumber inode.InodeNumber, basename string) (inodeNumbers []inode.InodeNumber) {
+	targetInodeNumber, err := aH.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil == err {
+		inodeNumbers = append(inodeNumbers, targetInodeNumber)
+	}
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Purge(inodeNumber inode.InodeNumber) (err error) {
+	err = aH.VolumeHandle.Purge(inodeNumber)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Destroy(inodeNumber inode.InodeNumber) (err error) {
+	err = aH.VolumeHandle.Destroy(inodeNumber)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetLinkCount(inodeNumber inode.InodeNumber, linkCount uint64) (err error) {
+	err = aH.VolumeHandle.SetLinkCount(inodeNumber, linkCount)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetCreationTime(inodeNumber inode.InodeNumber, creationTime time.Time) (err error) {
+	err = aH.VolumeHandle.SetCreationTime(inodeNumber, creationTime)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetModificationTime(inodeNumber inode.InodeNumber, modificationTime time.Time) (err error) {
+	err = aH.VolumeHandle.SetModificationTime(inodeNumber, modificationTime)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetAccessTime(inodeNumber inode.InodeNumber, accessTime time.Time) (err error) {
+	err = aH.VolumeHandle.SetAccessTime(inodeNumber, accessTime)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) UpdateAccessTime(inodeNumber inode.InodeNumber, accessTime time.Time) (err error) {
+	err = aH.VolumeHandle.UpdateAccessTime(inodeNumber, accessTime)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetPermMode(inodeNumber inode.InodeNumber, filePerm inode.InodeMode) (err error) {
+	err = aH.VolumeHandle.SetPermMode(inodeNumber, filePerm)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetFlags(inodeNumber inode.InodeNumber, flags inode.InodeFlags) (err error) {
+	err = aH.VolumeHandle.SetFlags(inodeNumber, flags)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetNFS4ACL(inodeNumber inode.InodeNumber, acl inode.NFS4ACL) (err error) {
+	err = aH.VolumeHandle.SetNFS4ACL(inodeNumber, acl)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetOwnerUserID(inodeNumber inode.InodeNumber, userID inode.InodeUserID) (err error) {
+	err = aH.VolumeHandle.SetOwnerUserID(inodeNumber, userID)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetOwnerUserIDGroupID(inodeNumber inode.InodeNumber, userID inode.InodeUserID, groupID inode.InodeGroupID) (err error) {
+	err = aH.VolumeHandle.SetOwnerUserIDGroupID(inodeNumber, userID, groupID)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetOwnerGroupID(inodeNumber inode.InodeNumber, groupID inode.InodeGroupID) (err error) {
+	err = aH.VolumeHandle.SetOwnerGroupID(inodeNumber, groupID)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetAttrs(inodeNumber inode.InodeNumber, attrs *inode.SetAttrsStruct) (err error) {
+	err = aH.VolumeHandle.SetAttrs(inodeNumber, attrs)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) PutStream(inodeNumber inode.InodeNumber, inodeStreamName string, buf []byte) (err error) {
+	err = aH.VolumeHandle.PutStream(inodeNumber, inodeStreamName, buf)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) UpdateStream(inodeNumber inode.InodeNumber, inodeStreamName string, buf []byte) (err error) {
+	err = aH.VolumeHandle.UpdateStream(inodeNumber, inodeStreamName, buf)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) DeleteStream(inodeNumber inode.InodeNumber, inodeStreamName string) (err error) {
+	err = aH.VolumeHandle.DeleteStream(inodeNumber, inodeStreamName)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) WriteStream(inodeNumber inode.InodeNumber, inodeStreamName string, offset uint64, buf []byte) (err error) {
+	err = aH.VolumeHandle.WriteStream(inodeNumber, inodeStreamName, offset, buf)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) ResizeStream(inodeNumber inode.InodeNumber, inodeStreamName string, newSize uint64) (err error) {
+	err = aH.VolumeHandle.ResizeStream(inodeNumber, inodeStreamName, newSize)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Optimize(inodeNumber inode.InodeNumber, maxDuration time.Duration) (err error) {
+	err = aH.VolumeHandle.Optimize(inodeNumber, maxDuration)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Adopt(inodeNumber inode.InodeNumber, logSegmentNumbers []uint64) (err error) {
+	err = aH.VolumeHandle.Adopt(inodeNumber, logSegmentNumbers)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Link(dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error) {
+	err = aH.VolumeHandle.Link(dirInodeNumber, basename, targetInodeNumber)
+	aH.cache.forgetInodes(dirInodeNumber, targetInodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Unlink(dirInodeNumber inode.InodeNumber, basename string) (err error) {
+	inodeNumbers := append(aH.lookupQuietly(dirInodeNumber, basename), dirInodeNumber)
+	err = aH.VolumeHandle.Unlink(dirInodeNumber, basename)
+	aH.cache.forgetInodes(inodeNumbers...)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) UnlinkGeneration(dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber, targetGeneration uint64) (unlinked bool, err error) {
+	unlinked, err = aH.VolumeHandle.UnlinkGeneration(dirInodeNumber, basename, targetInodeNumber, targetGeneration)
+	aH.cache.forgetInodes(dirInodeNumber, targetInodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) DestroyGeneration(inodeNumber inode.InodeNumber, generation uint64) (destroyed bool, err error) {
+	destroyed, err = aH.VolumeHandle.DestroyGeneration(inodeNumber, generation)
+	aH.cache.forgetInodes(inodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Move(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags) (err error) {
+	inodeNumbers := append(aH.lookupQuietly(srcDirInodeNumber, srcBasename), aH.lookupQuietly(dstDirInodeNumber, dstBasename)...)
+	inodeNumbers = append(inodeNumbers, srcDirInodeNumber, dstDirInodeNumber)
+	err = aH.VolumeHandle.Move(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags)
+	aH.cache.forgetInodes(inodeNumbers...)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Write(fileInodeNumber inode.InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (err error) {
+	err = aH.VolumeHandle.Write(fileInodeNumber, offset, buf, profiler)
+	aH.cache.forgetInodes(fileInodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Writev(fileInodeNumber inode.InodeNumber, segments []inode.WriteSegment, profiler *utils.Profiler) (err error) {
+	err = aH.VolumeHandle.Writev(fileInodeNumber, segments, profiler)
+	aH.cache.forgetInodes(fileInodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Wrote(fileInodeNumber inode.InodeNumber, fileOffset uint64, objectPath string, objectOffset uint64, length uint64, patchOnly bool) (err error) {
+	err = aH.VolumeHandle.Wrote(fileInodeNumber, fileOffset, objectPath, objectOffset, length, patchOnly)
+	aH.cache.forgetInodes(fileInodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) SetSize(fileInodeNumber inode.InodeNumber, size uint64) (err error) {
+	err = aH.VolumeHandle.SetSize(fileInodeNumber, size)
+	aH.cache.forgetInodes(fileInodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Flush(fileInodeNumber inode.InodeNumber, andPurge bool) (err error) {
+	err = aH.VolumeHandle.Flush(fileInodeNumber, andPurge)
+	aH.cache.forgetInodes(fileInodeNumber)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) Coalesce(containingDirInode inode.InodeNumber, combinationName string, elements []inode.CoalesceElement) (combinationInodeNumber inode.InodeNumber, modificationTime time.Time, numWrites uint64, err error) {
+	inodeNumbers := make([]inode.InodeNumber, 0, 2+2*len(elements))
+	inodeNumbers = append(inodeNumbers, aH.lookupQuietly(containingDirInode, combinationName)...)
+	inodeNumbers = append(inodeNumbers, containingDirInode)
+	for _, element := range elements {
+		inodeNumbers = append(inodeNumbers, element.ContainingDirectoryInodeNumber, element.ElementInodeNumber)
+	}
+
+	combinationInodeNumber, modificationTime, numWrites, err = aH.VolumeHandle.Coalesce(containingDirInode, combinationName, elements)
+
+	aH.cache.forgetInodes(append(inodeNumbers, combinationInodeNumber)...)
+	return
+}
+
+func (aH *attrCacheVolumeHandleStruct) CloneFile(fileInodeNumber inode.InodeNumber) (cloneInodeNumber inode.InodeNumber, err error) {
+	cloneInodeNumber, err = aH.VolumeHandle.CloneFile(fileInodeNumber)
+	aH.cache.forgetInodes(fileInodeNumber)
+	return
+}
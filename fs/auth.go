@@ -0,0 +1,243 @@
+package fs
+
+// Mount authentication
+//
+// Mount() and MountWithIDMap() serve in-process callers (e.g. FUSE and the Swift middleware) and are
+// trusted: each such mount is granted the MountAuthLocal root identity. A MountHandle obtained on
+// behalf of a remote client (e.g. via jrpcfs RpcMount) should instead come from MountAuthenticated(),
+// which admits the client only if its MountCredentialsStruct satisfies the volume's export policy:
+//
+//   [<volume-section>]MountAllowedClients, if set, lists the client addresses (IPs and/or CIDRs)
+//   that may mount the volume.
+//
+//   [<volume-section>]MountAuthMethod selects the credentials required: "none" (the default),
+//   "secret" (MountCredentialsStruct.Secret must match [<volume-section>]MountSecret), "token" (e.g.
+//   a Keystone token), or "certificate" (a client certificate). Tokens and certificates are verified
+//   by the MountAuthenticator registered for the method via RegisterMountAuthenticator(), which
+//   returns the principal (e.g. Keystone user or certificate subject) and identity to grant. Absent
+//   one, such mounts are refused.
+//
+//   [<volume-section>]MountAllowedPrincipals, if set, lists the principals that may mount the volume
+//   (so applies only to "token" and "certificate").
+//
+// A refused mount fails with PermDeniedError (EACCES). Mounts authenticated via "none" or "secret"
+// are granted the identity [<volume-section>]MountUserID & MountGroupID (defaulting to root). The
+// identity granted is recorded on the mount and returned by its Identity().
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type exportPolicyStruct struct {
+	authMethod        MountAuthMethod     // [<volume-section>]MountAuthMethod
+	secret            string              // [<volume-section>]MountSecret
+	allowedClients    []*net.IPNet        // [<volume-section>]MountAllowedClients (empty == any client)
+	allowedPrincipals map[string]struct{} // [<volume-section>]MountAllowedPrincipals (empty == any principal)
+	userID            inode.InodeUserID   // [<volume-section>]MountUserID
+	groupID           inode.InodeGroupID  // [<volume-section>]MountGroupID
+}
+
+func parseMountAuthMethod(methodAsString string) (method MountAuthMethod, err error) {
+	switch methodAsString {
+	case "none":
+		method = MountAuthNone
+	case "secret":
+		method = MountAuthSecret
+	case "token":
+		method = MountAuthToken
+	case "certificate":
+		method = MountAuthCertificate
+	default:
+		err = fmt.Errorf("MountAuthMethod must be one of \"none\", \"secret\", \"token\", or \"certificate\" (not \"%s\")", methodAsString)
+	}
+	return
+}
+
+// fetchExportPolicy loads the export policy settings from [<volume-section>].
+func fetchExportPolicy(confMap conf.ConfMap, volumeSectionName string) (exportPolicy exportPolicyStruct, err error) {
+	authMethodAsString, err := confMap.FetchOptionValueString(volumeSectionName, "MountAuthMethod")
+	if nil != err {
+		authMethodAsString = "none"
+	}
+	exportPolicy.authMethod, err = parseMountAuthMethod(authMethodAsString)
+	if nil != err {
+		return
+	}
+
+	exportPolicy.secret, err = confMap.FetchOptionValueString(volumeSectionName, "MountSecret")
+	if nil != err {
+		exportPolicy.secret = ""
+	}
+	if (MountAuthSecret == exportPolicy.authMethod) && ("" == exportPolicy.secret) {
+		err = fmt.Errorf("%s.MountSecret must be set if MountAuthMethod is \"secret\"", volumeSectionName)
+		return
+	}
+
+	allowedClients, err := confMap.FetchOptionValueStringSlice(volumeSectionName, "MountAllowedClients")
+	if nil != err {
+		allowedClients = []string{}
+	}
+	exportPolicy.allowedClients = make([]*net.IPNet, 0, len(allowedClients))
+	for _, allowedClient := range allowedClients {
+		_, allowedNet, parseErr := net.ParseCIDR(allowedClient)
+		if nil != parseErr {
+			allowedIP := net.ParseIP(allowedClient)
+			if nil == allowedIP {
+				err = fmt.Errorf("%s.MountAllowedClients entry \"%s\" is neither an IP address nor a CIDR", volumeSectionName, allowedClient)
+				return
+			}
+			if nil != allowedIP.To4() {
+				allowedIP = allowedIP.To4()
+			}
+			allowedNet = &net.IPNet{IP: allowedIP, Mask: net.CIDRMask(8*len(allowedIP), 8*len(allowedIP))}
+		}
+		exportPolicy.allowedClients = append(exportPolicy.allowedClients, allowedNet)
+	}
+
+	allowedPrincipals, err := confMap.FetchOptionValueStringSlice(volumeSectionName, "MountAllowedPrincipals")
+	if nil != err {
+		allowedPrincipals = []string{}
+	}
+	if (0 != len(allowedPrincipals)) && ((MountAuthToken != exportPolicy.authMethod) && (MountAuthCertificate != exportPolicy.authMethod)) {
+		err = fmt.Errorf("%s.MountAllowedPrincipals requires MountAuthMethod \"token\" or \"certificate\"", volumeSectionName)
+		return
+	}
+	exportPolicy.allowedPrincipals = make(map[string]struct{})
+	for _, allowedPrincipal := range allowedPrincipals {
+		exportPolicy.allowedPrincipals[allowedPrincipal] = struct{}{}
+	}
+
+	userID, err := confMap.FetchOptionValueUint32(volumeSectionName, "MountUserID")
+	if nil != err {
+		userID = uint32(inode.InodeRootUserID)
+	}
+	exportPolicy.userID = inode.InodeUserID(userID)
+
+	groupID, err := confMap.FetchOptionValueUint32(volumeSectionName, "MountGroupID")
+	if nil != err {
+		groupID = uint32(inode.InodeRootGroupID)
+	}
+	exportPolicy.groupID = inode.InodeGroupID(groupID)
+
+	err = nil
+	return
+}
+
+// RegisterMountAuthenticator sets (or, if authenticator is nil, clears) the MountAuthenticator
+// verifying credentials presented via method (MountAuthToken or MountAuthCertificate).
+func RegisterMountAuthenticator(method MountAuthMethod, authenticator MountAuthenticator) (err error) {
+	if (MountAuthToken != method) && (MountAuthCertificate != method) {
+		err = blunder.NewError(blunder.InvalidArgError, "RegisterMountAuthenticator() only supports MountAuthToken & MountAuthCertificate (not %v)", method)
+		return
+	}
+
+	globals.Lock()
+	if nil == authenticator {
+		delete(globals.mountAuthenticators, method)
+	} else {
+		globals.mountAuthenticators[method] = authenticator
+	}
+	globals.Unlock()
+
+	return
+}
+
+func mountAuthenticated(volumeName string, mountOptions MountOptions, idMap *IDMapStruct, credentials *MountCredentialsStruct) (mountHandle MountHandle, err error) {
+	if nil == credentials {
+		err = blunder.NewError(blunder.InvalidArgError, "MountAuthenticated() requires credentials")
+		return
+	}
+	mountHandle, err = mount(volumeName, mountOptions, idMap, credentials)
+	return
+}
+
+// authenticateMount returns the identity vS's export policy grants credentials (nil == in-process caller).
+func (vS *volumeStruct) authenticateMount(credentials *MountCredentialsStruct) (identity MountIdentityStruct, err error) {
+	if nil == credentials {
+		identity = MountIdentityStruct{
+			Method:  MountAuthLocal,
+			UserID:  inode.InodeRootUserID,
+			GroupID: inode.InodeRootGroupID,
+		}
+		return
+	}
+
+	defer func() {
+		if nil != err {
+			stats.IncrementOperations(&stats.FsMountAuthDeniedOps)
+		}
+	}()
+
+	vS.Lock()
+	exportPolicy := vS.exportPolicy
+	vS.Unlock()
+
+	if 0 != len(exportPolicy.allowedClients) {
+		clientIP := net.ParseIP(credentials.ClientAddr)
+		if nil == clientIP {
+			err = blunder.NewError(blunder.PermDeniedError, "volume %s only admits clients at MountAllowedClients (client address \"%s\" unknown)", vS.volumeName, credentials.ClientAddr)
+			return
+		}
+		allowed := false
+		for _, allowedNet := range exportPolicy.allowedClients {
+			if allowedNet.Contains(clientIP) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			err = blunder.NewError(blunder.PermDeniedError, "volume %s does not admit client %s", vS.volumeName, clientIP)
+			return
+		}
+	}
+
+	switch exportPolicy.authMethod {
+	case MountAuthNone:
+		identity = MountIdentityStruct{Method: MountAuthNone, UserID: exportPolicy.userID, GroupID: exportPolicy.groupID}
+	case MountAuthSecret:
+		if (MountAuthSecret != credentials.Method) || (1 != subtle.ConstantTimeCompare([]byte(exportPolicy.secret), []byte(credentials.Secret))) {
+			err = blunder.NewError(blunder.PermDeniedError, "volume %s requires its MountSecret", vS.volumeName)
+			return
+		}
+		identity = MountIdentityStruct{Method: MountAuthSecret, UserID: exportPolicy.userID, GroupID: exportPolicy.groupID}
+	default: // MountAuthToken or MountAuthCertificate
+		if exportPolicy.authMethod != credentials.Method {
+			err = blunder.NewError(blunder.PermDeniedError, "volume %s requires credentials of its MountAuthMethod", vS.volumeName)
+			return
+		}
+		globals.Lock()
+		authenticator, ok := globals.mountAuthenticators[exportPolicy.authMethod]
+		globals.Unlock()
+		if !ok {
+			err = blunder.NewError(blunder.PermDeniedError, "volume %s requires a MountAuthenticator for its MountAuthMethod but none is registered", vS.volumeName)
+			return
+		}
+		identity, err = authenticator(vS.volumeName, credentials)
+		if nil != err {
+			err = blunder.AddError(err, blunder.PermDeniedError)
+			return
+		}
+		identity.Method = exportPolicy.authMethod
+		if 0 != len(exportPolicy.allowedPrincipals) {
+			_, ok = exportPolicy.allowedPrincipals[identity.Principal]
+			if !ok {
+				err = blunder.NewError(blunder.PermDeniedError, "volume %s does not admit principal \"%s\"", vS.volumeName, identity.Principal)
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (mS *mountStruct) Identity() (identity MountIdentityStruct) {
+	identity = mS.identity
+	return
+}
@@ -0,0 +1,57 @@
+package fs
+
+// Coalesce manifests
+//
+// MiddlewareCoalesceElements() may be asked to leave, in the combined file's CoalesceManifestStream, a
+// manifest of the elements it was assembled from. Modeled on a Swift Static Large Object manifest (as
+// returned by a GET with ?multipart-manifest=get), it is a JSON array with, for each element (in order):
+//
+//   name   the element's path (e.g. "/container/object") prior to its consumption
+//   bytes  the number of bytes the element contributed to the combined file
+//   range  the byte range ("first-last") of the element contributed, present only if not all of it
+//
+// As the manifest resides in the "system." namespace, it may be read via GetXAttr() by any caller able to
+// read the file, but only modified by its owner.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+// CoalesceManifestStream is the stream of a combined file holding the manifest of its elements.
+const CoalesceManifestStream = XAttrSystemPrefix + "proxyfs.coalesce_manifest"
+
+type coalesceManifestSegmentStruct struct {
+	Name  string `json:"name"`
+	Bytes uint64 `json:"bytes"`
+	Range string `json:"range,omitempty"`
+}
+
+// makeCoalesceManifest returns the manifest of the (resolved) coalesceElements named by elements. Caller must
+// hold each element's lock.
+func (vS *volumeStruct) makeCoalesceManifest(elements []CoalesceElementSpec, coalesceElements []inode.CoalesceElement) (manifest []byte, err error) {
+	segments := make([]coalesceManifestSegmentStruct, len(elements))
+
+	for i, coalesceElement := range coalesceElements {
+		metadata, metadataErr := vS.VolumeHandle.GetMetadata(coalesceElement.ElementInodeNumber)
+		if nil != metadataErr {
+			err = metadataErr
+			return
+		}
+
+		segments[i].Name = "/" + strings.TrimLeft(elements[i].Path, "/")
+		segments[i].Bytes = coalesceElement.Length
+		if (0 == segments[i].Bytes) && (coalesceElement.Offset < metadata.Size) {
+			segments[i].Bytes = metadata.Size - coalesceElement.Offset
+		}
+		if (0 < segments[i].Bytes) && (segments[i].Bytes < metadata.Size) {
+			segments[i].Range = fmt.Sprintf("%d-%d", coalesceElement.Offset, coalesceElement.Offset+segments[i].Bytes-1)
+		}
+	}
+
+	manifest, err = json.Marshal(segments)
+	return
+}
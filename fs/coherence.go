@@ -0,0 +1,118 @@
+package fs
+
+// Cross-protocol coherence
+//
+// A mount's clients may cache what a lease permits (see lease.go). So that they don't go on serving
+// stale data once a file is changed via another mount or protocol (e.g. FUSE or the Swift middleware,
+// neither of which request leases), each access first breaks the conflicting leases held by other
+// mounts: a read breaks any LeaseWrite to LeaseRead (so that cached writes are written back before being
+// read) while a modification breaks every lease to LeaseNone. As with AcquireLease(), the access waits
+// (for up to [<volume-section>]LeaseBreakTimeout) for the holders to comply. This is done before the
+// access obtains any inode lock as a holder will likely need it to write back what it has cached.
+//
+// Operations naming the file (e.g. Unlink(), Rename(), and the Middleware...() operations) resolve the
+// name for this purpose ahead of locking. Should a racing change leave it naming a different file, the
+// NotifyEvent the operation posts remains to invalidate FUSE kernel caches (see fuse/notify.go) and
+// inform jrpcfs watchers (see jrpcfs/notify.go).
+//
+// Once a file is destroyed (e.g. as its last link is removed or an HTTP PUT replaces it), its cached
+// attributes (see attr_cache.go) are forgotten and any byte-range locks and leases still held on it are
+// dropped, waking any Read() or Write() awaiting a mandatory lock (see mandatory_lock.go) and telling each
+// lease holder to discard what it has cached.
+
+import (
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// breakLeasesForAccess breaks the leases held by mounts other than mountID that conflict with reading (or,
+// if modifying, changing) inodeNumber, waiting for them to be downgraded. Caller must hold no inode locks.
+func (vS *volumeStruct) breakLeasesForAccess(mountID MountID, inodeNumber inode.InodeNumber, modifying bool) {
+	var (
+		breakTo   LeaseType
+		leaseType LeaseType
+	)
+
+	vS.leases.Lock()
+	conflicts := 0 < len(vS.leases.inodeLeaseMap[inodeNumber])
+	vS.leases.Unlock()
+
+	if !conflicts {
+		return
+	}
+
+	if modifying {
+		leaseType = LeaseWrite
+		breakTo = LeaseNone
+	} else {
+		leaseType = LeaseRead
+		breakTo = LeaseRead
+	}
+
+	vS.breakLeasesAndLock(mountID, inodeNumber, leaseType, breakTo, "fs.breakLeasesForAccess()")
+	vS.leases.Unlock()
+
+	stats.IncrementOperations(&stats.FsCoherenceBreakOps)
+}
+
+// breakLeasesOfName is breakLeasesForAccess() of dirInodeNumber's entry basename (if any).
+func (vS *volumeStruct) breakLeasesOfName(mountID MountID, dirInodeNumber inode.InodeNumber, basename string, modifying bool) {
+	dirInodeLock, err := vS.getReadLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	if !vS.VolumeHandle.Access(dirInodeNumber, inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.F_OK) {
+		dirInodeLock.Unlock()
+		return
+	}
+	inodeNumber, err := vS.VolumeHandle.Lookup(dirInodeNumber, basename)
+	dirInodeLock.Unlock()
+	if nil != err {
+		return // nothing to break
+	}
+
+	vS.breakLeasesForAccess(mountID, inodeNumber, modifying)
+}
+
+// breakLeasesOfPath is breakLeasesForAccess() of the file fullpath (if any) resolves to.
+func (mS *mountStruct) breakLeasesOfPath(fullpath string, modifying bool) {
+	inodeNumber, err := mS.lookupPathAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, fullpath)
+	if nil != err {
+		return // nothing to break
+	}
+
+	mS.volStruct.breakLeasesForAccess(mS.id, inodeNumber, modifying)
+}
+
+// forgetInodeIfDestroyed drops the cached attributes of, and byte-range locks and leases held on, inodeNumber
+// should it no longer exist.
+func (vS *volumeStruct) forgetInodeIfDestroyed(inodeNumber inode.InodeNumber) {
+	if vS.VolumeHandle.Access(inodeNumber, inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.F_OK) {
+		return
+	}
+
+	vS.attrCache.forgetInodes(inodeNumber) // see attr_cache.go
+
+	vS.Lock()
+	flockList, hadFlocks := vS.FLockMap[inodeNumber]
+	hadFlocks = hadFlocks && (0 < flockList.Len())
+	delete(vS.FLockMap, inodeNumber)
+	vS.Unlock()
+
+	if hadFlocks {
+		vS.scheduleVolumeStateExport()
+		vS.noteFlockChange()
+	}
+
+	vS.leases.Lock()
+	hadLeases := 0 < len(vS.leases.inodeLeaseMap[inodeNumber])
+	for _, lease := range vS.leases.inodeLeaseMap[inodeNumber] {
+		lease.notifyBreak(LeaseNone)
+		vS.leases.downgradeWhileLocked(lease, LeaseNone)
+	}
+	vS.leases.Unlock()
+
+	if hadFlocks || hadLeases {
+		stats.IncrementOperations(&stats.FsCoherenceDropOps)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/dlm"
 	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/logger"
 	"github.com/swiftstack/ProxyFS/swiftclient"
@@ -28,16 +29,67 @@ const inFlightFileInodeDataControlBuffering = 100
 type mountStruct struct {
 	id        MountID
 	options   MountOptions
+	idMap     *IDMapStruct // nil == caller identities used as presented
 	volStruct *volumeStruct
+	identity  MountIdentityStruct // see auth.go
+	gate      mountGateStruct     // see unmount.go
+	opStats   opStatsStruct       // see op_stats.go
+	umask     uint32              // see umask.go (accessed atomically)
 }
 
 type volumeStruct struct {
 	sync.Mutex
 	volumeName               string
 	maxFlushTime             time.Duration
+	checkpointByteRangeLocks bool                                      // [<volume-section>]CheckpointByteRangeLocks
+	volumeStateExportTimer   *time.Timer                               // Non-nil while an exportVolumeState() is scheduled
+	retentionExportTimer     *time.Timer                               // Non-nil while the retention clock is periodically exported (see volume_state.go)
+	restoredFlockOwnerMap    map[inode.InodeNumber]map[uint64]struct{} // Pids whose restored byte-range locks await reclaiming (see volume_state.go)
+	flockReclaimTimer        *time.Timer                               // Non-nil while restored byte-range locks await reclaiming
+	quotaBytes               uint64                                    // [<volume-section>]QuotaBytes  (0 == no quota configured)
+	quotaInodes              uint64                                    // [<volume-section>]QuotaInodes (0 == no quota configured)
+	usageCacheTTL            time.Duration                             // [<volume-section>]UsageCacheTTL
+	replaceFenceMode         replaceFenceModeType                      // [<volume-section>]ReplaceFenceMode
+	replaceFenceMap          map[inode.InodeNumber]*replaceFenceStruct // see fence.go
+	mandatoryLockMode        mandatoryLockModeType                     // [<volume-section>]MandatoryByteRangeLocks
+	flockChangedC            chan struct{}                             // closed (and replaced) whenever a byte-range lock changes (see mandatory_lock.go)
+	orphanMap                map[inode.InodeNumber]struct{}            // unnamed inodes (see orphan.go)
+	segmentCheck             bool                                      // [<volume-section>]GetObjectSegmentCheck
+	segmentCheckCacheTTL     time.Duration                             // [<volume-section>]GetObjectSegmentCheckCacheTTL
+	segmentCheckCache        map[string]time.Time                      // key == ReadPlanStep.ObjectPath; value == time last verified to exist
+	dirLockShards            uint64                                    // [<volume-section>]DirLockShards (0 == directory entries not sharded; see locker.go)
+	readdirPlusParallelism   uint64                                    // [<volume-section>]ReaddirPlusParallelism (see readdir_plus.go)
+	contentTypeDetection     contentTypeDetectionType                  // [<volume-section>]ContentTypeDetection (see content_type.go)
+	symlinkPolicy            symlinkPolicyStruct                       // see symlink_policy.go
+	limits                   LimitsStruct                              // see limits.go
+	usageCache               *volumeUsageStruct
 	FLockMap                 map[inode.InodeNumber]*list.List
 	inFlightFileInodeDataMap map[inode.InodeNumber]*inFlightFileInodeDataStruct
 	mountList                []MountID
+	notify                   notifyStruct            // see notify.go
+	leaseBreakTimeout        time.Duration           // [<volume-section>]LeaseBreakTimeout
+	leases                   leaseManagerStruct      // see lease.go
+	containerFreezes         containerFreezesStruct  // see freeze.go
+	renameLock               sync.RWMutex            // see freeze.go
+	intents                  intentJournalStruct     // see intent.go
+	handles                  handleTableStruct       // see handle.go
+	history                  historyTableStruct      // see history.go
+	lockRetry                lockRetryStruct         // see retry.go
+	heavyOps                 heavyOpLimiterStruct    // see heavy_ops.go
+	treeDescentLimits        treeDescentLimitsStruct // see descend.go
+	listingCache             listingCacheStruct      // see listing_cache.go
+	exportPolicy             exportPolicyStruct      // see auth.go
+	adopt                    adoptStruct             // see adopt.go
+	nameRules                *nameRulesStruct        // see names.go
+	middlewareUmask          inode.InodeMode         // [<volume-section>]MiddlewareUmask (see umask.go)
+	usageTrend               usageTrendStruct        // see trend.go
+	opStats                  opStatsStruct           // see op_stats.go
+	etag                     etagStruct              // see etag.go
+	trash                    trashStruct             // see trash.go
+	versions                 versionsStruct          // see version.go
+	dentryCache              dentryCacheStruct       // see dentry_cache.go
+	attrCache                attrCacheStruct         // see attr_cache.go
+	retentionClock           retentionClockStruct    // see retention.go
 	inode.VolumeHandle
 }
 
@@ -47,11 +99,396 @@ type globalsStruct struct {
 	volumeMap                 map[string]*volumeStruct
 	mountMap                  map[MountID]*mountStruct
 	lastMountID               MountID
+	lastWatchID               WatchID
+	lastLeaseID               LeaseID
+	lastFreezeID              FreezeID                               // see freeze.go
+	lastFileHandle            FileHandle                             // see handle.go
+	usageAlertHandlers        []UsageAlertHandler                    // see trend.go
+	shutdownDrainTimeout      time.Duration                          // [FSGlobals]ShutdownDrainTimeout
+	shuttingDown              bool                                   // see shutdown.go
+	mountAuthenticators       map[MountAuthMethod]MountAuthenticator // see auth.go
 	inFlightFileInodeDataList *list.List
 }
 
 var globals globalsStruct
 
+// fetchVolumeOptions loads the optional per-volume settings from [<volume-section>].
+//
+// Each of these has a default that applies when the option is missing so that
+// existing .conf files continue to work unchanged.
+func (volume *volumeStruct) fetchVolumeOptions(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	volume.checkpointByteRangeLocks, err = confMap.FetchOptionValueBool(volumeSectionName, "CheckpointByteRangeLocks")
+	if nil != err {
+		volume.checkpointByteRangeLocks = false
+	}
+
+	volume.quotaBytes, err = confMap.FetchOptionValueUint64(volumeSectionName, "QuotaBytes")
+	if nil != err {
+		volume.quotaBytes = 0
+	}
+
+	volume.quotaInodes, err = confMap.FetchOptionValueUint64(volumeSectionName, "QuotaInodes")
+	if nil != err {
+		volume.quotaInodes = 0
+	}
+
+	volume.usageCacheTTL, err = confMap.FetchOptionValueDuration(volumeSectionName, "UsageCacheTTL")
+	if nil != err {
+		volume.usageCacheTTL = defaultUsageCacheTTL
+	}
+
+	replaceFenceModeAsString, err := confMap.FetchOptionValueString(volumeSectionName, "ReplaceFenceMode")
+	if nil != err {
+		replaceFenceModeAsString = "block"
+	}
+	replaceFenceMode, err := parseReplaceFenceMode(replaceFenceModeAsString)
+	if nil != err {
+		return
+	}
+
+	mandatoryLockModeAsString, err := confMap.FetchOptionValueString(volumeSectionName, "MandatoryByteRangeLocks")
+	if nil != err {
+		mandatoryLockModeAsString = "none"
+	}
+	mandatoryLockMode, err := parseMandatoryLockMode(mandatoryLockModeAsString)
+	if nil != err {
+		return
+	}
+
+	segmentCheck, err := confMap.FetchOptionValueBool(volumeSectionName, "GetObjectSegmentCheck")
+	if nil != err {
+		segmentCheck = false
+	}
+
+	segmentCheckCacheTTL, err := confMap.FetchOptionValueDuration(volumeSectionName, "GetObjectSegmentCheckCacheTTL")
+	if nil != err {
+		segmentCheckCacheTTL = defaultSegmentCheckCacheTTL
+	}
+
+	listingCacheMaxStaleness, err := confMap.FetchOptionValueDuration(volumeSectionName, "ListingCacheMaxStaleness")
+	if nil != err {
+		listingCacheMaxStaleness = defaultListingCacheMaxStaleness
+	}
+
+	dirLockShards, err := confMap.FetchOptionValueUint64(volumeSectionName, "DirLockShards")
+	if nil != err {
+		dirLockShards = 0
+	}
+
+	maxEntriesPerOperation, err := confMap.FetchOptionValueUint64(volumeSectionName, "MaxEntriesPerOperation")
+	if nil != err {
+		maxEntriesPerOperation = defaultMaxEntriesPerOperation
+	}
+	if 0 == maxEntriesPerOperation {
+		err = fmt.Errorf("%s.MaxEntriesPerOperation must be non-zero", volumeSectionName)
+		return
+	}
+
+	maxBytesPerOperation, err := confMap.FetchOptionValueUint64(volumeSectionName, "MaxBytesPerOperation")
+	if nil != err {
+		maxBytesPerOperation = defaultMaxBytesPerOperation
+	}
+	if 0 == maxBytesPerOperation {
+		err = fmt.Errorf("%s.MaxBytesPerOperation must be non-zero", volumeSectionName)
+		return
+	}
+
+	xattrNameMax, err := confMap.FetchOptionValueUint64(volumeSectionName, "XAttrNameMax")
+	if nil != err {
+		xattrNameMax = defaultXAttrNameMax
+	}
+	if 0 == xattrNameMax {
+		err = fmt.Errorf("%s.XAttrNameMax must be non-zero", volumeSectionName)
+		return
+	}
+
+	xattrValueMax, err := confMap.FetchOptionValueUint64(volumeSectionName, "XAttrValueMax")
+	if nil != err {
+		xattrValueMax = defaultXAttrValueMax
+	}
+	if 0 == xattrValueMax {
+		err = fmt.Errorf("%s.XAttrValueMax must be non-zero", volumeSectionName)
+		return
+	}
+
+	leaseBreakTimeout, err := confMap.FetchOptionValueDuration(volumeSectionName, "LeaseBreakTimeout")
+	if nil != err {
+		leaseBreakTimeout = defaultLeaseBreakTimeout
+	}
+
+	inodeHistoryDepth, err := confMap.FetchOptionValueUint64(volumeSectionName, "InodeHistoryDepth")
+	if nil != err {
+		inodeHistoryDepth = defaultInodeHistoryDepth
+	}
+
+	lockRetryLimit, err := confMap.FetchOptionValueUint64(volumeSectionName, "LockRetryLimit")
+	if nil != err {
+		lockRetryLimit = defaultLockRetryLimit
+	}
+
+	lockRetryDelay, err := confMap.FetchOptionValueDuration(volumeSectionName, "LockRetryDelay")
+	if nil != err {
+		lockRetryDelay = defaultLockRetryDelay
+	}
+
+	lockRetryMaxDelay, err := confMap.FetchOptionValueDuration(volumeSectionName, "LockRetryMaxDelay")
+	if nil != err {
+		lockRetryMaxDelay = defaultLockRetryMaxDelay
+	}
+	if lockRetryMaxDelay < lockRetryDelay {
+		err = fmt.Errorf("%s.LockRetryMaxDelay must not be less than LockRetryDelay", volumeSectionName)
+		return
+	}
+
+	lockRetryExpBackoff, err := confMap.FetchOptionValueFloat64(volumeSectionName, "LockRetryExpBackoff")
+	if nil != err {
+		lockRetryExpBackoff = defaultLockRetryExpBackoff
+	}
+	if 1.0 > lockRetryExpBackoff {
+		err = fmt.Errorf("%s.LockRetryExpBackoff must be at least 1.0", volumeSectionName)
+		return
+	}
+
+	lockFairnessPolicyAsString, err := confMap.FetchOptionValueString(volumeSectionName, "LockFairnessPolicy")
+	if nil != err {
+		lockFairnessPolicyAsString = "fifo"
+	}
+	lockFairnessPolicy, err := parseLockFairnessPolicy(lockFairnessPolicyAsString)
+	if nil != err {
+		return
+	}
+
+	heavyMiddlewareOpLimit, err := confMap.FetchOptionValueUint64(volumeSectionName, "HeavyMiddlewareOpLimit")
+	if nil != err {
+		heavyMiddlewareOpLimit = defaultHeavyMiddlewareOpLimit
+	}
+
+	heavyMiddlewareOpQueueDepth, err := confMap.FetchOptionValueUint64(volumeSectionName, "HeavyMiddlewareOpQueueDepth")
+	if nil != err {
+		heavyMiddlewareOpQueueDepth = defaultHeavyMiddlewareOpQueueDepth
+	}
+
+	heavyPutCompleteSegments, err := confMap.FetchOptionValueUint64(volumeSectionName, "HeavyPutCompleteSegments")
+	if nil != err {
+		heavyPutCompleteSegments = defaultHeavyPutCompleteSegments
+	}
+
+	maxTreeDescentDepth, err := confMap.FetchOptionValueUint64(volumeSectionName, "MaxTreeDescentDepth")
+	if nil != err {
+		maxTreeDescentDepth = defaultMaxTreeDescentDepth
+	}
+	if 0 == maxTreeDescentDepth {
+		err = fmt.Errorf("%s.MaxTreeDescentDepth must be at least 1", volumeSectionName)
+		return
+	}
+
+	maxTreeDescentPending, err := confMap.FetchOptionValueUint64(volumeSectionName, "MaxTreeDescentPending")
+	if nil != err {
+		maxTreeDescentPending = defaultMaxTreeDescentPending
+	}
+
+	exportPolicy, err := fetchExportPolicy(confMap, volumeSectionName)
+	if nil != err {
+		return
+	}
+
+	containerFreezeMaxTTL, err := confMap.FetchOptionValueDuration(volumeSectionName, "ContainerFreezeMaxTTL")
+	if nil != err {
+		containerFreezeMaxTTL = defaultContainerFreezeMaxTTL
+	}
+	if 0 == containerFreezeMaxTTL {
+		err = fmt.Errorf("%s.ContainerFreezeMaxTTL must be non-zero", volumeSectionName)
+		return
+	}
+
+	adoptMiddlewareObjects, err := confMap.FetchOptionValueBool(volumeSectionName, "AdoptMiddlewareObjects")
+	if nil != err {
+		adoptMiddlewareObjects = false
+	}
+
+	forbiddenNameCharacters, err := confMap.FetchOptionValueString(volumeSectionName, "ForbiddenNameCharacters")
+	if nil != err {
+		forbiddenNameCharacters = ""
+	}
+	nameRules, err := fetchNameRules(forbiddenNameCharacters, volumeSectionName)
+	if nil != err {
+		return
+	}
+
+	middlewareUmask, err := fetchMiddlewareUmask(confMap, volumeSectionName)
+	if nil != err {
+		return
+	}
+
+	usageSampleInterval, err := confMap.FetchOptionValueDuration(volumeSectionName, "UsageSampleInterval")
+	if nil != err {
+		usageSampleInterval = defaultUsageSampleInterval
+	}
+
+	usageSampleCount, err := confMap.FetchOptionValueUint64(volumeSectionName, "UsageSampleCount")
+	if nil != err {
+		usageSampleCount = defaultUsageSampleCount
+	}
+	if (0 != usageSampleInterval) && (0 == usageSampleCount) {
+		err = fmt.Errorf("%s.UsageSampleCount must be non-zero unless UsageSampleInterval is zero", volumeSectionName)
+		return
+	}
+
+	usageAlertPercent, err := confMap.FetchOptionValueUint64(volumeSectionName, "UsageAlertPercent")
+	if nil != err {
+		usageAlertPercent = defaultUsageAlertPercent
+	}
+	if 100 < usageAlertPercent {
+		err = fmt.Errorf("%s.UsageAlertPercent must not exceed 100", volumeSectionName)
+		return
+	}
+
+	usageAlertHorizon, err := confMap.FetchOptionValueDuration(volumeSectionName, "UsageAlertHorizon")
+	if nil != err {
+		usageAlertHorizon = defaultUsageAlertHorizon
+	}
+
+	usageAlertWebhook, err := confMap.FetchOptionValueString(volumeSectionName, "UsageAlertWebhook")
+	if nil != err {
+		usageAlertWebhook = ""
+	}
+
+	etagAlgorithmAsString, err := confMap.FetchOptionValueString(volumeSectionName, "ETagAlgorithm")
+	if nil != err {
+		etagAlgorithmAsString = defaultETagAlgorithm
+	}
+	etagAlgorithm, err := parseETagAlgorithm(etagAlgorithmAsString)
+	if nil != err {
+		return
+	}
+
+	etagMaxComputeSize, err := confMap.FetchOptionValueUint64(volumeSectionName, "ETagMaxComputeSize")
+	if nil != err {
+		etagMaxComputeSize = defaultETagMaxComputeSize
+	}
+
+	trashEnabled, err := confMap.FetchOptionValueBool(volumeSectionName, "TrashEnabled")
+	if nil != err {
+		trashEnabled = false
+	}
+
+	trashRetention, err := confMap.FetchOptionValueDuration(volumeSectionName, "TrashRetention")
+	if nil != err {
+		trashRetention = defaultTrashRetention
+	}
+
+	trashPurgeInterval, err := confMap.FetchOptionValueDuration(volumeSectionName, "TrashPurgeInterval")
+	if nil != err {
+		trashPurgeInterval = defaultTrashPurgeInterval
+	}
+	if (0 != trashRetention) && (0 == trashPurgeInterval) {
+		err = fmt.Errorf("%s.TrashPurgeInterval must be non-zero unless TrashRetention is zero", volumeSectionName)
+		return
+	}
+
+	maxFileVersions, err := confMap.FetchOptionValueUint64(volumeSectionName, "MaxFileVersions")
+	if nil != err {
+		maxFileVersions = 0
+	}
+
+	fileVersionInterval, err := confMap.FetchOptionValueDuration(volumeSectionName, "FileVersionInterval")
+	if nil != err {
+		fileVersionInterval = defaultFileVersionInterval
+	}
+
+	dentryCacheMax, err := confMap.FetchOptionValueUint64(volumeSectionName, "DentryCacheMax")
+	if nil != err {
+		dentryCacheMax = defaultDentryCacheMax
+	}
+
+	attrCacheMax, err := confMap.FetchOptionValueUint64(volumeSectionName, "AttrCacheMax")
+	if nil != err {
+		attrCacheMax = defaultAttrCacheMax
+	}
+
+	readdirPlusParallelism, err := confMap.FetchOptionValueUint64(volumeSectionName, "ReaddirPlusParallelism")
+	if nil != err {
+		readdirPlusParallelism = defaultReaddirPlusParallelism
+	}
+
+	contentTypeDetectionAsString, err := confMap.FetchOptionValueString(volumeSectionName, "ContentTypeDetection")
+	if nil != err {
+		contentTypeDetectionAsString = "none"
+	}
+	contentTypeDetection, err := parseContentTypeDetection(contentTypeDetectionAsString)
+	if nil != err {
+		return
+	}
+
+	maxSymlinks, err := confMap.FetchOptionValueUint64(volumeSectionName, "MaxSymlinks")
+	if nil != err {
+		maxSymlinks = MaxSymlinks
+	}
+
+	confineAbsoluteSymlinks, err := confMap.FetchOptionValueBool(volumeSectionName, "ConfineAbsoluteSymlinks")
+	if nil != err {
+		confineAbsoluteSymlinks = false
+	}
+
+	mountPointName, err := confMap.FetchOptionValueString(volumeSectionName, "FUSEMountPointName")
+	if nil != err {
+		mountPointName = "" // not served via FUSE
+	}
+
+	middlewareFollowSymlinks, err := confMap.FetchOptionValueBool(volumeSectionName, "MiddlewareFollowSymlinks")
+	if nil != err {
+		middlewareFollowSymlinks = true
+	}
+
+	volume.Lock()
+	volume.replaceFenceMode = replaceFenceMode
+	volume.mandatoryLockMode = mandatoryLockMode
+	volume.segmentCheck = segmentCheck
+	volume.segmentCheckCacheTTL = segmentCheckCacheTTL
+	volume.listingCache.maxStaleness = listingCacheMaxStaleness
+	volume.dirLockShards = dirLockShards
+	volume.readdirPlusParallelism = readdirPlusParallelism
+	volume.contentTypeDetection = contentTypeDetection
+	volume.limits = fixedLimits()
+	volume.limits.XAttrNameMax = xattrNameMax
+	volume.limits.XAttrValueMax = xattrValueMax
+	volume.limits.MaxEntriesPerOperation = maxEntriesPerOperation
+	volume.limits.MaxBytesPerOperation = maxBytesPerOperation
+	volume.limits.MaxSymlinks = maxSymlinks
+	volume.leaseBreakTimeout = leaseBreakTimeout
+	volume.lockRetry = lockRetryStruct{
+		limit:      lockRetryLimit,
+		delay:      lockRetryDelay,
+		maxDelay:   lockRetryMaxDelay,
+		expBackoff: lockRetryExpBackoff,
+	}
+	volume.treeDescentLimits = treeDescentLimitsStruct{
+		maxDepth:   maxTreeDescentDepth,
+		maxPending: maxTreeDescentPending,
+	}
+	volume.exportPolicy = exportPolicy
+	volume.nameRules = nameRules
+	volume.middlewareUmask = middlewareUmask
+	volume.Unlock()
+
+	volume.configureHistory(inodeHistoryDepth)
+	volume.configureContainerFreezes(containerFreezeMaxTTL)
+	volume.configureHeavyOps(heavyMiddlewareOpLimit, heavyMiddlewareOpQueueDepth, heavyPutCompleteSegments)
+	volume.configureAdopt(adoptMiddlewareObjects)
+	volume.configureUsageTrend(usageSampleInterval, usageSampleCount, usageAlertPercent, usageAlertHorizon, usageAlertWebhook)
+	volume.configureETag(etagAlgorithm, etagMaxComputeSize)
+	volume.configureTrash(trashEnabled, trashRetention, trashPurgeInterval)
+	volume.configureVersions(maxFileVersions, fileVersionInterval)
+	volume.configureDentryCache(dentryCacheMax)
+	volume.configureAttrCache(attrCacheMax)
+	volume.configureSymlinkPolicy(confineAbsoluteSymlinks, mountPointName, middlewareFollowSymlinks)
+
+	dlm.SetDomainFairnessPolicy(volume.volumeName, lockFairnessPolicy)
+
+	err = nil
+	return
+}
+
 func Up(confMap conf.ConfMap) (err error) {
 	var (
 		flowControlName        string
@@ -75,6 +512,10 @@ func Up(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	fetchShutdownDrainTimeout(confMap)
+
+	globals.shuttingDown = false
+
 	globals.volumeMap = make(map[string]*volumeStruct)
 
 	for _, volumeName = range volumeList {
@@ -94,8 +535,21 @@ func Up(confMap conf.ConfMap) (err error) {
 					volumeName:               volumeName,
 					FLockMap:                 make(map[inode.InodeNumber]*list.List),
 					inFlightFileInodeDataMap: make(map[inode.InodeNumber]*inFlightFileInodeDataStruct),
+					replaceFenceMap:          make(map[inode.InodeNumber]*replaceFenceStruct),
+					flockChangedC:            make(chan struct{}),
+					orphanMap:                make(map[inode.InodeNumber]struct{}),
+					segmentCheckCache:        make(map[string]time.Time),
 					mountList:                make([]MountID, 0),
 				}
+				volume.notify.watchMap = make(map[WatchID]*watchStruct)
+				volume.initLeases()
+				volume.initContainerFreezes()
+				volume.initHandles()
+				volume.initHistory()
+				volume.initAdopt()
+				volume.initETag()
+				volume.initHeavyOps()
+				volume.initOpStats()
 
 				flowControlName, err = confMap.FetchOptionValueString(volumeSectionName, "FlowControl")
 				if nil != err {
@@ -112,6 +566,41 @@ func Up(confMap conf.ConfMap) (err error) {
 				if nil != err {
 					return
 				}
+				volume.VolumeHandle = volume.newAttrCacheVolumeHandle(volume.newDentryCacheVolumeHandle(volume.VolumeHandle))
+
+				err = volume.fetchVolumeOptions(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				err = volume.importVolumeState()
+				if nil != err {
+					return
+				}
+
+				err = volume.reapOrphans()
+				if nil != err {
+					return
+				}
+
+				err = volume.recoverIntents()
+				if nil != err {
+					return
+				}
+
+				err = volume.loadTrash()
+				if nil != err {
+					return
+				}
+
+				err = volume.loadVersions()
+				if nil != err {
+					return
+				}
+
+				volume.startUsageTrend()
+				volume.startHistory()
+				volume.startTrashPurger()
 
 				globals.volumeMap[volumeName] = volume
 			}
@@ -123,6 +612,7 @@ func Up(confMap conf.ConfMap) (err error) {
 
 	globals.mountMap = make(map[MountID]*mountStruct)
 	globals.lastMountID = MountID(0)
+	globals.mountAuthenticators = make(map[MountAuthMethod]MountAuthenticator)
 	globals.inFlightFileInodeDataList = list.New()
 
 	swiftclient.SetStarvationCallbackFunc(chunkedPutConnectionPoolStarvationCallback)
@@ -197,6 +687,21 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 			delete(globals.mountMap, id)
 		}
 		volume.untrackInFlightFileInodeDataAll()
+		volume.removeAllWatches()
+		volume.stopVolumeStateTimers()
+		err = volume.exportVolumeState()
+		if nil != err {
+			logger.ErrorfWithError(err, "fs.PauseAndContract() unable to export state of volume '%s'", volumeName)
+		}
+		volume.releaseAllLeases()
+		volume.closeAllHandles()
+		volume.stopHistory()
+		volume.stopUsageTrend()
+		volume.stopTrashPurger()
+		err = dlm.DropDomain(volumeName)
+		if nil != err {
+			logger.ErrorfWithError(err, "fs.PauseAndContract() unable to drop lock domain of volume '%s'", volumeName)
+		}
 		globals.Lock()
 		delete(globals.volumeMap, volumeName)
 		globals.Unlock()
@@ -235,6 +740,8 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	fetchShutdownDrainTimeout(confMap)
+
 	for _, volumeName = range volumeList {
 		volumeSectionName = utils.VolumeNameConfSection(volumeName)
 
@@ -254,8 +761,21 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 						volumeName:               volumeName,
 						FLockMap:                 make(map[inode.InodeNumber]*list.List),
 						inFlightFileInodeDataMap: make(map[inode.InodeNumber]*inFlightFileInodeDataStruct),
+						replaceFenceMap:          make(map[inode.InodeNumber]*replaceFenceStruct),
+						flockChangedC:            make(chan struct{}),
+						orphanMap:                make(map[inode.InodeNumber]struct{}),
+						segmentCheckCache:        make(map[string]time.Time),
 						mountList:                make([]MountID, 0),
 					}
+					volume.notify.watchMap = make(map[WatchID]*watchStruct)
+					volume.initLeases()
+					volume.initContainerFreezes()
+					volume.initHandles()
+					volume.initHistory()
+					volume.initAdopt()
+					volume.initETag()
+					volume.initHeavyOps()
+					volume.initOpStats()
 
 					flowControlName, err = confMap.FetchOptionValueString(volumeSectionName, "FlowControl")
 					if nil != err {
@@ -272,6 +792,41 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 					if nil != err {
 						return
 					}
+					volume.VolumeHandle = volume.newAttrCacheVolumeHandle(volume.newDentryCacheVolumeHandle(volume.VolumeHandle))
+
+					err = volume.fetchVolumeOptions(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					err = volume.importVolumeState()
+					if nil != err {
+						return
+					}
+
+					err = volume.reapOrphans()
+					if nil != err {
+						return
+					}
+
+					err = volume.recoverIntents()
+					if nil != err {
+						return
+					}
+
+					err = volume.loadTrash()
+					if nil != err {
+						return
+					}
+
+					err = volume.loadVersions()
+					if nil != err {
+						return
+					}
+
+					volume.startUsageTrend()
+					volume.startHistory()
+					volume.startTrashPurger()
 
 					globals.volumeMap[volumeName] = volume
 				}
@@ -297,6 +852,21 @@ func Down() (err error) {
 
 	for _, volume = range globals.volumeMap {
 		volume.untrackInFlightFileInodeDataAll()
+		volume.removeAllWatches()
+		volume.stopVolumeStateTimers()
+		err = volume.exportVolumeState()
+		if nil != err {
+			logger.ErrorfWithError(err, "fs.Down() unable to export state of volume '%s'", volume.volumeName)
+		}
+		volume.releaseAllLeases()
+		volume.closeAllHandles()
+		volume.stopHistory()
+		volume.stopUsageTrend()
+		volume.stopTrashPurger()
+		err = dlm.DropDomain(volume.volumeName)
+		if nil != err {
+			logger.ErrorfWithError(err, "fs.Down() unable to drop lock domain of volume '%s'", volume.volumeName)
+		}
 	}
 
 	if 0 < globals.inFlightFileInodeDataList.Len() {
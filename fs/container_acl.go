@@ -0,0 +1,260 @@
+package fs
+
+// Container ACLs
+//
+// Middleware operations otherwise act as InodeRootUserID, leaving authorization entirely to the Swift
+// proxy. MiddlewareSetContainerACL() records a ContainerACL in the container's reserved ContainerACLStream
+// so that ProxyFS itself can enforce per-container access: MiddlewareGetObjectAsCaller() requires that
+// the caller be admitted by the container's Read list, while MiddlewarePutCompleteAsCaller(),
+// MiddlewareDeleteAsCaller(), and MiddlewareDeleteMultiAsCaller() require that it be admitted by its Write
+// list. Deleting a container itself is evaluated against that container's Write list.
+//
+// A caller is admitted by a list containing "*", its Principal, or any of its Groups. Callers flagged as
+// Admin (e.g. the account's owner) and containers without an ACL are not restricted, nor are callers of
+// the original Middleware...() APIs (equivalently, a nil caller) as these act on behalf of ProxyFS itself.
+// Refused operations fail with PermDeniedError (EACCES).
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// ContainerACLStream is the reserved stream on a container's directory inode holding its ContainerACL.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const ContainerACLStream = "proxyfs.containeracl"
+
+const containerACLWildcard = "*"
+
+// admits reports whether aclEntries lists caller.
+func (caller *MiddlewareCallerStruct) admits(aclEntries []string) bool {
+	for _, aclEntry := range aclEntries {
+		if (containerACLWildcard == aclEntry) || (("" != caller.Principal) && (caller.Principal == aclEntry)) {
+			return true
+		}
+		for _, group := range caller.Groups {
+			if group == aclEntry {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupContainer returns the directory inode of vContainerName.
+func (mS *mountStruct) lookupContainer(vContainerName string) (containerInodeNumber inode.InodeNumber, err error) {
+	if ("" == vContainerName) || strings.Contains(vContainerName, "/") {
+		err = blunder.NewError(blunder.InvalidArgError, "\"%s\" is not a container", vContainerName)
+		return
+	}
+
+	containerInodeNumber, err = mS.volStruct.VolumeHandle.Lookup(inode.RootDirInodeNumber, vContainerName)
+	if nil != err {
+		return
+	}
+
+	inodeType, err := mS.volStruct.VolumeHandle.GetType(containerInodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.DirType != inodeType {
+		err = blunder.NewError(blunder.NotDirError, "\"%s\" is not a container", vContainerName)
+	}
+	return
+}
+
+// fetchContainerACL returns the ContainerACL of containerInodeNumber (nil if none). Caller must hold
+// (at least) a read lock on containerInodeNumber.
+func (vS *volumeStruct) fetchContainerACL(containerInodeNumber inode.InodeNumber) (acl *ContainerACL, err error) {
+	buf, err := vS.VolumeHandle.GetStream(containerInodeNumber, ContainerACLStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	acl = &ContainerACL{}
+	err = json.Unmarshal(buf, acl)
+	if nil != err {
+		acl = nil
+		err = blunder.NewError(blunder.CorruptInodeError, "ContainerACLStream of inode %v is corrupt: %v", containerInodeNumber, err)
+	}
+	return
+}
+
+// authorizeCaller fails with PermDeniedError unless vContainerName's ContainerACL admits caller to read
+// (or, if forWrite, to write) it. It is not itself an operation (see enterOp()), but a check preceding one.
+func (mS *mountStruct) authorizeCaller(caller *MiddlewareCallerStruct, vContainerName string, forWrite bool) (err error) {
+	if (nil == caller) || caller.Admin {
+		return
+	}
+
+	containerInodeNumber, err := mS.lookupContainer(vContainerName)
+	if nil != err {
+		return
+	}
+
+	containerInodeLock, err := mS.volStruct.initInodeLock(containerInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = containerInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer containerInodeLock.Unlock()
+
+	acl, err := mS.volStruct.fetchContainerACL(containerInodeNumber)
+	if (nil != err) || (nil == acl) {
+		return
+	}
+
+	if forWrite {
+		if !caller.admits(acl.Write) {
+			stats.IncrementOperations(&stats.FsMwContainerACLDeniedOps)
+			err = blunder.NewError(blunder.PermDeniedError, "\"%s\" may not write container \"%s\"", caller.Principal, vContainerName)
+		}
+	} else {
+		if !caller.admits(acl.Read) {
+			stats.IncrementOperations(&stats.FsMwContainerACLDeniedOps)
+			err = blunder.NewError(blunder.PermDeniedError, "\"%s\" may not read container \"%s\"", caller.Principal, vContainerName)
+		}
+	}
+
+	return
+}
+
+func (mS *mountStruct) MiddlewareGetContainerACL(vContainerName string) (acl *ContainerACL, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	containerInodeNumber, err := mS.lookupContainer(vContainerName)
+	if nil != err {
+		return
+	}
+
+	containerInodeLock, err := mS.volStruct.initInodeLock(containerInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = containerInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer containerInodeLock.Unlock()
+
+	acl, err = mS.volStruct.fetchContainerACL(containerInodeNumber)
+	return
+}
+
+func (mS *mountStruct) MiddlewareSetContainerACL(vContainerName string, acl *ContainerACL) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(vContainerName) // see freeze.go
+	defer exitContainers()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	if nil != acl {
+		for _, aclEntry := range append(append([]string{}, acl.Read...), acl.Write...) {
+			if "" == aclEntry {
+				err = blunder.NewError(blunder.InvalidArgError, "ContainerACL entries must be non-empty")
+				return
+			}
+		}
+	}
+
+	containerInodeNumber, err := mS.lookupContainer(vContainerName)
+	if nil != err {
+		return
+	}
+
+	containerInodeLock, err := mS.volStruct.initInodeLock(containerInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = containerInodeLock.WriteLock()
+	if nil != err {
+		return
+	}
+	defer containerInodeLock.Unlock()
+
+	if nil == acl {
+		err = mS.volStruct.VolumeHandle.DeleteStream(containerInodeNumber, ContainerACLStream)
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+	} else {
+		var buf []byte
+		buf, err = json.Marshal(acl)
+		if nil != err {
+			return
+		}
+		err = mS.volStruct.VolumeHandle.PutStream(containerInodeNumber, ContainerACLStream, buf)
+	}
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.notifyInode(NotifySetAttr, containerInodeNumber)
+
+	stats.IncrementOperations(&stats.FsMwSetContainerACLOps)
+	return
+}
+
+func (mS *mountStruct) MiddlewareGetObjectAsCaller(caller *MiddlewareCallerStruct, volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, etag string, contentType string, err error) {
+	err = mS.authorizeCaller(caller, strings.SplitN(containerObjectPath, "/", 2)[0], false)
+	if nil != err {
+		return
+	}
+
+	fileSize, lastModified, ino, numWrites, serializedMetadata, etag, contentType, err = mS.MiddlewareGetObject(volumeName, containerObjectPath, readRangeIn, readRangeOut)
+	return
+}
+
+func (mS *mountStruct) MiddlewarePutCompleteAsCaller(caller *MiddlewareCallerStruct, vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte, preconditions PutPreconditions) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	err = mS.authorizeCaller(caller, vContainerName, true)
+	if nil != err {
+		return
+	}
+
+	mtime, fileInodeNumber, numWrites, err = mS.MiddlewarePutCompleteConditional(vContainerName, vObjectPath, pObjectPaths, pObjectLengths, pObjectMetadata, preconditions)
+	return
+}
+
+func (mS *mountStruct) MiddlewareDeleteAsCaller(caller *MiddlewareCallerStruct, parentDir string, baseName string) (err error) {
+	vContainerName := strings.SplitN(strings.TrimPrefix(parentDir, "/"), "/", 2)[0]
+	if "" == vContainerName {
+		vContainerName = baseName // deleting the container itself
+	}
+
+	err = mS.authorizeCaller(caller, vContainerName, true)
+	if nil != err {
+		return
+	}
+
+	err = mS.MiddlewareDelete(parentDir, baseName)
+	return
+}
+
+// MiddlewareDeleteMultiAsCaller is MiddlewareDeleteMulti() where entries in containers whose ContainerACL
+// does not admit caller to write them fail with PermDeniedError.
+func (mS *mountStruct) MiddlewareDeleteMultiAsCaller(caller *MiddlewareCallerStruct, entries []DeleteMultiEntry) (errs []error) {
+	errs = mS.middlewareDeleteMulti(caller, entries)
+	return
+}
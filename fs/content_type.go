@@ -0,0 +1,135 @@
+package fs
+
+// Content-Type detection
+//
+// The Swift middleware keeps the Content-Type of an object PUT in the file's MiddlewareStream. A file created
+// via SMB or FUSE has none, leaving the middleware to guess one (from the object name's extension) upon each
+// HEAD or GET. Unless [<volume-section>]ContentTypeDetection is "none" (the default), the Content-Type of such
+// a file is instead determined once and persisted in its reserved ContentTypeStream:
+//
+//   extension  upon Create(), from the basename's extension (see mime.TypeByExtension())
+//   sniff      as for extension or, should that be unknown, upon the first Flush() of the file once it
+//              holds data, from its leading bytes (see http.DetectContentType())
+//
+// MiddlewareHeadResponse() and MiddlewareGetObject() return the persisted Content-Type (empty if none) for
+// the middleware to use should the file's metadata specify none. A persisted Content-Type is not revised as
+// the file is later renamed or rewritten.
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// ContentTypeStream is the reserved stream on a file inode holding its detected Content-Type.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const ContentTypeStream = "proxyfs.content_type"
+
+const contentTypeSniffSize = uint64(512) // all http.DetectContentType() considers
+
+type contentTypeDetectionType uint8
+
+const (
+	contentTypeDetectionNone      contentTypeDetectionType = iota // no Content-Type is detected
+	contentTypeDetectionExtension                                 // detected from the basename upon Create()
+	contentTypeDetectionSniff                                     // ...else from the contents upon Flush()
+)
+
+func parseContentTypeDetection(detectionAsString string) (detection contentTypeDetectionType, err error) {
+	switch detectionAsString {
+	case "none":
+		detection = contentTypeDetectionNone
+	case "extension":
+		detection = contentTypeDetectionExtension
+	case "sniff":
+		detection = contentTypeDetectionSniff
+	default:
+		err = fmt.Errorf("ContentTypeDetection must be one of \"none\", \"extension\", or \"sniff\" (not \"%s\")", detectionAsString)
+	}
+	return
+}
+
+// detectContentTypeByExtension persists the Content-Type implied by basename's extension (if known) for the
+// just created fileInodeNumber. Caller must hold fileInodeNumber's write lock.
+func (vS *volumeStruct) detectContentTypeByExtension(fileInodeNumber inode.InodeNumber, basename string) (err error) {
+	vS.Lock()
+	detection := vS.contentTypeDetection
+	vS.Unlock()
+
+	if contentTypeDetectionNone == detection {
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(basename))
+	if "" == contentType {
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(fileInodeNumber, ContentTypeStream, []byte(contentType))
+	if nil == err {
+		stats.IncrementOperations(&stats.FsContentTypeDetectOps)
+	}
+	return
+}
+
+// sniffContentType persists the Content-Type of fileInodeNumber as sniffed from its leading bytes should
+// it hold data yet have neither a detected nor a middleware-specified one. Caller must hold fileInodeNumber's
+// write lock.
+func (vS *volumeStruct) sniffContentType(fileInodeNumber inode.InodeNumber) (err error) {
+	vS.Lock()
+	detection := vS.contentTypeDetection
+	vS.Unlock()
+
+	if contentTypeDetectionSniff != detection {
+		return
+	}
+
+	metadata, err := vS.VolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		return
+	}
+	if (inode.FileType != metadata.InodeType) || (0 == metadata.Size) {
+		return
+	}
+	for _, streamName := range metadata.InodeStreamNameSlice {
+		if (ContentTypeStream == streamName) || (MiddlewareStream == streamName) {
+			return
+		}
+	}
+
+	length := metadata.Size
+	if length > contentTypeSniffSize {
+		length = contentTypeSniffSize
+	}
+	buf, err := vS.VolumeHandle.Read(fileInodeNumber, 0, length, nil)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(fileInodeNumber, ContentTypeStream, []byte(http.DetectContentType(buf)))
+	if nil == err {
+		stats.IncrementOperations(&stats.FsContentTypeSniffOps)
+	}
+	return
+}
+
+// fetchContentType returns the detected Content-Type of fileInodeNumber (empty if none). Caller must hold
+// (at least) a read lock on fileInodeNumber.
+func (vS *volumeStruct) fetchContentType(fileInodeNumber inode.InodeNumber) (contentType string, err error) {
+	buf, err := vS.VolumeHandle.GetStream(fileInodeNumber, ContentTypeStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	contentType = string(buf)
+	return
+}
@@ -0,0 +1,375 @@
+package fs
+
+// Continuation tokens
+//
+// Marker-based pagination (of MiddlewareGetContainer() and, by basename, of Readdir()) requires the
+// caller to echo back the last name returned. Such names may be up to FilePathMax long (making for
+// unwieldy URLs) and, should the named entry be renamed mid-listing, no longer identify where the
+// listing left off. A continuation token instead records, for each directory from the one being
+// listed down to the last entry returned, that directory's modification sequence (its mtime) along
+// with the InodeDirLocation, InodeNumber, and basename of the entry within it. Upon resumption, the
+// recorded InodeDirLocation is used directly if the directory is unchanged or the entry there still
+// has the recorded InodeNumber and basename. Otherwise, as directory entries are sorted by basename,
+// the listing resumes with the first entry following the recorded basename. Entries present
+// throughout a listing are thus returned exactly once no matter what is created, removed, or renamed
+// between pages (including the last entry returned).
+//
+// Version 1 tokens (lacking basenames) remain accepted. For these, a changed directory has the entry
+// relocated by its InodeNumber (should it still be in the directory) or, failing that, the position
+// preceding the recorded InodeDirLocation is used as a best effort.
+//
+// Tokens are opaque to callers: a version byte followed by the uvarint encoded fields of each level
+// (with the basename encoded as its uvarint length followed by its bytes), all base64url encoded.
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	continuationTokenVersion1     = byte(1) // levels lack entryBasename
+	continuationTokenVersion      = byte(2)
+	continuationRelocateBatchSize = 1024
+)
+
+type continuationLevelStruct struct {
+	dirInodeNumber   inode.InodeNumber
+	dirSequence      uint64 // directory's ModificationTime (in nanoseconds) when entryLocation was recorded
+	entryLocation    inode.InodeDirLocation
+	entryInodeNumber inode.InodeNumber
+	entryBasename    string // "" if decoded from a version 1 token
+}
+
+func encodeContinuationToken(levels []continuationLevelStruct) (continuationToken string) {
+	buf := make([]byte, 1, 1+(len(levels)*5*binary.MaxVarintLen64))
+	buf[0] = continuationTokenVersion
+
+	field := make([]byte, binary.MaxVarintLen64)
+	for _, level := range levels {
+		for _, value := range []uint64{uint64(level.dirInodeNumber), level.dirSequence, uint64(level.entryLocation), uint64(level.entryInodeNumber), uint64(len(level.entryBasename))} {
+			n := binary.PutUvarint(field, value)
+			buf = append(buf, field[:n]...)
+		}
+		buf = append(buf, level.entryBasename...)
+	}
+
+	continuationToken = base64.RawURLEncoding.EncodeToString(buf)
+	return
+}
+
+func decodeContinuationToken(continuationToken string) (levels []continuationLevelStruct, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(continuationToken)
+	if (nil != err) || (0 == len(buf)) || ((continuationTokenVersion1 != buf[0]) && (continuationTokenVersion != buf[0])) {
+		err = blunder.NewError(blunder.InvalidArgError, "malformed continuation token \"%s\"", continuationToken)
+		return
+	}
+	version := buf[0]
+	buf = buf[1:]
+
+	var value [4]uint64
+
+	for 0 < len(buf) {
+		for i := range value {
+			var n int
+			value[i], n = binary.Uvarint(buf)
+			if 0 >= n {
+				err = blunder.NewError(blunder.InvalidArgError, "malformed continuation token \"%s\"", continuationToken)
+				return
+			}
+			buf = buf[n:]
+		}
+		level := continuationLevelStruct{
+			dirInodeNumber:   inode.InodeNumber(value[0]),
+			dirSequence:      value[1],
+			entryLocation:    inode.InodeDirLocation(value[2]),
+			entryInodeNumber: inode.InodeNumber(value[3]),
+		}
+		if continuationTokenVersion == version {
+			basenameLen, n := binary.Uvarint(buf)
+			if (0 >= n) || (basenameLen > uint64(len(buf)-n)) {
+				err = blunder.NewError(blunder.InvalidArgError, "malformed continuation token \"%s\"", continuationToken)
+				return
+			}
+			buf = buf[n:]
+			level.entryBasename = string(buf[:basenameLen])
+			buf = buf[basenameLen:]
+		}
+		levels = append(levels, level)
+	}
+
+	if 0 == len(levels) {
+		err = blunder.NewError(blunder.InvalidArgError, "malformed continuation token \"%s\"", continuationToken)
+	}
+	return
+}
+
+func (mS *mountStruct) dirSequence(dirInodeNumber inode.InodeNumber) (dirSequence uint64, err error) {
+	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(dirInodeNumber)
+	if nil != err {
+		return
+	}
+	dirSequence = uint64(metadata.ModificationTime.UnixNano())
+	return
+}
+
+// continuationLevel records the position of basename in dirInodeNumber.
+//
+// The caller must hold (at least) a read lock on dirInodeNumber.
+func (mS *mountStruct) continuationLevel(dirInodeNumber inode.InodeNumber, basename string) (level continuationLevelStruct, err error) {
+	level.dirInodeNumber = dirInodeNumber
+	level.dirSequence, err = mS.dirSequence(dirInodeNumber)
+	if nil != err {
+		return
+	}
+	level.entryLocation, level.entryInodeNumber, err = mS.volStruct.VolumeHandle.LocateDirEntry(dirInodeNumber, basename)
+	level.entryBasename = basename
+	return
+}
+
+// resumeContinuationLevel returns where to resume listing level.dirInodeNumber, as prevReturned, along
+// with the basename of the entry recorded by level.
+//
+// Where possible, prevReturned is the entry's current InodeDirLocation. If the directory has changed
+// such that the recorded location no longer holds the entry, prevReturned is instead the recorded
+// basename. For a version 1 token whose entry is no longer in the directory, the location (and
+// basename, if any) preceding the recorded location is returned. The caller must hold (at least) a
+// read lock on level.dirInodeNumber.
+func (mS *mountStruct) resumeContinuationLevel(level continuationLevelStruct) (prevReturned interface{}, basename string, err error) {
+	dirSequence, err := mS.dirSequence(level.dirInodeNumber)
+	if nil != err {
+		return
+	}
+
+	location := level.entryLocation
+	prevReturned = location
+
+	entries, _, readDirErr := mS.volStruct.VolumeHandle.ReadDir(level.dirInodeNumber, 1, 0, location-1)
+	if (nil == readDirErr) && (1 == len(entries)) {
+		basename = entries[0].Basename
+		if dirSequence == level.dirSequence {
+			return
+		}
+		if (level.entryInodeNumber == entries[0].InodeNumber) && (("" == level.entryBasename) || (level.entryBasename == basename)) {
+			return
+		}
+	}
+
+	if "" != level.entryBasename {
+		// Directory has changed... resume following the recorded basename wherever that now falls
+
+		stats.IncrementOperations(&stats.FsContinuationResumeByNameOps)
+
+		prevReturned = level.entryBasename
+		basename = level.entryBasename
+		return
+	}
+
+	// Directory has changed... attempt to relocate the entry by its InodeNumber
+
+	stats.IncrementOperations(&stats.FsContinuationRelocateOps)
+
+	prevLocation := inode.InodeDirLocation(-1)
+	for {
+		entries, areMoreEntries, readDirErr := mS.volStruct.VolumeHandle.ReadDir(level.dirInodeNumber, continuationRelocateBatchSize, 0, prevLocation)
+		if nil != readDirErr {
+			break
+		}
+		for _, entry := range entries {
+			if level.entryInodeNumber == entry.InodeNumber {
+				prevReturned = entry.NextDirLocation - 1
+				basename = entry.Basename
+				return
+			}
+		}
+		if !areMoreEntries || (0 == len(entries)) {
+			break
+		}
+		prevLocation = entries[len(entries)-1].NextDirLocation - 1
+	}
+
+	// Entry is gone... back up one position so that (at worst) an entry is returned twice rather than skipped
+
+	location = level.entryLocation - 1
+	prevReturned = location
+	basename = ""
+	if 0 <= location {
+		entries, _, readDirErr = mS.volStruct.VolumeHandle.ReadDir(level.dirInodeNumber, 1, 0, location-1)
+		if (nil == readDirErr) && (1 == len(entries)) {
+			basename = entries[0].Basename
+		}
+	}
+
+	return
+}
+
+// ReaddirByToken is Readdir() paginated by continuation token (see continuation.go) rather than basename.
+//
+// An empty continuationToken starts at the beginning of the directory.
+func (mS *mountStruct) ReaddirByToken(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, continuationToken string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, nextContinuationToken string, areMoreEntries bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.X_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	var prevReturned interface{} = inode.InodeDirLocation(-1)
+
+	if "" != continuationToken {
+		levels, decodeErr := decodeContinuationToken(continuationToken)
+		if nil != decodeErr {
+			err = decodeErr
+			return
+		}
+		if (1 != len(levels)) || (inodeNumber != levels[0].dirInodeNumber) {
+			err = blunder.NewError(blunder.InvalidArgError, "continuation token is not for directory inode %v", inodeNumber)
+			return
+		}
+		prevReturned, _, err = mS.resumeContinuationLevel(levels[0])
+		if nil != err {
+			return
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsReaddirOps)
+
+	entries, _, areMoreEntries, err = mS.readdirHelper(inodeNumber, prevReturned, "", maxEntries, maxBufSize, inodeLock.GetCallerID())
+	if (nil != err) || (0 == len(entries)) {
+		return
+	}
+
+	lastEntry := entries[len(entries)-1]
+	dirSequence, err := mS.dirSequence(inodeNumber)
+	if nil != err {
+		return
+	}
+	nextContinuationToken = encodeContinuationToken([]continuationLevelStruct{{
+		dirInodeNumber:   inodeNumber,
+		dirSequence:      dirSequence,
+		entryLocation:    lastEntry.NextDirLocation - 1,
+		entryInodeNumber: lastEntry.InodeNumber,
+		entryBasename:    lastEntry.Basename,
+	}})
+	return
+}
+
+// MiddlewareGetContainerByToken is MiddlewareGetContainer() that also returns a continuation token (see
+// continuation.go) for retrieving the next page.
+//
+// A non-empty continuationToken supersedes marker (which permits a listing begun by marker to switch over
+// to continuation tokens). An empty nextContinuationToken is returned if no entries were returned.
+func (mS *mountStruct) MiddlewareGetContainerByToken(vContainerName string, maxEntries uint64, marker string, continuationToken string, prefix string) (containerEnts []ContainerEntry, nextContinuationToken string, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	containerInodeNumber, _, containerInodeLock, err := mS.resolvePathForRead(vContainerName, nil)
+	if err != nil {
+		return
+	}
+	containerInodeLock.Unlock()
+
+	if "" != continuationToken {
+		levels, decodeErr := decodeContinuationToken(continuationToken)
+		if nil != decodeErr {
+			err = decodeErr
+			return
+		}
+		if containerInodeNumber != levels[0].dirInodeNumber {
+			err = blunder.NewError(blunder.InvalidArgError, "continuation token is not for container %s", vContainerName)
+			return
+		}
+
+		markerSegments := make([]string, 0, len(levels))
+		for _, level := range levels {
+			var (
+				basename string
+				dirLock  *dlm.RWLockStruct
+			)
+			dirLock, err = mS.volStruct.initInodeLock(level.dirInodeNumber, nil)
+			if nil != err {
+				return
+			}
+			err = dirLock.ReadLock()
+			if nil != err {
+				return
+			}
+			_, basename, err = mS.resumeContinuationLevel(level)
+			dirLock.Unlock()
+			if nil != err {
+				return
+			}
+			if "" == basename {
+				break // this level's directory no longer extends to the recorded location
+			}
+			markerSegments = append(markerSegments, basename)
+		}
+		marker = strings.Join(markerSegments, "/")
+	}
+
+	containerEnts, err = mS.middlewareGetContainer(vContainerName, maxEntries, marker, "", true, prefix, "", false)
+	if (nil != err) || (0 == len(containerEnts)) {
+		return
+	}
+
+	// Record the position of each path segment of the last entry returned
+
+	lastEntrySegments := strings.Split(containerEnts[len(containerEnts)-1].Basename, "/")
+	levels := make([]continuationLevelStruct, len(lastEntrySegments))
+	dirInodeNumber := containerInodeNumber
+
+	for i, segment := range lastEntrySegments {
+		dirLock, lockErr := mS.volStruct.initInodeLock(dirInodeNumber, nil)
+		if nil != lockErr {
+			err = lockErr
+			return
+		}
+		err = dirLock.ReadLock()
+		if nil != err {
+			return
+		}
+		levels[i], err = mS.continuationLevel(dirInodeNumber, segment)
+		dirLock.Unlock()
+		if nil != err {
+			return
+		}
+		dirInodeNumber = levels[i].entryInodeNumber
+	}
+
+	nextContinuationToken = encodeContinuationToken(levels)
+	return
+}
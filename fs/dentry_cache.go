@@ -0,0 +1,211 @@
+package fs
+
+// Dentry cache
+//
+// Each Lookup() of a directory entry (including each step of path resolution) would otherwise search the
+// directory's B+Tree in the inode layer. Instead, the volume's inode.VolumeHandle is wrapped by a
+// dentryCacheVolumeHandleStruct remembering the inode number found by up to [<volume-section>]DentryCacheMax
+// (0 == none) recent Lookup()s, keyed by directory inode number & basename, evicting the least recently used.
+//
+// As every change to a directory made by fs passes through the wrapper's Link(), Unlink(), Move(), Coalesce(),
+// or UnlinkGeneration(), each of these forgets every entry cached for the directories it touches (forgetting
+// the whole directory rather than just the basename as, in a CaseInsensitive volume, other spellings of it
+// may be cached too). A Lookup() racing such a change only caches what it found if no directory has been
+// changed since it began (see generation). Neither "." nor ".." are cached as the latter changes when a
+// directory is moved.
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const defaultDentryCacheMax = 65536
+
+type dentryCacheStruct struct {
+	sync.Mutex
+	max        uint64                                         // [<volume-section>]DentryCacheMax
+	generation uint64                                         // advanced as each directory is changed
+	dirMap     map[inode.InodeNumber]map[string]*list.Element // Value.(*dentryCacheEntryStruct)
+	lruList    *list.List                                     // front == most recently used
+}
+
+type dentryCacheEntryStruct struct {
+	dirInodeNumber    inode.InodeNumber
+	basename          string
+	targetInodeNumber inode.InodeNumber
+}
+
+// dentryCacheVolumeHandleStruct is the inode.VolumeHandle used by fs, consulting & maintaining cache.
+type dentryCacheVolumeHandleStruct struct {
+	inode.VolumeHandle
+	cache *dentryCacheStruct
+}
+
+// newDentryCacheVolumeHandle wraps volumeHandle (newly fetched for vS) with vS's (emptied) dentry cache.
+func (vS *volumeStruct) newDentryCacheVolumeHandle(volumeHandle inode.VolumeHandle) inode.VolumeHandle {
+	vS.dentryCache.Lock()
+	vS.dentryCache.dirMap = make(map[inode.InodeNumber]map[string]*list.Element)
+	vS.dentryCache.lruList = list.New()
+	vS.dentryCache.generation++
+	vS.dentryCache.Unlock()
+
+	return &dentryCacheVolumeHandleStruct{VolumeHandle: volumeHandle, cache: &vS.dentryCache}
+}
+
+func (vS *volumeStruct) configureDentryCache(max uint64) {
+	vS.dentryCache.Lock()
+	vS.dentryCache.max = max
+	vS.dentryCache.evictWhileLocked()
+	vS.dentryCache.Unlock()
+}
+
+// evictWhileLocked evicts the least recently used entries beyond max. Caller must hold cache.Mutex.
+func (cache *dentryCacheStruct) evictWhileLocked() {
+	if nil == cache.lruList {
+		return
+	}
+
+	for uint64(cache.lruList.Len()) > cache.max {
+		entry := cache.lruList.Remove(cache.lruList.Back()).(*dentryCacheEntryStruct)
+		basenameMap := cache.dirMap[entry.dirInodeNumber]
+		delete(basenameMap, entry.basename)
+		if 0 == len(basenameMap) {
+			delete(cache.dirMap, entry.dirInodeNumber)
+		}
+	}
+}
+
+// fetch returns the cached targetInodeNumber of dirInodeNumber's entry basename (if ok) along with the
+// generation to pass to any subsequent insert() of what an uncached Lookup() finds.
+func (cache *dentryCacheStruct) fetch(dirInodeNumber inode.InodeNumber, basename string) (targetInodeNumber inode.InodeNumber, ok bool, generation uint64) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	generation = cache.generation
+
+	element, ok := cache.dirMap[dirInodeNumber][basename]
+	if ok {
+		cache.lruList.MoveToFront(element)
+		targetInodeNumber = element.Value.(*dentryCacheEntryStruct).targetInodeNumber
+	}
+	return
+}
+
+// insert caches targetInodeNumber as dirInodeNumber's entry basename unless some directory has since
+// changed (i.e. generation is no longer current).
+func (cache *dentryCacheStruct) insert(dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber, generation uint64) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if (0 == cache.max) || (generation != cache.generation) || (nil == cache.dirMap) {
+		return
+	}
+
+	basenameMap, ok := cache.dirMap[dirInodeNumber]
+	if !ok {
+		basenameMap = make(map[string]*list.Element)
+		cache.dirMap[dirInodeNumber] = basenameMap
+	}
+	element, ok := basenameMap[basename]
+	if ok {
+		element.Value.(*dentryCacheEntryStruct).targetInodeNumber = targetInodeNumber
+		cache.lruList.MoveToFront(element)
+		return
+	}
+
+	basenameMap[basename] = cache.lruList.PushFront(&dentryCacheEntryStruct{
+		dirInodeNumber:    dirInodeNumber,
+		basename:          basename,
+		targetInodeNumber: targetInodeNumber,
+	})
+
+	cache.evictWhileLocked()
+}
+
+// forgetDirs forgets every entry cached for each of dirInodeNumbers.
+func (cache *dentryCacheStruct) forgetDirs(dirInodeNumbers ...inode.InodeNumber) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.generation++
+
+	for _, dirInodeNumber := range dirInodeNumbers {
+		for _, element := range cache.dirMap[dirInodeNumber] {
+			cache.lruList.Remove(element)
+		}
+		delete(cache.dirMap, dirInodeNumber)
+	}
+}
+
+func (dH *dentryCacheVolumeHandleStruct) Lookup(dirInodeNumber inode.InodeNumber, basename string) (targetInodeNumber inode.InodeNumber, err error) {
+	if ("." == basename) || (".." == basename) {
+		targetInodeNumber, err = dH.VolumeHandle.Lookup(dirInodeNumber, basename)
+		return
+	}
+
+	targetInodeNumber, ok, generation := dH.cache.fetch(dirInodeNumber, basename)
+	if ok {
+		stats.IncrementOperations(&stats.FsDentryCacheHitOps)
+		return
+	}
+
+	targetInodeNumber, err = dH.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil == err {
+		dH.cache.insert(dirInodeNumber, basename, targetInodeNumber, generation)
+	}
+
+	stats.IncrementOperations(&stats.FsDentryCacheMissOps)
+	return
+}
+
+func (dH *dentryCacheVolumeHandleStruct) Link(dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error) {
+	err = dH.VolumeHandle.Link(dirInodeNumber, basename, targetInodeNumber)
+	dH.cache.forgetDirs(dirInodeNumber)
+	return
+}
+
+func (dH *dentryCacheVolumeHandleStruct) Unlink(dirInodeNumber inode.InodeNumber, basename string) (err error) {
+	err = dH.VolumeHandle.Unlink(dirInodeNumber, basename)
+	dH.cache.forgetDirs(dirInodeNumber)
+	return
+}
+
+func (dH *dentryCacheVolumeHandleStruct) UnlinkGeneration(dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber, targetGeneration uint64) (unlinked bool, err error) {
+	unlinked, err = dH.VolumeHandle.UnlinkGeneration(dirInodeNumber, basename, targetInodeNumber, targetGeneration)
+	dH.cache.forgetDirs(dirInodeNumber)
+	return
+}
+
+func (dH *dentryCacheVolumeHandleStruct) Move(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags) (err error) {
+	err = dH.VolumeHandle.Move(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags)
+	dH.cache.forgetDirs(srcDirInodeNumber, dstDirInodeNumber)
+	return
+}
+
+func (dH *dentryCacheVolumeHandleStruct) Coalesce(containingDirInode inode.InodeNumber, combinationName string, elements []inode.CoalesceElement) (combinationInodeNumber inode.InodeNumber, modificationTime time.Time, numWrites uint64, err error) {
+	combinationInodeNumber, modificationTime, numWrites, err = dH.VolumeHandle.Coalesce(containingDirInode, combinationName, elements)
+
+	dirInodeNumbers := make([]inode.InodeNumber, 0, 1+len(elements))
+	dirInodeNumbers = append(dirInodeNumbers, containingDirInode)
+	for _, element := range elements {
+		dirInodeNumbers = append(dirInodeNumbers, element.ContainingDirectoryInodeNumber)
+	}
+	dH.cache.forgetDirs(dirInodeNumbers...)
+	return
+}
+
+func (dH *dentryCacheVolumeHandleStruct) Destroy(inodeNumber inode.InodeNumber) (err error) {
+	err = dH.VolumeHandle.Destroy(inodeNumber)
+	dH.cache.forgetDirs(inodeNumber)
+	return
+}
+
+func (dH *dentryCacheVolumeHandleStruct) DestroyGeneration(inodeNumber inode.InodeNumber, generation uint64) (destroyed bool, err error) {
+	destroyed, err = dH.VolumeHandle.DestroyGeneration(inodeNumber, generation)
+	dH.cache.forgetDirs(inodeNumber)
+	return
+}
@@ -0,0 +1,88 @@
+package fs
+
+// Directory tree descent
+//
+// Operations visiting every directory beneath some directory (container listings via
+// MiddlewareGetContainer() and PinPath()/UnpinPath()) do so via descendTree(), which keeps an explicit
+// stack of the directories being visited rather than recursing. A pathologically deep tree thus costs
+// neither goroutine stack nor more than a bounded amount of memory: a descent that would enter more
+// than [<volume-section>]MaxTreeDescentDepth levels fails with NameTooLongError (ENAMETOOLONG, as no
+// path to the directory beyond could be expressed anyway), and one needing to remember more than
+// MaxTreeDescentPending entries (across all levels) yet to be visited fails with OutOfMemoryError
+// (ENOMEM). The former is checked as each level is entered, the latter as each level remembers the
+// entries (e.g. subdirectories) it will visit later.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	defaultMaxTreeDescentDepth   = uint64(1024)
+	defaultMaxTreeDescentPending = uint64(1048576)
+)
+
+type treeDescentLimitsStruct struct {
+	maxDepth   uint64 // [<volume-section>]MaxTreeDescentDepth
+	maxPending uint64 // [<volume-section>]MaxTreeDescentPending
+}
+
+// treeDescentLevel is the state of the visit of one directory of a tree being descended.
+type treeDescentLevel interface {
+	// next continues the visit of the directory until it either finds a subdirectory to descend (returned
+	// as subLevel) or completes (returning a nil subLevel).
+	next(tD *treeDescentStruct) (subLevel treeDescentLevel, err error)
+}
+
+type treeDescentStruct struct {
+	vS      *volumeStruct
+	limits  treeDescentLimitsStruct
+	depth   uint64 // levels currently being visited
+	pending uint64 // entries remembered (see addPending()) across all levels being visited
+}
+
+// addPending notes that a level is remembering n more entries to visit later (or, if n is negative,
+// fewer).
+func (tD *treeDescentStruct) addPending(n int) (err error) {
+	tD.pending = uint64(int64(tD.pending) + int64(n))
+	if tD.pending > tD.limits.maxPending {
+		stats.IncrementOperations(&stats.FsTreeDescentPendingLimitOps)
+		err = blunder.NewError(blunder.OutOfMemoryError, "descent of volume %s tree would need to remember more than %v entries", tD.vS.volumeName, tD.limits.maxPending)
+	}
+	return
+}
+
+// descendTree visits the tree rooted at rootLevel, depth first.
+func (vS *volumeStruct) descendTree(rootLevel treeDescentLevel) (err error) {
+	vS.Lock()
+	tD := &treeDescentStruct{vS: vS, limits: vS.treeDescentLimits}
+	vS.Unlock()
+
+	levels := []treeDescentLevel{rootLevel}
+	tD.depth = 1
+
+	for 0 < len(levels) {
+		subLevel, nextErr := levels[len(levels)-1].next(tD)
+		if nil != nextErr {
+			err = nextErr
+			return
+		}
+
+		if nil == subLevel {
+			levels = levels[:len(levels)-1]
+			tD.depth--
+			continue
+		}
+
+		if tD.depth >= tD.limits.maxDepth {
+			stats.IncrementOperations(&stats.FsTreeDescentDepthLimitOps)
+			err = blunder.NewError(blunder.NameTooLongError, "descent of volume %s tree exceeded %v levels", vS.volumeName, tD.limits.maxDepth)
+			return
+		}
+
+		levels = append(levels, subLevel)
+		tD.depth++
+	}
+
+	return
+}
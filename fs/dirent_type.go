@@ -0,0 +1,80 @@
+package fs
+
+// Directory entry types
+//
+// Each directory entry records the InodeType of the inode it names (see inode/dirent_type.go), sparing
+// Readdir() and ReaddirOne() from locking and fetching each returned entry's inode. Entries written
+// before this was so lack the type, which ReadDir() must then fetch. UpgradeDirEntryTypes() walks a
+// volume's namespace recording the type in each such entry so that this need not persist.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// recordDirEntryTypes records the types of dirInodeNumber's entries while holding its write lock,
+// returning the entries naming subdirectories (other than "." and "..").
+func (vS *volumeStruct) recordDirEntryTypes(dirInodeNumber inode.InodeNumber) (recorded uint64, subdirInodeNumbers []inode.InodeNumber, err error) {
+	dirInodeLock, err := vS.getWriteLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	recorded, err = vS.VolumeHandle.RecordDirEntryTypes(dirInodeNumber)
+	if nil != err {
+		return
+	}
+
+	dirEntries, _, err := vS.VolumeHandle.ReadDir(dirInodeNumber, 0, 0)
+	if nil != err {
+		return
+	}
+
+	subdirInodeNumbers = make([]inode.InodeNumber, 0)
+	for _, dirEntry := range dirEntries {
+		if ("." != dirEntry.Basename) && (".." != dirEntry.Basename) && (inode.DirType == dirEntry.Type) {
+			subdirInodeNumbers = append(subdirInodeNumbers, dirEntry.InodeNumber)
+		}
+	}
+	return
+}
+
+func upgradeDirEntryTypes(volumeName string, stopChan chan bool) (result DirEntryTypesUpgradeResult, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	dirStack := []inode.InodeNumber{inode.RootDirInodeNumber}
+
+	for 0 < len(dirStack) {
+		select {
+		case <-stopChan:
+			result.Stopped = true
+			return
+		default:
+		}
+
+		dirInodeNumber := dirStack[len(dirStack)-1]
+		dirStack = dirStack[:len(dirStack)-1]
+
+		recorded, subdirInodeNumbers, recordErr := vS.recordDirEntryTypes(dirInodeNumber)
+		if nil != recordErr {
+			if blunder.Is(recordErr, blunder.NotFoundError) {
+				continue // removed since we found it
+			}
+			err = recordErr
+			return
+		}
+
+		result.DirsScanned++
+		result.EntriesRecorded += recorded
+
+		dirStack = append(dirStack, subdirInodeNumbers...)
+	}
+
+	stats.IncrementOperations(&stats.FsUpgradeDirEntryTypesOps)
+	return
+}
@@ -0,0 +1,143 @@
+package fs
+
+// Durable handles
+//
+// A client (e.g. the Samba vfs_proxyfs module) repeatedly operating on the same path would otherwise
+// pay for its full resolution, locking each directory along the way, on every request. OpenDurable()
+// instead resolves the path once and returns a DurableHandleStruct identifying the inode it names by
+// InodeNumber and Generation. The ...ByDurableHandle() APIs then operate on that inode directly, first
+// revalidating the handle: should the inode no longer exist (or no longer be of the same Generation),
+// they fail with StaleHandleError (ESTALE) and the client must resolve the path anew. As a handle names
+// an inode (not a path), it continues to refer to the inode should it be renamed or linked elsewhere.
+//
+// A Generation is the inode's creation time as of OpenDurable(). InodeNumbers are never reused within a
+// volume, so Generation distinguishes only inodes of a since re-formatted volume or an inode whose
+// creation time has since been set (e.g. via Setstat() of StatCRTime).
+//
+// Durable handles hold no state in ProxyFS: they remain valid across Unmount() & Mount() (or the volume
+// going offline & back online) and need not be released.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// revalidateDurableHandle returns StaleHandleError unless durableHandle still names an existing inode.
+func (mS *mountStruct) revalidateDurableHandle(durableHandle DurableHandleStruct) (err error) {
+	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(durableHandle.InodeNumber)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = mS.staleDurableHandle(durableHandle)
+		}
+		return
+	}
+	if durableHandle.Generation != uint64(metadata.CreationTime.UnixNano()) {
+		err = mS.staleDurableHandle(durableHandle)
+	}
+	return
+}
+
+func (mS *mountStruct) staleDurableHandle(durableHandle DurableHandleStruct) (err error) {
+	stats.IncrementOperations(&stats.FsDurableHandleStaleOps)
+	err = blunder.NewError(blunder.StaleHandleError, "durable handle of inode %v generation %v is stale", durableHandle.InodeNumber, durableHandle.Generation)
+	return
+}
+
+func (mS *mountStruct) OpenDurable(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (durableHandle DurableHandleStruct, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	inodeNumber, err := mS.lookupPath(userID, groupID, otherGroupIDs, fullpath)
+	if nil != err {
+		return
+	}
+
+	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	durableHandle = DurableHandleStruct{
+		InodeNumber: inodeNumber,
+		Generation:  uint64(metadata.CreationTime.UnixNano()),
+	}
+
+	stats.IncrementOperations(&stats.FsOpenDurableOps)
+	return
+}
+
+func (mS *mountStruct) GetstatByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct) (stat Stat, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	stat, err = mS.getstat(userID, groupID, otherGroupIDs, durableHandle.InodeNumber)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = mS.staleDurableHandle(durableHandle)
+		}
+		return
+	}
+
+	// The stat already carries the creation time, so no separate revalidation is needed
+
+	if durableHandle.Generation != stat[StatCRTime] {
+		stat = nil
+		err = mS.staleDurableHandle(durableHandle)
+	}
+	return
+}
+
+func (mS *mountStruct) GetXAttrByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct, streamName string) (value []byte, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.revalidateDurableHandle(durableHandle)
+	if nil != err {
+		return
+	}
+
+	value, err = mS.getXAttr(userID, groupID, otherGroupIDs, durableHandle.InodeNumber, streamName)
+	return
+}
+
+func (mS *mountStruct) ListXAttrByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct) (streamNames []string, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.revalidateDurableHandle(durableHandle)
+	if nil != err {
+		return
+	}
+
+	streamNames, err = mS.listXAttr(userID, groupID, otherGroupIDs, durableHandle.InodeNumber)
+	return
+}
+
+func (mS *mountStruct) OpenByDurableHandle(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, durableHandle DurableHandleStruct, flags OpenFlags, shareMode ShareMode) (fileHandle FileHandle, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.revalidateDurableHandle(durableHandle)
+	if nil != err {
+		return
+	}
+
+	fileHandle, err = mS.open(userID, groupID, otherGroupIDs, durableHandle.InodeNumber, flags, shareMode)
+	return
+}
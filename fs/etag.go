@@ -0,0 +1,221 @@
+package fs
+
+// Content-hash ETags
+//
+// Lacking anything better, the Swift middleware synthesizes the ETag of a file modified other than by an
+// object PUT (e.g. via SMB or FUSE) from its InodeNumber and NumWrites. While a strong identifier, that is
+// not the hash of the object's contents Swift clients expect. Unless [<volume-section>]ETagAlgorithm is
+// "none", MiddlewareHeadResponse() and MiddlewareGetObject() instead return the (hex-encoded) "md5" (the
+// default) or "sha256" hash of the file's contents for the middleware to use as its ETag.
+//
+// ETags are maintained lazily: one is computed upon the first HEAD or GET of a file following a change to
+// its contents (as told by NumWrites). A background job then persists it (along with the NumWrites and
+// algorithm it applies to) in the file's reserved ETagStream so that it needn't be recomputed (even across
+// restarts) until the file is next modified. Files larger than [<volume-section>]ETagMaxComputeSize are not
+// hashed (an empty ETag is returned) as that would unduly delay the HEAD or GET, leaving the middleware to
+// fall back to its own ETag.
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// ETagStream is the reserved stream on a file inode holding its persisted ETag.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const ETagStream = "proxyfs.etag"
+
+const (
+	defaultETagAlgorithm      = "md5"
+	defaultETagMaxComputeSize = uint64(64 * 1024 * 1024)
+
+	etagReadSize = uint64(1024 * 1024)
+)
+
+type etagRecordStruct struct {
+	NumWrites uint64
+	Algorithm string
+	ETag      string
+}
+
+type etagStruct struct {
+	sync.Mutex
+	algorithm      string                                 // [<volume-section>]ETagAlgorithm ("" == ETags not computed)
+	maxComputeSize uint64                                 // [<volume-section>]ETagMaxComputeSize
+	stopped        bool                                   // if true, no further ETags are persisted (see shutdown.go)
+	pending        map[inode.InodeNumber]etagRecordStruct // computed ETags awaiting persistence
+	jobs           sync.WaitGroup                         // signaled as each persist job exits
+}
+
+func parseETagAlgorithm(algorithmAsString string) (algorithm string, err error) {
+	switch algorithmAsString {
+	case "md5", "sha256":
+		algorithm = algorithmAsString
+	case "none":
+		algorithm = ""
+	default:
+		err = fmt.Errorf("ETagAlgorithm must be one of \"md5\", \"sha256\", or \"none\" (not \"%s\")", algorithmAsString)
+	}
+	return
+}
+
+func (vS *volumeStruct) initETag() {
+	vS.etag.pending = make(map[inode.InodeNumber]etagRecordStruct)
+}
+
+func (vS *volumeStruct) configureETag(algorithm string, maxComputeSize uint64) {
+	vS.etag.Lock()
+	vS.etag.algorithm = algorithm
+	vS.etag.maxComputeSize = maxComputeSize
+	vS.etag.Unlock()
+}
+
+// fetchETag returns the ETag of the file fileInodeNumber of fileSize and numWrites, computing it if necessary
+// (in which case it is also scheduled to be persisted). An empty ETag is returned if none is to be computed.
+// Caller must hold (at least) a read lock on fileInodeNumber.
+func (vS *volumeStruct) fetchETag(fileInodeNumber inode.InodeNumber, fileSize uint64, numWrites uint64) (etag string, err error) {
+	vS.etag.Lock()
+	algorithm := vS.etag.algorithm
+	maxComputeSize := vS.etag.maxComputeSize
+	record, ok := vS.etag.pending[fileInodeNumber]
+	vS.etag.Unlock()
+
+	if "" == algorithm {
+		return
+	}
+	if ok && (numWrites == record.NumWrites) && (algorithm == record.Algorithm) {
+		etag = record.ETag
+		return
+	}
+
+	buf, streamErr := vS.VolumeHandle.GetStream(fileInodeNumber, ETagStream)
+	if nil == streamErr {
+		record = etagRecordStruct{}
+		if (nil == json.Unmarshal(buf, &record)) && (numWrites == record.NumWrites) && (algorithm == record.Algorithm) {
+			etag = record.ETag
+			return
+		}
+	}
+
+	if fileSize > maxComputeSize {
+		stats.IncrementOperations(&stats.FsETagTooLargeOps)
+		return
+	}
+
+	etag, err = vS.computeETag(fileInodeNumber, fileSize, algorithm)
+	if nil != err {
+		return
+	}
+
+	vS.scheduleETagPersist(fileInodeNumber, etagRecordStruct{NumWrites: numWrites, Algorithm: algorithm, ETag: etag})
+	return
+}
+
+// computeETag hashes the fileSize bytes of fileInodeNumber using algorithm.
+func (vS *volumeStruct) computeETag(fileInodeNumber inode.InodeNumber, fileSize uint64, algorithm string) (etag string, err error) {
+	var hasher hash.Hash
+
+	if "sha256" == algorithm {
+		hasher = sha256.New()
+	} else {
+		hasher = md5.New()
+	}
+
+	for offset := uint64(0); offset < fileSize; {
+		length := fileSize - offset
+		if length > etagReadSize {
+			length = etagReadSize
+		}
+		buf, readErr := vS.VolumeHandle.Read(fileInodeNumber, offset, length, nil)
+		if nil != readErr {
+			err = readErr
+			return
+		}
+		if 0 == len(buf) {
+			break // file shrunk beneath us... can't happen while caller holds a lock
+		}
+		_, _ = hasher.Write(buf)
+		offset += uint64(len(buf))
+	}
+
+	etag = hex.EncodeToString(hasher.Sum(nil))
+
+	stats.IncrementOperations(&stats.FsETagComputeOps)
+	return
+}
+
+// scheduleETagPersist arranges for record to be persisted in fileInodeNumber's ETagStream.
+//
+// As the caller holds a lock on fileInodeNumber, the persist job (needing its write lock) proceeds only
+// once the caller is done. Multiple calls before the job runs are coalesced.
+func (vS *volumeStruct) scheduleETagPersist(fileInodeNumber inode.InodeNumber, record etagRecordStruct) {
+	vS.etag.Lock()
+	if vS.etag.stopped {
+		vS.etag.Unlock()
+		return
+	}
+	_, ok := vS.etag.pending[fileInodeNumber]
+	vS.etag.pending[fileInodeNumber] = record
+	if !ok {
+		vS.etag.jobs.Add(1)
+		go vS.persistETag(fileInodeNumber)
+	}
+	vS.etag.Unlock()
+}
+
+// persistETag is the persist job for fileInodeNumber.
+func (vS *volumeStruct) persistETag(fileInodeNumber inode.InodeNumber) {
+	defer vS.etag.jobs.Done()
+
+	inodeLock, err := vS.getWriteLock(fileInodeNumber, nil)
+
+	vS.etag.Lock()
+	record := vS.etag.pending[fileInodeNumber]
+	delete(vS.etag.pending, fileInodeNumber)
+	vS.etag.Unlock()
+
+	if nil != err {
+		logger.ErrorfWithError(err, "fs: unable to lock inode %v in volume '%s' to persist its ETag", fileInodeNumber, vS.volumeName)
+		return
+	}
+	defer inodeLock.Unlock()
+
+	metadata, err := vS.VolumeHandle.GetMetadata(fileInodeNumber)
+	if (nil != err) || (record.NumWrites != metadata.NumWrites) {
+		return // removed (or modified) in the meantime
+	}
+
+	buf, err := json.Marshal(record)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(fileInodeNumber, ETagStream, buf)
+	if nil != err {
+		logger.ErrorfWithError(err, "fs: unable to persist ETag of inode %v in volume '%s'", fileInodeNumber, vS.volumeName)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsETagPersistOps)
+}
+
+// stopETag prevents further ETags from being persisted, waiting until deadline for those being persisted. An
+// ETag not persisted is simply recomputed upon the file's next HEAD or GET.
+func (vS *volumeStruct) stopETag(deadline time.Time) (finished bool) {
+	vS.etag.Lock()
+	vS.etag.stopped = true
+	vS.etag.Unlock()
+
+	finished = waitUntil(&vS.etag.jobs, deadline)
+	return
+}
@@ -0,0 +1,101 @@
+package fs
+
+// Exclusive replace fencing
+//
+// MiddlewarePutComplete() replaces the file currently at an object path with a freshly reified
+// inode. Absent coordination, a POSIX Write() (e.g. via FUSE) arriving at the file being replaced
+// lands in extents that are about to be discarded (or, worse, interleaves with the replacement as
+// seen by a concurrent reader). While a MiddlewarePutComplete() is in progress, the inode it is
+// replacing is "fenced". Data modifying operations on a fenced inode either block until the fence
+// is lowered or fail immediately with a retryable TryAgainError (EAGAIN), per the volume's
+// [<volume-section>]ReplaceFenceMode setting.
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type replaceFenceModeType uint8
+
+const (
+	replaceFenceModeBlock replaceFenceModeType = iota // writers to a fenced inode wait for the fence to be lowered
+	replaceFenceModeFail                              // writers to a fenced inode fail with TryAgainError
+	replaceFenceModeNone                              // no fencing is performed
+)
+
+type replaceFenceStruct struct {
+	holders  uint64        // concurrent MiddlewarePutComplete()'s replacing the same inode
+	loweredC chan struct{} // closed once holders drops to zero
+}
+
+func parseReplaceFenceMode(modeAsString string) (mode replaceFenceModeType, err error) {
+	switch modeAsString {
+	case "block":
+		mode = replaceFenceModeBlock
+	case "fail":
+		mode = replaceFenceModeFail
+	case "none":
+		mode = replaceFenceModeNone
+	default:
+		err = fmt.Errorf("ReplaceFenceMode must be one of \"block\", \"fail\", or \"none\" (not \"%s\")", modeAsString)
+	}
+	return
+}
+
+// raiseReplaceFence fences inodeNumber until a matching lowerReplaceFence() call.
+func (vS *volumeStruct) raiseReplaceFence(inodeNumber inode.InodeNumber) {
+	vS.Lock()
+	if replaceFenceModeNone == vS.replaceFenceMode {
+		vS.Unlock()
+		return
+	}
+	fence, ok := vS.replaceFenceMap[inodeNumber]
+	if !ok {
+		fence = &replaceFenceStruct{
+			holders:  0,
+			loweredC: make(chan struct{}),
+		}
+		vS.replaceFenceMap[inodeNumber] = fence
+	}
+	fence.holders++
+	vS.Unlock()
+}
+
+func (vS *volumeStruct) lowerReplaceFence(inodeNumber inode.InodeNumber) {
+	vS.Lock()
+	fence, ok := vS.replaceFenceMap[inodeNumber]
+	if ok {
+		fence.holders--
+		if 0 == fence.holders {
+			delete(vS.replaceFenceMap, inodeNumber)
+			close(fence.loweredC)
+		}
+	}
+	vS.Unlock()
+}
+
+// awaitReplaceFence is called by data modifying operations prior to obtaining their inode lock
+// (as the fence holder will itself need that lock in order to complete the replacement).
+func (vS *volumeStruct) awaitReplaceFence(inodeNumber inode.InodeNumber) (err error) {
+	vS.Lock()
+	fence, ok := vS.replaceFenceMap[inodeNumber]
+	mode := vS.replaceFenceMode
+	vS.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if replaceFenceModeFail == mode {
+		stats.IncrementOperations(&stats.FsReplaceFenceFailOps)
+		err = blunder.NewError(blunder.TryAgainError, "inode %v is being replaced by MiddlewarePutComplete()", inodeNumber)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsReplaceFenceWaitOps)
+	<-fence.loweredC
+	return
+}
@@ -0,0 +1,497 @@
+package fs
+
+// Container freezes
+//
+// MiddlewareFreezeContainer() freezes a container (i.e. a top-level directory) while the middleware
+// performs maintenance spanning many of its objects (e.g. re-keying their metadata). Once every middleware
+// mutation beneath the container already in flight has completed, it returns a FreezeID. Until the freeze
+// is thawed, middleware mutations beneath the container made via any other mount wait: PUTs
+// (MiddlewarePutComplete() and MiddlewareMkdir()), POSTs, DELETEs (including MiddlewareDeleteMulti()),
+// MiddlewareCoalesce()s into or out of it, and MiddlewarePutContainer()s, MiddlewareSetContainerACL()s, and
+// MiddlewareSetContainerRetention()s of the container itself. As with leases (see lease.go), those made via
+// the freezing mount (i.e. the maintenance itself) proceed. Other containers of the volume are unaffected.
+//
+// So, too, do mutations beneath the container via the inode-addressed APIs used by FUSE and SMB: Create(),
+// Mkdir(), Mknod(), Symlink(), Link(), Unlink(), Rmdir(), Rename(), writes (including of streams), Resize(),
+// Setstat(), SetXAttr(), and RemoveXAttr(). These identify the inode altered (a directory for those altering
+// its entries) rather than a path. A directory's container is found by following ".." up to the root. As a
+// file has no "..", the directory it was last looked up, created, or renamed in is remembered (in a bounded
+// map) for the purpose. As it is only needed once a freeze exists, a mutation admitted while no container is
+// frozen (by another mount) is simply counted, and a freeze waits for all such mutations to complete. Once
+// any container is frozen, each mutation's container is found first, and one whose container (or, should
+// that not be known, any container) is frozen by another mount waits. A file linked into more than one
+// container is taken to be in only the one it was last seen in.
+//
+// Lest a Rename() move what a mutation alters into a frozen container between the mutation finding its
+// container and completing, a mutation finding its container holds the volume's renameLock shared until it
+// completes. Rename()s able to change the container beneath which something lies (i.e. those between
+// directories or of entries of the root directory) hold it exclusively (see rename.go). Neither holds it
+// while waiting upon a freeze.
+//
+// MiddlewareThawContainer() thaws the container, releasing the waiting mutations. Lest a freeze outlive its
+// maker (e.g. a crashed middleware), each is thawed regardless once its TTL (capped by
+// [<volume-section>]ContainerFreezeMaxTTL) expires, as are those of a mount as it is unmounted (see
+// unmount.go). Freezes are not persisted.
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const defaultContainerFreezeMaxTTL = 5 * time.Minute
+
+const (
+	freezeParentHintMax = 65536 // file parents remembered per volume
+	freezeMaxWalk       = 4096  // ".." traversals before concluding a directory's container is not known
+)
+
+type containerFreezeStruct struct {
+	freezeID             FreezeID
+	mountID              MountID
+	containerInodeNumber inode.InodeNumber
+	expiryTimer          *time.Timer
+}
+
+type containerFreezesStruct struct {
+	sync.Mutex
+	cond               *sync.Cond                              // broadcast whenever a container is thawed or a mutation completes
+	maxTTL             time.Duration                           // [<volume-section>]ContainerFreezeMaxTTL
+	frozenMap          map[string]*containerFreezeStruct       // key == container name
+	generation         uint64                                  // advanced whenever a container is frozen or thawed
+	inFlightMap        map[string]uint64                       // key == container name; value == middleware mutations in flight beneath it
+	inodeInFlightMap   map[inode.InodeNumber]uint64            // key == container inode; value == inode mutations in flight beneath it
+	unresolvedInFlight uint64                                  // inode mutations in flight admitted without finding their containers
+	parentHintMap      map[inode.InodeNumber]inode.InodeNumber // key == file inode; value == directory it was last seen in
+}
+
+func (vS *volumeStruct) initContainerFreezes() {
+	vS.containerFreezes.maxTTL = defaultContainerFreezeMaxTTL
+	vS.containerFreezes.frozenMap = make(map[string]*containerFreezeStruct)
+	vS.containerFreezes.inFlightMap = make(map[string]uint64)
+	vS.containerFreezes.inodeInFlightMap = make(map[inode.InodeNumber]uint64)
+	vS.containerFreezes.parentHintMap = make(map[inode.InodeNumber]inode.InodeNumber)
+	vS.containerFreezes.cond = sync.NewCond(&vS.containerFreezes)
+}
+
+func (vS *volumeStruct) configureContainerFreezes(maxTTL time.Duration) {
+	vS.containerFreezes.Lock()
+	vS.containerFreezes.maxTTL = maxTTL
+	vS.containerFreezes.Unlock()
+}
+
+// containerOfPath returns the container of the entity at path.Join(parentDir, baseName).
+func containerOfPath(parentDir string, baseName string) (vContainerName string) {
+	vContainerName = strings.SplitN(path.Clean("/" + parentDir)[1:], "/", 2)[0]
+	if "" == vContainerName {
+		vContainerName = strings.SplitN(path.Clean("/" + baseName)[1:], "/", 2)[0]
+	}
+	return
+}
+
+// enterContainers admits a middleware mutation via mS beneath each of vContainerNames, first waiting while
+// any of them is frozen by another mount. The returned exitContainers must be called once the mutation
+// completes.
+//
+// As freezing a container waits for the mutations admitted beneath it, a mutation must not call
+// enterContainers() again before exiting (lest it wait upon a freeze waiting upon it).
+func (mS *mountStruct) enterContainers(vContainerNames ...string) (exitContainers func()) {
+	freezes := &mS.volStruct.containerFreezes
+
+	freezes.Lock()
+
+	waited := false
+
+	for {
+		frozen := false
+		for _, vContainerName := range vContainerNames {
+			freeze, ok := freezes.frozenMap[vContainerName]
+			if ok && (mS.id != freeze.mountID) {
+				frozen = true
+				break
+			}
+		}
+		if !frozen {
+			break
+		}
+		if !waited {
+			stats.IncrementOperations(&stats.FsMwContainerFreezeWaitOps)
+			waited = true
+		}
+		freezes.cond.Wait()
+	}
+
+	for _, vContainerName := range vContainerNames {
+		freezes.inFlightMap[vContainerName]++
+	}
+
+	freezes.Unlock()
+
+	exitContainers = func() {
+		freezes.Lock()
+		for _, vContainerName := range vContainerNames {
+			freezes.inFlightMap[vContainerName]--
+			if 0 == freezes.inFlightMap[vContainerName] {
+				delete(freezes.inFlightMap, vContainerName)
+			}
+		}
+		freezes.cond.Broadcast()
+		freezes.Unlock()
+	}
+
+	return
+}
+
+// noteParent remembers that the file inodeNumber was last seen in dirInodeNumber.
+func (vS *volumeStruct) noteParent(inodeNumber inode.InodeNumber, dirInodeNumber inode.InodeNumber) {
+	freezes := &vS.containerFreezes
+
+	freezes.Lock()
+	if _, ok := freezes.parentHintMap[inodeNumber]; !ok && (len(freezes.parentHintMap) >= freezeParentHintMax) {
+		for evictInodeNumber := range freezes.parentHintMap {
+			delete(freezes.parentHintMap, evictInodeNumber)
+			break
+		}
+	}
+	freezes.parentHintMap[inodeNumber] = dirInodeNumber
+	freezes.Unlock()
+}
+
+// forgetParent forgets the directory inodeNumber was last seen in.
+func (vS *volumeStruct) forgetParent(inodeNumber inode.InodeNumber) {
+	vS.containerFreezes.Lock()
+	delete(vS.containerFreezes.parentHintMap, inodeNumber)
+	vS.containerFreezes.Unlock()
+}
+
+// containerOfInode returns the container inodeNumber is beneath (or is), inode.RootDirInodeNumber should it
+// be the root directory (or no longer exist), and !known should that not be known.
+func (vS *volumeStruct) containerOfInode(inodeNumber inode.InodeNumber) (containerInodeNumber inode.InodeNumber, known bool) {
+	inodeType, err := vS.VolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return inode.RootDirInodeNumber, true // the mutation will fail anyway
+	}
+
+	dirInodeNumber := inodeNumber
+	if inode.DirType != inodeType {
+		vS.containerFreezes.Lock()
+		dirInodeNumber, known = vS.containerFreezes.parentHintMap[inodeNumber]
+		vS.containerFreezes.Unlock()
+		if !known {
+			return
+		}
+	}
+
+	for i := 0; i < freezeMaxWalk; i++ {
+		if inode.RootDirInodeNumber == dirInodeNumber {
+			return inode.RootDirInodeNumber, true
+		}
+		parentInodeNumber, lookupErr := vS.VolumeHandle.Lookup(dirInodeNumber, "..")
+		if nil != lookupErr {
+			return 0, false
+		}
+		if inode.RootDirInodeNumber == parentInodeNumber {
+			return dirInodeNumber, true
+		}
+		dirInodeNumber = parentInodeNumber
+	}
+
+	return 0, false
+}
+
+// freezeEntryStruct identifies what a mutation alters: directory inodeNumber's entry basename or, should
+// basename be empty, inodeNumber itself.
+type freezeEntryStruct struct {
+	inodeNumber inode.InodeNumber
+	basename    string
+}
+
+// containersOfEntries returns the containers (other than the root directory) of entries, or nil should that
+// of any not be known. Caller must hold vS.renameLock.
+func (vS *volumeStruct) containersOfEntries(entries []freezeEntryStruct) (containerInodeNumbers []inode.InodeNumber) {
+	containerInodeNumbers = make([]inode.InodeNumber, 0, len(entries))
+
+	for _, entry := range entries {
+		inodeNumber := entry.inodeNumber
+		if ("" != entry.basename) && (inode.RootDirInodeNumber == inodeNumber) {
+			// As an entry of the root directory is a container, it is the inode (if any) it references that is altered
+			entryInodeNumber, err := vS.VolumeHandle.Lookup(inodeNumber, entry.basename)
+			if nil == err {
+				inodeNumber = entryInodeNumber
+			}
+		}
+		containerInodeNumber, known := vS.containerOfInode(inodeNumber)
+		if !known {
+			return nil
+		}
+		if inode.RootDirInodeNumber != containerInodeNumber {
+			containerInodeNumbers = append(containerInodeNumbers, containerInodeNumber)
+		}
+	}
+
+	return
+}
+
+// frozenByOthersWhileLocked reports whether any of containerInodeNumbers (or, if nil, any container) is
+// frozen by a mount other than mountID.
+func (freezes *containerFreezesStruct) frozenByOthersWhileLocked(mountID MountID, containerInodeNumbers []inode.InodeNumber) bool {
+	for _, freeze := range freezes.frozenMap {
+		if mountID == freeze.mountID {
+			continue
+		}
+		if nil == containerInodeNumbers {
+			return true
+		}
+		for _, containerInodeNumber := range containerInodeNumbers {
+			if containerInodeNumber == freeze.containerInodeNumber {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enterInodes admits a mutation via mS of each of inodeNumbers (for a directory, including of its entries),
+// first waiting while the container of any of them is frozen by another mount (as described above). The
+// returned exitInodes must be called once the mutation completes. As with enterContainers(), a mutation must
+// not call enterInodes() (or enterEntries()) again before exiting.
+func (mS *mountStruct) enterInodes(inodeNumbers ...inode.InodeNumber) (exitInodes func()) {
+	entries := make([]freezeEntryStruct, 0, len(inodeNumbers))
+	for _, inodeNumber := range inodeNumbers {
+		entries = append(entries, freezeEntryStruct{inodeNumber: inodeNumber})
+	}
+	exitInodes = mS.admitMutation(false, entries)
+	return
+}
+
+// enterEntries is enterInodes() for a mutation of each of entries.
+func (mS *mountStruct) enterEntries(entries ...freezeEntryStruct) (exitInodes func()) {
+	exitInodes = mS.admitMutation(false, entries)
+	return
+}
+
+// admitMutation implements enterInodes() and enterEntries() and, should renaming be true, admits a Rename()
+// holding vS.renameLock exclusively until exitInodes is called.
+func (mS *mountStruct) admitMutation(renaming bool, entries []freezeEntryStruct) (exitInodes func()) {
+	vS := mS.volStruct
+	freezes := &vS.containerFreezes
+
+	lockRenames, unlockRenames := vS.renameLock.RLock, vS.renameLock.RUnlock
+	if renaming {
+		lockRenames, unlockRenames = vS.renameLock.Lock, vS.renameLock.Unlock
+	}
+
+	var containerInodeNumbers []inode.InodeNumber
+
+	resolved := false
+	waited := false
+
+	for {
+		if renaming {
+			lockRenames()
+		}
+
+		freezes.Lock()
+
+		if !freezes.frozenByOthersWhileLocked(mS.id, nil) {
+			freezes.unresolvedInFlight++
+			break
+		}
+
+		generation := freezes.generation
+		freezes.Unlock()
+
+		if !renaming {
+			lockRenames()
+		}
+
+		containerInodeNumbers = vS.containersOfEntries(entries)
+
+		freezes.Lock()
+
+		if (generation == freezes.generation) && (nil != containerInodeNumbers) && !freezes.frozenByOthersWhileLocked(mS.id, containerInodeNumbers) {
+			for _, containerInodeNumber := range containerInodeNumbers {
+				freezes.inodeInFlightMap[containerInodeNumber]++
+			}
+			resolved = true
+			break
+		}
+
+		freezes.Unlock()
+		unlockRenames()
+		freezes.Lock()
+
+		if generation == freezes.generation { // else a container was frozen (or thawed) meanwhile
+			if !waited {
+				stats.IncrementOperations(&stats.FsContainerFreezeWaitOps)
+				waited = true
+			}
+			freezes.cond.Wait()
+		}
+
+		freezes.Unlock()
+	}
+
+	freezes.Unlock()
+
+	exitInodes = func() {
+		freezes.Lock()
+		if resolved {
+			for _, containerInodeNumber := range containerInodeNumbers {
+				freezes.inodeInFlightMap[containerInodeNumber]--
+				if 0 == freezes.inodeInFlightMap[containerInodeNumber] {
+					delete(freezes.inodeInFlightMap, containerInodeNumber)
+				}
+			}
+		} else {
+			freezes.unresolvedInFlight--
+		}
+		freezes.cond.Broadcast()
+		freezes.Unlock()
+
+		if renaming || resolved {
+			unlockRenames()
+		}
+	}
+
+	return
+}
+
+// MiddlewareFreezeContainer freezes vContainerName (as described above) for ttl (or, if zero or greater,
+// [<volume-section>]ContainerFreezeMaxTTL), returning when it will be thawed regardless. It fails with
+// DevBusyError (EBUSY) should the container already be frozen.
+func (mS *mountStruct) MiddlewareFreezeContainer(vContainerName string, ttl time.Duration) (freezeID FreezeID, expiry time.Time, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	if ("" == vContainerName) || strings.Contains(vContainerName, "/") {
+		err = blunder.NewError(blunder.InvalidArgError, "\"%s\" is not a container", vContainerName)
+		return
+	}
+	containerInodeNumber, err := mS.volStruct.VolumeHandle.Lookup(inode.RootDirInodeNumber, vContainerName)
+	if nil != err {
+		return
+	}
+	containerInodeType, err := mS.volStruct.VolumeHandle.GetType(containerInodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.DirType != containerInodeType {
+		err = blunder.NewError(blunder.NotDirError, "\"%s\" is not a container", vContainerName)
+		return
+	}
+
+	vS := mS.volStruct
+	freezes := &vS.containerFreezes
+
+	freezes.Lock()
+	defer freezes.Unlock()
+
+	_, ok := freezes.frozenMap[vContainerName]
+	if ok {
+		err = blunder.NewError(blunder.DevBusyError, "Container \"%s\" is already frozen", vContainerName)
+		return
+	}
+
+	if (0 == ttl) || (ttl > freezes.maxTTL) {
+		ttl = freezes.maxTTL
+	}
+
+	globals.Lock()
+	globals.lastFreezeID++
+	freezeID = globals.lastFreezeID
+	globals.Unlock()
+
+	expiry = time.Now().Add(ttl)
+
+	freeze := &containerFreezeStruct{freezeID: freezeID, mountID: mS.id, containerInodeNumber: containerInodeNumber}
+	freeze.expiryTimer = time.AfterFunc(ttl, func() { vS.expireContainerFreeze(vContainerName, freeze) })
+	freezes.frozenMap[vContainerName] = freeze
+	freezes.generation++
+
+	for (0 != freezes.inFlightMap[vContainerName]) || (0 != freezes.inodeInFlightMap[containerInodeNumber]) || (0 != freezes.unresolvedInFlight) {
+		freezes.cond.Wait()
+		if freeze != freezes.frozenMap[vContainerName] {
+			freezeID = 0
+			expiry = time.Time{}
+			err = blunder.NewError(blunder.TimedOut, "Freeze of container \"%s\" expired awaiting mutations in flight", vContainerName)
+			return
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsMwFreezeContainerOps)
+	return
+}
+
+// MiddlewareThawContainer thaws vContainerName, failing with NotFoundError (ENOENT) unless it is frozen
+// by freezeID.
+func (mS *mountStruct) MiddlewareThawContainer(vContainerName string, freezeID FreezeID) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	freezes := &mS.volStruct.containerFreezes
+
+	freezes.Lock()
+	defer freezes.Unlock()
+
+	freeze, ok := freezes.frozenMap[vContainerName]
+	if !ok || (freezeID != freeze.freezeID) {
+		err = blunder.NewError(blunder.NotFoundError, "Container \"%s\" is not frozen by FreezeID %v", vContainerName, freezeID)
+		return
+	}
+
+	freezes.thawWhileLocked(vContainerName, freeze)
+
+	stats.IncrementOperations(&stats.FsMwThawContainerOps)
+	return
+}
+
+func (freezes *containerFreezesStruct) thawWhileLocked(vContainerName string, freeze *containerFreezeStruct) {
+	freeze.expiryTimer.Stop()
+	delete(freezes.frozenMap, vContainerName)
+	freezes.generation++
+	freezes.cond.Broadcast()
+}
+
+// expireContainerFreeze thaws vContainerName should it yet be frozen by freeze once freeze's TTL expires.
+func (vS *volumeStruct) expireContainerFreeze(vContainerName string, freeze *containerFreezeStruct) {
+	freezes := &vS.containerFreezes
+
+	freezes.Lock()
+	defer freezes.Unlock()
+
+	if freeze != freezes.frozenMap[vContainerName] {
+		return
+	}
+
+	logger.Warnf("Volume '%s' container \"%s\" freeze %v expired... thawing", vS.volumeName, vContainerName, freeze.freezeID)
+
+	freezes.thawWhileLocked(vContainerName, freeze)
+
+	stats.IncrementOperations(&stats.FsMwContainerFreezeExpiredOps)
+}
+
+// thawMountFreezes is called as mountID is unmounted.
+func (vS *volumeStruct) thawMountFreezes(mountID MountID) {
+	freezes := &vS.containerFreezes
+
+	freezes.Lock()
+	for vContainerName, freeze := range freezes.frozenMap {
+		if mountID == freeze.mountID {
+			freezes.thawWhileLocked(vContainerName, freeze)
+		}
+	}
+	freezes.Unlock()
+}
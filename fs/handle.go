@@ -0,0 +1,322 @@
+package fs
+
+// Open file handles
+//
+// Open() returns a FileHandle carrying per-open state: the access requested (OpenFlags), the access
+// concurrently permitted to other opens of the same file (ShareMode), and ownership of byte-range
+// locks. ReadByHandle(), WriteByHandle(), and FlockByHandle() act with the identity presented to
+// Open() and are limited to the access it requested.
+//
+// As with SMB's ShareAccess, an Open() fails with DevBusyError (EBUSY) if either it requests access
+// not shared by an existing open of the file or an existing open has requested access it doesn't share.
+// Opens via any mount of the volume are considered. Access via the inode-based APIs is likewise
+// refused if not shared by an existing open (see share_mode.go).
+//
+// Each FileHandle is the lock owner (i.e. FlockStruct.Pid) of locks obtained via FlockByHandle(). To
+// keep them distinct from the process IDs presented to Flock(), FileHandles have their top bit set.
+// Close() releases any such locks still held.
+//
+// Handles are not persisted: all are dropped as a volume is taken offline.
+
+import (
+	"sync"
+	"syscall"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+const fileHandleBase = FileHandle(1) << 63
+
+type openStruct struct {
+	fileHandle    FileHandle
+	mountID       MountID
+	inodeNumber   inode.InodeNumber
+	userID        inode.InodeUserID    // as presented to Open() (i.e. prior to mapIDs())
+	groupID       inode.InodeGroupID   //   "
+	otherGroupIDs []inode.InodeGroupID //   "
+	flags         OpenFlags
+	shareMode     ShareMode
+	lockedInode   bool // if true, FlockByHandle() has succeeded in obtaining a lock
+}
+
+type handleTableStruct struct {
+	sync.Mutex
+	openMap      map[FileHandle]*openStruct
+	inodeOpenMap map[inode.InodeNumber]map[FileHandle]*openStruct
+}
+
+func (vS *volumeStruct) initHandles() {
+	vS.handles.openMap = make(map[FileHandle]*openStruct)
+	vS.handles.inodeOpenMap = make(map[inode.InodeNumber]map[FileHandle]*openStruct)
+}
+
+// closeAllHandles is called as a volume is taken offline.
+func (vS *volumeStruct) closeAllHandles() {
+	vS.handles.Lock()
+	vS.handles.openMap = make(map[FileHandle]*openStruct)
+	vS.handles.inodeOpenMap = make(map[inode.InodeNumber]map[FileHandle]*openStruct)
+	vS.handles.Unlock()
+}
+
+// openAccess returns the ShareMode bits an open with flags requires others to share.
+func openAccess(flags OpenFlags) (access ShareMode) {
+	if 0 != (flags & OpenRead) {
+		access |= ShareRead
+	}
+	if 0 != (flags & (OpenWrite | OpenAppend)) {
+		access |= ShareWrite
+	}
+	if 0 != (flags & OpenDelete) {
+		access |= ShareDelete
+	}
+	return
+}
+
+// lookupOpen returns the open of fileHandle made via mS.
+func (mS *mountStruct) lookupOpen(fileHandle FileHandle) (open *openStruct, err error) {
+	mS.volStruct.handles.Lock()
+	open, ok := mS.volStruct.handles.openMap[fileHandle]
+	mS.volStruct.handles.Unlock()
+
+	if !ok || (mS.id != open.mountID) {
+		err = blunder.NewError(blunder.BadFileError, "FileHandle %v not open", fileHandle)
+	}
+	return
+}
+
+func (mS *mountStruct) Open(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flags OpenFlags, shareMode ShareMode) (fileHandle FileHandle, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	fileHandle, err = mS.open(userID, groupID, otherGroupIDs, inodeNumber, flags, shareMode)
+	return
+}
+
+// open is Open() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) open(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flags OpenFlags, shareMode ShareMode) (fileHandle FileHandle, err error) {
+	var accessMode inode.InodeMode
+
+	mappedUserID, mappedGroupID, mappedOtherGroupIDs := mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpOpen, Flags: uint64(flags), ShareMode: shareMode}, err)
+	}()
+
+	if 0 != (flags & ^(OpenRead | OpenWrite | OpenDelete | OpenAppend | OpenDirect)) {
+		err = blunder.NewError(blunder.InvalidArgError, "Open() of invalid OpenFlags 0x%X", flags)
+		return
+	}
+	if 0 != (shareMode & ^(ShareRead | ShareWrite | ShareDelete)) {
+		err = blunder.NewError(blunder.InvalidArgError, "Open() of invalid ShareMode 0x%X", shareMode)
+		return
+	}
+
+	access := openAccess(flags)
+
+	if 0 != (access & (ShareWrite | ShareDelete)) {
+		err = mS.checkWritable()
+		if nil != err {
+			return
+		}
+	}
+	if 0 != (access & ShareRead) {
+		accessMode |= inode.R_OK
+	}
+	if 0 != (access & ShareWrite) {
+		accessMode |= inode.W_OK
+	}
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, mappedUserID, mappedGroupID, mappedOtherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, mappedUserID, mappedGroupID, mappedOtherGroupIDs, accessMode) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	inodeType, err := mS.volStruct.VolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.FileType != inodeType {
+		err = blunder.NewError(blunder.NotFileError, "Open() of inode %v requires a file inode (not %v)", inodeNumber, inodeType)
+		return
+	}
+
+	handles := &mS.volStruct.handles
+
+	handles.Lock()
+	defer handles.Unlock()
+
+	for _, otherOpen := range handles.inodeOpenMap[inodeNumber] {
+		if (0 != (access & ^otherOpen.shareMode)) || (0 != (openAccess(otherOpen.flags) & ^shareMode)) {
+			stats.IncrementOperations(&stats.FsOpenShareViolationOps)
+			err = blunder.NewError(blunder.DevBusyError, "Open() of inode %v conflicts with FileHandle %v", inodeNumber, otherOpen.fileHandle)
+			return
+		}
+	}
+
+	globals.Lock()
+	globals.lastFileHandle++
+	fileHandle = fileHandleBase | globals.lastFileHandle
+	globals.Unlock()
+
+	open := &openStruct{
+		fileHandle:    fileHandle,
+		mountID:       mS.id,
+		inodeNumber:   inodeNumber,
+		userID:        userID,
+		groupID:       groupID,
+		otherGroupIDs: otherGroupIDs,
+		flags:         flags,
+		shareMode:     shareMode,
+	}
+
+	handles.openMap[fileHandle] = open
+	inodeOpens, ok := handles.inodeOpenMap[inodeNumber]
+	if !ok {
+		inodeOpens = make(map[FileHandle]*openStruct)
+		handles.inodeOpenMap[inodeNumber] = inodeOpens
+	}
+	inodeOpens[fileHandle] = open
+
+	stats.IncrementOperations(&stats.FsOpenOps)
+	return
+}
+
+// Close releases fileHandle along with any byte-range locks it owns.
+//
+// Data written via fileHandle is not flushed (see Flush()) unless it was opened with OpenDirect.
+func (mS *mountStruct) Close(fileHandle FileHandle) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	open, err := mS.lookupOpen(fileHandle)
+	if nil != err {
+		return
+	}
+
+	defer func() { mS.noteHistory(open.inodeNumber, historyRecordStruct{Op: historyOpClose}, err) }()
+
+	handles := &mS.volStruct.handles
+
+	handles.Lock()
+	delete(handles.openMap, fileHandle)
+	inodeOpens := handles.inodeOpenMap[open.inodeNumber]
+	delete(inodeOpens, fileHandle)
+	if 0 == len(inodeOpens) {
+		delete(handles.inodeOpenMap, open.inodeNumber)
+	}
+	lockedInode := open.lockedInode
+	handles.Unlock()
+
+	if lockedInode {
+		unlockFlock := &FlockStruct{
+			Type:  syscall.F_UNLCK,
+			Start: 0,
+			Len:   ^uint64(0),
+			Pid:   uint64(fileHandle),
+		}
+		err = mS.fileUnlock(open.inodeNumber, unlockFlock)
+		if nil != err {
+			return
+		}
+		mS.volStruct.scheduleVolumeStateExport()
+		mS.volStruct.noteFlockChange()
+	}
+
+	stats.IncrementOperations(&stats.FsCloseOps)
+	return
+}
+
+func (mS *mountStruct) ReadByHandle(fileHandle FileHandle, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	open, err := mS.lookupOpen(fileHandle)
+	if nil != err {
+		return
+	}
+	if 0 == (open.flags & OpenRead) {
+		err = blunder.NewError(blunder.BadFileError, "FileHandle %v not open for reading", fileHandle)
+		return
+	}
+
+	buf, err = mS.readWithFlockPid(open.userID, open.groupID, open.otherGroupIDs, open.inodeNumber, uint64(fileHandle), offset, length, profiler)
+	return
+}
+
+// WriteByHandle writes buf at offset or, if fileHandle was opened with OpenAppend, at the end of the file.
+func (mS *mountStruct) WriteByHandle(fileHandle FileHandle, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	open, err := mS.lookupOpen(fileHandle)
+	if nil != err {
+		return
+	}
+	if 0 == (open.flags & (OpenWrite | OpenAppend)) {
+		err = blunder.NewError(blunder.BadFileError, "FileHandle %v not open for writing", fileHandle)
+		return
+	}
+
+	size, err = mS.writeHelper(open.userID, open.groupID, open.otherGroupIDs, open.inodeNumber, uint64(fileHandle), offset, 0 != (open.flags&OpenAppend), buf, profiler)
+	if nil != err {
+		return
+	}
+
+	if 0 != (open.flags & OpenDirect) {
+		err = mS.flush(open.userID, open.groupID, open.otherGroupIDs, open.inodeNumber)
+	}
+	return
+}
+
+// FlockByHandle is Flock() with inFlockStruct.Pid replaced by fileHandle (the lock owner).
+func (mS *mountStruct) FlockByHandle(fileHandle FileHandle, lockCmd int32, inFlockStruct *FlockStruct) (outFlockStruct *FlockStruct, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	open, err := mS.lookupOpen(fileHandle)
+	if nil != err {
+		return
+	}
+
+	inFlockStruct.Pid = uint64(fileHandle)
+
+	outFlockStruct, err = mS.flock(open.userID, open.groupID, open.otherGroupIDs, open.inodeNumber, lockCmd, inFlockStruct)
+	if (nil == err) && (syscall.F_SETLK == lockCmd) && (syscall.F_UNLCK != inFlockStruct.Type) {
+		mS.volStruct.handles.Lock()
+		open.lockedInode = true
+		mS.volStruct.handles.Unlock()
+	}
+	return
+}
@@ -0,0 +1,109 @@
+package fs
+
+// Heavy middleware operation admission
+//
+// Some middleware operations do work in proportion to their arguments or to the size of a container
+// rather than to that of a single object: MiddlewareCoalesce() (of any number of elements),
+// MiddlewarePutComplete() of an object made up of at least [<volume-section>]HeavyPutCompleteSegments
+// LogSegments, and container listings (MiddlewareGetContainer(), including on behalf of
+// MiddlewareGetContainerByToken()), which walk the directory tree beneath the container. So that a
+// storm of these (e.g. from a bulk upload tool) cannot starve interactive operations, at most
+// [<volume-section>]HeavyMiddlewareOpLimit of them run concurrently. Up to HeavyMiddlewareOpQueueDepth
+// more wait (in arrival order) for one to complete. Beyond that, they fail immediately with
+// DevBusyError (EBUSY), which the Swift middleware reports as 503 Service Unavailable so that the
+// client backs off and retries.
+
+import (
+	"sync"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	defaultHeavyMiddlewareOpLimit      = uint64(16)
+	defaultHeavyMiddlewareOpQueueDepth = uint64(64)
+	defaultHeavyPutCompleteSegments    = uint64(16)
+)
+
+type heavyOpLimiterStruct struct {
+	sync.Mutex
+	cond                *sync.Cond // signalled whenever a heavy operation completes (or limits change)
+	limit               uint64     // [<volume-section>]HeavyMiddlewareOpLimit (0 == unlimited)
+	queueDepth          uint64     // [<volume-section>]HeavyMiddlewareOpQueueDepth
+	putCompleteSegments uint64     // [<volume-section>]HeavyPutCompleteSegments (0 == no PutComplete is heavy)
+	active              uint64     // heavy operations running
+	queued              uint64     // heavy operations waiting for active to drop below limit
+	nextTicket          uint64     // ticket to be taken by the next heavy operation to queue
+	nextAdmitted        uint64     // ticket of the queued heavy operation to be admitted next
+}
+
+func (vS *volumeStruct) initHeavyOps() {
+	vS.heavyOps.cond = sync.NewCond(&vS.heavyOps)
+}
+
+// configureHeavyOps applies [<volume-section>]HeavyMiddlewareOpLimit, HeavyMiddlewareOpQueueDepth, &
+// HeavyPutCompleteSegments. Heavy operations already running or queued are unaffected.
+func (vS *volumeStruct) configureHeavyOps(limit uint64, queueDepth uint64, putCompleteSegments uint64) {
+	vS.heavyOps.Lock()
+	vS.heavyOps.limit = limit
+	vS.heavyOps.queueDepth = queueDepth
+	vS.heavyOps.putCompleteSegments = putCompleteSegments
+	vS.heavyOps.cond.Broadcast()
+	vS.heavyOps.Unlock()
+}
+
+// isHeavyPutComplete returns whether a MiddlewarePutComplete() of numSegments LogSegments is heavy.
+func (vS *volumeStruct) isHeavyPutComplete(numSegments int) (heavy bool) {
+	vS.heavyOps.Lock()
+	heavy = (0 != vS.heavyOps.putCompleteSegments) && (uint64(numSegments) >= vS.heavyOps.putCompleteSegments)
+	vS.heavyOps.Unlock()
+	return
+}
+
+// admitHeavyOp returns once the caller may proceed with a heavy operation (possibly after queueing
+// behind others), or fails with DevBusyError should the queue be full. If admitted, the caller must
+// call releaseHeavyOp() once done.
+func (vS *volumeStruct) admitHeavyOp() (err error) {
+	heavyOps := &vS.heavyOps
+
+	heavyOps.Lock()
+	defer heavyOps.Unlock()
+
+	if ((0 == heavyOps.limit) || (heavyOps.active < heavyOps.limit)) && (0 == heavyOps.queued) {
+		heavyOps.active++
+		stats.IncrementOperations(&stats.FsHeavyOpAdmittedOps)
+		return
+	}
+
+	if heavyOps.queued >= heavyOps.queueDepth {
+		stats.IncrementOperations(&stats.FsHeavyOpRejectedOps)
+		err = blunder.NewError(blunder.DevBusyError, "volume %s already has %v heavy middleware operations running & %v queued", vS.volumeName, heavyOps.active, heavyOps.queued)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsHeavyOpQueuedOps)
+
+	ticket := heavyOps.nextTicket
+	heavyOps.nextTicket++
+	heavyOps.queued++
+
+	for (ticket != heavyOps.nextAdmitted) || ((0 != heavyOps.limit) && (heavyOps.active >= heavyOps.limit)) {
+		heavyOps.cond.Wait()
+	}
+
+	heavyOps.nextAdmitted++
+	heavyOps.queued--
+	heavyOps.active++
+	heavyOps.cond.Broadcast() // the next ticket holder may also be admissible
+
+	stats.IncrementOperations(&stats.FsHeavyOpAdmittedOps)
+	return
+}
+
+func (vS *volumeStruct) releaseHeavyOp() {
+	vS.heavyOps.Lock()
+	vS.heavyOps.active--
+	vS.heavyOps.cond.Broadcast()
+	vS.heavyOps.Unlock()
+}
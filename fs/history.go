@@ -0,0 +1,362 @@
+package fs
+
+// Per-inode operation history
+//
+// So that support can see what recently befell a misbehaving file without mining logs, the last
+// [<volume-section>]InodeHistoryDepth (by default, none) operations upon each inode (the operation, the
+// mount it arrived via, when it completed, and its result) are kept, retrievable via FetchInodeHistory().
+// Operations naming a directory entry (e.g. Create(), Unlink(), and Rename()) are recorded against the
+// directory, noting the basename, and (where known) the inode so named. Reads are not recorded.
+//
+// An inode's history is kept in a ring buffer in its reserved InodeHistoryStream, so it survives a restart
+// (and is destroyed with the inode). Lest every operation also rewrite its inode, records are first
+// gathered in memory (sharded by inode number, so operations upon different inodes rarely contend) and
+// written to their inodes' streams every [<flow-control-section>]MaxFlushTime, as the volume is taken
+// offline, or as FetchInodeHistory() is called. Writing them alters neither the inode's AttrChangeTime nor
+// its ChangeCount. Records are kept in structured form, formatted only as FetchInodeHistory() returns them.
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// InodeHistoryStream is the reserved stream on an inode holding its recent history.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const InodeHistoryStream = "proxyfs.history"
+
+const defaultInodeHistoryDepth = uint64(0)
+
+const historyShardCount = 64
+
+// historyOpType identifies the operation of a historyRecordStruct. As they are persisted, new values must
+// only be appended.
+type historyOpType uint8
+
+const (
+	historyOpClose historyOpType = iota + 1
+	historyOpCreate
+	historyOpFlock
+	historyOpFlush
+	historyOpLink
+	historyOpMkdir
+	historyOpMknod
+	historyOpOpen
+	historyOpRemoveXAttr
+	historyOpRenameFrom // recorded against the source directory
+	historyOpRenameTo   // recorded against the destination directory
+	historyOpResize
+	historyOpResizeStream
+	historyOpRmdir
+	historyOpSetNFS4ACL
+	historyOpSetstat
+	historyOpSetXAttr
+	historyOpSetXAttrIfMatch
+	historyOpSymlink
+	historyOpUnlink
+	historyOpWrite
+	historyOpWriteStream
+	historyOpWritev
+)
+
+var historyOpNames = map[historyOpType]string{
+	historyOpClose:           "Close",
+	historyOpCreate:          "Create",
+	historyOpFlock:           "Flock",
+	historyOpFlush:           "Flush",
+	historyOpLink:            "Link",
+	historyOpMkdir:           "Mkdir",
+	historyOpMknod:           "Mknod",
+	historyOpOpen:            "Open",
+	historyOpRemoveXAttr:     "RemoveXAttr",
+	historyOpRenameFrom:      "Rename",
+	historyOpRenameTo:        "Rename",
+	historyOpResize:          "Resize",
+	historyOpResizeStream:    "ResizeStream",
+	historyOpRmdir:           "Rmdir",
+	historyOpSetNFS4ACL:      "SetNFS4ACL",
+	historyOpSetstat:         "Setstat",
+	historyOpSetXAttr:        "SetXAttr",
+	historyOpSetXAttrIfMatch: "SetXAttrIfMatch",
+	historyOpSymlink:         "Symlink",
+	historyOpUnlink:          "Unlink",
+	historyOpWrite:           "Write",
+	historyOpWriteStream:     "WriteStream",
+	historyOpWritev:          "Writev",
+}
+
+// historyRecordStruct is an entry of an InodeHistoryStream. Only those fields pertinent to Op are set.
+type historyRecordStruct struct {
+	Time        time.Time
+	MountID     MountID
+	Op          historyOpType
+	Name        string            `json:",omitempty"` // basename (for a Rename, the source basename) or stream name
+	OtherName   string            `json:",omitempty"` // for a Rename, the destination basename
+	InodeNumber inode.InodeNumber `json:",omitempty"` // the inode named or, if InDir (or for a Rename), the other directory
+	InDir       bool              `json:",omitempty"` // recorded against the inode named rather than its directory
+	Offset      uint64            `json:",omitempty"`
+	Length      uint64            `json:",omitempty"` // bytes, segments, or size
+	Flags       uint64            `json:",omitempty"` // for an Open, its OpenFlags; for a Flock, its command
+	ShareMode   ShareMode         `json:",omitempty"`
+	Result      string            `json:",omitempty"` // "" if the operation succeeded, else its error
+}
+
+type historyShardStruct struct {
+	sync.Mutex
+	pendingMap map[inode.InodeNumber][]historyRecordStruct // records yet to be written to their inodes' InodeHistoryStreams
+}
+
+type historyTableStruct struct {
+	sync.Mutex        // protects stopC
+	depth      uint64 // [<volume-section>]InodeHistoryDepth (0 == no history kept); accessed atomically
+	shards     [historyShardCount]historyShardStruct
+	stopC      chan struct{} // non-nil while the flusher runs
+	flusherWG  sync.WaitGroup
+}
+
+func (vS *volumeStruct) initHistory() {
+	for i := range vS.history.shards {
+		vS.history.shards[i].pendingMap = make(map[inode.InodeNumber][]historyRecordStruct)
+	}
+}
+
+// configureHistory applies [<volume-section>]InodeHistoryDepth.
+func (vS *volumeStruct) configureHistory(depth uint64) {
+	atomic.StoreUint64(&vS.history.depth, depth)
+}
+
+// startHistory is called as a volume is brought online.
+func (vS *volumeStruct) startHistory() {
+	vS.history.Lock()
+	if nil == vS.history.stopC {
+		vS.history.stopC = make(chan struct{})
+		vS.history.flusherWG.Add(1)
+		go vS.historyFlusher(vS.maxFlushTime, vS.history.stopC)
+	}
+	vS.history.Unlock()
+}
+
+// stopHistory is called as a volume is taken offline, writing out the records yet to be.
+func (vS *volumeStruct) stopHistory() {
+	vS.history.Lock()
+	stopC := vS.history.stopC
+	vS.history.stopC = nil
+	vS.history.Unlock()
+
+	if nil != stopC {
+		close(stopC)
+		vS.history.flusherWG.Wait()
+	}
+
+	vS.flushHistory()
+}
+
+func (vS *volumeStruct) historyFlusher(interval time.Duration, stopC chan struct{}) {
+	defer vS.history.flusherWG.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopC:
+			return
+		case <-ticker.C:
+			vS.flushHistory()
+		}
+	}
+}
+
+// noteHistory records an operation (described by record, with its result, err) in the history of
+// inodeNumber.
+func (mS *mountStruct) noteHistory(inodeNumber inode.InodeNumber, record historyRecordStruct, err error) {
+	vS := mS.volStruct
+
+	depth := atomic.LoadUint64(&vS.history.depth)
+	if 0 == depth {
+		return
+	}
+
+	record.Time = time.Now()
+	record.MountID = mS.id
+	if nil != err {
+		record.Result = err.Error()
+	}
+
+	shard := &vS.history.shards[uint64(inodeNumber)%historyShardCount]
+
+	shard.Lock()
+	records := append(shard.pendingMap[inodeNumber], record)
+	if uint64(len(records)) > depth {
+		records = records[uint64(len(records))-depth:]
+	}
+	shard.pendingMap[inodeNumber] = records
+	shard.Unlock()
+}
+
+// noteNameHistory records op upon basename in the history of dirInodeNumber and, if known (i.e.
+// non-zero), in the history of namedInodeNumber.
+func (mS *mountStruct) noteNameHistory(dirInodeNumber inode.InodeNumber, op historyOpType, basename string, namedInodeNumber inode.InodeNumber, err error) {
+	mS.noteHistory(dirInodeNumber, historyRecordStruct{Op: op, Name: basename, InodeNumber: namedInodeNumber}, err)
+	if 0 != namedInodeNumber {
+		mS.noteHistory(namedInodeNumber, historyRecordStruct{Op: op, Name: basename, InodeNumber: dirInodeNumber, InDir: true}, err)
+	}
+}
+
+// takePendingHistory removes (and returns) the records of inodeNumber yet to be written.
+func (vS *volumeStruct) takePendingHistory(inodeNumber inode.InodeNumber) (records []historyRecordStruct) {
+	shard := &vS.history.shards[uint64(inodeNumber)%historyShardCount]
+
+	shard.Lock()
+	records = shard.pendingMap[inodeNumber]
+	delete(shard.pendingMap, inodeNumber)
+	shard.Unlock()
+
+	return
+}
+
+// flushHistory writes each pending record to its inode's InodeHistoryStream.
+func (vS *volumeStruct) flushHistory() {
+	for i := range vS.history.shards {
+		shard := &vS.history.shards[i]
+
+		shard.Lock()
+		pendingMap := shard.pendingMap
+		if 0 != len(pendingMap) {
+			shard.pendingMap = make(map[inode.InodeNumber][]historyRecordStruct)
+		}
+		shard.Unlock()
+
+		for inodeNumber, records := range pendingMap {
+			_, err := vS.appendHistory(inodeNumber, records)
+			if (nil != err) && blunder.IsNot(err, blunder.NotFoundError) {
+				logger.WarnfWithError(err, "fs: volume '%s' unable to record history of inode %v", vS.volumeName, inodeNumber)
+			}
+		}
+	}
+}
+
+// appendHistory appends records to (and returns) the history of inodeNumber, retaining (in the order they
+// completed) just the last [<volume-section>]InodeHistoryDepth.
+func (vS *volumeStruct) appendHistory(inodeNumber inode.InodeNumber, records []historyRecordStruct) (history []historyRecordStruct, err error) {
+	inodeLock, err := vS.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !vS.VolumeHandle.Access(inodeNumber, inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+
+	buf, err := vS.VolumeHandle.GetStream(inodeNumber, InodeHistoryStream)
+	if nil == err {
+		err = json.Unmarshal(buf, &history)
+		if nil != err {
+			logger.WarnfWithError(err, "fs: volume '%s' discarding corrupt %s stream of inode %v", vS.volumeName, InodeHistoryStream, inodeNumber)
+			history = nil
+		}
+	} else if blunder.IsNot(err, blunder.StreamNotFound) {
+		return
+	}
+	err = nil
+
+	if 0 == len(records) {
+		return
+	}
+
+	// Records taken by concurrent flushes may be appended out of order
+
+	history = append(history, records...)
+	sort.SliceStable(history, func(i int, j int) bool { return history[i].Time.Before(history[j].Time) })
+
+	depth := atomic.LoadUint64(&vS.history.depth)
+	if uint64(len(history)) > depth {
+		history = history[uint64(len(history))-depth:]
+	}
+
+	buf, err = json.Marshal(history)
+	if nil != err {
+		err = blunder.AddError(err, blunder.PackError)
+		return
+	}
+
+	err = vS.VolumeHandle.UpdateStream(inodeNumber, InodeHistoryStream, buf)
+	return
+}
+
+// entry formats record as returned by FetchInodeHistory().
+func (record *historyRecordStruct) entry() (entry InodeHistoryEntry) {
+	entry = InodeHistoryEntry{
+		Time:    record.Time,
+		MountID: record.MountID,
+		Result:  record.Result,
+	}
+
+	opName, ok := historyOpNames[record.Op]
+	if !ok {
+		entry.Op = fmt.Sprintf("Unknown operation %d", record.Op)
+		return
+	}
+
+	switch record.Op {
+	case historyOpCreate, historyOpLink, historyOpMkdir, historyOpMknod, historyOpRmdir, historyOpSymlink, historyOpUnlink:
+		if record.InDir {
+			entry.Op = fmt.Sprintf("%s %s (in directory %d)", opName, record.Name, record.InodeNumber)
+		} else if 0 != record.InodeNumber {
+			entry.Op = fmt.Sprintf("%s %s (inode %d)", opName, record.Name, record.InodeNumber)
+		} else {
+			entry.Op = fmt.Sprintf("%s %s", opName, record.Name)
+		}
+	case historyOpRenameFrom:
+		entry.Op = fmt.Sprintf("%s %s to %s (in directory %d)", opName, record.Name, record.OtherName, record.InodeNumber)
+	case historyOpRenameTo:
+		entry.Op = fmt.Sprintf("%s %s (in directory %d) to %s", opName, record.Name, record.InodeNumber, record.OtherName)
+	case historyOpRemoveXAttr, historyOpResizeStream, historyOpSetXAttr, historyOpSetXAttrIfMatch, historyOpWriteStream:
+		entry.Op = opName + " " + record.Name
+	case historyOpFlock:
+		entry.Op = fmt.Sprintf("%s cmd %d", opName, record.Flags)
+	case historyOpOpen:
+		entry.Op = fmt.Sprintf("%s flags 0x%X shareMode 0x%X", opName, record.Flags, record.ShareMode)
+	case historyOpResize:
+		entry.Op = fmt.Sprintf("%s to %d", opName, record.Length)
+	case historyOpWrite:
+		entry.Op = fmt.Sprintf("%s %d bytes at offset %d", opName, record.Length, record.Offset)
+	case historyOpWritev:
+		entry.Op = fmt.Sprintf("%s %d segments", opName, record.Length)
+	default:
+		entry.Op = opName
+	}
+
+	return
+}
+
+func fetchInodeHistory(volumeName string, inodeNumber inode.InodeNumber) (entries []InodeHistoryEntry, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsInodeHistoryFetchOps)
+
+	history, err := vS.appendHistory(inodeNumber, vS.takePendingHistory(inodeNumber))
+	if nil != err {
+		return
+	}
+
+	entries = make([]InodeHistoryEntry, 0, len(history))
+	for i := range history {
+		entries = append(entries, history[i].entry())
+	}
+	return
+}
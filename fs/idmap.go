@@ -0,0 +1,103 @@
+package fs
+
+// Identity mapping
+//
+// A mount made via MountWithIDMap() does not take the caller identities presented to it at face
+// value. As with an NFS export, each (userID, groupID, otherGroupIDs) passed to a MountHandle method
+// is first translated through the IDMapStruct's UserIDMap and GroupIDMap and then, if RootSquash is
+// set, any remaining root user or group is replaced by AnonUserID or AnonGroupID. The mapped identity
+// is what inode.Access() checks and what owns any inode created. Owners explicitly set via Setstat()
+// are translated (but, being the target rather than the caller, not squashed).
+//
+// Some MountHandle methods are implemented in terms of others, so a mapping must be idempotent:
+// no ID may be mapped to an ID that is itself remapped (validateIDMap() enforces this).
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+func validateIDMap(idMap *IDMapStruct) (err error) {
+	if nil == idMap {
+		return
+	}
+
+	for fromUserID, toUserID := range idMap.UserIDMap {
+		if idMap.mapUserID(toUserID) != toUserID {
+			err = blunder.NewError(blunder.InvalidArgError, "UserIDMap maps %v to %v which is itself remapped", fromUserID, toUserID)
+			return
+		}
+	}
+	for fromGroupID, toGroupID := range idMap.GroupIDMap {
+		if idMap.mapGroupID(toGroupID) != toGroupID {
+			err = blunder.NewError(blunder.InvalidArgError, "GroupIDMap maps %v to %v which is itself remapped", fromGroupID, toGroupID)
+			return
+		}
+	}
+
+	if idMap.RootSquash {
+		if idMap.mapUserID(idMap.AnonUserID) != idMap.AnonUserID {
+			err = blunder.NewError(blunder.InvalidArgError, "AnonUserID %v is itself remapped by UserIDMap", idMap.AnonUserID)
+			return
+		}
+		if idMap.mapGroupID(idMap.AnonGroupID) != idMap.AnonGroupID {
+			err = blunder.NewError(blunder.InvalidArgError, "AnonGroupID %v is itself remapped by GroupIDMap", idMap.AnonGroupID)
+			return
+		}
+	}
+
+	return
+}
+
+func (idMap *IDMapStruct) mapUserID(userID inode.InodeUserID) inode.InodeUserID {
+	mappedUserID, ok := idMap.UserIDMap[userID]
+	if ok {
+		return mappedUserID
+	}
+	return userID
+}
+
+func (idMap *IDMapStruct) mapGroupID(groupID inode.InodeGroupID) inode.InodeGroupID {
+	mappedGroupID, ok := idMap.GroupIDMap[groupID]
+	if ok {
+		return mappedGroupID
+	}
+	return groupID
+}
+
+// mapIDs returns the identity a MountHandle method should act as on behalf of its caller.
+//
+// The caller's otherGroupIDs slice is never modified in place.
+func (mS *mountStruct) mapIDs(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID) (inode.InodeUserID, inode.InodeGroupID, []inode.InodeGroupID) {
+	idMap := mS.idMap
+	if nil == idMap {
+		return userID, groupID, otherGroupIDs
+	}
+
+	mappedUserID := idMap.mapUserID(userID)
+	mappedGroupID := idMap.mapGroupID(groupID)
+
+	if idMap.RootSquash {
+		if inode.InodeRootUserID == mappedUserID {
+			mappedUserID = idMap.AnonUserID
+			stats.IncrementOperations(&stats.FsRootSquashOps)
+		}
+		if inode.InodeRootGroupID == mappedGroupID {
+			mappedGroupID = idMap.AnonGroupID
+		}
+	}
+
+	var mappedOtherGroupIDs []inode.InodeGroupID
+	if nil != otherGroupIDs {
+		mappedOtherGroupIDs = make([]inode.InodeGroupID, len(otherGroupIDs))
+		for i, otherGroupID := range otherGroupIDs {
+			mappedOtherGroupIDs[i] = idMap.mapGroupID(otherGroupID)
+			if idMap.RootSquash && (inode.InodeRootGroupID == mappedOtherGroupIDs[i]) {
+				mappedOtherGroupIDs[i] = idMap.AnonGroupID
+			}
+		}
+	}
+
+	return mappedUserID, mappedGroupID, mappedOtherGroupIDs
+}
@@ -0,0 +1,135 @@
+package fs
+
+// Inode flags
+//
+// As with chattr(1), an inode may be flagged immutable (inode.ImmutableFlag) or append-only
+// (inode.AppendOnlyFlag). Neither may then be removed, renamed, replaced, resized, or have its attributes
+// altered via Setstat(). An immutable file may not be written at all, while an append-only file may only
+// be written at (or beyond) its end. Refused operations fail with NotPermError (EPERM), whoever the caller.
+//
+// Only root may fetch or change an inode's flags (via GetInodeFlags() & SetInodeFlags()). FUSE mounts
+// surface them as the "trusted.proxyfs.flags" extended attribute (see fuse/xattr.go).
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// checkInodeFlags fails with NotPermError if inodeNumber is flagged immutable or append-only. Caller must
+// hold (at least) a read lock on inodeNumber.
+func (vS *volumeStruct) checkInodeFlags(inodeNumber inode.InodeNumber) (err error) {
+	metadata, err := vS.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if 0 != metadata.Flags {
+		stats.IncrementOperations(&stats.FsInodeFlagsDeniedOps)
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+	}
+	return
+}
+
+// checkInodeFlagsOfName is checkInodeFlags() of dirInodeNumber's entry basename (if any).
+func (vS *volumeStruct) checkInodeFlagsOfName(dirInodeNumber inode.InodeNumber, basename string) (err error) {
+	targetInodeNumber, err := vS.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil // nothing to protect
+		}
+		return
+	}
+
+	err = vS.checkInodeFlags(targetInodeNumber)
+	return
+}
+
+// checkInodeFlagsForWrite fails with NotPermError if inodeNumber is flagged immutable or, unless appending
+// or writing at or beyond its end, append-only. Caller must hold (at least) a read lock on inodeNumber.
+func (vS *volumeStruct) checkInodeFlagsForWrite(inodeNumber inode.InodeNumber, offset uint64, appendMode bool) (err error) {
+	metadata, err := vS.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if (0 != (metadata.Flags & inode.ImmutableFlag)) ||
+		((0 != (metadata.Flags & inode.AppendOnlyFlag)) && !appendMode && (offset < metadata.Size)) {
+		stats.IncrementOperations(&stats.FsInodeFlagsDeniedOps)
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+	}
+	return
+}
+
+func (mS *mountStruct) GetInodeFlags(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (flags inode.InodeFlags, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeLock, err := mS.volStruct.getReadLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if inode.InodeRootUserID != userID {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+
+	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	flags = metadata.Flags
+	return
+}
+
+func (mS *mountStruct) SetInodeFlags(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, flags inode.InodeFlags) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	inodeLock, err := mS.volStruct.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if inode.InodeRootUserID != userID {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.SetFlags(inodeNumber, flags)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
+
+	stats.IncrementOperations(&stats.FsSetInodeFlagsOps)
+	return
+}
@@ -0,0 +1,267 @@
+package fs
+
+// Intent journal
+//
+// putObjectHelper() (serving MiddlewarePutComplete() and MiddlewareMkdir()) may need to create a
+// chain of directories leading down to the new file or directory. The chain is assembled bottom up,
+// out of sight, and then Link()'d into the lowest existing directory. A crash before that final
+// Link() would leave the chain's inodes unreachable. So the chain (along with any inode it replaces)
+// is recorded in the volume's intent journal before anything is Link()'d, and the record is removed
+// once the PUT has completed or been rolled back. When the volume is next brought up,
+// recoverIntents() resolves each remaining record: if the chain is intact and its name is still free,
+// the final Link() is completed; otherwise the chain is destroyed and any replaced inode put back.
+//
+// The journal is a stream (IntentStream) on a private, unlinked inode so that updating it requires
+// none of the inode locks putObjectHelper() already holds some of. The journal inode's InodeNumber is
+// recorded in IntentJournalStream on the root directory inode. As with the volume state (see
+// volume_state.go), both ride along with each headhunter checkpoint.
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// IntentJournalStream is the reserved stream on the root directory inode holding the InodeNumber of the intent journal.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const IntentJournalStream = "proxyfs.intentjournal"
+
+// IntentStream is the stream on the intent journal inode holding the pending intents.
+const IntentStream = "proxyfs.intents"
+
+// putIntentStruct records a chain of new inodes about to be Link()'d into ParentInodeNumber.
+//
+// ChainInodeNumbers[0] is the new file (or directory) and each subsequent element is the new directory
+// containing the previous one. Basenames[i] is the name of ChainInodeNumbers[i] in ChainInodeNumbers[i+1]
+// or, for the last element, in ParentInodeNumber.
+type putIntentStruct struct {
+	ParentInodeNumber   inode.InodeNumber
+	ChainInodeNumbers   []inode.InodeNumber
+	Basenames           []string
+	ObstacleInodeNumber inode.InodeNumber // inode being replaced (0 if none)
+}
+
+type intentJournalStruct struct {
+	sync.Mutex
+	inodeNumber  inode.InodeNumber // 0 until recoverIntents() has run
+	lastIntentID uint64
+	intentMap    map[uint64]*putIntentStruct
+}
+
+// persistIntentsWhileLocked rewrites IntentStream. Caller must hold vS.intents.Mutex.
+func (vS *volumeStruct) persistIntentsWhileLocked() (err error) {
+	buf, err := json.Marshal(vS.intents.intentMap)
+	if nil != err {
+		err = blunder.AddError(err, blunder.PackError)
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(vS.intents.inodeNumber, IntentStream, buf)
+	return
+}
+
+// recordPutIntent durably records intent, returning the intentID to later pass to clearPutIntent().
+func (vS *volumeStruct) recordPutIntent(intent *putIntentStruct) (intentID uint64, err error) {
+	vS.intents.Lock()
+	defer vS.intents.Unlock()
+
+	vS.intents.lastIntentID++
+	intentID = vS.intents.lastIntentID
+	vS.intents.intentMap[intentID] = intent
+
+	err = vS.persistIntentsWhileLocked()
+	if nil != err {
+		delete(vS.intents.intentMap, intentID)
+	}
+	return
+}
+
+func (vS *volumeStruct) clearPutIntent(intentID uint64) {
+	vS.intents.Lock()
+	defer vS.intents.Unlock()
+
+	delete(vS.intents.intentMap, intentID)
+
+	err := vS.persistIntentsWhileLocked()
+	if nil != err {
+		logger.WarnfWithError(err, "fs: couldn't clear intent %v in volume '%s'", intentID, vS.volumeName)
+	}
+}
+
+// isUnlinked reports whether inodeNumber exists but is not referenced by any directory.
+func (vS *volumeStruct) isUnlinked(inodeNumber inode.InodeNumber) bool {
+	inodeType, err := vS.VolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return false
+	}
+	linkCount, err := vS.VolumeHandle.GetLinkCount(inodeNumber)
+	if nil != err {
+		return false
+	}
+	if inode.DirType == inodeType {
+		return 1 >= linkCount // only its own "."
+	}
+	return 0 == linkCount
+}
+
+// resolvePutIntent completes (if allowCompletion) or rolls back intent, reporting which was done.
+//
+// If the chain has already been Link()'d into ParentInodeNumber, the replaced inode (if any) is destroyed
+// and completed is returned as true regardless of allowCompletion. Caller must hold the write lock on
+// intent.ParentInodeNumber (or be certain nothing else can access the volume). No locks are needed on
+// the chain's inodes as nothing else knows of them.
+func (vS *volumeStruct) resolvePutIntent(intent *putIntentStruct, allowCompletion bool) (completed bool) {
+	top := len(intent.ChainInodeNumbers) - 1
+	topInodeNumber := intent.ChainInodeNumbers[top]
+	topBasename := intent.Basenames[top]
+
+	parentEntryInodeNumber, lookupErr := vS.VolumeHandle.Lookup(intent.ParentInodeNumber, topBasename)
+	topNameFree := blunder.Is(lookupErr, blunder.NotFoundError)
+
+	if (nil == lookupErr) && (topInodeNumber == parentEntryInodeNumber) {
+		completed = true
+	} else if allowCompletion && topNameFree {
+		chainIntact := true
+		for i := 0; i < top; i++ {
+			entryInodeNumber, chainLookupErr := vS.VolumeHandle.Lookup(intent.ChainInodeNumbers[i+1], intent.Basenames[i])
+			if (nil != chainLookupErr) || (intent.ChainInodeNumbers[i] != entryInodeNumber) {
+				chainIntact = false
+				break
+			}
+		}
+		if chainIntact && vS.isUnlinked(topInodeNumber) {
+			linkErr := vS.VolumeHandle.Link(intent.ParentInodeNumber, topBasename, topInodeNumber)
+			if nil == linkErr {
+				completed = true
+				topNameFree = false
+			} else {
+				logger.WarnfWithError(linkErr, "fs: couldn't complete Link(%v, %v, %v) in volume '%s'", intent.ParentInodeNumber, topBasename, topInodeNumber, vS.volumeName)
+			}
+		}
+	}
+
+	if completed {
+		if (0 != intent.ObstacleInodeNumber) && vS.isUnlinked(intent.ObstacleInodeNumber) {
+			destroyErr := vS.VolumeHandle.Destroy(intent.ObstacleInodeNumber)
+			if nil != destroyErr {
+				logger.WarnfWithError(destroyErr, "fs: couldn't destroy replaced inode %v in volume '%s'", intent.ObstacleInodeNumber, vS.volumeName)
+			}
+		}
+		return
+	}
+
+	// Roll back... dismantle the chain top down, destroy it, and put back any replaced inode
+
+	for i := top; i > 0; i-- {
+		_ = vS.VolumeHandle.Unlink(intent.ChainInodeNumbers[i], intent.Basenames[i-1])
+	}
+	for _, chainInodeNumber := range intent.ChainInodeNumbers {
+		if vS.isUnlinked(chainInodeNumber) {
+			vS.untrackInFlightFileInodeData(chainInodeNumber, false)
+			destroyErr := vS.VolumeHandle.Destroy(chainInodeNumber)
+			if nil != destroyErr {
+				logger.WarnfWithError(destroyErr, "fs: couldn't destroy inode %v of rolled back PUT in volume '%s'", chainInodeNumber, vS.volumeName)
+			}
+		}
+	}
+
+	if (0 != intent.ObstacleInodeNumber) && topNameFree && vS.isUnlinked(intent.ObstacleInodeNumber) {
+		relinkErr := vS.VolumeHandle.Link(intent.ParentInodeNumber, topBasename, intent.ObstacleInodeNumber)
+		if nil != relinkErr {
+			logger.WarnfWithError(relinkErr, "fs: couldn't relink replaced inode %v in volume '%s'", intent.ObstacleInodeNumber, vS.volumeName)
+		}
+	}
+
+	return
+}
+
+// recoverIntents locates (creating if necessary) the volume's intent journal and resolves any intents
+// left by a previous instance of the volume.
+//
+// As this is called before the volume is available for mounting, no locks beyond the root directory
+// inode's are needed.
+func (vS *volumeStruct) recoverIntents() (err error) {
+	rootInodeLock, err := vS.getWriteLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	vS.intents.Lock()
+	defer vS.intents.Unlock()
+
+	vS.intents.intentMap = make(map[uint64]*putIntentStruct)
+
+	buf, err := vS.VolumeHandle.GetStream(inode.RootDirInodeNumber, IntentJournalStream)
+	if nil != err {
+		if blunder.IsNot(err, blunder.StreamNotFound) {
+			return
+		}
+
+		// First time up... create the intent journal inode
+
+		vS.intents.inodeNumber, err = vS.VolumeHandle.CreateFile(inode.InodeMode(0), inode.InodeRootUserID, inode.InodeRootGroupID)
+		if nil != err {
+			return
+		}
+		err = vS.persistIntentsWhileLocked() // also flushes the new journal inode
+		if nil != err {
+			return
+		}
+		buf, err = json.Marshal(vS.intents.inodeNumber)
+		if nil != err {
+			err = blunder.AddError(err, blunder.PackError)
+			return
+		}
+		err = vS.VolumeHandle.PutStream(inode.RootDirInodeNumber, IntentJournalStream, buf)
+		return
+	}
+
+	err = json.Unmarshal(buf, &vS.intents.inodeNumber)
+	if nil != err {
+		err = blunder.AddError(fmt.Errorf("fs: corrupt %s stream in volume '%s': %v", IntentJournalStream, vS.volumeName, err), blunder.UnpackError)
+		return
+	}
+
+	buf, err = vS.VolumeHandle.GetStream(vS.intents.inodeNumber, IntentStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	var intentMap map[uint64]*putIntentStruct
+
+	err = json.Unmarshal(buf, &intentMap)
+	if nil != err {
+		err = blunder.AddError(fmt.Errorf("fs: corrupt %s stream in volume '%s': %v", IntentStream, vS.volumeName, err), blunder.UnpackError)
+		return
+	}
+
+	if 0 == len(intentMap) {
+		return
+	}
+
+	for intentID, intent := range intentMap {
+		if (0 == len(intent.ChainInodeNumbers)) || (len(intent.ChainInodeNumbers) != len(intent.Basenames)) {
+			logger.Warnf("fs: ignoring malformed intent %v in volume '%s'", intentID, vS.volumeName)
+			continue
+		}
+		if vS.resolvePutIntent(intent, true) {
+			stats.IncrementOperations(&stats.FsPutIntentCompleteOps)
+		} else {
+			stats.IncrementOperations(&stats.FsPutIntentRollbackOps)
+		}
+	}
+
+	err = vS.persistIntentsWhileLocked()
+
+	return
+}
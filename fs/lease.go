@@ -0,0 +1,470 @@
+package fs
+
+// Leases (e.g. for SMB oplocks via Samba and NFS delegations via a gateway)
+//
+// AcquireLease() grants a mount a LeaseRead or LeaseWrite on an inode, permitting the mount's
+// clients to cache reads (and, for LeaseWrite, writes) of it. Leases held by the same mount never
+// conflict. Otherwise, a LeaseWrite conflicts with any other lease. A request for a conflicting
+// lease invokes the LeaseBreakHandler of each conflicting lease (from its own goroutine, so never
+// while the requesting operation holds any locks) asking that it be downgraded to LeaseRead (if
+// LeaseRead was requested) or LeaseNone (if LeaseWrite was requested). The request then waits
+// for those downgrades. Should a holder fail to respond within [<volume-section>]LeaseBreakTimeout,
+// its lease is downgraded regardless and the request is granted.
+//
+// Leases are also broken by conflicting access via other mounts, whether or not they request leases
+// (e.g. FUSE or the Swift middleware), as described in coherence.go.
+//
+// Leases are persisted with the rest of the volume state (see volume_state.go). As mounts do not survive
+// the volume being taken offline, leases restored as it is brought back online are held by no mount (they
+// have no LeaseBreakHandler, so are broken only by LeaseBreakTimeout). Within LeaseBreakTimeout, the client
+// that held one may adopt it via ReclaimLease() (e.g. as SMB durable handles are reconnected). Those not
+// reclaimed by then are released. Note that fs.Shutdown() first recalls all leases, giving holders the
+// chance to write back what they've cached, so only leases outstanding as a volume is otherwise taken
+// offline are restored. A mount's leases are released as it is unmounted (see unmount.go).
+
+import (
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const defaultLeaseBreakTimeout = 35 * time.Second // matches the SMB oplock break timeout
+
+type leaseStruct struct {
+	leaseID     LeaseID
+	mountID     MountID
+	inodeNumber inode.InodeNumber
+	leaseType   LeaseType
+	breaking    bool // if true, handler has been asked to downgrade to breakTo
+	breakTo     LeaseType
+	handler     LeaseBreakHandler // nil == restored lease not (yet) reclaimed
+}
+
+// notifyBreak asks lease's holder (if reachable) to downgrade it to breakTo.
+func (lease *leaseStruct) notifyBreak(breakTo LeaseType) {
+	if nil != lease.handler {
+		go lease.handler(lease.leaseID, lease.inodeNumber, breakTo)
+	}
+}
+
+type leaseManagerStruct struct {
+	sync.Mutex
+	vS            *volumeStruct
+	cond          *sync.Cond // broadcast whenever a lease is downgraded or released
+	leaseMap      map[LeaseID]*leaseStruct
+	inodeLeaseMap map[inode.InodeNumber]map[LeaseID]*leaseStruct
+	reclaimTimer  *time.Timer // non-nil while restored leases await ReclaimLease()
+}
+
+func (vS *volumeStruct) initLeases() {
+	vS.leases.vS = vS
+	vS.leases.leaseMap = make(map[LeaseID]*leaseStruct)
+	vS.leases.inodeLeaseMap = make(map[inode.InodeNumber]map[LeaseID]*leaseStruct)
+	vS.leases.cond = sync.NewCond(&vS.leases)
+}
+
+// releaseAllLeases is called as a volume is taken offline.
+func (vS *volumeStruct) releaseAllLeases() {
+	vS.leases.Lock()
+	vS.leases.leaseMap = make(map[LeaseID]*leaseStruct)
+	vS.leases.inodeLeaseMap = make(map[inode.InodeNumber]map[LeaseID]*leaseStruct)
+	if nil != vS.leases.reclaimTimer {
+		_ = vS.leases.reclaimTimer.Stop()
+		vS.leases.reclaimTimer = nil
+	}
+	vS.leases.cond.Broadcast()
+	vS.leases.Unlock()
+}
+
+// recallAllLeases asks the holder of each lease to release it (see shutdown.go), waiting until deadline for
+// them to do so. Any lease still held at deadline is released regardless.
+func (vS *volumeStruct) recallAllLeases(deadline time.Time) {
+	leases := &vS.leases
+
+	deadlineTimer := time.AfterFunc(time.Until(deadline), func() {
+		leases.Lock()
+		leases.cond.Broadcast()
+		leases.Unlock()
+	})
+	defer deadlineTimer.Stop()
+
+	leases.Lock()
+
+	for 0 != len(leases.leaseMap) {
+		if !time.Now().Before(deadline) {
+			for _, lease := range leases.leaseMap {
+				logger.Warnf("fs.Shutdown(): volume '%s' lease %v on inode %v not released in time... forcibly releasing", vS.volumeName, lease.leaseID, lease.inodeNumber)
+				leases.downgradeWhileLocked(lease, LeaseNone)
+				stats.IncrementOperations(&stats.FsLeaseBreakTimeoutOps)
+			}
+			break
+		}
+
+		for _, lease := range leases.leaseMap {
+			if !lease.breaking || (LeaseNone < lease.breakTo) {
+				lease.breaking = true
+				lease.breakTo = LeaseNone
+				lease.notifyBreak(LeaseNone)
+				stats.IncrementOperations(&stats.FsLeaseBreakOps)
+			}
+		}
+
+		leases.cond.Wait()
+	}
+
+	leases.Unlock()
+}
+
+// releaseMountLeases is called as mountID is unmounted.
+func (vS *volumeStruct) releaseMountLeases(mountID MountID) {
+	vS.leases.Lock()
+	for _, lease := range vS.leases.leaseMap {
+		if mountID == lease.mountID {
+			vS.leases.downgradeWhileLocked(lease, LeaseNone)
+		}
+	}
+	vS.leases.Unlock()
+}
+
+// conflictsWhileLocked returns the leases held by mounts other than mountID that conflict with leaseType on inodeNumber.
+func (leases *leaseManagerStruct) conflictsWhileLocked(mountID MountID, inodeNumber inode.InodeNumber, leaseType LeaseType) (conflicts []*leaseStruct) {
+	for _, lease := range leases.inodeLeaseMap[inodeNumber] {
+		if (mountID != lease.mountID) && ((LeaseWrite == leaseType) || (LeaseWrite == lease.leaseType)) {
+			conflicts = append(conflicts, lease)
+		}
+	}
+	return
+}
+
+func (leases *leaseManagerStruct) downgradeWhileLocked(lease *leaseStruct, leaseType LeaseType) {
+	if LeaseNone == leaseType {
+		delete(leases.leaseMap, lease.leaseID)
+		inodeLeases := leases.inodeLeaseMap[lease.inodeNumber]
+		delete(inodeLeases, lease.leaseID)
+		if 0 == len(inodeLeases) {
+			delete(leases.inodeLeaseMap, lease.inodeNumber)
+		}
+	}
+
+	lease.leaseType = leaseType
+	if lease.breaking && (lease.breakTo >= leaseType) {
+		lease.breaking = false
+	}
+
+	leases.cond.Broadcast()
+	leases.vS.scheduleVolumeStateExport()
+}
+
+// insertWhileLocked adds lease to leases.
+func (leases *leaseManagerStruct) insertWhileLocked(lease *leaseStruct) {
+	leases.leaseMap[lease.leaseID] = lease
+	inodeLeases, ok := leases.inodeLeaseMap[lease.inodeNumber]
+	if !ok {
+		inodeLeases = make(map[LeaseID]*leaseStruct)
+		leases.inodeLeaseMap[lease.inodeNumber] = inodeLeases
+	}
+	inodeLeases[lease.leaseID] = lease
+
+	leases.vS.scheduleVolumeStateExport()
+}
+
+// snapshotLeases returns the leases to be exported with the volume state. Caller must not hold vS.Mutex.
+func (vS *volumeStruct) snapshotLeases() (exportedLeases []volumeStateLeaseStruct) {
+	vS.leases.Lock()
+	for _, lease := range vS.leases.leaseMap {
+		exportedLeases = append(exportedLeases, volumeStateLeaseStruct{
+			LeaseID:     lease.leaseID,
+			InodeNumber: lease.inodeNumber,
+			LeaseType:   lease.leaseType,
+		})
+	}
+	vS.leases.Unlock()
+	return
+}
+
+// restoreLeases reinstates the leases imported with the volume state, held by no mount until reclaimed via
+// ReclaimLease(). Any not reclaimed within [<volume-section>]LeaseBreakTimeout are then released.
+func (vS *volumeStruct) restoreLeases(restoredLeases []volumeStateLeaseStruct) {
+	if 0 == len(restoredLeases) {
+		return
+	}
+
+	globals.Lock()
+	for _, restoredLease := range restoredLeases {
+		if restoredLease.LeaseID > globals.lastLeaseID {
+			globals.lastLeaseID = restoredLease.LeaseID
+		}
+	}
+	globals.Unlock()
+
+	vS.Lock()
+	leaseBreakTimeout := vS.leaseBreakTimeout
+	vS.Unlock()
+
+	vS.leases.Lock()
+	for _, restoredLease := range restoredLeases {
+		_, ok := vS.leases.leaseMap[restoredLease.LeaseID]
+		if ok || (LeaseNone == restoredLease.LeaseType) {
+			continue
+		}
+		vS.leases.insertWhileLocked(&leaseStruct{
+			leaseID:     restoredLease.LeaseID,
+			inodeNumber: restoredLease.InodeNumber,
+			leaseType:   restoredLease.LeaseType,
+		})
+	}
+	vS.leases.reclaimTimer = time.AfterFunc(leaseBreakTimeout, vS.releaseUnreclaimedLeases)
+	vS.leases.Unlock()
+}
+
+// releaseUnreclaimedLeases releases each restored lease not reclaimed via ReclaimLease().
+func (vS *volumeStruct) releaseUnreclaimedLeases() {
+	vS.leases.Lock()
+	vS.leases.reclaimTimer = nil
+	for _, lease := range vS.leases.leaseMap {
+		if nil == lease.handler {
+			logger.Warnf("fs: volume '%s' lease %v on inode %v not reclaimed... releasing", vS.volumeName, lease.leaseID, lease.inodeNumber)
+			vS.leases.downgradeWhileLocked(lease, LeaseNone)
+		}
+	}
+	vS.leases.Unlock()
+}
+
+// breakLeasesAndLock breaks each lease held by mounts other than mountID that conflicts with leaseType on
+// inodeNumber (asking that it be downgraded to breakTo) and waits for them to be downgraded. Any not
+// downgraded within [<volume-section>]LeaseBreakTimeout are downgraded regardless. Returns holding leases.Mutex.
+func (vS *volumeStruct) breakLeasesAndLock(mountID MountID, inodeNumber inode.InodeNumber, leaseType LeaseType, breakTo LeaseType, opName string) {
+	leases := &vS.leases
+
+	vS.Lock()
+	leaseBreakTimeout := vS.leaseBreakTimeout
+	vS.Unlock()
+
+	deadline := time.Now().Add(leaseBreakTimeout)
+	deadlineTimer := time.AfterFunc(leaseBreakTimeout, func() {
+		leases.Lock()
+		leases.cond.Broadcast()
+		leases.Unlock()
+	})
+	defer deadlineTimer.Stop()
+
+	leases.Lock()
+
+	for {
+		conflicts := leases.conflictsWhileLocked(mountID, inodeNumber, leaseType)
+		if 0 == len(conflicts) {
+			break
+		}
+
+		if !time.Now().Before(deadline) {
+			for _, lease := range conflicts {
+				logger.Warnf("%s: volume '%s' lease %v on inode %v not downgraded within %v... forcibly downgrading", opName, vS.volumeName, lease.leaseID, inodeNumber, leaseBreakTimeout)
+				leases.downgradeWhileLocked(lease, breakTo)
+				stats.IncrementOperations(&stats.FsLeaseBreakTimeoutOps)
+			}
+			break
+		}
+
+		for _, lease := range conflicts {
+			if !lease.breaking || (breakTo < lease.breakTo) {
+				lease.breaking = true
+				lease.breakTo = breakTo
+				lease.notifyBreak(breakTo)
+				stats.IncrementOperations(&stats.FsLeaseBreakOps)
+			}
+		}
+
+		leases.cond.Wait()
+	}
+}
+
+func (mS *mountStruct) AcquireLease(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, leaseType LeaseType, handler LeaseBreakHandler) (leaseID LeaseID, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	var (
+		accessMode inode.InodeMode
+		breakTo    LeaseType
+	)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if nil == handler {
+		err = blunder.NewError(blunder.InvalidArgError, "AcquireLease() requires a LeaseBreakHandler")
+		return
+	}
+
+	switch leaseType {
+	case LeaseRead:
+		accessMode = inode.R_OK
+		breakTo = LeaseRead
+	case LeaseWrite:
+		err = mS.checkWritable()
+		if nil != err {
+			return
+		}
+		accessMode = inode.R_OK | inode.W_OK
+		breakTo = LeaseNone
+	default:
+		err = blunder.NewError(blunder.InvalidArgError, "AcquireLease() of invalid LeaseType %v", leaseType)
+		return
+	}
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, accessMode) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	globals.Lock()
+	globals.lastLeaseID++
+	leaseID = globals.lastLeaseID
+	globals.Unlock()
+
+	leases := &mS.volStruct.leases
+
+	mS.volStruct.breakLeasesAndLock(mS.id, inodeNumber, leaseType, breakTo, "fs.AcquireLease()")
+
+	leases.insertWhileLocked(&leaseStruct{
+		leaseID:     leaseID,
+		mountID:     mS.id,
+		inodeNumber: inodeNumber,
+		leaseType:   leaseType,
+		handler:     handler,
+	})
+
+	leases.Unlock()
+
+	stats.IncrementOperations(&stats.FsLeaseAcquireOps)
+	return
+}
+
+// DowngradeLease reduces the caching permitted by leaseID (e.g. in response to a LeaseBreakHandler invocation).
+//
+// Downgrading to LeaseNone releases the lease.
+func (mS *mountStruct) DowngradeLease(leaseID LeaseID, leaseType LeaseType) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.downgradeLease(leaseID, leaseType)
+	return
+}
+
+// downgradeLease is DowngradeLease() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) downgradeLease(leaseID LeaseID, leaseType LeaseType) (err error) {
+	leases := &mS.volStruct.leases
+
+	leases.Lock()
+	defer leases.Unlock()
+
+	lease, ok := leases.leaseMap[leaseID]
+	if !ok || (mS.id != lease.mountID) {
+		err = blunder.NewError(blunder.NotFoundError, "LeaseID %v not found", leaseID)
+		return
+	}
+	if leaseType > lease.leaseType {
+		err = blunder.NewError(blunder.InvalidArgError, "LeaseID %v cannot be upgraded from %v to %v", leaseID, lease.leaseType, leaseType)
+		return
+	}
+
+	leases.downgradeWhileLocked(lease, leaseType)
+
+	stats.IncrementOperations(&stats.FsLeaseDowngradeOps)
+	return
+}
+
+// ReclaimLease adopts leaseID, restored as the volume was brought back online, on behalf of the client that
+// held it before. Breaks of the lease are subsequently delivered to handler (including any already pending).
+//
+// Only leases not yet reclaimed (nor released for want of being reclaimed within LeaseBreakTimeout) may be.
+func (mS *mountStruct) ReclaimLease(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, leaseID LeaseID, handler LeaseBreakHandler) (inodeNumber inode.InodeNumber, leaseType LeaseType, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if nil == handler {
+		err = blunder.NewError(blunder.InvalidArgError, "ReclaimLease() requires a LeaseBreakHandler")
+		return
+	}
+
+	leases := &mS.volStruct.leases
+
+	leases.Lock()
+	lease, ok := leases.leaseMap[leaseID]
+	if ok {
+		inodeNumber = lease.inodeNumber
+		leaseType = lease.leaseType
+	}
+	leases.Unlock()
+
+	if !ok {
+		err = blunder.NewError(blunder.NotFoundError, "LeaseID %v not found", leaseID)
+		return
+	}
+
+	accessMode := inode.R_OK
+	if LeaseWrite == leaseType {
+		err = mS.checkWritable()
+		if nil != err {
+			return
+		}
+		accessMode |= inode.W_OK
+	}
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, accessMode) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	leases.Lock()
+	defer leases.Unlock()
+
+	lease, ok = leases.leaseMap[leaseID]
+	if !ok || (nil != lease.handler) {
+		err = blunder.NewError(blunder.NotFoundError, "LeaseID %v not found", leaseID)
+		return
+	}
+
+	lease.mountID = mS.id
+	lease.handler = handler
+	leaseType = lease.leaseType
+
+	if lease.breaking {
+		lease.notifyBreak(lease.breakTo)
+	}
+
+	stats.IncrementOperations(&stats.FsLeaseReclaimOps)
+	return
+}
+
+func (mS *mountStruct) ReleaseLease(leaseID LeaseID) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	err = mS.downgradeLease(leaseID, LeaseNone)
+	return
+}
@@ -0,0 +1,133 @@
+package fs
+
+// Volume limits
+//
+// Each volume's limits are collected in a LimitsStruct so that frontends (e.g. FUSE, jrpcfs for Samba
+// and the Swift middleware) can discover them via GetLimits() rather than assuming their own. Those
+// derived from [<volume-section>] options may be adjusted at runtime via SetLimits().
+//
+// Per-operation memory caps
+//
+// Listing and read operations allocate in proportion to their maxEntries, maxBufSize, or length
+// arguments. So that a pathological request (e.g. maxEntries == 2^32 from a buggy client) cannot
+// allocate gigabytes, these are clamped to the volume's [<volume-section>]MaxEntriesPerOperation and
+// MaxBytesPerOperation before anything is allocated. Clamping (rather than rejecting) is safe as each
+// such operation already tells its caller whether more remains (areMoreEntries, a short Read(), or a
+// listing ending before maxEntries).
+//
+// XAttr limits
+//
+// SetXAttr() rejects names longer than [<volume-section>]XAttrNameMax with OutOfRangeError (ERANGE)
+// and values larger than XAttrValueMax with TooBigError (E2BIG), as does Linux. Streams written
+// internally (e.g. MiddlewareStream) are not subject to them, nor are those written via WriteStream()
+// (see stream.go) subject to the latter.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	defaultMaxEntriesPerOperation = uint64(100000)
+	defaultMaxBytesPerOperation   = uint64(64 * 1024 * 1024)
+	defaultXAttrNameMax           = uint64(255)       // Linux's XATTR_NAME_MAX
+	defaultXAttrValueMax          = uint64(64 * 1024) // Linux's XATTR_SIZE_MAX
+)
+
+// fixedLimits returns a LimitsStruct with only the non-adjustable fields (save MaxSymlinks) filled in.
+func fixedLimits() (limits LimitsStruct) {
+	limits = LimitsStruct{
+		FileNameMax:         FileNameMax,
+		FilePathMax:         FilePathMax,
+		FsBlockSize:         FsBlockSize,
+		OptimalTransferSize: FsOptimalTransferSize,
+	}
+	return
+}
+
+func (mS *mountStruct) GetLimits() (limits LimitsStruct) {
+	mS.volStruct.Lock()
+	limits = mS.volStruct.limits
+	mS.volStruct.Unlock()
+
+	stats.IncrementOperations(&stats.FsGetLimitsOps)
+	return
+}
+
+func setLimits(volumeName string, limits LimitsStruct) (err error) {
+	volume, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	volume.Lock()
+	maxSymlinks := volume.limits.MaxSymlinks
+	volume.Unlock()
+
+	adjustedLimits := fixedLimits()
+	adjustedLimits.MaxSymlinks = maxSymlinks
+	adjustedLimits.XAttrNameMax = limits.XAttrNameMax
+	adjustedLimits.XAttrValueMax = limits.XAttrValueMax
+	adjustedLimits.MaxEntriesPerOperation = limits.MaxEntriesPerOperation
+	adjustedLimits.MaxBytesPerOperation = limits.MaxBytesPerOperation
+
+	if adjustedLimits != limits {
+		err = blunder.NewError(blunder.InvalidArgError, "SetLimits() may only adjust XAttrNameMax, XAttrValueMax, MaxEntriesPerOperation, and MaxBytesPerOperation")
+		return
+	}
+	if (0 == limits.XAttrNameMax) || (0 == limits.XAttrValueMax) || (0 == limits.MaxEntriesPerOperation) || (0 == limits.MaxBytesPerOperation) {
+		err = blunder.NewError(blunder.InvalidArgError, "SetLimits() requires adjustable limits to be non-zero")
+		return
+	}
+
+	volume.Lock()
+	volume.limits = limits
+	volume.Unlock()
+
+	stats.IncrementOperations(&stats.FsSetLimitsOps)
+	return
+}
+
+// capEntries clamps maxEntries (where zero means "no maximum") to [<volume-section>]MaxEntriesPerOperation.
+func (vS *volumeStruct) capEntries(maxEntries uint64) uint64 {
+	vS.Lock()
+	maxEntriesPerOperation := vS.limits.MaxEntriesPerOperation
+	vS.Unlock()
+
+	if (0 == maxEntries) || (maxEntries > maxEntriesPerOperation) {
+		stats.IncrementOperations(&stats.FsOperationCappedOps)
+		return maxEntriesPerOperation
+	}
+	return maxEntries
+}
+
+// capBytes clamps maxBytes (where zero means "no maximum") to [<volume-section>]MaxBytesPerOperation.
+func (vS *volumeStruct) capBytes(maxBytes uint64) uint64 {
+	vS.Lock()
+	maxBytesPerOperation := vS.limits.MaxBytesPerOperation
+	vS.Unlock()
+
+	if (0 == maxBytes) || (maxBytes > maxBytesPerOperation) {
+		stats.IncrementOperations(&stats.FsOperationCappedOps)
+		return maxBytesPerOperation
+	}
+	return maxBytes
+}
+
+// checkXAttrLimits enforces [<volume-section>]XAttrNameMax and XAttrValueMax on SetXAttr().
+func (vS *volumeStruct) checkXAttrLimits(streamName string, value []byte) (err error) {
+	vS.Lock()
+	xattrNameMax := vS.limits.XAttrNameMax
+	xattrValueMax := vS.limits.XAttrValueMax
+	vS.Unlock()
+
+	if uint64(len(streamName)) > xattrNameMax {
+		err = blunder.NewError(blunder.OutOfRangeError, "XAttr name length %v exceeds %v", len(streamName), xattrNameMax)
+		return
+	}
+	if uint64(len(value)) > xattrValueMax {
+		err = blunder.NewError(blunder.TooBigError, "XAttr value length %v exceeds %v", len(value), xattrValueMax)
+		return
+	}
+	return
+}
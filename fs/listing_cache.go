@@ -0,0 +1,120 @@
+package fs
+
+// Container listing cache
+//
+// Dashboards and monitoring tools tend to poll the same container listings over and over, each poll walking
+// the container's tree anew. A caller able to tolerate a slightly stale listing may instead list via
+// MiddlewareGetContainerCached() with a non-zero maxStaleness: a listing of the same container (with the
+// same parameters) generated no longer than maxStaleness ago is then returned rather than walking the tree
+// again. The maxStaleness honored is capped at [<volume-section>]ListingCacheMaxStaleness (0 == no listing
+// is ever cached). Other listings neither consult nor populate the cache, so remain strongly consistent.
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	defaultListingCacheMaxStaleness = 10 * time.Second
+	listingCacheMax                 = 256 // listings remembered per volume
+)
+
+// listingCacheStruct is protected by the volumeStruct's sync.Mutex.
+type listingCacheStruct struct {
+	maxStaleness time.Duration                                     // [<volume-section>]ListingCacheMaxStaleness
+	listings     map[listingCacheKeyStruct]listingCacheEntryStruct // allocated upon first use
+}
+
+type listingCacheKeyStruct struct {
+	vContainerName string
+	maxEntries     uint64
+	marker         string
+	endMarker      string
+	prefix         string
+	delimiter      string
+	reverse        bool
+}
+
+type listingCacheEntryStruct struct {
+	containerEnts []ContainerEntry
+	generated     time.Time // when the listing began
+}
+
+// MiddlewareGetContainerCached is MiddlewareGetContainerListing() returning, if maxStaleness is non-zero,
+// a cached listing generated no longer than maxStaleness (up to [<volume-section>]ListingCacheMaxStaleness)
+// ago should there be one.
+func (mS *mountStruct) MiddlewareGetContainerCached(vContainerName string, maxEntries uint64, marker string, endMarker string, prefix string, delimiter string, reverse bool, maxStaleness time.Duration) (containerEnts []ContainerEntry, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	vS := mS.volStruct
+
+	vS.Lock()
+	if maxStaleness > vS.listingCache.maxStaleness {
+		maxStaleness = vS.listingCache.maxStaleness
+	}
+	vS.Unlock()
+
+	if 0 == maxStaleness {
+		containerEnts, err = mS.middlewareGetContainer(vContainerName, maxEntries, marker, endMarker, false, prefix, delimiter, reverse)
+		return
+	}
+
+	key := listingCacheKeyStruct{
+		vContainerName: vContainerName,
+		maxEntries:     maxEntries,
+		marker:         marker,
+		endMarker:      endMarker,
+		prefix:         prefix,
+		delimiter:      delimiter,
+		reverse:        reverse,
+	}
+
+	vS.Lock()
+	cachedListing, ok := vS.listingCache.listings[key]
+	if ok && (time.Since(cachedListing.generated) <= maxStaleness) {
+		vS.Unlock()
+		stats.IncrementOperations(&stats.FsMwGetContainerCacheHitOps)
+		containerEnts = append(make([]ContainerEntry, 0, len(cachedListing.containerEnts)), cachedListing.containerEnts...)
+		return
+	}
+	vS.Unlock()
+
+	stats.IncrementOperations(&stats.FsMwGetContainerCacheMissOps)
+
+	generated := time.Now()
+
+	containerEnts, err = mS.middlewareGetContainer(vContainerName, maxEntries, marker, endMarker, false, prefix, delimiter, reverse)
+	if nil != err {
+		return
+	}
+
+	vS.Lock()
+	if listingCacheMax <= len(vS.listingCache.listings) {
+		for cachedKey, cachedListing := range vS.listingCache.listings {
+			if time.Since(cachedListing.generated) > vS.listingCache.maxStaleness {
+				delete(vS.listingCache.listings, cachedKey)
+			}
+		}
+		if listingCacheMax <= len(vS.listingCache.listings) {
+			vS.listingCache.listings = nil
+		}
+	}
+	if nil == vS.listingCache.listings {
+		vS.listingCache.listings = make(map[listingCacheKeyStruct]listingCacheEntryStruct)
+	}
+	cachedListing, ok = vS.listingCache.listings[key]
+	if !ok || cachedListing.generated.Before(generated) {
+		vS.listingCache.listings[key] = listingCacheEntryStruct{
+			containerEnts: append(make([]ContainerEntry, 0, len(containerEnts)), containerEnts...),
+			generated:     generated,
+		}
+	}
+	vS.Unlock()
+
+	return
+}
@@ -9,12 +9,33 @@ package fs
 
 import (
 	"fmt"
+	"hash/fnv"
+	"strings"
 
 	"github.com/swiftstack/ProxyFS/dlm"
 	"github.com/swiftstack/ProxyFS/inode"
 )
 
+func parseLockFairnessPolicy(policyAsString string) (policy dlm.FairnessPolicy, err error) {
+	switch policyAsString {
+	case "fifo":
+		policy = dlm.FairnessFIFO
+	case "writer-preference":
+		policy = dlm.FairnessWriterPreference
+	case "reader-preference":
+		policy = dlm.FairnessReaderPreference
+	default:
+		err = fmt.Errorf("LockFairnessPolicy must be one of \"fifo\", \"writer-preference\", or \"reader-preference\" (not \"%s\")", policyAsString)
+	}
+	return
+}
+
 func (vS *volumeStruct) makeLockID(inodeNumber inode.InodeNumber) (lockID string, err error) {
+	if isSnapshotInodeNumber(inodeNumber) {
+		err = refuseSnapshotLock(inodeNumber)
+		return
+	}
+
 	myLockID := fmt.Sprintf("vol.%s:ino.%d", vS.volumeName, inodeNumber)
 
 	return myLockID, nil
@@ -32,7 +53,8 @@ func (vS *volumeStruct) initInodeLock(inodeNumber inode.InodeNumber, callerID dl
 		callerID = dlm.GenerateCallerID()
 	}
 
-	return &dlm.RWLockStruct{LockID: lockID,
+	return &dlm.RWLockStruct{Domain: vS.volumeName,
+		LockID:       lockID,
 		Notify:       nil,
 		LockCallerID: callerID,
 	}, nil
@@ -89,3 +111,92 @@ func (vS *volumeStruct) ensureWriteLock(inodeNumber inode.InodeNumber, callerID
 	err = lock.WriteLock()
 	return lock, err
 }
+
+// tryEnsureReadLock is ensureReadLock() but, should the lock not already be held (for reading or writing) by
+// callerID, only tries for it (failing with TryAgainError should it be unavailable).
+func (vS *volumeStruct) tryEnsureReadLock(inodeNumber inode.InodeNumber, callerID dlm.CallerID) (*dlm.RWLockStruct, error) {
+	lock, err := vS.initInodeLock(inodeNumber, callerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if lock.IsReadHeld() || lock.IsWriteHeld() {
+		return nil, nil
+	}
+
+	err = lock.TryReadLock()
+	if err != nil {
+		return nil, err // so that callers (e.g. resolvePath()) don't release a lock they never obtained
+	}
+	return lock, nil
+}
+
+// dirEntryLockStruct holds the lock(s) protecting a single entry of a directory (see getDirEntryLock()).
+type dirEntryLockStruct struct {
+	dirLock   *dlm.RWLockStruct
+	shardLock *dlm.RWLockStruct // nil unless [<volume-section>]DirLockShards is non-zero
+}
+
+// dirEntryShard returns which of shards shards of the directory's entry namespace contains basename.
+//
+// Names are folded so that, in a CaseInsensitive volume, case variants of a name share a shard.
+func dirEntryShard(basename string, shards uint64) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(strings.ToUpper(basename)))
+	return hash.Sum64() % shards
+}
+
+// getDirEntryLock acquires the lock(s) needed to add or remove basename in a directory.
+//
+// Normally, this is simply a write lock on the directory. If [<volume-section>]DirLockShards is non-zero,
+// a read lock on the directory (excluding operations on the directory as a whole, such as Rename() and
+// Rmdir()) plus a write lock on the shard of the directory's entry namespace containing basename are
+// taken instead. This allows adding and removing different names to proceed concurrently in a hot
+// directory. The inode layer serializes the actual entry changes (see inode.Link() & inode.Unlink()).
+func (vS *volumeStruct) getDirEntryLock(dirInodeNumber inode.InodeNumber, basename string, callerID dlm.CallerID) (dirEntryLock *dirEntryLockStruct, err error) {
+	vS.Lock()
+	shards := vS.dirLockShards
+	vS.Unlock()
+
+	dirLock, err := vS.initInodeLock(dirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+
+	if 0 == shards {
+		err = dirLock.WriteLock()
+		if nil != err {
+			return
+		}
+		dirEntryLock = &dirEntryLockStruct{dirLock: dirLock, shardLock: nil}
+		return
+	}
+
+	err = dirLock.ReadLock()
+	if nil != err {
+		return
+	}
+
+	shardLock := &dlm.RWLockStruct{
+		Domain:       dirLock.Domain,
+		LockID:       fmt.Sprintf("%s:shard.%d", dirLock.LockID, dirEntryShard(basename, shards)),
+		Notify:       nil,
+		LockCallerID: dirLock.LockCallerID,
+	}
+
+	err = shardLock.WriteLock()
+	if nil != err {
+		dirLock.Unlock()
+		return
+	}
+
+	dirEntryLock = &dirEntryLockStruct{dirLock: dirLock, shardLock: shardLock}
+	return
+}
+
+func (dirEntryLock *dirEntryLockStruct) Unlock() {
+	if nil != dirEntryLock.shardLock {
+		dirEntryLock.shardLock.Unlock()
+	}
+	dirEntryLock.dirLock.Unlock()
+}
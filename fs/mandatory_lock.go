@@ -0,0 +1,100 @@
+package fs
+
+// Mandatory byte-range locking
+//
+// Byte-range locks obtained via Flock() are normally advisory. Some SMB applications expect Windows
+// semantics in which a range locked by one owner can be neither read (if write-locked) nor written
+// (if locked at all) by anyone else. With [<volume-section>]MandatoryByteRangeLocks set to "fail"
+// or "block", Read() and Write() consult the volume's FLockMap and, should they intersect a conflicting
+// lock, either fail immediately with a retryable TryAgainError (EAGAIN) or wait for the lock to be
+// released. Read() and Write() act on behalf of no lock owner. ReadWithFlockPid() and WriteWithFlockPid()
+// instead identify the lock owner (i.e. FlockStruct.Pid) whose own locks are not in conflict.
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type mandatoryLockModeType uint8
+
+const (
+	mandatoryLockModeNone  mandatoryLockModeType = iota // byte-range locks are advisory
+	mandatoryLockModeFail                               // conflicting reads & writes fail with TryAgainError
+	mandatoryLockModeBlock                              // conflicting reads & writes wait for the lock to be released
+)
+
+func parseMandatoryLockMode(modeAsString string) (mode mandatoryLockModeType, err error) {
+	switch modeAsString {
+	case "none":
+		mode = mandatoryLockModeNone
+	case "fail":
+		mode = mandatoryLockModeFail
+	case "block":
+		mode = mandatoryLockModeBlock
+	default:
+		err = fmt.Errorf("MandatoryByteRangeLocks must be one of \"none\", \"fail\", or \"block\" (not \"%s\")", modeAsString)
+	}
+	return
+}
+
+// noteFlockChange wakes any Read() or Write() waiting on a conflicting byte-range lock.
+func (vS *volumeStruct) noteFlockChange() {
+	vS.Lock()
+	close(vS.flockChangedC)
+	vS.flockChangedC = make(chan struct{})
+	vS.Unlock()
+}
+
+// awaitMandatoryLock is called by Read() and Write() prior to obtaining their inode lock (as the
+// conflicting lock's owner may need that lock in order to finish up and release its byte-range lock).
+//
+// lockType is F_RDLCK for reads (conflicting only with write locks) or F_WRLCK for writes.
+func (vS *volumeStruct) awaitMandatoryLock(inodeNumber inode.InodeNumber, flockPid uint64, lockType int32, offset uint64, length uint64) (err error) {
+	if 0 == length {
+		return
+	}
+
+	ioFlock := &FlockStruct{
+		Type:  lockType,
+		Start: offset,
+		Len:   length,
+		Pid:   flockPid,
+	}
+
+	for {
+		vS.Lock()
+		mode := vS.mandatoryLockMode
+		if mandatoryLockModeNone == mode {
+			vS.Unlock()
+			return
+		}
+		conflict := false
+		flockList, ok := vS.FLockMap[inodeNumber]
+		if ok {
+			for e := flockList.Front(); e != nil; e = e.Next() {
+				if checkConflict(e.Value.(*FlockStruct), ioFlock) {
+					conflict = true
+					break
+				}
+			}
+		}
+		changedC := vS.flockChangedC
+		vS.Unlock()
+
+		if !conflict {
+			return
+		}
+
+		if mandatoryLockModeFail == mode {
+			stats.IncrementOperations(&stats.FsMandatoryLockFailOps)
+			err = blunder.NewError(blunder.TryAgainError, "inode %v range [%v:%v) is locked by another owner", inodeNumber, offset, offset+length)
+			return
+		}
+
+		stats.IncrementOperations(&stats.FsMandatoryLockWaitOps)
+		<-changedC
+	}
+}
@@ -0,0 +1,181 @@
+package fs
+
+// Directory listing filtered by pattern
+//
+// SMB clients enumerate directories with a wildcard (e.g. "*.jpg"). Rather than ship every entry of
+// a (possibly million entry) directory to the client for it to discard most of them, ReaddirMatch()
+// and ReaddirPlusMatch() evaluate the pattern here, scanning the directory in batches of
+// readdirMatchBatchSize entries until either maxEntries or maxBufSize matching entries have been
+// gathered or the directory is exhausted. Patterns use the syntax of path.Match() (i.e. "*", "?",
+// and "[...]" with "\" escaping). As directory entries are sorted by basename, the scan ends early
+// once past any entries starting with the literal prefix of the pattern (e.g. "IMG_" of "IMG_*.jpg").
+
+import (
+	"path"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const readdirMatchBatchSize = uint64(1024)
+
+// patternPrefix returns the literal portion of pattern preceding its first special character.
+func patternPrefix(pattern string) (prefix string) {
+	specialIndex := strings.IndexAny(pattern, "*?[\\")
+	if 0 > specialIndex {
+		prefix = pattern
+	} else {
+		prefix = pattern[:specialIndex]
+	}
+	return
+}
+
+// readdirMatch is ReadDir() returning only those entries whose basenames match pattern. Like
+// ReadDir(), it accepts as prevReturned either a string (basename) or inode.InodeDirLocation.
+func (mS *mountStruct) readdirMatch(inodeNumber inode.InodeNumber, prevReturned interface{}, pattern string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, areMoreEntries bool, err error) {
+	_, err = path.Match(pattern, "")
+	if nil != err {
+		err = blunder.NewError(blunder.InvalidArgError, "invalid pattern \"%s\": %v", pattern, err)
+		return
+	}
+
+	prefix := patternPrefix(pattern)
+
+	entries = make([]inode.DirEntry, 0)
+	bufSize := uint64(0)
+	firstBatch := true
+
+	for {
+		batch, moreBatches, readDirErr := mS.volStruct.VolumeHandle.ReadDir(inodeNumber, readdirMatchBatchSize, 0, prevReturned)
+		if nil != readDirErr {
+			if firstBatch || blunder.IsNot(readDirErr, blunder.NotFoundError) {
+				err = readDirErr
+			}
+			return
+		}
+
+		firstBatch = false
+
+		for _, entry := range batch {
+			if ("" != prefix) && (entry.Basename > prefix) && !strings.HasPrefix(entry.Basename, prefix) {
+				return
+			}
+
+			matched, _ := path.Match(pattern, entry.Basename)
+			if !matched {
+				continue
+			}
+
+			if ((0 != maxEntries) && (uint64(len(entries)) == maxEntries)) || ((0 != maxBufSize) && ((bufSize + uint64(entry.Size())) > maxBufSize)) {
+				areMoreEntries = true
+				return
+			}
+
+			entries = append(entries, entry)
+			bufSize += uint64(entry.Size())
+		}
+
+		if !moreBatches || (0 == len(batch)) {
+			return
+		}
+
+		lastEntry := batch[len(batch)-1]
+		if _, ok := prevReturned.(string); ok {
+			prevReturned = lastEntry.Basename
+		} else {
+			prevReturned = lastEntry.NextDirLocation - 1
+		}
+	}
+}
+
+// ReaddirMatch is ReaddirOne() returning up to maxEntries (and maxBufSize bytes of) entries following
+// prevDirLocation whose basenames match pattern (see match.go).
+//
+// Like ReaddirOne(), a NotFoundError is returned once no matching entries remain.
+func (mS *mountStruct) ReaddirMatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation, pattern string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, areMoreEntries bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	entries, areMoreEntries, err = mS.readdirMatchByLocation(userID, groupID, otherGroupIDs, inodeNumber, prevDirLocation, pattern, maxEntries, maxBufSize)
+	return
+}
+
+// readdirMatchByLocation is ReaddirMatch() for an operation already admitted by enterOp() (see unmount.go).
+func (mS *mountStruct) readdirMatchByLocation(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation, pattern string, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, areMoreEntries bool, err error) {
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		if nil == err {
+			mS.noteAccess(inodeNumber)
+		}
+	}()
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.X_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsReaddirMatchOps)
+
+	entries, _, areMoreEntries, err = mS.readdirHelper(inodeNumber, prevDirLocation, pattern, maxEntries, maxBufSize, inodeLock.GetCallerID())
+	if (nil == err) && (0 == len(entries)) && !areMoreEntries {
+		err = blunder.NewError(blunder.NotFoundError, "no entries matching \"%s\" follow location %v", pattern, prevDirLocation)
+	}
+	return
+}
+
+// ReaddirPlusMatch is ReaddirMatch() also returning the Stat of each entry.
+func (mS *mountStruct) ReaddirPlusMatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevDirLocation inode.InodeDirLocation, pattern string, maxEntries uint64, maxBufSize uint64) (dirEntries []inode.DirEntry, statEntries []Stat, areMoreEntries bool, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	dirEntries, areMoreEntries, err = mS.readdirMatchByLocation(userID, groupID, otherGroupIDs, inodeNumber, prevDirLocation, pattern, maxEntries, maxBufSize)
+	if nil != err {
+		return
+	}
+
+	statEntries = make([]Stat, len(dirEntries))
+	for i := range dirEntries {
+		entryInodeLock, err1 := mS.volStruct.initInodeLock(dirEntries[i].InodeNumber, nil)
+		if err = err1; err != nil {
+			return
+		}
+		err = entryInodeLock.ReadLock()
+		if err != nil {
+			return
+		}
+
+		statEntries[i], err = mS.getstatHelper(dirEntries[i].InodeNumber, entryInodeLock.GetCallerID())
+		entryInodeLock.Unlock()
+
+		if err != nil {
+			logger.ErrorWithError(err)
+			return
+		}
+	}
+
+	return
+}
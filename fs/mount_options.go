@@ -0,0 +1,68 @@
+package fs
+
+// Mount options
+//
+// Beyond MountReadOnly (see checkWritable()) and the access time policy (see atime.go), a mount may
+// specify any of:
+//
+//   MountNoExec  Access(X_OK) reports false for all but directories (where X_OK grants search rather
+//                than execute permission)
+//   MountNoSuid  setuid & setgid bits are silently dropped from the modes passed to Create(), Mkdir(),
+//                Mknod(), and Setstat(), and any already present on an inode are cleared once it is
+//                written, truncated, or chown'd via the mount
+//   MountNoDev   device files may not be used... as volumes never contain them (Mknod() of a character
+//                or block device fails with NotPermError regardless of mount options), this holds for
+//                every mount
+//
+// MountNoATime is described in atime.go.
+
+import (
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// allowedMode returns filePerm less any bits the mount does not permit to be set.
+func (mS *mountStruct) allowedMode(filePerm inode.InodeMode) inode.InodeMode {
+	if 0 != mS.options&MountNoSuid {
+		filePerm &^= inode.PosixModeSetID
+	}
+	return filePerm
+}
+
+// noExecDenies returns whether accessMode (as passed to Access()) of inodeNumber must be denied as
+// the mount is MountNoExec.
+func (mS *mountStruct) noExecDenies(inodeNumber inode.InodeNumber, accessMode inode.InodeMode) bool {
+	if (0 == accessMode&inode.X_OK) || (0 == mS.options&MountNoExec) {
+		return false
+	}
+
+	inodeType, err := mS.volStruct.VolumeHandle.GetType(inodeNumber)
+	if (nil != err) || (inode.DirType == inodeType) {
+		return false
+	}
+
+	stats.IncrementOperations(&stats.FsNoExecDeniedOps)
+	return true
+}
+
+// clearSetID clears any setuid & setgid bits of inodeNumber, being written, truncated, or chown'd
+// via a MountNoSuid mount. Caller must hold the inode's write lock.
+func (mS *mountStruct) clearSetID(inodeNumber inode.InodeNumber) (err error) {
+	if 0 == mS.options&MountNoSuid {
+		return
+	}
+
+	metadata, err := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+	if 0 == metadata.Mode&inode.PosixModeSetID {
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.SetPermMode(inodeNumber, metadata.Mode&inode.PosixModeBits&^inode.PosixModeSetID)
+	if nil == err {
+		stats.IncrementOperations(&stats.FsNoSuidSetIDClearedOps)
+	}
+	return
+}
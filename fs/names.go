@@ -0,0 +1,132 @@
+package fs
+
+// Name validation
+//
+// Each basename a caller asks to create (via Create(), Mkdir(), Mknod(), Symlink(), Link(), or Rename(),
+// or as a component of a path created via the Swift middleware) must be a valid POSIX filename:
+//
+//   ""                              fails with NotFoundError    (ENOENT)
+//   "." or ".."                     fails with InvalidArgError  (EINVAL)
+//   containing '/' or NUL           fails with InvalidArgError  (EINVAL)
+//   longer than FileNameMax bytes   fails with NameTooLongError (ENAMETOOLONG)
+//
+// In addition, [<volume-section>]ForbiddenNameCharacters lists characters no such name may contain (e.g.
+// `\:*?"<>|` for the benefit of SMB clients), the presence of which fails with InvalidArgError (EINVAL).
+//
+// Each name is checked in a single pass over its bytes against a table of the forbidden ASCII characters.
+// Only a name containing non-ASCII characters, should any non-ASCII characters also be forbidden, is then
+// checked (rune by rune) against the set of those.
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type nameRulesStruct struct {
+	forbiddenASCII    [utf8.RuneSelf]bool // '/', NUL, & any ASCII characters in ForbiddenNameCharacters
+	forbiddenNonASCII map[rune]struct{}   // any non-ASCII characters in ForbiddenNameCharacters
+}
+
+// posixNameRules forbid only the characters POSIX does.
+var posixNameRules = newNameRules("")
+
+func newNameRules(forbiddenCharacters string) (nameRules *nameRulesStruct) {
+	nameRules = &nameRulesStruct{forbiddenNonASCII: make(map[rune]struct{})}
+	nameRules.forbiddenASCII['/'] = true
+	nameRules.forbiddenASCII[0] = true
+	for _, forbiddenCharacter := range forbiddenCharacters {
+		if forbiddenCharacter < utf8.RuneSelf {
+			nameRules.forbiddenASCII[forbiddenCharacter] = true
+		} else {
+			nameRules.forbiddenNonASCII[forbiddenCharacter] = struct{}{}
+		}
+	}
+	return
+}
+
+// fetchNameRules parses [volumeSectionName]ForbiddenNameCharacters.
+func fetchNameRules(forbiddenCharacters string, volumeSectionName string) (nameRules *nameRulesStruct, err error) {
+	if !utf8.ValidString(forbiddenCharacters) || strings.ContainsRune(forbiddenCharacters, utf8.RuneError) {
+		err = fmt.Errorf("%s.ForbiddenNameCharacters must be valid UTF-8", volumeSectionName)
+		return
+	}
+	nameRules = newNameRules(forbiddenCharacters)
+	return
+}
+
+func (nameRules *nameRulesStruct) validate(baseName string) (err error) {
+	switch baseName {
+	case "":
+		err = blunder.NewError(blunder.NotFoundError, "basename is empty")
+		return
+	case ".", "..":
+		err = blunder.NewError(blunder.InvalidArgError, "basename \"%s\" is reserved", baseName)
+		return
+	}
+
+	if len(baseName) > FileNameMax {
+		err = blunder.NewError(blunder.NameTooLongError, "basename is too long. Length %v, max %v", len(baseName), FileNameMax)
+		return
+	}
+
+	nonASCII := false
+	for i := 0; i < len(baseName); i++ {
+		b := baseName[i]
+		if b >= utf8.RuneSelf {
+			nonASCII = true
+			continue
+		}
+		if nameRules.forbiddenASCII[b] {
+			err = blunder.NewError(blunder.InvalidArgError, "basename %q contains forbidden character %q", baseName, b)
+			return
+		}
+	}
+
+	if nonASCII && (0 != len(nameRules.forbiddenNonASCII)) {
+		for _, r := range baseName {
+			_, forbidden := nameRules.forbiddenNonASCII[r]
+			if forbidden {
+				err = blunder.NewError(blunder.InvalidArgError, "basename %q contains forbidden character %q", baseName, r)
+				return
+			}
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsBasenameValidateOps)
+	return
+}
+
+// validateName checks baseName against POSIX and vS's ForbiddenNameCharacters.
+func (vS *volumeStruct) validateName(baseName string) (err error) {
+	vS.Lock()
+	nameRules := vS.nameRules
+	vS.Unlock()
+
+	if nil == nameRules {
+		nameRules = posixNameRules
+	}
+
+	err = nameRules.validate(baseName)
+	if nil != err {
+		stats.IncrementOperations(&stats.FsBasenameInvalidOps)
+	}
+	return
+}
+
+// validatePathNames is validateName() of each component of path (other than "" and ".").
+func (vS *volumeStruct) validatePathNames(path string) (err error) {
+	for _, component := range strings.Split(path, "/") {
+		if ("" == component) || ("." == component) {
+			continue
+		}
+		err = vS.validateName(component)
+		if nil != err {
+			return
+		}
+	}
+	return
+}
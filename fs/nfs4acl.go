@@ -0,0 +1,98 @@
+package fs
+
+// NFSv4 ACLs
+//
+// GetNFS4ACL() and SetNFS4ACL() expose the NFSv4 ACL an inode may carry (see inode/nfs4acl.go) so that
+// an NFSv4 gateway (e.g. a Ganesha FSAL) may offer rich ACLs. Once set, the ACL rather than the mode bits
+// governs each (non-root) access check. Any caller able to see the inode may fetch its ACL (that equivalent
+// to its mode if none was set) but, as with chmod, only its owner or root may set (or, via a nil ACL,
+// remove) it. The ACL is held in the reserved inode.NFS4ACLStream, neither visible via, nor modifiable by,
+// the XAttr APIs.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+func (mS *mountStruct) GetNFS4ACL(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (acl inode.NFS4ACL, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeLock, err := mS.volStruct.getReadLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+
+	acl, err = mS.volStruct.VolumeHandle.GetNFS4ACL(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsGetNFS4ACLOps)
+	return
+}
+
+// SetNFS4ACL sets (or, if acl is nil, removes) the NFSv4 ACL of inodeNumber, setting its permission bits
+// to match.
+func (mS *mountStruct) SetNFS4ACL(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, acl inode.NFS4ACL) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() { mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpSetNFS4ACL}, err) }()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	inodeLock, err := mS.volStruct.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.P_OK) {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+
+	err = mS.volStruct.checkRetention(inodeNumber) // see retention.go
+	if nil != err {
+		return
+	}
+	err = mS.volStruct.checkInodeFlags(inodeNumber) // see inode_flags.go
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.SetNFS4ACL(inodeNumber, acl)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
+
+	stats.IncrementOperations(&stats.FsSetNFS4ACLOps)
+	return
+}
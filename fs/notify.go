@@ -0,0 +1,446 @@
+package fs
+
+// File change notification
+//
+// Each volume tracks the set of watches registered (via any mount) upon it. A watch names an inode
+// and, for a directory, whether it covers only the directory's immediate entries or its entire
+// subtree. Successful namespace and data modifying operations post a NotifyEvent that is matched
+// against every watch of the volume. Matching events are appended to a per-watch queue drained by
+// a per-watch goroutine that invokes the watch's NotifyHandler. As such, a handler never runs while
+// the posting operation holds its inode locks (important for FUSE, whose invalidation requests
+// must not be issued from within the very request that triggered them). Should a handler fall
+// far enough behind, its queued events are discarded and replaced by a single NotifyOverflow event.
+//
+// Operations that address an inode solely by InodeNumber (e.g. Write() and Setstat()) do not know
+// a parent or basename. While any watches exist, a bounded set of name hints (recorded as names are
+// looked up, created, and renamed) is consulted to fill these in when possible.
+//
+// A subtree watch is a single registration no matter how many directories lie beneath it. Rather
+// than registering every directory of the subtree, each event's ancestry is walked (via "..") up to
+// the root and matched against the watched subtree roots. While any subtree watches exist, the
+// parent of each directory walked is remembered (in a bounded map, forgotten as the directory is
+// renamed or removed) so that successive events in the same part of the tree needn't repeat the
+// walk's Lookup()s. Each watch also keeps a rollup version counter advanced by every change it
+// matches (even those discarded in a NotifyOverflow) that, along with the rest of the watch's
+// statistics, is reported by FetchWatchStats(): a caller need only rescan a subtree whose version
+// has moved.
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	notifyQueueMax       = 1024 // events queued for a single watch before collapsing into NotifyOverflow
+	notifyNameHintMax    = 4096 // name hints tracked per volume
+	notifyMaxSubtreeWalk = 4096 // ".." traversals before concluding an inode is not in a watched subtree
+	notifyParentMax      = 8192 // directory parents remembered per volume while subtree watches exist
+)
+
+type notifyNameHintStruct struct {
+	parentInodeNumber inode.InodeNumber
+	basename          string
+}
+
+type watchStruct struct {
+	sync.Mutex
+	cond        *sync.Cond
+	watchID     WatchID
+	mountID     MountID
+	volStruct   *volumeStruct
+	inodeNumber inode.InodeNumber
+	subtree     bool
+	handler     NotifyHandler
+	queue       []NotifyEvent
+	removed     bool
+	version     uint64 // rollup of changes matched (including those discarded in a NotifyOverflow)
+	delivered   uint64
+	overflows   uint64
+}
+
+type notifyStruct struct {
+	sync.Mutex
+	watchMap         map[WatchID]*watchStruct
+	subtreeCount     uint64                                     // number of watches in watchMap with subtree == true
+	nameHintMap      map[inode.InodeNumber]notifyNameHintStruct // only maintained while 0 < len(watchMap)
+	parentMap        map[inode.InodeNumber]inode.InodeNumber    // directory => its parent; only maintained while 0 < subtreeCount
+	parentGeneration uint64                                     // advanced as directories may be renamed or removed
+}
+
+func (mS *mountStruct) AddWatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, subtree bool, handler NotifyHandler) (watchID WatchID, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.R_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+	if subtree {
+		inodeType, getTypeErr := mS.volStruct.VolumeHandle.GetType(inodeNumber)
+		if nil != getTypeErr {
+			err = getTypeErr
+			return
+		}
+		if inode.DirType != inodeType {
+			err = fmt.Errorf("%s: subtree watch requested on non-directory inode %v", "fs.AddWatch()", inodeNumber)
+			err = blunder.AddError(err, blunder.NotDirError)
+			return
+		}
+	}
+
+	globals.Lock()
+	globals.lastWatchID++
+	watchID = globals.lastWatchID
+	globals.Unlock()
+
+	watch := &watchStruct{
+		watchID:     watchID,
+		mountID:     mS.id,
+		volStruct:   mS.volStruct,
+		inodeNumber: inodeNumber,
+		subtree:     subtree,
+		handler:     handler,
+		queue:       make([]NotifyEvent, 0),
+		removed:     false,
+	}
+	watch.cond = sync.NewCond(watch)
+
+	notify := &mS.volStruct.notify
+
+	notify.Lock()
+	if 0 == len(notify.watchMap) {
+		notify.nameHintMap = make(map[inode.InodeNumber]notifyNameHintStruct)
+	}
+	notify.watchMap[watchID] = watch
+	if subtree {
+		if 0 == notify.subtreeCount {
+			notify.parentMap = make(map[inode.InodeNumber]inode.InodeNumber)
+		}
+		notify.subtreeCount++
+	}
+	notify.Unlock()
+
+	go watch.deliver()
+
+	stats.IncrementOperations(&stats.FsWatchAddOps)
+	return
+}
+
+func (mS *mountStruct) RemoveWatch(watchID WatchID) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	notify := &mS.volStruct.notify
+
+	notify.Lock()
+	watch, ok := notify.watchMap[watchID]
+	if !ok {
+		notify.Unlock()
+		err = fmt.Errorf("%s: unknown WatchID %v", "fs.RemoveWatch()", watchID)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+	notify.removeWatchWhileLocked(watch)
+	notify.Unlock()
+
+	stats.IncrementOperations(&stats.FsWatchRemoveOps)
+	return
+}
+
+func (notify *notifyStruct) removeWatchWhileLocked(watch *watchStruct) {
+	delete(notify.watchMap, watch.watchID)
+	if watch.subtree {
+		notify.subtreeCount--
+		if 0 == notify.subtreeCount {
+			notify.parentMap = nil
+		}
+	}
+	if 0 == len(notify.watchMap) {
+		notify.nameHintMap = nil
+	}
+
+	watch.Lock()
+	watch.removed = true
+	watch.queue = nil
+	watch.cond.Signal()
+	watch.Unlock()
+}
+
+// removeAllWatches is called as a volume is taken offline.
+func (vS *volumeStruct) removeAllWatches() {
+	vS.notify.Lock()
+	for _, watch := range vS.notify.watchMap {
+		vS.notify.removeWatchWhileLocked(watch)
+	}
+	vS.notify.Unlock()
+}
+
+// removeMountWatches is called as mountID is unmounted.
+func (vS *volumeStruct) removeMountWatches(mountID MountID) {
+	vS.notify.Lock()
+	for _, watch := range vS.notify.watchMap {
+		if mountID == watch.mountID {
+			vS.notify.removeWatchWhileLocked(watch)
+		}
+	}
+	vS.notify.Unlock()
+}
+
+// deliver is the per-watch goroutine invoking the watch's NotifyHandler for each queued NotifyEvent.
+func (watch *watchStruct) deliver() {
+	watch.Lock()
+	for {
+		for !watch.removed && (0 == len(watch.queue)) {
+			watch.cond.Wait()
+		}
+		if watch.removed {
+			watch.Unlock()
+			return
+		}
+		event := watch.queue[0]
+		watch.queue = watch.queue[1:]
+		watch.Unlock()
+
+		watch.handler(watch.watchID, event)
+
+		watch.Lock()
+		watch.delivered++
+	}
+}
+
+func (mS *mountStruct) FetchWatchStats(watchID WatchID) (watchStats WatchStats, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	notify := &mS.volStruct.notify
+
+	notify.Lock()
+	watch, ok := notify.watchMap[watchID]
+	notify.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: unknown WatchID %v", "fs.FetchWatchStats()", watchID)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	watch.Lock()
+	watchStats = WatchStats{
+		Version:   watch.version,
+		Delivered: watch.delivered,
+		Queued:    uint64(len(watch.queue)),
+		Overflows: watch.overflows,
+	}
+	watch.Unlock()
+
+	return
+}
+
+func (watch *watchStruct) enqueue(event NotifyEvent) {
+	watch.Lock()
+	if !watch.removed {
+		watch.version++
+		if len(watch.queue) >= notifyQueueMax {
+			watch.queue = append(watch.queue[:0], NotifyEvent{Type: NotifyOverflow, InodeNumber: watch.inodeNumber})
+			watch.overflows++
+			stats.IncrementOperations(&stats.FsNotifyOverflowOps)
+		}
+		watch.queue = append(watch.queue, event)
+		watch.cond.Signal()
+	}
+	watch.Unlock()
+}
+
+// noteName records a name hint for inodeNumber (if any watches exist) along with its parent (see freeze.go).
+func (vS *volumeStruct) noteName(inodeNumber inode.InodeNumber, parentInodeNumber inode.InodeNumber, basename string) {
+	vS.noteParent(inodeNumber, parentInodeNumber)
+
+	vS.notify.Lock()
+	if nil != vS.notify.nameHintMap {
+		if len(vS.notify.nameHintMap) >= notifyNameHintMax {
+			for evictInodeNumber := range vS.notify.nameHintMap {
+				delete(vS.notify.nameHintMap, evictInodeNumber)
+				break
+			}
+		}
+		vS.notify.nameHintMap[inodeNumber] = notifyNameHintStruct{parentInodeNumber: parentInodeNumber, basename: basename}
+	}
+	vS.notify.Unlock()
+}
+
+// notifyName posts an event about basename in dirInodeNumber.
+func (vS *volumeStruct) notifyName(eventType NotifyEventType, dirInodeNumber inode.InodeNumber, basename string, inodeNumber inode.InodeNumber) {
+	if NotifyUnlink == eventType {
+		vS.forgetParent(inodeNumber)
+	} else if NotifyRenameFrom != eventType {
+		vS.noteParent(inodeNumber, dirInodeNumber)
+	}
+
+	vS.notify.Lock()
+	if nil != vS.notify.nameHintMap {
+		if NotifyUnlink == eventType {
+			delete(vS.notify.nameHintMap, inodeNumber)
+		} else if NotifyRenameFrom != eventType {
+			vS.notify.nameHintMap[inodeNumber] = notifyNameHintStruct{parentInodeNumber: dirInodeNumber, basename: basename}
+		}
+	}
+	if (NotifyUnlink == eventType) || (NotifyRenameFrom == eventType) {
+		vS.notify.parentGeneration++
+		delete(vS.notify.parentMap, inodeNumber)
+	}
+	vS.notify.Unlock()
+
+	vS.postNotifyEvent(NotifyEvent{
+		Type:              eventType,
+		InodeNumber:       inodeNumber,
+		ParentInodeNumber: dirInodeNumber,
+		Basename:          basename,
+	})
+}
+
+// notifyRename posts the NotifyRenameFrom/NotifyRenameTo pair for a completed Move(). Caller must still hold the
+// locks on both directories so that dstBasename continues to reference the moved inode.
+func (vS *volumeStruct) notifyRename(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) {
+	vS.notify.Lock()
+	watchesExist := (0 < len(vS.notify.watchMap))
+	vS.notify.parentGeneration++ // even absent watches, any ".." fetched before the Move() is now suspect
+	vS.notify.Unlock()
+
+	inodeNumber, err := vS.VolumeHandle.Lookup(dstDirInodeNumber, dstBasename)
+	if nil != err {
+		logger.WarnfWithError(err, "fs.notifyRename(): unable to find renamed inode %v/%v", dstDirInodeNumber, dstBasename)
+		return
+	}
+
+	if !watchesExist {
+		vS.noteParent(inodeNumber, dstDirInodeNumber) // see freeze.go
+		return
+	}
+
+	vS.notifyName(NotifyRenameFrom, srcDirInodeNumber, srcBasename, inodeNumber)
+	vS.notifyName(NotifyRenameTo, dstDirInodeNumber, dstBasename, inodeNumber)
+}
+
+// notifyInode posts an event about inodeNumber, using a name hint (if available) for its parent and basename.
+func (vS *volumeStruct) notifyInode(eventType NotifyEventType, inodeNumber inode.InodeNumber) {
+	event := NotifyEvent{Type: eventType, InodeNumber: inodeNumber}
+
+	vS.notify.Lock()
+	if 0 == len(vS.notify.watchMap) {
+		vS.notify.Unlock()
+		return
+	}
+	nameHint, ok := vS.notify.nameHintMap[inodeNumber]
+	vS.notify.Unlock()
+
+	if ok {
+		event.ParentInodeNumber = nameHint.parentInodeNumber
+		event.Basename = nameHint.basename
+	}
+
+	vS.postNotifyEvent(event)
+}
+
+func (vS *volumeStruct) postNotifyEvent(event NotifyEvent) {
+	var (
+		ancestorSet map[inode.InodeNumber]struct{}
+		matches     []*watchStruct
+	)
+
+	vS.notify.Lock()
+	if 0 == len(vS.notify.watchMap) {
+		vS.notify.Unlock()
+		return
+	}
+	needAncestors := (0 < vS.notify.subtreeCount) && (0 != event.ParentInodeNumber)
+	vS.notify.Unlock()
+
+	if needAncestors {
+		ancestorSet = vS.fetchAncestorSet(event.ParentInodeNumber)
+	}
+
+	vS.notify.Lock()
+	for _, watch := range vS.notify.watchMap {
+		if (watch.inodeNumber == event.InodeNumber) || (watch.inodeNumber == event.ParentInodeNumber) {
+			matches = append(matches, watch)
+		} else if watch.subtree && (nil != ancestorSet) {
+			_, ok := ancestorSet[watch.inodeNumber]
+			if ok {
+				matches = append(matches, watch)
+			}
+		}
+	}
+	vS.notify.Unlock()
+
+	for _, watch := range matches {
+		watch.enqueue(event)
+	}
+
+	stats.IncrementOperations(&stats.FsNotifyEventOps)
+}
+
+// fetchAncestorSet returns the set of directories containing dirInodeNumber (including itself).
+func (vS *volumeStruct) fetchAncestorSet(dirInodeNumber inode.InodeNumber) (ancestorSet map[inode.InodeNumber]struct{}) {
+	ancestorSet = make(map[inode.InodeNumber]struct{})
+
+	for i := 0; i < notifyMaxSubtreeWalk; i++ {
+		ancestorSet[dirInodeNumber] = struct{}{}
+		if inode.RootDirInodeNumber == dirInodeNumber {
+			return
+		}
+
+		vS.notify.Lock()
+		parentInodeNumber, ok := vS.notify.parentMap[dirInodeNumber]
+		parentGeneration := vS.notify.parentGeneration
+		vS.notify.Unlock()
+
+		if ok {
+			stats.IncrementOperations(&stats.FsNotifyParentCacheHitOps)
+		} else {
+			stats.IncrementOperations(&stats.FsNotifyParentCacheMissOps)
+
+			var err error
+			parentInodeNumber, err = vS.VolumeHandle.Lookup(dirInodeNumber, "..")
+			if nil != err {
+				logger.WarnfWithError(err, "fs.fetchAncestorSet(): unable to find parent of inode %v", dirInodeNumber)
+				return
+			}
+
+			vS.notify.Lock()
+			if (nil != vS.notify.parentMap) && (parentGeneration == vS.notify.parentGeneration) {
+				if len(vS.notify.parentMap) >= notifyParentMax {
+					for evictInodeNumber := range vS.notify.parentMap {
+						delete(vS.notify.parentMap, evictInodeNumber)
+						break
+					}
+				}
+				vS.notify.parentMap[dirInodeNumber] = parentInodeNumber
+			}
+			vS.notify.Unlock()
+		}
+
+		dirInodeNumber = parentInodeNumber
+	}
+
+	return
+}
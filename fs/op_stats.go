@@ -0,0 +1,128 @@
+package fs
+
+// Per-mount & per-volume operation statistics
+//
+// The fs stats (e.g. stats.FsReadOps) are process-wide, so one busy client obscures the activity of all
+// others. Each mount and each volume therefore also tallies its own OpStats: operations begun (see
+// enterOp()), those of them that failed (see exitOp()), and the bytes read and written. Each tally is also
+// sent to the stats subsystem as <prefix>.operations, <prefix>.errors, <prefix>.read.bytes, and
+// <prefix>.write.bytes where <prefix> is proxyfs.fs.volume.<volume-name> or proxyfs.fs.mount.<mount-id>.
+// FetchVolumeOpStats() returns those of a volume and of each of its current mounts, as served by the
+// httpserver at /volume/<volume-name>/op-stats.
+//
+// As with the operation count of trend.go, an operation performed on behalf of another (e.g. the
+// ResolvePathAt() within an ...At() call) is counted separately.
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type opStatsStruct struct {
+	operations   uint64 // accessed atomically
+	errors       uint64 // accessed atomically
+	readBytes    uint64 // accessed atomically
+	writtenBytes uint64 // accessed atomically
+
+	operationsStatName   string
+	errorsStatName       string
+	readBytesStatName    string
+	writtenBytesStatName string
+}
+
+func (opStats *opStatsStruct) init(statNamePrefix string) {
+	opStats.operationsStatName = statNamePrefix + ".operations"
+	opStats.errorsStatName = statNamePrefix + ".errors"
+	opStats.readBytesStatName = statNamePrefix + ".read.bytes"
+	opStats.writtenBytesStatName = statNamePrefix + ".write.bytes"
+}
+
+func (vS *volumeStruct) initOpStats() {
+	vS.opStats.init("proxyfs.fs.volume." + vS.volumeName)
+}
+
+func (mS *mountStruct) initOpStats() {
+	mS.opStats.init("proxyfs.fs.mount." + strconv.FormatUint(uint64(mS.id), 10))
+}
+
+func (opStats *opStatsStruct) noteBegun() {
+	atomic.AddUint64(&opStats.operations, 1)
+	stats.IncrementOperations(&opStats.operationsStatName)
+}
+
+func (opStats *opStatsStruct) noteFailed() {
+	atomic.AddUint64(&opStats.errors, 1)
+	stats.IncrementOperations(&opStats.errorsStatName)
+}
+
+func (opStats *opStatsStruct) noteRead(numBytes uint64) {
+	atomic.AddUint64(&opStats.readBytes, numBytes)
+	stats.IncrementBy(&opStats.readBytesStatName, numBytes)
+}
+
+func (opStats *opStatsStruct) noteWritten(numBytes uint64) {
+	atomic.AddUint64(&opStats.writtenBytes, numBytes)
+	stats.IncrementBy(&opStats.writtenBytesStatName, numBytes)
+}
+
+func (opStats *opStatsStruct) snapshot() (snapshot OpStats) {
+	snapshot = OpStats{
+		Operations:   atomic.LoadUint64(&opStats.operations),
+		Errors:       atomic.LoadUint64(&opStats.errors),
+		ReadBytes:    atomic.LoadUint64(&opStats.readBytes),
+		WrittenBytes: atomic.LoadUint64(&opStats.writtenBytes),
+	}
+	return
+}
+
+// noteOpBegun tallies an operation admitted by enterOp().
+func (mS *mountStruct) noteOpBegun() {
+	mS.opStats.noteBegun()
+	mS.volStruct.opStats.noteBegun()
+}
+
+// noteOpFailed tallies an operation that returned an error to exitOp().
+func (mS *mountStruct) noteOpFailed() {
+	mS.opStats.noteFailed()
+	mS.volStruct.opStats.noteFailed()
+}
+
+func (mS *mountStruct) noteRead(numBytes uint64) {
+	mS.opStats.noteRead(numBytes)
+	mS.volStruct.opStats.noteRead(numBytes)
+}
+
+func (mS *mountStruct) noteWritten(numBytes uint64) {
+	mS.opStats.noteWritten(numBytes)
+	mS.volStruct.opStats.noteWritten(numBytes)
+}
+
+func fetchVolumeOpStats(volumeName string) (volumeOpStats VolumeOpStats, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	volumeOpStats.Volume = vS.opStats.snapshot()
+	volumeOpStats.Mounts = make(map[MountID]OpStats)
+
+	globals.Lock()
+	vS.Lock()
+	for _, mountID := range vS.mountList {
+		mS, ok := globals.mountMap[mountID]
+		if ok {
+			volumeOpStats.Mounts[mountID] = mS.opStats.snapshot()
+		}
+	}
+	vS.Unlock()
+	globals.Unlock()
+
+	return
+}
+
+func (mS *mountStruct) FetchOpStats() (opStats OpStats) {
+	opStats = mS.opStats.snapshot()
+	return
+}
@@ -0,0 +1,265 @@
+package fs
+
+// Unnamed (O_TMPFILE-style) files
+//
+// CreateUnlinked() creates a file inode with no directory entry (i.e. a LinkCount of zero). It may
+// be written, read, and Setstat()'d by InodeNumber like any other file, then either given a name via
+// LinkByInode() or discarded via ReleaseUnlinked(). Atomically replacing an existing file is then a
+// matter of LinkByInode() to a scratch name followed by a Rename() over the original.
+//
+// As nothing in the namespace refers to an unnamed inode, a crash (of ProxyFS or of the client)
+// before either happens would leak it. Each volume therefore records its unnamed inodes in
+// OrphanStream, a reserved stream on the root directory inode that is rewritten before
+// CreateUnlinked() returns. As with the volume state (see volume_state.go), the stream rides along
+// with each headhunter checkpoint. When the volume is next brought up, reapOrphans() destroys every
+// recorded inode whose LinkCount is still zero. Removal from OrphanStream is lazy: an inode that
+// has since been linked or released is merely dropped from the in-memory set (and hence from the
+// next rewrite of the stream) or, failing that, skipped by reapOrphans().
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// OrphanStream is the reserved stream on the root directory inode listing the volume's unnamed inodes.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const OrphanStream = "proxyfs.orphans"
+
+func (vS *volumeStruct) isOrphan(inodeNumber inode.InodeNumber) (ok bool) {
+	vS.Lock()
+	_, ok = vS.orphanMap[inodeNumber]
+	vS.Unlock()
+	return
+}
+
+// trackOrphan adds inodeNumber to the volume's unnamed inodes and durably records the updated set.
+//
+// Caller must not hold any inode locks (the root directory inode's write lock is obtained).
+func (vS *volumeStruct) trackOrphan(inodeNumber inode.InodeNumber) (err error) {
+	vS.Lock()
+	vS.orphanMap[inodeNumber] = struct{}{}
+	vS.Unlock()
+
+	rootInodeLock, err := vS.getWriteLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	// Snapshot while holding the root directory inode's write lock so that concurrent callers cannot
+	// overwrite a newer set with an older one
+
+	vS.Lock()
+	orphans := make([]inode.InodeNumber, 0, len(vS.orphanMap))
+	for orphanInodeNumber := range vS.orphanMap {
+		orphans = append(orphans, orphanInodeNumber)
+	}
+	vS.Unlock()
+
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i] < orphans[j] })
+
+	buf, err := json.Marshal(orphans)
+	if nil != err {
+		err = blunder.AddError(err, blunder.PackError)
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(inode.RootDirInodeNumber, OrphanStream, buf)
+
+	return
+}
+
+// untrackOrphan removes inodeNumber from the volume's unnamed inodes (but see "lazy" above).
+func (vS *volumeStruct) untrackOrphan(inodeNumber inode.InodeNumber) {
+	vS.Lock()
+	delete(vS.orphanMap, inodeNumber)
+	vS.Unlock()
+}
+
+// reapOrphans destroys any inodes left unnamed by a previous instance of the volume.
+//
+// A missing OrphanStream is not an error (e.g. a freshly formatted volume).
+func (vS *volumeStruct) reapOrphans() (err error) {
+	rootInodeLock, err := vS.getWriteLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	buf, err := vS.VolumeHandle.GetStream(inode.RootDirInodeNumber, OrphanStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	var orphans []inode.InodeNumber
+
+	err = json.Unmarshal(buf, &orphans)
+	if nil != err {
+		err = blunder.AddError(fmt.Errorf("fs: corrupt %s stream in volume '%s': %v", OrphanStream, vS.volumeName, err), blunder.UnpackError)
+		return
+	}
+
+	for _, orphanInodeNumber := range orphans {
+		orphanInodeLock, lockErr := vS.getWriteLock(orphanInodeNumber, nil)
+		if nil != lockErr {
+			err = lockErr
+			return
+		}
+
+		linkCount, linkCountErr := vS.VolumeHandle.GetLinkCount(orphanInodeNumber)
+		if (nil == linkCountErr) && (0 == linkCount) {
+			destroyErr := vS.VolumeHandle.Destroy(orphanInodeNumber)
+			if nil == destroyErr {
+				stats.IncrementOperations(&stats.FsOrphanReapOps)
+			} else {
+				logger.WarnfWithError(destroyErr, "fs: couldn't destroy orphaned inode %v in volume '%s'", orphanInodeNumber, vS.volumeName)
+			}
+		}
+
+		orphanInodeLock.Unlock()
+	}
+
+	err = vS.VolumeHandle.DeleteStream(inode.RootDirInodeNumber, OrphanStream)
+
+	return
+}
+
+// CreateUnlinked creates a file inode that has no directory entry (see orphan.go).
+//
+// As for Create(), the caller must be able to write to dirInodeNumber (although no entry is added to it).
+func (mS *mountStruct) CreateUnlinked(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	dirInodeLock, err := mS.volStruct.getReadLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		dirInodeLock.Unlock()
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.W_OK|inode.X_OK) {
+		dirInodeLock.Unlock()
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	fileGroupID, fileMode := mS.inheritFromDir(userID, groupID, otherGroupIDs, dirInodeNumber, inode.FileType, mS.createMode(filePerm))
+	fileInodeNumber, err = mS.volStruct.VolumeHandle.CreateFile(fileMode, userID, fileGroupID)
+	dirInodeLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.trackOrphan(fileInodeNumber)
+	if nil != err {
+		mS.volStruct.untrackOrphan(fileInodeNumber)
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(fileInodeNumber)
+		if nil != destroyErr {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed trackOrphan() in fs.CreateUnlinked", fileInodeNumber)
+		}
+		fileInodeNumber = 0
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsCreateUnlinkedOps)
+	return
+}
+
+// LinkByInode gives an inode created by CreateUnlinked() its first name.
+func (mS *mountStruct) LinkByInode(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	if !mS.volStruct.isOrphan(targetInodeNumber) {
+		err = blunder.NewError(blunder.InvalidArgError, "inode %v is not an unnamed inode", targetInodeNumber)
+		return
+	}
+
+	err = mS.link(userID, groupID, otherGroupIDs, dirInodeNumber, basename, targetInodeNumber)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.untrackOrphan(targetInodeNumber)
+
+	stats.IncrementOperations(&stats.FsLinkByInodeOps)
+	return
+}
+
+// ReleaseUnlinked destroys an inode created by CreateUnlinked() that was never given a name.
+//
+// If the inode has since been linked, it is simply no longer tracked as unnamed.
+func (mS *mountStruct) ReleaseUnlinked(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	if !mS.volStruct.isOrphan(inodeNumber) {
+		err = blunder.NewError(blunder.InvalidArgError, "inode %v is not an unnamed inode", inodeNumber)
+		return
+	}
+
+	inodeLock, err := mS.volStruct.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	linkCount, err := mS.volStruct.VolumeHandle.GetLinkCount(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if 0 == linkCount {
+		mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
+		err = mS.volStruct.VolumeHandle.Destroy(inodeNumber)
+		if nil != err {
+			return
+		}
+	}
+
+	mS.volStruct.untrackOrphan(inodeNumber)
+
+	stats.IncrementOperations(&stats.FsReleaseUnlinkedOps)
+	return
+}
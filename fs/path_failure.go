@@ -0,0 +1,94 @@
+package fs
+
+// Path resolution failures
+//
+// Should resolvePath() (and hence LookupPath() and the Middleware...() APIs resolving a path) fail
+// partway along a path, the error returned (still bearing the usual errno) also carries a
+// PathFailureStruct, retrieved via PathFailure(), describing why and at which path component it failed.
+// The error also bears the HTTP status (see blunder.HTTPCode()) best describing the failure to a Swift
+// client: 404 should a component not exist, 409 should a component other than the last be a file (or
+// too many symlinks be followed), 403 should a directory not be searchable, and 500 otherwise (e.g. a
+// Swift or lock failure). This lets the Swift middleware tell, e.g., a GET of a missing object from one
+// of an object "within" another and say which component was at fault (see jrpcfs.PathFailure).
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ansel1/merry"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+type PathFailureReason uint32
+
+const (
+	PathFailureNotFound    PathFailureReason = iota + 1 // Segment does not exist (ENOENT)
+	PathFailureNotDir                                   // Segment is not a directory yet is followed by further components (ENOTDIR)
+	PathFailureSymlinkLoop                              // more than [<volume-section>]MaxSymlinks symlinks were followed reaching Segment (ELOOP)
+	PathFailurePermDenied                               // ParentPath may not be searched or Segment, a symlink, not followed (EACCES)
+	PathFailureInternal                                 // any other failure
+)
+
+func (reason PathFailureReason) String() string {
+	switch reason {
+	case PathFailureNotFound:
+		return "NotFound"
+	case PathFailureNotDir:
+		return "NotDir"
+	case PathFailureSymlinkLoop:
+		return "SymlinkLoop"
+	case PathFailurePermDenied:
+		return "PermDenied"
+	default:
+		return "Internal"
+	}
+}
+
+// PathFailureStruct describes where resolution of a path failed.
+type PathFailureStruct struct {
+	Reason     PathFailureReason
+	ParentPath string // directory (relative to where resolution started, with any symlinks followed) containing Segment
+	Segment    string // path component at which resolution failed
+	Remaining  uint64 // path components (with any symlinks followed) yet to be resolved beyond Segment
+}
+
+const pathFailureKey = "pathFailure"
+
+// PathFailure returns the PathFailureStruct carried by err (nil if err is not a path resolution failure).
+func PathFailure(err error) (pathFailure *PathFailureStruct) {
+	pathFailure, _ = merry.Value(err, pathFailureKey).(*PathFailureStruct)
+	return
+}
+
+// addPathFailure returns err, resolving parentSegments followed by segment (with remaining components
+// beyond it) having failed, annotated with the corresponding PathFailureStruct and HTTP status.
+func addPathFailure(err error, parentSegments []string, segment string, remaining int) error {
+	var httpStatus int
+
+	pathFailure := &PathFailureStruct{
+		ParentPath: strings.Join(parentSegments, "/"),
+		Segment:    segment,
+		Remaining:  uint64(remaining),
+	}
+
+	switch {
+	case blunder.Is(err, blunder.NotFoundError):
+		pathFailure.Reason = PathFailureNotFound
+		httpStatus = http.StatusNotFound
+	case blunder.Is(err, blunder.NotDirError):
+		pathFailure.Reason = PathFailureNotDir
+		httpStatus = http.StatusConflict
+	case blunder.Is(err, blunder.TooManySymlinksError):
+		pathFailure.Reason = PathFailureSymlinkLoop
+		httpStatus = http.StatusConflict
+	case blunder.Is(err, blunder.PermDeniedError):
+		pathFailure.Reason = PathFailurePermDenied
+		httpStatus = http.StatusForbidden
+	default:
+		pathFailure.Reason = PathFailureInternal
+		httpStatus = http.StatusInternalServerError
+	}
+
+	return blunder.AddHTTPCode(merry.WithValue(err, pathFailureKey, pathFailure), httpStatus)
+}
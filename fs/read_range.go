@@ -0,0 +1,67 @@
+package fs
+
+// Middleware GET ranges
+//
+// MiddlewareGetObject() is passed the byteranges of an HTTP Range header (see ReadRangeIn). Each is
+// validated against, and clamped to, the file's size before its read plan is fetched:
+//
+//   Offset & Len   bytes [Offset:Offset+Len) less any beyond the end of the file
+//   Offset only    bytes [Offset:) to the end of the file
+//   Len only       the last Len bytes of the file (all of it, should it be shorter)
+//
+// A range starting at or beyond the end of the file (or of zero length) is unsatisfiable. Per RFC 7233,
+// unsatisfiable ranges are dropped so long as one remains; should none remain, RangeNotSatisfiable is
+// returned (which the middleware answers with a 416). As for Swift, an empty file satisfies no range, so
+// any given are ignored and the (empty) file returned in whole.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+type readRangeStruct struct {
+	offset uint64
+	length uint64
+}
+
+// normalizeReadRanges returns the satisfiable ranges of readRangeIn for a file of fileSize bytes (in the
+// order requested) clamped to the file. No ranges are returned for an empty file.
+func normalizeReadRanges(readRangeIn []ReadRangeIn, fileSize uint64) (readRanges []readRangeStruct, err error) {
+	for _, rangeIn := range readRangeIn {
+		var readRange readRangeStruct
+
+		switch {
+		case (nil == rangeIn.Offset) && (nil == rangeIn.Len):
+			err = blunder.NewError(blunder.InvalidArgError, "read range specifies neither Offset nor Len")
+			return
+		case nil == rangeIn.Offset:
+			readRange.length = *rangeIn.Len
+			if readRange.length > fileSize {
+				readRange.length = fileSize
+			}
+			readRange.offset = fileSize - readRange.length
+		case nil == rangeIn.Len:
+			readRange.offset = *rangeIn.Offset
+			if readRange.offset < fileSize {
+				readRange.length = fileSize - readRange.offset
+			}
+		default:
+			readRange.offset = *rangeIn.Offset
+			if readRange.offset < fileSize {
+				readRange.length = *rangeIn.Len
+				if readRange.length > (fileSize - readRange.offset) {
+					readRange.length = fileSize - readRange.offset
+				}
+			}
+		}
+
+		if 0 < readRange.length {
+			readRanges = append(readRanges, readRange)
+		}
+	}
+
+	if (0 < len(readRangeIn)) && (0 == len(readRanges)) && (0 < fileSize) {
+		err = blunder.NewError(blunder.RangeNotSatisfiable, "no read range satisfiable for file size %v", fileSize)
+	}
+
+	return
+}
@@ -0,0 +1,128 @@
+package fs
+
+// Parallel ReaddirPlus
+//
+// ReaddirPlus() returns the Stat of each entry it lists. Rather than lock & stat each entry in turn, the
+// entries are divided into batches of up to readdirPlusBatchSize that up to [<volume-section>]ReaddirPlusParallelism
+// (0 or 1 == serially) workers stat concurrently, each storing its results at the entries' positions so that
+// the output order is preserved.
+//
+// A worker read locks its batch's inodes with a single dlm.CallerID and fetches their metadata with one
+// inode.VolumeHandle.GetMetadataMulti(). As the worker holds many locks at once, it only tries for each (see
+// tryEnsureReadLock()); an entry whose lock is unavailable is instead stated once the rest of the batch's
+// locks are released, exactly as a serial ReaddirPlus() would, so no worker ever waits for a lock while
+// holding another.
+
+import (
+	"sync"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+const (
+	defaultReaddirPlusParallelism = 8
+	readdirPlusBatchSize          = 32
+)
+
+// readdirPlusStats returns the Stat of each of dirEntries (in the same order).
+func (mS *mountStruct) readdirPlusStats(dirEntries []inode.DirEntry) (statEntries []Stat, err error) {
+	statEntries = make([]Stat, len(dirEntries))
+	errs := make([]error, len(dirEntries))
+
+	mS.volStruct.Lock()
+	parallelism := mS.volStruct.readdirPlusParallelism
+	mS.volStruct.Unlock()
+
+	if 1 > parallelism {
+		parallelism = 1
+	}
+
+	batchChan := make(chan int, (len(dirEntries)+readdirPlusBatchSize-1)/readdirPlusBatchSize)
+	for start := 0; start < len(dirEntries); start += readdirPlusBatchSize {
+		batchChan <- start
+	}
+	close(batchChan)
+
+	workers := uint64(cap(batchChan))
+	if workers > parallelism {
+		workers = parallelism
+	}
+
+	var wg sync.WaitGroup
+
+	for worker := uint64(0); worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batchChan {
+				end := start + readdirPlusBatchSize
+				if end > len(dirEntries) {
+					end = len(dirEntries)
+				}
+				mS.readdirPlusStatBatch(dirEntries[start:end], statEntries[start:end], errs[start:end])
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err = range errs {
+		if nil != err {
+			return
+		}
+	}
+	return
+}
+
+// readdirPlusStatBatch fills in statEntries & errs for each of dirEntries.
+func (mS *mountStruct) readdirPlusStatBatch(dirEntries []inode.DirEntry, statEntries []Stat, errs []error) {
+	callerID := dlm.GenerateCallerID()
+
+	locks := make([]*dlm.RWLockStruct, 0, len(dirEntries))
+	lockedIndices := make([]int, 0, len(dirEntries))
+	lockedInodeNumbers := make([]inode.InodeNumber, 0, len(dirEntries))
+	unlockedIndices := make([]int, 0)
+
+	for i := range dirEntries {
+		lock, err := mS.volStruct.tryEnsureReadLock(dirEntries[i].InodeNumber, callerID)
+		if nil != err {
+			if blunder.Is(err, blunder.TryAgainError) {
+				unlockedIndices = append(unlockedIndices, i)
+			} else {
+				errs[i] = err
+			}
+			continue
+		}
+		if nil != lock {
+			locks = append(locks, lock)
+		}
+		lockedIndices = append(lockedIndices, i)
+		lockedInodeNumbers = append(lockedInodeNumbers, dirEntries[i].InodeNumber)
+	}
+
+	metadataSlice, metadataErrs := mS.volStruct.VolumeHandle.GetMetadataMulti(lockedInodeNumbers)
+
+	for _, lock := range locks {
+		lock.Unlock()
+	}
+
+	for j, i := range lockedIndices {
+		if nil == metadataErrs[j] {
+			statEntries[i] = statFromMetadata(dirEntries[i].InodeNumber, metadataSlice[j])
+		} else {
+			errs[i] = metadataErrs[j]
+		}
+	}
+
+	for _, i := range unlockedIndices {
+		entryInodeLock, err := mS.volStruct.getReadLock(dirEntries[i].InodeNumber, nil)
+		if nil != err {
+			errs[i] = err
+			continue
+		}
+		statEntries[i], errs[i] = mS.getstatHelper(dirEntries[i].InodeNumber, entryInodeLock.GetCallerID())
+		entryInodeLock.Unlock()
+	}
+}
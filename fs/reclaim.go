@@ -0,0 +1,180 @@
+package fs
+
+// Reclaimable space
+//
+// AnalyzeReclaimable() walks a volume's namespace reporting the space that could be reclaimed:
+// chiefly the unreferenced bytes trapped in partially overwritten LogSegments (see
+// inode/fragmentation.go), listing each file holding any as a ReclaimCandidate, but also the unnamed
+// inodes awaiting reaping (see orphan.go). Reclaim() then compacts the candidates an operator selects,
+// within an optional time budget.
+//
+// Wholly unreferenced LogSegments are already deleted as each file is flushed and destroyed inodes are
+// reclaimed in the background (see inode/destroy.go), so neither appears in the report. ProxyFS keeps
+// neither a trash nor prior versions of files, and never allocates space for holes in sparse files.
+
+import (
+	"sort"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+func lookupVolume(volumeName string) (vS *volumeStruct, err error) {
+	globals.Lock()
+	vS, ok := globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = blunder.NewError(blunder.NotFoundError, "volume '%s' not found", volumeName)
+	}
+	return
+}
+
+// fragmentationReport returns the FragmentationReport of fileInodeNumber while holding its write lock.
+func (vS *volumeStruct) fragmentationReport(fileInodeNumber inode.InodeNumber) (fragmentationReport inode.FragmentationReport, err error) {
+	inodeLock, err := vS.getWriteLock(fileInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	fragmentationReport, err = vS.VolumeHandle.GetFragmentationReport(fileInodeNumber)
+	return
+}
+
+func analyzeReclaimable(volumeName string, stopChan chan bool) (report ReclaimReport, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	report.Candidates = make([]ReclaimCandidate, 0)
+
+	report.Stopped, err = vS.walkFiles(stopChan, func(fileInodeNumber inode.InodeNumber) (err error) {
+		fragmentationReport, err := vS.fragmentationReport(fileInodeNumber)
+		if nil != err {
+			return
+		}
+		report.FilesScanned++
+		if 0 < fragmentationReport.BytesTrapped {
+			report.TrappedBytes += fragmentationReport.BytesTrapped
+			report.Candidates = append(report.Candidates, ReclaimCandidate{
+				InodeNumber:  fileInodeNumber,
+				Fragments:    fragmentationReport.NumberOfFragments,
+				TrappedBytes: fragmentationReport.BytesTrapped,
+			})
+		}
+		return
+	})
+	if (nil != err) || report.Stopped {
+		return
+	}
+
+	sort.Slice(report.Candidates, func(i int, j int) bool {
+		return report.Candidates[i].TrappedBytes > report.Candidates[j].TrappedBytes
+	})
+
+	vS.Lock()
+	orphans := make([]inode.InodeNumber, 0, len(vS.orphanMap))
+	for orphanInodeNumber := range vS.orphanMap {
+		orphans = append(orphans, orphanInodeNumber)
+	}
+	vS.Unlock()
+
+	for _, orphanInodeNumber := range orphans {
+		metadata, metadataErr := vS.VolumeHandle.GetMetadata(orphanInodeNumber)
+		if (nil == metadataErr) && (0 == metadata.LinkCount) {
+			report.OrphanInodes++
+			report.OrphanBytes += metadata.Size
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsReclaimAnalyzeOps)
+	return
+}
+
+func reclaim(volumeName string, inodeNumbers []inode.InodeNumber, maxDuration time.Duration, stopChan chan bool) (result ReclaimResult, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	startTime := time.Now()
+
+	for _, inodeNumber := range inodeNumbers {
+		select {
+		case <-stopChan:
+			result.Stopped = true
+			return
+		default:
+		}
+
+		remainingDuration := time.Duration(0)
+		if 0 != maxDuration {
+			remainingDuration = maxDuration - time.Since(startTime)
+			if 0 >= remainingDuration {
+				result.Stopped = true
+				return
+			}
+		}
+
+		reclaimedBytes, compactErr := vS.compactFile(inodeNumber, remainingDuration)
+		if nil != compactErr {
+			if blunder.Is(compactErr, blunder.NotFoundError) {
+				continue // removed since it was reported
+			}
+			err = compactErr
+			return
+		}
+		if 0 < reclaimedBytes {
+			result.FilesCompacted++
+			result.ReclaimedBytes += reclaimedBytes
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsReclaimOps)
+	return
+}
+
+// compactFile Optimize()'s fileInodeNumber, returning the trapped bytes thus reclaimed.
+func (vS *volumeStruct) compactFile(fileInodeNumber inode.InodeNumber, maxDuration time.Duration) (reclaimedBytes uint64, err error) {
+	inodeLock, err := vS.getWriteLock(fileInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	inodeType, err := vS.VolumeHandle.GetType(fileInodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.FileType != inodeType {
+		err = blunder.NewError(blunder.NotFileError, "inode %v is not a file", fileInodeNumber)
+		return
+	}
+
+	before, err := vS.VolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if (nil != err) || (0 == before.BytesTrapped) {
+		return
+	}
+
+	err = vS.VolumeHandle.Optimize(fileInodeNumber, maxDuration)
+	if nil != err {
+		return
+	}
+
+	after, err := vS.VolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if before.BytesTrapped > after.BytesTrapped {
+		reclaimedBytes = before.BytesTrapped - after.BytesTrapped
+	}
+
+	logger.Infof("fs: compacted inode %v in volume '%s' reclaiming %v bytes", fileInodeNumber, vS.volumeName, reclaimedBytes)
+	return
+}
@@ -0,0 +1,91 @@
+package fs
+
+// Rename lock ordering & cycle checks
+//
+// Rename() write locks both the source & destination directories. Rather than obtaining the second with
+// TryWriteLock() (dropping both and retrying should it be unavailable, which two Rename()s of entries
+// between the same pair of directories in opposite directions can repeat indefinitely), the directories are
+// locked in increasing inode number order, so neither Rename() can hold the lock the other awaits.
+//
+// Moving a directory beneath itself (or, for a RenameExchange, exchanging a directory with one of its
+// descendants) would detach a cycle from the namespace, so Rename() fails such a request with EINVAL. The
+// check follows ".." from the destination directory up to the root. As Rename()s between directories are
+// the only operations changing a directory's ancestry, they are serialized (much as Linux does with its
+// s_vfs_rename_mutex): each obtains the volume's renameLock exclusively before locking either directory,
+// holding it until done. The ancestry checked thus remains that at the time of the Move() without locking
+// any ancestor. Holding renameLock, they may also change the container beneath which what they move lies,
+// as may Rename()s of entries of the root directory (which hold it likewise, see freeze.go).
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+// renameDirLocks returns the locks of srcDirInodeNumber & dstDirInodeNumber (just one should they be the
+// same) in the order they must be obtained.
+func (vS *volumeStruct) renameDirLocks(callerID dlm.CallerID, srcDirInodeNumber inode.InodeNumber, dstDirInodeNumber inode.InodeNumber) (dirLocks []*dlm.RWLockStruct, err error) {
+	dirInodeNumbers := []inode.InodeNumber{srcDirInodeNumber}
+	if dstDirInodeNumber < srcDirInodeNumber {
+		dirInodeNumbers = []inode.InodeNumber{dstDirInodeNumber, srcDirInodeNumber}
+	} else if dstDirInodeNumber > srcDirInodeNumber {
+		dirInodeNumbers = []inode.InodeNumber{srcDirInodeNumber, dstDirInodeNumber}
+	}
+
+	for _, dirInodeNumber := range dirInodeNumbers {
+		dirLock, lockErr := vS.initInodeLock(dirInodeNumber, callerID)
+		if nil != lockErr {
+			err = lockErr
+			return
+		}
+		dirLocks = append(dirLocks, dirLock)
+	}
+	return
+}
+
+// isAncestorDir reports whether ancestorInodeNumber is dirInodeNumber or one of its ancestors. Caller must
+// hold vS.renameLock exclusively.
+func (vS *volumeStruct) isAncestorDir(ancestorInodeNumber inode.InodeNumber, dirInodeNumber inode.InodeNumber) (isAncestor bool) {
+	visited := make(map[inode.InodeNumber]struct{})
+
+	for {
+		if ancestorInodeNumber == dirInodeNumber {
+			isAncestor = true
+			return
+		}
+		if _, ok := visited[dirInodeNumber]; ok {
+			return // reached the root (whose ".." is itself)
+		}
+		visited[dirInodeNumber] = struct{}{}
+
+		parentInodeNumber, lookupErr := vS.VolumeHandle.Lookup(dirInodeNumber, "..")
+		if nil != lookupErr {
+			return // not a directory (so can't be beneath ancestorInodeNumber)
+		}
+
+		dirInodeNumber = parentInodeNumber
+	}
+}
+
+// checkRenameCycle fails with InvalidArgError should moving srcBasename into dstDirInodeNumber (or, if
+// exchange, dstBasename into srcDirInodeNumber) place a directory beneath itself. Caller must hold
+// vS.renameLock exclusively as well as the write locks of both directories.
+func (vS *volumeStruct) checkRenameCycle(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, exchange bool) (err error) {
+	srcInodeNumber, lookupErr := vS.VolumeHandle.Lookup(srcDirInodeNumber, srcBasename)
+	if (nil == lookupErr) && vS.isAncestorDir(srcInodeNumber, dstDirInodeNumber) {
+		err = blunder.NewError(blunder.InvalidArgError, "EINVAL")
+		return
+	}
+
+	if !exchange {
+		return
+	}
+
+	dstInodeNumber, lookupErr := vS.VolumeHandle.Lookup(dstDirInodeNumber, dstBasename)
+	if (nil == lookupErr) && vS.isAncestorDir(dstInodeNumber, srcDirInodeNumber) {
+		err = blunder.NewError(blunder.InvalidArgError, "EINVAL")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,441 @@
+package fs
+
+// Retention
+//
+// MiddlewareSetContainerRetention() records a RetentionPolicy in the container's reserved
+// ContainerRetentionStream. Each file subsequently placed in that container (or any directory beneath it)
+// is stamped with the policy's MinimumRetention in its own reserved RetentionStream:
+//
+//   Objects PUT via the middleware are committed at once, retained until MinimumRetention from then.
+//
+//   Files created via Create() are committed (following the convention of WORM filers) once Setstat()
+//   removes all of their write permission bits, retained until MinimumRetention from then. Until so
+//   committed, they may be written (and removed) as usual.
+//
+// Independently, SetLegalHold() may place (or lift) a legal hold on any file. A file is protected while
+// under legal hold or until its retention period expires. Removing, renaming, replacing, writing,
+// resizing, or otherwise altering a protected file fails with NotPermError (EPERM), whoever the caller.
+//
+// Changing or removing a container's RetentionPolicy affects only files subsequently stamped; those already
+// committed keep their RetainUntil.
+//
+// Retention is measured by the volume's retention clock rather than the wall clock. It starts at the wall
+// clock as the volume is first brought online, advances (monotonically) only while the volume is online, and
+// is saved with the volume state (see volume_state.go), periodically as well as when the volume is taken
+// offline. So neither the volume being offline nor the system clock being set forward shortens a retention
+// period, and a crash only lengthens them (by at most the time since the clock was last saved). RetainUntil
+// is expressed in retention clock time.
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// ContainerRetentionStream is the reserved stream on a container's directory inode holding its RetentionPolicy.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const ContainerRetentionStream = "proxyfs.containerretention"
+
+// RetentionStream is the reserved stream on a file inode holding its retentionStruct.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const RetentionStream = "proxyfs.retention"
+
+// posixModeWriteBits are the permission bits Setstat() must clear to commit a file's pending retention
+const posixModeWriteBits inode.InodeMode = 0222
+
+type retentionStruct struct {
+	MinimumRetention time.Duration `json:",omitempty"` // applied upon commit
+	RetainUntil      time.Time     // zero until committed
+	LegalHold        bool          `json:",omitempty"`
+}
+
+type retentionClockStruct struct {
+	sync.Mutex
+	base  time.Time // retention clock time as of start
+	start time.Time // wall clock time (with monotonic reading) the retention clock was (re)started
+}
+
+// startRetentionClock (re)starts the retention clock at saved (or, if zero, the wall clock).
+func (vS *volumeStruct) startRetentionClock(saved time.Time) {
+	now := time.Now()
+	if saved.IsZero() {
+		saved = now.Round(0)
+	}
+
+	vS.retentionClock.Lock()
+	vS.retentionClock.base = saved
+	vS.retentionClock.start = now
+	vS.retentionClock.Unlock()
+}
+
+// retentionNow returns the current retention clock time.
+func (vS *volumeStruct) retentionNow() (now time.Time) {
+	vS.retentionClock.Lock()
+	now = vS.retentionClock.base.Add(time.Since(vS.retentionClock.start))
+	vS.retentionClock.Unlock()
+	return
+}
+
+func (retention *retentionStruct) protected(now time.Time) bool {
+	return retention.LegalHold || now.Before(retention.RetainUntil)
+}
+
+// fetchRetention returns the retentionStruct of inodeNumber (nil if none). Caller must hold (at least) a
+// read lock on inodeNumber.
+func (vS *volumeStruct) fetchRetention(inodeNumber inode.InodeNumber) (retention *retentionStruct, err error) {
+	buf, err := vS.VolumeHandle.GetStream(inodeNumber, RetentionStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	retention = &retentionStruct{}
+	err = json.Unmarshal(buf, retention)
+	if nil != err {
+		retention = nil
+		err = blunder.NewError(blunder.CorruptInodeError, "RetentionStream of inode %v is corrupt: %v", inodeNumber, err)
+	}
+	return
+}
+
+func (vS *volumeStruct) putRetention(inodeNumber inode.InodeNumber, retention *retentionStruct) (err error) {
+	buf, err := json.Marshal(retention)
+	if nil != err {
+		return
+	}
+	err = vS.VolumeHandle.PutStream(inodeNumber, RetentionStream, buf)
+	return
+}
+
+// checkRetention fails with NotPermError if inodeNumber is protected. Caller must hold (at least) a read
+// lock on inodeNumber.
+func (vS *volumeStruct) checkRetention(inodeNumber inode.InodeNumber) (err error) {
+	retention, err := vS.fetchRetention(inodeNumber)
+	if (nil != err) || (nil == retention) {
+		return
+	}
+
+	if retention.protected(vS.retentionNow()) {
+		stats.IncrementOperations(&stats.FsRetentionDeniedOps)
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+	}
+	return
+}
+
+// checkRetentionOfName is checkRetention() of dirInodeNumber's entry basename (if any).
+func (vS *volumeStruct) checkRetentionOfName(dirInodeNumber inode.InodeNumber, basename string) (err error) {
+	targetInodeNumber, err := vS.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil // nothing to protect
+		}
+		return
+	}
+
+	err = vS.checkRetention(targetInodeNumber)
+	return
+}
+
+// stampRetention applies policy (if any) to the newly created fileInodeNumber, committing it at once if
+// commit is set. Caller must hold a write lock on fileInodeNumber (or be its only user).
+func (vS *volumeStruct) stampRetention(fileInodeNumber inode.InodeNumber, policy *RetentionPolicy, commit bool) (err error) {
+	if nil == policy {
+		return
+	}
+
+	retention := &retentionStruct{}
+	if commit {
+		retention.RetainUntil = vS.retentionNow().Add(policy.MinimumRetention)
+		stats.IncrementOperations(&stats.FsRetentionCommitOps)
+	} else {
+		retention.MinimumRetention = policy.MinimumRetention
+	}
+
+	err = vS.putRetention(fileInodeNumber, retention)
+	return
+}
+
+// commitRetention commits fileInodeNumber's pending retention (if any). Caller must hold a write lock on
+// fileInodeNumber.
+func (vS *volumeStruct) commitRetention(fileInodeNumber inode.InodeNumber) (err error) {
+	retention, err := vS.fetchRetention(fileInodeNumber)
+	if (nil != err) || (nil == retention) || (0 == retention.MinimumRetention) {
+		return
+	}
+
+	retention.RetainUntil = vS.retentionNow().Add(retention.MinimumRetention)
+	retention.MinimumRetention = 0
+
+	err = vS.putRetention(fileInodeNumber, retention)
+	if nil == err {
+		stats.IncrementOperations(&stats.FsRetentionCommitOps)
+	}
+	return
+}
+
+// fetchContainerRetention returns the RetentionPolicy of containerInodeNumber (nil if none). Caller must
+// hold (at least) a read lock on containerInodeNumber.
+func (vS *volumeStruct) fetchContainerRetention(containerInodeNumber inode.InodeNumber) (policy *RetentionPolicy, err error) {
+	buf, err := vS.VolumeHandle.GetStream(containerInodeNumber, ContainerRetentionStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	policy = &RetentionPolicy{}
+	err = json.Unmarshal(buf, policy)
+	if nil != err {
+		policy = nil
+		err = blunder.NewError(blunder.CorruptInodeError, "ContainerRetentionStream of inode %v is corrupt: %v", containerInodeNumber, err)
+	}
+	return
+}
+
+// fetchContainerRetentionByName returns the RetentionPolicy of vContainerName (nil if none or if
+// vContainerName does not exist).
+func (mS *mountStruct) fetchContainerRetentionByName(vContainerName string) (policy *RetentionPolicy, err error) {
+	containerInodeNumber, err := mS.lookupContainer(vContainerName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil // to be created
+		}
+		return
+	}
+
+	containerInodeLock, err := mS.volStruct.getReadLock(containerInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer containerInodeLock.Unlock()
+
+	policy, err = mS.volStruct.fetchContainerRetention(containerInodeNumber)
+	return
+}
+
+// fetchRetentionPolicyOf returns the RetentionPolicy (nil if none) of the container holding dirInodeNumber
+// (i.e. its ancestor that is an entry of the root directory). Each directory walked through is locked only
+// while its ".." entry is looked up, so no lock may be held by the caller.
+func (vS *volumeStruct) fetchRetentionPolicyOf(dirInodeNumber inode.InodeNumber) (policy *RetentionPolicy, err error) {
+	callerID := dlm.GenerateCallerID()
+
+	for inode.RootDirInodeNumber != dirInodeNumber {
+		dirInodeLock, lockErr := vS.getReadLock(dirInodeNumber, callerID)
+		if nil != lockErr {
+			err = lockErr
+			return
+		}
+
+		// Should dirInodeNumber not be a directory (if it exists at all), leave it to the caller to say so
+		if !vS.VolumeHandle.Access(dirInodeNumber, inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.F_OK) {
+			dirInodeLock.Unlock()
+			return
+		}
+		inodeType, typeErr := vS.VolumeHandle.GetType(dirInodeNumber)
+		if (nil != typeErr) || (inode.DirType != inodeType) {
+			dirInodeLock.Unlock()
+			return
+		}
+
+		parentInodeNumber, lookupErr := vS.VolumeHandle.Lookup(dirInodeNumber, "..")
+		if nil != lookupErr {
+			dirInodeLock.Unlock()
+			err = lookupErr
+			return
+		}
+
+		if inode.RootDirInodeNumber == parentInodeNumber {
+			policy, err = vS.fetchContainerRetention(dirInodeNumber)
+			dirInodeLock.Unlock()
+			return
+		}
+
+		dirInodeLock.Unlock()
+		dirInodeNumber = parentInodeNumber
+	}
+
+	return
+}
+
+func (mS *mountStruct) MiddlewareGetContainerRetention(vContainerName string) (policy *RetentionPolicy, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	containerInodeNumber, err := mS.lookupContainer(vContainerName)
+	if nil != err {
+		return
+	}
+
+	containerInodeLock, err := mS.volStruct.getReadLock(containerInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer containerInodeLock.Unlock()
+
+	policy, err = mS.volStruct.fetchContainerRetention(containerInodeNumber)
+	return
+}
+
+func (mS *mountStruct) MiddlewareSetContainerRetention(vContainerName string, policy *RetentionPolicy) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	exitContainers := mS.enterContainers(vContainerName) // see freeze.go
+	defer exitContainers()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	if (nil != policy) && (0 >= policy.MinimumRetention) {
+		err = blunder.NewError(blunder.InvalidArgError, "RetentionPolicy.MinimumRetention must be positive")
+		return
+	}
+
+	containerInodeNumber, err := mS.lookupContainer(vContainerName)
+	if nil != err {
+		return
+	}
+
+	containerInodeLock, err := mS.volStruct.getWriteLock(containerInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer containerInodeLock.Unlock()
+
+	if nil == policy {
+		err = mS.volStruct.VolumeHandle.DeleteStream(containerInodeNumber, ContainerRetentionStream)
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+	} else {
+		var buf []byte
+		buf, err = json.Marshal(policy)
+		if nil != err {
+			return
+		}
+		err = mS.volStruct.VolumeHandle.PutStream(containerInodeNumber, ContainerRetentionStream, buf)
+	}
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.notifyInode(NotifySetAttr, containerInodeNumber)
+
+	stats.IncrementOperations(&stats.FsMwSetContainerRetentionOps)
+	return
+}
+
+func (mS *mountStruct) GetRetention(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (status RetentionStatus, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeLock, err := mS.volStruct.getReadLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+
+	retention, err := mS.volStruct.fetchRetention(inodeNumber)
+	if (nil != err) || (nil == retention) {
+		return
+	}
+
+	status = RetentionStatus{
+		MinimumRetention: retention.MinimumRetention,
+		RetainUntil:      retention.RetainUntil,
+		LegalHold:        retention.LegalHold,
+		Protected:        retention.protected(mS.volStruct.retentionNow()),
+	}
+	return
+}
+
+// SetLegalHold places (or, if !hold, lifts) a legal hold on the file inodeNumber. Only root may do so.
+func (mS *mountStruct) SetLegalHold(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, hold bool) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	inodeLock, err := mS.volStruct.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if inode.InodeRootUserID != userID {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+
+	inodeType, err := mS.volStruct.VolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.FileType != inodeType {
+		err = blunder.NewError(blunder.NotFileError, "legal holds may only be placed on files")
+		return
+	}
+
+	retention, err := mS.volStruct.fetchRetention(inodeNumber)
+	if nil != err {
+		return
+	}
+	if nil == retention {
+		if !hold {
+			return
+		}
+		retention = &retentionStruct{}
+	}
+
+	retention.LegalHold = hold
+
+	err = mS.volStruct.putRetention(inodeNumber, retention)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
+
+	stats.IncrementOperations(&stats.FsLegalHoldOps)
+	return
+}
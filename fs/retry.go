@@ -0,0 +1,84 @@
+package fs
+
+// Lock conflict retries
+//
+// To avoid deadlock, operations needing more than one lock (e.g. Coalesce(), or the exchanged inodes of a
+// RenameExchange) obtain all but the first with TryWriteLock(). Should one of those fail with TryAgainError,
+// the operation must drop every lock it holds and start over. retryLockConflicts() does so uniformly: an attempt
+// reporting a retriable failure is retried up to [<volume-section>]LockRetryLimit times, the first
+// after a delay of LockRetryDelay with each subsequent delay LockRetryExpBackoff times longer (but no
+// longer than LockRetryMaxDelay). Each delay is jittered (chosen uniformly from [delay/2:delay)) so
+// that operations contending for the same locks don't retry in lockstep.
+//
+// Only failures an attempt reports as retriable are retried. TryAgainErrors intended for the caller
+// (e.g. writes to a fenced inode (see fence.go), I/O conflicting with a mandatory byte-range lock
+// (see mandatory_lock.go), or metadata differing from that expected by MiddlewarePost()) are not.
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	defaultLockRetryLimit      = uint64(100)
+	defaultLockRetryDelay      = 100 * time.Microsecond
+	defaultLockRetryMaxDelay   = 50 * time.Millisecond
+	defaultLockRetryExpBackoff = float64(2.0)
+)
+
+type lockRetryStruct struct {
+	limit      uint64        // [<volume-section>]LockRetryLimit (0 == no retries)
+	delay      time.Duration // [<volume-section>]LockRetryDelay
+	maxDelay   time.Duration // [<volume-section>]LockRetryMaxDelay
+	expBackoff float64       // [<volume-section>]LockRetryExpBackoff
+}
+
+// retryLockConflicts calls attempt() until it succeeds, fails with a non-retriable error, or has
+// been retried vS.lockRetry.limit times. Before returning a retriable failure, attempt() must have
+// released any locks it obtained.
+//
+// Once retries are exhausted, the final (TryAgainError) failure of attempt() is returned.
+func (vS *volumeStruct) retryLockConflicts(attempt func() (retriable bool, err error)) (err error) {
+	retriable, err := attempt()
+	if (nil == err) || !retriable {
+		return
+	}
+
+	vS.Lock()
+	lockRetry := vS.lockRetry
+	vS.Unlock()
+
+	stats.IncrementOperations(&stats.FsLockRetryOps)
+
+	delay := lockRetry.delay
+
+	for retry := uint64(1); retry <= lockRetry.limit; retry++ {
+		if 0 < delay {
+			time.Sleep((delay / 2) + time.Duration(rand.Int63n(int64((delay+1)/2))))
+		}
+
+		retriable, err = attempt()
+		if nil == err {
+			stats.IncrementOperations(&stats.FsLockRetrySuccessOps)
+			return
+		}
+		if !retriable {
+			return
+		}
+
+		delay = time.Duration(float64(delay) * lockRetry.expBackoff)
+		if delay > lockRetry.maxDelay {
+			delay = lockRetry.maxDelay
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsLockRetryExhaustedOps)
+
+	if blunder.IsNot(err, blunder.TryAgainError) {
+		err = blunder.AddError(err, blunder.TryAgainError)
+	}
+	return
+}
@@ -0,0 +1,373 @@
+package fs
+
+// Reverse container & account listings
+//
+// Swift's reverse=true lists a container (or account) in descending order, marker then bounding the listing
+// from above and end_marker from below. Rather than listing forward and reversing the result (which, given a
+// marker near the end of a large container, would walk nearly all of it), a reverse listing reads each
+// directory backward from just after the last entry that could be listed given marker & prefix, a batch of
+// entries at a time, positioning each batch via the directory's index.
+//
+// The one complication is where a directory's subtree falls. Listing forward, the descent into directory "d"
+// is deferred until the entries sorting before "d/" (e.g. "d-README") have been listed. Listing backward, the
+// subtree must instead be listed before any entry sorting between "d/" and "d" (e.g. "d.txt"), yet "d" is only
+// read after them. As each such entry's basename begins with "d" followed by a byte sorting before '/', before
+// listing an entry a reverse listing looks up each proper prefix of its basename so followed (along with the
+// entry itself) and first lists the subtree of any that is a directory.
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+func (mS *mountStruct) middlewareGetAccountReverse(maxEntries uint64, marker string, endMarker string) (accountEnts []AccountEntry, err error) {
+	areMoreEntries := true
+	upperBasename := marker
+	for areMoreEntries && uint64(len(accountEnts)) < maxEntries {
+		var dirEnts []inode.DirEntry
+		dirEnts, areMoreEntries, err = mS.readDirBackward(inode.RootDirInodeNumber, upperBasename, maxEntries-uint64(len(accountEnts)))
+		if nil != err {
+			return
+		}
+
+		for _, dirEnt := range dirEnts {
+			if dirEnt.Basename == "." || dirEnt.Basename == ".." {
+				continue
+			}
+			if ("" != endMarker) && (dirEnt.Basename <= endMarker) {
+				return
+			}
+
+			var isItADir bool
+			isItADir, err = mS.isDir(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirEnt.InodeNumber)
+			if err != nil {
+				logger.ErrorfWithError(err, "MiddlewareGetAccount: error in IsDir(%v)", dirEnt.InodeNumber)
+				return
+			}
+
+			if isItADir {
+				accountEnts = append(accountEnts, AccountEntry{Basename: dirEnt.Basename})
+			}
+		}
+		if len(dirEnts) == 0 {
+			break
+		}
+		upperBasename = dirEnts[len(dirEnts)-1].Basename
+	}
+	return
+}
+
+// readDirBackward returns, in reverse order, up to maxEntries of the entries of directory dirInode sorting
+// before upperBasename ("" == no limit) along with whether there are more.
+func (mS *mountStruct) readDirBackward(dirInode inode.InodeNumber, upperBasename string, maxEntries uint64) (dirEnts []inode.DirEntry, areMoreEntries bool, err error) {
+	dirInodeLock, err := mS.volStruct.initInodeLock(dirInode, nil)
+	if nil != err {
+		return
+	}
+	err = dirInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	// Entries before the first one sorting after upperBasename are candidates (but for upperBasename itself)
+
+	endLocation, err := mS.volStruct.VolumeHandle.NumDirEntries(dirInode)
+	if nil != err {
+		return
+	}
+	if "" != upperBasename {
+		afterEnts, _, readDirErr := mS.volStruct.VolumeHandle.ReadDir(dirInode, 1, 0, upperBasename)
+		if nil == readDirErr {
+			if 0 < len(afterEnts) {
+				endLocation = uint64(afterEnts[0].NextDirLocation) - 1
+			}
+		} else if blunder.IsNot(readDirErr, blunder.NotFoundError) {
+			err = readDirErr
+			return
+		}
+	}
+
+	numEntries := maxEntries + 1 // in case one is upperBasename itself
+	if numEntries > endLocation {
+		numEntries = endLocation
+	}
+	if 0 == numEntries {
+		return
+	}
+	startLocation := endLocation - numEntries
+
+	readEnts, _, err := mS.volStruct.VolumeHandle.ReadDir(dirInode, numEntries, 0, inode.InodeDirLocation(startLocation)-1)
+	if nil != err {
+		return
+	}
+
+	dirEnts = make([]inode.DirEntry, 0, len(readEnts))
+	for i := len(readEnts) - 1; i >= 0; i-- {
+		if ("" != upperBasename) && (readEnts[i].Basename >= upperBasename) {
+			continue
+		}
+		dirEnts = append(dirEnts, readEnts[i])
+	}
+	areMoreEntries = (0 < startLocation)
+	return
+}
+
+// reverseContainerListingLevelStruct is the state of a reverse MiddlewareGetContainer() listing one directory.
+type reverseContainerListingLevelStruct struct {
+	listing              *containerListingStruct
+	dirName              string
+	dirInode             inode.InodeNumber
+	dirEnts              []inode.DirEntry // in reverse order
+	descents             []dirToDescend   // in reverse order
+	areMoreEntries       bool
+	upperBasename        string // entries yet to be read sort before this ("" == no limit)
+	lastDescentsBasename string // basename of the last dirEnt passed to findDescents()
+}
+
+// newReverseLevel returns the reverse listing level for directory dirInode (named dirName, with a trailing
+// "/" unless the container itself).
+//
+// Rather than reading the directory from its last entry, the level reads backward from just after the last
+// entry that could be listed (or lead to an entry to be listed) given marker & prefix.
+func (listing *containerListingStruct) newReverseLevel(dirName string, dirInode inode.InodeNumber) (level *reverseContainerListingLevelStruct) {
+	level = &reverseContainerListingLevelStruct{listing: listing, dirName: dirName, dirInode: dirInode, areMoreEntries: true}
+
+	if strings.HasPrefix(listing.marker, dirName) && (len(listing.marker) > len(dirName)) {
+		// An entry sorting after the marker's basename in this directory lists only names after the marker
+		// unless it is that basename followed by a byte sorting before '/' (e.g. "a.txt" for marker "a/b",
+		// as "a.txt" < "a/b")... so, if the marker continues beyond its basename, end at that basename + "/"
+		markerSegments := strings.SplitN(listing.marker[len(dirName):], "/", 2)
+		if 2 == len(markerSegments) {
+			level.upperBasename = markerSegments[0] + "/"
+		} else {
+			level.upperBasename = markerSegments[0]
+		}
+	}
+
+	if strings.HasPrefix(listing.prefix, dirName) && (len(listing.prefix) > len(dirName)) {
+		// No entry sorting after every basename beginning with the prefix's (possibly partial) basename in
+		// this directory can match... nor, if the prefix continues beyond its basename, after that basename
+		prefixSegments := strings.SplitN(listing.prefix[len(dirName):], "/", 2)
+		var prefixEnd string
+		if 2 == len(prefixSegments) {
+			prefixEnd = prefixSegments[0] + "\x00"
+		} else {
+			prefixEnd = basenamesBeginningWithEnd(prefixSegments[0])
+		}
+		if ("" != prefixEnd) && (("" == level.upperBasename) || (prefixEnd < level.upperBasename)) {
+			level.upperBasename = prefixEnd
+		}
+	}
+
+	return
+}
+
+// basenamesBeginningWithEnd returns the least string sorting after every basename beginning with prefix (or
+// "" if there is no such string).
+func basenamesBeginningWithEnd(prefix string) (end string) {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if 0xFF != prefix[i] {
+			end = prefix[:i] + string([]byte{prefix[i] + 1})
+			return
+		}
+	}
+	return
+}
+
+// pastEndReverse is pastEnd() for a reverse listing: whether name (and hence all that would follow it) is
+// not after the listing's endMarker. For isDescent, name is the path of a directory to be descended, so
+// is only past the endMarker if no name beneath it could follow the endMarker either.
+func (listing *containerListingStruct) pastEndReverse(name string, isDescent bool) bool {
+	if ("" != listing.endMarker) && ((name < listing.endMarker) || (!listing.endMarkerInclusive && (name == listing.endMarker))) {
+		if !isDescent || !strings.HasPrefix(listing.endMarker, name) {
+			listing.pastEndMarker = true
+		}
+	}
+	return listing.pastEndMarker
+}
+
+func (level *reverseContainerListingLevelStruct) next(tD *treeDescentStruct) (subLevel treeDescentLevel, err error) {
+	subLevel, err = level.listDir(tD)
+	if (nil == subLevel) && (nil == err) {
+		// Done with this directory... so forget any descents we won't be making
+		err = tD.addPending(-len(level.descents))
+		level.descents = nil
+	}
+	return
+}
+
+// listDir appends the entries of the directory to the listing, in reverse order, until it either needs to
+// descend into a subdirectory (returned as subLevel) or is done (returning a nil subLevel).
+func (level *reverseContainerListingLevelStruct) listDir(tD *treeDescentStruct) (subLevel treeDescentLevel, err error) {
+	listing := level.listing
+	mS := listing.mS
+
+	for (level.areMoreEntries || len(level.dirEnts) > 0 || len(level.descents) > 0) && uint64(len(listing.containerEnts)) < listing.maxEntries && !listing.pastEndMarker {
+		// If we've run out of real directory entries, load some more (from just before the last loaded).
+		if level.areMoreEntries && len(level.dirEnts) == 0 {
+			level.dirEnts, level.areMoreEntries, err = mS.readDirBackward(level.dirInode, level.upperBasename, listing.maxEntries-uint64(len(listing.containerEnts)))
+			if err != nil {
+				logger.ErrorfWithError(err, "MiddlewareGetContainer: error reading directory %s (inode %v)", level.dirName, level.dirInode)
+				return
+			}
+			if len(level.dirEnts) > 0 {
+				level.upperBasename = level.dirEnts[len(level.dirEnts)-1].Basename
+			}
+		}
+
+		// Ignore these early so we can stop thinking about them
+		if len(level.dirEnts) > 0 && (level.dirEnts[0].Basename == "." || level.dirEnts[0].Basename == "..") {
+			level.dirEnts = level.dirEnts[1:]
+			continue
+		}
+
+		// Any subtree sorting after the next dirEnt must be listed before it
+		if len(level.dirEnts) > 0 {
+			err = level.findDescents(tD, level.dirEnts[0])
+			if nil != err {
+				return
+			}
+		}
+		if len(level.descents) > 0 && (len(level.dirEnts) == 0 || (level.descents[0].name > level.dirEnts[0].Basename)) {
+			descent := level.descents[0]
+			level.descents = level.descents[1:]
+			err = tD.addPending(-1)
+			if nil != err {
+				return
+			}
+			if listing.pastEndReverse(descent.path, !descent.subdir) {
+				return
+			}
+			if descent.subdir {
+				err = listing.listSubdir(descent)
+				if nil != err {
+					return
+				}
+				continue
+			}
+			subLevel = listing.newReverseLevel(descent.path, descent.ino)
+			return
+		}
+
+		if !(len(level.dirEnts) > 0) {
+			continue
+		}
+
+		dirEnt := level.dirEnts[0]
+		level.dirEnts = level.dirEnts[1:]
+
+		fileName := level.dirName + dirEnt.Basename
+
+		if listing.pastEndReverse(fileName, false) {
+			return
+		}
+
+		if !strings.HasPrefix(fileName, listing.prefix) {
+			if (fileName < listing.prefix) && !strings.HasPrefix(listing.prefix, fileName) {
+				// Going over these in reverse order, nothing (nor anything beneath it) in the rest of
+				// the directory can start with the prefix either
+				level.dirEnts = nil
+				level.areMoreEntries = false
+			}
+			continue
+		}
+
+		if ("" != listing.marker) && (fileName >= listing.marker) {
+			continue
+		}
+
+		statResult, getstatErr := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, dirEnt.InodeNumber)
+		if getstatErr != nil {
+			logger.ErrorfWithError(getstatErr, "MiddlewareGetContainer: error in Getstat of %s", fileName)
+			err = getstatErr
+			return
+		}
+
+		if inode.DirType == inode.InodeType(statResult[StatFType]) {
+			// Any descent into it has already been made (its subtree sorting after it)
+			listing.appendDir(fileName, statResult)
+		} else {
+			err = listing.appendFile(fileName, dirEnt.InodeNumber, statResult)
+			if nil != err {
+				return
+			}
+		}
+	}
+	return
+}
+
+// findDescents adds to the level's descents each directory whose subtree sorts after dirEnt: dirEnt itself
+// or one whose basename is a proper prefix of dirEnt's followed there by a byte sorting before '/' (e.g. "a"
+// for "a.txt", as "a/b" > "a.txt").
+//
+// As the dirEnts sorting between such a directory's basename and its subtree are contiguous (and end with
+// the directory itself), any such basename shared with the previous dirEnt has already been looked up.
+func (level *reverseContainerListingLevelStruct) findDescents(tD *treeDescentStruct, dirEnt inode.DirEntry) (err error) {
+	listing := level.listing
+	mS := listing.mS
+
+	lastBasename := level.lastDescentsBasename
+	level.lastDescentsBasename = dirEnt.Basename
+
+	for basenameLen := 1; basenameLen <= len(dirEnt.Basename); basenameLen++ {
+		if (basenameLen < len(dirEnt.Basename)) && (dirEnt.Basename[basenameLen] >= '/') {
+			continue
+		}
+		basename := dirEnt.Basename[:basenameLen]
+		if strings.HasPrefix(lastBasename, basename) && ((basenameLen == len(lastBasename)) || (lastBasename[basenameLen] < '/')) {
+			continue // already looked up
+		}
+
+		descent := dirToDescend{path: level.dirName + basename + "/", name: basename + "/"}
+		if !strings.HasPrefix(descent.path, listing.prefix) && !strings.HasPrefix(listing.prefix, descent.path) {
+			continue
+		}
+		if ("" != listing.marker) && (descent.path >= listing.marker) {
+			continue // everything beneath it sorts after the marker
+		}
+
+		if basenameLen == len(dirEnt.Basename) {
+			descent.ino = dirEnt.InodeNumber
+		} else {
+			descent.ino, err = mS.Lookup(inode.InodeRootUserID, inode.InodeRootGroupID, nil, level.dirInode, basename)
+			if nil != err {
+				if blunder.Is(err, blunder.NotFoundError) {
+					err = nil
+					continue
+				}
+				logger.ErrorfWithError(err, "MiddlewareGetContainer: error in Lookup of %s", level.dirName+basename)
+				return
+			}
+		}
+
+		statResult, getstatErr := mS.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, descent.ino)
+		if getstatErr != nil {
+			logger.ErrorfWithError(getstatErr, "MiddlewareGetContainer: error in Getstat of %s", level.dirName+basename)
+			err = getstatErr
+			return
+		}
+		if inode.DirType != inode.InodeType(statResult[StatFType]) {
+			continue
+		}
+
+		if listing.delimited && !strings.HasPrefix(listing.prefix, descent.path) {
+			// As with a forward listing, list everything beneath it as just descent.path
+			descent.subdir = true
+		}
+
+		descentIndex := sort.Search(len(level.descents), func(i int) bool { return level.descents[i].name < descent.name })
+		level.descents = append(level.descents, dirToDescend{})
+		copy(level.descents[descentIndex+1:], level.descents[descentIndex:])
+		level.descents[descentIndex] = descent
+		err = tD.addPending(1)
+		if nil != err {
+			return
+		}
+	}
+	return
+}
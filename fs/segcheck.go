@@ -0,0 +1,114 @@
+package fs
+
+// Read plan segment existence checking for MiddlewareGetObject()
+//
+// The read plan returned by MiddlewareGetObject() names the LogSegments the middleware will then
+// GET directly from Swift. Should the file be concurrently overwritten, its prior LogSegments are
+// deleted and the middleware's client sees a 404 part way through the download. If
+// [<volume-section>]GetObjectSegmentCheck is true, the first and last LogSegments of the read plan
+// are verified to still exist (via HEAD) before the read plan is returned. A missing LogSegment fails
+// the request with TryAgainError (EAGAIN) before any data has been sent. As LogSegments are never
+// rewritten, a successful HEAD is remembered for [<volume-section>]GetObjectSegmentCheckCacheTTL.
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+const (
+	defaultSegmentCheckCacheTTL = 60 * time.Second
+	segmentCheckCacheMax        = 4096 // ObjectPaths remembered per volume
+)
+
+// checkReadPlanSegments verifies the first and last LogSegments of readPlan still exist.
+func (vS *volumeStruct) checkReadPlanSegments(readPlan []inode.ReadPlanStep) (err error) {
+	var (
+		firstStep *inode.ReadPlanStep
+		lastStep  *inode.ReadPlanStep
+	)
+
+	vS.Lock()
+	segmentCheck := vS.segmentCheck
+	vS.Unlock()
+
+	if !segmentCheck {
+		return
+	}
+
+	for i := range readPlan {
+		if "" != readPlan[i].ObjectPath {
+			if nil == firstStep {
+				firstStep = &readPlan[i]
+			}
+			lastStep = &readPlan[i]
+		}
+	}
+
+	if nil == firstStep {
+		// Read plan is entirely zero-fill
+		return
+	}
+
+	err = vS.checkSegment(firstStep)
+	if (nil == err) && (lastStep.ObjectPath != firstStep.ObjectPath) {
+		err = vS.checkSegment(lastStep)
+	}
+
+	return
+}
+
+func (vS *volumeStruct) checkSegment(step *inode.ReadPlanStep) (err error) {
+	vS.Lock()
+	verifiedTime, ok := vS.segmentCheckCache[step.ObjectPath]
+	if ok && (time.Since(verifiedTime) < vS.segmentCheckCacheTTL) {
+		vS.Unlock()
+		stats.IncrementOperations(&stats.FsMwSegmentCheckCachedOps)
+		return
+	}
+	vS.Unlock()
+
+	stats.IncrementOperations(&stats.FsMwSegmentCheckHeadOps)
+
+	accountName, containerName, objectName, err := utils.PathToAcctContObj(step.ObjectPath)
+	if nil != err {
+		logger.WarnfWithError(err, "fs.checkSegment(): unable to parse ObjectPath %s", step.ObjectPath)
+		err = nil
+		return
+	}
+
+	_, err = swiftclient.ObjectHead(accountName, containerName, objectName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			stats.IncrementOperations(&stats.FsMwSegmentCheckStaleOps)
+			err = blunder.NewError(blunder.TryAgainError, "read plan is stale: LogSegment %s no longer exists", step.ObjectPath)
+			return
+		}
+
+		// Inconclusive... let the middleware's GET discover any real problem
+		logger.WarnfWithError(err, "fs.checkSegment(): HEAD of %s failed", step.ObjectPath)
+		err = nil
+		return
+	}
+
+	vS.Lock()
+	if segmentCheckCacheMax <= len(vS.segmentCheckCache) {
+		for objectPath, verifiedTime := range vS.segmentCheckCache {
+			if time.Since(verifiedTime) >= vS.segmentCheckCacheTTL {
+				delete(vS.segmentCheckCache, objectPath)
+			}
+		}
+		if segmentCheckCacheMax <= len(vS.segmentCheckCache) {
+			vS.segmentCheckCache = make(map[string]time.Time)
+		}
+	}
+	vS.segmentCheckCache[step.ObjectPath] = time.Now()
+	vS.Unlock()
+
+	return
+}
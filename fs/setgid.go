@@ -0,0 +1,53 @@
+package fs
+
+// setgid directories
+//
+// As on local filesystems, an inode created (via Create(), CreateUnlinked(), Mkdir(), Mknod(), or
+// Symlink()) in a directory with its setgid bit (PosixModeSetGID) set belongs to the directory's group
+// rather than the caller's. A directory so created also inherits the setgid bit, so that the whole
+// subtree (e.g. a team's shared directory) continues to do so. Conversely, should the caller request the
+// setgid bit for a new non-directory of a group the caller is not a member of (and the caller not be
+// root), the bit is dropped.
+
+import (
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// inheritFromDir returns the group and mode an inode of inodeType that a caller of userID, groupID, &
+// otherGroupIDs asked to create in dirInodeNumber with filePerm should have.
+func (mS *mountStruct) inheritFromDir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, inodeType inode.InodeType, filePerm inode.InodeMode) (newGroupID inode.InodeGroupID, newFilePerm inode.InodeMode) {
+	newGroupID = groupID
+	newFilePerm = filePerm
+
+	dirMetadata, err := mS.volStruct.VolumeHandle.GetMetadata(dirInodeNumber)
+	if (nil != err) || (0 == dirMetadata.Mode&inode.PosixModeSetGID) {
+		return // any missing directory is reported by the caller's Access() checks
+	}
+
+	stats.IncrementOperations(&stats.FsSetGIDInheritOps)
+
+	newGroupID = dirMetadata.GroupID
+
+	if inode.DirType == inodeType {
+		newFilePerm |= inode.PosixModeSetGID
+		return
+	}
+
+	if (0 != newFilePerm&inode.PosixModeSetGID) && (inode.InodeRootUserID != userID) && !isGroupMember(newGroupID, groupID, otherGroupIDs) {
+		newFilePerm &^= inode.PosixModeSetGID
+	}
+	return
+}
+
+func isGroupMember(memberOfGroupID inode.InodeGroupID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID) bool {
+	if memberOfGroupID == groupID {
+		return true
+	}
+	for _, otherGroupID := range otherGroupIDs {
+		if memberOfGroupID == otherGroupID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package fs
+
+// Container listing shards
+//
+// Listing a container of tens of millions of objects via MiddlewareGetContainer() one page after another
+// is bounded by the rate a single listing can walk the tree. MiddlewareGetContainerShards() instead
+// partitions a container's listing into up to maxShards ContainerShards, each a range of names (after its
+// Marker and up to and including its EndMarker) that may be listed independently (and concurrently) via
+// MiddlewareGetContainerRange(). The listings of the ContainerShards, concatenated in order, are the
+// listing of the container.
+//
+// The shard boundaries are basenames of the container directory itself, taken at evenly spaced positions
+// of its directory index (without reading the entries in between). Each boundary basename (and, if it
+// names a directory, the directory itself) ends one shard, and anything beneath such a directory begins
+// the next. A container directory with few entries (e.g. one whose objects are all beneath a handful of
+// directories) thus yields correspondingly few ContainerShards. As boundaries are merely positions in the
+// name space, ContainerShards remain correct (if no longer balanced) as the container is modified.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+func (mS *mountStruct) MiddlewareGetContainerShards(vContainerName string, maxShards uint64) (shards []ContainerShard, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	if 0 == maxShards {
+		err = blunder.NewError(blunder.InvalidArgError, "maxShards must be non-zero")
+		return
+	}
+
+	containerInodeNumber, containerInodeType, containerInodeLock, err := mS.resolvePathForRead(vContainerName, nil)
+	if nil != err {
+		return
+	}
+	defer containerInodeLock.Unlock()
+
+	if inode.DirType != containerInodeType {
+		err = blunder.NewError(blunder.NotDirError, "%s is not a directory", vContainerName)
+		return
+	}
+
+	numEntries, err := mS.volStruct.VolumeHandle.NumDirEntries(containerInodeNumber)
+	if nil != err {
+		return
+	}
+
+	boundaries := make([]string, 0, maxShards-1)
+
+	for shardIndex := uint64(1); shardIndex < maxShards; shardIndex++ {
+		location := inode.InodeDirLocation(numEntries * shardIndex / maxShards)
+		entries, _, readDirErr := mS.volStruct.VolumeHandle.ReadDir(containerInodeNumber, 1, 0, location-1)
+		if nil != readDirErr {
+			err = readDirErr
+			return
+		}
+		if 0 == len(entries) {
+			break
+		}
+		basename := entries[0].Basename
+		if ("." == basename) || (".." == basename) {
+			continue
+		}
+		if (0 < len(boundaries)) && (basename <= boundaries[len(boundaries)-1]) {
+			continue
+		}
+		boundaries = append(boundaries, basename)
+	}
+
+	shards = make([]ContainerShard, 0, len(boundaries)+1)
+	marker := ""
+	for _, boundary := range boundaries {
+		shards = append(shards, ContainerShard{Marker: marker, EndMarker: boundary})
+		marker = boundary
+	}
+	shards = append(shards, ContainerShard{Marker: marker, EndMarker: ""})
+
+	stats.IncrementOperations(&stats.FsMwGetContainerShardsOps)
+	return
+}
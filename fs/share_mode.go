@@ -0,0 +1,57 @@
+package fs
+
+// Share mode enforcement
+//
+// Open() arbitrates the ShareMode of concurrent opens of a file (see handle.go). So that an SMB client's
+// deny-read, deny-write, or deny-delete open also holds against FUSE, the HTTP middleware, and other users
+// of the inode-based APIs, each of their reads, writes (including truncation and replacement), and deletes
+// (including renames of or over the file) fails with DevBusyError (EBUSY) unless every open of the file
+// shares that access.
+//
+// Reads & writes via a FileHandle are exempt from that handle's own ShareMode. As deletes are performed by
+// name rather than via a FileHandle, an open that itself requested OpenDelete is presumed to be the one
+// deleting and so doesn't deny it.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// checkShareModes fails with DevBusyError if some open of inodeNumber (other than that of flockPid, should
+// it be a FileHandle) doesn't share access.
+func (vS *volumeStruct) checkShareModes(inodeNumber inode.InodeNumber, access ShareMode, flockPid uint64) (err error) {
+	vS.handles.Lock()
+	defer vS.handles.Unlock()
+
+	for _, open := range vS.handles.inodeOpenMap[inodeNumber] {
+		if FileHandle(flockPid) == open.fileHandle {
+			continue
+		}
+		denied := access & ^open.shareMode
+		if 0 != (open.flags & OpenDelete) {
+			denied &= ^ShareDelete
+		}
+		if 0 != denied {
+			stats.IncrementOperations(&stats.FsShareModeDeniedOps)
+			err = blunder.NewError(blunder.DevBusyError, "access 0x%X to inode %v conflicts with FileHandle %v", access, inodeNumber, open.fileHandle)
+			return
+		}
+	}
+
+	return
+}
+
+// checkShareModesOfName is checkShareModes() of dirInodeNumber's entry basename (if any).
+func (vS *volumeStruct) checkShareModesOfName(dirInodeNumber inode.InodeNumber, basename string, access ShareMode) (err error) {
+	targetInodeNumber, err := vS.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil // nothing open
+		}
+		return
+	}
+
+	err = vS.checkShareModes(targetInodeNumber, access, 0)
+	return
+}
@@ -0,0 +1,176 @@
+package fs
+
+// Graceful shutdown
+//
+// Shutdown() takes every volume served by this peer offline in an orderly fashion ahead of process exit,
+// such that the subsequent Down() of package fs and those beneath it find nothing left dirty:
+//
+//   Mount() is refused (with TryAgainError) from then on
+//
+//   each lease is recalled (see lease.go), giving its holder the chance to write back what it has cached
+//
+//   each mount is closed to new operations... those attempted fail with TryAgainError (EAGAIN) so that
+//   clients retry them against the restarted process (note this includes an operation begun by another
+//   already in flight, e.g. Read() within ReadByHandle())
+//
+//   operations in flight are drained
+//
+//   background jobs (usage sampling, adopt jobs, & ETag persistence) are stopped
+//
+//   in-flight file data (including writes staged in inode's write-back cache) is flushed
+//
+//   volume state (see volume_state.go) is exported and a checkpoint taken so that it, along with
+//   everything flushed above, is durable
+//
+// Lease recall, draining, and awaiting background jobs are together bounded by [FSGlobals]ShutdownDrainTimeout.
+// Leases not released by then are released regardless, and operations still in flight are abandoned (with
+// a warning logged) as the process is exiting anyway. FileHandles and leases are bound to mounts, which do
+// not outlive the process, so are not themselves persisted. Open files that have been unlinked, however,
+// are already recorded as orphans (see orphan.go) and so are reaped once the volume is next brought up.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const defaultShutdownDrainTimeout = 30 * time.Second
+
+func fetchShutdownDrainTimeout(confMap conf.ConfMap) {
+	shutdownDrainTimeout, err := confMap.FetchOptionValueDuration("FSGlobals", "ShutdownDrainTimeout")
+	if nil != err {
+		shutdownDrainTimeout = defaultShutdownDrainTimeout
+	}
+
+	globals.Lock()
+	globals.shutdownDrainTimeout = shutdownDrainTimeout
+	globals.Unlock()
+}
+
+func shutdown() (err error) {
+	globals.Lock()
+	if globals.shuttingDown {
+		globals.Unlock()
+		return
+	}
+	globals.shuttingDown = true
+	deadline := time.Now().Add(globals.shutdownDrainTimeout)
+	volumes := make([]*volumeStruct, 0, len(globals.volumeMap))
+	for _, vS := range globals.volumeMap {
+		volumes = append(volumes, vS)
+	}
+	mounts := make([]*mountStruct, 0, len(globals.mountMap))
+	for _, mS := range globals.mountMap {
+		mounts = append(mounts, mS)
+	}
+	globals.Unlock()
+
+	logger.Infof("fs.Shutdown() taking %d volume(s) offline (%d mount(s))", len(volumes), len(mounts))
+
+	for _, vS := range volumes {
+		vS.recallAllLeases(deadline)
+	}
+
+	if !drainMounts(mounts, deadline) {
+		stats.IncrementOperations(&stats.FsShutdownDrainTimeoutOps)
+	}
+
+	for _, vS := range volumes {
+		volumeErr := vS.shutdownVolume(deadline)
+		if nil != volumeErr {
+			logger.ErrorfWithError(volumeErr, "fs.Shutdown() unable to cleanly take volume '%s' offline", vS.volumeName)
+			if nil == err {
+				err = volumeErr
+			}
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsShutdownOps)
+	return
+}
+
+// drainMounts closes each of mounts to new operations and waits until deadline for those in flight to complete.
+func drainMounts(mounts []*mountStruct, deadline time.Time) (drained bool) {
+	for _, mS := range mounts {
+		mS.gate.Lock()
+		if mountStateMounted == mS.gate.state {
+			mS.gate.state = mountStateShutDown
+		}
+		mS.gate.Unlock()
+	}
+
+	deadlineTimer := time.AfterFunc(time.Until(deadline), func() {
+		for _, mS := range mounts {
+			mS.gate.Lock()
+			mS.gate.cond.Broadcast()
+			mS.gate.Unlock()
+		}
+	})
+	defer deadlineTimer.Stop()
+
+	drained = true
+
+	for _, mS := range mounts {
+		mS.gate.Lock()
+		for (0 != mS.gate.inFlight) && time.Now().Before(deadline) {
+			mS.gate.cond.Wait()
+		}
+		if 0 != mS.gate.inFlight {
+			logger.Warnf("fs.Shutdown(): MountID %v of volume '%s' still has %d operation(s) in flight", mS.id, mS.volStruct.volumeName, mS.gate.inFlight)
+			drained = false
+		}
+		mS.gate.Unlock()
+	}
+
+	return
+}
+
+// shutdownVolume stops vS's background jobs and makes everything it has yet to persist durable.
+func (vS *volumeStruct) shutdownVolume(deadline time.Time) (err error) {
+	vS.stopUsageTrend()
+	vS.stopTrashPurger()
+	vS.stopHistory()
+
+	if !vS.stopAdopt(deadline) {
+		logger.Warnf("fs.Shutdown(): volume '%s' adopt jobs still running", vS.volumeName)
+	}
+
+	if !vS.stopETag(deadline) {
+		logger.Warnf("fs.Shutdown(): volume '%s' ETag persist jobs still running", vS.volumeName)
+	}
+
+	vS.untrackInFlightFileInodeDataAll()
+	vS.stopVolumeStateTimers()
+
+	err = vS.exportVolumeState()
+	if nil != err {
+		return
+	}
+
+	err = vS.FlushDir(inode.RootDirInodeNumber) // awaits a checkpoint of the entire volume
+	return
+}
+
+// waitUntil waits until deadline for wg, reporting whether it was signaled in time.
+func waitUntil(wg *sync.WaitGroup, deadline time.Time) (signaled bool) {
+	doneC := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneC)
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-doneC:
+		signaled = true
+	case <-timer.C:
+	}
+
+	return
+}
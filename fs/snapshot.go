@@ -0,0 +1,481 @@
+package fs
+
+// Snapshots
+//
+// CreateSnapshot() flushes the data of files being written and then records (via headhunter, see
+// headhunter/snapshot.go) a read-only snapshot of the volume. Until DeleteSnapshot() is called, the volume
+// as of that moment may be browsed beneath the virtual SnapshotDirName directory of the volume's root
+// (i.e. /.snapshot/<snapshot-name>/...) so that users may restore files themselves via any mount.
+//
+// The .snapshot directory is not listed by Readdir() of the root directory but may be looked up (unless
+// the root directory holds an actual entry of that name). Inodes beneath it are identified by InodeNumbers
+// with snapshotInodeNumberFlag set, the snapshot's ID in the next snapshotIDBits, and the InodeNumber of
+// the inode within the snapshot in the low snapshotInnerInodeNumberBits (the .snapshot directory itself is
+// SnapshotDirInodeNumber). Lookup(), Getstat(), GetType(), Access(), Readdir(), ReaddirOne(), ReaddirPlus(),
+// ReaddirOnePlus(), Read(), ReadInto(), and Readsymlink() are served from the snapshot while Flush() and
+// FlushDir() trivially succeed. Every other operation upon such an inode fails with ReadOnlyError (EROFS)
+// as makeLockID() refuses to lock it. Path based operations (e.g. LookupPath()) do not traverse .snapshot.
+
+import (
+	"sort"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// SnapshotDirName is the name of the virtual directory, in the root directory, holding each snapshot.
+const SnapshotDirName = ".snapshot"
+
+const (
+	snapshotInodeNumberFlag      = uint64(1) << 63
+	snapshotIDBits               = 23
+	snapshotInnerInodeNumberBits = 40
+
+	snapshotIDMax               = (uint64(1) << snapshotIDBits) - 1
+	snapshotInnerInodeNumberMax = (uint64(1) << snapshotInnerInodeNumberBits) - 1
+)
+
+// SnapshotDirInodeNumber is the InodeNumber of the virtual SnapshotDirName directory.
+const SnapshotDirInodeNumber = inode.InodeNumber(snapshotInodeNumberFlag)
+
+func isSnapshotInodeNumber(inodeNumber inode.InodeNumber) bool {
+	return 0 != uint64(inodeNumber)&snapshotInodeNumberFlag
+}
+
+func decodeSnapshotInodeNumber(inodeNumber inode.InodeNumber) (snapshotID uint64, innerInodeNumber inode.InodeNumber) {
+	snapshotID = (uint64(inodeNumber) >> snapshotInnerInodeNumberBits) & snapshotIDMax
+	innerInodeNumber = inode.InodeNumber(uint64(inodeNumber) & snapshotInnerInodeNumberMax)
+	return
+}
+
+func encodeSnapshotInodeNumber(snapshotID uint64, innerInodeNumber inode.InodeNumber) (inodeNumber inode.InodeNumber, err error) {
+	if uint64(innerInodeNumber) > snapshotInnerInodeNumberMax {
+		err = blunder.NewError(blunder.IOError, "inode %v of snapshot %v cannot be presented beneath %s", innerInodeNumber, snapshotID, SnapshotDirName)
+		return
+	}
+	inodeNumber = inode.InodeNumber(snapshotInodeNumberFlag | (snapshotID << snapshotInnerInodeNumberBits) | uint64(innerInodeNumber))
+	return
+}
+
+// outerSnapshotInodeNumber returns the InodeNumber presenting innerInodeNumber, found as basename in
+// innerDirInodeNumber of snapshotID. The ".." of a snapshot's root directory is the .snapshot directory.
+func outerSnapshotInodeNumber(snapshotID uint64, innerDirInodeNumber inode.InodeNumber, basename string, innerInodeNumber inode.InodeNumber) (inodeNumber inode.InodeNumber, err error) {
+	if (inode.RootDirInodeNumber == innerDirInodeNumber) && (".." == basename) {
+		inodeNumber = SnapshotDirInodeNumber
+		return
+	}
+	inodeNumber, err = encodeSnapshotInodeNumber(snapshotID, innerInodeNumber)
+	return
+}
+
+func findSnapshot(id uint64, list []Snapshot) (snapshot Snapshot, ok bool) {
+	for _, snapshot = range list {
+		if id == snapshot.ID {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+func (vS *volumeStruct) listSnapshots() (snapshots []Snapshot) {
+	list := vS.VolumeHandle.SnapshotList()
+	snapshots = make([]Snapshot, 0, len(list))
+	for _, element := range list {
+		snapshots = append(snapshots, Snapshot{ID: element.ID, Name: element.Name, CreateTime: element.CreateTime})
+	}
+	return
+}
+
+func createSnapshot(volumeName string, name string) (snapshot Snapshot, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	err = validateBaseName(name)
+	if nil != err {
+		return
+	}
+	if ("." == name) || (".." == name) {
+		err = blunder.NewError(blunder.InvalidArgError, "\"%s\" is not a valid snapshot name", name)
+		return
+	}
+
+	// Ensure data written but not yet flushed is included
+
+	vS.untrackInFlightFileInodeDataAll()
+
+	id, err := vS.VolumeHandle.SnapshotCreate(name)
+	if nil != err {
+		return
+	}
+	if id > snapshotIDMax {
+		_ = vS.VolumeHandle.SnapshotDelete(id)
+		err = blunder.NewError(blunder.NoSpaceError, "volume '%s' has exhausted its snapshot IDs", volumeName)
+		return
+	}
+
+	snapshot, ok := findSnapshot(id, vS.listSnapshots())
+	if !ok {
+		err = blunder.NewError(blunder.NotFoundError, "snapshot %v of volume '%s' deleted as it was created", id, volumeName)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsSnapshotCreateOps)
+	return
+}
+
+func deleteSnapshot(volumeName string, id uint64) (err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.SnapshotDelete(id)
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsSnapshotDeleteOps)
+	return
+}
+
+func listSnapshots(volumeName string) (snapshots []Snapshot, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	snapshots = vS.listSnapshots()
+
+	stats.IncrementOperations(&stats.FsSnapshotListOps)
+	return
+}
+
+// snapshotVolumeHandle returns the VolumeHandle presenting the snapshot of inodeNumber (which must not be
+// SnapshotDirInodeNumber) along with the InodeNumber within it.
+func (vS *volumeStruct) snapshotVolumeHandle(inodeNumber inode.InodeNumber) (snapshotID uint64, volumeHandle inode.VolumeHandle, innerInodeNumber inode.InodeNumber, err error) {
+	snapshotID, innerInodeNumber = decodeSnapshotInodeNumber(inodeNumber)
+
+	volumeHandle, err = vS.VolumeHandle.FetchSnapshotVolumeHandle(snapshotID)
+	if nil != err {
+		err = blunder.NewError(blunder.NotFoundError, "snapshot %v of volume '%s' not found", snapshotID, vS.volumeName)
+	}
+	return
+}
+
+// snapshotDirEntries returns the entries of the .snapshot directory in the order ReadDir() would.
+func (vS *volumeStruct) snapshotDirEntries() (entries []inode.DirEntry, err error) {
+	snapshots := vS.listSnapshots()
+
+	entries = make([]inode.DirEntry, 0, 2+len(snapshots))
+	entries = append(entries, inode.DirEntry{InodeNumber: SnapshotDirInodeNumber, Basename: ".", Type: inode.DirType})
+	entries = append(entries, inode.DirEntry{InodeNumber: inode.RootDirInodeNumber, Basename: "..", Type: inode.DirType})
+
+	for _, snapshot := range snapshots {
+		entry := inode.DirEntry{Basename: snapshot.Name, Type: inode.DirType}
+		entry.InodeNumber, err = encodeSnapshotInodeNumber(snapshot.ID, inode.RootDirInodeNumber)
+		if nil != err {
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Basename < entries[j].Basename })
+
+	for i := range entries {
+		entries[i].NextDirLocation = inode.InodeDirLocation(i) + 1
+	}
+
+	return
+}
+
+// readSnapshotDir mimics inode.ReadDir() for the .snapshot directory.
+func (vS *volumeStruct) readSnapshotDir(maxEntries uint64, maxBufSize uint64, prevReturned interface{}) (entries []inode.DirEntry, areMoreEntries bool, err error) {
+	allEntries, err := vS.snapshotDirEntries()
+	if nil != err {
+		return
+	}
+
+	dirIndex := 0
+
+	switch prevReturnedAsType := prevReturned.(type) {
+	case inode.InodeDirLocation:
+		if 0 <= prevReturnedAsType {
+			dirIndex = int(prevReturnedAsType) + 1
+		}
+	case string:
+		dirIndex = sort.Search(len(allEntries), func(i int) bool { return allEntries[i].Basename > prevReturnedAsType })
+	default:
+		err = blunder.NewError(blunder.NotSupportedError, "readSnapshotDir() accepts only an InodeDirLocation or string prevReturned")
+		return
+	}
+
+	entries = make([]inode.DirEntry, 0)
+	bufSize := uint64(0)
+	atLeastOneEntryFound := dirIndex < len(allEntries)
+
+	for ; dirIndex < len(allEntries); dirIndex++ {
+		if (0 != maxEntries) && (uint64(len(entries)+1) > maxEntries) {
+			break
+		}
+		if (0 != maxBufSize) && ((bufSize + uint64(allEntries[dirIndex].Size())) > maxBufSize) {
+			break
+		}
+		entries = append(entries, allEntries[dirIndex])
+		bufSize += uint64(allEntries[dirIndex].Size())
+	}
+
+	areMoreEntries = dirIndex < len(allEntries)
+
+	if (0 == len(entries)) && !atLeastOneEntryFound {
+		err = blunder.NewError(blunder.NotFoundError, "ReadDir() called for prevReturned at or beyond end of directory")
+	}
+
+	return
+}
+
+// snapshotAccess is Access() of an inode beneath .snapshot, all of which are read-only.
+func (mS *mountStruct) snapshotAccess(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, accessMode inode.InodeMode) (accessReturn bool) {
+	if 0 != accessMode&(inode.W_OK|inode.P_OK) {
+		return false
+	}
+	if SnapshotDirInodeNumber == inodeNumber {
+		return true // r-x for all
+	}
+
+	_, volumeHandle, innerInodeNumber, err := mS.volStruct.snapshotVolumeHandle(inodeNumber)
+	if nil != err {
+		return false
+	}
+
+	if (0 != accessMode&inode.X_OK) && (0 != mS.options&MountNoExec) {
+		inodeType, err := volumeHandle.GetType(innerInodeNumber)
+		if (nil == err) && (inode.DirType != inodeType) {
+			stats.IncrementOperations(&stats.FsNoExecDeniedOps)
+			return false
+		}
+	}
+
+	accessReturn = volumeHandle.Access(innerInodeNumber, userID, groupID, otherGroupIDs, accessMode)
+	return
+}
+
+// snapshotCheckAccess returns the errors the fs would for a failed F_OK or accessMode Access() check.
+func (mS *mountStruct) snapshotCheckAccess(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, accessMode inode.InodeMode) (err error) {
+	if !mS.snapshotAccess(userID, groupID, otherGroupIDs, inodeNumber, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.snapshotAccess(userID, groupID, otherGroupIDs, inodeNumber, accessMode) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+	}
+	return
+}
+
+func (mS *mountStruct) snapshotLookup(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string) (inodeNumber inode.InodeNumber, err error) {
+	err = mS.snapshotCheckAccess(userID, groupID, otherGroupIDs, dirInodeNumber, inode.X_OK)
+	if nil != err {
+		return
+	}
+
+	if SnapshotDirInodeNumber == dirInodeNumber {
+		var entries []inode.DirEntry
+		entries, err = mS.volStruct.snapshotDirEntries()
+		if nil != err {
+			return
+		}
+		for _, entry := range entries {
+			if basename == entry.Basename {
+				inodeNumber = entry.InodeNumber
+				return
+			}
+		}
+		err = blunder.NewError(blunder.NotFoundError, "snapshot \"%s\" not found", basename)
+		return
+	}
+
+	snapshotID, volumeHandle, innerDirInodeNumber, err := mS.volStruct.snapshotVolumeHandle(dirInodeNumber)
+	if nil != err {
+		return
+	}
+
+	innerInodeNumber, err := volumeHandle.Lookup(innerDirInodeNumber, basename)
+	if nil != err {
+		return
+	}
+
+	inodeNumber, err = outerSnapshotInodeNumber(snapshotID, innerDirInodeNumber, basename, innerInodeNumber)
+	return
+}
+
+func (mS *mountStruct) snapshotGetType(inodeNumber inode.InodeNumber) (inodeType inode.InodeType, err error) {
+	if SnapshotDirInodeNumber == inodeNumber {
+		inodeType = inode.DirType
+		return
+	}
+
+	_, volumeHandle, innerInodeNumber, err := mS.volStruct.snapshotVolumeHandle(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	inodeType, err = volumeHandle.GetType(innerInodeNumber)
+	return
+}
+
+func (mS *mountStruct) snapshotGetstat(inodeNumber inode.InodeNumber) (stat Stat, err error) {
+	if SnapshotDirInodeNumber == inodeNumber {
+		// Present the root directory's ownership & times, but read-only and holding only the snapshots
+
+		rootInodeLock, lockErr := mS.volStruct.getReadLock(inode.RootDirInodeNumber, nil)
+		if nil != lockErr {
+			err = lockErr
+			return
+		}
+		metadata, metadataErr := mS.volStruct.VolumeHandle.GetMetadata(inode.RootDirInodeNumber)
+		rootInodeLock.Unlock()
+		if nil != metadataErr {
+			err = metadataErr
+			return
+		}
+
+		stat = statFromMetadata(inodeNumber, metadata)
+		stat[StatMode] = 0555
+		stat[StatSize] = 0
+		stat[StatNLink] = 2 + uint64(len(mS.volStruct.listSnapshots()))
+		stat[StatNumWrites] = 0
+		stat[StatBlocks] = 0
+		stat[StatChangeCount] = 0
+		return
+	}
+
+	_, volumeHandle, innerInodeNumber, err := mS.volStruct.snapshotVolumeHandle(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	metadata, err := volumeHandle.GetMetadata(innerInodeNumber)
+	if nil != err {
+		return
+	}
+
+	stat = statFromMetadata(inodeNumber, metadata)
+	return
+}
+
+// snapshotReaddir implements Readdir() and its variants for directories beneath .snapshot.
+func (mS *mountStruct) snapshotReaddir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, prevReturned interface{}, maxEntries uint64, maxBufSize uint64) (entries []inode.DirEntry, areMoreEntries bool, err error) {
+	err = mS.snapshotCheckAccess(userID, groupID, otherGroupIDs, inodeNumber, inode.X_OK)
+	if nil != err {
+		return
+	}
+
+	maxEntries = mS.volStruct.capEntries(maxEntries)
+	maxBufSize = mS.volStruct.capBytes(maxBufSize)
+
+	if SnapshotDirInodeNumber == inodeNumber {
+		entries, areMoreEntries, err = mS.volStruct.readSnapshotDir(maxEntries, maxBufSize, prevReturned)
+		return
+	}
+
+	snapshotID, volumeHandle, innerDirInodeNumber, err := mS.volStruct.snapshotVolumeHandle(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	entries, areMoreEntries, err = volumeHandle.ReadDir(innerDirInodeNumber, maxEntries, maxBufSize, prevReturned)
+	if nil != err {
+		return
+	}
+
+	for i := range entries {
+		innerInodeNumber := entries[i].InodeNumber
+		entries[i].InodeNumber, err = outerSnapshotInodeNumber(snapshotID, innerDirInodeNumber, entries[i].Basename, innerInodeNumber)
+		if nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// snapshotReaddirPlus returns the Stat of each of entries (as returned by snapshotReaddir()).
+func (mS *mountStruct) snapshotReaddirPlus(entries []inode.DirEntry) (statEntries []Stat, err error) {
+	statEntries = make([]Stat, len(entries))
+	for i := range entries {
+		statEntries[i], err = mS.snapshotGetstat(entries[i].InodeNumber)
+		if nil != err {
+			return
+		}
+	}
+	return
+}
+
+// snapshotRead implements read() for files beneath .snapshot.
+func (mS *mountStruct) snapshotRead(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, length uint64, dst []byte, profiler *utils.Profiler) (buf []byte, err error) {
+	err = mS.snapshotCheckAccess(userID, groupID, otherGroupIDs, inodeNumber, inode.R_OK)
+	if nil != err {
+		return
+	}
+
+	inodeType, err := mS.snapshotGetType(inodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.FileType != inodeType {
+		err = blunder.NewError(blunder.NotFileError, "%s: expected inode %v to be a file inode, got %v", utils.GetFnName(), inodeNumber, inodeType)
+		return
+	}
+
+	_, volumeHandle, innerInodeNumber, err := mS.volStruct.snapshotVolumeHandle(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if 0 < length {
+		length = mS.volStruct.capBytes(length) // a short read is permitted
+	}
+
+	if nil == dst {
+		buf, err = volumeHandle.Read(innerInodeNumber, offset, length, profiler)
+	} else {
+		var n uint64
+		n, err = volumeHandle.ReadInto(innerInodeNumber, offset, dst[:length], profiler)
+		buf = dst[:n]
+	}
+	if nil == err {
+		mS.noteRead(uint64(len(buf)))
+	}
+
+	return
+}
+
+func (mS *mountStruct) snapshotReadsymlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (target string, err error) {
+	err = mS.snapshotCheckAccess(userID, groupID, otherGroupIDs, inodeNumber, inode.R_OK)
+	if nil != err {
+		return
+	}
+
+	if SnapshotDirInodeNumber == inodeNumber {
+		err = blunder.NewError(blunder.NotSymlinkError, "%s is not a symlink", SnapshotDirName)
+		return
+	}
+
+	_, volumeHandle, innerInodeNumber, err := mS.volStruct.snapshotVolumeHandle(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	target, err = volumeHandle.GetSymlink(innerInodeNumber)
+	return
+}
+
+// refuseSnapshotLock is called by makeLockID() as no inode beneath .snapshot may be locked (and thus modified).
+func refuseSnapshotLock(inodeNumber inode.InodeNumber) (err error) {
+	err = blunder.NewError(blunder.ReadOnlyError, "%s: inode %v is within a read-only snapshot", utils.GetFnName(), inodeNumber)
+	return
+}
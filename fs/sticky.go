@@ -0,0 +1,61 @@
+package fs
+
+// Restricted deletion (the sticky bit)
+//
+// In a directory with the sticky bit (PosixModeSticky) set (e.g. a 1777 /tmp), write & search access
+// to the directory no longer suffice to remove or rename its entries: the caller must also own either
+// the entry's inode or the directory (or be root). Otherwise Unlink(), Rmdir(), and Rename() (of either
+// the entry being moved or one it would replace or exchange with) fail with NotPermError (EPERM). The
+// Swift middleware acts as root and so is unaffected.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// checkRestrictedDeletion returns NotPermError should dirInodeNumber's sticky bit forbid userID
+// removing its entry for targetInodeNumber.
+func (mS *mountStruct) checkRestrictedDeletion(userID inode.InodeUserID, dirInodeNumber inode.InodeNumber, targetInodeNumber inode.InodeNumber) (err error) {
+	if inode.InodeRootUserID == userID {
+		return
+	}
+
+	dirMetadata, err := mS.volStruct.VolumeHandle.GetMetadata(dirInodeNumber)
+	if nil != err {
+		return
+	}
+	if (0 == (dirMetadata.Mode & inode.PosixModeSticky)) || (userID == dirMetadata.UserID) {
+		return
+	}
+
+	targetMetadata, err := mS.volStruct.VolumeHandle.GetMetadata(targetInodeNumber)
+	if nil != err {
+		return
+	}
+	if userID == targetMetadata.UserID {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsStickyDeniedOps)
+	err = blunder.NewError(blunder.NotPermError, "EPERM")
+	return
+}
+
+// checkRestrictedDeletionOfName is checkRestrictedDeletion() of dirInodeNumber's entry basename (if any).
+func (mS *mountStruct) checkRestrictedDeletionOfName(userID inode.InodeUserID, dirInodeNumber inode.InodeNumber, basename string) (err error) {
+	if inode.InodeRootUserID == userID {
+		return
+	}
+
+	targetInodeNumber, err := mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil // nothing to delete
+		}
+		return
+	}
+
+	err = mS.checkRestrictedDeletion(userID, dirInodeNumber, targetInodeNumber)
+	return
+}
@@ -0,0 +1,205 @@
+package fs
+
+// Ranged stream access
+//
+// GetXAttr() and SetXAttr() transfer a stream's entire value, as must the RPCs built upon them. For a
+// stream too large for that (e.g. a multi-megabyte SMB alternate data stream), ReadStream(), WriteStream(),
+// and ResizeStream() access it at an offset, much as Read(), Write(), and Resize() access a file, with
+// GetStreamSize() reporting its current length.
+//
+// Access is checked as for GetXAttr() (ReadStream() & GetStreamSize()) or SetXAttr() (WriteStream() &
+// ResizeStream()), including the namespace rules of xattr.go and the refusal of reserved streams. A stream
+// written this way is not limited to [<volume-section>]XAttrValueMax, although reads of it are limited
+// to MaxBytesPerOperation (see limits.go).
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// checkStreamAccess checks that userID may read (or, if modifying, write) streamName of inodeNumber.
+// Caller must hold the inode's lock.
+func (mS *mountStruct) checkStreamAccess(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, modifying bool) (err error) {
+	var accessMode inode.InodeMode
+
+	if modifying {
+		accessMode = inode.W_OK
+	} else {
+		accessMode = inode.R_OK
+	}
+
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, accessMode) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	if isReservedStream(inodeNumber, streamName) {
+		if modifying {
+			err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		} else {
+			err = blunder.NewError(blunder.StreamNotFound, "ENODATA")
+		}
+		return
+	}
+
+	if modifying {
+		err = mS.volStruct.checkXAttrLimits(streamName, nil)
+		if nil != err {
+			return
+		}
+	}
+
+	err = mS.checkXAttrNamespace(userID, inodeNumber, streamName, modifying)
+	return
+}
+
+func (mS *mountStruct) GetStreamSize(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (size uint64, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeLock, err := mS.volStruct.getReadLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	err = mS.checkStreamAccess(userID, groupID, otherGroupIDs, inodeNumber, streamName, false)
+	if nil != err {
+		return
+	}
+
+	size, err = mS.volStruct.VolumeHandle.GetStreamSize(inodeNumber, streamName)
+
+	stats.IncrementOperations(&stats.FsGetStreamSizeOps)
+	return
+}
+
+// ReadStream returns up to length bytes of streamName starting at offset, short (or empty) if the stream
+// ends sooner.
+func (mS *mountStruct) ReadStream(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, offset uint64, length uint64) (buf []byte, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	length = mS.volStruct.capBytes(length) // a short read is permitted
+
+	inodeLock, err := mS.volStruct.getReadLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	err = mS.checkStreamAccess(userID, groupID, otherGroupIDs, inodeNumber, streamName, false)
+	if nil != err {
+		return
+	}
+
+	buf, err = mS.volStruct.VolumeHandle.ReadStream(inodeNumber, streamName, offset, length)
+
+	stats.IncrementOperations(&stats.FsReadStreamOps)
+	return
+}
+
+// WriteStream writes buf into streamName at offset, creating the stream if necessary.
+func (mS *mountStruct) WriteStream(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, offset uint64, buf []byte) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpWriteStream, Name: streamName}, err)
+	}()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
+	inodeLock, err := mS.volStruct.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	err = mS.checkStreamAccess(userID, groupID, otherGroupIDs, inodeNumber, streamName, true)
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.WriteStream(inodeNumber, streamName, offset, buf)
+	if nil != err {
+		logger.ErrorfWithError(err, "Failed to write stream %v of inode %v", streamName, inodeNumber)
+		return
+	}
+
+	mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
+
+	stats.IncrementOperations(&stats.FsWriteStreamOps)
+	return
+}
+
+// ResizeStream truncates or (zero-)extends streamName to newSize bytes.
+func (mS *mountStruct) ResizeStream(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, newSize uint64) (err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	defer func() {
+		mS.noteHistory(inodeNumber, historyRecordStruct{Op: historyOpResizeStream, Name: streamName}, err)
+	}()
+
+	err = mS.checkWritable()
+	if nil != err {
+		return
+	}
+
+	exitInodes := mS.enterInodes(inodeNumber) // see freeze.go
+	defer exitInodes()
+
+	inodeLock, err := mS.volStruct.getWriteLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	err = mS.checkStreamAccess(userID, groupID, otherGroupIDs, inodeNumber, streamName, true)
+	if nil != err {
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.ResizeStream(inodeNumber, streamName, newSize)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.notifyInode(NotifySetAttr, inodeNumber)
+
+	stats.IncrementOperations(&stats.FsResizeStreamOps)
+	return
+}
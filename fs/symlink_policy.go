@@ -0,0 +1,105 @@
+package fs
+
+// Symlink traversal policy
+//
+// resolvePath() (and hence LookupPath(), the *At() APIs, and the Middleware...() APIs resolving a path), as
+// well as the middleware's walk creating the intermediate directories of a PUT, follow symlinks subject to
+// the volume's policy:
+//
+//   MaxSymlinks               most symlinks followed resolving a path (defaults to MaxSymlinks); following
+//                             more fails with TooManySymlinksError (ELOOP). Reported via GetLimits().
+//   ConfineAbsoluteSymlinks   if true, an absolute target is taken to name a path as seen via the volume's
+//                             FUSEMountPointName (where such symlinks are typically made): one beneath the
+//                             mount point is resolved from the volume's root less the mount point prefix,
+//                             while any other (naming something outside the volume) fails with
+//                             PermDeniedError (EACCES). If false (the default), an absolute target is simply
+//                             resolved from the volume's root.
+//   MiddlewareFollowSymlinks  if false, a Middleware...() API reaching a symlink anywhere along a path fails
+//                             with PermDeniedError rather than following it, so that HTTP access reaches only
+//                             what is actually at the path named (defaults to true).
+//
+// A refused symlink is reported (see path_failure.go) as PathFailurePermDenied naming the symlink as Segment.
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type symlinkPolicyStruct struct {
+	confineAbsolute  bool   // [<volume-section>]ConfineAbsoluteSymlinks
+	mountPointPath   string // absolute path of [<volume-section>]FUSEMountPointName ("" if none)
+	middlewareFollow bool   // [<volume-section>]MiddlewareFollowSymlinks
+}
+
+// symlinkFollowerStruct tracks the symlinks followed resolving a single path.
+type symlinkFollowerStruct struct {
+	policy        symlinkPolicyStruct
+	remaining     uint64
+	forMiddleware bool
+}
+
+func (vS *volumeStruct) configureSymlinkPolicy(confineAbsolute bool, mountPointName string, middlewareFollow bool) {
+	mountPointPath := ""
+	if "" != mountPointName {
+		absMountPointName, err := filepath.Abs(mountPointName) // as resolved by package fuse
+		if nil == err {
+			mountPointPath = strings.TrimRight(absMountPointName, "/")
+		}
+	}
+
+	vS.Lock()
+	vS.symlinkPolicy = symlinkPolicyStruct{
+		confineAbsolute:  confineAbsolute,
+		mountPointPath:   mountPointPath,
+		middlewareFollow: middlewareFollow,
+	}
+	vS.Unlock()
+}
+
+// newSymlinkFollower returns a symlinkFollowerStruct for resolving a path on behalf of a Middleware...() API
+// (if forMiddleware) or any other.
+func (vS *volumeStruct) newSymlinkFollower(forMiddleware bool) (follower *symlinkFollowerStruct) {
+	vS.Lock()
+	follower = &symlinkFollowerStruct{
+		policy:        vS.symlinkPolicy,
+		remaining:     vS.limits.MaxSymlinks,
+		forMiddleware: forMiddleware,
+	}
+	vS.Unlock()
+	return
+}
+
+// follow accounts for following a symlink to target while resolving fullpath, returning the path to
+// resolve in its stead and whether that is to be resolved from the volume's root.
+func (follower *symlinkFollowerStruct) follow(fullpath string, target string) (newTarget string, fromRoot bool, err error) {
+	if follower.forMiddleware && !follower.policy.middlewareFollow {
+		stats.IncrementOperations(&stats.FsSymlinkRefusedOps)
+		err = blunder.NewError(blunder.PermDeniedError, "Symlinks are not followed for middleware requests resolving %s", fullpath)
+		return
+	}
+
+	if 0 == follower.remaining {
+		err = blunder.NewError(blunder.TooManySymlinksError, "Too many symlinks while resolving %s", fullpath)
+		return
+	}
+	follower.remaining--
+
+	newTarget = target
+	fromRoot = strings.HasPrefix(target, "/")
+
+	if fromRoot && follower.policy.confineAbsolute {
+		mountPointPath := follower.policy.mountPointPath
+		if ("" != mountPointPath) && ((target == mountPointPath) || strings.HasPrefix(target, mountPointPath+"/")) {
+			newTarget = "/" + strings.TrimLeft(target[len(mountPointPath):], "/")
+		} else {
+			stats.IncrementOperations(&stats.FsSymlinkRefusedOps)
+			err = blunder.NewError(blunder.PermDeniedError, "Symlink target %s lies outside the volume resolving %s", target, fullpath)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,635 @@
+package fs
+
+// Trash
+//
+// While [<volume-section>]TrashEnabled is true, neither Unlink() of a file's last name nor Rmdir() destroys
+// the inode. It is instead moved into the volume's trash directory: a directory named by no other (so
+// invisible to clients) whose InodeNumber is recorded in TrashStream, a reserved stream on the root
+// directory inode. There, each trashed inode is named by its InodeNumber (see trashName()) and records, in
+// its own TrashEntryStream, the directory and name from which it was removed and when. As the move is a
+// single inode.Move(), a crash can neither lose nor leak a trashed inode.
+//
+// ListTrash() returns a volume's trashed inodes, RestoreTrash() moves one back to the name from which it was
+// removed (failing should that name have since been reused or its directory be gone or itself trashed), and
+// PurgeTrash() destroys one. A TrashEntry's OriginalPath is that of its directory as of the ListTrash() (or,
+// should the directory itself be trashed, the path to which it would be restored). Trashed inodes older than
+// [<volume-section>]TrashRetention are purged every [<volume-section>]TrashPurgeInterval.
+//
+// Until purged, trashed files continue to consume space (and count against any quota). Removing one of
+// several hard links to a file loses nothing so is not trashed. Files replaced by Rename() or deleted via the
+// Swift middleware are destroyed as before.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// TrashStream is the reserved stream on the root directory inode recording the volume's trash directory.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const TrashStream = "proxyfs.trash"
+
+// TrashEntryStream is the reserved stream on each trashed inode recording from where it was removed.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const TrashEntryStream = "proxyfs.trashentry"
+
+const (
+	defaultTrashRetention     = 7 * 24 * time.Hour
+	defaultTrashPurgeInterval = time.Hour
+
+	trashMaxPathDepth = 4096 // ".." traversals before OriginalPath is deemed undeterminable
+)
+
+type trashStruct struct {
+	sync.Mutex
+	enabled        bool              // [<volume-section>]TrashEnabled
+	retention      time.Duration     // [<volume-section>]TrashRetention (0 == never purged automatically)
+	purgeInterval  time.Duration     // [<volume-section>]TrashPurgeInterval
+	dirInodeNumber inode.InodeNumber // 0 == volume has no trash directory
+	stopC          chan struct{}     // non-nil while the purger goroutine is running
+	purgerWG       sync.WaitGroup    // signaled once the purger goroutine exits
+}
+
+type hiddenDirStreamStruct struct {
+	DirInodeNumber inode.InodeNumber
+}
+
+type trashEntryStreamStruct struct {
+	DirInodeNumber inode.InodeNumber
+	Basename       string
+	DeleteTime     time.Time
+}
+
+func (vS *volumeStruct) configureTrash(enabled bool, retention time.Duration, purgeInterval time.Duration) {
+	vS.trash.Lock()
+	vS.trash.enabled = enabled
+	vS.trash.retention = retention
+	vS.trash.purgeInterval = purgeInterval
+	vS.trash.Unlock()
+}
+
+// trashName returns the name of inodeNumber in the trash directory.
+func trashName(inodeNumber inode.InodeNumber) string {
+	return fmt.Sprintf("%016X", uint64(inodeNumber))
+}
+
+// loadTrash finds (or, if [<volume-section>]TrashEnabled, creates) the volume's trash directory.
+//
+// Caller must not hold any inode locks (the root directory inode's write lock is obtained).
+func (vS *volumeStruct) loadTrash() (err error) {
+	vS.trash.Lock()
+	enabled := vS.trash.enabled
+	vS.trash.Unlock()
+
+	trashDirInodeNumber, err := vS.loadHiddenDir(TrashStream, enabled)
+	if nil != err {
+		return
+	}
+
+	vS.trash.Lock()
+	vS.trash.dirInodeNumber = trashDirInodeNumber
+	vS.trash.Unlock()
+
+	return
+}
+
+// loadHiddenDir returns the directory recorded in streamName, a reserved stream on the root directory
+// inode, or 0 if there is none. Should there be none and create be true, an unlinked directory (so one
+// invisible to clients) is first created and recorded there. Trash (and file versions) are kept in such
+// directories.
+//
+// Caller must not hold any inode locks (the root directory inode's write lock is obtained).
+func (vS *volumeStruct) loadHiddenDir(streamName string, create bool) (dirInodeNumber inode.InodeNumber, err error) {
+	rootInodeLock, err := vS.getWriteLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	var hiddenDirStream hiddenDirStreamStruct
+
+	buf, err := vS.VolumeHandle.GetStream(inode.RootDirInodeNumber, streamName)
+	if nil == err {
+		err = json.Unmarshal(buf, &hiddenDirStream)
+		if nil != err {
+			err = blunder.AddError(fmt.Errorf("fs: corrupt %s stream in volume '%s': %v", streamName, vS.volumeName, err), blunder.UnpackError)
+			return
+		}
+		dirInodeNumber = hiddenDirStream.DirInodeNumber
+		return
+	}
+	if blunder.IsNot(err, blunder.StreamNotFound) {
+		return
+	}
+	err = nil
+	if !create {
+		return
+	}
+
+	hiddenDirStream.DirInodeNumber, err = vS.VolumeHandle.CreateDir(inode.InodeMode(0700), inode.InodeRootUserID, inode.InodeRootGroupID)
+	if nil != err {
+		return
+	}
+
+	buf, err = json.Marshal(hiddenDirStream)
+	if nil != err {
+		err = blunder.AddError(err, blunder.PackError)
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(inode.RootDirInodeNumber, streamName, buf)
+	if nil != err {
+		destroyErr := vS.VolumeHandle.Destroy(hiddenDirStream.DirInodeNumber)
+		if nil != destroyErr {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed PutStream() in fs.loadHiddenDir", hiddenDirStream.DirInodeNumber)
+		}
+		return
+	}
+
+	dirInodeNumber = hiddenDirStream.DirInodeNumber
+	return
+}
+
+// fetchTrashDir returns the volume's trash directory (0 if none) and whether removed inodes are to be trashed.
+func (vS *volumeStruct) fetchTrashDir() (trashDirInodeNumber inode.InodeNumber, enabled bool) {
+	vS.trash.Lock()
+	trashDirInodeNumber = vS.trash.dirInodeNumber
+	enabled = vS.trash.enabled && (0 != trashDirInodeNumber)
+	vS.trash.Unlock()
+	return
+}
+
+// getUnlinkLock acquires the lock(s) Unlink() needs to remove basename from a directory. These are those of
+// getDirEntryLock() unless the inode may be trashed, as its inode.Move() requires a write lock on the directory.
+func (vS *volumeStruct) getUnlinkLock(dirInodeNumber inode.InodeNumber, basename string, callerID dlm.CallerID) (dirEntryLock *dirEntryLockStruct, err error) {
+	_, enabled := vS.fetchTrashDir()
+	if !enabled {
+		dirEntryLock, err = vS.getDirEntryLock(dirInodeNumber, basename, callerID)
+		return
+	}
+
+	dirLock, err := vS.getWriteLock(dirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+
+	dirEntryLock = &dirEntryLockStruct{dirLock: dirLock, shardLock: nil}
+	return
+}
+
+// trashInode moves inodeNumber, named basename in dirInodeNumber, into the trash directory.
+//
+// Caller must hold (via callerID) write locks on both dirInodeNumber and inodeNumber.
+func (vS *volumeStruct) trashInode(callerID dlm.CallerID, trashDirInodeNumber inode.InodeNumber, dirInodeNumber inode.InodeNumber, basename string, inodeNumber inode.InodeNumber) (err error) {
+	trashDirInodeLock, err := vS.getWriteLock(trashDirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer trashDirInodeLock.Unlock()
+
+	buf, err := json.Marshal(trashEntryStreamStruct{
+		DirInodeNumber: dirInodeNumber,
+		Basename:       basename,
+		DeleteTime:     time.Now(),
+	})
+	if nil != err {
+		err = blunder.AddError(err, blunder.PackError)
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(inodeNumber, TrashEntryStream, buf)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.Move(dirInodeNumber, basename, trashDirInodeNumber, trashName(inodeNumber), inode.MoveNoReplace)
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsTrashOps)
+	return
+}
+
+// fetchTrashEntry returns the TrashEntry of inodeNumber (which need not be trashed). Caller must hold its lock.
+func (vS *volumeStruct) fetchTrashEntry(inodeNumber inode.InodeNumber) (entry TrashEntry, err error) {
+	buf, err := vS.VolumeHandle.GetStream(inodeNumber, TrashEntryStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = blunder.NewError(blunder.NotFoundError, "inode %v of volume '%s' was never trashed", inodeNumber, vS.volumeName)
+		}
+		return
+	}
+
+	var trashEntryStream trashEntryStreamStruct
+
+	err = json.Unmarshal(buf, &trashEntryStream)
+	if nil != err {
+		err = blunder.AddError(fmt.Errorf("fs: corrupt %s stream of inode %v in volume '%s': %v", TrashEntryStream, inodeNumber, vS.volumeName, err), blunder.UnpackError)
+		return
+	}
+
+	entry.InodeType, err = vS.VolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	entry.InodeNumber = inodeNumber
+	entry.DirInodeNumber = trashEntryStream.DirInodeNumber
+	entry.Basename = trashEntryStream.Basename
+	entry.DeleteTime = trashEntryStream.DeleteTime
+	return
+}
+
+// trashedInodeNumbers returns the InodeNumbers of the inodes in the trash directory.
+func (vS *volumeStruct) trashedInodeNumbers(trashDirInodeNumber inode.InodeNumber) (inodeNumbers []inode.InodeNumber, err error) {
+	trashDirInodeLock, err := vS.getReadLock(trashDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	dirEntries, _, err := vS.VolumeHandle.ReadDir(trashDirInodeNumber, 0, 0)
+	trashDirInodeLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	inodeNumbers = make([]inode.InodeNumber, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if ("." != dirEntry.Basename) && (".." != dirEntry.Basename) {
+			inodeNumbers = append(inodeNumbers, dirEntry.InodeNumber)
+		}
+	}
+
+	return
+}
+
+// fetchDirPath returns the path of dirInodeNumber from the root directory or, should it (or one of its
+// ancestors) be trashed, the path to which it would be restored.
+func (vS *volumeStruct) fetchDirPath(trashDirInodeNumber inode.InodeNumber, dirInodeNumber inode.InodeNumber) (path string, err error) {
+	basenames := make([]string, 0)
+
+	for inode.RootDirInodeNumber != dirInodeNumber {
+		if trashMaxPathDepth <= len(basenames) {
+			err = blunder.NewError(blunder.TooManySymlinksError, "directory %v of volume '%s' is too deep", dirInodeNumber, vS.volumeName)
+			return
+		}
+
+		var (
+			basename          string
+			parentInodeNumber inode.InodeNumber
+		)
+
+		parentInodeNumber, basename, err = vS.fetchParentAndName(trashDirInodeNumber, dirInodeNumber)
+		if nil != err {
+			return
+		}
+
+		basenames = append(basenames, basename)
+		dirInodeNumber = parentInodeNumber
+	}
+
+	for i, j := 0, len(basenames)-1; i < j; i, j = i+1, j-1 {
+		basenames[i], basenames[j] = basenames[j], basenames[i]
+	}
+
+	path = "/" + strings.Join(basenames, "/")
+	return
+}
+
+// fetchParentAndName returns the directory containing dirInodeNumber and its name there or, should it be
+// trashed, the directory and name from which it was removed.
+func (vS *volumeStruct) fetchParentAndName(trashDirInodeNumber inode.InodeNumber, dirInodeNumber inode.InodeNumber) (parentInodeNumber inode.InodeNumber, basename string, err error) {
+	dirInodeLock, err := vS.getReadLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	parentInodeNumber, err = vS.VolumeHandle.Lookup(dirInodeNumber, "..")
+	if (nil == err) && (trashDirInodeNumber == parentInodeNumber) {
+		var entry TrashEntry
+		entry, err = vS.fetchTrashEntry(dirInodeNumber)
+		parentInodeNumber, basename = entry.DirInodeNumber, entry.Basename
+		dirInodeLock.Unlock()
+		return
+	}
+	dirInodeLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	parentInodeLock, err := vS.getReadLock(parentInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	dirEntries, _, err := vS.VolumeHandle.ReadDir(parentInodeNumber, 0, 0)
+	parentInodeLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	for _, dirEntry := range dirEntries {
+		if (dirInodeNumber == dirEntry.InodeNumber) && ("." != dirEntry.Basename) && (".." != dirEntry.Basename) {
+			basename = dirEntry.Basename
+			return
+		}
+	}
+
+	err = blunder.NewError(blunder.NotFoundError, "directory %v of volume '%s' not found in its parent %v", dirInodeNumber, vS.volumeName, parentInodeNumber)
+	return
+}
+
+func listTrash(volumeName string) (entries []TrashEntry, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	entries = make([]TrashEntry, 0)
+
+	trashDirInodeNumber, _ := vS.fetchTrashDir()
+	if 0 == trashDirInodeNumber {
+		stats.IncrementOperations(&stats.FsTrashListOps)
+		return
+	}
+
+	inodeNumbers, err := vS.trashedInodeNumbers(trashDirInodeNumber)
+	if nil != err {
+		return
+	}
+
+	for _, inodeNumber := range inodeNumbers {
+		inodeLock, lockErr := vS.getReadLock(inodeNumber, nil)
+		if nil != lockErr {
+			err = lockErr
+			return
+		}
+		entry, entryErr := vS.fetchTrashEntry(inodeNumber)
+		inodeLock.Unlock()
+		if nil != entryErr {
+			if blunder.Is(entryErr, blunder.NotFoundError) {
+				continue // restored or purged since we found it
+			}
+			err = entryErr
+			return
+		}
+
+		dirPath, pathErr := vS.fetchDirPath(trashDirInodeNumber, entry.DirInodeNumber)
+		if nil == pathErr {
+			entry.OriginalPath = strings.TrimSuffix(dirPath, "/") + "/" + entry.Basename
+		}
+
+		entries = append(entries, entry)
+	}
+
+	stats.IncrementOperations(&stats.FsTrashListOps)
+	return
+}
+
+func restoreTrash(volumeName string, inodeNumber inode.InodeNumber) (err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	trashDirInodeNumber, _ := vS.fetchTrashDir()
+	if 0 == trashDirInodeNumber {
+		err = blunder.NewError(blunder.NotFoundError, "volume '%s' has no trash", volumeName)
+		return
+	}
+
+	// Locks are taken in the order Unlink() takes them (directory, inode, then trash directory), so the
+	// directory to restore to must be learned before it is locked (and confirmed once it is)
+
+	inodeLock, err := vS.getReadLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	entry, err := vS.fetchTrashEntry(inodeNumber)
+	inodeLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	callerID := dlm.GenerateCallerID()
+
+	dirInodeLock, err := vS.getWriteLock(entry.DirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	inodeLock, err = vS.getWriteLock(inodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	trashDirInodeLock, err := vS.getWriteLock(trashDirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer trashDirInodeLock.Unlock()
+
+	trashedInodeNumber, err := vS.VolumeHandle.Lookup(trashDirInodeNumber, trashName(inodeNumber))
+	if (nil != err) || (inodeNumber != trashedInodeNumber) {
+		err = blunder.NewError(blunder.NotFoundError, "inode %v is not in the trash of volume '%s'", inodeNumber, volumeName)
+		return
+	}
+
+	lockedEntry, err := vS.fetchTrashEntry(inodeNumber)
+	if nil != err {
+		return
+	}
+	if (entry.DirInodeNumber != lockedEntry.DirInodeNumber) || (entry.Basename != lockedEntry.Basename) {
+		err = blunder.NewError(blunder.TryAgainError, "inode %v of volume '%s' was restored and trashed again", inodeNumber, volumeName)
+		return
+	}
+
+	dirInodeType, err := vS.VolumeHandle.GetType(entry.DirInodeNumber)
+	if nil != err {
+		err = blunder.NewError(blunder.NotFoundError, "directory %v from which inode %v was removed no longer exists", entry.DirInodeNumber, inodeNumber)
+		return
+	}
+	if inode.DirType != dirInodeType {
+		err = blunder.NewError(blunder.NotDirError, "inode %v from which inode %v was removed is not a directory", entry.DirInodeNumber, inodeNumber)
+		return
+	}
+	if inode.RootDirInodeNumber != entry.DirInodeNumber {
+		parentInodeNumber, parentErr := vS.VolumeHandle.Lookup(entry.DirInodeNumber, "..")
+		if (nil == parentErr) && (trashDirInodeNumber == parentInodeNumber) {
+			err = blunder.NewError(blunder.NotFoundError, "directory %v from which inode %v was removed must be restored first", entry.DirInodeNumber, inodeNumber)
+			return
+		}
+	}
+
+	err = vS.VolumeHandle.Move(trashDirInodeNumber, trashName(inodeNumber), entry.DirInodeNumber, entry.Basename, inode.MoveNoReplace)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.DeleteStream(inodeNumber, TrashEntryStream)
+	if nil != err {
+		logger.WarnfWithError(err, "fs: couldn't delete %s of restored inode %v in volume '%s'", TrashEntryStream, inodeNumber, volumeName)
+		err = nil // merely ignored should the inode be trashed again
+	}
+
+	vS.notifyName(NotifyCreate, entry.DirInodeNumber, entry.Basename, inodeNumber)
+
+	stats.IncrementOperations(&stats.FsTrashRestoreOps)
+	return
+}
+
+func purgeTrash(volumeName string, inodeNumber inode.InodeNumber) (err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	trashDirInodeNumber, _ := vS.fetchTrashDir()
+	if 0 == trashDirInodeNumber {
+		err = blunder.NewError(blunder.NotFoundError, "volume '%s' has no trash", volumeName)
+		return
+	}
+
+	_, err = vS.purgeTrashedInode(trashDirInodeNumber, inodeNumber, time.Time{})
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsTrashPurgeOps)
+	return
+}
+
+// purgeTrashedInode destroys inodeNumber unless, should cutoff be non-zero, it was trashed after cutoff.
+func (vS *volumeStruct) purgeTrashedInode(trashDirInodeNumber inode.InodeNumber, inodeNumber inode.InodeNumber, cutoff time.Time) (purged bool, err error) {
+	callerID := dlm.GenerateCallerID()
+
+	inodeLock, err := vS.getWriteLock(inodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	trashDirInodeLock, err := vS.getWriteLock(trashDirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer trashDirInodeLock.Unlock()
+
+	trashedInodeNumber, err := vS.VolumeHandle.Lookup(trashDirInodeNumber, trashName(inodeNumber))
+	if (nil != err) || (inodeNumber != trashedInodeNumber) {
+		err = blunder.NewError(blunder.NotFoundError, "inode %v is not in the trash of volume '%s'", inodeNumber, vS.volumeName)
+		return
+	}
+
+	if !cutoff.IsZero() {
+		entry, entryErr := vS.fetchTrashEntry(inodeNumber)
+		if (nil == entryErr) && entry.DeleteTime.After(cutoff) {
+			return
+		}
+	}
+
+	err = vS.VolumeHandle.Unlink(trashDirInodeNumber, trashName(inodeNumber))
+	if nil != err {
+		return
+	}
+
+	vS.untrackInFlightFileInodeData(inodeNumber, false)
+
+	err = vS.VolumeHandle.Destroy(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	vS.destroyVersions(inodeNumber)
+
+	purged = true
+	return
+}
+
+// startTrashPurger launches the purger goroutine (if trashing with a non-zero [<volume-section>]TrashRetention).
+func (vS *volumeStruct) startTrashPurger() {
+	_, enabled := vS.fetchTrashDir()
+
+	vS.trash.Lock()
+	if enabled && (0 != vS.trash.retention) && (nil == vS.trash.stopC) {
+		vS.trash.stopC = make(chan struct{})
+		vS.trash.purgerWG.Add(1)
+		go vS.trashPurger(vS.trash.purgeInterval, vS.trash.stopC)
+	}
+	vS.trash.Unlock()
+}
+
+// stopTrashPurger is called as a volume is taken offline.
+func (vS *volumeStruct) stopTrashPurger() {
+	vS.trash.Lock()
+	stopC := vS.trash.stopC
+	vS.trash.stopC = nil
+	vS.trash.Unlock()
+
+	if nil != stopC {
+		close(stopC)
+		vS.trash.purgerWG.Wait()
+	}
+}
+
+func (vS *volumeStruct) trashPurger(interval time.Duration, stopC chan struct{}) {
+	defer vS.trash.purgerWG.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopC:
+			return
+		case now := <-ticker.C:
+			vS.purgeExpiredTrash(now, stopC)
+		}
+	}
+}
+
+// purgeExpiredTrash destroys each inode trashed more than [<volume-section>]TrashRetention before now.
+func (vS *volumeStruct) purgeExpiredTrash(now time.Time, stopC chan struct{}) {
+	vS.trash.Lock()
+	retention := vS.trash.retention
+	vS.trash.Unlock()
+
+	trashDirInodeNumber, _ := vS.fetchTrashDir()
+	if (0 == trashDirInodeNumber) || (0 == retention) {
+		return
+	}
+
+	inodeNumbers, err := vS.trashedInodeNumbers(trashDirInodeNumber)
+	if nil != err {
+		logger.WarnfWithError(err, "fs: couldn't list the trash of volume '%s'", vS.volumeName)
+		return
+	}
+
+	cutoff := now.Add(-retention)
+
+	for _, inodeNumber := range inodeNumbers {
+		select {
+		case <-stopC:
+			return
+		default:
+		}
+
+		purged, purgeErr := vS.purgeTrashedInode(trashDirInodeNumber, inodeNumber, cutoff)
+		if purged {
+			stats.IncrementOperations(&stats.FsTrashAutoPurgeOps)
+		} else if (nil != purgeErr) && blunder.IsNot(purgeErr, blunder.NotFoundError) {
+			logger.WarnfWithError(purgeErr, "fs: couldn't purge trashed inode %v of volume '%s'", inodeNumber, vS.volumeName)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package fs
 import (
 	"fmt"
 
+	"github.com/swiftstack/ProxyFS/blunder"
 	"github.com/swiftstack/ProxyFS/inode"
 )
 
@@ -133,3 +134,66 @@ func (tWS *treeWalkStruct) validateNonDirInode(nonDirInodeNumber inode.InodeNumb
 	}
 	return
 }
+
+// walkFiles calls fileFunc once for each file inode named in the volume (however many hard links it
+// has). Files removed during the walk (i.e. for which fileFunc returns NotFoundError) are skipped.
+// The walk ends early, returning stopped == true, should stopChan be signaled.
+func (vS *volumeStruct) walkFiles(stopChan chan bool, fileFunc func(fileInodeNumber inode.InodeNumber) error) (stopped bool, err error) {
+	visited := make(map[inode.InodeNumber]struct{})
+	dirStack := []inode.InodeNumber{inode.RootDirInodeNumber}
+
+	for 0 < len(dirStack) {
+		dirInodeNumber := dirStack[len(dirStack)-1]
+		dirStack = dirStack[:len(dirStack)-1]
+
+		dirInodeLock, lockErr := vS.getReadLock(dirInodeNumber, nil)
+		if nil != lockErr {
+			err = lockErr
+			return
+		}
+		dirEntries, _, readDirErr := vS.VolumeHandle.ReadDir(dirInodeNumber, 0, 0)
+		dirInodeLock.Unlock()
+		if nil != readDirErr {
+			if blunder.Is(readDirErr, blunder.NotFoundError) {
+				continue // removed since we found it
+			}
+			err = readDirErr
+			return
+		}
+
+		for _, dirEntry := range dirEntries {
+			select {
+			case <-stopChan:
+				stopped = true
+				return
+			default:
+			}
+
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+			if _, ok := visited[dirEntry.InodeNumber]; ok {
+				continue // another hard link to a file already visited
+			}
+			visited[dirEntry.InodeNumber] = struct{}{}
+
+			inodeType, typeErr := vS.VolumeHandle.GetType(dirEntry.InodeNumber)
+			if nil != typeErr {
+				continue // removed since we found it
+			}
+
+			switch inodeType {
+			case inode.DirType:
+				dirStack = append(dirStack, dirEntry.InodeNumber)
+			case inode.FileType:
+				fileErr := fileFunc(dirEntry.InodeNumber)
+				if (nil != fileErr) && blunder.IsNot(fileErr, blunder.NotFoundError) {
+					err = fileErr
+					return
+				}
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,274 @@
+package fs
+
+// Volume usage trend & alerts
+//
+// While [<volume-section>]UsageSampleInterval is non-zero, a per-volume goroutine samples the volume that
+// often: bytes & inodes used (as reported by StatVfs(), so possibly up to UsageCacheTTL old) and the number
+// of operations begun since the prior sample. The most recent [<volume-section>]UsageSampleCount samples
+// are kept in a ring buffer returned (oldest first) by FetchUsageTrend() and served by the httpserver at
+// /volume/<volume-name>/usage-trend.
+//
+// Each sample is also checked against the volume's capacity (see fetchCapacity()). A UsageAlert is raised
+// once bytes or inodes used reach [<volume-section>]UsageAlertPercent of capacity or, extrapolating the
+// growth seen across the ring buffer, would exhaust it within [<volume-section>]UsageAlertHorizon. Each
+// such alert is raised but once, being re-armed only once usage no longer warrants it. Alerts are logged,
+// POSTed (JSON-encoded) to [<volume-section>]UsageAlertWebhook (if set), and handed to each handler
+// registered via RegisterUsageAlertHandler().
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	defaultUsageSampleInterval = time.Minute
+	defaultUsageSampleCount    = uint64(60)
+	defaultUsageAlertPercent   = uint64(90)
+	defaultUsageAlertHorizon   = 24 * time.Hour
+
+	usageAlertWebhookTimeout = 10 * time.Second
+)
+
+type usageTrendStruct struct {
+	sync.Mutex
+	ops            uint64         // operations begun (see enterOp()); accessed atomically
+	interval       time.Duration  // [<volume-section>]UsageSampleInterval (0 == not sampled)
+	alertPercent   uint64         // [<volume-section>]UsageAlertPercent (0 == no threshold)
+	alertHorizon   time.Duration  // [<volume-section>]UsageAlertHorizon (0 == no extrapolation)
+	alertWebhook   string         // [<volume-section>]UsageAlertWebhook ("" == none)
+	samples        []UsageSample  // ring buffer of cap() == [<volume-section>]UsageSampleCount
+	next           int            // once len(samples) == cap(samples), index of the oldest sample
+	lastOps        uint64         // ops as of the latest sample
+	bytesAlerting  bool           // if true, a "bytes" UsageAlert has been raised and not yet re-armed
+	inodesAlerting bool           // if true, an "inodes" UsageAlert has been raised and not yet re-armed
+	stopC          chan struct{}  // non-nil while the sampler goroutine is running
+	samplerWG      sync.WaitGroup // signaled once the sampler goroutine exits
+}
+
+func (vS *volumeStruct) configureUsageTrend(interval time.Duration, sampleCount uint64, alertPercent uint64, alertHorizon time.Duration, alertWebhook string) {
+	vS.usageTrend.Lock()
+	vS.usageTrend.interval = interval
+	vS.usageTrend.alertPercent = alertPercent
+	vS.usageTrend.alertHorizon = alertHorizon
+	vS.usageTrend.alertWebhook = alertWebhook
+	if uint64(cap(vS.usageTrend.samples)) != sampleCount {
+		samples := vS.usageTrend.orderedSamples()
+		if uint64(len(samples)) > sampleCount {
+			samples = samples[uint64(len(samples))-sampleCount:]
+		}
+		vS.usageTrend.samples = append(make([]UsageSample, 0, sampleCount), samples...)
+		vS.usageTrend.next = 0
+	}
+	vS.usageTrend.Unlock()
+}
+
+// startUsageTrend launches the sampler goroutine (if [<volume-section>]UsageSampleInterval is non-zero).
+func (vS *volumeStruct) startUsageTrend() {
+	vS.usageTrend.Lock()
+	if (0 != vS.usageTrend.interval) && (nil == vS.usageTrend.stopC) {
+		vS.usageTrend.stopC = make(chan struct{})
+		vS.usageTrend.samplerWG.Add(1)
+		go vS.usageSampler(vS.usageTrend.interval, vS.usageTrend.stopC)
+	}
+	vS.usageTrend.Unlock()
+}
+
+// stopUsageTrend is called as a volume is taken offline.
+func (vS *volumeStruct) stopUsageTrend() {
+	vS.usageTrend.Lock()
+	stopC := vS.usageTrend.stopC
+	vS.usageTrend.stopC = nil
+	vS.usageTrend.Unlock()
+
+	if nil != stopC {
+		close(stopC)
+		vS.usageTrend.samplerWG.Wait()
+	}
+}
+
+func (vS *volumeStruct) usageSampler(interval time.Duration, stopC chan struct{}) {
+	defer vS.usageTrend.samplerWG.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopC:
+			return
+		case now := <-ticker.C:
+			vS.sampleUsage(now)
+		}
+	}
+}
+
+// noteOp counts an operation begun upon the volume.
+func (vS *volumeStruct) noteOp() {
+	atomic.AddUint64(&vS.usageTrend.ops, 1)
+}
+
+// orderedSamples returns the ring buffer's samples, oldest first. Caller must hold the usageTrend lock.
+func (usageTrend *usageTrendStruct) orderedSamples() (samples []UsageSample) {
+	samples = make([]UsageSample, 0, len(usageTrend.samples))
+	samples = append(samples, usageTrend.samples[usageTrend.next:]...)
+	samples = append(samples, usageTrend.samples[:usageTrend.next]...)
+	return
+}
+
+// sampleUsage records a sample of the volume's usage as of now, raising any UsageAlerts it warrants.
+func (vS *volumeStruct) sampleUsage(now time.Time) {
+	var (
+		alerts []UsageAlert
+		sample UsageSample
+	)
+
+	totalBlocks, _, _, totalInodes, _, _ := vS.fetchCapacity()
+	usage := vS.fetchUsage() // just cached by fetchCapacity()
+	ops := atomic.LoadUint64(&vS.usageTrend.ops)
+
+	usageTrend := &vS.usageTrend
+
+	usageTrend.Lock()
+
+	if 0 == cap(usageTrend.samples) {
+		usageTrend.Unlock()
+		return
+	}
+
+	sample = UsageSample{
+		Time:       now,
+		BytesUsed:  usage.bytesUsed,
+		InodesUsed: usage.inodesUsed,
+		Ops:        ops - usageTrend.lastOps,
+	}
+	if 0 < len(usageTrend.samples) {
+		latest := usageTrend.samples[(usageTrend.next+len(usageTrend.samples)-1)%len(usageTrend.samples)]
+		elapsed := now.Sub(latest.Time).Seconds()
+		if 0 < elapsed {
+			sample.OpsPerSec = float64(sample.Ops) / elapsed
+		}
+	}
+	usageTrend.lastOps = ops
+
+	if len(usageTrend.samples) < cap(usageTrend.samples) {
+		usageTrend.samples = append(usageTrend.samples, sample)
+	} else {
+		usageTrend.samples[usageTrend.next] = sample
+		usageTrend.next = (usageTrend.next + 1) % len(usageTrend.samples)
+	}
+
+	oldest := usageTrend.samples[usageTrend.next%len(usageTrend.samples)]
+	elapsed := now.Sub(oldest.Time)
+
+	alert, raise := usageTrend.checkAlert(&usageTrend.bytesAlerting, "bytes", oldest.BytesUsed, sample.BytesUsed, totalBlocks*FsBlockSize, elapsed)
+	if raise {
+		alerts = append(alerts, alert)
+	}
+	alert, raise = usageTrend.checkAlert(&usageTrend.inodesAlerting, "inodes", oldest.InodesUsed, sample.InodesUsed, totalInodes, elapsed)
+	if raise {
+		alerts = append(alerts, alert)
+	}
+
+	alertWebhook := usageTrend.alertWebhook
+
+	usageTrend.Unlock()
+
+	stats.IncrementOperations(&stats.FsUsageSampleOps)
+
+	for _, alert = range alerts {
+		alert.VolumeName = vS.volumeName
+		vS.raiseUsageAlert(alert, alertWebhook)
+	}
+}
+
+// checkAlert determines if growth of a resource from oldUsed to used over elapsed warrants a UsageAlert. Should
+// it (and *alerting not already be set), *alerting is set and raise is returned true. Caller must hold the
+// usageTrend lock.
+func (usageTrend *usageTrendStruct) checkAlert(alerting *bool, resource string, oldUsed uint64, used uint64, total uint64, elapsed time.Duration) (alert UsageAlert, raise bool) {
+	if 0 == total {
+		return
+	}
+
+	alert = UsageAlert{
+		Resource: resource,
+		Used:     used,
+		Total:    total,
+		Percent:  100.0 * float64(used) / float64(total),
+	}
+	if (used > oldUsed) && (used < total) && (0 < elapsed) {
+		alert.TimeToFull = time.Duration(float64(total-used) * float64(elapsed) / float64(used-oldUsed))
+	}
+
+	warranted := ((0 != usageTrend.alertPercent) && (alert.Percent >= float64(usageTrend.alertPercent))) ||
+		((0 != usageTrend.alertHorizon) && (0 != alert.TimeToFull) && (alert.TimeToFull <= usageTrend.alertHorizon))
+
+	raise = warranted && !*alerting
+	*alerting = warranted
+	return
+}
+
+func (vS *volumeStruct) raiseUsageAlert(alert UsageAlert, alertWebhook string) {
+	logger.Warnf("fs: volume '%s' has used %v of %v %s (%.1f%%; full in %v at the recent rate of growth)",
+		alert.VolumeName, alert.Used, alert.Total, alert.Resource, alert.Percent, alert.TimeToFull)
+
+	stats.IncrementOperations(&stats.FsUsageAlertOps)
+
+	globals.Lock()
+	handlers := append([]UsageAlertHandler(nil), globals.usageAlertHandlers...)
+	globals.Unlock()
+
+	for _, handler := range handlers {
+		handler(alert)
+	}
+
+	if "" != alertWebhook {
+		go postUsageAlert(alertWebhook, alert)
+	}
+}
+
+func postUsageAlert(alertWebhook string, alert UsageAlert) {
+	buf, err := json.Marshal(alert)
+	if nil != err {
+		logger.ErrorfWithError(err, "fs: unable to encode UsageAlert of volume '%s'", alert.VolumeName)
+		return
+	}
+
+	client := &http.Client{Timeout: usageAlertWebhookTimeout}
+
+	response, err := client.Post(alertWebhook, "application/json", bytes.NewReader(buf))
+	if nil != err {
+		logger.WarnfWithError(err, "fs: unable to POST UsageAlert of volume '%s' to %s", alert.VolumeName, alertWebhook)
+		return
+	}
+	_ = response.Body.Close()
+
+	if (http.StatusOK > response.StatusCode) || (http.StatusMultipleChoices <= response.StatusCode) {
+		logger.Warnf("fs: POST of UsageAlert of volume '%s' to %s returned %s", alert.VolumeName, alertWebhook, response.Status)
+	}
+}
+
+func registerUsageAlertHandler(handler UsageAlertHandler) {
+	globals.Lock()
+	globals.usageAlertHandlers = append(globals.usageAlertHandlers, handler)
+	globals.Unlock()
+}
+
+func fetchUsageTrend(volumeName string) (samples []UsageSample, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	vS.usageTrend.Lock()
+	samples = vS.usageTrend.orderedSamples()
+	vS.usageTrend.Unlock()
+
+	return
+}
@@ -0,0 +1,58 @@
+package fs
+
+// Umask
+//
+// Create(), CreateUnlinked(), Mkdir(), and Mknod() clear from the mode they are passed the bits set in
+// the mount's umask (as set via SetUmask(); initially 0, i.e. modes are applied as given). Callers able
+// to apply their own umask (e.g. FUSE, where the kernel has already done so) simply leave it at 0, while
+// those that cannot (e.g. some SMB paths) set it once per mount (see jrpcfs.MountRequest.Umask). As on
+// local filesystems, Symlink() ignores the umask (symlinks are always rwxrwxrwx) as does Setstat().
+//
+// Files and directories created via the Swift middleware (which has no notion of a mode) are created
+// rwxrwxrwx less [<volume-section>]MiddlewareUmask (given in octal, defaulting to 0000).
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+// fetchMiddlewareUmask returns [volumeSectionName]MiddlewareUmask.
+func fetchMiddlewareUmask(confMap conf.ConfMap, volumeSectionName string) (middlewareUmask inode.InodeMode, err error) {
+	middlewareUmaskString, err := confMap.FetchOptionValueString(volumeSectionName, "MiddlewareUmask")
+	if nil != err {
+		middlewareUmask = 0
+		err = nil
+		return
+	}
+
+	middlewareUmaskUint64, err := strconv.ParseUint(middlewareUmaskString, 8, 32)
+	if (nil != err) || (0 != inode.InodeMode(middlewareUmaskUint64)&^inode.PosixModePerm) {
+		err = fmt.Errorf("%s.MiddlewareUmask must be an octal mode no greater than 0777", volumeSectionName)
+		return
+	}
+
+	middlewareUmask = inode.InodeMode(middlewareUmaskUint64)
+	return
+}
+
+func (mS *mountStruct) SetUmask(umask inode.InodeMode) (previousUmask inode.InodeMode) {
+	previousUmask = inode.InodeMode(atomic.SwapUint32(&mS.umask, uint32(umask&inode.PosixModePerm)))
+	return
+}
+
+// createMode returns the mode an inode Create()'d, Mkdir()'d, etc. with filePerm via the mount is given.
+func (mS *mountStruct) createMode(filePerm inode.InodeMode) inode.InodeMode {
+	return mS.allowedMode(filePerm &^ inode.InodeMode(atomic.LoadUint32(&mS.umask)))
+}
+
+// middlewareMode returns the mode of a file or directory created via the Swift middleware.
+func (vS *volumeStruct) middlewareMode() (filePerm inode.InodeMode) {
+	vS.Lock()
+	filePerm = inode.PosixModePerm &^ vS.middlewareUmask
+	vS.Unlock()
+	return
+}
@@ -0,0 +1,201 @@
+package fs
+
+// Unmount
+//
+// Each exported mountStruct method is bracketed by enterOp() and exitOp(), which count the operations
+// in flight via the mount. Unmount() first drains them: it waits until none remain in flight, failing
+// operations begun while the drain is underway with TryAgainError (EAGAIN). An operation performing
+// another as part of itself (e.g. ReadByHandle() performing Read()) does so via the unexported method
+// doing the work of the exported one (e.g. readWithFlockPid()) so as not to be admitted (and, while
+// draining, rejected) a second time. Should any FileHandle opened via the mount remain open
+// once drained, Unmount() fails with DevBusyError (EBUSY) and the mount remains usable. Otherwise,
+// operations subsequently attempted via the mount fail with BadMountIDError (EINVAL), and Unmount()
+// releases what the mount still holds:
+//
+//   byte-range locks obtained via Flock() (i.e. for each Pid that obtained one via the mount, all
+//   locks held by that Pid... as they are owned by Pid alone, a Pid should not be shared by mounts)
+//
+//   leases obtained via AcquireLease()
+//
+//   watches added via AddWatch()
+//
+// Once the last mount of a volume is unmounted, the volume's remaining in-memory state is dropped:
+// in-flight file data is flushed, operation history is discarded, and its dlm lock domain is dropped.
+
+import (
+	"sync"
+	"syscall"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type mountStateType uint32
+
+const (
+	mountStateMounted mountStateType = iota
+	mountStateDraining
+	mountStateUnmounted
+	mountStateShutDown // see shutdown.go
+)
+
+type mountGateStruct struct {
+	sync.Mutex
+	cond        *sync.Cond // broadcast whenever inFlight drops to zero
+	state       mountStateType
+	inFlight    uint64
+	flockOwners map[inode.InodeNumber]map[uint64]struct{} // Pids having obtained byte-range locks via Flock()
+}
+
+func (mS *mountStruct) initGate() {
+	mS.gate.cond = sync.NewCond(&mS.gate)
+	mS.gate.state = mountStateMounted
+	mS.gate.flockOwners = make(map[inode.InodeNumber]map[uint64]struct{})
+}
+
+// enterOp admits an operation via mS unless mS is being (or has been) unmounted.
+func (mS *mountStruct) enterOp() (err error) {
+	mS.gate.Lock()
+	if mountStateDraining == mS.gate.state {
+		mS.gate.Unlock()
+		err = blunder.NewError(blunder.TryAgainError, "MountID %v is being unmounted", mS.id)
+		return
+	}
+	if mountStateUnmounted == mS.gate.state {
+		mS.gate.Unlock()
+		err = blunder.NewError(blunder.BadMountIDError, "MountID %v has been unmounted", mS.id)
+		return
+	}
+	if mountStateShutDown == mS.gate.state {
+		mS.gate.Unlock()
+		err = blunder.NewError(blunder.TryAgainError, "MountID %v is shutting down", mS.id)
+		return
+	}
+	mS.gate.inFlight++
+	mS.gate.Unlock()
+	mS.volStruct.noteOp() // see trend.go
+	mS.noteOpBegun()      // see op_stats.go
+	return
+}
+
+// exitOp notes the completion of an operation admitted by enterOp() that is returning *errPtr (errPtr is
+// nil for operations not returning an error).
+func (mS *mountStruct) exitOp(errPtr *error) {
+	if (nil != errPtr) && (nil != *errPtr) {
+		mS.noteOpFailed() // see op_stats.go
+	}
+
+	mS.gate.Lock()
+	mS.gate.inFlight--
+	if 0 == mS.gate.inFlight {
+		mS.gate.cond.Broadcast()
+	}
+	mS.gate.Unlock()
+}
+
+// noteFlockOwner records that pid has obtained a byte-range lock on inodeNumber via mS.
+func (mS *mountStruct) noteFlockOwner(inodeNumber inode.InodeNumber, pid uint64) {
+	if 0 != (pid & uint64(fileHandleBase)) {
+		return // locks obtained via FlockByHandle() are released by Close()
+	}
+
+	mS.gate.Lock()
+	owners, ok := mS.gate.flockOwners[inodeNumber]
+	if !ok {
+		owners = make(map[uint64]struct{})
+		mS.gate.flockOwners[inodeNumber] = owners
+	}
+	owners[pid] = struct{}{}
+	mS.gate.Unlock()
+}
+
+func unmount(mountHandle MountHandle) (err error) {
+	mS, ok := mountHandle.(*mountStruct)
+	if !ok {
+		err = blunder.NewError(blunder.BadMountIDError, "Unmount() passed an unknown MountHandle")
+		return
+	}
+
+	vS := mS.volStruct
+
+	mS.gate.Lock()
+	if mountStateMounted != mS.gate.state {
+		mS.gate.Unlock()
+		err = blunder.NewError(blunder.BadMountIDError, "MountID %v is not mounted", mS.id)
+		return
+	}
+	mS.gate.state = mountStateDraining
+	for 0 != mS.gate.inFlight {
+		mS.gate.cond.Wait()
+	}
+
+	openCount := 0
+	vS.handles.Lock()
+	for _, open := range vS.handles.openMap {
+		if mS.id == open.mountID {
+			openCount++
+		}
+	}
+	vS.handles.Unlock()
+
+	if 0 != openCount {
+		mS.gate.state = mountStateMounted
+		mS.gate.Unlock()
+		stats.IncrementOperations(&stats.FsUnmountBusyOps)
+		err = blunder.NewError(blunder.DevBusyError, "MountID %v has %d FileHandles still open", mS.id, openCount)
+		return
+	}
+
+	mS.gate.state = mountStateUnmounted
+	flockOwners := mS.gate.flockOwners
+	mS.gate.flockOwners = nil
+	mS.gate.Unlock()
+
+	if 0 != len(flockOwners) {
+		for inodeNumber, owners := range flockOwners {
+			for pid := range owners {
+				unlockFlock := &FlockStruct{
+					Type:  syscall.F_UNLCK,
+					Start: 0,
+					Len:   ^uint64(0),
+					Pid:   pid,
+				}
+				_ = mS.fileUnlock(inodeNumber, unlockFlock)
+			}
+		}
+		vS.scheduleVolumeStateExport()
+		vS.noteFlockChange()
+	}
+
+	vS.releaseMountLeases(mS.id)
+	vS.thawMountFreezes(mS.id)
+	vS.removeMountWatches(mS.id)
+
+	globals.Lock()
+	delete(globals.mountMap, mS.id)
+	vS.Lock()
+	for i, id := range vS.mountList {
+		if mS.id == id {
+			vS.mountList = append(vS.mountList[:i], vS.mountList[i+1:]...)
+			break
+		}
+	}
+	lastMount := (0 == len(vS.mountList))
+	vS.Unlock()
+	globals.Unlock()
+
+	if lastMount {
+		vS.untrackInFlightFileInodeDataAll()
+		vS.flushHistory()
+		dropErr := dlm.DropDomain(vS.volumeName)
+		if nil != dropErr {
+			logger.ErrorfWithError(dropErr, "fs.Unmount() unable to drop lock domain of volume '%s'", vS.volumeName)
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsUnmountOps)
+	return
+}
@@ -0,0 +1,126 @@
+package fs
+
+// Volume capacity and usage reporting for StatVfs()
+//
+// Bytes used are taken from the Swift account backing the volume (X-Account-Bytes-Used) and
+// inodes used from the volume's inode records. Capacity comes from [<volume-section>]QuotaBytes
+// & QuotaInodes if configured, else from any Swift account quota (X-Account-Meta-Quota-Bytes),
+// else from the VolFake* constants. Since HEADing the account on every StatVfs() would be costly
+// (df is called often), results are cached for [<volume-section>]UsageCacheTTL.
+//
+// StatVfsAt() reports the capacity available to the subtree containing a particular inode (e.g. the
+// directory a Windows Explorer or df user is looking at). This is that of the most restrictive quota
+// applicable to the subtree. As quotas are presently only imposed on a volume as a whole, that is
+// always the volume's capacity.
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+const defaultUsageCacheTTL = 10 * time.Second
+
+const (
+	swiftAccountBytesUsedHeader  = "X-Account-Bytes-Used"
+	swiftAccountQuotaBytesHeader = "X-Account-Meta-Quota-Bytes"
+)
+
+type volumeUsageStruct struct {
+	fetchTime         time.Time
+	bytesUsed         uint64
+	accountQuotaBytes uint64 // 0 == no Swift account quota set
+	inodesUsed        uint64
+}
+
+// parseUint64Header returns the value of a single-valued numeric header (or 0 if missing or malformed).
+func parseUint64Header(headers map[string][]string, headerName string) (value uint64) {
+	headerValues, ok := headers[headerName]
+	if !ok || (1 != len(headerValues)) {
+		return 0
+	}
+
+	value, err := strconv.ParseUint(headerValues[0], 10, 64)
+	if nil != err {
+		return 0
+	}
+
+	return
+}
+
+// fetchUsage returns the (possibly cached) usage of the volume.
+//
+// Should Swift or headhunter be unreachable, the last known usage (if any) is returned.
+func (vS *volumeStruct) fetchUsage() (usage volumeUsageStruct) {
+	vS.Lock()
+	if (nil != vS.usageCache) && (time.Since(vS.usageCache.fetchTime) < vS.usageCacheTTL) {
+		usage = *vS.usageCache
+		vS.Unlock()
+		return
+	}
+	vS.Unlock()
+
+	newUsage := &volumeUsageStruct{fetchTime: time.Now()}
+
+	headers, err := swiftclient.AccountHead(vS.VolumeHandle.GetAccountName())
+	if nil == err {
+		newUsage.bytesUsed = parseUint64Header(headers, swiftAccountBytesUsedHeader)
+		newUsage.accountQuotaBytes = parseUint64Header(headers, swiftAccountQuotaBytesHeader)
+	}
+	if nil == err {
+		newUsage.inodesUsed, err = vS.VolumeHandle.GetInodeCount()
+	}
+
+	vS.Lock()
+	defer vS.Unlock()
+
+	if nil != err {
+		logger.WarnfWithError(err, "fs: unable to fetch usage of volume '%s'", vS.volumeName)
+		if nil != vS.usageCache {
+			usage = *vS.usageCache
+		}
+		return
+	}
+
+	vS.usageCache = newUsage
+	usage = *newUsage
+	return
+}
+
+// fetchCapacity returns the total, free, and available bytes & inodes of the volume.
+func (vS *volumeStruct) fetchCapacity() (totalBlocks uint64, freeBlocks uint64, availBlocks uint64, totalInodes uint64, freeInodes uint64, availInodes uint64) {
+	usage := vS.fetchUsage()
+
+	if 0 != vS.quotaBytes {
+		totalBlocks = vS.quotaBytes / FsBlockSize
+	} else if 0 != usage.accountQuotaBytes {
+		totalBlocks = usage.accountQuotaBytes / FsBlockSize
+	} else {
+		totalBlocks = VolFakeTotalBlocks
+	}
+
+	usedBlocks := (usage.bytesUsed + FsBlockSize - 1) / FsBlockSize
+	if usedBlocks < totalBlocks {
+		freeBlocks = totalBlocks - usedBlocks
+	} else {
+		freeBlocks = 0
+	}
+	availBlocks = freeBlocks
+
+	if 0 != vS.quotaInodes {
+		totalInodes = vS.quotaInodes
+	} else {
+		totalInodes = VolFakeTotalInodes
+	}
+
+	if usage.inodesUsed < totalInodes {
+		freeInodes = totalInodes - usage.inodesUsed
+	} else {
+		freeInodes = 0
+	}
+	availInodes = freeInodes
+
+	return
+}
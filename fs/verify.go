@@ -0,0 +1,233 @@
+package fs
+
+// Swift verification
+//
+// VerifyVolume() reconciles a volume's files against direct listings of its PhysicalContainers,
+// reporting both Objects not referenced by any file (e.g. left behind by a restore of an older
+// checkpoint, or by a PUT via the middleware that was never completed) and LogSegments referenced
+// by a file that no longer exist (i.e. extents whose data is lost). It is intended for use after a
+// restore or upon suspected corruption and modifies nothing.
+//
+// As the volume remains in service, the PhysicalContainers are listed both before and after the
+// namespace is walked. Only an Object present in both listings is reported as unreferenced, and only a
+// LogSegment absent from both is reported as missing, so that Objects written or deleted during the
+// walk are not mistaken for discrepancies.
+
+import (
+	"sort"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+type verifyObjectKeyStruct struct {
+	containerName string
+	objectName    string
+}
+
+// logSegmentLocations returns the LogSegmentLocations of fileInodeNumber while holding its write lock.
+func (vS *volumeStruct) logSegmentLocations(fileInodeNumber inode.InodeNumber) (locations []inode.LogSegmentLocation, err error) {
+	inodeLock, err := vS.getWriteLock(fileInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	locations, err = vS.VolumeHandle.FetchLogSegmentLocations(fileInodeNumber)
+	return
+}
+
+// listPhysicalContainers lists each of the volume's PhysicalContainers in full, returning the set of
+// Objects found and the number of PhysicalContainers listed.
+func (vS *volumeStruct) listPhysicalContainers() (listed map[verifyObjectKeyStruct]struct{}, containersListed uint64, err error) {
+	accountName := vS.VolumeHandle.GetAccountName()
+
+	containerNames, err := vS.VolumeHandle.FetchPhysicalContainerNames()
+	if nil != err {
+		return
+	}
+
+	listed = make(map[verifyObjectKeyStruct]struct{})
+
+	for _, containerName := range containerNames {
+		marker := ""
+		for {
+			_, objectList, listErr := swiftclient.ContainerGetAfter(accountName, containerName, marker)
+			if nil != listErr {
+				if blunder.Is(listErr, blunder.NotFoundError) {
+					break // deleted since the Account was listed
+				}
+				err = listErr
+				return
+			}
+			if 0 == len(objectList) {
+				containersListed++
+				break
+			}
+			for _, objectName := range objectList {
+				listed[verifyObjectKeyStruct{containerName: containerName, objectName: objectName}] = struct{}{}
+			}
+			marker = objectList[len(objectList)-1]
+		}
+	}
+
+	return
+}
+
+func verifyVolume(volumeName string, stopChan chan bool) (report VerifyReport, err error) {
+	vS, err := lookupVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	report.UnreferencedObjects = make([]VerifyObject, 0)
+	report.MissingObjects = make([]VerifyObject, 0)
+
+	listedBefore, _, err := vS.listPhysicalContainers()
+	if nil != err {
+		return
+	}
+
+	referenced := make(map[verifyObjectKeyStruct]inode.InodeNumber) // value == a file referencing key
+
+	verifyFile := func(fileInodeNumber inode.InodeNumber) (err error) {
+		locations, err := vS.logSegmentLocations(fileInodeNumber)
+		if nil != err {
+			return
+		}
+		report.FilesScanned++
+		for _, location := range locations {
+			report.LogSegmentsScanned++
+			key := verifyObjectKeyStruct{containerName: location.ContainerName, objectName: location.ObjectName}
+			referenced[key] = fileInodeNumber
+		}
+		return
+	}
+
+	report.Stopped, err = vS.walkFiles(stopChan, verifyFile)
+	if (nil != err) || report.Stopped {
+		return
+	}
+
+	// Unnamed inodes (see orphan.go) still reference their LogSegments
+
+	vS.Lock()
+	orphans := make([]inode.InodeNumber, 0, len(vS.orphanMap))
+	for orphanInodeNumber := range vS.orphanMap {
+		orphans = append(orphans, orphanInodeNumber)
+	}
+	vS.Unlock()
+
+	for _, orphanInodeNumber := range orphans {
+		orphanErr := verifyFile(orphanInodeNumber)
+		if (nil != orphanErr) && blunder.IsNot(orphanErr, blunder.NotFoundError) {
+			err = orphanErr
+			return
+		}
+	}
+
+	// As do trashed files (see trash.go)
+
+	trashDirInodeNumber, _ := vS.fetchTrashDir()
+	if 0 != trashDirInodeNumber {
+		trashedInodeNumbers, trashErr := vS.trashedInodeNumbers(trashDirInodeNumber)
+		if nil != trashErr {
+			err = trashErr
+			return
+		}
+		for _, trashedInodeNumber := range trashedInodeNumbers {
+			inodeType, typeErr := vS.VolumeHandle.GetType(trashedInodeNumber)
+			if (nil != typeErr) || (inode.FileType != inodeType) {
+				continue // purged since we found it or not a file
+			}
+			trashErr = verifyFile(trashedInodeNumber)
+			if (nil != trashErr) && blunder.IsNot(trashErr, blunder.NotFoundError) {
+				err = trashErr
+				return
+			}
+		}
+	}
+
+	// As do file versions (see version.go)
+
+	versionsDirInodeNumber := vS.fetchVersionsDir()
+	if 0 != versionsDirInodeNumber {
+		versionsDirInodeLock, versionErr := vS.getReadLock(versionsDirInodeNumber, nil)
+		if nil != versionErr {
+			err = versionErr
+			return
+		}
+		versionEntries, _, versionErr := vS.VolumeHandle.ReadDir(versionsDirInodeNumber, 0, 0)
+		versionsDirInodeLock.Unlock()
+		if nil != versionErr {
+			err = versionErr
+			return
+		}
+		for _, versionEntry := range versionEntries {
+			if _, ok := versionFileInodeNumber(versionEntry.Basename); !ok {
+				continue // i.e. "." or ".."
+			}
+			versionErr = verifyFile(versionEntry.InodeNumber)
+			if (nil != versionErr) && blunder.IsNot(versionErr, blunder.NotFoundError) {
+				err = versionErr
+				return
+			}
+		}
+	}
+
+	listedAfter, containersListed, err := vS.listPhysicalContainers()
+	if nil != err {
+		return
+	}
+
+	report.ContainersListed = containersListed
+	report.ObjectsListed = uint64(len(listedAfter))
+
+	for key := range listedBefore {
+		if _, ok := referenced[key]; ok {
+			continue
+		}
+		if _, ok := listedAfter[key]; ok {
+			report.UnreferencedObjects = append(report.UnreferencedObjects, VerifyObject{
+				ContainerName: key.containerName,
+				ObjectName:    key.objectName,
+			})
+		}
+	}
+
+	for key, fileInodeNumber := range referenced {
+		if _, ok := listedBefore[key]; ok {
+			continue
+		}
+		if _, ok := listedAfter[key]; ok {
+			continue // written during the walk
+		}
+		report.MissingObjects = append(report.MissingObjects, VerifyObject{
+			ContainerName: key.containerName,
+			ObjectName:    key.objectName,
+			InodeNumber:   fileInodeNumber,
+		})
+	}
+
+	sortVerifyObjects(report.UnreferencedObjects)
+	sortVerifyObjects(report.MissingObjects)
+
+	if (0 < len(report.UnreferencedObjects)) || (0 < len(report.MissingObjects)) {
+		logger.Warnf("fs: VerifyVolume() of volume '%s' found %v unreferenced and %v missing Objects", volumeName, len(report.UnreferencedObjects), len(report.MissingObjects))
+	}
+
+	stats.IncrementOperations(&stats.FsVerifyVolumeOps)
+	return
+}
+
+func sortVerifyObjects(verifyObjects []VerifyObject) {
+	sort.Slice(verifyObjects, func(i int, j int) bool {
+		if verifyObjects[i].ContainerName != verifyObjects[j].ContainerName {
+			return verifyObjects[i].ContainerName < verifyObjects[j].ContainerName
+		}
+		return verifyObjects[i].ObjectName < verifyObjects[j].ObjectName
+	})
+}
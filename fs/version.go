@@ -0,0 +1,469 @@
+package fs
+
+// File versions
+//
+// While [<volume-section>]MaxFileVersions is non-zero, truncating a file (via Resize() or Setstat()) or
+// overwriting any of its contents (via Write(), Writev(), or WriteByHandle()) first preserves the file as a
+// version: an inode.CloneFile() sharing, rather than copying, its LogSegments, so that a version consumes
+// space only for data since overwritten. Versions are linked into the volume's versions directory: a directory
+// named by no other (so invisible to clients) whose InodeNumber is recorded in VersionsStream, a reserved
+// stream on the root directory inode. There, each is named by the InodeNumbers of its file and of itself
+// (see versionName()) such that the versions of a file are adjacent and ordered oldest first.
+//
+// Once a file has more than MaxFileVersions versions, the oldest is destroyed. No version is preserved of a
+// file unmodified since its newest version nor within [<volume-section>]FileVersionInterval of that version,
+// so a burst of overwrites preserves the file just as it was before the first of them.
+//
+// ListVersions() enumerates the versions of a file and OpenVersion() opens one for ReadByHandle(). Versions
+// are destroyed along with their file by Unlink() (unless trashed), PurgeTrash(), and DELETE via the Swift
+// middleware. Those of files destroyed otherwise (e.g. replaced by Rename() or PUT) are destroyed as the
+// volume is next brought up. Appending to a file preserves no version.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// VersionsStream is the reserved stream on the root directory inode recording the volume's versions directory.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const VersionsStream = "proxyfs.versions"
+
+const (
+	defaultFileVersionInterval = time.Minute
+
+	versionReadDirBatch = 64 // versions directory entries fetched per ReadDir()
+)
+
+type versionsStruct struct {
+	sync.Mutex
+	maxVersions    uint64            // [<volume-section>]MaxFileVersions (0 == no versions are preserved)
+	interval       time.Duration     // [<volume-section>]FileVersionInterval
+	dirInodeNumber inode.InodeNumber // 0 == volume has no versions directory
+}
+
+func (vS *volumeStruct) configureVersions(maxVersions uint64, interval time.Duration) {
+	vS.versions.Lock()
+	vS.versions.maxVersions = maxVersions
+	vS.versions.interval = interval
+	vS.versions.Unlock()
+}
+
+// versionName returns the name of versionInodeNumber, a version of fileInodeNumber, in the versions directory.
+func versionName(fileInodeNumber inode.InodeNumber, versionInodeNumber inode.InodeNumber) string {
+	return fmt.Sprintf("%016X.%016X", uint64(fileInodeNumber), uint64(versionInodeNumber))
+}
+
+// versionFileInodeNumber returns the InodeNumber of the file of which the version named versionName is a version.
+func versionFileInodeNumber(versionName string) (fileInodeNumber inode.InodeNumber, ok bool) {
+	dotIndex := strings.Index(versionName, ".")
+	if 16 != dotIndex {
+		return
+	}
+
+	fileInodeNumberAsUint64, err := strconv.ParseUint(versionName[:dotIndex], 16, 64)
+	if nil != err {
+		return
+	}
+
+	fileInodeNumber = inode.InodeNumber(fileInodeNumberAsUint64)
+	ok = true
+	return
+}
+
+// loadVersions finds (or, if [<volume-section>]MaxFileVersions is non-zero, creates) the volume's versions
+// directory and then destroys the versions of any file since destroyed.
+//
+// Caller must not hold any inode locks.
+func (vS *volumeStruct) loadVersions() (err error) {
+	vS.versions.Lock()
+	maxVersions := vS.versions.maxVersions
+	vS.versions.Unlock()
+
+	versionsDirInodeNumber, err := vS.loadHiddenDir(VersionsStream, 0 != maxVersions)
+	if nil != err {
+		return
+	}
+
+	vS.versions.Lock()
+	vS.versions.dirInodeNumber = versionsDirInodeNumber
+	vS.versions.Unlock()
+
+	if 0 != versionsDirInodeNumber {
+		err = vS.reapVersions(versionsDirInodeNumber)
+	}
+
+	return
+}
+
+// fetchVersionsDir returns the volume's versions directory (0 if none).
+func (vS *volumeStruct) fetchVersionsDir() (versionsDirInodeNumber inode.InodeNumber) {
+	vS.versions.Lock()
+	versionsDirInodeNumber = vS.versions.dirInodeNumber
+	vS.versions.Unlock()
+	return
+}
+
+// reapVersions destroys the versions of files that no longer exist.
+func (vS *volumeStruct) reapVersions(versionsDirInodeNumber inode.InodeNumber) (err error) {
+	callerID := dlm.GenerateCallerID()
+
+	versionsDirInodeLock, err := vS.getWriteLock(versionsDirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer versionsDirInodeLock.Unlock()
+
+	dirEntries, _, err := vS.VolumeHandle.ReadDir(versionsDirInodeNumber, 0, 0)
+	if nil != err {
+		return
+	}
+
+	fileExists := make(map[inode.InodeNumber]bool)
+
+	for _, dirEntry := range dirEntries {
+		fileInodeNumber, ok := versionFileInodeNumber(dirEntry.Basename)
+		if !ok {
+			continue // i.e. "." or ".."
+		}
+
+		exists, checked := fileExists[fileInodeNumber]
+		if !checked {
+			_, typeErr := vS.VolumeHandle.GetType(fileInodeNumber)
+			exists = (nil == typeErr) || blunder.IsNot(typeErr, blunder.NotFoundError)
+			fileExists[fileInodeNumber] = exists
+		}
+		if exists {
+			continue
+		}
+
+		err = vS.destroyVersion(callerID, versionsDirInodeNumber, dirEntry)
+		if nil != err {
+			return
+		}
+		stats.IncrementOperations(&stats.FsVersionReapOps)
+	}
+
+	return
+}
+
+// fetchVersionEntries returns the entries in the versions directory of the versions of fileInodeNumber, oldest first.
+//
+// Caller must hold a lock on the versions directory.
+func (vS *volumeStruct) fetchVersionEntries(versionsDirInodeNumber inode.InodeNumber, fileInodeNumber inode.InodeNumber) (versionEntries []inode.DirEntry, err error) {
+	prefix := fmt.Sprintf("%016X.", uint64(fileInodeNumber))
+	prevReturned := strings.TrimSuffix(prefix, ".") // sorts just before the first name starting with prefix
+
+	versionEntries = make([]inode.DirEntry, 0)
+
+	for {
+		dirEntries, moreEntries, readDirErr := vS.VolumeHandle.ReadDir(versionsDirInodeNumber, versionReadDirBatch, 0, prevReturned)
+		if nil != readDirErr {
+			if blunder.IsNot(readDirErr, blunder.NotFoundError) {
+				err = readDirErr
+			}
+			return // NotFoundError just means no entries follow prevReturned
+		}
+
+		for _, dirEntry := range dirEntries {
+			if !strings.HasPrefix(dirEntry.Basename, prefix) {
+				return // past the versions of fileInodeNumber
+			}
+			versionEntries = append(versionEntries, dirEntry)
+		}
+
+		if !moreEntries || (0 == len(dirEntries)) {
+			return
+		}
+
+		prevReturned = dirEntries[len(dirEntries)-1].Basename
+	}
+}
+
+// destroyVersion removes versionEntry from the versions directory and destroys the version.
+//
+// Caller must hold (via callerID) the versions directory's write lock.
+func (vS *volumeStruct) destroyVersion(callerID dlm.CallerID, versionsDirInodeNumber inode.InodeNumber, versionEntry inode.DirEntry) (err error) {
+	versionInodeLock, err := vS.getWriteLock(versionEntry.InodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer versionInodeLock.Unlock()
+
+	err = vS.VolumeHandle.Unlink(versionsDirInodeNumber, versionEntry.Basename)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.Destroy(versionEntry.InodeNumber)
+	return
+}
+
+// preserveVersion preserves fileInodeNumber as a version (see above) should it be a file with contents at
+// or beyond offset (from which it is about to be truncated or overwritten).
+//
+// Caller must hold fileInodeNumber's write lock.
+func (vS *volumeStruct) preserveVersion(fileInodeNumber inode.InodeNumber, offset uint64) (err error) {
+	vS.versions.Lock()
+	maxVersions := vS.versions.maxVersions
+	interval := vS.versions.interval
+	versionsDirInodeNumber := vS.versions.dirInodeNumber
+	vS.versions.Unlock()
+
+	if (0 == maxVersions) || (0 == versionsDirInodeNumber) {
+		return
+	}
+
+	metadata, err := vS.VolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		return
+	}
+	if (inode.FileType != metadata.InodeType) || (offset >= metadata.Size) {
+		return
+	}
+
+	callerID := dlm.GenerateCallerID()
+
+	versionsDirInodeLock, err := vS.getWriteLock(versionsDirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	defer versionsDirInodeLock.Unlock()
+
+	versionEntries, err := vS.fetchVersionEntries(versionsDirInodeNumber, fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if 0 < len(versionEntries) {
+		newestMetadata, newestErr := vS.VolumeHandle.GetMetadata(versionEntries[len(versionEntries)-1].InodeNumber)
+		if nil != newestErr {
+			err = newestErr
+			return
+		}
+		if newestMetadata.ModificationTime.Equal(metadata.ModificationTime) && (newestMetadata.Size == metadata.Size) {
+			return // unmodified since the newest version
+		}
+		if (0 != interval) && (time.Since(newestMetadata.CreationTime) < interval) {
+			return
+		}
+	}
+
+	versionInodeNumber, err := vS.VolumeHandle.CloneFile(fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	name := versionName(fileInodeNumber, versionInodeNumber)
+
+	err = vS.VolumeHandle.Link(versionsDirInodeNumber, name, versionInodeNumber)
+	if nil != err {
+		destroyErr := vS.VolumeHandle.Destroy(versionInodeNumber)
+		if nil != destroyErr {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed Link() in fs.preserveVersion", versionInodeNumber)
+		}
+		return
+	}
+
+	versionEntries = append(versionEntries, inode.DirEntry{InodeNumber: versionInodeNumber, Basename: name})
+
+	stats.IncrementOperations(&stats.FsVersionOps)
+
+	for uint64(len(versionEntries)) > maxVersions {
+		destroyErr := vS.destroyVersion(callerID, versionsDirInodeNumber, versionEntries[0])
+		if nil != destroyErr {
+			logger.WarnfWithError(destroyErr, "couldn't destroy version %v of inode %v of volume '%s'", versionEntries[0].InodeNumber, fileInodeNumber, vS.volumeName)
+			break // the file's next version will try again
+		}
+		versionEntries = versionEntries[1:]
+		stats.IncrementOperations(&stats.FsVersionExpireOps)
+	}
+
+	return
+}
+
+// destroyVersions destroys the versions of fileInodeNumber once it has itself been destroyed. Failures are
+// logged (leaving the remaining versions to be reaped as the volume is next brought up) rather than returned.
+//
+// Caller must hold fileInodeNumber's write lock.
+func (vS *volumeStruct) destroyVersions(fileInodeNumber inode.InodeNumber) {
+	versionsDirInodeNumber := vS.fetchVersionsDir()
+	if 0 == versionsDirInodeNumber {
+		return
+	}
+
+	callerID := dlm.GenerateCallerID()
+
+	versionsDirInodeLock, err := vS.getWriteLock(versionsDirInodeNumber, callerID)
+	if nil != err {
+		logger.WarnfWithError(err, "couldn't lock versions directory of volume '%s'", vS.volumeName)
+		return
+	}
+	defer versionsDirInodeLock.Unlock()
+
+	versionEntries, err := vS.fetchVersionEntries(versionsDirInodeNumber, fileInodeNumber)
+	if nil != err {
+		logger.WarnfWithError(err, "couldn't find versions of inode %v of volume '%s'", fileInodeNumber, vS.volumeName)
+		return
+	}
+
+	for _, versionEntry := range versionEntries {
+		err = vS.destroyVersion(callerID, versionsDirInodeNumber, versionEntry)
+		if nil != err {
+			logger.WarnfWithError(err, "couldn't destroy version %v of inode %v of volume '%s'", versionEntry.InodeNumber, fileInodeNumber, vS.volumeName)
+			return
+		}
+	}
+}
+
+// fetchVersions returns the versions of fileInodeNumber, oldest first.
+//
+// Caller must hold a lock on fileInodeNumber.
+func (vS *volumeStruct) fetchVersions(fileInodeNumber inode.InodeNumber) (versions []FileVersion, err error) {
+	versions = make([]FileVersion, 0)
+
+	versionsDirInodeNumber := vS.fetchVersionsDir()
+	if 0 == versionsDirInodeNumber {
+		return
+	}
+
+	versionsDirInodeLock, err := vS.getReadLock(versionsDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer versionsDirInodeLock.Unlock()
+
+	versionEntries, err := vS.fetchVersionEntries(versionsDirInodeNumber, fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	for _, versionEntry := range versionEntries {
+		metadata, metadataErr := vS.VolumeHandle.GetMetadata(versionEntry.InodeNumber)
+		if nil != metadataErr {
+			err = metadataErr
+			return
+		}
+		versions = append(versions, FileVersion{
+			VersionInodeNumber: versionEntry.InodeNumber,
+			VersionTime:        metadata.CreationTime,
+			ModificationTime:   metadata.ModificationTime,
+			Size:               metadata.Size,
+		})
+	}
+
+	return
+}
+
+func (mS *mountStruct) ListVersions(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (versions []FileVersion, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	userID, groupID, otherGroupIDs = mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	err = mS.checkVersionsAccess(userID, groupID, otherGroupIDs, inodeNumber)
+	if nil != err {
+		return
+	}
+
+	versions, err = mS.volStruct.fetchVersions(inodeNumber)
+
+	stats.IncrementOperations(&stats.FsVersionListOps)
+	return
+}
+
+// OpenVersion opens (as if by Open() with OpenRead and all ShareModes) versionInodeNumber, a version of
+// inodeNumber. Reading a version requires read access to both the file and the version (which retains the
+// file's mode and ownership as of its preservation).
+func (mS *mountStruct) OpenVersion(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, versionInodeNumber inode.InodeNumber) (fileHandle FileHandle, err error) {
+	err = mS.enterOp()
+	if nil != err {
+		return
+	}
+	defer mS.exitOp(&err)
+
+	mappedUserID, mappedGroupID, mappedOtherGroupIDs := mS.mapIDs(userID, groupID, otherGroupIDs)
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+
+	err = mS.checkVersionsAccess(mappedUserID, mappedGroupID, mappedOtherGroupIDs, inodeNumber)
+	if nil == err {
+		var versions []FileVersion
+		versions, err = mS.volStruct.fetchVersions(inodeNumber)
+		if nil == err {
+			err = blunder.NewError(blunder.NotFoundError, "inode %v is not a version of inode %v", versionInodeNumber, inodeNumber)
+			for _, version := range versions {
+				if versionInodeNumber == version.VersionInodeNumber {
+					err = nil
+					break
+				}
+			}
+		}
+	}
+
+	inodeLock.Unlock()
+
+	if nil != err {
+		return
+	}
+
+	fileHandle, err = mS.open(userID, groupID, otherGroupIDs, versionInodeNumber, OpenRead, ShareRead|ShareWrite|ShareDelete)
+	if nil != err {
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsVersionOpenOps)
+	return
+}
+
+// checkVersionsAccess verifies that the (already mapped) caller may read inodeNumber.
+//
+// Caller must hold a lock on inodeNumber.
+func (mS *mountStruct) checkVersionsAccess(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error) {
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.R_OK) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	inodeType, err := mS.volStruct.VolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.FileType != inodeType {
+		err = blunder.NewError(blunder.NotFileError, "inode %v is not a file (but a %v) so has no versions", inodeNumber, inodeType)
+	}
+	return
+}
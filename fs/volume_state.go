@@ -0,0 +1,296 @@
+package fs
+
+// Volume state export/import
+//
+// Some fs-level state lives only in memory. So that a crash and restart (or the volume
+// otherwise being taken offline and brought back) does not silently forget it, that state
+// is serialized into a reserved stream on the volume's root directory inode. Since inode
+// records are persisted as part of each headhunter checkpoint, the state rides along with
+// the checkpoint. The state comprises:
+//
+//   Leases (see lease.go), restored held by no mount until reclaimed
+//
+//   The retention clock (see retention.go), so that retention periods do not elapse
+//   while the volume is offline. As it advances continually, it is also exported every
+//   retentionExportInterval while the volume is online, so a crash sets it back by at
+//   most that (plus the time until the export is checkpointed).
+//
+//   Byte-range locks, if [<volume-section>]CheckpointByteRangeLocks is set. Should the
+//   volume have crashed, the processes holding them are likely gone. So, as with leases,
+//   those restored are released unless reclaimed within [<volume-section>]LeaseBreakTimeout.
+//   Each Pid whose restored locks on an inode are not reclaimed by its obtaining (or
+//   releasing) a byte-range lock on that inode via Flock() within that time loses them.
+//
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// VolumeStateStream is the reserved stream on the root directory inode holding the exported volume state.
+//
+// It is not visible via, nor modifiable by, the XAttr APIs.
+const VolumeStateStream = "proxyfs.volumestate"
+
+const volumeStateVersion = uint64(1)
+
+const retentionExportInterval = time.Minute
+
+type volumeStateLeaseStruct struct {
+	LeaseID     LeaseID
+	InodeNumber inode.InodeNumber
+	LeaseType   LeaseType
+}
+
+type volumeStateStruct struct {
+	Version        uint64
+	FLocks         map[inode.InodeNumber][]FlockStruct `json:",omitempty"`
+	Leases         []volumeStateLeaseStruct            `json:",omitempty"`
+	RetentionClock time.Time                           // zero if exported before the retention clock was
+}
+
+// isReservedStream reports whether streamName on inodeNumber is reserved for fs-internal use.
+func isReservedStream(inodeNumber inode.InodeNumber, streamName string) bool {
+	if (MiddlewareStream == streamName) || (AdoptStream == streamName) || (ETagStream == streamName) || (ContentTypeStream == streamName) || (ContainerACLStream == streamName) || (TrashEntryStream == streamName) || (ContainerRetentionStream == streamName) || (RetentionStream == streamName) || (InodeHistoryStream == streamName) || (inode.NFS4ACLStream == streamName) {
+		return true
+	}
+	return (inode.RootDirInodeNumber == inodeNumber) && ((VolumeStateStream == streamName) || (OrphanStream == streamName) || (IntentJournalStream == streamName) || (AccountMetadataStream == streamName) || (TrashStream == streamName) || (VersionsStream == streamName))
+}
+
+// scheduleVolumeStateExport arranges for exportVolumeState() to be called within maxFlushTime.
+//
+// Callers typically hold inode locks, so the export (which needs the root directory inode's
+// write lock) is performed asynchronously. Multiple calls before the export runs are coalesced.
+func (vS *volumeStruct) scheduleVolumeStateExport() {
+	vS.Lock()
+	vS.scheduleVolumeStateExportWhileLocked()
+	vS.Unlock()
+}
+
+// scheduleVolumeStateExportWhileLocked is scheduleVolumeStateExport() for a caller holding vS.Mutex.
+func (vS *volumeStruct) scheduleVolumeStateExportWhileLocked() {
+	if nil != vS.volumeStateExportTimer {
+		return
+	}
+
+	vS.volumeStateExportTimer = time.AfterFunc(vS.maxFlushTime, func() {
+		err := vS.exportVolumeState()
+		if nil != err {
+			logger.ErrorfWithError(err, "fs: unable to export state of volume '%s'", vS.volumeName)
+		}
+	})
+}
+
+// snapshotVolumeState captures the current volume state (but for leases, see snapshotLeases()). Caller
+// must hold vS.Mutex.
+func (vS *volumeStruct) snapshotVolumeState() (volumeState *volumeStateStruct) {
+	volumeState = &volumeStateStruct{
+		Version:        volumeStateVersion,
+		RetentionClock: vS.retentionNow(),
+	}
+
+	if vS.checkpointByteRangeLocks {
+		volumeState.FLocks = make(map[inode.InodeNumber][]FlockStruct)
+		for inodeNumber, flockList := range vS.FLockMap {
+			if 0 == flockList.Len() {
+				continue
+			}
+			flocks := make([]FlockStruct, 0, flockList.Len())
+			for e := flockList.Front(); e != nil; e = e.Next() {
+				flocks = append(flocks, *e.Value.(*FlockStruct))
+			}
+			volumeState.FLocks[inodeNumber] = flocks
+		}
+	}
+
+	return
+}
+
+// exportVolumeState serializes the volume state into VolumeStateStream of the root directory inode.
+func (vS *volumeStruct) exportVolumeState() (err error) {
+	rootInodeLock, err := vS.getWriteLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	vS.Lock()
+	if nil != vS.volumeStateExportTimer {
+		_ = vS.volumeStateExportTimer.Stop()
+		vS.volumeStateExportTimer = nil
+	}
+	volumeState := vS.snapshotVolumeState()
+	vS.Unlock()
+
+	volumeState.Leases = vS.snapshotLeases()
+
+	buf, err := json.Marshal(volumeState)
+	if nil != err {
+		err = blunder.AddError(err, blunder.PackError)
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(inode.RootDirInodeNumber, VolumeStateStream, buf)
+
+	return
+}
+
+// importVolumeState restores the volume state previously saved by exportVolumeState().
+//
+// A missing VolumeStateStream is not an error (e.g. a freshly formatted volume).
+func (vS *volumeStruct) importVolumeState() (err error) {
+	defer func() {
+		if nil == err {
+			vS.startRetentionExport()
+		}
+	}()
+
+	vS.startRetentionClock(time.Time{})
+
+	buf, err := vS.VolumeHandle.GetStream(inode.RootDirInodeNumber, VolumeStateStream)
+	if nil != err {
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	volumeState := &volumeStateStruct{}
+
+	err = json.Unmarshal(buf, volumeState)
+	if nil != err {
+		err = blunder.AddError(fmt.Errorf("fs: corrupt %s stream in volume '%s': %v", VolumeStateStream, vS.volumeName, err), blunder.UnpackError)
+		return
+	}
+	if volumeStateVersion != volumeState.Version {
+		err = blunder.NewError(blunder.UnpackError, "fs: unsupported %s stream version %v in volume '%s'", VolumeStateStream, volumeState.Version, vS.volumeName)
+		return
+	}
+
+	vS.startRetentionClock(volumeState.RetentionClock)
+
+	vS.Lock()
+	if vS.checkpointByteRangeLocks {
+		for inodeNumber, flocks := range volumeState.FLocks {
+			flockList := new(list.List)
+			owners := make(map[uint64]struct{})
+			for i := range flocks {
+				flock := flocks[i]
+				flockList.PushBack(&flock)
+				owners[flock.Pid] = struct{}{}
+			}
+			vS.FLockMap[inodeNumber] = flockList
+			if nil == vS.restoredFlockOwnerMap {
+				vS.restoredFlockOwnerMap = make(map[inode.InodeNumber]map[uint64]struct{})
+			}
+			vS.restoredFlockOwnerMap[inodeNumber] = owners
+		}
+		if (0 != len(vS.restoredFlockOwnerMap)) && (nil == vS.flockReclaimTimer) {
+			vS.flockReclaimTimer = time.AfterFunc(vS.leaseBreakTimeout, vS.releaseUnreclaimedFlocks)
+		}
+	}
+	vS.Unlock()
+
+	vS.restoreLeases(volumeState.Leases)
+
+	return
+}
+
+// reclaimFlocks notes that pid has obtained (or released) a byte-range lock on inodeNumber, reclaiming any
+// restored with the volume state.
+func (vS *volumeStruct) reclaimFlocks(inodeNumber inode.InodeNumber, pid uint64) {
+	vS.Lock()
+	owners, ok := vS.restoredFlockOwnerMap[inodeNumber]
+	if ok {
+		delete(owners, pid)
+		if 0 == len(owners) {
+			delete(vS.restoredFlockOwnerMap, inodeNumber)
+		}
+	}
+	vS.Unlock()
+}
+
+// releaseUnreclaimedFlocks releases each restored byte-range lock not reclaimed via reclaimFlocks().
+func (vS *volumeStruct) releaseUnreclaimedFlocks() {
+	vS.Lock()
+	if nil == vS.flockReclaimTimer {
+		vS.Unlock()
+		return // the volume was taken offline meanwhile
+	}
+	vS.flockReclaimTimer = nil
+	restoredFlockOwnerMap := vS.restoredFlockOwnerMap
+	vS.restoredFlockOwnerMap = nil
+	vS.Unlock()
+
+	released := false
+
+	for inodeNumber, owners := range restoredFlockOwnerMap {
+		inodeLock, err := vS.getWriteLock(inodeNumber, nil)
+		if nil != err {
+			logger.ErrorfWithError(err, "fs: volume '%s' unable to release unreclaimed byte-range locks on inode %v", vS.volumeName, inodeNumber)
+			continue
+		}
+
+		vS.Lock()
+		flockList, ok := vS.FLockMap[inodeNumber]
+		if ok {
+			var next *list.Element
+			for e := flockList.Front(); nil != e; e = next {
+				next = e.Next()
+				flock := e.Value.(*FlockStruct)
+				if _, unreclaimed := owners[flock.Pid]; unreclaimed {
+					logger.Warnf("fs: volume '%s' byte-range lock of Pid %v on inode %v not reclaimed... releasing", vS.volumeName, flock.Pid, inodeNumber)
+					flockList.Remove(e)
+					released = true
+				}
+			}
+		}
+		vS.Unlock()
+
+		inodeLock.Unlock()
+	}
+
+	if released {
+		vS.scheduleVolumeStateExport()
+		vS.noteFlockChange()
+	}
+}
+
+// startRetentionExport starts exporting the volume state every retentionExportInterval.
+func (vS *volumeStruct) startRetentionExport() {
+	vS.Lock()
+	if nil == vS.retentionExportTimer {
+		vS.retentionExportTimer = time.AfterFunc(retentionExportInterval, vS.exportRetentionClock)
+	}
+	vS.Unlock()
+}
+
+func (vS *volumeStruct) exportRetentionClock() {
+	vS.Lock()
+	if nil != vS.retentionExportTimer {
+		vS.retentionExportTimer.Reset(retentionExportInterval)
+		vS.scheduleVolumeStateExportWhileLocked()
+	}
+	vS.Unlock()
+}
+
+// stopVolumeStateTimers is called as a volume is taken offline, stopping the timers started by
+// importVolumeState().
+func (vS *volumeStruct) stopVolumeStateTimers() {
+	vS.Lock()
+	if nil != vS.retentionExportTimer {
+		_ = vS.retentionExportTimer.Stop()
+		vS.retentionExportTimer = nil
+	}
+	if nil != vS.flockReclaimTimer {
+		_ = vS.flockReclaimTimer.Stop()
+		vS.flockReclaimTimer = nil
+	}
+	vS.Unlock()
+}
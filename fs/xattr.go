@@ -0,0 +1,110 @@
+package fs
+
+// Extended attribute namespaces
+//
+// As on Linux, each extended attribute name must begin with one of the "user.", "system.",
+// "security.", or "trusted." namespace prefixes followed by at least one more character. Names in no
+// namespace fail with NotSupportedError (EOPNOTSUPP) and a bare prefix with InvalidArgError (EINVAL).
+// Beyond the R_OK (to get or list) and W_OK (to set or remove) checks applied to all attributes:
+//
+//   user.*     may only be attached to files and directories (as their permission bits govern
+//              access to it)
+//   system.*   may only be set or removed by the inode's owner or a privileged caller
+//   security.* may only be set or removed by a privileged caller
+//   trusted.*  is only visible to (and may only be set or removed by) a privileged caller
+//
+// where a privileged caller is one whose (mapped) userID is inode.InodeRootUserID. Where Linux does,
+// attributes a caller may not see are reported as absent (StreamNotFound, i.e. ENODATA) while those
+// a caller may not modify fail with NotPermError (EPERM).
+//
+// Streams used internally (e.g. MiddlewareStream and, on the root directory, VolumeStateStream and
+// AccountMetadataStream) are reserved (see isReservedStream()) and so are neither visible via, nor
+// modifiable by, the XAttr APIs.
+
+import (
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+const (
+	XAttrUserPrefix     = "user."
+	XAttrSystemPrefix   = "system."
+	XAttrSecurityPrefix = "security."
+	XAttrTrustedPrefix  = "trusted."
+)
+
+// xattrNamespace returns the namespace prefix of streamName, or "" if it has none.
+func xattrNamespace(streamName string) (prefix string) {
+	for _, prefix = range []string{XAttrUserPrefix, XAttrSystemPrefix, XAttrSecurityPrefix, XAttrTrustedPrefix} {
+		if strings.HasPrefix(streamName, prefix) {
+			return
+		}
+	}
+	prefix = ""
+	return
+}
+
+// xattrListable reports whether streamName should be returned to userID by ListXAttr().
+func xattrListable(userID inode.InodeUserID, streamName string) bool {
+	prefix := xattrNamespace(streamName)
+	return ("" != prefix) && ((XAttrTrustedPrefix != prefix) || (inode.InodeRootUserID == userID))
+}
+
+// checkXAttrNamespace enforces the namespace rules above for userID getting (or, if modifying, setting
+// or removing) streamName of inodeNumber. Caller must hold the inode's lock.
+func (mS *mountStruct) checkXAttrNamespace(userID inode.InodeUserID, inodeNumber inode.InodeNumber, streamName string, modifying bool) (err error) {
+	prefix := xattrNamespace(streamName)
+	if "" == prefix {
+		err = blunder.NewError(blunder.NotSupportedError, "XAttr name \"%s\" is not in the user, system, security, or trusted namespace", streamName)
+		return
+	}
+	if len(prefix) == len(streamName) {
+		err = blunder.NewError(blunder.InvalidArgError, "XAttr name \"%s\" is empty following its namespace", streamName)
+		return
+	}
+
+	privileged := (inode.InodeRootUserID == userID)
+
+	switch prefix {
+	case XAttrUserPrefix:
+		inodeType, getTypeErr := mS.volStruct.VolumeHandle.GetType(inodeNumber)
+		if nil != getTypeErr {
+			err = getTypeErr
+			return
+		}
+		if (inode.FileType != inodeType) && (inode.DirType != inodeType) {
+			if modifying {
+				err = blunder.NewError(blunder.NotPermError, "EPERM")
+			} else {
+				err = blunder.NewError(blunder.StreamNotFound, "ENODATA")
+			}
+		}
+	case XAttrSystemPrefix:
+		if modifying && !privileged {
+			metadata, getMetadataErr := mS.volStruct.VolumeHandle.GetMetadata(inodeNumber)
+			if nil != getMetadataErr {
+				err = getMetadataErr
+				return
+			}
+			if userID != metadata.UserID {
+				err = blunder.NewError(blunder.NotPermError, "EPERM")
+			}
+		}
+	case XAttrSecurityPrefix:
+		if modifying && !privileged {
+			err = blunder.NewError(blunder.NotPermError, "EPERM")
+		}
+	case XAttrTrustedPrefix:
+		if !privileged {
+			if modifying {
+				err = blunder.NewError(blunder.NotPermError, "EPERM")
+			} else {
+				err = blunder.NewError(blunder.StreamNotFound, "ENODATA")
+			}
+		}
+	}
+
+	return
+}
@@ -26,7 +26,10 @@ const (
 type mountPointStruct struct {
 	mountPointName string
 	volumeName     string
+	mountOptions   fs.MountOptions // [<volume-section>]FUSEATimePolicy & FUSEReadOnly
 	mounted        bool
+	mountHandle    fs.MountHandle // non-nil while watchID is registered (see notify.go)
+	watchID        fs.WatchID
 }
 
 type globalsStruct struct {
@@ -39,6 +42,7 @@ var globals globalsStruct
 func Up(confMap conf.ConfMap) (err error) {
 	var (
 		alreadyInMountPointMap bool
+		mountOptions           fs.MountOptions
 		mountPoint             *mountPointStruct
 		mountPointName         string
 		primaryPeerNameList    []string
@@ -82,7 +86,12 @@ func Up(confMap conf.ConfMap) (err error) {
 					return
 				}
 
-				mountPoint = &mountPointStruct{mountPointName: mountPointName, volumeName: volumeName, mounted: false}
+				mountOptions, err = fetchMountOptions(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				mountPoint = &mountPointStruct{mountPointName: mountPointName, volumeName: volumeName, mountOptions: mountOptions, mounted: false}
 
 				globals.mountPointMap[mountPointName] = mountPoint
 			}
@@ -160,6 +169,7 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 	}
 
 	for volumeName, mountPoint = range removedVolumeMap {
+		removeInvalidationWatch(mountPoint)
 		if mountPoint.mounted {
 			err = fuselib.Unmount(mountPoint.mountPointName)
 			if nil == err {
@@ -184,6 +194,7 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 
 func ExpandAndResume(confMap conf.ConfMap) (err error) {
 	var (
+		mountOptions        fs.MountOptions
 		mountPoint          *mountPointStruct
 		mountPointName      string
 		ok                  bool
@@ -218,7 +229,12 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 
 				_, ok = globals.mountPointMap[mountPointName]
 				if !ok {
-					mountPoint = &mountPointStruct{mountPointName: mountPointName, volumeName: volumeName, mounted: false}
+					mountOptions, err = fetchMountOptions(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					mountPoint = &mountPointStruct{mountPointName: mountPointName, volumeName: volumeName, mountOptions: mountOptions, mounted: false}
 
 					globals.mountPointMap[mountPointName] = mountPoint
 
@@ -245,6 +261,7 @@ func Down() (err error) {
 	)
 
 	for mountPointName, mountPoint = range globals.mountPointMap {
+		removeInvalidationWatch(mountPoint)
 		if mountPoint.mounted {
 			err = fuselib.Unmount(mountPointName)
 			if nil == err {
@@ -264,6 +281,37 @@ func Down() (err error) {
 	return
 }
 
+// fetchMountOptions maps [<volume-section>]FUSEATimePolicy & FUSEReadOnly to the corresponding fs.MountOptions bits.
+func fetchMountOptions(confMap conf.ConfMap, volumeSectionName string) (mountOptions fs.MountOptions, err error) {
+	aTimePolicy, err := confMap.FetchOptionValueString(volumeSectionName, "FUSEATimePolicy")
+	if nil != err {
+		aTimePolicy = "noatime"
+	}
+
+	switch aTimePolicy {
+	case "noatime":
+		mountOptions = fs.MountNoATime
+	case "relatime":
+		mountOptions = fs.MountRelATime
+	case "strictatime":
+		mountOptions = fs.MountStrictATime
+	default:
+		err = fmt.Errorf("%s.FUSEATimePolicy must be one of \"noatime\", \"relatime\", or \"strictatime\" (not \"%s\")", volumeSectionName, aTimePolicy)
+		return
+	}
+
+	readOnly, err := confMap.FetchOptionValueBool(volumeSectionName, "FUSEReadOnly")
+	if nil != err {
+		readOnly = false
+	}
+	if readOnly {
+		mountOptions |= fs.MountReadOnly
+	}
+
+	err = nil
+	return
+}
+
 func fetchInodeDevice(path string) (missing bool, inodeDevice int64, err error) {
 	fi, err := os.Stat(path)
 	if nil != err {
@@ -349,32 +397,43 @@ func performMount(mountPoint *mountPointStruct) (err error) {
 		}
 	}
 
-	conn, err = fuselib.Mount(
-		mountPoint.mountPointName,
+	fuseMountOptions := []fuselib.MountOption{
 		fuselib.FSName(mountPoint.mountPointName),
 		fuselib.AllowOther(),
 		// OS X specific—
 		fuselib.LocalVolume(),
 		fuselib.VolumeName(mountPoint.mountPointName),
-	)
+	}
+	if 0 != mountPoint.mountOptions&fs.MountReadOnly {
+		fuseMountOptions = append(fuseMountOptions, fuselib.ReadOnly())
+	}
+
+	conn, err = fuselib.Mount(mountPoint.mountPointName, fuseMountOptions...)
 	if nil != err {
 		logger.WarnfWithError(err, "Couldn't mount %s.FUSEMountPoint == %s", mountPoint.volumeName, mountPoint.mountPointName)
 		err = nil
 		return
 	}
 
-	mountHandle, err = fs.Mount(mountPoint.volumeName, fs.MountOptions(0))
+	mountHandle, err = fs.Mount(mountPoint.volumeName, mountPoint.mountOptions)
 	if nil != err {
 		return
 	}
 
 	fs := &ProxyFUSE{mountHandle: mountHandle}
 
+	server := fusefslib.New(conn, nil)
+
 	go func(mountPointName string, conn *fuselib.Conn) {
 		defer conn.Close()
-		fusefslib.Serve(conn, fs)
+		server.Serve(fs)
 	}(mountPoint.mountPointName, conn)
 
+	err = addInvalidationWatch(mountPoint, mountHandle, server)
+	if nil != err {
+		logger.WarnfWithError(err, "Unable to watch %s.FUSEMountPoint == %s for changes", mountPoint.volumeName, mountPoint.mountPointName)
+	}
+
 	// Finally, await mount point becoming available
 
 	missing, mountPointDevice, err = fetchInodeDevice(mountPoint.mountPointName)
@@ -146,6 +146,8 @@ func (d Dir) Lookup(ctx context.Context, name string) (fusefslib.Node, error) {
 	if err != nil {
 		err = newFuseError(err)
 		return nil, err
+	} else if (inode.FIFOType == actualType) || (inode.SocketType == actualType) {
+		return Special{mountHandle: d.mountHandle, inodeNumber: childInodeNumber}, nil
 	} else {
 		err = fmt.Errorf("Unrecognized inode type %v", actualType)
 		err = blunder.AddError(err, blunder.InvalidInodeTypeError)
@@ -162,6 +164,10 @@ func inodeTypeToDirentType(inodeType inode.InodeType) fuselib.DirentType {
 		return fuselib.DT_Dir
 	case inode.SymlinkType:
 		return fuselib.DT_Link
+	case inode.FIFOType:
+		return fuselib.DT_FIFO
+	case inode.SocketType:
+		return fuselib.DT_Socket
 	default:
 		return fuselib.DT_Unknown
 	}
@@ -214,9 +220,27 @@ func (d Dir) Remove(ctx context.Context, req *fuselib.RemoveRequest) (err error)
 }
 
 func (d Dir) Mknod(ctx context.Context, req *fuselib.MknodRequest) (fusefslib.Node, error) {
+	// FIFOs and Unix sockets are created via fs.Mknod()
+	var specialTypeMode inode.InodeMode
+	switch req.Mode & os.ModeType {
+	case os.ModeNamedPipe:
+		specialTypeMode = inode.PosixModeFIFO
+	case os.ModeSocket:
+		specialTypeMode = inode.PosixModeSocket
+	}
+	if 0 != specialTypeMode {
+		inodeNumber, err := d.mountHandle.Mknod(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, d.inodeNumber, req.Name, specialTypeMode|(inode.InodeMode(req.Mode)&inode.PosixModePerm))
+		if err != nil {
+			err = newFuseError(err)
+			return nil, err
+		}
+		special := Special{mountHandle: d.mountHandle, inodeNumber: inodeNumber}
+		return special, nil
+	}
+
 	// Note: NFSd apparently prefers to use Mknod() instead of Create() when creating normal files...
 	if 0 != (inode.InodeMode(req.Mode) & ^inode.PosixModePerm) {
-		err := fmt.Errorf("Invalid Mode... only normal file creations supported")
+		err := fmt.Errorf("Invalid Mode... only normal file, FIFO, and socket creations supported")
 		err = blunder.AddError(err, blunder.InvalidInodeTypeError)
 		err = newFuseError(err)
 		return nil, err
@@ -255,7 +279,11 @@ func (d Dir) Flush(ctx context.Context, req *fuselib.FlushRequest) error {
 }
 
 func (d Dir) Fsync(ctx context.Context, req *fuselib.FsyncRequest) error {
-	return fuselib.ENOSYS
+	err := d.mountHandle.FlushDir(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, d.inodeNumber)
+	if nil != err {
+		err = newFuseError(err)
+	}
+	return err
 }
 
 func (d Dir) Mkdir(ctx context.Context, req *fuselib.MkdirRequest) (fusefslib.Node, error) {
@@ -273,7 +301,9 @@ func (d Dir) Rename(ctx context.Context, req *fuselib.RenameRequest, newDir fuse
 	if !ok {
 		return fuselib.EIO
 	}
-	err := d.mountHandle.Rename(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, d.inodeNumber, req.OldName, dstDir.inodeNumber, req.NewName)
+	// The FUSE protocol version negotiated by bazil.org/fuse (7.12) predates FUSE_RENAME2 (7.23), so the kernel
+	// itself rejects renameat2() flags on this mount... hence only a plain rename is ever requested here.
+	err := d.mountHandle.Rename(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, d.inodeNumber, req.OldName, dstDir.inodeNumber, req.NewName, 0)
 	if err != nil {
 		err = newFuseError(err)
 	}
@@ -46,7 +46,7 @@ func (f File) Attr(ctx context.Context, attr *fuselib.Attr) (err error) {
 
 	attr.Inode = uint64(f.inodeNumber) // or stat[fs.StatINum]
 	attr.Size = stat[fs.StatSize]
-	attr.Blocks = (stat[fs.StatSize] + 511) / 512
+	attr.Blocks = stat[fs.StatBlocks]
 	attr.Atime = time.Unix(0, int64(stat[fs.StatATime]))
 	attr.Mtime = time.Unix(0, int64(stat[fs.StatMTime]))
 	attr.Ctime = time.Unix(0, int64(stat[fs.StatCTime]))
@@ -55,7 +55,7 @@ func (f File) Attr(ctx context.Context, attr *fuselib.Attr) (err error) {
 	attr.Nlink = uint32(stat[fs.StatNLink])
 	attr.Uid = uint32(stat[fs.StatUserID])
 	attr.Gid = uint32(stat[fs.StatGroupID])
-	attr.BlockSize = 4096 // Just a guess at a reasonable block size
+	attr.BlockSize = uint32(stat[fs.StatBlkSize])
 
 	return
 }
@@ -135,12 +135,13 @@ func (f File) Fsync(ctx context.Context, req *fuselib.FsyncRequest) error {
 }
 
 func (f File) Read(ctx context.Context, req *fuselib.ReadRequest, resp *fuselib.ReadResponse) (err error) {
-	buf, err := f.mountHandle.Read(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, f.inodeNumber, uint64(req.Offset), uint64(req.Size), nil)
+	// resp.Data arrives empty but with capacity req.Size, so read directly into it
+	size, err := f.mountHandle.ReadInto(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, f.inodeNumber, uint64(req.Offset), resp.Data[:req.Size], nil)
 	if err != nil && err != io.EOF {
 		err = newFuseError(err)
 		return err
 	}
-	resp.Data = buf
+	resp.Data = resp.Data[:size]
 	return nil
 }
 
@@ -0,0 +1,60 @@
+package fuse
+
+// Kernel cache invalidation driven by fs change notification
+//
+// Changes made to a volume other than via this FUSE mount (e.g. via Samba or the Swift API) would
+// otherwise go unnoticed until the kernel's entry and attribute caches time out. A subtree watch on
+// the root directory invalidates the affected kernel cache entries as such changes occur so that
+// subsequent lookups, stat()s, and reads (and hence inotify-based tools rescanning upon them) see
+// the change promptly. Changes made via this FUSE mount are (harmlessly) invalidated as well.
+
+import (
+	fuselib "bazil.org/fuse"
+	fusefslib "bazil.org/fuse/fs"
+
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+func addInvalidationWatch(mountPoint *mountPointStruct, mountHandle fs.MountHandle, server *fusefslib.Server) (err error) {
+	handler := func(watchID fs.WatchID, event fs.NotifyEvent) {
+		var invalidateErr error
+
+		switch event.Type {
+		case fs.NotifyCreate, fs.NotifyUnlink, fs.NotifyRenameFrom, fs.NotifyRenameTo:
+			parentDir := Dir{mountHandle: mountHandle, inodeNumber: event.ParentInodeNumber}
+			invalidateErr = server.InvalidateEntry(parentDir, event.Basename)
+			if (nil == invalidateErr) || (fuselib.ErrNotCached == invalidateErr) {
+				invalidateErr = server.InvalidateNodeData(parentDir)
+			}
+		case fs.NotifyWrite:
+			invalidateErr = server.InvalidateNodeData(File{mountHandle: mountHandle, inodeNumber: event.InodeNumber})
+		case fs.NotifySetAttr:
+			// Only the Node (if any) the kernel is caching for InodeNumber will be found
+			_ = server.InvalidateNodeAttr(Dir{mountHandle: mountHandle, inodeNumber: event.InodeNumber})
+			_ = server.InvalidateNodeAttr(File{mountHandle: mountHandle, inodeNumber: event.InodeNumber})
+			_ = server.InvalidateNodeAttr(Symlink{mountHandle: mountHandle, inodeNumber: event.InodeNumber})
+		default: // fs.NotifyOverflow... nothing practical to be done
+		}
+
+		if (nil != invalidateErr) && (fuselib.ErrNotCached != invalidateErr) {
+			logger.InfofWithError(invalidateErr, "Unable to invalidate kernel cache for %s event %+v", mountPoint.mountPointName, event)
+		}
+	}
+
+	mountPoint.mountHandle = mountHandle
+	mountPoint.watchID, err = mountHandle.AddWatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.RootDirInodeNumber, true, handler)
+
+	return
+}
+
+func removeInvalidationWatch(mountPoint *mountPointStruct) {
+	if nil != mountPoint.mountHandle {
+		err := mountPoint.mountHandle.RemoveWatch(mountPoint.watchID)
+		if nil != err {
+			logger.InfofWithError(err, "Unable to remove invalidation watch for %s", mountPoint.mountPointName)
+		}
+		mountPoint.mountHandle = nil
+	}
+}
@@ -0,0 +1,112 @@
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	fuselib "bazil.org/fuse"
+	"golang.org/x/net/context"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+// Special is a FIFO or Unix socket. Its data never passes through ProxyFS.
+type Special struct {
+	mountHandle fs.MountHandle
+	inodeNumber inode.InodeNumber
+}
+
+func (s Special) Attr(ctx context.Context, attr *fuselib.Attr) (err error) {
+	var (
+		stat fs.Stat
+	)
+
+	stat, err = s.mountHandle.Getstat(inode.InodeRootUserID, inode.InodeRootGroupID, nil, s.inodeNumber)
+	if nil != err {
+		err = newFuseError(err)
+		return
+	}
+	if (uint64(inode.FIFOType) != stat[fs.StatFType]) && (uint64(inode.SocketType) != stat[fs.StatFType]) {
+		err = fmt.Errorf("[fuse]Special.Attr() called on non-Special")
+		err = blunder.AddError(err, blunder.InvalidInodeTypeError)
+		err = newFuseError(err)
+		return
+	}
+
+	attr.Inode = uint64(s.inodeNumber) // or stat[fs.StatINum]
+	attr.Size = stat[fs.StatSize]
+	attr.Atime = time.Unix(0, int64(stat[fs.StatATime]))
+	attr.Mtime = time.Unix(0, int64(stat[fs.StatMTime]))
+	attr.Ctime = time.Unix(0, int64(stat[fs.StatCTime]))
+	attr.Crtime = time.Unix(0, int64(stat[fs.StatCRTime]))
+	if uint64(inode.FIFOType) == stat[fs.StatFType] {
+		attr.Mode = os.ModeNamedPipe | os.FileMode(stat[fs.StatMode]&0777)
+	} else {
+		attr.Mode = os.ModeSocket | os.FileMode(stat[fs.StatMode]&0777)
+	}
+	attr.Nlink = uint32(stat[fs.StatNLink])
+	attr.Uid = uint32(stat[fs.StatUserID])
+	attr.Gid = uint32(stat[fs.StatGroupID])
+
+	return
+}
+
+func (s Special) Setattr(ctx context.Context, req *fuselib.SetattrRequest, resp *fuselib.SetattrResponse) (err error) {
+	var (
+		stat        fs.Stat
+		statUpdates fs.Stat
+	)
+
+	stat, err = s.mountHandle.Getstat(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, s.inodeNumber)
+	if nil != err {
+		err = newFuseError(err)
+		return
+	}
+	if (uint64(inode.FIFOType) != stat[fs.StatFType]) && (uint64(inode.SocketType) != stat[fs.StatFType]) {
+		err = fmt.Errorf("[fuse]Special.Setattr() called on non-Special")
+		err = blunder.AddError(err, blunder.InvalidInodeTypeError)
+		err = newFuseError(err)
+		return
+	}
+
+	statUpdates = make(fs.Stat)
+
+	if 0 != (fuselib.SetattrMode & req.Valid) {
+		statUpdates[fs.StatMode] = uint64(req.Mode & 0777)
+	}
+	if 0 != (fuselib.SetattrUid & req.Valid) {
+		statUpdates[fs.StatUserID] = uint64(req.Uid)
+	}
+	if 0 != (fuselib.SetattrGid & req.Valid) {
+		statUpdates[fs.StatGroupID] = uint64(req.Gid)
+	}
+	if 0 != (fuselib.SetattrAtime & req.Valid) {
+		statUpdates[fs.StatATime] = uint64(req.Atime.UnixNano())
+	}
+	if 0 != (fuselib.SetattrMtime & req.Valid) {
+		statUpdates[fs.StatMTime] = uint64(req.Mtime.UnixNano())
+	}
+	if 0 != (fuselib.SetattrAtimeNow & req.Valid) {
+		statUpdates[fs.StatATime] = uint64(time.Now().UnixNano())
+	}
+	if 0 != (fuselib.SetattrMtimeNow & req.Valid) {
+		statUpdates[fs.StatMTime] = uint64(time.Now().UnixNano())
+	}
+	if 0 != (fuselib.SetattrCrtime & req.Valid) {
+		statUpdates[fs.StatCRTime] = uint64(req.Crtime.UnixNano())
+	}
+
+	err = s.mountHandle.Setstat(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, s.inodeNumber, statUpdates)
+	if nil != err {
+		err = newFuseError(err)
+	}
+
+	return
+}
+
+func (s Special) Fsync(ctx context.Context, req *fuselib.FsyncRequest) error {
+	return fuselib.ENOSYS
+}
@@ -0,0 +1,103 @@
+package fuse
+
+import (
+	"strings"
+
+	fuselib "bazil.org/fuse"
+	"golang.org/x/net/context"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+// inodeFlagsXattrName is the extended attribute through which an inode's immutable & append-only flags are
+// fetched & set. The kernel restricts the trusted namespace to privileged (CAP_SYS_ADMIN) callers. The value
+// lists the flags set using the letters of chattr(1) (e.g. "i", "a", or "ia").
+const inodeFlagsXattrName = "trusted.proxyfs.flags"
+
+var inodeFlagLetters = []struct {
+	flag   inode.InodeFlags
+	letter byte
+}{
+	{inode.ImmutableFlag, 'i'},
+	{inode.AppendOnlyFlag, 'a'},
+}
+
+func formatInodeFlags(flags inode.InodeFlags) string {
+	var letters []byte
+
+	for _, inodeFlagLetter := range inodeFlagLetters {
+		if 0 != (flags & inodeFlagLetter.flag) {
+			letters = append(letters, inodeFlagLetter.letter)
+		}
+	}
+
+	return string(letters)
+}
+
+func parseInodeFlags(value string) (flags inode.InodeFlags, err error) {
+	for _, letter := range []byte(strings.TrimSpace(value)) {
+		found := false
+		for _, inodeFlagLetter := range inodeFlagLetters {
+			if letter == inodeFlagLetter.letter {
+				flags |= inodeFlagLetter.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = blunder.NewError(blunder.InvalidArgError, "unknown inode flag '%c'", letter)
+			return
+		}
+	}
+	return
+}
+
+func getxattr(mountHandle fs.MountHandle, inodeNumber inode.InodeNumber, req *fuselib.GetxattrRequest, resp *fuselib.GetxattrResponse) error {
+	if inodeFlagsXattrName != req.Name {
+		return fuselib.ErrNoXattr
+	}
+
+	flags, err := mountHandle.GetInodeFlags(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, inodeNumber)
+	if nil != err {
+		return newFuseError(err)
+	}
+
+	resp.Xattr = []byte(formatInodeFlags(flags))
+	return nil
+}
+
+func setxattr(mountHandle fs.MountHandle, inodeNumber inode.InodeNumber, req *fuselib.SetxattrRequest) error {
+	if inodeFlagsXattrName != req.Name {
+		return fuselib.ENOTSUP
+	}
+
+	flags, err := parseInodeFlags(string(req.Xattr))
+	if nil != err {
+		return newFuseError(err)
+	}
+
+	err = mountHandle.SetInodeFlags(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, inodeNumber, flags)
+	if nil != err {
+		return newFuseError(err)
+	}
+
+	return nil
+}
+
+func (d Dir) Getxattr(ctx context.Context, req *fuselib.GetxattrRequest, resp *fuselib.GetxattrResponse) error {
+	return getxattr(d.mountHandle, d.inodeNumber, req, resp)
+}
+
+func (d Dir) Setxattr(ctx context.Context, req *fuselib.SetxattrRequest) error {
+	return setxattr(d.mountHandle, d.inodeNumber, req)
+}
+
+func (f File) Getxattr(ctx context.Context, req *fuselib.GetxattrRequest, resp *fuselib.GetxattrResponse) error {
+	return getxattr(f.mountHandle, f.inodeNumber, req, resp)
+}
+
+func (f File) Setxattr(ctx context.Context, req *fuselib.SetxattrRequest) error {
+	return setxattr(f.mountHandle, f.inodeNumber, req)
+}
@@ -10,6 +10,7 @@ import (
 type VolumeHandle interface {
 	FetchNextCheckPointDoneWaitGroup() (wg *sync.WaitGroup)
 	FetchNonce() (nonce uint64, err error)
+	FetchInodeRecCount() (inodeRecCount uint64, err error)
 	GetInodeRec(inodeNumber uint64) (value []byte, ok bool, err error)
 	PutInodeRec(inodeNumber uint64, value []byte) (err error)
 	PutInodeRecs(inodeNumbers []uint64, values [][]byte) (err error)
@@ -21,6 +22,14 @@ type VolumeHandle interface {
 	PutBPlusTreeObject(objectNumber uint64, value []byte) (err error)
 	DeleteBPlusTreeObject(objectNumber uint64) (err error)
 	DoCheckpoint() (err error)
+
+	// Snapshot methods, implemented in snapshot.go
+
+	SnapshotCreate(name string) (id uint64, err error)
+	SnapshotDelete(id uint64) (err error)
+	SnapshotList() (list []SnapshotStruct)
+	SnapshotRetainsLogSegment(logSegmentNumber uint64, containerName string) (retained bool)
+	FetchSnapshotVolumeHandle(id uint64) (snapshotVolumeHandle VolumeHandle, err error)
 }
 
 // FetchVolumeHandle is used to fetch a VolumeHandle to use when operating on a given volume's database
@@ -457,8 +457,9 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 
 	volume.nextNonce = volume.checkpointHeader.ReservedToNonce
 
-	err = nil
-	return
+	err = volume.getSnapshots(checkpointContainerHeaders)
+
+	return // err set as appropriate
 }
 
 func (volume *volumeStruct) putCheckpoint() (err error) {
@@ -646,6 +647,10 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 			delete(volume.logSegmentRecBPlusTreeLayout, objectNumber)
 			delete(volume.bPlusTreeObjectBPlusTreeLayout, objectNumber)
 
+			if volume.snapshotRetainsCheckpointObjectWhileLocked(objectNumber) {
+				continue // SnapshotDelete() will delete it once no snapshot references it
+			}
+
 			swiftclient.ObjectDeleteAsync(
 				volume.accountName,
 				volume.checkpointContainerName,
@@ -744,6 +749,10 @@ func (volume *volumeStruct) checkpointDaemon() {
 
 		checkpointRequest.err = volume.putCheckpoint()
 
+		if (nil == checkpointRequest.err) && volume.snapshotsDirty {
+			checkpointRequest.err = volume.putSnapshotsWhileLocked()
+		}
+
 		if nil != checkpointRequest.err {
 			// As part of conducting the checkpoint - and depending upon where the early non-nil
 			// error was reported - it is highly likely that e.g. pages of the B+Trees have been
@@ -1082,6 +1091,18 @@ func (volume *volumeStruct) FetchNonce() (nonce uint64, err error) {
 	return
 }
 
+func (volume *volumeStruct) FetchInodeRecCount() (inodeRecCount uint64, err error) {
+	volume.Lock()
+	numberOfItems, err := volume.inodeRecWrapper.bPlusTree.Len()
+	volume.Unlock()
+	if nil != err {
+		return
+	}
+
+	inodeRecCount = uint64(numberOfItems)
+	return
+}
+
 func (volume *volumeStruct) GetInodeRec(inodeNumber uint64) (value []byte, ok bool, err error) {
 	volume.Lock()
 	valueAsValue, ok, err := volume.inodeRecWrapper.bPlusTree.GetByKey(inodeNumber)
@@ -85,6 +85,80 @@ func putInodeRecsTest(t *testing.T, volume VolumeHandle) {
 	}
 }
 
+func snapshotTest(t *testing.T, volume VolumeHandle) {
+	var key uint64 = 5678
+
+	inodeRecPutGet(t, volume, key, []byte{1, 2, 3})
+
+	id, err := volume.SnapshotCreate("first")
+	if nil != err {
+		t.Fatalf("SnapshotCreate() failed: %v", err)
+	}
+	_, err = volume.SnapshotCreate("first")
+	if nil == err {
+		t.Fatalf("SnapshotCreate() of duplicate name should have failed")
+	}
+
+	list := volume.SnapshotList()
+	if (1 != len(list)) || (id != list[0].ID) || ("first" != list[0].Name) {
+		t.Fatalf("SnapshotList() returned unexpected %+v", list)
+	}
+
+	inodeRecPutGet(t, volume, key, []byte{4, 5, 6})
+	err = volume.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() failed: %v", err)
+	}
+
+	snapshotVolume, err := volume.FetchSnapshotVolumeHandle(id)
+	if nil != err {
+		t.Fatalf("FetchSnapshotVolumeHandle() failed: %v", err)
+	}
+	value, ok, err := snapshotVolume.GetInodeRec(key)
+	if (nil != err) || !ok || !bytes.Equal([]byte{1, 2, 3}, value) {
+		t.Fatalf("snapshot GetInodeRec() returned %v, %v, %v", value, ok, err)
+	}
+	err = snapshotVolume.PutInodeRec(key, []byte{7})
+	if nil == err {
+		t.Fatalf("snapshot PutInodeRec() should have failed")
+	}
+
+	nonce, err := volume.FetchNonce()
+	if nil != err {
+		t.Fatalf("FetchNonce() failed: %v", err)
+	}
+	if !volume.SnapshotRetainsLogSegment(1, "TestContainer") {
+		t.Fatalf("SnapshotRetainsLogSegment() of LogSegment older than snapshot should have returned true")
+	}
+	if volume.SnapshotRetainsLogSegment(nonce, "TestContainer") {
+		t.Fatalf("SnapshotRetainsLogSegment() of LogSegment newer than snapshot should have returned false")
+	}
+
+	err = volume.SnapshotDelete(id)
+	if nil != err {
+		t.Fatalf("SnapshotDelete() failed: %v", err)
+	}
+	err = volume.SnapshotDelete(id)
+	if nil == err {
+		t.Fatalf("SnapshotDelete() of deleted snapshot should have failed")
+	}
+	_, _, err = snapshotVolume.GetInodeRec(key)
+	if nil == err {
+		t.Fatalf("GetInodeRec() of deleted snapshot should have failed")
+	}
+	if 0 != len(volume.SnapshotList()) {
+		t.Fatalf("SnapshotList() after SnapshotDelete() should have been empty")
+	}
+	if volume.SnapshotRetainsLogSegment(1, "TestContainer") {
+		t.Fatalf("SnapshotRetainsLogSegment() without snapshots should have returned false")
+	}
+
+	err = volume.DeleteInodeRec(key)
+	if nil != err {
+		t.Fatalf("Delete of key %d failed: %v", key, err)
+	}
+}
+
 func TestHeadHunterAPI(t *testing.T) {
 	confStrings := []string{
 		"Stats.IPAddr=localhost",
@@ -271,6 +345,8 @@ func TestHeadHunterAPI(t *testing.T) {
 		t.Fatalf("Delete of key %d failed: %v", key, err)
 	}
 
+	snapshotTest(t, volume)
+
 	// Shutdown packages
 
 	err = Down()
@@ -149,6 +149,12 @@ type volumeStruct struct {
 	inodeRecBPlusTreeLayout          sortedmap.LayoutReport
 	logSegmentRecBPlusTreeLayout     sortedmap.LayoutReport
 	bPlusTreeObjectBPlusTreeLayout   sortedmap.LayoutReport
+	snapshotMap                      map[uint64]*snapshotStruct // key == snapshotStruct.ID (see snapshot.go)
+	nextSnapshotID                   uint64
+	snapshotsObjectNumber            uint64              // 0 == no snapshots object yet
+	snapshotsDirty                   bool                // deferred deletes have been recorded since last putSnapshotsWhileLocked()
+	deferredCheckpointObjectDeletes  map[uint64]struct{} // key == objectNumber in checkpointContainerName
+	deferredLogSegmentDeletes        map[uint64]string   // key == logSegmentNumber; value == containerName
 }
 
 type globalsStruct struct {
@@ -0,0 +1,598 @@
+package headhunter
+
+// Snapshots
+//
+// A snapshot preserves the volume's B+Trees as of a checkpoint. SnapshotCreate() first completes a
+// checkpoint and then records the Root Node of each of the three B+Trees, the set of checkpoint objects
+// holding their nodes, and the nextNonce (every LogSegment referenced by the snapshot has a lower
+// logSegmentNumber). While any snapshot remains:
+//
+//   putCheckpoint() will not delete a checkpoint object still holding nodes of a snapshot
+//   SnapshotRetainsLogSegment() tells callers (i.e. package inode) not to delete a LogSegment it references
+//
+// Both are instead recorded as deferred deletes that SnapshotDelete() issues once no remaining snapshot
+// references them. Snapshots (and the deferred deletes) are persisted as JSON in an object of the
+// checkpoint container named by the SnapshotsHeaderName header of that container. Each update is written
+// to a new object before the header is switched to it, so a crash leaves either the prior or the new set.
+//
+// FetchSnapshotVolumeHandle() returns a read-only VolumeHandle presenting the B+Trees of a snapshot.
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// SnapshotsHeaderName is the checkpoint container header holding the objectNumber (in %016X form) of the
+// object listing the volume's snapshots.
+const SnapshotsHeaderName = "X-Container-Meta-Snapshots"
+
+// MaxSnapshotNameLength bounds the length of a snapshot's Name.
+const MaxSnapshotNameLength = 255
+
+// SnapshotStruct describes a snapshot as returned by SnapshotList().
+type SnapshotStruct struct {
+	ID         uint64
+	Name       string
+	CreateTime time.Time
+}
+
+type snapshotStruct struct {
+	SnapshotStruct
+	InodeRecBPlusTreeObjectNumber        uint64
+	InodeRecBPlusTreeObjectOffset        uint64
+	InodeRecBPlusTreeObjectLength        uint64
+	LogSegmentRecBPlusTreeObjectNumber   uint64
+	LogSegmentRecBPlusTreeObjectOffset   uint64
+	LogSegmentRecBPlusTreeObjectLength   uint64
+	BPlusTreeObjectBPlusTreeObjectNumber uint64
+	BPlusTreeObjectBPlusTreeObjectOffset uint64
+	BPlusTreeObjectBPlusTreeObjectLength uint64
+	NextNonce                            uint64
+	CheckpointObjects                    []uint64
+	checkpointObjectSet                  map[uint64]struct{}
+	view                                 *snapshotVolumeStruct // lazily created by FetchSnapshotVolumeHandle()
+}
+
+// snapshotsStruct is the persisted form of a volume's snapshots.
+type snapshotsStruct struct {
+	NextSnapshotID                  uint64
+	Snapshots                       []*snapshotStruct
+	DeferredCheckpointObjectDeletes []uint64
+	DeferredLogSegmentDeletes       map[string]string // key == logSegmentNumber in %016X form; value == containerName
+}
+
+func (snapshot *snapshotStruct) computeCheckpointObjectSet() {
+	snapshot.checkpointObjectSet = make(map[uint64]struct{}, len(snapshot.CheckpointObjects))
+	for _, objectNumber := range snapshot.CheckpointObjects {
+		snapshot.checkpointObjectSet[objectNumber] = struct{}{}
+	}
+}
+
+// getSnapshots loads the snapshots (if any) named by checkpointContainerHeaders. Called (by getCheckpoint())
+// once volume.nextNonce has been set.
+func (volume *volumeStruct) getSnapshots(checkpointContainerHeaders map[string][]string) (err error) {
+	volume.snapshotMap = make(map[uint64]*snapshotStruct)
+	volume.nextSnapshotID = 1
+	volume.snapshotsObjectNumber = 0
+	volume.deferredCheckpointObjectDeletes = make(map[uint64]struct{})
+	volume.deferredLogSegmentDeletes = make(map[uint64]string)
+	volume.snapshotsDirty = false
+
+	snapshotsHeaderValues, ok := checkpointContainerHeaders[SnapshotsHeaderName]
+	if !ok || (1 != len(snapshotsHeaderValues)) || ("" == snapshotsHeaderValues[0]) {
+		return
+	}
+
+	volume.snapshotsObjectNumber, err = strconv.ParseUint(snapshotsHeaderValues[0], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v header %v: %v", volume.accountName, volume.checkpointContainerName, SnapshotsHeaderName, snapshotsHeaderValues[0])
+		return
+	}
+
+	buf, err := swiftclient.ObjectLoad(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(volume.snapshotsObjectNumber))
+	if nil != err {
+		return
+	}
+
+	snapshots := &snapshotsStruct{}
+	err = json.Unmarshal(buf, snapshots)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v snapshots object %016X: %v", volume.accountName, volume.checkpointContainerName, volume.snapshotsObjectNumber, err)
+		return
+	}
+
+	volume.nextSnapshotID = snapshots.NextSnapshotID
+	for _, snapshot := range snapshots.Snapshots {
+		snapshot.computeCheckpointObjectSet()
+		volume.snapshotMap[snapshot.ID] = snapshot
+	}
+	for _, objectNumber := range snapshots.DeferredCheckpointObjectDeletes {
+		volume.deferredCheckpointObjectDeletes[objectNumber] = struct{}{}
+	}
+	for logSegmentNumberAsHexStr, containerName := range snapshots.DeferredLogSegmentDeletes {
+		logSegmentNumber, parseErr := strconv.ParseUint(logSegmentNumberAsHexStr, 16, 64)
+		if nil != parseErr {
+			err = fmt.Errorf("Cannot parse %v/%v snapshots object %016X: bad logSegmentNumber %v", volume.accountName, volume.checkpointContainerName, volume.snapshotsObjectNumber, logSegmentNumberAsHexStr)
+			return
+		}
+		volume.deferredLogSegmentDeletes[logSegmentNumber] = containerName
+	}
+
+	return
+}
+
+// putSnapshotsWhileLocked persists the volume's snapshots to a new object, switches SnapshotsHeaderName to
+// it, and then deletes the prior one.
+func (volume *volumeStruct) putSnapshotsWhileLocked() (err error) {
+	snapshots := &snapshotsStruct{
+		NextSnapshotID:                  volume.nextSnapshotID,
+		Snapshots:                       make([]*snapshotStruct, 0, len(volume.snapshotMap)),
+		DeferredCheckpointObjectDeletes: make([]uint64, 0, len(volume.deferredCheckpointObjectDeletes)),
+		DeferredLogSegmentDeletes:       make(map[string]string, len(volume.deferredLogSegmentDeletes)),
+	}
+	for _, snapshot := range volume.snapshotMap {
+		snapshots.Snapshots = append(snapshots.Snapshots, snapshot)
+	}
+	sort.Slice(snapshots.Snapshots, func(i, j int) bool { return snapshots.Snapshots[i].ID < snapshots.Snapshots[j].ID })
+	for objectNumber := range volume.deferredCheckpointObjectDeletes {
+		snapshots.DeferredCheckpointObjectDeletes = append(snapshots.DeferredCheckpointObjectDeletes, objectNumber)
+	}
+	for logSegmentNumber, containerName := range volume.deferredLogSegmentDeletes {
+		snapshots.DeferredLogSegmentDeletes[utils.Uint64ToHexStr(logSegmentNumber)] = containerName
+	}
+
+	buf, err := json.Marshal(snapshots)
+	if nil != err {
+		return
+	}
+
+	objectNumber, err := volume.fetchNonceWhileLocked()
+	if nil != err {
+		return
+	}
+
+	chunkedPutContext, err := swiftclient.ObjectFetchChunkedPutContext(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(objectNumber))
+	if nil != err {
+		return
+	}
+	err = chunkedPutContext.SendChunk(buf)
+	if nil != err {
+		return
+	}
+	err = chunkedPutContext.Close()
+	if nil != err {
+		return
+	}
+
+	checkpointContainerHeaders := make(map[string][]string)
+	checkpointContainerHeaders[SnapshotsHeaderName] = []string{utils.Uint64ToHexStr(objectNumber)}
+
+	err = swiftclient.ContainerPost(volume.accountName, volume.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	if 0 != volume.snapshotsObjectNumber {
+		swiftclient.ObjectDeleteAsync(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(volume.snapshotsObjectNumber), nil, nil)
+	}
+
+	volume.snapshotsObjectNumber = objectNumber
+	volume.snapshotsDirty = false
+
+	return
+}
+
+// snapshotRetainsCheckpointObjectWhileLocked reports whether a snapshot still references objectNumber
+// (recording a deferred delete if so).
+func (volume *volumeStruct) snapshotRetainsCheckpointObjectWhileLocked(objectNumber uint64) (retained bool) {
+	for _, snapshot := range volume.snapshotMap {
+		_, retained = snapshot.checkpointObjectSet[objectNumber]
+		if retained {
+			volume.deferredCheckpointObjectDeletes[objectNumber] = struct{}{}
+			volume.snapshotsDirty = true
+			return
+		}
+	}
+	return
+}
+
+func (volume *volumeStruct) SnapshotCreate(name string) (id uint64, err error) {
+	if ("" == name) || (MaxSnapshotNameLength < len(name)) {
+		err = blunder.NewError(blunder.InvalidArgError, "snapshot name must be between 1 and %v bytes long", MaxSnapshotNameLength)
+		return
+	}
+
+	// Complete a checkpoint so that the trailer's Root Nodes reflect all changes made prior to this call
+
+	err = volume.DoCheckpoint()
+	if nil != err {
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	for _, snapshot := range volume.snapshotMap {
+		if snapshot.Name == name {
+			err = blunder.NewError(blunder.FileExistsError, "snapshot \"%s\" already exists", name)
+			return
+		}
+	}
+
+	snapshot := &snapshotStruct{
+		SnapshotStruct: SnapshotStruct{
+			ID:         volume.nextSnapshotID,
+			Name:       name,
+			CreateTime: time.Now(),
+		},
+		InodeRecBPlusTreeObjectNumber:        volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
+		InodeRecBPlusTreeObjectOffset:        volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
+		InodeRecBPlusTreeObjectLength:        volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectLength,
+		LogSegmentRecBPlusTreeObjectNumber:   volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber,
+		LogSegmentRecBPlusTreeObjectOffset:   volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset,
+		LogSegmentRecBPlusTreeObjectLength:   volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength,
+		BPlusTreeObjectBPlusTreeObjectNumber: volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber,
+		BPlusTreeObjectBPlusTreeObjectOffset: volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset,
+		BPlusTreeObjectBPlusTreeObjectLength: volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength,
+		NextNonce:                            volume.nextNonce,
+	}
+
+	checkpointObjectSet := make(map[uint64]struct{})
+	for _, layout := range []sortedmap.LayoutReport{volume.inodeRecBPlusTreeLayout, volume.logSegmentRecBPlusTreeLayout, volume.bPlusTreeObjectBPlusTreeLayout} {
+		for objectNumber := range layout {
+			checkpointObjectSet[objectNumber] = struct{}{}
+		}
+	}
+	snapshot.CheckpointObjects = make([]uint64, 0, len(checkpointObjectSet))
+	for objectNumber := range checkpointObjectSet {
+		snapshot.CheckpointObjects = append(snapshot.CheckpointObjects, objectNumber)
+	}
+	sort.Slice(snapshot.CheckpointObjects, func(i, j int) bool { return snapshot.CheckpointObjects[i] < snapshot.CheckpointObjects[j] })
+	snapshot.checkpointObjectSet = checkpointObjectSet
+
+	volume.snapshotMap[snapshot.ID] = snapshot
+	volume.nextSnapshotID++
+
+	err = volume.putSnapshotsWhileLocked()
+	if nil != err {
+		delete(volume.snapshotMap, snapshot.ID)
+		volume.nextSnapshotID--
+		return
+	}
+
+	id = snapshot.ID
+	return
+}
+
+func (volume *volumeStruct) SnapshotDelete(id uint64) (err error) {
+	volume.Lock()
+	defer volume.Unlock()
+
+	snapshot, ok := volume.snapshotMap[id]
+	if !ok {
+		err = blunder.NewError(blunder.NotFoundError, "snapshot %v not found", id)
+		return
+	}
+
+	delete(volume.snapshotMap, id)
+
+	// Determine which deferred deletes are no longer retained by a remaining snapshot
+
+	checkpointObjectsToDelete := make([]uint64, 0)
+	for objectNumber := range volume.deferredCheckpointObjectDeletes {
+		retained := false
+		for _, otherSnapshot := range volume.snapshotMap {
+			_, retained = otherSnapshot.checkpointObjectSet[objectNumber]
+			if retained {
+				break
+			}
+		}
+		if !retained {
+			checkpointObjectsToDelete = append(checkpointObjectsToDelete, objectNumber)
+		}
+	}
+
+	retainedBelowNonce := volume.snapshotsRetainedBelowNonceWhileLocked()
+
+	logSegmentsToDelete := make(map[uint64]string)
+	for logSegmentNumber, containerName := range volume.deferredLogSegmentDeletes {
+		if logSegmentNumber >= retainedBelowNonce {
+			logSegmentsToDelete[logSegmentNumber] = containerName
+		}
+	}
+
+	for _, objectNumber := range checkpointObjectsToDelete {
+		delete(volume.deferredCheckpointObjectDeletes, objectNumber)
+	}
+	for logSegmentNumber := range logSegmentsToDelete {
+		delete(volume.deferredLogSegmentDeletes, logSegmentNumber)
+	}
+
+	// Persist the removal before issuing any DELETEs so a crash cannot leave a snapshot missing objects
+
+	err = volume.putSnapshotsWhileLocked()
+	if nil != err {
+		volume.snapshotMap[id] = snapshot
+		for _, objectNumber := range checkpointObjectsToDelete {
+			volume.deferredCheckpointObjectDeletes[objectNumber] = struct{}{}
+		}
+		for logSegmentNumber, containerName := range logSegmentsToDelete {
+			volume.deferredLogSegmentDeletes[logSegmentNumber] = containerName
+		}
+		return
+	}
+
+	for _, objectNumber := range checkpointObjectsToDelete {
+		swiftclient.ObjectDeleteAsync(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(objectNumber), nil, nil)
+	}
+	for logSegmentNumber, containerName := range logSegmentsToDelete {
+		swiftclient.ObjectDeleteAsync(volume.accountName, containerName, utils.Uint64ToHexStr(logSegmentNumber), nil, nil)
+	}
+
+	if nil != snapshot.view {
+		snapshot.view.Lock()
+		snapshot.view.deleted = true
+		snapshot.view.Unlock()
+	}
+
+	return
+}
+
+func (volume *volumeStruct) SnapshotList() (list []SnapshotStruct) {
+	volume.Lock()
+	list = make([]SnapshotStruct, 0, len(volume.snapshotMap))
+	for _, snapshot := range volume.snapshotMap {
+		list = append(list, snapshot.SnapshotStruct)
+	}
+	volume.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return
+}
+
+// snapshotsRetainedBelowNonceWhileLocked returns the highest NextNonce of any snapshot (0 if none); every
+// LogSegment with a lower logSegmentNumber may be referenced by a snapshot.
+func (volume *volumeStruct) snapshotsRetainedBelowNonceWhileLocked() (retainedBelowNonce uint64) {
+	for _, snapshot := range volume.snapshotMap {
+		if snapshot.NextNonce > retainedBelowNonce {
+			retainedBelowNonce = snapshot.NextNonce
+		}
+	}
+	return
+}
+
+func (volume *volumeStruct) SnapshotRetainsLogSegment(logSegmentNumber uint64, containerName string) (retained bool) {
+	volume.Lock()
+	retained = logSegmentNumber < volume.snapshotsRetainedBelowNonceWhileLocked()
+	if retained {
+		volume.deferredLogSegmentDeletes[logSegmentNumber] = containerName
+		volume.snapshotsDirty = true
+	}
+	volume.Unlock()
+	return
+}
+
+func (volume *volumeStruct) FetchSnapshotVolumeHandle(id uint64) (snapshotVolumeHandle VolumeHandle, err error) {
+	volume.Lock()
+	defer volume.Unlock()
+
+	snapshot, ok := volume.snapshotMap[id]
+	if !ok {
+		err = blunder.NewError(blunder.NotFoundError, "snapshot %v not found", id)
+		return
+	}
+
+	if nil == snapshot.view {
+		snapshot.view, err = volume.newSnapshotVolume(snapshot)
+		if nil != err {
+			return
+		}
+	}
+
+	snapshotVolumeHandle = snapshot.view
+	return
+}
+
+// snapshotBPlusTreeWrapperStruct reads the nodes of a snapshot's B+Tree just as the live one would but
+// refuses to write any.
+type snapshotBPlusTreeWrapperStruct struct {
+	bPlusTreeWrapperStruct
+}
+
+func (bPlusTreeWrapper *snapshotBPlusTreeWrapperStruct) PutNode(nodeByteSlice []byte) (objectNumber uint64, objectOffset uint64, err error) {
+	err = blunder.NewError(blunder.ReadOnlyError, "snapshot B+Tree nodes may not be written")
+	return
+}
+
+func (bPlusTreeWrapper *snapshotBPlusTreeWrapperStruct) DiscardNode(objectNumber uint64, objectOffset uint64, objectLength uint64) (err error) {
+	err = blunder.NewError(blunder.ReadOnlyError, "snapshot B+Tree nodes may not be discarded")
+	return
+}
+
+// snapshotVolumeStruct is the read-only VolumeHandle of a snapshot.
+type snapshotVolumeStruct struct {
+	sync.Mutex
+	volume          *volumeStruct
+	id              uint64
+	deleted         bool // set by SnapshotDelete()
+	inodeRec        sortedmap.BPlusTree
+	logSegmentRec   sortedmap.BPlusTree
+	bPlusTreeObject sortedmap.BPlusTree
+}
+
+func (volume *volumeStruct) newSnapshotVolume(snapshot *snapshotStruct) (view *snapshotVolumeStruct, err error) {
+	view = &snapshotVolumeStruct{volume: volume, id: snapshot.ID}
+
+	openTree := func(wrapperType uint32, maxKeysPerNode uint64, objectNumber uint64, objectOffset uint64, objectLength uint64, bPlusTreeCache sortedmap.BPlusTreeCache) (tree sortedmap.BPlusTree, err error) {
+		wrapper := &snapshotBPlusTreeWrapperStruct{bPlusTreeWrapperStruct{volume: volume, wrapperType: wrapperType}}
+		if 0 == objectNumber {
+			tree = sortedmap.NewBPlusTree(maxKeysPerNode, sortedmap.CompareUint64, wrapper, bPlusTreeCache)
+		} else {
+			tree, err = sortedmap.OldBPlusTree(objectNumber, objectOffset, objectLength, sortedmap.CompareUint64, wrapper, bPlusTreeCache)
+		}
+		return
+	}
+
+	view.inodeRec, err = openTree(inodeRecBPlusTreeWrapperType, volume.maxInodesPerMetadataNode,
+		snapshot.InodeRecBPlusTreeObjectNumber, snapshot.InodeRecBPlusTreeObjectOffset, snapshot.InodeRecBPlusTreeObjectLength,
+		globals.inodeRecCache)
+	if nil != err {
+		return
+	}
+	view.logSegmentRec, err = openTree(logSegmentRecBPlusTreeWrapperType, volume.maxLogSegmentsPerMetadataNode,
+		snapshot.LogSegmentRecBPlusTreeObjectNumber, snapshot.LogSegmentRecBPlusTreeObjectOffset, snapshot.LogSegmentRecBPlusTreeObjectLength,
+		globals.logSegmentRecCache)
+	if nil != err {
+		return
+	}
+	view.bPlusTreeObject, err = openTree(bPlusTreeObjectBPlusTreeWrapperType, volume.maxDirFileNodesPerMetadataNode,
+		snapshot.BPlusTreeObjectBPlusTreeObjectNumber, snapshot.BPlusTreeObjectBPlusTreeObjectOffset, snapshot.BPlusTreeObjectBPlusTreeObjectLength,
+		globals.bPlusTreeObjectCache)
+
+	return
+}
+
+func (view *snapshotVolumeStruct) readOnlyError() (err error) {
+	err = blunder.NewError(blunder.ReadOnlyError, "snapshot %v of volume \"%s\" is read-only", view.id, view.volume.volumeName)
+	return
+}
+
+// get returns a copy of the value of key in tree (nil, false if not found).
+func (view *snapshotVolumeStruct) get(tree sortedmap.BPlusTree, key uint64) (value []byte, ok bool, err error) {
+	view.Lock()
+	defer view.Unlock()
+
+	if view.deleted {
+		err = blunder.NewError(blunder.NotFoundError, "snapshot %v of volume \"%s\" has been deleted", view.id, view.volume.volumeName)
+		return
+	}
+
+	valueAsValue, ok, err := tree.GetByKey(key)
+	if (nil != err) || !ok {
+		return
+	}
+	valueFromTree := valueAsValue.([]byte)
+	value = make([]byte, len(valueFromTree))
+	copy(value, valueFromTree)
+	return
+}
+
+func (view *snapshotVolumeStruct) FetchNextCheckPointDoneWaitGroup() (wg *sync.WaitGroup) {
+	wg = &sync.WaitGroup{} // nothing is ever awaiting a checkpoint of a snapshot
+	return
+}
+
+func (view *snapshotVolumeStruct) FetchNonce() (nonce uint64, err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) FetchInodeRecCount() (inodeRecCount uint64, err error) {
+	view.Lock()
+	numberOfItems, err := view.inodeRec.Len()
+	view.Unlock()
+	if nil != err {
+		return
+	}
+
+	inodeRecCount = uint64(numberOfItems)
+	return
+}
+
+func (view *snapshotVolumeStruct) GetInodeRec(inodeNumber uint64) (value []byte, ok bool, err error) {
+	value, ok, err = view.get(view.inodeRec, inodeNumber)
+	return
+}
+
+func (view *snapshotVolumeStruct) PutInodeRec(inodeNumber uint64, value []byte) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) PutInodeRecs(inodeNumbers []uint64, values [][]byte) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) DeleteInodeRec(inodeNumber uint64) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) GetLogSegmentRec(logSegmentNumber uint64) (value []byte, err error) {
+	value, ok, err := view.get(view.logSegmentRec, logSegmentNumber)
+	if (nil == err) && !ok {
+		err = fmt.Errorf("logSegmentNumber 0x%016X not found in snapshot %v of volume \"%v\"", logSegmentNumber, view.id, view.volume.volumeName)
+	}
+	return
+}
+
+func (view *snapshotVolumeStruct) PutLogSegmentRec(logSegmentNumber uint64, value []byte) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) DeleteLogSegmentRec(logSegmentNumber uint64) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) GetBPlusTreeObject(objectNumber uint64) (value []byte, err error) {
+	value, ok, err := view.get(view.bPlusTreeObject, objectNumber)
+	if (nil == err) && !ok {
+		err = fmt.Errorf("objectNumber 0x%016X not found in snapshot %v of volume \"%v\"", objectNumber, view.id, view.volume.volumeName)
+	}
+	return
+}
+
+func (view *snapshotVolumeStruct) PutBPlusTreeObject(objectNumber uint64, value []byte) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) DeleteBPlusTreeObject(objectNumber uint64) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) DoCheckpoint() (err error) {
+	return // nothing to persist
+}
+
+func (view *snapshotVolumeStruct) SnapshotCreate(name string) (id uint64, err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) SnapshotDelete(id uint64) (err error) {
+	err = view.readOnlyError()
+	return
+}
+
+func (view *snapshotVolumeStruct) SnapshotList() (list []SnapshotStruct) {
+	list = make([]SnapshotStruct, 0)
+	return
+}
+
+func (view *snapshotVolumeStruct) SnapshotRetainsLogSegment(logSegmentNumber uint64, containerName string) (retained bool) {
+	logger.Errorf("headhunter: SnapshotRetainsLogSegment(0x%016X) called on snapshot %v of volume \"%s\"", logSegmentNumber, view.id, view.volume.volumeName)
+	retained = true // never delete anything on behalf of a snapshot
+	return
+}
+
+func (view *snapshotVolumeStruct) FetchSnapshotVolumeHandle(id uint64) (snapshotVolumeHandle VolumeHandle, err error) {
+	err = blunder.NewError(blunder.NotFoundError, "snapshot %v of volume \"%s\" has no snapshots", view.id, view.volume.volumeName)
+	return
+}
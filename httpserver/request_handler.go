@@ -12,7 +12,9 @@ import (
 
 	"github.com/swiftstack/sortedmap"
 
+	"github.com/swiftstack/ProxyFS/blunder"
 	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/logger"
 	"github.com/swiftstack/ProxyFS/stats"
 	"github.com/swiftstack/ProxyFS/utils"
@@ -294,8 +296,12 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 		// Form: /volume
 	case 3:
 		// Form: /volume/<volume-name/fsck-job
+		// Form: /volume/<volume-name/verify
+		// Form: /volume/<volume-name/usage-trend
+		// Form: /volume/<volume-name/op-stats
 	case 4:
 		// Form: /volume/<volume-name/fsck-job/<job-id>
+		// Form: /volume/<volume-name/inode-history/<inode-number>
 	default:
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
@@ -398,6 +404,26 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	}
 	volume = volumeAsValue.(*volumeStruct)
 
+	if (3 == numPathParts) && ("verify" == pathSplit[3]) {
+		doGetOfVolumeVerify(responseWriter, volumeName, formatResponseCompactly)
+		return
+	}
+
+	if (3 == numPathParts) && ("usage-trend" == pathSplit[3]) {
+		doGetOfVolumeUsageTrend(responseWriter, volumeName, formatResponseCompactly)
+		return
+	}
+
+	if (3 == numPathParts) && ("op-stats" == pathSplit[3]) {
+		doGetOfVolumeOpStats(responseWriter, volumeName, formatResponseCompactly)
+		return
+	}
+
+	if (4 == numPathParts) && ("inode-history" == pathSplit[3]) {
+		doGetOfVolumeInodeHistory(responseWriter, volumeName, pathSplit[4], formatResponseCompactly)
+		return
+	}
+
 	volume.Lock()
 
 	if "fsck-job" != pathSplit[3] {
@@ -641,6 +667,165 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	volume.Unlock()
 }
 
+// doGetOfVolumeVerify runs fs.VerifyVolume() to completion, always responding with its JSON-encoded
+// fs.VerifyReport (see fs/verify.go) as it is intended for consumption by tooling.
+func doGetOfVolumeVerify(responseWriter http.ResponseWriter, volumeName string, formatResponseCompactly bool) {
+	var (
+		err                    error
+		verifyReport           fs.VerifyReport
+		verifyReportJSON       bytes.Buffer
+		verifyReportJSONPacked []byte
+	)
+
+	verifyReport, err = fs.VerifyVolume(volumeName, nil)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("%v\n", err)))
+		}
+		return
+	}
+
+	verifyReportJSONPacked, err = json.Marshal(verifyReport)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	if formatResponseCompactly {
+		_, _ = responseWriter.Write(verifyReportJSONPacked)
+	} else {
+		json.Indent(&verifyReportJSON, verifyReportJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(verifyReportJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
+// doGetOfVolumeUsageTrend always responds with the JSON-encoded []fs.UsageSample (see fs/trend.go) of the
+// volume, oldest first, as it is intended for consumption by tooling.
+func doGetOfVolumeUsageTrend(responseWriter http.ResponseWriter, volumeName string, formatResponseCompactly bool) {
+	var (
+		err                  error
+		usageTrend           []fs.UsageSample
+		usageTrendJSON       bytes.Buffer
+		usageTrendJSONPacked []byte
+	)
+
+	usageTrend, err = fs.FetchUsageTrend(volumeName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("%v\n", err)))
+		}
+		return
+	}
+
+	usageTrendJSONPacked, err = json.Marshal(usageTrend)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	if formatResponseCompactly {
+		_, _ = responseWriter.Write(usageTrendJSONPacked)
+	} else {
+		json.Indent(&usageTrendJSON, usageTrendJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(usageTrendJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
+// doGetOfVolumeOpStats always responds with the JSON-encoded fs.VolumeOpStats (see fs/op_stats.go) of
+// the volume and its current mounts as it is intended for consumption by tooling.
+func doGetOfVolumeOpStats(responseWriter http.ResponseWriter, volumeName string, formatResponseCompactly bool) {
+	var (
+		err               error
+		opStats           fs.VolumeOpStats
+		opStatsJSON       bytes.Buffer
+		opStatsJSONPacked []byte
+	)
+
+	opStats, err = fs.FetchVolumeOpStats(volumeName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("%v\n", err)))
+		}
+		return
+	}
+
+	opStatsJSONPacked, err = json.Marshal(opStats)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	if formatResponseCompactly {
+		_, _ = responseWriter.Write(opStatsJSONPacked)
+	} else {
+		json.Indent(&opStatsJSON, opStatsJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(opStatsJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
+// doGetOfVolumeInodeHistory always responds with the JSON-encoded []fs.InodeHistoryEntry (see
+// fs/history.go) of the specified inode as it is intended for consumption by tooling.
+func doGetOfVolumeInodeHistory(responseWriter http.ResponseWriter, volumeName string, inodeNumberAsString string, formatResponseCompactly bool) {
+	var (
+		err                    error
+		inodeHistory           []fs.InodeHistoryEntry
+		inodeHistoryJSON       bytes.Buffer
+		inodeHistoryJSONPacked []byte
+		inodeNumber            uint64
+	)
+
+	inodeNumber, err = strconv.ParseUint(inodeNumberAsString, 10, 64)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	inodeHistory, err = fs.FetchInodeHistory(volumeName, inode.InodeNumber(inodeNumber))
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("%v\n", err)))
+		}
+		return
+	}
+
+	inodeHistoryJSONPacked, err = json.Marshal(inodeHistory)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	if formatResponseCompactly {
+		_, _ = responseWriter.Write(inodeHistoryJSONPacked)
+	} else {
+		json.Indent(&inodeHistoryJSON, inodeHistoryJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(inodeHistoryJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
 func doPost(responseWriter http.ResponseWriter, request *http.Request) {
 	var (
 		err            error
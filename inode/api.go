@@ -7,6 +7,7 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"github.com/swiftstack/ProxyFS/headhunter"
 	"github.com/swiftstack/ProxyFS/utils"
 )
 
@@ -28,6 +29,8 @@ const (
 	DirType     InodeType = unix.DT_DIR
 	FileType    InodeType = unix.DT_REG
 	SymlinkType InodeType = unix.DT_LNK
+	FIFOType    InodeType = unix.DT_FIFO
+	SocketType  InodeType = unix.DT_SOCK
 )
 
 // The following are used in calls to Access()... either F_OK or bitwise or of R_OK, W_OK, and X_OK
@@ -40,6 +43,26 @@ const (
 	P_OK = InodeMode((unix.R_OK | unix.W_OK | unix.X_OK) + 1) //         check for ownership
 )
 
+// The following may be bitwise or'd together in the flags passed to Move() (values match Linux renameat2())
+
+type MoveFlags uint32
+
+const (
+	MoveNoReplace MoveFlags = 1 << iota // fail with FileExistsError if dstBasename already exists
+	MoveExchange                        // atomically swap the inodes referenced by srcBasename & dstBasename (both must exist)
+)
+
+// The following may be bitwise or'd together in the flags passed to SetFlags() (values match Linux FS_IOC_SETFLAGS)
+
+type InodeFlags uint32
+
+const (
+	ImmutableFlag  InodeFlags = 0x00000010 // the inode may be neither modified nor removed
+	AppendOnlyFlag InodeFlags = 0x00000020 // the inode may only be appended to (and not removed)
+
+	KnownFlags = ImmutableFlag | AppendOnlyFlag
+)
+
 // The following line of code is a directive to go generate that tells it to create a
 // file called inodetype_string.go that implements the .String() method for InodeType.
 //go:generate stringer -type=InodeType
@@ -53,10 +76,31 @@ type MetadataStruct struct {
 	AccessTime           time.Time
 	AttrChangeTime       time.Time // aka ctime; This field is intended to be changed by writing or by setting inode information (i.e., owner, group, link count, mode, etc.).
 	NumWrites            uint64    // only maintained for FileType inodes
+	ChangeCount          uint64    // incremented with each update of AttrChangeTime (e.g. NFS change attribute)
+	AllocatedBytes       uint64    // FileType inodes: bytes referenced in LogSegments (i.e. excluding holes); otherwise Size
 	InodeStreamNameSlice []string
 	Mode                 InodeMode
 	UserID               InodeUserID
 	GroupID              InodeGroupID
+	Flags                InodeFlags
+}
+
+// SetAttrsStruct describes the attributes SetAttrs() changes: each is applied only if its Set... field is true
+type SetAttrsStruct struct {
+	SetCreationTime     bool
+	CreationTime        time.Time
+	SetModificationTime bool
+	ModificationTime    time.Time
+	SetAccessTime       bool
+	AccessTime          time.Time
+	SetSize             bool
+	Size                uint64 // only FileType inodes have a settable Size
+	SetUserID           bool
+	UserID              InodeUserID
+	SetGroupID          bool
+	GroupID             InodeGroupID
+	SetPermMode         bool
+	PermMode            InodeMode
 }
 
 type FragmentationReport struct {
@@ -76,6 +120,8 @@ type CoalesceElement struct {
 	ContainingDirectoryInodeNumber InodeNumber
 	ElementInodeNumber             InodeNumber
 	ElementName                    string
+	Offset                         uint64 // of the element's contents to include in the combination
+	Length                         uint64 // of the element's contents to include (0 == through the end of the element)
 }
 
 func (de *DirEntry) Size() int {
@@ -83,6 +129,13 @@ func (de *DirEntry) Size() int {
 	return int(unsafe.Sizeof(de.InodeNumber)) + int(unsafe.Sizeof(de.Type)) + int(unsafe.Sizeof(de.NextDirLocation)) + len(de.Basename) + 1
 }
 
+// LogSegmentLocation names the Swift Object holding a LogSegment referenced by a file inode
+type LogSegmentLocation struct {
+	LogSegmentNumber uint64
+	ContainerName    string // If == "", the LogSegment's container was not recorded
+	ObjectName       string
+}
+
 type ReadPlanStep struct {
 	LogSegmentNumber uint64 // If == 0, Length specifies zero-file size
 	Offset           uint64 // If zero-fill case, == 0
@@ -93,6 +146,22 @@ type ReadPlanStep struct {
 	ObjectPath       string // If == "", Length specifies a zero-fill size
 }
 
+// WriteSegment is one of the (offset, buffer) pairs written by Writev()
+type WriteSegment struct {
+	Offset uint64
+	Buf    []byte
+}
+
+// ReadaheadStats reports the adaptive readahead state of a file inode (see readahead.go).
+type ReadaheadStats struct {
+	SequentialReads  uint64
+	RandomReads      uint64
+	PrefetchedLines  uint64        // Read Cache Lines fetched by readahead
+	WindowCacheLines uint64        // current readahead window
+	ConsumptionRate  uint64        // bytes/second
+	GetLatency       time.Duration // per Read Cache Line
+}
+
 const (
 	RootDirInodeNumber = InodeNumber(1)
 )
@@ -122,6 +191,9 @@ type VolumeHandle interface {
 	// Generic methods, implemented volume.go
 
 	GetFSID() (fsid uint64)
+	GetAccountName() (accountName string)
+	GetInodeCount() (inodeCount uint64, err error)
+	Now() (timestamp time.Time) // the volume clock's next timestamp (see clock.go)
 
 	// Common Inode methods, implemented in inode.go
 
@@ -129,21 +201,33 @@ type VolumeHandle interface {
 	Purge(inodeNumber InodeNumber) (err error)
 	Destroy(inodeNumber InodeNumber) (err error)
 	GetMetadata(inodeNumber InodeNumber) (metadata *MetadataStruct, err error)
+	GetMetadataMulti(inodeNumbers []InodeNumber) (metadataSlice []*MetadataStruct, errs []error)
 	GetType(inodeNumber InodeNumber) (inodeType InodeType, err error)
 	GetLinkCount(inodeNumber InodeNumber) (linkCount uint64, err error)
 	SetLinkCount(inodeNumber InodeNumber, linkCount uint64) (err error)
 	SetCreationTime(inodeNumber InodeNumber, creationTime time.Time) (err error)
 	SetModificationTime(inodeNumber InodeNumber, modificationTime time.Time) (err error)
 	SetAccessTime(inodeNumber InodeNumber, accessTime time.Time) (err error)
+	UpdateAccessTime(inodeNumber InodeNumber, accessTime time.Time) (err error)
 	SetPermMode(inodeNumber InodeNumber, filePerm InodeMode) (err error)
+	SetFlags(inodeNumber InodeNumber, flags InodeFlags) (err error)
+	GetNFS4ACL(inodeNumber InodeNumber) (acl NFS4ACL, err error)
+	SetNFS4ACL(inodeNumber InodeNumber, acl NFS4ACL) (err error)
 	SetOwnerUserID(inodeNumber InodeNumber, userID InodeUserID) (err error)
 	SetOwnerUserIDGroupID(inodeNumber InodeNumber, userID InodeUserID, groupID InodeGroupID) (err error)
 	SetOwnerGroupID(inodeNumber InodeNumber, groupID InodeGroupID) (err error)
+	SetAttrs(inodeNumber InodeNumber, attrs *SetAttrsStruct) (err error) // implemented in setattrs.go
 	GetStream(inodeNumber InodeNumber, inodeStreamName string) (buf []byte, err error)
 	PutStream(inodeNumber InodeNumber, inodeStreamName string, buf []byte) (err error)
+	UpdateStream(inodeNumber InodeNumber, inodeStreamName string, buf []byte) (err error)
 	DeleteStream(inodeNumber InodeNumber, inodeStreamName string) (err error)
+	GetStreamSize(inodeNumber InodeNumber, inodeStreamName string) (size uint64, err error)
+	ReadStream(inodeNumber InodeNumber, inodeStreamName string, offset uint64, length uint64) (buf []byte, err error)
+	WriteStream(inodeNumber InodeNumber, inodeStreamName string, offset uint64, buf []byte) (err error)
+	ResizeStream(inodeNumber InodeNumber, inodeStreamName string, newSize uint64) (err error)
 	GetFragmentationReport(inodeNumber InodeNumber) (fragmentationReport FragmentationReport, err error)
 	Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (err error)
+	Adopt(inodeNumber InodeNumber, logSegmentNumbers []uint64) (err error)
 	Validate(inodeNumber InodeNumber) (err error)
 
 	// Directory Inode specific methods, implemented in dir.go
@@ -151,25 +235,69 @@ type VolumeHandle interface {
 	CreateDir(filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (dirInodeNumber InodeNumber, err error)
 	Link(dirInodeNumber InodeNumber, basename string, targetInodeNumber InodeNumber) (err error)
 	Unlink(dirInodeNumber InodeNumber, basename string) (err error)
-	Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string) (err error)
+	Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string, flags MoveFlags) (err error)
 	Lookup(dirInodeNumber InodeNumber, basename string) (targetInodeNumber InodeNumber, err error)
+	LocateDirEntry(dirInodeNumber InodeNumber, basename string) (location InodeDirLocation, targetInodeNumber InodeNumber, err error)
 	NumDirEntries(dirInodeNumber InodeNumber) (numEntries uint64, err error)
+	FlushDir(dirInodeNumber InodeNumber) (err error)
 	ReadDir(dirInodeNumber InodeNumber, maxEntries uint64, maxBufSize uint64, prevReturned ...interface{}) (dirEntrySlice []DirEntry, moreEntries bool, err error)
 
 	// File Inode specific methods, implemented in file.go
 
 	CreateFile(filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (fileInodeNumber InodeNumber, err error)
 	Read(inodeNumber InodeNumber, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error)
+	ReadInto(fileInodeNumber InodeNumber, offset uint64, dst []byte, profiler *utils.Profiler) (n uint64, err error)
 	GetReadPlan(fileInodeNumber InodeNumber, offset *uint64, length *uint64) (readPlan []ReadPlanStep, err error)
 	Write(fileInodeNumber InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (err error)
+	Writev(fileInodeNumber InodeNumber, segments []WriteSegment, profiler *utils.Profiler) (err error)
 	ProvisionObject() (objectPath string, err error)
 	Wrote(fileInodeNumber InodeNumber, fileOffset uint64, objectPath string, objectOffset uint64, length uint64, patchOnly bool) (err error)
 	SetSize(fileInodeNumber InodeNumber, Size uint64) (err error)
 	Flush(fileInodeNumber InodeNumber, andPurge bool) (err error)
 	Coalesce(containingDirInode InodeNumber, combinationName string, elements []CoalesceElement) (combinationInodeNumber InodeNumber, modificationTime time.Time, numWrites uint64, err error)
 
+	// Directory entry type methods, implemented in dirent_type.go
+
+	RecordDirEntryTypes(dirInodeNumber InodeNumber) (recorded uint64, err error)
+
+	// File clone methods, implemented in clone.go
+
+	CloneFile(fileInodeNumber InodeNumber) (cloneInodeNumber InodeNumber, err error)
+
 	// Symlink Inode specific methods, implemented in symlink.go
 
 	CreateSymlink(target string, filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (symlinkInodeNumber InodeNumber, err error)
 	GetSymlink(symlinkInodeNumber InodeNumber) (target string, err error)
+
+	// Special (FIFO & Unix socket) Inode specific methods, implemented in special.go
+
+	CreateSpecial(inodeType InodeType, filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (specialInodeNumber InodeNumber, err error)
+
+	// Cache pinning methods, implemented in pin.go
+
+	Pin(inodeNumber InodeNumber) (pinnedBytes uint64, err error)
+	Unpin(inodeNumber InodeNumber) (err error)
+	GetPinnedStats() (pinnedInodes uint64, pinnedBytes uint64)
+
+	// Readahead methods, implemented in readahead.go
+
+	FetchReadaheadStats(fileInodeNumber InodeNumber) (readaheadStats ReadaheadStats, err error)
+
+	// Generation-guarded (idempotent) Unlink & Destroy methods, implemented in generation.go
+
+	GetGeneration(inodeNumber InodeNumber) (generation uint64, err error)
+	UnlinkGeneration(dirInodeNumber InodeNumber, basename string, targetInodeNumber InodeNumber, targetGeneration uint64) (unlinked bool, err error)
+	DestroyGeneration(inodeNumber InodeNumber, generation uint64) (destroyed bool, err error)
+
+	// Swift reconciliation methods, implemented in verify.go
+
+	FetchLogSegmentLocations(fileInodeNumber InodeNumber) (locations []LogSegmentLocation, err error)
+	FetchPhysicalContainerNames() (containerNames []string, err error)
+
+	// Snapshot methods, implemented in snapshot.go
+
+	SnapshotCreate(name string) (id uint64, err error)
+	SnapshotDelete(id uint64) (err error)
+	SnapshotList() (list []headhunter.SnapshotStruct)
+	FetchSnapshotVolumeHandle(id uint64) (snapshotVolumeHandle VolumeHandle, err error)
 }
@@ -1404,7 +1404,7 @@ func TestAPI(t *testing.T) {
 		t.Fatalf("ReadDir(RootDirInodeNumber, 0, 0) returned unexpected dirEntrySlice[2]")
 	}
 
-	err = testVolumeHandle.Move(RootDirInodeNumber, "1stLocation", RootDirInodeNumber, "2ndLocation")
+	err = testVolumeHandle.Move(RootDirInodeNumber, "1stLocation", RootDirInodeNumber, "2ndLocation", 0)
 	if nil != err {
 		t.Fatalf("Move(RootDirInodeNumber, \"1stLocation\", RootDirInodeNumber, \"2ndLocation\") failed: %v", err)
 	}
@@ -1436,7 +1436,7 @@ func TestAPI(t *testing.T) {
 	if nil != err {
 		t.Fatalf("Unlink(RootDirInodeNumber, \"3rdLocation\") failed: %v", err)
 	}
-	err = testVolumeHandle.Move(RootDirInodeNumber, "2ndLocation", RootDirInodeNumber, "3rdLocation")
+	err = testVolumeHandle.Move(RootDirInodeNumber, "2ndLocation", RootDirInodeNumber, "3rdLocation", 0)
 	if nil != err {
 		t.Fatalf("Move(RootDirInodeNumber, \"2ndLocation\", RootDirInodeNumber, \"3rdLocation\") failed: %v", err)
 	}
@@ -1589,7 +1589,7 @@ func TestAPI(t *testing.T) {
 		t.Fatalf("ReadDir(subDirInode, 0, 0) returned unexpected dirEntrySlice[1]")
 	}
 
-	err = testVolumeHandle.Move(RootDirInodeNumber, "3rdLocation", subDirInode, "4thLocation")
+	err = testVolumeHandle.Move(RootDirInodeNumber, "3rdLocation", subDirInode, "4thLocation", 0)
 	if nil != err {
 		t.Fatalf("Move(RootDirInodeNumber, \"3rdLocation\", subDirInode, \"4thLocation\") failed: %v", err)
 	}
@@ -2130,7 +2130,7 @@ func TestAPI(t *testing.T) {
 
 	time.Sleep(positiveDurationToDelayOrSkew)
 
-	err = testVolumeHandle.Move(dirInode, "loc_1", dirInode, "loc_2")
+	err = testVolumeHandle.Move(dirInode, "loc_1", dirInode, "loc_2", 0)
 	if nil != err {
 		t.Fatalf("Move(dirInode, \"loc_1\", dirInode, \"loc_2\") failed: %v", err)
 	}
@@ -2309,7 +2309,5 @@ func TestAPI(t *testing.T) {
 		t.Fatalf("fileInodeMetadataAfterSetSize.AccessTime unexpected change")
 	}
 
-	// TODO: Need to test GetFragmentationReport()
-
-	// TODO: Once implemented, need to test Optimize()
+	// GetFragmentationReport() & Optimize() are tested in fragmentation_test.go
 }
@@ -0,0 +1,105 @@
+package inode
+
+// Case-insensitive, case-preserving directory entry matching
+//
+// When [<volume-section>]CaseInsensitive is true, directory entries continue to be stored (and
+// returned by ReadDir()) with the case supplied when they were created, but Lookup(), Link(),
+// Unlink(), and Move() match basenames without regard to case (as Windows SMB clients expect).
+//
+// To avoid a linear scan of a directory's B+Tree for each such operation, each dirInode carries
+// an in-memory index (foldedBasenameMap) from the case-folded form of each basename to the basename
+// actually stored in the B+Tree. The index is built the first time it is needed after the dirInode
+// is fetched and is thereafter kept current by addDirEntryInMemory(), removeDirEntryInMemory(), and
+// Move(). The embedded sync.Mutex of the dirInode protects the index as concurrent readers may
+// trigger its construction.
+
+import (
+	"strings"
+
+	"github.com/swiftstack/sortedmap"
+)
+
+// foldBasename returns the form of basename used to match it irrespective of case.
+func foldBasename(basename string) (foldedBasename string) {
+	foldedBasename = strings.ToUpper(basename)
+	return
+}
+
+// Build dirInode.foldedBasenameMap if necessary. Caller must hold dirInode.Mutex.
+func (dirInode *inMemoryInodeStruct) buildFoldedBasenameMapWhileLocked() (err error) {
+	if nil != dirInode.foldedBasenameMap {
+		return
+	}
+
+	dirMapping := dirInode.payload.(sortedmap.BPlusTree)
+
+	dirMappingLen, err := dirMapping.Len()
+	if nil != err {
+		return
+	}
+
+	foldedBasenameMap := make(map[string]string, dirMappingLen)
+
+	for dirIndex := 0; dirIndex < dirMappingLen; dirIndex++ {
+		key, _, ok, nonShadowingErr := dirMapping.GetByIndex(dirIndex)
+		if nil != nonShadowingErr {
+			err = nonShadowingErr
+			return
+		}
+		if !ok {
+			break
+		}
+		basename := key.(string)
+		if ("." != basename) && (".." != basename) {
+			foldedBasenameMap[foldBasename(basename)] = basename
+		}
+	}
+
+	dirInode.foldedBasenameMap = foldedBasenameMap
+
+	err = nil
+	return
+}
+
+// resolveBasename returns the basename, as stored in dirInode, matching basename. If the volume
+// is not case-insensitive or no matching entry is found, basename itself is returned.
+func (vS *volumeStruct) resolveBasename(dirInode *inMemoryInodeStruct, basename string) (storedBasename string, err error) {
+	storedBasename = basename
+
+	if !vS.caseInsensitive || ("." == basename) || (".." == basename) {
+		return
+	}
+
+	dirInode.Lock()
+	defer dirInode.Unlock()
+
+	err = dirInode.buildFoldedBasenameMapWhileLocked()
+	if nil != err {
+		return
+	}
+
+	matchedBasename, ok := dirInode.foldedBasenameMap[foldBasename(basename)]
+	if ok {
+		storedBasename = matchedBasename
+	}
+
+	return
+}
+
+// noteDirEntryAdded records the addition of basename to dirInode in its foldedBasenameMap (if built).
+func noteDirEntryAdded(dirInode *inMemoryInodeStruct, basename string) {
+	dirInode.Lock()
+	if nil != dirInode.foldedBasenameMap {
+		dirInode.foldedBasenameMap[foldBasename(basename)] = basename
+	}
+	dirInode.Unlock()
+}
+
+// noteDirEntryRemoved records the removal of basename from dirInode in its foldedBasenameMap (if built).
+func noteDirEntryRemoved(dirInode *inMemoryInodeStruct, basename string) {
+	dirInode.Lock()
+	if nil != dirInode.foldedBasenameMap {
+		delete(dirInode.foldedBasenameMap, foldBasename(basename))
+	}
+	dirInode.Unlock()
+}
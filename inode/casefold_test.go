@@ -0,0 +1,105 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestCaseInsensitive(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+	volume.caseInsensitive = true
+	defer func() {
+		volume.caseInsensitive = false
+	}()
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(RootDirInodeNumber, "TestCaseInsensitiveDir", dirInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() of dir failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "MixedCase", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() of file failed: %v", err)
+	}
+
+	lookupInodeNumber, err := testVolumeHandle.Lookup(dirInodeNumber, "mixedcase")
+	if nil != err {
+		t.Fatalf("Lookup(\"mixedcase\") failed: %v", err)
+	}
+	if fileInodeNumber != lookupInodeNumber {
+		t.Fatalf("Lookup(\"mixedcase\") returned %v (expected %v)", lookupInodeNumber, fileInodeNumber)
+	}
+
+	otherFileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "MIXEDCASE", otherFileInodeNumber)
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("Link() of case variant should have failed with FileExistsError: %v", err)
+	}
+
+	// A case-only rename must preserve the new case
+
+	err = testVolumeHandle.Move(dirInodeNumber, "mixedCASE", dirInodeNumber, "MIXEDcase", 0)
+	if nil != err {
+		t.Fatalf("Move() for case-only rename failed: %v", err)
+	}
+	_, err = testVolumeHandle.Lookup(dirInodeNumber, "MixedCase")
+	if nil != err {
+		t.Fatalf("Lookup(\"MixedCase\") after case-only rename failed: %v", err)
+	}
+	dirEntries, _, err := testVolumeHandle.ReadDir(dirInodeNumber, 0, 0, "..")
+	if nil != err {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	if (1 != len(dirEntries)) || ("MIXEDcase" != dirEntries[0].Basename) {
+		t.Fatalf("ReadDir() after case-only rename returned unexpected %v", dirEntries)
+	}
+
+	// Replacing an entry stored with different case leaves only the new name
+
+	err = testVolumeHandle.Link(dirInodeNumber, "Other", otherFileInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() of other file failed: %v", err)
+	}
+	err = testVolumeHandle.Move(dirInodeNumber, "OTHER", dirInodeNumber, "mixedcase", 0)
+	if nil != err {
+		t.Fatalf("Move() replacing case variant failed: %v", err)
+	}
+	dirEntries, _, err = testVolumeHandle.ReadDir(dirInodeNumber, 0, 0, "..")
+	if nil != err {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	if (1 != len(dirEntries)) || ("mixedcase" != dirEntries[0].Basename) || (otherFileInodeNumber != dirEntries[0].InodeNumber) {
+		t.Fatalf("ReadDir() after replacing Move() returned unexpected %v", dirEntries)
+	}
+
+	err = testVolumeHandle.Unlink(dirInodeNumber, "MIXEDCASE")
+	if nil != err {
+		t.Fatalf("Unlink(\"MIXEDCASE\") failed: %v", err)
+	}
+	_, err = testVolumeHandle.Lookup(dirInodeNumber, "mixedcase")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() after Unlink() should have failed with NotFoundError: %v", err)
+	}
+
+	err = testVolumeHandle.Unlink(RootDirInodeNumber, "testcaseinsensitivedir")
+	if nil != err {
+		t.Fatalf("Unlink() of dir failed: %v", err)
+	}
+}
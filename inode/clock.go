@@ -0,0 +1,71 @@
+package inode
+
+// Volume clock
+//
+// All timestamps ProxyFS assigns to a volume's inodes (creation, modification, and attribute change
+// times... and hence the lastModified reported via the Swift middleware) come from the volume's clock
+// rather than directly from time.Now(). The clock is a hybrid of the wall clock and a logical counter:
+// each timestamp is the wall clock time unless that would not be after the previous timestamp (e.g.
+// as the wall clock was stepped backwards, or two timestamps were taken within its resolution), in
+// which case it is the previous timestamp plus a nanosecond. Timestamps thus strictly increase.
+//
+// Each timestamp applied to an inode is further made to follow the inode's current AttrChangeTime.
+// As ctime is only ever set by ProxyFS (and always along with mtime when the data is modified), an
+// inode's mtime never goes backwards as it is modified even should it have last been modified by a
+// node whose clock ran ahead of this one's (at which point this node's clock is advanced to match).
+// To keep a single badly skewed timestamp from dragging the clock arbitrarily far into the future,
+// the clock only follows timestamps up to [<volume-section>]MaxClockSkew ahead of the wall clock
+// (defaulting to one minute; 0 == never). Timestamps beyond that are counted and logged (as the
+// InodeClockSkewOps stat) and ignored. Times explicitly set (e.g. via SetModificationTime()) are
+// applied as given.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const defaultMaxClockSkew = time.Minute
+
+type clockStruct struct {
+	sync.Mutex
+	maxSkew time.Duration // [<volume-section>]MaxClockSkew
+	last    time.Time     // most recent timestamp returned by timestamp()
+}
+
+// timestamp returns the volume clock's next timestamp, following the AttrChangeTime of each (non-nil)
+// inode in inodes.
+func (vS *volumeStruct) timestamp(inodes ...*inMemoryInodeStruct) (timestamp time.Time) {
+	wallTime := time.Now().Round(0) // strip the monotonic clock reading as timestamps are persisted
+
+	vS.clock.Lock()
+	defer vS.clock.Unlock()
+
+	timestamp = wallTime
+	if !timestamp.After(vS.clock.last) {
+		timestamp = vS.clock.last.Add(time.Nanosecond)
+	}
+
+	for _, inode := range inodes {
+		if (nil == inode) || timestamp.After(inode.AttrChangeTime) {
+			continue
+		}
+		if inode.AttrChangeTime.Sub(wallTime) > vS.clock.maxSkew {
+			stats.IncrementOperations(&stats.InodeClockSkewOps)
+			logger.Warnf("inode %v of volume '%s' has ctime %v beyond MaxClockSkew (%v) of %v", inode.InodeNumber, vS.volumeName, inode.AttrChangeTime, vS.clock.maxSkew, wallTime)
+			continue
+		}
+		timestamp = inode.AttrChangeTime.Add(time.Nanosecond)
+	}
+
+	vS.clock.last = timestamp
+
+	return
+}
+
+func (vS *volumeStruct) Now() (timestamp time.Time) {
+	timestamp = vS.timestamp()
+	return
+}
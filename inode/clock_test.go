@@ -0,0 +1,95 @@
+package inode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+	testVolume := testVolumeHandle.(*volumeStruct)
+
+	defer func() {
+		testVolume.clock.Lock()
+		testVolume.clock.last = time.Time{}
+		testVolume.clock.Unlock()
+	}()
+
+	// Timestamps strictly increase...
+
+	prevTimestamp := testVolumeHandle.Now()
+	for i := 0; i < 100; i++ {
+		timestamp := testVolumeHandle.Now()
+		if !timestamp.After(prevTimestamp) {
+			t.Fatalf("Now() returned %v not after %v", timestamp, prevTimestamp)
+		}
+		prevTimestamp = timestamp
+	}
+
+	// ...even as the wall clock appears to step backwards
+
+	testVolume.clock.Lock()
+	testVolume.clock.last = time.Now().Add(time.Hour)
+	aheadTimestamp := testVolume.clock.last
+	testVolume.clock.Unlock()
+
+	timestamp := testVolumeHandle.Now()
+	if !timestamp.Equal(aheadTimestamp.Add(time.Nanosecond)) {
+		t.Fatalf("Now() after wall clock stepped backwards returned %v (expected %v)", timestamp, aheadTimestamp.Add(time.Nanosecond))
+	}
+
+	testVolume.clock.Lock()
+	testVolume.clock.last = time.Time{}
+	testVolume.clock.Unlock()
+
+	// An inode's mtime follows its ctime if set (e.g. by a node whose clock runs ahead) within MaxClockSkew...
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	fileInode, ok, err := testVolume.fetchInode(fileInodeNumber)
+	if (nil != err) || !ok {
+		t.Fatalf("fetchInode() failed: %v", err)
+	}
+
+	skewedCTime := time.Now().Add(testVolume.clock.maxSkew / 2)
+	fileInode.AttrChangeTime = skewedCTime
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("abc"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	metadata, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if !metadata.ModificationTime.After(skewedCTime) {
+		t.Fatalf("Write() set mtime %v not after ctime %v within MaxClockSkew", metadata.ModificationTime, skewedCTime)
+	}
+
+	// ...but not beyond it
+
+	skewedCTime = time.Now().Add(2 * testVolume.clock.maxSkew)
+	fileInode.AttrChangeTime = skewedCTime
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("def"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	metadata, err = testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if !metadata.ModificationTime.Before(skewedCTime) {
+		t.Fatalf("Write() set mtime %v following ctime %v beyond MaxClockSkew", metadata.ModificationTime, skewedCTime)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
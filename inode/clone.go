@@ -0,0 +1,191 @@
+package inode
+
+// File clones
+//
+// CloneFile() creates an unlinked file inode whose contents are those of an existing file inode. Rather than
+// copying any data, the clone's extents reference the very LogSegments referenced by the original. Each
+// LogSegment so shared counts, in its LogSegmentRec, the file inodes referencing it beyond the first (see
+// formatLogSegmentRecValue()). As a file ceases to reference a shared LogSegment (i.e. as it is overwritten,
+// truncated, or destroyed), releaseLogSegmentRec() merely decrements that count. Only once the last file
+// referencing it lets go is the LogSegmentRec deleted and (unless a snapshot retains it) the LogSegment too.
+//
+// As the counts are persisted by the same checkpoint as the inodes referencing the LogSegments, a crash can
+// neither leak nor prematurely delete a shared LogSegment.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// logSegmentRecSharersSeparator separates the container name from the count of additional referencing file
+// inodes in a LogSegmentRec. Being disallowed in Swift container names, it cannot appear in the former.
+const logSegmentRecSharersSeparator = "/"
+
+// formatLogSegmentRecValue returns the LogSegmentRec value recording that the LogSegment resides in
+// containerName and is referenced by sharers file inodes beyond the first. Unshared LogSegments have
+// LogSegmentRecs holding just containerName, as they always have.
+func formatLogSegmentRecValue(containerName string, sharers uint64) (value string) {
+	if 0 == sharers {
+		value = containerName
+	} else {
+		value = containerName + logSegmentRecSharersSeparator + strconv.FormatUint(sharers, 16)
+	}
+	return
+}
+
+func parseLogSegmentRecValue(value string) (containerName string, sharers uint64, err error) {
+	separatorIndex := strings.LastIndex(value, logSegmentRecSharersSeparator)
+	if -1 == separatorIndex {
+		containerName = value
+		return
+	}
+
+	containerName = value[:separatorIndex]
+	sharers, err = strconv.ParseUint(value[separatorIndex+len(logSegmentRecSharersSeparator):], 16, 64)
+	if nil != err {
+		err = blunder.AddError(fmt.Errorf("inode: corrupt LogSegmentRec value \"%s\": %v", value, err), blunder.UnpackError)
+	}
+	return
+}
+
+func (vS *volumeStruct) getLogSegmentRec(logSegmentNumber uint64) (containerName string, sharers uint64, err error) {
+	valueAsByteSlice, err := vS.headhunterVolumeHandle.GetLogSegmentRec(logSegmentNumber)
+	if nil != err {
+		return
+	}
+	containerName, sharers, err = parseLogSegmentRecValue(utils.ByteSliceToString(valueAsByteSlice))
+	return
+}
+
+// shareLogSegment notes that one more file inode references logSegmentNumber.
+func (vS *volumeStruct) shareLogSegment(logSegmentNumber uint64) (err error) {
+	vS.logSegmentRecLock.Lock()
+	defer vS.logSegmentRecLock.Unlock()
+
+	containerName, sharers, err := vS.getLogSegmentRec(logSegmentNumber)
+	if nil != err {
+		return
+	}
+
+	err = vS.headhunterVolumeHandle.PutLogSegmentRec(logSegmentNumber, utils.StringToByteSlice(formatLogSegmentRecValue(containerName, sharers+1)))
+	return
+}
+
+// releaseLogSegmentRec notes that a file inode no longer references logSegmentNumber. If it was the last to
+// do so, the LogSegmentRec is deleted and released is returned true: it is then up to the caller to delete
+// the LogSegment from containerName.
+func (vS *volumeStruct) releaseLogSegmentRec(logSegmentNumber uint64) (containerName string, released bool, err error) {
+	vS.logSegmentRecLock.Lock()
+	defer vS.logSegmentRecLock.Unlock()
+
+	containerName, sharers, err := vS.getLogSegmentRec(logSegmentNumber)
+	if nil != err {
+		return
+	}
+
+	if 0 < sharers {
+		err = vS.headhunterVolumeHandle.PutLogSegmentRec(logSegmentNumber, utils.StringToByteSlice(formatLogSegmentRecValue(containerName, sharers-1)))
+		return
+	}
+
+	err = vS.headhunterVolumeHandle.DeleteLogSegmentRec(logSegmentNumber)
+	if nil != err {
+		return
+	}
+
+	released = true
+	return
+}
+
+func (vS *volumeStruct) CloneFile(fileInodeNumber InodeNumber) (cloneInodeNumber InodeNumber, err error) {
+	if nil != vS.snapshotOf {
+		err = blunder.NewError(blunder.ReadOnlyError, "files of volume '%s' may not be cloned within a snapshot", vS.volumeName)
+		return
+	}
+
+	fileInode, err := vS.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		return
+	}
+
+	// NB: as for Coalesce(), GetReadPlan() flushes any pending writes so that only LogSegments are shared
+	offset := uint64(0)
+	length := fileInode.Size
+	readPlan, err := vS.GetReadPlan(fileInodeNumber, &offset, &length)
+	if nil != err {
+		return
+	}
+
+	cloneInode, err := vS.createFileInode(fileInode.Mode&PosixModePerm, fileInode.UserID, fileInode.GroupID)
+	if nil != err {
+		return
+	}
+
+	fileOffset := uint64(0)
+	for _, step := range readPlan {
+		if (0 != step.LogSegmentNumber) && (0 != step.Length) {
+			err = recordWrite(cloneInode, fileOffset, step.Length, step.LogSegmentNumber, step.Offset)
+			if nil != err {
+				vS.discardClone(cloneInode)
+				return
+			}
+			cloneInode.NumWrites++
+		}
+		fileOffset += step.Length
+	}
+
+	cloneInode.Size = fileInode.Size
+	cloneInode.ModificationTime = fileInode.ModificationTime
+
+	sharedLogSegmentNumbers := make([]uint64, 0, len(cloneInode.LogSegmentMap))
+	for logSegmentNumber := range cloneInode.LogSegmentMap {
+		err = vS.shareLogSegment(logSegmentNumber)
+		if nil != err {
+			vS.unshareLogSegments(sharedLogSegmentNumbers)
+			vS.discardClone(cloneInode)
+			return
+		}
+		sharedLogSegmentNumbers = append(sharedLogSegmentNumbers, logSegmentNumber)
+	}
+
+	err = vS.flushInodes([]*inMemoryInodeStruct{cloneInode})
+	if nil != err {
+		vS.unshareLogSegments(sharedLogSegmentNumbers)
+		vS.discardClone(cloneInode)
+		return
+	}
+
+	cloneInodeNumber = cloneInode.InodeNumber
+	stats.IncrementOperations(&stats.FileCloneOps)
+	return
+}
+
+// unshareLogSegments undoes shareLogSegment() of each of logSegmentNumbers by a CloneFile() that failed.
+func (vS *volumeStruct) unshareLogSegments(logSegmentNumbers []uint64) {
+	for _, logSegmentNumber := range logSegmentNumbers {
+		_, released, err := vS.releaseLogSegmentRec(logSegmentNumber)
+		if nil != err {
+			logger.WarnfWithError(err, "couldn't unshare LogSegment 0x%016X of volume '%s'", logSegmentNumber, vS.volumeName)
+		}
+		if released {
+			logger.Errorf("unsharing LogSegment 0x%016X of volume '%s' deleted its LogSegmentRec", logSegmentNumber, vS.volumeName)
+		}
+	}
+}
+
+// discardClone forgets cloneInode (which has never been flushed) without touching the LogSegments it references.
+func (vS *volumeStruct) discardClone(cloneInode *inMemoryInodeStruct) {
+	_ = cloneInode.payload.(sortedmap.BPlusTree).Discard()
+
+	vS.Lock()
+	delete(vS.inodeCache, cloneInode.InodeNumber)
+	vS.Unlock()
+}
@@ -0,0 +1,133 @@
+package inode
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+func TestCloneFile(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	// CloneFile() must first flush the pending write so as to share its LogSegment
+	cloneInodeNumber, err := testVolumeHandle.CloneFile(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("CloneFile() failed: %v", err)
+	}
+
+	buf, err := testVolumeHandle.Read(cloneInodeNumber, 0, 10, nil)
+	if nil != err {
+		t.Fatalf("Read() of clone failed: %v", err)
+	}
+	if !bytes.Equal([]byte("0123456789"), buf) {
+		t.Fatalf("Read() of clone returned \"%s\"", buf)
+	}
+
+	volume.Lock()
+	cloneInode := volume.inodeCache[cloneInodeNumber]
+	volume.Unlock()
+
+	if 1 != len(cloneInode.LogSegmentMap) {
+		t.Fatalf("expected clone to reference 1 LogSegment, found %v", len(cloneInode.LogSegmentMap))
+	}
+	var sharedLogSegmentNumber uint64
+	for sharedLogSegmentNumber = range cloneInode.LogSegmentMap {
+	}
+
+	containerName, sharers, err := volume.getLogSegmentRec(sharedLogSegmentNumber)
+	if nil != err {
+		t.Fatalf("getLogSegmentRec() failed: %v", err)
+	}
+	if 1 != sharers {
+		t.Fatalf("expected shared LogSegment to have 1 sharer, found %v", sharers)
+	}
+	objectName := fmt.Sprintf("%016X", sharedLogSegmentNumber)
+
+	// Neither overwriting nor truncating the original may delete the shared LogSegment...
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("abcde"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.SetSize(fileInodeNumber, 0)
+	if nil != err {
+		t.Fatalf("SetSize() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	_, sharers, err = volume.getLogSegmentRec(sharedLogSegmentNumber)
+	if nil != err {
+		t.Fatalf("getLogSegmentRec() after original let go failed: %v", err)
+	}
+	if 0 != sharers {
+		t.Fatalf("expected LogSegment referenced only by clone to have 0 sharers, found %v", sharers)
+	}
+
+	buf, err = testVolumeHandle.Read(cloneInodeNumber, 0, 10, nil)
+	if nil != err {
+		t.Fatalf("Read() of clone after original let go failed: %v", err)
+	}
+	if !bytes.Equal([]byte("0123456789"), buf) {
+		t.Fatalf("Read() of clone after original let go returned \"%s\"", buf)
+	}
+
+	// ...while destroying the clone (the last reference) does
+	err = testVolumeHandle.Destroy(cloneInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() of clone failed: %v", err)
+	}
+	volume.drainDestroyQueue()
+
+	_, err = volume.getLogSegmentContainer(sharedLogSegmentNumber)
+	if nil == err {
+		t.Fatalf("expected LogSegmentRec for 0x%016X to have been deleted", sharedLogSegmentNumber)
+	}
+	_, err = swiftclient.ObjectGet(volume.accountName, containerName, objectName, 0, 4)
+	if nil == err {
+		t.Fatalf("expected object GET to fail for deleted log segment object at %s/%s/%s", volume.accountName, containerName, objectName)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
+
+func TestLogSegmentRecValue(t *testing.T) {
+	for _, sharers := range []uint64{0, 1, 0x1F} {
+		value := formatLogSegmentRecValue("Container_0001", sharers)
+		containerName, parsedSharers, err := parseLogSegmentRecValue(value)
+		if nil != err {
+			t.Fatalf("parseLogSegmentRecValue(\"%s\") failed: %v", value, err)
+		}
+		if ("Container_0001" != containerName) || (sharers != parsedSharers) {
+			t.Fatalf("parseLogSegmentRecValue(\"%s\") returned \"%s\", %v", value, containerName, parsedSharers)
+		}
+	}
+	if "Container_0001" != formatLogSegmentRecValue("Container_0001", 0) {
+		t.Fatalf("unshared LogSegmentRec value should be just the container name")
+	}
+
+	_, _, err := parseLogSegmentRecValue("Container_0001/xyz")
+	if nil == err {
+		t.Fatalf("parseLogSegmentRecValue() of corrupt value should have failed")
+	}
+}
@@ -338,3 +338,96 @@ func TestCoalesceDuplicates(t *testing.T) {
 	assert.NotNil(err)
 	assert.True(blunder.Is(err, blunder.InvalidArgError))
 }
+
+func TestCoalesceRanges(t *testing.T) {
+	assert := assert.New(t)
+	vh, err := FetchVolumeHandle("TestVolume")
+	if !assert.Nil(err) {
+		return
+	}
+
+	dirInodeNumber, err := vh.CreateDir(PosixModePerm, 0, 0)
+	if !assert.Nil(err) {
+		return
+	}
+	err = vh.Link(RootDirInodeNumber, "coalesce-rangetest-dir", dirInodeNumber)
+	if !assert.Nil(err) {
+		return
+	}
+
+	file1InodeNumber, err := vh.CreateFile(PosixModePerm, 0, 0)
+	if !assert.Nil(err) {
+		return
+	}
+	err = vh.Write(file1InodeNumber, 0, []byte("abcdefgh"), nil)
+	if !assert.Nil(err) {
+		return
+	}
+	err = vh.Link(dirInodeNumber, "file1", file1InodeNumber)
+	if !assert.Nil(err) {
+		return
+	}
+
+	file2InodeNumber, err := vh.CreateFile(PosixModePerm, 0, 0)
+	if !assert.Nil(err) {
+		return
+	}
+	err = vh.Write(file2InodeNumber, 0, []byte("ijkl"), nil)
+	if !assert.Nil(err) {
+		return
+	}
+	err = vh.Link(dirInodeNumber, "file2", file2InodeNumber)
+	if !assert.Nil(err) {
+		return
+	}
+
+	// A range extending beyond the end of an element is refused (leaving the elements untouched)
+	elements := []CoalesceElement{
+		{
+			ContainingDirectoryInodeNumber: dirInodeNumber,
+			ElementInodeNumber:             file1InodeNumber,
+			ElementName:                    "file1",
+			Offset:                         6,
+			Length:                         3},
+	}
+	_, _, _, err = vh.Coalesce(dirInodeNumber, "combined", elements)
+	assert.True(blunder.Is(err, blunder.InvalidArgError))
+	_, err = vh.Lookup(dirInodeNumber, "file1")
+	assert.Nil(err)
+
+	elements = []CoalesceElement{
+		{
+			ContainingDirectoryInodeNumber: dirInodeNumber,
+			ElementInodeNumber:             file1InodeNumber,
+			ElementName:                    "file1",
+			Offset:                         2,
+			Length:                         3},
+		{
+			ContainingDirectoryInodeNumber: dirInodeNumber,
+			ElementInodeNumber:             file2InodeNumber,
+			ElementName:                    "file2",
+			Offset:                         1}, // through the end
+	}
+	combinedInodeNumber, _, _, err := vh.Coalesce(dirInodeNumber, "combined", elements)
+	if !assert.Nil(err) {
+		return
+	}
+
+	// The new file has just the requested ranges of the old files
+	metadata, err := vh.GetMetadata(combinedInodeNumber)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(uint64(6), metadata.Size)
+	contents, err := vh.Read(combinedInodeNumber, 0, 6, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal([]byte("cdejkl"), contents)
+
+	// The old files are consumed regardless
+	_, err = vh.Lookup(dirInodeNumber, "file1")
+	assert.True(blunder.Is(err, blunder.NotFoundError))
+	_, err = vh.Lookup(dirInodeNumber, "file2")
+	assert.True(blunder.Is(err, blunder.NotFoundError))
+}
@@ -37,6 +37,7 @@ type readCacheElementStruct struct {
 	next         *readCacheElementStruct // nil if MRU element of flowControlStruct.readCache
 	prev         *readCacheElementStruct // nil if LRU element of flowControlStruct.readCache
 	cacheLine    []byte
+	pinCount     uint64 //                      if non-zero, not linked into MRU/LRU list (see pin.go)
 }
 
 type flowControlStruct struct {
@@ -51,6 +52,9 @@ type flowControlStruct struct {
 	readCache          map[readCacheKeyStruct]*readCacheElementStruct
 	readCacheMRU       *readCacheElementStruct
 	readCacheLRU       *readCacheElementStruct
+	readCachePinned    uint64 // number of elements of readCache with non-zero pinCount
+	maxReadahead       uint64 // [<flow-control-section>]ReadaheadMaxCacheLines (see readahead.go)
+	readRangeMinSize   uint64 // [<flow-control-section>]ReadRangeMinSize (see ranged_read.go)
 }
 
 type volumeStruct struct {
@@ -62,6 +66,7 @@ type volumeStruct struct {
 	activePeerPrivateIPAddr        string
 	maxEntriesPerDirNode           uint64
 	maxExtentsPerFileNode          uint64
+	caseInsensitive                bool                                      // [<volume-section>]CaseInsensitive (see casefold.go)
 	physicalContainerLayoutSet     map[string]struct{}                       // key == physicalContainerLayoutStruct.physicalContainerLayoutName
 	physicalContainerNamePrefixSet map[string]struct{}                       // key == physicalContainerLayoutStruct.physicalContainerNamePrefix
 	physicalContainerLayoutMap     map[string]*physicalContainerLayoutStruct // key == physicalContainerLayoutStruct.physicalContainerLayoutName
@@ -69,6 +74,17 @@ type volumeStruct struct {
 	flowControl                    *flowControlStruct
 	headhunterVolumeHandle         headhunter.VolumeHandle
 	inodeCache                     map[InodeNumber]*inMemoryInodeStruct //      key == InodeNumber
+	pinnedInodeMap                 map[InodeNumber]*pinnedInodeStruct   //      key == InodeNumber
+	destroyBatchSize               uint64                               //      [<volume-section>]DestroyBatchSize (see destroy.go)
+	destroyRetryLimit              uint64                               //      [<volume-section>]DestroyRetryLimit (see destroy.go)
+	writeBackBudget                uint64                               //      [<volume-section>]WriteBackBudget (0 == Write() is synchronous; see write_back.go)
+	stagedBytes                    uint64                               //      sum of all file inodes' staged write bytes (see write_back.go)
+	clock                          clockStruct                          //      see clock.go
+	inodePool                      inodePoolStruct                      //      see inode_pool.go
+	destroyQueue                   destroyQueueStruct
+	logSegmentRecLock              sync.Mutex               //          serializes updates of shared LogSegmentRecs (see clone.go)
+	snapshotVolumeMap              map[uint64]*volumeStruct //          key == snapshot ID (see snapshot.go)
+	snapshotOf                     *volumeStruct            //          non-nil for the VolumeHandle of a snapshot
 }
 
 type globalsStruct struct {
@@ -86,6 +102,8 @@ type globalsStruct struct {
 	corruptionDetectedFalseBuf   []byte                        // holds serialized CorruptionDetected == false
 	versionV1Buf                 []byte                        // holds serialized Version            == V1
 	inodeRecDefaultPreambleBuf   []byte                        // holds concatenated corruptionDetectedFalseBuf & versionV1Buf
+	chaosUnlinkFailureRate       uint64                        // set only during testing (see generation.go)
+	chaosDestroyFailureRate      uint64                        // set only during testing (see generation.go)
 }
 
 var globals globalsStruct
@@ -109,6 +127,7 @@ func Up(confMap conf.ConfMap) (err error) {
 		flowControlName                                string
 		flowControlSectionName                         string
 		flowControlWeightSum                           uint64
+		inodePoolSize                                  uint64
 		ok                                             bool
 		peerName                                       string
 		peerNames                                      []string
@@ -200,6 +219,7 @@ func Up(confMap conf.ConfMap) (err error) {
 			physicalContainerNamePrefixSet: make(map[string]struct{}),
 			physicalContainerLayoutMap:     make(map[string]*physicalContainerLayoutStruct),
 			inodeCache:                     make(map[InodeNumber]*inMemoryInodeStruct),
+			pinnedInodeMap:                 make(map[InodeNumber]*pinnedInodeStruct),
 		}
 
 		volume.fsid, err = confMap.FetchOptionValueUint64(volumeSectionName, "FSID")
@@ -262,6 +282,40 @@ func Up(confMap conf.ConfMap) (err error) {
 				return
 			}
 
+			volume.caseInsensitive, err = confMap.FetchOptionValueBool(volumeSectionName, "CaseInsensitive")
+			if nil != err {
+				volume.caseInsensitive = false
+			}
+
+			volume.destroyBatchSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "DestroyBatchSize")
+			if nil != err {
+				volume.destroyBatchSize = defaultDestroyBatchSize
+			}
+			if 0 == volume.destroyBatchSize {
+				err = fmt.Errorf("%s.DestroyBatchSize must be non-zero", volumeSectionName)
+				return
+			}
+
+			volume.destroyRetryLimit, err = confMap.FetchOptionValueUint64(volumeSectionName, "DestroyRetryLimit")
+			if nil != err {
+				volume.destroyRetryLimit = defaultDestroyRetryLimit
+			}
+
+			volume.writeBackBudget, err = confMap.FetchOptionValueUint64(volumeSectionName, "WriteBackBudget")
+			if nil != err {
+				volume.writeBackBudget = 0
+			}
+
+			volume.clock.maxSkew, err = confMap.FetchOptionValueDuration(volumeSectionName, "MaxClockSkew")
+			if nil != err {
+				volume.clock.maxSkew = defaultMaxClockSkew
+			}
+
+			inodePoolSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "InodePoolSize")
+			if nil != err {
+				inodePoolSize = 0
+			}
+
 			// [Case 1] For now, physicalContainerLayoutNameSlice will simply contain only defaultPhysicalContainerLayoutName
 			//
 			// The expectation is that, at some point, multiple container layouts may be supported along with
@@ -367,6 +421,16 @@ func Up(confMap conf.ConfMap) (err error) {
 					return
 				}
 
+				flowControl.maxReadahead, err = confMap.FetchOptionValueUint64(flowControlSectionName, "ReadaheadMaxCacheLines")
+				if nil != err {
+					flowControl.maxReadahead = defaultReadaheadMaxCacheLines
+				}
+
+				flowControl.readRangeMinSize, err = fetchReadRangeMinSize(confMap, flowControlSectionName, flowControl.readCacheLineSize)
+				if nil != err {
+					return
+				}
+
 				globals.flowControlMap[flowControlName] = flowControl
 			}
 
@@ -377,6 +441,8 @@ func Up(confMap conf.ConfMap) (err error) {
 			if nil != err {
 				return
 			}
+
+			volume.configureInodePool(inodePoolSize)
 		}
 
 		globals.volumeMap[volume.volumeName] = volume
@@ -532,6 +598,8 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 
 	for volumeName = range volumesDeletedSet {
 		volume = globals.volumeMap[volumeName]
+		volume.drainDestroyQueue()
+		volume.drainInodePool()
 		volume.flowControl.refCount--
 		if 0 == volume.flowControl.refCount {
 			delete(globals.flowControlMap, volume.flowControl.flowControlName)
@@ -541,6 +609,8 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 
 	for volumeName = range volumesNewlyInactiveSet {
 		volume = globals.volumeMap[volumeName]
+		volume.drainDestroyQueue()
+		volume.drainInodePool()
 		volume.active = false
 		primaryPeerNameList, err = confMap.FetchOptionValueStringSlice(utils.VolumeNameConfSection(volumeName), "PrimaryPeer")
 		if nil != err {
@@ -562,6 +632,7 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 		volume.physicalContainerNamePrefixSet = make(map[string]struct{})
 		volume.physicalContainerLayoutMap = make(map[string]*physicalContainerLayoutStruct)
 		volume.defaultPhysicalContainerLayout = nil
+		volume.unpinAll()
 		volume.flowControl.refCount--
 		if 0 == volume.flowControl.refCount {
 			delete(globals.flowControlMap, volume.flowControl.flowControlName)
@@ -590,6 +661,7 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 		flowControl                                    *flowControlStruct
 		flowControlWeightSum                           uint64
 		fsid                                           uint64
+		inodePoolSize                                  uint64
 		newlyActiveVolumeSet                           map[string]*volumeStruct
 		ok                                             bool
 		peerName                                       string
@@ -717,6 +789,16 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 							return
 						}
 
+						flowControl.maxReadahead, err = confMap.FetchOptionValueUint64(flowControlSectionName, "ReadaheadMaxCacheLines")
+						if nil != err {
+							flowControl.maxReadahead = defaultReadaheadMaxCacheLines
+						}
+
+						flowControl.readRangeMinSize, err = fetchReadRangeMinSize(confMap, flowControlSectionName, flowControl.readCacheLineSize)
+						if nil != err {
+							return
+						}
+
 					} else {
 						err = fmt.Errorf("Volume \"%v\" changed its FlowControl name", volumeName)
 						return
@@ -750,6 +832,7 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 				physicalContainerNamePrefixSet: make(map[string]struct{}),
 				physicalContainerLayoutMap:     make(map[string]*physicalContainerLayoutStruct),
 				inodeCache:                     make(map[InodeNumber]*inMemoryInodeStruct),
+				pinnedInodeMap:                 make(map[InodeNumber]*pinnedInodeStruct),
 			}
 
 			globals.volumeMap[volume.volumeName] = volume
@@ -779,6 +862,40 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 				return
 			}
 
+			volume.caseInsensitive, err = confMap.FetchOptionValueBool(volumeSectionName, "CaseInsensitive")
+			if nil != err {
+				volume.caseInsensitive = false
+			}
+
+			volume.destroyBatchSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "DestroyBatchSize")
+			if nil != err {
+				volume.destroyBatchSize = defaultDestroyBatchSize
+			}
+			if 0 == volume.destroyBatchSize {
+				err = fmt.Errorf("%s.DestroyBatchSize must be non-zero", volumeSectionName)
+				return
+			}
+
+			volume.destroyRetryLimit, err = confMap.FetchOptionValueUint64(volumeSectionName, "DestroyRetryLimit")
+			if nil != err {
+				volume.destroyRetryLimit = defaultDestroyRetryLimit
+			}
+
+			volume.writeBackBudget, err = confMap.FetchOptionValueUint64(volumeSectionName, "WriteBackBudget")
+			if nil != err {
+				volume.writeBackBudget = 0
+			}
+
+			volume.clock.maxSkew, err = confMap.FetchOptionValueDuration(volumeSectionName, "MaxClockSkew")
+			if nil != err {
+				volume.clock.maxSkew = defaultMaxClockSkew
+			}
+
+			inodePoolSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "InodePoolSize")
+			if nil != err {
+				inodePoolSize = 0
+			}
+
 			defaultPhysicalContainerLayoutName, err = confMap.FetchOptionValueString(volumeSectionName, "DefaultPhysicalContainerLayout")
 			if nil != err {
 				return
@@ -878,6 +995,16 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 					return
 				}
 
+				flowControl.maxReadahead, err = confMap.FetchOptionValueUint64(flowControlSectionName, "ReadaheadMaxCacheLines")
+				if nil != err {
+					flowControl.maxReadahead = defaultReadaheadMaxCacheLines
+				}
+
+				flowControl.readRangeMinSize, err = fetchReadRangeMinSize(confMap, flowControlSectionName, flowControl.readCacheLineSize)
+				if nil != err {
+					return
+				}
+
 				globals.flowControlMap[flowControlName] = flowControl
 			}
 
@@ -888,6 +1015,8 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 			if nil != err {
 				return
 			}
+
+			volume.configureInodePool(inodePoolSize)
 		}
 	}
 
@@ -923,6 +1052,13 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 }
 
 func Down() (err error) {
-	err = nil // Nothing to do
+	for _, volume := range globals.volumeMap {
+		if volume.active {
+			volume.drainDestroyQueue()
+			volume.drainInodePool()
+		}
+	}
+
+	err = nil
 	return
 }
@@ -0,0 +1,270 @@
+package inode
+
+// Deferred (asynchronous) Destroy
+//
+// Destroy() synchronously removes the InodeRec of the destroyed inode (so that the namespace
+// change is durable as of the next checkpoint) but hands the remaining work off to a per-volume
+// destroyer goroutine so that latency-sensitive callers (e.g. Unlink() and the removal of obstacles
+// in fs.MiddlewarePutComplete()) need not wait on Swift. The destroyer takes up to
+// [<volume-section>]DestroyBatchSize inodes at a time, flushes any in-flight LogSegments, discards
+// their B+Trees, and deletes their LogSegmentRecs. Once a single checkpoint has made those deletions
+// durable, the Swift DELETEs for the entire batch are issued concurrently. A failed DELETE is retried
+// in a subsequent batch up to [<volume-section>]DestroyRetryLimit times before the LogSegment is
+// abandoned (logged but otherwise leaked).
+//
+// The destroyer goroutine is only running while there is work queued. The pending-destroy backlog
+// is reported via stats as InodeDestroyQueuedOps less InodeDestroyDoneOps.
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+const (
+	defaultDestroyBatchSize  = uint64(100)
+	defaultDestroyRetryLimit = uint64(5)
+
+	destroyRetryDelay = time.Second // pause before a batch containing only retries
+)
+
+type pendingLogSegmentDeleteStruct struct {
+	logSegmentNumber uint64
+	containerName    string
+	attempts         uint64
+}
+
+type destroyQueueStruct struct {
+	sync.Mutex
+	daemonRunning        bool
+	draining             bool                             // if true, force (rather than await) checkpoints
+	awaitingCheckpoint   bool                             // if true, destroyer is blocked on a checkpointDoneWaitGroup
+	pendingInodes        []*inMemoryInodeStruct           // inodes whose InodeRec has already been deleted
+	pendingDeletes       []*pendingLogSegmentDeleteStruct // failed Swift DELETEs awaiting retry
+	outstandingWaitGroup sync.WaitGroup                   // one count per element of pendingInodes & pendingDeletes (and those in progress)
+}
+
+// queueDestroy hands off a destroyed inode to the volume's destroyer goroutine (starting it if necessary).
+func (vS *volumeStruct) queueDestroy(destroyedInode *inMemoryInodeStruct) {
+	dQ := &vS.destroyQueue
+
+	dQ.Lock()
+	dQ.outstandingWaitGroup.Add(1)
+	dQ.pendingInodes = append(dQ.pendingInodes, destroyedInode)
+	if !dQ.daemonRunning {
+		dQ.daemonRunning = true
+		go vS.destroyDaemon()
+	}
+	dQ.Unlock()
+
+	stats.IncrementOperations(&stats.InodeDestroyQueuedOps)
+}
+
+// drainDestroyQueue waits for all queued destroys (including retries) to complete. Rather than
+// waiting for the next scheduled checkpoint, checkpoints are forced while draining.
+func (vS *volumeStruct) drainDestroyQueue() {
+	dQ := &vS.destroyQueue
+
+	dQ.Lock()
+	dQ.draining = true
+	kickCheckpoint := dQ.awaitingCheckpoint
+	dQ.Unlock()
+
+	if kickCheckpoint {
+		err := vS.headhunterVolumeHandle.DoCheckpoint()
+		if nil != err {
+			logger.ErrorfWithError(err, "inode.drainDestroyQueue(): volume '%s' DoCheckpoint() failed", vS.volumeName)
+		}
+	}
+
+	dQ.outstandingWaitGroup.Wait()
+
+	dQ.Lock()
+	dQ.draining = false
+	dQ.Unlock()
+}
+
+func (vS *volumeStruct) destroyDaemon() {
+	var (
+		batchInodes  []*inMemoryInodeStruct
+		batchDeletes []*pendingLogSegmentDeleteStruct
+		batchRetries int
+		batchSize    int
+		draining     bool
+	)
+
+	dQ := &vS.destroyQueue
+
+	for {
+		dQ.Lock()
+
+		if (0 == len(dQ.pendingInodes)) && (0 == len(dQ.pendingDeletes)) {
+			dQ.daemonRunning = false
+			dQ.Unlock()
+			return
+		}
+
+		if (0 == len(dQ.pendingInodes)) && !dQ.draining {
+			// Only retries remain... so give Swift a moment before trying again
+			dQ.Unlock()
+			time.Sleep(destroyRetryDelay)
+			dQ.Lock()
+		}
+
+		batchSize = int(vS.destroyBatchSize)
+		if batchSize > len(dQ.pendingInodes) {
+			batchSize = len(dQ.pendingInodes)
+		}
+		batchInodes = dQ.pendingInodes[:batchSize]
+		dQ.pendingInodes = dQ.pendingInodes[batchSize:]
+		batchDeletes = dQ.pendingDeletes
+		batchRetries = len(batchDeletes)
+		dQ.pendingDeletes = nil
+
+		dQ.Unlock()
+
+		for _, destroyedInode := range batchInodes {
+			batchDeletes = append(batchDeletes, vS.destroyInodePayload(destroyedInode)...)
+		}
+
+		// Ensure the LogSegmentRec deletions are durable before removing the LogSegments themselves
+
+		dQ.Lock()
+		draining = dQ.draining
+		if draining {
+			dQ.Unlock()
+			err := vS.headhunterVolumeHandle.DoCheckpoint()
+			if nil != err {
+				logger.ErrorfWithError(err, "inode.destroyDaemon(): volume '%s' DoCheckpoint() failed", vS.volumeName)
+			}
+		} else {
+			dQ.awaitingCheckpoint = true
+			checkpointDoneWaitGroup := vS.headhunterVolumeHandle.FetchNextCheckPointDoneWaitGroup()
+			dQ.Unlock()
+			checkpointDoneWaitGroup.Wait()
+			dQ.Lock()
+			dQ.awaitingCheckpoint = false
+			dQ.Unlock()
+		}
+
+		vS.deleteLogSegmentBatch(batchDeletes)
+
+		dQ.outstandingWaitGroup.Add(-batchRetries)
+
+		for range batchInodes {
+			dQ.outstandingWaitGroup.Done()
+			stats.IncrementOperations(&stats.InodeDestroyDoneOps)
+		}
+	}
+}
+
+// destroyInodePayload performs the deferred portion of Destroy() other than Swift DELETEs, returning
+// the LogSegments (if any) that should now be deleted.
+func (vS *volumeStruct) destroyInodePayload(destroyedInode *inMemoryInodeStruct) (logSegmentDeletes []*pendingLogSegmentDeleteStruct) {
+	switch destroyedInode.InodeType {
+	case DirType:
+		dirMapping := destroyedInode.payload.(sortedmap.BPlusTree)
+
+		err := dirMapping.Discard()
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+
+		stats.IncrementOperations(&stats.DirDestroyOps)
+	case FileType:
+		_ = vS.doFileInodeDataFlush(destroyedInode)
+
+		extents := destroyedInode.payload.(sortedmap.BPlusTree)
+
+		err := extents.Discard()
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+
+		logSegmentDeletes = make([]*pendingLogSegmentDeleteStruct, 0, len(destroyedInode.LogSegmentMap))
+
+		for logSegmentNumber := range destroyedInode.LogSegmentMap {
+			containerName, released, releaseLogSegmentRecErr := vS.releaseLogSegmentRec(logSegmentNumber)
+			if nil != releaseLogSegmentRecErr {
+				logger.WarnfWithError(releaseLogSegmentRecErr, "couldn't delete destroy'd log segment")
+				continue
+			}
+			if !released {
+				stats.IncrementOperations(&stats.GcLogSegSharedOps)
+				continue // deleted once no other file references it (see clone.go)
+			}
+			if vS.headhunterVolumeHandle.SnapshotRetainsLogSegment(logSegmentNumber, containerName) {
+				continue // deleted once no snapshot references it
+			}
+			logSegmentDeletes = append(logSegmentDeletes, &pendingLogSegmentDeleteStruct{
+				logSegmentNumber: logSegmentNumber,
+				containerName:    containerName,
+				attempts:         0,
+			})
+		}
+		stats.IncrementOperations(&stats.GcLogSegOps)
+
+		stats.IncrementOperations(&stats.FileDestroyOps)
+	case SymlinkType:
+		stats.IncrementOperations(&stats.SymlinkDestroyOps)
+	default: // FIFOType or SocketType
+		stats.IncrementOperations(&stats.SpecialDestroyOps)
+	}
+
+	return
+}
+
+// deleteLogSegmentBatch concurrently issues the Swift DELETEs for a batch of LogSegments, queueing
+// those that fail for retry.
+func (vS *volumeStruct) deleteLogSegmentBatch(logSegmentDeletes []*pendingLogSegmentDeleteStruct) {
+	var (
+		deleteWaitGroup sync.WaitGroup
+		retryDeletes    []*pendingLogSegmentDeleteStruct
+		retryLock       sync.Mutex
+	)
+
+	for _, logSegmentDelete := range logSegmentDeletes {
+		deleteWaitGroup.Add(1)
+		go func(logSegmentDelete *pendingLogSegmentDeleteStruct) {
+			defer deleteWaitGroup.Done()
+
+			objectName := fmt.Sprintf("%016X", logSegmentDelete.logSegmentNumber)
+			err := swiftclient.ObjectDeleteSync(vS.accountName, logSegmentDelete.containerName, objectName)
+			if (nil == err) || blunder.Is(err, blunder.NotFoundError) {
+				stats.IncrementOperations(&stats.GcLogSegDeleteOps)
+				return
+			}
+
+			logSegmentDelete.attempts++
+			if logSegmentDelete.attempts > vS.destroyRetryLimit {
+				logger.ErrorfWithError(err, "inode.deleteLogSegmentBatch(): volume '%s' abandoning delete of %s/%s", vS.volumeName, logSegmentDelete.containerName, objectName)
+				return
+			}
+
+			stats.IncrementOperations(&stats.InodeDestroyRetryOps)
+
+			retryLock.Lock()
+			retryDeletes = append(retryDeletes, logSegmentDelete)
+			retryLock.Unlock()
+		}(logSegmentDelete)
+	}
+
+	deleteWaitGroup.Wait()
+
+	if 0 < len(retryDeletes) {
+		dQ := &vS.destroyQueue
+		dQ.Lock()
+		dQ.outstandingWaitGroup.Add(len(retryDeletes))
+		dQ.pendingDeletes = append(dQ.pendingDeletes, retryDeletes...)
+		dQ.Unlock()
+	}
+}
@@ -0,0 +1,86 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+func TestDeferredDestroy(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	ino, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Leave the last write unflushed so that Destroy() must also dispose of an in-flight LogSegment
+	for i := 0; i < 3; i++ {
+		err = testVolumeHandle.Write(ino, uint64(i*4), []byte{0x00, 0x01, 0x02, 0x03}, nil)
+		if nil != err {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		if i < 2 {
+			err = testVolumeHandle.Flush(ino, false)
+			if nil != err {
+				t.Fatalf("Flush() failed: %v", err)
+			}
+		}
+	}
+
+	volume.Lock()
+	ourInode := volume.inodeCache[ino]
+	volume.Unlock()
+
+	segmentObjectLocations := make([]testObjectLocationStruct, 0, len(ourInode.LogSegmentMap))
+	for segmentNumber := range ourInode.LogSegmentMap {
+		containerName, objectName, _, getObjectLocationErr := volume.getObjectLocationFromLogSegmentNumber(segmentNumber)
+		if nil != getObjectLocationErr {
+			t.Fatalf("expected to be able to get log segment 0x%016X", segmentNumber)
+		}
+		segmentObjectLocations = append(segmentObjectLocations, testObjectLocationStruct{volume.accountName, containerName, objectName})
+	}
+	if 3 != len(segmentObjectLocations) {
+		t.Fatalf("expected 3 log segments (2 flushed & 1 in-flight), found %v", len(segmentObjectLocations))
+	}
+
+	err = testVolumeHandle.Destroy(ino)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+
+	// The namespace change is immediate...
+	_, err = testVolumeHandle.GetMetadata(ino)
+	if nil == err {
+		t.Fatalf("GetMetadata() of destroyed inode should have failed")
+	}
+
+	// ...while the LogSegments are removed once the destroyer has finished
+	volume.drainDestroyQueue()
+
+	volume.destroyQueue.Lock()
+	if (0 != len(volume.destroyQueue.pendingInodes)) || volume.destroyQueue.daemonRunning {
+		volume.destroyQueue.Unlock()
+		t.Fatalf("drainDestroyQueue() returned with work still pending")
+	}
+	volume.destroyQueue.Unlock()
+
+	for segmentNumber := range ourInode.LogSegmentMap {
+		_, getLogSegmentContainerErr := volume.getLogSegmentContainer(segmentNumber)
+		if nil == getLogSegmentContainerErr {
+			t.Fatalf("expected LogSegmentRec for 0x%016X to have been deleted", segmentNumber)
+		}
+	}
+
+	for _, segmentObjectLocation := range segmentObjectLocations {
+		_, err = swiftclient.ObjectGet(segmentObjectLocation.accountName, segmentObjectLocation.containerName, segmentObjectLocation.objectName, 0, 4)
+		if nil == err {
+			t.Fatalf("expected object GET to fail for deleted log segment object at %s/%s/%s", segmentObjectLocation.accountName, segmentObjectLocation.containerName, segmentObjectLocation.objectName)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package inode
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/swiftstack/sortedmap"
 
@@ -43,13 +42,13 @@ func (vS *volumeStruct) createRootOrSubDir(filePerm InodeMode, userID InodeUserI
 			&dirInodeCallbacks{treeNodeLoadable{inode: dirInode}},
 			globals.dirEntryCache)
 
-	ok, err := dirMapping.Put(".", dirInode.InodeNumber)
+	ok, err := dirMapping.Put(".", makeDirEntryValue(dirInode.InodeNumber, DirType))
 	if (nil != err) || (!ok) {
 		panic(err)
 	}
 
 	if isRootDir {
-		ok, err = dirMapping.Put("..", dirInode.InodeNumber)
+		ok, err = dirMapping.Put("..", makeDirEntryValue(dirInode.InodeNumber, DirType))
 		if (nil != err) || (!ok) {
 			panic(err)
 		}
@@ -102,7 +101,17 @@ func addDirEntryInMemory(dirInode *inMemoryInodeStruct, targetInode *inMemoryIno
 
 	dirMapping := dirInode.payload.(sortedmap.BPlusTree)
 
-	ok, err := dirMapping.Put(basename, targetInode.InodeNumber)
+	storedBasename, err := dirInode.volume.resolveBasename(dirInode, basename)
+	if nil != err {
+		panic(err)
+	}
+	if storedBasename != basename {
+		err = fmt.Errorf("%s: failed to create link '%v' to inode %v in directory inode %v: entry '%v' exists",
+			utils.GetFnName(), basename, targetInode.InodeNumber, dirInode.InodeNumber, storedBasename)
+		return blunder.AddError(err, blunder.FileExistsError)
+	}
+
+	ok, err := dirMapping.Put(basename, makeDirEntryValue(targetInode.InodeNumber, targetInode.InodeType))
 	if nil != err {
 		panic(err)
 	}
@@ -112,18 +121,22 @@ func addDirEntryInMemory(dirInode *inMemoryInodeStruct, targetInode *inMemoryIno
 		return blunder.AddError(err, blunder.FileExistsError)
 	}
 
-	updateTime := time.Now()
+	noteDirEntryAdded(dirInode, basename)
+
+	updateTime := dirInode.volume.timestamp(dirInode, targetInode)
 
 	targetInode.LinkCount++
 	targetInode.AttrChangeTime = updateTime
+	targetInode.ChangeCount++
 
 	if targetInode.InodeType == DirType && targetInode.InodeNumber != RootDirInodeNumber {
 		subdirMapping := targetInode.payload.(sortedmap.BPlusTree)
-		subdirMapping.Put("..", dirInode.InodeNumber)
+		subdirMapping.Put("..", makeDirEntryValue(dirInode.InodeNumber, DirType))
 		dirInode.LinkCount++
 	}
 
 	dirInode.AttrChangeTime = updateTime
+	dirInode.ChangeCount++
 	dirInode.ModificationTime = updateTime
 	return nil
 }
@@ -165,6 +178,10 @@ func (vS *volumeStruct) Link(dirInodeNumber InodeNumber, basename string, target
 		}
 	}
 
+	// Callers may hold but a shared lock on dirInode (see fs.getDirEntryLock())
+	dirInode.entryMutex.Lock()
+	defer dirInode.entryMutex.Unlock()
+
 	err = addDirEntryInMemory(dirInode, targetInode, basename)
 	if err != nil {
 		return err
@@ -196,6 +213,8 @@ func removeDirEntryInMemory(dirInode *inMemoryInodeStruct, untargetInode *inMemo
 		panic(err)
 	}
 
+	noteDirEntryRemoved(dirInode, basename)
+
 	untargetInode.LinkCount--
 
 	if DirType == untargetInode.InodeType {
@@ -213,12 +232,14 @@ func removeDirEntryInMemory(dirInode *inMemoryInodeStruct, untargetInode *inMemo
 		dirInode.LinkCount--
 	}
 
-	updateTime := time.Now()
+	updateTime := dirInode.volume.timestamp(dirInode, untargetInode)
 
 	dirInode.AttrChangeTime = updateTime
+	dirInode.ChangeCount++
 	dirInode.ModificationTime = updateTime
 
 	untargetInode.AttrChangeTime = updateTime
+	untargetInode.ChangeCount++
 	return
 }
 
@@ -230,6 +251,15 @@ func (vS *volumeStruct) Unlink(dirInodeNumber InodeNumber, basename string) (err
 		return err
 	}
 
+	// Callers may hold but a shared lock on dirInode (see fs.getDirEntryLock())
+	dirInode.entryMutex.Lock()
+	defer dirInode.entryMutex.Unlock()
+
+	basename, err = vS.resolveBasename(dirInode, basename)
+	if nil != err {
+		return err
+	}
+
 	untargetInodeNumber, err := vS.Lookup(dirInodeNumber, basename)
 	if nil != err {
 		err = blunder.AddError(err, blunder.NotFoundError)
@@ -271,13 +301,24 @@ func (vS *volumeStruct) Unlink(dirInodeNumber InodeNumber, basename string) (err
 		return err
 	}
 
+	err = injectUnlinkFailure() // see generation.go
+	if nil != err {
+		return err
+	}
+
 	stats.IncrementOperations(&stats.DirUnlinkSuccessOps)
 	return
 }
 
-func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string) (err error) {
+func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string, flags MoveFlags) (err error) {
 	stats.IncrementOperations(&stats.DirRenameOps)
 
+	if (MoveNoReplace | MoveExchange) == (flags & (MoveNoReplace | MoveExchange)) {
+		err = fmt.Errorf("%v: MoveNoReplace & MoveExchange are mutually exclusive", utils.GetFnName())
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
 	srcDirInode, err := vS.fetchInodeType(srcDirInodeNumber, DirType)
 	if nil != err {
 		logger.ErrorfWithError(err, "Move(): srcDirInode fetch error")
@@ -285,6 +326,11 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 	}
 	srcDirMapping := srcDirInode.payload.(sortedmap.BPlusTree)
 
+	srcBasename, err = vS.resolveBasename(srcDirInode, srcBasename)
+	if nil != err {
+		panic(err)
+	}
+
 	var dstDirInode *inMemoryInodeStruct
 	var dstDirMapping sortedmap.BPlusTree
 	if srcDirInodeNumber == dstDirInodeNumber {
@@ -315,7 +361,7 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		err = blunder.AddError(err, blunder.NotFoundError)
 		return
 	}
-	srcInodeNumber := srcInodeNumberAsValue.(InodeNumber)
+	srcInodeNumber := srcInodeNumberAsValue.(dirEntryValue).inodeNumber()
 
 	srcInode, ok, err := vS.fetchInode(srcInodeNumber)
 	if nil != err {
@@ -334,17 +380,29 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		return err
 	}
 
+	// If case-insensitive, dstBasename may match an existing entry stored with different case. Unless that
+	// entry is the source itself (i.e. a case-only rename), the existing entry is replaced by dstBasename.
+
+	storedDstBasename, err := vS.resolveBasename(dstDirInode, dstBasename)
+	if nil != err {
+		panic(err)
+	}
+	caseOnlyRename := (srcDirInodeNumber == dstDirInodeNumber) && (srcBasename == storedDstBasename)
+
 	var dstInodeNumber InodeNumber
 	var dstInode *inMemoryInodeStruct
-	dstInodeNumberAsValue, ok, err := dstDirMapping.GetByKey(dstBasename)
+	dstInodeNumberAsValue, ok, err := dstDirMapping.GetByKey(storedDstBasename)
 	if nil != err {
 		// this indicates disk corruption or software bug
 		logger.ErrorfWithError(err, "%s: dstDirInode GetByKey(%s) error inode %d volume '%s'",
 			utils.GetFnName(), dstBasename, dstDirInode.InodeNumber, vS.volumeName)
 		panic(err)
 	}
+	if caseOnlyRename {
+		ok = false
+	}
 	if ok {
-		dstInodeNumber = dstInodeNumberAsValue.(InodeNumber)
+		dstInodeNumber = dstInodeNumberAsValue.(dirEntryValue).inodeNumber()
 
 		dstInode, ok, err = vS.fetchInode(dstInodeNumber)
 		if nil != err {
@@ -366,6 +424,25 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		dstInode = nil
 	}
 
+	if (0 != flags&MoveNoReplace) && (nil != dstInode) {
+		err = fmt.Errorf("%v: Target of Move() exists: %v/%v", utils.GetFnName(), dstDirInodeNumber, dstBasename)
+		err = blunder.AddError(err, blunder.FileExistsError)
+		return
+	}
+
+	if 0 != flags&MoveExchange {
+		if nil == dstInode {
+			if caseOnlyRename {
+				return // exchanging an entry with itself
+			}
+			err = fmt.Errorf("%v: Target of exchanging Move() does not exist: %v/%v", utils.GetFnName(), dstDirInodeNumber, dstBasename)
+			err = blunder.AddError(err, blunder.NotFoundError)
+			return
+		}
+		err = vS.moveExchange(srcDirInode, srcBasename, srcInode, dstDirInode, storedDstBasename, dstInode)
+		return
+	}
+
 	// I believe this is allowed so long at the dstInode is empty --craig
 	if (nil != dstInode) && (DirType == dstInode.InodeType) {
 		err = fmt.Errorf("%v: Target of Move() is an existing directory: %v/%v", utils.GetFnName(), dstDirInodeNumber, dstBasename)
@@ -393,18 +470,20 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		}
 	}
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(srcDirInode, dstDirInode, srcInode, dstInode)
 
 	inodes := make([]*inMemoryInodeStruct, 0, 4)
 
 	srcDirInode.dirty = true
 	srcDirInode.AttrChangeTime = updateTime
+	srcDirInode.ChangeCount++
 	srcDirInode.ModificationTime = updateTime
 	inodes = append(inodes, srcDirInode)
 
 	if srcDirInodeNumber != dstDirInodeNumber {
 		dstDirInode.dirty = true
 		dstDirInode.AttrChangeTime = updateTime
+		dstDirInode.ChangeCount++
 		dstDirInode.ModificationTime = updateTime
 		inodes = append(inodes, dstDirInode)
 
@@ -413,7 +492,7 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 			dstDirInode.LinkCount++
 
 			srcInodeAsDirMapping := srcInode.payload.(sortedmap.BPlusTree)
-			ok, err = srcInodeAsDirMapping.PatchByKey("..", dstDirInodeNumber)
+			ok, err = srcInodeAsDirMapping.PatchByKey("..", makeDirEntryValue(dstDirInodeNumber, DirType))
 			if nil != err {
 				logger.ErrorfWithError(err, "Move(): srcInode PatchByKey error")
 				panic(err)
@@ -428,6 +507,7 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 
 	srcInode.dirty = true
 	srcInode.AttrChangeTime = updateTime
+	srcInode.ChangeCount++
 	inodes = append(inodes, srcInode)
 
 	ok, err = srcDirMapping.DeleteByKey(srcBasename)
@@ -441,8 +521,10 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		panic(err)
 	}
 
+	noteDirEntryRemoved(srcDirInode, srcBasename)
+
 	if nil == dstInode {
-		ok, err = dstDirMapping.Put(dstBasename, srcInodeNumber)
+		ok, err = dstDirMapping.Put(dstBasename, makeDirEntryValue(srcInodeNumber, srcInode.InodeType))
 		if nil != err {
 			logger.ErrorfWithError(err, "Move(): dstDirInode Put error")
 			panic(err)
@@ -455,20 +537,164 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 	} else {
 		dstInode.dirty = true
 		dstInode.AttrChangeTime = updateTime
+		dstInode.ChangeCount++
 		inodes = append(inodes, dstInode)
 
 		dstInode.LinkCount--
 
-		ok, err = dstDirMapping.PatchByKey(dstBasename, srcInodeNumber)
-		if nil != err {
-			logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
-			panic(err)
+		if storedDstBasename == dstBasename {
+			ok, err = dstDirMapping.PatchByKey(dstBasename, makeDirEntryValue(srcInodeNumber, srcInode.InodeType))
+			if nil != err {
+				logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
+				panic(err)
+			}
+			if !ok {
+				err = fmt.Errorf("Should have been able to PatchByKey \"%v\" entry", dstBasename)
+				logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
+				panic(err)
+			}
+		} else {
+			ok, err = dstDirMapping.DeleteByKey(storedDstBasename)
+			if nil != err {
+				logger.ErrorfWithError(err, "Move(): dstDirInode DeleteByKey error")
+				panic(err)
+			}
+			if !ok {
+				err = fmt.Errorf("Should have found \"%v\" entry", storedDstBasename)
+				logger.ErrorfWithError(err, "Move(): dstDirInode DeleteByKey error")
+				panic(err)
+			}
+			ok, err = dstDirMapping.Put(dstBasename, makeDirEntryValue(srcInodeNumber, srcInode.InodeType))
+			if nil != err {
+				logger.ErrorfWithError(err, "Move(): dstDirInode Put error")
+				panic(err)
+			}
+			if !ok {
+				err = fmt.Errorf("Should have been able to PUT \"%v\" entry", dstBasename)
+				logger.ErrorfWithError(err, "Move(): dstDirInode Put error")
+				panic(err)
+			}
 		}
-		if !ok {
-			err = fmt.Errorf("Should have been able to PatchByKey \"%v\" entry", dstBasename)
-			logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
-			panic(err)
+	}
+
+	noteDirEntryAdded(dstDirInode, dstBasename)
+
+	// Finally flush the multi-inode transaction
+
+	err = vS.flushInodes(inodes)
+	if err != nil {
+		logger.ErrorfWithError(err, "flushInodes(%v) error", inodes)
+		panic(err)
+	}
+
+	stats.IncrementOperations(&stats.DirRenameSuccessOps)
+	return
+}
+
+// moveExchange completes an exchanging Move() of existing entries srcBasename & dstBasename.
+//
+// Both names remain in place... only the inodes they reference (and, for directories, their ".." entries) change.
+func (vS *volumeStruct) moveExchange(srcDirInode *inMemoryInodeStruct, srcBasename string, srcInode *inMemoryInodeStruct, dstDirInode *inMemoryInodeStruct, dstBasename string, dstInode *inMemoryInodeStruct) (err error) {
+	var ok bool
+
+	if srcInode.InodeNumber == dstInode.InodeNumber {
+		// Both names already reference the same inode (e.g. they are hard links)
+		stats.IncrementOperations(&stats.DirRenameSuccessOps)
+		return
+	}
+
+	for _, exchangedInode := range []*inMemoryInodeStruct{srcInode, dstInode} {
+		if FileType == exchangedInode.InodeType {
+			// Pre-flush exchangedInode so that no time-based (implicit) flushes will occur during this transaction
+			err = vS.flushInode(exchangedInode)
+			if err != nil {
+				logger.ErrorfWithError(err, "Move(): exchanged inode flush error")
+				panic(err)
+			}
+		}
+	}
+
+	updateTime := vS.timestamp(srcDirInode, dstDirInode, srcInode, dstInode)
+
+	inodes := make([]*inMemoryInodeStruct, 0, 4)
+
+	srcDirInode.dirty = true
+	srcDirInode.AttrChangeTime = updateTime
+	srcDirInode.ChangeCount++
+	srcDirInode.ModificationTime = updateTime
+	inodes = append(inodes, srcDirInode)
+
+	if srcDirInode.InodeNumber != dstDirInode.InodeNumber {
+		dstDirInode.dirty = true
+		dstDirInode.AttrChangeTime = updateTime
+		dstDirInode.ChangeCount++
+		dstDirInode.ModificationTime = updateTime
+		inodes = append(inodes, dstDirInode)
+
+		// Each directory being exchanged moves to the other parent
+
+		if DirType == srcInode.InodeType {
+			srcDirInode.LinkCount--
+			dstDirInode.LinkCount++
+
+			ok, err = srcInode.payload.(sortedmap.BPlusTree).PatchByKey("..", makeDirEntryValue(dstDirInode.InodeNumber, DirType))
+			if nil != err {
+				logger.ErrorfWithError(err, "Move(): srcInode PatchByKey error")
+				panic(err)
+			}
+			if !ok {
+				err = fmt.Errorf("Should have found \"..\" entry")
+				logger.ErrorfWithError(err, "Move(): srcInode PatchByKey error")
+				panic(err)
+			}
 		}
+		if DirType == dstInode.InodeType {
+			dstDirInode.LinkCount--
+			srcDirInode.LinkCount++
+
+			ok, err = dstInode.payload.(sortedmap.BPlusTree).PatchByKey("..", makeDirEntryValue(srcDirInode.InodeNumber, DirType))
+			if nil != err {
+				logger.ErrorfWithError(err, "Move(): dstInode PatchByKey error")
+				panic(err)
+			}
+			if !ok {
+				err = fmt.Errorf("Should have found \"..\" entry")
+				logger.ErrorfWithError(err, "Move(): dstInode PatchByKey error")
+				panic(err)
+			}
+		}
+	}
+
+	srcInode.dirty = true
+	srcInode.AttrChangeTime = updateTime
+	srcInode.ChangeCount++
+	inodes = append(inodes, srcInode)
+
+	dstInode.dirty = true
+	dstInode.AttrChangeTime = updateTime
+	dstInode.ChangeCount++
+	inodes = append(inodes, dstInode)
+
+	ok, err = srcDirInode.payload.(sortedmap.BPlusTree).PatchByKey(srcBasename, makeDirEntryValue(dstInode.InodeNumber, dstInode.InodeType))
+	if nil != err {
+		logger.ErrorfWithError(err, "Move(): srcDirInode PatchByKey error")
+		panic(err)
+	}
+	if !ok {
+		err = fmt.Errorf("Should have been able to PatchByKey \"%v\" entry", srcBasename)
+		logger.ErrorfWithError(err, "Move(): srcDirInode PatchByKey error")
+		panic(err)
+	}
+
+	ok, err = dstDirInode.payload.(sortedmap.BPlusTree).PatchByKey(dstBasename, makeDirEntryValue(srcInode.InodeNumber, srcInode.InodeType))
+	if nil != err {
+		logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
+		panic(err)
+	}
+	if !ok {
+		err = fmt.Errorf("Should have been able to PatchByKey \"%v\" entry", dstBasename)
+		logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
+		panic(err)
 	}
 
 	// Finally flush the multi-inode transaction
@@ -492,6 +718,11 @@ func (vS *volumeStruct) Lookup(dirInodeNumber InodeNumber, basename string) (tar
 		return 0, err
 	}
 
+	basename, err = vS.resolveBasename(dirInode, basename)
+	if nil != err {
+		panic(err)
+	}
+
 	dirMapping := dirInode.payload.(sortedmap.BPlusTree)
 	value, ok, err := dirMapping.GetByKey(basename)
 	if nil != err {
@@ -503,11 +734,50 @@ func (vS *volumeStruct) Lookup(dirInodeNumber InodeNumber, basename string) (tar
 		// Not logging any errors here; let the caller decide if this is log-worthy
 		return 0, blunder.AddError(err, blunder.NotFoundError)
 	}
-	targetInodeNumber = value.(InodeNumber)
+	targetInodeNumber = value.(dirEntryValue).inodeNumber()
 
 	return targetInodeNumber, nil
 }
 
+// LocateDirEntry returns the InodeDirLocation of basename within dirInodeNumber along with the InodeNumber it references.
+func (vS *volumeStruct) LocateDirEntry(dirInodeNumber InodeNumber, basename string) (location InodeDirLocation, targetInodeNumber InodeNumber, err error) {
+	dirInode, err := vS.fetchInodeType(dirInodeNumber, DirType)
+	if nil != err {
+		return
+	}
+
+	basename, err = vS.resolveBasename(dirInode, basename)
+	if nil != err {
+		return
+	}
+
+	dirMapping := dirInode.payload.(sortedmap.BPlusTree)
+	index, found, err := dirMapping.BisectLeft(basename)
+	if nil != err {
+		err = blunder.AddError(err, blunder.IOError)
+		return
+	}
+	if !found {
+		err = fmt.Errorf("%v: unable to find basename %v in dirInode %v", utils.GetFnName(), basename, dirInodeNumber)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+	_, value, ok, err := dirMapping.GetByIndex(index)
+	if nil != err {
+		err = blunder.AddError(err, blunder.IOError)
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%v: unable to fetch index %v of dirInode %v", utils.GetFnName(), index, dirInodeNumber)
+		err = blunder.AddError(err, blunder.IOError)
+		return
+	}
+
+	location = InodeDirLocation(index)
+	targetInodeNumber = value.(dirEntryValue).inodeNumber()
+	return
+}
+
 func (vS *volumeStruct) NumDirEntries(dirInodeNumber InodeNumber) (numEntries uint64, err error) {
 	inode, err := vS.fetchInodeType(dirInodeNumber, DirType)
 	if nil != err {
@@ -527,6 +797,27 @@ func (vS *volumeStruct) NumDirEntries(dirInodeNumber InodeNumber) (numEntries ui
 	return
 }
 
+// FlushDir returns once all completed modifications to dirInodeNumber are durable.
+//
+// Each such modification has already been written through to headhunter (see flushInodes()), so it
+// suffices to await a checkpoint begun after this call. Note that this covers the entire volume.
+func (vS *volumeStruct) FlushDir(dirInodeNumber InodeNumber) (err error) {
+	_, err = vS.fetchInodeType(dirInodeNumber, DirType)
+	if nil != err {
+		return
+	}
+
+	err = vS.headhunterVolumeHandle.DoCheckpoint()
+	if nil != err {
+		err = blunder.AddError(err, blunder.InodeFlushError)
+		return
+	}
+
+	stats.IncrementOperations(&stats.DirFlushOps)
+
+	return
+}
+
 // A maxEntries or maxBufSize argument of zero is interpreted to mean "no maximum".
 func (vS *volumeStruct) ReadDir(dirInodeNumber InodeNumber, maxEntries uint64, maxBufSize uint64, prevReturned ...interface{}) (dirEntries []DirEntry, moreEntries bool, err error) {
 	var (
@@ -545,7 +836,7 @@ func (vS *volumeStruct) ReadDir(dirInodeNumber InodeNumber, maxEntries uint64, m
 
 	stats.IncrementOperations(&stats.DirReaddirOps)
 
-	dirEntries = make([]DirEntry, 0, int(maxEntries))
+	dirEntries = make([]DirEntry, 0)
 	moreEntries = false
 
 	inode, err = vS.fetchInodeType(dirInodeNumber, DirType)
@@ -566,6 +857,13 @@ func (vS *volumeStruct) ReadDir(dirInodeNumber InodeNumber, maxEntries uint64, m
 		return
 	}
 
+	// Preallocate no more than the directory could return (maxEntries may be absurdly large)
+	if (0 < maxEntries) && (maxEntries < uint64(dirMappingLen)) {
+		dirEntries = make([]DirEntry, 0, maxEntries)
+	} else {
+		dirEntries = make([]DirEntry, 0, dirMappingLen)
+	}
+
 	switch len(prevReturned) {
 	case 0:
 		dirIndex = int(0)
@@ -623,10 +921,11 @@ func (vS *volumeStruct) ReadDir(dirInodeNumber InodeNumber, maxEntries uint64, m
 		atLeastOneEntryFound = true
 
 		nextEntry = DirEntry{
-			InodeNumber:     value.(InodeNumber),
+			InodeNumber:     value.(dirEntryValue).inodeNumber(),
 			Basename:        key.(string),
 			NextDirLocation: InodeDirLocation(dirIndex) + 1,
 		}
+		nextEntry.Type, _ = vS.typeOfDirEntry(value.(dirEntryValue)) // see dirent_type.go
 
 		if (0 != maxEntries) && (uint64(len(dirEntries)+1) > maxEntries) {
 			break
@@ -0,0 +1,126 @@
+package inode
+
+// Directory entry types
+//
+// Each entry of a directory's B+Tree maps its basename to a dirEntryValue: the InodeNumber it references
+// with, in the top dirEntryTypeBits, that inode's InodeType (recorded as the entry is created by Link(),
+// Move(), or Coalesce()). As an inode's type never changes, ReadDir() returns each DirEntry's Type without
+// fetching (let alone locking) the inode it references. InodeNumbers being allocated sequentially, none
+// reaches the top dirEntryTypeBits.
+//
+// Entries written before types were recorded hold none (i.e. zero, which no InodeType uses). ReadDir()
+// fetches the type of each such entry's inode instead, while RecordDirEntryTypes() rewrites a directory's
+// entries to record their types (see fs.UpgradeDirEntryTypes()).
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+const (
+	dirEntryTypeBits        = 8
+	dirEntryTypeShift       = 64 - dirEntryTypeBits
+	dirEntryInodeNumberMask = (uint64(1) << dirEntryTypeShift) - 1
+)
+
+type dirEntryValue uint64
+
+func makeDirEntryValue(inodeNumber InodeNumber, inodeType InodeType) dirEntryValue {
+	if 0 != (uint64(inodeNumber) &^ dirEntryInodeNumberMask) {
+		panic(fmt.Errorf("InodeNumber 0x%016X overlaps the InodeType of its directory entry", uint64(inodeNumber)))
+	}
+	return dirEntryValue(uint64(inodeNumber) | (uint64(inodeType) << dirEntryTypeShift))
+}
+
+func (value dirEntryValue) inodeNumber() InodeNumber {
+	return InodeNumber(uint64(value) & dirEntryInodeNumberMask)
+}
+
+// inodeType returns the recorded InodeType (zero if none was recorded).
+func (value dirEntryValue) inodeType() InodeType {
+	return InodeType(uint64(value) >> dirEntryTypeShift)
+}
+
+// typeOfDirEntry returns the InodeType of the inode referenced by value, fetching it if none was recorded.
+func (vS *volumeStruct) typeOfDirEntry(value dirEntryValue) (inodeType InodeType, err error) {
+	inodeType = value.inodeType()
+	if 0 != inodeType {
+		return
+	}
+
+	inode, ok, err := vS.fetchInode(value.inodeNumber())
+	if nil != err {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: inode %d volume '%s' is unallocated", utils.GetFnName(), value.inodeNumber(), vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	inodeType = inode.InodeType
+	return
+}
+
+// RecordDirEntryTypes records the InodeType in each entry of dirInodeNumber lacking one, returning the
+// number of entries so rewritten.
+func (vS *volumeStruct) RecordDirEntryTypes(dirInodeNumber InodeNumber) (recorded uint64, err error) {
+	dirInode, err := vS.fetchInodeType(dirInodeNumber, DirType)
+	if nil != err {
+		return
+	}
+
+	dirMapping := dirInode.payload.(sortedmap.BPlusTree)
+
+	dirMappingLen, err := dirMapping.Len()
+	if nil != err {
+		err = blunder.AddError(err, blunder.IOError)
+		return
+	}
+
+	for dirIndex := 0; dirIndex < dirMappingLen; dirIndex++ {
+		_, value, ok, getByIndexErr := dirMapping.GetByIndex(dirIndex)
+		if nil != getByIndexErr {
+			err = blunder.AddError(getByIndexErr, blunder.IOError)
+			return
+		}
+		if !ok {
+			break
+		}
+
+		entryValue := value.(dirEntryValue)
+		if 0 != entryValue.inodeType() {
+			continue
+		}
+
+		inodeType, typeErr := vS.typeOfDirEntry(entryValue)
+		if nil != typeErr {
+			logger.WarnfWithError(typeErr, "%s: entry %v of directory inode %d volume '%s' references no inode", utils.GetFnName(), dirIndex, dirInodeNumber, vS.volumeName)
+			continue
+		}
+
+		_, err = dirMapping.PatchByIndex(dirIndex, makeDirEntryValue(entryValue.inodeNumber(), inodeType))
+		if nil != err {
+			err = blunder.AddError(err, blunder.IOError)
+			return
+		}
+		recorded++
+	}
+
+	if 0 == recorded {
+		return
+	}
+
+	dirInode.dirty = true
+
+	err = vS.flushInode(dirInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+	}
+	return
+}
@@ -0,0 +1,141 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/sortedmap"
+)
+
+func TestDirEntryValue(t *testing.T) {
+	for _, inodeType := range []InodeType{DirType, FileType, SymlinkType} {
+		value := makeDirEntryValue(InodeNumber(dirEntryInodeNumberMask), inodeType)
+		if InodeNumber(dirEntryInodeNumberMask) != value.inodeNumber() {
+			t.Fatalf("makeDirEntryValue().inodeNumber() returned 0x%016X", uint64(value.inodeNumber()))
+		}
+		if inodeType != value.inodeType() {
+			t.Fatalf("makeDirEntryValue().inodeType() returned %v (expected %v)", value.inodeType(), inodeType)
+		}
+	}
+
+	legacyValue := dirEntryValue(0x1234)
+	if (InodeNumber(0x1234) != legacyValue.inodeNumber()) || (0 != legacyValue.inodeType()) {
+		t.Fatalf("legacy dirEntryValue decoded as (0x%016X,%v)", uint64(legacyValue.inodeNumber()), legacyValue.inodeType())
+	}
+
+	defer func() {
+		if nil == recover() {
+			t.Fatalf("makeDirEntryValue() of overlarge InodeNumber should have panicked")
+		}
+	}()
+	_ = makeDirEntryValue(InodeNumber(dirEntryInodeNumberMask+1), FileType)
+}
+
+func TestDirEntryTypes(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+	vS := testVolumeHandle.(*volumeStruct)
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	symlinkInodeNumber, err := testVolumeHandle.CreateSymlink("File", PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateSymlink() failed: %v", err)
+	}
+
+	err = testVolumeHandle.Link(dirInodeNumber, "File", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "Symlink", symlinkInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+
+	expectedTypes := map[string]InodeType{
+		".":       DirType,
+		"File":    FileType,
+		"Symlink": SymlinkType,
+	}
+
+	checkTypes := func(context string) {
+		dirEntries, _, readDirErr := testVolumeHandle.ReadDir(dirInodeNumber, 0, 0)
+		if nil != readDirErr {
+			t.Fatalf("ReadDir() %s failed: %v", context, readDirErr)
+		}
+		if len(expectedTypes) != len(dirEntries) {
+			t.Fatalf("ReadDir() %s returned %v entries (expected %v)", context, len(dirEntries), len(expectedTypes))
+		}
+		for _, dirEntry := range dirEntries {
+			if expectedTypes[dirEntry.Basename] != dirEntry.Type {
+				t.Fatalf("ReadDir() %s returned Type %v for %v (expected %v)", context, dirEntry.Type, dirEntry.Basename, expectedTypes[dirEntry.Basename])
+			}
+		}
+	}
+
+	checkTypes("of new entries")
+
+	// Strip the recorded types as if the entries predated them
+
+	dirInode, err := vS.fetchInodeType(dirInodeNumber, DirType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	dirMapping := dirInode.payload.(sortedmap.BPlusTree)
+	for basename, inodeNumber := range map[string]InodeNumber{".": dirInodeNumber, "File": fileInodeNumber, "Symlink": symlinkInodeNumber} {
+		ok, patchErr := dirMapping.PatchByKey(basename, dirEntryValue(inodeNumber))
+		if (nil != patchErr) || !ok {
+			t.Fatalf("PatchByKey(\"%v\") failed: %v", basename, patchErr)
+		}
+	}
+
+	checkTypes("of legacy entries")
+
+	recorded, err := testVolumeHandle.RecordDirEntryTypes(dirInodeNumber)
+	if nil != err {
+		t.Fatalf("RecordDirEntryTypes() failed: %v", err)
+	}
+	if 3 != recorded {
+		t.Fatalf("RecordDirEntryTypes() recorded %v entries (expected 3)", recorded)
+	}
+
+	value, ok, err := dirMapping.GetByKey("Symlink")
+	if (nil != err) || !ok {
+		t.Fatalf("GetByKey(\"Symlink\") failed: %v", err)
+	}
+	if SymlinkType != value.(dirEntryValue).inodeType() {
+		t.Fatalf("RecordDirEntryTypes() should have recorded SymlinkType")
+	}
+
+	checkTypes("of upgraded entries")
+
+	recorded, err = testVolumeHandle.RecordDirEntryTypes(dirInodeNumber)
+	if (nil != err) || (0 != recorded) {
+		t.Fatalf("repeated RecordDirEntryTypes() returned (%v,%v)", recorded, err)
+	}
+
+	_, err = testVolumeHandle.RecordDirEntryTypes(fileInodeNumber)
+	if nil == err {
+		t.Fatalf("RecordDirEntryTypes() of file should have failed")
+	}
+
+	for _, basename := range []string{"File", "Symlink"} {
+		err = testVolumeHandle.Unlink(dirInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink() failed: %v", err)
+		}
+	}
+	for _, inodeNumber := range []InodeNumber{fileInodeNumber, symlinkInodeNumber, dirInodeNumber} {
+		err = testVolumeHandle.Destroy(inodeNumber)
+		if nil != err {
+			t.Fatalf("Destroy() failed: %v", err)
+		}
+	}
+}
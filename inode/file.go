@@ -153,13 +153,35 @@ func setSizeInMemory(fileInode *inMemoryInodeStruct, size uint64) (err error) {
 	fileInode.dirty = true
 	fileInode.Size = size
 
-	updateTime := time.Now()
+	updateTime := fileInode.volume.timestamp(fileInode)
 	fileInode.ModificationTime = updateTime
 	fileInode.AttrChangeTime = updateTime
+	fileInode.ChangeCount++
 	return
 }
 
 func (vS *volumeStruct) Read(fileInodeNumber InodeNumber, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error) {
+	buf, err = vS.read(fileInodeNumber, offset, length, nil)
+	return
+}
+
+// ReadInto is Read() of len(dst) bytes at offset placing the data directly in dst rather than in a
+// freshly allocated buffer. It returns the number of bytes read (fewer than len(dst) if the file ends
+// before offset+len(dst)). The contents of dst[n:] are unspecified upon return.
+func (vS *volumeStruct) ReadInto(fileInodeNumber InodeNumber, offset uint64, dst []byte, profiler *utils.Profiler) (n uint64, err error) {
+	buf, err := vS.read(fileInodeNumber, offset, uint64(len(dst)), dst[:0:len(dst)])
+	if nil != err {
+		return
+	}
+
+	n = uint64(len(buf))
+
+	stats.IncrementOperations(&stats.FileReadIntoOps)
+	return
+}
+
+// read implements Read() and, if dst is non-nil, ReadInto().
+func (vS *volumeStruct) read(fileInodeNumber InodeNumber, offset uint64, length uint64, dst []byte) (buf []byte, err error) {
 	var (
 		fileInode     *inMemoryInodeStruct
 		readPlan      []ReadPlanStep
@@ -172,18 +194,26 @@ func (vS *volumeStruct) Read(fileInodeNumber InodeNumber, offset uint64, length
 		return
 	}
 
+	err = vS.sendStagedWrites(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
 	readPlan, readPlanBytes, err = vS.getReadPlanHelper(fileInode, &offset, &length)
 	if nil != err {
 		logger.WarnWithError(err)
 		return
 	}
 
-	buf, err = vS.doReadPlan(fileInode, readPlan, readPlanBytes)
+	buf, err = vS.doReadPlan(fileInode, readPlan, readPlanBytes, vS.rangedReadPermitted(fileInode, offset), dst)
 	if nil != err {
 		logger.WarnWithError(err)
 		return
 	}
 
+	vS.readahead(fileInode, offset, uint64(len(buf)))
+
 	stats.IncrementOperationsAndBucketedBytes(stats.FileRead, uint64(len(buf)))
 
 	err = nil
@@ -230,13 +260,22 @@ func (vS *volumeStruct) getReadPlanHelper(fileInode *inMemoryInodeStruct, reques
 		err = fmt.Errorf("requestedOffset and requestedLength cannot both be nil")
 		return
 	} else if requestedOffset == nil {
-		// Suffix request, e.g. "bytes=-10", the last 10 bytes of the file
-		offset = fileInode.Size - *requestedLength
-		readPlanBytes = *requestedLength
+		// Suffix request, e.g. "bytes=-10", the last 10 bytes of the file (or all of a shorter file)
+		if *requestedLength > fileInode.Size {
+			offset = 0
+			readPlanBytes = fileInode.Size
+		} else {
+			offset = fileInode.Size - *requestedLength
+			readPlanBytes = *requestedLength
+		}
 	} else if requestedLength == nil {
-		// Prefix request, e.g. "bytes=25-", from byte 25 to the end
+		// Prefix request, e.g. "bytes=25-", from byte 25 to the end (or nothing if beyond the end)
 		offset = *requestedOffset
-		readPlanBytes = fileInode.Size - *requestedOffset
+		if offset < fileInode.Size {
+			readPlanBytes = fileInode.Size - offset
+		} else {
+			readPlanBytes = 0
+		}
 	} else {
 		offset = *requestedOffset
 		readPlanBytes = *requestedLength
@@ -580,20 +619,112 @@ func (vS *volumeStruct) Write(fileInodeNumber InodeNumber, offset uint64, buf []
 
 	fileInode.dirty = true
 
-	logSegmentNumber, logSegmentOffset, err := vS.doSendChunk(fileInode, buf)
+	err = vS.writeSegment(fileInode, offset, buf)
+	if nil != err {
+		return
+	}
+
+	updateTime := vS.timestamp(fileInode)
+	fileInode.AttrChangeTime = updateTime
+	fileInode.ChangeCount++
+	fileInode.ModificationTime = updateTime
+	fileInode.NumWrites++
+
+	return
+}
+
+// Writev is Write() of each of segments in turn (so later segments overwrite any earlier ones they overlap)
+// that counts as a single write (e.g. NumWrites is incremented only once). Each run of segments that are
+// contiguous is coalesced into a single extent, and all are sent to the file's open LogSegment.
+func (vS *volumeStruct) Writev(fileInodeNumber InodeNumber, segments []WriteSegment, profiler *utils.Profiler) (err error) {
+	fileInode, err := vS.fetchInodeType(fileInodeNumber, FileType)
 	if nil != err {
 		logger.ErrorWithError(err)
 		return
 	}
 
-	length := uint64(len(buf))
+	coalescedSegments := coalesceWriteSegments(segments)
+	if 0 == len(coalescedSegments) {
+		return
+	}
 
-	err = recordWrite(fileInode, offset, length, logSegmentNumber, logSegmentOffset)
+	fileInode.dirty = true
+
+	// Should a segment fail, those before it have nevertheless been written
+
+	for i, segment := range coalescedSegments {
+		err = vS.writeSegment(fileInode, segment.Offset, segment.Buf)
+		if nil != err {
+			if 0 == i {
+				return
+			}
+			break
+		}
+	}
+
+	stats.IncrementOperations(&stats.FileWritevOps)
+
+	updateTime := vS.timestamp(fileInode)
+	fileInode.AttrChangeTime = updateTime
+	fileInode.ChangeCount++
+	fileInode.ModificationTime = updateTime
+	fileInode.NumWrites++
+
+	return
+}
+
+// coalesceWriteSegments returns segments with each run of contiguous (non-empty) segments merged. Empty
+// segments are dropped. The Buf of a merged segment is a copy; segments themselves are not modified.
+func coalesceWriteSegments(segments []WriteSegment) (coalescedSegments []WriteSegment) {
+	coalescedSegments = make([]WriteSegment, 0, len(segments))
+	merged := false // if true, the last of coalescedSegments has a Buf of our own (so may be appended to)
+
+	for _, segment := range segments {
+		if 0 == len(segment.Buf) {
+			continue
+		}
+		lastIndex := len(coalescedSegments) - 1
+		if (0 <= lastIndex) && ((coalescedSegments[lastIndex].Offset + uint64(len(coalescedSegments[lastIndex].Buf))) == segment.Offset) {
+			if !merged {
+				coalescedSegments[lastIndex].Buf = append(make([]byte, 0, len(coalescedSegments[lastIndex].Buf)+len(segment.Buf)), coalescedSegments[lastIndex].Buf...)
+				merged = true
+			}
+			coalescedSegments[lastIndex].Buf = append(coalescedSegments[lastIndex].Buf, segment.Buf...)
+			continue
+		}
+		coalescedSegments = append(coalescedSegments, segment)
+		merged = false
+	}
+
+	return
+}
+
+// writeSegment writes buf at offset in fileInode, staging it (see write_back.go) or sending it to the
+// file's open LogSegment. The caller is responsible for updating fileInode's times & NumWrites.
+func (vS *volumeStruct) writeSegment(fileInode *inMemoryInodeStruct, offset uint64, buf []byte) (err error) {
+	staged, err := vS.stageWrite(fileInode, offset, buf)
 	if nil != err {
 		logger.ErrorWithError(err)
 		return
 	}
 
+	length := uint64(len(buf))
+
+	if !staged {
+		logSegmentNumber, logSegmentOffset, sendErr := vS.doSendChunk(fileInode, buf)
+		if nil != sendErr {
+			err = sendErr
+			logger.ErrorWithError(err)
+			return
+		}
+
+		err = recordWrite(fileInode, offset, length, logSegmentNumber, logSegmentOffset)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+	}
+
 	startingSize := fileInode.Size
 
 	offsetJustAfterWhereBufLogicallyWritten := offset + length
@@ -607,11 +738,6 @@ func (vS *volumeStruct) Write(fileInodeNumber InodeNumber, offset uint64, buf []
 
 	stats.IncrementOperationsBucketedBytesAndAppendedOverwritten(stats.FileWrite, length, appendedBytes, overwrittenBytes)
 
-	updateTime := time.Now()
-	fileInode.AttrChangeTime = updateTime
-	fileInode.ModificationTime = updateTime
-	fileInode.NumWrites++
-
 	return
 }
 
@@ -670,9 +796,10 @@ func (vS *volumeStruct) Wrote(fileInodeNumber InodeNumber, fileOffset uint64, ob
 			fileInode.Size = fileOffset + length
 		}
 
-		updateTime := time.Now()
+		updateTime := vS.timestamp(fileInode)
 		fileInode.ModificationTime = updateTime
 		fileInode.AttrChangeTime = updateTime
+		fileInode.ChangeCount++
 	} else {
 		err = setSizeInMemory(fileInode, length)
 		if err != nil {
@@ -704,6 +831,12 @@ func (vS *volumeStruct) SetSize(fileInodeNumber InodeNumber, size uint64) (err e
 
 	fileInode.dirty = true
 
+	err = vS.sendStagedWrites(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
 	err = setSizeInMemory(fileInode, size)
 	if nil != err {
 		logger.ErrorWithError(err)
@@ -767,7 +900,9 @@ func flush(fileInode *inMemoryInodeStruct, andPurge bool) (err error) {
 
 	if andPurge {
 		volume.Lock()
-		delete(volume.inodeCache, inodeNumber)
+		if _, pinned := volume.pinnedInodeMap[inodeNumber]; !pinned {
+			delete(volume.inodeCache, inodeNumber)
+		}
 		volume.Unlock()
 	}
 
@@ -775,8 +910,9 @@ func flush(fileInode *inMemoryInodeStruct, andPurge bool) (err error) {
 }
 
 func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinationName string, elements []CoalesceElement) (combinationInodeNumber InodeNumber, modificationTime time.Time, numWrites uint64, err error) {
-	// We steal the log segments from each element by getting a read plan for the whole element, then calling
-	// recordWrite to point the combined inode at them.
+	// We steal the log segments from each element by getting a read plan for the element's range (by default the
+	// whole element), then calling recordWrite to point the combined inode at them. Any log segments of an element
+	// referenced by none of its range are left to be deleted along with the element.
 	//
 	// While this does result in log segments being shared by two inodes (an illegal state), we undo the damage later by
 	// deleting the elements' inodes but not their log segments. Also, we carefully manage transactions so this whole
@@ -808,6 +944,12 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 			return
 		}
 
+		// The range to include must lie within the element
+		if (elements[i].Offset > elementInodes[i].Size) || ((0 != elements[i].Length) && (elements[i].Length > (elementInodes[i].Size - elements[i].Offset))) {
+			err = blunder.NewError(blunder.InvalidArgError, "Range [%v:+%v] exceeds size %v of file %v", elements[i].Offset, elements[i].Length, elementInodes[i].Size, elements[i].ElementName)
+			return
+		}
+
 		elementDirInodes[i], err = vS.fetchInodeType(elements[i].ContainingDirectoryInodeNumber, DirType)
 		if err != nil {
 			return
@@ -825,9 +967,13 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 	}
 
 	sumOfElementSizes := uint64(0)
-	for _, inodeStruct := range elementInodes {
-		offset := uint64(0)
-		length := inodeStruct.Size
+	stolenLogSegments := make(map[uint64]bool)
+	for i, inodeStruct := range elementInodes {
+		offset := elements[i].Offset
+		length := elements[i].Length
+		if 0 == length {
+			length = inodeStruct.Size - offset
+		}
 		// NB: we rely on the fact that GetReadPlan causes a flush of any pending writes to disk. This lets us steal log
 		// segments without concerning ourselves with stealing data from the write-back cache as well.
 		readPlanSteps, err1 := vS.GetReadPlan(inodeStruct.InodeNumber, &offset, &length)
@@ -839,13 +985,14 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 		fileOffset := uint64(0)
 		for _, step := range readPlanSteps {
 			// For normal steps, steal the log segment. For sparse steps (zero-fill in sparse files), just increment the
-			// file size.
-			if step.LogSegmentNumber != 0 {
+			// file size. Empty steps (e.g. from a zero-length extent) contribute nothing, so are skipped.
+			if step.LogSegmentNumber != 0 && step.Length != 0 {
 				err = recordWrite(combinationInode, sumOfElementSizes+fileOffset, step.Length, step.LogSegmentNumber, step.Offset)
 				combinationInode.NumWrites++
 				if err != nil {
 					return
 				}
+				stolenLogSegments[step.LogSegmentNumber] = true
 			}
 			fileOffset += step.Length
 		}
@@ -882,7 +1029,7 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 		panic(err)
 	}
 	if ok {
-		obstacleInodeNumber := obstacle.(InodeNumber)
+		obstacleInodeNumber := obstacle.(dirEntryValue).inodeNumber()
 		obstacleInode, ok, err1 := vS.fetchInode(obstacleInodeNumber)
 		if !ok {
 			err = errors.New("dir has inode, but we can't fetch it?")
@@ -919,11 +1066,18 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 	// destroying all the elements' inodes, there will be unreferenced inodes left lying around. Should fsck discover
 	// such inodes, it will delete them *and* their log segments, thus corrupting the combined file we have just built.
 	//
-	// To avoid that, we set the size of each element to 0, clear its LogSegmentMap, and flush it. This way, should fsck
-	// get its hands on the inode, it will not destroy the underlying log segments, leaving the combined file intact.
+	// To avoid that, we set the size of each element to 0, remove the log segments now referenced by the combined file
+	// from its LogSegmentMap, and flush it. This way, should fsck get its hands on the inode, it will not destroy the
+	// underlying log segments, leaving the combined file intact.
 	for _, elementInode := range elementInodes {
 		elementInode.Size = uint64(0)
-		elementInode.LogSegmentMap = make(map[uint64]uint64)
+		unstolenLogSegmentMap := make(map[uint64]uint64)
+		for logSegmentNumber, logSegmentBytes := range elementInode.LogSegmentMap {
+			if !stolenLogSegments[logSegmentNumber] {
+				unstolenLogSegmentMap[logSegmentNumber] = logSegmentBytes
+			}
+		}
+		elementInode.LogSegmentMap = unstolenLogSegmentMap
 		toFlush = append(toFlush, elementInode)
 	}
 
@@ -940,8 +1094,9 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 		}
 	}
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(combinationInode)
 	combinationInode.AttrChangeTime = updateTime
+	combinationInode.ChangeCount++
 	combinationInode.ModificationTime = updateTime
 
 	combinationInodeNumber = combinationInode.InodeNumber
@@ -951,11 +1106,7 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 }
 
 func (vS *volumeStruct) getLogSegmentContainer(logSegmentNumber uint64) (containerName string, err error) {
-	containerNameAsByteSlice, err := vS.headhunterVolumeHandle.GetLogSegmentRec(logSegmentNumber)
-	if nil != err {
-		return
-	}
-	containerName = utils.ByteSliceToString(containerNameAsByteSlice)
+	containerName, _, err = vS.getLogSegmentRec(logSegmentNumber) // see clone.go
 	return
 }
 
@@ -990,14 +1141,17 @@ func (vS *volumeStruct) getObjectPathFromContainerNameAndLogSegmentNumber(contai
 }
 
 func (vS *volumeStruct) deleteLogSegmentAsync(logSegmentNumber uint64, checkpointDoneWaitGroup *sync.WaitGroup) (err error) {
-	containerName, err := vS.getLogSegmentContainer(logSegmentNumber)
+	containerName, released, err := vS.releaseLogSegmentRec(logSegmentNumber)
 	if nil != err {
 		return
 	}
+	if !released {
+		stats.IncrementOperations(&stats.GcLogSegSharedOps)
+		return // deleted once no other file references it (see clone.go)
+	}
 	objectName := fmt.Sprintf("%016X", logSegmentNumber)
-	err = vS.headhunterVolumeHandle.DeleteLogSegmentRec(logSegmentNumber)
-	if nil != err {
-		return
+	if vS.headhunterVolumeHandle.SnapshotRetainsLogSegment(logSegmentNumber, containerName) {
+		return // deleted once no snapshot references it
 	}
 	swiftclient.ObjectDeleteAsync(vS.accountName, containerName, objectName, checkpointDoneWaitGroup, nil)
 	return
@@ -10,18 +10,46 @@ import (
 	"github.com/swiftstack/ProxyFS/utils"
 )
 
+// evictReadCacheLRU purges the LRU element of flowControl.readCache (if any).
+//
+// Pinned elements are not linked into the MRU/LRU list and are, therefore, never evicted.
+// Caller must hold flowControl.Mutex.
+func evictReadCacheLRU(flowControl *flowControlStruct) (evicted bool) {
+	if nil == flowControl.readCacheLRU {
+		evicted = false
+		return
+	}
+
+	delete(flowControl.readCache, flowControl.readCacheLRU.readCacheKey)
+	if nil == flowControl.readCacheLRU.prev {
+		flowControl.readCacheMRU = nil
+		flowControl.readCacheLRU = nil
+	} else {
+		flowControl.readCacheLRU = flowControl.readCacheLRU.prev
+		flowControl.readCacheLRU.next = nil
+	}
+
+	evicted = true
+	return
+}
+
 func capReadCache(flowControl *flowControlStruct) {
 	flowControl.Lock()
 
 	for uint64(len(flowControl.readCache)) > flowControl.readCacheLineCount {
-		flowControl.readCacheLRU = flowControl.readCacheLRU.prev
-		flowControl.readCacheLRU.next = nil
+		if !evictReadCacheLRU(flowControl) {
+			break
+		}
 	}
 
 	flowControl.Unlock()
 }
 
-func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []ReadPlanStep, readPlanBytes uint64) (buf []byte, err error) {
+// doReadPlan returns the data described by readPlan. If rangeOK, Read Cache misses may be satisfied by
+// ranged GETs (see ranged_read.go). If dst is non-nil (in which case cap(dst) must be at least
+// readPlanBytes), the data is placed in (and buf is a prefix of) dst rather than a freshly allocated or
+// Read Cache Line backed buf.
+func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []ReadPlanStep, readPlanBytes uint64, rangeOK bool, dst []byte) (buf []byte, err error) {
 	var (
 		cacheLine            []byte
 		cacheLineHitLength   uint64
@@ -32,6 +60,8 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 		inFlightHit          bool
 		inFlightHitBuf       []byte
 		inFlightLogSegment   *inFlightLogSegmentStruct
+		rangeBuf             []byte
+		rangeLength          uint64
 		readCacheElement     *readCacheElementStruct
 		readCacheHit         bool
 		readCacheKey         readCacheKeyStruct
@@ -55,7 +85,14 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 
 		if 0 == step.LogSegmentNumber {
 			// Case 1: The lone step calls for a zero-filled []byte
-			buf = make([]byte, step.Length)
+			if nil == dst {
+				buf = make([]byte, step.Length)
+			} else {
+				buf = dst[:step.Length]
+				for i := range buf {
+					buf[i] = 0
+				}
+			}
 			stats.IncrementOperationsAndBucketedBytes(stats.FileRead, step.Length)
 			err = nil
 			return
@@ -74,6 +111,9 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 				return
 			}
 			fileInode.Unlock()
+			if nil != dst {
+				buf = append(dst[:0], buf...)
+			}
 			stats.IncrementOperations(&stats.FileWritebackHitOps)
 			stats.IncrementOperationsAndBucketedBytes(stats.FileRead, step.Length)
 			return
@@ -95,7 +135,7 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 			readCacheElement, readCacheHit = flowControl.readCache[readCacheKey]
 
 			if readCacheHit {
-				if flowControl.readCacheMRU != readCacheElement {
+				if (0 == readCacheElement.pinCount) && (flowControl.readCacheMRU != readCacheElement) {
 					// Move readCacheElement to MRU position in readCache
 					if readCacheElement == flowControl.readCacheLRU {
 						flowControl.readCacheLRU = readCacheElement.prev
@@ -115,6 +155,17 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 			} else {
 				flowControl.Unlock()
 				stats.IncrementOperations(&stats.FileReadcacheMissOps)
+				if rangeOK {
+					rangeLength = flowControl.rangedReadLength(cacheLineHitOffset, step.Length)
+					if 0 != rangeLength {
+						buf, err = vS.rangedRead(step, step.Offset, step.Length, rangeLength, dst)
+						if nil != err {
+							return
+						}
+						stats.IncrementOperationsAndBucketedBytes(stats.FileRead, step.Length)
+						return
+					}
+				}
 				// Make readCacheHit true (at MRU, likely kicking out LRU)
 				cacheLineStartOffset = readCacheKey.cacheLineTag * readCacheLineSize
 				cacheLine, err = swiftclient.ObjectGet(step.AccountName, step.ContainerName, step.ObjectName, cacheLineStartOffset, readCacheLineSize)
@@ -130,28 +181,24 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 					cacheLine:    cacheLine,
 				}
 				flowControl.Lock()
-				// If the readCache size is at or greater than the limit, delete something.
-				if uint64(len(flowControl.readCache)) >= flowControl.readCacheLineCount {
-					// Purge LRU element
-					delete(flowControl.readCache, flowControl.readCacheLRU.readCacheKey)
-					if 1 == flowControl.readCacheLineCount {
-						// Unreasonable... but still possible
-						flowControl.readCacheMRU = nil
-						flowControl.readCacheLRU = nil
+				// Another thread (e.g. a Pin()) may have inserted this Read Cache Line in the meantime
+				_, readCacheHit = flowControl.readCache[readCacheElement.readCacheKey]
+				if !readCacheHit {
+					// If the readCache size is at or greater than the limit, delete something.
+					if uint64(len(flowControl.readCache)) >= flowControl.readCacheLineCount {
+						// Purge LRU element
+						_ = evictReadCacheLRU(flowControl)
+					}
+					flowControl.readCache[readCacheElement.readCacheKey] = readCacheElement
+					if nil == flowControl.readCacheMRU {
+						flowControl.readCacheMRU = readCacheElement
+						flowControl.readCacheLRU = readCacheElement
 					} else {
-						flowControl.readCacheLRU = flowControl.readCacheLRU.prev
-						flowControl.readCacheLRU.next = nil
+						readCacheElement.next = flowControl.readCacheMRU
+						readCacheElement.next.prev = readCacheElement
+						flowControl.readCacheMRU = readCacheElement
 					}
 				}
-				flowControl.readCache[readCacheElement.readCacheKey] = readCacheElement
-				if nil == flowControl.readCacheMRU {
-					flowControl.readCacheMRU = readCacheElement
-					flowControl.readCacheLRU = readCacheElement
-				} else {
-					readCacheElement.next = flowControl.readCacheMRU
-					readCacheElement.next.prev = readCacheElement
-					flowControl.readCacheMRU = readCacheElement
-				}
 				flowControl.Unlock()
 			}
 
@@ -162,7 +209,11 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 				return
 			}
 
-			buf = cacheLine[cacheLineHitOffset:(cacheLineHitOffset + step.Length)]
+			if nil == dst {
+				buf = cacheLine[cacheLineHitOffset:(cacheLineHitOffset + step.Length)]
+			} else {
+				buf = append(dst[:0], cacheLine[cacheLineHitOffset:(cacheLineHitOffset+step.Length)]...)
+			}
 
 			stats.IncrementOperationsAndBucketedBytes(stats.FileRead, step.Length)
 
@@ -173,7 +224,11 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 
 	// If we reach here, normal readPlan processing will be performed... no zero-copy opportunity
 
-	buf = make([]byte, 0, readPlanBytes)
+	if nil == dst {
+		buf = make([]byte, 0, readPlanBytes)
+	} else {
+		buf = dst[:0]
+	}
 
 	for stepIndex, step = range readPlan {
 		if 0 == step.LogSegmentNumber {
@@ -220,7 +275,7 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 					flowControl.Lock()
 					readCacheElement, readCacheHit = flowControl.readCache[readCacheKey]
 					if readCacheHit {
-						if flowControl.readCacheMRU != readCacheElement {
+						if (0 == readCacheElement.pinCount) && (flowControl.readCacheMRU != readCacheElement) {
 							// Move readCacheElement to MRU position in readCache
 							if readCacheElement == flowControl.readCacheLRU {
 								flowControl.readCacheLRU = readCacheElement.prev
@@ -240,6 +295,19 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 					} else {
 						flowControl.Unlock()
 						stats.IncrementOperations(&stats.FileReadcacheMissOps)
+						if rangeOK {
+							rangeLength = flowControl.rangedReadLength(cacheLineHitOffset, cacheLineHitLength)
+							if 0 != rangeLength {
+								rangeBuf, err = vS.rangedRead(step, chunkOffset, cacheLineHitLength, rangeLength, buf[len(buf):])
+								if nil != err {
+									return
+								}
+								buf = buf[:len(buf)+len(rangeBuf)]
+								chunkOffset += cacheLineHitLength
+								remainingLength -= cacheLineHitLength
+								continue
+							}
+						}
 						// Make readCacheHit true (at MRU, likely kicking out LRU)
 						cacheLineStartOffset = readCacheKey.cacheLineTag * readCacheLineSize
 						cacheLine, err = swiftclient.ObjectGet(step.AccountName, step.ContainerName, step.ObjectName, cacheLineStartOffset, readCacheLineSize)
@@ -255,28 +323,24 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 							cacheLine:    cacheLine,
 						}
 						flowControl.Lock()
-						// If the readCache size is at or greater than the limit, delete something.
-						if uint64(len(flowControl.readCache)) >= flowControl.readCacheLineCount {
-							// Purge LRU element
-							delete(flowControl.readCache, flowControl.readCacheLRU.readCacheKey)
-							if 1 == flowControl.readCacheLineCount {
-								// Unreasonable... but still possible
-								flowControl.readCacheMRU = nil
-								flowControl.readCacheLRU = nil
+						// Another thread (e.g. a Pin()) may have inserted this Read Cache Line in the meantime
+						_, readCacheHit = flowControl.readCache[readCacheElement.readCacheKey]
+						if !readCacheHit {
+							// If the readCache size is at or greater than the limit, delete something.
+							if uint64(len(flowControl.readCache)) >= flowControl.readCacheLineCount {
+								// Purge LRU element
+								_ = evictReadCacheLRU(flowControl)
+							}
+							flowControl.readCache[readCacheElement.readCacheKey] = readCacheElement
+							if nil == flowControl.readCacheMRU {
+								flowControl.readCacheMRU = readCacheElement
+								flowControl.readCacheLRU = readCacheElement
 							} else {
-								flowControl.readCacheLRU = flowControl.readCacheLRU.prev
-								flowControl.readCacheLRU.next = nil
+								readCacheElement.next = flowControl.readCacheMRU
+								readCacheElement.next.prev = readCacheElement
+								flowControl.readCacheMRU = readCacheElement
 							}
 						}
-						flowControl.readCache[readCacheElement.readCacheKey] = readCacheElement
-						if nil == flowControl.readCacheMRU {
-							flowControl.readCacheMRU = readCacheElement
-							flowControl.readCacheLRU = readCacheElement
-						} else {
-							readCacheElement.next = flowControl.readCacheMRU
-							readCacheElement.next.prev = readCacheElement
-							flowControl.readCacheMRU = readCacheElement
-						}
 						flowControl.Unlock()
 					}
 					if (cacheLineHitOffset + cacheLineHitLength) > uint64(len(cacheLine)) {
@@ -0,0 +1,65 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestSetFlags(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	metadata, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if 0 != metadata.Flags {
+		t.Fatalf("new file has Flags 0x%X", metadata.Flags)
+	}
+	changeCount := metadata.ChangeCount
+
+	err = testVolumeHandle.SetFlags(fileInodeNumber, ImmutableFlag|AppendOnlyFlag)
+	if nil != err {
+		t.Fatalf("SetFlags() failed: %v", err)
+	}
+	metadata, err = testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if (ImmutableFlag | AppendOnlyFlag) != metadata.Flags {
+		t.Fatalf("GetMetadata() after SetFlags() returned Flags 0x%X", metadata.Flags)
+	}
+	if changeCount == metadata.ChangeCount {
+		t.Fatalf("SetFlags() should have incremented ChangeCount")
+	}
+
+	err = testVolumeHandle.SetFlags(fileInodeNumber, 0x1)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("SetFlags() of unknown flag should have failed with InvalidArgError, got: %v", err)
+	}
+
+	err = testVolumeHandle.SetFlags(fileInodeNumber, 0)
+	if nil != err {
+		t.Fatalf("SetFlags() clearing flags failed: %v", err)
+	}
+	metadata, err = testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if 0 != metadata.Flags {
+		t.Fatalf("GetMetadata() after clearing flags returned Flags 0x%X", metadata.Flags)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
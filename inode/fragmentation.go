@@ -0,0 +1,248 @@
+package inode
+
+// Fragmentation reporting and file compaction
+//
+// Overwriting (or truncating) part of a file leaves the bytes previously written there in their
+// LogSegment. A LogSegment no longer referenced at all is deleted as the file is flushed, but one that
+// is still partly referenced retains its unreferenced ("trapped") bytes for as long as the file exists.
+// GetFragmentationReport() quantifies this by HEAD'ing each LogSegment referenced by the file.
+//
+// Optimize() reclaims the trapped bytes by rewriting each extent residing in a LogSegment with trapped
+// bytes to a new LogSegment. Those LogSegments then become unreferenced and are deleted once the file
+// is flushed. The file's contents, Size, times, and NumWrites are unaffected. As each rewritten extent
+// leaves the file consistent, Optimize() may stop once maxDuration (if non-zero) has elapsed.
+//
+// Adopt() similarly rewrites each extent residing in any of a given set of LogSegments (e.g. those of
+// objects PUT via the Swift middleware and attached to the file via Wrote()) to new LogSegments, just as
+// Write() would have written them.
+//
+// All assume each LogSegment is referenced by a single file (as is the case for all but the elements
+// of a Coalesce()).
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+const optimizeChunkSize = uint64(16 * 1024 * 1024) // most bytes Optimize() rewrites at a time
+
+// trappedBytesByLogSegment HEADs each of fileInode's LogSegments, returning the unreferenced bytes in
+// each LogSegment that has any. Caller must have flushed fileInode.
+func (vS *volumeStruct) trappedBytesByLogSegment(fileInode *inMemoryInodeStruct) (trappedBytes map[uint64]uint64, err error) {
+	trappedBytes = make(map[uint64]uint64)
+
+	for logSegmentNumber, referencedBytes := range fileInode.LogSegmentMap {
+		containerName, objectName, _, locationErr := vS.getObjectLocationFromLogSegmentNumber(logSegmentNumber)
+		if nil != locationErr {
+			err = locationErr
+			return
+		}
+		contentLength, headErr := swiftclient.ObjectContentLength(vS.accountName, containerName, objectName)
+		if nil != headErr {
+			err = headErr
+			return
+		}
+		if contentLength > referencedBytes {
+			trappedBytes[logSegmentNumber] = contentLength - referencedBytes
+		}
+	}
+
+	return
+}
+
+func (vS *volumeStruct) GetFragmentationReport(inodeNumber InodeNumber) (fragmentationReport FragmentationReport, err error) {
+	fileInode, err := vS.fetchInodeType(inodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	// Flush so that each referenced LogSegment exists (and emptied ones are gone)
+
+	err = vS.flushInode(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	zero := uint64(0)
+
+	readPlan, _, err := vS.getReadPlanHelper(fileInode, &zero, nil)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	for _, step := range readPlan {
+		if 0 != step.LogSegmentNumber {
+			fragmentationReport.NumberOfFragments++
+			fragmentationReport.BytesInFragments += step.Length
+		}
+	}
+
+	trappedBytes, err := vS.trappedBytesByLogSegment(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	for _, logSegmentTrappedBytes := range trappedBytes {
+		fragmentationReport.BytesTrapped += logSegmentTrappedBytes
+	}
+
+	stats.IncrementOperations(&stats.FileFragmentationReportOps)
+
+	return
+}
+
+func (vS *volumeStruct) Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (err error) {
+	startTime := time.Now()
+
+	fileInode, err := vS.fetchInodeType(inodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	err = vS.flushInode(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	trappedBytes, err := vS.trappedBytesByLogSegment(fileInode)
+	if (nil != err) || (0 == len(trappedBytes)) {
+		return
+	}
+
+	err = vS.rewriteExtents(fileInode, func(logSegmentNumber uint64) bool {
+		_, trapped := trappedBytes[logSegmentNumber]
+		return trapped
+	}, startTime, maxDuration)
+	if nil != err {
+		return
+	}
+
+	// Flushing deletes the LogSegments we've emptied
+
+	err = vS.flushInode(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FileOptimizeOps)
+
+	return
+}
+
+func (vS *volumeStruct) Adopt(inodeNumber InodeNumber, logSegmentNumbers []uint64) (err error) {
+	fileInode, err := vS.fetchInodeType(inodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	err = vS.flushInode(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	adoptSet := make(map[uint64]struct{})
+	for _, logSegmentNumber := range logSegmentNumbers {
+		_, referenced := fileInode.LogSegmentMap[logSegmentNumber]
+		if referenced {
+			adoptSet[logSegmentNumber] = struct{}{}
+		}
+	}
+	if 0 == len(adoptSet) {
+		return
+	}
+
+	err = vS.rewriteExtents(fileInode, func(logSegmentNumber uint64) bool {
+		_, adopt := adoptSet[logSegmentNumber]
+		return adopt
+	}, time.Now(), 0)
+	if nil != err {
+		return
+	}
+
+	// Flushing deletes the LogSegments we've emptied
+
+	err = vS.flushInode(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FileAdoptOps)
+
+	return
+}
+
+// rewriteExtents rewrites each extent of fileInode residing in a selected LogSegment to a new
+// LogSegment, stopping once maxDuration (if non-zero) has elapsed since startTime. Caller must have
+// flushed fileInode and should flush it afterwards (deleting the LogSegments thus emptied).
+func (vS *volumeStruct) rewriteExtents(fileInode *inMemoryInodeStruct, selected func(logSegmentNumber uint64) bool, startTime time.Time, maxDuration time.Duration) (err error) {
+	zero := uint64(0)
+
+	readPlan, _, err := vS.getReadPlanHelper(fileInode, &zero, nil)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	fileOffset := uint64(0)
+
+readPlanLoop:
+	for _, step := range readPlan {
+		if (0 == step.LogSegmentNumber) || !selected(step.LogSegmentNumber) {
+			fileOffset += step.Length
+			continue
+		}
+
+		for stepOffset := uint64(0); stepOffset < step.Length; stepOffset += optimizeChunkSize {
+			if (0 != maxDuration) && (time.Since(startTime) >= maxDuration) {
+				break readPlanLoop
+			}
+
+			chunkStep := step
+			chunkStep.Offset += stepOffset
+			chunkStep.Length = step.Length - stepOffset
+			if chunkStep.Length > optimizeChunkSize {
+				chunkStep.Length = optimizeChunkSize
+			}
+
+			buf, readErr := vS.doReadPlan(fileInode, []ReadPlanStep{chunkStep}, chunkStep.Length, false, nil)
+			if nil != readErr {
+				err = readErr
+				logger.ErrorWithError(err)
+				return
+			}
+
+			logSegmentNumber, logSegmentOffset, sendErr := vS.doSendChunk(fileInode, buf)
+			if nil != sendErr {
+				err = sendErr
+				logger.ErrorWithError(err)
+				return
+			}
+
+			fileInode.dirty = true
+
+			err = recordWrite(fileInode, fileOffset+stepOffset, chunkStep.Length, logSegmentNumber, logSegmentOffset)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+
+		}
+
+		fileOffset += step.Length
+	}
+
+	return
+}
@@ -0,0 +1,85 @@
+package inode
+
+import (
+	"testing"
+)
+
+func TestFragmentation(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	fragmentationReport, err := testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if (1 != fragmentationReport.NumberOfFragments) || (10 != fragmentationReport.BytesInFragments) || (0 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() of unfragmented file returned %+v", fragmentationReport)
+	}
+
+	// Overwriting "23" (in a new LogSegment) traps those two bytes in the first LogSegment
+
+	err = testVolumeHandle.Write(fileInodeNumber, 2, []byte("ab"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if (3 != fragmentationReport.NumberOfFragments) || (10 != fragmentationReport.BytesInFragments) || (2 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() of overwritten file returned %+v", fragmentationReport)
+	}
+
+	metadataBefore, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+
+	err = testVolumeHandle.Optimize(fileInodeNumber, 0)
+	if nil != err {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if 0 != fragmentationReport.BytesTrapped {
+		t.Fatalf("GetFragmentationReport() after Optimize() returned %+v", fragmentationReport)
+	}
+
+	buf, err := testVolumeHandle.Read(fileInodeNumber, 0, 10, nil)
+	if (nil != err) || ("01ab456789" != string(buf)) {
+		t.Fatalf("Read() after Optimize() returned \"%s\", %v", string(buf), err)
+	}
+
+	metadataAfter, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if (metadataBefore.Size != metadataAfter.Size) || (metadataBefore.NumWrites != metadataAfter.NumWrites) || !metadataBefore.ModificationTime.Equal(metadataAfter.ModificationTime) {
+		t.Fatalf("Optimize() changed metadata from %+v to %+v", metadataBefore, metadataAfter)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
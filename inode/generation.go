@@ -0,0 +1,120 @@
+package inode
+
+// Generation-guarded (idempotent) Unlink & Destroy
+//
+// An Unlink() or Destroy() may fail ambiguously (e.g. a timeout talking to the metadata store) having
+// nonetheless taken effect. Blindly retrying it is unsafe: the retried Unlink() may remove a name since
+// re-created for another inode (decrementing the wrong LinkCount), and a retried Destroy() may find
+// (should the inode have been re-created or otherwise reused) an inode that is no longer the caller's
+// to destroy. UnlinkGeneration() and DestroyGeneration() instead take the target inode's generation (its
+// CreationTime in nanoseconds, as fetched via GetGeneration() before the first attempt) as proof of
+// identity. Should basename no longer reference the target of that generation (or the target no longer
+// exist with that generation), the earlier attempt is deemed to have completed and the retry succeeds
+// without doing anything. As such, both may be retried as often as needed.
+//
+// Ambiguous failures are injected during testing by setting globals.chaosUnlinkFailureRate and/or
+// globals.chaosDestroyFailureRate: every that many calls, Unlink() or Destroy() completes and then
+// reports a simulated failure anyway.
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// used during testing for error injection
+var (
+	unlinkCnt  uint64
+	destroyCnt uint64
+)
+
+func (vS *volumeStruct) GetGeneration(inodeNumber InodeNumber) (generation uint64, err error) {
+	metadata, err := vS.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	generation = uint64(metadata.CreationTime.UnixNano())
+	return
+}
+
+func (vS *volumeStruct) UnlinkGeneration(dirInodeNumber InodeNumber, basename string, targetInodeNumber InodeNumber, targetGeneration uint64) (unlinked bool, err error) {
+	foundInodeNumber, err := vS.Lookup(dirInodeNumber, basename)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			stats.IncrementOperations(&stats.DirUnlinkGenerationSkipOps)
+			err = nil
+		}
+		return
+	}
+	if foundInodeNumber != targetInodeNumber {
+		stats.IncrementOperations(&stats.DirUnlinkGenerationSkipOps)
+		return
+	}
+
+	foundGeneration, err := vS.GetGeneration(foundInodeNumber)
+	if nil != err {
+		return
+	}
+	if foundGeneration != targetGeneration {
+		stats.IncrementOperations(&stats.DirUnlinkGenerationSkipOps)
+		return
+	}
+
+	err = vS.Unlink(dirInodeNumber, basename)
+	if nil != err {
+		return
+	}
+
+	unlinked = true
+	return
+}
+
+func (vS *volumeStruct) DestroyGeneration(inodeNumber InodeNumber, generation uint64) (destroyed bool, err error) {
+	_, ok, err := vS.fetchInode(inodeNumber)
+	if nil != err {
+		return
+	}
+	if !ok {
+		stats.IncrementOperations(&stats.InodeDestroyGenerationSkipOps)
+		return
+	}
+
+	foundGeneration, err := vS.GetGeneration(inodeNumber)
+	if nil != err {
+		return
+	}
+	if foundGeneration != generation {
+		stats.IncrementOperations(&stats.InodeDestroyGenerationSkipOps)
+		return
+	}
+
+	err = vS.Destroy(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	destroyed = true
+	return
+}
+
+// injectUnlinkFailure is called once Unlink() has otherwise succeeded.
+func injectUnlinkFailure() (err error) {
+	unlinkCnt++
+	if (0 < globals.chaosUnlinkFailureRate) && (0 == unlinkCnt%globals.chaosUnlinkFailureRate) {
+		err = fmt.Errorf("inode.Unlink() returning simulated error")
+		err = blunder.AddError(err, blunder.IOError)
+	}
+	return
+}
+
+// injectDestroyFailure is called once Destroy() has otherwise succeeded.
+func injectDestroyFailure() (err error) {
+	destroyCnt++
+	if (0 < globals.chaosDestroyFailureRate) && (0 == destroyCnt%globals.chaosDestroyFailureRate) {
+		err = fmt.Errorf("inode.Destroy() returning simulated error")
+		err = blunder.AddError(err, blunder.IOError)
+	}
+	return
+}
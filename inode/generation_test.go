@@ -0,0 +1,135 @@
+package inode
+
+import (
+	"testing"
+)
+
+func TestGenerationGuards(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	defer func() {
+		globals.chaosUnlinkFailureRate = 0
+		globals.chaosDestroyFailureRate = 0
+	}()
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	oldInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "name", oldInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+	oldGeneration, err := testVolumeHandle.GetGeneration(oldInodeNumber)
+	if nil != err {
+		t.Fatalf("GetGeneration() failed: %v", err)
+	}
+
+	// An Unlink() that takes effect yet reports failure...
+
+	globals.chaosUnlinkFailureRate = 1
+	unlinkCnt = 0
+
+	err = testVolumeHandle.Unlink(dirInodeNumber, "name")
+	if nil == err {
+		t.Fatalf("Unlink() with chaosUnlinkFailureRate == 1 should have failed")
+	}
+
+	globals.chaosUnlinkFailureRate = 0
+
+	_, err = testVolumeHandle.Lookup(dirInodeNumber, "name")
+	if nil == err {
+		t.Fatalf("Lookup() after (simulated) failed Unlink() should have failed")
+	}
+	linkCount, err := testVolumeHandle.GetLinkCount(oldInodeNumber)
+	if (nil != err) || (0 != linkCount) {
+		t.Fatalf("GetLinkCount() after (simulated) failed Unlink() returned %v, %v (expected 0, nil)", linkCount, err)
+	}
+
+	// ...and whose name is then reused before the retry
+
+	newInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "name", newInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+	newGeneration, err := testVolumeHandle.GetGeneration(newInodeNumber)
+	if nil != err {
+		t.Fatalf("GetGeneration() failed: %v", err)
+	}
+	if newGeneration == oldGeneration {
+		t.Fatalf("GetGeneration() of distinct inodes both returned %v", newGeneration)
+	}
+
+	unlinked, err := testVolumeHandle.UnlinkGeneration(dirInodeNumber, "name", oldInodeNumber, oldGeneration)
+	if (nil != err) || unlinked {
+		t.Fatalf("UnlinkGeneration() retry returned %v, %v (expected false, nil)", unlinked, err)
+	}
+	lookupInodeNumber, err := testVolumeHandle.Lookup(dirInodeNumber, "name")
+	if (nil != err) || (newInodeNumber != lookupInodeNumber) {
+		t.Fatalf("Lookup() after UnlinkGeneration() retry returned %v, %v (expected %v, nil)", lookupInodeNumber, err, newInodeNumber)
+	}
+	linkCount, err = testVolumeHandle.GetLinkCount(newInodeNumber)
+	if (nil != err) || (1 != linkCount) {
+		t.Fatalf("GetLinkCount() after UnlinkGeneration() retry returned %v, %v (expected 1, nil)", linkCount, err)
+	}
+
+	// A Destroy() that takes effect yet reports failure may be safely retried
+
+	globals.chaosDestroyFailureRate = 1
+	destroyCnt = 0
+
+	destroyed, err := testVolumeHandle.DestroyGeneration(oldInodeNumber, oldGeneration)
+	if nil == err {
+		t.Fatalf("DestroyGeneration() with chaosDestroyFailureRate == 1 should have failed")
+	}
+
+	globals.chaosDestroyFailureRate = 0
+
+	_, err = testVolumeHandle.GetMetadata(oldInodeNumber)
+	if nil == err {
+		t.Fatalf("GetMetadata() after (simulated) failed DestroyGeneration() should have failed")
+	}
+
+	destroyed, err = testVolumeHandle.DestroyGeneration(oldInodeNumber, oldGeneration)
+	if (nil != err) || destroyed {
+		t.Fatalf("DestroyGeneration() retry returned %v, %v (expected false, nil)", destroyed, err)
+	}
+
+	// Nor may a DestroyGeneration() of the wrong generation destroy anything
+
+	destroyed, err = testVolumeHandle.DestroyGeneration(newInodeNumber, oldGeneration)
+	if (nil != err) || destroyed {
+		t.Fatalf("DestroyGeneration() of wrong generation returned %v, %v (expected false, nil)", destroyed, err)
+	}
+	_, err = testVolumeHandle.GetMetadata(newInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() after DestroyGeneration() of wrong generation failed: %v", err)
+	}
+
+	// The right generation, of course, is honored
+
+	unlinked, err = testVolumeHandle.UnlinkGeneration(dirInodeNumber, "name", newInodeNumber, newGeneration)
+	if (nil != err) || !unlinked {
+		t.Fatalf("UnlinkGeneration() returned %v, %v (expected true, nil)", unlinked, err)
+	}
+	destroyed, err = testVolumeHandle.DestroyGeneration(newInodeNumber, newGeneration)
+	if (nil != err) || !destroyed {
+		t.Fatalf("DestroyGeneration() returned %v, %v (expected true, nil)", destroyed, err)
+	}
+
+	err = testVolumeHandle.Destroy(dirInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() of dir failed: %v", err)
+	}
+}
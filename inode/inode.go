@@ -44,9 +44,11 @@ type onDiskInodeV1Struct struct { // Preceded "on disk" by CorruptionDetected th
 	AccessTime          time.Time
 	AttrChangeTime      time.Time
 	NumWrites           uint64
+	ChangeCount         uint64 // incremented with each update of AttrChangeTime
 	Mode                InodeMode
 	UserID              InodeUserID
 	GroupID             InodeGroupID
+	Flags               InodeFlags `json:",omitempty"`
 	StreamMap           map[string][]byte
 	PayloadObjectNumber uint64            // DirInode:     B+Tree Root with Key == dir_entry_name, Value = InodeNumber
 	PayloadObjectLength uint64            // FileInode:    B+Tree Root with Key == fileOffset, Value = fileExtent
@@ -73,6 +75,11 @@ type inMemoryInodeStruct struct {
 	openLogSegment           *inFlightLogSegmentStruct            // FileInode only... also in inFlightLogSegmentMap
 	inFlightLogSegmentMap    map[uint64]*inFlightLogSegmentStruct // FileInode: key == logSegmentNumber
 	inFlightLogSegmentErrors map[uint64]error                     // FileInode: key == logSegmentNumber; value == err (if non nil)
+	foldedBasenameMap        map[string]string                    // DirInode: key == foldBasename(basename); value == basename (see casefold.go)
+	readahead                *readaheadStruct                     // FileInode: sequential read stream tracking (see readahead.go)
+	entryMutex               sync.Mutex                           // DirInode: serializes entry changes made under a shared (sharded) lock
+	stagingMutex             sync.Mutex                           // FileInode: protects stagedWrites
+	stagedWrites             []*stagedWriteStruct                 // FileInode: Write()'s not yet sent to openLogSegment (see write_back.go)
 	onDiskInodeV1Struct                                           // Real on-disk inode information embedded here
 }
 
@@ -189,7 +196,7 @@ func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode
 				return
 			}
 		}
-	case SymlinkType:
+	case SymlinkType, FIFOType, SocketType:
 		// Nothing special here
 	default:
 		err = fmt.Errorf("%s: inodeRec.InodeType for inode %d (%v) not supported", utils.GetFnName(), inodeNumber, inMemoryInode.InodeType)
@@ -248,6 +255,8 @@ func (vS *volumeStruct) fetchInodeType(inodeNumber InodeNumber, expectedType Ino
 		errVal = blunder.NotFileError
 	case SymlinkType:
 		errVal = blunder.NotSymlinkError
+	case FIFOType, SocketType:
+		errVal = blunder.InvalidInodeTypeError
 	default:
 		panic(fmt.Sprintf("unknown inode type=%v!", expectedType))
 	}
@@ -257,7 +266,7 @@ func (vS *volumeStruct) fetchInodeType(inodeNumber InodeNumber, expectedType Ino
 }
 
 func (vS *volumeStruct) makeInMemoryInodeWithThisInodeNumber(inodeType InodeType, fileMode InodeMode, userID InodeUserID, groupID InodeGroupID, inodeNumber InodeNumber, volumeLocked bool) (inMemoryInode *inMemoryInodeStruct) {
-	birthTime := time.Now()
+	birthTime := vS.timestamp()
 
 	inMemoryInode = &inMemoryInodeStruct{
 		dirty:                    true,
@@ -285,13 +294,13 @@ func (vS *volumeStruct) makeInMemoryInodeWithThisInodeNumber(inodeType InodeType
 }
 
 func (vS *volumeStruct) makeInMemoryInode(inodeType InodeType, fileMode InodeMode, userID InodeUserID, groupID InodeGroupID) (inMemoryInode *inMemoryInodeStruct, err error) {
-	inodeNumberAsUint64, err := vS.headhunterVolumeHandle.FetchNonce()
+	inodeNumber, err := vS.fetchInodeNumber()
 	if nil != err {
 		err = fmt.Errorf("headhunter.FetchNonce() returned error: %v", err)
 		return
 	}
 
-	inMemoryInode = vS.makeInMemoryInodeWithThisInodeNumber(inodeType, fileMode, userID, groupID, InodeNumber(inodeNumberAsUint64), false)
+	inMemoryInode = vS.makeInMemoryInodeWithThisInodeNumber(inodeType, fileMode, userID, groupID, inodeNumber, false)
 
 	return
 }
@@ -369,6 +378,12 @@ func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 
 	for _, inode = range inodes {
 		if FileType == inode.InodeType {
+			err = vS.sendStagedWrites(inode)
+			if nil != err {
+				logger.ErrorWithError(err)
+				err = blunder.AddError(err, blunder.InodeFlushError)
+				return
+			}
 			err = vS.doFileInodeDataFlush(inode)
 			if nil != err {
 				logger.ErrorWithError(err)
@@ -386,8 +401,7 @@ func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 			}
 			emptyLogSegments = append(emptyLogSegments, emptyLogSegmentsThisInode...)
 		}
-		if SymlinkType != inode.InodeType {
-			// (FileType == inode.InodeType || (DirType == inode.InodeType)
+		if (FileType == inode.InodeType) || (DirType == inode.InodeType) {
 			payloadAsBPlusTree = inode.payload.(sortedmap.BPlusTree)
 			payloadObjectNumber, _, payloadObjectLength, err = payloadAsBPlusTree.Flush(false)
 			if nil != err {
@@ -680,6 +694,18 @@ func (vS *volumeStruct) Access(inodeNumber InodeNumber, userID InodeUserID, grou
 		return
 	}
 
+	// An NFSv4 ACL, if present, supplants the mode bits (see nfs4acl.go)
+	if aclBuf, ok := ourInode.StreamMap[NFS4ACLStream]; ok {
+		acl, decodeErr := decodeNFS4ACL(aclBuf)
+		if nil != decodeErr {
+			logger.ErrorfWithError(decodeErr, "%s: inode %d volume '%s' has a corrupt NFS4ACL", utils.GetFnName(), inodeNumber, vS.volumeName)
+			accessReturn = false
+			return
+		}
+		accessReturn = nfs4ACLAccess(acl, ourInode, userID, groupID, otherGroupIDs, accessMode)
+		return
+	}
+
 	if (userID == ourInode.UserID) && (((ourInode.Mode >> 6) & accessMode) == accessMode) {
 		accessReturn = true
 		return
@@ -738,6 +764,12 @@ func (vS *volumeStruct) Purge(inodeNumber InodeNumber) (err error) {
 		return
 	}
 
+	if _, ok = vS.pinnedInodeMap[inodeNumber]; ok {
+		// Pinned inodes are exempt from eviction
+		err = nil
+		return
+	}
+
 	delete(vS.inodeCache, inodeNumber)
 
 	err = nil
@@ -766,13 +798,13 @@ func (vS *volumeStruct) Destroy(inodeNumber InodeNumber) (err error) {
 		return
 	}
 
+	vS.discardStagedWrites(ourInode)
+
 	vS.Lock()
 	delete(vS.inodeCache, inodeNumber)
 	vS.Unlock()
 
-	if ourInode.InodeType == FileType {
-		_ = vS.doFileInodeDataFlush(ourInode)
-	}
+	vS.unpinInode(inodeNumber)
 
 	err = vS.headhunterVolumeHandle.DeleteInodeRec(uint64(inodeNumber))
 	if nil != err {
@@ -780,41 +812,11 @@ func (vS *volumeStruct) Destroy(inodeNumber InodeNumber) (err error) {
 		return
 	}
 
-	if DirType == ourInode.InodeType {
-		dirMapping := ourInode.payload.(sortedmap.BPlusTree)
-
-		err = dirMapping.Discard()
-		if nil != err {
-			logger.ErrorWithError(err)
-			return
-		}
-
-		stats.IncrementOperations(&stats.DirDestroyOps)
-	} else if FileType == ourInode.InodeType {
-		extents := ourInode.payload.(sortedmap.BPlusTree)
+	// The rest (including deleting any LogSegments) is deferred (see destroy.go)
 
-		err = extents.Discard()
-		if nil != err {
-			logger.ErrorWithError(err)
-			return
-		}
+	vS.queueDestroy(ourInode)
 
-		checkpointDoneWaitGroup := vS.headhunterVolumeHandle.FetchNextCheckPointDoneWaitGroup()
-
-		for logSegmentNumber := range ourInode.LogSegmentMap {
-			deleteSegmentErr := vS.deleteLogSegmentAsync(logSegmentNumber, checkpointDoneWaitGroup)
-			if nil != deleteSegmentErr {
-				logger.WarnfWithError(deleteSegmentErr, "couldn't delete destroy'd log segment")
-				return
-			}
-			stats.IncrementOperations(&stats.GcLogSegDeleteOps)
-		}
-		stats.IncrementOperations(&stats.GcLogSegOps)
-
-		stats.IncrementOperations(&stats.FileDestroyOps)
-	} else { // SymlinkType == ourInode.InodeType
-		stats.IncrementOperations(&stats.SymlinkDestroyOps)
-	}
+	err = injectDestroyFailure() // see generation.go
 
 	return
 }
@@ -837,6 +839,46 @@ func (vS *volumeStruct) GetMetadata(inodeNumber InodeNumber) (metadata *Metadata
 		return nil, err
 	}
 
+	metadata = metadataOfInode(inode)
+
+	stats.IncrementOperations(&stats.InodeGetMetadataOps)
+	return metadata, err
+}
+
+// GetMetadataMulti is GetMetadata() of each of inodeNumbers, returning each one's outcome in the corresponding
+// element of metadataSlice & errs. Those already cached are found under a single acquisition of the volume's lock.
+func (vS *volumeStruct) GetMetadataMulti(inodeNumbers []InodeNumber) (metadataSlice []*MetadataStruct, errs []error) {
+	metadataSlice = make([]*MetadataStruct, len(inodeNumbers))
+	errs = make([]error, len(inodeNumbers))
+
+	inodes := make([]*inMemoryInodeStruct, len(inodeNumbers))
+
+	vS.Lock()
+	for i, inodeNumber := range inodeNumbers {
+		inodes[i] = vS.inodeCache[inodeNumber]
+	}
+	vS.Unlock()
+
+	for i, inodeNumber := range inodeNumbers {
+		if nil == inodes[i] {
+			metadataSlice[i], errs[i] = vS.GetMetadata(inodeNumber)
+			continue
+		}
+
+		metadataSlice[i] = metadataOfInode(inodes[i])
+
+		stats.IncrementOperations(&stats.InodeGetMetadataOps)
+	}
+
+	return
+}
+
+// metadataOfInode returns the MetadataStruct describing inode.
+func metadataOfInode(inode *inMemoryInodeStruct) (metadata *MetadataStruct) {
+	// A directory's LinkCount & times may be concurrently updated by Link()/Unlink() callers holding sharded locks
+	inode.entryMutex.Lock()
+	defer inode.entryMutex.Unlock()
+
 	metadata = &MetadataStruct{
 		InodeType:            inode.InodeType,
 		LinkCount:            inode.LinkCount,
@@ -846,10 +888,20 @@ func (vS *volumeStruct) GetMetadata(inodeNumber InodeNumber) (metadata *Metadata
 		AccessTime:           inode.AccessTime,
 		AttrChangeTime:       inode.AttrChangeTime,
 		NumWrites:            inode.NumWrites,
+		ChangeCount:          inode.ChangeCount,
+		AllocatedBytes:       inode.Size,
 		InodeStreamNameSlice: make([]string, len(inode.StreamMap)),
 		Mode:                 inode.Mode,
 		UserID:               inode.UserID,
 		GroupID:              inode.GroupID,
+		Flags:                inode.Flags,
+	}
+
+	if FileType == inode.InodeType {
+		metadata.AllocatedBytes = 0
+		for _, logSegmentBytes := range inode.LogSegmentMap {
+			metadata.AllocatedBytes += logSegmentBytes
+		}
 	}
 
 	pos := 0
@@ -858,8 +910,7 @@ func (vS *volumeStruct) GetMetadata(inodeNumber InodeNumber) (metadata *Metadata
 		pos++
 	}
 
-	stats.IncrementOperations(&stats.InodeGetMetadataOps)
-	return metadata, err
+	return
 }
 
 func (vS *volumeStruct) GetType(inodeNumber InodeNumber) (inodeType InodeType, err error) {
@@ -959,7 +1010,8 @@ func (vS *volumeStruct) SetCreationTime(inodeNumber InodeNumber, CreationTime ti
 	}
 
 	inode.dirty = true
-	inode.AttrChangeTime = time.Now()
+	inode.AttrChangeTime = vS.timestamp(inode)
+	inode.ChangeCount++
 	inode.CreationTime = CreationTime
 
 	err = vS.flushInode(inode)
@@ -990,7 +1042,8 @@ func (vS *volumeStruct) SetModificationTime(inodeNumber InodeNumber, Modificatio
 	}
 
 	inode.dirty = true
-	inode.AttrChangeTime = time.Now()
+	inode.AttrChangeTime = vS.timestamp(inode)
+	inode.ChangeCount++
 	inode.ModificationTime = ModificationTime
 
 	err = vS.flushInode(inode)
@@ -1022,7 +1075,38 @@ func (vS *volumeStruct) SetAccessTime(inodeNumber InodeNumber, accessTime time.T
 	}
 
 	inode.dirty = true
-	inode.AttrChangeTime = time.Now()
+	inode.AttrChangeTime = vS.timestamp(inode)
+	inode.ChangeCount++
+	inode.AccessTime = accessTime
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return err
+	}
+
+	return
+}
+
+// UpdateAccessTime records a read access of the inode. Unlike SetAccessTime(), which is an explicit
+// attribute change, AttrChangeTime is left untouched.
+func (vS *volumeStruct) UpdateAccessTime(inodeNumber InodeNumber, accessTime time.Time) (err error) {
+	// NOTE: Errors are logged by the caller
+
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if err != nil {
+		logger.ErrorfWithError(err, "%s: fetch of target inode failed", utils.GetFnName())
+		return err
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because its unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		logger.ErrorWithError(err)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return err
+	}
+
+	inode.dirty = true
 	inode.AccessTime = accessTime
 
 	err = vS.flushInode(inode)
@@ -1041,19 +1125,27 @@ const (
 	PosixModeDir     InodeMode = 0x4000
 	PosixModeFile    InodeMode = 0x8000
 	PosixModeSymlink InodeMode = 0xa000
+	PosixModeFIFO    InodeMode = 0x1000
+	PosixModeSocket  InodeMode = 0xc000
+	PosixModeType    InodeMode = 0xf000
 	PosixModePerm    InodeMode = 0777
+	PosixModeSetUID  InodeMode = 04000
+	PosixModeSetGID  InodeMode = 02000
+	PosixModeSticky  InodeMode = 01000
+	PosixModeSetID   InodeMode = PosixModeSetUID | PosixModeSetGID
+	PosixModeBits    InodeMode = PosixModePerm | PosixModeSetID | PosixModeSticky // all but PosixModeType
 )
 
 func determineMode(filePerm InodeMode, inodeType InodeType) (fileMode InodeMode, err error) {
 	// Caller should only be setting the file perm bits, but samba seems to send file type
 	// bits as well. Since we need to work with whatever samba does, let's just silently
 	// mask off the other bits.
-	if filePerm&^PosixModePerm != 0 {
-		logger.Tracef("inode.determineMode(): invalid file mode 0x%x (max 0x%x); removing file type bits.", uint32(filePerm), uint32(PosixModePerm))
+	if filePerm&^PosixModeBits != 0 {
+		logger.Tracef("inode.determineMode(): invalid file mode 0x%x (max 0x%x); removing file type bits.", uint32(filePerm), uint32(PosixModeBits))
 	}
 
-	// Build fileMode starting with the file permission bits
-	fileMode = filePerm & PosixModePerm
+	// Build fileMode starting with the file permission (and setuid, setgid, & sticky) bits
+	fileMode = filePerm & PosixModeBits
 
 	// Add the file type to the mode.
 	switch inodeType {
@@ -1064,6 +1156,10 @@ func determineMode(filePerm InodeMode, inodeType InodeType) (fileMode InodeMode,
 		break
 	case SymlinkType:
 		fileMode |= PosixModeSymlink
+	case FIFOType:
+		fileMode |= PosixModeFIFO
+	case SocketType:
+		fileMode |= PosixModeSocket
 	default:
 		err = fmt.Errorf("%s: unrecognized inode type %v", utils.GetFnName(), inodeType)
 		err = blunder.AddError(err, blunder.InvalidInodeTypeError)
@@ -1100,11 +1196,51 @@ func (vS *volumeStruct) SetPermMode(inodeNumber InodeNumber, filePerm InodeMode)
 		return err
 	}
 
+	if (inode.Mode & PosixModePerm) != (fileMode & PosixModePerm) {
+		delete(inode.StreamMap, NFS4ACLStream) // the mode now describes access in full (see nfs4acl.go)
+	}
+
 	inode.dirty = true
 	inode.Mode = fileMode
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(inode)
+	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return err
+	}
+
+	return
+}
+
+func (vS *volumeStruct) SetFlags(inodeNumber InodeNumber, flags InodeFlags) (err error) {
+	if 0 != (flags &^ KnownFlags) {
+		err = blunder.NewError(blunder.InvalidArgError, "%s: unknown flags 0x%X", utils.GetFnName(), uint32(flags&^KnownFlags))
+		return
+	}
+
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if err != nil {
+		logger.ErrorfWithError(err, "%s: fetch of target inode failed", utils.GetFnName())
+		return err
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because its unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		logger.ErrorWithError(err)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return err
+	}
+
+	inode.dirty = true
+	inode.Flags = flags
+
+	updateTime := vS.timestamp(inode)
 	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
 
 	err = vS.flushInode(inode)
 	if err != nil {
@@ -1137,8 +1273,9 @@ func (vS *volumeStruct) SetOwnerUserID(inodeNumber InodeNumber, userID InodeUser
 	inode.dirty = true
 	inode.UserID = userID
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(inode)
 	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
 
 	err = vS.flushInode(inode)
 	if err != nil {
@@ -1172,8 +1309,9 @@ func (vS *volumeStruct) SetOwnerUserIDGroupID(inodeNumber InodeNumber, userID In
 	inode.UserID = userID
 	inode.GroupID = groupID
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(inode)
 	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
 
 	err = vS.flushInode(inode)
 	if err != nil {
@@ -1206,8 +1344,9 @@ func (vS *volumeStruct) SetOwnerGroupID(inodeNumber InodeNumber, groupID InodeGr
 	inode.dirty = true
 	inode.GroupID = groupID
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(inode)
 	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
 
 	err = vS.flushInode(inode)
 	if err != nil {
@@ -1277,8 +1416,40 @@ func (vS *volumeStruct) PutStream(inodeNumber InodeNumber, inodeStreamName strin
 	inode.dirty = true
 	inode.StreamMap[inodeStreamName] = inodeStreamBuf
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(inode)
 	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return err
+	}
+
+	return
+}
+
+// UpdateStream records buf as the inode's stream inodeStreamName. Unlike PutStream(), which is an explicit
+// attribute change, AttrChangeTime and ChangeCount are left untouched.
+func (vS *volumeStruct) UpdateStream(inodeNumber InodeNumber, inodeStreamName string, buf []byte) (err error) {
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if err != nil {
+		logger.ErrorfWithError(err, "%s: fetch of inode failed", utils.GetFnName())
+		return err
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because its unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return err
+	}
+
+	inodeStreamBuf := make([]byte, len(buf))
+
+	copy(inodeStreamBuf, buf)
+
+	inode.dirty = true
+	inode.StreamMap[inodeStreamName] = inodeStreamBuf
 
 	err = vS.flushInode(inode)
 	if err != nil {
@@ -1310,8 +1481,9 @@ func (vS *volumeStruct) DeleteStream(inodeNumber InodeNumber, inodeStreamName st
 	inode.dirty = true
 	delete(inode.StreamMap, inodeStreamName)
 
-	updateTime := time.Now()
+	updateTime := vS.timestamp(inode)
 	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
 
 	err = vS.flushInode(inode)
 	if err != nil {
@@ -1322,16 +1494,6 @@ func (vS *volumeStruct) DeleteStream(inodeNumber InodeNumber, inodeStreamName st
 	return
 }
 
-func (vS *volumeStruct) GetFragmentationReport(inodeNumber InodeNumber) (fragmentationReport FragmentationReport, err error) {
-	err = fmt.Errorf("GetFragmentationReport not yet implemented")
-	return
-}
-
-func (vS *volumeStruct) Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (err error) {
-	err = fmt.Errorf("Optimize not yet implemented")
-	return
-}
-
 func validateFileExtents(ourInode *inMemoryInodeStruct) (err error) {
 	var (
 		zero = uint64(0)
@@ -1503,7 +1665,7 @@ func (vS *volumeStruct) Validate(inodeNumber InodeNumber) (err error) {
 				return
 			}
 		}
-	case SymlinkType:
+	case SymlinkType, FIFOType, SocketType:
 		// Nothing to be done here
 	default:
 		err = fmt.Errorf("unrecognized inode type")
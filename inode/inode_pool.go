@@ -0,0 +1,144 @@
+package inode
+
+// Inode number preallocation
+//
+// Each new inode (of CreateFile(), CreateDir(), CreateSymlink(), etc.) needs an InodeNumber from
+// headhunter's FetchNonce(), which contends with checkpoints for the headhunter volume lock and,
+// whenever the range of nonces reserved in the checkpoint header is exhausted, waits on a POST to the
+// checkpoint container reserving the next. Namespace-creation-heavy workloads (e.g. untarring a kernel
+// tree) would thus see the latency of every so many creates spike. If [<volume-section>]InodePoolSize
+// is non-zero, each volume instead keeps a pool of up to that many InodeNumbers fetched in advance.
+// makeInMemoryInode() takes its InodeNumber from the pool (only calling FetchNonce() itself should the
+// pool be empty) and, whenever that leaves the pool less than half full, starts a refiller goroutine
+// (running only until the pool is full again) to top it up.
+//
+// As InodeNumbers are never reused, those remaining in the pool when the volume goes offline are simply
+// discarded. Use of the pool is reported via stats as InodePoolHitOps & InodePoolMissOps.
+
+import (
+	"sync"
+
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type inodePoolStruct struct {
+	sync.Mutex
+	size           uint64         // [<volume-section>]InodePoolSize (0 == no pool)
+	inodeNumbers   []InodeNumber  // preallocated InodeNumbers, next to be used first
+	refillerActive bool           // if true, a refiller goroutine is running
+	draining       bool           // if true, no refiller goroutine may be started
+	refillerWG     sync.WaitGroup // waited on by drainInodePool()
+}
+
+// fetchInodeNumber returns the next InodeNumber for a new inode of the volume.
+func (vS *volumeStruct) fetchInodeNumber() (inodeNumber InodeNumber, err error) {
+	pool := &vS.inodePool
+
+	pool.Lock()
+	if 0 == pool.size {
+		pool.Unlock()
+		inodeNumber, err = vS.fetchNonceInodeNumber()
+		return
+	}
+	if 0 < len(pool.inodeNumbers) {
+		inodeNumber = pool.inodeNumbers[0]
+		pool.inodeNumbers = pool.inodeNumbers[1:]
+		vS.startInodePoolRefillerWhileLocked()
+		pool.Unlock()
+		stats.IncrementOperations(&stats.InodePoolHitOps)
+		return
+	}
+	vS.startInodePoolRefillerWhileLocked()
+	pool.Unlock()
+
+	stats.IncrementOperations(&stats.InodePoolMissOps)
+	inodeNumber, err = vS.fetchNonceInodeNumber()
+	return
+}
+
+func (vS *volumeStruct) fetchNonceInodeNumber() (inodeNumber InodeNumber, err error) {
+	nonce, err := vS.headhunterVolumeHandle.FetchNonce()
+	if nil != err {
+		return
+	}
+	inodeNumber = InodeNumber(nonce)
+	return
+}
+
+// startInodePoolRefillerWhileLocked starts a refiller goroutine if the pool is less than half full.
+//
+// Caller holds vS.inodePool.Mutex.
+func (vS *volumeStruct) startInodePoolRefillerWhileLocked() {
+	pool := &vS.inodePool
+
+	if pool.refillerActive || pool.draining || (uint64(len(pool.inodeNumbers)) >= ((pool.size + 1) / 2)) {
+		return
+	}
+
+	pool.refillerActive = true
+	pool.refillerWG.Add(1)
+	go vS.inodePoolRefiller()
+}
+
+func (vS *volumeStruct) inodePoolRefiller() {
+	pool := &vS.inodePool
+
+	defer pool.refillerWG.Done()
+
+	for {
+		pool.Lock()
+		if pool.draining || (uint64(len(pool.inodeNumbers)) >= pool.size) {
+			pool.refillerActive = false
+			pool.Unlock()
+			return
+		}
+		pool.Unlock()
+
+		inodeNumber, err := vS.fetchNonceInodeNumber()
+		if nil != err {
+			logger.ErrorfWithError(err, "inode.inodePoolRefiller(): volume '%s' FetchNonce() failed", vS.volumeName)
+			pool.Lock()
+			pool.refillerActive = false
+			pool.Unlock()
+			return
+		}
+
+		pool.Lock()
+		pool.inodeNumbers = append(pool.inodeNumbers, inodeNumber)
+		pool.Unlock()
+	}
+}
+
+// configureInodePool sets the volume's InodePoolSize (discarding any InodeNumbers beyond it) and begins
+// filling the pool.
+//
+// Caller has already fetched vS.headhunterVolumeHandle.
+func (vS *volumeStruct) configureInodePool(size uint64) {
+	pool := &vS.inodePool
+
+	pool.Lock()
+	pool.size = size
+	pool.draining = false
+	if uint64(len(pool.inodeNumbers)) > size {
+		pool.inodeNumbers = pool.inodeNumbers[:size]
+	}
+	vS.startInodePoolRefillerWhileLocked()
+	pool.Unlock()
+}
+
+// drainInodePool stops any refiller goroutine and discards the pool's InodeNumbers (e.g. as the volume
+// goes offline). The pool remains empty until configureInodePool() is next called.
+func (vS *volumeStruct) drainInodePool() {
+	pool := &vS.inodePool
+
+	pool.Lock()
+	pool.draining = true
+	pool.Unlock()
+
+	pool.refillerWG.Wait()
+
+	pool.Lock()
+	pool.inodeNumbers = nil
+	pool.Unlock()
+}
@@ -0,0 +1,103 @@
+package inode
+
+import (
+	"testing"
+	"time"
+)
+
+func waitForInodePoolLen(t *testing.T, testVolume *volumeStruct, expectedLen int) {
+	for i := 0; i < 1000; i++ {
+		testVolume.inodePool.Lock()
+		poolLen := len(testVolume.inodePool.inodeNumbers)
+		refillerActive := testVolume.inodePool.refillerActive
+		testVolume.inodePool.Unlock()
+		if (expectedLen == poolLen) && !refillerActive {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("inode pool never reached %v InodeNumbers", expectedLen)
+}
+
+func TestInodePool(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+	testVolume := testVolumeHandle.(*volumeStruct)
+
+	defer func() {
+		testVolume.drainInodePool()
+		testVolume.configureInodePool(0)
+	}()
+
+	testVolume.configureInodePool(4)
+	waitForInodePoolLen(t, testVolume, 4)
+
+	// New inodes take their InodeNumbers from the pool in order...
+
+	testVolume.inodePool.Lock()
+	pooledInodeNumbers := append([]InodeNumber{}, testVolume.inodePool.inodeNumbers...)
+	testVolume.inodePool.Unlock()
+
+	var createdInodeNumbers []InodeNumber
+
+	for i := 0; i < 3; i++ {
+		fileInodeNumber, createErr := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+		if nil != createErr {
+			t.Fatalf("CreateFile() failed: %v", createErr)
+		}
+		if pooledInodeNumbers[i] != fileInodeNumber {
+			t.Fatalf("CreateFile() returned inode %v (expected pooled %v)", fileInodeNumber, pooledInodeNumbers[i])
+		}
+		createdInodeNumbers = append(createdInodeNumbers, fileInodeNumber)
+	}
+
+	// ...and the pool, having dropped below half full, is refilled with InodeNumbers not yet used
+
+	waitForInodePoolLen(t, testVolume, 4)
+
+	testVolume.inodePool.Lock()
+	refilledInodeNumbers := append([]InodeNumber{}, testVolume.inodePool.inodeNumbers...)
+	testVolume.inodePool.Unlock()
+
+	if pooledInodeNumbers[3] != refilledInodeNumbers[0] {
+		t.Fatalf("refilled inode pool %v does not begin with remaining pooled InodeNumber %v", refilledInodeNumbers, pooledInodeNumbers[3])
+	}
+
+	used := make(map[InodeNumber]struct{})
+	for _, inodeNumber := range append(createdInodeNumbers, refilledInodeNumbers...) {
+		_, ok := used[inodeNumber]
+		if ok {
+			t.Fatalf("refilled inode pool %v reuses InodeNumber %v", refilledInodeNumbers, inodeNumber)
+		}
+		used[inodeNumber] = struct{}{}
+	}
+
+	for _, inodeNumber := range createdInodeNumbers {
+		err = testVolumeHandle.Destroy(inodeNumber)
+		if nil != err {
+			t.Fatalf("Destroy() failed: %v", err)
+		}
+	}
+
+	// Once drained, new inodes fetch their InodeNumbers directly
+
+	testVolume.drainInodePool()
+	waitForInodePoolLen(t, testVolume, 0)
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() of drained pool failed: %v", err)
+	}
+	_, ok := used[fileInodeNumber]
+	if ok {
+		t.Fatalf("CreateFile() of drained pool reused InodeNumber %v", fileInodeNumber)
+	}
+	waitForInodePoolLen(t, testVolume, 0)
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestGetMetadataMulti(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(InodeMode(0640), InodeUserID(1), InodeGroupID(2))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	dirInodeNumber, err := testVolumeHandle.CreateDir(InodeMode(0750), InodeUserID(3), InodeGroupID(4))
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	unallocatedInodeNumber := dirInodeNumber + 1000000
+
+	// Purge one so that it must be fetched rather than found cached
+
+	err = testVolumeHandle.Flush(fileInodeNumber, true)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	inodeNumbers := []InodeNumber{fileInodeNumber, unallocatedInodeNumber, dirInodeNumber, fileInodeNumber}
+
+	metadataSlice, errs := testVolumeHandle.GetMetadataMulti(inodeNumbers)
+	if (len(inodeNumbers) != len(metadataSlice)) || (len(inodeNumbers) != len(errs)) {
+		t.Fatalf("GetMetadataMulti() returned %v metadata & %v errs (expected %v of each)", len(metadataSlice), len(errs), len(inodeNumbers))
+	}
+
+	for i, inodeNumber := range inodeNumbers {
+		if unallocatedInodeNumber == inodeNumber {
+			if !blunder.Is(errs[i], blunder.NotFoundError) {
+				t.Fatalf("GetMetadataMulti() of unallocated inode should have failed with NotFoundError, got: %v", errs[i])
+			}
+			continue
+		}
+		if nil != errs[i] {
+			t.Fatalf("GetMetadataMulti() of inode %v failed: %v", inodeNumber, errs[i])
+		}
+		metadata, getMetadataErr := testVolumeHandle.GetMetadata(inodeNumber)
+		if nil != getMetadataErr {
+			t.Fatalf("GetMetadata() failed: %v", getMetadataErr)
+		}
+		if (metadata.InodeType != metadataSlice[i].InodeType) || (metadata.Size != metadataSlice[i].Size) || (metadata.Mode != metadataSlice[i].Mode) || (metadata.UserID != metadataSlice[i].UserID) || (metadata.ChangeCount != metadataSlice[i].ChangeCount) {
+			t.Fatalf("GetMetadataMulti() returned %+v for inode %v (GetMetadata() returned %+v)", metadataSlice[i], inodeNumber, metadata)
+		}
+	}
+	if 10 != metadataSlice[0].Size {
+		t.Fatalf("GetMetadataMulti() returned Size %v (expected 10)", metadataSlice[0].Size)
+	}
+
+	metadataSlice, errs = testVolumeHandle.GetMetadataMulti(nil)
+	if (0 != len(metadataSlice)) || (0 != len(errs)) {
+		t.Fatalf("GetMetadataMulti(nil) should have returned nothing")
+	}
+
+	for _, inodeNumber := range []InodeNumber{fileInodeNumber, dirInodeNumber} {
+		err = testVolumeHandle.Destroy(inodeNumber)
+		if nil != err {
+			t.Fatalf("Destroy() failed: %v", err)
+		}
+	}
+}
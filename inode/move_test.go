@@ -0,0 +1,121 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestMoveFlags(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	createDir := func(parentDirInodeNumber InodeNumber, basename string) (dirInodeNumber InodeNumber) {
+		dirInodeNumber, err = testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+		if nil != err {
+			t.Fatalf("CreateDir() failed: %v", err)
+		}
+		err = testVolumeHandle.Link(parentDirInodeNumber, basename, dirInodeNumber)
+		if nil != err {
+			t.Fatalf("Link() of dir \"%s\" failed: %v", basename, err)
+		}
+		return
+	}
+	createFile := func(parentDirInodeNumber InodeNumber, basename string) (fileInodeNumber InodeNumber) {
+		fileInodeNumber, err = testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+		if nil != err {
+			t.Fatalf("CreateFile() failed: %v", err)
+		}
+		err = testVolumeHandle.Link(parentDirInodeNumber, basename, fileInodeNumber)
+		if nil != err {
+			t.Fatalf("Link() of file \"%s\" failed: %v", basename, err)
+		}
+		return
+	}
+	expectLookup := func(dirInodeNumber InodeNumber, basename string, expectedInodeNumber InodeNumber) {
+		lookupInodeNumber, lookupErr := testVolumeHandle.Lookup(dirInodeNumber, basename)
+		if nil != lookupErr {
+			t.Fatalf("Lookup(\"%s\") failed: %v", basename, lookupErr)
+		}
+		if expectedInodeNumber != lookupInodeNumber {
+			t.Fatalf("Lookup(\"%s\") returned %v (expected %v)", basename, lookupInodeNumber, expectedInodeNumber)
+		}
+	}
+	expectLinkCount := func(inodeNumber InodeNumber, expectedLinkCount uint64) {
+		linkCount, linkCountErr := testVolumeHandle.GetLinkCount(inodeNumber)
+		if nil != linkCountErr {
+			t.Fatalf("GetLinkCount(%v) failed: %v", inodeNumber, linkCountErr)
+		}
+		if expectedLinkCount != linkCount {
+			t.Fatalf("GetLinkCount(%v) returned %v (expected %v)", inodeNumber, linkCount, expectedLinkCount)
+		}
+	}
+
+	dirAInodeNumber := createDir(RootDirInodeNumber, "TestMoveFlagsDirA")
+	dirBInodeNumber := createDir(RootDirInodeNumber, "TestMoveFlagsDirB")
+	fileInodeNumber := createFile(dirAInodeNumber, "File")
+	otherFileInodeNumber := createFile(dirAInodeNumber, "OtherFile")
+	subDirInodeNumber := createDir(dirBInodeNumber, "SubDir")
+
+	err = testVolumeHandle.Move(dirAInodeNumber, "File", dirAInodeNumber, "OtherFile", MoveNoReplace|MoveExchange)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("Move() with MoveNoReplace|MoveExchange should have failed with InvalidArgError: %v", err)
+	}
+
+	// MoveNoReplace
+
+	err = testVolumeHandle.Move(dirAInodeNumber, "File", dirAInodeNumber, "OtherFile", MoveNoReplace)
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("Move(MoveNoReplace) onto existing entry should have failed with FileExistsError: %v", err)
+	}
+	expectLookup(dirAInodeNumber, "File", fileInodeNumber)
+	expectLookup(dirAInodeNumber, "OtherFile", otherFileInodeNumber)
+
+	err = testVolumeHandle.Move(dirAInodeNumber, "File", dirAInodeNumber, "RenamedFile", MoveNoReplace)
+	if nil != err {
+		t.Fatalf("Move(MoveNoReplace) onto absent entry failed: %v", err)
+	}
+	expectLookup(dirAInodeNumber, "RenamedFile", fileInodeNumber)
+
+	// MoveExchange
+
+	err = testVolumeHandle.Move(dirAInodeNumber, "RenamedFile", dirAInodeNumber, "Absent", MoveExchange)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Move(MoveExchange) with absent target should have failed with NotFoundError: %v", err)
+	}
+
+	err = testVolumeHandle.Move(dirAInodeNumber, "RenamedFile", dirAInodeNumber, "OtherFile", MoveExchange)
+	if nil != err {
+		t.Fatalf("Move(MoveExchange) within a directory failed: %v", err)
+	}
+	expectLookup(dirAInodeNumber, "RenamedFile", otherFileInodeNumber)
+	expectLookup(dirAInodeNumber, "OtherFile", fileInodeNumber)
+	expectLinkCount(fileInodeNumber, 1)
+	expectLinkCount(otherFileInodeNumber, 1)
+
+	// Exchanging a file & a directory across directories moves the directory to its new parent
+
+	expectLinkCount(dirAInodeNumber, 2)
+	expectLinkCount(dirBInodeNumber, 3)
+
+	err = testVolumeHandle.Move(dirAInodeNumber, "OtherFile", dirBInodeNumber, "SubDir", MoveExchange)
+	if nil != err {
+		t.Fatalf("Move(MoveExchange) across directories failed: %v", err)
+	}
+	expectLookup(dirAInodeNumber, "OtherFile", subDirInodeNumber)
+	expectLookup(dirBInodeNumber, "SubDir", fileInodeNumber)
+	expectLookup(subDirInodeNumber, "..", dirAInodeNumber)
+	expectLinkCount(dirAInodeNumber, 3)
+	expectLinkCount(dirBInodeNumber, 2)
+
+	err = testVolumeHandle.Validate(dirAInodeNumber)
+	if nil != err {
+		t.Fatalf("Validate() of dirA failed: %v", err)
+	}
+	err = testVolumeHandle.Validate(dirBInodeNumber)
+	if nil != err {
+		t.Fatalf("Validate() of dirB failed: %v", err)
+	}
+}
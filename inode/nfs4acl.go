@@ -0,0 +1,385 @@
+package inode
+
+// NFSv4 ACLs
+//
+// An inode may carry an NFSv4 ACL (RFC 7530 section 6), stored in its NFS4ACLStream in a dense binary
+// form: a little-endian uint32 format version (nfs4ACLVersion) and ACE count followed by each ACE's
+// Type, Flags, Mask, & Who as little-endian uint32s. Rather than a string, Who is a UserID (or, with
+// NFS4ACEIdentifierGroup, a GroupID) or, with NFS4ACESpecialWho, one of the NFS4Who* special identifiers.
+//
+// While an inode has an ACL, Access() evaluates its ACEs in order (rather than the inode's mode bits) for
+// R_OK (NFS4MaskReadData), W_OK (NFS4MaskWriteData & NFS4MaskAppendData), and X_OK (NFS4MaskExecute):
+// each ACE applying to the caller allows or denies whichever of its Mask bits are requested and not yet
+// decided, any left undecided being denied. Audit & alarm ACEs, inherit-only ACEs, and the remaining Mask
+// bits are stored for the benefit of the gateway but play no part in Access(). As before, root (and the
+// P_OK ownership check) bypasses the ACL.
+//
+// SetNFS4ACL() also sets the inode's permission bits to those the ACL grants OWNER@, GROUP@, & EVERYONE@
+// (see Mode()). Conversely, SetPermMode() (i.e. chmod) changing the permission bits discards any ACL as
+// the new mode then describes access in full (changing only the setuid, setgid, or sticky bits keeps it),
+// while GetNFS4ACL() of an inode without an ACL returns the one equivalent to its mode (see
+// NFS4ACLFromMode()).
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// NFS4ACLStream is the stream holding an inode's NFS4ACL (if any).
+const NFS4ACLStream = "proxyfs.nfs4acl"
+
+// NFS4ACLMaxACEs limits the number of ACEs in an NFS4ACL.
+const NFS4ACLMaxACEs = 1024
+
+const (
+	nfs4ACLVersion    = uint32(1)
+	nfs4ACLHeaderSize = 8
+	nfs4ACESize       = 16
+)
+
+// NFS4ACEType values match RFC 7530's acetype4.
+type NFS4ACEType uint32
+
+const (
+	NFS4ACEAccessAllowed NFS4ACEType = 0x00000000
+	NFS4ACEAccessDenied  NFS4ACEType = 0x00000001
+	NFS4ACESystemAudit   NFS4ACEType = 0x00000002
+	NFS4ACESystemAlarm   NFS4ACEType = 0x00000003
+)
+
+// NFS4ACEFlags values match RFC 7530's aceflag4 (plus NFS4ACESpecialWho, as used by Linux richacls).
+type NFS4ACEFlags uint32
+
+const (
+	NFS4ACEFileInherit        NFS4ACEFlags = 0x00000001
+	NFS4ACEDirectoryInherit   NFS4ACEFlags = 0x00000002
+	NFS4ACENoPropagateInherit NFS4ACEFlags = 0x00000004
+	NFS4ACEInheritOnly        NFS4ACEFlags = 0x00000008
+	NFS4ACESuccessfulAccess   NFS4ACEFlags = 0x00000010
+	NFS4ACEFailedAccess       NFS4ACEFlags = 0x00000020
+	NFS4ACEIdentifierGroup    NFS4ACEFlags = 0x00000040
+	NFS4ACEInheritedACE       NFS4ACEFlags = 0x00000080
+	NFS4ACESpecialWho         NFS4ACEFlags = 0x00004000 // Who is one of the NFS4Who* special identifiers
+	NFS4ACEKnownFlags                      = NFS4ACEFileInherit | NFS4ACEDirectoryInherit | NFS4ACENoPropagateInherit | NFS4ACEInheritOnly | NFS4ACESuccessfulAccess | NFS4ACEFailedAccess | NFS4ACEIdentifierGroup | NFS4ACEInheritedACE | NFS4ACESpecialWho
+)
+
+// NFS4ACEMask values match RFC 7530's acemask4 (the directory variants sharing the values of their file counterparts).
+type NFS4ACEMask uint32
+
+const (
+	NFS4MaskReadData        NFS4ACEMask = 0x00000001 // aka ACE4_LIST_DIRECTORY
+	NFS4MaskWriteData       NFS4ACEMask = 0x00000002 // aka ACE4_ADD_FILE
+	NFS4MaskAppendData      NFS4ACEMask = 0x00000004 // aka ACE4_ADD_SUBDIRECTORY
+	NFS4MaskReadNamedAttrs  NFS4ACEMask = 0x00000008
+	NFS4MaskWriteNamedAttrs NFS4ACEMask = 0x00000010
+	NFS4MaskExecute         NFS4ACEMask = 0x00000020
+	NFS4MaskDeleteChild     NFS4ACEMask = 0x00000040
+	NFS4MaskReadAttributes  NFS4ACEMask = 0x00000080
+	NFS4MaskWriteAttributes NFS4ACEMask = 0x00000100
+	NFS4MaskDelete          NFS4ACEMask = 0x00010000
+	NFS4MaskReadACL         NFS4ACEMask = 0x00020000
+	NFS4MaskWriteACL        NFS4ACEMask = 0x00040000
+	NFS4MaskWriteOwner      NFS4ACEMask = 0x00080000
+	NFS4MaskSynchronize     NFS4ACEMask = 0x00100000
+	NFS4MaskKnown                       = NFS4MaskReadData | NFS4MaskWriteData | NFS4MaskAppendData | NFS4MaskReadNamedAttrs | NFS4MaskWriteNamedAttrs | NFS4MaskExecute | NFS4MaskDeleteChild | NFS4MaskReadAttributes | NFS4MaskWriteAttributes | NFS4MaskDelete | NFS4MaskReadACL | NFS4MaskWriteACL | NFS4MaskWriteOwner | NFS4MaskSynchronize
+
+	nfs4MaskR = NFS4MaskReadData                       // R_OK
+	nfs4MaskW = NFS4MaskWriteData | NFS4MaskAppendData // W_OK
+	nfs4MaskX = NFS4MaskExecute                        // X_OK
+)
+
+// The following are the Who of an NFS4ACEStruct flagged NFS4ACESpecialWho.
+const (
+	NFS4WhoOwner    = uint32(0) // OWNER@
+	NFS4WhoGroup    = uint32(1) // GROUP@
+	NFS4WhoEveryone = uint32(2) // EVERYONE@
+)
+
+type NFS4ACEStruct struct {
+	Type  NFS4ACEType
+	Flags NFS4ACEFlags
+	Mask  NFS4ACEMask
+	Who   uint32 // see NFS4ACESpecialWho & NFS4ACEIdentifierGroup
+}
+
+type NFS4ACL []NFS4ACEStruct
+
+// validate fails with InvalidArgError should acl be malformed.
+func (acl NFS4ACL) validate() (err error) {
+	if NFS4ACLMaxACEs < len(acl) {
+		err = blunder.NewError(blunder.InvalidArgError, "NFS4ACL of %v ACEs exceeds NFS4ACLMaxACEs (%v)", len(acl), NFS4ACLMaxACEs)
+		return
+	}
+
+	for i, ace := range acl {
+		if NFS4ACESystemAlarm < ace.Type {
+			err = blunder.NewError(blunder.InvalidArgError, "NFS4ACL ACE %v has unknown Type 0x%X", i, uint32(ace.Type))
+			return
+		}
+		if 0 != (ace.Flags &^ NFS4ACEKnownFlags) {
+			err = blunder.NewError(blunder.InvalidArgError, "NFS4ACL ACE %v has unknown Flags 0x%X", i, uint32(ace.Flags&^NFS4ACEKnownFlags))
+			return
+		}
+		if 0 != (ace.Mask &^ NFS4MaskKnown) {
+			err = blunder.NewError(blunder.InvalidArgError, "NFS4ACL ACE %v has unknown Mask bits 0x%X", i, uint32(ace.Mask&^NFS4MaskKnown))
+			return
+		}
+		if (0 != (ace.Flags & NFS4ACESpecialWho)) && (NFS4WhoEveryone < ace.Who) {
+			err = blunder.NewError(blunder.InvalidArgError, "NFS4ACL ACE %v has unknown special Who %v", i, ace.Who)
+			return
+		}
+	}
+
+	return
+}
+
+func (acl NFS4ACL) encode() (buf []byte) {
+	buf = make([]byte, nfs4ACLHeaderSize+nfs4ACESize*len(acl))
+
+	binary.LittleEndian.PutUint32(buf[0:], nfs4ACLVersion)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(acl)))
+
+	for i, ace := range acl {
+		aceBuf := buf[nfs4ACLHeaderSize+nfs4ACESize*i:]
+		binary.LittleEndian.PutUint32(aceBuf[0:], uint32(ace.Type))
+		binary.LittleEndian.PutUint32(aceBuf[4:], uint32(ace.Flags))
+		binary.LittleEndian.PutUint32(aceBuf[8:], uint32(ace.Mask))
+		binary.LittleEndian.PutUint32(aceBuf[12:], ace.Who)
+	}
+
+	return
+}
+
+func decodeNFS4ACL(buf []byte) (acl NFS4ACL, err error) {
+	if nfs4ACLHeaderSize > len(buf) {
+		err = fmt.Errorf("NFS4ACL of %v bytes lacks a header", len(buf))
+		return
+	}
+
+	version := binary.LittleEndian.Uint32(buf[0:])
+	if nfs4ACLVersion != version {
+		err = fmt.Errorf("NFS4ACL format version %v unsupported", version)
+		return
+	}
+
+	aceCount := binary.LittleEndian.Uint32(buf[4:])
+	if uint64(len(buf)) != uint64(nfs4ACLHeaderSize)+uint64(nfs4ACESize)*uint64(aceCount) {
+		err = fmt.Errorf("NFS4ACL of %v ACEs is %v bytes", aceCount, len(buf))
+		return
+	}
+
+	acl = make(NFS4ACL, aceCount)
+
+	for i := range acl {
+		aceBuf := buf[nfs4ACLHeaderSize+nfs4ACESize*i:]
+		acl[i].Type = NFS4ACEType(binary.LittleEndian.Uint32(aceBuf[0:]))
+		acl[i].Flags = NFS4ACEFlags(binary.LittleEndian.Uint32(aceBuf[4:]))
+		acl[i].Mask = NFS4ACEMask(binary.LittleEndian.Uint32(aceBuf[8:]))
+		acl[i].Who = binary.LittleEndian.Uint32(aceBuf[12:])
+	}
+
+	return
+}
+
+// permits reports whether acl, evaluated for each ACE that applies (see above), allows every bit of mask.
+func (acl NFS4ACL) permits(mask NFS4ACEMask, applies func(ace *NFS4ACEStruct) bool) bool {
+	undecided := mask
+
+	for i := range acl {
+		ace := &acl[i]
+
+		if (0 != (ace.Flags & NFS4ACEInheritOnly)) || !applies(ace) {
+			continue
+		}
+
+		switch ace.Type {
+		case NFS4ACEAccessAllowed:
+			undecided &^= ace.Mask
+		case NFS4ACEAccessDenied:
+			if 0 != (undecided & ace.Mask) {
+				return false
+			}
+		}
+
+		if 0 == undecided {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSpecialWho reports whether ace applies to the special identifier who.
+func (ace *NFS4ACEStruct) isSpecialWho(who uint32) bool {
+	return (0 != (ace.Flags & NFS4ACESpecialWho)) && (who == ace.Who)
+}
+
+// Mode returns the permission bits acl grants OWNER@, GROUP@, & EVERYONE@ (considering only the ACEs
+// naming them).
+func (acl NFS4ACL) Mode() (mode InodeMode) {
+	classes := []func(ace *NFS4ACEStruct) bool{
+		func(ace *NFS4ACEStruct) bool {
+			return ace.isSpecialWho(NFS4WhoOwner) || ace.isSpecialWho(NFS4WhoEveryone)
+		},
+		func(ace *NFS4ACEStruct) bool {
+			return ace.isSpecialWho(NFS4WhoGroup) || ace.isSpecialWho(NFS4WhoEveryone)
+		},
+		func(ace *NFS4ACEStruct) bool { return ace.isSpecialWho(NFS4WhoEveryone) },
+	}
+
+	for _, applies := range classes {
+		mode <<= 3
+		if acl.permits(nfs4MaskR, applies) {
+			mode |= R_OK
+		}
+		if acl.permits(nfs4MaskW, applies) {
+			mode |= W_OK
+		}
+		if acl.permits(nfs4MaskX, applies) {
+			mode |= X_OK
+		}
+	}
+
+	return
+}
+
+// nfs4MaskOfMode returns the NFS4ACEMask equivalent to the R_OK, W_OK, & X_OK bits of mode.
+func nfs4MaskOfMode(mode InodeMode) (mask NFS4ACEMask) {
+	if 0 != (mode & R_OK) {
+		mask |= nfs4MaskR
+	}
+	if 0 != (mode & W_OK) {
+		mask |= nfs4MaskW
+	}
+	if 0 != (mode & X_OK) {
+		mask |= nfs4MaskX
+	}
+	return
+}
+
+// NFS4ACLFromMode returns the NFS4ACL granting exactly the access of mode's permission bits. Each of OWNER@
+// and GROUP@ has an allow ACE followed by a deny ACE of what it lacks (lest a later ACE grant it), then
+// EVERYONE@ an allow ACE.
+func NFS4ACLFromMode(mode InodeMode) (acl NFS4ACL) {
+	allRWX := nfs4MaskR | nfs4MaskW | nfs4MaskX
+
+	acl = make(NFS4ACL, 0, 5)
+
+	for _, class := range []struct {
+		who  uint32
+		mask NFS4ACEMask
+	}{
+		{NFS4WhoOwner, nfs4MaskOfMode(mode >> 6)},
+		{NFS4WhoGroup, nfs4MaskOfMode(mode >> 3)},
+	} {
+		if 0 != class.mask {
+			acl = append(acl, NFS4ACEStruct{Type: NFS4ACEAccessAllowed, Flags: NFS4ACESpecialWho, Mask: class.mask, Who: class.who})
+		}
+		if allRWX != class.mask {
+			acl = append(acl, NFS4ACEStruct{Type: NFS4ACEAccessDenied, Flags: NFS4ACESpecialWho, Mask: allRWX &^ class.mask, Who: class.who})
+		}
+	}
+
+	everyoneMask := nfs4MaskOfMode(mode)
+	if 0 != everyoneMask {
+		acl = append(acl, NFS4ACEStruct{Type: NFS4ACEAccessAllowed, Flags: NFS4ACESpecialWho, Mask: everyoneMask, Who: NFS4WhoEveryone})
+	}
+
+	return
+}
+
+// nfs4ACLAccess is Access() of inode (whose ACL is acl) for a caller other than root.
+func nfs4ACLAccess(acl NFS4ACL, inode *inMemoryInodeStruct, userID InodeUserID, groupID InodeGroupID, otherGroupIDs []InodeGroupID, accessMode InodeMode) bool {
+	inGroup := func(gid InodeGroupID) bool {
+		if groupID == gid {
+			return true
+		}
+		for _, otherGroupID := range otherGroupIDs {
+			if otherGroupID == gid {
+				return true
+			}
+		}
+		return false
+	}
+
+	applies := func(ace *NFS4ACEStruct) bool {
+		switch {
+		case 0 != (ace.Flags & NFS4ACESpecialWho):
+			switch ace.Who {
+			case NFS4WhoOwner:
+				return userID == inode.UserID
+			case NFS4WhoGroup:
+				return inGroup(inode.GroupID)
+			default:
+				return NFS4WhoEveryone == ace.Who
+			}
+		case 0 != (ace.Flags & NFS4ACEIdentifierGroup):
+			return inGroup(InodeGroupID(ace.Who))
+		default:
+			return userID == InodeUserID(ace.Who)
+		}
+	}
+
+	return acl.permits(nfs4MaskOfMode(accessMode), applies)
+}
+
+// GetNFS4ACL returns the NFS4ACL of inodeNumber or, if it has none, that equivalent to its mode.
+func (vS *volumeStruct) GetNFS4ACL(inodeNumber InodeNumber) (acl NFS4ACL, err error) {
+	inode, err := vS.fetchStreamInode(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	aclBuf, ok := inode.StreamMap[NFS4ACLStream]
+	if !ok {
+		acl = NFS4ACLFromMode(inode.Mode)
+		return
+	}
+
+	acl, err = decodeNFS4ACL(aclBuf)
+	if nil != err {
+		err = blunder.NewError(blunder.CorruptInodeError, "%s: inode %v volume '%s': %v", utils.GetFnName(), inodeNumber, vS.volumeName, err)
+	}
+	return
+}
+
+// SetNFS4ACL sets the NFS4ACL of inodeNumber (or, if acl is nil, removes it) along with the permission bits
+// of its mode (see Mode()). Removing the ACL leaves the mode unchanged.
+func (vS *volumeStruct) SetNFS4ACL(inodeNumber InodeNumber, acl NFS4ACL) (err error) {
+	if nil != acl {
+		err = acl.validate()
+		if nil != err {
+			return
+		}
+	}
+
+	inode, err := vS.fetchStreamInode(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if nil == acl {
+		delete(inode.StreamMap, NFS4ACLStream)
+	} else {
+		inode.StreamMap[NFS4ACLStream] = acl.encode()
+		inode.Mode = (inode.Mode &^ PosixModePerm) | acl.Mode()
+	}
+
+	inode.dirty = true
+
+	updateTime := vS.timestamp(inode)
+	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return err
+	}
+
+	return
+}
@@ -0,0 +1,200 @@
+package inode
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestNFS4ACLFromMode(t *testing.T) {
+	for mode := InodeMode(0); mode <= PosixModePerm; mode++ {
+		acl := NFS4ACLFromMode(mode)
+		if mode != acl.Mode() {
+			t.Fatalf("NFS4ACLFromMode(0%03o).Mode() returned 0%03o", mode, acl.Mode())
+		}
+
+		decodedACL, err := decodeNFS4ACL(acl.encode())
+		if nil != err {
+			t.Fatalf("decodeNFS4ACL() of NFS4ACLFromMode(0%03o) failed: %v", mode, err)
+		}
+		if !reflect.DeepEqual(acl, decodedACL) {
+			t.Fatalf("decodeNFS4ACL() of NFS4ACLFromMode(0%03o) returned %v", mode, decodedACL)
+		}
+	}
+
+	_, err := decodeNFS4ACL([]byte{0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00})
+	if nil == err {
+		t.Fatalf("decodeNFS4ACL() of truncated ACL should have failed")
+	}
+}
+
+func TestNFS4ACL(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(InodeMode(0640), InodeUserID(1), InodeGroupID(2))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	acl, err := testVolumeHandle.GetNFS4ACL(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetNFS4ACL() failed: %v", err)
+	}
+	if !reflect.DeepEqual(NFS4ACLFromMode(0640), acl) {
+		t.Fatalf("GetNFS4ACL() of file without ACL returned %v", acl)
+	}
+
+	// The ACL equivalent to the mode grants the same access
+
+	err = testVolumeHandle.SetNFS4ACL(fileInodeNumber, acl)
+	if nil != err {
+		t.Fatalf("SetNFS4ACL() failed: %v", err)
+	}
+
+	accessTests := []struct {
+		userID        InodeUserID
+		groupID       InodeGroupID
+		otherGroupIDs []InodeGroupID
+		accessMode    InodeMode
+		expected      bool
+	}{
+		{1, 9, nil, R_OK | W_OK, true},
+		{1, 9, nil, X_OK, false},
+		{3, 2, nil, R_OK, true},
+		{3, 2, nil, W_OK, false},
+		{3, 9, []InodeGroupID{2}, R_OK, true},
+		{3, 9, nil, R_OK, false},
+	}
+	for _, accessTest := range accessTests {
+		if accessTest.expected != testVolumeHandle.Access(fileInodeNumber, accessTest.userID, accessTest.groupID, accessTest.otherGroupIDs, accessTest.accessMode) {
+			t.Fatalf("Access(%v, %v, %v, 0%o) of mode-equivalent ACL should have returned %v", accessTest.userID, accessTest.groupID, accessTest.otherGroupIDs, accessTest.accessMode, accessTest.expected)
+		}
+	}
+
+	// A richer ACL is evaluated in order (and determines the mode)
+
+	metadata, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	changeCount := metadata.ChangeCount
+
+	acl = NFS4ACL{
+		{Type: NFS4ACEAccessAllowed, Mask: NFS4MaskReadData | NFS4MaskWriteData | NFS4MaskAppendData, Who: 5},
+		{Type: NFS4ACEAccessDenied, Flags: NFS4ACEIdentifierGroup, Mask: NFS4MaskReadData, Who: 7},
+		{Type: NFS4ACEAccessAllowed, Flags: NFS4ACEInheritOnly | NFS4ACESpecialWho, Mask: NFS4MaskWriteData | NFS4MaskAppendData, Who: NFS4WhoEveryone},
+		{Type: NFS4ACEAccessAllowed, Flags: NFS4ACESpecialWho, Mask: NFS4MaskReadData, Who: NFS4WhoEveryone},
+		{Type: NFS4ACESystemAudit, Flags: NFS4ACEFailedAccess | NFS4ACESpecialWho, Mask: NFS4MaskWriteData, Who: NFS4WhoEveryone},
+	}
+
+	err = testVolumeHandle.SetNFS4ACL(fileInodeNumber, acl)
+	if nil != err {
+		t.Fatalf("SetNFS4ACL() failed: %v", err)
+	}
+
+	fetchedACL, err := testVolumeHandle.GetNFS4ACL(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetNFS4ACL() failed: %v", err)
+	}
+	if !reflect.DeepEqual(acl, fetchedACL) {
+		t.Fatalf("GetNFS4ACL() returned %v (expected %v)", fetchedACL, acl)
+	}
+
+	metadata, err = testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if (PosixModeFile | 0444) != metadata.Mode {
+		t.Fatalf("SetNFS4ACL() should have set Mode to 0%o (not 0%o)", PosixModeFile|0444, metadata.Mode)
+	}
+	if changeCount == metadata.ChangeCount {
+		t.Fatalf("SetNFS4ACL() should have incremented ChangeCount")
+	}
+
+	accessTests = []struct {
+		userID        InodeUserID
+		groupID       InodeGroupID
+		otherGroupIDs []InodeGroupID
+		accessMode    InodeMode
+		expected      bool
+	}{
+		{5, 7, nil, R_OK | W_OK, true},
+		{6, 7, nil, R_OK, false},
+		{6, 9, []InodeGroupID{7}, R_OK, false},
+		{6, 9, nil, R_OK, true},
+		{6, 9, nil, W_OK, false},
+		{1, 2, nil, W_OK, false},
+		{InodeRootUserID, 9, nil, R_OK | W_OK | X_OK, true},
+	}
+	for _, accessTest := range accessTests {
+		if accessTest.expected != testVolumeHandle.Access(fileInodeNumber, accessTest.userID, accessTest.groupID, accessTest.otherGroupIDs, accessTest.accessMode) {
+			t.Fatalf("Access(%v, %v, %v, 0%o) should have returned %v", accessTest.userID, accessTest.groupID, accessTest.otherGroupIDs, accessTest.accessMode, accessTest.expected)
+		}
+	}
+	if !testVolumeHandle.Access(fileInodeNumber, 1, 9, nil, P_OK) {
+		t.Fatalf("Access(P_OK) by the owner should not be subject to the ACL")
+	}
+
+	// Malformed ACLs are refused
+
+	err = testVolumeHandle.SetNFS4ACL(fileInodeNumber, NFS4ACL{{Type: NFS4ACESystemAlarm + 1}})
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("SetNFS4ACL() of unknown Type should have failed with InvalidArgError, got: %v", err)
+	}
+	err = testVolumeHandle.SetNFS4ACL(fileInodeNumber, NFS4ACL{{Flags: NFS4ACESpecialWho, Who: NFS4WhoEveryone + 1}})
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("SetNFS4ACL() of unknown special Who should have failed with InvalidArgError, got: %v", err)
+	}
+	err = testVolumeHandle.SetNFS4ACL(fileInodeNumber, NFS4ACL{{Mask: 0x80000000}})
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("SetNFS4ACL() of unknown Mask bits should have failed with InvalidArgError, got: %v", err)
+	}
+
+	// SetPermMode() discards the ACL
+
+	err = testVolumeHandle.SetPermMode(fileInodeNumber, 0600)
+	if nil != err {
+		t.Fatalf("SetPermMode() failed: %v", err)
+	}
+	acl, err = testVolumeHandle.GetNFS4ACL(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetNFS4ACL() failed: %v", err)
+	}
+	if !reflect.DeepEqual(NFS4ACLFromMode(0600), acl) {
+		t.Fatalf("GetNFS4ACL() after SetPermMode() returned %v", acl)
+	}
+	if testVolumeHandle.Access(fileInodeNumber, 5, 9, nil, R_OK) {
+		t.Fatalf("Access() after SetPermMode() should not have consulted the discarded ACL")
+	}
+
+	// As does SetNFS4ACL() of nil, leaving the mode as is
+
+	err = testVolumeHandle.SetNFS4ACL(fileInodeNumber, NFS4ACL{{Type: NFS4ACEAccessAllowed, Flags: NFS4ACESpecialWho, Mask: NFS4MaskReadData, Who: NFS4WhoEveryone}})
+	if nil != err {
+		t.Fatalf("SetNFS4ACL() failed: %v", err)
+	}
+	err = testVolumeHandle.SetNFS4ACL(fileInodeNumber, nil)
+	if nil != err {
+		t.Fatalf("SetNFS4ACL(nil) failed: %v", err)
+	}
+	_, err = testVolumeHandle.GetStream(fileInodeNumber, NFS4ACLStream)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("SetNFS4ACL(nil) should have removed NFS4ACLStream, got: %v", err)
+	}
+	metadata, err = testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if (PosixModeFile | 0444) != metadata.Mode {
+		t.Fatalf("SetNFS4ACL(nil) should have left Mode 0%o (not 0%o)", PosixModeFile|0444, metadata.Mode)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
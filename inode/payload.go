@@ -156,11 +156,12 @@ func (c *dirInodeCallbacks) DumpKey(key sortedmap.Key) (keyAsString string, err
 }
 
 func (c *dirInodeCallbacks) DumpValue(value sortedmap.Value) (valueAsString string, err error) {
-	valueAsUint64, ok := value.(InodeNumber)
+	valueAsDirEntryValue, ok := value.(dirEntryValue)
 	if !ok {
-		err = fmt.Errorf("dirInodeCallbacks.DumpValue() could not parse value as a uint64")
+		err = fmt.Errorf("dirInodeCallbacks.DumpValue() could not parse value as a dirEntryValue")
 		return
 	}
+	valueAsUint64 := uint64(valueAsDirEntryValue)
 
 	valueAsString = fmt.Sprintf("0x%016X", valueAsUint64)
 
@@ -181,12 +182,12 @@ func (c *dirInodeCallbacks) PackKey(key sortedmap.Key) (packedKey []byte, err er
 }
 
 func (c *dirInodeCallbacks) PackValue(value sortedmap.Value) (packedValue []byte, err error) {
-	valueAsInodeNumber, ok := value.(InodeNumber)
+	valueAsDirEntryValue, ok := value.(dirEntryValue)
 	if !ok {
-		err = fmt.Errorf("PackValue() arg is not an InodeNumber")
+		err = fmt.Errorf("PackValue() arg is not a dirEntryValue")
 		return
 	}
-	valueAsUint64 := uint64(valueAsInodeNumber)
+	valueAsUint64 := uint64(valueAsDirEntryValue)
 	packedValue, err = cstruct.Pack(valueAsUint64, sortedmap.OnDiskByteOrder)
 	return
 }
@@ -207,7 +208,7 @@ func (c *dirInodeCallbacks) UnpackValue(payloadData []byte) (value sortedmap.Val
 	if nil != err {
 		return
 	}
-	value = InodeNumber(valueAsUint64)
+	value = dirEntryValue(valueAsUint64)
 	bytesConsumed = 8
 	return
 }
@@ -0,0 +1,293 @@
+package inode
+
+// Cache pinning
+//
+// A pinned inode is exempt from eviction from vS.inodeCache (see Purge() & flush()). For a
+// file inode, the Read Cache Lines covering its data (as of the time it was pinned) are also
+// loaded into flowControl.readCache and unlinked from the MRU/LRU list so that they are never
+// chosen for eviction. Pinned Read Cache Lines continue to count against readCacheLineCount,
+// so pinning consumes (rather than adds to) the memory budget of the Read Cache. To ensure the
+// Read Cache remains usable, at least one Read Cache Line is always left unpinned.
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+type pinnedInodeStruct struct {
+	readCacheKeys []readCacheKeyStruct // Read Cache Lines pinned on behalf of this inode
+}
+
+// unlinkReadCacheElement removes readCacheElement from the MRU/LRU list.
+//
+// Caller must hold flowControl.Mutex.
+func unlinkReadCacheElement(flowControl *flowControlStruct, readCacheElement *readCacheElementStruct) {
+	if nil == readCacheElement.prev {
+		flowControl.readCacheMRU = readCacheElement.next
+	} else {
+		readCacheElement.prev.next = readCacheElement.next
+	}
+	if nil == readCacheElement.next {
+		flowControl.readCacheLRU = readCacheElement.prev
+	} else {
+		readCacheElement.next.prev = readCacheElement.prev
+	}
+	readCacheElement.next = nil
+	readCacheElement.prev = nil
+}
+
+// linkReadCacheElementAtMRU inserts readCacheElement at the MRU position of the MRU/LRU list.
+//
+// Caller must hold flowControl.Mutex.
+func linkReadCacheElementAtMRU(flowControl *flowControlStruct, readCacheElement *readCacheElementStruct) {
+	readCacheElement.prev = nil
+	readCacheElement.next = flowControl.readCacheMRU
+	if nil == flowControl.readCacheMRU {
+		flowControl.readCacheLRU = readCacheElement
+	} else {
+		flowControl.readCacheMRU.prev = readCacheElement
+	}
+	flowControl.readCacheMRU = readCacheElement
+}
+
+// pinReadCacheElement increments readCacheElement's pinCount, unlinking it from the MRU/LRU list if necessary.
+//
+// Caller must hold flowControl.Mutex.
+func pinReadCacheElement(flowControl *flowControlStruct, readCacheElement *readCacheElementStruct) (err error) {
+	if 0 == readCacheElement.pinCount {
+		if (flowControl.readCachePinned + 1) >= flowControl.readCacheLineCount {
+			err = fmt.Errorf("FlowControl \"%v\" cannot pin more than %v Read Cache Lines", flowControl.flowControlName, flowControl.readCachePinned)
+			err = blunder.AddError(err, blunder.NoSpaceError)
+			return
+		}
+		unlinkReadCacheElement(flowControl, readCacheElement)
+		flowControl.readCachePinned++
+	}
+	readCacheElement.pinCount++
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) pinReadCacheLine(step ReadPlanStep, readCacheKey readCacheKeyStruct) (err error) {
+	var (
+		cacheLine        []byte
+		flowControl      = vS.flowControl
+		readCacheElement *readCacheElementStruct
+		readCacheHit     bool
+	)
+
+	for {
+		flowControl.Lock()
+		readCacheElement, readCacheHit = flowControl.readCache[readCacheKey]
+		if readCacheHit {
+			err = pinReadCacheElement(flowControl, readCacheElement)
+			flowControl.Unlock()
+			return
+		}
+		if nil != cacheLine {
+			if uint64(len(flowControl.readCache)) >= flowControl.readCacheLineCount {
+				_ = evictReadCacheLRU(flowControl)
+			}
+			readCacheElement = &readCacheElementStruct{
+				readCacheKey: readCacheKey,
+				next:         nil,
+				prev:         nil,
+				cacheLine:    cacheLine,
+			}
+			linkReadCacheElementAtMRU(flowControl, readCacheElement)
+			flowControl.readCache[readCacheKey] = readCacheElement
+			// Should pinning fail, the fetched Read Cache Line simply remains (unpinned) in the Read Cache
+			err = pinReadCacheElement(flowControl, readCacheElement)
+			flowControl.Unlock()
+			return
+		}
+		flowControl.Unlock()
+
+		stats.IncrementOperations(&stats.FileReadcacheMissOps)
+		cacheLine, err = swiftclient.ObjectGet(step.AccountName, step.ContainerName, step.ObjectName, readCacheKey.cacheLineTag*flowControl.readCacheLineSize, flowControl.readCacheLineSize)
+		if nil != err {
+			logger.ErrorfWithError(err, "Reading from LogSegment object failed - pinning")
+			err = blunder.AddError(err, blunder.SegReadError)
+			return
+		}
+	}
+}
+
+func (vS *volumeStruct) unpinReadCacheLine(readCacheKey readCacheKeyStruct) {
+	flowControl := vS.flowControl
+
+	flowControl.Lock()
+	defer flowControl.Unlock()
+
+	readCacheElement, readCacheHit := flowControl.readCache[readCacheKey]
+	if !readCacheHit || (0 == readCacheElement.pinCount) {
+		logger.Errorf("%s: Read Cache Line %+v was not pinned", utils.GetFnName(), readCacheKey)
+		return
+	}
+
+	readCacheElement.pinCount--
+	if 0 == readCacheElement.pinCount {
+		// Return to the MRU position so that it ages out of the Read Cache normally
+		flowControl.readCachePinned--
+		linkReadCacheElementAtMRU(flowControl, readCacheElement)
+	}
+}
+
+func (vS *volumeStruct) pinFileData(fileInodeNumber InodeNumber) (readCacheKeys []readCacheKeyStruct, err error) {
+	var (
+		cacheLineTag      uint64
+		offset            = uint64(0)
+		readCacheKey      readCacheKeyStruct
+		readCacheKeySet   map[readCacheKeyStruct]struct{}
+		readCacheLineSize = vS.flowControl.readCacheLineSize
+		readPlan          []ReadPlanStep
+	)
+
+	readPlan, err = vS.GetReadPlan(fileInodeNumber, &offset, nil)
+	if nil != err {
+		return
+	}
+
+	readCacheKeys = make([]readCacheKeyStruct, 0)
+	readCacheKeySet = make(map[readCacheKeyStruct]struct{})
+	readCacheKey.volumeName = vS.volumeName
+
+	for _, step := range readPlan {
+		if (0 == step.LogSegmentNumber) || (0 == step.Length) {
+			// Zero-fill steps have nothing to cache
+			continue
+		}
+		readCacheKey.logSegmentNumber = step.LogSegmentNumber
+		for cacheLineTag = step.Offset / readCacheLineSize; cacheLineTag <= (step.Offset+step.Length-1)/readCacheLineSize; cacheLineTag++ {
+			readCacheKey.cacheLineTag = cacheLineTag
+			if _, alreadyPinned := readCacheKeySet[readCacheKey]; alreadyPinned {
+				continue
+			}
+			err = vS.pinReadCacheLine(step, readCacheKey)
+			if nil != err {
+				for _, readCacheKey = range readCacheKeys {
+					vS.unpinReadCacheLine(readCacheKey)
+				}
+				readCacheKeys = nil
+				return
+			}
+			readCacheKeySet[readCacheKey] = struct{}{}
+			readCacheKeys = append(readCacheKeys, readCacheKey)
+		}
+	}
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) isPinned(inodeNumber InodeNumber) (pinned bool) {
+	vS.Lock()
+	_, pinned = vS.pinnedInodeMap[inodeNumber]
+	vS.Unlock()
+	return
+}
+
+// unpinAll releases every pin held by this volume (e.g. as it is being removed).
+func (vS *volumeStruct) unpinAll() {
+	vS.Lock()
+	pinnedInodeMap := vS.pinnedInodeMap
+	vS.pinnedInodeMap = make(map[InodeNumber]*pinnedInodeStruct)
+	vS.Unlock()
+
+	for _, pinnedInode := range pinnedInodeMap {
+		for _, readCacheKey := range pinnedInode.readCacheKeys {
+			vS.unpinReadCacheLine(readCacheKey)
+		}
+	}
+}
+
+func (vS *volumeStruct) Pin(inodeNumber InodeNumber) (pinnedBytes uint64, err error) {
+	stats.IncrementOperations(&stats.InodePinOps)
+
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: cannot pin inode %v volume '%s' because it is unallocated", utils.GetFnName(), inodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if vS.isPinned(inodeNumber) {
+		// Already pinned... nothing more to do
+		err = nil
+		return
+	}
+
+	pinnedInode := &pinnedInodeStruct{}
+
+	if FileType == inode.InodeType {
+		pinnedInode.readCacheKeys, err = vS.pinFileData(inodeNumber)
+		if nil != err {
+			return
+		}
+	}
+
+	vS.Lock()
+	_, ok = vS.pinnedInodeMap[inodeNumber]
+	if ok {
+		// Lost a race with another Pin() of this inode... so drop our (redundant) pins
+		vS.Unlock()
+		for _, readCacheKey := range pinnedInode.readCacheKeys {
+			vS.unpinReadCacheLine(readCacheKey)
+		}
+		err = nil
+		return
+	}
+	vS.pinnedInodeMap[inodeNumber] = pinnedInode
+	vS.Unlock()
+
+	pinnedBytes = uint64(len(pinnedInode.readCacheKeys)) * vS.flowControl.readCacheLineSize
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) Unpin(inodeNumber InodeNumber) (err error) {
+	stats.IncrementOperations(&stats.InodeUnpinOps)
+
+	vS.unpinInode(inodeNumber)
+
+	err = nil
+	return
+}
+
+// unpinInode releases the pins (if any) held on behalf of inodeNumber.
+func (vS *volumeStruct) unpinInode(inodeNumber InodeNumber) {
+	vS.Lock()
+	pinnedInode, ok := vS.pinnedInodeMap[inodeNumber]
+	if ok {
+		delete(vS.pinnedInodeMap, inodeNumber)
+	}
+	vS.Unlock()
+
+	if ok {
+		for _, readCacheKey := range pinnedInode.readCacheKeys {
+			vS.unpinReadCacheLine(readCacheKey)
+		}
+	}
+}
+
+func (vS *volumeStruct) GetPinnedStats() (pinnedInodes uint64, pinnedBytes uint64) {
+	vS.Lock()
+	defer vS.Unlock()
+
+	pinnedInodes = uint64(len(vS.pinnedInodeMap))
+	for _, pinnedInode := range vS.pinnedInodeMap {
+		pinnedBytes += uint64(len(pinnedInode.readCacheKeys)) * vS.flowControl.readCacheLineSize
+	}
+
+	return
+}
@@ -0,0 +1,118 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestPin(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+	flowControl := volume.flowControl
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	pinnedBytes, err := testVolumeHandle.Pin(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Pin() failed: %v", err)
+	}
+	if flowControl.readCacheLineSize != pinnedBytes {
+		t.Fatalf("Pin() returned pinnedBytes == %v (expected %v)", pinnedBytes, flowControl.readCacheLineSize)
+	}
+
+	pinnedInodes, pinnedBytes := testVolumeHandle.GetPinnedStats()
+	if (1 != pinnedInodes) || (flowControl.readCacheLineSize != pinnedBytes) {
+		t.Fatalf("GetPinnedStats() returned unexpected (%v, %v)", pinnedInodes, pinnedBytes)
+	}
+
+	// Pinned Read Cache Lines must not be reachable via the MRU/LRU list (and hence never evicted)
+	flowControl.Lock()
+	for readCacheElement := flowControl.readCacheMRU; nil != readCacheElement; readCacheElement = readCacheElement.next {
+		if 0 != readCacheElement.pinCount {
+			flowControl.Unlock()
+			t.Fatalf("Pinned Read Cache Line found on MRU/LRU list")
+		}
+	}
+	flowControl.Unlock()
+
+	// A pinned inode must survive a Purge()
+	err = testVolumeHandle.Purge(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Purge() failed: %v", err)
+	}
+	volume.Lock()
+	_, ok := volume.inodeCache[fileInodeNumber]
+	volume.Unlock()
+	if !ok {
+		t.Fatalf("Purge() should not have evicted pinned inode")
+	}
+
+	// Pinning must leave at least one Read Cache Line unpinned
+	otherFileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(otherFileInodeNumber, 0, []byte{0x04, 0x05}, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	flowControl.Lock()
+	savedReadCacheLineCount := flowControl.readCacheLineCount
+	flowControl.readCacheLineCount = flowControl.readCachePinned + 1
+	flowControl.Unlock()
+	_, err = testVolumeHandle.Pin(otherFileInodeNumber)
+	flowControl.Lock()
+	flowControl.readCacheLineCount = savedReadCacheLineCount
+	flowControl.Unlock()
+	if blunder.IsNot(err, blunder.NoSpaceError) {
+		t.Fatalf("Pin() beyond Read Cache budget should have failed with NoSpaceError, instead got: %v", err)
+	}
+
+	err = testVolumeHandle.Unpin(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Unpin() failed: %v", err)
+	}
+	if 0 != flowControl.readCachePinned {
+		t.Fatalf("Unpin() left %v Read Cache Lines pinned", flowControl.readCachePinned)
+	}
+	pinnedInodes, _ = testVolumeHandle.GetPinnedStats()
+	if 0 != pinnedInodes {
+		t.Fatalf("GetPinnedStats() returned %v pinned inodes after Unpin()", pinnedInodes)
+	}
+
+	err = testVolumeHandle.Purge(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Purge() failed: %v", err)
+	}
+	volume.Lock()
+	_, ok = volume.inodeCache[fileInodeNumber]
+	volume.Unlock()
+	if ok {
+		t.Fatalf("Purge() should have evicted unpinned inode")
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+	err = testVolumeHandle.Destroy(otherFileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package inode
+
+// Ranged reads
+//
+// A Read Cache miss ordinarily GETs the entire Read Cache Line ([<flow-control-section>]ReadCacheLineSize)
+// containing the data needed so that neighboring data is then cached. For random reads of small slices
+// of large LogSegments (e.g. the pages of an sqlite database on an SMB share), most of each such Read
+// Cache Line is wasted. If [<flow-control-section>]ReadRangeMinSize is non-zero, a Read() not continuing
+// a sequential stream (see readahead.go) instead satisfies a Read Cache miss with a ranged GET (answered
+// by Swift with a 206) of the data needed (but at least ReadRangeMinSize bytes, up to the end of the Read
+// Cache Line). Data fetched this way is not cached. A miss whose range would cover its entire Read Cache
+// Line fetches (and caches) the Read Cache Line as usual.
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+// fetchReadRangeMinSize returns [flowControlSectionName]ReadRangeMinSize (0 == ranged reads disabled).
+func fetchReadRangeMinSize(confMap conf.ConfMap, flowControlSectionName string, readCacheLineSize uint64) (readRangeMinSize uint64, err error) {
+	readRangeMinSize, err = confMap.FetchOptionValueUint64(flowControlSectionName, "ReadRangeMinSize")
+	if nil != err {
+		readRangeMinSize = 0
+	}
+	if readRangeMinSize >= readCacheLineSize {
+		err = fmt.Errorf("%s.ReadRangeMinSize must be less than ReadCacheLineSize", flowControlSectionName)
+		return
+	}
+
+	err = nil
+	return
+}
+
+// rangedReadPermitted returns whether a Read() of fileInode at offset may use ranged GETs.
+func (vS *volumeStruct) rangedReadPermitted(fileInode *inMemoryInodeStruct, offset uint64) (permitted bool) {
+	if 0 == vS.flowControl.readRangeMinSize {
+		return false
+	}
+
+	fileInode.Lock()
+	ra := fileInode.readahead
+	permitted = (nil == ra) || (0 == ra.windowCacheLines) || (offset != ra.nextOffset)
+	fileInode.Unlock()
+
+	return
+}
+
+// rangedReadLength returns the length of the ranged GET to satisfy a Read Cache miss needing
+// cacheLineHitLength bytes at cacheLineHitOffset within its Read Cache Line (0 == GET the Read Cache Line).
+func (flowControl *flowControlStruct) rangedReadLength(cacheLineHitOffset uint64, cacheLineHitLength uint64) (rangeLength uint64) {
+	rangeLength = cacheLineHitLength
+	if rangeLength < flowControl.readRangeMinSize {
+		rangeLength = flowControl.readRangeMinSize
+	}
+	if (cacheLineHitOffset + rangeLength) > flowControl.readCacheLineSize {
+		rangeLength = flowControl.readCacheLineSize - cacheLineHitOffset
+	}
+	if rangeLength == flowControl.readCacheLineSize {
+		rangeLength = 0
+	}
+	return
+}
+
+// rangedRead returns the length bytes at offset in step's LogSegment via a ranged GET of rangeLength bytes.
+// If dst is non-nil, buf is instead dst[:length] (cap(dst) must be at least length) with the GET landing
+// directly in dst whenever cap(dst) also accommodates rangeLength.
+func (vS *volumeStruct) rangedRead(step ReadPlanStep, offset uint64, length uint64, rangeLength uint64, dst []byte) (buf []byte, err error) {
+	var (
+		rangeBuf    []byte
+		rangeBufLen uint64
+	)
+
+	if (nil != dst) && (uint64(cap(dst)) >= rangeLength) {
+		rangeBuf = dst[:rangeLength]
+		rangeBufLen, err = swiftclient.ObjectGetInto(step.AccountName, step.ContainerName, step.ObjectName, offset, rangeBuf)
+		rangeBuf = rangeBuf[:rangeBufLen]
+	} else {
+		rangeBuf, err = swiftclient.ObjectGet(step.AccountName, step.ContainerName, step.ObjectName, offset, rangeLength)
+	}
+	if nil != err {
+		logger.ErrorfWithError(err, "Reading range from LogSegment object failed")
+		err = blunder.AddError(err, blunder.SegReadError)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FileReadcacheRangedOps)
+
+	if uint64(len(rangeBuf)) < length {
+		err = fmt.Errorf("Invalid range for LogSegment object - ranged read")
+		logger.ErrorWithError(err)
+		err = blunder.AddError(err, blunder.SegReadError)
+		return
+	}
+
+	if nil == dst {
+		buf = rangeBuf[:length]
+	} else {
+		buf = append(dst[:0], rangeBuf[:length]...)
+	}
+	return
+}
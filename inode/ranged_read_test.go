@@ -0,0 +1,106 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRangedRead(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+	flowControl := volume.flowControl
+	readCacheLineSize := flowControl.readCacheLineSize
+
+	flowControl.readRangeMinSize = 4096
+	defer func() {
+		flowControl.readRangeMinSize = 0
+	}()
+
+	// rangedReadLength() extends short ranges to ReadRangeMinSize but never beyond the Read Cache Line
+
+	if 4096 != flowControl.rangedReadLength(100, 10) {
+		t.Fatalf("rangedReadLength(100, 10) should have returned 4096")
+	}
+	if 8192 != flowControl.rangedReadLength(100, 8192) {
+		t.Fatalf("rangedReadLength(100, 8192) should have returned 8192")
+	}
+	if 10 != flowControl.rangedReadLength(readCacheLineSize-10, 10) {
+		t.Fatalf("rangedReadLength(readCacheLineSize-10, 10) should have returned 10")
+	}
+	if 0 != flowControl.rangedReadLength(0, readCacheLineSize) {
+		t.Fatalf("rangedReadLength(0, readCacheLineSize) should have returned 0")
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	fileData := make([]byte, 3*readCacheLineSize)
+	for i := range fileData {
+		fileData[i] = byte(i % 251)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 0, fileData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	readCacheLen := func() (readCacheLen int) {
+		flowControl.Lock()
+		readCacheLen = len(flowControl.readCache)
+		flowControl.Unlock()
+		return
+	}
+	expectRead := func(offset uint64, length uint64) {
+		buf, err := testVolumeHandle.Read(fileInodeNumber, offset, length, nil)
+		if nil != err {
+			t.Fatalf("Read(%v, %v) failed: %v", offset, length, err)
+		}
+		if !bytes.Equal(fileData[offset:offset+length], buf) {
+			t.Fatalf("Read(%v, %v) returned wrong data", offset, length)
+		}
+	}
+
+	// Random Read()s (both within and straddling Read Cache Lines) use ranged GETs that aren't cached
+
+	readCacheLenBefore := readCacheLen()
+	expectRead(readCacheLineSize+500, 100)
+	expectRead((2*readCacheLineSize)-50, 100)
+	if readCacheLenBefore != readCacheLen() {
+		t.Fatalf("ranged Read()s should not have populated the Read Cache")
+	}
+
+	// A Read() continuing a sequential stream is not ranged
+
+	fileInode, ok, err := volume.fetchInode(fileInodeNumber)
+	if (nil != err) || !ok {
+		t.Fatalf("fetchInode() failed: %v", err)
+	}
+	expectRead(0, 1000)
+	expectRead(1000, 1000)
+	if volume.rangedReadPermitted(fileInode, 2000) {
+		t.Fatalf("rangedReadPermitted() should have returned false for sequential Read()")
+	}
+	if !volume.rangedReadPermitted(fileInode, 5000) {
+		t.Fatalf("rangedReadPermitted() should have returned true for non-sequential Read()")
+	}
+
+	// ReadRangeMinSize zero disables ranged GETs
+
+	flowControl.readRangeMinSize = 0
+	if volume.rangedReadPermitted(fileInode, 5000) {
+		t.Fatalf("rangedReadPermitted() should have returned false with ReadRangeMinSize == 0")
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
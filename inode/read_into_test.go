@@ -0,0 +1,73 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadInto(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Leave a hole at [4:8)
+
+	err = testVolumeHandle.Writev(fileInodeNumber, []WriteSegment{
+		{Offset: 0, Buf: []byte("0123")},
+		{Offset: 8, Buf: []byte("89ab")},
+	}, nil)
+	if nil != err {
+		t.Fatalf("Writev() failed: %v", err)
+	}
+
+	// Exercise both the in-flight and (once flushed) the Read Cache paths
+
+	for _, flushFirst := range []bool{false, true} {
+		if flushFirst {
+			err = testVolumeHandle.Flush(fileInodeNumber, false)
+			if nil != err {
+				t.Fatalf("Flush() failed: %v", err)
+			}
+		}
+
+		dst := bytes.Repeat([]byte{0xFF}, 12)
+		n, err := testVolumeHandle.ReadInto(fileInodeNumber, 0, dst, nil)
+		if nil != err {
+			t.Fatalf("ReadInto() failed: %v", err)
+		}
+		if (12 != n) || !bytes.Equal([]byte("0123\x00\x00\x00\x0089ab"), dst) {
+			t.Fatalf("ReadInto() [flushFirst: %v] returned %v & %q", flushFirst, n, dst)
+		}
+
+		dst = bytes.Repeat([]byte{0xFF}, 3)
+		n, err = testVolumeHandle.ReadInto(fileInodeNumber, 9, dst, nil)
+		if (nil != err) || (3 != n) || !bytes.Equal([]byte("9ab"), dst) {
+			t.Fatalf("ReadInto() [flushFirst: %v] of single step returned %v, %q, %v", flushFirst, n, dst, err)
+		}
+
+		dst = bytes.Repeat([]byte{0xFF}, 2)
+		n, err = testVolumeHandle.ReadInto(fileInodeNumber, 5, dst, nil)
+		if (nil != err) || (2 != n) || !bytes.Equal([]byte{0x00, 0x00}, dst) {
+			t.Fatalf("ReadInto() [flushFirst: %v] of hole returned %v, %q, %v", flushFirst, n, dst, err)
+		}
+
+		// A ReadInto() extending beyond EOF is short
+
+		dst = bytes.Repeat([]byte{0xFF}, 8)
+		n, err = testVolumeHandle.ReadInto(fileInodeNumber, 10, dst, nil)
+		if (nil != err) || (2 != n) || !bytes.Equal([]byte("ab"), dst[:n]) {
+			t.Fatalf("ReadInto() [flushFirst: %v] beyond EOF returned %v, %q, %v", flushFirst, n, dst, err)
+		}
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package inode
+
+// Adaptive readahead
+//
+// Each file inode tracks the sequential read stream presented to it. A Read() starting where the
+// previous one ended (or a first Read() at offset zero) is sequential and causes the Read Cache Lines
+// following it to be prefetched asynchronously. As with Linux readahead, the size of this readahead
+// window starts at one Read Cache Line and doubles with each further sequential Read(). Rather than
+// ramping all the way to a static maximum, however, the window only grows until it covers the data
+// the reader is expected to consume while a Swift GET is outstanding (the observed consumption rate
+// times the observed GET latency, scaled by readaheadLatencyFactor). A window that has grown larger
+// than needed (e.g. because GETs have become faster or the reader slower) is halved. A non-sequential
+// Read() resets the window. The window never exceeds [<flow-control-section>]ReadaheadMaxCacheLines
+// (0 disables readahead) and only a single prefetch is outstanding per inode at a time.
+
+import (
+	"math"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+const (
+	defaultReadaheadMaxCacheLines = 8
+	readaheadLatencyFactor        = 2.0  // multiple of (consumption rate * GET latency) the window aims to cover
+	readaheadEWMAWeight           = 0.25 // weight given each new sample of consumption rate & GET latency
+)
+
+type readaheadStruct struct {
+	nextOffset        uint64        // file offset expected of the next sequential Read()
+	prefetchedThrough uint64        // file offset through which prefetching has been issued
+	windowCacheLines  uint64        // current readahead window (0 == no sequential stream detected)
+	lastReadTime      time.Time     // time of the most recent Read() (zero == none yet)
+	consumptionRate   float64       // bytes/second consumed by sequential Read()s (EWMA)
+	getLatency        time.Duration // per Read Cache Line GET latency (EWMA; 0 == not yet sampled)
+	prefetchInFlight  bool
+	stats             ReadaheadStats
+}
+
+type readaheadPrefetchStruct struct {
+	step         ReadPlanStep
+	readCacheKey readCacheKeyStruct
+}
+
+func ewma(average float64, sample float64) float64 {
+	if 0 == average {
+		return sample
+	}
+	return ((1 - readaheadEWMAWeight) * average) + (readaheadEWMAWeight * sample)
+}
+
+// readahead notes a completed Read() of fileInode and, if warranted, launches a prefetch.
+//
+// Caller is expected to hold (at least) a read lock on fileInode's InodeNumber.
+func (vS *volumeStruct) readahead(fileInode *inMemoryInodeStruct, offset uint64, length uint64) {
+	var (
+		flowControl        = vS.flowControl
+		neededCacheLines   uint64
+		prefetchEnd        uint64
+		prefetchLength     uint64
+		prefetchList       []readaheadPrefetchStruct
+		prefetchStart      uint64
+		readCacheHit       bool
+		readCacheKey       readCacheKeyStruct
+		readCacheLineSize  = flowControl.readCacheLineSize
+		readPlan           []ReadPlanStep
+		sampleElapsed      time.Duration
+		sequential         bool
+		stepCacheLineFirst uint64
+		stepCacheLineLast  uint64
+		err                error
+	)
+
+	if (0 == flowControl.maxReadahead) || (0 == length) {
+		return
+	}
+
+	now := time.Now()
+
+	fileInode.Lock()
+
+	ra := fileInode.readahead
+	if nil == ra {
+		ra = &readaheadStruct{}
+		fileInode.readahead = ra
+	}
+
+	if ra.lastReadTime.IsZero() {
+		sequential = (0 == offset)
+	} else {
+		sequential = (offset == ra.nextOffset)
+	}
+
+	if sequential {
+		ra.stats.SequentialReads++
+		stats.IncrementOperations(&stats.FileReadaheadSequentialOps)
+
+		if !ra.lastReadTime.IsZero() {
+			sampleElapsed = now.Sub(ra.lastReadTime)
+			if 0 < sampleElapsed {
+				ra.consumptionRate = ewma(ra.consumptionRate, float64(length)/sampleElapsed.Seconds())
+			}
+		}
+
+		if 0 == ra.windowCacheLines {
+			ra.windowCacheLines = 1
+		} else {
+			neededCacheLines = uint64(math.Ceil(ra.consumptionRate * ra.getLatency.Seconds() * readaheadLatencyFactor / float64(readCacheLineSize)))
+			if 0 == neededCacheLines {
+				neededCacheLines = 1
+			}
+			if (0 == ra.getLatency) || (ra.windowCacheLines < neededCacheLines) {
+				ra.windowCacheLines *= 2
+				stats.IncrementOperations(&stats.FileReadaheadWindowGrowOps)
+			} else if ra.windowCacheLines > (2 * neededCacheLines) {
+				ra.windowCacheLines /= 2
+				stats.IncrementOperations(&stats.FileReadaheadWindowShrinkOps)
+			}
+		}
+		if ra.windowCacheLines > flowControl.maxReadahead {
+			ra.windowCacheLines = flowControl.maxReadahead
+		}
+	} else {
+		ra.stats.RandomReads++
+		stats.IncrementOperations(&stats.FileReadaheadRandomOps)
+
+		ra.windowCacheLines = 0
+		ra.prefetchedThrough = 0
+		ra.consumptionRate = 0
+	}
+
+	ra.nextOffset = offset + length
+	ra.lastReadTime = now
+
+	if (0 == ra.windowCacheLines) || ra.prefetchInFlight {
+		fileInode.Unlock()
+		return
+	}
+
+	prefetchStart = ra.nextOffset
+	if prefetchStart < ra.prefetchedThrough {
+		prefetchStart = ra.prefetchedThrough
+	}
+	prefetchEnd = ra.nextOffset + (ra.windowCacheLines * readCacheLineSize)
+	if prefetchEnd > fileInode.Size {
+		prefetchEnd = fileInode.Size
+	}
+
+	if prefetchStart >= prefetchEnd {
+		fileInode.Unlock()
+		return
+	}
+
+	prefetchLength = prefetchEnd - prefetchStart
+
+	readPlan, _, err = vS.getReadPlanHelper(fileInode, &prefetchStart, &prefetchLength)
+	if nil != err {
+		fileInode.Unlock()
+		logger.WarnfWithError(err, "readahead of inode %v failed to compute read plan", fileInode.InodeNumber)
+		return
+	}
+
+	readCacheKey.volumeName = vS.volumeName
+
+	prefetchList = make([]readaheadPrefetchStruct, 0, ra.windowCacheLines+1)
+
+	flowControl.Lock()
+	for _, step := range readPlan {
+		if 0 == step.LogSegmentNumber {
+			continue // zero-fill
+		}
+		_, inFlight := fileInode.inFlightLogSegmentMap[step.LogSegmentNumber]
+		if inFlight {
+			continue // not yet readable from Swift... but served from memory anyway
+		}
+		readCacheKey.logSegmentNumber = step.LogSegmentNumber
+		stepCacheLineFirst = step.Offset / readCacheLineSize
+		stepCacheLineLast = (step.Offset + step.Length - 1) / readCacheLineSize
+		for readCacheKey.cacheLineTag = stepCacheLineFirst; readCacheKey.cacheLineTag <= stepCacheLineLast; readCacheKey.cacheLineTag++ {
+			_, readCacheHit = flowControl.readCache[readCacheKey]
+			if !readCacheHit {
+				prefetchList = append(prefetchList, readaheadPrefetchStruct{step: step, readCacheKey: readCacheKey})
+			}
+		}
+	}
+	flowControl.Unlock()
+
+	ra.prefetchedThrough = prefetchEnd
+
+	if 0 == len(prefetchList) {
+		fileInode.Unlock()
+		return
+	}
+
+	ra.prefetchInFlight = true
+
+	fileInode.Unlock()
+
+	go vS.readaheadPrefetcher(fileInode, ra, prefetchList)
+}
+
+func (vS *volumeStruct) readaheadPrefetcher(fileInode *inMemoryInodeStruct, ra *readaheadStruct, prefetchList []readaheadPrefetchStruct) {
+	var (
+		cacheLine        []byte
+		err              error
+		flowControl      = vS.flowControl
+		getLatencySum    time.Duration
+		getStart         time.Time
+		prefetchedLines  uint64
+		readCacheElement *readCacheElementStruct
+		readCacheHit     bool
+	)
+
+	for _, prefetch := range prefetchList {
+		flowControl.Lock()
+		_, readCacheHit = flowControl.readCache[prefetch.readCacheKey]
+		flowControl.Unlock()
+		if readCacheHit {
+			continue // a concurrent Read() (or Pin()) beat us to it
+		}
+
+		getStart = time.Now()
+		cacheLine, err = swiftclient.ObjectGet(prefetch.step.AccountName, prefetch.step.ContainerName, prefetch.step.ObjectName, prefetch.readCacheKey.cacheLineTag*flowControl.readCacheLineSize, flowControl.readCacheLineSize)
+		if nil != err {
+			// Not fatal... the subsequent Read() will simply miss (and report any real problem)
+			err = blunder.AddError(err, blunder.SegReadError)
+			logger.WarnfWithError(err, "readahead of inode %v failed reading LogSegment %v", fileInode.InodeNumber, prefetch.step.LogSegmentNumber)
+			break
+		}
+		getLatencySum += time.Since(getStart)
+		prefetchedLines++
+
+		flowControl.Lock()
+		_, readCacheHit = flowControl.readCache[prefetch.readCacheKey]
+		if !readCacheHit {
+			if uint64(len(flowControl.readCache)) >= flowControl.readCacheLineCount {
+				_ = evictReadCacheLRU(flowControl)
+			}
+			readCacheElement = &readCacheElementStruct{
+				readCacheKey: prefetch.readCacheKey,
+				next:         nil,
+				prev:         nil,
+				cacheLine:    cacheLine,
+			}
+			linkReadCacheElementAtMRU(flowControl, readCacheElement)
+			flowControl.readCache[prefetch.readCacheKey] = readCacheElement
+		}
+		flowControl.Unlock()
+
+		stats.IncrementOperations(&stats.FileReadaheadPrefetchOps)
+	}
+
+	fileInode.Lock()
+	if 0 < prefetchedLines {
+		ra.getLatency = time.Duration(ewma(float64(ra.getLatency), float64(getLatencySum)/float64(prefetchedLines)))
+		ra.stats.PrefetchedLines += prefetchedLines
+	}
+	ra.prefetchInFlight = false
+	fileInode.Unlock()
+}
+
+func (vS *volumeStruct) FetchReadaheadStats(fileInodeNumber InodeNumber) (readaheadStats ReadaheadStats, err error) {
+	fileInode, err := vS.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		return
+	}
+
+	fileInode.Lock()
+	if nil != fileInode.readahead {
+		readaheadStats = fileInode.readahead.stats
+		readaheadStats.WindowCacheLines = fileInode.readahead.windowCacheLines
+		readaheadStats.ConsumptionRate = uint64(fileInode.readahead.consumptionRate)
+		readaheadStats.GetLatency = fileInode.readahead.getLatency
+	}
+	fileInode.Unlock()
+
+	return
+}
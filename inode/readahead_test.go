@@ -0,0 +1,96 @@
+package inode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadahead(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+	readCacheLineSize := volume.flowControl.readCacheLineSize
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 0, make([]byte, (3*readCacheLineSize)+1), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	awaitPrefetchedLines := func(expectedPrefetchedLines uint64) (readaheadStats ReadaheadStats) {
+		for i := 0; i < 100; i++ {
+			readaheadStats, err = testVolumeHandle.FetchReadaheadStats(fileInodeNumber)
+			if nil != err {
+				t.Fatalf("FetchReadaheadStats() failed: %v", err)
+			}
+			if expectedPrefetchedLines <= readaheadStats.PrefetchedLines {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("FetchReadaheadStats() reported PrefetchedLines == %v (expected %v)", readaheadStats.PrefetchedLines, expectedPrefetchedLines)
+		return
+	}
+
+	// A first Read() at offset zero prefetches the following Read Cache Line
+
+	_, err = testVolumeHandle.Read(fileInodeNumber, 0, 1000, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	readaheadStats := awaitPrefetchedLines(1)
+	if (1 != readaheadStats.SequentialReads) || (1 != readaheadStats.WindowCacheLines) {
+		t.Fatalf("FetchReadaheadStats() returned unexpected %+v after first Read()", readaheadStats)
+	}
+
+	// A sequential Read() ramps up the window when GET latency (here, artificially) outpaces it
+
+	fileInode, ok, err := volume.fetchInode(fileInodeNumber)
+	if (nil != err) || !ok {
+		t.Fatalf("fetchInode() failed: %v", err)
+	}
+	fileInode.Lock()
+	fileInode.readahead.getLatency = time.Hour
+	fileInode.Unlock()
+
+	_, err = testVolumeHandle.Read(fileInodeNumber, 1000, 1000, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	readaheadStats = awaitPrefetchedLines(2)
+	if (2 != readaheadStats.SequentialReads) || (2 != readaheadStats.WindowCacheLines) {
+		t.Fatalf("FetchReadaheadStats() returned unexpected %+v after sequential Read()", readaheadStats)
+	}
+	if (0 == readaheadStats.GetLatency) || (time.Hour <= readaheadStats.GetLatency) {
+		t.Fatalf("FetchReadaheadStats() should have reported a measured GetLatency (got %v)", readaheadStats.GetLatency)
+	}
+
+	// A non-sequential Read() resets the window
+
+	_, err = testVolumeHandle.Read(fileInodeNumber, 3*readCacheLineSize, 1, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	readaheadStats, err = testVolumeHandle.FetchReadaheadStats(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("FetchReadaheadStats() failed: %v", err)
+	}
+	if (1 != readaheadStats.RandomReads) || (0 != readaheadStats.WindowCacheLines) {
+		t.Fatalf("FetchReadaheadStats() returned unexpected %+v after non-sequential Read()", readaheadStats)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
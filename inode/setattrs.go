@@ -0,0 +1,109 @@
+package inode
+
+// Atomic multi-attribute updates
+//
+// SetAttrs() changes any combination of an inode's times, size, owner, and mode as a single update: every
+// requested attribute is validated before any is applied, the in-memory inode is then modified in one go,
+// and the result is flushed once (counting a single ChangeCount increment). Either all of the attributes
+// change or, should validation fail, none do.
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+func (vS *volumeStruct) SetAttrs(inodeNumber InodeNumber, attrs *SetAttrsStruct) (err error) {
+	// NOTE: Errors are logged by the caller
+
+	var (
+		fileMode InodeMode
+	)
+
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if err != nil {
+		// the inode is locked so this should never happen (unless the inode
+		// was evicted from the cache and it was corrupt when read from disk)
+		logger.ErrorfWithError(err, "%s: fetch of target inode failed", utils.GetFnName())
+		return err
+	}
+	if !ok {
+		// this should never happen (see above)
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because its unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		logger.ErrorWithError(err)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return err
+	}
+
+	// Validate everything before changing anything
+
+	if attrs.SetSize && (FileType != inode.InodeType) {
+		err = blunder.NewError(blunder.NotFileError, "%s: cannot set size of inode %d volume '%s' type %v",
+			utils.GetFnName(), inodeNumber, vS.volumeName, inode.InodeType)
+		return
+	}
+	if attrs.SetPermMode {
+		fileMode, err = determineMode(attrs.PermMode, inode.InodeType)
+		if nil != err {
+			return
+		}
+	}
+
+	if attrs.SetSize {
+		// Pending writes must land before the extents they may reference are trimmed
+		err = vS.sendStagedWrites(inode)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+	}
+
+	changeCount := inode.ChangeCount
+
+	if attrs.SetSize {
+		err = setSizeInMemory(inode, attrs.Size) // also updates ModificationTime (unless overridden below)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+	}
+	if attrs.SetCreationTime {
+		inode.CreationTime = attrs.CreationTime
+	}
+	if attrs.SetModificationTime {
+		inode.ModificationTime = attrs.ModificationTime
+	}
+	if attrs.SetAccessTime {
+		inode.AccessTime = attrs.AccessTime
+	}
+	if attrs.SetUserID {
+		inode.UserID = attrs.UserID
+	}
+	if attrs.SetGroupID {
+		inode.GroupID = attrs.GroupID
+	}
+	if attrs.SetPermMode {
+		if (inode.Mode & PosixModePerm) != (fileMode & PosixModePerm) {
+			delete(inode.StreamMap, NFS4ACLStream) // the mode now describes access in full (see nfs4acl.go)
+		}
+		inode.Mode = fileMode
+	}
+
+	inode.dirty = true
+	inode.AttrChangeTime = vS.timestamp(inode)
+	inode.ChangeCount = changeCount + 1
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return err
+	}
+
+	stats.IncrementOperations(&stats.InodeSetAttrsOps)
+
+	return
+}
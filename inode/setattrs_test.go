@@ -0,0 +1,98 @@
+package inode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestSetAttrs(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	metadata, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	changeCount := metadata.ChangeCount
+
+	modificationTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	err = testVolumeHandle.SetAttrs(fileInodeNumber, &SetAttrsStruct{
+		SetModificationTime: true,
+		ModificationTime:    modificationTime,
+		SetSize:             true,
+		Size:                3,
+		SetUserID:           true,
+		UserID:              7,
+		SetGroupID:          true,
+		GroupID:             8,
+		SetPermMode:         true,
+		PermMode:            0600,
+	})
+	if nil != err {
+		t.Fatalf("SetAttrs() failed: %v", err)
+	}
+
+	metadata, err = testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if (3 != metadata.Size) || (7 != metadata.UserID) || (8 != metadata.GroupID) || ((PosixModeFile | 0600) != metadata.Mode) {
+		t.Fatalf("SetAttrs() left Size %v UserID %v GroupID %v Mode 0%o", metadata.Size, metadata.UserID, metadata.GroupID, metadata.Mode)
+	}
+	if !modificationTime.Equal(metadata.ModificationTime) {
+		t.Fatalf("SetAttrs() left ModificationTime %v", metadata.ModificationTime)
+	}
+	if changeCount+1 != metadata.ChangeCount {
+		t.Fatalf("SetAttrs() changed ChangeCount from %v to %v", changeCount, metadata.ChangeCount)
+	}
+
+	buf, err := testVolumeHandle.Read(fileInodeNumber, 0, 10, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if "012" != string(buf) {
+		t.Fatalf("Read() after SetAttrs() returned \"%s\"", string(buf))
+	}
+
+	// Setting the size of a directory fails without changing any other attribute
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+
+	err = testVolumeHandle.SetAttrs(dirInodeNumber, &SetAttrsStruct{SetSize: true, SetUserID: true, UserID: 7})
+	if !blunder.Is(err, blunder.NotFileError) {
+		t.Fatalf("SetAttrs() of directory Size should have failed with NotFileError, got: %v", err)
+	}
+	metadata, err = testVolumeHandle.GetMetadata(dirInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if 0 != metadata.UserID {
+		t.Fatalf("failed SetAttrs() changed UserID to %v", metadata.UserID)
+	}
+
+	err = testVolumeHandle.Destroy(dirInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() of directory failed: %v", err)
+	}
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() of file failed: %v", err)
+	}
+}
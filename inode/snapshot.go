@@ -0,0 +1,99 @@
+package inode
+
+// Snapshots
+//
+// Snapshots of a volume are maintained by headhunter (see headhunter/snapshot.go). Each one is presented
+// by a VolumeHandle of its own (see FetchSnapshotVolumeHandle()) whose inodes are those recorded in the
+// snapshot's B+Trees. Only its read methods (e.g. GetMetadata(), Lookup(), ReadDir(), Read(), GetSymlink())
+// may be used: headhunter refuses to persist any modification.
+//
+// While a snapshot remains, LogSegments it may reference are not deleted as their files are truncated,
+// overwritten, or destroyed (see SnapshotRetainsLogSegment()). Deleting the snapshot deletes those no
+// longer referenced by any snapshot.
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/headhunter"
+)
+
+func (vS *volumeStruct) SnapshotCreate(name string) (id uint64, err error) {
+	if nil != vS.snapshotOf {
+		err = blunder.NewError(blunder.ReadOnlyError, "snapshots of volume '%s' may not be created within a snapshot", vS.volumeName)
+		return
+	}
+
+	id, err = vS.headhunterVolumeHandle.SnapshotCreate(name)
+	return
+}
+
+func (vS *volumeStruct) SnapshotDelete(id uint64) (err error) {
+	if nil != vS.snapshotOf {
+		err = blunder.NewError(blunder.ReadOnlyError, "snapshots of volume '%s' may not be deleted within a snapshot", vS.volumeName)
+		return
+	}
+
+	err = vS.headhunterVolumeHandle.SnapshotDelete(id)
+	if nil != err {
+		return
+	}
+
+	vS.Lock()
+	delete(vS.snapshotVolumeMap, id)
+	vS.Unlock()
+
+	return
+}
+
+func (vS *volumeStruct) SnapshotList() (list []headhunter.SnapshotStruct) {
+	list = vS.headhunterVolumeHandle.SnapshotList()
+	return
+}
+
+func (vS *volumeStruct) FetchSnapshotVolumeHandle(id uint64) (snapshotVolumeHandle VolumeHandle, err error) {
+	if nil != vS.snapshotOf {
+		err = blunder.NewError(blunder.NotFoundError, "snapshots of volume '%s' have no snapshots", vS.volumeName)
+		return
+	}
+
+	vS.Lock()
+	defer vS.Unlock()
+
+	snapshotVolume, ok := vS.snapshotVolumeMap[id]
+	if ok {
+		snapshotVolumeHandle = snapshotVolume
+		return
+	}
+
+	headhunterSnapshotVolumeHandle, err := vS.headhunterVolumeHandle.FetchSnapshotVolumeHandle(id)
+	if nil != err {
+		return
+	}
+
+	snapshotVolume = &volumeStruct{
+		fsid:                           vS.fsid,
+		volumeName:                     vS.volumeName,
+		accountName:                    vS.accountName,
+		active:                         true,
+		activePeerPrivateIPAddr:        vS.activePeerPrivateIPAddr,
+		maxEntriesPerDirNode:           vS.maxEntriesPerDirNode,
+		maxExtentsPerFileNode:          vS.maxExtentsPerFileNode,
+		caseInsensitive:                vS.caseInsensitive,
+		physicalContainerLayoutSet:     vS.physicalContainerLayoutSet,
+		physicalContainerNamePrefixSet: vS.physicalContainerNamePrefixSet,
+		physicalContainerLayoutMap:     vS.physicalContainerLayoutMap,
+		defaultPhysicalContainerLayout: vS.defaultPhysicalContainerLayout,
+		flowControl:                    vS.flowControl,
+		headhunterVolumeHandle:         headhunterSnapshotVolumeHandle,
+		inodeCache:                     make(map[InodeNumber]*inMemoryInodeStruct),
+		pinnedInodeMap:                 make(map[InodeNumber]*pinnedInodeStruct),
+		snapshotOf:                     vS,
+	}
+
+	if nil == vS.snapshotVolumeMap {
+		vS.snapshotVolumeMap = make(map[uint64]*volumeStruct)
+	}
+	vS.snapshotVolumeMap[id] = snapshotVolume
+
+	snapshotVolumeHandle = snapshotVolume
+	return
+}
@@ -0,0 +1,50 @@
+package inode
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// CreateSpecial creates a FIFO or Unix socket inode.
+//
+// Such inodes have no payload... the data passing through them never reaches ProxyFS.
+func (vS *volumeStruct) CreateSpecial(inodeType InodeType, filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (specialInodeNumber InodeNumber, err error) {
+	if (FIFOType != inodeType) && (SocketType != inodeType) {
+		err = fmt.Errorf("%s: inode type %v is not a special inode type", utils.GetFnName(), inodeType)
+		err = blunder.AddError(err, blunder.InvalidInodeTypeError)
+		return
+	}
+
+	// Create file mode out of file permissions plus inode type
+	fileMode, err := determineMode(filePerm, inodeType)
+	if err != nil {
+		return
+	}
+
+	specialInode, err := vS.makeInMemoryInode(inodeType, fileMode, userID, groupID)
+	if err != nil {
+		return
+	}
+
+	specialInode.dirty = true
+
+	specialInodeNumber = specialInode.InodeNumber
+
+	vS.Lock()
+	vS.inodeCache[specialInodeNumber] = specialInode
+	vS.Unlock()
+
+	err = vS.flushInode(specialInode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	stats.IncrementOperations(&stats.SpecialCreateOps)
+
+	return
+}
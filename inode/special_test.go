@@ -0,0 +1,73 @@
+package inode
+
+import (
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestCreateSpecial(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	_, err = testVolumeHandle.CreateSpecial(FileType, PosixModePerm, 0, 0)
+	if !blunder.Is(err, blunder.InvalidInodeTypeError) {
+		t.Fatalf("CreateSpecial(FileType) should have failed with InvalidInodeTypeError: %v", err)
+	}
+
+	for _, special := range []struct {
+		basename  string
+		inodeType InodeType
+		typeMode  InodeMode
+	}{
+		{"TestCreateSpecialFIFO", FIFOType, PosixModeFIFO},
+		{"TestCreateSpecialSocket", SocketType, PosixModeSocket},
+	} {
+		specialInodeNumber, err := testVolumeHandle.CreateSpecial(special.inodeType, InodeMode(0640), 0, 0)
+		if nil != err {
+			t.Fatalf("CreateSpecial(%v) failed: %v", special.inodeType, err)
+		}
+		err = testVolumeHandle.Link(RootDirInodeNumber, special.basename, specialInodeNumber)
+		if nil != err {
+			t.Fatalf("Link() of \"%s\" failed: %v", special.basename, err)
+		}
+
+		// Validate() purges the inode from the cache so the checks below see what was persisted
+
+		err = testVolumeHandle.Validate(specialInodeNumber)
+		if nil != err {
+			t.Fatalf("Validate() of \"%s\" failed: %v", special.basename, err)
+		}
+
+		inodeType, err := testVolumeHandle.GetType(specialInodeNumber)
+		if nil != err {
+			t.Fatalf("GetType() of \"%s\" failed: %v", special.basename, err)
+		}
+		if special.inodeType != inodeType {
+			t.Fatalf("GetType() of \"%s\" returned %v (expected %v)", special.basename, inodeType, special.inodeType)
+		}
+		metadata, err := testVolumeHandle.GetMetadata(specialInodeNumber)
+		if nil != err {
+			t.Fatalf("GetMetadata() of \"%s\" failed: %v", special.basename, err)
+		}
+		if (special.typeMode | InodeMode(0640)) != metadata.Mode {
+			t.Fatalf("GetMetadata() of \"%s\" returned Mode 0%o (expected 0%o)", special.basename, metadata.Mode, special.typeMode|InodeMode(0640))
+		}
+
+		_, err = testVolumeHandle.Read(specialInodeNumber, 0, 1, nil)
+		if nil == err {
+			t.Fatalf("Read() of \"%s\" should have failed", special.basename)
+		}
+
+		err = testVolumeHandle.Unlink(RootDirInodeNumber, special.basename)
+		if nil != err {
+			t.Fatalf("Unlink() of \"%s\" failed: %v", special.basename, err)
+		}
+		err = testVolumeHandle.Destroy(specialInodeNumber)
+		if nil != err {
+			t.Fatalf("Destroy() of \"%s\" failed: %v", special.basename, err)
+		}
+	}
+}
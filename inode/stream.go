@@ -0,0 +1,150 @@
+package inode
+
+// Ranged stream access
+//
+// GetStream() and PutStream() transfer a stream's entire value. ReadStream(), WriteStream(), and
+// ResizeStream() instead access a stream at an offset, much as Read(), Write(), and SetSize() access a
+// file, so that a large stream (e.g. an SMB alternate data stream) may be transferred incrementally. The
+// stream itself remains stored in the inode's StreamMap.
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// fetchStreamInode fetches inodeNumber on behalf of one of the ranged stream operations.
+func (vS *volumeStruct) fetchStreamInode(inodeNumber InodeNumber) (inode *inMemoryInodeStruct, err error) {
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if err != nil {
+		// this indicates disk corruption or software error
+		// (err includes volume name and inode number)
+		logger.ErrorfWithError(err, "%s: fetch of inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		// disk corruption or client request for unallocated inode
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because its unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		logger.InfoWithError(err)
+		err = blunder.AddError(err, blunder.NotFoundError)
+	}
+	return
+}
+
+// GetStreamSize returns the length of inodeStreamName's value.
+func (vS *volumeStruct) GetStreamSize(inodeNumber InodeNumber, inodeStreamName string) (size uint64, err error) {
+	inode, err := vS.fetchStreamInode(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	inodeStreamBuf, ok := inode.StreamMap[inodeStreamName]
+	if !ok {
+		err = blunder.NewError(blunder.StreamNotFound, "No stream '%v'", inodeStreamName)
+		return
+	}
+
+	size = uint64(len(inodeStreamBuf))
+	return
+}
+
+// ReadStream returns up to length bytes of inodeStreamName's value starting at offset. As with Read(),
+// the returned buf is short (or empty) if the value ends before offset+length.
+func (vS *volumeStruct) ReadStream(inodeNumber InodeNumber, inodeStreamName string, offset uint64, length uint64) (buf []byte, err error) {
+	inode, err := vS.fetchStreamInode(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	inodeStreamBuf, ok := inode.StreamMap[inodeStreamName]
+	if !ok {
+		err = blunder.NewError(blunder.StreamNotFound, "No stream '%v'", inodeStreamName)
+		return
+	}
+
+	if offset >= uint64(len(inodeStreamBuf)) {
+		buf = []byte{}
+		return
+	}
+	if length > uint64(len(inodeStreamBuf))-offset {
+		length = uint64(len(inodeStreamBuf)) - offset
+	}
+
+	buf = make([]byte, length)
+	copy(buf, inodeStreamBuf[offset:offset+length])
+	return
+}
+
+// WriteStream writes buf into inodeStreamName's value at offset, creating the stream if necessary and
+// zero-filling any gap between its prior end and offset.
+func (vS *volumeStruct) WriteStream(inodeNumber InodeNumber, inodeStreamName string, offset uint64, buf []byte) (err error) {
+	inode, err := vS.fetchStreamInode(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	inodeStreamBuf := inode.StreamMap[inodeStreamName]
+
+	end := offset + uint64(len(buf))
+	if end < offset {
+		err = blunder.NewError(blunder.InvalidArgError, "%s: offset %v + length %v overflows", utils.GetFnName(), offset, len(buf))
+		return
+	}
+	if end > uint64(len(inodeStreamBuf)) {
+		inodeStreamBuf = append(inodeStreamBuf, make([]byte, end-uint64(len(inodeStreamBuf)))...)
+	}
+	copy(inodeStreamBuf[offset:end], buf)
+
+	inode.dirty = true
+	inode.StreamMap[inodeStreamName] = inodeStreamBuf
+
+	updateTime := vS.timestamp(inode)
+	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return err
+	}
+
+	return
+}
+
+// ResizeStream truncates or (zero-)extends inodeStreamName's value to newSize bytes.
+func (vS *volumeStruct) ResizeStream(inodeNumber InodeNumber, inodeStreamName string, newSize uint64) (err error) {
+	inode, err := vS.fetchStreamInode(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	inodeStreamBuf, ok := inode.StreamMap[inodeStreamName]
+	if !ok {
+		err = blunder.NewError(blunder.StreamNotFound, "No stream '%v'", inodeStreamName)
+		return
+	}
+
+	if newSize > uint64(len(inodeStreamBuf)) {
+		inodeStreamBuf = append(inodeStreamBuf, make([]byte, newSize-uint64(len(inodeStreamBuf)))...)
+	} else {
+		inodeStreamBuf = inodeStreamBuf[:newSize]
+	}
+
+	inode.dirty = true
+	inode.StreamMap[inodeStreamName] = inodeStreamBuf
+
+	updateTime := vS.timestamp(inode)
+	inode.AttrChangeTime = updateTime
+	inode.ChangeCount++
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return err
+	}
+
+	return
+}
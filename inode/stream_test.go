@@ -0,0 +1,95 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+)
+
+func TestRangedStreams(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	_, err = testVolumeHandle.ReadStream(fileInodeNumber, "stream", 0, 1)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("ReadStream() of missing stream should have failed with StreamNotFound, got: %v", err)
+	}
+	err = testVolumeHandle.ResizeStream(fileInodeNumber, "stream", 1)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("ResizeStream() of missing stream should have failed with StreamNotFound, got: %v", err)
+	}
+
+	// WriteStream() creates the stream and zero-fills any gap
+
+	err = testVolumeHandle.WriteStream(fileInodeNumber, "stream", 2, []byte("abc"))
+	if nil != err {
+		t.Fatalf("WriteStream() failed: %v", err)
+	}
+	err = testVolumeHandle.WriteStream(fileInodeNumber, "stream", 4, []byte("XYZ"))
+	if nil != err {
+		t.Fatalf("WriteStream() failed: %v", err)
+	}
+
+	buf, err := testVolumeHandle.GetStream(fileInodeNumber, "stream")
+	if nil != err {
+		t.Fatalf("GetStream() failed: %v", err)
+	}
+	if !bytes.Equal([]byte("\x00\x00abXYZ"), buf) {
+		t.Fatalf("GetStream() returned %q", buf)
+	}
+	size, err := testVolumeHandle.GetStreamSize(fileInodeNumber, "stream")
+	if nil != err {
+		t.Fatalf("GetStreamSize() failed: %v", err)
+	}
+	if 7 != size {
+		t.Fatalf("GetStreamSize() returned %v (expected 7)", size)
+	}
+
+	// ReadStream() is short at the end of the stream
+
+	buf, err = testVolumeHandle.ReadStream(fileInodeNumber, "stream", 3, 100)
+	if nil != err {
+		t.Fatalf("ReadStream() failed: %v", err)
+	}
+	if "bXYZ" != string(buf) {
+		t.Fatalf("ReadStream() returned %q (expected \"bXYZ\")", buf)
+	}
+	buf, err = testVolumeHandle.ReadStream(fileInodeNumber, "stream", 100, 1)
+	if nil != err {
+		t.Fatalf("ReadStream() beyond end failed: %v", err)
+	}
+	if 0 != len(buf) {
+		t.Fatalf("ReadStream() beyond end returned %q", buf)
+	}
+
+	// Truncated data does not reappear when the stream is extended
+
+	err = testVolumeHandle.ResizeStream(fileInodeNumber, "stream", 3)
+	if nil != err {
+		t.Fatalf("ResizeStream() failed: %v", err)
+	}
+	err = testVolumeHandle.ResizeStream(fileInodeNumber, "stream", 5)
+	if nil != err {
+		t.Fatalf("ResizeStream() failed: %v", err)
+	}
+	buf, err = testVolumeHandle.ReadStream(fileInodeNumber, "stream", 0, 5)
+	if nil != err {
+		t.Fatalf("ReadStream() failed: %v", err)
+	}
+	if !bytes.Equal([]byte("\x00\x00a\x00\x00"), buf) {
+		t.Fatalf("ReadStream() after ResizeStream()s returned %q", buf)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
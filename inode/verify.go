@@ -0,0 +1,81 @@
+package inode
+
+// Swift reconciliation
+//
+// FetchLogSegmentLocations() and FetchPhysicalContainerNames() allow a volume's view of its LogSegments
+// to be compared against direct listings of the Swift Containers holding them (see fs/verify.go).
+// Neither alters the volume's contents.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+// FetchLogSegmentLocations returns the location of each LogSegment referenced by the file inode,
+// flushing it first so that each referenced LogSegment has been written.
+func (vS *volumeStruct) FetchLogSegmentLocations(fileInodeNumber InodeNumber) (locations []LogSegmentLocation, err error) {
+	fileInode, err := vS.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	err = vS.flushInode(fileInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	locations = make([]LogSegmentLocation, 0, len(fileInode.LogSegmentMap))
+
+	for logSegmentNumber := range fileInode.LogSegmentMap {
+		location := LogSegmentLocation{
+			LogSegmentNumber: logSegmentNumber,
+			ObjectName:       fmt.Sprintf("%016X", logSegmentNumber),
+		}
+		containerName, containerErr := vS.getLogSegmentContainer(logSegmentNumber)
+		if nil == containerErr {
+			location.ContainerName = containerName
+		}
+		locations = append(locations, location)
+	}
+
+	sort.Slice(locations, func(i int, j int) bool {
+		return locations[i].LogSegmentNumber < locations[j].LogSegmentNumber
+	})
+
+	return
+}
+
+// FetchPhysicalContainerNames returns the Containers in the volume's Account named by any of its
+// PhysicalContainerLayouts, including those no longer being provisioned from.
+func (vS *volumeStruct) FetchPhysicalContainerNames() (containerNames []string, err error) {
+	_, containerList, err := swiftclient.AccountGet(vS.accountName)
+	if nil != err {
+		return
+	}
+
+	vS.Lock()
+	prefixes := make([]string, 0, len(vS.physicalContainerLayoutMap))
+	for _, physicalContainerLayout := range vS.physicalContainerLayoutMap {
+		prefixes = append(prefixes, physicalContainerLayout.physicalContainerNamePrefix)
+	}
+	vS.Unlock()
+
+	containerNames = make([]string, 0, len(containerList))
+
+	for _, containerName := range containerList {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(containerName, prefix) {
+				containerNames = append(containerNames, containerName)
+				break
+			}
+		}
+	}
+
+	return
+}
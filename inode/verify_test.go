@@ -0,0 +1,58 @@
+package inode
+
+import (
+	"testing"
+)
+
+func TestLogSegmentLocations(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	locations, err := testVolumeHandle.FetchLogSegmentLocations(fileInodeNumber)
+	if (nil != err) || (0 != len(locations)) {
+		t.Fatalf("FetchLogSegmentLocations() of empty file returned %+v, %v", locations, err)
+	}
+
+	// Unflushed writes are flushed by FetchLogSegmentLocations()
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	locations, err = testVolumeHandle.FetchLogSegmentLocations(fileInodeNumber)
+	if (nil != err) || (1 != len(locations)) {
+		t.Fatalf("FetchLogSegmentLocations() returned %+v, %v", locations, err)
+	}
+
+	containerNames, err := testVolumeHandle.FetchPhysicalContainerNames()
+	if nil != err {
+		t.Fatalf("FetchPhysicalContainerNames() failed: %v", err)
+	}
+	found := false
+	for _, containerName := range containerNames {
+		if locations[0].ContainerName == containerName {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("FetchPhysicalContainerNames() returned %v lacking LogSegment's Container %v", containerNames, locations[0].ContainerName)
+	}
+
+	_, err = testVolumeHandle.FetchLogSegmentLocations(RootDirInodeNumber)
+	if nil == err {
+		t.Fatalf("FetchLogSegmentLocations() of a directory should have failed")
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
@@ -4,3 +4,16 @@ func (vS *volumeStruct) GetFSID() (fsid uint64) {
 	fsid = vS.fsid
 	return
 }
+
+func (vS *volumeStruct) GetAccountName() (accountName string) {
+	accountName = vS.accountName
+	return
+}
+
+// GetInodeCount returns the number of inodes recorded for the volume.
+//
+// Note: Newly created inodes not yet flushed to headhunter are not included.
+func (vS *volumeStruct) GetInodeCount() (inodeCount uint64, err error) {
+	inodeCount, err = vS.headhunterVolumeHandle.FetchInodeRecCount()
+	return
+}
@@ -0,0 +1,124 @@
+package inode
+
+// Write-back staging
+//
+// Normally, Write() sends each buffer to the file's open LogSegment (via its Chunked PUT) before
+// returning. With [<volume-section>]WriteBackBudget non-zero, Write() instead stages a copy of the
+// buffer in memory and returns at once, coalescing contiguous writes so that they are later sent as
+// a single chunk. The file's Size, ModificationTime, and NumWrites reflect staged writes immediately.
+//
+// Staged writes are sent before the file's extent map is consulted (e.g. by Read() or SetSize()) and
+// whenever the inode is flushed. Hence Flush() (and the flush that follows each Write() by at most
+// MaxFlushTime) remains the durability barrier. Staged data counts against a budget shared by all of
+// the volume's file inodes. Should staging a write exceed it, the inode's staged writes are sent first
+// and, if the budget is still exceeded, the write is sent directly as if staging were disabled.
+
+import (
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+type stagedWriteStruct struct {
+	offset uint64
+	buf    []byte
+}
+
+// stageWrite stages a copy of buf at offset in fileInode, reporting false if WriteBackBudget is zero or
+// couldn't accommodate buf (in which case the caller must send buf itself). Caller must hold fileInode's
+// exclusive lock.
+func (vS *volumeStruct) stageWrite(fileInode *inMemoryInodeStruct, offset uint64, buf []byte) (staged bool, err error) {
+	length := uint64(len(buf))
+
+	vS.Lock()
+	if (0 == vS.writeBackBudget) || (0 == length) {
+		vS.Unlock()
+		return
+	}
+	if (vS.stagedBytes + length) > vS.writeBackBudget {
+		vS.Unlock()
+		err = vS.sendStagedWrites(fileInode)
+		if nil != err {
+			return
+		}
+		vS.Lock()
+		if (vS.stagedBytes + length) > vS.writeBackBudget {
+			vS.Unlock()
+			stats.IncrementOperations(&stats.FileWriteBackBudgetExceededOps)
+			return
+		}
+	}
+	vS.stagedBytes += length
+	vS.Unlock()
+
+	fileInode.stagingMutex.Lock()
+	lastIndex := len(fileInode.stagedWrites) - 1
+	if (0 <= lastIndex) && ((fileInode.stagedWrites[lastIndex].offset + uint64(len(fileInode.stagedWrites[lastIndex].buf))) == offset) {
+		fileInode.stagedWrites[lastIndex].buf = append(fileInode.stagedWrites[lastIndex].buf, buf...)
+	} else {
+		bufCopy := make([]byte, length)
+		copy(bufCopy, buf)
+		fileInode.stagedWrites = append(fileInode.stagedWrites, &stagedWriteStruct{offset: offset, buf: bufCopy})
+	}
+	fileInode.stagingMutex.Unlock()
+
+	stats.IncrementOperations(&stats.FileWriteStagedOps)
+
+	staged = true
+	return
+}
+
+// sendStagedWrites sends fileInode's staged writes (if any), in order, to its open LogSegment and records
+// each in its extent map.
+//
+// A shared lock on fileInode suffices as no writes may be staged while it is held. Concurrent callers
+// are serialized such that only the first finds anything left to send.
+func (vS *volumeStruct) sendStagedWrites(fileInode *inMemoryInodeStruct) (err error) {
+	fileInode.stagingMutex.Lock()
+	defer fileInode.stagingMutex.Unlock()
+
+	for 0 < len(fileInode.stagedWrites) {
+		stagedWrite := fileInode.stagedWrites[0]
+		length := uint64(len(stagedWrite.buf))
+
+		logSegmentNumber, logSegmentOffset, sendErr := vS.doSendChunk(fileInode, stagedWrite.buf)
+		if nil != sendErr {
+			err = sendErr
+			return
+		}
+
+		err = recordWrite(fileInode, stagedWrite.offset, length, logSegmentNumber, logSegmentOffset)
+		if nil != err {
+			return
+		}
+
+		fileInode.stagedWrites[0] = nil
+		fileInode.stagedWrites = fileInode.stagedWrites[1:]
+
+		vS.Lock()
+		vS.stagedBytes -= length
+		vS.Unlock()
+
+		stats.IncrementOperations(&stats.FileWriteBackSendOps)
+	}
+
+	fileInode.stagedWrites = nil
+
+	return
+}
+
+// discardStagedWrites drops fileInode's staged writes (if any) as it is destroyed.
+func (vS *volumeStruct) discardStagedWrites(fileInode *inMemoryInodeStruct) {
+	discardedBytes := uint64(0)
+
+	fileInode.stagingMutex.Lock()
+	for _, stagedWrite := range fileInode.stagedWrites {
+		discardedBytes += uint64(len(stagedWrite.buf))
+	}
+	fileInode.stagedWrites = nil
+	fileInode.stagingMutex.Unlock()
+
+	if 0 < discardedBytes {
+		vS.Lock()
+		vS.stagedBytes -= discardedBytes
+		vS.Unlock()
+	}
+}
@@ -0,0 +1,116 @@
+package inode
+
+import (
+	"testing"
+)
+
+func TestWriteBack(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+	testVolume := testVolumeHandle.(*volumeStruct)
+
+	stagedBytes := func() (bytes uint64) {
+		testVolume.Lock()
+		bytes = testVolume.stagedBytes
+		testVolume.Unlock()
+		return
+	}
+
+	testVolume.Lock()
+	testVolume.writeBackBudget = 16
+	testVolume.Unlock()
+
+	defer func() {
+		testVolume.Lock()
+		testVolume.writeBackBudget = 0
+		testVolume.Unlock()
+	}()
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Contiguous writes are staged as one
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte("abc"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 3, []byte("def"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	fileInode, err := testVolume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	if (1 != len(fileInode.stagedWrites)) || (6 != stagedBytes()) {
+		t.Fatalf("expected a single 6 byte staged write, got %v staged writes totaling %v bytes", len(fileInode.stagedWrites), stagedBytes())
+	}
+
+	metadata, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if (nil != err) || (6 != metadata.Size) || (2 != metadata.NumWrites) {
+		t.Fatalf("GetMetadata() with staged writes returned %+v, %v", metadata, err)
+	}
+
+	// Read() sends staged writes first
+
+	buf, err := testVolumeHandle.Read(fileInodeNumber, 0, 6, nil)
+	if (nil != err) || ("abcdef" != string(buf)) {
+		t.Fatalf("Read() returned \"%s\", %v", string(buf), err)
+	}
+	if (0 != len(fileInode.stagedWrites)) || (0 != stagedBytes()) {
+		t.Fatalf("Read() left %v staged writes totaling %v bytes", len(fileInode.stagedWrites), stagedBytes())
+	}
+
+	// A write exceeding the budget is sent directly (after any staged writes)
+
+	err = testVolumeHandle.Write(fileInodeNumber, 1, []byte("BC"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 2, []byte("0123456789ABCDEFGHIJ"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if (0 != len(fileInode.stagedWrites)) || (0 != stagedBytes()) {
+		t.Fatalf("Write() exceeding budget left %v staged writes totaling %v bytes", len(fileInode.stagedWrites), stagedBytes())
+	}
+
+	// Flush() sends staged writes
+
+	err = testVolumeHandle.Write(fileInodeNumber, 22, []byte("xyz"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	if 0 != stagedBytes() {
+		t.Fatalf("Flush() left %v staged bytes", stagedBytes())
+	}
+
+	buf, err = testVolumeHandle.Read(fileInodeNumber, 0, 25, nil)
+	if (nil != err) || ("aB0123456789ABCDEFGHIJxyz" != string(buf)) {
+		t.Fatalf("Read() returned \"%s\", %v", string(buf), err)
+	}
+
+	// Destroy() discards staged writes
+
+	err = testVolumeHandle.Write(fileInodeNumber, 25, []byte("!"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+	if 0 != stagedBytes() {
+		t.Fatalf("Destroy() left %v staged bytes", stagedBytes())
+	}
+}
@@ -0,0 +1,86 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCoalesceWriteSegments(t *testing.T) {
+	first := []byte("abc")
+	segments := []WriteSegment{
+		{Offset: 0, Buf: first},
+		{Offset: 3, Buf: []byte("def")},
+		{Offset: 9, Buf: []byte{}},
+		{Offset: 10, Buf: []byte("xyz")},
+		{Offset: 6, Buf: []byte("g")},
+		{Offset: 7, Buf: []byte("hi")},
+	}
+
+	coalescedSegments := coalesceWriteSegments(segments)
+
+	if (3 != len(coalescedSegments)) ||
+		(0 != coalescedSegments[0].Offset) || !bytes.Equal([]byte("abcdef"), coalescedSegments[0].Buf) ||
+		(10 != coalescedSegments[1].Offset) || !bytes.Equal([]byte("xyz"), coalescedSegments[1].Buf) ||
+		(6 != coalescedSegments[2].Offset) || !bytes.Equal([]byte("ghi"), coalescedSegments[2].Buf) {
+		t.Fatalf("coalesceWriteSegments() returned unexpected %+v", coalescedSegments)
+	}
+	if !bytes.Equal([]byte("abc"), first) || (3 != len(segments[0].Buf)) {
+		t.Fatalf("coalesceWriteSegments() modified the caller's segments")
+	}
+}
+
+func TestWritev(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Later segments overwrite earlier ones, yet all count as a single write
+
+	err = testVolumeHandle.Writev(fileInodeNumber, []WriteSegment{
+		{Offset: 0, Buf: []byte("0123")},
+		{Offset: 4, Buf: []byte("4567")},
+		{Offset: 12, Buf: []byte("cd")},
+		{Offset: 2, Buf: []byte("XY")},
+	}, nil)
+	if nil != err {
+		t.Fatalf("Writev() failed: %v", err)
+	}
+
+	metadata, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if (14 != metadata.Size) || (1 != metadata.NumWrites) {
+		t.Fatalf("Writev() left Size %v & NumWrites %v (expected 14 & 1)", metadata.Size, metadata.NumWrites)
+	}
+
+	buf, err := testVolumeHandle.Read(fileInodeNumber, 0, 14, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if !bytes.Equal([]byte("01XY4567\x00\x00\x00\x00cd"), buf) {
+		t.Fatalf("Read() after Writev() returned %q", buf)
+	}
+
+	// Nothing to write is not a write
+
+	err = testVolumeHandle.Writev(fileInodeNumber, []WriteSegment{{Offset: 20, Buf: nil}}, nil)
+	if nil != err {
+		t.Fatalf("Writev() of empty segment failed: %v", err)
+	}
+	metadata, err = testVolumeHandle.GetMetadata(fileInodeNumber)
+	if (nil != err) || (14 != metadata.Size) || (1 != metadata.NumWrites) {
+		t.Fatalf("Writev() of empty segment left %+v, %v", metadata, err)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package jrpcfs
+
+// Access logging
+//
+// Each middleware RPC (i.e. one made by pfs_middleware on behalf of a Swift API request) carries
+// in its TransId field the X-Trans-Id of the Swift request being served. If [JSONRPCServer]
+// AccessLogFilePath is set, a record of each such RPC (its op, virtual path, bytes, duration,
+// result, and TransId) is appended to that file as a line of JSON so that it may be joined with
+// the proxy-server's own logs. Only a [JSONRPCServer]AccessLogSampleRate fraction (0.0 through
+// 1.0, defaulting to 1.0) of successful RPCs are recorded; failed RPCs are always recorded. As
+// with the main log, the file is closed and reopened upon SIGHUP to permit rotation.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+type accessLogStruct struct {
+	sync.Mutex
+	file       *os.File // nil == access logging disabled
+	sampleRate float64
+}
+
+type accessLogRecordStruct struct {
+	Time     string  `json:"time"`
+	TransID  string  `json:"trans_id"`
+	Op       string  `json:"op"`
+	Path     string  `json:"path"`
+	Bytes    uint64  `json:"bytes"`
+	Duration float64 `json:"duration"` // in seconds
+	Result   int     `json:"result"`   // 0 == success, else errno
+}
+
+var accessLog accessLogStruct
+
+// middlewareOpStruct is the context of a single middleware RPC for access logging purposes.
+type middlewareOpStruct struct {
+	op      string
+	transID string
+	path    string
+	start   time.Time
+	bytes   uint64 // set by the RPC if it has a meaningful byte count (e.g. an object's size)
+}
+
+func accessLogUp(confMap conf.ConfMap) (err error) {
+	accessLogFilePath, fetchErr := confMap.FetchOptionValueString("JSONRPCServer", "AccessLogFilePath")
+	if (nil != fetchErr) || ("" == accessLogFilePath) {
+		return // access logging disabled
+	}
+
+	sampleRate, fetchErr := confMap.FetchOptionValueFloat64("JSONRPCServer", "AccessLogSampleRate")
+	if nil != fetchErr {
+		sampleRate = 1.0
+	}
+	if (0.0 > sampleRate) || (1.0 < sampleRate) {
+		err = fmt.Errorf("[JSONRPCServer]AccessLogSampleRate (%v) must be between 0.0 and 1.0", sampleRate)
+		return
+	}
+
+	file, err := os.OpenFile(accessLogFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if nil != err {
+		err = fmt.Errorf("couldn't open [JSONRPCServer]AccessLogFilePath (%s): %v", accessLogFilePath, err)
+		return
+	}
+
+	accessLog.Lock()
+	accessLog.file = file
+	accessLog.sampleRate = sampleRate
+	accessLog.Unlock()
+
+	return
+}
+
+func accessLogDown() {
+	accessLog.Lock()
+	if nil != accessLog.file {
+		_ = accessLog.file.Close()
+		accessLog.file = nil
+	}
+	accessLog.Unlock()
+}
+
+func beginMiddlewareOp(op string, transID string, path string) (mOp *middlewareOpStruct) {
+	mOp = &middlewareOpStruct{
+		op:      op,
+		transID: transID,
+		path:    path,
+		start:   time.Now(),
+	}
+	return
+}
+
+// end records mOp in the access log (subject to sampling).
+//
+// As err must not yet have been encoded for return by RPC, end() should be deferred after rpcEncodeError().
+func (mOp *middlewareOpStruct) end(err error) {
+	duration := time.Since(mOp.start)
+	result := blunder.Errno(err)
+
+	accessLog.Lock()
+	defer accessLog.Unlock()
+
+	if nil == accessLog.file {
+		return
+	}
+	if (0 == result) && (rand.Float64() >= accessLog.sampleRate) {
+		return
+	}
+
+	buf, marshalErr := json.Marshal(&accessLogRecordStruct{
+		Time:     mOp.start.UTC().Format(time.RFC3339Nano),
+		TransID:  mOp.transID,
+		Op:       mOp.op,
+		Path:     mOp.path,
+		Bytes:    mOp.bytes,
+		Duration: duration.Seconds(),
+		Result:   result,
+	})
+	if nil != marshalErr {
+		logger.ErrorfWithError(marshalErr, "unable to marshal access log record for %s", mOp.op)
+		return
+	}
+
+	_, writeErr := accessLog.file.Write(append(buf, '\n'))
+	if nil != writeErr {
+		logger.ErrorfWithError(writeErr, "unable to write access log record for %s", mOp.op)
+	}
+}
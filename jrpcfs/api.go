@@ -154,6 +154,12 @@ type ChownPathRequest struct {
 	GroupID int32
 }
 
+// CloseRequest is the request object for RpcClose.
+type CloseRequest struct {
+	MountID    uint64
+	FileHandle uint64
+}
+
 // CreateRequest is the request object for RpcCreate.
 type CreateRequest struct {
 	InodeHandle
@@ -171,9 +177,19 @@ type CreatePathRequest struct {
 	FileMode uint32
 }
 
+// CreateUnlinkedRequest is the request object for RpcCreateUnlinked.
+//
+// The InodeHandle identifies the directory the unnamed file is created "in" (for permission checking only).
+type CreateUnlinkedRequest struct {
+	InodeHandle
+	UserID   int32
+	GroupID  int32
+	FileMode uint32
+}
+
 // DirEntry is used as part of ReaddirReply and ReaddirPlusReply.
 //
-// FileType here will be a uint16 containing DT_DIR|DT_REG|DT_LNK.
+// FileType here will be a uint16 containing DT_DIR|DT_REG|DT_LNK|DT_FIFO|DT_SOCK.
 //
 type DirEntry struct {
 	InodeNumber     uint64
@@ -182,6 +198,62 @@ type DirEntry struct {
 	NextDirLocation uint32
 }
 
+// DurableHandle is embedded in the request objects of the ...ByDurableHandle RPCs.
+//
+// InodeNumber and Generation are as returned by RpcOpenDurable (see fs/durable.go). Should the inode
+// no longer exist (or be of a different Generation), these RPCs fail with ESTALE.
+type DurableHandle struct {
+	MountID     uint64
+	InodeNumber uint64
+	Generation  uint64
+}
+
+// OpenDurableRequest is the request object for RpcOpenDurable.
+type OpenDurableRequest struct {
+	PathHandle
+	UserID  int32
+	GroupID int32
+}
+
+// OpenDurableReply is the reply object for RpcOpenDurable.
+type OpenDurableReply struct {
+	InodeNumber uint64
+	Generation  uint64
+}
+
+// GetStatByDurableHandleRequest is the request object for RpcGetStatByDurableHandle.
+type GetStatByDurableHandleRequest struct {
+	DurableHandle
+	UserID  int32
+	GroupID int32
+}
+
+// GetXAttrByDurableHandleRequest is the request object for RpcGetXAttrByDurableHandle.
+type GetXAttrByDurableHandleRequest struct {
+	DurableHandle
+	UserID   int32
+	GroupID  int32
+	AttrName string
+}
+
+// ListXAttrByDurableHandleRequest is the request object for RpcListXAttrByDurableHandle.
+type ListXAttrByDurableHandleRequest struct {
+	DurableHandle
+	UserID  int32
+	GroupID int32
+}
+
+// OpenByDurableHandleRequest is the request object for RpcOpenByDurableHandle.
+//
+// As for OpenRequest, but opening the file identified by DurableHandle.
+type OpenByDurableHandleRequest struct {
+	DurableHandle
+	UserID    int32
+	GroupID   int32
+	OpenFlags uint32
+	ShareMode uint32
+}
+
 // FlushRequest is the request object for RpcFlush.
 type FlushRequest struct {
 	InodeHandle
@@ -189,6 +261,23 @@ type FlushRequest struct {
 	SendTimeNsec int64
 }
 
+// FlushDirRequest is the request object for RpcFlushDir.
+type FlushDirRequest struct {
+	InodeHandle
+	UserID  int32
+	GroupID int32
+}
+
+// GetNFS4ACLRequest is the request object for RpcGetNFS4ACL.
+type GetNFS4ACLRequest struct {
+	InodeHandle
+}
+
+// GetNFS4ACLReply is the reply object for RpcGetNFS4ACL.
+type GetNFS4ACLReply struct {
+	ACEs []NFS4ACE
+}
+
 // GetStatRequest is the request object for RpcGetStat.
 type GetStatRequest struct {
 	InodeHandle
@@ -222,6 +311,7 @@ type FlockRequest struct {
 	FlockStart  uint64
 	FlockLen    uint64
 	FlockPid    uint64
+	FileHandle  uint64 // if non-zero, the open (see RpcOpen) owning the lock... InodeNumber & FlockPid are ignored
 }
 
 type FlockReply struct {
@@ -232,6 +322,26 @@ type FlockReply struct {
 	FlockPid    uint64
 }
 
+// GetLimitsRequest is the request object for RpcGetLimits.
+type GetLimitsRequest struct {
+	MountID uint64
+}
+
+// GetLimitsReply is the reply object for RpcGetLimits.
+//
+// Each field is the like-named field of fs.LimitsStruct.
+type GetLimitsReply struct {
+	FileNameMax            uint64
+	FilePathMax            uint64
+	MaxSymlinks            uint64
+	FsBlockSize            uint64
+	OptimalTransferSize    uint64
+	XAttrNameMax           uint64
+	XAttrValueMax          uint64
+	MaxEntriesPerOperation uint64
+	MaxBytesPerOperation   uint64
+}
+
 // InodeHandle is embedded in a number of the request objects.
 type InodeHandle struct {
 	MountID     uint64
@@ -244,6 +354,74 @@ type InodeReply struct {
 	InodeNumber uint64
 }
 
+// LeaseAcquireRequest is the request object for RpcLeaseAcquire.
+//
+// LeaseType is an fs.LeaseType (1 == LeaseRead, 2 == LeaseWrite). RpcLeaseAcquire does not return
+// until any conflicting leases held via other mounts have been downgraded (see RpcLeaseBreakFetch).
+type LeaseAcquireRequest struct {
+	InodeHandle
+	UserID    int32
+	GroupID   int32
+	LeaseType uint32
+}
+
+// LeaseAcquireReply is the reply object for RpcLeaseAcquire.
+type LeaseAcquireReply struct {
+	LeaseID uint64
+}
+
+// LeaseBreak asks that LeaseID (on InodeNumber) be downgraded to BreakTo (an fs.LeaseType).
+type LeaseBreak struct {
+	LeaseID     uint64
+	InodeNumber uint64
+	BreakTo     uint32
+}
+
+// LeaseBreakFetchRequest is the request object for RpcLeaseBreakFetch.
+//
+// RpcLeaseBreakFetch returns as soon as at least one lease break for MountID is available (up to
+// MaxBreaks of them) or, if none arrive, after TimeoutMsec has elapsed.
+type LeaseBreakFetchRequest struct {
+	MountID     uint64
+	MaxBreaks   uint64
+	TimeoutMsec uint64
+}
+
+// LeaseBreakFetchReply is the reply object for RpcLeaseBreakFetch.
+type LeaseBreakFetchReply struct {
+	Breaks []LeaseBreak
+}
+
+// LeaseDowngradeRequest is the request object for RpcLeaseDowngrade.
+type LeaseDowngradeRequest struct {
+	MountID   uint64
+	LeaseID   uint64
+	LeaseType uint32
+}
+
+// LeaseReclaimRequest is the request object for RpcLeaseReclaim.
+//
+// LeaseID is one acquired (typically via a since-forgotten MountID) before the volume was last taken
+// offline. Subsequent breaks of it are queued for MountID (see RpcLeaseBreakFetch).
+type LeaseReclaimRequest struct {
+	MountID uint64
+	UserID  int32
+	GroupID int32
+	LeaseID uint64
+}
+
+// LeaseReclaimReply is the reply object for RpcLeaseReclaim.
+type LeaseReclaimReply struct {
+	InodeNumber uint64
+	LeaseType   uint32
+}
+
+// LeaseReleaseRequest is the request object for RpcLeaseRelease.
+type LeaseReleaseRequest struct {
+	MountID uint64
+	LeaseID uint64
+}
+
 // LogRequest is the request object for RpcLog.
 type LogRequest struct {
 	Message string
@@ -255,6 +433,14 @@ type LookupPathRequest struct {
 	Fullpath string
 }
 
+// LookupPathAtRequest is the request object for RpcLookupPathAt.
+//
+// Path is resolved relative to the directory InodeNumber (unless it begins with "/").
+type LookupPathAtRequest struct {
+	InodeHandle
+	Path string
+}
+
 // LinkRequest is the request object for RpcLinkPath.
 type LinkRequest struct {
 	InodeHandle
@@ -262,6 +448,13 @@ type LinkRequest struct {
 	TargetInodeNumber uint64
 }
 
+// LinkByInodeRequest is the request object for RpcLinkByInode.
+type LinkByInodeRequest struct {
+	InodeHandle
+	Basename          string
+	TargetInodeNumber uint64
+}
+
 // LinkPathRequest is the request object for .
 type LinkPathRequest struct {
 	PathHandle
@@ -286,6 +479,18 @@ type LookupRequest struct {
 	Basename string
 }
 
+// LookupMultiRequest is the request object for RpcLookupMulti.
+type LookupMultiRequest struct {
+	InodeHandle
+	Basenames []string
+}
+
+// LookupMultiReply is the reply object for RpcLookupMulti.
+type LookupMultiReply struct {
+	InodeNumbers []uint64 // one per LookupMultiRequest.Basenames element (in order); valid if Errnos[i] == 0
+	Errnos       []int    // 0 == found
+}
+
 // MkdirRequest is the request object for RpcMkdir.
 type MkdirRequest struct {
 	InodeHandle
@@ -303,12 +508,34 @@ type MkdirPathRequest struct {
 	FileMode uint32
 }
 
+// MknodRequest is the request object for RpcMknod.
+//
+// FileMode includes the file type bits (S_IFIFO, S_IFSOCK, or S_IFREG) along with the permission bits.
+type MknodRequest struct {
+	InodeHandle
+	Basename string
+	UserID   int32
+	GroupID  int32
+	FileMode uint32
+}
+
 // MountRequest is the request object for RpcMount.
 type MountRequest struct {
 	VolumeName   string
 	MountOptions uint64
 	AuthUserID   uint64
 	AuthGroupID  uint64
+	RootSquash   bool              // if true, requests made as root act as AnonUserID/AnonGroupID
+	AnonUserID   uint32            // used only if RootSquash is true
+	AnonGroupID  uint32            // used only if RootSquash is true
+	UserIDMap    map[uint32]uint32 // key == client userID; value == userID used in volume
+	GroupIDMap   map[uint32]uint32 // key == client groupID; value == groupID used in volume
+	AuthMethod   uint32            // one of the fs.MountAuth* constants (see fs/auth.go)
+	AuthSecret   string            // used only if AuthMethod is fs.MountAuthSecret
+	AuthToken    string            // used only if AuthMethod is fs.MountAuthToken
+	AuthCert     []byte            // used only if AuthMethod is fs.MountAuthCertificate (DER-encoded)
+	ClientAddr   string            // set by the server from the connection (any value sent is ignored)
+	Umask        uint32            // cleared from the mode of each file & directory created via the mount (see fs/umask.go)
 }
 
 // MountReply is the reply object for RpcMount.
@@ -317,6 +544,54 @@ type MountReply struct {
 	RootDirInodeNumber uint64
 }
 
+// UnmountRequest is the request object for RpcUnmount.
+//
+// RpcUnmount fails with EBUSY should any FileHandle opened via MountID remain open.
+type UnmountRequest struct {
+	MountID uint64
+}
+
+// NotifyEvent is used as part of WatchFetchReply.
+//
+// EventType here will be one of the fs.Notify* constants (e.g. fs.NotifyCreate).
+//
+type NotifyEvent struct {
+	EventType         uint32
+	InodeNumber       uint64
+	ParentInodeNumber uint64
+	Basename          string
+}
+
+// OpenRequest is the request object for RpcOpen.
+//
+// OpenFlags is a mask of fs.OpenFlags (e.g. fs.OpenRead) and ShareMode a mask of fs.ShareMode (e.g.
+// fs.ShareRead). The returned FileHandle may be presented in subsequent ReadRequest, WriteRequest, and
+// FlockRequest objects until passed to RpcClose.
+type OpenRequest struct {
+	InodeHandle
+	UserID    int32
+	GroupID   int32
+	OpenFlags uint32
+	ShareMode uint32
+}
+
+// OpenReply is the reply object for RpcOpen.
+type OpenReply struct {
+	FileHandle uint64
+}
+
+// OpenAtRequest is the request object for RpcOpenAt.
+//
+// As for OpenRequest, but opening the file Path resolved relative to the directory InodeNumber.
+type OpenAtRequest struct {
+	InodeHandle
+	UserID    int32
+	GroupID   int32
+	Path      string
+	OpenFlags uint32
+	ShareMode uint32
+}
+
 // PathHandle is embedded in a number of the request objects.
 type PathHandle struct {
 	MountID  uint64
@@ -327,6 +602,7 @@ type PathHandle struct {
 type ReaddirPlusRequest struct {
 	InodeHandle
 	PrevDirLocation int64
+	Pattern         string // if non-empty, only the next entry whose basename matches (see path.Match()) is returned
 }
 
 // ReaddirPlusReply is the reply object for RpcReaddirPlus.
@@ -339,6 +615,7 @@ type ReaddirPlusReply struct {
 type ReaddirRequest struct {
 	InodeHandle
 	PrevDirLocation int64
+	Pattern         string // if non-empty, only the next entry whose basename matches (see path.Match()) is returned
 }
 
 // ReaddirReply is the reply object for RpcReaddir.
@@ -346,11 +623,32 @@ type ReaddirReply struct {
 	DirEnts []DirEntry
 }
 
+// ReaddirByTokenRequest is the request object for RpcReaddirByToken.
+//
+// An empty ContinuationToken starts at the beginning of the directory.
+type ReaddirByTokenRequest struct {
+	InodeHandle
+	ContinuationToken string
+	MaxEntries        uint64
+	MaxBufSize        uint64
+}
+
+// ReaddirByTokenReply is the reply object for RpcReaddirByToken.
+//
+// ContinuationToken resumes the listing following the last of DirEnts (see fs/continuation.go).
+type ReaddirByTokenReply struct {
+	DirEnts           []DirEntry
+	ContinuationToken string
+	AreMoreEntries    bool
+}
+
 // ReadRequest is the request object for RpcRead.
 type ReadRequest struct {
 	InodeHandle
 	Offset       uint64
 	Length       uint64
+	FlockPid     uint64 // if non-zero, the byte-range lock owner (see RpcFlock) performing the I/O
+	FileHandle   uint64 // if non-zero, the open (see RpcOpen) performing the I/O... InodeNumber & FlockPid are ignored
 	SendTimeSec  int64
 	SendTimeNsec int64
 }
@@ -379,6 +677,13 @@ type ReadSymlinkReply struct {
 	Target string
 }
 
+// ReleaseUnlinkedRequest is the request object for RpcReleaseUnlinked.
+type ReleaseUnlinkedRequest struct {
+	InodeHandle
+	UserID  int32
+	GroupID int32
+}
+
 type RemoveXAttrRequest struct {
 	InodeHandle
 	AttrName string
@@ -396,12 +701,26 @@ type RenameRequest struct {
 	SrcBasename       string
 	DstDirInodeNumber uint64
 	DstBasename       string
+	Flags             uint32 // renameat2() flags (RENAME_NOREPLACE and/or RENAME_EXCHANGE)
+}
+
+// RenameAtRequest is the request object for RpcRenameAt.
+//
+// SrcPath and DstPath are resolved relative to SrcDirInodeNumber and DstDirInodeNumber respectively.
+type RenameAtRequest struct {
+	MountID           uint64
+	SrcDirInodeNumber uint64
+	SrcPath           string
+	DstDirInodeNumber uint64
+	DstPath           string
+	Flags             uint32 // renameat2() flags (RENAME_NOREPLACE and/or RENAME_EXCHANGE)
 }
 
 // RenamePathRequest is the request object for RpcRenamePath.
 type RenamePathRequest struct {
 	PathHandle
 	DstFullpath string
+	Flags       uint32 // renameat2() flags (RENAME_NOREPLACE and/or RENAME_EXCHANGE)
 }
 
 // Reply is a generic response object used when no values need to be returned.
@@ -419,6 +738,24 @@ type ResizeRequest struct {
 	NewSize uint64
 }
 
+// NFS4ACE is an inode.NFS4ACEStruct (see inode/nfs4acl.go for the meaning of each field).
+type NFS4ACE struct {
+	Type  uint32
+	Flags uint32
+	Mask  uint32
+	Who   uint32
+}
+
+// SetNFS4ACLRequest is the request object for RpcSetNFS4ACL.
+//
+// If Remove is true, any NFSv4 ACL is removed (and ACEs ignored). Otherwise, the ACL is set to ACEs
+// (an empty ACL denying all but root).
+type SetNFS4ACLRequest struct {
+	InodeHandle
+	ACEs   []NFS4ACE
+	Remove bool
+}
+
 // SetstatRequest is the request object for RpcSetstat.
 type SetstatRequest struct {
 	InodeHandle
@@ -444,6 +781,18 @@ type SetXAttrRequest struct {
 	AttrFlags int
 }
 
+// SetXAttrIfMatchRequest is the request object for RpcSetXAttrIfMatch.
+//
+// AttrName is only set to AttrValue if its current value is OldAttrValue or, if OldAttrAbsent is true,
+// if it is not currently set. Otherwise, RpcSetXAttrIfMatch fails with EAGAIN.
+type SetXAttrIfMatchRequest struct {
+	InodeHandle
+	AttrName      string
+	OldAttrValue  []byte
+	OldAttrAbsent bool
+	AttrValue     []byte
+}
+
 type SetXAttrPathRequest struct {
 	PathHandle
 	AttrName  string
@@ -453,7 +802,8 @@ type SetXAttrPathRequest struct {
 
 // StatVFSRequest is the request object for RpcStatVFS.
 type StatVFSRequest struct {
-	MountID uint64
+	MountID     uint64
+	InodeNumber uint64 // if non-zero, capacity available to the subtree containing InodeNumber is reported
 }
 
 // StatVFS is used when filesystem stats need to be conveyed. It is used by RpcStatVFS.
@@ -474,7 +824,8 @@ type StatVFS struct {
 // StatStruct is used when stats need to be conveyed. It is used as the response to RpcGetStat and RpcGetStatPath,
 // as well as in RpcSetStat and RpcReaddirPlus.
 //
-// Note that times are conveyed as nanoseconds since epoch.
+// Note that times are conveyed as nanoseconds since epoch. CRTimeNs is the birth time. Blocks, BlkSize, and ChangeCount
+// are ignored by RpcSetStat.
 //
 type StatStruct struct {
 	CTimeNs         uint64
@@ -487,6 +838,9 @@ type StatStruct struct {
 	FileMode        uint32
 	UserID          uint32
 	GroupID         uint32
+	Blocks          uint64 // number of 512-byte blocks allocated
+	BlkSize         uint64 // preferred I/O size in bytes
+	ChangeCount     uint64 // incremented with each change to inode data or attributes
 }
 
 // SymlinkRequest is the request object for RpcSymlink.
@@ -513,7 +867,7 @@ type TypeRequest struct {
 
 // TypeReply is the reply object for RpcType.
 //
-// FileType here will be a uint16 containing DT_DIR|DT_REG|DT_LNK.
+// FileType here will be a uint16 containing DT_DIR|DT_REG|DT_LNK|DT_FIFO|DT_SOCK.
 //
 type TypeReply struct {
 	FileType uint16
@@ -525,16 +879,62 @@ type UnlinkRequest struct {
 	Basename string
 }
 
+// UnlinkAtRequest is the request object for RpcUnlinkAt.
+//
+// Path is resolved relative to the directory InodeNumber. If RemoveDir is true (i.e. AT_REMOVEDIR),
+// Path must name an empty directory rather than a non-directory.
+type UnlinkAtRequest struct {
+	InodeHandle
+	Path      string
+	RemoveDir bool
+}
+
 // UnlinkPathRequest is the request object for RpcUnlinkPath.
 type UnlinkPathRequest struct {
 	PathHandle
 }
 
+// WatchAddRequest is the request object for RpcWatchAdd.
+type WatchAddRequest struct {
+	InodeHandle
+	Subtree bool
+}
+
+// WatchAddReply is the reply object for RpcWatchAdd.
+type WatchAddReply struct {
+	WatchID uint64
+}
+
+// WatchFetchRequest is the request object for RpcWatchFetch.
+//
+// RpcWatchFetch returns as soon as at least one event is available (up to MaxEvents of them)
+// or, if none arrive, after TimeoutMsec has elapsed.
+//
+type WatchFetchRequest struct {
+	MountID     uint64
+	WatchID     uint64
+	MaxEvents   uint64
+	TimeoutMsec uint64
+}
+
+// WatchFetchReply is the reply object for RpcWatchFetch.
+type WatchFetchReply struct {
+	Events []NotifyEvent
+}
+
+// WatchRemoveRequest is the request object for RpcWatchRemove.
+type WatchRemoveRequest struct {
+	MountID uint64
+	WatchID uint64
+}
+
 // WriteRequest is the request object for RpcWrite.
 type WriteRequest struct {
 	InodeHandle
 	Offset       uint64
 	Buf          []byte
+	FlockPid     uint64 // if non-zero, the byte-range lock owner (see RpcFlock) performing the I/O
+	FileHandle   uint64 // if non-zero, the open (see RpcOpen) performing the I/O... InodeNumber & FlockPid are ignored
 	SendTimeSec  int64
 	SendTimeNsec int64
 }
@@ -557,6 +957,7 @@ type WriteReply struct {
 // CreateContainerRequest is the request object for RpcCreateContainer.
 type CreateContainerRequest struct {
 	VirtPath string
+	TransId  string // Swift X-Trans-Id of the request being served (see access_log.go)
 }
 
 // CreateContainerReply is the reply object for RpcCreateContainer.
@@ -570,6 +971,44 @@ type DeleteReply struct {
 // DeleteReq is the request object for RpcDelete
 type DeleteReq struct {
 	VirtPath string
+	FreezeID uint64                     // if non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
+	TransId  string                     // Swift X-Trans-Id of the request being served (see access_log.go)
+	Caller   *fs.MiddlewareCallerStruct // if non-nil, subject to the container's ContainerACL
+}
+
+// DeleteMultiReq is the request object for RpcDeleteMulti
+type DeleteMultiReq struct {
+	VirtPath string                     // virtual account path, e.g. /v1/AUTH_acc
+	Entries  []fs.DeleteMultiEntry      // containers and/or objects within the account to delete
+	FreezeID uint64                     // if non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
+	TransId  string                     // Swift X-Trans-Id of the request being served (see access_log.go)
+	Caller   *fs.MiddlewareCallerStruct // if non-nil, subject to each container's ContainerACL
+}
+
+// DeleteMultiReply is the response object for RpcDeleteMulti
+type DeleteMultiReply struct {
+	Errnos      []int  // one per DeleteMultiReq.Entries element (in order); 0 == deleted
+	NumDeleted  uint64 // number of Errnos that are 0
+	NumNotFound uint64 // number of Errnos that are ENOENT (which Swift's bulk delete does not consider an error)
+	NumFailed   uint64 // number of other Errnos
+}
+
+// HeadMultipleReq is the request object for RpcHeadMultiple
+type HeadMultipleReq struct {
+	VirtPath    string   // virtual account path, e.g. /v1/AUTH_acc
+	EntityPaths []string // entity paths within the account, e.g. some-dir[/some-file]
+	TransId     string   // Swift X-Trans-Id of the request being served (see access_log.go)
+}
+
+// HeadMultipleEntity is the result of the HEAD of one of HeadMultipleReq.EntityPaths
+type HeadMultipleEntity struct {
+	Errno int // 0 == success (HeadReply is valid)
+	HeadReply
+}
+
+// HeadMultipleReply is the response object for RpcHeadMultiple
+type HeadMultipleReply struct {
+	Entities []HeadMultipleEntity // one per HeadMultipleReq.EntityPaths element (in order)
 }
 
 type HeadReply struct {
@@ -579,25 +1018,57 @@ type HeadReply struct {
 	InodeNumber      uint64
 	NumWrites        uint64
 	Metadata         []byte // entity metadata, serialized
+	ETag             string // hash of a file's contents (see fs/etag.go); empty if a directory or not computed
+	ContentType      string // detected Content-Type of a file (see fs/content_type.go); empty if a directory or none
 }
 
 type HeadReq struct {
 	VirtPath string // virtual entity path, e.g. /v1/AUTH_acc/some-dir[/some-file]
+	TransId  string // Swift X-Trans-Id of the request being served (see access_log.go)
 }
 
+// GetContainerCapabilities are bitwise or'd into GetContainerReq.Capabilities to indicate optional behaviors
+// the caller supports. GetContainerReply.Capabilities reports which of them were honored (a server predating
+// a capability leaves its bit clear).
+const (
+	GetContainerCapabilityContinuationToken uint64 = 1 << iota // paginate by ContinuationToken rather than Marker
+)
+
 // GetContainerReply is the response object for RpcGetContainer
 type GetContainerReply struct {
-	ContainerEntries []fs.ContainerEntry
-	ModificationTime uint64
-	Metadata         []byte // container metadata, serialized
+	ContainerEntries  []fs.ContainerEntry
+	ModificationTime  uint64
+	Metadata          []byte // container metadata, serialized
+	Capabilities      uint64 // those of GetContainerReq.Capabilities honored
+	ContinuationToken string // if GetContainerCapabilityContinuationToken honored, pass as GetContainerReq.ContinuationToken for the next page
 }
 
 // GetContainerReq is the request object for RpcGetContainer
 type GetContainerReq struct {
-	VirtPath   string // virtual container path, e.g. /v1/AUTH_acc/some-dir
-	Marker     string // marker from query string, used in pagination
-	Prefix     string // only look at entries starting with this
-	MaxEntries uint64 // maximum number of entries to return
+	VirtPath           string // virtual container path, e.g. /v1/AUTH_acc/some-dir
+	Marker             string // marker from query string, used in pagination
+	Prefix             string // only look at entries starting with this
+	Delimiter          string // delimiter from query string ("/" or ""); if non-empty, ContinuationToken is not honored
+	EndMarker          string // list only entries up to and including this ("" == no limit); if non-empty, ContinuationToken is not honored
+	EndMarkerExclusive bool   // if true, EndMarker itself is not listed (as with Swift's end_marker); ContinuationToken is not honored
+	Reverse            bool   // if true, list in reverse order (as with Swift's reverse); EndMarker is then exclusive, ContinuationToken not honored
+	MaxStalenessMsec   uint64 // if non-zero, a cached listing up to this stale may be returned (see fs.MiddlewareGetContainerCached()); not honored with an inclusive EndMarker
+	MaxEntries         uint64 // maximum number of entries to return
+	Capabilities       uint64 // bitwise or of GetContainerCapability* values supported by the caller
+	ContinuationToken  string // if GetContainerCapabilityContinuationToken, used (instead of Marker) if non-empty
+	TransId            string // Swift X-Trans-Id of the request being served (see access_log.go)
+}
+
+// GetContainerShardsReply is the response object for RpcGetContainerShards
+type GetContainerShardsReply struct {
+	Shards []fs.ContainerShard // in order; each to be listed via RpcGetContainer with its Marker & EndMarker
+}
+
+// GetContainerShardsReq is the request object for RpcGetContainerShards
+type GetContainerShardsReq struct {
+	VirtPath  string // virtual container path, e.g. /v1/AUTH_acc/some-dir
+	MaxShards uint64 // maximum number of shards to return
+	TransId   string // Swift X-Trans-Id of the request being served (see access_log.go)
 }
 
 // Response object for RpcGetAccount
@@ -609,7 +1080,10 @@ type GetAccountReply struct {
 type GetAccountReq struct {
 	VirtPath   string // account path, e.g. /v1/AUTH_acc
 	Marker     string // marker from query string, used in pagination
+	EndMarker  string // end_marker from query string ("" == no limit), itself not listed
+	Reverse    bool   // if true, list in reverse order (as with Swift's reverse)
 	MaxEntries uint64 // maximum number of entries to return
+	TransId    string // Swift X-Trans-Id of the request being served (see access_log.go)
 }
 
 // GetObjectReply is the response object for RpcGetObject
@@ -621,6 +1095,21 @@ type GetObjectReply struct {
 	Metadata         []byte // serialized object metadata (previously set by middleware; empty if absent)
 	ModificationTime uint64 // file's mtime in nanoseconds since the epoch
 	LeaseId          string
+	ETag             string // hash of the file's contents (see fs/etag.go); empty if not computed
+	ContentType      string // detected Content-Type of the file (see fs/content_type.go); empty if none
+}
+
+// PathFailure describes why RpcGetObject's VirtPath could not be resolved (see fs/path_failure.go).
+//
+// It is JSON-encoded following " pathFailure: " in the error returned, e.g.:
+//
+//   errno: 20 pathFailure: {"Reason":"NotDir","HTTPStatus":409,"ParentPath":"c/dir","Segment":"file.txt","Remaining":1}
+type PathFailure struct {
+	Reason     string // "NotFound", "NotDir", "SymlinkLoop", "PermDenied", or "Internal"
+	HTTPStatus int    // status best describing the failure to a Swift client
+	ParentPath string // container-relative path (with any symlinks followed) of the directory containing Segment
+	Segment    string // path component at which resolution failed
+	Remaining  uint64 // path components yet to be resolved beyond Segment (0 == Segment was the last)
 }
 
 // GetObjectReq is the request object for RpcGetObject
@@ -634,6 +1123,13 @@ type GetObjectReq struct {
 	// to convert the values. To obtain a read plan for the entire
 	// object, leave ReadEntsIn empty.
 	ReadEntsIn []fs.ReadRangeIn
+
+	// Swift X-Trans-Id of the request being served (see access_log.go)
+	TransId string
+
+	// If non-nil, the HTTP client on whose behalf the object is read
+	// (subject to the container's ContainerACL)
+	Caller *fs.MiddlewareCallerStruct
 }
 
 // MiddlewarePostReply is the reply object for RpcPost
@@ -651,6 +1147,12 @@ type MiddlewarePostReq struct {
 
 	// Last MetaData known by caller - used to resolve races between clients by doing read/modify/write
 	OldMetaData []byte
+
+	// If non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
+	FreezeID uint64
+
+	// Swift X-Trans-Id of the request being served (see access_log.go)
+	TransId string
 }
 
 type MiddlewareMkdirReply struct {
@@ -666,14 +1168,33 @@ type MiddlewareMkdirReq struct {
 
 	// HTTP metadata to be stored
 	Metadata []byte
+
+	// If non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
+	FreezeID uint64
+
+	// Swift X-Trans-Id of the request being served (see access_log.go)
+	TransId string
 }
 
 // PutCompleteReq is the request object for RpcPutComplete
 type PutCompleteReq struct {
 	VirtPath    string
+	FreezeID    uint64 // if non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
 	PhysPaths   []string
 	PhysLengths []uint64
 	Metadata    []byte
+	TransId     string // Swift X-Trans-Id of the request being served (see access_log.go)
+
+	// Optional preconditions upon what VirtPath currently holds (see fs.PutPreconditions); if unmet,
+	// the request fails with PreconditionFailed and the object is left as it was
+	IfNoneMatch        bool
+	IfMatch            bool
+	IfMatchETag        string
+	IfMatchInodeNumber uint64
+	IfMatchNumWrites   uint64
+
+	// If non-nil, the HTTP client on whose behalf the object is PUT (subject to the container's ContainerACL)
+	Caller *fs.MiddlewareCallerStruct
 }
 
 // PutCompleteReply is the response object for RpcPutComplete
@@ -686,6 +1207,7 @@ type PutCompleteReply struct {
 // PutLocationReq is the request object for RpcPutLocation
 type PutLocationReq struct {
 	VirtPath string
+	TransId  string // Swift X-Trans-Id of the request being served (see access_log.go)
 }
 
 // PutLocationReply is the response object for RpcPutLocation
@@ -717,16 +1239,49 @@ type IsAccountBimodalReply struct {
 // Types for RpcPutContainer
 type PutContainerReq struct {
 	VirtPath    string
+	FreezeID    uint64 // if non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
 	NewMetadata []byte
 	OldMetadata []byte
+	TransId     string // Swift X-Trans-Id of the request being served (see access_log.go)
 }
 
 type PutContainerReply struct {
 }
 
+// Types for RpcGetContainerACL
+type GetContainerACLReq struct {
+	VirtPath string // container path, e.g. /v1/AUTH_acc/a-container
+	TransId  string // Swift X-Trans-Id of the request being served (see access_log.go)
+}
+
+type GetContainerACLReply struct {
+	ACL *fs.ContainerACL // nil if the container has none
+}
+
+// Types for RpcSetContainerACL
+type SetContainerACLReq struct {
+	VirtPath string           // container path, e.g. /v1/AUTH_acc/a-container
+	ACL      *fs.ContainerACL // nil removes the container's ACL
+	FreezeID uint64           // if non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
+	TransId  string           // Swift X-Trans-Id of the request being served (see access_log.go)
+}
+
+type SetContainerACLReply struct {
+}
+
 type CoalesceReq struct {
 	VirtPath                    string
+	FreezeID                    uint64 // if non-zero, the RpcFreezeContainer freeze this request is part of (see freeze.go)
 	ElementAccountRelativePaths []string
+	ElementRanges               []CoalesceElementRange // if non-empty, the range of each element (in order) to include
+	EmitManifest                bool                   // if true, leave a manifest of the elements (see fs/coalesce_manifest.go)
+	TransId                     string                 // Swift X-Trans-Id of the request being served (see access_log.go)
+}
+
+// CoalesceElementRange is the range of a CoalesceReq element's contents to include in the combined file.
+type CoalesceElementRange struct {
+	Offset uint64
+	Length uint64 // 0 == through the end of the element
 }
 
 type CoalesceReply struct {
@@ -735,6 +1290,26 @@ type CoalesceReply struct {
 	NumWrites        uint64
 }
 
+type FreezeContainerReq struct {
+	VirtPath string
+	TTLMsec  uint64 // if zero (or beyond the volume's ContainerFreezeMaxTTL), ContainerFreezeMaxTTL
+	TransId  string // Swift X-Trans-Id of the request being served (see access_log.go)
+}
+
+type FreezeContainerReply struct {
+	FreezeID uint64
+	TTLMsec  uint64 // until the freeze is thawed regardless
+}
+
+type ThawContainerReq struct {
+	VirtPath string
+	FreezeID uint64
+	TransId  string // Swift X-Trans-Id of the request being served (see access_log.go)
+}
+
+type ThawContainerReply struct {
+}
+
 type RenewLeaseReq struct {
 	LeaseId string
 }
@@ -0,0 +1,84 @@
+package jrpcfs
+
+// Relative path resolution (*at() semantics)
+//
+// RpcLookupPathAt, RpcOpenAt, RpcUnlinkAt, and RpcRenameAt resolve paths relative to a directory
+// InodeNumber already held by the client (see fs/at.go) rather than from the root directory.
+
+import (
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+func (s *Server) RpcLookupPathAt(in *LookupPathAtRequest, reply *InodeReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	ino, err := mountHandle.ResolvePathAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.Path)
+	if nil == err {
+		reply.InodeNumber = uint64(ino)
+	}
+	return
+}
+
+func (s *Server) RpcOpenAt(in *OpenAtRequest, reply *OpenReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	fileHandle, err := mountHandle.OpenAt(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Path, fs.OpenFlags(in.OpenFlags), fs.ShareMode(in.ShareMode))
+	reply.FileHandle = uint64(fileHandle)
+	return
+}
+
+func (s *Server) RpcUnlinkAt(in *UnlinkAtRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.UnlinkAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.Path, in.RemoveDir)
+	return
+}
+
+func (s *Server) RpcRenameAt(in *RenameAtRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.RenameAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcPath, inode.InodeNumber(in.DstDirInodeNumber), in.DstPath, fs.RenameFlags(in.Flags))
+	return
+}
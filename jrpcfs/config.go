@@ -33,6 +33,15 @@ type globalsStruct struct {
 
 	// Map used to store volumes already mounted for bimodal support
 	bimodalMountMap map[string]fs.MountHandle
+
+	// Map used to find the mount via which a container was frozen by RpcFreezeContainer (see freeze.go)
+	freezeMountMap map[uint64]*freezeMountStruct
+
+	// Map used to find the queue of events for a watch added via RpcWatchAdd (see notify.go)
+	watchQueueMap map[fs.WatchID]*watchQueueStruct
+
+	// Map used to find the queue of lease breaks for a mount acquiring leases via RpcLeaseAcquire (see lease.go)
+	leaseBreakQueueMap map[uint64]*leaseBreakQueueStruct
 }
 
 var globals globalsStruct
@@ -51,6 +60,12 @@ func Up(confMap conf.ConfMap) (err error) {
 
 	globals.bimodalMountMap = make(map[string]fs.MountHandle)
 
+	globals.freezeMountMap = make(map[uint64]*freezeMountStruct)
+
+	globals.watchQueueMap = make(map[fs.WatchID]*watchQueueStruct)
+
+	globals.leaseBreakQueueMap = make(map[uint64]*leaseBreakQueueStruct)
+
 	// Fetch IPAddr from config file
 	globals.whoAmI, err = confMap.FetchOptionValueString("Cluster", "WhoAmI")
 	if nil != err {
@@ -87,6 +102,12 @@ func Up(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	err = accessLogUp(confMap)
+	if nil != err {
+		logger.ErrorfWithError(err, "failed to set up access log")
+		return
+	}
+
 	// Compute volumeMap
 	volumeList, err = confMap.FetchOptionValueStringSlice("FSGlobals", "VolumeList")
 	if nil != err {
@@ -194,6 +215,8 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 
 	globals.gate.Lock()
 
+	accessLogDown() // reopened (possibly elsewhere) by ExpandAndResume()
+
 	volumeList, err = confMap.FetchOptionValueStringSlice("FSGlobals", "VolumeList")
 	if nil != err {
 		err = fmt.Errorf("confMap.FetchOptionValueStringSlice(\"FSGlobals\", \"VolumeList\") failed: %v", err)
@@ -289,6 +312,11 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 
 	globals.volumeMap = updatedVolumeMap
 
+	err = accessLogUp(confMap)
+	if nil != err {
+		logger.ErrorfWithError(err, "access log disabled")
+	}
+
 	globals.gate.Unlock()
 
 	err = nil
@@ -299,5 +327,6 @@ func Down() (err error) {
 	err = nil
 	jsonRpcServerDown()
 	ioServerDown()
+	accessLogDown()
 	return
 }
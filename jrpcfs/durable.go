@@ -0,0 +1,114 @@
+package jrpcfs
+
+// Durable handles
+//
+// RpcOpenDurable resolves a path once, returning the InodeNumber and Generation the client then presents
+// in the DurableHandle of RpcGetStatByDurableHandle, RpcGetXAttrByDurableHandle, RpcListXAttrByDurableHandle,
+// and RpcOpenByDurableHandle requests rather than resolving the path anew each time (see fs/durable.go).
+
+import (
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+func (durableHandle *DurableHandle) fsDurableHandle() fs.DurableHandleStruct {
+	return fs.DurableHandleStruct{
+		InodeNumber: inode.InodeNumber(durableHandle.InodeNumber),
+		Generation:  durableHandle.Generation,
+	}
+}
+
+func (s *Server) RpcOpenDurable(in *OpenDurableRequest, reply *OpenDurableReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	durableHandle, err := mountHandle.OpenDurable(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, in.Fullpath)
+	if nil == err {
+		reply.InodeNumber = uint64(durableHandle.InodeNumber)
+		reply.Generation = durableHandle.Generation
+	}
+	return
+}
+
+func (s *Server) RpcGetStatByDurableHandle(in *GetStatByDurableHandleRequest, reply *StatStruct) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	stat, err := mountHandle.GetstatByDurableHandle(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, in.fsDurableHandle())
+	if nil == err {
+		reply.fsStatToStatStruct(stat)
+	}
+	return
+}
+
+func (s *Server) RpcGetXAttrByDurableHandle(in *GetXAttrByDurableHandleRequest, reply *GetXAttrReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	reply.AttrValue, err = mountHandle.GetXAttrByDurableHandle(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, in.fsDurableHandle(), in.AttrName)
+	reply.AttrValueSize = uint64(len(reply.AttrValue))
+	return
+}
+
+func (s *Server) RpcListXAttrByDurableHandle(in *ListXAttrByDurableHandleRequest, reply *ListXAttrReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	reply.AttrNames, err = mountHandle.ListXAttrByDurableHandle(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, in.fsDurableHandle())
+	return
+}
+
+func (s *Server) RpcOpenByDurableHandle(in *OpenByDurableHandleRequest, reply *OpenReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	fileHandle, err := mountHandle.OpenByDurableHandle(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, in.fsDurableHandle(), fs.OpenFlags(in.OpenFlags), fs.ShareMode(in.ShareMode))
+	reply.FileHandle = uint64(fileHandle)
+	return
+}
@@ -56,10 +56,32 @@ func jrpcServerLoop() {
 			return
 		}
 
-		go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+		go srv.ServeCodec(&mountAddrCodec{ServerCodec: jsonrpc.NewServerCodec(conn), conn: conn})
 	}
 }
 
+// mountAddrCodec supplies each MountRequest's ClientAddr from the connection it arrived on.
+type mountAddrCodec struct {
+	rpc.ServerCodec
+	conn net.Conn
+}
+
+func (codec *mountAddrCodec) ReadRequestBody(body interface{}) (err error) {
+	err = codec.ServerCodec.ReadRequestBody(body)
+	if nil != err {
+		return
+	}
+	mountRequest, ok := body.(*MountRequest)
+	if ok {
+		mountRequest.ClientAddr, _, err = net.SplitHostPort(codec.conn.RemoteAddr().String())
+		if nil != err {
+			mountRequest.ClientAddr = ""
+			err = nil
+		}
+	}
+	return
+}
+
 func jsonRpcServerDown() {
 	DumpIfNecessary(jserver)
 	stopServerProfiling(jserver)
@@ -811,6 +833,24 @@ func (s *Server) RpcCreatePath(in *CreatePathRequest, reply *InodeReply) (err er
 	return
 }
 
+func (s *Server) RpcCreateUnlinked(in *CreateUnlinkedRequest, reply *InodeReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	fino, err := mountHandle.CreateUnlinked(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), inode.InodeMode(in.FileMode))
+	reply.InodeNumber = uint64(fino)
+	return
+}
+
 func (s *Server) RpcFlock(in *FlockRequest, reply *FlockReply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -831,7 +871,12 @@ func (s *Server) RpcFlock(in *FlockRequest, reply *FlockReply) (err error) {
 	flock.Len = in.FlockLen
 	flock.Pid = in.FlockPid
 
-	lockStruct, err := mountHandle.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.FlockCmd, &flock)
+	var lockStruct *fs.FlockStruct
+	if 0 == in.FileHandle {
+		lockStruct, err = mountHandle.Flock(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.FlockCmd, &flock)
+	} else {
+		lockStruct, err = mountHandle.FlockByHandle(fs.FileHandle(in.FileHandle), in.FlockCmd, &flock)
+	}
 	if lockStruct != nil {
 		reply.FlockType = lockStruct.Type
 		reply.FlockWhence = lockStruct.Whence
@@ -896,6 +941,23 @@ func (s *Server) RpcFlush(in *FlushRequest, reply *Reply) (err error) {
 	return
 }
 
+func (s *Server) RpcFlushDir(in *FlushDirRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.FlushDir(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber))
+	return
+}
+
 func (stat *StatStruct) fsStatToStatStruct(fsStat fs.Stat) {
 	stat.CRTimeNs = fsStat[fs.StatCRTime]
 	stat.CTimeNs = fsStat[fs.StatCTime]
@@ -907,6 +969,9 @@ func (stat *StatStruct) fsStatToStatStruct(fsStat fs.Stat) {
 	stat.FileMode = uint32(fsStat[fs.StatMode])
 	stat.UserID = uint32(fsStat[fs.StatUserID])
 	stat.GroupID = uint32(fsStat[fs.StatGroupID])
+	stat.Blocks = fsStat[fs.StatBlocks]
+	stat.BlkSize = fsStat[fs.StatBlkSize]
+	stat.ChangeCount = fsStat[fs.StatChangeCount]
 }
 
 func (s *Server) RpcGetStat(in *GetStatRequest, reply *StatStruct) (err error) {
@@ -1095,6 +1160,23 @@ func (s *Server) RpcLink(in *LinkRequest, reply *Reply) (err error) {
 	return
 }
 
+func (s *Server) RpcLinkByInode(in *LinkByInodeRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.LinkByInode(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeNumber(in.TargetInodeNumber))
+	return
+}
+
 func (s *Server) RpcLinkPath(in *LinkPathRequest, reply *Reply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1202,6 +1284,39 @@ func (s *Server) RpcLookup(in *LookupRequest, reply *InodeReply) (err error) {
 	return
 }
 
+// RpcLookupMulti resolves each of in.Basenames within in.InodeNumber under a single lock of the directory.
+func (s *Server) RpcLookupMulti(in *LookupMultiRequest, reply *LookupMultiReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	inodeNumbers, errs, err := mountHandle.LookupMulti(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.Basenames)
+	if nil != err {
+		return
+	}
+
+	reply.InodeNumbers = make([]uint64, len(inodeNumbers))
+	reply.Errnos = make([]int, len(errs))
+
+	for i, lookupErr := range errs {
+		if nil == lookupErr {
+			reply.InodeNumbers[i] = uint64(inodeNumbers[i])
+		} else {
+			reply.Errnos[i] = blunder.Errno(lookupErr)
+		}
+	}
+
+	return
+}
+
 func (s *Server) RpcMkdir(in *MkdirRequest, reply *InodeReply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1256,7 +1371,7 @@ func (s *Server) RpcMkdirPath(in *MkdirPathRequest, reply *Reply) (err error) {
 	return
 }
 
-func (s *Server) RpcMount(in *MountRequest, reply *MountReply) (err error) {
+func (s *Server) RpcMknod(in *MknodRequest, reply *InodeReply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
 
@@ -1264,14 +1379,95 @@ func (s *Server) RpcMount(in *MountRequest, reply *MountReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := fs.Mount(in.VolumeName, fs.MountOptions(in.MountOptions))
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	ino, err := mountHandle.Mknod(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeMode(in.FileMode))
+	reply.InodeNumber = uint64(ino)
+	return
+}
+
+// redacted returns a copy of in suitable for logging (i.e. without its credentials).
+func (in *MountRequest) redacted() (loggable *MountRequest) {
+	redactedIn := *in
+	if "" != redactedIn.AuthSecret {
+		redactedIn.AuthSecret = "<redacted>"
+	}
+	if "" != redactedIn.AuthToken {
+		redactedIn.AuthToken = "<redacted>"
+	}
+	loggable = &redactedIn
+	return
+}
+
+func (s *Server) RpcMount(in *MountRequest, reply *MountReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in.redacted())
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	var idMap *fs.IDMapStruct
+	if in.RootSquash || (0 < len(in.UserIDMap)) || (0 < len(in.GroupIDMap)) {
+		idMap = &fs.IDMapStruct{
+			RootSquash:  in.RootSquash,
+			AnonUserID:  inode.InodeUserID(in.AnonUserID),
+			AnonGroupID: inode.InodeGroupID(in.AnonGroupID),
+			UserIDMap:   make(map[inode.InodeUserID]inode.InodeUserID),
+			GroupIDMap:  make(map[inode.InodeGroupID]inode.InodeGroupID),
+		}
+		for clientUserID, userID := range in.UserIDMap {
+			idMap.UserIDMap[inode.InodeUserID(clientUserID)] = inode.InodeUserID(userID)
+		}
+		for clientGroupID, groupID := range in.GroupIDMap {
+			idMap.GroupIDMap[inode.InodeGroupID(clientGroupID)] = inode.InodeGroupID(groupID)
+		}
+	}
+	credentials := &fs.MountCredentialsStruct{
+		Method:      fs.MountAuthMethod(in.AuthMethod),
+		ClientAddr:  in.ClientAddr,
+		Secret:      in.AuthSecret,
+		Token:       in.AuthToken,
+		Certificate: in.AuthCert,
+	}
+	mountHandle, err := fs.MountAuthenticated(in.VolumeName, fs.MountOptions(in.MountOptions), idMap, credentials)
 	if err == nil {
+		mountHandle.SetUmask(inode.InodeMode(in.Umask))
 		reply.MountID = allocateMountID(mountHandle)
 		reply.RootDirInodeNumber = uint64(inode.RootDirInodeNumber)
 	}
 	return
 }
 
+func (s *Server) RpcUnmount(in *UnmountRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = fs.Unmount(mountHandle)
+	if nil != err {
+		return
+	}
+
+	globals.Lock()
+	delete(globals.mountIDMap, in.MountID)
+	delete(globals.leaseBreakQueueMap, in.MountID)
+	globals.Unlock()
+
+	return
+}
+
 func (s *Server) RpcRead(in *ReadRequest, reply *ReadReply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1305,7 +1501,11 @@ func (s *Server) RpcRead(in *ReadRequest, reply *ReadReply) (err error) {
 
 	mountHandle, err := lookupMountHandle(in.MountID)
 	if nil == err {
-		reply.Buf, err = mountHandle.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.Offset, in.Length, nil)
+		if 0 == in.FileHandle {
+			reply.Buf, err = mountHandle.ReadWithFlockPid(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.FlockPid, in.Offset, in.Length, nil)
+		} else {
+			reply.Buf, err = mountHandle.ReadByHandle(fs.FileHandle(in.FileHandle), in.Offset, in.Length, nil)
+		}
 	}
 
 	reply.RequestTimeSec = UnixSec(requestRecTime)
@@ -1339,9 +1539,16 @@ func (s *Server) RpcReaddir(in *ReaddirRequest, reply *ReaddirReply) (err error)
 		return
 	}
 
-	profiler.AddEventNow("before fs.ReaddirOne()")
-	dirEnts, err := mountHandle.ReaddirOne(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), inode.InodeDirLocation(in.PrevDirLocation))
-	profiler.AddEventNow("after fs.ReaddirOne()")
+	var dirEnts []inode.DirEntry
+	if "" == in.Pattern {
+		profiler.AddEventNow("before fs.ReaddirOne()")
+		dirEnts, err = mountHandle.ReaddirOne(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), inode.InodeDirLocation(in.PrevDirLocation))
+		profiler.AddEventNow("after fs.ReaddirOne()")
+	} else {
+		profiler.AddEventNow("before fs.ReaddirMatch()")
+		dirEnts, _, err = mountHandle.ReaddirMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), inode.InodeDirLocation(in.PrevDirLocation), in.Pattern, 1, 0)
+		profiler.AddEventNow("after fs.ReaddirMatch()")
+	}
 	if err == nil {
 		reply.DirEnts = make([]DirEntry, len(dirEnts))
 		for i := range dirEnts {
@@ -1371,9 +1578,19 @@ func (s *Server) RpcReaddirPlus(in *ReaddirPlusRequest, reply *ReaddirPlusReply)
 		return
 	}
 
-	profiler.AddEventNow("before fs.ReaddirOnePlus()")
-	dirEnts, statEnts, err := mountHandle.ReaddirOnePlus(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), inode.InodeDirLocation(in.PrevDirLocation))
-	profiler.AddEventNow("after fs.ReaddirOnePlus()")
+	var (
+		dirEnts  []inode.DirEntry
+		statEnts []fs.Stat
+	)
+	if "" == in.Pattern {
+		profiler.AddEventNow("before fs.ReaddirOnePlus()")
+		dirEnts, statEnts, err = mountHandle.ReaddirOnePlus(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), inode.InodeDirLocation(in.PrevDirLocation))
+		profiler.AddEventNow("after fs.ReaddirOnePlus()")
+	} else {
+		profiler.AddEventNow("before fs.ReaddirPlusMatch()")
+		dirEnts, statEnts, _, err = mountHandle.ReaddirPlusMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), inode.InodeDirLocation(in.PrevDirLocation), in.Pattern, 1, 0)
+		profiler.AddEventNow("after fs.ReaddirPlusMatch()")
+	}
 	if err == nil {
 		reply.DirEnts = make([]DirEntry, len(dirEnts))
 		reply.StatEnts = make([]StatStruct, len(dirEnts))
@@ -1393,6 +1610,31 @@ func (s *Server) RpcReaddirPlus(in *ReaddirPlusRequest, reply *ReaddirPlusReply)
 	return
 }
 
+func (s *Server) RpcReaddirByToken(in *ReaddirByTokenRequest, reply *ReaddirByTokenReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	dirEnts, continuationToken, areMoreEntries, err := mountHandle.ReaddirByToken(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.ContinuationToken, in.MaxEntries, in.MaxBufSize)
+	if nil == err {
+		reply.DirEnts = make([]DirEntry, len(dirEnts))
+		for i := range dirEnts {
+			reply.DirEnts[i].fsDirentToDirEntryStruct(dirEnts[i])
+		}
+		reply.ContinuationToken = continuationToken
+		reply.AreMoreEntries = areMoreEntries
+	}
+	return
+}
+
 func (s *Server) RpcReadSymlink(in *ReadSymlinkRequest, reply *ReadSymlinkReply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1435,6 +1677,23 @@ func (s *Server) RpcReadSymlinkPath(in *ReadSymlinkPathRequest, reply *ReadSymli
 	return
 }
 
+func (s *Server) RpcReleaseUnlinked(in *ReleaseUnlinkedRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.ReleaseUnlinked(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber))
+	return
+}
+
 func (s *Server) RpcRemovetXAttr(in *RemoveXAttrRequest, reply *Reply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1487,7 +1746,7 @@ func (s *Server) RpcRename(in *RenameRequest, reply *Reply) (err error) {
 		return
 	}
 
-	err = mountHandle.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename)
+	err = mountHandle.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename, fs.RenameFlags(in.Flags))
 	return
 }
 
@@ -1523,7 +1782,7 @@ func (s *Server) RpcRenamePath(in *RenamePathRequest, reply *Reply) (err error)
 	}
 
 	// Do the rename
-	err = mountHandle.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, srcIno, srcBasename, dstIno, dstBasename)
+	err = mountHandle.Rename(inode.InodeRootUserID, inode.InodeRootGroupID, nil, srcIno, srcBasename, dstIno, dstBasename, fs.RenameFlags(in.Flags))
 	return
 }
 
@@ -1676,6 +1935,30 @@ func (s *Server) RpcSetXAttr(in *SetXAttrRequest, reply *Reply) (err error) {
 	return
 }
 
+func (s *Server) RpcSetXAttrIfMatch(in *SetXAttrIfMatchRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	oldAttrValue := in.OldAttrValue
+	if in.OldAttrAbsent {
+		oldAttrValue = nil
+	} else if nil == oldAttrValue {
+		oldAttrValue = []byte{}
+	}
+
+	err = mountHandle.SetXAttrIfMatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.AttrName, oldAttrValue, in.AttrValue)
+	return
+}
+
 func (s *Server) RpcSetXAttrPath(in *SetXAttrPathRequest, reply *Reply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1711,7 +1994,12 @@ func (s *Server) RpcStatVFS(in *StatVFSRequest, reply *StatVFS) (err error) {
 		return
 	}
 
-	statvfs, err := mountHandle.StatVfs()
+	var statvfs fs.StatVFS
+	if 0 == in.InodeNumber {
+		statvfs, err = mountHandle.StatVfs()
+	} else {
+		statvfs, err = mountHandle.StatVfsAt(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber))
+	}
 	if err != nil {
 		return
 	}
@@ -1732,6 +2020,33 @@ func (s *Server) RpcStatVFS(in *StatVFSRequest, reply *StatVFS) (err error) {
 	return
 }
 
+func (s *Server) RpcGetLimits(in *GetLimitsRequest, reply *GetLimitsReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	limits := mountHandle.GetLimits()
+
+	reply.FileNameMax = limits.FileNameMax
+	reply.FilePathMax = limits.FilePathMax
+	reply.MaxSymlinks = limits.MaxSymlinks
+	reply.FsBlockSize = limits.FsBlockSize
+	reply.OptimalTransferSize = limits.OptimalTransferSize
+	reply.XAttrNameMax = limits.XAttrNameMax
+	reply.XAttrValueMax = limits.XAttrValueMax
+	reply.MaxEntriesPerOperation = limits.MaxEntriesPerOperation
+	reply.MaxBytesPerOperation = limits.MaxBytesPerOperation
+	return
+}
+
 func (s *Server) RpcSymlink(in *SymlinkRequest, reply *Reply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1875,7 +2190,11 @@ func (s *Server) RpcWrite(in *WriteRequest, reply *WriteReply) (err error) {
 
 	mountHandle, err := lookupMountHandle(in.MountID)
 	if nil == err {
-		size, err = mountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.Offset, in.Buf, nil)
+		if 0 == in.FileHandle {
+			size, err = mountHandle.WriteWithFlockPid(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.FlockPid, in.Offset, in.Buf, nil)
+		} else {
+			size, err = mountHandle.WriteByHandle(fs.FileHandle(in.FileHandle), in.Offset, in.Buf, nil)
+		}
 		reply.Size = uint64(size)
 	}
 
@@ -0,0 +1,132 @@
+package jrpcfs
+
+// Container freezes
+//
+// RpcFreezeContainer freezes a container (see fs/freeze.go) via a mount made just for the freeze. Mutations
+// beneath the container via the mount shared by other middleware requests (see mountIfNotMounted()) then
+// wait, while those of requests carrying the returned FreezeID (i.e. the maintenance for which it was
+// frozen) are served via the freeze's mount and so proceed. The freeze's mount is unmounted (thawing the
+// freeze should it not already have been) by RpcThawContainer or once the freeze expires.
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+type freezeMountStruct struct {
+	mountHandle fs.MountHandle
+	expiryTimer *time.Timer
+}
+
+// freezeMountHandle returns the mount via which the freeze freezeID was made or, if freezeID is zero,
+// mountHandle.
+func freezeMountHandle(freezeID uint64, mountHandle fs.MountHandle) (fs.MountHandle, error) {
+	if 0 == freezeID {
+		return mountHandle, nil
+	}
+
+	globals.Lock()
+	freezeMount, ok := globals.freezeMountMap[freezeID]
+	globals.Unlock()
+
+	if !ok {
+		return nil, blunder.NewError(blunder.NotFoundError, "FreezeID %v is not (or is no longer) frozen", freezeID)
+	}
+
+	return freezeMount.mountHandle, nil
+}
+
+// releaseFreezeMount unmounts the mount via which the freeze freezeID was made.
+func releaseFreezeMount(freezeID uint64) {
+	globals.Lock()
+	freezeMount, ok := globals.freezeMountMap[freezeID]
+	delete(globals.freezeMountMap, freezeID)
+	globals.Unlock()
+
+	if !ok {
+		return
+	}
+
+	freezeMount.expiryTimer.Stop()
+
+	err := fs.Unmount(freezeMount.mountHandle)
+	if nil != err {
+		logger.ErrorfWithError(err, "fs.Unmount() of FreezeID %v's mount failed", freezeID)
+	}
+}
+
+// RpcFreezeContainer holds back mutations beneath the container (other than those of requests carrying the
+// returned FreezeID) until RpcThawContainer is called or the returned TTLMsec elapses.
+func (s *Server) RpcFreezeContainer(in *FreezeContainerReq, reply *FreezeContainerReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("FreezeContainer", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
+
+	_, containerName, _, volumeName, _, err := mountIfNotMounted(in.VirtPath)
+	if err != nil {
+		return err
+	}
+
+	mountHandle, err := fs.Mount(volumeName, fs.MountOptions(0))
+	if err != nil {
+		return err
+	}
+
+	freezeID, expiry, err := mountHandle.MiddlewareFreezeContainer(containerName, time.Duration(in.TTLMsec)*time.Millisecond)
+	if err != nil {
+		_ = fs.Unmount(mountHandle)
+		return err
+	}
+
+	freezeMount := &freezeMountStruct{mountHandle: mountHandle}
+
+	globals.Lock()
+	globals.freezeMountMap[uint64(freezeID)] = freezeMount
+	freezeMount.expiryTimer = time.AfterFunc(time.Until(expiry), func() { releaseFreezeMount(uint64(freezeID)) })
+	globals.Unlock()
+
+	reply.FreezeID = uint64(freezeID)
+	reply.TTLMsec = uint64(time.Until(expiry) / time.Millisecond)
+	return nil
+}
+
+// RpcThawContainer releases the freeze made by RpcFreezeContainer.
+func (s *Server) RpcThawContainer(in *ThawContainerReq, reply *ThawContainerReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("ThawContainer", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
+
+	_, containerName, _, _, _, err := mountIfNotMounted(in.VirtPath)
+	if err != nil {
+		return err
+	}
+
+	mountHandle, err := freezeMountHandle(in.FreezeID, nil)
+	if err != nil {
+		return err
+	}
+	if nil == mountHandle {
+		return blunder.NewError(blunder.InvalidArgError, "FreezeID must be non-zero")
+	}
+
+	err = mountHandle.MiddlewareThawContainer(containerName, fs.FreezeID(in.FreezeID))
+	if err != nil {
+		return err
+	}
+
+	releaseFreezeMount(in.FreezeID)
+	return nil
+}
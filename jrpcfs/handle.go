@@ -0,0 +1,47 @@
+package jrpcfs
+
+// Open file handles (e.g. for SMB share modes and append semantics via Samba)
+//
+// RpcOpen obtains an fs.FileHandle that subsequent RpcRead, RpcWrite, and RpcFlock requests may
+// present in place of an InodeNumber. RpcClose releases it along with any byte-range locks it owns.
+
+import (
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+func (s *Server) RpcOpen(in *OpenRequest, reply *OpenReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	fileHandle, err := mountHandle.Open(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), fs.OpenFlags(in.OpenFlags), fs.ShareMode(in.ShareMode))
+	reply.FileHandle = uint64(fileHandle)
+	return
+}
+
+func (s *Server) RpcClose(in *CloseRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.Close(fs.FileHandle(in.FileHandle))
+	return
+}
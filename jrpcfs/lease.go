@@ -0,0 +1,166 @@
+package jrpcfs
+
+// Leases (e.g. for SMB oplocks via Samba and NFS delegations via a gateway)
+//
+// RpcLeaseAcquire obtains an fs lease whose LeaseBreakHandler appends each lease break to a queue
+// held here for the acquiring mount. As with change notification (see notify.go), clients long-poll
+// RpcLeaseBreakFetch to receive them and respond via RpcLeaseDowngrade or RpcLeaseRelease. Leases
+// restored as a volume is brought back online are adopted via RpcLeaseReclaim.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+type leaseBreakQueueStruct struct {
+	sync.Mutex
+	breaks   []LeaseBreak
+	wakeChan chan struct{} // buffered (1); signaled whenever breaks transitions from empty
+}
+
+func (leaseBreakQueue *leaseBreakQueueStruct) handler(leaseID fs.LeaseID, inodeNumber inode.InodeNumber, breakTo fs.LeaseType) {
+	leaseBreakQueue.Lock()
+	leaseBreakQueue.breaks = append(leaseBreakQueue.breaks, LeaseBreak{
+		LeaseID:     uint64(leaseID),
+		InodeNumber: uint64(inodeNumber),
+		BreakTo:     uint32(breakTo),
+	})
+	leaseBreakQueue.Unlock()
+
+	select {
+	case leaseBreakQueue.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// fetchLeaseBreakQueue returns (creating if necessary) the lease break queue for mountID.
+func fetchLeaseBreakQueue(mountID uint64) (leaseBreakQueue *leaseBreakQueueStruct) {
+	globals.Lock()
+	leaseBreakQueue, ok := globals.leaseBreakQueueMap[mountID]
+	if !ok {
+		leaseBreakQueue = &leaseBreakQueueStruct{
+			breaks:   make([]LeaseBreak, 0),
+			wakeChan: make(chan struct{}, 1),
+		}
+		globals.leaseBreakQueueMap[mountID] = leaseBreakQueue
+	}
+	globals.Unlock()
+	return
+}
+
+func (s *Server) RpcLeaseAcquire(in *LeaseAcquireRequest, reply *LeaseAcquireReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	leaseBreakQueue := fetchLeaseBreakQueue(in.MountID)
+
+	leaseID, err := mountHandle.AcquireLease(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), fs.LeaseType(in.LeaseType), leaseBreakQueue.handler)
+	reply.LeaseID = uint64(leaseID)
+	return
+}
+
+func (s *Server) RpcLeaseReclaim(in *LeaseReclaimRequest, reply *LeaseReclaimReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	leaseBreakQueue := fetchLeaseBreakQueue(in.MountID)
+
+	inodeNumber, leaseType, err := mountHandle.ReclaimLease(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, fs.LeaseID(in.LeaseID), leaseBreakQueue.handler)
+	reply.InodeNumber = uint64(inodeNumber)
+	reply.LeaseType = uint32(leaseType)
+	return
+}
+
+func (s *Server) RpcLeaseBreakFetch(in *LeaseBreakFetchRequest, reply *LeaseBreakFetchReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	_, err = lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	leaseBreakQueue := fetchLeaseBreakQueue(in.MountID)
+
+	leaseBreakQueue.Lock()
+	if (0 == len(leaseBreakQueue.breaks)) && (0 < in.TimeoutMsec) {
+		leaseBreakQueue.Unlock()
+		select {
+		case <-leaseBreakQueue.wakeChan:
+		case <-time.After(time.Duration(in.TimeoutMsec) * time.Millisecond):
+		}
+		leaseBreakQueue.Lock()
+	}
+
+	breakCount := uint64(len(leaseBreakQueue.breaks))
+	if (0 < in.MaxBreaks) && (breakCount > in.MaxBreaks) {
+		breakCount = in.MaxBreaks
+	}
+
+	reply.Breaks = make([]LeaseBreak, breakCount)
+	copy(reply.Breaks, leaseBreakQueue.breaks[:breakCount])
+	leaseBreakQueue.breaks = leaseBreakQueue.breaks[breakCount:]
+	leaseBreakQueue.Unlock()
+
+	return
+}
+
+func (s *Server) RpcLeaseDowngrade(in *LeaseDowngradeRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.DowngradeLease(fs.LeaseID(in.LeaseID), fs.LeaseType(in.LeaseType))
+	return
+}
+
+func (s *Server) RpcLeaseRelease(in *LeaseReleaseRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.ReleaseLease(fs.LeaseID(in.LeaseID))
+	return
+}
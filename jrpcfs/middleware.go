@@ -1,7 +1,9 @@
 package jrpcfs
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/swiftstack/ProxyFS/blunder"
 	"github.com/swiftstack/ProxyFS/fs"
@@ -67,6 +69,8 @@ func mountIfNotMounted(virtPath string) (accountName string, containerName strin
 func (s *Server) RpcCreateContainer(in *CreateContainerRequest, reply *CreateContainerReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	mOp := beginMiddlewareOp("CreateContainer", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 	// XXX TODO: Need to determine how we want to pass errors back for RPCs used by middleware.
 	// By default, jrpcfs code (and rpcEncodeError) use errno-type errors.
 	// However for RPCs used by middleware, perhaps we want to return HTTP status codes?
@@ -103,9 +107,16 @@ func (s *Server) RpcDelete(in *DeleteReq, reply *DeleteReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("Delete", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, containerName, objectName, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return err
+	}
+
 	parentDir, baseName := splitPath(containerName + "/" + objectName)
 
 	// objectName empty means we are deleting a container
@@ -115,16 +126,58 @@ func (s *Server) RpcDelete(in *DeleteReq, reply *DeleteReply) (err error) {
 	}
 
 	// Call fs to delete the baseName if it is a file or an empty directory.
-	err = mountHandle.MiddlewareDelete(parentDir, baseName)
+	err = mountHandle.MiddlewareDeleteAsCaller(in.Caller, parentDir, baseName)
 
 	return err
 }
 
+// RpcDeleteMulti is used by Middleware to service a bulk delete of many containers and/or objects in
+// one request.
+func (s *Server) RpcDeleteMulti(in *DeleteMultiReq, reply *DeleteMultiReply) (err error) {
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("DeleteMulti", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
+
+	_, _, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
+	if err != nil {
+		logger.ErrorfWithError(err, "RpcDeleteMulti: error mounting share for %s", in.VirtPath)
+		return err
+	}
+
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return err
+	}
+
+	errs := mountHandle.MiddlewareDeleteMultiAsCaller(in.Caller, in.Entries)
+
+	reply.Errnos = make([]int, len(errs))
+
+	for i, deleteErr := range errs {
+		if nil == deleteErr {
+			reply.NumDeleted++
+			continue
+		}
+		reply.Errnos[i] = blunder.Errno(deleteErr)
+		if blunder.Is(deleteErr, blunder.NotFoundError) {
+			reply.NumNotFound++
+		} else {
+			reply.NumFailed++
+		}
+	}
+
+	return nil
+}
+
 // RpcGetAccount is used by Middleware to issue a GET on an account and return the results.
 func (s *Server) RpcGetAccount(in *GetAccountReq, reply *GetAccountReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("GetAccount", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, _, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 	if err != nil {
@@ -132,7 +185,7 @@ func (s *Server) RpcGetAccount(in *GetAccountReq, reply *GetAccountReply) (err e
 		return err
 	}
 
-	entries, err := mountHandle.MiddlewareGetAccount(in.MaxEntries, in.Marker)
+	entries, err := mountHandle.MiddlewareGetAccountListing(in.MaxEntries, in.Marker, in.EndMarker, in.Reverse)
 	if err != nil {
 		return err
 	}
@@ -145,6 +198,8 @@ func (s *Server) RpcHead(in *HeadReq, reply *HeadReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("Head", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, vContainerName, vObjectName, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 	if err != nil {
@@ -152,12 +207,18 @@ func (s *Server) RpcHead(in *HeadReq, reply *HeadReply) (err error) {
 		return err
 	}
 
-	entityPath := vContainerName
-	if vObjectName != "" {
-		entityPath = entityPath + "/" + vObjectName
-	}
+	var resp fs.HeadResponse
+	if "" == vContainerName {
+		// HEAD of the account itself
+		resp, err = mountHandle.MiddlewareHeadAccount()
+	} else {
+		entityPath := vContainerName
+		if vObjectName != "" {
+			entityPath = entityPath + "/" + vObjectName
+		}
 
-	resp, err := mountHandle.MiddlewareHeadResponse(entityPath)
+		resp, err = mountHandle.MiddlewareHeadResponse(entityPath)
+	}
 	if err != nil {
 		if !blunder.Is(err, blunder.NotFoundError) {
 			logger.ErrorfWithError(err, "RpcHead: error retrieving metadata for %s", in.VirtPath)
@@ -170,17 +231,62 @@ func (s *Server) RpcHead(in *HeadReq, reply *HeadReply) (err error) {
 	reply.ModificationTime = resp.ModificationTime
 	reply.InodeNumber = uint64(resp.InodeNumber)
 	reply.NumWrites = resp.NumWrites
+	reply.ETag = resp.ETag
+	reply.ContentType = resp.ContentType
 
 	reply.IsDir = resp.IsDir
 
 	return nil
 }
 
+// RpcHeadMultiple is used by Middleware to HEAD many containers and/or objects (e.g. the segments of
+// an SLO manifest being validated) in one request.
+func (s *Server) RpcHeadMultiple(in *HeadMultipleReq, reply *HeadMultipleReply) (err error) {
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("HeadMultiple", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
+
+	_, _, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
+	if err != nil {
+		logger.ErrorfWithError(err, "RpcHeadMultiple: error mounting share for %s", in.VirtPath)
+		return err
+	}
+
+	responses, errs := mountHandle.MiddlewareHeadMultiple(in.EntityPaths)
+
+	reply.Entities = make([]HeadMultipleEntity, len(in.EntityPaths))
+
+	for i, resp := range responses {
+		if errs[i] != nil {
+			if !blunder.Is(errs[i], blunder.NotFoundError) {
+				logger.ErrorfWithError(errs[i], "RpcHeadMultiple: error retrieving metadata for %s/%s", in.VirtPath, in.EntityPaths[i])
+			}
+			reply.Entities[i].Errno = blunder.Errno(errs[i])
+			continue
+		}
+
+		reply.Entities[i].Metadata = resp.Metadata
+		reply.Entities[i].FileSize = resp.FileSize
+		reply.Entities[i].ModificationTime = resp.ModificationTime
+		reply.Entities[i].InodeNumber = uint64(resp.InodeNumber)
+		reply.Entities[i].NumWrites = resp.NumWrites
+		reply.Entities[i].ETag = resp.ETag
+		reply.Entities[i].ContentType = resp.ContentType
+		reply.Entities[i].IsDir = resp.IsDir
+	}
+
+	return nil
+}
+
 // RpcGetContainer is used by Middleware to issue a GET on a container and return the results.
 func (s *Server) RpcGetContainer(in *GetContainerReq, reply *GetContainerReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("GetContainer", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, vContainerName, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 	if err != nil {
@@ -188,7 +294,20 @@ func (s *Server) RpcGetContainer(in *GetContainerReq, reply *GetContainerReply)
 		return err
 	}
 
-	entries, err := mountHandle.MiddlewareGetContainer(vContainerName, in.MaxEntries, in.Marker, in.Prefix)
+	var entries []fs.ContainerEntry
+	if (0 != in.MaxStalenessMsec) && (("" == in.EndMarker) || in.EndMarkerExclusive || in.Reverse) {
+		maxStaleness := time.Duration(in.MaxStalenessMsec) * time.Millisecond
+		entries, err = mountHandle.MiddlewareGetContainerCached(vContainerName, in.MaxEntries, in.Marker, in.EndMarker, in.Prefix, in.Delimiter, in.Reverse, maxStaleness)
+	} else if in.EndMarkerExclusive || in.Reverse {
+		entries, err = mountHandle.MiddlewareGetContainerListing(vContainerName, in.MaxEntries, in.Marker, in.EndMarker, in.Prefix, in.Delimiter, in.Reverse)
+	} else if ("" != in.Delimiter) || ("" != in.EndMarker) {
+		entries, err = mountHandle.MiddlewareGetContainerRange(vContainerName, in.MaxEntries, in.Marker, in.EndMarker, in.Prefix, in.Delimiter)
+	} else if 0 != in.Capabilities&GetContainerCapabilityContinuationToken {
+		entries, reply.ContinuationToken, err = mountHandle.MiddlewareGetContainerByToken(vContainerName, in.MaxEntries, in.Marker, in.ContinuationToken, in.Prefix)
+		reply.Capabilities |= GetContainerCapabilityContinuationToken
+	} else {
+		entries, err = mountHandle.MiddlewareGetContainer(vContainerName, in.MaxEntries, in.Marker, in.Prefix)
+	}
 	if err != nil {
 		return err
 	}
@@ -202,20 +321,69 @@ func (s *Server) RpcGetContainer(in *GetContainerReq, reply *GetContainerReply)
 	return nil
 }
 
+// RpcGetContainerShards is used by Middleware to partition the listing of a container into shards that may
+// be listed concurrently (see fs.MiddlewareGetContainerShards()).
+func (s *Server) RpcGetContainerShards(in *GetContainerShardsReq, reply *GetContainerShardsReply) (err error) {
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("GetContainerShards", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
+
+	_, vContainerName, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
+	if err != nil {
+		logger.ErrorfWithError(err, "RpcGetContainerShards: error mounting share for %s", in.VirtPath)
+		return err
+	}
+
+	reply.Shards, err = mountHandle.MiddlewareGetContainerShards(vContainerName, in.MaxShards)
+	return
+}
+
+// rpcEncodeErrorWithPathFailure is rpcEncodeError() also conveying any PathFailure err carries.
+func rpcEncodeErrorWithPathFailure(e *error) {
+	if nil == *e {
+		return
+	}
+
+	pathFailure := fs.PathFailure(*e)
+	if nil == pathFailure {
+		rpcEncodeError(e)
+		return
+	}
+
+	buf, err := json.Marshal(PathFailure{
+		Reason:     pathFailure.Reason.String(),
+		HTTPStatus: blunder.HTTPCode(*e),
+		ParentPath: pathFailure.ParentPath,
+		Segment:    pathFailure.Segment,
+		Remaining:  pathFailure.Remaining,
+	})
+	if nil != err {
+		rpcEncodeError(e)
+		return
+	}
+
+	*e = fmt.Errorf("errno: %d pathFailure: %s", blunder.Errno(*e), buf)
+}
+
 // RpcGetObject is used by GET HTTP request to retrieve the read plan for an object.
 func (s *Server) RpcGetObject(in *GetObjectReq, reply *GetObjectReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
-	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	defer func() { rpcEncodeErrorWithPathFailure(&err) }() // Encode error (with any PathFailure) for return by RPC
+	mOp := beginMiddlewareOp("GetObject", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, vContainerName, objectName, volumeName, mountHandle, err := mountIfNotMounted(in.VirtPath)
 
 	mountRelativePath := vContainerName + "/" + objectName
 
-	reply.FileSize, reply.ModificationTime, reply.InodeNumber, reply.NumWrites, reply.Metadata, err = mountHandle.MiddlewareGetObject(volumeName, mountRelativePath, in.ReadEntsIn, &reply.ReadEntsOut)
+	reply.FileSize, reply.ModificationTime, reply.InodeNumber, reply.NumWrites, reply.Metadata, reply.ETag, reply.ContentType, err = mountHandle.MiddlewareGetObjectAsCaller(in.Caller, volumeName, mountRelativePath, in.ReadEntsIn, &reply.ReadEntsOut)
 	if err != nil {
 		return err
 	}
+	mOp.bytes = reply.FileSize
 
 	return err
 }
@@ -224,17 +392,30 @@ func (s *Server) RpcGetObject(in *GetObjectReq, reply *GetObjectReply) (err erro
 func (s *Server) RpcPost(in *MiddlewarePostReq, reply *MiddlewarePostReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	mOp := beginMiddlewareOp("Post", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	accountName, containerName, objectName, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 
-	// Don't allow a POST on an invalid account or on just an account
-	if accountName == "" || containerName == "" {
-		err = fmt.Errorf("%s: Can't modify an account, AccountName: %v is invalid or ContainerName: %v is invalid.", utils.GetFnName(), accountName, containerName)
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return err
+	}
+
+	// Don't allow a POST on an invalid account
+	if accountName == "" {
+		err = fmt.Errorf("%s: Can't modify an account, AccountName: %v is invalid.", utils.GetFnName(), accountName)
 		logger.ErrorWithError(err)
 		err = blunder.AddError(err, blunder.AccountNotModifiable)
 		return err
 	}
 
+	// A POST on just an account sets the account's metadata
+	if containerName == "" {
+		err = mountHandle.MiddlewarePostAccount(in.NewMetaData, in.OldMetaData)
+		return err
+	}
+
 	var parentDir, baseName string
 	if objectName != "" {
 		parentDir, baseName = splitPath(containerName + "/" + objectName)
@@ -252,9 +433,16 @@ func (s *Server) RpcPost(in *MiddlewarePostReq, reply *MiddlewarePostReply) (err
 func (s *Server) RpcMiddlewareMkdir(in *MiddlewareMkdirReq, reply *MiddlewareMkdirReply) (err error) {
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	mOp := beginMiddlewareOp("MiddlewareMkdir", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, containerName, objectName, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return err
+	}
+
 	// Require a reference to an object; you can't create a container with this method.
 	if objectName == "" {
 		err = blunder.NewError(blunder.NotAnObjectError, "%s: VirtPath must reference an object, not container or account (%s)", utils.GetFnName(), in.VirtPath)
@@ -277,12 +465,31 @@ func (s *Server) RpcPutComplete(in *PutCompleteReq, reply *PutCompleteReply) (er
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("PutComplete", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, containerName, objectName, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return err
+	}
+
 	// Call fs to complete the creation of the inode for the file and
 	// the directories.
-	mtime, ino, numWrites, err := mountHandle.MiddlewarePutComplete(containerName, objectName, in.PhysPaths, in.PhysLengths, in.Metadata)
+	for _, physLength := range in.PhysLengths {
+		mOp.bytes += physLength
+	}
+
+	preconditions := fs.PutPreconditions{
+		IfNoneMatch:        in.IfNoneMatch,
+		IfMatch:            in.IfMatch,
+		IfMatchETag:        in.IfMatchETag,
+		IfMatchInodeNumber: inode.InodeNumber(in.IfMatchInodeNumber),
+		IfMatchNumWrites:   in.IfMatchNumWrites,
+	}
+
+	mtime, ino, numWrites, err := mountHandle.MiddlewarePutCompleteAsCaller(in.Caller, containerName, objectName, in.PhysPaths, in.PhysLengths, in.Metadata, preconditions)
 	reply.ModificationTime = mtime
 	reply.InodeNumber = uint64(ino)
 	reply.NumWrites = numWrites
@@ -300,6 +507,8 @@ func (s *Server) RpcPutLocation(in *PutLocationReq, reply *PutLocationReply) (er
 
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	mOp := beginMiddlewareOp("PutLocation", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	accountName, containerName, objectName, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 
@@ -332,16 +541,66 @@ func (s *Server) RpcPutContainer(in *PutContainerReq, reply *PutContainerReply)
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("PutContainer", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, containerName, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 	if err != nil {
 		return err
 	}
 
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return err
+	}
+
 	err = mountHandle.MiddlewarePutContainer(containerName, in.OldMetadata, in.NewMetadata)
 	return err
 }
 
+// RpcGetContainerACL returns the ContainerACL (if any) enforced upon callers of the container's objects.
+func (s *Server) RpcGetContainerACL(in *GetContainerACLReq, reply *GetContainerACLReply) (err error) {
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("GetContainerACL", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
+
+	_, containerName, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
+	if err != nil {
+		return err
+	}
+
+	reply.ACL, err = mountHandle.MiddlewareGetContainerACL(containerName)
+	return err
+}
+
+// RpcSetContainerACL replaces (or, if in.ACL is nil, removes) the ContainerACL enforced upon callers of
+// the container's objects.
+func (s *Server) RpcSetContainerACL(in *SetContainerACLReq, reply *SetContainerACLReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("SetContainerACL", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
+
+	_, containerName, _, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
+	if err != nil {
+		return err
+	}
+
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return err
+	}
+
+	err = mountHandle.MiddlewareSetContainerACL(containerName, in.ACL)
+	return err
+}
+
 // Combine a bunch of files together into a big one. It's like "cat old1 old2 ... > new", but without the cat. Also
 // removes the files old1 old2 ...
 func (s *Server) RpcCoalesce(in *CoalesceReq, reply *CoalesceReply) (err error) {
@@ -351,10 +610,35 @@ func (s *Server) RpcCoalesce(in *CoalesceReq, reply *CoalesceReply) (err error)
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+	mOp := beginMiddlewareOp("Coalesce", in.TransId, in.VirtPath)
+	defer func() { mOp.end(err) }()
 
 	_, destContainer, destObject, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
 
-	reply.InodeNumber, reply.NumWrites, reply.ModificationTime, err = mountHandle.MiddlewareCoalesce(destContainer+"/"+destObject, in.ElementAccountRelativePaths)
+	if err != nil {
+		return
+	}
+
+	mountHandle, err = freezeMountHandle(in.FreezeID, mountHandle)
+	if err != nil {
+		return
+	}
+
+	if (0 != len(in.ElementRanges)) && (len(in.ElementRanges) != len(in.ElementAccountRelativePaths)) {
+		err = blunder.NewError(blunder.InvalidArgError, "%v ElementRanges given for %v elements", len(in.ElementRanges), len(in.ElementAccountRelativePaths))
+		return
+	}
+
+	elements := make([]fs.CoalesceElementSpec, len(in.ElementAccountRelativePaths))
+	for i, elementPath := range in.ElementAccountRelativePaths {
+		elements[i].Path = elementPath
+		if 0 != len(in.ElementRanges) {
+			elements[i].Offset = in.ElementRanges[i].Offset
+			elements[i].Length = in.ElementRanges[i].Length
+		}
+	}
+
+	reply.InodeNumber, reply.NumWrites, reply.ModificationTime, err = mountHandle.MiddlewareCoalesceElements(destContainer+"/"+destObject, elements, in.EmitManifest)
 	return
 }
 
@@ -1,10 +1,12 @@
 package jrpcfs
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -512,6 +514,125 @@ func testRpcHeadObjectFile(t *testing.T, server *Server) {
 	assert.Equal(statResult[fs.StatNumWrites], response.NumWrites)
 }
 
+func TestRpcHeadMultiple(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+
+	request := HeadMultipleReq{
+		VirtPath: testVerAccountName,
+		EntityPaths: []string{
+			"c",
+			"c/plants/eggplant.txt",
+			"sir-not-appearing-in-this-test",
+			"c/plants-symlink/eggplant.txt-symlink",
+			"c-no-metadata",
+			"c/plants/sir-not-appearing-in-this-test",
+		},
+	}
+	response := HeadMultipleReply{}
+	err := server.RpcHeadMultiple(&request, &response)
+
+	assert.Nil(err)
+	assert.Equal(len(request.EntityPaths), len(response.Entities))
+
+	assert.Equal(0, response.Entities[0].Errno)
+	assert.Equal([]byte("metadata for c"), response.Entities[0].Metadata)
+	assert.Equal(true, response.Entities[0].IsDir)
+
+	statResult := fsStatPath(testVerAccountName, "/c/plants/eggplant.txt")
+
+	assert.Equal(0, response.Entities[1].Errno)
+	assert.Equal(uint64(12), response.Entities[1].FileSize)
+	assert.Equal(false, response.Entities[1].IsDir)
+	assert.Equal(statResult[fs.StatINum], response.Entities[1].InodeNumber)
+
+	assert.NotEqual(0, response.Entities[2].Errno)
+
+	assert.Equal(0, response.Entities[3].Errno)
+	assert.Equal(statResult[fs.StatINum], response.Entities[3].InodeNumber)
+
+	assert.Equal(0, response.Entities[4].Errno)
+	assert.Equal([]byte(""), response.Entities[4].Metadata)
+
+	assert.NotEqual(0, response.Entities[5].Errno)
+}
+
+func TestAccessLog(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+
+	accessLogFile, err := ioutil.TempFile("", "jrpcfs_access_log")
+	assert.Nil(err)
+	accessLogFilePath := accessLogFile.Name()
+	accessLogFile.Close()
+	defer os.Remove(accessLogFilePath)
+
+	readAccessLog := func() (records []accessLogRecordStruct) {
+		buf, readErr := ioutil.ReadFile(accessLogFilePath)
+		assert.Nil(readErr)
+		for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+			if "" == line {
+				continue
+			}
+			record := accessLogRecordStruct{}
+			assert.Nil(json.Unmarshal([]byte(line), &record))
+			records = append(records, record)
+		}
+		return
+	}
+
+	// All RPCs logged
+
+	confMap, err := conf.MakeConfMapFromStrings([]string{"JSONRPCServer.AccessLogFilePath=" + accessLogFilePath})
+	assert.Nil(err)
+	assert.Nil(accessLogUp(confMap))
+
+	err = server.RpcHead(&HeadReq{VirtPath: testVerAccountName + "/c", TransId: "tx-found"}, &HeadReply{})
+	assert.Nil(err)
+	err = server.RpcHead(&HeadReq{VirtPath: testVerAccountName + "/sir-not-appearing-in-this-test", TransId: "tx-missing"}, &HeadReply{})
+	assert.NotNil(err)
+
+	accessLogDown()
+
+	records := readAccessLog()
+	assert.Equal(2, len(records))
+	assert.Equal("tx-found", records[0].TransID)
+	assert.Equal("Head", records[0].Op)
+	assert.Equal(testVerAccountName+"/c", records[0].Path)
+	assert.Equal(0, records[0].Result)
+	assert.Equal("tx-missing", records[1].TransID)
+	assert.Equal(blunder.Errno(blunder.NewError(blunder.NotFoundError, "")), records[1].Result)
+
+	// Only failed RPCs logged
+
+	confMap, err = conf.MakeConfMapFromStrings([]string{
+		"JSONRPCServer.AccessLogFilePath=" + accessLogFilePath,
+		"JSONRPCServer.AccessLogSampleRate=0.0",
+	})
+	assert.Nil(err)
+	assert.Nil(accessLogUp(confMap))
+
+	err = server.RpcHead(&HeadReq{VirtPath: testVerAccountName + "/c", TransId: "tx-sampled-out"}, &HeadReply{})
+	assert.Nil(err)
+	err = server.RpcHead(&HeadReq{VirtPath: testVerAccountName + "/sir-not-appearing-in-this-test", TransId: "tx-missing-again"}, &HeadReply{})
+	assert.NotNil(err)
+
+	accessLogDown()
+
+	records = readAccessLog()
+	assert.Equal(3, len(records))
+	assert.Equal("tx-missing-again", records[2].TransID)
+
+	// Out of range sample rate rejected
+
+	confMap, err = conf.MakeConfMapFromStrings([]string{
+		"JSONRPCServer.AccessLogFilePath=" + accessLogFilePath,
+		"JSONRPCServer.AccessLogSampleRate=1.5",
+	})
+	assert.Nil(err)
+	assert.NotNil(accessLogUp(confMap))
+}
+
 func TestRpcGetContainerMetadata(t *testing.T) {
 	server := &Server{}
 	assert := assert.New(t)
@@ -581,6 +702,60 @@ func TestRpcGetContainerNested(t *testing.T) {
 	assert.Equal("a/b/c/d-2", ents[30].Basename)
 }
 
+func TestRpcGetContainerContinuationToken(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+
+	request := GetContainerReq{
+		VirtPath:   testVerAccountName + "/" + "c-nested",
+		MaxEntries: 10000,
+	}
+	response := GetContainerReply{}
+	err := server.RpcGetContainer(&request, &response)
+	assert.Nil(err)
+	assert.Equal(uint64(0), response.Capabilities)
+	assert.Equal("", response.ContinuationToken)
+	expectedEnts := response.ContainerEntries
+
+	// Page through the same listing a few entries at a time
+
+	var ents []fs.ContainerEntry
+	continuationToken := ""
+	for {
+		request = GetContainerReq{
+			VirtPath:          testVerAccountName + "/" + "c-nested",
+			MaxEntries:        4,
+			Capabilities:      GetContainerCapabilityContinuationToken,
+			ContinuationToken: continuationToken,
+		}
+		response = GetContainerReply{}
+		err = server.RpcGetContainer(&request, &response)
+		assert.Nil(err)
+		assert.Equal(GetContainerCapabilityContinuationToken, response.Capabilities)
+		if 0 == len(response.ContainerEntries) {
+			assert.Equal("", response.ContinuationToken)
+			break
+		}
+		ents = append(ents, response.ContainerEntries...)
+		continuationToken = response.ContinuationToken
+	}
+
+	assert.Equal(len(expectedEnts), len(ents))
+	for i := range expectedEnts {
+		assert.Equal(expectedEnts[i].Basename, ents[i].Basename)
+	}
+
+	request = GetContainerReq{
+		VirtPath:          testVerAccountName + "/" + "c-nested",
+		MaxEntries:        4,
+		Capabilities:      GetContainerCapabilityContinuationToken,
+		ContinuationToken: "not-a-token",
+	}
+	response = GetContainerReply{}
+	err = server.RpcGetContainer(&request, &response)
+	assert.NotNil(err)
+}
+
 func TestRpcGetContainerPrefix(t *testing.T) {
 	server := &Server{}
 	assert := assert.New(t)
@@ -666,6 +841,248 @@ func TestRpcGetContainerPrefixAndMarker(t *testing.T) {
 	assert.Equal(".git/logs/refs/stash", ents[3].Basename)
 }
 
+func TestRpcGetContainerDelimiter(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+
+	getContainerDelimited := func(prefix string, marker string, maxEntries uint64) (basenames []string, subdirs []bool) {
+		request := GetContainerReq{
+			VirtPath:   testVerAccountName + "/" + "c-nested",
+			Marker:     marker,
+			MaxEntries: maxEntries,
+			Prefix:     prefix,
+			Delimiter:  "/",
+		}
+		response := GetContainerReply{}
+		err := server.RpcGetContainer(&request, &response)
+		assert.Nil(err)
+		for _, ent := range response.ContainerEntries {
+			basenames = append(basenames, ent.Basename)
+			subdirs = append(subdirs, ent.IsSubdir)
+		}
+		return
+	}
+
+	basenames, subdirs := getContainerDelimited("", "", 10000)
+	assert.Equal([]string{".DS_Store", ".git", ".git/", "a", "a/"}, basenames)
+	assert.Equal([]bool{false, false, true, false, true}, subdirs)
+
+	basenames, subdirs = getContainerDelimited(".git/logs/", "", 10000)
+	assert.Equal([]string{".git/logs/.DS_Store", ".git/logs/HEAD", ".git/logs/refs", ".git/logs/refs/"}, basenames)
+	assert.Equal([]bool{false, false, false, true}, subdirs)
+
+	// A prefix ending mid-basename lists the matching directory and its subdir
+	basenames, _ = getContainerDelimited(".git/logs/re", "", 10000)
+	assert.Equal([]string{".git/logs/refs", ".git/logs/refs/"}, basenames)
+
+	basenames, _ = getContainerDelimited("a/b/", "", 10000)
+	assert.Equal([]string{"a/b/c", "a/b/c-1", "a/b/c-2", "a/b/c/"}, basenames)
+
+	// Paginating by the last entry (including a subdir) returned
+	basenames, _ = getContainerDelimited("", "", 3)
+	assert.Equal([]string{".DS_Store", ".git", ".git/"}, basenames)
+	basenames, _ = getContainerDelimited("", ".git/", 3)
+	assert.Equal([]string{"a", "a/"}, basenames)
+
+	request := GetContainerReq{
+		VirtPath:   testVerAccountName + "/" + "c-nested",
+		MaxEntries: 10000,
+		Delimiter:  "-",
+	}
+	response := GetContainerReply{}
+	err := server.RpcGetContainer(&request, &response)
+	assert.NotNil(err)
+}
+
+func TestRpcGetContainerShards(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+
+	request := GetContainerReq{
+		VirtPath:   testVerAccountName + "/" + "c-nested",
+		MaxEntries: 10000,
+	}
+	response := GetContainerReply{}
+	err := server.RpcGetContainer(&request, &response)
+	assert.Nil(err)
+	var expectedBasenames []string
+	for _, ent := range response.ContainerEntries {
+		expectedBasenames = append(expectedBasenames, ent.Basename)
+	}
+
+	// listShard pages through shard (maxEntries at a time) just as a middleware worker would
+	listShard := func(shard fs.ContainerShard, maxEntries uint64) (basenames []string) {
+		marker := shard.Marker
+		for {
+			request := GetContainerReq{
+				VirtPath:   testVerAccountName + "/" + "c-nested",
+				Marker:     marker,
+				EndMarker:  shard.EndMarker,
+				MaxEntries: maxEntries,
+			}
+			response := GetContainerReply{}
+			err := server.RpcGetContainer(&request, &response)
+			assert.Nil(err)
+			if 0 == len(response.ContainerEntries) {
+				return
+			}
+			for _, ent := range response.ContainerEntries {
+				basenames = append(basenames, ent.Basename)
+			}
+			marker = basenames[len(basenames)-1]
+		}
+	}
+
+	for _, maxShards := range []uint64{1, 2, 3, 8} {
+		shardsRequest := GetContainerShardsReq{
+			VirtPath:  testVerAccountName + "/" + "c-nested",
+			MaxShards: maxShards,
+		}
+		shardsResponse := GetContainerShardsReply{}
+		err = server.RpcGetContainerShards(&shardsRequest, &shardsResponse)
+		assert.Nil(err)
+		shards := shardsResponse.Shards
+		assert.True((0 < len(shards)) && (uint64(len(shards)) <= maxShards))
+		assert.Equal("", shards[0].Marker)
+		assert.Equal("", shards[len(shards)-1].EndMarker)
+		if 1 < maxShards {
+			assert.True(1 < len(shards))
+		}
+
+		// List the shards concurrently, aggregating the listings in shard order
+
+		shardBasenames := make([][]string, len(shards))
+		wg := sync.WaitGroup{}
+		for i, shard := range shards {
+			wg.Add(1)
+			go func(i int, shard fs.ContainerShard) {
+				shardBasenames[i] = listShard(shard, 2)
+				wg.Done()
+			}(i, shard)
+		}
+		wg.Wait()
+
+		var basenames []string
+		for _, shardBasename := range shardBasenames {
+			basenames = append(basenames, shardBasename...)
+		}
+		assert.Equal(expectedBasenames, basenames, "maxShards == %v (shards %+v)", maxShards, shards)
+	}
+
+	shardsRequest := GetContainerShardsReq{
+		VirtPath:  testVerAccountName + "/" + "c-nested",
+		MaxShards: 0,
+	}
+	shardsResponse := GetContainerShardsReply{}
+	err = server.RpcGetContainerShards(&shardsRequest, &shardsResponse)
+	assert.NotNil(err)
+}
+
+func TestRpcGetContainerReverse(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+
+	request := GetContainerReq{
+		VirtPath:   testVerAccountName + "/" + "c-nested",
+		MaxEntries: 10000,
+	}
+	response := GetContainerReply{}
+	err := server.RpcGetContainer(&request, &response)
+	assert.Nil(err)
+	var expectedBasenames []string
+	for i := len(response.ContainerEntries) - 1; i >= 0; i-- {
+		expectedBasenames = append(expectedBasenames, response.ContainerEntries[i].Basename)
+	}
+	assert.True(4 < len(expectedBasenames))
+
+	// Paging through the reverse listing yields the listing reversed
+
+	var basenames []string
+	marker := ""
+	for {
+		request := GetContainerReq{
+			VirtPath:   testVerAccountName + "/" + "c-nested",
+			Marker:     marker,
+			Reverse:    true,
+			MaxEntries: 2,
+		}
+		response := GetContainerReply{}
+		err := server.RpcGetContainer(&request, &response)
+		assert.Nil(err)
+		if 0 == len(response.ContainerEntries) {
+			break
+		}
+		for _, ent := range response.ContainerEntries {
+			basenames = append(basenames, ent.Basename)
+		}
+		marker = basenames[len(basenames)-1]
+	}
+	assert.Equal(expectedBasenames, basenames)
+
+	// The end_marker bounds the reverse listing from below (and is itself not listed)
+
+	request = GetContainerReq{
+		VirtPath:   testVerAccountName + "/" + "c-nested",
+		Marker:     expectedBasenames[0],
+		EndMarker:  expectedBasenames[3],
+		Reverse:    true,
+		MaxEntries: 10000,
+	}
+	response = GetContainerReply{}
+	err = server.RpcGetContainer(&request, &response)
+	assert.Nil(err)
+	basenames = nil
+	for _, ent := range response.ContainerEntries {
+		basenames = append(basenames, ent.Basename)
+	}
+	assert.Equal(expectedBasenames[1:3], basenames)
+
+	// ...just as an exclusive end_marker bounds a forward listing from above
+
+	request = GetContainerReq{
+		VirtPath:           testVerAccountName + "/" + "c-nested",
+		Marker:             expectedBasenames[3],
+		EndMarker:          expectedBasenames[0],
+		EndMarkerExclusive: true,
+		MaxEntries:         10000,
+	}
+	response = GetContainerReply{}
+	err = server.RpcGetContainer(&request, &response)
+	assert.Nil(err)
+	basenames = nil
+	for i := len(response.ContainerEntries) - 1; i >= 0; i-- {
+		basenames = append(basenames, response.ContainerEntries[i].Basename)
+	}
+	assert.Equal(expectedBasenames[1:3], basenames)
+}
+
+func TestRpcGetContainerMaxStaleness(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+
+	request := GetContainerReq{
+		VirtPath:   testVerAccountName + "/" + "c-nested",
+		MaxEntries: 10000,
+	}
+	response := GetContainerReply{}
+	err := server.RpcGetContainer(&request, &response)
+	assert.Nil(err)
+
+	// Whether generated anew or cached, the listing is the same (absent intervening changes)
+	for i := 0; i < 2; i++ {
+		cachedRequest := GetContainerReq{
+			VirtPath:         testVerAccountName + "/" + "c-nested",
+			MaxEntries:       10000,
+			MaxStalenessMsec: 60000,
+		}
+		cachedResponse := GetContainerReply{}
+		err = server.RpcGetContainer(&cachedRequest, &cachedResponse)
+		assert.Nil(err)
+		assert.Equal(response.ContainerEntries, cachedResponse.ContainerEntries)
+		assert.Equal(response.Metadata, cachedResponse.Metadata)
+	}
+}
+
 func TestRpcGetContainerPaginated(t *testing.T) {
 	server := &Server{}
 	assert := assert.New(t)
@@ -870,6 +1287,37 @@ func TestRpcGetAccount(t *testing.T) {
 
 	assert.Nil(err)
 	assert.Equal(0, len(response.AccountEntries))
+	// Reverse listings start before the marker and end after the end_marker
+	request = GetAccountReq{
+		VirtPath:   "/v1/" + testAccountName2,
+		Marker:     "lima",
+		EndMarker:  "foxtrot",
+		Reverse:    true,
+		MaxEntries: 3,
+	}
+	response = GetAccountReply{}
+	err = server.RpcGetAccount(&request, &response)
+
+	assert.Nil(err)
+	assert.Equal(3, len(response.AccountEntries))
+	assert.Equal("kilo", response.AccountEntries[0].Basename)
+	assert.Equal("juliet", response.AccountEntries[1].Basename)
+	assert.Equal("india", response.AccountEntries[2].Basename)
+
+	request = GetAccountReq{
+		VirtPath:   "/v1/" + testAccountName2,
+		Marker:     "india",
+		EndMarker:  "foxtrot",
+		Reverse:    true,
+		MaxEntries: 3,
+	}
+	response = GetAccountReply{}
+	err = server.RpcGetAccount(&request, &response)
+
+	assert.Nil(err)
+	assert.Equal(2, len(response.AccountEntries))
+	assert.Equal("hotel", response.AccountEntries[0].Basename)
+	assert.Equal("golf", response.AccountEntries[1].Basename)
 }
 
 func TestRpcBasicApi(t *testing.T) {
@@ -1045,12 +1493,32 @@ func testRpcPost(t *testing.T, server *Server) {
 	err = middlewarePost(server, virtPath, newContMetaData, oldContMetaData)
 	assert.True(blunder.Is(err, blunder.AccountNotModifiable))
 
-	// POST to account
+	// POST to account sets the account's metadata
 	virtPath = testVerAccountName
 	newContMetaData = []byte("account metadata")
 	oldContMetaData = []byte("")
 	err = middlewarePost(server, virtPath, newContMetaData, oldContMetaData)
-	assert.True(blunder.Is(err, blunder.AccountNotModifiable))
+	assert.Nil(err)
+
+	accountHeadRequest := HeadReq{
+		VirtPath: testVerAccountName,
+	}
+	accountHeadResponse := HeadReply{}
+	err = server.RpcHead(&accountHeadRequest, &accountHeadResponse)
+	assert.Nil(err)
+	assert.Equal(newContMetaData, accountHeadResponse.Metadata)
+	assert.True(accountHeadResponse.IsDir)
+
+	// ...provided it is still the metadata the caller last saw
+	newContMetaData = []byte("account metadata with more stuff")
+	oldContMetaData = []byte("incorrect metadata")
+	err = middlewarePost(server, virtPath, newContMetaData, oldContMetaData)
+	assert.True(blunder.Is(err, blunder.OldMetaDataDifferent))
+
+	newContMetaData = []byte("")
+	oldContMetaData = []byte("account metadata")
+	err = middlewarePost(server, virtPath, newContMetaData, oldContMetaData)
+	assert.Nil(err)
 
 	// POST to account/container
 	virtPath = testVerAccountContainerName
@@ -1720,14 +2188,27 @@ func TestRpcGetObjectSymlinkFollowing(t *testing.T) {
 	reply = GetObjectReply{}
 	err = server.RpcGetObject(&req, &reply)
 	assert.NotNil(err)
-	assert.Equal(fmt.Sprintf("errno: %d", blunder.TooManySymlinksError.Value()), err.Error())
+	assert.Equal(fmt.Sprintf("errno: %d pathFailure: {\"Reason\":\"SymlinkLoop\",\"HTTPStatus\":409,\"ParentPath\":\"c1\",\"Segment\":\"symlink-1\",\"Remaining\":0}", blunder.TooManySymlinksError.Value()), err.Error())
 
 	// Test following a cycle: it should look just like an over-length chain
 	req = GetObjectReq{VirtPath: "/v1/AN_account/c3/cycle-a"}
 	reply = GetObjectReply{}
 	err = server.RpcGetObject(&req, &reply)
 	assert.NotNil(err)
-	assert.Equal(fmt.Sprintf("errno: %d", blunder.TooManySymlinksError.Value()), err.Error())
+	assert.Equal(fmt.Sprintf("errno: %d pathFailure: {\"Reason\":\"SymlinkLoop\",\"HTTPStatus\":409,\"ParentPath\":\"c3\",\"Segment\":\"cycle-c\",\"Remaining\":0}", blunder.TooManySymlinksError.Value()), err.Error())
+
+	// Test a path running through a file and one through a missing directory
+	req = GetObjectReq{VirtPath: "/v1/AN_account/c4/symlink-d1/symlink-d2/symlink-kitten.png/whiskers"}
+	reply = GetObjectReply{}
+	err = server.RpcGetObject(&req, &reply)
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d pathFailure: {\"Reason\":\"NotDir\",\"HTTPStatus\":409,\"ParentPath\":\"c1\",\"Segment\":\"kitten.png\",\"Remaining\":1}", blunder.NotDirError.Value()), err.Error())
+
+	req = GetObjectReq{VirtPath: "/v1/AN_account/c4/d1/missing/kitten.png"}
+	reply = GetObjectReply{}
+	err = server.RpcGetObject(&req, &reply)
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d pathFailure: {\"Reason\":\"NotFound\",\"HTTPStatus\":404,\"ParentPath\":\"c4/d1\",\"Segment\":\"missing\",\"Remaining\":1}", blunder.NotFoundError.Value()), err.Error())
 
 	// Test following a symlink to a directory
 	req = GetObjectReq{VirtPath: "/v1/AN_account/c3/symlink-c2"}
@@ -1815,7 +2296,7 @@ func TestRpcMiddlewareMkdir(t *testing.T) {
 	}
 	containerName := "rpc-middleware-mkdir-container"
 
-	fsMkDir(mountHandle, inode.RootDirInodeNumber, containerName)
+	containerInode := fsMkDir(mountHandle, inode.RootDirInodeNumber, containerName)
 	dirName := "rpc-middleware-mkdir-test"
 	dirPath := testVerAccountName + "/" + containerName + "/" + dirName
 	dirMetadata := []byte("some metadata b5fdbc4a0f1484225fcb7aa64b1e6b94")
@@ -1839,15 +2320,41 @@ func TestRpcMiddlewareMkdir(t *testing.T) {
 	assert.True(headReply.IsDir)
 	oldInodeNumber := headReply.InodeNumber
 
-	// If the file exists, we just overwrite it, same as with RpcPutComplete
+	// If the directory exists, we keep it and just replace its metadata
+	newDirMetadata := []byte("some new metadata 3f0e0f6d2c4c1b5b8d9a7e6f5c4b3a29")
 	req = MiddlewareMkdirReq{
 		VirtPath: dirPath,
-		Metadata: dirMetadata,
+		Metadata: newDirMetadata,
 	}
 	reply = MiddlewareMkdirReply{}
 	err = server.RpcMiddlewareMkdir(&req, &reply)
 	assert.Nil(err)
-	assert.NotEqual(reply.InodeNumber, oldInodeNumber)
+	assert.Equal(reply.InodeNumber, oldInodeNumber)
+
+	headReply = HeadReply{}
+	err = server.RpcHead(&headRequest, &headReply)
+	assert.Nil(err)
+	assert.Equal(headReply.Metadata, newDirMetadata)
+	assert.True(headReply.IsDir)
+
+	// A file in the way (or along the way) is a conflict, not something to replace
+	fsCreateFile(mountHandle, containerInode, "rpc-middleware-mkdir-file")
+
+	req = MiddlewareMkdirReq{
+		VirtPath: testVerAccountName + "/" + containerName + "/rpc-middleware-mkdir-file",
+		Metadata: dirMetadata,
+	}
+	reply = MiddlewareMkdirReply{}
+	err = server.RpcMiddlewareMkdir(&req, &reply)
+	assert.True(blunder.Is(err, blunder.FileExistsError))
+
+	req = MiddlewareMkdirReq{
+		VirtPath: testVerAccountName + "/" + containerName + "/rpc-middleware-mkdir-file/subdir",
+		Metadata: dirMetadata,
+	}
+	reply = MiddlewareMkdirReply{}
+	err = server.RpcMiddlewareMkdir(&req, &reply)
+	assert.True(blunder.Is(err, blunder.NotDirError))
 }
 
 func TestRpcMiddlewareMkdirNested(t *testing.T) {
@@ -1883,6 +2390,77 @@ func TestRpcMiddlewareMkdirNested(t *testing.T) {
 	assert.True(headReply.IsDir)
 }
 
+func TestRpcFreezeContainer(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+	mountHandle, err := fs.Mount("SomeVolume", fs.MountOptions(0))
+	if nil != err {
+		panic(fmt.Sprintf("failed to mount SomeVolume: %v", err))
+	}
+
+	containerName := "rpc-freeze-container-hibernal-stasis"
+	containerPath := testVerAccountName + "/" + containerName
+
+	fsMkDir(mountHandle, inode.RootDirInodeNumber, containerName)
+
+	freezeRequest := FreezeContainerReq{
+		VirtPath: containerPath,
+		TTLMsec:  60000,
+	}
+	freezeReply := FreezeContainerReply{}
+	err = server.RpcFreezeContainer(&freezeRequest, &freezeReply)
+	assert.Nil(err)
+	assert.NotEqual(uint64(0), freezeReply.FreezeID)
+	assert.True(freezeReply.TTLMsec <= 60000)
+
+	// Requests carrying the FreezeID proceed
+
+	mkdirRequest := MiddlewareMkdirReq{
+		VirtPath: containerPath + "/frozen-dir",
+		FreezeID: freezeReply.FreezeID,
+	}
+	mkdirReply := MiddlewareMkdirReply{}
+	err = server.RpcMiddlewareMkdir(&mkdirRequest, &mkdirReply)
+	assert.Nil(err)
+
+	// Others wait until thawed
+
+	mkdirDoneChan := make(chan error, 1)
+	go func() {
+		otherMkdirRequest := MiddlewareMkdirReq{
+			VirtPath: containerPath + "/thawed-dir",
+		}
+		otherMkdirReply := MiddlewareMkdirReply{}
+		mkdirDoneChan <- server.RpcMiddlewareMkdir(&otherMkdirRequest, &otherMkdirReply)
+	}()
+
+	select {
+	case err = <-mkdirDoneChan:
+		t.Fatalf("RpcMiddlewareMkdir() completed (with %v) while frozen", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	thawRequest := ThawContainerReq{
+		VirtPath: containerPath,
+		FreezeID: freezeReply.FreezeID,
+	}
+	thawReply := ThawContainerReply{}
+	err = server.RpcThawContainer(&thawRequest, &thawReply)
+	assert.Nil(err)
+
+	select {
+	case err = <-mkdirDoneChan:
+		assert.Nil(err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("RpcMiddlewareMkdir() not released by RpcThawContainer()")
+	}
+
+	// The FreezeID is no longer honored once thawed
+
+	err = server.RpcMiddlewareMkdir(&mkdirRequest, &mkdirReply)
+	assert.True(blunder.Is(err, blunder.NotFoundError))
+}
+
 func TestRpcCoalesce(t *testing.T) {
 	server := &Server{}
 	assert := assert.New(t)
@@ -1952,6 +2530,64 @@ func TestRpcCoalesce(t *testing.T) {
 	assert.Equal([]byte("red orange yellow"), combinedContents)
 }
 
+func TestRpcCoalesceRanges(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+	mountHandle, err := fs.Mount("SomeVolume", fs.MountOptions(0))
+	if nil != err {
+		panic(fmt.Sprintf("failed to mount SomeVolume: %v", err))
+	}
+
+	containerName := "rpc-coalesce-ranges-unprovident-hyperemia"
+	containerPath := testVerAccountName + "/" + containerName
+
+	destinationPath := containerPath + "/" + "combined-file"
+
+	containerInode := fsMkDir(mountHandle, inode.RootDirInodeNumber, containerName)
+	containerDirInode := fsMkDir(mountHandle, containerInode, "dir")
+
+	file1Path := "/" + containerName + "/dir/1"
+	file1Inode := fsCreateFile(mountHandle, containerDirInode, "1")
+	_, err = mountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, file1Inode, 0, []byte("the red "), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	file2Path := "/" + containerName + "/dir/2"
+	file2Inode := fsCreateFile(mountHandle, containerDirInode, "2")
+	_, err = mountHandle.Write(inode.InodeRootUserID, inode.InodeRootGroupID, nil, file2Inode, 0, []byte("orange"), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	// There must be a range for each element (if any are given)
+	coalesceRequest := CoalesceReq{
+		VirtPath:                    destinationPath,
+		ElementAccountRelativePaths: []string{file1Path, file2Path},
+		ElementRanges:               []CoalesceElementRange{{Offset: 4}},
+	}
+	coalesceReply := CoalesceReply{}
+	err = server.RpcCoalesce(&coalesceRequest, &coalesceReply)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.InvalidArgError), err.Error())
+
+	coalesceRequest.ElementRanges = []CoalesceElementRange{{Offset: 4}, {Offset: 0, Length: 2}}
+	coalesceRequest.EmitManifest = true
+	err = server.RpcCoalesce(&coalesceRequest, &coalesceReply)
+	assert.Nil(err)
+
+	combinedInode, err := mountHandle.LookupPath(inode.InodeRootUserID, inode.InodeRootGroupID, nil, containerName+"/combined-file")
+	assert.Nil(err)
+	assert.Equal(uint64(combinedInode), coalesceReply.InodeNumber)
+
+	combinedContents, err := mountHandle.Read(inode.InodeRootUserID, inode.InodeRootGroupID, nil, combinedInode, 0, 99999, nil)
+	assert.Nil(err)
+	assert.Equal([]byte("red or"), combinedContents)
+
+	manifest, err := mountHandle.GetXAttr(inode.InodeRootUserID, inode.InodeRootGroupID, nil, combinedInode, fs.CoalesceManifestStream)
+	assert.Nil(err)
+	assert.Equal(`[{"name":"/`+containerName+`/dir/1","bytes":4,"range":"4-7"},{"name":"/`+containerName+`/dir/2","bytes":2,"range":"0-1"}]`, string(manifest))
+}
+
 func TestRpcCoalesceOverwrite(t *testing.T) {
 	server := &Server{}
 	assert := assert.New(t)
@@ -2284,3 +2920,91 @@ func TestRpcCoalesceSubdirOfAFile(t *testing.T) {
 	assert.NotNil(err)
 	assert.Equal(fmt.Sprintf("errno: %d", blunder.NotDirError), err.Error())
 }
+
+func TestRpcContainerACL(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+	mountHandle, err := fs.Mount("SomeVolume", fs.MountOptions(0))
+	if nil != err {
+		panic(fmt.Sprintf("failed to mount SomeVolume: %v", err))
+	}
+
+	containerName := "rpc-container-acl-unvizarded-Pelecaniformes"
+	containerPath := testVerAccountName + "/" + containerName
+	containerInode := fsMkDir(mountHandle, inode.RootDirInodeNumber, containerName)
+	fsCreateFile(mountHandle, containerInode, "README")
+
+	setReq := SetContainerACLReq{
+		VirtPath: containerPath,
+		ACL:      &fs.ContainerACL{Read: []string{"AUTH_test:reader"}},
+	}
+	setReply := SetContainerACLReply{}
+	err = server.RpcSetContainerACL(&setReq, &setReply)
+	assert.Nil(err)
+
+	getReq := GetContainerACLReq{VirtPath: containerPath}
+	getReply := GetContainerACLReply{}
+	err = server.RpcGetContainerACL(&getReq, &getReply)
+	assert.Nil(err)
+	assert.Equal(setReq.ACL, getReply.ACL)
+
+	// The listed caller may read, but not delete
+	req := GetObjectReq{
+		VirtPath: containerPath + "/README",
+		Caller:   &fs.MiddlewareCallerStruct{Principal: "AUTH_test:reader"},
+	}
+	reply := GetObjectReply{}
+	err = server.RpcGetObject(&req, &reply)
+	assert.Nil(err)
+
+	deleteReq := DeleteReq{
+		VirtPath: containerPath + "/README",
+		Caller:   &fs.MiddlewareCallerStruct{Principal: "AUTH_test:reader"},
+	}
+	deleteReply := DeleteReply{}
+	err = server.RpcDelete(&deleteReq, &deleteReply)
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.PermDeniedError), err.Error())
+
+	// Others may not read
+	req.Caller = &fs.MiddlewareCallerStruct{Principal: "AUTH_test:stranger"}
+	err = server.RpcGetObject(&req, &reply)
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.PermDeniedError), err.Error())
+
+	// Requests without a caller are not subject to the ACL
+	deleteReq.Caller = nil
+	err = server.RpcDelete(&deleteReq, &deleteReply)
+	assert.Nil(err)
+}
+
+func TestRpcDeleteMulti(t *testing.T) {
+	server := &Server{}
+	assert := assert.New(t)
+	mountHandle, err := fs.Mount("SomeVolume", fs.MountOptions(0))
+	if nil != err {
+		panic(fmt.Sprintf("failed to mount SomeVolume: %v", err))
+	}
+
+	containerName := "rpc-delete-multi-Hydrocharis-overbrutal"
+	containerInode := fsMkDir(mountHandle, inode.RootDirInodeNumber, containerName)
+	fsCreateFile(mountHandle, containerInode, "one")
+	fsCreateFile(mountHandle, containerInode, "two")
+
+	req := DeleteMultiReq{
+		VirtPath: testVerAccountName,
+		Entries: []fs.DeleteMultiEntry{
+			{Container: containerName, ObjectPath: "one"},
+			{Container: containerName, ObjectPath: "missing"},
+			{Container: containerName, ObjectPath: "two"},
+			{Container: containerName},
+		},
+	}
+	reply := DeleteMultiReply{}
+	err = server.RpcDeleteMulti(&req, &reply)
+	assert.Nil(err)
+	assert.Equal([]int{0, int(blunder.NotFoundError), 0, 0}, reply.Errnos)
+	assert.Equal(uint64(3), reply.NumDeleted)
+	assert.Equal(uint64(1), reply.NumNotFound)
+	assert.Equal(uint64(0), reply.NumFailed)
+}
@@ -0,0 +1,72 @@
+package jrpcfs
+
+// NFSv4 ACLs (e.g. for an NFSv4 gateway or Ganesha FSAL)
+//
+// RpcGetNFS4ACL and RpcSetNFS4ACL transfer the NFSv4 ACL of an inode (see fs/nfs4acl.go) as a slice of
+// NFS4ACEs, each the like-named fields of an inode.NFS4ACEStruct.
+
+import (
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+func (s *Server) RpcGetNFS4ACL(in *GetNFS4ACLRequest, reply *GetNFS4ACLReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	acl, err := mountHandle.GetNFS4ACL(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+
+	reply.ACEs = make([]NFS4ACE, len(acl))
+	for i, ace := range acl {
+		reply.ACEs[i] = NFS4ACE{
+			Type:  uint32(ace.Type),
+			Flags: uint32(ace.Flags),
+			Mask:  uint32(ace.Mask),
+			Who:   ace.Who,
+		}
+	}
+
+	return
+}
+
+func (s *Server) RpcSetNFS4ACL(in *SetNFS4ACLRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	var acl inode.NFS4ACL
+	if !in.Remove {
+		acl = make(inode.NFS4ACL, len(in.ACEs))
+		for i, ace := range in.ACEs {
+			acl[i] = inode.NFS4ACEStruct{
+				Type:  inode.NFS4ACEType(ace.Type),
+				Flags: inode.NFS4ACEFlags(ace.Flags),
+				Mask:  inode.NFS4ACEMask(ace.Mask),
+				Who:   ace.Who,
+			}
+		}
+	}
+
+	err = mountHandle.SetNFS4ACL(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), acl)
+	return
+}
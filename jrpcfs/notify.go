@@ -0,0 +1,155 @@
+package jrpcfs
+
+// Change notification (e.g. for SMB change notify via Samba)
+//
+// RpcWatchAdd registers an fs watch whose NotifyHandler appends each event to a queue held here.
+// Since JSON RPC is strictly request/response, clients long-poll RpcWatchFetch to receive queued
+// events. Should a client stop fetching, its queue collapses into a single fs.NotifyOverflow.
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+const watchQueueMax = 1024 // events held for a single watch awaiting RpcWatchFetch
+
+type watchQueueStruct struct {
+	sync.Mutex
+	mountID  uint64
+	events   []fs.NotifyEvent
+	wakeChan chan struct{} // buffered (1); signaled whenever events transitions from empty
+}
+
+func (watchQueue *watchQueueStruct) handler(watchID fs.WatchID, event fs.NotifyEvent) {
+	watchQueue.Lock()
+	if len(watchQueue.events) >= watchQueueMax {
+		watchQueue.events = append(watchQueue.events[:0], fs.NotifyEvent{Type: fs.NotifyOverflow})
+	}
+	watchQueue.events = append(watchQueue.events, event)
+	watchQueue.Unlock()
+
+	select {
+	case watchQueue.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+func lookupWatchQueue(mountID uint64, watchID uint64) (watchQueue *watchQueueStruct, err error) {
+	globals.Lock()
+	watchQueue, ok := globals.watchQueueMap[fs.WatchID(watchID)]
+	globals.Unlock()
+	if !ok || (mountID != watchQueue.mountID) {
+		err = fmt.Errorf("WatchID %v not found for MountID %v", watchID, mountID)
+		err = blunder.AddError(err, blunder.NotFoundError)
+	}
+	return
+}
+
+func (s *Server) RpcWatchAdd(in *WatchAddRequest, reply *WatchAddReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+
+	watchQueue := &watchQueueStruct{
+		mountID:  in.MountID,
+		events:   make([]fs.NotifyEvent, 0),
+		wakeChan: make(chan struct{}, 1),
+	}
+
+	// Hold globals while adding the watch so that no event can be delivered before watchQueueMap is updated
+	globals.Lock()
+	defer globals.Unlock()
+
+	watchID, err := mountHandle.AddWatch(inode.InodeRootUserID, inode.InodeRootGroupID, nil, inode.InodeNumber(in.InodeNumber), in.Subtree, watchQueue.handler)
+	if nil != err {
+		return
+	}
+
+	globals.watchQueueMap[watchID] = watchQueue
+
+	reply.WatchID = uint64(watchID)
+	return
+}
+
+func (s *Server) RpcWatchFetch(in *WatchFetchRequest, reply *WatchFetchReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	watchQueue, err := lookupWatchQueue(in.MountID, in.WatchID)
+	if nil != err {
+		return
+	}
+
+	watchQueue.Lock()
+	if (0 == len(watchQueue.events)) && (0 < in.TimeoutMsec) {
+		watchQueue.Unlock()
+		select {
+		case <-watchQueue.wakeChan:
+		case <-time.After(time.Duration(in.TimeoutMsec) * time.Millisecond):
+		}
+		watchQueue.Lock()
+	}
+
+	eventCount := uint64(len(watchQueue.events))
+	if (0 < in.MaxEvents) && (eventCount > in.MaxEvents) {
+		eventCount = in.MaxEvents
+	}
+
+	reply.Events = make([]NotifyEvent, eventCount)
+	for i, event := range watchQueue.events[:eventCount] {
+		reply.Events[i] = NotifyEvent{
+			EventType:         uint32(event.Type),
+			InodeNumber:       uint64(event.InodeNumber),
+			ParentInodeNumber: uint64(event.ParentInodeNumber),
+			Basename:          event.Basename,
+		}
+	}
+	watchQueue.events = watchQueue.events[eventCount:]
+	watchQueue.Unlock()
+
+	return
+}
+
+func (s *Server) RpcWatchRemove(in *WatchRemoveRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.MountID)
+	if nil != err {
+		return
+	}
+	_, err = lookupWatchQueue(in.MountID, in.WatchID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.RemoveWatch(fs.WatchID(in.WatchID))
+
+	globals.Lock()
+	delete(globals.watchQueueMap, fs.WatchID(in.WatchID))
+	globals.Unlock()
+
+	return
+}
@@ -24,8 +24,10 @@ differences that must be called out.
   sometimes an opaque value sufficient to provide a strong identifier as per
   RFC 7231, but it is not the MD5 checksum of the object's contents.
 
-  ETags start out as MD5 checksums, but if files are subsequently modified
-  via SMB or NFS, the ETags become opaque values.
+  ETags start out as MD5 checksums. If files are subsequently modified
+  via SMB or NFS, proxyfsd hashes their contents anew (see ETagAlgorithm
+  in proxyfsd's configuration) unless they're too large to hash quickly,
+  in which case the ETags become opaque values.
 
 * Container listings lack object count. To get an object count, it would be
   necessary to traverse the entire directory structure underneath the
@@ -105,7 +107,7 @@ from swift.common import swob
 
 # Our logs should go to the same place as everyone else's. Plus, this logger
 # works well in an eventlet-ified process, and SegmentedIterable needs one.
-from swift.common.utils import get_logger
+from swift.common.utils import config_true_value, get_logger
 
 
 # Used for content type of directories in container listings
@@ -388,7 +390,10 @@ def extract_container_metadata_from_headers(headers):
     return meta_headers
 
 
-def best_possible_etag(obj_metadata, account_name, ino, num_writes):
+def best_possible_etag(obj_metadata, account_name, ino, num_writes,
+                       content_etag=None):
+    if content_etag:
+        return content_etag
     if ORIGINAL_MD5_HEADER in obj_metadata:
         val = obj_metadata[ORIGINAL_MD5_HEADER]
         try:
@@ -415,6 +420,39 @@ def construct_etag(account_name, ino, num_writes):
         urllib_parse.quote(account_name), ino, num_writes)
 
 
+def path_failure_response(req, path_failure):
+    """
+    Build the response to a request whose path proxyfsd could not
+    resolve, given the path failure from the RpcError (see
+    utils.extract_path_failure).
+    """
+    reason = path_failure.get("Reason")
+    segment = path_failure.get("Segment", "")
+    if path_failure.get("ParentPath"):
+        where = "/".join((path_failure["ParentPath"], segment))
+    else:
+        where = segment
+
+    if reason == "NotFound" and path_failure.get("Remaining"):
+        body = "Path element %s not found" % (where,)
+    elif reason == "NotFound":
+        body = ""
+    elif reason == "NotDir":
+        body = "Path element %s is a file, not a directory" % (where,)
+    elif reason == "SymlinkLoop":
+        body = "Too many symlinks while resolving %s" % (where,)
+    elif reason == "PermDenied":
+        body = "Path element %s may not be searched" % (
+            path_failure.get("ParentPath") or "/",)
+    else:
+        body = "Error resolving path element %s" % (where,)
+
+    resp_class = swob.status_map.get(path_failure.get("HTTPStatus"),
+                                     swob.HTTPInternalServerError)
+    return resp_class(request=req, headers={"Content-Type": "text/plain"},
+                      body=body)
+
+
 def iterator_posthook(iterable, posthook, *posthook_args, **posthook_kwargs):
     try:
         for x in iterable:
@@ -606,6 +644,9 @@ class PfsMiddleware(object):
         self.max_log_segment_size = int(conf.get(
             'max_log_segment_size', '2147483648'))  # 2 GiB
         self.max_coalesce = int(conf.get('max_coalesce', '1000'))
+        # If greater than 1, container listings are partitioned into up to
+        # this many shards that are listed concurrently.
+        self.listing_shards = int(conf.get('listing_shards', '0'))
 
         # Assume a max object length of the Swift default of 1024 bytes plus
         # a few extra for JSON quotes, commas, et cetera.
@@ -711,6 +752,13 @@ class PfsMiddleware(object):
                 headers={"Content-Type": "text/plain"},
                 body="RPC timeout: {0}".format(err))
         except utils.RpcError as err:
+            if err.errno == pfs_errno.DevBusyError:
+                # proxyfsd is shedding heavy operations (e.g. COALESCE or
+                # container listings); the client should back off and retry.
+                return swob.HTTPServiceUnavailable(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="RPC error: {0}".format(err))
             self.logger.error(
                 "RPC error: %s; consulting proxyfsd logs may be helpful", err)
             return swob.HTTPInternalServerError(
@@ -759,8 +807,10 @@ class PfsMiddleware(object):
         limit = self._get_listing_limit(
             req, self._default_account_listing_limit())
         marker = req.params.get('marker', '')
+        end_marker = req.params.get('end_marker', '')
+        reverse = config_true_value(req.params.get('reverse', ''))
         get_account_request = rpc.get_account_request(
-            urllib_parse.unquote(req.path), marker, limit)
+            urllib_parse.unquote(req.path), marker, limit, end_marker, reverse)
         # If the account does not exist, then __call__ just falls through to
         # self.app, so we never even get here. If we got here, then the
         # account does exist, so we don't have to worry about not-found
@@ -984,19 +1034,43 @@ class PfsMiddleware(object):
             req, self._default_container_listing_limit())
         marker = req.params.get('marker', '')
         prefix = req.params.get('prefix', '')
-        get_container_request = rpc.get_container_request(
-            urllib_parse.unquote(req.path), marker, limit, prefix)
+        # ProxyFS can only delimit listings by directory; any other
+        # delimiter is ignored (yielding the full listing) as before.
+        delimiter = req.params.get('delimiter', '')
+        if delimiter != '/':
+            delimiter = ''
+        end_marker = req.params.get('end_marker', '')
+        reverse = config_true_value(req.params.get('reverse', ''))
+        # Clients that poll listings (e.g. dashboards) may accept a listing
+        # up to this many seconds stale, sparing ProxyFS a walk of the
+        # container each time. Otherwise listings are strongly consistent.
         try:
-            get_container_response = self.rpc_call(ctx, get_container_request)
+            max_staleness_msec = int(1000 * float(req.headers.get(
+                'ProxyFS-Listing-Max-Staleness', '0')))
+        except ValueError:
+            max_staleness_msec = 0
+        max_staleness_msec = max(max_staleness_msec, 0)
+        path = urllib_parse.unquote(req.path)
+        try:
+            if (self.listing_shards > 1 and limit > 0 and not reverse and
+                    not max_staleness_msec):
+                container_ents, raw_metadata, mtime_ns = \
+                    self._get_container_sharded(
+                        ctx, path, marker, end_marker, limit, prefix,
+                        delimiter)
+            else:
+                get_container_request = rpc.get_container_request(
+                    path, marker, limit, prefix, delimiter, end_marker,
+                    reverse=reverse, max_staleness_msec=max_staleness_msec)
+                container_ents, raw_metadata, mtime_ns = \
+                    rpc.parse_get_container_response(
+                        self.rpc_call(ctx, get_container_request))
         except utils.RpcError as err:
             if err.errno == pfs_errno.NotFoundError:
                 return swob.HTTPNotFound(request=req)
             else:
                 raise
 
-        container_ents, raw_metadata, mtime_ns = \
-            rpc.parse_get_container_response(get_container_response)
-
         resp_content_type = swift_code.get_listing_content_type(req)
         resp = swob.HTTPOk(content_type=resp_content_type, charset="utf-8",
                            request=req)
@@ -1021,6 +1095,59 @@ class PfsMiddleware(object):
 
         return resp
 
+    def _get_container_sharded(self, ctx, path, marker, end_marker, limit,
+                               prefix, delimiter):
+        """
+        List a container by partitioning it into shards (see
+        RpcGetContainerShards) listed concurrently, each for up to limit
+        entries. As the shards are disjoint and in order, their listings
+        concatenated (and cut off at limit) are the container listing.
+
+        Returns the same (entries, metadata, mtime) as
+        rpc.parse_get_container_response().
+        """
+        shards = rpc.parse_get_container_shards_response(self.rpc_call(
+            ctx, rpc.get_container_shards_request(path, self.listing_shards)))
+
+        shard_requests = []
+        for shard_marker, shard_end_marker in shards:
+            if shard_end_marker and shard_end_marker <= marker:
+                continue  # entirely at or before the marker
+            if end_marker and shard_marker >= end_marker:
+                break  # entirely at or after the end_marker
+            if end_marker and (not shard_end_marker or
+                               end_marker <= shard_end_marker):
+                # the last shard to list, ending (exclusively) at end_marker
+                shard_requests.append(rpc.get_container_request(
+                    path, max(marker, shard_marker), limit, prefix,
+                    delimiter, end_marker))
+                break
+            shard_requests.append(rpc.get_container_request(
+                path, max(marker, shard_marker), limit, prefix, delimiter,
+                shard_end_marker, end_marker_inclusive=True))
+
+        if not shard_requests:
+            # Still need the container's metadata & mtime
+            shard_requests.append(rpc.get_container_request(
+                path, marker, limit, prefix, delimiter, end_marker))
+
+        pool = eventlet.GreenPool(len(shard_requests))
+        responses = list(pool.imap(
+            lambda shard_request: self.rpc_call(ctx, shard_request),
+            shard_requests))
+
+        container_ents = []
+        raw_metadata, mtime_ns = None, None
+        for response in responses:
+            shard_ents, shard_metadata, shard_mtime_ns = \
+                rpc.parse_get_container_response(response)
+            if raw_metadata is None:
+                raw_metadata, mtime_ns = shard_metadata, shard_mtime_ns
+            container_ents.extend(shard_ents)
+            if len(container_ents) >= limit:
+                break
+        return container_ents[:limit], raw_metadata, mtime_ns
+
     def _plaintext_container_get_response(self, container_entries):
         chunks = []
         for ent in container_entries:
@@ -1031,6 +1158,9 @@ class PfsMiddleware(object):
     def _json_container_get_response(self, container_entries, account_name):
         json_entries = []
         for ent in container_entries:
+            if ent.get("IsSubdir"):
+                json_entries.append({"subdir": ent["Basename"]})
+                continue
             name = ent["Basename"]
             size = ent["FileSize"]
             last_modified = iso_timestamp_from_epoch_ns(
@@ -1057,6 +1187,15 @@ class PfsMiddleware(object):
         root_node = ET.Element('container', name=container_name)
 
         for container_entry in container_entries:
+            if container_entry.get("IsSubdir"):
+                subdir_node = ET.Element('subdir',
+                                         name=container_entry['Basename'])
+                name_node = ET.Element('name')
+                name_node.text = container_entry['Basename']
+                subdir_node.append(name_node)
+                root_node.append(subdir_node)
+                continue
+
             obj_name = container_entry['Basename']
             obj_metadata = deserialize_metadata(container_entry["Metadata"])
             content_type = obj_metadata.get("Content-Type")
@@ -1131,7 +1270,24 @@ class PfsMiddleware(object):
             req.headers))
 
         rpc_req = rpc.middleware_mkdir_request(path, obj_metadata)
-        rpc_resp = self.rpc_call(ctx, rpc_req)
+        try:
+            rpc_resp = self.rpc_call(ctx, rpc_req)
+        except utils.RpcError as err:
+            # An existing directory is simply reused, but a file is never
+            # replaced by a directory.
+            if err.errno == pfs_errno.FileExistsError:
+                return swob.HTTPConflict(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="This is a file, not a directory")
+            elif err.errno == pfs_errno.NotDirError:
+                return swob.HTTPConflict(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="Path element is a file, not a directory")
+            else:
+                # punt to top-level error handler
+                raise
         mtime_ns, inode, num_writes = rpc.parse_middleware_mkdir_response(
             rpc_resp)
 
@@ -1181,6 +1337,10 @@ class PfsMiddleware(object):
         if error_response:
             return error_response
 
+        preconditions, error_response = self._put_preconditions(ctx)
+        if error_response:
+            return error_response
+
         # If these make it to Swift, they can goof up our log segment behind
         # proxyfs's back.
         for forbidden_header in FORBIDDEN_OBJECT_HEADERS:
@@ -1237,7 +1397,8 @@ class PfsMiddleware(object):
                                                        hasher.hexdigest())
 
         put_complete_req = rpc.put_complete_request(
-            virtual_path, log_segments, serialize_metadata(obj_metadata))
+            virtual_path, log_segments, serialize_metadata(obj_metadata),
+            preconditions)
         try:
             # Ignore the return value. On success, there's nothing
             # useful in the response.
@@ -1257,6 +1418,9 @@ class PfsMiddleware(object):
                     request=req,
                     headers={"Content-Type": "text/plain"},
                     body="Path element is a file, not a directory")
+            elif err.errno == pfs_errno.PreconditionFailed:
+                # The object changed while we were uploading
+                return swob.HTTPPreconditionFailed(request=req)
             else:
                 # punt to top-level error handler
                 raise
@@ -1278,6 +1442,72 @@ class PfsMiddleware(object):
             "Last-Modified": last_modified_from_epoch_ns(mtime_ns)}
         return swob.HTTPCreated(request=req, headers=resp_headers, body="")
 
+    def _put_preconditions(self, ctx):
+        """
+        Translate an object PUT's If-Match and If-None-Match headers into
+        preconditions for RpcPutComplete.
+
+        Since ProxyFS checks the preconditions only once the data has been
+        uploaded, we also check them here up front so that a doomed PUT
+        fails before we read its body. Matching an If-Match ETag against
+        the object's current one pins the object's inode number and number
+        of writes; ProxyFS then replaces the object only if it still has
+        those, i.e. if nothing has modified it in the meantime.
+
+        Returns: 2-tuple (preconditions dict or None, error response or
+            None)
+        """
+        req = ctx.req
+        if_match = req.headers.pop("If-Match", None)
+        if_none_match = req.headers.pop("If-None-Match", None)
+
+        if if_match is None and if_none_match is None:
+            return None, None
+
+        if if_none_match is not None and if_none_match.strip() != "*":
+            return None, swob.HTTPBadRequest(
+                request=req,
+                headers={"Content-Type": "text/plain"},
+                body="If-None-Match only supports *")
+
+        try:
+            head_response = self.rpc_call(ctx, rpc.head_request(
+                urllib_parse.unquote(req.path)))
+        except utils.RpcError as err:
+            if err.errno in (pfs_errno.NotFoundError, pfs_errno.NotDirError):
+                head_response = None
+            else:
+                raise
+
+        preconditions = {}
+
+        if if_none_match is not None:
+            if head_response is not None:
+                return None, swob.HTTPPreconditionFailed(request=req)
+            preconditions["IfNoneMatch"] = True
+
+        if if_match is not None:
+            if head_response is None:
+                return None, swob.HTTPPreconditionFailed(request=req)
+            raw_md, _, _, is_dir, ino, num_writes = \
+                rpc.parse_head_response(head_response)
+            if is_dir:
+                return None, swob.HTTPPreconditionFailed(request=req)
+
+            wanted_etags = [e.strip().strip('"')
+                            for e in if_match.split(",")]
+            preconditions["IfMatch"] = True
+            if "*" not in wanted_etags:
+                current_etag = best_possible_etag(
+                    deserialize_metadata(raw_md), ctx.account_name, ino,
+                    num_writes, rpc.parse_etag(head_response)).strip('"')
+                if current_etag not in wanted_etags:
+                    return None, swob.HTTPPreconditionFailed(request=req)
+                preconditions["IfMatchInodeNumber"] = ino
+                preconditions["IfMatchNumWrites"] = num_writes
+
+        return preconditions, None
+
     def post_object(self, ctx):
         req = ctx.req
         path = urllib_parse.unquote(req.path)
@@ -1302,7 +1532,8 @@ class PfsMiddleware(object):
 
         resp = swob.HTTPAccepted(request=req, body="")
         resp.headers["ETag"] = best_possible_etag(
-            old_metadata, ctx.account_name, inode_number, num_writes)
+            old_metadata, ctx.account_name, inode_number, num_writes,
+            rpc.parse_etag(head_response))
         resp.headers["Last-Modified"] = last_modified_from_epoch_ns(mtime)
         return resp
 
@@ -1314,7 +1545,9 @@ class PfsMiddleware(object):
             object_response = self.rpc_call(ctx, rpc.get_object_request(
                 urllib_parse.unquote(req.path), byteranges))
         except utils.RpcError as err:
-            if err.errno == pfs_errno.NotFoundError:
+            if err.path_failure:
+                return path_failure_response(req, err.path_failure)
+            elif err.errno == pfs_errno.NotFoundError:
                 return swob.HTTPNotFound(request=req)
             elif err.errno == pfs_errno.NotDirError:
                 return swob.HTTPNotFound(request=req)
@@ -1322,6 +1555,8 @@ class PfsMiddleware(object):
                 return swob.HTTPOk(
                     request=req, body="",
                     headers={"Content-Type": DIRECTORY_CONTENT_TYPE})
+            elif err.errno == pfs_errno.RangeNotSatisfiable:
+                return swob.HTTPRequestedRangeNotSatisfiable(request=req)
 
             else:
                 # punt to top-level exception handler
@@ -1341,16 +1576,18 @@ class PfsMiddleware(object):
         headers = swob.HeaderKeyDict(deserialize_metadata(raw_metadata))
 
         if "Content-Type" not in headers:
-            headers["Content-Type"] = guess_content_type(req.path,
-                                                         is_dir=False)
+            headers["Content-Type"] = (
+                rpc.parse_content_type(object_response) or
+                guess_content_type(req.path, is_dir=False))
 
         headers["Accept-Ranges"] = "bytes"
         headers["Last-Modified"] = last_modified_from_epoch_ns(
             mtime_ns)
         headers["X-Timestamp"] = x_timestamp_from_epoch_ns(
             mtime_ns)
-        headers["Etag"] = best_possible_etag(headers, ctx.account_name,
-                                             ino, num_writes)
+        headers["Etag"] = best_possible_etag(
+            headers, ctx.account_name, ino, num_writes,
+            rpc.parse_etag(object_response))
 
         listing_iter = listing_iter_from_read_plan(read_plan)
         # Make sure that nobody (like our __call__ method) messes with this
@@ -1464,11 +1701,14 @@ class PfsMiddleware(object):
         headers = swob.HeaderKeyDict(deserialize_metadata(raw_md))
 
         if "Content-Type" not in headers:
-            headers["Content-Type"] = guess_content_type(req.path, is_dir)
+            headers["Content-Type"] = (
+                rpc.parse_content_type(head_response) or
+                guess_content_type(req.path, is_dir))
 
         headers["Content-Length"] = file_size
         headers["ETag"] = best_possible_etag(
-            headers, ctx.account_name, ino, num_writes)
+            headers, ctx.account_name, ino, num_writes,
+            rpc.parse_etag(head_response))
         headers["Last-Modified"] = last_modified_from_epoch_ns(
             last_modified_ns)
         headers["X-Timestamp"] = x_timestamp_from_epoch_ns(
@@ -1572,6 +1812,13 @@ class PfsMiddleware(object):
             have an errno in it, then the exception's errno attribute will
             be None.
         """
+        # Let proxyfsd tag its access log record for this RPC with our
+        # transaction ID so the two logs can be joined.
+        trans_id = ctx.req.headers.get('X-Trans-Id')
+        params = rpc_request.get("params")
+        if trans_id and params and isinstance(params[0], dict):
+            params[0]["TransId"] = trans_id
+
         return self._rpc_call([ctx.proxyfsd_addrinfo], rpc_request)
 
     def _rpc_call(self, addrinfos, rpc_request):
@@ -1608,6 +1855,8 @@ class PfsMiddleware(object):
             errstr = result.get("error")
             if errstr:
                 errno = utils.extract_errno(errstr)
-                raise utils.RpcError(errno, errstr)
+                raise utils.RpcError(
+                    errno, errstr,
+                    path_failure=utils.extract_path_failure(errstr))
 
             return result["result"]
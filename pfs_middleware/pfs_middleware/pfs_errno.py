@@ -24,11 +24,14 @@ different.
 
 errorcode = {
     2: "NotFoundError",
+    6: "RangeNotSatisfiable",
+    16: "DevBusyError",
     17: "FileExistsError",
     20: "NotDirError",
     21: "IsDirError",
     31: "TooManyLinksError",
     39: "NotEmptyError",
+    1004: "PreconditionFailed",
 }
 
 g = globals()
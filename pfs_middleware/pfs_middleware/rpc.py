@@ -193,7 +193,8 @@ def parse_put_location_response(put_location_response):
     return put_location_response["PhysPath"]
 
 
-def put_complete_request(virtual_path, log_segments, obj_metadata):
+def put_complete_request(virtual_path, log_segments, obj_metadata,
+                         preconditions=None):
     """
     Return a JSON-RPC request to notify proxyfsd that an object PUT has
     completed.
@@ -210,12 +211,21 @@ def put_complete_request(virtual_path, log_segments, obj_metadata):
         sizes. Comes as a list of 2-tuples (segment-name, segment-size).
 
     :param obj_metadata: serialized object metadata
+
+    :param preconditions: optional dict of conditions upon the object
+        currently at virtual_path (any of "IfNoneMatch", "IfMatch",
+        "IfMatchETag", "IfMatchInodeNumber", and "IfMatchNumWrites"); if
+        they aren't met, the request fails with PreconditionFailed and the
+        object is left as it was.
     """
-    return jsonrpc_request("Server.RpcPutComplete", [{
+    args = {
         "VirtPath": virtual_path,
         "PhysPaths": [ls[0] for ls in log_segments],
         "PhysLengths": [ls[1] for ls in log_segments],
-        "Metadata": _encode_binary(obj_metadata)}])
+        "Metadata": _encode_binary(obj_metadata)}
+    if preconditions:
+        args.update(preconditions)
+    return jsonrpc_request("Server.RpcPutComplete", [args])
 
 
 def parse_put_complete_response(put_complete_response):
@@ -231,7 +241,7 @@ def parse_put_complete_response(put_complete_response):
             put_complete_response["NumWrites"])
 
 
-def get_account_request(path, marker, limit):
+def get_account_request(path, marker, limit, end_marker="", reverse=False):
     """
     Return a JSON-RPC request to get a account listing for a given
     account.
@@ -243,12 +253,20 @@ def get_account_request(path, marker, limit):
                    client.
 
     :param limit: maximum number of entries to return
+
+    :param end_marker: end_marker query param; if given, only entries
+                       before this (or, if reverse, after it) are returned.
+
+    :param reverse: if true, entries are returned in reverse order.
     """
     # This RPC method takes one positional argument, which is a JSON object
     # with two fields: the path and the ranges.
-    return jsonrpc_request("Server.RpcGetAccount",
-                           [{"VirtPath": path, "Marker": marker,
-                             "MaxEntries": limit}])
+    args = {"VirtPath": path, "Marker": marker, "MaxEntries": limit}
+    if end_marker:
+        args["EndMarker"] = end_marker
+    if reverse:
+        args["Reverse"] = True
+    return jsonrpc_request("Server.RpcGetAccount", [args])
 
 
 def parse_get_account_response(get_account_response):
@@ -266,7 +284,9 @@ def parse_get_account_response(get_account_response):
         return [ae["Basename"] for ae in account_entries]
 
 
-def get_container_request(path, marker, limit, prefix):
+def get_container_request(path, marker, limit, prefix, delimiter="",
+                          end_marker="", end_marker_inclusive=False,
+                          reverse=False, max_staleness_msec=0):
     """
     Return a JSON-RPC request to get a container listing for a given
     container.
@@ -280,12 +300,64 @@ def get_container_request(path, marker, limit, prefix):
     :param limit: maximum number of entries to return
 
     :param prefix: prefix of all returned entries' filenames
+
+    :param delimiter: delimiter query param; only "/" is supported. If
+                      given, directories beneath the prefix are returned as
+                      subdir entries rather than being descended.
+
+    :param end_marker: end_marker query param; if given, only entries
+                       before this (or, if reverse, after it) are returned.
+
+    :param end_marker_inclusive: if true, an entry named end_marker is
+                                 returned too (unlike with the end_marker
+                                 query param). Not supported with reverse.
+
+    :param reverse: if true, entries are returned in reverse order.
+
+    :param max_staleness_msec: if non-zero, a cached listing up to this many
+                               milliseconds stale may be returned.
     """
     # This RPC method takes one positional argument, which is a JSON object
     # with two fields: the path and the ranges.
-    return jsonrpc_request("Server.RpcGetContainer",
-                           [{"VirtPath": path, "Marker": marker,
-                             "MaxEntries": limit, "Prefix": prefix}])
+    args = {"VirtPath": path, "Marker": marker,
+            "MaxEntries": limit, "Prefix": prefix}
+    if delimiter:
+        args["Delimiter"] = delimiter
+    if end_marker:
+        args["EndMarker"] = end_marker
+        if not end_marker_inclusive:
+            args["EndMarkerExclusive"] = True
+    if reverse:
+        args["Reverse"] = True
+    if max_staleness_msec:
+        args["MaxStalenessMsec"] = max_staleness_msec
+    return jsonrpc_request("Server.RpcGetContainer", [args])
+
+
+def get_container_shards_request(path, max_shards):
+    """
+    Return a JSON-RPC request to partition a container's listing into
+    shards that may be listed concurrently.
+
+    :param path: URL path component for the container, e.g. "/v1/acc/con"
+
+    :param max_shards: maximum number of shards to return
+    """
+    return jsonrpc_request("Server.RpcGetContainerShards",
+                           [{"VirtPath": path, "MaxShards": max_shards}])
+
+
+def parse_get_container_shards_response(get_container_shards_response):
+    """
+    Parse a response from RpcGetContainerShards.
+
+    Returns a list of (marker, end_marker) pairs, in order. Listing each
+    with get_container_request() (with end_marker_inclusive) and
+    concatenating the results yields the container listing. An empty
+    marker means the start of the container; an empty end_marker, its end.
+    """
+    return [(shard["Marker"], shard["EndMarker"])
+            for shard in get_container_shards_response["Shards"]]
 
 
 def parse_get_container_response(get_container_response):
@@ -308,6 +380,10 @@ def parse_get_container_response(get_container_response):
 
         Metadata: object's serialized metadata, if any
 
+        IsSubdir: True if this is a pseudo-directory ("subdir") of a
+                  delimited listing, in which case only Basename (ending
+                  with the delimiter) is meaningful
+
     The container's metadata is just a string. Presumably it's some
     JSON-serialized dictionary that this middleware previously set, but it
     could really be anything.
@@ -381,6 +457,29 @@ def parse_head_response(head_response):
             head_response["InodeNumber"], head_response["NumWrites"])
 
 
+def parse_etag(response):
+    """
+    Parse the ETag from a response from RpcHead or RpcGetObject.
+
+    Returns the (hex-encoded) hash of the file's contents, or None if
+    proxyfsd didn't compute one (e.g. the file is too large to hash, or
+    proxyfsd predates ETag tracking).
+    """
+    return response.get("ETag") or None
+
+
+def parse_content_type(response):
+    """
+    Parse the detected Content-Type from a response from RpcHead or
+    RpcGetObject.
+
+    Returns the Content-Type proxyfsd detected for a file created other
+    than by an object PUT, or None if it detected none (e.g. detection is
+    disabled, or proxyfsd predates Content-Type detection).
+    """
+    return response.get("ContentType") or None
+
+
 def delete_request(path):
     """
     Return a JSON-RPC request to delete a file or directory.
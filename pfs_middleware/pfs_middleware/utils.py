@@ -29,6 +29,7 @@ ENV_BIMODAL_CHECKER = 'pfs.bimodal_checker'
 class RpcError(Exception):
     def __init__(self, errno, *a, **kw):
         self.errno = errno
+        self.path_failure = kw.pop("path_failure", None)
         super(RpcError, self).__init__(*a, **kw)
 
 
@@ -63,7 +64,7 @@ def parse_path(path):
     return segs
 
 
-PFS_ERRNO_RE = re.compile("^errno: (\d+)$")
+PFS_ERRNO_RE = re.compile("^errno: (\d+)(?: pathFailure: (\{.*\}))?$")
 
 
 def extract_errno(errstr):
@@ -77,6 +78,22 @@ def extract_errno(errstr):
         return int(m.group(1))
 
 
+def extract_path_failure(errstr):
+    """
+    Given an error response from a proxyfs RPC, extracts the description
+    of why the RPC's path could not be resolved (a dictionary with keys
+    "Reason", "HTTPStatus", "ParentPath", "Segment", and "Remaining"), or
+    None if the error doesn't have one.
+    """
+    # Such an error response looks like 'errno: 20 pathFailure: {...}'
+    m = re.match(PFS_ERRNO_RE, errstr)
+    if m and m.group(2):
+        try:
+            return json.loads(m.group(2))
+        except ValueError:
+            return None
+
+
 class JsonRpcClient(object):
     def __init__(self, addrinfo):
         self.addrinfo = addrinfo
@@ -137,5 +154,5 @@ class JsonRpcClient(object):
                     errno = extract_errno(errstr)
                     raise RpcError(errno, "Error in %s: %s" % (
                         rpc_request.get("method", "<unknown method>"),
-                        errstr))
+                        errstr), path_failure=extract_path_failure(errstr))
                 return response
@@ -253,6 +253,14 @@ class TestAccountGet(BaseMiddlewareTest):
         # relevant to what we're testing here
         self.assertEqual(self.fake_rpc.calls[1][1][0]['Marker'], 'mk')
 
+    def test_end_marker_and_reverse(self):
+        req = swob.Request.blank("/v1/AUTH_test?end_marker=em&reverse=on")
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(2, len(self.fake_rpc.calls))
+        self.assertEqual(self.fake_rpc.calls[1][1][0]['EndMarker'], 'em')
+        self.assertTrue(self.fake_rpc.calls[1][1][0]['Reverse'])
+
     def test_limit(self):
         req = swob.Request.blank("/v1/AUTH_test?limit=101")
         status, headers, body = self.call_pfs(req)
@@ -817,6 +825,23 @@ class TestObjectGet(BaseMiddlewareTest):
 
         self.assertEqual(status, '416 Requested Range Not Satisfiable')
 
+    def test_GET_range_unsatisfiable_error(self):
+        def mock_RpcGetObject(get_object_req):
+            self.assertEqual(get_object_req['ReadEntsIn'],
+                             [{"Offset": 4000, "Len": None}])
+            return {
+                "error": "errno: 6",  # ENXIO
+                "result": None}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/elements',
+                                 headers={"Range": "bytes=4000-"})
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+
+        self.assertEqual(status, '416 Requested Range Not Satisfiable')
+
     def test_GET_multiple_ranges(self):
         self.app.register(
             'GET', '/v1/AUTH_test/InternalContainerName/0000000000000001',
@@ -959,6 +984,71 @@ class TestObjectGet(BaseMiddlewareTest):
         status, headers, body = self.call_pfs(req)
         self.assertEqual(status, '404 Not Found')
 
+    def test_GET_file_as_dir_path_failure(self):
+        # With a path failure, a file in the middle of the path is a
+        # conflict rather than something that merely doesn't exist.
+        def mock_RpcGetObject(get_object_req):
+            self.assertEqual(get_object_req['VirtPath'],
+                             "/v1/AUTH_test/c/thing.txt/kitten.png")
+
+            return {
+                "error": ('errno: 20 pathFailure: {"Reason":"NotDir",'
+                          '"HTTPStatus":409,"ParentPath":"c",'
+                          '"Segment":"thing.txt","Remaining":1}'),
+                "result": None}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/thing.txt/kitten.png')
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '409 Conflict')
+        self.assertEqual(
+            body, "Path element c/thing.txt is a file, not a directory")
+
+    def test_GET_missing_dir_path_failure(self):
+        def mock_RpcGetObject(get_object_req):
+            return {
+                "error": ('errno: 2 pathFailure: {"Reason":"NotFound",'
+                          '"HTTPStatus":404,"ParentPath":"c",'
+                          '"Segment":"d","Remaining":1}'),
+                "result": None}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/d/kitten.png')
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '404 Not Found')
+        self.assertEqual(body, "Path element c/d not found")
+
+    def test_GET_symlink_loop_path_failure(self):
+        def mock_RpcGetObject(get_object_req):
+            return {
+                "error": ('errno: 40 pathFailure: {"Reason":"SymlinkLoop",'
+                          '"HTTPStatus":409,"ParentPath":"c",'
+                          '"Segment":"loop","Remaining":0}'),
+                "result": None}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/loop')
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '409 Conflict')
+        self.assertEqual(body, "Too many symlinks while resolving c/loop")
+
+    def test_GET_internal_path_failure(self):
+        def mock_RpcGetObject(get_object_req):
+            return {
+                "error": ('errno: 5 pathFailure: {"Reason":"Internal",'
+                          '"HTTPStatus":500,"ParentPath":"c",'
+                          '"Segment":"o","Remaining":0}'),
+                "result": None}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/o')
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '500 Internal Error')
+
     def test_GET_weird_error(self):
         def mock_RpcGetObject(get_object_req):
             self.assertEqual(get_object_req['VirtPath'],
@@ -1075,6 +1165,37 @@ class TestObjectGet(BaseMiddlewareTest):
         self.assertEqual(status, '200 OK')
         self.assertEqual(headers["Etag"], "25152b9f7ca24b61eec895be4e89a950")
 
+    def test_content_etag(self):
+        self.app.register(
+            'GET', '/v1/AUTH_test/InternalContainerName/0000000000000456',
+            200, {}, "stuff stuff stuff")
+
+        def mock_RpcGetObject(_):
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": "",
+                    "ModificationTime": 1506039770222591000,
+                    "FileSize": 17,
+                    "IsDir": False,
+                    "InodeNumber": 1433230,
+                    "NumWrites": 4,
+                    "LeaseId": "leaseid",
+                    "ETag": "a5d3a8c8bd1eea6fa3ef4a0c40f5b1a6",
+                    "ReadEntsOut": [{
+                        "ObjectPath": ("/v1/AUTH_test/InternalContainer"
+                                       "Name/0000000000000456"),
+                        "Offset": 0,
+                        "Length": 17}]}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+
+        req = swob.Request.blank("/v1/AUTH_test/c/an-object.png")
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(headers["Etag"], "a5d3a8c8bd1eea6fa3ef4a0c40f5b1a6")
+
     def test_lease_maintenance(self):
         self.app.register(
             'GET', '/v1/AUTH_test/InternalContainerName/0000000000000456',
@@ -1537,6 +1658,60 @@ class TestContainerGet(BaseMiddlewareTest):
         self.assertEqual(rpc_method, "Server.RpcGetContainer")
         self.assertEqual(rpc_args[0]["Prefix"], "cow")
 
+    def test_end_marker(self):
+        req = swob.Request.blank(
+            '/v1/AUTH_test/a-container?end_marker=images/cherimoya.png')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        rpc_method, rpc_args = self.fake_rpc.calls[1]
+        self.assertEqual(rpc_method, "Server.RpcGetContainer")
+        self.assertEqual(rpc_args[0]["EndMarker"], "images/cherimoya.png")
+        self.assertTrue(rpc_args[0]["EndMarkerExclusive"])
+        self.assertNotIn("Reverse", rpc_args[0])
+        # the listing is pruned by ProxyFS, so no more than the limit
+        self.assertEqual(rpc_args[0]["MaxEntries"], 6543)
+
+    def test_reverse(self):
+        req = swob.Request.blank(
+            '/v1/AUTH_test/a-container?reverse=true&marker=images/d')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        rpc_method, rpc_args = self.fake_rpc.calls[1]
+        self.assertEqual(rpc_method, "Server.RpcGetContainer")
+        self.assertEqual(rpc_args[0]["Marker"], "images/d")
+        self.assertTrue(rpc_args[0]["Reverse"])
+
+        req = swob.Request.blank(
+            '/v1/AUTH_test/a-container?reverse=false')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertNotIn("Reverse", self.fake_rpc.calls[-1][1][0])
+
+    def test_max_staleness(self):
+        req = swob.Request.blank(
+            '/v1/AUTH_test/a-container',
+            headers={"ProxyFS-Listing-Max-Staleness": "2.5"})
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        rpc_method, rpc_args = self.fake_rpc.calls[1]
+        self.assertEqual(rpc_method, "Server.RpcGetContainer")
+        self.assertEqual(rpc_args[0]["MaxStalenessMsec"], 2500)
+
+    def test_max_staleness_default(self):
+        for headers in ({}, {"ProxyFS-Listing-Max-Staleness": "bogus"},
+                        {"ProxyFS-Listing-Max-Staleness": "-1"}):
+            req = swob.Request.blank('/v1/AUTH_test/a-container',
+                                     headers=headers)
+            status, _, _ = self.call_pfs(req)
+            self.assertEqual(status, '200 OK')
+
+            rpc_method, rpc_args = self.fake_rpc.calls[-1]
+            self.assertEqual(rpc_method, "Server.RpcGetContainer")
+            self.assertNotIn("MaxStalenessMsec", rpc_args[0])
+
     def test_default_limit(self):
         req = swob.Request.blank('/v1/AUTH_test/a-container')
         status, _, _ = self.call_pfs(req)
@@ -1650,6 +1825,215 @@ class TestContainerGet(BaseMiddlewareTest):
         status, _, _ = self.call_pfs(req)
         self.assertEqual(status, '500 Internal Error')
 
+    def test_busy(self):
+        def mock_RpcGetContainer_busy(get_container_req):
+            return {"error": "errno: 16", "result": None}  # EBUSY
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetContainer", mock_RpcGetContainer_busy)
+
+        req = swob.Request.blank('/v1/AUTH_test/a-container')
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, '503 Service Unavailable')
+
+
+class TestContainerGetDelimited(BaseMiddlewareTest):
+    def setUp(self):
+        super(TestContainerGetDelimited, self).setUp()
+
+        def mock_RpcGetContainer(get_container_req):
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": "",
+                    "ModificationTime": 1510790796076041000,
+                    "ContainerEntries": [{
+                        "Basename": "images",
+                        "FileSize": 0,
+                        "ModificationTime": 1471915816359209849,
+                        "IsDir": True,
+                        "InodeNumber": 2489682,
+                        "NumWrites": 0,
+                        "Metadata": "",
+                    }, {
+                        "Basename": "images/",
+                        "FileSize": 0,
+                        "ModificationTime": 0,
+                        "IsDir": True,
+                        "InodeNumber": 2489682,
+                        "NumWrites": 0,
+                        "Metadata": "",
+                        "IsSubdir": True,
+                    }]}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetContainer", mock_RpcGetContainer)
+
+    def test_delimiter_passed(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?delimiter=/')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "images\nimages/\n")
+
+        rpc_method, rpc_args = self.fake_rpc.calls[1]
+        self.assertEqual(rpc_method, "Server.RpcGetContainer")
+        self.assertEqual(rpc_args[0]["Delimiter"], "/")
+
+    def test_unsupported_delimiter_ignored(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?delimiter=-')
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        rpc_method, rpc_args = self.fake_rpc.calls[1]
+        self.assertEqual(rpc_method, "Server.RpcGetContainer")
+        self.assertNotIn("Delimiter", rpc_args[0])
+
+    def test_json(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?delimiter=/',
+                                 headers={"Accept": "application/json"})
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        resp_data = json.loads(body)
+        self.assertEqual(len(resp_data), 2)
+        self.assertEqual(resp_data[0]["name"], "images")
+        self.assertEqual(resp_data[1], {"subdir": "images/"})
+
+    def test_xml(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?delimiter=/',
+                                 headers={"Accept": "application/xml"})
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        root_node = ElementTree.fromstring(body)
+        children = list(root_node)
+        self.assertEqual(len(children), 2)
+        self.assertEqual(children[0].tag, 'object')
+        self.assertEqual(children[1].tag, 'subdir')
+        self.assertEqual(children[1].attrib["name"], 'images/')
+        self.assertEqual(children[1].find('name').text, 'images/')
+
+
+class TestContainerGetSharded(BaseMiddlewareTest):
+    names = ["a", "a/1", "a/2", "b", "c", "c/1", "d"]
+
+    def setUp(self):
+        super(TestContainerGetSharded, self).setUp()
+        self.pfs.listing_shards = 3
+
+        def mock_RpcGetContainerShards(get_container_shards_req):
+            self.assertEqual(get_container_shards_req["MaxShards"], 3)
+            return {
+                "error": None,
+                "result": {"Shards": [
+                    {"Marker": "", "EndMarker": "a"},
+                    {"Marker": "a", "EndMarker": "c"},
+                    {"Marker": "c", "EndMarker": ""}]}}
+
+        def mock_RpcGetContainer(get_container_req):
+            marker = get_container_req["Marker"]
+            end_marker = get_container_req.get("EndMarker", "")
+            end_marker_exclusive = get_container_req.get(
+                "EndMarkerExclusive", False)
+            names = [name for name in self.names
+                     if name > marker and
+                     (not end_marker or name < end_marker or
+                      (name == end_marker and not end_marker_exclusive))]
+            if get_container_req.get("Reverse"):
+                names = [name for name in reversed(self.names)
+                         if (not marker or name < marker) and
+                         (not end_marker or name > end_marker)]
+            names = names[:get_container_req["MaxEntries"]]
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": "",
+                    "ModificationTime": 1510790796076041000,
+                    "ContainerEntries": [{
+                        "Basename": name,
+                        "FileSize": 0,
+                        "ModificationTime": 1471915816359209849,
+                        "IsDir": False,
+                        "InodeNumber": 1234,
+                        "NumWrites": 0,
+                        "Metadata": "",
+                    } for name in names]}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetContainerShards", mock_RpcGetContainerShards)
+        self.fake_rpc.register_handler(
+            "Server.RpcGetContainer", mock_RpcGetContainer)
+
+    def _get_container_calls(self):
+        return [rpc_args[0] for rpc_method, rpc_args in self.fake_rpc.calls
+                if rpc_method == "Server.RpcGetContainer"]
+
+    def test_shards_aggregated_in_order(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "".join(name + "\n" for name in self.names))
+        self.assertEqual(len(self._get_container_calls()), 3)
+
+    def test_limit(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?limit=4')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "a\na/1\na/2\nb\n")
+
+    def test_marker_skips_shards(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?marker=b')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "c\nc/1\nd\n")
+
+        calls = self._get_container_calls()
+        self.assertEqual([(c["Marker"], c.get("EndMarker", ""))
+                          for c in calls], [("b", "c"), ("c", "")])
+
+    def test_end_marker_skips_shards(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?end_marker=a/2')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "a\na/1\n")
+
+        calls = self._get_container_calls()
+        self.assertEqual([(c["Marker"], c.get("EndMarker", ""),
+                           c.get("EndMarkerExclusive", False))
+                          for c in calls],
+                         [("", "a", False), ("a", "a/2", True)])
+
+    def test_end_marker_at_shard_boundary(self):
+        req = swob.Request.blank('/v1/AUTH_test/a-container?end_marker=c')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "a\na/1\na/2\nb\n")
+
+    def test_reverse_not_sharded(self):
+        req = swob.Request.blank(
+            '/v1/AUTH_test/a-container?reverse=1&marker=c&end_marker=a/1')
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "b\na/2\n")
+
+        self.assertNotIn("Server.RpcGetContainerShards",
+                         [rpc_method for rpc_method, _ in self.fake_rpc.calls])
+        calls = self._get_container_calls()
+        self.assertEqual(len(calls), 1)
+        self.assertTrue(calls[0]["Reverse"])
+
+    def test_max_staleness_not_sharded(self):
+        req = swob.Request.blank(
+            '/v1/AUTH_test/a-container',
+            headers={"ProxyFS-Listing-Max-Staleness": "10"})
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(body, "".join(name + "\n" for name in self.names))
+
+        calls = self._get_container_calls()
+        self.assertEqual(len(calls), 1)
+        self.assertEqual(calls[0]["MaxStalenessMsec"], 10000)
+
 
 class TestContainerPost(BaseMiddlewareTest):
     def test_missing_container(self):
@@ -2130,6 +2514,10 @@ class TestObjectPut(BaseMiddlewareTest):
         self.assertEqual(
             "big-txid-002", put_calls[2][2]["X-Trans-Id"])  # 3rd PUT
 
+        # ...and that proxyfsd was told the txid for its access log
+        for method, args in rpc_calls[1:]:
+            self.assertEqual(args[0]["TransId"], "big-txid")
+
         # If we sent the original Content-Length, the first PUT would fail.
         # At some point, we should send the correct Content-Length value
         # when we can compute it, but for now, we just send nothing.
@@ -2291,6 +2679,190 @@ class TestObjectPut(BaseMiddlewareTest):
         status, headers, body = self.call_pfs(req)
         self.assertEqual(status, '409 Conflict')
 
+    def _register_object_head(self, object_result):
+        # Container HEADs succeed as in setUp(); the object HEAD gets
+        # object_result (None meaning the object doesn't exist)
+        def mock_RpcHead(head_req):
+            if head_req["VirtPath"] == "/v1/AUTH_test/a-container":
+                return {
+                    "error": None,
+                    "result": {
+                        "Metadata": "",
+                        "ModificationTime": 14792389930244718933,
+                        "FileSize": 0,
+                        "IsDir": True,
+                        "InodeNumber": 1828,
+                        "NumWrites": 893,
+                    }}
+            elif object_result is None:
+                return {"error": "errno: 2", "result": None}
+            else:
+                return {"error": None, "result": object_result}
+
+        self.fake_rpc.register_handler("Server.RpcHead", mock_RpcHead)
+
+    def _conditional_put(self, headers):
+        wsgi_input = StringIO("lithographical-unsurpassably")
+        headers = dict(headers)
+        headers["Content-Length"] = str(len(wsgi_input.getvalue()))
+        req = swob.Request.blank("/v1/AUTH_test/a-container/an-object",
+                                 environ={"REQUEST_METHOD": "PUT",
+                                          "wsgi.input": wsgi_input},
+                                 headers=headers)
+        return self.call_pfs(req)
+
+    def _put_complete_calls(self):
+        return [args for method, args in self.fake_rpc.calls
+                if method == "Server.RpcPutComplete"]
+
+    def test_if_none_match(self):
+        self._register_object_head(None)
+
+        status, _, _ = self._conditional_put({"If-None-Match": "*"})
+        self.assertEqual(status, '201 Created')
+
+        put_complete_calls = self._put_complete_calls()
+        self.assertEqual(len(put_complete_calls), 1)
+        self.assertTrue(put_complete_calls[0][0]["IfNoneMatch"])
+        self.assertNotIn("IfMatch", put_complete_calls[0][0])
+
+    def test_if_none_match_exists(self):
+        self._register_object_head({
+            "Metadata": "",
+            "ModificationTime": 1481311245635845000,
+            "FileSize": 28,
+            "IsDir": False,
+            "InodeNumber": 4116394,
+            "NumWrites": 1})
+
+        status, _, _ = self._conditional_put({"If-None-Match": "*"})
+        self.assertEqual(status, '412 Precondition Failed')
+        self.assertEqual(self._put_complete_calls(), [])
+
+    def test_if_none_match_not_star(self):
+        status, _, _ = self._conditional_put({"If-None-Match": "abc123"})
+        self.assertEqual(status, '400 Bad Request')
+        self.assertEqual(self._put_complete_calls(), [])
+
+    def test_if_match(self):
+        self._register_object_head({
+            "Metadata": "",
+            "ModificationTime": 1481311245635845000,
+            "FileSize": 28,
+            "IsDir": False,
+            "InodeNumber": 4116394,
+            "NumWrites": 3,
+            "ETag": "0f343b0931126a20f133d67c2b018a3b"})
+
+        status, _, _ = self._conditional_put(
+            {"If-Match": '"0f343b0931126a20f133d67c2b018a3b"'})
+        self.assertEqual(status, '201 Created')
+
+        put_complete_calls = self._put_complete_calls()
+        self.assertEqual(len(put_complete_calls), 1)
+        self.assertTrue(put_complete_calls[0][0]["IfMatch"])
+        self.assertEqual(put_complete_calls[0][0]["IfMatchInodeNumber"],
+                         4116394)
+        self.assertEqual(put_complete_calls[0][0]["IfMatchNumWrites"], 3)
+
+    def test_if_match_star(self):
+        self._register_object_head({
+            "Metadata": "",
+            "ModificationTime": 1481311245635845000,
+            "FileSize": 28,
+            "IsDir": False,
+            "InodeNumber": 4116394,
+            "NumWrites": 3})
+
+        status, _, _ = self._conditional_put({"If-Match": "*"})
+        self.assertEqual(status, '201 Created')
+
+        put_complete_calls = self._put_complete_calls()
+        self.assertEqual(len(put_complete_calls), 1)
+        self.assertTrue(put_complete_calls[0][0]["IfMatch"])
+        self.assertNotIn("IfMatchInodeNumber", put_complete_calls[0][0])
+
+    def test_if_match_mismatch(self):
+        self._register_object_head({
+            "Metadata": "",
+            "ModificationTime": 1481311245635845000,
+            "FileSize": 28,
+            "IsDir": False,
+            "InodeNumber": 4116394,
+            "NumWrites": 3,
+            "ETag": "0f343b0931126a20f133d67c2b018a3b"})
+
+        status, _, _ = self._conditional_put({"If-Match": '"abc123"'})
+        self.assertEqual(status, '412 Precondition Failed')
+        self.assertEqual(self._put_complete_calls(), [])
+
+    def test_if_match_missing(self):
+        self._register_object_head(None)
+
+        status, _, _ = self._conditional_put({"If-Match": "*"})
+        self.assertEqual(status, '412 Precondition Failed')
+        self.assertEqual(self._put_complete_calls(), [])
+
+    def test_if_match_modified_during_upload(self):
+        # The object matched when the PUT began, but was modified before
+        # the upload completed
+        self._register_object_head({
+            "Metadata": "",
+            "ModificationTime": 1481311245635845000,
+            "FileSize": 28,
+            "IsDir": False,
+            "InodeNumber": 4116394,
+            "NumWrites": 3})
+
+        def mock_RpcPutComplete_precondition_failed(put_complete_req):
+            return {
+                "error": "errno: 1004",
+                "result": None}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcPutComplete", mock_RpcPutComplete_precondition_failed)
+
+        status, _, _ = self._conditional_put({"If-Match": "*"})
+        self.assertEqual(status, '412 Precondition Failed')
+
+    def test_directory_over_file(self):
+        # A directory marker never replaces a file; ProxyFS refuses with
+        # FileExistsError, which the middleware turns into a 409 Conflict.
+        def mock_RpcMiddlewareMkdir_exists(middleware_mkdir_req):
+            return {
+                "error": "errno: 17",
+                "result": None}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcMiddlewareMkdir", mock_RpcMiddlewareMkdir_exists)
+
+        req = swob.Request.blank(
+            "/v1/AUTH_test/a-container/a-file",
+            environ={"REQUEST_METHOD": "PUT"},
+            headers={"Content-Length": 0,
+                     "Content-Type": "application/directory"},
+            body="")
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '409 Conflict')
+
+    def test_directory_beneath_file(self):
+        def mock_RpcMiddlewareMkdir_notdir(middleware_mkdir_req):
+            return {
+                "error": "errno: 20",
+                "result": None}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcMiddlewareMkdir", mock_RpcMiddlewareMkdir_notdir)
+
+        req = swob.Request.blank(
+            "/v1/AUTH_test/a-container/a-file/a-dir",
+            environ={"REQUEST_METHOD": "PUT"},
+            headers={"Content-Length": 0,
+                     "Content-Type": "application/directory"},
+            body="")
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '409 Conflict')
+
     def test_stripping_bad_headers(self):
         # Someday, we'll have to figure out how to expire objects in
         # proxyfs. For now, though, we remove X-Delete-At and X-Delete-After
@@ -2601,6 +3173,7 @@ class TestObjectHead(BaseMiddlewareTest):
         super(TestObjectHead, self).setUp()
 
         self.serialized_object_metadata = ""
+        self.detected_content_type = ""
 
         # All these tests run against the same object.
         def mock_RpcHead(head_object_req):
@@ -2621,6 +3194,7 @@ class TestObjectHead(BaseMiddlewareTest):
                     "IsDir": False,
                     "InodeNumber": 4591,
                     "NumWrites": 874,
+                    "ContentType": self.detected_content_type,
                 }}
 
         self.fake_rpc.register_handler(
@@ -2669,6 +3243,25 @@ class TestObjectHead(BaseMiddlewareTest):
         self.assertEqual(status, '200 OK')
         self.assertEqual(headers["Content-Type"], "Pegasus/inartistic")
 
+    def test_detected_content_type(self):
+        self.detected_content_type = "text/plain; charset=utf-8"
+
+        req = swob.Request.blank("/v1/AUTH_test/c/an-object.png",
+                                 environ={"REQUEST_METHOD": "HEAD"})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(headers["Content-Type"], "text/plain; charset=utf-8")
+
+        # Metadata set via the middleware still takes precedence
+        self.serialized_object_metadata = json.dumps({
+            "Content-Type": "Pegasus/inartistic"})
+
+        req = swob.Request.blank("/v1/AUTH_test/c/an-object.png",
+                                 environ={"REQUEST_METHOD": "HEAD"})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(headers["Content-Type"], "Pegasus/inartistic")
+
     def test_bogus_meta(self):
         self.serialized_object_metadata = "{[{[{[{[{[[(((!"
 
@@ -2769,6 +3362,36 @@ class TestObjectHead(BaseMiddlewareTest):
         self.assertEqual(status, '200 OK')
         self.assertEqual(headers["Etag"], "b61d068208b52f4acbd618860d30faae")
 
+    def test_content_etag(self):
+        # the original MD5 is stale (the file has since been written via
+        # SMB/FUSE), but proxyfsd has hashed the file's current contents
+        self.serialized_object_metadata = json.dumps({
+            mware.ORIGINAL_MD5_HEADER: "1:b61d068208b52f4acbd618860d30faae",
+        })
+
+        def mock_RpcHead(head_object_req):
+            md = base64.b64encode(self.serialized_object_metadata)
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": md,
+                    "ModificationTime": 1506039770222591000,
+                    "FileSize": 3397331,
+                    "IsDir": False,
+                    "InodeNumber": 1433230,
+                    "NumWrites": 2,
+                    "ETag": "0c8e4e0e6a0bb5c4d6e2c0a3b2e3ee69",
+                }}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcHead", mock_RpcHead)
+
+        req = swob.Request.blank("/v1/AUTH_test/c/an-object.png",
+                                 environ={"REQUEST_METHOD": "HEAD"})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(headers["Etag"], "0c8e4e0e6a0bb5c4d6e2c0a3b2e3ee69")
+
 
 class TestObjectHeadDir(BaseMiddlewareTest):
     def test_dir(self):
@@ -22,6 +22,18 @@ class TestHelperFunctions(unittest.TestCase):
         self.assertEqual(2, utils.extract_errno("errno: 2"))
         self.assertEqual(17, utils.extract_errno("errno: 17"))
         self.assertEqual(None, utils.extract_errno("it broke"))
+        self.assertEqual(20, utils.extract_errno(
+            'errno: 20 pathFailure: {"Reason":"NotDir"}'))
+
+    def test_extract_path_failure(self):
+        self.assertEqual(None, utils.extract_path_failure("errno: 2"))
+        self.assertEqual(None, utils.extract_path_failure("it broke"))
+        self.assertEqual(
+            {"Reason": "NotDir", "HTTPStatus": 409, "ParentPath": "c/d",
+             "Segment": "f.txt", "Remaining": 1},
+            utils.extract_path_failure(
+                'errno: 20 pathFailure: {"Reason":"NotDir","HTTPStatus":409,'
+                '"ParentPath":"c/d","Segment":"f.txt","Remaining":1}'))
 
     def test_parse_path(self):
         self.assertEqual(
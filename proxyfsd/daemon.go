@@ -267,6 +267,13 @@ func Daemon(confFile string, confStrings []string, signalHandlerIsArmed *bool, e
 
 		if unix.SIGHUP != signalReceived { // signalReceived either SIGINT or SIGTERM... so just exit
 
+			// quiesce & flush the volumes before the defer'ed Down()'s (above) tear things down
+
+			err = fs.Shutdown()
+			if nil != err {
+				logger.Errorf("fs.Shutdown() failed: %v", err)
+			}
+
 			errChan <- nil
 
 			// if the following message doesn't appear in the log,
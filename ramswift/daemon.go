@@ -651,10 +651,20 @@ func doGet(responseWriter http.ResponseWriter, request *http.Request) {
 								if nil != err {
 									panic(err)
 								}
-								if 0 == numObjects {
+								firstObjectIndex := 0
+								marker := request.URL.Query().Get("marker")
+								if "" != marker {
+									// List only those SwiftObjects following marker
+									markerIndex, _, err := swiftContainer.swiftObjectTree.BisectLeft(marker)
+									if nil != err {
+										panic(err)
+									}
+									firstObjectIndex = markerIndex + 1
+								}
+								if firstObjectIndex >= numObjects {
 									responseWriter.WriteHeader(http.StatusNoContent)
 								} else {
-									for objectIndex := 0; objectIndex < numObjects; objectIndex++ {
+									for objectIndex := firstObjectIndex; objectIndex < numObjects; objectIndex++ {
 										swiftObjectNameAsKey, _, _, err := swiftContainer.swiftObjectTree.GetByIndex(objectIndex)
 										if nil != err {
 											panic(err)
@@ -309,6 +309,12 @@ func IncrementOperations(statName *string) {
 	go incrementOperations(statName)
 }
 
+// IncrementBy sends an increment of incBy to the named stat (e.g. a count of bytes).
+func IncrementBy(statName *string, incBy uint64) {
+	// Do this in a goroutine since channel operations are suprisingly expensive due to locking underneath
+	go incrementSomething(statName, incBy)
+}
+
 // IncrementOperationsAndBytes sends an increment of .operations and .bytes to statsd.
 func IncrementOperationsAndBytes(stat MultipleStat, bytes uint64) {
 	// Do this in a goroutine since channel operations are suprisingly expensive due to locking underneath
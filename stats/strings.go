@@ -11,42 +11,139 @@ package stats
 //       that a change there is not required as well.
 var (
 	FsBasenameValidateOps             = "proxyfs.fs.statName_validate.operations"
+	FsBasenameInvalidOps              = "proxyfs.fs.basename.invalid.operations"
 	FsFullpathValidateOps             = "proxyfs.fs.fullpath_validate.operations"
 	FsVolumeValidateOps               = "proxyfs.fs.volume_validate.operations"
 	FsMountOps                        = "proxyfs.fs.mount.operations"
+	FsUnmountOps                      = "proxyfs.fs.unmount.operations"
+	FsUnmountBusyOps                  = "proxyfs.fs.unmount.busy.operations"
+	FsShutdownOps                     = "proxyfs.fs.shutdown.operations"
+	FsShutdownDrainTimeoutOps         = "proxyfs.fs.shutdown.drain.timeout.operations"
+	FsMountAuthDeniedOps              = "proxyfs.fs.mount.auth_denied.operations"
 	FsRenameOps                       = "proxyfs.fs.rename.operations"
 	FsStatvfsOps                      = "proxyfs.fs.statvfs.operations"
+	FsStatvfsAtOps                    = "proxyfs.fs.statvfs.at.operations"
 	FsPathLookupOps                   = "proxyfs.fs.path_lookup.operations"
+	FsResolvePathAtOps                = "proxyfs.fs.resolve_path_at.operations"
+	FsUnlinkAtOps                     = "proxyfs.fs.unlink_at.operations"
+	FsRenameAtOps                     = "proxyfs.fs.rename_at.operations"
 	FsCreateOps                       = "proxyfs.fs.create.operations"
+	FsCreateUnlinkedOps               = "proxyfs.fs.create_unlinked.operations"
 	FsFlushOps                        = "proxyfs.fs.flush.operations"
+	FsFlushDirOps                     = "proxyfs.fs.flush.dir.operations"
+	FsOperationCappedOps              = "proxyfs.fs.operation.capped.operations"
+	FsGetLimitsOps                    = "proxyfs.fs.get.limits.operations"
+	FsSetLimitsOps                    = "proxyfs.fs.set.limits.operations"
+	FsReclaimAnalyzeOps               = "proxyfs.fs.reclaim.analyze.operations"
+	FsReclaimOps                      = "proxyfs.fs.reclaim.operations"
+	FsUpgradeDirEntryTypesOps         = "proxyfs.fs.upgrade_dir_entry_types.operations"
+	FsAdoptOps                        = "proxyfs.fs.adopt.operations"
+	FsVerifyVolumeOps                 = "proxyfs.fs.volume_verify.operations"
+	FsSnapshotCreateOps               = "proxyfs.fs.snapshot.create.operations"
+	FsSnapshotDeleteOps               = "proxyfs.fs.snapshot.delete.operations"
+	FsSnapshotListOps                 = "proxyfs.fs.snapshot.list.operations"
+	FsTrashOps                        = "proxyfs.fs.trash.operations"
+	FsTrashListOps                    = "proxyfs.fs.trash.list.operations"
+	FsTrashRestoreOps                 = "proxyfs.fs.trash.restore.operations"
+	FsTrashPurgeOps                   = "proxyfs.fs.trash.purge.operations"
+	FsTrashAutoPurgeOps               = "proxyfs.fs.trash.auto_purge.operations"
+	FsVersionOps                      = "proxyfs.fs.version.operations"
+	FsVersionExpireOps                = "proxyfs.fs.version.expire.operations"
+	FsVersionReapOps                  = "proxyfs.fs.version.reap.operations"
+	FsVersionListOps                  = "proxyfs.fs.version.list.operations"
+	FsVersionOpenOps                  = "proxyfs.fs.version.open.operations"
+	FsRetentionDeniedOps              = "proxyfs.fs.retention.denied.operations"
+	FsRetentionCommitOps              = "proxyfs.fs.retention.commit.operations"
+	FsLegalHoldOps                    = "proxyfs.fs.legal_hold.operations"
+	FsMwSetContainerRetentionOps      = "proxyfs.fs.middleware_set_container_retention.operations"
+	FsInodeFlagsDeniedOps             = "proxyfs.fs.inode_flags.denied.operations"
+	FsSetInodeFlagsOps                = "proxyfs.fs.set_inode_flags.operations"
+	FsGetNFS4ACLOps                   = "proxyfs.fs.get_nfs4acl.operations"
+	FsSetNFS4ACLOps                   = "proxyfs.fs.set_nfs4acl.operations"
+	FsDentryCacheHitOps               = "proxyfs.fs.dentry_cache.hit.operations"
+	FsDentryCacheMissOps              = "proxyfs.fs.dentry_cache.miss.operations"
+	FsAttrCacheHitOps                 = "proxyfs.fs.attr_cache.hit.operations"
+	FsAttrCacheMissOps                = "proxyfs.fs.attr_cache.miss.operations"
+	FsInodeHistoryFetchOps            = "proxyfs.fs.inode_history_fetch.operations"
+	FsLockRetryOps                    = "proxyfs.fs.lock_retry.operations"
+	FsLockRetrySuccessOps             = "proxyfs.fs.lock_retry_success.operations"
+	FsLockRetryExhaustedOps           = "proxyfs.fs.lock_retry_exhausted.operations"
+	FsHeavyOpAdmittedOps              = "proxyfs.fs.heavy_op_admitted.operations"
+	FsHeavyOpQueuedOps                = "proxyfs.fs.heavy_op_queued.operations"
+	FsHeavyOpRejectedOps              = "proxyfs.fs.heavy_op_rejected.operations"
+	FsPutIntentCompleteOps            = "proxyfs.fs.put.intent.complete.operations"
+	FsPutIntentRollbackOps            = "proxyfs.fs.put.intent.rollback.operations"
 	FsGetstatOps                      = "proxyfs.fs.getstat.operations"
 	FsIsdirOps                        = "proxyfs.fs.isdir.operations"
 	FsIsfileOps                       = "proxyfs.fs.isfile.operations"
 	FsIssymlinkOps                    = "proxyfs.fs.issymlink.operations"
+	FsOpenOps                         = "proxyfs.fs.open.operations"
+	FsOpenDurableOps                  = "proxyfs.fs.open.durable.operations"
+	FsDurableHandleStaleOps           = "proxyfs.fs.durable.handle.stale.operations"
+	FsOpenShareViolationOps           = "proxyfs.fs.open.share.violation.operations"
+	FsShareModeDeniedOps              = "proxyfs.fs.share_mode.denied.operations"
+	FsCoherenceBreakOps               = "proxyfs.fs.coherence.break.operations"
+	FsCoherenceDropOps                = "proxyfs.fs.coherence.drop.operations"
+	FsCloseOps                        = "proxyfs.fs.close.operations"
+	FsLeaseAcquireOps                 = "proxyfs.fs.lease.acquire.operations"
+	FsLeaseDowngradeOps               = "proxyfs.fs.lease.downgrade.operations"
+	FsLeaseBreakOps                   = "proxyfs.fs.lease.break.operations"
+	FsLeaseBreakTimeoutOps            = "proxyfs.fs.lease.break.timeout.operations"
+	FsLeaseReclaimOps                 = "proxyfs.fs.lease.reclaim.operations"
 	FsLinkOps                         = "proxyfs.fs.link.operations"
+	FsLinkByInodeOps                  = "proxyfs.fs.link_by_inode.operations"
 	FsLookupOps                       = "proxyfs.fs.lookup.operations"
+	FsLookupMultiOps                  = "proxyfs.fs.lookup_multi.operations"
 	FsMkdirOps                        = "proxyfs.fs.mkdir.operations"
+	FsMknodOps                        = "proxyfs.fs.mknod.operations"
 	FsReadOps                         = "proxyfs.fs.read.operations"
 	FsMwDeleteOps                     = "proxyfs.fs.middleware_delete.operations"
+	FsMwDeleteMultiOps                = "proxyfs.fs.middleware_delete_multi.operations"
 	FsMwPostOps                       = "proxyfs.fs.middleware_post.operations"
+	FsMwPostAccountOps                = "proxyfs.fs.middleware_post_account.operations"
 	FsMwHeadResponseOps               = "proxyfs.fs.middleware_head_response.operations"
+	FsMwHeadAccountOps                = "proxyfs.fs.middleware_head_account.operations"
+	FsMwHeadMultipleOps               = "proxyfs.fs.middleware_head_multiple.operations"
+	FsContinuationRelocateOps         = "proxyfs.fs.continuation.relocate.operations"
+	FsContinuationResumeByNameOps     = "proxyfs.fs.continuation.resume_by_name.operations"
 	FsMwPutCompleteOps                = "proxyfs.fs.middleware_put_complete.operations"
+	FsMwPutPreconditionFailedOps      = "proxyfs.fs.middleware_put_complete.precondition_failed.operations"
+	FsMwContainerACLDeniedOps         = "proxyfs.fs.middleware_container_acl.denied.operations"
+	FsMwSetContainerACLOps            = "proxyfs.fs.middleware_set_container_acl.operations"
 	FsMwGetAccountOps                 = "proxyfs.fs.middleware_get_account.operations"
 	FsMwGetContainerOps               = "proxyfs.fs.middleware_get_container.operations"
+	FsMwGetContainerDelimitedOps      = "proxyfs.fs.middleware_get_container_delimited.operations"
+	FsMwGetContainerShardsOps         = "proxyfs.fs.middleware_get_container_shards.operations"
+	FsMwGetContainerReverseOps        = "proxyfs.fs.middleware_get_container_reverse.operations"
+	FsMwGetContainerCacheHitOps       = "proxyfs.fs.middleware_get_container_cache_hit.operations"
+	FsMwGetContainerCacheMissOps      = "proxyfs.fs.middleware_get_container_cache_miss.operations"
+	FsTreeDescentDepthLimitOps        = "proxyfs.fs.tree.descent.depth.limit.operations"
+	FsTreeDescentPendingLimitOps      = "proxyfs.fs.tree.descent.pending.limit.operations"
 	FsMwPutContainerOps               = "proxyfs.fs.middleware_put_container.operations"
+	FsMwFreezeContainerOps            = "proxyfs.fs.middleware_freeze_container.operations"
+	FsMwThawContainerOps              = "proxyfs.fs.middleware_thaw_container.operations"
+	FsMwContainerFreezeWaitOps        = "proxyfs.fs.middleware_container_freeze.wait.operations"
+	FsMwContainerFreezeExpiredOps     = "proxyfs.fs.middleware_container_freeze.expired.operations"
+	FsContainerFreezeWaitOps          = "proxyfs.fs.container_freeze.wait.operations"
 	FsMwGetObjOps                     = "proxyfs.fs.middleware_get_object.operations"
 	FsReaddirOps                      = "proxyfs.fs.readdir.operations"
 	FsReaddirOneOps                   = "proxyfs.fs.one_readdir.operations"
 	FsReaddirPlusOps                  = "proxyfs.fs.plus_readdir.operations"
 	FsReaddirOnePlusOps               = "proxyfs.fs.plus_one_readdir.operations"
+	FsReaddirMatchOps                 = "proxyfs.fs.readdir_match.operations"
 	FsSymlinkReadOps                  = "proxyfs.fs.symlink_read.operations"
 	FsSetsizeOps                      = "proxyfs.fs.setsize.operations"
 	FsSetstatOps                      = "proxyfs.fs.setstat.operations"
 	FsSymlinkOps                      = "proxyfs.fs.symlink.operations"
 	FsGetTypeOps                      = "proxyfs.fs.get_type.operations"
 	FsUnlinkOps                       = "proxyfs.fs.unlink.operations"
+	FsUnlinkRetryOps                  = "proxyfs.fs.unlink.retry.operations"
+	FsReleaseUnlinkedOps              = "proxyfs.fs.release_unlinked.operations"
+	FsOrphanReapOps                   = "proxyfs.fs.orphan_reap.operations"
 	FsRmdirOps                        = "proxyfs.fs.rmdir.operations"
 	FsWriteOps                        = "proxyfs.fs.write.operations"
+	FsWritevOps                       = "proxyfs.fs.writev.operations"
+	FsReadIntoOps                     = "proxyfs.fs.readinto.operations"
 	FsValidateOps                     = "proxyfs.fs.validate.operations"
 	FsProvisionObjOps                 = "proxyfs.fs.provision_object.operations"
 	FsAcctToVolumeOps                 = "proxyfs.fs.acct_to_volume.operations"
@@ -55,26 +152,71 @@ var (
 	FsListXattrOps                    = "proxyfs.fs.list_xattr.operations"
 	FsRemoveXattrOps                  = "proxyfs.fs.remove_xattr.operations"
 	FsSetXattrOps                     = "proxyfs.fs.set_xattr.operations"
+	FsSetXattrIfMatchOps              = "proxyfs.fs.set_xattr_if_match.operations"
+	FsSetXattrIfMatchMismatchOps      = "proxyfs.fs.set_xattr_if_match_mismatch.operations"
+	FsGetStreamSizeOps                = "proxyfs.fs.get_stream_size.operations"
+	FsReadStreamOps                   = "proxyfs.fs.read_stream.operations"
+	FsWriteStreamOps                  = "proxyfs.fs.write_stream.operations"
+	FsResizeStreamOps                 = "proxyfs.fs.resize_stream.operations"
 	FsFlockOps                        = "proxyfs.fs.flock.operations"
+	FsPinOps                          = "proxyfs.fs.pin.operations"
+	FsUnpinOps                        = "proxyfs.fs.unpin.operations"
+	FsUsageSampleOps                  = "proxyfs.fs.usage.sample.operations"
+	FsUsageAlertOps                   = "proxyfs.fs.usage.alert.operations"
+	FsETagComputeOps                  = "proxyfs.fs.etag.compute.operations"
+	FsETagPersistOps                  = "proxyfs.fs.etag.persist.operations"
+	FsETagTooLargeOps                 = "proxyfs.fs.etag.too_large.operations"
+	FsContentTypeDetectOps            = "proxyfs.fs.content_type.detect.operations"
+	FsContentTypeSniffOps             = "proxyfs.fs.content_type.sniff.operations"
+	FsSymlinkRefusedOps               = "proxyfs.fs.symlink.refused.operations"
+	FsWatchAddOps                     = "proxyfs.fs.watch.add.operations"
+	FsWatchRemoveOps                  = "proxyfs.fs.watch.remove.operations"
+	FsNotifyEventOps                  = "proxyfs.fs.notify.event.operations"
+	FsNotifyOverflowOps               = "proxyfs.fs.notify.overflow.operations"
+	FsNotifyParentCacheHitOps         = "proxyfs.fs.notify.parent.cache.hit.operations"
+	FsNotifyParentCacheMissOps        = "proxyfs.fs.notify.parent.cache.miss.operations"
+	FsReplaceFenceWaitOps             = "proxyfs.fs.replace.fence.wait.operations"
+	FsReplaceFenceFailOps             = "proxyfs.fs.replace.fence.fail.operations"
+	FsMandatoryLockWaitOps            = "proxyfs.fs.mandatory.lock.wait.operations"
+	FsMandatoryLockFailOps            = "proxyfs.fs.mandatory.lock.fail.operations"
+	FsATimeUpdateOps                  = "proxyfs.fs.atime.update.operations"
+	FsMwSegmentCheckHeadOps           = "proxyfs.fs.middleware.segment.check.head.operations"
+	FsMwSegmentCheckCachedOps         = "proxyfs.fs.middleware.segment.check.cached.operations"
+	FsMwSegmentCheckStaleOps          = "proxyfs.fs.middleware.segment.check.stale.operations"
+	FsReadOnlyDeniedOps               = "proxyfs.fs.readonly.denied.operations"
+	FsNoExecDeniedOps                 = "proxyfs.fs.noexec.denied.operations"
+	FsNoSuidSetIDClearedOps           = "proxyfs.fs.nosuid.setid.cleared.operations"
+	FsRootSquashOps                   = "proxyfs.fs.root.squash.operations"
+	FsStickyDeniedOps                 = "proxyfs.fs.sticky.denied.operations"
+	FsSetGIDInheritOps                = "proxyfs.fs.setgid.inherit.operations"
 	DirCreateOps                      = "proxyfs.inode.directory.create.operations"
 	DirCreateSuccessOps               = "proxyfs.inode.directory.create.success.operations"
 	DirLinkOps                        = "proxyfs.inode.directory.link.operations"
 	DirLinkSuccessOps                 = "proxyfs.inode.directory.link.success.operations"
 	DirUnlinkOps                      = "proxyfs.inode.directory.unlink.operations"
 	DirUnlinkSuccessOps               = "proxyfs.inode.directory.unlink.success.operations"
+	DirUnlinkGenerationSkipOps        = "proxyfs.inode.directory.unlink.generation.skip.operations"
 	DirRenameOps                      = "proxyfs.inode.directory.rename.operations"
 	DirRenameSuccessOps               = "proxyfs.inode.directory.rename.success.operations"
 	DirLookupOps                      = "proxyfs.inode.directory.lookup.operations"
 	DirReaddirOps                     = "proxyfs.inode.directory.readdir.operations"
+	DirFlushOps                       = "proxyfs.inode.directory.flush.operations"
 	DirReadOps                        = "proxyfs.inode.directory.read.operations"
 	DirReadEntries                    = "proxyfs.inode.directory.read.entries"
 	DirReadBytes                      = "proxyfs.inode.directory.read.bytes"
 	FileCreateOps                     = "proxyfs.inode.file.create.operations"
 	FileCreateSuccessOps              = "proxyfs.inode.file.create.success.operations"
+	FileCloneOps                      = "proxyfs.inode.file.clone.operations"
 	FileWritebackHitOps               = "proxyfs.inode.file.writeback.hit.operations"
 	FileWritebackMissOps              = "proxyfs.inode.file.writeback.miss.operations"
 	FileReadcacheHitOps               = "proxyfs.inode.file.readcache.hit.operations"
 	FileReadcacheMissOps              = "proxyfs.inode.file.readcache.miss.operations"
+	FileReadcacheRangedOps            = "proxyfs.inode.file.readcache.ranged.operations"
+	FileReadaheadSequentialOps        = "proxyfs.inode.file.readahead.sequential.operations"
+	FileReadaheadRandomOps            = "proxyfs.inode.file.readahead.random.operations"
+	FileReadaheadWindowGrowOps        = "proxyfs.inode.file.readahead.window.grow.operations"
+	FileReadaheadWindowShrinkOps      = "proxyfs.inode.file.readahead.window.shrink.operations"
+	FileReadaheadPrefetchOps          = "proxyfs.inode.file.readahead.prefetch.operations"
 	FileReadOps                       = "proxyfs.inode.file.read.operations"
 	FileReadOps4K                     = "proxyfs.inode.file.read.operations.size-up-to-4KB"
 	FileReadOps8K                     = "proxyfs.inode.file.read.operations.size-4KB-to-8KB"
@@ -101,6 +243,14 @@ var (
 	FileWriteBytes                    = "proxyfs.inode.file.write.bytes"
 	FileWriteAppended                 = "proxyfs.inode.file.write.appended"
 	FileWriteOverwritten              = "proxyfs.inode.file.write.overwritten"
+	FileWriteStagedOps                = "proxyfs.inode.file.write.staged.operations"
+	FileWritevOps                     = "proxyfs.inode.file.writev.operations"
+	FileReadIntoOps                   = "proxyfs.inode.file.readinto.operations"
+	FileWriteBackSendOps              = "proxyfs.inode.file.write-back.send.operations"
+	FileWriteBackBudgetExceededOps    = "proxyfs.inode.file.write-back.budget-exceeded.operations"
+	FileFragmentationReportOps        = "proxyfs.inode.file.fragmentation-report.operations"
+	FileOptimizeOps                   = "proxyfs.inode.file.optimize.operations"
+	FileAdoptOps                      = "proxyfs.inode.file.adopt.operations"
 	FileWroteOps                      = "proxyfs.inode.file.wrote.operations"
 	FileWroteOps4K                    = "proxyfs.inode.file.wrote.operations.size-up-to-4KB"
 	FileWroteOps8K                    = "proxyfs.inode.file.wrote.operations.size-4KB-to-8KB"
@@ -114,12 +264,25 @@ var (
 	LogSegCreateOps                   = "proxyfs.inode.file.log-segment.create.operations"
 	GcLogSegDeleteOps                 = "proxyfs.inode.garbage-collection.log-segment.delete.operations"
 	GcLogSegOps                       = "proxyfs.inode.garbage-collection.log-segment.operations"
+	GcLogSegSharedOps                 = "proxyfs.inode.garbage-collection.log-segment.shared.operations"
 	DirDestroyOps                     = "proxyfs.inode.directory.destroy.operations"
 	FileDestroyOps                    = "proxyfs.inode.file.destroy.operations"
 	SymlinkDestroyOps                 = "proxyfs.inode.symlink.destroy.operations"
+	SpecialDestroyOps                 = "proxyfs.inode.special.destroy.operations"
+	InodeDestroyGenerationSkipOps     = "proxyfs.inode.destroy.generation.skip.operations"
+	InodeDestroyQueuedOps             = "proxyfs.inode.destroy.queued.operations" // backlog == queued - done
+	InodeDestroyDoneOps               = "proxyfs.inode.destroy.done.operations"
+	InodeDestroyRetryOps              = "proxyfs.inode.destroy.log-segment.retry.operations"
 	InodeGetMetadataOps               = "proxyfs.inode.get_metadata.operations"
 	InodeGetTypeOps                   = "proxyfs.inode.get_type.operations"
+	InodeSetAttrsOps                  = "proxyfs.inode.set_attrs.operations"
+	InodePinOps                       = "proxyfs.inode.pin.operations"
+	InodeUnpinOps                     = "proxyfs.inode.unpin.operations"
+	InodeClockSkewOps                 = "proxyfs.inode.clock.skew.operations"
+	InodePoolHitOps                   = "proxyfs.inode.pool.hit.operations"
+	InodePoolMissOps                  = "proxyfs.inode.pool.miss.operations"
 	SymlinkCreateOps                  = "proxyfs.inode.symlink.create.operations"
+	SpecialCreateOps                  = "proxyfs.inode.special.create.operations"
 	SymlinkReadOps                    = "proxyfs.inode.symlink.read.operations"
 	JrpcfsIoWriteOps                  = "proxyfs.jrpcfs.write.operations"
 	JrpcfsIoWriteOps4K                = "proxyfs.jrpcfs.write.operations.size-up-to-4KB"
@@ -73,7 +73,15 @@ func ContainerDelete(accountName string, containerName string) (err error) {
 
 // ContainerGet invokes HTTP GET on the named Swift Container.
 func ContainerGet(accountName string, containerName string) (headers map[string][]string, objectList []string, err error) {
-	return containerGetWithRetry(accountName, containerName)
+	return containerGetWithRetry(accountName, containerName, "")
+}
+
+// ContainerGetAfter invokes HTTP GET on the named Swift Container listing only Objects following marker.
+//
+// As Swift limits the Objects listed per GET, a complete listing requires repeated calls (each passing
+// the last Object previously listed as marker) until an empty objectList is returned.
+func ContainerGetAfter(accountName string, containerName string, marker string) (headers map[string][]string, objectList []string, err error) {
+	return containerGetWithRetry(accountName, containerName, marker)
 }
 
 // ContainerHead invokes HTTP HEAD on the named Swift Container.
@@ -121,7 +129,22 @@ func ObjectFetchChunkedPutContext(accountName string, containerName string, obje
 
 // ObjectGet invokes HTTP GET on the named Swift Object for the specified byte range.
 func ObjectGet(accountName string, containerName string, objectName string, offset uint64, length uint64) (buf []byte, err error) {
-	return objectGetWithRetry(accountName, containerName, objectName, offset, length)
+	return objectGetWithRetry(accountName, containerName, objectName, offset, length, nil)
+}
+
+// ObjectGetInto invokes HTTP GET on the named Swift Object for the len(buf) bytes at offset, reading the
+// response directly into buf. It returns the number of bytes placed in buf (fewer than len(buf) only if
+// the Object ends before offset+len(buf)).
+func ObjectGetInto(accountName string, containerName string, objectName string, offset uint64, buf []byte) (n uint64, err error) {
+	if 0 == len(buf) {
+		return
+	}
+
+	got, err := objectGetWithRetry(accountName, containerName, objectName, offset, uint64(len(buf)), buf)
+	if nil == err {
+		n = uint64(len(got))
+	}
+	return
 }
 
 // ObjectHead invokes HTTP HEAD on the named Swift Object.
@@ -560,6 +560,25 @@ func testOps(t *testing.T) {
 		t.Fatalf("ContainerGet(\"TestAccount\", \"TestContainer\") didn't return expected objectList")
 	}
 
+	// Send a GET for container "TestContainer" with markers before & at object "FooBar"
+
+	_, objectList, err = ContainerGetAfter("TestAccount", "TestContainer", "Foo")
+	if nil != err {
+		tErr := fmt.Sprintf("ContainerGetAfter(\"TestAccount\", \"TestContainer\", \"Foo\") failed: %v", err)
+		t.Fatal(tErr)
+	}
+	if (1 != len(objectList)) || ("FooBar" != objectList[0]) {
+		t.Fatalf("ContainerGetAfter(\"TestAccount\", \"TestContainer\", \"Foo\") didn't return expected objectList")
+	}
+	_, objectList, err = ContainerGetAfter("TestAccount", "TestContainer", "FooBar")
+	if nil != err {
+		tErr := fmt.Sprintf("ContainerGetAfter(\"TestAccount\", \"TestContainer\", \"FooBar\") failed: %v", err)
+		t.Fatal(tErr)
+	}
+	if 0 != len(objectList) {
+		t.Fatalf("ContainerGetAfter(\"TestAccount\", \"TestContainer\", \"FooBar\") didn't return expected objectList")
+	}
+
 	// Send a HEAD for object "FooBar" expecting Content-Length: 5
 
 	objectHeaders, err := ObjectHead("TestAccount", "TestContainer", "FooBar")
@@ -599,6 +618,18 @@ func testOps(t *testing.T) {
 		t.Fatalf(tErr)
 	}
 
+	// Send a range GET of bytes at offset 2 into a 2-byte buffer for object "FooBar" expecting []byte{0xCC, 0xDD}
+
+	getIntoBuf := make([]byte, 2)
+	getIntoLen, err := ObjectGetInto("TestAccount", "TestContainer", "FooBar", uint64(2), getIntoBuf)
+	if nil != err {
+		tErr := fmt.Sprintf("ObjectGetInto(\"TestAccount\", \"TestContainer\", \"FooBar\", uint64(2), getIntoBuf) failed: %v", err)
+		t.Fatal(tErr)
+	}
+	if (uint64(2) != getIntoLen) || (0 != bytes.Compare([]byte{0xCC, 0xDD}, getIntoBuf)) {
+		t.Fatalf("ObjectGetInto(\"TestAccount\", \"TestContainer\", \"FooBar\", uint64(2), getIntoBuf) didn't fill getIntoBuf as expected")
+	}
+
 	// Send a full GET for object "FooBar" expecting []byte{0xAA, 0xBB, 0xCC, 0xDD, OxEE}
 
 	loadBuf, err := ObjectLoad("TestAccount", "TestContainer", "FooBar")
@@ -4,6 +4,7 @@ package swiftclient
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/swiftstack/ProxyFS/blunder"
 	"github.com/swiftstack/ProxyFS/logger"
@@ -74,7 +75,7 @@ func containerDelete(accountName string, containerName string) (err error) {
 	return
 }
 
-func containerGetWithRetry(accountName string, containerName string) (map[string][]string, []string, error) {
+func containerGetWithRetry(accountName string, containerName string, marker string) (map[string][]string, []string, error) {
 	// request is a function that, through the miracle of closure, calls
 	// containerGet() with the paramaters passed to this function, stashes
 	// the relevant return values into the local variables of this function,
@@ -86,7 +87,7 @@ func containerGetWithRetry(accountName string, containerName string) (map[string
 	)
 	request := func() (bool, error) {
 		var err error
-		headers, objectList, err = containerGet(accountName, containerName)
+		headers, objectList, err = containerGet(accountName, containerName, marker)
 		return true, err
 	}
 
@@ -100,7 +101,7 @@ func containerGetWithRetry(accountName string, containerName string) (map[string
 	err = retryObj.RequestWithRetry(request, &opname, &statnm)
 	return headers, objectList, err
 }
-func containerGet(accountName string, containerName string) (headers map[string][]string, objectList []string, err error) {
+func containerGet(accountName string, containerName string, marker string) (headers map[string][]string, objectList []string, err error) {
 	var (
 		connection *connectionStruct
 		fsErr      blunder.FsError
@@ -110,7 +111,12 @@ func containerGet(accountName string, containerName string) (headers map[string]
 
 	connection = acquireNonChunkedConnection()
 
-	err = writeHTTPRequestLineAndHeaders(connection.tcpConn, "GET", "/"+swiftVersion+"/"+accountName+"/"+containerName, nil)
+	path := "/" + swiftVersion + "/" + accountName + "/" + containerName
+	if "" != marker {
+		path += "?marker=" + url.QueryEscape(marker)
+	}
+
+	err = writeHTTPRequestLineAndHeaders(connection.tcpConn, "GET", path, nil)
 	if nil != err {
 		releaseNonChunkedConnection(connection, false)
 		err = blunder.AddError(err, blunder.BadHTTPGetError)
@@ -126,7 +126,7 @@ func objectCopy(srcAccountName string, srcContainerName string, srcObjectName st
 
 			chunk, err = objectTailWithRetry(srcAccountName, srcContainerName, srcObjectName, chunkSize)
 		} else {
-			chunk, err = objectGetWithRetry(srcAccountName, srcContainerName, srcObjectName, srcObjectPosition, chunkSize)
+			chunk, err = objectGetWithRetry(srcAccountName, srcContainerName, srcObjectName, srcObjectPosition, chunkSize, nil)
 		}
 
 		srcObjectPosition += chunkSize
@@ -281,7 +281,7 @@ func objectDeleteSync(accountName string, containerName string, objectName strin
 }
 
 func objectGetWithRetry(accountName string, containerName string, objectName string,
-	offset uint64, length uint64) ([]byte, error) {
+	offset uint64, length uint64, dst []byte) ([]byte, error) {
 
 	// request is a function that, through the miracle of closure, calls
 	// objectGet() with the paramaters passed to this function, stashes the
@@ -293,7 +293,7 @@ func objectGetWithRetry(accountName string, containerName string, objectName str
 	)
 	request := func() (bool, error) {
 		var err error
-		buf, err = objectGet(accountName, containerName, objectName, offset, length)
+		buf, err = objectGet(accountName, containerName, objectName, offset, length, dst)
 		return true, err
 	}
 
@@ -308,7 +308,9 @@ func objectGetWithRetry(accountName string, containerName string, objectName str
 	return buf, err
 }
 
-func objectGet(accountName string, containerName string, objectName string, offset uint64, length uint64) (buf []byte, err error) {
+// objectGet returns the requested byte range. If dst is non-nil, the data is read directly into (and
+// returned as a prefix of) dst rather than into a freshly allocated buf.
+func objectGet(accountName string, containerName string, objectName string, offset uint64, length uint64, dst []byte) (buf []byte, err error) {
 	var (
 		connection    *connectionStruct
 		chunk         []byte
@@ -349,7 +351,11 @@ func objectGet(accountName string, containerName string, objectName string, offs
 	}
 
 	if parseTransferEncoding(headers) {
-		buf = make([]byte, 0)
+		if nil == dst {
+			buf = make([]byte, 0)
+		} else {
+			buf = dst[:0]
+		}
 		for {
 			chunk, err = readHTTPChunk(connection.tcpConn)
 			if nil != err {
@@ -363,6 +369,13 @@ func objectGet(accountName string, containerName string, objectName string, offs
 				break
 			}
 
+			if (nil != dst) && ((len(buf) + len(chunk)) > len(dst)) {
+				releaseNonChunkedConnection(connection, false)
+				err = blunder.NewError(blunder.BadHTTPGetError, "GET %s/%s/%s returned more than the %d bytes requested", accountName, containerName, objectName, len(dst))
+				logger.ErrorfWithError(err, "swiftclient.objectGet(\"%v/%v/%v\") got oversized response", accountName, containerName, objectName)
+				return
+			}
+
 			buf = append(buf, chunk...)
 		}
 	} else {
@@ -374,8 +387,28 @@ func objectGet(accountName string, containerName string, objectName string, offs
 			return
 		}
 
+		if (nil != dst) && (contentLength > len(dst)) {
+			releaseNonChunkedConnection(connection, false)
+			err = blunder.NewError(blunder.BadHTTPGetError, "GET %s/%s/%s returned more than the %d bytes requested", accountName, containerName, objectName, len(dst))
+			logger.ErrorfWithError(err, "swiftclient.objectGet(\"%v/%v/%v\") got oversized response", accountName, containerName, objectName)
+			return
+		}
+
 		if 0 == contentLength {
-			buf = make([]byte, 0)
+			if nil == dst {
+				buf = make([]byte, 0)
+			} else {
+				buf = dst[:0]
+			}
+		} else if nil != dst {
+			buf = dst[:contentLength]
+			err = readBytesFromTCPConnInto(connection.tcpConn, buf)
+			if nil != err {
+				releaseNonChunkedConnection(connection, false)
+				err = blunder.AddError(err, blunder.BadHTTPGetError)
+				logger.ErrorfWithError(err, "swiftclient.objectGet(\"%v/%v/%v\") got readBytesFromTCPConnInto() error", accountName, containerName, objectName)
+				return
+			}
 		} else {
 			buf, err = readBytesFromTCPConn(connection.tcpConn, contentLength)
 			if nil != err {
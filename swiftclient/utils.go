@@ -292,13 +292,21 @@ func readByteFromTCPConn(tcpConn *net.TCPConn) (b byte, err error) {
 }
 
 func readBytesFromTCPConn(tcpConn *net.TCPConn, bufLen int) (buf []byte, err error) {
+	buf = make([]byte, bufLen)
+
+	err = readBytesFromTCPConnInto(tcpConn, buf)
+
+	return
+}
+
+// readBytesFromTCPConnInto fills buf from tcpConn.
+func readBytesFromTCPConnInto(tcpConn *net.TCPConn, buf []byte) (err error) {
 	var (
 		numBytesRead int
+		bufLen       = len(buf)
 		bufPos       = int(0)
 	)
 
-	buf = make([]byte, bufLen)
-
 	for bufPos < bufLen {
 		numBytesRead, err = tcpConn.Read(buf[bufPos:])
 		if nil != err {
@@ -16,6 +16,7 @@ import (
 )
 
 var (
+	confSectionPrefixMutex                       sync.RWMutex // protects the ...ConfSectionPrefix vars below
 	volumeNameConfSectionPrefix                  = "Volume:"
 	physicalContinaerLayoutNameConfSectionPrefix = "PhysicalContainerLayout:"
 	flowControlNameConfSectionPrefix             = "FlowControl:"
@@ -46,6 +47,9 @@ func AdjustConfSectionNamespacingAsNecessary(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	confSectionPrefixMutex.Lock()
+	defer confSectionPrefixMutex.Unlock()
+
 	if unNamespacedWhoAmISectionExists {
 		volumeNameConfSectionPrefix = ""
 		physicalContinaerLayoutNameConfSectionPrefix = ""
@@ -63,22 +67,30 @@ func AdjustConfSectionNamespacingAsNecessary(confMap conf.ConfMap) (err error) {
 }
 
 func VolumeNameConfSection(volumeName string) (sectionName string) {
+	confSectionPrefixMutex.RLock()
 	sectionName = volumeNameConfSectionPrefix + volumeName
+	confSectionPrefixMutex.RUnlock()
 	return
 }
 
 func PhysicalContainerLayoutNameConfSection(physicalContainerLayoutName string) (sectionName string) {
+	confSectionPrefixMutex.RLock()
 	sectionName = physicalContinaerLayoutNameConfSectionPrefix + physicalContainerLayoutName
+	confSectionPrefixMutex.RUnlock()
 	return
 }
 
 func FlowControlNameConfSection(flowControlName string) (sectionName string) {
+	confSectionPrefixMutex.RLock()
 	sectionName = flowControlNameConfSectionPrefix + flowControlName
+	confSectionPrefixMutex.RUnlock()
 	return
 }
 
 func PeerNameConfSection(peerName string) (sectionName string) {
+	confSectionPrefixMutex.RLock()
 	sectionName = peerNameConfSectionPrefix + peerName
+	confSectionPrefixMutex.RUnlock()
 	return
 }
 